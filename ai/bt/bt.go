@@ -0,0 +1,152 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+// Package bt is a behavior tree for scripting character decisions.
+// A tree of nodes is ticked each update. Leaf nodes check conditions
+// and run actions. Composite nodes decide which children run, and
+// decorator nodes change the result of their child. Nodes share data
+// using the tree blackboard, eg:
+//
+//	tree := bt.NewTree(bt.Selector(
+//		bt.Sequence(
+//			bt.Condition(func(bb *bt.Blackboard) bool { return bb.Has("enemy") }),
+//			bt.Action(attack),
+//		),
+//		bt.Sequence(bt.Action(patrol), bt.Wait(2*time.Second)),
+//	))
+//	guard.RunBehavior(4, tree) // tick from the engine update loop.
+//
+// Package bt is provided as part of the vu (virtual universe) 3D engine.
+package bt
+
+// bt.go has the tree, node interfaces, and blackboard.
+//	 nodes.go : leaf, composite, and decorator nodes.
+
+import (
+	"time"
+)
+
+// Status is the result of ticking a node.
+type Status int
+
+// Node status values.
+const (
+	Running Status = iota // node needs more ticks to finish.
+	Success               // node finished and succeeded.
+	Failure               // node finished and failed.
+)
+
+// String returns the status name.
+func (s Status) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case Success:
+		return "success"
+	case Failure:
+		return "failure"
+	}
+	return "unknown"
+}
+
+// Node is a behavior tree node. Tick is called with the time since the
+// node was last ticked. A node that returns Running is ticked again on
+// the next tree tick unless a parent node stops it. Reset is called when
+// a node is stopped, or before it starts again after finishing.
+type Node interface {
+	Tick(bb *Blackboard, delta time.Duration) Status
+	Reset()
+}
+
+// Composite is a node that decides which of its children to tick.
+type Composite interface {
+	Node
+	Children() []Node
+}
+
+// Decorator is a node that changes how its child is ticked or
+// the child result.
+type Decorator interface {
+	Node
+	Child() Node
+}
+
+// Tree ticks a root node. Finished trees start again from the
+// root on the next tick.
+type Tree struct {
+	Blackboard *Blackboard   // data shared by the tree nodes.
+	Interval   time.Duration // minimum time between root ticks.
+
+	root    Node
+	elapsed time.Duration // time since the root was last ticked.
+	status  Status        // last root status.
+}
+
+// NewTree returns a tree for the given root node with an empty blackboard.
+// The tree is ticked each time Tick is called.
+func NewTree(root Node) *Tree {
+	return &Tree{Blackboard: NewBlackboard(), root: root}
+}
+
+// Tick adds the time since the last call and ticks the root node once
+// the tree interval has passed. Returns the last root status.
+// Expected to be called once each engine update.
+func (t *Tree) Tick(delta time.Duration) Status {
+	t.elapsed += delta
+	if t.elapsed < t.Interval {
+		return t.status
+	}
+	t.status = t.root.Tick(t.Blackboard, t.elapsed)
+	t.elapsed = 0
+	if t.status != Running {
+		t.root.Reset() // start again next tick.
+	}
+	return t.status
+}
+
+// Status returns the last root status.
+func (t *Tree) Status() Status { return t.status }
+
+// Reset stops any running nodes so that the next tick starts
+// from the root. The blackboard is unchanged.
+func (t *Tree) Reset() {
+	t.root.Reset()
+	t.elapsed, t.status = 0, Running
+}
+
+// Blackboard is named data shared by the nodes of a tree.
+type Blackboard struct {
+	values map[string]any
+}
+
+// NewBlackboard returns an empty blackboard.
+func NewBlackboard() *Blackboard {
+	return &Blackboard{values: map[string]any{}}
+}
+
+// Set saves the value for the given key.
+func (bb *Blackboard) Set(key string, value any) { bb.values[key] = value }
+
+// Get returns the value for the given key and
+// false if there is no value for the key.
+func (bb *Blackboard) Get(key string) (value any, ok bool) {
+	value, ok = bb.values[key]
+	return value, ok
+}
+
+// Has returns true if there is a value for the given key.
+func (bb *Blackboard) Has(key string) bool {
+	_, ok := bb.values[key]
+	return ok
+}
+
+// Delete removes the value for the given key.
+func (bb *Blackboard) Delete(key string) { delete(bb.values, key) }
+
+// Value returns the blackboard value for the key as type T. Returns
+// the zero value and false if there is no value or it is not a T, eg:
+//
+//	target, ok := bt.Value[lin.V3](bb, "target")
+func Value[T any](bb *Blackboard, key string) (value T, ok bool) {
+	value, ok = bb.values[key].(T)
+	return value, ok
+}
@@ -0,0 +1,150 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package bt
+
+import (
+	"testing"
+	"time"
+)
+
+// counter returns an action that counts its ticks and returns
+// the given results in order, repeating the last result.
+func counter(ticks *int, results ...Status) Action {
+	return func(bb *Blackboard, delta time.Duration) Status {
+		*ticks++
+		return results[min(*ticks, len(results))-1]
+	}
+}
+
+// go test -run BT
+func TestBT(t *testing.T) {
+	ms := time.Millisecond
+
+	// go test -run BT/sequence
+	t.Run("sequence", func(t *testing.T) {
+		a, b, c := 0, 0, 0
+		seq := Sequence(counter(&a, Success), counter(&b, Running, Success), counter(&c, Failure))
+		if s := seq.Tick(nil, ms); s != Running {
+			t.Fatalf("expected running got %s", s)
+		}
+		if s := seq.Tick(nil, ms); s != Failure || a != 1 || b != 2 || c != 1 {
+			t.Errorf("expected failure continuing from running child got %s %d %d %d", s, a, b, c)
+		}
+		if len(seq.Children()) != 3 {
+			t.Errorf("expected children")
+		}
+	})
+
+	// go test -run BT/selector
+	t.Run("selector", func(t *testing.T) {
+		a, b, c := 0, 0, 0
+		sel := Selector(counter(&a, Failure), counter(&b, Success), counter(&c, Success))
+		if s := sel.Tick(nil, ms); s != Success || a != 1 || b != 1 || c != 0 {
+			t.Errorf("expected first success got %s %d %d %d", s, a, b, c)
+		}
+		sel.Reset()
+		sel = Selector(Condition(func(*Blackboard) bool { return false }))
+		if s := sel.Tick(nil, ms); s != Failure {
+			t.Errorf("expected failure got %s", s)
+		}
+	})
+
+	// go test -run BT/parallel
+	t.Run("parallel", func(t *testing.T) {
+		a, b, c := 0, 0, 0
+		par := Parallel(2, counter(&a, Success), counter(&b, Running, Running, Success), counter(&c, Failure))
+		for i := 0; i < 2; i++ {
+			if s := par.Tick(nil, ms); s != Running {
+				t.Fatalf("expected running got %s", s)
+			}
+		}
+		if s := par.Tick(nil, ms); s != Success || a != 1 || b != 3 || c != 1 {
+			t.Errorf("expected finished children to stay finished got %s %d %d %d", s, a, b, c)
+		}
+		par = Parallel(2, counter(&a, Failure), Wait(time.Second), counter(&c, Failure))
+		if s := par.Tick(nil, ms); s != Failure {
+			t.Errorf("expected failure got %s", s)
+		}
+	})
+
+	// go test -run BT/decorators
+	t.Run("decorators", func(t *testing.T) {
+		if s := Invert(Condition(func(*Blackboard) bool { return true })).Tick(nil, ms); s != Failure {
+			t.Errorf("expected invert got %s", s)
+		}
+		if s := Succeed(Condition(func(*Blackboard) bool { return false })).Tick(nil, ms); s != Success {
+			t.Errorf("expected succeed got %s", s)
+		}
+		ticks := 0
+		rep := Repeat(3, counter(&ticks, Success))
+		for i := 0; i < 2; i++ {
+			if s := rep.Tick(nil, ms); s != Running {
+				t.Fatalf("expected repeat running got %s", s)
+			}
+		}
+		if s := rep.Tick(nil, ms); s != Success || ticks != 3 {
+			t.Errorf("expected repeat success got %s %d", s, ticks)
+		}
+		ticks = 0
+		retry := Retry(5, counter(&ticks, Failure, Failure, Success))
+		for retry.Tick(nil, ms) == Running {
+		}
+		if ticks != 3 {
+			t.Errorf("expected success on third try got %d", ticks)
+		}
+		ticks = 0
+		retry = Retry(2, counter(&ticks, Failure))
+		if s, s2 := retry.Tick(nil, ms), retry.Tick(nil, ms); s != Running || s2 != Failure {
+			t.Errorf("expected retries to run out got %s %s", s, s2)
+		}
+		timeout := Timeout(10*ms, Wait(time.Second))
+		if s := timeout.Tick(nil, 5*ms); s != Running {
+			t.Errorf("expected running got %s", s)
+		}
+		if s := timeout.Tick(nil, 5*ms); s != Failure {
+			t.Errorf("expected timeout got %s", s)
+		}
+		if d := Invert(Wait(ms)); d.Child() == nil {
+			t.Errorf("expected child")
+		}
+	})
+
+	// go test -run BT/tree
+	t.Run("tree", func(t *testing.T) {
+		patrols := 0
+		tree := NewTree(Selector(
+			Sequence(
+				Condition(func(bb *Blackboard) bool { return bb.Has("enemy") }),
+				Action(func(bb *Blackboard, delta time.Duration) Status {
+					bb.Delete("enemy")
+					bb.Set("attacked", true)
+					return Success
+				}),
+			),
+			Sequence(
+				Action(func(bb *Blackboard, delta time.Duration) Status { patrols++; return Success }),
+				Wait(20*ms),
+			),
+		))
+		tree.Interval = 10 * ms
+		if s := tree.Tick(5 * ms); s != Running || patrols != 0 {
+			t.Errorf("expected no tick before the interval got %s %d", s, patrols)
+		}
+		tree.Tick(5 * ms) // patrol and start waiting.
+		tree.Tick(10 * ms)
+		if s := tree.Tick(10 * ms); s != Success || patrols != 1 {
+			t.Errorf("expected patrol to finish got %s %d", s, patrols)
+		}
+		tree.Blackboard.Set("enemy", "orc")
+		tree.Tick(10 * ms) // restarts from the root.
+		if attacked, ok := Value[bool](tree.Blackboard, "attacked"); !ok || !attacked || tree.Status() != Success {
+			t.Errorf("expected attack got %t %s", attacked, tree.Status())
+		}
+		if _, ok := Value[int](tree.Blackboard, "attacked"); ok {
+			t.Errorf("expected wrong type to fail")
+		}
+		if _, ok := tree.Blackboard.Get("enemy"); ok {
+			t.Errorf("expected enemy to be removed")
+		}
+	})
+}
@@ -0,0 +1,336 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package bt
+
+// nodes.go has the standard behavior tree nodes. Composites remember
+// their running child so that later ticks continue from that child
+// instead of starting again from the first child.
+
+import (
+	"time"
+)
+
+// =============================================================================
+// leaf nodes.
+
+// Action is a leaf node that runs application code.
+type Action func(bb *Blackboard, delta time.Duration) Status
+
+// Tick calls the action.
+func (a Action) Tick(bb *Blackboard, delta time.Duration) Status { return a(bb, delta) }
+
+// Reset does nothing since actions keep their state in the blackboard.
+func (a Action) Reset() {}
+
+// Condition is a leaf node that succeeds if the condition is true
+// and fails otherwise.
+type Condition func(bb *Blackboard) bool
+
+// Tick checks the condition.
+func (c Condition) Tick(bb *Blackboard, delta time.Duration) Status {
+	if c(bb) {
+		return Success
+	}
+	return Failure
+}
+
+// Reset does nothing since conditions have no state.
+func (c Condition) Reset() {}
+
+// Wait returns a leaf node that runs for the given time and then succeeds.
+func Wait(duration time.Duration) Node {
+	return &wait{duration: duration}
+}
+
+// wait is a timer leaf node.
+type wait struct {
+	duration time.Duration
+	elapsed  time.Duration
+}
+
+// Tick adds the time and succeeds once the duration has passed.
+func (w *wait) Tick(bb *Blackboard, delta time.Duration) Status {
+	w.elapsed += delta
+	if w.elapsed >= w.duration {
+		return Success
+	}
+	return Running
+}
+
+// Reset restarts the timer.
+func (w *wait) Reset() { w.elapsed = 0 }
+
+// =============================================================================
+// composite nodes.
+
+// composite is the state shared by the composite nodes.
+type composite struct {
+	children []Node
+	current  int // running child.
+}
+
+// Children returns the child nodes.
+func (c *composite) Children() []Node { return c.children }
+
+// Reset stops the running child and restarts from the first child.
+func (c *composite) Reset() {
+	for _, child := range c.children {
+		child.Reset()
+	}
+	c.current = 0
+}
+
+// Sequence returns a composite node that ticks its children in order.
+// It fails when a child fails and succeeds when all children succeed.
+func Sequence(children ...Node) Composite {
+	return &sequence{composite{children: children}}
+}
+
+// sequence runs its children until one fails.
+type sequence struct{ composite }
+
+// Tick continues from the running child.
+func (s *sequence) Tick(bb *Blackboard, delta time.Duration) Status {
+	for ; s.current < len(s.children); s.current++ {
+		switch s.children[s.current].Tick(bb, delta) {
+		case Running:
+			return Running
+		case Failure:
+			return Failure
+		}
+		delta = 0 // only the first child ticked gets the elapsed time.
+	}
+	return Success
+}
+
+// Selector returns a composite node that ticks its children in order.
+// It succeeds when a child succeeds and fails when all children fail.
+// Children are usually ordered from highest to lowest priority.
+func Selector(children ...Node) Composite {
+	return &selector{composite{children: children}}
+}
+
+// selector runs its children until one succeeds.
+type selector struct{ composite }
+
+// Tick continues from the running child.
+func (s *selector) Tick(bb *Blackboard, delta time.Duration) Status {
+	for ; s.current < len(s.children); s.current++ {
+		switch s.children[s.current].Tick(bb, delta) {
+		case Running:
+			return Running
+		case Success:
+			return Success
+		}
+		delta = 0 // only the first child ticked gets the elapsed time.
+	}
+	return Failure
+}
+
+// Parallel returns a composite node that ticks all of its children each
+// tick. It succeeds once the given number of children succeed and fails
+// once too many children fail for that to happen. Running children are
+// stopped when the parallel node finishes.
+func Parallel(successes int, children ...Node) Composite {
+	return &parallel{composite: composite{children: children}, need: successes, results: make([]Status, len(children))}
+}
+
+// parallel runs all of its unfinished children each tick.
+type parallel struct {
+	composite
+	need    int      // successes needed.
+	results []Status // child results, Running if unfinished.
+}
+
+// Tick ticks the unfinished children.
+func (p *parallel) Tick(bb *Blackboard, delta time.Duration) Status {
+	successes, failures := 0, 0
+	for i, child := range p.children {
+		if p.results[i] == Running {
+			p.results[i] = child.Tick(bb, delta)
+		}
+		switch p.results[i] {
+		case Success:
+			successes++
+		case Failure:
+			failures++
+		}
+	}
+	status := Running
+	switch {
+	case successes >= p.need:
+		status = Success
+	case len(p.children)-failures < p.need:
+		status = Failure
+	}
+	if status != Running {
+		p.Reset()
+	}
+	return status
+}
+
+// Reset stops the running children.
+func (p *parallel) Reset() {
+	p.composite.Reset()
+	clear(p.results) // Running is zero.
+}
+
+// =============================================================================
+// decorator nodes.
+
+// decorator is the state shared by the decorator nodes.
+type decorator struct {
+	child Node
+}
+
+// Child returns the decorated node.
+func (d *decorator) Child() Node { return d.child }
+
+// Reset stops the child.
+func (d *decorator) Reset() { d.child.Reset() }
+
+// Invert returns a decorator that swaps the success and failure
+// results of its child.
+func Invert(child Node) Decorator {
+	return &invert{decorator{child: child}}
+}
+
+// invert swaps its child result.
+type invert struct{ decorator }
+
+// Tick inverts the child result.
+func (n *invert) Tick(bb *Blackboard, delta time.Duration) Status {
+	switch n.child.Tick(bb, delta) {
+	case Success:
+		return Failure
+	case Failure:
+		return Success
+	}
+	return Running
+}
+
+// Succeed returns a decorator that succeeds when its child finishes,
+// eg: for optional steps in a sequence.
+func Succeed(child Node) Decorator {
+	return &succeed{decorator{child: child}}
+}
+
+// succeed ignores child failures.
+type succeed struct{ decorator }
+
+// Tick succeeds when the child finishes.
+func (n *succeed) Tick(bb *Blackboard, delta time.Duration) Status {
+	if n.child.Tick(bb, delta) == Running {
+		return Running
+	}
+	return Success
+}
+
+// Repeat returns a decorator that runs its child the given number of
+// times, or forever if times is zero. It fails if the child fails.
+// The child is run at most once each tick.
+func Repeat(times int, child Node) Decorator {
+	return &repeat{decorator: decorator{child: child}, times: times}
+}
+
+// repeat restarts its child when it succeeds.
+type repeat struct {
+	decorator
+	times int // runs needed, zero for forever.
+	count int // successful runs.
+}
+
+// Tick runs the child.
+func (n *repeat) Tick(bb *Blackboard, delta time.Duration) Status {
+	switch n.child.Tick(bb, delta) {
+	case Running:
+		return Running
+	case Failure:
+		n.Reset()
+		return Failure
+	}
+	n.child.Reset()
+	n.count++
+	if n.times > 0 && n.count >= n.times {
+		n.count = 0
+		return Success
+	}
+	return Running
+}
+
+// Reset stops the child and restarts the count.
+func (n *repeat) Reset() {
+	n.decorator.Reset()
+	n.count = 0
+}
+
+// Retry returns a decorator that runs its child until it succeeds,
+// up to the given number of times, or forever if times is zero.
+// The child is run at most once each tick.
+func Retry(times int, child Node) Decorator {
+	return &retry{decorator: decorator{child: child}, times: times}
+}
+
+// retry restarts its child when it fails.
+type retry struct {
+	decorator
+	times int // tries allowed, zero for forever.
+	count int // failed tries.
+}
+
+// Tick runs the child.
+func (n *retry) Tick(bb *Blackboard, delta time.Duration) Status {
+	switch n.child.Tick(bb, delta) {
+	case Running:
+		return Running
+	case Success:
+		n.Reset()
+		return Success
+	}
+	n.child.Reset()
+	n.count++
+	if n.times > 0 && n.count >= n.times {
+		n.count = 0
+		return Failure
+	}
+	return Running
+}
+
+// Reset stops the child and restarts the count.
+func (n *retry) Reset() {
+	n.decorator.Reset()
+	n.count = 0
+}
+
+// Timeout returns a decorator that fails if its child is still
+// running after the given time. The child is stopped when it fails.
+func Timeout(duration time.Duration, child Node) Decorator {
+	return &timeout{decorator: decorator{child: child}, duration: duration}
+}
+
+// timeout limits how long its child can run.
+type timeout struct {
+	decorator
+	duration time.Duration
+	elapsed  time.Duration
+}
+
+// Tick runs the child until the time is up.
+func (n *timeout) Tick(bb *Blackboard, delta time.Duration) Status {
+	n.elapsed += delta
+	status := n.child.Tick(bb, delta)
+	if status == Running && n.elapsed >= n.duration {
+		n.Reset()
+		return Failure
+	}
+	if status != Running {
+		n.elapsed = 0
+	}
+	return status
+}
+
+// Reset stops the child and restarts the timer.
+func (n *timeout) Reset() {
+	n.decorator.Reset()
+	n.elapsed = 0
+}
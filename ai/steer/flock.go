@@ -0,0 +1,78 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package steer
+
+// flock.go has the group behaviors. Flocks are small enough that each
+// boid checks every other boid. Larger groups should be partitioned by
+// the app so that each boid only sees the boids near it.
+
+import (
+	"github.com/gazed/vu/math/lin"
+)
+
+// Boid is a flock member location and velocity.
+type Boid struct {
+	At       lin.V3
+	Velocity lin.V3
+}
+
+// Flock weights the boids flocking rules. Each rule only uses the
+// boids within the neighbour radius.
+type Flock struct {
+	Radius     float64 // neighbour distance.
+	Separation float64 // weight for moving away from crowding neighbours.
+	Alignment  float64 // weight for heading the same way as neighbours.
+	Cohesion   float64 // weight for moving towards the neighbours center.
+}
+
+// NewFlock returns a flock with the given neighbour radius and
+// default rule weights.
+func NewFlock(radius float64) *Flock {
+	return &Flock{Radius: radius, Separation: 1.5, Alignment: 1, Cohesion: 1}
+}
+
+// Velocity returns the flocking velocity at the given speed for boid i.
+// Boids without neighbours keep their current velocity.
+func (f *Flock) Velocity(boids []Boid, i int, speed float64) lin.V3 {
+	b := &boids[i]
+	separate, align, center := lin.V3{}, lin.V3{}, lin.V3{}
+	neighbours := 0
+	for j := range boids {
+		other := &boids[j]
+		if j == i {
+			continue
+		}
+		dist := b.At.Dist(&other.At)
+		if dist >= f.Radius {
+			continue
+		}
+		neighbours++
+		align.Add(&align, &other.Velocity)
+		center.Add(&center, &other.At)
+		if dist > lin.Epsilon {
+			// push away more from closer neighbours.
+			away := lin.NewV3().Sub(&b.At, &other.At)
+			separate.Add(&separate, away.Scale(away, (f.Radius-dist)/(f.Radius*dist)))
+		}
+	}
+	if neighbours == 0 {
+		return b.Velocity
+	}
+	center.Scale(&center, 1/float64(neighbours))
+	desired := lin.NewV3().Scale(&separate, f.Separation*speed)
+	align = towards(lin.V3{}, align, speed)
+	desired.Add(desired, align.Scale(&align, f.Alignment))
+	cohere := towards(b.At, center, speed)
+	desired.Add(desired, cohere.Scale(&cohere, f.Cohesion))
+	return Limit(*desired, speed)
+}
+
+// Velocities sets the flocking velocity for each boid, see Velocity.
+// The desired slice is resized to match the boids and returned.
+func (f *Flock) Velocities(boids []Boid, speed float64, desired []lin.V3) []lin.V3 {
+	desired = append(desired[:0], make([]lin.V3, len(boids))...)
+	for i := range boids {
+		desired[i] = f.Velocity(boids, i, speed)
+	}
+	return desired
+}
@@ -0,0 +1,173 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+// Package steer moves characters using steering behaviors based on
+// Craig Reynolds "Steering Behaviors For Autonomous Characters":
+// https://www.red3d.com/cwr/steer/
+//
+// Each behavior returns a desired velocity. Desired velocities can be
+// weighted and added together, then applied using Steer so that the
+// character turns and speeds up smoothly. For example, an app moving
+// a part towards a target each update:
+//
+//	x, y, z := part.At()
+//	desired := steer.Arrive(lin.V3{X: x, Y: y, Z: z}, target, 4, 2)
+//	velocity = steer.Steer(velocity, desired, 8, dt)
+//	part.SetAt(x+velocity.X*dt, y+velocity.Y*dt, z+velocity.Z*dt)
+//
+// Package steer is provided as part of the vu (virtual universe) 3D engine.
+package steer
+
+// steer.go has the single character behaviors.
+//	 flock.go : group behaviors.
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Seek returns the velocity that moves from at
+// straight towards the target at the given speed.
+func Seek(at, target lin.V3, speed float64) lin.V3 {
+	return towards(at, target, speed)
+}
+
+// Flee returns the velocity that moves from at straight away from the
+// threat at the given speed. Returns zero if the threat is further
+// away than panicDist. A panicDist of zero always flees.
+func Flee(at, threat lin.V3, speed, panicDist float64) lin.V3 {
+	if panicDist > 0 && at.DistSqr(&threat) > panicDist*panicDist {
+		return lin.V3{}
+	}
+	return towards(threat, at, speed)
+}
+
+// Arrive returns the velocity that moves from at towards the target,
+// slowing down to stop at the target once it is within the slowing
+// distance.
+func Arrive(at, target lin.V3, speed, slowing float64) lin.V3 {
+	dist := at.Dist(&target)
+	if slowing > 0 && dist < slowing {
+		speed *= dist / slowing
+	}
+	return towards(at, target, speed)
+}
+
+// towards returns the velocity from a to b at the given speed.
+// Returns zero if a and b are the same point.
+func towards(a, b lin.V3, speed float64) lin.V3 {
+	v := lin.NewV3().Sub(&b, &a)
+	dist := v.Len()
+	if dist < lin.Epsilon {
+		return lin.V3{}
+	}
+	return *v.Scale(v, speed/dist)
+}
+
+// Steer returns the velocity changed towards the desired velocity
+// by at most accel meters per second per second over dt seconds.
+func Steer(velocity, desired lin.V3, accel, dt float64) lin.V3 {
+	change := lin.NewV3().Sub(&desired, &velocity)
+	*change = Limit(*change, accel*dt)
+	return *change.Add(change, &velocity)
+}
+
+// Limit returns v shortened to the max length if it is longer.
+func Limit(v lin.V3, max float64) lin.V3 {
+	if size := v.Len(); size > max {
+		v.Scale(&v, max/size)
+	}
+	return v
+}
+
+// Wander is a random walk that changes direction smoothly. A point
+// moves randomly around a circle in front of the character and the
+// character heads towards that point. Wandering is on the XZ plane.
+type Wander struct {
+	Distance float64 // circle distance in front of the character.
+	Radius   float64 // circle radius. Larger circles turn more.
+	Jitter   float64 // largest point move in radians per second.
+
+	angle float64 // point position on the circle.
+	rng   *rand.Rand
+}
+
+// NewWander returns a wander with default settings. The seed picks
+// the random walk so that characters can wander differently and
+// repeatably.
+func NewWander(seed int64) *Wander {
+	return &Wander{Distance: 2, Radius: 1, Jitter: 4, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Velocity returns the wander velocity at the given speed for a
+// character heading in the given direction. Characters that are not
+// moving head along -Z. Expected to be called once each update.
+func (w *Wander) Velocity(heading lin.V3, speed, dt float64) lin.V3 {
+	w.angle += (w.rng.Float64()*2 - 1) * w.Jitter * dt
+	heading.Y = 0
+	if heading.Len() < lin.Epsilon {
+		heading = lin.V3{Z: -1}
+	}
+	heading.Unit()
+	target := lin.NewV3().Scale(&heading, w.Distance)
+
+	// circle point relative to the heading.
+	sin, cos := math.Sincos(w.angle)
+	target.X += (heading.X*cos - heading.Z*sin) * w.Radius
+	target.Z += (heading.Z*cos + heading.X*sin) * w.Radius
+	return towards(lin.V3{}, *target, speed)
+}
+
+// Obstacle is a sphere to be avoided.
+type Obstacle struct {
+	At     lin.V3
+	Radius float64
+}
+
+// Avoid returns the velocity changed to steer a character of the given
+// radius around the closest obstacle in its path. Obstacles are looked
+// for ahead of the character up to the distance travelled in lookahead
+// seconds. The velocity is returned unchanged if the path is clear.
+func Avoid(at, velocity lin.V3, radius, lookahead float64, obstacles []Obstacle) lin.V3 {
+	speed := velocity.Len()
+	if speed < lin.Epsilon || len(obstacles) == 0 {
+		return velocity
+	}
+	ahead := *lin.NewV3().Scale(&velocity, 1/speed)
+	reach := speed * lookahead
+	closest, hit := reach, -1
+	for i := range obstacles {
+		ob := &obstacles[i]
+		offset := lin.NewV3().Sub(&ob.At, &at)
+		along := offset.Dot(&ahead) // distance along the path to the obstacle.
+		clear := ob.Radius + radius
+		if along < -clear || along-clear > closest {
+			continue // behind or too far ahead.
+		}
+		side := lin.NewV3().Scale(&ahead, along)
+		side.Sub(offset, side)
+		if side.Len() >= clear {
+			continue // path goes past the obstacle.
+		}
+		closest, hit = max(along, 0), i
+	}
+	if hit < 0 {
+		return velocity
+	}
+
+	// turn away from the obstacle center, harder for closer obstacles.
+	ob := &obstacles[hit]
+	away := lin.NewV3().Sub(&at, &ob.At)
+	away.Sub(away, lin.NewV3().Scale(&ahead, away.Dot(&ahead)))
+	if away.Len() < lin.Epsilon {
+		// heading straight at the center: pick a side.
+		away.Cross(&ahead, &lin.V3{Y: 1})
+		if away.Len() < lin.Epsilon {
+			away.SetS(1, 0, 0)
+		}
+	}
+	away.Unit().Scale(away, speed*(1-closest/reach))
+	avoid := lin.NewV3().Add(&velocity, away)
+	return *avoid.Scale(avoid, speed/max(avoid.Len(), lin.Epsilon))
+}
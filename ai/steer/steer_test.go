@@ -0,0 +1,133 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package steer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Steer
+func TestSteer(t *testing.T) {
+	at, target := lin.V3{}, lin.V3{X: 10}
+
+	// go test -run Steer/seek
+	t.Run("seek", func(t *testing.T) {
+		v := Seek(at, target, 3)
+		if !v.Aeq(&lin.V3{X: 3}) {
+			t.Errorf("expected seek towards target got %v", v)
+		}
+		if v := Seek(target, target, 3); !v.AeqZ() {
+			t.Errorf("expected no seek at the target got %v", v)
+		}
+		if v := Flee(at, target, 3, 0); !v.Aeq(&lin.V3{X: -3}) {
+			t.Errorf("expected flee from threat got %v", v)
+		}
+		if v := Flee(at, target, 3, 5); !v.AeqZ() {
+			t.Errorf("expected no flee from distant threat got %v", v)
+		}
+	})
+
+	// go test -run Steer/arrive
+	t.Run("arrive", func(t *testing.T) {
+		if v := Arrive(at, target, 4, 2); !v.Aeq(&lin.V3{X: 4}) {
+			t.Errorf("expected full speed far away got %v", v)
+		}
+		if v := Arrive(lin.V3{X: 9}, target, 4, 2); !v.Aeq(&lin.V3{X: 2}) {
+			t.Errorf("expected half speed when close got %v", v)
+		}
+
+		// stops at the target without overshooting.
+		pos, velocity, dt, furthest := at, lin.V3{}, 0.02, 0.0
+		for i := 0; i < 1000; i++ {
+			velocity = Steer(velocity, Arrive(pos, target, 4, 2), 8, dt)
+			pos.Add(&pos, lin.NewV3().Scale(&velocity, dt))
+			furthest = max(furthest, pos.X)
+		}
+		if math.Abs(pos.X-10) > 0.05 || furthest > 10.2 {
+			t.Errorf("expected stop at target got %v furthest %f", pos, furthest)
+		}
+	})
+
+	// go test -run Steer/steer
+	t.Run("steer", func(t *testing.T) {
+		v := Steer(lin.V3{}, lin.V3{X: 10}, 5, 0.1)
+		if !v.Aeq(&lin.V3{X: 0.5}) {
+			t.Errorf("expected limited acceleration got %v", v)
+		}
+		if v := Steer(lin.V3{X: 1}, lin.V3{X: 1.2}, 5, 0.1); !v.Aeq(&lin.V3{X: 1.2}) {
+			t.Errorf("expected desired velocity got %v", v)
+		}
+	})
+
+	// go test -run Steer/wander
+	t.Run("wander", func(t *testing.T) {
+		a, b := NewWander(7), NewWander(7)
+		heading := lin.V3{X: 1}
+		turned := false
+		for i := 0; i < 100; i++ {
+			va, vb := a.Velocity(heading, 2, 0.1), b.Velocity(heading, 2, 0.1)
+			if !va.Eq(&vb) {
+				t.Fatalf("expected repeatable wander got %v %v", va, vb)
+			}
+			if math.Abs(va.Len()-2) > 0.001 || va.Y != 0 {
+				t.Fatalf("expected wander speed on the ground got %v", va)
+			}
+			turned = turned || va.Z != 0
+			heading = va
+		}
+		if !turned {
+			t.Error("expected wander to turn")
+		}
+	})
+
+	// go test -run Steer/avoid
+	t.Run("avoid", func(t *testing.T) {
+		obstacles := []Obstacle{{At: lin.V3{X: 5, Z: 0.5}, Radius: 1}}
+		velocity := lin.V3{X: 2}
+		v := Avoid(at, velocity, 0.5, 4, obstacles)
+		if v.Z >= 0 || math.Abs(v.Len()-2) > 0.001 {
+			t.Errorf("expected turn away from obstacle got %v", v)
+		}
+		if v := Avoid(at, velocity, 0.5, 1, obstacles); !v.Eq(&velocity) {
+			t.Errorf("expected obstacle out of reach got %v", v)
+		}
+		if v := Avoid(at, lin.V3{Z: 2}, 0.5, 4, obstacles); !v.Eq(&lin.V3{Z: 2}) {
+			t.Errorf("expected clear path got %v", v)
+		}
+		if v := Avoid(at, velocity, 0.5, 4, []Obstacle{{At: lin.V3{X: 3}, Radius: 1}}); v.Aeq(&velocity) {
+			t.Errorf("expected turn from head on obstacle got %v", v)
+		}
+	})
+
+	// go test -run Steer/flock
+	t.Run("flock", func(t *testing.T) {
+		boids := []Boid{
+			{At: lin.V3{X: 0}, Velocity: lin.V3{X: 1}},
+			{At: lin.V3{X: 0.2}, Velocity: lin.V3{Z: 1}},
+			{At: lin.V3{X: 4.8}, Velocity: lin.V3{Z: 1}},
+			{At: lin.V3{X: 50}, Velocity: lin.V3{Z: -1}},
+		}
+		f := NewFlock(5)
+		desired := f.Velocities(boids, 2, nil)
+		if len(desired) != len(boids) {
+			t.Fatalf("expected velocity for each boid got %d", len(desired))
+		}
+		if desired[0].X >= 0 {
+			t.Errorf("expected separation from close neighbour got %v", desired[0])
+		}
+		if desired[2].X >= 0 || desired[2].Z <= 0 {
+			t.Errorf("expected cohesion and alignment got %v", desired[2])
+		}
+		if !desired[3].Eq(&boids[3].Velocity) {
+			t.Errorf("expected lone boid to keep its velocity got %v", desired[3])
+		}
+		for _, v := range desired {
+			if v.Len() > 2+lin.Epsilon {
+				t.Errorf("expected speed limit got %v", v)
+			}
+		}
+	})
+}
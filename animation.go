@@ -0,0 +1,270 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// animation.go plays skeletal animations for skinned models, eg:
+//
+//	eng.ImportAssets("anim3D.shd", "mrfixit.iqm")
+//	guy := scene.AddModel("shd:anim3D", "msh:mrfixit", "anm:mrfixit")
+//	guy.PlayAnimation("idle")
+//	...
+//	guy.BlendAnimation("run", 250*time.Millisecond)
+//
+// Each frame the joint poses for the current clip are sampled at the
+// clip time, interpolating between clip frames, and optionally blended
+// with the previous clip. The joint poses are combined into the bone
+// matrices that are passed to the shader to skin the mesh on the GPU.
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// PlayAnimation starts playing the named animation clip from the start.
+// The clip is played once the animation has loaded. The model shows its
+// bind pose until an animation is played.
+//
+// Depends on Entity.AddModel with an "anm:" animation asset.
+func (e *Entity) PlayAnimation(clip string) *Entity {
+	return e.BlendAnimation(clip, 0)
+}
+
+// BlendAnimation starts playing the named animation clip, blending from
+// the current clip to the new clip over the given duration. A zero
+// duration switches clips without blending.
+//
+// Depends on Entity.AddModel with an "anm:" animation asset.
+func (e *Entity) BlendAnimation(clip string, blend time.Duration) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.actor != nil {
+		m.actor.play(clip, blend.Seconds())
+		return e
+	}
+	slog.Error("BlendAnimation needs AddModel with an animation", "eid", e.eid)
+	return e
+}
+
+// SetAnimationSpeed scales the animation playback rate.
+// The default is 1. Zero pauses the animation.
+//
+// Depends on Entity.AddModel with an "anm:" animation asset.
+func (e *Entity) SetAnimationSpeed(speed float64) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.actor != nil {
+		m.actor.speed = max(0, speed)
+		return e
+	}
+	slog.Error("SetAnimationSpeed needs AddModel with an animation", "eid", e.eid)
+	return e
+}
+
+// AnimationClips returns the names of the animation clips.
+// Nothing is returned until the animation has loaded.
+//
+// Depends on Entity.AddModel with an "anm:" animation asset.
+func (e *Entity) AnimationClips() (clips []string) {
+	if m := e.app.models.get(e.eid); m != nil && m.actor != nil {
+		if m.actor.anim != nil {
+			for _, c := range m.actor.anim.clips {
+				clips = append(clips, c.Name)
+			}
+		}
+		return clips
+	}
+	slog.Error("AnimationClips needs AddModel with an animation", "eid", e.eid)
+	return clips
+}
+
+// =============================================================================
+// actor
+
+// actor tracks the animation playback for one model.
+type actor struct {
+	anim  *animation // shared animation data, nil until loaded.
+	speed float64    // playback rate, 1 is normal speed.
+
+	// the requested clip is started on the next update.
+	request   string  // requested clip name.
+	requested bool    // true if there is a new clip request.
+	blend     float64 // requested blend time in seconds.
+
+	// current and previous clips and their times in seconds.
+	clip, prev   int     // clip indexes, -1 for the bind pose.
+	time, ptime  float64 // time in seconds for clip and prev.
+	btime, bspan float64 // elapsed and total blend time in seconds.
+
+	// scratch data reused each update.
+	poses []jointPose // current pose for each joint.
+	other []jointPose // previous clip pose used for blending.
+	world []lin.M4    // joint model space transforms.
+	bone  *lin.M4     // scratch bone matrix.
+	b64   []byte      // scratch bone matrix bytes.
+	bones []byte      // bone matrices for the shader.
+}
+
+// newActor creates an actor that shows the bind pose.
+func newActor() *actor {
+	return &actor{speed: 1, clip: -1, prev: -1, bone: &lin.M4{}}
+}
+
+// play requests a new clip. The clip is started on the next
+// update since the animation may not have loaded.
+func (a *actor) play(clip string, blend float64) {
+	a.request, a.requested, a.blend = clip, true, blend
+}
+
+// update advances the clip times by the elapsed seconds and
+// calculates the bone matrices for the new joint poses.
+func (a *actor) update(dt float64) {
+	if a.anim == nil {
+		return // wait for the animation to load.
+	}
+	if a.requested {
+		a.requested = false
+		clip := a.anim.clip(a.request)
+		if clip < 0 {
+			slog.Error("no such animation clip", "anm", a.anim.name, "clip", a.request)
+		} else {
+			a.prev, a.ptime = -1, 0
+			if a.blend > 0 && a.clip >= 0 && a.clip != clip {
+				a.prev, a.ptime = a.clip, a.time
+				a.btime, a.bspan = 0, a.blend
+			}
+			a.clip, a.time = clip, 0
+		}
+	}
+
+	// advance the clips.
+	dt *= a.speed
+	a.time += dt
+	if a.prev >= 0 {
+		a.ptime += dt
+		a.btime += dt
+		if a.btime >= a.bspan {
+			a.prev = -1 // finished blending.
+		}
+	}
+
+	// sample the joint poses, blending with the previous clip if necessary.
+	njoints := len(a.anim.joints)
+	a.poses = a.sample(a.poses[:0], a.clip, a.time)
+	if a.prev >= 0 {
+		a.other = a.sample(a.other[:0], a.prev, a.ptime)
+		ratio := a.btime / a.bspan
+		for i := range a.poses {
+			a.poses[i].blend(&a.other[i], &a.poses[i], ratio)
+		}
+	}
+
+	// combine the joint poses into model space joint
+	// transforms and then into the bone matrices.
+	if len(a.world) != njoints {
+		a.world = make([]lin.M4, njoints)
+	}
+	a.bones = a.bones[:0]
+	for i, j := range a.anim.joints {
+		world := &a.world[i]
+		a.poses[i].matrix(world)
+		if j.Parent >= 0 {
+			world.Mult(world, &a.world[j.Parent])
+		}
+		a.bone.Mult(&a.anim.inverse[i], world)
+		a.b64 = render.M4ToBytes(a.bone, a.b64)
+		a.bones = append(a.bones, a.b64...)
+	}
+}
+
+// sample appends the joint poses for the given clip at the given time.
+// The bind pose is used for invalid clips and clips without frames.
+// Looping clips wrap around while other clips hold their last frame.
+func (a *actor) sample(poses []jointPose, clip int, t float64) []jointPose {
+	if clip < 0 || clip >= len(a.anim.clips) || len(a.anim.clips[clip].Frames) == 0 {
+		for _, j := range a.anim.joints {
+			poses = append(poses, newJointPose(j.Pose))
+		}
+		return poses
+	}
+	c := &a.anim.clips[clip]
+	nframes := len(c.Frames)
+	frame := t * float64(c.Rate)
+	f0 := int(math.Floor(frame))
+	f1 := f0 + 1
+	ratio := frame - float64(f0)
+	if c.Loop {
+		f0, f1 = f0%nframes, f1%nframes
+	} else if f1 >= nframes {
+		f0, f1, ratio = nframes-1, nframes-1, 0
+	}
+	p0, p1 := jointPose{}, jointPose{}
+	for i := range a.anim.joints {
+		p0 = newJointPose(c.Frames[f0][i])
+		p1 = newJointPose(c.Frames[f1][i])
+		p0.blend(&p0, &p1, ratio)
+		poses = append(poses, p0)
+	}
+	return poses
+}
+
+// =============================================================================
+// jointPose
+
+// jointPose is a joint transform relative to its parent joint.
+type jointPose struct {
+	t lin.V3 // translation.
+	r lin.Q  // rotation.
+	s lin.V3 // scale.
+}
+
+// newJointPose converts loaded joint pose data.
+func newJointPose(p load.JointPose) jointPose {
+	return jointPose{
+		t: lin.V3{X: float64(p.T[0]), Y: float64(p.T[1]), Z: float64(p.T[2])},
+		r: lin.Q{X: float64(p.R[0]), Y: float64(p.R[1]), Z: float64(p.R[2]), W: float64(p.R[3])},
+		s: lin.V3{X: float64(p.S[0]), Y: float64(p.S[1]), Z: float64(p.S[2])},
+	}
+}
+
+// blend sets jp to be the interpolation of poses a and b where
+// ratio is expected to be between 0 and 1. Rotations take the
+// shortest path. It is safe to use jp as one of the parameters.
+func (jp *jointPose) blend(a, b *jointPose, ratio float64) {
+	r := b.r
+	if a.r.Dot(&r) < 0 {
+		r.Neg() // same rotation in the same hemisphere.
+	}
+	jp.t.Lerp(&a.t, &b.t, ratio)
+	jp.s.Lerp(&a.s, &b.s, ratio)
+	jp.r.Nlerp(&a.r, &r, ratio)
+}
+
+// matrix sets m to the joint transform using the
+// same conventions as the pov model matrix.
+func (jp *jointPose) matrix(m *lin.M4) {
+	rot := jp.r
+	m.SetQ(rot.Inv(&rot))
+	m.ScaleSM(jp.s.X, jp.s.Y, jp.s.Z)
+	m.TranslateMT(jp.t.X, jp.t.Y, jp.t.Z)
+}
+
+// =============================================================================
+// models animation support.
+
+// animate advances the animations for all animated models.
+// Each actor is independent so actors are updated in parallel.
+func (ms *models) animate(w *workers, delta time.Duration) {
+	dt := delta.Seconds()
+	ms.actors = ms.actors[:0]
+	for _, m := range ms.list {
+		if m.actor != nil {
+			ms.actors = append(ms.actors, m.actor)
+		}
+	}
+	w.run(len(ms.actors), 1, func(start, end int) {
+		for _, a := range ms.actors[start:end] {
+			a.update(dt)
+		}
+	})
+}
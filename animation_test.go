@@ -0,0 +1,104 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Animation
+func TestAnimation(t *testing.T) {
+	rest := load.JointPose{R: [4]float32{0, 0, 0, 1}, S: [3]float32{1, 1, 1}}
+	arm := rest
+	arm.T = [3]float32{1, 0, 0}
+	up := arm
+	up.T = [3]float32{1, 2, 0}
+	data := &load.AnimationData{
+		Joints: []load.JointData{{Name: "root", Parent: -1, Pose: rest}, {Name: "arm", Parent: 0, Pose: arm}},
+		Clips: []load.ClipData{
+			{Name: "wave", Rate: 10, Frames: [][]load.JointPose{{rest, arm}, {rest, up}}},
+			{Name: "rest", Rate: 10, Frames: [][]load.JointPose{{rest, arm}}},
+		},
+	}
+
+	// boneY returns the y translation of the given bone.
+	boneY := func(a *actor, bone int) float64 {
+		bits := binary.LittleEndian.Uint32(a.bones[bone*64+13*4:])
+		return float64(math.Float32frombits(bits))
+	}
+
+	t.Run("bind pose", func(t *testing.T) {
+		a := newActor()
+		a.anim = newAnimation("test", data)
+		a.update(0)
+		if len(a.bones) != 2*64 || boneY(a, 1) != 0 || a.world[1].Wx != 1 {
+			t.Errorf("expected identity bones for the bind pose")
+		}
+	})
+	t.Run("empty clip", func(t *testing.T) {
+		empty := *data
+		empty.Clips = []load.ClipData{{Name: "empty", Rate: 10, Loop: true}}
+		a := newActor()
+		a.anim = newAnimation("test", &empty)
+		a.play("empty", 0)
+		a.update(0.05) // bind pose, no panic.
+		if boneY(a, 1) != 0 {
+			t.Errorf("expected bind pose for a clip without frames")
+		}
+	})
+	t.Run("interpolate", func(t *testing.T) {
+		a := newActor()
+		a.anim = newAnimation("test", data)
+		a.play("wave", 0)
+		a.update(0.05) // halfway between frames.
+		if y := boneY(a, 1); math.Abs(y-1) > 1e-6 {
+			t.Errorf("expected arm bone moved up 1 got %f", y)
+		}
+	})
+	t.Run("blend", func(t *testing.T) {
+		a := newActor()
+		a.anim = newAnimation("test", data)
+		a.play("wave", 0)
+		a.update(1) // holds the last frame.
+		a.play("rest", 1)
+		a.update(0.5) // halfway through the blend.
+		if y := boneY(a, 1); math.Abs(y-1) > 1e-6 {
+			t.Errorf("expected blended arm bone at 1 got %f", y)
+		}
+		a.update(0.5) // blend done.
+		if y := boneY(a, 1); a.prev != -1 || y != 0 {
+			t.Errorf("expected rest pose after blend got %f", y)
+		}
+	})
+	t.Run("packet", func(t *testing.T) {
+		rc := &mrc{} // mock render context.
+		app := newApplication()
+		app.ld.loadDefaultAssets(rc)
+		anim3D := newShader("anim3D")
+		cfg, err := load.ShaderConfig("anim3D.shd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		anim3D.setConfig(cfg)
+		app.ld.assets[anim3D.aid()] = anim3D
+		anm := newAnimation("test", data)
+		app.ld.assets[anm.aid()] = anm
+		me := app.addScene(Scene3D).AddModel("shd:anim3D", "msh:cube", "anm:test")
+		me.SetColor(1, 1, 1, 1).SetAt(0, 0, -5)
+		if clips := me.AnimationClips(); len(clips) != 2 || clips[0] != "wave" {
+			t.Errorf("expected animation clips got %v", clips)
+		}
+		me.BlendAnimation("wave", 250*time.Millisecond)
+		app.models.animate(app.work, 50*time.Millisecond)
+		packets := app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets
+		if len(packets) != 1 || len(packets[0].Bones) != 2*64 || len(packets[0].Uniforms[load.BONES]) != 4 {
+			t.Errorf("expected a packet with bones")
+		}
+	})
+}
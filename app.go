@@ -20,28 +20,65 @@ import (
 //
 // One application instance is created by engine on startup.
 type application struct {
-	updator Updator // Application update callback
-	resizer Resizer // Application resize callback
-	input   *Input  // User input is refreshed each update.
+	updator   Updator   // Application update callback
+	resizer   Resizer   // Application resize callback
+	displayer Displayer // Application display change callback
+	input     *Input    // User input is refreshed each update.
 
 	// Application resources are grouped by the type of data.
-	eids   *entities   // Entity id manager.
-	sounds *sounds     // Audio components.
-	scenes *scenes     // Scene component, one camera per scene
-	povs   *povs       // Transform components.
-	models *models     // Render components.
-	lights *lights     // Light components.
-	sim    *simulation // Physic simulation components.
+	eids     *entities   // Entity id manager.
+	sounds   *sounds     // Audio components.
+	scenes   *scenes     // Scene component, one camera per scene
+	povs     *povs       // Transform components.
+	models   *models     // Render components.
+	lights   *lights     // Light components.
+	sim      *simulation // Physic simulation components.
+	tags     *tags       // Entity tags and tag queries.
+	spatial  *spatial    // 3D part bounds and queries.
+	tiles    *tilemaps   // 2D tilemaps.
+	cloths   *cloths     // Cloth simulation components.
+	decals   *decals     // Decal pools.
+	terrains *terrains   // Heightmap terrains.
+	waters   *waters     // Water surfaces.
+	probes   *probes     // Reflection probes.
+	ui       *ui         // 2D widgets.
+	patches  *patches    // Nine patch skins.
+	videos   *videos     // Movie textures.
+	debug    *Debug      // Debug drawing, created when first used.
+	gui      *GUI        // Immediate mode GUI, created when first used.
+	console  *Console    // Developer console, created when first used.
+	events   *events     // Event bus and engine topics.
+	work     *workers    // Parallel update goroutines.
+
+	// comps are the application components from NewComponents.
+	comps []componentStore
+
+	// coroutines are resumed, and ticks, tweens, and timers are called each update.
+	coroutines *coroutines
+	ticks      *ticks
+	tweens     *tweens
+	timers     *timers // also keeps the game clock.
 
 	// Load assets from files in a separate go-routine.
 	ld *assetLoader // looks in local "assets" directory by default.
 
 	// frame holds the scene render packet information.
 	frame []render.Pass // reused each render.
+
+	// windows holds the additional windows and their render frames.
+	windows []*appWindow
+
+	// players are the local players indexed by slot.
+	players []*Player
+
+	// gpuScopes are the application GPU profile scope names
+	// starting with render.ScopeApp.
+	gpuScopes []string
 }
 
 // Initialize the application data.
 func newApplication() (app *application) {
+	ld := newLoader() // start the loader goroutine.
 	app = &application{
 		input: &Input{
 			Pressed:  map[int32]bool{},
@@ -50,15 +87,34 @@ func newApplication() (app *application) {
 		},
 
 		// initialize the component managers.
-		eids:   &entities{},     // entity id manager.
-		sounds: newSounds(),     // audio resources.
-		scenes: newScenes(),     // scenes to group models.
-		povs:   newPovs(),       // model transforms.
-		models: newModels(),     // 2D and 3D models.
-		lights: newLights(),     // 3D lights.
-		sim:    newSimulation(), // physics simulation
+		eids:     &entities{},     // entity id manager.
+		sounds:   newSounds(),     // audio resources.
+		scenes:   newScenes(),     // scenes to group models.
+		povs:     newPovs(),       // model transforms.
+		models:   newModels(ld),   // 2D and 3D models.
+		lights:   newLights(),     // 3D lights.
+		sim:      newSimulation(), // physics simulation
+		tags:     newTags(),       // entity tags.
+		spatial:  newSpatial(),    // 3D part bounds.
+		tiles:    newTilemaps(),   // 2D tilemaps.
+		cloths:   newCloths(),     // cloth simulation.
+		decals:   newDecals(),     // projected decals.
+		terrains: newTerrains(),   // chunked heightmap terrains.
+		waters:   newWaters(),     // animated water surfaces.
+		probes:   newProbes(),     // reflection probes.
+		ui:       newUI(),         // menus and HUDs.
+		patches:  newPatches(),    // stretched skins.
+		videos:   newVideos(),     // movie playback.
+		work:     newWorkers(),    // parallel updates.
+		events:   newEvents(),     // gameplay messaging.
+
+		// gameplay sequences.
+		coroutines: newCoroutines(),
+		ticks:      newTicks(),
+		tweens:     newTweens(),
+		timers:     newTimers(),
 	}
-	app.ld = newLoader() // start the loader goroutine.
+	app.ld = ld
 	app.frame = []render.Pass{
 		render.NewPass(), // 3D
 		render.NewPass(), // 2D
@@ -86,9 +142,27 @@ func (app *application) dispose(eng *Engine, eid eID) {
 	}
 	dead = app.povs.dispose(eid, dead)
 	app.sim.dispose(eid)
+	app.cloths.dispose(app, eid) // before the cloth model.
+	app.decals.dispose(eid)
+	dead = app.terrains.dispose(app, eid, dead) // before the chunk models.
+	app.waters.dispose(app, eid)
+	app.probes.dispose(app, eid)
+	app.ui.dispose(eid)
+	app.patches.dispose(eid)
+	app.videos.dispose(eng, eid)
 	app.lights.dispose(eid)
 	app.models.dispose(eid)
+	app.spatial.dispose(eid)
+	app.tiles.dispose(eid)
 	app.sounds.dispose(eng, eid)
+	app.tags.dispose(app.povs, eid)
+	app.coroutines.dispose(eid)
+	app.ticks.dispose(eid)
+	app.tweens.dispose(eid)
+	app.timers.dispose(eid)
+	for _, cs := range app.comps {
+		cs.dispose(eid)
+	}
 	app.eids.dispose(eid)
 	for _, eid := range dead {
 		app.dispose(eng, eid)
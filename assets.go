@@ -6,12 +6,14 @@ package vu
 // The assets are used by game entities to create game objects.
 
 import (
+	"encoding/binary"
 	"hash/crc64"
 	"math"
 	"math/rand"
 
 	"github.com/gazed/vu/audio"
 	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
 )
 
 // ============================================================================
@@ -66,6 +68,25 @@ type mesh struct {
 	name string // Unique mesh name.
 	tag  aid    // name and type as a number.
 	mid  uint32 // GPU vertex data reference.
+
+	// generated meshes belong to a single model, eg: label text,
+	// and are dropped when the model no longer uses them.
+	generated bool
+
+	// local bounds of the mesh vertexes, used to cull parts
+	// outside the camera view. False if the mesh has no bounds.
+	bounded bool
+	lo, hi  lin.V3
+
+	// local vertex positions and triangle vertex indexes kept to
+	// pick parts, see MeshTriangles. Empty if the mesh has no bounds.
+	verts []lin.V3
+	faces []uint32
+
+	// lightmap texture coordinates and vertex normals kept to bake
+	// lightmaps. Empty if the mesh has no lightmap texture coordinates.
+	uv2   [][2]float64
+	norms []lin.V3
 }
 
 // newMesh allocates space for a mesh structure,
@@ -75,6 +96,87 @@ func newMesh(name string) *mesh {
 	return m
 }
 
+// setBounds sets the mesh bounds from the mesh vertex positions.
+// The positions and triangles are also kept when triangles is true,
+// for picking, decals, and lightmaps, see MeshTriangles.
+func (m *mesh) setBounds(md load.MeshData, triangles bool) {
+	m.bounded, m.verts, m.faces = false, nil, nil
+	m.uv2, m.norms = nil, nil
+	if len(md) <= load.Vertexes || md[load.Vertexes].Stride != 12 || md[load.Vertexes].Count == 0 {
+		return
+	}
+	data := md[load.Vertexes].Data
+	v := func(i int) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))) }
+	m.lo = lin.V3{X: v(0), Y: v(1), Z: v(2)}
+	m.hi = m.lo
+	if triangles {
+		m.verts = make([]lin.V3, 0, md[load.Vertexes].Count)
+	}
+	for i := 0; i < int(md[load.Vertexes].Count)*3 && i*4+12 <= len(data); i += 3 {
+		p := lin.V3{X: v(i), Y: v(i + 1), Z: v(i + 2)}
+		m.lo.Min(&m.lo, &p)
+		m.hi.Max(&m.hi, &p)
+		if triangles {
+			m.verts = append(m.verts, p)
+		}
+	}
+	m.bounded = true
+	if !triangles {
+		return
+	}
+	m.setLightmapData(md)
+
+	// keep the triangles that only reference known vertexes.
+	// Indexes are uint16 or uint32.
+	if len(md) <= load.Indexes {
+		return
+	}
+	size := int(md[load.Indexes].Stride)
+	if size != 2 && size != 4 {
+		return
+	}
+	index := md[load.Indexes].Data
+	at := func(i int) uint32 {
+		if size == 2 {
+			return uint32(binary.LittleEndian.Uint16(index[i*2:]))
+		}
+		return binary.LittleEndian.Uint32(index[i*4:])
+	}
+	count := min(int(md[load.Indexes].Count), len(index)/size) / 3 * 3
+	m.faces = make([]uint32, 0, count)
+	for i := 0; i < count; i += 3 {
+		a, b, c := at(i), at(i+1), at(i+2)
+		if int(max(a, b, c)) < len(m.verts) {
+			m.faces = append(m.faces, a, b, c)
+		}
+	}
+}
+
+// setLightmapData keeps the lightmap texture coordinates and the
+// vertex normals needed to bake lightmaps, see BakeLightmap.
+func (m *mesh) setLightmapData(md load.MeshData) {
+	count := uint32(len(m.verts))
+	if len(md) <= load.Texcoords2 || md[load.Texcoords2].Stride != 8 || md[load.Texcoords2].Count != count {
+		return
+	}
+	f := func(data []byte, i int) float64 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+	}
+	uv := md[load.Texcoords2].Data
+	m.uv2 = make([][2]float64, count)
+	for i := range m.uv2 {
+		m.uv2[i] = [2]float64{f(uv, i*2), f(uv, i*2+1)}
+	}
+	if md[load.Normals].Stride != 12 || md[load.Normals].Count != count {
+		return // use the triangle normals.
+	}
+	n := md[load.Normals].Data
+	m.norms = make([]lin.V3, count)
+	for i := range m.norms {
+		m.norms[i] = lin.V3{X: f(n, i*3), Y: f(n, i*3+1), Z: f(n, i*3+2)}
+	}
+}
+
 // implement assset interface
 func (m *mesh) aid() aid      { return m.tag }  // hashed type and name.
 func (m *mesh) label() string { return m.name } // asset name
@@ -117,6 +219,7 @@ type texture struct {
 	tag    aid    // Name and type as a number.
 	tid    uint32 // GPU texture reference.
 	opaque bool   // All pixels have alpha==1.0.
+	w, h   int    // Size in pixels.
 }
 
 // newTexture allocates space for a texture asset.
@@ -186,3 +289,53 @@ func newSound(name string) *sound {
 // implement assset interface
 func (s *sound) aid() aid      { return s.tag }  // hashed type and name.
 func (s *sound) label() string { return s.name } // asset name
+
+// =============================================================================
+// animation
+
+// animation is a skeleton and its animation clips. It is shared by
+// all the models that use it. Each model tracks its own playback
+// using an actor.
+type animation struct {
+	name    string           // Unique name of the animation.
+	tag     aid              // name and type as a number.
+	joints  []load.JointData // skeleton, parents before children.
+	clips   []load.ClipData  // animation clips.
+	inverse []lin.M4         // inverse bind pose for each joint.
+}
+
+// newAnimation creates an animation asset from the loaded animation
+// data, calculating the inverse bind pose for each joint.
+func newAnimation(name string, data *load.AnimationData) *animation {
+	a := &animation{name: name, tag: assetID(anm, name)}
+	a.joints = data.Joints
+	a.clips = data.Clips
+	a.inverse = make([]lin.M4, len(a.joints))
+	local := &lin.M4{}
+	for i, j := range a.joints {
+		p := newJointPose(j.Pose)
+		inv := &a.inverse[i]
+		inv.SetQ(&p.r) // the inverse of the inverted model rotation.
+		inv.ScaleMS(1/p.s.X, 1/p.s.Y, 1/p.s.Z)
+		inv.TranslateTM(-p.t.X, -p.t.Y, -p.t.Z)
+		if j.Parent >= 0 {
+			local.Set(inv)
+			inv.Mult(&a.inverse[j.Parent], local)
+		}
+	}
+	return a
+}
+
+// clip returns the index of the named clip or -1 if there is no such clip.
+func (a *animation) clip(name string) int {
+	for i := range a.clips {
+		if a.clips[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// implement assset interface
+func (a *animation) aid() aid      { return a.tag }  // hashed type and name.
+func (a *animation) label() string { return a.name } // asset name
@@ -0,0 +1,78 @@
+#version 450
+
+// A PBR shader for skinned meshes using material values instead of textures.
+// Each vertex is moved by up to four weighted bones.
+// Requires one directional light.
+
+layout(location=0) in vec3 position; // vertex model location.
+layout(location=1) in vec3 normal;   // vertex normal.
+layout(location=2) in vec4 joint;    // up to four bone indexes.
+layout(location=3) in vec4 weight;   // bone weights that add to 1.
+
+layout(location=0) out struct out_dto {
+    vec3 normal;
+    vec3 world_pos;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    // vertex shader uniforms
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+
+    // fragment shader uniforms
+    vec4 cam;        // 16 bytes : local camera location
+
+    // The first light must exist and be directional.
+    // The remaining lights are optional point, spot, or area lights.
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 4 lights
+} su;
+
+// bone matrices for all animated models in the scene.
+layout(set=0, binding=1) readonly buffer scene_bones {
+    mat4 bones[];
+} sb;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    // vertex shader uniforms
+    mat4 model;      // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color;      // 16 bytes: rgba
+    vec4 material;   // 16 bytes: x:metallic y:roughness
+
+    // vertex shader uniforms
+    int bones;       //  4 bytes: first model bone in sb.bones
+} mu;
+
+void main() {
+
+    // blend the bone transforms for this vertex.
+    ivec4 j = ivec4(joint) + mu.bones;
+    mat4 skin = weight.x * sb.bones[j.x] +
+                weight.y * sb.bones[j.y] +
+                weight.z * sb.bones[j.z] +
+                weight.w * sb.bones[j.w];
+    mat4 world = mu.model * skin;
+
+    // calcuate unit normal in world space
+    mat4 nmat = transpose(inverse(world));
+    dto.normal = normalize((nmat * vec4(normal, 0)).xyz);
+
+    // calculate vertex world space position
+    dto.world_pos = (world * vec4(position, 1.0)).xyz;
+    gl_Position = su.proj * su.view * world * vec4(position, 1.0);
+}
@@ -2,6 +2,14 @@
 
 layout(location=0) out vec4 out_color;
 
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;     // 64 bytes
+    mat4 view;     // 64 bytes
+    vec4 fog;      // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor; // 16 bytes : rgb, a:most fog
+} su;
+
 // model uniforms
 layout(push_constant) uniform push_constants {
     mat4 model; // 64 bytes
@@ -10,6 +18,11 @@ layout(push_constant) uniform push_constants {
     vec4 color; // 16 bytes: rgba
 } mu;
 
+layout(location=0) in struct in_dto {
+    float fog;
+} dto;
+
 void main() {
     out_color = mu.color;
+    out_color.rgb = mix(out_color.rgb, su.fogcolor.rgb, dto.fog);
 }
@@ -0,0 +1,17 @@
+#version 450
+
+layout(location=0) in vec3 frag_color;
+
+layout(location=0) out vec4 out_color;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+	mat4 model; // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color; // 16 bytes: rgba tint and opacity.
+} mu;
+
+void main() {
+    out_color = vec4(frag_color * mu.color.rgb, mu.color.a);
+}
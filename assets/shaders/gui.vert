@@ -0,0 +1,25 @@
+#version 450
+
+layout(location=0) in vec2 position;
+layout(location=1) in vec3 v_color;
+
+layout(location=0) out vec3 frag_color;
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj; // 64 bytes
+    mat4 view; // 64 bytes
+} su;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+	mat4 model; // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color; // 16 bytes: rgba
+} mu;
+
+void main() {
+    gl_Position = su.proj * su.view * mu.model * vec4(position, 0.0, 1.0);
+    frag_color = v_color;
+}
@@ -0,0 +1,29 @@
+#version 450
+
+layout(location=0) out vec4 out_color;
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;     // 64 bytes
+    mat4 view;     // 64 bytes
+    vec4 fog;      // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor; // 16 bytes : rgb, a:most fog
+} su;
+
+// samplers
+layout(set=1, binding=0) uniform sampler2D color;
+layout(set=1, binding=1) uniform sampler2D lightmap;
+
+layout(location=0) in struct in_dto {
+    vec2 texcoord;
+    vec2 texcoord2;
+    float fog;
+} dto;
+
+void main() {
+    // lightmaps hold half the light, see vu/lightmap.go.
+    vec3 light = texture(lightmap, dto.texcoord2).rgb * 2.0;
+    out_color = texture(color, dto.texcoord);
+    out_color.rgb = min(out_color.rgb * light, vec3(1.0));
+    out_color.rgb = mix(out_color.rgb, su.fogcolor.rgb, dto.fog);
+}
@@ -0,0 +1,6 @@
+#version 450
+
+// occlusion queries count the samples that pass the depth test.
+// Nothing is written to the color buffer.
+void main() {
+}
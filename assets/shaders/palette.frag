@@ -0,0 +1,38 @@
+#version 450
+
+layout(location=0) out vec4 out_color;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+
+    // fragment shader uniforms
+    vec4 keycolor;   // 16 bytes: rgb key color, a: tolerance, negative for none.
+    vec4 paletterow; // 16 bytes: x: palette row.
+} mu;
+
+// Samplers
+const int COLOR = 0;
+const int PALETTE = 1;
+layout(set = 1, binding = 0) uniform sampler2D samplers[2];
+
+layout(location=0) in struct in_dto {
+    vec2 texcoord;
+} dto;
+
+void main() {
+    // fetch exact texels, filtering would blend the indexes.
+    ivec2 size = textureSize(samplers[COLOR], 0);
+    ivec2 texel = clamp(ivec2(dto.texcoord * vec2(size)), ivec2(0), size - 1);
+    int index = int(texelFetch(samplers[COLOR], texel, 0).r * 255.0 + 0.5);
+
+    // lookup the color in the palette row. Both textures are linear
+    // data so the palette color is still sRGB, like the key color.
+    ivec2 psize = textureSize(samplers[PALETTE], 0);
+    ivec2 entry = clamp(ivec2(index, int(mu.paletterow.x)), ivec2(0), psize - 1);
+    vec4 color = texelFetch(samplers[PALETTE], entry, 0);
+    if (distance(color.rgb, mu.keycolor.rgb) <= mu.keycolor.a) {
+        discard; // color key transparency.
+    }
+    out_color = vec4(pow(color.rgb, vec3(2.2)), color.a); // sRGB to linear.
+}
@@ -7,11 +7,13 @@ layout(location=0) in struct in_dto {
     vec3 world_pos;
 } dto;
 
-// light is either a directional light
-// or a point light with a position.
+// light is a directional, point, spot, or area light, see vu/light.go.
 struct light {
-    vec4 pos;   // if w == 1 position, else direction
+    vec4 pos;   // xyz position or direction, w:light type
     vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
 };
 
 // scene uniforms
@@ -24,8 +26,10 @@ layout(set=0, binding=0) uniform scene_uniforms {
     vec4 cam;        // 16 bytes : local camera location
 
     // The first light must exist and be directional.
-    // The remaining three lights are optional point lights.
-    light lights[3]; // 96 bytes
+    // The remaining lights are optional point, spot, or area lights.
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
     int nlights;     //  4 bytes : 1 to 4 lights
 } su;
 
@@ -70,20 +74,58 @@ float ggxDistribution(float nDotH, float roughness) {
     return ggxdistrib;
 }
 
+// lightFalloff returns how much of the light reaches the world location
+// and sets l to the unit vector towards the light. Point, spot, and area
+// lights fade with distance using the light attenuation profile. Spot
+// lights soften between the inner and outer cone. Area lights shine from
+// the closest point on the light rectangle on the facing side only.
+float lightFalloff(light L, vec3 world_pos, out vec3 l) {
+    int kind = int(L.pos.w + 0.5);
+    if (kind == 0) {
+        l = normalize(-L.pos.xyz); // directional.
+        return 1.0;
+    }
+    vec3 to = L.pos.xyz - world_pos;
+    float cone = 1.0;
+    if (kind == 3) {
+        vec3 up = cross(L.right.xyz, L.dir.xyz);
+        float x = clamp(dot(-to, L.right.xyz), -L.shape.x, L.shape.x);
+        float y = clamp(dot(-to, up), -L.shape.y, L.shape.y);
+        to += L.right.xyz * x + up * y;
+    }
+    float dist = max(length(to), 0.0001);
+    l = to / dist;
+    if (kind == 2) {
+        cone = smoothstep(L.shape.y, L.shape.x, dot(-l, L.dir.xyz));
+    } else if (kind == 3) {
+        cone = max(dot(-l, L.dir.xyz), 0.0);
+    }
+
+    // attenuation profiles: inverse square, linear, or none.
+    float range = L.dir.w;
+    int profile = int(L.right.w + 0.5);
+    float atten = 1.0;
+    if (profile == 0) {
+        atten = 1.0 / max(dist * dist, 0.01);
+        if (range > 0.0) {
+            float window = clamp(1.0 - pow(dist / range, 4.0), 0.0, 1.0);
+            atten *= window * window;
+        }
+    } else if (range > 0.0) {
+        atten = profile == 1 ? clamp(1.0 - dist / range, 0.0, 1.0) : step(dist, range);
+    }
+    return atten * cone;
+}
+
 vec3 CalcPBRLighting(light Light, bool IsDirLight, vec3 Normal) {
     vec3 LightIntensity = Light.color.xyz * Light.color.w; // color * intensity
     vec3 l = vec3(0.0);
     float metallic = float(round(mu.material.x)); // 0.0 or 1.0
     float roughness = mu.material.y;              // 0.0 to 1.0
 
-    vec3 PosDir = vec3(Light.pos);
-    if (IsDirLight) {
-        l = normalize(-PosDir.xyz);
-    } else {
-        l = PosDir - dto.world_pos;
-        float LightToPixelDist = length(l);
-        l = normalize(l);
-        LightIntensity /= (LightToPixelDist * LightToPixelDist);
+    l = normalize(-Light.pos.xyz);
+    if (!IsDirLight) {
+        LightIntensity *= lightFalloff(Light, dto.world_pos, l);
     }
 
     // object normal vector, view vector, half vector.
@@ -118,6 +160,16 @@ vec3 CalcPBRLighting(light Light, bool IsDirLight, vec3 Normal) {
     return FinalColor;
 }
 
+// fogAmount returns how much fog is between the camera and the world
+// location. Fog thickens from the start to the end distance and thins
+// above the base height, see vu/fog.go.
+float fogAmount(vec3 world_pos) {
+    float span = max(su.fog.y - su.fog.x, 0.0001);
+    float fogd = clamp((length(su.cam.xyz - world_pos) - su.fog.x) / span, 0.0, 1.0);
+    float fogh = exp(-su.fog.z * max(world_pos.y - su.fog.w, 0.0));
+    return su.fogcolor.a * fogd * fogh;
+}
+
 void main() {
     vec3 N = normalize(dto.normal);
     vec3 TotalLight = CalcPBRLighting(su.lights[0], true, N);
@@ -135,4 +187,7 @@ void main() {
     // Gamma correction
     float alpha = mu.color.w;
     frag_color = vec4(pow(TotalLight, vec3(1.0/2.2)), alpha);
+
+    // distance and height fog.
+    frag_color.rgb = mix(frag_color.rgb, su.fogcolor.rgb, fogAmount(dto.world_pos));
 }
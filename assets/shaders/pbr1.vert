@@ -13,11 +13,13 @@ layout(location=0) out struct out_dto {
     vec2 texcoord;
 } dto;
 
-// light is either a directional light
-// or a point light with a position.
+// light is a directional, point, spot, or area light, see vu/light.go.
 struct light {
-    vec4 pos;   // if w == 1 position, else direction
+    vec4 pos;   // xyz position or direction, w:light type
     vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
 };
 
 // scene uniforms
@@ -30,8 +32,10 @@ layout(set=0, binding=0) uniform scene_uniforms {
     vec4 cam;        // 16 bytes : local camera location
 
     // The first light must exist and be directional.
-    // The remaining three lights are optional point lights.
-    light lights[3]; // 96 bytes
+    // The remaining lights are optional point, spot, or area lights.
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
     int nlights;     //  4 bytes : 1 to 4 lights
 } su;
 
@@ -0,0 +1,223 @@
+#version 450
+
+layout(location=0) out vec4 frag_color;
+
+layout(location=0) in struct in_dto {
+    vec3 normal;
+    vec3 world_pos;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    // vertex shader uniforms
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+
+    // fragment shader uniforms
+    vec4 cam;        // 16 bytes : local camera location
+
+    // The first light must exist and be directional.
+    // The remaining lights are optional point, spot, or area lights.
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 4 lights
+} su;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    // vertex shader uniforms
+    mat4 model;      // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color;      // 16 bytes: rgba
+    vec4 material;   // 16 bytes: x:metallic y:roughness
+    vec4 probe;      // 16 bytes: xyz:probe location w:1 if there is a probe
+    vec4 probebox;   // 16 bytes: xyz:probe box half size
+} mu;
+
+// reflection probe environment, see vu/probe.go.
+layout(set=1, binding=0) uniform samplerCube env;
+
+#define PI 3.1415926535897932384626433832795
+
+// uniforms and constants
+// =============================================================================
+// code
+
+// specular BRDF Fresnel function
+vec3 schlickFresnel(float vDotH, float metallic) {
+    vec3 F0 = vec3(0.04); // specular color for non-metals
+
+    // use material color for metals
+    F0 = mix(F0, vec3(mu.color), metallic);
+    vec3 ret = F0 + (1 - F0) * pow(clamp(1.0 - vDotH, 0.0, 1.0), 5);
+    return ret;
+}
+
+// specular BRDF geometry function
+float geomSmith(float dp, float roughness) {
+    float k = (roughness + 1.0) * (roughness + 1.0) / 8.0;
+    float denom = dp * (1 - k) + k;
+    return dp / denom;
+}
+
+// specular BRDF normal distribution funtion.
+float ggxDistribution(float nDotH, float roughness) {
+    float alpha2 = roughness * roughness * roughness * roughness;
+    float d = nDotH * nDotH * (alpha2 - 1) + 1;
+    float ggxdistrib = alpha2 / (PI * d * d);
+    return ggxdistrib;
+}
+
+// lightFalloff returns how much of the light reaches the world location
+// and sets l to the unit vector towards the light. Point, spot, and area
+// lights fade with distance using the light attenuation profile. Spot
+// lights soften between the inner and outer cone. Area lights shine from
+// the closest point on the light rectangle on the facing side only.
+float lightFalloff(light L, vec3 world_pos, out vec3 l) {
+    int kind = int(L.pos.w + 0.5);
+    if (kind == 0) {
+        l = normalize(-L.pos.xyz); // directional.
+        return 1.0;
+    }
+    vec3 to = L.pos.xyz - world_pos;
+    float cone = 1.0;
+    if (kind == 3) {
+        vec3 up = cross(L.right.xyz, L.dir.xyz);
+        float x = clamp(dot(-to, L.right.xyz), -L.shape.x, L.shape.x);
+        float y = clamp(dot(-to, up), -L.shape.y, L.shape.y);
+        to += L.right.xyz * x + up * y;
+    }
+    float dist = max(length(to), 0.0001);
+    l = to / dist;
+    if (kind == 2) {
+        cone = smoothstep(L.shape.y, L.shape.x, dot(-l, L.dir.xyz));
+    } else if (kind == 3) {
+        cone = max(dot(-l, L.dir.xyz), 0.0);
+    }
+
+    // attenuation profiles: inverse square, linear, or none.
+    float range = L.dir.w;
+    int profile = int(L.right.w + 0.5);
+    float atten = 1.0;
+    if (profile == 0) {
+        atten = 1.0 / max(dist * dist, 0.01);
+        if (range > 0.0) {
+            float window = clamp(1.0 - pow(dist / range, 4.0), 0.0, 1.0);
+            atten *= window * window;
+        }
+    } else if (range > 0.0) {
+        atten = profile == 1 ? clamp(1.0 - dist / range, 0.0, 1.0) : step(dist, range);
+    }
+    return atten * cone;
+}
+
+vec3 CalcPBRLighting(light Light, bool IsDirLight, vec3 Normal) {
+    vec3 LightIntensity = Light.color.xyz * Light.color.w; // color * intensity
+    vec3 l = vec3(0.0);
+    float metallic = float(round(mu.material.x)); // 0.0 or 1.0
+    float roughness = mu.material.y;              // 0.0 to 1.0
+
+    l = normalize(-Light.pos.xyz);
+    if (!IsDirLight) {
+        LightIntensity *= lightFalloff(Light, dto.world_pos, l);
+    }
+
+    // object normal vector, view vector, half vector.
+    vec3 n = Normal;
+    vec3 v = normalize(vec3(su.cam) - dto.world_pos);
+    vec3 h = normalize(v + l);
+    float nDotH = max(dot(n, h), 0.0);
+    float vDotH = max(dot(v, h), 0.0);
+    float nDotL = max(dot(n, l), 0.0);
+    float nDotV = max(dot(n, v), 0.0);
+
+    // conserve energy so refaction+reflection==1.0
+    vec3 F = schlickFresnel(vDotH, metallic);
+    vec3 kS = F;          // specular: reflection
+    vec3 kD = 1.0 - kS;   // diffuse : refraction
+
+    // specular BRDF.
+    vec3 SpecBRDF_nom  = ggxDistribution(nDotH, roughness) *
+                         F *
+                         geomSmith(nDotL, roughness) *
+                         geomSmith(nDotV, roughness);
+    float SpecBRDF_denom = 4.0 * nDotV * nDotL + 0.0001;
+    vec3 SpecBRDF = SpecBRDF_nom / SpecBRDF_denom;
+
+    // use color for non-metals, metals will already have the color in kS.
+    vec3 fLambert = mix(vec3(mu.color), vec3(0.0), metallic);
+    vec3 DiffuseBRDF = kD * fLambert / PI;
+
+    // final color value for the given light.
+    float alpha = mu.color.w;
+    vec3 FinalColor = (DiffuseBRDF*alpha + SpecBRDF) * LightIntensity * nDotL;
+    return FinalColor;
+}
+
+// probeReflection returns the probe environment color reflected along r
+// from the world location. Locations inside the probe box correct the
+// reflection so that it hits the box walls instead of the far distance.
+vec3 probeReflection(vec3 r, vec3 world_pos) {
+    vec3 lo = mu.probe.xyz - mu.probebox.xyz;
+    vec3 hi = mu.probe.xyz + mu.probebox.xyz;
+    if (all(greaterThan(world_pos, lo)) && all(lessThan(world_pos, hi))) {
+        vec3 far = max((hi - world_pos) / r, (lo - world_pos) / r);
+        float dist = min(min(far.x, far.y), far.z);
+        r = normalize(world_pos + r * dist - mu.probe.xyz);
+    }
+    return texture(env, r).rgb;
+}
+
+// fogAmount returns how much fog is between the camera and the world
+// location. Fog thickens from the start to the end distance and thins
+// above the base height, see vu/fog.go.
+float fogAmount(vec3 world_pos) {
+    float span = max(su.fog.y - su.fog.x, 0.0001);
+    float fogd = clamp((length(su.cam.xyz - world_pos) - su.fog.x) / span, 0.0, 1.0);
+    float fogh = exp(-su.fog.z * max(world_pos.y - su.fog.w, 0.0));
+    return su.fogcolor.a * fogd * fogh;
+}
+
+void main() {
+    vec3 N = normalize(dto.normal);
+    vec3 TotalLight = CalcPBRLighting(su.lights[0], true, N);
+    for (int i = 1; i < su.nlights; i++) {
+        TotalLight += CalcPBRLighting(su.lights[i], false, N);
+    }
+
+    // specular reflection of the probe environment, fading with roughness.
+    if (mu.probe.w > 0.0) {
+        float metallic = float(round(mu.material.x));
+        float roughness = mu.material.y;
+        vec3 v = normalize(su.cam.xyz - dto.world_pos);
+        vec3 F0 = mix(vec3(0.04), mu.color.rgb, metallic);
+        vec3 F = F0 + (1.0 - F0) * pow(1.0 - max(dot(N, v), 0.0), 5.0);
+        float gloss = (1.0 - roughness) * (1.0 - roughness);
+        TotalLight += probeReflection(reflect(-v, N), dto.world_pos) * F * gloss;
+    }
+
+    // add fixed (indirect) ambient value as a cheap replacement for global illumination.
+    float ambientStrength = 0.0005;
+    TotalLight += (ambientStrength * mu.color).xyz;
+
+    // HDR tone mapping
+    TotalLight = TotalLight / (TotalLight + vec3(1.0));
+
+    // Gamma correction
+    float alpha = mu.color.w;
+    frag_color = vec4(pow(TotalLight, vec3(1.0/2.2)), alpha);
+
+    // distance and height fog.
+    frag_color.rgb = mix(frag_color.rgb, su.fogcolor.rgb, fogAmount(dto.world_pos));
+}
@@ -6,18 +6,41 @@ layout(location=0) out vec4 out_color;
 const int COLOR = 0;
 layout(set = 1, binding = 0) uniform sampler2D samplers[1];
 
-// FUTURE: convert to model push constant Uniforms
-const vec3  text_color = vec3(0.0, 0.0, 0.0);
-const float text_alpha = 1.0;
-
-layout(location=0) in struct dto {
+layout(location=0) in struct in_dto {
     vec2 texcoord;
-} in_dto;
+} dto;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+    mat4 model;   // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color;   // 16 bytes: rgba
+    vec4 args4;   // 16 bytes: outline width, glow width, edge softness, unused
+    vec4 outline; // 16 bytes: rgba
+} mu;
 
-const float smoothing = 1.0/16.0;
+// the glyph edge is where the distance field is 0.5.
+const float edge = 0.5;
 
 void main() {
-    float distance = texture(samplers[COLOR], in_dto.texcoord).a;
-    float clamp = smoothstep(0.5 - smoothing, 0.5 + smoothing, distance);
-    out_color = vec4(text_color, text_alpha*clamp);
+    float dist = texture(samplers[COLOR], dto.texcoord).a;
+
+    // anti-alias using the screen space rate of change so that
+    // the text edges stay sharp at any scale. Softness blurs the edges.
+    float aa = max(fwidth(dist), 0.001) + mu.args4.z * edge;
+    float text = smoothstep(edge - aa, edge + aa, dist);
+
+    // the outline extends the glyph edge outwards and
+    // the glow fades out beyond the outline.
+    float outer = edge - mu.args4.x * edge;
+    float outline = mu.args4.x > 0.0 ? smoothstep(outer - aa, outer + aa, dist) : 0.0;
+    float glow = mu.args4.y > 0.0 ? smoothstep(outer - mu.args4.y * edge, outer, dist) : 0.0;
+    vec4 effect = vec4(mu.outline.rgb, mu.outline.a * max(outline, glow * glow));
+
+    // text is drawn over the outline and glow.
+    out_color = mix(effect, mu.color, text);
+    if (out_color.a <= 0.0) {
+        discard;
+    }
 }
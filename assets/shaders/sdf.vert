@@ -11,7 +11,12 @@ layout(set=0, binding=0) uniform scene_uniforms {
 
 // model uniforms
 layout(push_constant) uniform push_constants {
-    mat4 model; // 64 bytes
+    mat4 model;   // 64 bytes
+
+    // fragment shader uniforms
+    vec4 color;   // 16 bytes: rgba
+    vec4 args4;   // 16 bytes: outline width, glow width, edge softness, unused
+    vec4 outline; // 16 bytes: rgba
 } mu;
 
 layout(location=0) out struct out_dto {
@@ -23,6 +23,8 @@ package shaders
 // run "go generate" to create or update the shader byte code.
 
 // 3D shaders
+//go:generate glslc anim3D.vert -o anim3D.vert.spv
+//go:generate glslc anim3D.frag -o anim3D.frag.spv
 //go:generate glslc bbinst.vert -o bbinst.vert.spv
 //go:generate glslc bbinst.frag -o bbinst.frag.spv
 //go:generate glslc bboard.vert -o bboard.vert.spv
@@ -31,23 +33,45 @@ package shaders
 //go:generate glslc circle.frag -o circle.frag.spv
 //go:generate glslc col3D.vert -o col3D.vert.spv
 //go:generate glslc col3D.frag -o col3D.frag.spv
+//go:generate glslc debug.vert -o debug.vert.spv
+//go:generate glslc debug.frag -o debug.frag.spv
+//go:generate glslc lightmap.vert -o lightmap.vert.spv
+//go:generate glslc lightmap.frag -o lightmap.frag.spv
 //go:generate glslc lines.vert -o lines.vert.spv
 //go:generate glslc lines.frag -o lines.frag.spv
+//go:generate glslc occlude.vert -o occlude.vert.spv
+//go:generate glslc occlude.frag -o occlude.frag.spv
 //go:generate glslc pbr0.vert -o pbr0.vert.spv
 //go:generate glslc pbr0.frag -o pbr0.frag.spv
 //go:generate glslc pbr1.vert -o pbr1.vert.spv
 //go:generate glslc pbr1.frag -o pbr1.frag.spv
+//go:generate glslc terrain.vert -o terrain.vert.spv
+//go:generate glslc terrain.frag -o terrain.frag.spv
 //go:generate glslc tex3D.vert -o tex3D.vert.spv
 //go:generate glslc tex3D.frag -o tex3D.frag.spv
+//go:generate glslc reflect.vert -o reflect.vert.spv
+//go:generate glslc reflect.frag -o reflect.frag.spv
 //go:generate glslc sdf.vert -o sdf.vert.spv
 //go:generate glslc sdf.frag -o sdf.frag.spv
+//go:generate glslc sky.vert -o sky.vert.spv
+//go:generate glslc sky.frag -o sky.frag.spv
+//go:generate glslc water.vert -o water.vert.spv
+//go:generate glslc water.frag -o water.frag.spv
 
 // 2D shaders
 //go:generate glslc col2D.vert -o col2D.vert.spv
 //go:generate glslc col2D.frag -o col2D.frag.spv
+//go:generate glslc gui.vert -o gui.vert.spv
+//go:generate glslc gui.frag -o gui.frag.spv
 //go:generate glslc icon.vert -o icon.vert.spv
 //go:generate glslc icon.frag -o icon.frag.spv
 //go:generate glslc label.vert -o label.vert.spv
 //go:generate glslc label.frag -o label.frag.spv
 //go:generate glslc lines2D.vert -o lines2D.vert.spv
 //go:generate glslc lines2D.frag -o lines2D.frag.spv
+//go:generate glslc palette.vert -o palette.vert.spv
+//go:generate glslc palette.frag -o palette.frag.spv
+//go:generate glslc skin.vert -o skin.vert.spv
+//go:generate glslc skin.frag -o skin.frag.spv
+//go:generate glslc sprite.vert -o sprite.vert.spv
+//go:generate glslc sprite.frag -o sprite.frag.spv
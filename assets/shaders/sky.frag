@@ -0,0 +1,82 @@
+#version 450
+
+// Single scattering of sunlight through the atmosphere. Rayleigh
+// scattering gives the blue sky and red sunsets while Mie scattering
+// gives the bright haze around the sun. Based on "Accurate Atmospheric
+// Scattering", Sean O'Neil, GPU Gems 2, 2005, with fewer samples.
+
+layout(location=0) out vec4 frag_color;
+
+layout(location=0) in struct in_dto {
+    vec3 dir; // view direction.
+} dto;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+    vec4 args4; // 16 bytes: xyz:sun direction, w:sun intensity
+} mu;
+
+const float PI = 3.14159265;
+const float PLANET = 6371e3;      // planet radius in meters.
+const float ATMOSPHERE = 6471e3;  // atmosphere radius in meters.
+const vec3 RAYLEIGH = vec3(5.5e-6, 13.0e-6, 22.4e-6); // scattering per wavelength.
+const float MIE = 21e-6;          // scattering for all wavelengths.
+const float RAYLEIGH_HEIGHT = 8e3; // scale heights.
+const float MIE_HEIGHT = 1.2e3;
+const float MIE_G = 0.758;        // preferred scattering direction.
+const int STEPS = 12;             // view ray samples.
+const int LIGHT_STEPS = 4;        // sun ray samples.
+
+// sphere returns the distance along the ray to where it
+// leaves a sphere of radius r centered on the planet.
+float sphere(vec3 origin, vec3 dir, float r) {
+    float b = dot(origin, dir);
+    float c = dot(origin, origin) - r * r;
+    return -b + sqrt(max(b * b - c, 0.0));
+}
+
+void main() {
+    vec3 dir = normalize(dto.dir);
+    vec3 sun = normalize(mu.args4.xyz);
+    vec3 origin = vec3(0.0, PLANET + 1.0, 0.0); // viewer just above the ground.
+
+    float len = sphere(origin, dir, ATMOSPHERE);
+    float step = len / float(STEPS);
+    float mu_ = dot(dir, sun);
+    float phaseR = 3.0 / (16.0 * PI) * (1.0 + mu_ * mu_);
+    float g2 = MIE_G * MIE_G;
+    float phaseM = 3.0 / (8.0 * PI) * ((1.0 - g2) * (1.0 + mu_ * mu_)) /
+                   ((2.0 + g2) * pow(1.0 + g2 - 2.0 * MIE_G * mu_, 1.5));
+
+    vec3 sumR = vec3(0.0), sumM = vec3(0.0);
+    float depthR = 0.0, depthM = 0.0;
+    for (int i = 0; i < STEPS; i++) {
+        vec3 p = origin + dir * (float(i) + 0.5) * step;
+        float h = length(p) - PLANET;
+        float hr = exp(-h / RAYLEIGH_HEIGHT) * step;
+        float hm = exp(-h / MIE_HEIGHT) * step;
+        depthR += hr;
+        depthM += hm;
+
+        // optical depth towards the sun.
+        float lightLen = sphere(p, sun, ATMOSPHERE);
+        float lightStep = lightLen / float(LIGHT_STEPS);
+        float lightR = 0.0, lightM = 0.0;
+        for (int j = 0; j < LIGHT_STEPS; j++) {
+            vec3 q = p + sun * (float(j) + 0.5) * lightStep;
+            float lh = length(q) - PLANET;
+            lightR += exp(-lh / RAYLEIGH_HEIGHT) * lightStep;
+            lightM += exp(-lh / MIE_HEIGHT) * lightStep;
+        }
+        vec3 tau = RAYLEIGH * (depthR + lightR) + MIE * 1.1 * (depthM + lightM);
+        vec3 attenuation = exp(-tau);
+        sumR += attenuation * hr;
+        sumM += attenuation * hm;
+    }
+    vec3 color = mu.args4.w * (sumR * RAYLEIGH * phaseR + sumM * MIE * phaseM);
+
+    // tone map and gamma correct like the PBR shaders.
+    color = 1.0 - exp(-color);
+    frag_color = vec4(pow(color, vec3(1.0 / 2.2)), 1.0);
+}
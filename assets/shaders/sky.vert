@@ -0,0 +1,29 @@
+#version 450
+
+// A sky shader that keeps the sky centered on the camera and
+// behind everything else in the scene.
+
+layout(location=0) in vec3 position; // cube vertex location.
+
+layout(location=0) out struct out_dto {
+    vec3 dir; // view direction.
+} dto;
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj; // 64 bytes
+    mat4 view; // 64 bytes
+} su;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+    vec4 args4; // 16 bytes: xyz:sun direction, w:sun intensity
+} mu;
+
+void main() {
+    dto.dir = position;
+    mat4 rotation = mat4(mat3(su.view)); // ignore the camera location.
+    vec4 pos = su.proj * rotation * vec4(position, 1.0);
+    gl_Position = vec4(pos.xy, pos.w * 0.99999, pos.w); // just inside the far plane.
+}
@@ -0,0 +1,28 @@
+#version 450
+
+layout(location=0) out vec4 out_color;
+
+// model uniforms
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+
+    // fragment shader uniforms
+    vec4 keycolor; // 16 bytes: rgb key color, a: tolerance, negative for none.
+} mu;
+
+// Samplers
+const int COLOR = 0;
+layout(set = 1, binding = 0) uniform sampler2D samplers[1];
+
+layout(location=0) in struct in_dto {
+    vec2 texcoord;
+} dto;
+
+void main() {
+    // the texture is sampled as linear color, the key color is sRGB.
+    vec4 color = texture(samplers[COLOR], dto.texcoord);
+    if (distance(pow(color.rgb, vec3(1.0/2.2)), mu.keycolor.rgb) <= mu.keycolor.a) {
+        discard; // color key transparency.
+    }
+    out_color = color;
+}
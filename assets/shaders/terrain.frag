@@ -0,0 +1,124 @@
+#version 450
+
+layout(location=0) out vec4 frag_color;
+
+layout(location=0) in struct in_dto {
+    vec3 normal;
+    vec3 world_pos;
+    vec2 texcoord;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+    vec4 cam;        // 16 bytes : camera location
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 3 lights
+} su;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+    vec4 args4; // 16 bytes: x:layer texture repeats
+} mu;
+
+// samplers
+layout(set=1, binding=0) uniform sampler2D splat;
+layout(set=1, binding=1) uniform sampler2D layer0;
+layout(set=1, binding=2) uniform sampler2D layer1;
+layout(set=1, binding=3) uniform sampler2D layer2;
+layout(set=1, binding=4) uniform sampler2D layer3;
+
+// lightFalloff returns how much of the light reaches the world location
+// and sets l to the unit vector towards the light. Point, spot, and area
+// lights fade with distance using the light attenuation profile. Spot
+// lights soften between the inner and outer cone. Area lights shine from
+// the closest point on the light rectangle on the facing side only.
+float lightFalloff(light L, vec3 world_pos, out vec3 l) {
+    int kind = int(L.pos.w + 0.5);
+    if (kind == 0) {
+        l = normalize(-L.pos.xyz); // directional.
+        return 1.0;
+    }
+    vec3 to = L.pos.xyz - world_pos;
+    float cone = 1.0;
+    if (kind == 3) {
+        vec3 up = cross(L.right.xyz, L.dir.xyz);
+        float x = clamp(dot(-to, L.right.xyz), -L.shape.x, L.shape.x);
+        float y = clamp(dot(-to, up), -L.shape.y, L.shape.y);
+        to += L.right.xyz * x + up * y;
+    }
+    float dist = max(length(to), 0.0001);
+    l = to / dist;
+    if (kind == 2) {
+        cone = smoothstep(L.shape.y, L.shape.x, dot(-l, L.dir.xyz));
+    } else if (kind == 3) {
+        cone = max(dot(-l, L.dir.xyz), 0.0);
+    }
+
+    // attenuation profiles: inverse square, linear, or none.
+    float range = L.dir.w;
+    int profile = int(L.right.w + 0.5);
+    float atten = 1.0;
+    if (profile == 0) {
+        atten = 1.0 / max(dist * dist, 0.01);
+        if (range > 0.0) {
+            float window = clamp(1.0 - pow(dist / range, 4.0), 0.0, 1.0);
+            atten *= window * window;
+        }
+    } else if (range > 0.0) {
+        atten = profile == 1 ? clamp(1.0 - dist / range, 0.0, 1.0) : step(dist, range);
+    }
+    return atten * cone;
+}
+
+// fogAmount returns how much fog is between the camera and the world
+// location. Fog thickens from the start to the end distance and thins
+// above the base height, see vu/fog.go.
+float fogAmount(vec3 world_pos) {
+    float span = max(su.fog.y - su.fog.x, 0.0001);
+    float fogd = clamp((length(su.cam.xyz - world_pos) - su.fog.x) / span, 0.0, 1.0);
+    float fogh = exp(-su.fog.z * max(world_pos.y - su.fog.w, 0.0));
+    return su.fogcolor.a * fogd * fogh;
+}
+
+void main() {
+    // blend the repeating layer textures using the splat weights.
+    vec4 w = texture(splat, dto.texcoord);
+    w /= max(w.r + w.g + w.b + w.a, 0.0001);
+    vec2 uv = dto.texcoord * mu.args4.x;
+    vec3 base = texture(layer0, uv).rgb * w.r +
+                texture(layer1, uv).rgb * w.g +
+                texture(layer2, uv).rgb * w.b +
+                texture(layer3, uv).rgb * w.a;
+
+    // the first light is directional: diffuse plus ambient.
+    light sun = su.lights[0];
+    vec3 l = normalize(-sun.pos.xyz);
+    float diffuse = max(dot(normalize(dto.normal), l), 0.0);
+    vec3 lit = base * (0.25 + sun.color.rgb * sun.color.w * diffuse);
+
+    // the remaining point, spot, and area lights are diffuse only.
+    for (int i = 1; i < su.nlights; i++) {
+        vec3 ll;
+        float atten = lightFalloff(su.lights[i], dto.world_pos, ll);
+        diffuse = max(dot(normalize(dto.normal), ll), 0.0);
+        lit += base * su.lights[i].color.rgb * su.lights[i].color.w * atten * diffuse;
+    }
+    frag_color = vec4(lit, 1.0);
+
+    // distance and height fog.
+    frag_color.rgb = mix(frag_color.rgb, su.fogcolor.rgb, fogAmount(dto.world_pos));
+}
@@ -0,0 +1,47 @@
+#version 450
+
+// A terrain shader where the texture coordinates span the whole
+// terrain so that one splat texture covers all terrain chunks.
+
+layout(location=0) in vec3 position; // vertex location.
+layout(location=1) in vec3 normal;   // vertex normal.
+layout(location=2) in vec2 texcoord; // terrain texture coordinates.
+
+layout(location=0) out struct out_dto {
+    vec3 normal;
+    vec3 world_pos;
+    vec2 texcoord;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+    vec4 cam;        // 16 bytes : camera location
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 3 lights
+} su;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    mat4 model; // 64 bytes
+    vec4 args4; // 16 bytes: x:layer texture repeats
+} mu;
+
+void main() {
+    dto.texcoord = texcoord;
+    dto.normal = normalize((mu.model * vec4(normal, 0)).xyz); // terrain is not scaled.
+    dto.world_pos = (mu.model * vec4(position, 1.0)).xyz;
+    gl_Position = su.proj * su.view * mu.model * vec4(position, 1.0);
+}
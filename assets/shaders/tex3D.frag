@@ -2,14 +2,24 @@
 
 layout(location=0) out vec4 out_color;
 
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;     // 64 bytes
+    mat4 view;     // 64 bytes
+    vec4 fog;      // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor; // 16 bytes : rgb, a:most fog
+} su;
+
 // samplers
 const int COLOR = 0;
 layout(set = 1, binding = 0) uniform sampler2D samplers[1];
 
 layout(location=0) in struct in_dto {
     vec2 texcoord;
+    float fog;
 } dto;
 
 void main() {
     out_color = texture(samplers[COLOR], dto.texcoord);
+    out_color.rgb = mix(out_color.rgb, su.fogcolor.rgb, dto.fog);
 }
@@ -5,8 +5,10 @@ layout(location=1) in vec2 texcoord;
 
 // scene uniforms
 layout(set=0, binding=0) uniform scene_uniforms {
-    mat4 proj; // 64 bytes
-    mat4 view; // 64 bytes
+    mat4 proj;     // 64 bytes
+    mat4 view;     // 64 bytes
+    vec4 fog;      // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor; // 16 bytes : rgb, a:most fog
 } su;
 
 // model uniforms
@@ -16,9 +18,18 @@ layout(push_constant) uniform push_constants {
 
 layout(location=0) out struct out_dto {
     vec2 texcoord;
+    float fog;
 } dto;
 
 void main() {
     dto.texcoord = texcoord;
-    gl_Position = su.proj * su.view * mu.model * vec4(position, 1.0);
+    vec4 world_pos = mu.model * vec4(position, 1.0);
+    vec4 view_pos = su.view * world_pos;
+
+    // distance and height fog, see vu/fog.go.
+    float span = max(su.fog.y - su.fog.x, 0.0001);
+    float fogd = clamp((length(view_pos.xyz) - su.fog.x) / span, 0.0, 1.0);
+    float fogh = exp(-su.fog.z * max(world_pos.y - su.fog.w, 0.0));
+    dto.fog = su.fogcolor.a * fogd * fogh;
+    gl_Position = su.proj * view_pos;
 }
@@ -0,0 +1,98 @@
+#version 450
+
+layout(location=0) out vec4 frag_color;
+
+layout(location=0) in struct in_dto {
+    vec3 normal;
+    vec3 world_pos;
+    vec2 texcoord;
+    vec4 clip;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+    vec4 cam;        // 16 bytes : camera location
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 3 lights
+} su;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    mat4 model;  // 64 bytes
+    mat4 args16; // 64 bytes: directions+time, amplitudes+tiling, lengths+flow, color.
+} mu;
+
+// samplers
+layout(set=1, binding=0) uniform sampler2D normals;
+layout(set=1, binding=1) uniform sampler2D sky;
+layout(set=1, binding=2) uniform sampler2D reflection; // upside down, see vu/water.go
+layout(set=1, binding=3) uniform sampler2D refraction;
+
+const float PI = 3.14159265;
+
+// fogAmount returns how much fog is between the camera and the world
+// location. Fog thickens from the start to the end distance and thins
+// above the base height, see vu/fog.go.
+float fogAmount(vec3 world_pos) {
+    float span = max(su.fog.y - su.fog.x, 0.0001);
+    float fogd = clamp((length(su.cam.xyz - world_pos) - su.fog.x) / span, 0.0, 1.0);
+    float fogh = exp(-su.fog.z * max(world_pos.y - su.fog.w, 0.0));
+    return su.fogcolor.a * fogd * fogh;
+}
+
+void main() {
+    float seconds = mu.args16[0].w;
+    float tiling = abs(mu.args16[1].w);
+    bool planar = mu.args16[1].w < 0.0; // use the reflection images.
+    float flow = mu.args16[2].w;
+    vec4 water = mu.args16[3];
+
+    // ripples from two normal map samples drifting in different directions.
+    vec2 uv = dto.texcoord * tiling;
+    vec3 n1 = texture(normals, uv + vec2(flow, flow * 0.5) * seconds).rgb * 2.0 - 1.0;
+    vec3 n2 = texture(normals, uv * 1.7 - vec2(flow * 0.6, -flow) * seconds).rgb * 2.0 - 1.0;
+    vec3 ripple = normalize(vec3(n1.xy + n2.xy, n1.z * n2.z)); // tangent space, z up.
+    vec3 n = normalize(dto.normal + vec3(ripple.x, 0.0, ripple.y));
+
+    // reflect the sky, using an equirectangular lookup.
+    vec3 v = normalize(su.cam.xyz - dto.world_pos);
+    vec3 r = reflect(-v, n);
+    vec2 skyUV = vec2(atan(r.x, -r.z) / (2.0 * PI) + 0.5, acos(clamp(r.y, -1.0, 1.0)) / PI);
+    vec3 reflected = texture(sky, skyUV).rgb;
+
+    // Schlick Fresnel for water with a base reflectance of 0.02.
+    float fresnel = 0.02 + 0.98 * pow(1.0 - max(dot(n, v), 0.0), 5.0);
+    vec3 color = mix(water.rgb, reflected, fresnel);
+    float alpha = mix(water.a, 1.0, fresnel);
+    if (planar) {
+        // the scene images at this screen location, moved by the ripples.
+        vec2 screen = dto.clip.xy / dto.clip.w * 0.5 + 0.5 + ripple.xy * 0.02;
+        reflected = texture(reflection, vec2(screen.x, 1.0 - screen.y)).rgb;
+        vec3 below = texture(refraction, screen).rgb;
+        color = mix(mix(below, water.rgb, water.a), reflected, fresnel);
+        alpha = 1.0;
+    }
+
+    // sun highlight from the first light.
+    light sun = su.lights[0];
+    vec3 l = normalize(-sun.pos.xyz);
+    float spec = pow(max(dot(r, l), 0.0), 200.0);
+    color += sun.color.rgb * sun.color.w * spec;
+    frag_color = vec4(color, alpha);
+
+    // distance and height fog.
+    frag_color.rgb = mix(frag_color.rgb, su.fogcolor.rgb, fogAmount(dto.world_pos));
+}
@@ -0,0 +1,68 @@
+#version 450
+
+// A water shader that moves a flat grid using three sine waves.
+// The wave settings are packed into args16, see vu/water.go.
+
+layout(location=0) in vec3 position; // vertex location.
+layout(location=1) in vec2 texcoord; // surface texture coordinates.
+
+layout(location=0) out struct out_dto {
+    vec3 normal;
+    vec3 world_pos;
+    vec2 texcoord;
+    vec4 clip;
+} dto;
+
+// light is a directional, point, spot, or area light, see vu/light.go.
+struct light {
+    vec4 pos;   // xyz position or direction, w:light type
+    vec4 color; // xyz are rgb 0-1 and w is light intensity
+    vec4 dir;   // xyz spot and area facing, w:range or 0 for unlimited
+    vec4 right; // xyz area width axis, w:attenuation profile
+    vec4 shape; // xy spot cone cosines inner,outer or area half width,height
+};
+
+// scene uniforms
+layout(set=0, binding=0) uniform scene_uniforms {
+    mat4 proj;       // 64 bytes
+    mat4 view;       // 64 bytes
+    vec4 cam;        // 16 bytes : camera location
+    light lights[3]; // 240 bytes
+    vec4 fog;        // 16 bytes : x:start y:end z:height falloff w:base height
+    vec4 fogcolor;   // 16 bytes : rgb, a:most fog
+    int nlights;     //  4 bytes : 1 to 3 lights
+} su;
+
+// model uniforms max 128 bytes
+layout(push_constant) uniform push_constants {
+    mat4 model;  // 64 bytes
+    mat4 args16; // 64 bytes: directions+time, amplitudes+tiling, lengths+flow, color.
+} mu;
+
+const float PI = 3.14159265;
+const float GRAVITY = 9.8;
+
+void main() {
+    float seconds = mu.args16[0].w;
+    vec3 pos = position;
+    vec2 slope = vec2(0.0); // height change along x and z.
+    for (int i = 0; i < 3; i++) {
+        float amplitude = mu.args16[1][i];
+        float len = mu.args16[2][i];
+        if (amplitude == 0.0 || len <= 0.0) {
+            continue;
+        }
+        float angle = radians(mu.args16[0][i]);
+        vec2 dir = vec2(cos(angle), sin(angle));
+        float k = 2.0 * PI / len;               // wave number.
+        float speed = sqrt(GRAVITY * k);         // deep water dispersion.
+        float phase = k * dot(dir, position.xz) - speed * seconds;
+        pos.y += amplitude * sin(phase);
+        slope += amplitude * k * cos(phase) * dir;
+    }
+    dto.texcoord = texcoord;
+    dto.normal = normalize(vec3(-slope.x, 1.0, -slope.y));
+    dto.world_pos = (mu.model * vec4(pos, 1.0)).xyz;
+    gl_Position = su.proj * su.view * mu.model * vec4(pos, 1.0);
+    dto.clip = gl_Position; // screen location for the reflection images.
+}
@@ -7,24 +7,64 @@
 // Package audio is provided as part of the vu (virtual universe) 3D engine.
 package audio
 
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
 // Context is used to initialize and play audio.
 // It works with an audioAPI to allow different audio players implementations.
 type Context struct {
 	player audioAPI // audio device.
+	mix    mixer    // sound buses, see SetSoundBus.
+
+	capture *capture // microphone recording, see StartCapture.
 }
 
 // New provides the default audio implementation.
 func New() *Context { return &Context{player: &openal{}} }
 
 // Init the audio context state. Must be called once on startup.
-func (c *Context) Init() error { return c.player.init() }
+// Sounds are played through the native platform audio when the
+// OpenAL library is not installed.
+func (c *Context) Init() error {
+	err := c.player.init()
+	if _, ok := c.player.(*openal); ok && err != nil {
+		slog.Info("openal unavailable, using native audio", "error", err)
+		c.player = newNative()
+		return c.player.init()
+	}
+	return err
+}
 
 // Closes and the audio layer, releasing any audio resources.
-func (c *Context) Dispose() { c.player.dispose() }
+func (c *Context) Dispose() {
+	c.StopCapture()
+	c.player.dispose()
+}
 
 // Volume control: valid values are 0->1.
 func (c *Context) SetGain(gain float64) { c.player.setGain(gain) }
 
+// Devices returns the names of the available audio output devices.
+func (c *Context) Devices() []string { return c.player.devices() }
+
+// Device returns the name of the audio output device playing sounds.
+func (c *Context) Device() string { return c.player.device() }
+
+// SetDevice switches sounds to the named audio output device.
+// Loaded sounds are kept. The empty string "" follows the system
+// default device, switching when the default device changes.
+// Returns an error wrapping errors.ErrUnsupported when audio is disabled.
+func (c *Context) SetDevice(name string) error { return c.player.setDevice(name) }
+
+// Refresh reopens the audio output device if it has been disconnected,
+// or if the system default device has changed while following the
+// default device. Returns true if the output device changed.
+// Expected to be called periodically.
+func (c *Context) Refresh() bool { return c.player.refresh() }
+
 // LoadSound copies the sound data to the sound card and returns
 // references that can be used to play or dispose the sound.
 //
@@ -35,9 +75,33 @@ func (c *Context) LoadSound(sound, buff *uint64, d *Data) error {
 	return c.player.loadSound(sound, buff, d)
 }
 
-// DropSound disposes the audio resources allocated with LoadSound.
-// Must be called on a valid audio context, ie: before Dispose()
+// LoadStream creates a sound that plays 16 bit samples as they are
+// queued with QueueStream, eg: a movie sound track, so that long sounds
+// are not held in memory. Streams are played, stopped, and dropped like
+// loaded sounds, where dropping uses a buff of 0. Stopping a stream
+// discards the samples that have not been played.
+//
+//	sound    : updated reference to the bound sound.
+//	channels : 1 for mono or 2 for stereo.
+//	rate     : samples per second, eg: 44100
+func (c *Context) LoadStream(sound *uint64, channels, rate int) error {
+	return c.player.loadStream(sound, channels, rate)
+}
+
+// QueueStream appends samples to a stream created with LoadStream.
+// The samples have the channels interleaved and are copied, so pcm
+// can be reused. A playing stream that runs out of samples restarts
+// when more samples are queued.
+func (c *Context) QueueStream(sound uint64, pcm []int16) {
+	if len(pcm) > 0 {
+		c.player.queueStream(sound, pcm)
+	}
+}
+
+// DropSound disposes the audio resources allocated with LoadSound
+// or LoadStream. Must be called on a valid audio context, ie: before Dispose()
 func (c *Context) DropSound(sound, buff uint64) {
+	c.mix.dropSound(sound)
 	c.player.dropSound(sound, buff)
 }
 
@@ -53,6 +117,19 @@ func (c *Context) PlaySound(sound uint64, x, y, z float64) {
 	c.player.playSound(sound, x, y, z)
 }
 
+// StopSound stops the given sound if it is playing.
+func (c *Context) StopSound(sound uint64) { c.player.stopSound(sound) }
+
+// TapOutput passes a copy of the mixed sound output to pcm, eg: to record
+// gameplay, or stops copying the output for a nil pcm. The samples are
+// interleaved stereo, -1 to 1, at the output rate. The pcm callback is
+// called from the audio stream goroutine, must not block, and must copy
+// the samples it keeps. Returns an error wrapping errors.ErrUnsupported
+// when the sounds are mixed by OpenAL.
+func (c *Context) TapOutput(pcm func(stereo []float32, rate int)) error {
+	return c.player.tapOutput(pcm)
+}
+
 // DisableAudio is used to turn off the audio system when
 // there are no supported audio drivers.
 func (c *Context) DisableAudio() { c.player = &noAudio{} }
@@ -73,6 +150,12 @@ type audioAPI interface {
 	dispose()             // Closes and cleans up the audio layer.
 	setGain(gain float64) // Volume control: valid values are 0->1.
 
+	// Audio output device selection.
+	devices() []string           // Available output device names.
+	device() string              // Current output device name.
+	setDevice(name string) error // Switch output devices.
+	refresh() bool               // Follow default and disconnected devices.
+
 	// LoadSound copies the sound data to the sound card and returns
 	// references that can be used to play or dispose the sound.
 	//     sound : updated reference to the bound sound.
@@ -83,11 +166,28 @@ type audioAPI interface {
 	// Must be called on a valid audio context, ie: before Dispose()
 	dropSound(sound, buff uint64)
 
+	// Streamed sounds, see LoadStream.
+	loadStream(sound *uint64, channels, rate int) error // Create a stream.
+	queueStream(sound uint64, pcm []int16)              // Append stream samples.
+
 	// Control sounds by setting the x,y,z locations for a listener
 	// and the played sounds. While there is only ever one listener,
 	// there can be many sounds.
 	placeListener(x, y, z float64)           // Only ever one listener.
 	playSound(sound uint64, x, y, z float64) // Play the bound sound.
+	stopSound(sound uint64)                  // Stop a playing sound.
+
+	// Mixer bus support, see SetSoundBus.
+	setSoundGain(sound uint64, gain float64)    // Volume for one sound.
+	setSoundEffect(sound uint64, effect Effect) // Effect for one sound.
+	hasEffects() bool                           // True if effects are supported.
+
+	// Audio input, see StartCapture.
+	captureDevices() []string                            // Available input device names.
+	openCapture(name string, rate int) (recorder, error) // Start recording mono 16-bit.
+
+	// Audio output, see TapOutput.
+	tapOutput(pcm func(stereo []float32, rate int)) error // Copy the mixed output.
 }
 
 // ===========================================================================
@@ -95,13 +195,38 @@ type audioAPI interface {
 // initialization fails.
 type noAudio struct{}
 
+// ErrDevice is wrapped by the errors for audio devices
+// that fail to open, eg: an unplugged headset.
+var ErrDevice = errors.New("audio device failed")
+
+// errNoAudio is returned when using a device with audio disabled.
+var errNoAudio = fmt.Errorf("%w: audio disabled", errors.ErrUnsupported)
+
+// errNoEffects is returned when the audio device does not support effects.
+var errNoEffects = fmt.Errorf("%w: audio effects", errors.ErrUnsupported)
+
 func (na *noAudio) init() error                                  { return nil }
 func (na *noAudio) dispose()                                     {}
 func (na *noAudio) setGain(gain float64)                         {}
-func (na *noAudio) loadSound(sound, buff *uint64, d *Data) error { return nil }
+func (na *noAudio) devices() []string                            { return nil }
+func (na *noAudio) device() string                               { return "" }
+func (na *noAudio) setDevice(name string) error                  { return errNoAudio }
+func (na *noAudio) refresh() bool                                { return false }
+func (na *noAudio) loadSound(sound, buff *uint64, d *Data) error { return errNoAudio }
 func (na *noAudio) dropSound(sound, buff uint64)                 {}
+func (na *noAudio) loadStream(sound *uint64, ch, rate int) error { return errNoAudio }
+func (na *noAudio) queueStream(sound uint64, pcm []int16)        {}
 func (na *noAudio) placeListener(x, y, z float64)                {}
 func (na *noAudio) playSound(sound uint64, x, y, z float64)      {}
+func (na *noAudio) stopSound(sound uint64)                       {}
+func (na *noAudio) setSoundGain(sound uint64, gain float64)      {}
+func (na *noAudio) setSoundEffect(sound uint64, effect Effect)   {}
+func (na *noAudio) hasEffects() bool                             { return false }
+func (na *noAudio) captureDevices() []string                     { return nil }
+func (na *noAudio) tapOutput(pcm func([]float32, int)) error     { return errNoAudio }
+func (na *noAudio) openCapture(name string, rate int) (recorder, error) {
+	return nil, errNoAudio
+}
 
 // ===========================================================================
 
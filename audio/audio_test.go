@@ -3,6 +3,7 @@
 package audio
 
 import (
+	"slices"
 	"testing"
 	"time"
 
@@ -34,6 +35,11 @@ func TestAudio(t *testing.T) {
 	a.PlaySound(snd, 0, 0, 0)
 	time.Sleep(500 * time.Millisecond) // wait for sound to play
 
+	// check that the output device is one of the available devices.
+	if dev := a.Device(); !slices.Contains(a.Devices(), dev) {
+		t.Errorf("expected device %q in %v", dev, a.Devices())
+	}
+
 	// check that disposing doesn't complain.
 	a.DropSound(snd, buff)
 	if alerr := al.GetError(); alerr != al.NO_ERROR {
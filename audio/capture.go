@@ -0,0 +1,82 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+// capture.go records sound from audio input devices, eg: a microphone,
+// for voice chat or for visuals that react to sound.
+
+import (
+	"fmt"
+	"time"
+)
+
+// recorder is an open audio input device.
+type recorder interface {
+	read(samples []int16) []int16 // append the samples recorded since the last read.
+	close()                       // stop recording and release the device.
+}
+
+// capture passes recorded samples to the capture callback.
+type capture struct {
+	quit chan struct{} // closed to stop the capture.
+	done chan struct{} // closed once the capture has stopped.
+}
+
+// captureInterval is the time between capture callbacks.
+const captureInterval = 20 * time.Millisecond
+
+// CaptureDevices returns the names of the available audio input devices.
+func (c *Context) CaptureDevices() []string { return c.player.captureDevices() }
+
+// StartCapture records from the named audio input device, or the
+// default input device for "". Recorded sound is passed to pcm as
+// mono 16-bit samples at the given rate, eg: 16000 for voice.
+// Any capture in progress is stopped.
+//
+// The pcm callback is called from a capture goroutine about every 20ms.
+// The samples are reused after the callback returns.
+func (c *Context) StartCapture(name string, rate int, pcm func(samples []int16)) error {
+	c.StopCapture()
+	if rate <= 0 {
+		return fmt.Errorf("StartCapture %q: invalid rate %d", name, rate)
+	}
+	rec, err := c.player.openCapture(name, rate)
+	if err != nil {
+		return fmt.Errorf("StartCapture %q: %w", name, err)
+	}
+	c.capture = &capture{quit: make(chan struct{}), done: make(chan struct{})}
+	go c.capture.run(rec, pcm)
+	return nil
+}
+
+// StopCapture stops recording. The pcm callback is
+// not called once StopCapture returns.
+func (c *Context) StopCapture() {
+	if c.capture != nil {
+		close(c.capture.quit)
+		<-c.capture.done
+		c.capture = nil
+	}
+}
+
+// Capturing returns true between StartCapture and StopCapture.
+func (c *Context) Capturing() bool { return c.capture != nil }
+
+// run polls the recorder until the capture is stopped.
+func (cp *capture) run(rec recorder, pcm func(samples []int16)) {
+	defer close(cp.done)
+	defer rec.close()
+	tick := time.NewTicker(captureInterval)
+	defer tick.Stop()
+	var samples []int16
+	for {
+		select {
+		case <-cp.quit:
+			return
+		case <-tick.C:
+		}
+		if samples = rec.read(samples[:0]); len(samples) > 0 {
+			pcm(samples)
+		}
+	}
+}
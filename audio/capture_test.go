@@ -0,0 +1,88 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -run Capture
+func TestCapture(t *testing.T) {
+	t.Run("samples", func(t *testing.T) {
+		rec := &fakeRecorder{samples: []int16{1, 2, 3}}
+		c := &Context{player: &fakeCapturer{rec: rec}}
+		got := make(chan []int16, 1)
+		err := c.StartCapture("", 16000, func(samples []int16) {
+			select {
+			case got <- append([]int16{}, samples...):
+			default:
+			}
+		})
+		if err != nil || !c.Capturing() {
+			t.Fatalf("expected capture got %v", err)
+		}
+		select {
+		case samples := <-got:
+			if len(samples) != 3 || samples[2] != 3 {
+				t.Errorf("expected recorded samples got %v", samples)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected samples")
+		}
+		c.StopCapture()
+		if c.Capturing() || !rec.isClosed() {
+			t.Errorf("expected stopped capture")
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c := &Context{player: &noAudio{}}
+		if err := c.StartCapture("", 16000, func([]int16) {}); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unsupported got %v", err)
+		}
+		var sound, buff uint64
+		if err := c.LoadSound(&sound, &buff, &Data{}); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unsupported sound load got %v", err)
+		}
+		c = &Context{player: &fakeCapturer{rec: &fakeRecorder{}}}
+		if err := c.StartCapture("", 0, func([]int16) {}); err == nil || c.Capturing() {
+			t.Errorf("expected rate error")
+		}
+	})
+}
+
+// fakeCapturer opens a fake recorder.
+type fakeCapturer struct {
+	noAudio
+	rec *fakeRecorder
+}
+
+func (fc *fakeCapturer) openCapture(name string, rate int) (recorder, error) { return fc.rec, nil }
+
+// fakeRecorder returns its samples on the first read.
+type fakeRecorder struct {
+	mu      sync.Mutex
+	samples []int16
+	closed  bool
+}
+
+func (fr *fakeRecorder) read(samples []int16) []int16 {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	samples = append(samples, fr.samples...)
+	fr.samples = nil
+	return samples
+}
+func (fr *fakeRecorder) close() {
+	fr.mu.Lock()
+	fr.closed = true
+	fr.mu.Unlock()
+}
+func (fr *fakeRecorder) isClosed() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.closed
+}
@@ -0,0 +1,139 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+// mixer.go groups sounds into named buses, eg: "music", "sfx", "voice",
+// so that each group has its own volume, mute, and effect. Sounds that
+// are not assigned to a bus are only affected by the overall SetGain.
+
+import "fmt"
+
+// Effect is applied to all the sounds on a bus.
+type Effect int
+
+// Bus effects.
+const (
+	NoEffect Effect = iota // sounds play unchanged.
+	Reverb                 // sounds echo as if in a large room.
+	LowPass                // sounds are muffled, eg: underwater or behind a wall.
+)
+
+// bus is a named group of sounds.
+type bus struct {
+	gain   float64         // 0 to 1.
+	muted  bool            // true to silence the bus without losing the gain.
+	effect Effect          // applied to each sound on the bus.
+	sounds map[uint64]bool // sound references.
+}
+
+// volume returns the gain applied to each sound on the bus.
+func (b *bus) volume() float64 {
+	if b.muted {
+		return 0
+	}
+	return b.gain
+}
+
+// mixer tracks the buses and the bus for each sound.
+type mixer struct {
+	buses  map[string]*bus
+	sounds map[uint64]string // sound reference to bus name.
+}
+
+// bus returns the named bus, creating it if necessary.
+func (m *mixer) bus(name string) *bus {
+	if m.buses == nil {
+		m.buses = map[string]*bus{}
+		m.sounds = map[uint64]string{}
+	}
+	b, ok := m.buses[name]
+	if !ok {
+		b = &bus{gain: 1, sounds: map[uint64]bool{}}
+		m.buses[name] = b
+	}
+	return b
+}
+
+// SetSoundBus moves a sound loaded with LoadSound to the named bus.
+// The sound takes on the bus volume and effect. Buses are created
+// the first time they are used, with full volume and no effect.
+func (c *Context) SetSoundBus(sound uint64, name string) {
+	if old, ok := c.mix.sounds[sound]; ok {
+		delete(c.mix.buses[old].sounds, sound)
+	}
+	b := c.mix.bus(name)
+	b.sounds[sound] = true
+	c.mix.sounds[sound] = name
+	c.player.setSoundGain(sound, b.volume())
+	c.player.setSoundEffect(sound, b.effect)
+}
+
+// SoundBus returns the bus name for the given sound,
+// or "" if the sound is not on a bus.
+func (c *Context) SoundBus(sound uint64) string { return c.mix.sounds[sound] }
+
+// SetBusGain sets the volume for all sounds on the named bus.
+// Valid values are 0 for silent to 1 for full volume.
+func (c *Context) SetBusGain(name string, gain float64) {
+	b := c.mix.bus(name)
+	b.gain = max(0, min(gain, 1))
+	for sound := range b.sounds {
+		c.player.setSoundGain(sound, b.volume())
+	}
+}
+
+// BusGain returns the volume of the named bus. Buses
+// that have not been used have full volume.
+func (c *Context) BusGain(name string) float64 {
+	if b, ok := c.mix.buses[name]; ok {
+		return b.gain
+	}
+	return 1
+}
+
+// MuteBus silences, or restores, all the sounds on the named bus.
+// The bus volume is kept while the bus is muted.
+func (c *Context) MuteBus(name string, mute bool) {
+	b := c.mix.bus(name)
+	b.muted = mute
+	for sound := range b.sounds {
+		c.player.setSoundGain(sound, b.volume())
+	}
+}
+
+// BusMuted returns true if the named bus is muted.
+func (c *Context) BusMuted(name string) bool {
+	b, ok := c.mix.buses[name]
+	return ok && b.muted
+}
+
+// SetBusEffect applies the effect to all sounds on the named bus.
+// Returns an error wrapping errors.ErrUnsupported if the audio
+// device does not support effects, in which case the bus is unchanged.
+func (c *Context) SetBusEffect(name string, effect Effect) error {
+	if !c.player.hasEffects() && effect != NoEffect {
+		return fmt.Errorf("SetBusEffect %s: %w", name, errNoEffects)
+	}
+	b := c.mix.bus(name)
+	b.effect = effect
+	for sound := range b.sounds {
+		c.player.setSoundEffect(sound, effect)
+	}
+	return nil
+}
+
+// BusEffect returns the effect for the named bus.
+func (c *Context) BusEffect(name string) Effect {
+	if b, ok := c.mix.buses[name]; ok {
+		return b.effect
+	}
+	return NoEffect
+}
+
+// dropSound removes a sound from its bus.
+func (m *mixer) dropSound(sound uint64) {
+	if name, ok := m.sounds[sound]; ok {
+		delete(m.buses[name].sounds, sound)
+		delete(m.sounds, sound)
+	}
+}
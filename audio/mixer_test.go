@@ -0,0 +1,72 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+// go test -run Mixer
+func TestMixer(t *testing.T) {
+	t.Run("gain", func(t *testing.T) {
+		fp := &fakePlayer{gains: map[uint64]float64{}, effects: map[uint64]Effect{}}
+		c := &Context{player: fp}
+		c.SetBusGain("music", 0.5)
+		c.SetSoundBus(1, "music")
+		c.SetSoundBus(2, "sfx")
+		if fp.gains[1] != 0.5 || fp.gains[2] != 1 {
+			t.Errorf("expected bus gains got %v", fp.gains)
+		}
+		c.MuteBus("music", true)
+		if fp.gains[1] != 0 || !c.BusMuted("music") || c.BusGain("music") != 0.5 {
+			t.Errorf("expected muted music got %v", fp.gains)
+		}
+		c.MuteBus("music", false)
+		c.SetBusGain("music", 2) // clamped.
+		if fp.gains[1] != 1 {
+			t.Errorf("expected restored music got %v", fp.gains)
+		}
+
+		// moving a sound takes the new bus gain.
+		c.SetBusGain("sfx", 0.25)
+		c.SetSoundBus(1, "sfx")
+		c.SetBusGain("music", 0.75)
+		if fp.gains[1] != 0.25 || c.SoundBus(1) != "sfx" {
+			t.Errorf("expected sound on sfx got %v", fp.gains)
+		}
+		c.DropSound(1, 0)
+		if c.SoundBus(1) != "" {
+			t.Errorf("expected dropped sound to leave its bus")
+		}
+	})
+
+	t.Run("effects", func(t *testing.T) {
+		fp := &fakePlayer{gains: map[uint64]float64{}, effects: map[uint64]Effect{}}
+		c := &Context{player: fp}
+		c.SetSoundBus(1, "sfx")
+		if err := c.SetBusEffect("sfx", LowPass); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unsupported effects got %v", err)
+		}
+		fp.effectsOn = true
+		if err := c.SetBusEffect("sfx", LowPass); err != nil || fp.effects[1] != LowPass {
+			t.Errorf("expected low pass got %v %v", err, fp.effects)
+		}
+		c.SetSoundBus(2, "sfx")
+		if fp.effects[2] != LowPass || c.BusEffect("sfx") != LowPass {
+			t.Errorf("expected new sound to get bus effect got %v", fp.effects)
+		}
+	})
+}
+
+// fakePlayer records the mixer changes for each sound.
+type fakePlayer struct {
+	noAudio
+	gains     map[uint64]float64
+	effects   map[uint64]Effect
+	effectsOn bool
+}
+
+func (fp *fakePlayer) setSoundGain(sound uint64, gain float64)    { fp.gains[sound] = gain }
+func (fp *fakePlayer) setSoundEffect(sound uint64, effect Effect) { fp.effects[sound] = effect }
+func (fp *fakePlayer) hasEffects() bool                           { return fp.effectsOn }
@@ -5,6 +5,7 @@ package audio
 // openal.go provides the wrapper for the openal bindings.
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -21,29 +22,88 @@ import (
 type openal struct {
 	dev al.Device  // created on initialization.
 	ctx al.Context // created on initialization.
+
+	// output device selection.
+	name      string // requested device name, "" for the default device.
+	defaultAt string // default device name when the device was opened.
+
+	// bus effects from the ALC_EXT_EFX extension.
+	effects bool   // true if the effects extension is available.
+	reverb  uint32 // auxiliary effect slot holding a reverb effect.
+	effect  uint32 // the reverb effect.
+	lowpass uint32 // direct filter that removes high frequencies.
+
+	// streamed sounds, see loadStream.
+	streams map[uint32]*alStream
+}
+
+// alStream is a source that plays a queue of buffers.
+type alStream struct {
+	format  int32 // mono or stereo 16 bit samples.
+	rate    int32 // samples per second.
+	playing bool  // true between playSound and stopSound.
 }
 
 // init runs the one time openal library initialization. It is expected to
 // be called once by the engine on startup.
 func (a *openal) init() (err error) {
 	if err := al.Init(); err != nil {
-		return fmt.Errorf("openal init %s", err)
+		return fmt.Errorf("openal init %w", err)
 	}
 	if err = a.validate(); err != nil {
-		return fmt.Errorf("openal validate %s", err)
+		return fmt.Errorf("openal validate %w", err)
 	}
 
 	// Open the audio device create a context for all sounds.
 	if a.dev = al.OpenDevice(""); a.dev == 0 {
-		return fmt.Errorf("OpenAL device failed %d", al.GetError())
+		return fmt.Errorf("openal %w: open %d", ErrDevice, al.GetError())
 	}
 	if a.ctx = al.CreateContext(a.dev, nil); a.ctx == 0 {
-		return fmt.Errorf("OpenAL context failed %d", al.GetError())
+		return fmt.Errorf("openal %w: context %d", ErrDevice, al.GetError())
 	}
 	al.MakeContextCurrent(a.ctx)
+	a.defaultAt = a.defaultDevice()
+	a.initEffects()
 	return nil // success
 }
 
+// initEffects creates the shared reverb and low pass filter used by
+// the mixer bus effects. Effects are unavailable if this fails.
+func (a *openal) initEffects() {
+	if !al.IsDeviceExtensionPresent(a.dev, al.C_EFX_EXTENSION_STRING) {
+		return
+	}
+	al.GetError() // clear any prior error.
+	al.GenEffects(1, &a.effect)
+	al.Effecti(a.effect, al.EFFECT_TYPE, al.EFFECT_REVERB)
+	al.GenAuxiliaryEffectSlots(1, &a.reverb)
+	al.AuxiliaryEffectSloti(a.reverb, al.EFFECTSLOT_EFFECT, int32(a.effect))
+	al.GenFilters(1, &a.lowpass)
+	al.Filteri(a.lowpass, al.FILTER_TYPE, al.FILTER_LOWPASS)
+	al.Filterf(a.lowpass, al.LOWPASS_GAIN, 1.0)
+	al.Filterf(a.lowpass, al.LOWPASS_GAINHF, 0.1) // keep 10% of the high frequencies.
+	if alerr := al.GetError(); alerr != al.NO_ERROR {
+		slog.Warn("openal effects unavailable", "error", alerr)
+		a.disposeEffects()
+		return
+	}
+	a.effects = true
+}
+
+// disposeEffects releases the effect resources.
+func (a *openal) disposeEffects() {
+	if a.reverb != 0 {
+		al.DeleteAuxiliaryEffectSlots(1, &a.reverb)
+	}
+	if a.effect != 0 {
+		al.DeleteEffects(1, &a.effect)
+	}
+	if a.lowpass != 0 {
+		al.DeleteFilters(1, &a.lowpass)
+	}
+	a.reverb, a.effect, a.lowpass, a.effects = 0, 0, 0, false
+}
+
 // validate that OpenAL is available. OSX has OpenAL.
 func (a *openal) validate() error {
 	if report := al.BindingReport(); len(report) > 0 {
@@ -53,7 +113,7 @@ func (a *openal) validate() error {
 			}
 		}
 	} else {
-		return fmt.Errorf("OpenAL unavailable")
+		return fmt.Errorf("%w: OpenAL unavailable", errors.ErrUnsupported)
 	}
 	return nil
 }
@@ -61,6 +121,7 @@ func (a *openal) validate() error {
 // dispose closes down the openal library. This is expected
 // to be called once by the engine when it is shutting down.
 func (a *openal) dispose() {
+	a.disposeEffects()
 	al.MakeContextCurrent(0)
 	if a.ctx != 0 {
 		al.DestroyContext(a.ctx)
@@ -78,6 +139,76 @@ func (a *openal) setGain(zeroToOne float64) {
 	}
 }
 
+// devices returns the available output devices. Uses the
+// ALC_ENUMERATE_ALL_EXT extension to list all devices if possible.
+func (a *openal) devices() []string {
+	if al.IsDeviceExtensionPresent(0, "ALC_ENUMERATE_ALL_EXT") {
+		return al.GetDeviceStrings(0, al.C_ALL_DEVICES_SPECIFIER)
+	}
+	return al.GetDeviceStrings(0, al.C_DEVICE_SPECIFIER)
+}
+
+// device returns the name of the opened output device.
+func (a *openal) device() string {
+	if a.dev == 0 {
+		return ""
+	}
+	if al.IsDeviceExtensionPresent(a.dev, "ALC_ENUMERATE_ALL_EXT") {
+		return al.GetDeviceString(a.dev, al.C_ALL_DEVICES_SPECIFIER)
+	}
+	return al.GetDeviceString(a.dev, al.C_DEVICE_SPECIFIER)
+}
+
+// defaultDevice returns the name of the system default output device.
+func (a *openal) defaultDevice() string {
+	if al.IsDeviceExtensionPresent(0, "ALC_ENUMERATE_ALL_EXT") {
+		return al.GetDeviceString(0, al.C_DEFAULT_ALL_DEVICES_SPECIFIER)
+	}
+	return al.GetDeviceString(0, al.C_DEFAULT_DEVICE_SPECIFIER)
+}
+
+// setDevice moves the open device to the named output device.
+// Reopening the device keeps the context, sources, and buffers.
+func (a *openal) setDevice(name string) error {
+	if a.dev == 0 {
+		return fmt.Errorf("openal setDevice: %w: no device", ErrDevice)
+	}
+	if !al.ReopenDevice(a.dev, name) {
+		return fmt.Errorf("openal setDevice: %w: failed to open %q", ErrDevice, name)
+	}
+	a.name = name
+	a.defaultAt = a.defaultDevice()
+	return nil
+}
+
+// refresh reopens the output device when it has been disconnected,
+// eg: unplugged headphones, or when following the default device and
+// the default device has changed, eg: plugged in headphones.
+func (a *openal) refresh() bool {
+	if a.dev == 0 {
+		return false
+	}
+	connected := int32(al.C_TRUE)
+	if al.IsDeviceExtensionPresent(a.dev, "ALC_EXT_disconnect") {
+		al.GetDeviceIntegerv(a.dev, al.C_CONNECTED, 1, &connected)
+	}
+	defaultAt := a.defaultDevice()
+	if connected == al.C_TRUE && (a.name != "" || defaultAt == a.defaultAt) {
+		return false // nothing has changed.
+	}
+	name := a.name
+	if connected != al.C_TRUE {
+		name = "" // fall back to the default device.
+	}
+	if !al.ReopenDevice(a.dev, name) {
+		a.defaultAt = defaultAt // don't retry until the default changes.
+		slog.Warn("openal refresh: failed to reopen device", "device", name)
+		return false
+	}
+	a.defaultAt = defaultAt
+	return true
+}
+
 // loadSound copies sound data to the sound card. If successful then the
 // sound reference, snd, and sound data buffer reference, buff are updated
 // with valid references.
@@ -104,6 +235,64 @@ func (a *openal) loadSound(snd, buff *uint64, d *Data) (err error) {
 	return err
 }
 
+// loadStream creates a source that plays the buffers queued by queueStream.
+func (a *openal) loadStream(snd *uint64, channels, rate int) error {
+	format, err := a.format(&Data{Channels: uint16(channels), SampleBits: 16})
+	if err != nil {
+		return err
+	}
+	if rate <= 0 {
+		return fmt.Errorf("openal: audio stream rate %d", rate)
+	}
+	al.GetError() // clear any prior error.
+	var snd32 uint32
+	al.GenSources(1, &snd32)
+	if alerr := al.GetError(); alerr != al.NO_ERROR {
+		return fmt.Errorf("openal: audio stream source %d", alerr)
+	}
+	if a.streams == nil {
+		a.streams = map[uint32]*alStream{}
+	}
+	a.streams[snd32] = &alStream{format: format, rate: int32(rate)}
+	*snd = uint64(snd32)
+	return nil
+}
+
+// queueStream copies the samples into a new buffer at the end of the
+// stream queue, after releasing the buffers that have been played.
+// A playing stream that has run out of buffers is restarted.
+func (a *openal) queueStream(snd uint64, pcm []int16) {
+	snd32 := uint32(snd)
+	s, ok := a.streams[snd32]
+	if !ok {
+		return
+	}
+	a.unqueue(snd32)
+	var buff32 uint32
+	al.GenBuffers(1, &buff32)
+	al.BufferData(buff32, s.format, al.Pointer(&pcm[0]), int32(2*len(pcm)), s.rate)
+	al.SourceQueueBuffers(snd32, 1, &buff32)
+	if s.playing {
+		var state int32
+		al.GetSourcei(snd32, al.SOURCE_STATE, &state)
+		if state != al.PLAYING {
+			al.SourcePlay(snd32)
+		}
+	}
+}
+
+// unqueue deletes the stream buffers that have been played.
+// All the buffers of a stopped stream have been played.
+func (a *openal) unqueue(snd32 uint32) {
+	var played int32
+	al.GetSourcei(snd32, al.BUFFERS_PROCESSED, &played)
+	if played > 0 {
+		buffs := make([]uint32, played)
+		al.SourceUnqueueBuffers(snd32, played, &buffs[0])
+		al.DeleteBuffers(played, &buffs[0])
+	}
+}
+
 // Implement audioAPI.
 func (a *openal) placeListener(x, y, z float64) {
 	al.Listener3f(al.POSITION, float32(x), float32(y), float32(z))
@@ -111,18 +300,106 @@ func (a *openal) placeListener(x, y, z float64) {
 
 // Implement audioAPI.
 func (a *openal) playSound(snd uint64, x, y, z float64) {
+	if s, ok := a.streams[uint32(snd)]; ok {
+		a.unqueue(uint32(snd)) // play from the next queued samples.
+		s.playing = true
+	}
 	al.Source3f(uint32(snd), al.POSITION, float32(x), float32(y), float32(z))
 	al.SourcePlay(uint32(snd))
 }
 
+// stopSound implements audioAPI. Streams discard their queued buffers.
+func (a *openal) stopSound(snd uint64) {
+	al.SourceStop(uint32(snd))
+	if s, ok := a.streams[uint32(snd)]; ok {
+		a.unqueue(uint32(snd))
+		s.playing = false
+	}
+}
+
+// setSoundGain implements audioAPI.
+func (a *openal) setSoundGain(snd uint64, gain float64) {
+	al.Sourcef(uint32(snd), al.GAIN, float32(gain))
+}
+
+// setSoundEffect implements audioAPI. Reverb is an auxiliary send
+// added to the unchanged sound while low pass filters the sound.
+func (a *openal) setSoundEffect(snd uint64, effect Effect) {
+	if !a.effects {
+		return
+	}
+	direct, slot := int32(al.FILTER_NULL), int32(al.EFFECTSLOT_NULL)
+	switch effect {
+	case Reverb:
+		slot = int32(a.reverb)
+	case LowPass:
+		direct = int32(a.lowpass)
+	}
+	al.Sourcei(uint32(snd), al.DIRECT_FILTER, direct)
+	al.Source3i(uint32(snd), al.AUXILIARY_SEND_FILTER, slot, 0, al.FILTER_NULL)
+}
+
+// hasEffects implements audioAPI.
+func (a *openal) hasEffects() bool { return a.effects }
+
+// tapOutput implements audioAPI. OpenAL mixes the sounds
+// itself so the output can't be copied.
+func (a *openal) tapOutput(pcm func(stereo []float32, rate int)) error {
+	return fmt.Errorf("openal output tap: %w", errors.ErrUnsupported)
+}
+
 // Implement Audio.
 func (a *openal) dropSound(snd, buff uint64) {
 	snd32 := uint32(snd)
 	buff32 := uint32(buff)
+	if _, ok := a.streams[snd32]; ok {
+		a.stopSound(snd) // release the queued buffers.
+		delete(a.streams, snd32)
+	}
 	al.DeleteSources(1, &snd32)  // delete source first...
 	al.DeleteBuffers(1, &buff32) // ...then delete related buffer.
 }
 
+// captureDevices implements audioAPI.
+func (a *openal) captureDevices() []string {
+	return al.GetDeviceStrings(0, al.C_CAPTURE_DEVICE_SPECIFIER)
+}
+
+// openCapture implements audioAPI. The device holds half
+// a second of samples between reads.
+func (a *openal) openCapture(name string, rate int) (recorder, error) {
+	dev := al.CaptureOpenDevice(name, uint32(rate), al.FORMAT_MONO16, int32(rate/2))
+	if dev == 0 {
+		return nil, fmt.Errorf("openal capture %w: %d", ErrDevice, al.GetDeviceError(0))
+	}
+	al.CaptureStart(dev)
+	return &alCapture{dev: dev}, nil
+}
+
+// alCapture is an open OpenAL capture device.
+type alCapture struct {
+	dev al.Device
+}
+
+// read implements recorder.
+func (ac *alCapture) read(samples []int16) []int16 {
+	var available int32
+	al.GetDeviceIntegerv(ac.dev, al.C_CAPTURE_SAMPLES, 1, &available)
+	if available <= 0 {
+		return samples
+	}
+	start := len(samples)
+	samples = append(samples, make([]int16, available)...)
+	al.CaptureSamples(ac.dev, al.Pointer(&samples[start]), int(available))
+	return samples
+}
+
+// close implements recorder.
+func (ac *alCapture) close() {
+	al.CaptureStop(ac.dev)
+	al.CaptureCloseDevice(ac.dev)
+}
+
 // format figures out which of the OpenAL formats to use based on the
 // WAVE file information. A -1 value, and error, is returned if the format
 // cannot be determined.
@@ -138,7 +415,7 @@ func (a *openal) format(d *Data) (format int32, err error) {
 		format = al.FORMAT_STEREO16
 	}
 	if format < 0 {
-		err = fmt.Errorf("openal:unknown audio format Channels:%d SampleBits:%d", d.Channels, d.SampleBits)
+		err = fmt.Errorf("openal:%w: audio format Channels:%d SampleBits:%d", errors.ErrUnsupported, d.Channels, d.SampleBits)
 	}
 	return format, err
 }
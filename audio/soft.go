@@ -0,0 +1,347 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+// soft.go mixes sounds in software for audio backends that only
+// provide an output stream, see wasapi.go. It follows the OpenAL
+// behaviour used by openal.go: one voice per loaded sound, playing
+// a sound restarts it, and mono sounds are placed relative to the
+// listener using the inverse distance clamped model.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// softMixer mixes the playing sounds into an output stream.
+// The mixer is called from the stream while sounds are
+// changed by the engine, so all access is locked.
+type softMixer struct {
+	mu      sync.Mutex
+	gain    float32              // listener volume 0 to 1.
+	lx, ly  float64              // listener location.
+	lz      float64              //   "
+	voices  map[uint64]*voice    // loaded sounds.
+	lastID  uint64               // last assigned sound reference.
+	reverb  reverb               // shared by sounds using the Reverb effect.
+	wet     []float32            // reverb send, one sample per output frame.
+	lowpass float32              // low pass filter coefficient for the output rate.
+	rate    int                  // output rate for the low pass coefficient.
+	tap     func([]float32, int) // optional copy of the output, see TapOutput.
+}
+
+// voice is a loaded sound.
+type voice struct {
+	samples  []float32  // interleaved samples -1 to 1.
+	channels int        // 1 or 2.
+	rate     int        // samples per second.
+	pos      float64    // playback position in frames.
+	playing  bool       // true while the sound is playing.
+	stream   bool       // true for sounds queued with queueStream.
+	gain     float32    // bus volume.
+	effect   Effect     // bus effect.
+	left     float32    // gains from the play location.
+	right    float32    //   "
+	lp       [2]float32 // low pass filter state for each channel.
+}
+
+// newSoftMixer returns a mixer with full volume.
+func newSoftMixer() *softMixer {
+	return &softMixer{gain: 1, voices: map[uint64]*voice{}}
+}
+
+// setGain implements audioAPI. Values outside 0 to 1 are ignored.
+func (m *softMixer) setGain(zeroToOne float64) {
+	if zeroToOne >= 0 && zeroToOne <= 1 {
+		m.mu.Lock()
+		m.gain = float32(zeroToOne)
+		m.mu.Unlock()
+	}
+}
+
+// loadSound implements audioAPI. The sound data is converted to
+// float samples. The sound and buffer references are the same.
+func (m *softMixer) loadSound(snd, buff *uint64, d *Data) error {
+	if (d.Channels != 1 && d.Channels != 2) || (d.SampleBits != 8 && d.SampleBits != 16) {
+		return fmt.Errorf("soft:%w: audio format Channels:%d SampleBits:%d", errors.ErrUnsupported, d.Channels, d.SampleBits)
+	}
+	if d.Frequency == 0 {
+		return fmt.Errorf("soft: audio frequency for %s", d.Name)
+	}
+	data := d.AudioData[:min(int(d.DataSize), len(d.AudioData))]
+	var samples []float32
+	if d.SampleBits == 8 {
+		samples = make([]float32, len(data))
+		for i, b := range data {
+			samples[i] = (float32(b) - 128) / 128 // 8-bit samples are unsigned.
+		}
+	} else {
+		samples = make([]float32, len(data)/2)
+		for i := range samples {
+			samples[i] = float32(int16(uint16(data[2*i])|uint16(data[2*i+1])<<8)) / 32768
+		}
+	}
+	v := &voice{samples: samples, channels: int(d.Channels), rate: int(d.Frequency), gain: 1}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	m.voices[m.lastID] = v
+	*snd, *buff = m.lastID, m.lastID
+	return nil
+}
+
+// loadStream implements audioAPI. Streams start without samples.
+func (m *softMixer) loadStream(snd *uint64, channels, rate int) error {
+	if channels != 1 && channels != 2 {
+		return fmt.Errorf("soft:%w: audio stream Channels:%d", errors.ErrUnsupported, channels)
+	}
+	if rate <= 0 {
+		return fmt.Errorf("soft: audio stream rate %d", rate)
+	}
+	v := &voice{channels: channels, rate: rate, gain: 1, stream: true}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	m.voices[m.lastID] = v
+	*snd = m.lastID
+	return nil
+}
+
+// queueStream implements audioAPI. The samples that have
+// been played are discarded before the new samples are added.
+func (m *softMixer) queueStream(snd uint64, pcm []int16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.voices[snd]
+	if !ok || !v.stream {
+		return
+	}
+	played := min(int(v.pos), len(v.samples)/v.channels)
+	v.samples = append(v.samples[:0], v.samples[played*v.channels:]...)
+	v.pos -= float64(played)
+	for _, s := range pcm {
+		v.samples = append(v.samples, float32(s)/32768)
+	}
+}
+
+// dropSound implements audioAPI.
+func (m *softMixer) dropSound(snd, buff uint64) {
+	m.mu.Lock()
+	delete(m.voices, snd)
+	m.mu.Unlock()
+}
+
+// placeListener implements audioAPI.
+func (m *softMixer) placeListener(x, y, z float64) {
+	m.mu.Lock()
+	m.lx, m.ly, m.lz = x, y, z
+	m.mu.Unlock()
+}
+
+// playSound implements audioAPI. The sound restarts if it is
+// already playing, while streams continue from their queued samples.
+// Stereo sounds are not placed, as in OpenAL.
+func (m *softMixer) playSound(snd uint64, x, y, z float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.voices[snd]
+	if !ok {
+		return
+	}
+	if !v.stream {
+		v.pos = 0
+	}
+	v.playing = true
+	v.left, v.right = 1, 1
+	if v.channels == 1 {
+		// inverse distance clamped with a reference distance of 1.
+		dx, dy, dz := x-m.lx, y-m.ly, z-m.lz
+		dist := math.Sqrt(dx*dx + dy*dy + dz*dz)
+		attenuation := 1 / max(dist, 1)
+
+		// equal power pan where the listener faces -Z with +X to the right.
+		pan := 0.0
+		if dist > 0 {
+			pan = dx / dist
+		}
+		angle := (pan + 1) * math.Pi / 4
+		v.left = float32(attenuation * math.Cos(angle))
+		v.right = float32(attenuation * math.Sin(angle))
+	}
+}
+
+// stopSound implements audioAPI. Streams discard their queued samples.
+func (m *softMixer) stopSound(snd uint64) {
+	m.mu.Lock()
+	if v, ok := m.voices[snd]; ok {
+		v.playing = false
+		if v.stream {
+			v.samples, v.pos = v.samples[:0], 0
+		}
+	}
+	m.mu.Unlock()
+}
+
+// setSoundGain implements audioAPI.
+func (m *softMixer) setSoundGain(snd uint64, gain float64) {
+	m.mu.Lock()
+	if v, ok := m.voices[snd]; ok {
+		v.gain = float32(gain)
+	}
+	m.mu.Unlock()
+}
+
+// setSoundEffect implements audioAPI.
+func (m *softMixer) setSoundEffect(snd uint64, effect Effect) {
+	m.mu.Lock()
+	if v, ok := m.voices[snd]; ok {
+		v.effect = effect
+	}
+	m.mu.Unlock()
+}
+
+// hasEffects implements audioAPI.
+func (m *softMixer) hasEffects() bool { return true }
+
+// tapOutput implements audioAPI.
+func (m *softMixer) tapOutput(pcm func(stereo []float32, rate int)) error {
+	m.mu.Lock()
+	m.tap = pcm
+	m.mu.Unlock()
+	return nil
+}
+
+// mix overwrites out with the playing sounds as interleaved
+// stereo samples at the given output rate.
+func (m *softMixer) mix(out []float32, rate int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(out)
+	frames := len(out) / 2
+	if cap(m.wet) < frames {
+		m.wet = make([]float32, frames)
+	}
+	m.wet = m.wet[:frames]
+	clear(m.wet)
+	if m.rate != rate {
+		m.rate = rate
+		m.lowpass = float32(1 - math.Exp(-2*math.Pi*lowPassCutoff/float64(rate)))
+		m.reverb.init(rate)
+	}
+	for _, v := range m.voices {
+		if v.playing {
+			m.mixVoice(v, out, rate)
+		}
+	}
+	m.reverb.process(m.wet, out)
+	for i, s := range out {
+		out[i] = max(-1, min(s, 1))
+	}
+	if m.tap != nil {
+		m.tap(out, rate)
+	}
+}
+
+// mixVoice adds one sound to the output, resampling
+// with linear interpolation. Streams keep playing, silently,
+// when they run out of samples.
+func (m *softMixer) mixVoice(v *voice, out []float32, rate int) {
+	step := float64(v.rate) / float64(rate)
+	frames := len(v.samples) / v.channels
+	gain := m.gain * v.gain
+	for i := 0; i < len(out); i += 2 {
+		at := int(v.pos)
+		if at >= frames {
+			v.playing = v.stream
+			return
+		}
+		frac := float32(v.pos - float64(at))
+		var l, r float32
+		for c := 0; c < v.channels; c++ {
+			s0 := v.samples[at*v.channels+c]
+			s1 := s0
+			if at+1 < frames {
+				s1 = v.samples[(at+1)*v.channels+c]
+			}
+			s := s0 + (s1-s0)*frac
+			if v.effect == LowPass {
+				v.lp[c] += m.lowpass * (s - v.lp[c])
+				s = v.lp[c]
+			}
+			if c == 0 {
+				l, r = s, s
+			} else {
+				r = s
+			}
+		}
+		l, r = l*v.left*gain, r*v.right*gain
+		out[i] += l
+		out[i+1] += r
+		if v.effect == Reverb {
+			m.wet[i/2] += (l + r) * 0.5
+		}
+		v.pos += step
+	}
+}
+
+// lowPassCutoff is the frequency, in Hz, above
+// which the LowPass effect muffles sounds.
+const lowPassCutoff = 800
+
+// reverb is a Schroeder reverberator: parallel comb filters
+// followed by allpass filters. Delays are the freeverb tunings.
+type reverb struct {
+	combs     [4]delay
+	allpasses [2]delay
+}
+
+// delay is a circular sample buffer.
+type delay struct {
+	buf []float32
+	at  int
+}
+
+// reverb tuning.
+const (
+	combFeedback    = 0.84 // longer reverb as this approaches 1.
+	allpassFeedback = 0.5
+	reverbWet       = 0.3 // reverb volume added to the unchanged sound.
+)
+
+// init sizes the delays for the output rate.
+func (r *reverb) init(rate int) {
+	scale := float64(rate) / 44100
+	for i, n := range []int{1116, 1188, 1277, 1356} {
+		r.combs[i] = delay{buf: make([]float32, max(1, int(float64(n)*scale)))}
+	}
+	for i, n := range []int{556, 441} {
+		r.allpasses[i] = delay{buf: make([]float32, max(1, int(float64(n)*scale)))}
+	}
+}
+
+// process adds the reverb of the wet samples to the stereo output.
+// The reverb runs even without input so that the tail fades out.
+func (r *reverb) process(wet, out []float32) {
+	for i, in := range wet {
+		var s float32
+		for c := range r.combs {
+			d := &r.combs[c]
+			y := d.buf[d.at]
+			d.buf[d.at] = in + y*combFeedback
+			d.at = (d.at + 1) % len(d.buf)
+			s += y
+		}
+		s *= 0.25
+		for a := range r.allpasses {
+			d := &r.allpasses[a]
+			y := d.buf[d.at]
+			d.buf[d.at] = s + y*allpassFeedback
+			d.at = (d.at + 1) % len(d.buf)
+			s = y - s*allpassFeedback
+		}
+		out[2*i] += s * reverbWet
+		out[2*i+1] += s * reverbWet
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// go test -run Soft
+func TestSoft(t *testing.T) {
+	// pcm16 returns mono 16-bit sound data holding the given samples.
+	pcm16 := func(rate uint32, samples ...int16) *Data {
+		d := &Data{Name: "test"}
+		data := make([]byte, 0, 2*len(samples))
+		for _, s := range samples {
+			data = append(data, byte(s), byte(uint16(s)>>8))
+		}
+		d.Set(1, 16, rate, uint32(len(data)), data)
+		return d
+	}
+
+	t.Run("play", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		if err := m.loadSound(&snd, &buff, pcm16(100, 16384, 16384)); err != nil {
+			t.Fatal(err)
+		}
+		out := make([]float32, 8) // 4 stereo frames.
+		m.mix(out, 100)
+		if out[0] != 0 {
+			t.Errorf("expected silence before play got %v", out)
+		}
+		m.playSound(snd, 0, 0, 0)
+		m.mix(out, 100)
+		want := float32(0.5 * math.Sqrt2 / 2) // centered equal power pan.
+		if !near(out[0], want) || !near(out[1], want) || !near(out[2], want) || out[4] != 0 {
+			t.Errorf("expected two frames at %f got %v", want, out)
+		}
+		if m.voices[snd].playing {
+			t.Errorf("expected sound to stop at its end")
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(100, 16384, 16384))
+		m.playSound(snd, 0, 0, 0)
+		m.stopSound(snd)
+		out := make([]float32, 4)
+		m.mix(out, 100)
+		if out[0] != 0 || m.voices[snd].playing {
+			t.Errorf("expected silence after stop got %v", out)
+		}
+	})
+
+	t.Run("tap", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(100, 16384, 16384))
+		m.playSound(snd, 0, 0, 0)
+		var tapped []float32
+		m.tapOutput(func(stereo []float32, rate int) { tapped = append(tapped, stereo...) })
+		out := make([]float32, 4)
+		m.mix(out, 100)
+		if len(tapped) != 4 || tapped[0] != out[0] || tapped[0] == 0 {
+			t.Errorf("expected a copy of the output got %v", tapped)
+		}
+		m.tapOutput(nil)
+		m.mix(out, 100)
+		if len(tapped) != 4 {
+			t.Errorf("expected the tap to be removed")
+		}
+	})
+	t.Run("place", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(100, 16384))
+		m.placeListener(1, 0, 0)
+		m.playSound(snd, 5, 0, 0) // 4 units to the right.
+		out := make([]float32, 2)
+		m.mix(out, 100)
+		if !near(out[0], 0) || !near(out[1], 0.5/4) {
+			t.Errorf("expected quiet right side got %v", out)
+		}
+	})
+
+	t.Run("resample", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(50, 0, 16384))
+		m.playSound(snd, 0, 0, 0)
+		out := make([]float32, 10)
+		m.mix(out, 100) // twice the sound rate.
+		pan := float32(math.Sqrt2 / 2)
+		if !near(out[0], 0) || !near(out[2], 0.25*pan) || !near(out[4], 0.5*pan) || !near(out[6], 0.5*pan) || out[8] != 0 {
+			t.Errorf("expected interpolated samples got %v", out)
+		}
+	})
+
+	t.Run("gain", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(100, 16384))
+		m.setGain(0.5)
+		m.setSoundGain(snd, 0.5)
+		m.playSound(snd, 0, 0, 0)
+		out := make([]float32, 2)
+		m.mix(out, 100)
+		if want := float32(0.125 * math.Sqrt2 / 2); !near(out[0], want) {
+			t.Errorf("expected %f got %v", want, out)
+		}
+	})
+
+	t.Run("lowpass", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(44100, 32767, -32768, 32767, -32768))
+		m.setSoundEffect(snd, LowPass)
+		m.playSound(snd, 0, 0, 0)
+		out := make([]float32, 8)
+		m.mix(out, 44100)
+		for _, s := range out {
+			if math.Abs(float64(s)) > 0.1 {
+				t.Fatalf("expected high frequencies removed got %v", out)
+			}
+		}
+	})
+
+	t.Run("reverb", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		m.loadSound(&snd, &buff, pcm16(44100, 32767))
+		m.setSoundEffect(snd, Reverb)
+		m.playSound(snd, 0, 0, 0)
+		out := make([]float32, 2*4410) // 100ms.
+		m.mix(out, 44100)
+		tail := float32(0)
+		for _, s := range out[2:] {
+			tail = max(tail, float32(math.Abs(float64(s))))
+		}
+		if tail == 0 {
+			t.Errorf("expected reverb after the sound")
+		}
+	})
+
+	// go test -run Soft/stream
+	t.Run("stream", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd uint64
+		if err := m.loadStream(&snd, 2, 100); err != nil {
+			t.Fatal(err)
+		}
+		m.queueStream(snd, []int16{16384, -16384, 16384, -16384})
+		m.playSound(snd, 0, 0, 0)
+		out := make([]float32, 6) // 3 stereo frames.
+		m.mix(out, 100)
+		if !near(out[0], 0.5) || !near(out[3], -0.5) || out[4] != 0 || !m.voices[snd].playing {
+			t.Errorf("expected two frames then silence got %v", out)
+		}
+		m.queueStream(snd, []int16{8192, 8192})
+		if v := m.voices[snd]; len(v.samples) != 2 || v.pos > 1 {
+			t.Errorf("expected played samples to be discarded got %d", len(v.samples))
+		}
+		m.mix(out, 100)
+		if !near(out[0], 0.25) {
+			t.Errorf("expected the stream to continue got %v", out)
+		}
+		m.queueStream(snd, []int16{8192, 8192})
+		m.stopSound(snd)
+		if len(m.voices[snd].samples) != 0 {
+			t.Errorf("expected stop to discard the queued samples")
+		}
+		if err := m.loadStream(&snd, 6, 100); err == nil {
+			t.Errorf("expected unsupported channels error")
+		}
+	})
+
+	t.Run("format", func(t *testing.T) {
+		m := newSoftMixer()
+		var snd, buff uint64
+		d := pcm16(100, 0)
+		d.SampleBits = 24
+		if err := m.loadSound(&snd, &buff, d); err == nil {
+			t.Errorf("expected unsupported format error")
+		}
+	})
+}
+
+// near returns true if the samples are almost equal.
+func near(a, b float32) bool { return math.Abs(float64(a-b)) < 0.001 }
@@ -0,0 +1,79 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package audio
+
+// wasapi.go plays sounds through the Windows Audio Session API.
+// It is used when the OpenAL library is not installed.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gazed/vu/internal/audio/wasapi"
+)
+
+// native streams the software mixer to the default output device.
+type native struct {
+	*softMixer
+	stream *wasapi.Stream // created on initialization.
+}
+
+// newNative returns the native audio backend.
+func newNative() *native { return &native{softMixer: newSoftMixer()} }
+
+// init opens the default output device.
+func (n *native) init() (err error) {
+	if n.stream, err = wasapi.Open(n.mix); err != nil {
+		return fmt.Errorf("native audio init %w: %w", ErrDevice, err)
+	}
+	return nil
+}
+
+// dispose closes the output device.
+func (n *native) dispose() {
+	if n.stream != nil {
+		n.stream.Close()
+		n.stream = nil
+	}
+}
+
+// devices implements audioAPI. Only the default device is used.
+func (n *native) devices() []string { return nil }
+
+// device implements audioAPI.
+func (n *native) device() string { return "" }
+
+// setDevice implements audioAPI. The stream always follows
+// the default device, so only "" is accepted.
+func (n *native) setDevice(name string) error {
+	if name != "" {
+		return fmt.Errorf("native audio setDevice %q: %w", name, errors.ErrUnsupported)
+	}
+	return nil
+}
+
+// captureDevices implements audioAPI. Only the default device is used.
+func (n *native) captureDevices() []string { return nil }
+
+// openCapture implements audioAPI. Only "", the default device,
+// can be recorded.
+func (n *native) openCapture(name string, rate int) (recorder, error) {
+	if name != "" {
+		return nil, fmt.Errorf("native audio capture %q: %w", name, errors.ErrUnsupported)
+	}
+	c, err := wasapi.OpenCapture(rate)
+	if err != nil {
+		return nil, fmt.Errorf("native audio capture %w: %w", ErrDevice, err)
+	}
+	return &wasapiCapture{c}, nil
+}
+
+// wasapiCapture adapts the wasapi capture to a recorder.
+type wasapiCapture struct{ *wasapi.Capture }
+
+func (wc *wasapiCapture) read(samples []int16) []int16 { return wc.Read(samples) }
+func (wc *wasapiCapture) close()                       { wc.Close() }
+
+// refresh implements audioAPI. The stream reopens devices
+// itself, this reports when that has happened.
+func (n *native) refresh() bool { return n.stream != nil && n.stream.Changed() }
@@ -0,0 +1,19 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// behavior.go runs behavior trees from the engine update loop.
+
+import (
+	"time"
+
+	"github.com/gazed/vu/ai/bt"
+)
+
+// RunBehavior ticks the behavior tree each engine update using an entity
+// tick, see Entity.OnTick for the maximum interval. Trees for entities far
+// from the camera tick less often. The tree stops when the entity ticks
+// are stopped or the entity is disposed.
+func (e *Entity) RunBehavior(maxInterval int, tree *bt.Tree) *Entity {
+	return e.OnTick(maxInterval, func(delta time.Duration) { tree.Tick(delta) })
+}
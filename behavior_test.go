@@ -0,0 +1,34 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/ai/bt"
+)
+
+// go test -run Behavior
+func TestBehavior(t *testing.T) {
+	app := &application{eids: &entities{}, povs: newPovs(), scenes: newScenes(), ticks: newTicks()}
+	scene := app.addScene(Scene3D)
+	npc := scene.AddPart()
+	ticks := 0
+	tree := bt.NewTree(bt.Action(func(bb *bt.Blackboard, delta time.Duration) bt.Status {
+		ticks++
+		return bt.Running
+	}))
+	npc.RunBehavior(1, tree)
+	for i := 0; i < 3; i++ {
+		app.ticks.update(app, time.Millisecond)
+	}
+	if ticks != 3 {
+		t.Errorf("expected a tree tick each update got %d", ticks)
+	}
+	npc.StopTicks()
+	app.ticks.update(app, time.Millisecond)
+	if ticks != 3 {
+		t.Errorf("expected stopped tree got %d ticks", ticks)
+	}
+}
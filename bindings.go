@@ -0,0 +1,204 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// bindings.go maps named game actions to keys, mouse buttons, gamepad
+// buttons, and gamepad sticks so that games check actions instead of
+// raw key codes. Bindings can be changed while the game is running,
+// eg: from a controls menu, and saved with the player settings.
+//
+//	binds := vu.Bindings{}
+//	binds.BindKey("jump", vu.KSpace)
+//	binds.BindButton("jump", vu.PadA)
+//	binds.BindAxis("move", vu.AxisLX)
+//	p := eng.AssignPlayer(0, vu.Gamepad1, binds)
+//	...
+//	if key, button, ok := p.PressedInput(); ok {
+//		p.Bindings().BindKey("jump", key)       // keyboard player.
+//		p.Bindings().BindButton("jump", button) // gamepad player.
+//	}
+//	...
+//	err := vu.SaveBindings(file, *p.Bindings())
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+
+	"github.com/gazed/vu/device"
+)
+
+// BindingsFormat is the data format written by SaveBindings.
+const BindingsFormat = 1
+
+// Bindings map game actions to the inputs that trigger them. Keyboard
+// players use the keys and gamepad players use the buttons and axes.
+type Bindings struct {
+	Keys    map[string][]int32 `yaml:"keys,omitempty"`    // keys and mouse buttons, eg: KSpace, KML.
+	Buttons map[string]uint16  `yaml:"buttons,omitempty"` // gamepad buttons, eg: PadA|PadB.
+	Axes    map[string]PadAxis `yaml:"axes,omitempty"`    // gamepad sticks and triggers, see Player.Value.
+}
+
+// PadAxis identifies an analog gamepad input.
+type PadAxis uint8
+
+// Gamepad axes.
+const (
+	AxisLX PadAxis = iota + 1 // left stick, -1 left to 1 right.
+	AxisLY                    // left stick, -1 down to 1 up.
+	AxisRX                    // right stick, -1 left to 1 right.
+	AxisRY                    // right stick, -1 down to 1 up.
+	AxisLT                    // left trigger, 0 to 1.
+	AxisRT                    // right trigger, 0 to 1.
+)
+
+// value returns the axis position for the given pad.
+func (a PadAxis) value(pad *device.Pad) float64 {
+	switch a {
+	case AxisLX:
+		return pad.LX
+	case AxisLY:
+		return pad.LY
+	case AxisRX:
+		return pad.RX
+	case AxisRY:
+		return pad.RY
+	case AxisLT:
+		return pad.LT
+	case AxisRT:
+		return pad.RT
+	}
+	return 0
+}
+
+// BindKey adds the key to the action. The key is removed
+// from any other action so that each key does one thing.
+func (b *Bindings) BindKey(action string, key int32) {
+	if b.Keys == nil {
+		b.Keys = map[string][]int32{}
+	}
+	for other, keys := range b.Keys {
+		if i := slices.Index(keys, key); i >= 0 {
+			b.Keys[other] = slices.Delete(keys, i, i+1)
+			if len(b.Keys[other]) == 0 {
+				delete(b.Keys, other)
+			}
+		}
+	}
+	b.Keys[action] = append(b.Keys[action], key)
+}
+
+// BindButton adds the gamepad buttons to the action. The buttons
+// are removed from any other action so each button does one thing.
+func (b *Bindings) BindButton(action string, buttons uint16) {
+	if b.Buttons == nil {
+		b.Buttons = map[string]uint16{}
+	}
+	for other, bound := range b.Buttons {
+		if bound&^buttons == 0 {
+			delete(b.Buttons, other)
+		} else {
+			b.Buttons[other] = bound &^ buttons
+		}
+	}
+	b.Buttons[action] |= buttons
+}
+
+// BindAxis sets the gamepad axis for the action.
+func (b *Bindings) BindAxis(action string, axis PadAxis) {
+	if b.Axes == nil {
+		b.Axes = map[string]PadAxis{}
+	}
+	b.Axes[action] = axis
+}
+
+// Unbind removes all the inputs for the action.
+func (b *Bindings) Unbind(action string) {
+	delete(b.Keys, action)
+	delete(b.Buttons, action)
+	delete(b.Axes, action)
+}
+
+// Clone returns a copy of the bindings that can be changed
+// without affecting the original.
+func (b Bindings) Clone() Bindings {
+	c := Bindings{}
+	if b.Keys != nil {
+		c.Keys = make(map[string][]int32, len(b.Keys))
+		for action, keys := range b.Keys {
+			c.Keys[action] = slices.Clone(keys)
+		}
+	}
+	if b.Buttons != nil {
+		c.Buttons = make(map[string]uint16, len(b.Buttons))
+		for action, buttons := range b.Buttons {
+			c.Buttons[action] = buttons
+		}
+	}
+	if b.Axes != nil {
+		c.Axes = make(map[string]PadAxis, len(b.Axes))
+		for action, axis := range b.Axes {
+			c.Axes[action] = axis
+		}
+	}
+	return c
+}
+
+// SaveBindings writes the bindings to w as YAML data.
+func SaveBindings(w io.Writer, binds Bindings) error {
+	return SaveData(w, "bindings", BindingsFormat, &binds)
+}
+
+// LoadBindings reads bindings written by SaveBindings.
+func LoadBindings(r io.Reader) (binds Bindings, err error) {
+	if err = LoadData(r, "bindings", BindingsFormat, &binds); err != nil {
+		return Bindings{}, fmt.Errorf("LoadBindings: %w", err)
+	}
+	return binds, nil
+}
+
+// =============================================================================
+// player bindings.
+
+// Bindings returns the player bindings so they can be changed
+// while the game is running. Changes apply immediately.
+func (p *Player) Bindings() *Bindings { return &p.binds }
+
+// Value returns the position of the gamepad axis bound to the action.
+// Otherwise returns 1 while the action is down and 0 when it is not, eg:
+//
+//	speed := p.Value("accelerate") // trigger or key.
+func (p *Player) Value(action string) float64 {
+	if pad := p.pad(); pad != nil {
+		if axis, ok := p.binds.Axes[action]; ok {
+			return axis.value(pad)
+		}
+	}
+	if p.Down(action) {
+		return 1
+	}
+	return 0
+}
+
+// PressedInput returns a key or gamepad button that the player pressed
+// since the last update. Used to let players choose their own bindings.
+// Keyboard players return a key and gamepad players return a button.
+func (p *Player) PressedInput() (key int32, button uint16, ok bool) {
+	if pad := p.pad(); pad != nil {
+		if pressed := pad.Buttons &^ pad.Last; pressed != 0 {
+			return 0, 1 << bits.TrailingZeros16(pressed), true
+		}
+		return 0, 0, false
+	}
+	keys := []int32{}
+	for k, pressed := range p.in.Pressed {
+		if pressed {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, 0, false
+	}
+	return slices.Min(keys), 0, true // consistent when several are pressed.
+}
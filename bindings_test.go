@@ -0,0 +1,91 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run Bindings
+func TestBindings(t *testing.T) {
+	t.Run("rebind", func(t *testing.T) {
+		b := Bindings{}
+		b.BindKey("jump", KSpace)
+		b.BindKey("fire", KML)
+		b.BindKey("fire", KSpace) // moves space from jump to fire.
+		b.BindButton("jump", PadA|PadB)
+		b.BindButton("fire", PadB)
+		if _, ok := b.Keys["jump"]; ok || !slices.Equal(b.Keys["fire"], []int32{KML, KSpace}) {
+			t.Errorf("expected space to move to fire got %v", b.Keys)
+		}
+		if b.Buttons["jump"] != PadA || b.Buttons["fire"] != PadB {
+			t.Errorf("expected PadB to move to fire got %v", b.Buttons)
+		}
+		b.Unbind("fire")
+		if _, ok := b.Keys["fire"]; ok {
+			t.Errorf("expected fire to be unbound")
+		}
+	})
+
+	t.Run("players", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		defer eng.app.ld.dispose()
+		binds := Bindings{}
+		binds.BindKey("jump", KSpace)
+		binds.BindButton("jump", PadA)
+		binds.BindAxis("move", AxisLX)
+		keys := eng.AssignPlayer(0, KeyboardMouse, binds)
+		pad := eng.AssignPlayer(1, Gamepad1, binds)
+
+		// rebinding one player does not change the others.
+		keys.Bindings().BindKey("jump", KW)
+		if !slices.Equal(binds.Keys["jump"], []int32{KSpace}) {
+			t.Errorf("expected original bindings to be unchanged")
+		}
+
+		in := eng.app.input
+		in.Pressed[KW] = true
+		in.Down[KW] = time.Now()
+		in.Pads[0].Connected = true
+		in.Pads[0].LX = -0.5
+		in.Pads[0].Buttons = PadA | PadX
+		if keys.Value("jump") != 1 || pad.Value("move") != -0.5 || pad.Value("jump") != 1 {
+			t.Errorf("expected action values got %f %f", keys.Value("jump"), pad.Value("move"))
+		}
+		if key, _, ok := keys.PressedInput(); !ok || key != KW {
+			t.Errorf("expected pressed key got %d", key)
+		}
+		if _, button, ok := pad.PressedInput(); !ok || button != PadA {
+			t.Errorf("expected pressed button got %x", button)
+		}
+	})
+
+	t.Run("save", func(t *testing.T) {
+		b := Bindings{}
+		b.BindKey("jump", KSpace)
+		b.BindButton("jump", PadA)
+		b.BindAxis("move", AxisLX)
+		buf := &bytes.Buffer{}
+		if err := SaveBindings(buf, b); err != nil {
+			t.Fatalf("save failed %s", err)
+		}
+		if !strings.HasPrefix(buf.String(), "kind: bindings\n") {
+			t.Errorf("expected bindings header got %s", buf.String())
+		}
+		loaded, err := LoadBindings(buf)
+		if err != nil {
+			t.Fatalf("load failed %s", err)
+		}
+		if !reflect.DeepEqual(b, loaded) {
+			t.Errorf("expected %v got %v", b, loaded)
+		}
+		if _, err := LoadBindings(strings.NewReader("kind: scene\n")); err == nil {
+			t.Errorf("expected wrong kind to fail")
+		}
+	})
+}
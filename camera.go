@@ -6,6 +6,8 @@ package vu
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
 
 	"github.com/gazed/vu/math/lin"
 )
@@ -25,6 +27,9 @@ type Camera struct {
 	yrot *lin.Q // Y-axis quaternion rotation updated by SetYaw.
 	xrot *lin.Q // X-axis quaternion rotation updated by SetPitch.
 
+	// pitch and yaw in degrees set by application.
+	pitch, yaw float64
+
 	// View matrix. The V part of the MVP transform matrix
 	vm  *lin.M4 // camera view matrix
 	ivm *lin.M4 // Inverse camera view matrix.
@@ -35,12 +40,32 @@ type Camera struct {
 	focus     bool    // true if the camera projection needs setting.
 	pm        *lin.M4 // Projection matrix.
 	ipm       *lin.M4 // Inverse projection matrix.
+
+	// Projection style set by application.
+	ortho          float64 // 3D orthographic view height, zero for perspective.
+	shiftX, shiftY float64 // off-center lens shift in view sizes.
+	custom         *lin.M4 // application projection, nil for none.
+
+	// Pixel perfect orthographic projection set by application.
+	pixels     int  // pixels per unit, zero if not pixel perfect.
+	fitW, fitH int  // units to fit in the window, zero if not fitting.
+	snap       bool // true to snap the camera location to whole pixels.
+	scale      int  // pixels per unit for the current window size.
+
+	// 2D zoom, follow, and bounds set by application.
+	camera2D
+
+	// 3D orbit, chase, and first person rigs set by application.
+	camera3D
 }
 
 // newCamera creates a default rendering field that is looking
 // down the negative Z axis with positive Y up.
 func newCamera() *Camera {
 	c := &Camera{fov: 90, focus: true} // Default fov.
+	c.zoom = 1
+	c.minPitch, c.maxPitch = -89, 89 // Turn limits.
+	c.maxDist = math.Inf(1)          // Orbit limits.
 	c.at = lin.NewT()
 	c.yrot = lin.NewQ().SetAa(0, 1, 0, 0)
 	c.xrot = lin.NewQ().SetAa(0, 0, 0, 0)
@@ -65,6 +90,88 @@ func (c *Camera) SetFov(deg float64) *Camera {
 	return c
 }
 
+// Clip returns the near and far clipping planes.
+func (c *Camera) Clip() (near, far float64) { return c.near, c.far }
+
+// Fov returns the field of view in degrees.
+func (c *Camera) Fov() float64 { return c.fov }
+
+// SetOrthographic makes a 3D camera use an orthographic projection that
+// shows height units vertically, centered on the camera view. Models do
+// not get smaller with distance, as needed for CAD views, isometric games,
+// or directional light shadow views. The width is set from the window
+// aspect ratio. A height less than or equal to 0 restores the perspective
+// projection. Ignored for 2D cameras which are always orthographic.
+// The camera instance is returned.
+func (c *Camera) SetOrthographic(height float64) *Camera {
+	c.ortho, c.focus = max(height, 0), true
+	return c
+}
+
+// SetLensShift moves the center of a 3D camera projection by x,y view
+// sizes, making an off-center frustum without changing the camera
+// location or orientation, eg: 0.5,0 shows the view half a view width
+// to the right. Useful for tiled displays, keeping the horizon low in
+// the view, or architectural views without converging verticals.
+// The camera instance is returned.
+func (c *Camera) SetLensShift(x, y float64) *Camera {
+	c.shiftX, c.shiftY, c.focus = x, y, true
+	return c
+}
+
+// SetProjection makes the camera use the given projection matrix, eg:
+// an oblique near plane for clipping water reflections. The matrix is
+// copied and needs to have an inverse. It replaces the projection set
+// from the clip planes, field of view, and window size until a nil
+// matrix restores the default projection. The camera instance is returned.
+func (c *Camera) SetProjection(pm *lin.M4) *Camera {
+	c.focus = true
+	if pm == nil {
+		c.custom = nil
+		return c
+	}
+	if pm.Det() == 0 {
+		slog.Error("SetProjection needs invertible matrix")
+		return c
+	}
+	c.custom = lin.NewM4().Set(pm)
+	return c
+}
+
+// SetPixelPerfect makes the camera use an orthographic projection where
+// one unit is exactly scale pixels. The origin is the bottom left of the
+// window. Models with whole unit sizes and locations, using textures with
+// one texel per unit, are drawn with each texel covering exactly scale by
+// scale pixels. No half pixel offset is needed since the render surface
+// is sampled at pixel centers. Snap rounds the camera location to whole
+// pixels so that scrolling does not blur pixel art. The projection is
+// updated when the window is resized, including resizes from display
+// DPI changes. A scale less than 1 restores the default projection.
+// The camera instance is returned.
+func (c *Camera) SetPixelPerfect(scale int, snap bool) *Camera {
+	c.pixels, c.fitW, c.fitH, c.snap = max(scale, 0), 0, 0, snap
+	c.focus = true
+	return c
+}
+
+// SetPixelFit is SetPixelPerfect using the largest scale that shows
+// at least width by height units. The scale is recalculated when the
+// window is resized so that a fixed size pixel art view grows in whole
+// pixel steps. The scale is never less than 1. A width or height less
+// than 1 restores the default projection. The camera instance is returned.
+func (c *Camera) SetPixelFit(width, height int, snap bool) *Camera {
+	c.pixels, c.fitW, c.fitH, c.snap = 0, 0, 0, snap
+	if width > 0 && height > 0 {
+		c.fitW, c.fitH = width, height
+	}
+	c.focus = true
+	return c
+}
+
+// PixelScale returns the number of pixels per unit for the current
+// window size. Returns 0 if the camera is not pixel perfect.
+func (c *Camera) PixelScale() int { return c.scale }
+
 // At returns the cameras current location in world space.
 func (c *Camera) At() (x, y, z float64) {
 	return c.at.Loc.GetS()
@@ -97,6 +204,7 @@ func (c *Camera) SetLook(q *lin.Q) { c.at.Rot.Set(q) }
 // SetPitch sets the rotation around the X axis and updates
 // the Look direction. The camera instance is returned.
 func (c *Camera) SetPitch(deg float64) *Camera {
+	c.pitch = deg
 	c.xrot.SetAa(1, 0, 0, lin.Rad(deg))
 	c.at.Rot.Mult(c.xrot, c.yrot).Unit()
 	return c
@@ -105,6 +213,7 @@ func (c *Camera) SetPitch(deg float64) *Camera {
 // SetYaw sets the rotation around the Y axis and updates the
 // Look and Lookat directions. The camera instance is returned.
 func (c *Camera) SetYaw(deg float64) *Camera {
+	c.yaw = deg
 	c.yrot.SetAa(0, 1, 0, lin.Rad(deg))
 	c.at.Rot.Mult(c.xrot, c.yrot).Unit()
 	return c
@@ -113,6 +222,7 @@ func (c *Camera) SetYaw(deg float64) *Camera {
 // Ray applies inverse transforms to derive world space coordinates
 // for a ray projected from the camera through the mouse's mx,my
 // screen position given window width and height ww,wh.
+// Use Unproject for orthographic, off-center, or custom projections.
 func (c *Camera) Ray(mx, my, ww, wh int) (x, y, z float64, err error) {
 	ray := lin.NewV3().SetS(0, 0, 0)
 	if mx >= 0 && mx <= ww && my >= 0 && my <= wh {
@@ -134,6 +244,26 @@ func (c *Camera) Ray(mx, my, ww, wh int) (x, y, z float64, err error) {
 	return 0, 0, 0, fmt.Errorf("mouse not in window")
 }
 
+// Unproject returns the world location under the mx,my screen position
+// given window width and height ww,wh. Depth is 0 for the near clip plane
+// and 1 for the far clip plane. Works with any camera projection, so that
+// picking rays can be made from the near and far locations.
+func (c *Camera) Unproject(mx, my, ww, wh int, depth float64) (x, y, z float64, err error) {
+	if mx < 0 || mx >= ww || my < 0 || my >= wh || ww <= 0 || wh <= 0 {
+		return 0, 0, 0, fmt.Errorf("mouse not in window")
+	}
+	clipx := float64(2*mx)/float64(ww) - 1 // mx to range -1:1
+	clipy := float64(2*my)/float64(wh) - 1 // my to range -1:1
+	v := lin.NewV4().SetS(clipx, clipy, depth, 1)
+	v.MultvM(v, c.ipm) // clip to eye (view) coordinates.
+	if lin.AeqZ(v.W) {
+		return 0, 0, 0, fmt.Errorf("invalid projection")
+	}
+	v.SetS(v.X/v.W, v.Y/v.W, v.Z/v.W, 1)
+	v.MultvM(v, c.ivm) // eye (view) to world coordinates.
+	return v.X, v.Y, v.Z, nil
+}
+
 // RayCastSphere checks for collision between a ray originating from the camera
 // and a sphere in world space. The ray must be a unit vector, see: camera.Ray().
 //   - see: http://en.wikipedia.org/wiki/Line–sphere_intersection
@@ -233,15 +363,72 @@ func (c *Camera) setPerspective(fov, ratio, near, far float64) {
 // This is the projection part of model-view-projection.
 func (c *Camera) setOrthographic(left, right, bottom, top, near, far float64) {
 	c.pm.OrthographicProjection(left, right, bottom, top, near, far)
+	c.ipm.OrthographicInverse(left, right, bottom, top, near, far)
+}
+
+// setFrustum makes the camera use an off-center 3D projection.
+// This is the projection part of model-view-projection.
+func (c *Camera) setFrustum(left, right, bottom, top, near, far float64) {
+	c.pm.FrustumProjection(left, right, bottom, top, near, far)
+	c.ipm.FrustumInverse(left, right, bottom, top, near, far)
+}
+
+// setProjection3D sets the 3D camera perspective or orthographic
+// projection, including any lens shift, for the given aspect ratio.
+func (c *Camera) setProjection3D(ratio float64) {
+	if c.ortho <= 0 && c.shiftX == 0 && c.shiftY == 0 {
+		c.setPerspective(c.fov, ratio, c.near, c.far)
+		return
+	}
+	h := c.ortho * 0.5 // half height on the near plane or ortho view.
+	if c.ortho <= 0 {
+		h = c.near * math.Tan(lin.Rad(c.fov)*0.5)
+	}
+	w := h * ratio
+	dx, dy := 2*w*c.shiftX, 2*h*c.shiftY
+	if c.ortho > 0 {
+		// flip top and bottom since vulkan clip space y points down.
+		c.setOrthographic(-w+dx, w+dx, h+dy, -h+dy, c.near, c.far)
+		return
+	}
+	c.setFrustum(-w+dx, w+dx, -h+dy, h+dy, c.near, c.far)
+}
+
+// setCustom sets the application projection and its inverse.
+func (c *Camera) setCustom() {
+	c.pm.Set(c.custom)
+	c.ipm.Inv(c.custom)
+}
+
+// isPixelPerfect returns true if the camera uses a pixel perfect
+// orthographic projection.
+func (c *Camera) isPixelPerfect() bool { return c.pixels > 0 || c.fitW > 0 }
+
+// setPixelPerfect sets the pixel perfect orthographic projection
+// for the given window size in pixels.
+func (c *Camera) setPixelPerfect(ww, wh uint32) {
+	c.scale = c.pixels
+	if c.fitW > 0 {
+		c.scale = max(1, min(int(ww)/c.fitW, int(wh)/c.fitH))
+	}
+	s := float64(c.scale)
+	c.setOrthographic(0, float64(ww)/s, 0, float64(wh)/s, c.near, c.far)
 }
 
 // updateView recalulates the view matricies.
 func (c *Camera) updateView() {
+	x, y, z := c.at.Loc.GetS()
+	if c.snap && c.scale > 0 {
+		// snap to whole pixels, leaving the camera location unchanged.
+		s := float64(c.scale)
+		x, y = math.Round(x*s)/s, math.Round(y*s)/s
+	}
+
 	// Set the view transform matrix
 	c.vm.SetQ(c.at.Rot)
-	c.vm.TranslateTM(-c.at.Loc.X, -c.at.Loc.Y, -c.at.Loc.Z)
+	c.vm.TranslateTM(-x, -y, -z)
 
 	// Set the view inverse transform matrix
 	c.ivm.SetQ(lin.NewQ().Inv(c.at.Rot))
-	c.ivm.TranslateMT(c.at.Loc.X, c.at.Loc.Y, c.at.Loc.Z)
+	c.ivm.TranslateMT(x, y, z)
 }
@@ -0,0 +1,177 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// camera2d.go moves 2D scene cameras to follow a target entity within
+// world bounds, eg:
+//
+//	cam := scene.Cam().SetPixelFit(320, 180, true)
+//	cam.Follow(player, 32, 16).SetSmoothing(0.2, 0.3).SetBounds(0, 0, 1024, 512)
+//
+// A 2D camera location is the corner of the view so that the view shows
+// the world from the camera location to the camera location plus the
+// view size. The view size is the window size in world units.
+
+import (
+	"math"
+	"time"
+
+	"github.com/gazed/vu/render"
+)
+
+// camera2D holds the 2D camera follow state.
+type camera2D struct {
+	ww, wh       uint32  // window size in pixels.
+	zoom         float64 // 2D zoom, pixels per unit when not pixel perfect.
+	target       eID     // followed entity, 0 for none.
+	deadW, deadH float64 // dead zone size centered in the view.
+	smooth       float64 // seconds to move most of the way to the target.
+	lookahead    float64 // seconds of target velocity to look ahead.
+	tx, ty       float64 // last target location for the target velocity.
+	bounded      bool    // true if the view is kept within the bounds.
+	bx0, by0     float64 // world bounds minimum.
+	bx1, by1     float64 // world bounds maximum.
+}
+
+// SetZoom sets the number of pixels per unit for 2D cameras that are
+// not pixel perfect. The default zoom is 1. Zooms less than or equal
+// to zero are ignored. The camera instance is returned.
+func (c *Camera) SetZoom(zoom float64) *Camera {
+	if zoom > 0 {
+		c.zoom, c.focus = zoom, true
+	}
+	return c
+}
+
+// Zoom returns the 2D camera zoom.
+func (c *Camera) Zoom() float64 { return c.zoom }
+
+// ViewSize returns the size of the 2D camera view in world units.
+// Returns zeros before the first frame is rendered.
+func (c *Camera) ViewSize() (w, h float64) {
+	switch {
+	case c.ww == 0 || c.wh == 0:
+		return 0, 0
+	case c.isPixelPerfect():
+		s := float64(c.pixels)
+		if c.fitW > 0 {
+			s = float64(max(1, min(int(c.ww)/c.fitW, int(c.wh)/c.fitH)))
+		}
+		return float64(c.ww) / s, float64(c.wh) / s
+	}
+	return float64(c.ww) / c.zoom, float64(c.wh) / c.zoom
+}
+
+// Follow moves the 2D camera each update to keep the target entity
+// centered in the view. The camera only moves once the target leaves
+// a dead zone of the given size centered in the view. A nil target
+// stops following. The camera instance is returned.
+//
+// Depends on the target having a transform, see Entity.AddPart.
+func (c *Camera) Follow(target *Entity, deadW, deadH float64) *Camera {
+	c.target, c.deadW, c.deadH = 0, max(deadW, 0), max(deadH, 0)
+	if target != nil {
+		c.target = target.eid
+		c.tx, c.ty, _ = target.World()
+	}
+	return c
+}
+
+// SetSmoothing sets how quickly a following camera catches up to its
+// target. Smooth is the seconds taken to move most of the way, zero
+// to move immediately. Lookahead is the seconds of target movement
+// that the camera leads the target by, zero for no lookahead.
+// The camera instance is returned.
+func (c *Camera) SetSmoothing(smooth, lookahead float64) *Camera {
+	c.smooth, c.lookahead = max(smooth, 0), max(lookahead, 0)
+	return c
+}
+
+// SetBounds keeps the 2D camera view within the given world rectangle.
+// Views larger than the bounds are centered on the bounds. Bounds where
+// the maximum is not larger than the minimum remove the confinement.
+// The camera instance is returned.
+func (c *Camera) SetBounds(x0, y0, x1, y1 float64) *Camera {
+	c.bounded = x1 > x0 && y1 > y0
+	c.bx0, c.by0, c.bx1, c.by1 = x0, y0, x1, y1
+	return c
+}
+
+// ZoomToFit zooms the 2D camera so that the given world rectangle
+// fills as much of the view as possible and centers the view on the
+// rectangle. Pixel perfect cameras change to SetPixelFit with the
+// rectangle size. Ignored before the first frame is rendered.
+// The camera instance is returned.
+func (c *Camera) ZoomToFit(x0, y0, x1, y1 float64) *Camera {
+	w, h := x1-x0, y1-y0
+	if w <= 0 || h <= 0 || c.ww == 0 || c.wh == 0 {
+		return c
+	}
+	if c.isPixelPerfect() {
+		c.SetPixelFit(int(math.Ceil(w)), int(math.Ceil(h)), c.snap)
+	} else {
+		c.SetZoom(min(float64(c.ww)/w, float64(c.wh)/h))
+	}
+	vw, vh := c.ViewSize()
+	c.at.Loc.X, c.at.Loc.Y = x0+(w-vw)*0.5, y0+(h-vh)*0.5
+	return c
+}
+
+// follow moves the camera towards the target and within the bounds.
+// The target location is the world location of the target entity.
+func (c *Camera) follow(tx, ty float64, delta time.Duration) {
+	dt := delta.Seconds()
+	vw, vh := c.ViewSize()
+	x, y := c.at.Loc.X, c.at.Loc.Y
+	if c.target != 0 {
+		// move the view center so the target is in the dead zone.
+		cx, cy := x+vw*0.5, y+vh*0.5
+		cx = min(max(cx, tx-c.deadW*0.5), tx+c.deadW*0.5)
+		cy = min(max(cy, ty-c.deadH*0.5), ty+c.deadH*0.5)
+		if c.lookahead > 0 && dt > 0 {
+			cx += (tx - c.tx) / dt * c.lookahead
+			cy += (ty - c.ty) / dt * c.lookahead
+		}
+		c.tx, c.ty = tx, ty
+
+		// ease towards the new view center.
+		fx, fy := cx-vw*0.5, cy-vh*0.5
+		if c.smooth > 0 {
+			t := 1 - math.Exp(-3*dt/c.smooth) // 95% after smooth seconds.
+			fx, fy = x+(fx-x)*t, y+(fy-y)*t
+		}
+		x, y = fx, fy
+	}
+	if c.bounded {
+		x = confine(x, vw, c.bx0, c.bx1)
+		y = confine(y, vh, c.by0, c.by1)
+	}
+	c.at.Loc.X, c.at.Loc.Y = x, y
+}
+
+// confine returns the view start so that the view size
+// fits between min and max, centering views that do not fit.
+func confine(at, size, lo, hi float64) float64 {
+	if size >= hi-lo {
+		return lo + (hi-lo-size)*0.5
+	}
+	return min(max(at, lo), hi-size)
+}
+
+// follow updates the 2D scene cameras that are following
+// a target or are confined to bounds.
+func (ss *scenes) follow(app *application, delta time.Duration) {
+	ss.eachView(func(s *scene) {
+		c := s.cam
+		if s.pid != render.Pass2D || (c.target == 0 && !c.bounded) {
+			return
+		}
+		var tx, ty float64
+		if p := app.povs.get(c.target); p != nil {
+			tx, ty, _ = p.world()
+		} else {
+			c.target = 0 // target was disposed.
+		}
+		c.follow(tx, ty, delta)
+	})
+}
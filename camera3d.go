@@ -0,0 +1,229 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// camera3d.go moves 3D scene cameras using reusable camera rigs, eg:
+//
+//	cam := scene.Cam().Orbit(player, 10).SetOrbitLimits(2, 30)
+//	cam.Turn(-dx*0.2, -dy*0.2).Dolly(-scroll) // mouse look and zoom.
+//	cam.SetRigBlend(0.5).Chase(car, 8, 3, 0.3) // ease into a chase view.
+//
+// Rigs are updated each frame after the application update so that the
+// camera tracks where its target was moved. Turn sets the yaw and pitch
+// used by the orbit and first person rigs. The chase rig looks at its
+// target. Changing rigs eases the camera from its current location and
+// orientation to the new rig over the rig blend time.
+
+import (
+	"math"
+	"time"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// camera3D holds the 3D camera rig state.
+type camera3D struct {
+	rig       cameraRig // current rig, rigNone for no rig.
+	rigTarget eID       // entity followed by the rig, 0 for none.
+	orbit     float64   // orbit distance from the target.
+	minDist   float64   // orbit distance limits.
+	maxDist   float64   //   "
+	minPitch  float64   // pitch limits in degrees for Turn.
+	maxPitch  float64   //   "
+	back, up  float64   // chase offset in the target frame.
+	eye       float64   // first person height above the target.
+	lag       float64   // seconds for the chase camera to catch up.
+
+	// blend eases from the camera pose when the rig changed.
+	blendTime float64 // seconds to blend to a new rig.
+	blended   float64 // 0 to 1 for how far the blend has progressed.
+	fromLoc   lin.V3  // camera location when the rig changed.
+	fromRot   lin.Q   // camera orientation when the rig changed.
+}
+
+// cameraRig identifies how a 3D camera is moved each frame.
+type cameraRig uint8
+
+const (
+	rigNone        cameraRig = iota // camera is moved by the application.
+	rigOrbit                        // camera circles a target.
+	rigChase                        // camera follows behind a target.
+	rigFirstPerson                  // camera is at the target eye.
+)
+
+// Orbit circles the 3D camera around the target at the given distance.
+// The camera looks at the target from the direction set by Turn. A nil
+// target stops the rig. The camera instance is returned.
+//
+// Depends on the target having a transform, see Entity.AddPart.
+func (c *Camera) Orbit(target *Entity, distance float64) *Camera {
+	c.setRig(rigOrbit, target)
+	c.orbit = min(max(distance, c.minDist), c.maxDist)
+	return c
+}
+
+// SetOrbitLimits limits the orbit distance changed by Dolly.
+// Limits where the maximum is less than the minimum are ignored.
+// The camera instance is returned.
+func (c *Camera) SetOrbitLimits(minDist, maxDist float64) *Camera {
+	if maxDist >= minDist && minDist >= 0 {
+		c.minDist, c.maxDist = minDist, maxDist
+		c.orbit = min(max(c.orbit, minDist), maxDist)
+	}
+	return c
+}
+
+// Dolly moves an orbiting camera closer to, or further from, its target
+// by the given distance, keeping within the orbit limits.
+// The camera instance is returned.
+func (c *Camera) Dolly(distance float64) *Camera {
+	c.orbit = min(max(c.orbit+distance, c.minDist), c.maxDist)
+	return c
+}
+
+// Chase follows the 3D camera behind the target, looking at the target.
+// Back and up are the camera offset in the target frame, where the target
+// faces -Z. Lag is the seconds for the camera to move most of the way to
+// the offset, zero to stay at the offset. A nil target stops the rig.
+// The camera instance is returned.
+//
+// Depends on the target having a transform, see Entity.AddPart.
+func (c *Camera) Chase(target *Entity, back, up, lag float64) *Camera {
+	c.setRig(rigChase, target)
+	c.back, c.up, c.lag = back, up, max(lag, 0)
+	return c
+}
+
+// FirstPerson keeps the 3D camera at the target location raised by the
+// eye height. The camera looks in the direction set by Turn. A nil target
+// leaves the camera location to the application while Turn still keeps
+// the pitch within limits. The camera instance is returned.
+//
+// Depends on the target having a transform, see Entity.AddPart.
+func (c *Camera) FirstPerson(target *Entity, eye float64) *Camera {
+	c.setRig(rigFirstPerson, target)
+	c.rig, c.eye = rigFirstPerson, eye // also without a target.
+	return c
+}
+
+// ClearRig stops moving the 3D camera with a rig. The camera stays
+// where the rig left it. The camera instance is returned.
+func (c *Camera) ClearRig() *Camera {
+	c.setRig(rigNone, nil)
+	return c
+}
+
+// SetPitchLimits limits the pitch in degrees set by Turn. The default
+// limits are -89 to 89 so that the camera never looks straight up or
+// down. Limits where the maximum is less than the minimum are ignored.
+// The camera instance is returned.
+func (c *Camera) SetPitchLimits(minPitch, maxPitch float64) *Camera {
+	if maxPitch >= minPitch {
+		c.minPitch, c.maxPitch = minPitch, maxPitch
+		c.Turn(0, 0)
+	}
+	return c
+}
+
+// Turn changes the camera yaw and pitch by the given degrees, keeping
+// the pitch within the pitch limits. Positive pitch looks up and
+// positive yaw turns left. The camera instance is returned.
+func (c *Camera) Turn(yaw, pitch float64) *Camera {
+	c.SetPitch(min(max(c.pitch+pitch, c.minPitch), c.maxPitch))
+	return c.SetYaw(math.Mod(c.yaw+yaw, 360))
+}
+
+// SetRigBlend sets the seconds taken to ease the camera from where it
+// is to where the next rig puts it. Zero, the default, changes rigs
+// immediately. The camera instance is returned.
+func (c *Camera) SetRigBlend(seconds float64) *Camera {
+	c.blendTime = max(seconds, 0)
+	return c
+}
+
+// setRig changes the camera rig, starting a blend from the current
+// camera location and orientation.
+func (c *Camera) setRig(rig cameraRig, target *Entity) {
+	c.rig, c.rigTarget = rigNone, 0
+	if target != nil {
+		c.rig, c.rigTarget = rig, target.eid
+	}
+	c.blended = 1
+	if c.blendTime > 0 {
+		c.blended = 0
+		c.fromLoc.Set(c.at.Loc)
+		c.fromRot.Set(c.at.Rot)
+	}
+}
+
+// lookAt sets the camera yaw and pitch to face the given location.
+func (c *Camera) lookAt(x, y, z float64) {
+	dx, dy, dz := x-c.at.Loc.X, y-c.at.Loc.Y, z-c.at.Loc.Z
+	flat := math.Hypot(dx, dz)
+	if flat < lin.Epsilon && math.Abs(dy) < lin.Epsilon {
+		return // already at the location.
+	}
+	c.SetPitch(lin.Deg(math.Atan2(dy, flat)))
+	c.SetYaw(lin.Deg(math.Atan2(-dx, -dz)))
+}
+
+// moveRig moves the camera to where the rig puts it given the
+// target world location and rotation.
+func (c *Camera) moveRig(target *pov, delta time.Duration) {
+	tx, ty, tz := target.world()
+	switch c.rig {
+	case rigOrbit:
+		c.at.Rot.Mult(c.xrot, c.yrot).Unit() // undo any blend.
+		bx, by, bz := lin.MultSQ(0, 0, c.orbit, c.at.Rot)
+		c.at.Loc.SetS(tx+bx, ty+by, tz+bz)
+	case rigChase:
+		ox, oy, oz := lin.MultSQ(0, c.up, c.back, target.tw.Rot)
+		x, y, z := tx+ox, ty+oy, tz+oz
+		if c.lag > 0 {
+			t := 1 - math.Exp(-3*delta.Seconds()/c.lag) // 95% after lag seconds.
+			loc := c.at.Loc
+			x, y, z = loc.X+(x-loc.X)*t, loc.Y+(y-loc.Y)*t, loc.Z+(z-loc.Z)*t
+		}
+		c.at.Loc.SetS(x, y, z)
+		c.lookAt(tx, ty, tz)
+	case rigFirstPerson:
+		c.at.Rot.Mult(c.xrot, c.yrot).Unit() // undo any blend.
+		c.at.Loc.SetS(tx, ty+c.eye, tz)
+	}
+}
+
+// blend eases the camera from where it was when the rig changed
+// to where the rig puts it.
+func (c *Camera) blend(delta time.Duration) {
+	if c.blended >= 1 || c.blendTime <= 0 {
+		c.blended = 1
+		return
+	}
+	c.blended = min(c.blended+delta.Seconds()/c.blendTime, 1)
+	s := c.blended * c.blended * (3 - 2*c.blended) // smoothstep.
+	c.at.Loc.Lerp(&c.fromLoc, c.at.Loc, s)
+	if c.fromRot.Dot(c.at.Rot) < 0 {
+		c.fromRot.Neg() // take the shorter way around.
+	}
+	c.at.Rot.Nlerp(&c.fromRot, c.at.Rot, s)
+}
+
+// rigs updates the 3D scene cameras that are using a rig
+// or are blending between rigs.
+func (ss *scenes) rigs(app *application, delta time.Duration) {
+	ss.eachView(func(s *scene) {
+		c := s.cam
+		if s.pid != render.Pass3D || (c.rig == rigNone && c.blended >= 1) {
+			return
+		}
+		if c.rigTarget != 0 {
+			if p := app.povs.get(c.rigTarget); p != nil {
+				c.moveRig(p, delta)
+			} else {
+				c.rig, c.rigTarget = rigNone, 0 // target was disposed.
+			}
+		}
+		c.blend(delta)
+	})
+}
@@ -4,8 +4,10 @@ package vu
 
 import (
 	"testing"
+	"time"
 
 	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
 )
 
 // go test -run Camera
@@ -60,6 +62,154 @@ func TestCamera(t *testing.T) {
 			t.Error("invalid inverse view matrix")
 		}
 	})
+
+	// Test pixel perfect scaling and snapping.
+	t.Run("pixel perfect", func(t *testing.T) {
+		sc := newScene(eID(1), render.Pass2D)
+		cam := sc.cam.SetPixelPerfect(3, true)
+		sc.setProjection(960, 540)
+		cam.SetAt(1.2, 0, 0).updateView()
+		if cam.PixelScale() != 3 || !lin.Aeq(cam.vm.Wx, -4.0/3.0) {
+			t.Errorf("expected camera snapped to pixels got %f", cam.vm.Wx)
+		}
+		cam.SetAt(0, 0, 0).updateView()
+		if sx, sy := cam.Screen(10, 20, -1, 960, 540); sx != 30 || sy != 60 {
+			t.Errorf("expected 3 pixels per unit got %d %d", sx, sy)
+		}
+
+		// fit the scale to the window size.
+		cam.SetPixelFit(320, 180, false)
+		sizes := []struct{ w, h uint32 }{{1920, 1080}, {1366, 768}, {1920, 540}, {300, 100}}
+		for i, scale := range []int{6, 4, 3, 1} {
+			if sc.setProjection(sizes[i].w, sizes[i].h); cam.PixelScale() != scale {
+				t.Errorf("%v expected scale %d got %d", sizes[i], scale, cam.PixelScale())
+			}
+		}
+		if sc.setProjection(960, 540); cam.SetPixelPerfect(0, false).PixelScale() != 3 {
+			t.Errorf("expected scale until the projection is updated")
+		}
+		if sc.setProjection(960, 540); cam.PixelScale() != 0 {
+			t.Errorf("expected default projection")
+		}
+	})
+
+	// Test orthographic, off-center, and custom 3D projections.
+	t.Run("projections", func(t *testing.T) {
+		sc := newScene(eID(1), render.Pass3D)
+		cam := sc.cam.SetOrthographic(6)
+		sc.setProjection(800, 600)
+		cam.updateView() // at the origin looking down -Z.
+		if x, y, z, _ := cam.Unproject(0, 0, 800, 600, 0); !lin.Aeq(x, -4) || !lin.Aeq(y, 3) || !lin.Aeq(z, -0.1) {
+			t.Errorf("expected top left of orthographic view got %f %f %f", x, y, z)
+		}
+		if o, d, ok := cam.pickRay(0, 300); !ok || !lin.Aeq(o.X, -4) || !lin.Aeq(o.Y, 0) || !lin.Aeq(d.Z, -1) {
+			t.Errorf("expected orthographic ray got %v %v", o, d)
+		}
+		if _, _, _, err := cam.Unproject(800, 599, 800, 600, 0); err == nil {
+			t.Errorf("expected pixel past the right edge to be outside the window")
+		}
+		if _, _, _, err := cam.Unproject(0, 600, 800, 600, 0); err == nil {
+			t.Errorf("expected pixel past the bottom edge to be outside the window")
+		}
+
+		// shift the perspective view so the left edge is straight ahead.
+		cam.SetOrthographic(0).SetLensShift(0.5, 0)
+		sc.setProjection(800, 600)
+		if x, _, z, _ := cam.Unproject(0, 300, 800, 600, 1); !lin.Aeq(x, 0) || !lin.Aeq(z, -1000) {
+			t.Errorf("expected off-center frustum got %f %f", x, z)
+		}
+		if !lin.NewM4().Mult(cam.pm, cam.ipm).Aeq(lin.M4I) {
+			t.Error("invalid inverse frustum matrix")
+		}
+
+		// custom projections replace the camera projection.
+		pm := lin.NewM4().PerspectiveProjection(60, 2, 1, 100)
+		cam.SetProjection(pm)
+		sc.setProjection(800, 600)
+		if _, _, z, _ := cam.Unproject(400, 300, 800, 600, 0); !cam.pm.Eq(pm) || !lin.Aeq(z, -1) {
+			t.Errorf("expected custom projection got near %f", z)
+		}
+		cam.SetProjection(nil)
+		if sc.setProjection(800, 600); cam.pm.Eq(pm) {
+			t.Errorf("expected default projection")
+		}
+	})
+
+	// Test 2D follow, bounds, and zoom to fit.
+	t.Run("2D follow", func(t *testing.T) {
+		app := newApplication()
+		defer app.ld.dispose()
+		scene := app.addScene(Scene2D)
+		sc := app.scenes.get(scene.eid)
+		sc.setProjection(200, 100)
+		player := scene.AddPart().SetAt(50, 50, 0)
+		cam := scene.Cam().Follow(player, 20, 10)
+		app.scenes.follow(app, time.Second/60)
+		if x, y, _ := cam.At(); x != -40 || y != 0 {
+			t.Errorf("expected player at dead zone edge got %f %f", x, y)
+		}
+		player.SetAt(55, 53, 0) // within the dead zone.
+		if app.scenes.follow(app, time.Second/60); cam.at.Loc.X != -40 {
+			t.Errorf("expected no move in dead zone got %f", cam.at.Loc.X)
+		}
+		player.SetAt(80, 50, 0) // 10 past the dead zone.
+		if app.scenes.follow(app, time.Second/60); cam.at.Loc.X != -30 {
+			t.Errorf("expected move to dead zone got %f", cam.at.Loc.X)
+		}
+		cam.SetBounds(0, 0, 400, 80)
+		app.scenes.follow(app, time.Second/60)
+		if x, y, _ := cam.At(); x != 0 || y != -10 {
+			t.Errorf("expected view within bounds got %f %f", x, y)
+		}
+		cam.Follow(nil, 0, 0).SetBounds(0, 0, 0, 0).ZoomToFit(0, 0, 100, 100)
+		if w, h := cam.ViewSize(); cam.Zoom() != 1 || w != 200 || h != 100 || cam.at.Loc.X != -50 {
+			t.Errorf("expected fit zoom 1 got %f %f %f", cam.Zoom(), w, h)
+		}
+		cam.ZoomToFit(0, 0, 50, 10)
+		if w, h := cam.ViewSize(); cam.Zoom() != 4 || w != 50 || h != 25 || cam.at.Loc.Y != -7.5 {
+			t.Errorf("expected fit zoom 4 got %f %f %f", cam.Zoom(), w, h)
+		}
+	})
+
+	// Test 3D orbit, chase, and first person rigs.
+	t.Run("3D rigs", func(t *testing.T) {
+		app := newApplication()
+		defer app.ld.dispose()
+		scene := app.addScene(Scene3D)
+		player := scene.AddPart().SetAt(10, 0, 0)
+		cam := scene.Cam().Orbit(player, 5).SetOrbitLimits(2, 8)
+		app.scenes.rigs(app, time.Second/60)
+		if x, y, z := cam.At(); !lin.Aeq(x, 10) || !lin.Aeq(y, 0) || !lin.Aeq(z, 5) {
+			t.Errorf("expected camera behind player got %f %f %f", x, y, z)
+		}
+		cam.Turn(90, -100).Dolly(10) // pitch and distance are limited.
+		app.scenes.rigs(app, time.Second/60)
+		if x, y, _ := cam.At(); cam.pitch != -89 || cam.orbit != 8 || x <= 10 || y <= 7.9 {
+			t.Errorf("expected limited orbit got %f %f %f %f", cam.pitch, cam.orbit, x, y)
+		}
+
+		// chase looks at the player from behind and above.
+		cam.Chase(player, 4, 3, 0)
+		app.scenes.rigs(app, time.Second/60)
+		if x, y, z := cam.At(); !lin.Aeq(x, 10) || !lin.Aeq(y, 3) || !lin.Aeq(z, 4) || !lin.Aeq(cam.pitch, -36.869898) {
+			t.Errorf("expected chase camera got %f %f %f %f", x, y, z, cam.pitch)
+		}
+
+		// blend halfway to the first person eye.
+		cam.SetRigBlend(1).FirstPerson(player, 2)
+		app.scenes.rigs(app, time.Second/2)
+		if x, y, z := cam.At(); !lin.Aeq(x, 10) || !lin.Aeq(y, 2.5) || !lin.Aeq(z, 2) {
+			t.Errorf("expected blended camera got %f %f %f", x, y, z)
+		}
+		app.scenes.rigs(app, time.Second/2)
+		if x, y, z := cam.At(); !lin.Aeq(x, 10) || !lin.Aeq(y, 2) || !lin.Aeq(z, 0) {
+			t.Errorf("expected first person camera got %f %f %f", x, y, z)
+		}
+		app.dispose(nil, player.eid)
+		if app.scenes.rigs(app, time.Second/60); cam.rig != rigNone {
+			t.Errorf("expected rig stopped for disposed target")
+		}
+	})
 }
 
 // go test -run Ray
@@ -0,0 +1,240 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// cloth.go adds cloth models, like flags, capes, and curtains, that are
+// moved by the physics cloth solver. Cloth is simulated in world space
+// after the physics bodies and its mesh is regenerated each frame.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/physics"
+	"github.com/gazed/vu/render"
+)
+
+// AddCloth adds a cloth model of width by height meters that hangs down
+// from the entity. The cloth has cols by rows particles and the top row
+// is pinned to the entity so that it moves with the entity. Both sides
+// of the cloth are drawn using the given assets, eg:
+//
+//	curtain := rail.AddCloth(2, 3, 20, 30, "shd:pbr0", "mat:cloth")
+//	curtain.Cloth().Wind = lin.V3{X: 0, Y: 0, Z: 2}
+//
+// The cloth is centered on the entity with columns along the x axis
+// and rows down the y axis. The front of the cloth faces +Z. Cloth
+// collides with physics bodies but does not push them. Cloth is limited
+// to 32768 particles.
+func (e *Entity) AddCloth(width, height float64, cols, rows int, assets ...string) (me *Entity) {
+	me = e.AddModel(assets...)
+	if width <= 0 || height <= 0 || cols < 2 || rows < 2 || cols*rows > maxClothParticles {
+		slog.Error("AddCloth invalid size", "width", width, "height", height, "cols", cols, "rows", rows)
+		return me
+	}
+	me.app.cloths.create(me.app, me.eid, width, height, cols, rows)
+	return me
+}
+
+// Cloth returns the cloth simulation, eg: to set the wind or to pin
+// more particles. Pinned particles move with the cloth entity.
+// Returns nil if the entity does not have a cloth.
+//
+// Depends on Entity.AddCloth.
+func (e *Entity) Cloth() *physics.Cloth {
+	if c := e.app.cloths.get(e.eid); c != nil {
+		return c.sim
+	}
+	slog.Error("Cloth needs AddCloth", "eid", e.eid)
+	return nil
+}
+
+// =============================================================================
+// cloth data
+
+// maxClothParticles keeps the two sided cloth mesh within uint16 indexes.
+const maxClothParticles = 32768
+
+// cloth is a simulated cloth drawn by the model of the same entity.
+type cloth struct {
+	sim  *physics.Cloth
+	rest []lin.V3 // particle locations relative to the cloth entity.
+
+	// double buffered dynamic meshes with reused mesh data.
+	meshes [2]*mesh
+	flip   int
+	vx, nm []float32 // vertexes and normals, updated each frame.
+	uv     []float32 // texture coordinates.
+	ix     []uint16  // two sided triangles.
+}
+
+// newCloth creates a grid of particles with the top row pinned
+// at the entity world transform p.
+func newCloth(p *pov, width, height float64, cols, rows int) *cloth {
+	c := &cloth{}
+	n := cols * rows
+	verts, indexes := make([]lin.V3, 0, n), make([]uint32, 0, (cols-1)*(rows-1)*6)
+	for r := 0; r < rows; r++ {
+		for col := 0; col < cols; col++ {
+			u, v := float64(col)/float64(cols-1), float64(r)/float64(rows-1)
+			c.rest = append(c.rest, lin.V3{X: width * (u - 0.5), Y: -height * v, Z: 0})
+			verts = append(verts, c.world(p, len(c.rest)-1))
+			c.uv = append(c.uv, float32(u), float32(v))
+		}
+	}
+	for r := 0; r < rows-1; r++ {
+		for col := 0; col < cols-1; col++ {
+			i := uint32(r*cols + col) // counter-clockwise facing +Z.
+			indexes = append(indexes, i, i+uint32(cols), i+1, i+1, i+uint32(cols), i+uint32(cols)+1)
+		}
+	}
+	c.sim = physics.NewCloth(verts, indexes)
+	for col := 0; col < cols; col++ {
+		c.sim.Pin(col, true)
+	}
+
+	// the back of the cloth reuses the particles with reversed triangles.
+	c.uv = append(c.uv, c.uv...)
+	for _, i := range indexes {
+		c.ix = append(c.ix, uint16(i))
+	}
+	for t := 0; t < len(indexes); t += 3 {
+		c.ix = append(c.ix, uint16(indexes[t]+uint32(n)), uint16(indexes[t+2]+uint32(n)), uint16(indexes[t+1]+uint32(n)))
+	}
+	c.vx, c.nm = make([]float32, n*2*3), make([]float32, n*2*3)
+	return c
+}
+
+// world returns the world location of the rest location of particle i
+// for the entity world transform p.
+func (c *cloth) world(p *pov, i int) lin.V3 {
+	at := c.rest[i]
+	at.Mult(&at, p.sw).MultQ(&at, p.tw.Rot).Add(&at, p.tw.Loc)
+	return at
+}
+
+// meshData returns the cloth mesh data relative to the entity
+// world transform p. The back vertexes have reversed normals.
+func (c *cloth) meshData(p *pov) load.MeshData {
+	inv := lin.NewQ().Inv(p.tw.Rot)
+	unscale := &lin.V3{X: clothUnscale(p.sw.X), Y: clothUnscale(p.sw.Y), Z: clothUnscale(p.sw.Z)}
+	particles, normals := c.sim.Particles(), c.sim.Normals()
+	back := len(particles) * 3
+	for i := range particles {
+		at := lin.NewV3().Sub(&particles[i], p.tw.Loc)
+		at.MultQ(at, inv).Mult(at, unscale)
+		n := lin.NewV3().MultQ(&normals[i], inv)
+		n.Mult(n, p.sw).Unit()
+		j := i * 3
+		c.vx[j], c.vx[j+1], c.vx[j+2] = float32(at.X), float32(at.Y), float32(at.Z)
+		c.vx[back+j], c.vx[back+j+1], c.vx[back+j+2] = c.vx[j], c.vx[j+1], c.vx[j+2]
+		c.nm[j], c.nm[j+1], c.nm[j+2] = float32(n.X), float32(n.Y), float32(n.Z)
+		c.nm[back+j], c.nm[back+j+1], c.nm[back+j+2] = -c.nm[j], -c.nm[j+1], -c.nm[j+2]
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(c.vx, 3)  // vec3
+	md[load.Normals] = load.F32Buffer(c.nm, 3)   // vec3
+	md[load.Texcoords] = load.F32Buffer(c.uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(c.ix)
+	return md
+}
+
+// clothUnscale returns the inverse of a world scale, or 0 for no scale.
+func clothUnscale(s float64) float64 {
+	if s == 0 {
+		return 0
+	}
+	return 1 / s
+}
+
+// =============================================================================
+// cloths component manager.
+
+// cloths tracks the cloth components.
+type cloths struct {
+	list map[eID]*cloth
+}
+
+// newCloths creates the cloth component manager.
+// There is only expected to be once instance created by the engine.
+func newCloths() *cloths {
+	return &cloths{list: map[eID]*cloth{}}
+}
+
+// create a cloth for the given model entity.
+func (cs *cloths) create(app *application, eid eID, width, height float64, cols, rows int) {
+	if p := app.povs.get(eid); p != nil {
+		cs.list[eid] = newCloth(p, width, height, cols, rows)
+	}
+}
+
+// get the cloth for the given entity.
+func (cs *cloths) get(eid eID) *cloth { return cs.list[eid] }
+
+// dispose removes the cloth and releases its meshes.
+// Called before the cloth model is disposed.
+func (cs *cloths) dispose(app *application, eid eID) {
+	c := cs.list[eid]
+	if c == nil {
+		return
+	}
+	if m := app.models.get(eid); m != nil {
+		m.mesh = nil // released below.
+	}
+	for _, msh := range c.meshes {
+		if msh != nil {
+			app.ld.release(msh) // generated mesh.
+		}
+	}
+	delete(cs.list, eid)
+}
+
+// simulate moves the pinned particles with their entities and then
+// moves the cloth particles. Called by the engine after the physics
+// bodies are simulated for the same timestep.
+func (cs *cloths) simulate(app *application, timestep float64) {
+	for eid, c := range cs.list {
+		p := app.povs.get(eid)
+		if p == nil {
+			continue
+		}
+		for i := range c.rest {
+			if c.sim.Pinned(i) {
+				c.sim.MoveParticle(i, c.world(p, i))
+			}
+		}
+		c.sim.Simulate(app.sim.bodies, timestep)
+	}
+}
+
+// draw uploads the cloth meshes. Each cloth has two meshes
+// so that one can be updated while the other is rendered.
+// Called by the engine once each frame.
+func (cs *cloths) draw(app *application, rc *render.Context) {
+	for eid, c := range cs.list {
+		m, p := app.models.get(eid), app.povs.get(eid)
+		if m == nil || p == nil {
+			continue
+		}
+		md := c.meshData(p)
+		if c.meshes[0] == nil {
+			mids, err := rc.LoadMeshes([]load.MeshData{md, md})
+			if err != nil {
+				slog.Error("cloth LoadMeshes", "error", err)
+				delete(cs.list, eid)
+				continue
+			}
+			for i, mid := range mids {
+				c.meshes[i] = newMesh("cloth")
+				c.meshes[i].mid = mid
+				c.meshes[i].generated = true
+			}
+		} else if err := rc.UpdateMesh(c.meshes[c.flip].mid, md); err != nil {
+			slog.Error("cloth UpdateMesh", "error", err)
+			continue
+		}
+		m.mesh = c.meshes[c.flip]
+		c.flip = (c.flip + 1) % 2
+	}
+}
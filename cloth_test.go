@@ -0,0 +1,72 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Cloth
+func TestCloth(t *testing.T) {
+	app := newApplication()
+	defer app.ld.dispose()
+	scene := app.addScene(Scene3D)
+	rail := scene.AddPart().SetAt(0, 5, 0)
+	curtain := rail.AddCloth(2, 1, 5, 4, "shd:pbr0")
+	c := app.cloths.get(curtain.eid)
+	if c == nil || curtain.Cloth() == nil {
+		t.Fatal("expected cloth")
+	}
+
+	// go test -run Cloth/pinned
+	t.Run("pinned", func(t *testing.T) {
+		for i := 0; i < 60; i++ {
+			app.cloths.simulate(app, timestepSecs)
+		}
+		p := curtain.Cloth().Particles()
+		if want := (lin.V3{X: -1, Y: 5, Z: 0}); !p[0].Aeq(&want) {
+			t.Errorf("expected pinned particle at %v got %v", want, p[0])
+		}
+		if p[19].Y > 4.2 {
+			t.Errorf("expected cloth to hang got %v", p[19])
+		}
+		rail.SetAt(3, 5, 0)
+		app.cloths.simulate(app, timestepSecs)
+		if want := (lin.V3{X: 2, Y: 5, Z: 0}); !p[0].Aeq(&want) {
+			t.Errorf("expected pinned particle to move with rail got %v", p[0])
+		}
+	})
+
+	// go test -run Cloth/mesh
+	t.Run("mesh", func(t *testing.T) {
+		md := c.meshData(app.povs.get(curtain.eid))
+		if md[load.Vertexes].Count != 40 || md[load.Normals].Count != 40 || md[load.Indexes].Count != 144 {
+			t.Fatalf("unexpected mesh %d %d", md[load.Vertexes].Count, md[load.Indexes].Count)
+		}
+		f32 := func(b load.Buffer, i int) float64 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b.Data[i*4:])))
+		}
+		if x, y := f32(md[load.Vertexes], 0), f32(md[load.Vertexes], 1); x != -1 || y != 0 {
+			t.Errorf("expected entity relative vertex got %f %f", x, y)
+		}
+		if front, back := f32(md[load.Normals], 2), f32(md[load.Normals], 20*3+2); front != -back {
+			t.Errorf("expected reversed back normal %f %f", front, back)
+		}
+	})
+
+	// go test -run Cloth/dispose
+	t.Run("dispose", func(t *testing.T) {
+		c.meshes[0], c.meshes[1] = newMesh("cloth"), newMesh("cloth")
+		c.meshes[0].generated, c.meshes[1].generated = true, true
+		app.models.get(curtain.eid).mesh = c.meshes[0]
+		curtain.Dispose(&Engine{app: app})
+		if app.cloths.get(curtain.eid) != nil || len(app.ld.drops) != 2 {
+			t.Errorf("expected cloth meshes released once got %d", len(app.ld.drops))
+		}
+	})
+}
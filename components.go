@@ -0,0 +1,195 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// components.go exposes the engine component managers as an entity
+// component system (ECS). Each engine component is kept by its own
+// manager and can be iterated without walking the scene graph:
+//
+//	transform : Entity.AddPart
+//	render    : Entity.AddModel, Entity.AddLabel, Entity.AddLight
+//	physics   : Entity.AddToSimulation
+//	audio     : Engine.AddSound
+//	script    : Entity.OnTick
+//
+// Application data is added to entities as typed components that are
+// stored in dense arrays for efficient iteration, eg:
+//
+//	health := vu.NewComponents[Health](eng)
+//	health.Add(monster, Health{hp: 100})
+//	health.Each(func(e *vu.Entity, h *Health) { h.hp += regen })
+
+import (
+	"log/slog"
+)
+
+// AddEntity creates an entity with no components. Components can be
+// added using the application Components. Use Entity.AddPart to create
+// entities that are positioned within a scene.
+func (eng *Engine) AddEntity() *Entity {
+	return &Entity{app: eng.app, eid: eng.app.eids.create()}
+}
+
+// ComponentType identifies the engine components.
+type ComponentType uint8
+
+// Engine component types.
+const (
+	PovComponent   ComponentType = iota // Entity.AddPart transform.
+	ModelComponent                      // Entity.AddModel render model.
+	LightComponent                      // Entity.AddLight light.
+	BodyComponent                       // Entity.AddToSimulation physics body.
+	SoundComponent                      // Engine.AddSound audio.
+	TickComponent                       // Entity.OnTick script.
+)
+
+// Has returns true if the entity has the given engine component.
+func (e *Entity) Has(ct ComponentType) bool {
+	switch ct {
+	case PovComponent:
+		return e.app.povs.get(e.eid) != nil
+	case ModelComponent:
+		return e.app.models.get(e.eid) != nil
+	case LightComponent:
+		return e.app.lights.get(e.eid) != nil
+	case BodyComponent:
+		return e.app.sim.get(e.eid) != nil
+	case SoundComponent:
+		return e.app.sounds.get(e.eid) != nil
+	case TickComponent:
+		return e.app.ticks.has(e.eid)
+	}
+	return false
+}
+
+// Each calls fn for every entity that has the given engine component.
+// Entities are visited in component storage order, not scene graph order.
+// Components must not be added or removed by fn.
+func (eng *Engine) Each(ct ComponentType, fn func(e *Entity)) {
+	app := eng.app
+	switch ct {
+	case PovComponent:
+		for _, eid := range app.povs.eids {
+			fn(&Entity{app: app, eid: eid})
+		}
+	case ModelComponent:
+		for eid := range app.models.list {
+			fn(&Entity{app: app, eid: eid})
+		}
+	case LightComponent:
+		for eid := range app.lights.data {
+			fn(&Entity{app: app, eid: eid})
+		}
+	case BodyComponent:
+		for _, eid := range app.sim.eids {
+			fn(&Entity{app: app, eid: eid})
+		}
+	case SoundComponent:
+		for eid := range app.sounds.list {
+			fn(&Entity{app: app, eid: eid})
+		}
+	case TickComponent:
+		seen := map[eID]bool{} // entities can have more than one tick.
+		for _, t := range app.ticks.list {
+			if !t.removed && !seen[t.eid] {
+				seen[t.eid] = true
+				fn(&Entity{app: app, eid: t.eid})
+			}
+		}
+	default:
+		slog.Error("Each unknown component type", "type", ct)
+	}
+}
+
+// =============================================================================
+// application components.
+
+// componentStore is implemented by application components so that
+// they are removed when their entity is disposed.
+type componentStore interface {
+	dispose(eid eID)
+}
+
+// Components holds one application component of type T for each entity.
+// Components are kept in a dense array for efficient iteration and are
+// removed when their entity is disposed.
+type Components[T any] struct {
+	app   *application
+	index map[eID]uint32 // Sparse entity-id to dense data index.
+	data  []T            // Dense array of component data...
+	eids  []eID          // ...and associated entity identifiers.
+}
+
+// NewComponents creates storage for an application component type.
+// Expected to be called once for each component type on startup.
+func NewComponents[T any](eng *Engine) *Components[T] {
+	cs := &Components[T]{app: eng.app, index: map[eID]uint32{}}
+	eng.app.comps = append(eng.app.comps, cs)
+	return cs
+}
+
+// Add sets the component data for the given entity, replacing any
+// existing data. The returned pointer is valid until the next Add
+// or Remove.
+func (cs *Components[T]) Add(e *Entity, v T) *T {
+	if !e.Exists() {
+		slog.Error("Components.Add needs entity", "eid", e.eid)
+		return nil
+	}
+	if i, ok := cs.index[e.eid]; ok {
+		cs.data[i] = v
+		return &cs.data[i]
+	}
+	cs.index[e.eid] = uint32(len(cs.data))
+	cs.data = append(cs.data, v)
+	cs.eids = append(cs.eids, e.eid)
+	return &cs.data[len(cs.data)-1]
+}
+
+// Get returns the component data for the given entity, or nil if the
+// entity does not have the component. The returned pointer is valid
+// until the next Add or Remove.
+func (cs *Components[T]) Get(e *Entity) *T {
+	if i, ok := cs.index[e.eid]; ok {
+		return &cs.data[i]
+	}
+	return nil
+}
+
+// Remove deletes the component data for the given entity.
+// Does nothing if the entity does not have the component.
+func (cs *Components[T]) Remove(e *Entity) { cs.dispose(e.eid) }
+
+// Len returns the number of entities with the component.
+func (cs *Components[T]) Len() int { return len(cs.data) }
+
+// Each calls fn for every entity with the component. The component
+// of the visited entity can be removed by fn. Adding components
+// from fn is not supported.
+func (cs *Components[T]) Each(fn func(e *Entity, v *T)) {
+	for i := len(cs.data) - 1; i >= 0; i-- {
+		if i < len(cs.data) {
+			fn(&Entity{app: cs.app, eid: cs.eids[i]}, &cs.data[i])
+		}
+	}
+}
+
+// dispose deletes the component data by replacing it with the last
+// element so that the data remains dense.
+func (cs *Components[T]) dispose(eid eID) {
+	index, ok := cs.index[eid]
+	if !ok {
+		return
+	}
+	delete(cs.index, eid)
+	last := len(cs.data) - 1
+	lastID := cs.eids[last]
+	cs.data[index] = cs.data[last]
+	cs.eids[index] = lastID
+	var zero T
+	cs.data[last] = zero // release references held by the data.
+	cs.data, cs.eids = cs.data[:last], cs.eids[:last]
+	if eid != lastID {
+		cs.index[lastID] = index
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Components
+func TestComponents(t *testing.T) {
+	type health struct{ hp int }
+	eng := &Engine{app: newApplication()}
+	defer eng.app.ld.dispose()
+	scene := eng.AddScene(Scene3D)
+	hp := NewComponents[health](eng)
+
+	t.Run("add get remove", func(t *testing.T) {
+		a, b, c := eng.AddEntity(), scene.AddPart(), scene.AddPart()
+		hp.Add(a, health{hp: 1})
+		hp.Add(b, health{hp: 2})
+		hp.Add(c, health{hp: 3})
+		hp.Remove(a)
+		if hp.Len() != 2 || hp.Get(a) != nil || hp.Get(c).hp != 3 {
+			t.Fatalf("expected dense data after remove")
+		}
+		b.Dispose(eng)
+		if hp.Len() != 1 || hp.Get(c).hp != 3 {
+			t.Fatalf("expected component removed with entity")
+		}
+		c.Dispose(eng)
+		a.Dispose(eng)
+	})
+	t.Run("each", func(t *testing.T) {
+		ents := []*Entity{}
+		for i := 0; i < 10; i++ {
+			e := scene.AddPart()
+			hp.Add(e, health{hp: i})
+			ents = append(ents, e)
+		}
+		total := 0
+		hp.Each(func(e *Entity, h *health) {
+			total += h.hp
+			if h.hp%2 == 0 {
+				hp.Remove(e) // removing while iterating.
+			}
+		})
+		if total != 45 || hp.Len() != 5 {
+			t.Errorf("expected all visited and 5 left got %d %d", total, hp.Len())
+		}
+		for _, e := range ents {
+			e.Dispose(eng)
+		}
+	})
+	t.Run("engine components", func(t *testing.T) {
+		e := scene.AddPart().AddToSimulation(Sphere(1, StaticSim))
+		e.OnTick(1, func(delta time.Duration) {})
+		e.OnTick(2, func(delta time.Duration) {})
+		if !e.Has(BodyComponent) || !e.Has(TickComponent) || e.Has(ModelComponent) {
+			t.Errorf("expected body and tick components")
+		}
+		bodies, ticks, povs := 0, 0, 0
+		eng.Each(BodyComponent, func(e *Entity) { bodies++ })
+		eng.Each(TickComponent, func(e *Entity) { ticks++ })
+		eng.Each(PovComponent, func(e *Entity) { povs++ })
+		if bodies != 1 || ticks != 1 || povs != 2 {
+			t.Errorf("expected 1 body 1 tick 2 povs got %d %d %d", bodies, ticks, povs)
+		}
+	})
+}
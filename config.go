@@ -15,6 +15,10 @@ type Config struct {
 	windowed bool   // true to run in windowed mode.
 	x, y     int32  // display top left corner in pixels
 	w, h     int32  // display width and height in pixels
+	highDPI  bool   // true to render at full resolution on high-DPI displays.
+
+	// keep a CPU copy of the mesh triangles.
+	triangles bool // see MeshTriangles.
 
 	// display default background color
 	r, g, b, a float32 // red, green, blue, alpha: range 0-1
@@ -79,6 +83,23 @@ func Windowed() Attr {
 	return func(c *Config) { c.windowed = true }
 }
 
+// HighDPI renders at the full resolution of high-DPI displays instead
+// of letting the OS stretch a 1x image. The Size width and height are
+// scaled by the display scale, and the window size and mouse locations
+// are reported in pixels. See Engine.DisplayScale.
+func HighDPI() Attr {
+	return func(c *Config) { c.highDPI = true }
+}
+
+// MeshTriangles keeps a CPU copy of the triangles of each loaded mesh
+// so that parts can be picked by their triangles, decals can be placed,
+// and lightmaps can be baked, see Engine.Pick, Entity.AddDecal, and
+// Entity.BakeLightmap. Otherwise only the mesh bounds are kept.
+// The built-in meshes, eg: "msh:cube", always keep their triangles.
+func MeshTriangles() Attr {
+	return func(c *Config) { c.triangles = true }
+}
+
 // Background display clear color.
 func Background(r, g, b, a float32) Attr {
 	return func(c *Config) { c.r = r; c.g = g; c.b = b; c.a = a }
@@ -0,0 +1,426 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// console.go provides a drop down developer console for controlling the
+// engine while it runs. The console runs registered commands and shows
+// and changes registered variables, eg:
+//
+//	con := eng.Console()
+//	con.AddVar("speed", "player speed", &speed)
+//	con.AddCommand("spawn", "spawn <kind>", func(args []string) (string, error) {
+//		return spawn(args)
+//	})
+//
+// Typing "speed 12" sets the variable and "speed" shows it. The toggle
+// key, KGrave by default, opens and closes the console. While open the
+// console takes the keyboard input: Tab completes names, the up and down
+// arrows recall earlier commands, and page up and page down scroll the
+// output. Variables are saved and restored with SaveVars and LoadVars.
+//
+// Log output is shown in the console once it is added as a log sink:
+//
+//	vu.NewLogger().AddSink(con, slog.LevelInfo).Install()
+//
+// The console is drawn with the GUI and uses the GUI font, see GUI.SetFont.
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConsoleFormat is the variable data format written by Console.SaveVars.
+const ConsoleFormat = 1
+
+// Console returns the engine developer console, creating it on first use
+// with the "help", "clear", and "quit" commands and the "stats" variable.
+func (eng *Engine) Console() *Console {
+	if eng.app.console == nil {
+		c := newConsole(eng.app)
+		c.AddCommand("quit", "stop the engine", func(args []string) (string, error) {
+			eng.Shutdown()
+			return "", nil
+		})
+		c.AddVar("stats", "show the frame statistics", &eng.showStats)
+		eng.app.console = c
+		eng.GUI()
+	}
+	return eng.app.console
+}
+
+// Console is a drop down command line for commands and variables.
+type Console struct {
+	app  *application
+	key  int32 // toggle key.
+	open bool  // true while the console is shown.
+
+	// command line editing.
+	line    string   // command being typed.
+	history []string // commands run, oldest first.
+	recall  int      // history index while browsing, len(history) otherwise.
+	scroll  int      // output lines scrolled back from the newest.
+
+	cmds  map[string]consoleCmd
+	vars  map[string]consoleVar
+	saved map[string]string // loaded variables waiting for AddVar.
+
+	// output can be written by log handlers on any goroutine.
+	lock sync.Mutex
+	out  []string // output lines, oldest first.
+}
+
+// consoleCmd is a registered command.
+type consoleCmd struct {
+	help string
+	run  func(args []string) (string, error)
+}
+
+// consoleData is the saved console variables.
+type consoleData struct {
+	Vars map[string]string `yaml:"vars"`
+}
+
+// consoleVar is a registered variable.
+type consoleVar struct {
+	help  string
+	value any // *bool, *int, *float64, or *string.
+}
+
+// Limits for the console.
+const maxConsoleLines = 500 // output lines kept.
+
+// Console colors.
+var consoleBack = [3]float32{0.05, 0.05, 0.08}
+
+// newConsole returns a closed console with the built in commands.
+func newConsole(app *application) *Console {
+	c := &Console{app: app, key: KGrave, cmds: map[string]consoleCmd{}, vars: map[string]consoleVar{}}
+	c.AddCommand("help", "list the commands and variables", func(args []string) (string, error) {
+		return c.help(), nil
+	})
+	c.AddCommand("clear", "clear the output", func(args []string) (string, error) {
+		c.lock.Lock()
+		c.out, c.scroll = c.out[:0], 0
+		c.lock.Unlock()
+		return "", nil
+	})
+	return c
+}
+
+// SetKey sets the key that opens and closes the console.
+func (c *Console) SetKey(key int32) *Console {
+	c.key = key
+	return c
+}
+
+// IsOpen returns true while the console is shown. The console
+// takes the keyboard input while it is open.
+func (c *Console) IsOpen() bool { return c.open }
+
+// AddCommand registers a command. The command is run with the words
+// typed after its name and its output, or error, is shown in the console.
+func (c *Console) AddCommand(name, help string, run func(args []string) (string, error)) *Console {
+	if name == "" || strings.ContainsAny(name, " \t") || run == nil {
+		slog.Error("AddCommand needs a name and function", "name", name)
+		return c
+	}
+	c.cmds[name] = consoleCmd{help: help, run: run}
+	return c
+}
+
+// AddVar registers a variable that can be shown and changed from the
+// console. The value must be a *bool, *int, *float64, or *string.
+// A value loaded by LoadVars before the variable was added is applied.
+func (c *Console) AddVar(name, help string, value any) *Console {
+	switch value.(type) {
+	case *bool, *int, *float64, *string:
+	default:
+		slog.Error("AddVar needs a *bool, *int, *float64, or *string", "name", name)
+		return c
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		slog.Error("AddVar needs a name", "name", name)
+		return c
+	}
+	v := consoleVar{help: help, value: value}
+	c.vars[name] = v
+	if s, ok := c.saved[name]; ok {
+		if err := v.set(s); err != nil {
+			slog.Error("AddVar saved value", "name", name, "error", err)
+		}
+		delete(c.saved, name)
+	}
+	return c
+}
+
+// Exec runs a command line as if it was typed into the console.
+func (c *Console) Exec(line string) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return
+	}
+	c.Print("> " + line)
+	name, args := words[0], words[1:]
+	if cmd, ok := c.cmds[name]; ok {
+		out, err := cmd.run(args)
+		if out != "" {
+			c.Print(out)
+		}
+		if err != nil {
+			c.Print("error: " + err.Error())
+		}
+		return
+	}
+	v, ok := c.vars[name]
+	switch {
+	case !ok:
+		c.Print(fmt.Sprintf("unknown command %q, try help", name))
+	case len(args) == 0:
+		c.Print(fmt.Sprintf("%s = %s", name, v.get()))
+	default:
+		if err := v.set(strings.Join(args, " ")); err != nil {
+			c.Print("error: " + err.Error())
+		}
+	}
+}
+
+// Print adds text to the console output.
+// Multiple lines are split into separate output lines.
+func (c *Console) Print(text string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.out = append(c.out, strings.Split(strings.TrimRight(text, "\n"), "\n")...)
+	if extra := len(c.out) - maxConsoleLines; extra > 0 {
+		c.out = append(c.out[:0], c.out[extra:]...)
+	}
+}
+
+// SaveVars writes the console variables to w.
+func (c *Console) SaveVars(w io.Writer) error {
+	vars := map[string]string{}
+	for name, value := range c.saved {
+		vars[name] = value // loaded values for variables not added yet.
+	}
+	for name, v := range c.vars {
+		vars[name] = v.get()
+	}
+	return SaveData(w, "console", ConsoleFormat, &consoleData{Vars: vars})
+}
+
+// LoadVars reads console variables saved by SaveVars. Values for
+// variables that are not added yet are applied by AddVar.
+func (c *Console) LoadVars(r io.Reader) error {
+	data := &consoleData{}
+	if err := LoadData(r, "console", ConsoleFormat, data); err != nil {
+		return err
+	}
+	for name, value := range data.Vars {
+		v, ok := c.vars[name]
+		if !ok {
+			if c.saved == nil {
+				c.saved = map[string]string{}
+			}
+			c.saved[name] = value
+			continue
+		}
+		if err := v.set(value); err != nil {
+			return fmt.Errorf("LoadVars %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Log implements LogSink by showing the log record in the console.
+func (c *Console) Log(rec *LogRecord) { c.Print(rec.String()) }
+
+// =============================================================================
+// console commands and variables.
+
+// help lists the commands and variables.
+func (c *Console) help() string {
+	lines := []string{}
+	for _, name := range c.names("") {
+		if cmd, ok := c.cmds[name]; ok {
+			lines = append(lines, fmt.Sprintf("%-12s %s", name, cmd.help))
+			continue
+		}
+		v := c.vars[name]
+		lines = append(lines, fmt.Sprintf("%-12s %s (%s)", name, v.help, v.get()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// names returns the sorted command and variable names with the given prefix.
+func (c *Console) names(prefix string) (names []string) {
+	for name := range c.cmds {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	for name := range c.vars {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// complete extends the command name being typed. A unique name is
+// completed, otherwise the matches are shown and their common
+// prefix is completed.
+func (c *Console) complete() {
+	if strings.Contains(c.line, " ") {
+		return // only names are completed.
+	}
+	names := c.names(c.line)
+	switch len(names) {
+	case 0:
+		return
+	case 1:
+		c.line = names[0] + " "
+		return
+	}
+	c.Print(strings.Join(names, "  "))
+	common := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	c.line = common
+}
+
+// get returns the variable value as text.
+func (v consoleVar) get() string {
+	switch p := v.value.(type) {
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *string:
+		return *p
+	}
+	return ""
+}
+
+// set parses and sets the variable value.
+func (v consoleVar) set(s string) (err error) {
+	switch p := v.value.(type) {
+	case *bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			*p = b
+		}
+	case *int:
+		var i int
+		if i, err = strconv.Atoi(s); err == nil {
+			*p = i
+		}
+	case *float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 64); err == nil {
+			*p = f
+		}
+	case *string:
+		*p = s
+	}
+	return err
+}
+
+// =============================================================================
+// console input and drawing.
+
+// update toggles the console and, while it is open, edits and runs
+// the command line. The keyboard input is removed so that the
+// application does not react to the typing.
+func (c *Console) update(eng *Engine, in *Input) {
+	if in.Pressed[c.key] {
+		c.open, c.line, c.scroll = !c.open, "", 0
+		c.recall = len(c.history)
+		if eng.dev != nil {
+			eng.SetTextInput(c.open)
+		}
+		c.takeKeys(in)
+		return
+	}
+	if !c.open {
+		return
+	}
+	c.line += in.Text
+	switch {
+	case in.Pressed[KDel] && c.line != "":
+		runes := []rune(c.line)
+		c.line = string(runes[:len(runes)-1])
+	case in.Pressed[KRet]:
+		if line := strings.TrimSpace(c.line); line != "" {
+			if n := len(c.history); n == 0 || c.history[n-1] != line {
+				c.history = append(c.history, line)
+			}
+			c.Exec(line)
+		}
+		c.line, c.scroll, c.recall = "", 0, len(c.history)
+	case in.Pressed[KTab]:
+		c.complete()
+	case in.Pressed[KAUp] && c.recall > 0:
+		c.recall--
+		c.line = c.history[c.recall]
+	case in.Pressed[KADown] && c.recall < len(c.history):
+		c.recall++
+		c.line = ""
+		if c.recall < len(c.history) {
+			c.line = c.history[c.recall]
+		}
+	case in.Pressed[KPgUp]:
+		c.scroll += 5
+	case in.Pressed[KPgDn]:
+		c.scroll = max(0, c.scroll-5)
+	}
+	c.takeKeys(in)
+}
+
+// takeKeys removes the keyboard input, leaving the mouse buttons.
+func (c *Console) takeKeys(in *Input) {
+	mouse := func(k int32) bool { return k == KML || k == KMM || k == KMR }
+	for k := range in.Pressed {
+		if !mouse(k) {
+			delete(in.Pressed, k)
+		}
+	}
+	for k := range in.Down {
+		if !mouse(k) {
+			delete(in.Down, k)
+		}
+	}
+	in.Text = ""
+}
+
+// draw adds the open console to the GUI draws, over the top half of
+// the screen, with the newest output just above the command line.
+func (c *Console) draw(g *GUI, w, h int) {
+	if !c.open {
+		return
+	}
+	lh := g.lineHeight()
+	panel := h / 2
+	g.rect(0, 0, w, panel, consoleBack)
+	lineY := panel - lh - 2*guiPadding
+	g.rect(0, lineY, w, lh+2*guiPadding, guiWidget)
+	g.label(guiPadding, lineY+guiPadding, "> "+c.line+"_")
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	rows := (lineY - guiPadding) / lh
+	c.scroll = max(0, min(c.scroll, len(c.out)-rows))
+	last := len(c.out) - c.scroll
+	for i, y := last-1, lineY-lh; i >= 0 && i >= last-rows; i, y = i-1, y-lh {
+		g.label(guiPadding, y, c.out[i])
+	}
+}
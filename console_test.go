@@ -0,0 +1,193 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run Console
+func TestConsole(t *testing.T) {
+	app := newApplication()
+	eng := &Engine{app: app}
+	con := eng.Console()
+	in := app.input
+
+	// key runs one console update with the given key press and typing.
+	key := func(k int32, text string) {
+		clear(in.Pressed)
+		clear(in.Down)
+		if k != 0 {
+			in.Pressed[k] = true
+			in.Down[k] = time.Now()
+		}
+		in.Text = text
+		con.update(eng, in)
+	}
+	last := func() string { return con.out[len(con.out)-1] }
+
+	// go test -run Console/toggle
+	t.Run("toggle", func(t *testing.T) {
+		key(KA, "a")
+		if con.IsOpen() || !in.Pressed[KA] || in.Text != "a" {
+			t.Errorf("expected closed console to ignore input")
+		}
+		key(KGrave, "")
+		if !con.IsOpen() || len(in.Pressed) != 0 {
+			t.Errorf("expected open console")
+		}
+		key(KA, "a")
+		if con.line != "a" || len(in.Pressed) != 0 || len(in.Down) != 0 || in.Text != "" {
+			t.Errorf("expected console to take the typing got %q", con.line)
+		}
+		key(KML, "")
+		if !in.Pressed[KML] {
+			t.Errorf("expected mouse buttons to pass through")
+		}
+		key(KGrave, "`")
+		if con.IsOpen() || con.line != "" {
+			t.Errorf("expected closed console")
+		}
+	})
+
+	// go test -run Console/commands
+	t.Run("commands", func(t *testing.T) {
+		spawned := []string{}
+		con.AddCommand("spawn", "spawn <kind>", func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", errors.New("spawn needs a kind")
+			}
+			spawned = append(spawned, args...)
+			return "spawned " + args[0], nil
+		})
+		key(KGrave, "")
+		key(0, "spawn orc")
+		key(KRet, "")
+		if len(spawned) != 1 || spawned[0] != "orc" || last() != "spawned orc" || con.line != "" {
+			t.Errorf("expected command to run got %v %q", spawned, last())
+		}
+		con.Exec("spawn")
+		if last() != "error: spawn needs a kind" {
+			t.Errorf("expected command error got %q", last())
+		}
+		con.Exec("fly")
+		if !strings.Contains(last(), "unknown command") {
+			t.Errorf("expected unknown command got %q", last())
+		}
+		con.Exec("help")
+		if !strings.Contains(strings.Join(con.out, "\n"), "spawn        spawn <kind>") {
+			t.Errorf("expected help to list commands")
+		}
+		eng.running = true
+		con.Exec("quit")
+		if eng.running {
+			t.Errorf("expected quit to stop the engine")
+		}
+	})
+
+	// go test -run Console/vars
+	t.Run("vars", func(t *testing.T) {
+		speed, name := 5.0, "hero"
+		con.AddVar("speed", "player speed", &speed).AddVar("name", "player name", &name)
+		con.Exec("speed 12.5")
+		con.Exec("name sir hero")
+		con.Exec("stats true")
+		if speed != 12.5 || name != "sir hero" || !eng.showStats {
+			t.Errorf("expected variables set got %v %q %v", speed, name, eng.showStats)
+		}
+		con.Exec("speed")
+		if last() != "speed = 12.5" {
+			t.Errorf("expected variable shown got %q", last())
+		}
+		con.Exec("speed fast")
+		if speed != 12.5 || !strings.HasPrefix(last(), "error:") {
+			t.Errorf("expected invalid value error got %q", last())
+		}
+		con.AddVar("bad", "unsupported", &[]int{})
+		if _, ok := con.vars["bad"]; ok {
+			t.Errorf("expected unsupported variable type to be ignored")
+		}
+	})
+
+	// go test -run Console/persist
+	t.Run("persist", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := con.SaveVars(buf); err != nil {
+			t.Fatal(err)
+		}
+		other := newConsole(newApplication())
+		speed, lives := 0.0, 3
+		other.AddVar("speed", "", &speed)
+		if err := other.LoadVars(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		other.AddVar("lives", "", &lives)
+		name := ""
+		other.AddVar("name", "", &name) // added after loading.
+		if speed != 12.5 || name != "sir hero" || lives != 3 {
+			t.Errorf("expected loaded variables got %v %q %d", speed, name, lives)
+		}
+	})
+
+	// go test -run Console/history
+	t.Run("history", func(t *testing.T) {
+		con.history, con.recall = []string{"speed 1", "speed 2"}, 2
+		key(KAUp, "")
+		key(KAUp, "")
+		if con.line != "speed 1" {
+			t.Errorf("expected earlier command got %q", con.line)
+		}
+		key(KADown, "")
+		key(KADown, "")
+		if con.line != "" {
+			t.Errorf("expected empty line after the newest command got %q", con.line)
+		}
+		key(0, "sp")
+		key(KTab, "")
+		if con.line != "sp" || last() != "spawn  speed" {
+			t.Errorf("expected matching names got %q", last())
+		}
+		con.line = "qu"
+		key(KTab, "")
+		if con.line != "quit " {
+			t.Errorf("expected completed name got %q", con.line)
+		}
+		key(KDel, "")
+		if con.line != "quit" {
+			t.Errorf("expected backspace got %q", con.line)
+		}
+	})
+
+	// go test -run Console/log
+	t.Run("log", func(t *testing.T) {
+		log := slog.New(NewLogger().AddSink(con, slog.LevelInfo).Handler())
+		log.With("eid", 7).Info("loaded", "asset", "msh:box")
+		log.Debug("hidden")
+		if last() != "INFO engine: loaded eid=7 asset=msh:box" {
+			t.Errorf("expected info in the console got %q", last())
+		}
+		log.Error("failed", "sys", "render")
+		if last() != "ERROR render: failed" {
+			t.Errorf("expected error in the console got %q", last())
+		}
+	})
+
+	// go test -run Console/draw
+	t.Run("draw", func(t *testing.T) {
+		g := eng.GUI()
+		for i := 0; i < 100; i++ {
+			con.Print("line")
+		}
+		con.draw(g, 800, 600)
+		rows := (300 - guiLineHeight - 3*guiPadding) / guiLineHeight
+		if len(g.ix) != 12 || len(g.texts) != rows+1 || !strings.HasPrefix(g.texts[0].str, "> quit") {
+			t.Errorf("expected console panel and %d rows got %d", rows, len(g.texts))
+		}
+		g.frame()
+	})
+}
@@ -0,0 +1,205 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// coroutine.go allows gameplay sequences to be written as linear code
+// instead of state machines, eg:
+//
+//	door.StartCoroutine(func(co *vu.Coroutine) {
+//		door.SetAt(0, 1, 0)
+//		co.WaitSeconds(2)
+//		door.SetAt(0, 2, 0)
+//		co.WaitUntil(func() bool { return player.HasTag("key") })
+//		door.Dispose(eng) // cancels this coroutine.
+//	})
+//
+// Each coroutine runs in its own goroutine, but only while the engine
+// main loop is waiting for it. This means coroutines run one at a time
+// in step with the engine updates and can safely change entities.
+
+import (
+	"log/slog"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// StartCoroutine runs the given function as a coroutine owned by this
+// entity. The coroutine runs immediately until its first wait. It is
+// then resumed from the engine update loop once each wait is over.
+// The coroutine is cancelled when the entity is disposed.
+func (e *Entity) StartCoroutine(fn func(co *Coroutine)) *Coroutine {
+	if !e.Exists() {
+		slog.Error("StartCoroutine needs entity", "eid", e.eid)
+		return nil
+	}
+	return e.app.coroutines.start(e.eid, fn)
+}
+
+// Coroutine is a function that can wait for engine updates. The wait
+// methods only return when the wait is over. A cancelled coroutine
+// exits from its current wait, running any deferred functions.
+//
+// The wait methods must only be called from the coroutine function.
+type Coroutine struct {
+	eid    eID           // owning entity.
+	resume chan bool     // resume the coroutine, false to cancel.
+	yield  chan struct{} // coroutine waiting or done.
+
+	// wait conditions.
+	frames  int           // updates to wait.
+	seconds time.Duration // time to wait.
+	until   func() bool   // condition to wait for.
+
+	running   bool // true while the coroutine is executing.
+	cancelled bool // true if the coroutine has been cancelled.
+	done      bool // true once the coroutine has finished.
+}
+
+// WaitFrames waits for the given number of engine updates.
+func (co *Coroutine) WaitFrames(frames int) {
+	co.frames = max(1, frames)
+	co.wait()
+}
+
+// WaitSeconds waits until the given amount of time has passed.
+// The time is measured on the game clock, see Engine.SetTimeScale.
+func (co *Coroutine) WaitSeconds(seconds float64) {
+	co.seconds = time.Duration(seconds * float64(time.Second))
+	co.frames = 1 // check time starting with the next update.
+	co.wait()
+}
+
+// WaitUntil waits until the given condition returns true.
+// The condition is checked once each engine update.
+func (co *Coroutine) WaitUntil(condition func() bool) {
+	co.until = condition
+	co.frames = 1 // check condition starting with the next update.
+	co.wait()
+}
+
+// Stop cancels the coroutine. A coroutine that stops
+// itself exits at its next wait.
+func (co *Coroutine) Stop() { co.cancel() }
+
+// Done returns true if the coroutine has finished or been cancelled.
+func (co *Coroutine) Done() bool { return co.done }
+
+// wait hands control back to the engine until resumed.
+func (co *Coroutine) wait() {
+	if co.cancelled {
+		runtime.Goexit() // runs deferred functions.
+	}
+	co.running = false
+	co.yield <- struct{}{}
+	if !<-co.resume {
+		runtime.Goexit() // cancelled while waiting.
+	}
+	co.running = true
+}
+
+// step runs the coroutine until it waits or finishes.
+// Expected to be called from the engine main thread.
+func (co *Coroutine) step(ok bool) {
+	co.running = ok
+	co.resume <- ok
+	<-co.yield
+}
+
+// cancel stops the coroutine. Waiting coroutines are resumed
+// so that they can exit. A coroutine that is currently running
+// exits at its next wait.
+func (co *Coroutine) cancel() {
+	switch {
+	case co.done || co.cancelled:
+	case co.running:
+		co.cancelled = true
+	default:
+		co.cancelled = true
+		co.step(false)
+	}
+}
+
+// ready returns true if the coroutine wait is over.
+func (co *Coroutine) ready(delta time.Duration) bool {
+	if co.frames > 1 {
+		co.frames--
+		return false
+	}
+	if co.seconds > 0 {
+		co.seconds -= delta
+		if co.seconds > 0 {
+			return false
+		}
+	}
+	if co.until != nil {
+		if !co.until() {
+			return false
+		}
+		co.until = nil
+	}
+	co.frames = 0
+	return true
+}
+
+// =============================================================================
+// coroutines component manager.
+
+// coroutines tracks the running coroutines.
+type coroutines struct {
+	list []*Coroutine // Active coroutines in the order they were started.
+}
+
+// newCoroutines creates the coroutine component manager.
+// There is only expected to be once instance created by the engine.
+func newCoroutines() *coroutines {
+	return &coroutines{list: []*Coroutine{}}
+}
+
+// start creates a coroutine and runs it until its first wait.
+func (cs *coroutines) start(eid eID, fn func(co *Coroutine)) *Coroutine {
+	co := &Coroutine{eid: eid, resume: make(chan bool), yield: make(chan struct{})}
+	go func() {
+		defer func() {
+			co.done, co.running = true, false
+			co.yield <- struct{}{} // return control to the engine.
+		}()
+		if !<-co.resume {
+			return // cancelled before starting.
+		}
+		fn(co)
+	}()
+	cs.list = append(cs.list, co)
+	co.step(true)
+	return co
+}
+
+// update resumes the coroutines whose waits are over.
+// Called by the engine once each update.
+func (cs *coroutines) update(delta time.Duration) {
+	cnt := len(cs.list) // coroutines started during update have already run.
+	for i := 0; i < cnt; i++ {
+		co := cs.list[i]
+		if !co.done && co.ready(delta) {
+			co.step(true)
+		}
+	}
+	cs.list = slices.DeleteFunc(cs.list, func(co *Coroutine) bool { return co.done })
+}
+
+// dispose cancels the coroutines owned by the given entity.
+func (cs *coroutines) dispose(eid eID) {
+	for _, co := range cs.list {
+		if co.eid == eid {
+			co.cancel()
+		}
+	}
+}
+
+// stop cancels all coroutines. Called when the engine shuts down.
+func (cs *coroutines) stop() {
+	for _, co := range cs.list {
+		co.cancel()
+	}
+	cs.list = cs.list[:0]
+}
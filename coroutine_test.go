@@ -0,0 +1,76 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Coroutine
+func TestCoroutine(t *testing.T) {
+	t.Run("waits", func(t *testing.T) {
+		cs := newCoroutines()
+		steps := []string{}
+		ready := false
+		co := cs.start(1, func(co *Coroutine) {
+			steps = append(steps, "start")
+			co.WaitFrames(2)
+			steps = append(steps, "frames")
+			co.WaitSeconds(0.5)
+			steps = append(steps, "seconds")
+			co.WaitUntil(func() bool { return ready })
+			steps = append(steps, "until")
+		})
+		expect := []int{1, 1, 2, 2, 2, 3, 3, 4}
+		for i, cnt := range expect {
+			if i == 6 {
+				ready = true
+			}
+			if len(steps) != cnt {
+				t.Fatalf("update %d expected %d steps got %v", i, cnt, steps)
+			}
+			cs.update(200 * time.Millisecond)
+		}
+		if !co.Done() || len(cs.list) != 0 {
+			t.Errorf("expected finished coroutine")
+		}
+	})
+	t.Run("cancel", func(t *testing.T) {
+		cs := newCoroutines()
+		cleanup := 0
+		loop := func(co *Coroutine) {
+			defer func() { cleanup++ }()
+			for {
+				co.WaitFrames(1)
+			}
+		}
+		cs.start(1, loop)
+		cs.start(2, loop)
+		cs.update(0)
+		cs.dispose(1) // cancel coroutines for entity 1.
+		cs.update(0)
+		if cleanup != 1 || len(cs.list) != 1 {
+			t.Errorf("expected 1 cancelled coroutine got %d %d", cleanup, len(cs.list))
+		}
+		cs.stop() // engine shutdown.
+		if cleanup != 2 || len(cs.list) != 0 {
+			t.Errorf("expected all coroutines cancelled got %d", cleanup)
+		}
+	})
+	t.Run("self", func(t *testing.T) {
+		cs := newCoroutines()
+		after := false
+		other := cs.start(2, func(co *Coroutine) { co.WaitFrames(1) })
+		co := cs.start(1, func(co *Coroutine) {
+			co.Stop()    // exits at the next wait.
+			other.Stop() // cancel a waiting coroutine.
+			co.WaitFrames(1)
+			after = true
+		})
+		cs.update(0)
+		if !co.Done() || !other.Done() || after {
+			t.Errorf("expected stopped coroutines")
+		}
+	})
+}
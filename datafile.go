@@ -0,0 +1,154 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// datafile.go versions the data files saved by the engine and by the
+// application, like scene files and save games. Each file starts with a
+// header recording the kind of data, the data format, and the engine
+// version that saved it, eg:
+//
+//	kind: scene
+//	format: 1
+//	engine: (devel)
+//
+// Data saved with an older format is upgraded before it is decoded by
+// the migrations registered for the kind of data. Eg: a save game that
+// renamed "hp" to "health" in format 2:
+//
+//	vu.RegisterMigration("save", 1, func(doc map[string]any) error {
+//		doc["health"] = doc["hp"]
+//		delete(doc, "hp")
+//		return nil
+//	})
+//
+// Asset packs are versioned separately, see load.PackFormat.
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gazed/vu/load"
+	"gopkg.in/yaml.v3"
+)
+
+// SceneFormat is the scene data format written by Entity.SaveScene.
+const SceneFormat = 1
+
+// Migration upgrades a decoded data file from one format to the next.
+// The data file is changed in place.
+type Migration func(doc map[string]any) error
+
+// migrations are the registered data upgrades for each kind of
+// data, indexed by the format they upgrade from.
+var migrations struct {
+	lock sync.Mutex
+	list map[string]map[int]Migration
+}
+
+// RegisterMigration adds a migration that upgrades data of the given
+// kind from the given format to the next format. The engine saves the
+// "scene" kind, and the "save" kind for SaveGame. Expected to be
+// called on startup before loading data.
+func RegisterMigration(kind string, from int, migrate Migration) {
+	migrations.lock.Lock()
+	defer migrations.lock.Unlock()
+	if migrations.list == nil {
+		migrations.list = map[string]map[int]Migration{}
+	}
+	if migrations.list[kind] == nil {
+		migrations.list[kind] = map[int]Migration{}
+	}
+	migrations.list[kind][from] = migrate
+}
+
+// SaveData writes v to w as YAML data of the given kind and format
+// with a version header. v must encode as a YAML mapping, eg: a struct.
+func SaveData(w io.Writer, kind string, format int, v any) error {
+	doc := &yaml.Node{}
+	if err := doc.Encode(v); err != nil {
+		return fmt.Errorf("SaveData %s: %w", kind, err)
+	}
+	if doc.Kind != yaml.MappingNode {
+		return fmt.Errorf("SaveData %s: data is not a mapping", kind)
+	}
+	header := &yaml.Node{}
+	header.Encode(&dataHeader{Kind: kind, Format: format, Engine: load.EngineVersion()})
+	doc.Content = append(header.Content, doc.Content...)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("SaveData %s: %w", kind, err)
+	}
+	return enc.Close()
+}
+
+// LoadData decodes YAML or JSON data of the given kind from r into v.
+// Data saved with an older format is upgraded to the given format using
+// the registered migrations. Data without a header is treated as format 1.
+// Data saved with a newer format is not loaded.
+func LoadData(r io.Reader, kind string, format int, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("LoadData %s: %w", kind, err)
+	}
+	hdr := dataHeader{Kind: kind, Format: 1}
+	if err := yaml.Unmarshal(data, &hdr); err != nil {
+		return fmt.Errorf("LoadData %s: %w", kind, err)
+	}
+	saved := fmt.Sprintf("%s format %d", kind, hdr.Format)
+	if hdr.Engine != "" {
+		saved += " saved by engine " + hdr.Engine
+	}
+	switch {
+	case hdr.Kind != kind:
+		return fmt.Errorf("LoadData %s: found %s data", kind, hdr.Kind)
+	case hdr.Format > format:
+		return fmt.Errorf("LoadData %s: is newer than supported format %d", saved, format)
+	case hdr.Format < format:
+		if data, err = migrate(data, kind, hdr.Format, format); err != nil {
+			return fmt.Errorf("LoadData %s: %w", saved, err)
+		}
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("LoadData %s: %w", saved, err)
+	}
+	return nil
+}
+
+// dataHeader is the version header of saved data files.
+type dataHeader struct {
+	Kind   string `yaml:"kind"`
+	Format int    `yaml:"format"`
+	Engine string `yaml:"engine,omitempty"`
+}
+
+// migrate upgrades the data from the given format to the latest
+// format, returning the upgraded data.
+func migrate(data []byte, kind string, from, to int) ([]byte, error) {
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := migrateDoc(doc, kind, from, to); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// migrateDoc upgrades the decoded data in place from the
+// given format to the latest format.
+func migrateDoc(doc map[string]any, kind string, from, to int) error {
+	migrations.lock.Lock()
+	defer migrations.lock.Unlock()
+	for format := from; format < to; format++ {
+		upgrade, ok := migrations.list[kind][format]
+		if !ok {
+			return fmt.Errorf("no migration to format %d", format+1)
+		}
+		if err := upgrade(doc); err != nil {
+			return fmt.Errorf("migrating to format %d: %w", format+1, err)
+		}
+	}
+	return nil
+}
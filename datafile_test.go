@@ -0,0 +1,64 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// go test -run DataFile
+func TestDataFile(t *testing.T) {
+	type saveGame struct {
+		Level  int `yaml:"level"`
+		Health int `yaml:"health"`
+	}
+	RegisterMigration("test-save", 1, func(doc map[string]any) error {
+		doc["health"] = doc["hp"]
+		delete(doc, "hp")
+		return nil
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := SaveData(buf, "test-save", 2, &saveGame{Level: 3, Health: 90}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), "kind: test-save\nformat: 2\n") {
+			t.Errorf("expected version header got\n%s", buf)
+		}
+		sg := saveGame{}
+		if err := LoadData(buf, "test-save", 2, &sg); err != nil || sg.Level != 3 || sg.Health != 90 {
+			t.Errorf("expected saved game got %+v %v", sg, err)
+		}
+	})
+	t.Run("migrate", func(t *testing.T) {
+		sg := saveGame{}
+		old := "kind: test-save\nformat: 1\nlevel: 2\nhp: 50\n"
+		if err := LoadData(strings.NewReader(old), "test-save", 2, &sg); err != nil || sg.Health != 50 {
+			t.Errorf("expected migrated health got %+v %v", sg, err)
+		}
+		noHeader := "level: 2\nhp: 40\n" // treated as format 1.
+		if err := LoadData(strings.NewReader(noHeader), "test-save", 2, &sg); err != nil || sg.Health != 40 {
+			t.Errorf("expected migrated health got %+v %v", sg, err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		sg := saveGame{}
+		newer := "kind: test-save\nformat: 3\nengine: v9.0.0\nlevel: 2\n"
+		err := LoadData(strings.NewReader(newer), "test-save", 2, &sg)
+		if err == nil || !strings.Contains(err.Error(), "format 3 saved by engine v9.0.0") {
+			t.Errorf("expected newer format error got %v", err)
+		}
+		if err := LoadData(strings.NewReader("format: 1\n"), "test-save", 3, &sg); err == nil {
+			t.Errorf("expected missing migration error")
+		}
+		if err := LoadData(strings.NewReader("kind: scene\n"), "test-save", 1, &sg); err == nil {
+			t.Errorf("expected wrong kind error")
+		}
+		if err := SaveData(&bytes.Buffer{}, "test-save", 1, []int{1}); err == nil {
+			t.Errorf("expected mapping error")
+		}
+	})
+}
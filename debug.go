@@ -0,0 +1,293 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// debug.go provides immediate mode drawing for debugging, eg: showing
+// physics volumes or AI paths without creating scene models.
+// Debug draws only last for one frame and must be redrawn each update.
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// Debug returns the engine debug drawing facility. Debug lines and
+// shapes are drawn in world space in the 3D scene. Debug text is drawn
+// in pixels in the 2D scene, which is created if it does not exist.
+// Everything is drawn for one frame, so debug drawing is expected to be
+// done each update, eg:
+//
+//	eng.Debug().Box(x, y, z, 1, 1, 1, 1, 0, 0).Text(10, 10, "box")
+//
+// Debug draws are batched into a single dynamic mesh for lines and one
+// for text. The debug shader is imported on the first call. Debug text
+// also needs the label shader and a bitmap font, see Debug.SetFont.
+func (eng *Engine) Debug() *Debug {
+	if eng.app.debug == nil {
+		eng.app.debug = newDebug(eng.app)
+		eng.app.ld.importAssetData("debug.shd")
+	}
+	return eng.app.debug
+}
+
+// Debug collects lines and text that are drawn for a single frame.
+// Draws past the debug buffer limits are ignored.
+type Debug struct {
+	app *application
+
+	// line data for the current frame.
+	vx []float32 // vec3 line end points.
+	cx []float32 // vec3 line colors.
+	ix []uint16  // line indexes.
+
+	// text data for the current frame.
+	font  string      // font asset name.
+	texts []debugText // text requests.
+	tvx   []float32   // vec2 glyph vertexes.
+	tuv   []float32   // vec2 glyph texcoords.
+	tix   []uint16    // glyph triangle indexes.
+
+	// models draw the debug lines and text using double buffered
+	// dynamic meshes that are updated each frame.
+	lines, text            *Entity
+	lineMeshes, textMeshes [2]*mesh
+	flip                   int // alternates the double buffered meshes.
+}
+
+// debugText is a screen space string.
+type debugText struct {
+	x, y int
+	str  string
+}
+
+// Limits for the debug dynamic meshes.
+const (
+	maxDebugVerts = 16384 // line end points: 8192 lines.
+	maxDebugChars = 4096  // text characters.
+)
+
+// newDebug allocates space for one frame of debug draws.
+func newDebug(app *application) *Debug {
+	return &Debug{
+		app: app,
+		vx:  make([]float32, 0, maxDebugVerts*3),
+		cx:  make([]float32, 0, maxDebugVerts*3),
+		ix:  make([]uint16, 0, maxDebugVerts),
+	}
+}
+
+// SetFont sets the font asset used for debug text, eg: "lucon18".
+// The font and its texture must be imported by the application
+// along with the label shader, eg:
+//
+//	eng.ImportAssets("label.shd", "18:lucon.ttf")
+//	eng.Debug().SetFont("lucon18")
+//
+// The font can only be set before the first debug text is drawn.
+func (d *Debug) SetFont(name string) *Debug {
+	if d.text != nil {
+		slog.Error("Debug.SetFont after text drawn", "font", d.font, "new_font", name)
+		return d
+	}
+	d.font = name
+	return d
+}
+
+// Line draws a line between two world space points using
+// the given color. Color values are from 0 to 1.
+func (d *Debug) Line(x0, y0, z0, x1, y1, z1, r, g, b float64) *Debug {
+	if len(d.ix)+2 > maxDebugVerts {
+		return d // debug buffer full.
+	}
+	i0 := uint16(len(d.vx) / 3)
+	d.vx = append(d.vx, float32(x0), float32(y0), float32(z0), float32(x1), float32(y1), float32(z1))
+	d.cx = append(d.cx, float32(r), float32(g), float32(b), float32(r), float32(g), float32(b))
+	d.ix = append(d.ix, i0, i0+1)
+	return d
+}
+
+// Box draws an axis aligned wireframe box centered at x, y, z
+// with the given half extents along each axis.
+func (d *Debug) Box(x, y, z, hx, hy, hz, r, g, b float64) *Debug {
+	for _, s := range [2]float64{-1, 1} {
+		// 4 edges along the X axis, then Y, then Z.
+		d.Line(x-hx, y+s*hy, z-hz, x+hx, y+s*hy, z-hz, r, g, b)
+		d.Line(x-hx, y+s*hy, z+hz, x+hx, y+s*hy, z+hz, r, g, b)
+		d.Line(x+s*hx, y-hy, z-hz, x+s*hx, y+hy, z-hz, r, g, b)
+		d.Line(x+s*hx, y-hy, z+hz, x+s*hx, y+hy, z+hz, r, g, b)
+		d.Line(x+s*hx, y-hy, z-hz, x+s*hx, y-hy, z+hz, r, g, b)
+		d.Line(x+s*hx, y+hy, z-hz, x+s*hx, y+hy, z+hz, r, g, b)
+	}
+	return d
+}
+
+// Sphere draws a wireframe sphere centered at x, y, z as
+// three circles around the X, Y, and Z axes.
+func (d *Debug) Sphere(x, y, z, radius, r, g, b float64) *Debug {
+	segments := 24
+	step := 2 * math.Pi / float64(segments)
+	for i := 0; i < segments; i++ {
+		s0, c0 := math.Sincos(float64(i) * step)
+		s1, c1 := math.Sincos(float64(i+1) * step)
+		s0, c0, s1, c1 = s0*radius, c0*radius, s1*radius, c1*radius
+		d.Line(x+c0, y+s0, z, x+c1, y+s1, z, r, g, b) // around Z
+		d.Line(x+c0, y, z+s0, x+c1, y, z+s1, r, g, b) // around Y
+		d.Line(x, y+c0, z+s0, x, y+c1, z+s1, r, g, b) // around X
+	}
+	return d
+}
+
+// Axes draws the X, Y, Z axes from the given world space location
+// colored red, green, and blue respectively.
+func (d *Debug) Axes(x, y, z, size float64) *Debug {
+	d.Line(x, y, z, x+size, y, z, 1, 0, 0)
+	d.Line(x, y, z, x, y+size, z, 0, 1, 0)
+	d.Line(x, y, z, x, y, z+size, 0, 0, 1)
+	return d
+}
+
+// Text draws a string in the 2D scene where x, y are pixels
+// from the bottom left of the window. Text is ignored until
+// the debug font has been set and loaded.
+func (d *Debug) Text(x, y int, str string) *Debug {
+	d.texts = append(d.texts, debugText{x: x, y: y, str: str})
+	return d
+}
+
+// draw uploads the debug data collected for this frame and
+// resets for the next frame. Called by the engine before rendering.
+func (d *Debug) draw(rc *render.Context) {
+	d.flip = (d.flip + 1) % 2
+	d.drawLines(rc)
+	d.drawText(rc)
+	d.vx, d.cx, d.ix = d.vx[:0], d.cx[:0], d.ix[:0]
+	d.texts = d.texts[:0]
+}
+
+// drawLines updates the line mesh for the 3D scene.
+func (d *Debug) drawLines(rc *render.Context) {
+	if d.lines == nil {
+		if len(d.ix) == 0 {
+			return // nothing to draw.
+		}
+		scene := d.app.scenes.first(d.app, Scene3D)
+		if scene == nil {
+			return // lines need a 3D scene.
+		}
+		var err error
+		if d.lineMeshes, err = debugMeshes(rc, "debugLines", maxDebugVerts, 3, true, maxDebugVerts); err != nil {
+			slog.Error("Debug lines", "error", err)
+			return
+		}
+		d.lines = scene.AddModel("shd:debug")
+	}
+	d.lines.Cull(len(d.ix) == 0)
+	if len(d.ix) == 0 {
+		return
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(d.vx, 3) // vec3
+	md[load.Colors] = load.F32Buffer(d.cx, 3)   // vec3
+	md[load.Indexes] = load.U16Buffer(d.ix)
+	setMesh(d.app, rc, d.lines, d.lineMeshes[d.flip], md)
+}
+
+// drawText updates the text mesh for the 2D scene.
+func (d *Debug) drawText(rc *render.Context) {
+	if d.text == nil && len(d.texts) == 0 {
+		return // nothing to draw.
+	}
+	f, _ := d.app.ld.getLoadedAsset(assetID(fnt, d.font)).(*font)
+	if f == nil {
+		return // waiting for SetFont and the font to load.
+	}
+
+	// generate the glyph quads for all the text.
+	d.tvx, d.tuv, d.tix = textQuads(f, d.texts, maxDebugChars, d.tvx[:0], d.tuv[:0], d.tix[:0])
+	if d.text == nil {
+		scene := d.app.scenes.first(d.app, Scene2D)
+		if scene == nil {
+			scene = d.app.addScene(Scene2D)
+		}
+		var err error
+		if d.textMeshes, err = debugMeshes(rc, "debugText", maxDebugChars*4, 2, false, maxDebugChars*6); err != nil {
+			slog.Error("Debug text", "error", err)
+			return
+		}
+		d.text = scene.AddModel("shd:label", "tex:color:"+d.font)
+		d.text.SetColor(1, 1, 1, 1).SetLayer(15) // draw over other 2D models.
+	}
+	d.text.Cull(len(d.tix) == 0)
+	if len(d.tix) == 0 {
+		return
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(d.tvx, 2)  // vec2
+	md[load.Texcoords] = load.F32Buffer(d.tuv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(d.tix)
+	setMesh(d.app, rc, d.text, d.textMeshes[d.flip], md)
+}
+
+// textQuads appends the glyph quads for the given screen space strings,
+// up to the given number of characters. Only the first font atlas page
+// is used so that all the text can be drawn by a single model.
+func textQuads(f *font, texts []debugText, limit int, vx, uv []float32, ix []uint16) ([]float32, []float32, []uint16) {
+	for _, t := range texts {
+		for i, line := range f.wrapLines(t.str, 0) {
+			x, y := t.x, t.y+i*f.lineh
+			for j, r := range line {
+				c := f.glyph(r)
+				if c == nil {
+					continue
+				}
+				if c.w != 0 && c.h != 0 && c.page == 0 && len(ix) < limit*6 {
+					vx, uv, ix = c.quad(x, y, vx, uv, ix)
+				}
+				next := rune(0)
+				if j+1 < len(line) {
+					next = line[j+1]
+				}
+				x += f.advance(r, next)
+			}
+		}
+	}
+	return vx, uv, ix
+}
+
+// setMesh uploads the mesh data to the given dynamic mesh
+// and uses it for the given model.
+func setMesh(app *application, rc *render.Context, me *Entity, msh *mesh, md load.MeshData) {
+	if err := rc.UpdateMesh(msh.mid, md); err != nil {
+		slog.Error("Debug UpdateMesh", "error", err)
+		return
+	}
+	if m := app.models.get(me.eid); m != nil {
+		m.mesh = msh
+	}
+}
+
+// debugMeshes allocates two dynamic meshes with space for the given
+// number of vertexes and indexes. Vertexes have the given dimension
+// and either colors or texture coordinates.
+func debugMeshes(rc *render.Context, name string, verts, dim int, colors bool, indexes int) (meshes [2]*mesh, err error) {
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(make([]float32, verts*dim), uint32(dim))
+	if colors {
+		md[load.Colors] = load.F32Buffer(make([]float32, verts*3), 3)
+	} else {
+		md[load.Texcoords] = load.F32Buffer(make([]float32, verts*2), 2)
+	}
+	md[load.Indexes] = load.U16Buffer(make([]uint16, indexes))
+	mids, err := rc.LoadMeshes([]load.MeshData{md, md})
+	if err != nil {
+		return meshes, err
+	}
+	for i, mid := range mids {
+		meshes[i] = newMesh(name)
+		meshes[i].mid = mid
+	}
+	return meshes, nil
+}
@@ -0,0 +1,30 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+)
+
+// go test -run Debug
+func TestDebug(t *testing.T) {
+	d := newDebug(nil)
+	t.Run("shapes", func(t *testing.T) {
+		d.Box(0, 0, 0, 1, 1, 1, 1, 0, 0)
+		if len(d.ix) != 24 || len(d.vx) != 24*3 || len(d.cx) != 24*3 {
+			t.Errorf("expected 12 box lines got %d indexes", len(d.ix))
+		}
+		d.Axes(0, 0, 0, 1).Sphere(0, 0, 0, 1, 0, 1, 0)
+		if len(d.ix) != 24+6+24*6 || d.ix[len(d.ix)-1] != uint16(len(d.ix)-1) {
+			t.Errorf("unexpected line indexes %d", len(d.ix))
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		for i := 0; i < maxDebugVerts; i++ {
+			d.Line(0, 0, 0, 1, 1, 1, 1, 1, 1)
+		}
+		if len(d.ix) != maxDebugVerts || len(d.vx) != maxDebugVerts*3 {
+			t.Errorf("expected full debug buffer got %d indexes", len(d.ix))
+		}
+	})
+}
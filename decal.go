@@ -0,0 +1,381 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// decal.go projects textures, like bullet holes, blood, and tire marks,
+// onto the 3D scene geometry at runtime, eg:
+//
+//	holes := scene.AddDecals(64, 20*time.Second, "shd:tex3D", "tex:color:hole")
+//	if _, x, y, z, ok := eng.Pick(scene, in.Mx, in.My); ok {
+//		cx, cy, cz := scene.Cam().At() // face the hole towards the camera.
+//		holes.AddDecal(x, y, z, cx-x, cy-y, cz-z, 0.2, rand.Float64()*360)
+//	}
+//
+// Each decal is a box centered on a surface location and facing along the
+// surface normal. The scene part triangles that face the decal are clipped
+// to the box and become decal triangles with texture coordinates projected
+// across the box. The decal triangles are generated once on the CPU and all
+// the decals in a pool are drawn using one mesh. A pool holds a fixed
+// number of decals where a new decal replaces the oldest decal when the
+// pool is full. Decals are removed once they are older than the pool
+// lifetime. Decals stay where they were made, so they are expected to be
+// added to parts that do not move.
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// AddDecals adds a pool of up to count decals drawn using the given
+// shader and texture assets. Decals are removed after the lifetime,
+// or kept until replaced if the lifetime is zero. The decals are drawn
+// relative to the pool, so the pool is normally left at the scene origin.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) AddDecals(count int, lifetime time.Duration, assets ...string) (me *Entity) {
+	me = e.AddModel(assets...)
+	if count <= 0 || lifetime < 0 {
+		slog.Error("AddDecals invalid pool", "count", count, "lifetime", lifetime)
+		return me
+	}
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		slog.Error("AddDecals needs 3D scene", "eid", e.eid)
+		return me
+	}
+	me.app.decals.create(me.eid, scene, count, lifetime)
+	return me
+}
+
+// AddDecal projects the decal pool texture onto the scene parts near the
+// world location x,y,z. The texture faces along the surface normal nx,ny,nz
+// and is size by size units, rotated by angle degrees around the normal.
+// Only part triangles facing the normal and within size/2 of the surface
+// location are covered. Parts need mesh triangles to be covered, see
+// MeshTriangles and Engine.Pick.
+// Returns the decal pool entity.
+//
+// Depends on Entity.AddDecals.
+func (e *Entity) AddDecal(x, y, z, nx, ny, nz, size, angle float64) *Entity {
+	pool := e.app.decals.get(e.eid)
+	if pool == nil {
+		slog.Error("AddDecal needs AddDecals", "eid", e.eid)
+		return e
+	}
+	normal := lin.V3{X: nx, Y: ny, Z: nz}
+	if size <= 0 || lin.AeqZ(normal.Len()) {
+		slog.Error("AddDecal invalid decal", "size", size, "normal", normal)
+		return e
+	}
+	d := newDecal(lin.V3{X: x, Y: y, Z: z}, *normal.Unit(), size, angle)
+	d.project(e.app, pool)
+	pool.add(d)
+	return e
+}
+
+// ClearDecals removes all the decals from the decal pool.
+//
+// Depends on Entity.AddDecals.
+func (e *Entity) ClearDecals() *Entity {
+	if pool := e.app.decals.get(e.eid); pool != nil {
+		pool.list, pool.dirty = pool.list[:0], true
+		return e
+	}
+	slog.Error("ClearDecals needs AddDecals", "eid", e.eid)
+	return e
+}
+
+// DecalCount returns the number of decals in the decal pool.
+//
+// Depends on Entity.AddDecals.
+func (e *Entity) DecalCount() int {
+	if pool := e.app.decals.get(e.eid); pool != nil {
+		return len(pool.list)
+	}
+	slog.Error("DecalCount needs AddDecals", "eid", e.eid)
+	return 0
+}
+
+// =============================================================================
+// decal data
+
+const (
+	maxDecalVerts = math.MaxUint16 // pool mesh uses uint16 indexes.
+	decalFacing   = 0.1            // cosine of the steepest covered surface.
+	decalLift     = 0.002          // decal offset from the surface per size.
+)
+
+// decal holds the clipped world space triangles for one decal.
+type decal struct {
+	at, normal  lin.V3  // box center and facing.
+	right, up   lin.V3  // box texture directions.
+	size        float64 // box size in all directions.
+	age         time.Duration
+	verts, norm []lin.V3  // world vertexes and normals.
+	uvs         []float32 // texture coordinates.
+	tris        []uint16  // triangle indexes into verts.
+}
+
+// newDecal creates a decal box at the given location. The texture
+// up direction is world up rotated by angle around the normal.
+func newDecal(at, normal lin.V3, size, angle float64) *decal {
+	d := &decal{at: at, normal: normal, size: size}
+	up := lin.V3{Y: 1}
+	if math.Abs(normal.Y) > 0.99 {
+		up = lin.V3{Z: -1} // looking straight up or down.
+	}
+	d.right.Cross(&up, &normal).Unit()
+	d.up.Cross(&normal, &d.right)
+	sin, cos := math.Sincos(lin.Rad(angle))
+	r, u := d.right, d.up
+	d.right.SetS(r.X*cos+u.X*sin, r.Y*cos+u.Y*sin, r.Z*cos+u.Z*sin)
+	d.up.SetS(u.X*cos-r.X*sin, u.Y*cos-r.Y*sin, u.Z*cos-r.Z*sin)
+	return d
+}
+
+// project clips the triangles of the scene parts that overlap the
+// decal box and keeps them as the decal triangles.
+func (d *decal) project(app *application, pool *decalPool) {
+	half := d.size * 0.5 * math.Sqrt(3) // box corners from any orientation.
+	lo := lin.V3{X: d.at.X - half, Y: d.at.Y - half, Z: d.at.Z - half}
+	hi := lin.V3{X: d.at.X + half, Y: d.at.Y + half, Z: d.at.Z + half}
+	overlaps := func(blo, bhi *lin.V3) bool {
+		return blo.X <= hi.X && blo.Y <= hi.Y && blo.Z <= hi.Z &&
+			bhi.X >= lo.X && bhi.Y >= lo.Y && bhi.Z >= lo.Z
+	}
+	app.spatial.update(app)
+	app.spatial.tree.query(overlaps, func(n *bvhNode) {
+		if n.scene != pool.scene || n.eid == pool.eid || culled(app.povs, n.eid) || !overlaps(&n.blo, &n.bhi) {
+			return
+		}
+		m, p := app.models.get(n.eid), app.povs.get(n.eid)
+		if m == nil || p == nil || m.mesh == nil {
+			return
+		}
+		d.addMesh(m.mesh, p.wm)
+	})
+}
+
+// addMesh clips the mesh triangles, moved to world space by the world
+// matrix wm, to the decal box.
+func (d *decal) addMesh(msh *mesh, wm *lin.M4) {
+	world := func(v *lin.V3) lin.V3 {
+		return lin.V3{
+			X: v.X*wm.Xx + v.Y*wm.Yx + v.Z*wm.Zx + wm.Wx,
+			Y: v.X*wm.Xy + v.Y*wm.Yy + v.Z*wm.Zy + wm.Wy,
+			Z: v.X*wm.Xz + v.Y*wm.Yz + v.Z*wm.Zz + wm.Wz,
+		}
+	}
+	poly, clip := make([]lin.V3, 0, 9), make([]lin.V3, 0, 9)
+	for i := 0; i+2 < len(msh.faces); i += 3 {
+		a := world(&msh.verts[msh.faces[i]])
+		b := world(&msh.verts[msh.faces[i+1]])
+		c := world(&msh.verts[msh.faces[i+2]])
+		e1, e2 := lin.NewV3().Sub(&b, &a), lin.NewV3().Sub(&c, &a)
+		n := e1.Cross(e1, e2)
+		if lin.AeqZ(n.Len()) || n.Unit().Dot(&d.normal) < decalFacing {
+			continue // degenerate or facing away from the decal.
+		}
+
+		// clip the triangle in decal box space where the box is -0.5 to 0.5.
+		poly = append(poly[:0], d.local(&a), d.local(&b), d.local(&c))
+		for axis := 0; axis < 3 && len(poly) > 0; axis++ {
+			clip = clipPolygon(poly, clip[:0], axis, -0.5)
+			poly = clipPolygon(clip, poly[:0], axis, 0.5)
+		}
+		if len(poly) < 3 || len(d.verts)+len(poly) > maxDecalVerts {
+			continue
+		}
+
+		// keep the clipped polygon as a triangle fan.
+		first := uint16(len(d.verts))
+		for j := range poly {
+			d.verts = append(d.verts, d.world(&poly[j]))
+			d.norm = append(d.norm, *n)
+			d.uvs = append(d.uvs, float32(poly[j].X+0.5), float32(0.5-poly[j].Y))
+			if j >= 2 {
+				d.tris = append(d.tris, first, first+uint16(j-1), first+uint16(j))
+			}
+		}
+	}
+}
+
+// local returns the world location w in decal box space where
+// x is right, y is up, and z is along the normal.
+func (d *decal) local(w *lin.V3) lin.V3 {
+	v := lin.NewV3().Sub(w, &d.at)
+	s := 1 / d.size
+	return lin.V3{X: v.Dot(&d.right) * s, Y: v.Dot(&d.up) * s, Z: v.Dot(&d.normal) * s}
+}
+
+// world returns the world location of decal box location v lifted
+// off the surface to avoid fighting over the depth buffer.
+func (d *decal) world(v *lin.V3) lin.V3 {
+	x, y, z := v.X*d.size, v.Y*d.size, (v.Z+decalLift)*d.size
+	return lin.V3{
+		X: d.at.X + d.right.X*x + d.up.X*y + d.normal.X*z,
+		Y: d.at.Y + d.right.Y*x + d.up.Y*y + d.normal.Y*z,
+		Z: d.at.Z + d.right.Z*x + d.up.Z*y + d.normal.Z*z,
+	}
+}
+
+// clipPolygon appends the part of polygon in to out that is inside the
+// plane on the given axis. The inside is above a negative limit and
+// below a positive limit. Based on Sutherland–Hodgman polygon clipping.
+func clipPolygon(in, out []lin.V3, axis int, limit float64) []lin.V3 {
+	dist := func(v *lin.V3) float64 {
+		c := [3]float64{v.X, v.Y, v.Z}[axis]
+		if limit < 0 {
+			return c - limit
+		}
+		return limit - c
+	}
+	for i := range in {
+		a, b := &in[i], &in[(i+1)%len(in)]
+		da, db := dist(a), dist(b)
+		if da >= 0 {
+			out = append(out, *a)
+		}
+		if (da >= 0) != (db >= 0) {
+			t := da / (da - db)
+			out = append(out, *lin.NewV3().Lerp(a, b, t))
+		}
+	}
+	return out
+}
+
+// decalPool holds the decals drawn by one model.
+type decalPool struct {
+	eid, scene eID // pool model and its scene.
+	count      int // maximum decals.
+	lifetime   time.Duration
+	list       []*decal // oldest first.
+	dirty      bool     // true if the mesh needs to be regenerated.
+}
+
+// add keeps the decal, removing the oldest decals when the pool is full.
+func (dp *decalPool) add(d *decal) {
+	if len(d.tris) == 0 {
+		return // nothing was covered.
+	}
+	verts := len(d.verts)
+	for _, old := range dp.list {
+		verts += len(old.verts)
+	}
+	drop := max(len(dp.list)+1-dp.count, 0)
+	for drop < len(dp.list) && verts > maxDecalVerts {
+		verts -= len(dp.list[drop].verts)
+		drop++
+	}
+	dp.list = append(dp.list[:0], dp.list[min(drop, len(dp.list)):]...)
+	dp.list = append(dp.list, d)
+	dp.dirty = true
+}
+
+// meshData returns the decals mesh data relative to the pool world
+// transform p. Returns nil if there are no decals.
+func (dp *decalPool) meshData(p *pov) load.MeshData {
+	if len(dp.list) == 0 {
+		return nil
+	}
+	inv := lin.NewQ().Inv(p.tw.Rot)
+	unscale := &lin.V3{X: clothUnscale(p.sw.X), Y: clothUnscale(p.sw.Y), Z: clothUnscale(p.sw.Z)}
+	vx, nm, uv, ix := []float32{}, []float32{}, []float32{}, []uint16{}
+	for _, d := range dp.list {
+		base := uint16(len(vx) / 3)
+		for i := range d.verts {
+			at := lin.NewV3().Sub(&d.verts[i], p.tw.Loc)
+			at.MultQ(at, inv).Mult(at, unscale)
+			n := lin.NewV3().MultQ(&d.norm[i], inv)
+			vx = append(vx, float32(at.X), float32(at.Y), float32(at.Z))
+			nm = append(nm, float32(n.X), float32(n.Y), float32(n.Z))
+		}
+		uv = append(uv, d.uvs...)
+		for _, t := range d.tris {
+			ix = append(ix, base+t)
+		}
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(vx, 3)  // vec3
+	md[load.Normals] = load.F32Buffer(nm, 3)   // vec3
+	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(ix)
+	return md
+}
+
+// =============================================================================
+// decals component manager.
+
+// decals tracks the decal pools.
+type decals struct {
+	list map[eID]*decalPool
+}
+
+// newDecals creates the decal component manager.
+// There is only expected to be once instance created by the engine.
+func newDecals() *decals {
+	return &decals{list: map[eID]*decalPool{}}
+}
+
+// create a decal pool for the given model entity.
+func (ds *decals) create(eid, scene eID, count int, lifetime time.Duration) {
+	ds.list[eid] = &decalPool{eid: eid, scene: scene, count: count, lifetime: lifetime}
+}
+
+// get the decal pool for the given entity.
+func (ds *decals) get(eid eID) *decalPool { return ds.list[eid] }
+
+// dispose removes the decal pool. The pool mesh
+// is released with the pool model.
+func (ds *decals) dispose(eid eID) { delete(ds.list, eid) }
+
+// update ages the decals, removing the expired decals, and regenerates
+// the meshes of the pools that have changed. Called once each frame.
+func (ds *decals) update(app *application, rc render.Loader, delta time.Duration) {
+	for eid, dp := range ds.list {
+		expired := 0
+		for _, d := range dp.list {
+			if d.age += delta; dp.lifetime > 0 && d.age >= dp.lifetime {
+				expired++ // decals are oldest first.
+			}
+		}
+		if expired > 0 {
+			dp.list, dp.dirty = append(dp.list[:0], dp.list[expired:]...), true
+		}
+		if dp.dirty {
+			ds.draw(app, rc, eid, dp)
+		}
+	}
+}
+
+// draw replaces the pool mesh with the current decals. The previous
+// mesh is released once it is no longer drawn.
+func (ds *decals) draw(app *application, rc render.Loader, eid eID, dp *decalPool) {
+	m, p := app.models.get(eid), app.povs.get(eid)
+	if m == nil || p == nil {
+		return
+	}
+	dp.dirty = false
+	if m.mesh != nil {
+		app.ld.release(m.mesh) // generated mesh.
+		m.mesh = nil
+	}
+	md := dp.meshData(p)
+	(&Entity{app: app, eid: eid}).Cull(md == nil)
+	if md == nil {
+		return // no decals.
+	}
+	mid, err := rc.LoadMesh(md)
+	if err != nil {
+		slog.Error("decals LoadMesh", "error", err)
+		return
+	}
+	m.mesh = newMesh("decals")
+	m.mesh.mid = mid
+	m.mesh.generated = true
+}
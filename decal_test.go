@@ -0,0 +1,61 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Decal
+func TestDecal(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	scene.AddModel("msh:cube").SetAt(0, 0, -10)
+	pool := scene.AddDecals(2, time.Second, "shd:icon", "tex:color:test")
+	app.povs.setWorldMatrix(app.work, 0)
+
+	// go test -run Decal/project
+	t.Run("project", func(t *testing.T) {
+		pool.AddDecal(0.25, 0, -9.5, 0, 0, 2, 1, 0) // right edge of the front face.
+		d := app.decals.get(pool.eid).list[0]
+		if pool.DecalCount() != 1 || len(d.tris) == 0 {
+			t.Fatalf("expected decal triangles got %d", len(d.tris))
+		}
+		for i, v := range d.verts {
+			u := float64(d.uvs[i*2])
+			if v.X < -0.25-lin.Epsilon || v.X > 0.5+lin.Epsilon || !lin.Aeq(v.Z, -9.498) || u < 0 || u > 0.75+lin.Epsilon {
+				t.Errorf("expected clipped decal got %v %f", v, u)
+			}
+		}
+		if app.decals.update(app, rc, time.Millisecond); app.models.get(pool.eid).mesh == nil {
+			t.Errorf("expected decal mesh")
+		}
+	})
+
+	// go test -run Decal/miss
+	t.Run("miss", func(t *testing.T) {
+		pool.AddDecal(0, 5, -10, 0, 1, 0, 1, 0)   // nothing nearby.
+		pool.AddDecal(0, 0, -10.5, 0, 0, 1, 1, 0) // back face is facing away.
+		if pool.DecalCount() != 1 {
+			t.Errorf("expected no decal got %d", pool.DecalCount())
+		}
+	})
+
+	// go test -run Decal/pool
+	t.Run("pool", func(t *testing.T) {
+		pool.AddDecal(0, 0.5, -10, 0, 1, 0, 0.5, 45)  // top face.
+		pool.AddDecal(-0.5, 0, -10, -1, 0, 0, 0.5, 0) // left face.
+		if dp := app.decals.get(pool.eid); pool.DecalCount() != 2 || dp.list[1].normal.X != -1 {
+			t.Errorf("expected oldest decal replaced got %d", pool.DecalCount())
+		}
+		app.decals.update(app, rc, time.Second)
+		if pool.DecalCount() != 0 || !app.povs.getNode(pool.eid).cull {
+			t.Errorf("expected expired decals got %d", pool.DecalCount())
+		}
+	})
+}
@@ -3,13 +3,27 @@ package device
 // device.go wraps the platform specific functionality.
 
 import (
+	"runtime"
 	"time"
+	"unicode"
+	"unicode/utf16"
 )
 
 // New creates the platform for the current host.
 func New(windowed bool, title string, x int32, y int32, w int32, h int32) *Device {
 	// newPlatform is implemented by each platform.
 	// FUTURE: provide platforms for linux, macos, etc.
+	// A linux platform should pick wayland or X11 on startup, eg: wayland
+	// when WAYLAND_DISPLAY is set unless overridden by an environment
+	// variable. Wayland needs xdg-shell windows, libinput or wl_seat
+	// events, and a VK_KHR_wayland_surface in place of GetRenderSurfaceInfo.
+	// An android platform needs a native activity, touch input, and pause
+	// and resume events that release and recreate the render surface.
+	// The renderer is vulkan only, so android would use a
+	// VK_KHR_android_surface rather than EGL and GLES bindings.
+	// An ios platform needs UIKit touch and app lifecycle events, and a
+	// CAMetalLayer with VK_EXT_metal_surface through a vulkan portability
+	// layer like MoltenVK. CAEAGLLayer is GLES and not used by the renderer.
 	d := &Device{platform: newPlatform()}
 	d.platform.init(windowed, title, x, y, w, h)
 	return d
@@ -31,6 +45,36 @@ func (d *Device) SurfaceSize() (w, h uint32) {
 	return d.platform.surfaceSize()
 }
 
+// EnableHighDPI opts into rendering at the full resolution of high-DPI
+// displays. Otherwise the OS renders at 1x and stretches the image.
+// The initial window size is scaled by the display scale so that the
+// window looks the same size. Called before CreateDisplay.
+func (d *Device) EnableHighDPI() {
+	d.platform.enableHighDPI()
+}
+
+// DisplayScale returns the ratio of surface pixels to logical window
+// units for the monitor showing the window, eg: 1.5 for 150% scaling.
+// Always 1 unless EnableHighDPI was called.
+func (d *Device) DisplayScale() float32 {
+	return d.platform.displayScale()
+}
+
+// LogicalSize returns the size of the surface in logical window units.
+// This is the SurfaceSize divided by the DisplayScale.
+func (d *Device) LogicalSize() (w, h uint32) {
+	w, h = d.platform.surfaceSize()
+	return logical(w, d.platform.displayScale()), logical(h, d.platform.displayScale())
+}
+
+// logical converts a pixel size to logical window units.
+func logical(pixels uint32, scale float32) uint32 {
+	if scale <= 0 {
+		return pixels
+	}
+	return uint32(float32(pixels)/scale + 0.5)
+}
+
 // Returns the upper corner location of the surface in pixels.
 // Consistent with the values provided in device.New().
 func (d *Device) SurfaceLocation() (x, y int32) {
@@ -59,12 +103,131 @@ func (d *Device) SetResizeHandler(callback func()) {
 	d.platform.setResizeHandler(callback)
 }
 
+// SetDisplayHandler registers a callback that is called when the
+// display changes. This happens when the display resolution or refresh
+// rate changes, when monitors are plugged in or unplugged, and when
+// the window is moved to a different monitor.
+func (d *Device) SetDisplayHandler(callback func()) {
+	d.platform.setDisplayHandler(callback)
+}
+
+// RefreshRate returns the refresh rate, in hertz, of the monitor
+// showing the window. Returns 0 if the refresh rate is not known.
+func (d *Device) RefreshRate() int {
+	return d.platform.refreshRate()
+}
+
+// Monitors returns the number of monitors attached to the desktop.
+func (d *Device) Monitors() int {
+	return d.platform.monitors()
+}
+
+// Handles returns the number of operating system handles
+// used by the process. Returns 0 if the count is not known.
+func (d *Device) Handles() int {
+	return d.platform.handles()
+}
+
+// SetWindow moves and resizes the bordered window where x,y is
+// the upper left corner and w,h is the surface size in pixels.
+// Ignored when the window is fullscreen.
+func (d *Device) SetWindow(x, y, w, h int32) {
+	d.platform.setWindow(x, y, w, h)
+}
+
 // ToggleFullscreen toggles between windowed with border
 // and windowed fullscreen with no border.
 func (d *Device) ToggleFullscreen() {
 	d.platform.toggleFullscreen()
 }
 
+// SetTextInput turns typed text on or off, see Input.Text. Text input
+// also enables the input method editor (IME) used to type languages
+// like Chinese or Japanese. Off by default so that the IME does not
+// capture game keys. Expected to be on only while a text field has focus.
+func (d *Device) SetTextInput(on bool) {
+	d.platform.setTextInput(on)
+}
+
+// Clipboard returns the text on the system clipboard.
+// Returns "" if the clipboard does not contain text.
+func (d *Device) Clipboard() string {
+	return d.platform.clipboard()
+}
+
+// SetClipboard puts the given text on the system clipboard.
+func (d *Device) SetClipboard(text string) error {
+	return d.platform.setClipboard(text)
+}
+
+// AcceptDrops allows files to be dragged from the desktop and dropped
+// on the main window, see Input.Dropped. Off by default.
+func (d *Device) AcceptDrops(accept bool) {
+	d.platform.acceptDrops(accept)
+}
+
+// CursorMode controls the mouse cursor when it is over the main window.
+type CursorMode int
+
+const (
+	CursorNormal   CursorMode = iota // visible and free to leave the window.
+	CursorHidden                     // hidden while over the window.
+	CursorCaptured                   // hidden and held in the window, eg: mouse look.
+)
+
+// SetCursorMode shows, hides, or captures the cursor. A captured cursor
+// is hidden and kept at the window center so that only the relative
+// motion, Input.Dx and Input.Dy, is useful. The cursor is released while
+// the window does not have focus.
+func (d *Device) SetCursorMode(mode CursorMode) {
+	d.platform.setCursorMode(mode)
+}
+
+// WarpCursor moves the cursor to the given location
+// in pixels relative to the top left of the main window.
+func (d *Device) WarpCursor(x, y int32) {
+	d.platform.warpCursor(x, y)
+}
+
+// ConfineCursor keeps the cursor inside the main window while
+// the window has focus. The cursor remains visible.
+func (d *Device) ConfineCursor(confine bool) {
+	d.platform.confineCursor(confine)
+}
+
+// OpenWindow creates an additional bordered window where x,y is the
+// upper left corner and w,h is the surface size in pixels, eg: a tool
+// view beside the main viewport. Returns the window ID used to render
+// to the window. The main window is ID 0. User input from all windows
+// is combined into the device input. Called after CreateDisplay.
+func (d *Device) OpenWindow(title string, x, y, w, h int32) (win uint32, err error) {
+	return d.platform.openWindow(title, x, y, w, h)
+}
+
+// CloseWindow closes a window opened with OpenWindow.
+func (d *Device) CloseWindow(win uint32) {
+	d.platform.closeWindow(win)
+}
+
+// WindowOpen returns false once the user has closed the given window,
+// or if the window does not exist. A window closed by the user is
+// hidden until it is closed with CloseWindow.
+func (d *Device) WindowOpen(win uint32) bool {
+	return d.platform.windowOpen(win)
+}
+
+// WindowSize returns the surface size in pixels of the given window.
+// Window 0 is the main window, see SurfaceSize.
+func (d *Device) WindowSize(win uint32) (w, h uint32) {
+	return d.platform.windowSize(win)
+}
+
+// WindowCursor returns the mouse location relative
+// to the upper left corner of the given window.
+func (d *Device) WindowCursor(win uint32) (mx, my int32) {
+	return d.platform.windowCursor(win)
+}
+
 // platformAPI is the interface that each platform must implement.
 // One platform will be active on startup.
 type platformAPI interface {
@@ -73,14 +236,36 @@ type platformAPI interface {
 	init(windowed bool, title string, x, y, w, h int32)
 
 	// exposed as Device public methods.
-	createDisplay() error             // see CreateDisplay
-	surfaceSize() (w, h uint32)       // see SurfaceSize
-	surfaceLocation() (x, y int32)    // see SurfaceLocation
-	getInput() *Input                 // see GetInput
-	dispose()                         // see Dispose
-	isRunning() bool                  // see IsRunning
-	setResizeHandler(callback func()) // see SetResizeHandler
-	toggleFullscreen()                // see ToggleFullscreen
+	createDisplay() error              // see CreateDisplay
+	surfaceSize() (w, h uint32)        // see SurfaceSize
+	enableHighDPI()                    // see EnableHighDPI
+	displayScale() float32             // see DisplayScale
+	surfaceLocation() (x, y int32)     // see SurfaceLocation
+	getInput() *Input                  // see GetInput
+	dispose()                          // see Dispose
+	isRunning() bool                   // see IsRunning
+	setResizeHandler(callback func())  // see SetResizeHandler
+	setDisplayHandler(callback func()) // see SetDisplayHandler
+	refreshRate() int                  // see RefreshRate
+	monitors() int                     // see Monitors
+	handles() int                      // see Handles
+	setWindow(x, y, w, h int32)        // see SetWindow
+	toggleFullscreen()                 // see ToggleFullscreen
+	setCursorMode(mode CursorMode)     // see SetCursorMode
+	warpCursor(x, y int32)             // see WarpCursor
+	confineCursor(confine bool)        // see ConfineCursor
+	rumble(pad int, low, high float64) // see Rumble
+	setTextInput(on bool)              // see SetTextInput
+	clipboard() string                 // see Clipboard
+	setClipboard(text string) error    // see SetClipboard
+	acceptDrops(accept bool)           // see AcceptDrops
+
+	// additional windows.
+	openWindow(title string, x, y, w, h int32) (uint32, error) // see OpenWindow
+	closeWindow(win uint32)                                    // see CloseWindow
+	windowOpen(win uint32) bool                                // see WindowOpen
+	windowSize(win uint32) (w, h uint32)                       // see WindowSize
+	windowCursor(win uint32) (mx, my int32)                    // see WindowCursor
 }
 
 // =============================================================================
@@ -89,9 +274,11 @@ type platformAPI interface {
 // Input data is shared with the app and the app should treat the data
 // as read only.
 type Input struct {
-	Mx, My int32 // Mouse location relative to top left.
-	Scroll int   // Scroll amount: positive, negative, or Zero if no scrolling.
-	Focus  bool  // True if window has focus.
+	Mx, My int32   // Mouse location in surface pixels relative to top left.
+	Dx, Dy int32   // Raw mouse motion since last request, in mouse units.
+	Scale  float32 // Surface pixels per logical unit, see DisplayScale.
+	Scroll int     // Scroll amount: positive, negative, or Zero if no scrolling.
+	Focus  bool    // True if window has focus.
 
 	// Pressed are keys that were pressed since last request.
 	Pressed map[int32]bool //
@@ -108,16 +295,45 @@ type Input struct {
 	// Keys are also released when the window loses focus.
 	Released map[int32]time.Duration // total time down.
 
+	// Pads are the gamepads, indexed by controller slot.
+	Pads [MaxPads]Pad
+
+	// Text is the text typed since last request using the current
+	// keyboard layout. Always empty unless text input is on, see
+	// Device.SetTextInput. Editing keys, like KDel, are not included.
+	Text string
+
+	// Composing is the text being composed with an input method editor.
+	// It is not part of Text until the composition is finished.
+	Composing string
+	surrogate uint16 // first half of a UTF-16 surrogate pair.
+
+	// Dropped are the full paths of files dropped on the window since
+	// the last request. The mouse location, Mx, My, is where they were
+	// dropped. Always empty unless drops are on, see Device.AcceptDrops.
+	Dropped []string
+
+	// Touches are the fingers on a touch screen, including those lifted
+	// since the last request. Gesture is the gesture recognized from the
+	// touches, if any. Touches may also be reported as mouse input.
+	Touches []Touch
+	Gesture Gesture
+
 	// internal signal for when the user has closed the window.
 	shutdown bool // true when user closes window.
 }
 
 // reset prepares input data for a refresh.
 func (in *Input) reset() {
-	in.Mx = 0       // to be refreshed with current mouse location.
-	in.My = 0       // ""
-	in.Scroll = 0   // no scrolling happening.
-	in.Focus = true // window has focus.
+	in.Mx = 0        // to be refreshed with current mouse location.
+	in.My = 0        // ""
+	in.Dx = 0        // no mouse motion.
+	in.Dy = 0        // ""
+	in.Scroll = 0    // no scrolling happening.
+	in.Focus = true  // window has focus.
+	in.Text = ""     // no typed text.
+	in.Dropped = nil // no dropped files.
+	in.resetTouches()
 
 	// clear the Pressed and Released as they are a one time notification.
 	// The Down keys are kept until they are released.
@@ -137,6 +353,23 @@ func (in *Input) keyReleased(k int32) {
 	}
 }
 
+// typeChar adds a typed UTF-16 character to the text. Characters
+// outside the basic plane arrive as two halves of a surrogate pair.
+func (in *Input) typeChar(c uint16) {
+	r := rune(c)
+	switch {
+	case utf16.IsSurrogate(r) && r < 0xDC00:
+		in.surrogate = c // wait for the second half.
+		return
+	case utf16.IsSurrogate(r):
+		r = utf16.DecodeRune(rune(in.surrogate), r)
+	}
+	in.surrogate = 0
+	if r != unicode.ReplacementChar && !unicode.IsControl(r) {
+		in.Text += string(r) // editing keys are reported as key presses.
+	}
+}
+
 // keyPressed records keys that have just been pressed in the last poll.
 // Ignore repeat pressed events for keys that are already down.
 func (in *Input) keyPressed(k int32) {
@@ -150,6 +383,7 @@ func (in *Input) keyPressed(k int32) {
 // release events will be missed.
 func (in *Input) loseFocus() {
 	input.reset()
+	in.releaseTouches() // touch up events will be missed.
 	for k, v := range in.Down {
 		in.Released[k] = time.Since(v) // inform app
 		delete(in.Down, k)             // key is no longer down.
@@ -165,3 +399,18 @@ func (in *Input) allMouseButtonsReleased() bool {
 	_, rightMouseDown := in.Down[KMR]
 	return !leftMouseDown && !middleMouseDown && !rightMouseDown
 }
+
+// SleepUntil pauses the calling goroutine until the given deadline.
+// Most of the wait is slept and the last part is spent yielding the
+// processor since OS timers can wake up a millisecond or more late.
+func SleepUntil(deadline time.Time) {
+	if wait := time.Until(deadline) - sleepMargin; wait > 0 {
+		time.Sleep(wait)
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+// sleepMargin is the part of SleepUntil that is not slept.
+const sleepMargin = 2 * time.Millisecond
@@ -0,0 +1,102 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package device
+
+// gamepad.go describes the state of connected gamepads. Gamepad buttons
+// are reported once per input poll as a bit mask along with the thumb
+// stick and trigger positions. Gamepads can be plugged in and unplugged
+// while the app is running.
+
+// MaxPads is the number of gamepads that are polled.
+const MaxPads = 4
+
+// Gamepad buttons. Buttons are bit flags that can
+// be combined, eg: PadA|PadB.
+const (
+	PadUp     = 0x0001 // directional pad up.
+	PadDown   = 0x0002 // directional pad down.
+	PadLeft   = 0x0004 // directional pad left.
+	PadRight  = 0x0008 // directional pad right.
+	PadStart  = 0x0010 // start or menu button.
+	PadBack   = 0x0020 // back or view button.
+	PadLStick = 0x0040 // left thumb stick pressed.
+	PadRStick = 0x0080 // right thumb stick pressed.
+	PadLB     = 0x0100 // left shoulder bumper.
+	PadRB     = 0x0200 // right shoulder bumper.
+	PadA      = 0x1000 // bottom face button.
+	PadB      = 0x2000 // right face button.
+	PadX      = 0x4000 // left face button.
+	PadY      = 0x8000 // top face button.
+)
+
+// Pad is the state of one gamepad.
+type Pad struct {
+	Connected bool   // true if the gamepad is plugged in.
+	Plugged   bool   // true for the poll where the gamepad was plugged in.
+	Unplugged bool   // true for the poll where the gamepad was unplugged.
+	Buttons   uint16 // buttons that are down.
+	Last      uint16 // buttons that were down the poll before.
+
+	// Stick positions are -1 to 1 where positive is right and up.
+	// Small movements around the center are reported as 0.
+	LX, LY float64 // left thumb stick.
+	RX, RY float64 // right thumb stick.
+
+	// Trigger positions are 0 when released to 1 when fully pulled.
+	LT, RT float64 // left and right triggers.
+}
+
+// Down returns true if any of the given buttons are down.
+func (p *Pad) Down(buttons uint16) bool { return p.Buttons&buttons != 0 }
+
+// Pressed returns true if any of the given buttons
+// were pressed since the last poll.
+func (p *Pad) Pressed(buttons uint16) bool { return p.Buttons&^p.Last&buttons != 0 }
+
+// Released returns true if any of the given buttons
+// were released since the last poll.
+func (p *Pad) Released(buttons uint16) bool { return p.Last&^p.Buttons&buttons != 0 }
+
+// stickDeadZone is the fraction of the stick range
+// around the center that is reported as 0.
+var stickDeadZone = 0.24
+
+// SetPadDeadZone sets the fraction, 0 to 0.9, of the thumb stick
+// range around the center that is reported as 0. Worn sticks may
+// need a larger dead zone to stop drifting. The default is 0.24.
+func (d *Device) SetPadDeadZone(zone float64) {
+	stickDeadZone = max(0, min(zone, 0.9))
+}
+
+// Rumble sets the gamepad vibration where low and high are the
+// speeds, 0 to 1, of the low and high frequency motors. Vibration
+// continues until it is set to 0. Ignored for gamepads without
+// vibration motors or that are not connected.
+func (d *Device) Rumble(pad int, low, high float64) {
+	if pad >= 0 && pad < MaxPads {
+		d.platform.rumble(pad, max(0, min(low, 1)), max(0, min(high, 1)))
+	}
+}
+
+// stick converts a raw thumb stick axis to -1 to 1,
+// removing the dead zone and rescaling the remaining range.
+func stick(raw int16) float64 {
+	v := float64(raw) / 32767
+	switch {
+	case v > stickDeadZone:
+		return min((v-stickDeadZone)/(1-stickDeadZone), 1)
+	case v < -stickDeadZone:
+		return max((v+stickDeadZone)/(1-stickDeadZone), -1)
+	}
+	return 0
+}
+
+// trigger converts a raw trigger value to 0 to 1,
+// ignoring small amounts of trigger pull.
+func trigger(raw uint8) float64 {
+	const threshold = 30 // XINPUT_GAMEPAD_TRIGGER_THRESHOLD
+	if raw <= threshold {
+		return 0
+	}
+	return float64(raw-threshold) / (255 - threshold)
+}
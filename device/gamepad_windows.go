@@ -0,0 +1,113 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package device
+
+// gamepad_windows.go polls gamepads using the XInput API.
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// xinputState matches the XINPUT_STATE structure.
+type xinputState struct {
+	packet  uint32
+	buttons uint16
+	lt, rt  uint8
+	lx, ly  int16
+	rx, ry  int16
+}
+
+// xinputVibration matches the XINPUT_VIBRATION structure.
+type xinputVibration struct {
+	low, high uint16 // left and right motor speeds.
+}
+
+// xinput is loaded on first use. Newer systems have xinput1_4 while
+// xinput9_1_0 is available on all systems that support XInput.
+var xinput struct {
+	getState *windows.LazyProc // XInputGetState, nil if not available.
+	setState *windows.LazyProc // XInputSetState, nil if not available.
+	loaded   bool
+
+	// pads that are vibrating, stopped when the device is disposed.
+	rumbling [MaxPads]bool
+
+	// polling a disconnected pad is slow so they are
+	// only checked for new connections every so often.
+	retry [MaxPads]time.Time
+}
+
+// padRetry is how often disconnected pads are checked.
+const padRetry = time.Second
+
+// loadXInput finds the XInputGetState and XInputSetState functions.
+func loadXInput() {
+	xinput.loaded = true
+	for _, dll := range []string{"xinput1_4.dll", "xinput9_1_0.dll"} {
+		lib := windows.NewLazySystemDLL(dll)
+		if get, set := lib.NewProc("XInputGetState"), lib.NewProc("XInputSetState"); get.Find() == nil && set.Find() == nil {
+			xinput.getState, xinput.setState = get, set
+			return
+		}
+	}
+}
+
+// rumble implements Device.
+func (wd *windowsDevice) rumble(pad int, low, high float64) { setRumble(pad, low, high) }
+
+// setRumble sets the vibration motor speeds for the given pad.
+func setRumble(pad int, low, high float64) {
+	if !xinput.loaded {
+		loadXInput()
+	}
+	if xinput.setState == nil {
+		return // no XInput on this system.
+	}
+	vib := xinputVibration{low: uint16(low * 65535), high: uint16(high * 65535)}
+	ret, _, _ := xinput.setState.Call(uintptr(pad), uintptr(unsafe.Pointer(&vib)))
+	xinput.rumbling[pad] = ret == 0 && (vib.low > 0 || vib.high > 0)
+}
+
+// stopRumble stops any vibrating pads.
+func stopRumble() {
+	for pad, on := range xinput.rumbling {
+		if on {
+			setRumble(pad, 0, 0)
+		}
+	}
+}
+
+// pollPads updates the gamepad state in the given input.
+func pollPads(in *Input, now time.Time) {
+	if !xinput.loaded {
+		loadXInput()
+	}
+	if xinput.getState == nil {
+		return // no XInput on this system.
+	}
+	for i := range in.Pads {
+		pad := &in.Pads[i]
+		pad.Last = pad.Buttons
+		pad.Plugged, pad.Unplugged = false, false
+		if !pad.Connected && now.Before(xinput.retry[i]) {
+			continue
+		}
+		state := xinputState{}
+		ret, _, _ := xinput.getState.Call(uintptr(i), uintptr(unsafe.Pointer(&state)))
+		if ret != 0 { // ERROR_DEVICE_NOT_CONNECTED
+			*pad = Pad{Unplugged: pad.Connected}
+			xinput.retry[i] = now.Add(padRetry)
+			xinput.rumbling[i] = false
+			continue
+		}
+		pad.Plugged = !pad.Connected
+		pad.Connected = true
+		pad.Buttons = state.buttons
+		pad.LX, pad.LY = stick(state.lx), stick(state.ly)
+		pad.RX, pad.RY = stick(state.rx), stick(state.ry)
+		pad.LT, pad.RT = trigger(state.lt), trigger(state.rt)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"syscall"
 	"time"
+	"unicode/utf16"
 	"unsafe"
 
 	"github.com/gazed/vu/internal/device/win"
@@ -23,6 +24,7 @@ type windowsDevice struct {
 	hwnd      win.HWND      // window handle
 	windowed  bool          // window bordered vs full screen
 	title     string        // window with border title
+	highDPI   bool          // true to render at full resolution.
 }
 
 // newPlatform gets the platform specific window and input handler.
@@ -45,16 +47,30 @@ func (wd *windowsDevice) init(windowed bool, title string, x int32, y int32, w i
 	display.h = h
 	display.fw = win.GetSystemMetrics(win.SM_CXSCREEN)
 	display.fh = win.GetSystemMetrics(win.SM_CYSCREEN)
+	display.dpi = win.USER_DEFAULT_SCREEN_DPI
 }
 
 // GetRenderSurfaceInfo exposes the windows API specific information
 // needed by the render package to create a rendering surface.
 // Called by the platform specific code in the Render package.
 func GetRenderSurfaceInfo(d *Device) (hinst windows.Handle, hwnd windows.HWND, err error) {
-	if wd, ok := d.platform.(*windowsDevice); ok {
+	return GetWindowSurfaceInfo(d, 0)
+}
+
+// GetWindowSurfaceInfo is GetRenderSurfaceInfo for the given window
+// where window 0 is the main window, see Device.OpenWindow.
+func GetWindowSurfaceInfo(d *Device, id uint32) (hinst windows.Handle, hwnd windows.HWND, err error) {
+	wd, ok := d.platform.(*windowsDevice)
+	if !ok {
+		return 0, 0, fmt.Errorf("GetWindowSurfaceInfo: invalid device")
+	}
+	if id == 0 {
 		return windows.Handle(wd.hinstance), windows.HWND(wd.hwnd), nil
 	}
-	return 0, 0, fmt.Errorf("GetRenderSurfaceInfo: invalid device")
+	if aw := findWindow(id); aw != nil {
+		return windows.Handle(wd.hinstance), windows.HWND(aw.hwnd), nil
+	}
+	return 0, 0, fmt.Errorf("GetWindowSurfaceInfo: unknown window %d", id)
 }
 
 // User input data is refreshed each call to PollInput
@@ -78,6 +94,27 @@ var display struct {
 
 	// used to ignore extra WM_SIZE message when going fullscreen.
 	toggledFull bool // set when calling toggle to fullscreen.
+
+	// used to notice when the window moves to a different monitor.
+	monitor win.HMONITOR // monitor showing most of the window.
+
+	// dots per inch of the monitor showing the window.
+	dpi uint32 // USER_DEFAULT_SCREEN_DPI unless high DPI is enabled.
+}
+
+// cursor tracks how the mouse cursor is shown and constrained.
+var cursor struct {
+	mode    CursorMode  // see SetCursorMode.
+	confine bool        // true to keep the cursor in the window.
+	clipped bool        // true while the cursor is confined.
+	arrow   win.HCURSOR // default cursor over the window.
+}
+
+// text tracks typed text and input method editor (IME) compositions.
+var text struct {
+	on        bool     // true to translate key presses to text.
+	himc      win.HIMC // IME context, detached while text input is off.
+	composing string   // IME text being composed.
 }
 
 // resizeHandler processes resize events immediately since the windows loop
@@ -86,9 +123,34 @@ var resizeHandler func() = nil
 
 func (wd *windowsDevice) setResizeHandler(callback func()) { resizeHandler = callback }
 
+// displayHandler processes display changes like refresh rate changes
+// and monitors being plugged in or unplugged.
+var displayHandler func() = nil
+
+func (wd *windowsDevice) setDisplayHandler(callback func()) { displayHandler = callback }
+
+// enableHighDPI implements Device.
+func (wd *windowsDevice) enableHighDPI() { wd.highDPI = true }
+
+// displayScale implements Device.
+func (wd *windowsDevice) displayScale() float32 {
+	return float32(display.dpi) / win.USER_DEFAULT_SCREEN_DPI
+}
+
 // Device interface: createDisplay
 func (wd *windowsDevice) createDisplay() error {
 
+	// DPI awareness is set before any windows are created.
+	// Otherwise windows scales the window contents and reports
+	// the window size and mouse locations in logical units.
+	if wd.highDPI {
+		// fails if not supported or already set by the application
+		// manifest. The window DPI is checked after the window is created.
+		win.SetProcessDpiAwarenessContext(win.DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2)
+		display.fw = win.GetSystemMetrics(win.SM_CXSCREEN) // now in pixels.
+		display.fh = win.GetSystemMetrics(win.SM_CYSCREEN) // ""
+	}
+
 	// get the application instance.
 	wd.hinstance = win.GetModuleHandle(nil)
 	if wd.hinstance == 0 {
@@ -110,6 +172,7 @@ func (wd *windowsDevice) createDisplay() error {
 	wc.HInstance = wd.hinstance
 	wc.HIcon = win.LoadIcon(0, (*uint16)(appIcon))
 	wc.HCursor = win.LoadCursor(0, (*uint16)(arrow))
+	cursor.arrow = wc.HCursor
 	wc.HbrBackground = (win.HBRUSH)(win.COLOR_WINDOW + 1)
 	wc.LpszMenuName = nil
 	wc.LpszClassName = classname
@@ -164,12 +227,55 @@ func (wd *windowsDevice) createDisplay() error {
 	}
 	win.ShowWindow(wd.hwnd, int32(show))
 	win.SetForegroundWindow(wd.hwnd)
+	display.monitor = win.MonitorFromWindow(wd.hwnd, win.MONITOR_DEFAULTTONEAREST)
+
+	// turn off the IME until text input is needed.
+	text.himc = win.ImmAssociateContext(wd.hwnd, 0)
+
+	// ask for raw mouse motion, sent as WM_INPUT to the focus window.
+	// Input.Dx, Input.Dy remain 0 if raw input is not available.
+	rid := win.RAWINPUTDEVICE{UsUsagePage: 0x01, UsUsage: 0x02} // generic mouse.
+	win.RegisterRawInputDevices(&rid, 1, uint32(unsafe.Sizeof(rid)))
+
+	// scale the initial window size to match the monitor scaling.
+	// DPI unaware windows report the default DPI and are not scaled.
+	if wd.highDPI {
+		if dpi := win.GetDpiForWindow(wd.hwnd); dpi > 0 && dpi != display.dpi {
+			w := display.w * int32(dpi) / win.USER_DEFAULT_SCREEN_DPI
+			h := display.h * int32(dpi) / win.USER_DEFAULT_SCREEN_DPI
+			display.dpi = dpi
+			wd.setWindow(display.x, display.y, w, h)
+		}
+	}
 	return nil
 }
 
 // Windows callback procedure. This method is mostly microsoft magic
 // as each event has its own behaviour and different return codes.
 func winProcessMsg(hwnd win.HWND, msg uint32, wParam uintptr, lParam uintptr) uintptr {
+	if aw, ok := auxWindows.byHwnd[hwnd]; ok {
+		// additional windows share the input messages
+		// but not the main window size and lifetime.
+		switch msg {
+		case win.WM_CLOSE:
+			aw.closed = true // the app closes the window.
+			win.ShowWindow(hwnd, win.SW_HIDE)
+			return 0
+		case win.WM_DESTROY:
+			return 0 // only the main window quits.
+		case win.WM_SIZE:
+			aw.w = int32(win.LOWORD(uint32(lParam)))
+			aw.h = int32(win.HIWORD(uint32(lParam)))
+			return 0
+		case win.WM_EXITSIZEMOVE, win.WM_DISPLAYCHANGE, win.WM_SETCURSOR:
+			return win.DefWindowProc(hwnd, msg, wParam, lParam)
+		case win.WM_DPICHANGED:
+			dpiChanged(hwnd, lParam)
+			return 0
+		case win.WM_ACTIVATE:
+			return 0 // focus is checked when getting input.
+		}
+	}
 	switch msg {
 	case win.WM_ERASEBKGND:
 		// repaint window, generally on window resize.
@@ -202,11 +308,35 @@ func winProcessMsg(hwnd win.HWND, msg uint32, wParam uintptr, lParam uintptr) ui
 			display.x, display.y = point.X, point.Y
 			resizeHandler()
 		}
+
+		monitorChanged(hwnd)
+		return 0
+	case win.WM_DISPLAYCHANGE:
+		// called when the display resolution or refresh rate changes
+		// and when monitors are plugged in or unplugged.
+		display.fw = win.GetSystemMetrics(win.SM_CXSCREEN)
+		display.fh = win.GetSystemMetrics(win.SM_CYSCREEN)
+		display.monitor = win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST)
+		if displayHandler != nil {
+			displayHandler()
+		}
+		return 0
+	case win.WM_DPICHANGED:
+		// called when the window moves to a monitor with a different
+		// scaling, or the scaling changes. Only sent when DPI aware.
+		display.dpi = uint32(win.HIWORD(uint32(wParam)))
+		dpiChanged(hwnd, lParam) // generates WM_SIZE
+		if displayHandler != nil {
+			displayHandler()
+		}
 		return 0
 	case win.WM_ACTIVATE:
-		// window is gaining or losing focus.
+		// window is gaining or losing focus. Keys are kept
+		// when focus moves to one of the additional windows.
 		if win.LOWORD(uint32(wParam)) == win.WA_INACTIVE {
-			input.loseFocus()
+			if _, ok := auxWindows.byHwnd[win.HWND(lParam)]; !ok {
+				input.loseFocus()
+			}
 			return 0
 		}
 		return 0 // nothing to do if focus is gained.
@@ -232,6 +362,32 @@ func winProcessMsg(hwnd win.HWND, msg uint32, wParam uintptr, lParam uintptr) ui
 			return 0
 		}
 		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	case win.WM_CHAR:
+		input.typeChar(uint16(wParam))
+		return 0
+	case win.WM_IME_COMPOSITION:
+		// the composition result is added here instead of
+		// letting windows send it again as WM_CHAR messages.
+		handled := false
+		if lParam&win.GCS_RESULTSTR != 0 {
+			input.Text += compositionString(hwnd, win.GCS_RESULTSTR)
+			text.composing = ""
+			handled = true
+		}
+		if lParam&win.GCS_COMPSTR != 0 {
+			text.composing = compositionString(hwnd, win.GCS_COMPSTR)
+			handled = true
+		}
+		if handled {
+			return 0
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	case win.WM_IME_ENDCOMPOSITION:
+		text.composing = "" // finished or cancelled.
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	case win.WM_DROPFILES:
+		input.Dropped = append(input.Dropped, droppedFiles(win.HDROP(wParam))...)
+		return 0
 	case win.WM_SYSCOMMAND:
 		if (wParam & 0xfff0) == win.SC_KEYMENU {
 			// ignore windows system commands like F10 - menu
@@ -269,6 +425,41 @@ func winProcessMsg(hwnd win.HWND, msg uint32, wParam uintptr, lParam uintptr) ui
 			win.ReleaseCapture()
 		}
 		return 0
+	case win.WM_INPUT:
+		// raw mouse motion is not clamped by the window or screen edges.
+		var raw win.RAWINPUTMOUSE
+		size := uint32(unsafe.Sizeof(raw))
+		header := uint32(unsafe.Sizeof(raw.Header))
+		if win.GetRawInputData(win.HRAWINPUT(lParam), win.RID_INPUT, unsafe.Pointer(&raw), &size, header) != ^uint32(0) {
+			if raw.Header.DwType == win.RIM_TYPEMOUSE && raw.Data.UsFlags&win.MOUSE_MOVE_ABSOLUTE == 0 {
+				input.Dx += raw.Data.LLastX
+				input.Dy += raw.Data.LLastY
+			}
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam) // releases the raw input.
+	case win.WM_SETCURSOR:
+		if cursor.mode != CursorNormal && win.LOWORD(uint32(lParam)) == win.HTCLIENT {
+			win.SetCursor(0) // hide the cursor over the window.
+			return 1
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	case win.WM_POINTERDOWN, win.WM_POINTERUPDATE, win.WM_POINTERUP:
+		// touch screens. DefWindowProc also reports touches as mouse input.
+		id := uint32(win.LOWORD(uint32(wParam)))
+		var kind uint32
+		if win.GetPointerType(id, &kind) && kind == win.PT_TOUCH {
+			point := win.POINT{X: win.GET_X_LPARAM(lParam), Y: win.GET_Y_LPARAM(lParam)}
+			win.ScreenToClient(hwnd, &point)
+			switch msg {
+			case win.WM_POINTERDOWN:
+				input.touchDown(id, point.X, point.Y, time.Now())
+			case win.WM_POINTERUPDATE:
+				input.touchMoved(id, point.X, point.Y)
+			case win.WM_POINTERUP:
+				input.touchUp(id, point.X, point.Y)
+			}
+		}
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
 	case win.WM_MOUSEWHEEL:
 		// normalize the mouse delta from the high word
 		if delta := int16(wParam >> 16); delta != 0 {
@@ -289,6 +480,14 @@ func winProcessMsg(hwnd win.HWND, msg uint32, wParam uintptr, lParam uintptr) ui
 // Destroy the application window. Attempt to remove the rendering context
 // and the device context as well.
 func (wd *windowsDevice) dispose() {
+	for _, aw := range auxWindows.byHwnd {
+		wd.closeWindow(aw.id)
+	}
+	if cursor.clipped {
+		win.ClipCursor(nil)
+		cursor.clipped = false
+	}
+	stopRumble() // motors keep running after the app exits.
 	if wd.hwnd != 0 {
 		win.DestroyWindow(wd.hwnd)
 		wd.hwnd = 0
@@ -311,6 +510,67 @@ func (wd *windowsDevice) surfaceLocation() (x, y int32) {
 	return display.x, display.y
 }
 
+// refreshRate implements Device.
+func (wd *windowsDevice) refreshRate() int {
+	if !wd.isRunning() {
+		return 0
+	}
+	hdc := win.GetDC(wd.hwnd)
+	defer win.ReleaseDC(wd.hwnd, hdc)
+	if hz := win.GetDeviceCaps(hdc, win.VREFRESH); hz > 1 {
+		return int(hz) // 0 and 1 mean the hardware default rate.
+	}
+	return 0
+}
+
+// monitors implements Device.
+func (wd *windowsDevice) monitors() int {
+	return int(win.GetSystemMetrics(win.SM_CMONITORS))
+}
+
+// handles implements Device.
+func (wd *windowsDevice) handles() int {
+	count := uint32(0)
+	if !win.GetProcessHandleCount(win.HANDLE(windows.CurrentProcess()), &count) {
+		return 0
+	}
+	return int(count)
+}
+
+// setWindow implements Device.
+func (wd *windowsDevice) setWindow(x, y, w, h int32) {
+	display.x, display.y, display.w, display.h = x, y, w, h
+	if !wd.isRunning() || !wd.windowed {
+		return // the new size and location are used when leaving fullscreen.
+	}
+	style := uint32(win.WS_CAPTION | win.WS_SYSMENU | win.WS_THICKFRAME)
+	border := win.RECT{Left: x, Top: y, Right: x + w, Bottom: y + h}
+	win.AdjustWindowRectEx(&border, style, false, 0)
+	ww := border.Right - border.Left
+	wh := border.Bottom - border.Top
+	win.SetWindowPos(wd.hwnd, 0, border.Left, border.Top, ww, wh, win.SWP_NOZORDER|win.SWP_NOACTIVATE)
+	monitorChanged(wd.hwnd)
+}
+
+// monitorChanged calls the display handler when the window has moved
+// to a different monitor since the refresh rate may be different.
+func monitorChanged(hwnd win.HWND) {
+	monitor := win.MonitorFromWindow(hwnd, win.MONITOR_DEFAULTTONEAREST)
+	if monitor != display.monitor {
+		display.monitor = monitor
+		if displayHandler != nil {
+			displayHandler()
+		}
+	}
+}
+
+// dpiChanged resizes the window to the size suggested by windows
+// so that the window keeps the same logical size at the new scaling.
+func dpiChanged(hwnd win.HWND, lParam uintptr) {
+	r := (*win.RECT)(unsafe.Add(nil, lParam)) // suggested window rectangle.
+	win.SetWindowPos(hwnd, 0, r.Left, r.Top, r.Right-r.Left, r.Bottom-r.Top, win.SWP_NOZORDER|win.SWP_NOACTIVATE)
+}
+
 // isFullscreen is an internal utility method.
 // FUTURE: expose when needed.
 func (wd *windowsDevice) isFullscreen() bool {
@@ -336,9 +596,10 @@ func (wd *windowsDevice) getInput() *Input {
 	var msg win.MSG
 	for win.PeekMessage(&msg, win.WM_NULL, 0, 0, win.PM_REMOVE) {
 
-		// FUTURE call TranslateMessage(&msg) if the characters
-		// are needed instead of just the virtual key codes.
-
+		// key presses generate WM_CHAR messages when typing text.
+		if text.on {
+			win.TranslateMessage(&msg)
+		}
 		win.DispatchMessage(&msg) // goes to winProcessMsg
 	}
 	if input.shutdown {
@@ -350,8 +611,15 @@ func (wd *windowsDevice) getInput() *Input {
 	// check the IsRunning().
 	if !input.shutdown {
 		// get focus and mouse coordinates
-		input.Focus = wd.hwnd == win.GetActiveWindow()
+		active := win.GetActiveWindow()
+		_, auxActive := auxWindows.byHwnd[active]
+		input.Focus = wd.hwnd == active || auxActive
 		input.Mx, input.My = wd.cursorLocation()
+		input.Scale = wd.displayScale()
+		input.Composing = text.composing
+		wd.updateCursor(input.Focus)
+		pollPads(input, time.Now())
+		input.recognizeGestures(time.Now())
 	}
 	return input // singleton for collecting the latest user input.
 }
@@ -365,6 +633,143 @@ func (wd *windowsDevice) cursorLocation() (mx, my int32) {
 	return point.X, point.Y
 }
 
+// setTextInput implements Device.
+func (wd *windowsDevice) setTextInput(on bool) {
+	if on == text.on {
+		return
+	}
+	text.on = on
+	if on {
+		win.ImmAssociateContext(wd.hwnd, text.himc)
+		return
+	}
+	text.himc = win.ImmAssociateContext(wd.hwnd, 0)
+	text.composing = ""
+}
+
+// compositionString returns the requested IME composition string.
+func compositionString(hwnd win.HWND, index uint32) string {
+	himc := win.ImmGetContext(hwnd)
+	if himc == 0 {
+		return ""
+	}
+	defer win.ImmReleaseContext(hwnd, himc)
+	size := win.ImmGetCompositionString(himc, index, nil, 0) // in bytes.
+	if size <= 0 {
+		return ""
+	}
+	buf := make([]uint16, size/2)
+	win.ImmGetCompositionString(himc, index, unsafe.Pointer(&buf[0]), uint32(size))
+	return string(utf16.Decode(buf))
+}
+
+// clipboard implements Device.
+func (wd *windowsDevice) clipboard() string {
+	if !win.IsClipboardFormatAvailable(win.CF_UNICODETEXT) || !win.OpenClipboard(wd.hwnd) {
+		return ""
+	}
+	defer win.CloseClipboard()
+	data := win.HGLOBAL(win.GetClipboardData(win.CF_UNICODETEXT))
+	if data == 0 {
+		return ""
+	}
+	ptr := win.GlobalLock(data)
+	if ptr == nil {
+		return ""
+	}
+	defer win.GlobalUnlock(data)
+	return windows.UTF16PtrToString((*uint16)(ptr))
+}
+
+// setClipboard implements Device.
+func (wd *windowsDevice) setClipboard(text string) error {
+	chars, err := windows.UTF16FromString(text) // includes the null terminator.
+	if err != nil {
+		return fmt.Errorf("SetClipboard: %w", err)
+	}
+	if !win.OpenClipboard(wd.hwnd) {
+		return fmt.Errorf("SetClipboard: OpenClipboard failed %d", win.GetLastError())
+	}
+	defer win.CloseClipboard()
+	win.EmptyClipboard()
+	data := win.GlobalAlloc(win.GMEM_MOVEABLE, uintptr(len(chars)*2))
+	if data == 0 {
+		return fmt.Errorf("SetClipboard: GlobalAlloc failed %d", win.GetLastError())
+	}
+	ptr := win.GlobalLock(data)
+	copy(unsafe.Slice((*uint16)(ptr), len(chars)), chars)
+	win.GlobalUnlock(data)
+	if win.SetClipboardData(win.CF_UNICODETEXT, win.HANDLE(data)) == 0 {
+		win.GlobalFree(data) // owned by the clipboard on success.
+		return fmt.Errorf("SetClipboard: SetClipboardData failed %d", win.GetLastError())
+	}
+	return nil
+}
+
+// acceptDrops implements Device.
+func (wd *windowsDevice) acceptDrops(accept bool) { win.DragAcceptFiles(wd.hwnd, accept) }
+
+// droppedFiles returns the file paths for a WM_DROPFILES message.
+func droppedFiles(drop win.HDROP) (files []string) {
+	defer win.DragFinish(drop)
+	count := win.DragQueryFile(drop, 0xFFFFFFFF, nil, 0)
+	for i := uint32(0); i < count; i++ {
+		size := win.DragQueryFile(drop, i, nil, 0) + 1 // include the null terminator.
+		name := make([]uint16, size)
+		if win.DragQueryFile(drop, i, &name[0], size) > 0 {
+			files = append(files, windows.UTF16ToString(name))
+		}
+	}
+	return files
+}
+
+// setCursorMode implements Device.
+func (wd *windowsDevice) setCursorMode(mode CursorMode) {
+	cursor.mode = mode
+	if mode == CursorNormal {
+		win.SetCursor(cursor.arrow)
+	} else {
+		win.SetCursor(0) // don't wait for the next mouse move.
+	}
+}
+
+// warpCursor implements Device.
+func (wd *windowsDevice) warpCursor(x, y int32) {
+	point := win.POINT{X: x, Y: y}
+	win.ClientToScreen(wd.hwnd, &point)
+	win.SetCursorPos(point.X, point.Y)
+}
+
+// confineCursor implements Device.
+func (wd *windowsDevice) confineCursor(confine bool) { cursor.confine = confine }
+
+// updateCursor confines and centers the cursor based on the cursor mode.
+// The confining rectangle is reapplied each poll since windows resets it
+// when focus changes, and the window may have moved or resized.
+func (wd *windowsDevice) updateCursor(focus bool) {
+	if !focus || (cursor.mode != CursorCaptured && !cursor.confine) {
+		if cursor.clipped {
+			win.ClipCursor(nil) // release the cursor.
+			cursor.clipped = false
+		}
+		return
+	}
+	var client win.RECT
+	win.GetClientRect(wd.hwnd, &client)
+	topLeft := win.POINT{X: client.Left, Y: client.Top}
+	bottomRight := win.POINT{X: client.Right, Y: client.Bottom}
+	win.ClientToScreen(wd.hwnd, &topLeft)
+	win.ClientToScreen(wd.hwnd, &bottomRight)
+	clip := win.RECT{Left: topLeft.X, Top: topLeft.Y, Right: bottomRight.X, Bottom: bottomRight.Y}
+	win.ClipCursor(&clip)
+	cursor.clipped = true
+	if cursor.mode == CursorCaptured {
+		// keep the cursor away from the edges so it always
+		// lands back in the window, eg: after a click.
+		win.SetCursorPos((clip.Left+clip.Right)/2, (clip.Top+clip.Bottom)/2)
+	}
+}
+
 // switch between a window with a border and a fullscreen
 // window with no border. Expected to be called using F11.
 func (wd *windowsDevice) toggleFullscreen() {
@@ -393,6 +798,106 @@ func (wd *windowsDevice) toggleFullscreen() {
 	}
 }
 
+// =============================================================================
+// additional windows.
+
+// auxWindow is an additional window opened by the application.
+type auxWindow struct {
+	id     uint32   // window ID, never 0.
+	hwnd   win.HWND // window handle
+	w, h   int32    // surface size.
+	closed bool     // true once the user has closed the window.
+}
+
+// auxWindows tracks the open additional windows.
+var auxWindows = struct {
+	byHwnd map[win.HWND]*auxWindow
+	lastID uint32 // last window ID.
+}{byHwnd: map[win.HWND]*auxWindow{}}
+
+// findWindow returns the additional window with the given ID.
+func findWindow(id uint32) *auxWindow {
+	for _, aw := range auxWindows.byHwnd {
+		if aw.id == id {
+			return aw
+		}
+	}
+	return nil
+}
+
+// openWindow implements Device.
+// Uses the window class registered for the main window.
+func (wd *windowsDevice) openWindow(title string, x, y, w, h int32) (uint32, error) {
+	if !wd.isRunning() {
+		return 0, fmt.Errorf("OpenWindow needs CreateDisplay")
+	}
+	style := uint32(win.WS_CAPTION | win.WS_SYSMENU | win.WS_THICKFRAME)
+	border := win.RECT{Left: x, Top: y, Right: x + w, Bottom: y + h}
+	win.AdjustWindowRectEx(&border, style, false, 0)
+	hwnd := win.CreateWindowEx(
+		0,
+		syscall.StringToUTF16Ptr("vuwin"), // registered by createDisplay
+		syscall.StringToUTF16Ptr(title),
+		style,
+		border.Left,
+		border.Top,
+		border.Right-border.Left,
+		border.Bottom-border.Top,
+		win.HWND(0),
+		win.HMENU(0),
+		wd.hinstance,
+		nil,
+	)
+	if hwnd == 0 {
+		return 0, fmt.Errorf("CreateWindowEx failed %d", win.GetLastError())
+	}
+	auxWindows.lastID++
+	aw := &auxWindow{id: auxWindows.lastID, hwnd: hwnd, w: w, h: h}
+	auxWindows.byHwnd[hwnd] = aw
+	win.ShowWindow(hwnd, win.SW_SHOW)
+	return aw.id, nil
+}
+
+// closeWindow implements Device.
+func (wd *windowsDevice) closeWindow(id uint32) {
+	if aw := findWindow(id); aw != nil {
+		delete(auxWindows.byHwnd, aw.hwnd)
+		win.DestroyWindow(aw.hwnd)
+	}
+}
+
+// windowOpen implements Device.
+func (wd *windowsDevice) windowOpen(id uint32) bool {
+	if id == 0 {
+		return wd.isRunning()
+	}
+	aw := findWindow(id)
+	return aw != nil && !aw.closed
+}
+
+// windowSize implements Device.
+func (wd *windowsDevice) windowSize(id uint32) (w, h uint32) {
+	if id == 0 {
+		return wd.surfaceSize()
+	}
+	if aw := findWindow(id); aw != nil {
+		return uint32(aw.w), uint32(aw.h)
+	}
+	return 0, 0
+}
+
+// windowCursor implements Device.
+func (wd *windowsDevice) windowCursor(id uint32) (mx, my int32) {
+	hwnd := wd.hwnd
+	if aw := findWindow(id); aw != nil {
+		hwnd = aw.hwnd
+	}
+	var point win.POINT
+	win.GetCursorPos(&point)
+	win.ScreenToClient(hwnd, &point)
+	return point.X, point.Y
+}
+
 // =============================================================================
 
 // Windows virtual key codes. Map Windows key codes to Vu key codes.
@@ -0,0 +1,163 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package device
+
+// touch.go describes touch screen input. Each finger on the screen is
+// reported as a Touch along with at most one recognized gesture per
+// input poll. Touches are delivered alongside the mouse and keyboard
+// input, and platforms may also report a touch as mouse input.
+
+import (
+	"math"
+	"time"
+)
+
+// Touch is one finger on a touch screen.
+type Touch struct {
+	ID    uint32 // unique while the finger is down.
+	X, Y  int32  // location in surface pixels relative to top left.
+	Began bool   // true for the poll where the finger touched down.
+	Ended bool   // true for the poll where the finger was lifted.
+
+	sx, sy int32     // location where the touch began.
+	px, py int32     // location at the previous poll.
+	start  time.Time // time when the touch began.
+	moved  bool      // true once the touch moves further than tapSlop.
+	multi  bool      // true if other fingers were down at the same time.
+	held   bool      // true once reported as a long press.
+}
+
+// GestureKind identifies a recognized touch gesture.
+type GestureKind int
+
+// Touch gestures.
+const (
+	GestureNone      GestureKind = iota // no gesture this poll.
+	GestureTap                          // one finger quickly touched and lifted.
+	GestureLongPress                    // one finger held down without moving.
+	GesturePan                          // one finger dragged.
+	GesturePinch                        // two fingers moved together or apart.
+)
+
+// Gesture is a touch gesture recognized from the current touches.
+type Gesture struct {
+	Kind   GestureKind // GestureNone if there was no gesture this poll.
+	X, Y   int32       // location of a tap or press, or the pan or pinch center.
+	Dx, Dy int32       // pan motion, in pixels, since last poll.
+	Scale  float64     // pinch distance over the distance at the last poll.
+}
+
+// Gesture recognition thresholds.
+const (
+	tapSlop       = 10                     // pixels a touch can move and still tap.
+	longPressTime = 500 * time.Millisecond // hold time for a long press.
+)
+
+// touchDown records a new finger on the touch screen.
+func (in *Input) touchDown(id uint32, x, y int32, now time.Time) {
+	multi := false
+	for i := range in.Touches {
+		if !in.Touches[i].Ended {
+			in.Touches[i].multi = true
+			multi = true
+		}
+	}
+	in.Touches = append(in.Touches, Touch{ID: id, X: x, Y: y, Began: true,
+		sx: x, sy: y, px: x, py: y, start: now, multi: multi})
+}
+
+// touchMoved updates the location of a finger on the touch screen.
+func (in *Input) touchMoved(id uint32, x, y int32) {
+	if t := in.touch(id); t != nil {
+		t.X, t.Y = x, y
+		if abs(x-t.sx) > tapSlop || abs(y-t.sy) > tapSlop {
+			t.moved = true
+		}
+	}
+}
+
+// touchUp records a finger lifted from the touch screen.
+// The touch is removed at the next poll.
+func (in *Input) touchUp(id uint32, x, y int32) {
+	if t := in.touch(id); t != nil {
+		in.touchMoved(id, x, y)
+		t.Ended = true
+	}
+}
+
+// touch returns the active touch with the given ID, or nil.
+func (in *Input) touch(id uint32) *Touch {
+	for i := range in.Touches {
+		if t := &in.Touches[i]; t.ID == id && !t.Ended {
+			return t
+		}
+	}
+	return nil
+}
+
+// resetTouches removes the touches that ended last poll
+// and clears the one time touch flags.
+func (in *Input) resetTouches() {
+	active := in.Touches[:0]
+	for _, t := range in.Touches {
+		if !t.Ended {
+			t.Began = false
+			t.px, t.py = t.X, t.Y
+			active = append(active, t)
+		}
+	}
+	in.Touches = active
+	in.Gesture = Gesture{}
+}
+
+// releaseTouches ends all the touches, eg: when
+// focus is lost and the touch up events are missed.
+func (in *Input) releaseTouches() {
+	for i := range in.Touches {
+		in.Touches[i].Ended = true
+		in.Touches[i].moved = true // not a tap.
+	}
+}
+
+// recognizeGestures checks the touches for a gesture.
+// Called once per poll after the touch events are processed.
+func (in *Input) recognizeGestures(now time.Time) {
+	down := []*Touch{}
+	for i := range in.Touches {
+		if t := &in.Touches[i]; !t.Ended {
+			down = append(down, t)
+		}
+	}
+	switch {
+	case len(down) >= 2:
+		a, b := down[0], down[1]
+		was := math.Hypot(float64(a.px-b.px), float64(a.py-b.py))
+		is := math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+		if was > 0 && !a.Began && !b.Began && is != was {
+			in.Gesture = Gesture{Kind: GesturePinch, X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2, Scale: is / was}
+		}
+	case len(down) == 1 && !down[0].multi:
+		t := down[0]
+		switch {
+		case t.moved && (t.X != t.px || t.Y != t.py):
+			in.Gesture = Gesture{Kind: GesturePan, X: t.X, Y: t.Y, Dx: t.X - t.px, Dy: t.Y - t.py}
+		case !t.moved && !t.held && now.Sub(t.start) >= longPressTime:
+			t.held = true // report the long press once.
+			in.Gesture = Gesture{Kind: GestureLongPress, X: t.X, Y: t.Y}
+		}
+	case len(down) == 0:
+		for _, t := range in.Touches {
+			if t.Ended && !t.moved && !t.multi && !t.held {
+				in.Gesture = Gesture{Kind: GestureTap, X: t.X, Y: t.Y}
+			}
+		}
+	}
+}
+
+// abs returns the absolute value of x.
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
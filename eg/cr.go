@@ -21,10 +21,15 @@ import (
 //   - adding a light to a scene.
 //   - reacting to user input
 //   - physics
+//   - debug drawing of tagged entities.
+//   - showing the frame statistics and frame graph overlays.
 //
 // CONTROLS:
 //   - A,D   : move the camera left/right around scene center.
 //   - Space : throw a ball from the camera position.
+//   - B     : toggle debug drawing of the physics bodies.
+//   - H     : toggle the frame statistics overlay.
+//   - G     : toggle the frame graph overlay.
 //   - Q     : quit and close window.
 func cr() {
 	defer catchErrors()
@@ -43,6 +48,8 @@ func cr() {
 	// import assets from asset files.
 	// This creates the assets referenced by the models below.
 	eng.ImportAssets("pbr0.shd", "sphere.glb", "box0.glb")
+	eng.ImportAssets("label.shd", "18:lucon.ttf") // statistics overlay.
+	eng.Debug().SetFont("lucon18")
 
 	// New scene with default camera.
 	cr.pos = lin.NewV3().SetS(0, 16, 30)
@@ -78,8 +85,12 @@ func cr() {
 // Globally unique "tag" that encapsulates example specific data.
 type crtag struct {
 	scene *vu.Entity
-	pos   *lin.V3 // initial location.
-	rot   float64 // rotation around origin.
+	pos   *lin.V3      // initial location.
+	rot   float64      // rotation around origin.
+	debug bool         // true to draw physics bodies.
+	stats bool         // true to show frame statistics.
+	graph bool         // true to show the frame graph.
+	found []*vu.Entity // reused for tag queries.
 }
 
 // Update is the regular engine callback.
@@ -101,6 +112,29 @@ func (cr *crtag) Update(eng *vu.Engine, in *vu.Input, delta time.Duration) {
 			forward := lin.NewV3().Forward(lookat).Unit()
 			throw := lin.NewV3().Scale(forward, -30)
 			ball.Push(throw.X, throw.Y, throw.Z)
+		case vu.KB:
+			cr.debug = !cr.debug
+		case vu.KH:
+			cr.stats = !cr.stats
+			eng.ShowStats(cr.stats)
+		case vu.KG:
+			cr.graph = !cr.graph
+			eng.ShowFrameGraph(cr.graph)
+		}
+	}
+
+	// debug draw the physics bodies. Debug draws last one frame.
+	if cr.debug {
+		dbg := eng.Debug()
+		cr.found = eng.Tagged("ball", cr.found)
+		for _, ball := range cr.found {
+			x, y, z := ball.World()
+			dbg.Sphere(x, y, z, 2*sphereRadius, 1, 1, 0)
+		}
+		cr.found = eng.Tagged("box", cr.found)
+		for _, box := range cr.found {
+			x, y, z := box.World()
+			dbg.Axes(x, y, z, 3)
 		}
 	}
 
@@ -126,12 +160,14 @@ func (cr *crtag) Update(eng *vu.Engine, in *vu.Input, delta time.Duration) {
 	}
 }
 
+// sphereRadius is the sphere.glb mesh radius.
+const sphereRadius = 1.2849 // from blender
+
 // makeBall creates a visible sphere physics body.
 func (cr *crtag) makeBall(lx, ly, lz float64) (ball *vu.Entity) {
-	const sphere_radius = 1.2849 // from blender
-	ball = cr.scene.AddModel("shd:pbr0", "msh:sphere")
+	ball = cr.scene.AddModel("shd:pbr0", "msh:sphere").Tag("ball")
 	ball.SetScale(2, 2, 2).SetAt(lx, ly, lz)
-	ball.AddToSimulation(vu.Sphere(2*sphere_radius, vu.KinematicSim))
+	ball.AddToSimulation(vu.Sphere(2*sphereRadius, vu.KinematicSim))
 	r, g, b, a, metallic, roughness := randomColor()
 	ball.SetColor(r, g, b, a)
 	ball.SetMetallicRoughness(metallic, roughness)
@@ -140,7 +176,7 @@ func (cr *crtag) makeBall(lx, ly, lz float64) (ball *vu.Entity) {
 
 // makeBox creates a visible box physics body.
 func (cr *crtag) makeBox(lx, ly, lz float64) (box *vu.Entity) {
-	box = cr.scene.AddModel("shd:pbr0", "msh:box0")
+	box = cr.scene.AddModel("shd:pbr0", "msh:box0").Tag("box")
 	box.SetScale(2, 2, 2).SetAt(lx, ly, lz)
 	box.AddToSimulation(vu.Box(2, 2, 2, vu.KinematicSim))
 	r, g, b, a, metallic, roughness := randomColor()
@@ -39,6 +39,7 @@ func main() {
 	load.SetAssetDir(".shd", "../assets/shaders")
 	load.SetAssetDir(".png", "../assets/images")
 	load.SetAssetDir(".wav", "../assets/audio")
+	load.SetAssetDir(".flac", "../assets/audio")
 	load.SetAssetDir(".glb", "../assets/models")
 	load.SetAssetDir(".ttf", "../assets/fonts")
 	load.SetAssetDir(".yaml", "../assets/data")
@@ -17,6 +17,7 @@ import (
 //   - controlling scene camera movement.
 //   - shaders for Physically Based Rendering (PBR).
 //   - binary GLTF (GLB) imports for mesh, texture, and material assets.
+//   - signed distance field text in a 3D scene.
 //
 // CONTROLS:
 //   - W,S    : move forward, back
@@ -42,6 +43,7 @@ func mh() {
 	// import assets from asset files.
 	// This creates the assets referenced by the models below.
 	eng.ImportAssets("pbr0.shd", "monkey0.glb", "pbr1.shd", "monkey1.glb")
+	eng.ImportAssets("sdf.shd", "sdf:48:lucon.ttf")
 
 	// The scene holds the cameras and lighting information
 	// and acts as the root for all models added to the scene.
@@ -62,6 +64,11 @@ func mh() {
 	mh2 := mh.scene.AddModel("shd:pbr1", "msh:monkey1", "tex:color:monkey1", "mat:monkey1")
 	mh2.SetAt(+1.5, 0, -5)
 
+	// signed distance field text stays crisp when scaled into the 3D scene.
+	title := mh.scene.AddLabel("Monkey Heads", 0, "shd:sdf", "fnt:lucon48sdf", "tex:color:lucon48sdf")
+	title.SetAt(-2.2, 1.5, -5).SetScale(0.01, 0.01, 0.01)
+	title.SetColor(1, 0.8, 0.2, 1).SetTextOutline(0.3, 0, 0, 0, 1).SetTextGlow(0.6)
+
 	eng.Run(mh) // does not return while example is running.
 }
 
@@ -0,0 +1,47 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// errors.go exposes the errors returned by the engine subsystems so
+// that applications can check them without importing each package.
+//
+//	eng.ImportAssetsFunc(func(filename string, err error) {
+//	   if errors.Is(err, vu.ErrAssetNotFound) {
+//	      // use a fallback asset.
+//	   }
+//	}, "tree.glb")
+
+import (
+	"errors"
+
+	"github.com/gazed/vu/audio"
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+var (
+	// ErrAssetNotFound is wrapped by asset load errors
+	// for asset files that do not exist.
+	ErrAssetNotFound = load.ErrAssetNotFound
+
+	// ErrShaderCompile is wrapped by asset load errors for shaders
+	// that the GPU rejects. Use errors.As with a *ShaderError for
+	// the failure details.
+	ErrShaderCompile = render.ErrShaderCompile
+
+	// ErrDeviceLost is returned by Engine.Err when the GPU
+	// device was lost, eg: a driver crash or reset.
+	ErrDeviceLost = render.ErrDeviceLost
+
+	// ErrAudioDevice is wrapped by the errors for audio devices
+	// that fail to open, eg: an unplugged headset.
+	ErrAudioDevice = audio.ErrDevice
+
+	// ErrUnsupportedFeature is wrapped by errors for features that
+	// the platform or hardware does not support, eg: an unknown asset
+	// file type, or selecting an audio device with audio disabled.
+	ErrUnsupportedFeature = errors.ErrUnsupported
+)
+
+// ShaderError is returned for shaders that fail to load.
+type ShaderError = render.ShaderError
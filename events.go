@@ -0,0 +1,209 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// events.go is a publish and subscribe event bus for decoupled gameplay
+// messaging. Events are typed values sent on topics, eg:
+//
+//	type Damage struct{ Target *vu.Entity; HP int }
+//	damage := vu.NewTopic[Damage](eng, "damage")
+//	damage.Subscribe(func(ev Damage) { hud.flash(ev.HP) })
+//	damage.Publish(Damage{Target: orc, HP: 10})
+//
+// Published events are queued and delivered, in publish order across all
+// topics, once each frame just before the application Update. Events
+// published while delivering are delivered the next frame. Send delivers
+// an event immediately instead.
+//
+// The engine publishes its own notifications on the engine topics,
+// see Engine.ResizeEvents, Engine.FocusEvents, and Engine.ContactEvents.
+
+import (
+	"github.com/gazed/vu/math/lin"
+)
+
+// Topic delivers events of type T to its subscribers.
+// Create with NewTopic.
+type Topic[T any] struct {
+	bus     *events
+	name    string
+	subs    []topicSub[T] // subscribers in subscribe order.
+	sending int           // nested sends, subs are compacted when 0.
+	removed bool          // true if there are cancelled subscribers.
+}
+
+// topicSub is a subscriber function.
+type topicSub[T any] struct {
+	sub *Subscription
+	fn  func(ev T)
+}
+
+// NewTopic creates a topic for events of type T. The name
+// identifies the topic in logs. Expected to be called once
+// for each topic on startup.
+func NewTopic[T any](eng *Engine, name string) *Topic[T] {
+	return newTopic[T](eng.app.events, name)
+}
+
+// newTopic creates a topic on the given bus.
+func newTopic[T any](bus *events, name string) *Topic[T] {
+	return &Topic[T]{bus: bus, name: name}
+}
+
+// Name returns the topic name.
+func (t *Topic[T]) Name() string { return t.name }
+
+// Subscribe adds a function that is called with each event sent on the
+// topic. Subscribers are called in subscribe order. Subscribers added
+// while an event is being delivered receive the next event.
+func (t *Topic[T]) Subscribe(fn func(ev T)) *Subscription {
+	sub := &Subscription{topic: t}
+	if fn != nil {
+		t.subs = append(t.subs, topicSub[T]{sub: sub, fn: fn})
+	}
+	return sub
+}
+
+// Publish queues the event for delivery before the next application
+// Update, see Send for immediate delivery.
+func (t *Topic[T]) Publish(ev T) {
+	if t.active() {
+		t.bus.queue = append(t.bus.queue, func() { t.Send(ev) })
+	}
+}
+
+// Send delivers the event to the current subscribers immediately.
+func (t *Topic[T]) Send(ev T) {
+	t.sending++
+	for i, last := 0, len(t.subs); i < last; i++ {
+		if s := t.subs[i]; !s.sub.cancelled {
+			s.fn(ev)
+		}
+	}
+	t.sending--
+	t.compact()
+}
+
+// active returns true if the topic has any subscribers.
+func (t *Topic[T]) active() bool {
+	for _, s := range t.subs {
+		if !s.sub.cancelled {
+			return true
+		}
+	}
+	return false
+}
+
+// compact removes the cancelled subscribers once the
+// topic is no longer delivering events.
+func (t *Topic[T]) compact() {
+	if t.sending > 0 || !t.removed {
+		return
+	}
+	keep := t.subs[:0]
+	for _, s := range t.subs {
+		if !s.sub.cancelled {
+			keep = append(keep, s)
+		}
+	}
+	clear(t.subs[len(keep):]) // release the subscriber functions.
+	t.subs, t.removed = keep, false
+}
+
+// cancel is called when a subscription is cancelled.
+func (t *Topic[T]) cancel() {
+	t.removed = true
+	t.compact()
+}
+
+// Subscription is a topic subscriber, see Topic.Subscribe.
+type Subscription struct {
+	topic     interface{ cancel() }
+	cancelled bool
+}
+
+// Cancel stops delivering events to the subscriber,
+// including any queued events.
+func (s *Subscription) Cancel() {
+	if !s.cancelled {
+		s.cancelled = true
+		s.topic.cancel()
+	}
+}
+
+// =============================================================================
+// engine topics.
+
+// ResizeEvent is published when the window is moved or resized.
+// The location is the upper left corner of the window.
+type ResizeEvent struct {
+	X, Y int32
+	W, H uint32
+}
+
+// FocusEvent is published when the window gains or loses focus.
+type FocusEvent struct {
+	Focus bool // true if the window has focus.
+}
+
+// ContactEvent is published when two physics bodies start or stop
+// touching, see Entity.OnContact for contacts of a single entity.
+type ContactEvent struct {
+	A, B    *Entity // the touching entities.
+	Point   lin.V3  // world location of the contact.
+	Normal  lin.V3  // contact normal pointing from A towards B.
+	Trigger bool    // true if either body is a trigger volume.
+	Begin   bool    // true when the bodies start touching.
+}
+
+// ResizeEvents returns the topic for window resize events.
+func (eng *Engine) ResizeEvents() *Topic[ResizeEvent] { return eng.app.events.resize }
+
+// FocusEvents returns the topic for window focus events.
+func (eng *Engine) FocusEvents() *Topic[FocusEvent] { return eng.app.events.focus }
+
+// ContactEvents returns the topic for physics contact events.
+// Contacts are only tracked while the topic has subscribers.
+func (eng *Engine) ContactEvents() *Topic[ContactEvent] { return eng.app.events.contacts }
+
+// =============================================================================
+
+// events is the event queue and the engine topics.
+type events struct {
+	queue []func() // published events in publish order.
+	next  []func() // reused queue.
+
+	resize   *Topic[ResizeEvent]
+	focus    *Topic[FocusEvent]
+	contacts *Topic[ContactEvent]
+	focused  bool // last published window focus.
+}
+
+// newEvents creates the event bus and the engine topics.
+func newEvents() *events {
+	bus := &events{focused: true}
+	bus.resize = newTopic[ResizeEvent](bus, "resize")
+	bus.focus = newTopic[FocusEvent](bus, "focus")
+	bus.contacts = newTopic[ContactEvent](bus, "contact")
+	return bus
+}
+
+// checkFocus publishes a focus event when the window focus changes.
+func (bus *events) checkFocus(in *Input) {
+	if in.Focus != bus.focused {
+		bus.focused = in.Focus
+		bus.focus.Publish(FocusEvent{Focus: in.Focus})
+	}
+}
+
+// flush delivers the queued events. Events published
+// while flushing are delivered by the next flush.
+func (bus *events) flush() {
+	queue := bus.queue
+	bus.queue, bus.next = bus.next[:0], nil
+	for i, send := range queue {
+		send()
+		queue[i] = nil // release the event.
+	}
+	bus.next = queue[:0]
+}
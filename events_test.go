@@ -0,0 +1,98 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+)
+
+// go test -run Events
+func TestEvents(t *testing.T) {
+	type damage struct{ hp int }
+
+	// go test -run Events/queued
+	t.Run("queued", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		hits, heals := NewTopic[damage](eng, "damage"), NewTopic[int](eng, "heal")
+		got := []int{}
+		hits.Subscribe(func(ev damage) {
+			got = append(got, -ev.hp)
+			heals.Publish(ev.hp) // delivered next flush.
+		})
+		heals.Subscribe(func(hp int) { got = append(got, hp) })
+		hits.Publish(damage{hp: 1})
+		heals.Publish(2)
+		hits.Publish(damage{hp: 3})
+		if len(got) != 0 {
+			t.Fatalf("expected events queued until flush")
+		}
+		eng.app.events.flush()
+		if len(got) != 3 || got[0] != -1 || got[1] != 2 || got[2] != -3 {
+			t.Errorf("expected publish order got %v", got)
+		}
+		eng.app.events.flush()
+		if len(got) != 5 || got[3] != 1 || got[4] != 3 {
+			t.Errorf("expected events published while flushing next flush got %v", got)
+		}
+	})
+
+	// go test -run Events/send
+	t.Run("send", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		hits := NewTopic[damage](eng, "damage")
+		total := 0
+		var first, second *Subscription
+		first = hits.Subscribe(func(ev damage) {
+			total += ev.hp
+			second.Cancel() // cancelled while sending.
+			hits.Subscribe(func(ev damage) { total += 100 * ev.hp })
+		})
+		second = hits.Subscribe(func(ev damage) { total += 10 * ev.hp })
+		hits.Send(damage{hp: 1})
+		if total != 1 || len(hits.subs) != 2 {
+			t.Errorf("expected immediate delivery to the first subscriber got %d", total)
+		}
+		hits.Publish(damage{hp: 2})
+		first.Cancel()
+		eng.app.events.flush()
+		if total != 201 || len(hits.subs) != 1 {
+			t.Errorf("expected queued event skips cancelled subscribers got %d", total)
+		}
+	})
+
+	// go test -run Events/engine
+	t.Run("engine", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		app := eng.app
+		focus := []bool{}
+		eng.FocusEvents().Subscribe(func(ev FocusEvent) { focus = append(focus, ev.Focus) })
+		app.input.Focus = true
+		app.events.checkFocus(app.input)
+		app.input.Focus = false
+		app.events.checkFocus(app.input)
+		app.events.checkFocus(app.input)
+		app.events.flush()
+		if len(focus) != 1 || focus[0] {
+			t.Errorf("expected one focus lost event got %v", focus)
+		}
+
+		// contacts are published without entity contact functions.
+		scene := eng.AddScene(Scene3D)
+		ground := scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(10, 1, 10, StaticSim))
+		ball := scene.AddPart().SetAt(0, 1, 0).AddToSimulation(Sphere(0.5, KinematicSim))
+		contacts := []ContactEvent{}
+		eng.ContactEvents().Subscribe(func(ev ContactEvent) { contacts = append(contacts, ev) })
+		for i := 0; i < 60; i++ {
+			app.sim.simulate(app.povs, app.work, timestepSecs)
+			app.sim.contact(app)
+		}
+		app.events.flush()
+		if len(contacts) != 1 {
+			t.Fatalf("expected one contact got %d", len(contacts))
+		}
+		c := contacts[0]
+		if !c.Begin || c.A.eid != ground.eid || c.B.eid != ball.eid || c.Normal.Y < 0.99 {
+			t.Errorf("expected ball landing on the ground got %+v", c)
+		}
+	})
+}
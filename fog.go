@@ -0,0 +1,88 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// fog.go adds depth cues to outdoor 3D scenes, eg:
+//
+//	world.SetFog(0.7, 0.75, 0.8, 0.9, 50, 600) // haze from 50 to 600 units.
+//	world.SetHeightFog(0, 0.02)                 // thicker in the valleys.
+//	world.AddSky().SetSkySun(0.3, 0.4, -1, 20)  // morning sky.
+//
+// Fog is passed to the standard 3D shaders as scene uniforms: the pbr,
+// anim3D, tex3D, col3D, terrain, and water shaders. Fog increases from
+// nothing at the start distance to the fog amount at the end distance.
+// Height fog thins the fog exponentially above a base height.
+//
+// The sky is drawn on a cube that follows the camera and stays behind
+// everything else in the scene. The sky shader scatters sunlight through
+// the atmosphere to color the sky from the sun direction, giving a blue
+// sky at noon and red skies when the sun is near the horizon.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// SetFog sets the 3D scene fog color and the most fog, from 0 for no fog
+// to 1 for solid fog. Fog starts at the start distance from the camera
+// and is thickest at the end distance. Fog is off by default.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) SetFog(r, g, b, amount, start, end float64) *Entity {
+	s := e.app.scenes.get(e.eid)
+	if s == nil || s.pid != render.Pass3D {
+		slog.Error("SetFog needs 3D scene", "eid", e.eid)
+		return e
+	}
+	s.fogColor = lin.V4{X: r, Y: g, Z: b, W: lin.Clamp(amount, 0, 1)}
+	s.fog.X, s.fog.Y = max(start, 0), max(end, start)
+	return e
+}
+
+// SetHeightFog makes the 3D scene fog thinner above the base height.
+// Falloff is how quickly the fog thins with height, where 0, the default,
+// gives the same fog at all heights.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) SetHeightFog(base, falloff float64) *Entity {
+	s := e.app.scenes.get(e.eid)
+	if s == nil || s.pid != render.Pass3D {
+		slog.Error("SetHeightFog needs 3D scene", "eid", e.eid)
+		return e
+	}
+	s.fog.Z, s.fog.W = max(falloff, 0), base
+	return e
+}
+
+// AddSky adds an atmospheric scattering sky to a 3D scene. The sun is
+// overhead until set with SetSkySun. Returns the sky model.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) AddSky() (me *Entity) {
+	me = e.AddModel("shd:sky", "msh:cube").SetRenderQueue(QueueBackground)
+	if s := e.app.scenes.get(e.eid); s == nil || s.pid != render.Pass3D {
+		slog.Error("AddSky needs 3D scene", "eid", e.eid)
+		return me
+	}
+	me.SetViewCull(false) // the sky follows the camera.
+	return me.SetSkySun(0, 1, 0, skySunIntensity)
+}
+
+// SetSkySun sets the direction towards the sun and the sun brightness.
+// The default brightness is 20.
+//
+// Depends on Entity.AddSky.
+func (e *Entity) SetSkySun(x, y, z, intensity float64) *Entity {
+	sun := lin.V3{X: x, Y: y, Z: z}
+	if lin.AeqZ(sun.Len()) {
+		slog.Error("SetSkySun invalid direction", "eid", e.eid)
+		return e
+	}
+	sun.Unit()
+	return e.SetModelUniform("args4", []float32{float32(sun.X), float32(sun.Y), float32(sun.Z), float32(intensity)})
+}
+
+// skySunIntensity is the default sun brightness.
+const skySunIntensity = 20.0
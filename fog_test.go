@@ -0,0 +1,50 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Fog
+func TestFog(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	scene.AddView(0.5, 0, 0.5, 1)
+	app.scenes.setViewMatrixes(0, 800, 600)
+	app.povs.setWorldMatrix(app.work, 0)
+
+	// go test -run Fog/uniforms
+	t.Run("uniforms", func(t *testing.T) {
+		scene.SetFog(0.5, 0.6, 0.7, 2, 10, 5).SetHeightFog(3, 0.1)
+		s := app.scenes.get(scene.eid)
+		if s.fogColor.W != 1 || s.fog.X != 10 || s.fog.Y != 10 || s.fog.Z != 0.1 || s.fog.W != 3 {
+			t.Errorf("expected limited fog got %v %v", s.fog, s.fogColor)
+		}
+		passes := app.scenes.getFrame(app, 0, app.frame)
+		for _, i := range []int{0, 2} { // scene and view.
+			want := render.V4ToBytes(&s.fogColor, nil)
+			if got := passes[i].Uniforms[load.FOGCOLOR]; string(got) != string(want) || len(passes[i].Uniforms[load.FOG]) != 16 {
+				t.Errorf("expected pass %d fog uniforms", i)
+			}
+		}
+	})
+
+	// go test -run Fog/sky
+	t.Run("sky", func(t *testing.T) {
+		sky := scene.AddSky().SetSkySun(0, 0, 2, 10)
+		m := app.models.get(sky.eid)
+		if m.queue != QueueBackground || !m.noViewCull {
+			t.Errorf("expected unculled background sky")
+		}
+		want := render.V4S32ToBytes(0, 0, 1, 10, nil)
+		if got := m.uniforms[load.ARGS4]; string(got) != string(want) {
+			t.Errorf("expected unit sun direction got %v", got)
+		}
+	})
+}
@@ -7,6 +7,10 @@ require (
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/image v0.20.0
+require (
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/vorbis v1.0.2
+	golang.org/x/image v0.20.0
+)
 
 require golang.org/x/text v0.18.0 // indirect
@@ -0,0 +1,501 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// gui.go provides immediate mode widgets for tools and tuning panels.
+// Unlike the retained widgets in ui.go, GUI widgets only exist while
+// they are called each update, and they return the user interaction
+// directly, eg:
+//
+//	gui := eng.GUI().SetFont("lucon18")
+//	if gui.Begin("Tuning", 10, 10, 240) {
+//		gui.Text(fmt.Sprintf("fps %.0f", fps))
+//		gui.SliderFloat("speed", &speed, 0, 20)
+//		gui.Checkbox("wireframe", &wireframe)
+//		if gui.Button("reset") {
+//			speed = 5
+//		}
+//	}
+//	gui.End()
+//
+// Windows are remembered by title so that they keep their location and
+// collapsed state. Drag the title bar to move a window and click it to
+// collapse or expand the window. Widgets are identified by their window
+// title and label, so labels are expected to be unique within a window.
+//
+// GUI draws are batched into one dynamic mesh for the widget rectangles
+// and one for the widget text, like the Debug draws. The gui shader is
+// imported on the first call. Text needs the label shader and a bitmap
+// font, see GUI.SetFont.
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// GUI returns the engine immediate mode GUI. GUI windows are drawn in
+// pixels in the 2D scene, which is created if it does not exist. Windows
+// are drawn for one frame, so the GUI is expected to be called each update.
+func (eng *Engine) GUI() *GUI {
+	if eng.app.gui == nil {
+		eng.app.gui = newGUI(eng.app)
+		eng.app.ld.importAssetData("gui.shd")
+	}
+	return eng.app.gui
+}
+
+// GUI collects the immediate mode windows and widgets for a single frame.
+// Draws past the GUI buffer limits are ignored.
+type GUI struct {
+	app  *application
+	font string // font asset name.
+
+	// windows remember their location between frames.
+	windows map[string]*guiWindow
+	win     *guiWindow   // window between Begin and End.
+	drawn   []*guiWindow // windows drawn this frame, back to front.
+	last    []*guiWindow // windows drawn last frame.
+	hot     *guiWindow   // top window under the mouse.
+	active  string       // widget being pressed or dragged.
+	started bool         // true once the first window of a frame begins.
+
+	// rectangle and text data for the current frame.
+	vx    []float32   // vec2 rectangle corners.
+	cx    []float32   // vec3 rectangle colors.
+	ix    []uint16    // rectangle triangle indexes.
+	texts []debugText // text requests.
+	tvx   []float32   // vec2 glyph vertexes.
+	tuv   []float32   // vec2 glyph texcoords.
+	tix   []uint16    // glyph triangle indexes.
+
+	// models draw the rectangles and text using double buffered
+	// dynamic meshes that are updated each frame.
+	rects, text            *Entity
+	rectMeshes, textMeshes [2]*mesh
+	flip                   int // alternates the double buffered meshes.
+}
+
+// guiWindow is a GUI window remembered between frames.
+type guiWindow struct {
+	title     string
+	x, y, w   int  // top left corner and width in pixels.
+	h         int  // height when last drawn.
+	cursor    int  // top of the next widget row.
+	collapsed bool // true to only show the title bar.
+	bg        int  // index of the background rectangle vertexes.
+
+	// title bar dragging.
+	dragX, dragY int  // mouse offset from the window corner.
+	moved        bool // true if the window was dragged.
+}
+
+// Limits and sizes for the GUI.
+const (
+	maxGUIRects   = 2048 // rectangles, 4 vertexes each.
+	maxGUIChars   = 4096 // text characters.
+	guiPadding    = 4    // pixels around widgets and text.
+	guiLineHeight = 16   // row text height until the font loads.
+	guiDragSlop   = 3    // pixels a title bar moves before it is a drag.
+)
+
+// GUI colors.
+var (
+	guiTitle  = [3]float32{0.2, 0.3, 0.5}
+	guiBack   = [3]float32{0.1, 0.1, 0.12}
+	guiWidget = [3]float32{0.25, 0.25, 0.3}
+	guiHover  = [3]float32{0.35, 0.35, 0.42}
+	guiActive = [3]float32{0.2, 0.5, 0.9}
+	guiMark   = [3]float32{0.85, 0.85, 0.9}
+)
+
+// newGUI allocates space for one frame of GUI draws.
+func newGUI(app *application) *GUI {
+	return &GUI{
+		app:     app,
+		windows: map[string]*guiWindow{},
+		vx:      make([]float32, 0, maxGUIRects*4*2),
+		cx:      make([]float32, 0, maxGUIRects*4*3),
+		ix:      make([]uint16, 0, maxGUIRects*6),
+	}
+}
+
+// SetFont sets the font asset used for GUI text, eg: "lucon18".
+// The font and its texture must be imported by the application
+// along with the label shader, eg:
+//
+//	eng.ImportAssets("label.shd", "18:lucon.ttf")
+//	eng.GUI().SetFont("lucon18")
+//
+// The font can only be set before the first GUI text is drawn.
+func (g *GUI) SetFont(name string) *GUI {
+	if g.text != nil {
+		slog.Error("GUI.SetFont after text drawn", "font", g.font, "new_font", name)
+		return g
+	}
+	g.font = name
+	return g
+}
+
+// WantsMouse returns true if the mouse is over a GUI window or is
+// dragging a GUI widget. The application is expected to ignore the
+// mouse while the GUI is using it.
+func (g *GUI) WantsMouse() bool {
+	in := g.app.input
+	return g.active != "" || g.over(int(in.Mx), int(in.My)) != nil
+}
+
+// Begin starts a window with the given title. The location and width
+// are used the first time the window is shown. Returns false if the
+// window is collapsed, in which case its widgets can be skipped.
+// Each Begin must be followed by End.
+func (g *GUI) Begin(title string, x, y, w int) (open bool) {
+	if g.win != nil {
+		slog.Error("GUI.Begin needs End", "window", g.win.title, "title", title)
+		g.End()
+	}
+	if !g.started {
+		in := g.app.input
+		g.hot, g.started = g.over(int(in.Mx), int(in.My)), true
+	}
+	win, ok := g.windows[title]
+	if !ok {
+		win = &guiWindow{title: title, x: x, y: y, w: w}
+		g.windows[title] = win
+	}
+	g.win = win
+
+	// the background is sized by End once the widgets are known.
+	win.bg = len(g.vx) / 2
+	g.rect(0, 0, 0, 0, guiBack)
+
+	// drag the title bar to move the window, click it to collapse.
+	h := g.lineHeight() + 2*guiPadding
+	id := title + "#title"
+	hover, pressed, released := g.interact(id, win.x, win.y, win.w, h)
+	in := g.app.input
+	mx, my := int(in.Mx), int(in.My)
+	switch {
+	case pressed:
+		win.dragX, win.dragY, win.moved = mx-win.x, my-win.y, false
+	case g.active == id:
+		x, y := mx-win.dragX, my-win.dragY
+		if win.moved || abs(x-win.x) > guiDragSlop || abs(y-win.y) > guiDragSlop {
+			win.x, win.y, win.moved = x, y, true
+		}
+	}
+	if released && !win.moved {
+		win.collapsed = !win.collapsed
+	}
+	color := guiTitle
+	if hover {
+		color = guiHover
+	}
+	g.rect(win.x, win.y, win.w, h, color)
+	marker := map[bool]string{true: "+ ", false: "- "}[win.collapsed]
+	g.label(win.x+guiPadding, win.y+guiPadding, marker+title)
+	win.cursor = win.y + h
+	return !win.collapsed
+}
+
+// End finishes the window started by Begin.
+func (g *GUI) End() {
+	win := g.win
+	if win == nil {
+		slog.Error("GUI.End needs Begin")
+		return
+	}
+	win.h = win.cursor - win.y
+	if !win.collapsed {
+		win.h += guiPadding
+	}
+	g.place(win.bg, win.x, win.y, win.w, win.h)
+	g.drawn = append(g.drawn, win)
+	g.win = nil
+}
+
+// Text shows a line of text in the current window.
+func (g *GUI) Text(str string) {
+	if x, y, _, _, ok := g.row(); ok {
+		g.label(x, y+guiPadding, str)
+	}
+}
+
+// Button shows a button with the given label.
+// Returns true if the button was clicked.
+func (g *GUI) Button(label string) (clicked bool) {
+	x, y, w, h, ok := g.row()
+	if !ok {
+		return false
+	}
+	hover, _, released := g.interact(g.win.title+"/"+label, x, y, w, h)
+	g.rect(x, y, w, h, g.color(label, hover))
+	g.label(x+guiPadding, y+guiPadding, label)
+	return released && hover
+}
+
+// Checkbox shows a check box that toggles the given value.
+// Returns true if the value changed.
+func (g *GUI) Checkbox(label string, value *bool) (changed bool) {
+	x, y, w, h, ok := g.row()
+	if !ok {
+		return false
+	}
+	hover, _, released := g.interact(g.win.title+"/"+label, x, y, w, h)
+	if released && hover {
+		*value, changed = !*value, true
+	}
+	g.rect(x, y, h, h, g.color(label, hover))
+	if *value {
+		g.rect(x+guiPadding, y+guiPadding, h-2*guiPadding, h-2*guiPadding, guiMark)
+	}
+	g.label(x+h+guiPadding, y+guiPadding, label)
+	return changed
+}
+
+// SliderFloat shows a slider that sets the given value
+// between lo and hi. Returns true if the value changed.
+func (g *GUI) SliderFloat(label string, value *float64, lo, hi float64) (changed bool) {
+	text := label + " " + strconv.FormatFloat(*value, 'f', 2, 64)
+	fraction, ok := g.slider(label, text, (*value-lo)/(hi-lo))
+	if ok && hi > lo {
+		if v := lo + fraction*(hi-lo); v != *value {
+			*value, changed = v, true
+		}
+	}
+	return changed
+}
+
+// SliderInt shows a slider that sets the given value
+// between lo and hi. Returns true if the value changed.
+func (g *GUI) SliderInt(label string, value *int, lo, hi int) (changed bool) {
+	text := fmt.Sprintf("%s %d", label, *value)
+	fraction, ok := g.slider(label, text, float64(*value-lo)/float64(hi-lo))
+	if ok && hi > lo {
+		if v := lo + int(fraction*float64(hi-lo)+0.5); v != *value {
+			*value, changed = v, true
+		}
+	}
+	return changed
+}
+
+// slider draws a slider filled to the given fraction. Returns
+// the new fraction and true while the slider is being dragged.
+func (g *GUI) slider(label, text string, fraction float64) (float64, bool) {
+	x, y, w, h, ok := g.row()
+	if !ok {
+		return 0, false
+	}
+	id := g.win.title + "/" + label
+	hover, _, _ := g.interact(id, x, y, w, h)
+	dragging := g.active == id
+	if dragging {
+		mx := float64(g.app.input.Mx)
+		fraction = min(max((mx-float64(x))/float64(w), 0), 1)
+	}
+	fraction = min(max(fraction, 0), 1)
+	g.rect(x, y, w, h, g.color(label, hover))
+	g.rect(x, y, int(fraction*float64(w)), h, guiActive)
+	g.label(x+guiPadding, y+guiPadding, text)
+	return fraction, dragging
+}
+
+// =============================================================================
+// GUI layout and interaction.
+
+// row returns the location of the next widget in the current window
+// and moves the window cursor. Returns false if there is no open window.
+func (g *GUI) row() (x, y, w, h int, ok bool) {
+	win := g.win
+	if win == nil || win.collapsed {
+		if win == nil {
+			slog.Error("GUI widgets need Begin")
+		}
+		return 0, 0, 0, 0, false
+	}
+	x, y = win.x+guiPadding, win.cursor+guiPadding
+	w, h = win.w-2*guiPadding, g.lineHeight()+2*guiPadding
+	win.cursor = y + h
+	return x, y, w, h, true
+}
+
+// interact checks the mouse against a widget in the current window.
+// Pressed is true when the mouse is pressed over the widget, which
+// makes it the active widget. Released is true when the mouse is
+// released after pressing the widget.
+func (g *GUI) interact(id string, x, y, w, h int) (hover, pressed, released bool) {
+	in := g.app.input
+	mx, my := int(in.Mx), int(in.My)
+	inside := mx >= x && mx < x+w && my >= y && my < y+h
+	hover = inside && g.hot == g.win && (g.active == "" || g.active == id)
+	if _, ok := in.Pressed[KML]; ok && hover {
+		g.active, pressed = id, true
+	}
+	if _, ok := in.Released[KML]; ok && g.active == id {
+		released = true
+	}
+	return hover, pressed, released
+}
+
+// color returns the widget color for its interaction state.
+func (g *GUI) color(label string, hover bool) [3]float32 {
+	switch {
+	case g.active == g.win.title+"/"+label:
+		return guiActive
+	case hover:
+		return guiHover
+	}
+	return guiWidget
+}
+
+// over returns the top window drawn last frame
+// that contains the given pixel location.
+func (g *GUI) over(x, y int) *guiWindow {
+	for i := len(g.last) - 1; i >= 0; i-- {
+		win := g.last[i]
+		if x >= win.x && x < win.x+win.w && y >= win.y && y < win.y+win.h {
+			return win
+		}
+	}
+	return nil
+}
+
+// lineHeight returns the font line height,
+// or a default height if the font is not loaded.
+func (g *GUI) lineHeight() int {
+	if f, _ := g.app.ld.getLoadedAsset(assetID(fnt, g.font)).(*font); f != nil && f.lineh > 0 {
+		return f.lineh
+	}
+	return guiLineHeight
+}
+
+// rect adds a rectangle with the given top left corner and size.
+func (g *GUI) rect(x, y, w, h int, c [3]float32) {
+	if len(g.ix)+6 > maxGUIRects*6 {
+		return // GUI buffer full.
+	}
+	i0 := uint16(len(g.vx) / 2)
+	g.vx = append(g.vx, 0, 0, 0, 0, 0, 0, 0, 0)
+	g.place(int(i0), x, y, w, h)
+	for i := 0; i < 4; i++ {
+		g.cx = append(g.cx, c[0], c[1], c[2])
+	}
+	g.ix = append(g.ix, i0, i0+2, i0+1, i0+1, i0+2, i0+3)
+}
+
+// place sets the corners of the rectangle at the given vertex index.
+func (g *GUI) place(i0, x, y, w, h int) {
+	if (i0+4)*2 > len(g.vx) {
+		return // rectangle was dropped when the buffer was full.
+	}
+	x0, y0, x1, y1 := float32(x), float32(y), float32(x+w), float32(y+h)
+	copy(g.vx[i0*2:], []float32{x0, y0, x1, y0, x0, y1, x1, y1})
+}
+
+// label adds text with its top left corner at x, y.
+func (g *GUI) label(x, y int, str string) {
+	g.texts = append(g.texts, debugText{x: x, y: y, str: str})
+}
+
+// abs returns the absolute value of an int.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// =============================================================================
+// GUI rendering.
+
+// frame resets the GUI for the next frame. The windows drawn this frame
+// are remembered to find the window under the mouse next frame.
+func (g *GUI) frame() {
+	if g.win != nil {
+		slog.Error("GUI.End missing", "window", g.win.title)
+		g.End()
+	}
+	if _, down := g.app.input.Down[KML]; !down {
+		g.active = "" // mouse released.
+	}
+	g.last, g.drawn = append(g.last[:0], g.drawn...), g.drawn[:0]
+	g.vx, g.cx, g.ix = g.vx[:0], g.cx[:0], g.ix[:0]
+	g.texts = g.texts[:0]
+	g.started, g.hot = false, nil
+}
+
+// draw uploads the GUI data collected for this frame and
+// resets for the next frame. Called by the engine before rendering.
+func (g *GUI) draw(rc *render.Context) {
+	g.flip = (g.flip + 1) % 2
+	g.drawRects(rc)
+	g.drawText(rc)
+	g.frame()
+}
+
+// drawRects updates the rectangle mesh for the 2D scene.
+func (g *GUI) drawRects(rc *render.Context) {
+	if g.rects == nil {
+		if len(g.ix) == 0 {
+			return // nothing to draw.
+		}
+		var err error
+		if g.rectMeshes, err = debugMeshes(rc, "guiRects", maxGUIRects*4, 2, true, maxGUIRects*6); err != nil {
+			slog.Error("GUI rects", "error", err)
+			return
+		}
+		g.rects = g.scene().AddModel("shd:gui").SetColor(1, 1, 1, 0.92)
+		g.rects.SetLayer(14).SetRenderQueue(QueueTransparent) // under the GUI text.
+	}
+	g.rects.Cull(len(g.ix) == 0)
+	if len(g.ix) == 0 {
+		return
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(g.vx, 2) // vec2
+	md[load.Colors] = load.F32Buffer(g.cx, 3)   // vec3
+	md[load.Indexes] = load.U16Buffer(g.ix)
+	setMesh(g.app, rc, g.rects, g.rectMeshes[g.flip], md)
+}
+
+// drawText updates the text mesh for the 2D scene.
+func (g *GUI) drawText(rc *render.Context) {
+	if g.text == nil && len(g.texts) == 0 {
+		return // nothing to draw.
+	}
+	f, _ := g.app.ld.getLoadedAsset(assetID(fnt, g.font)).(*font)
+	if f == nil {
+		return // waiting for SetFont and the font to load.
+	}
+	g.tvx, g.tuv, g.tix = textQuads(f, g.texts, maxGUIChars, g.tvx[:0], g.tuv[:0], g.tix[:0])
+	if g.text == nil {
+		var err error
+		if g.textMeshes, err = debugMeshes(rc, "guiText", maxGUIChars*4, 2, false, maxGUIChars*6); err != nil {
+			slog.Error("GUI text", "error", err)
+			return
+		}
+		g.text = g.scene().AddModel("shd:label", "tex:color:"+g.font)
+		g.text.SetColor(1, 1, 1, 1).SetLayer(15) // over the GUI rects.
+	}
+	g.text.Cull(len(g.tix) == 0)
+	if len(g.tix) == 0 {
+		return
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(g.tvx, 2)  // vec2
+	md[load.Texcoords] = load.F32Buffer(g.tuv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(g.tix)
+	setMesh(g.app, rc, g.text, g.textMeshes[g.flip], md)
+}
+
+// scene returns the first 2D scene, creating one if needed.
+func (g *GUI) scene() *Entity {
+	scene := g.app.scenes.first(g.app, Scene2D)
+	if scene == nil {
+		scene = g.app.addScene(Scene2D)
+	}
+	return scene
+}
@@ -0,0 +1,106 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run GUI
+func TestGUI(t *testing.T) {
+	app := newApplication()
+	g := newGUI(app)
+	in := app.input
+
+	// frame runs one update of a tuning window with the given mouse input.
+	speed, lives, wire, resets := 5.0, 3, false, 0
+	frame := func(x, y int32, pressed, down, released bool) (open bool) {
+		in.Mx, in.My = x, y
+		clear(in.Pressed)
+		clear(in.Down)
+		clear(in.Released)
+		if pressed {
+			in.Pressed[KML] = true
+		}
+		if down {
+			in.Down[KML] = time.Time{}
+		}
+		if released {
+			in.Released[KML] = 0
+		}
+		if open = g.Begin("Tuning", 10, 10, 200); open {
+			g.Text("stats")
+			if g.Button("reset") {
+				resets++
+			}
+			g.Checkbox("wire", &wire)
+			g.SliderFloat("speed", &speed, 0, 10)
+			g.SliderInt("lives", &lives, 1, 5)
+		}
+		g.End()
+		g.frame()
+		return open
+	}
+	click := func(x, y int32) {
+		frame(x, y, true, true, false)
+		frame(x, y, false, false, true)
+	}
+
+	// rows are 24 pixels: 16 pixel lines with 4 pixels padding.
+	// title 10-34, text 38-62, reset 66-90, wire 94-118, speed 122-146, lives 150-174.
+	frame(0, 0, false, false, false)
+
+	// go test -run GUI/layout
+	t.Run("layout", func(t *testing.T) {
+		win := g.windows["Tuning"]
+		if win.h != 168 || len(g.last) != 1 {
+			t.Errorf("expected window height 168 got %d", win.h)
+		}
+		if g.WantsMouse() || g.over(100, 100) == nil || g.over(5, 5) != nil {
+			t.Errorf("expected mouse over window")
+		}
+	})
+
+	// go test -run GUI/widgets
+	t.Run("widgets", func(t *testing.T) {
+		click(50, 70)
+		if resets != 1 {
+			t.Errorf("expected button click got %d", resets)
+		}
+		frame(50, 70, true, true, false)
+		frame(50, 20, false, false, true) // released off the button.
+		if resets != 1 {
+			t.Errorf("expected no click when released elsewhere")
+		}
+		click(50, 100)
+		if !wire {
+			t.Errorf("expected checked box")
+		}
+		frame(14, 130, true, true, false) // left end of the slider.
+		frame(500, 130, false, true, false)
+		if speed != 10 || g.active != "Tuning/speed" {
+			t.Errorf("expected dragged slider at 10 got %f", speed)
+		}
+		frame(500, 130, false, false, true)
+		frame(110, 160, true, true, false) // middle of the int slider.
+		if lives != 3 {
+			t.Errorf("expected 3 lives got %d", lives)
+		}
+		frame(110, 160, false, false, true)
+	})
+
+	// go test -run GUI/window
+	t.Run("window", func(t *testing.T) {
+		frame(20, 20, true, true, false)
+		frame(120, 70, false, true, false) // drag the title bar.
+		frame(120, 70, false, false, true)
+		if win := g.windows["Tuning"]; win.x != 110 || win.y != 60 || win.collapsed {
+			t.Errorf("expected dragged window got %d %d", win.x, win.y)
+		}
+		click(120, 70)
+		if open := frame(0, 0, false, false, false); open || g.windows["Tuning"].h != 24 {
+			t.Errorf("expected collapsed window")
+		}
+	})
+}
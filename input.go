@@ -5,7 +5,8 @@ package vu
 // input.go wraps device package input as a convenience so the
 // device package does not always need to be included.
 //
-// FUTURE: Have the device package support text entry.
+// Typed text is available in Input.Text while text input
+// is on, see Engine.SetTextInput.
 
 import (
 	"github.com/gazed/vu/device"
@@ -23,8 +24,17 @@ type Input device.Input
 func (in *Input) Clone(b *device.Input) {
 	in.Mx = b.Mx
 	in.My = b.My
+	in.Dx = b.Dx
+	in.Dy = b.Dy
+	in.Scale = b.Scale
 	in.Focus = b.Focus
 	in.Scroll = b.Scroll
+	in.Pads = b.Pads
+	in.Text = b.Text
+	in.Composing = b.Composing
+	in.Dropped = append(in.Dropped[:0], b.Dropped...)
+	in.Touches = append(in.Touches[:0], b.Touches...)
+	in.Gesture = b.Gesture
 
 	// clear current keymaps
 	for key := range in.Pressed {
@@ -54,7 +64,7 @@ func (in *Input) Clone(b *device.Input) {
 // The symbol associated to each key is shown in the comments.
 //
 // Keys are expected to be used for controlling game actions.
-// There is no text entry or text layout support.
+// Use Input.Text for text entry, see Engine.SetTextInput.
 const (
 	K0      = device.K0      // 0 48     Standard keyboard numbers.
 	K1      = device.K1      // 1 49       "
@@ -163,3 +173,41 @@ const (
 	KCmd    = device.KCmd    // ◆ 9670     "
 	KAlt    = device.KAlt    // ◇ 9671     "
 )
+
+// Expose the device gamepad buttons as a convenience, see Input.Pads.
+// Buttons are bit flags that can be combined, eg: PadA|PadB.
+const (
+	PadUp     = device.PadUp     // directional pad.
+	PadDown   = device.PadDown   //   "
+	PadLeft   = device.PadLeft   //   "
+	PadRight  = device.PadRight  //   "
+	PadStart  = device.PadStart  // menu buttons.
+	PadBack   = device.PadBack   //   "
+	PadLStick = device.PadLStick // thumb sticks pressed.
+	PadRStick = device.PadRStick //   "
+	PadLB     = device.PadLB     // shoulder bumpers.
+	PadRB     = device.PadRB     //   "
+	PadA      = device.PadA      // face buttons.
+	PadB      = device.PadB      //   "
+	PadX      = device.PadX      //   "
+	PadY      = device.PadY      //   "
+)
+
+// Expose the device touch gestures, see Input.Gesture.
+const (
+	GestureNone      = device.GestureNone      // no gesture.
+	GestureTap       = device.GestureTap       // one finger touch and lift.
+	GestureLongPress = device.GestureLongPress // one finger held still.
+	GesturePan       = device.GesturePan       // one finger drag.
+	GesturePinch     = device.GesturePinch     // two finger zoom.
+)
+
+// CursorMode controls the mouse cursor, see Engine.SetCursorMode.
+type CursorMode = device.CursorMode
+
+// Expose the device cursor modes, see Engine.SetCursorMode.
+const (
+	CursorNormal   = device.CursorNormal   // visible and free to leave the window.
+	CursorHidden   = device.CursorHidden   // hidden while over the window.
+	CursorCaptured = device.CursorCaptured // hidden and held in the window.
+)
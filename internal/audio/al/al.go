@@ -14,6 +14,7 @@ package al
 
 import (
 	"fmt"
+	"math"
 	"syscall"
 	"unsafe"
 
@@ -120,6 +121,9 @@ var (
 	alcCaptureStart       *windows.LazyProc
 	alcCaptureStop        *windows.LazyProc
 	alcCaptureSamples     *windows.LazyProc
+
+	// ALC_SOFT_reopen_device extension, may not be available.
+	alcReopenDeviceSOFT *windows.LazyProc
 )
 
 // bind the methods to the function pointers
@@ -225,7 +229,11 @@ func Init() error {
 	alcCaptureCloseDevice = libopenal32.NewProc("alcCaptureCloseDevice")
 	alcCaptureStart = libopenal32.NewProc("alcCaptureStart")
 	alcCaptureStop = libopenal32.NewProc("alcCaptureStop")
-	alcCaptureSamples = libopenal32.NewProc("alcCaptureSamples ")
+	alcCaptureSamples = libopenal32.NewProc("alcCaptureSamples")
+
+	// extensions
+	alcReopenDeviceSOFT = libopenal32.NewProc("alcReopenDeviceSOFT")
+	initEFX()
 	return nil
 }
 
@@ -323,6 +331,11 @@ const (
 	C_CAPTURE_DEVICE_SPECIFIER         = 0x310
 	C_CAPTURE_DEFAULT_DEVICE_SPECIFIER = 0x311
 	C_CAPTURE_SAMPLES                  = 0x312
+
+	// ALC_ENUMERATE_ALL_EXT and ALC_EXT_disconnect extensions.
+	C_DEFAULT_ALL_DEVICES_SPECIFIER = 0x1012
+	C_ALL_DEVICES_SPECIFIER         = 0x1013
+	C_CONNECTED                     = 0x313
 )
 
 func UTF16PtrToString(s *uint16) string {
@@ -332,6 +345,11 @@ func UTF16PtrToString(s *uint16) string {
 // convert a uint boolean to a go bool
 func cbool(albool uint) bool { return albool == TRUE }
 
+// f32 passes a float argument as its bits. Windows expects float
+// arguments in the XMM registers and syscalls copy the integer
+// registers there, so converting the value would truncate it.
+func f32(value float32) uintptr { return uintptr(math.Float32bits(value)) }
+
 // Special type mappings. Note that the context and device are pointers
 // on Windows and Linux, but integers on OSX.
 type (
@@ -440,14 +458,14 @@ func GetEnumValue(ename string) int32 {
 func Listenerf(param int32, value float32) {
 	syscall.SyscallN(alListenerf.Addr(),
 		uintptr(param),
-		uintptr(value))
+		f32(value))
 }
 func Listener3f(param int32, value1, value2, value3 float32) {
 	syscall.SyscallN(alListener3f.Addr(),
 		uintptr(param),
-		uintptr(value1),
-		uintptr(value2),
-		uintptr(value3))
+		f32(value1),
+		f32(value2),
+		f32(value3))
 }
 func Listenerfv(param int32, values *float32) {
 	syscall.SyscallN(alListenerfv.Addr(),
@@ -524,15 +542,15 @@ func Sourcef(sid uint32, param int32, value float32) {
 	syscall.SyscallN(alSourcef.Addr(),
 		uintptr(sid),
 		uintptr(param),
-		uintptr(value))
+		f32(value))
 }
 func Source3f(sid uint32, param int32, value1, value2, value3 float32) {
 	syscall.SyscallN(alSource3f.Addr(),
 		uintptr(sid),
 		uintptr(param),
-		uintptr(value1),
-		uintptr(value2),
-		uintptr(value3))
+		f32(value1),
+		f32(value2),
+		f32(value3))
 }
 func Sourcefv(sid uint32, param int32, values *float32) {
 	syscall.SyscallN(alSourcefv.Addr(),
@@ -675,15 +693,15 @@ func Bufferf(bid uint32, param int32, value float32) {
 	syscall.SyscallN(alBufferf.Addr(),
 		uintptr(bid),
 		uintptr(param),
-		uintptr(value))
+		f32(value))
 }
 func Buffer3f(bid uint32, param int32, value1, value2, value3 float32) {
 	syscall.SyscallN(alBuffer3f.Addr(),
 		uintptr(bid),
 		uintptr(param),
-		uintptr(value1),
-		uintptr(value2),
-		uintptr(value3))
+		f32(value1),
+		f32(value2),
+		f32(value3))
 }
 func Bufferfv(bid uint32, param int32, values *float32) {
 	syscall.SyscallN(alBufferfv.Addr(),
@@ -753,15 +771,15 @@ func GetBufferiv(bid uint32, param int32, values *int32) {
 }
 func DopplerFactor(value float32) {
 	syscall.SyscallN(alDopplerFactor.Addr(),
-		uintptr(value))
+		f32(value))
 }
 func DopplerVelocity(value float32) {
 	syscall.SyscallN(alDopplerVelocity.Addr(),
-		uintptr(value))
+		f32(value))
 }
 func SpeedOfSound(value float32) {
 	syscall.SyscallN(alSpeedOfSound.Addr(),
-		uintptr(value))
+		f32(value))
 }
 func DistanceModel(distanceModel float32) {
 	syscall.SyscallN(alDistanceModel.Addr(),
@@ -808,14 +826,37 @@ func OpenDevice(devicename string) Device {
 			uintptr(0))
 		return Device(ret)
 	}
-	str16, err := syscall.UTF16PtrFromString(devicename)
+	str8, err := windows.BytePtrFromString(devicename) // ALCchar is char.
 	if err != nil {
 		return 0
 	}
 	ret, _, _ := syscall.SyscallN(alcOpenDevice.Addr(),
-		uintptr(unsafe.Pointer(str16)))
+		uintptr(unsafe.Pointer(str8)))
 	return Device(ret)
 }
+
+// ReopenDevice moves an open device to the named output device, or
+// to the default device for "", keeping the device contexts, sources,
+// and buffers. Returns false if the device could not be reopened
+// or if the ALC_SOFT_reopen_device extension is not available.
+func ReopenDevice(device Device, devicename string) bool {
+	if alcReopenDeviceSOFT.Find() != nil {
+		return false // extension not available.
+	}
+	var name uintptr // nil requests the default device.
+	if devicename != "" {
+		str8, err := windows.BytePtrFromString(devicename)
+		if err != nil {
+			return false
+		}
+		name = uintptr(unsafe.Pointer(str8))
+	}
+	ret, _, _ := syscall.SyscallN(alcReopenDeviceSOFT.Addr(),
+		uintptr(device),
+		name,
+		uintptr(0))
+	return ret == TRUE
+}
 func CloseDevice(device Device) bool {
 	ret, _, _ := syscall.SyscallN(alcCloseDevice.Addr(),
 		uintptr(device))
@@ -827,13 +868,13 @@ func GetDeviceError(device Device) int32 {
 	return int32(ret)
 }
 func IsDeviceExtensionPresent(device Device, extname string) bool {
-	str16, err := syscall.UTF16PtrFromString(extname)
+	str8, err := windows.BytePtrFromString(extname) // ALCchar is char.
 	if err != nil {
 		return false
 	}
 	ret, _, _ := syscall.SyscallN(alcIsExtensionPresent.Addr(),
 		uintptr(device),
-		uintptr(unsafe.Pointer(str16)))
+		uintptr(unsafe.Pointer(str8)))
 	return ret == TRUE
 }
 func GetDeviceProcAddress(device Device, fname string) Pointer {
@@ -857,14 +898,29 @@ func GetDeviceEnumValue(device Device, ename string) int32 {
 	return int32(ret)
 }
 
-//	func GetDeviceString(device Device, param int32) string {
-//		ret, _, _ := syscall.SyscallN(alcGetString.Addr(), 2,
-//			uintptr(device),
-//			uintptr(param),
-//			0)
-//		return ""
-//		// return C.GoString(alcGetString((C.uintptr_t)(device), C.int(param)))
-//	}
+// GetDeviceString returns the string for the given parameter.
+// Device can be 0 for parameters that do not need a device.
+func GetDeviceString(device Device, param int32) string {
+	ret, _, _ := syscall.SyscallN(alcGetString.Addr(),
+		uintptr(device),
+		uintptr(param))
+	return windows.BytePtrToString((*byte)(unsafe.Add(nil, ret)))
+}
+
+// GetDeviceStrings returns the strings for parameters, like
+// C_ALL_DEVICES_SPECIFIER, that return a list of strings.
+// The list is separated by nulls and ends with a double null.
+func GetDeviceStrings(device Device, param int32) (list []string) {
+	ret, _, _ := syscall.SyscallN(alcGetString.Addr(),
+		uintptr(device),
+		uintptr(param))
+	for ptr := (*byte)(unsafe.Add(nil, ret)); ptr != nil && *ptr != 0; {
+		str := windows.BytePtrToString(ptr)
+		list = append(list, str)
+		ptr = (*byte)(unsafe.Add(unsafe.Pointer(ptr), len(str)+1))
+	}
+	return list
+}
 func GetDeviceIntegerv(device Device, param int32, size int32, data *int32) {
 	syscall.SyscallN(alcGetIntegerv.Addr(),
 		uintptr(device),
@@ -872,13 +928,20 @@ func GetDeviceIntegerv(device Device, param int32, size int32, data *int32) {
 		uintptr(size),
 		uintptr(unsafe.Pointer(data)))
 }
+
+// CaptureOpenDevice opens the named input device, or the default
+// input device for "". The buffersize is in sample frames.
 func CaptureOpenDevice(devicename string, frequency uint32, format int32, buffersize int32) Device {
-	str16, err := syscall.UTF16PtrFromString(devicename)
-	if err != nil {
-		return 0
+	var name uintptr // nil requests the default device.
+	if devicename != "" {
+		str8, err := windows.BytePtrFromString(devicename) // ALCchar is char.
+		if err != nil {
+			return 0
+		}
+		name = uintptr(unsafe.Pointer(str8))
 	}
 	ret, _, _ := syscall.SyscallN(alcCaptureOpenDevice.Addr(),
-		uintptr(unsafe.Pointer(str16)),
+		name,
 		uintptr(frequency),
 		uintptr(format),
 		uintptr(buffersize))
@@ -0,0 +1,138 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+//go:build windows
+
+package al
+
+// efx.go binds the OpenAL effects extension, ALC_EXT_EFX, which
+// routes sources through filters and auxiliary effect slots.
+// Check IsDeviceExtensionPresent(device, "ALC_EXT_EFX") before use.
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	// Functions AL/efx.h
+	alGenEffects                 *windows.LazyProc
+	alDeleteEffects              *windows.LazyProc
+	alEffecti                    *windows.LazyProc
+	alEffectf                    *windows.LazyProc
+	alGenFilters                 *windows.LazyProc
+	alDeleteFilters              *windows.LazyProc
+	alFilteri                    *windows.LazyProc
+	alFilterf                    *windows.LazyProc
+	alGenAuxiliaryEffectSlots    *windows.LazyProc
+	alDeleteAuxiliaryEffectSlots *windows.LazyProc
+	alAuxiliaryEffectSloti       *windows.LazyProc
+	alAuxiliaryEffectSlotf       *windows.LazyProc
+)
+
+// initEFX binds the effect extension functions. Called from Init.
+func initEFX() {
+	alGenEffects = libopenal32.NewProc("alGenEffects")
+	alDeleteEffects = libopenal32.NewProc("alDeleteEffects")
+	alEffecti = libopenal32.NewProc("alEffecti")
+	alEffectf = libopenal32.NewProc("alEffectf")
+	alGenFilters = libopenal32.NewProc("alGenFilters")
+	alDeleteFilters = libopenal32.NewProc("alDeleteFilters")
+	alFilteri = libopenal32.NewProc("alFilteri")
+	alFilterf = libopenal32.NewProc("alFilterf")
+	alGenAuxiliaryEffectSlots = libopenal32.NewProc("alGenAuxiliaryEffectSlots")
+	alDeleteAuxiliaryEffectSlots = libopenal32.NewProc("alDeleteAuxiliaryEffectSlots")
+	alAuxiliaryEffectSloti = libopenal32.NewProc("alAuxiliaryEffectSloti")
+	alAuxiliaryEffectSlotf = libopenal32.NewProc("alAuxiliaryEffectSlotf")
+}
+
+// AL/efx.h constants (with AL_ removed). Refer to the original header for constant documentation.
+const (
+	DIRECT_FILTER          = 0x20005 // source property.
+	AUXILIARY_SEND_FILTER  = 0x20006 // source property.
+	EFFECTSLOT_NULL        = 0x0000
+	EFFECTSLOT_EFFECT      = 0x0001
+	EFFECTSLOT_GAIN        = 0x0002
+	EFFECT_NULL            = 0x0000
+	EFFECT_REVERB          = 0x0001
+	EFFECT_TYPE            = 0x8001
+	REVERB_DECAY_TIME      = 0x0005
+	FILTER_NULL            = 0x0000
+	FILTER_LOWPASS         = 0x0001
+	FILTER_TYPE            = 0x8001
+	LOWPASS_GAIN           = 0x0001
+	LOWPASS_GAINHF         = 0x0002
+	C_EFX_MAJOR_VERSION    = 0x20001
+	C_EFX_MINOR_VERSION    = 0x20002
+	C_MAX_AUXILIARY_SENDS  = 0x20003
+	C_EFX_EXTENSION_STRING = "ALC_EXT_EFX"
+)
+
+// AL/efx.h go bindings
+func GenEffects(n int32, effects *uint32) {
+	syscall.SyscallN(alGenEffects.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(effects)))
+}
+func DeleteEffects(n int32, effects *uint32) {
+	syscall.SyscallN(alDeleteEffects.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(effects)))
+}
+func Effecti(eid uint32, param int32, value int32) {
+	syscall.SyscallN(alEffecti.Addr(),
+		uintptr(eid),
+		uintptr(param),
+		uintptr(value))
+}
+func Effectf(eid uint32, param int32, value float32) {
+	syscall.SyscallN(alEffectf.Addr(),
+		uintptr(eid),
+		uintptr(param),
+		f32(value))
+}
+func GenFilters(n int32, filters *uint32) {
+	syscall.SyscallN(alGenFilters.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(filters)))
+}
+func DeleteFilters(n int32, filters *uint32) {
+	syscall.SyscallN(alDeleteFilters.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(filters)))
+}
+func Filteri(fid uint32, param int32, value int32) {
+	syscall.SyscallN(alFilteri.Addr(),
+		uintptr(fid),
+		uintptr(param),
+		uintptr(value))
+}
+func Filterf(fid uint32, param int32, value float32) {
+	syscall.SyscallN(alFilterf.Addr(),
+		uintptr(fid),
+		uintptr(param),
+		f32(value))
+}
+func GenAuxiliaryEffectSlots(n int32, slots *uint32) {
+	syscall.SyscallN(alGenAuxiliaryEffectSlots.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(slots)))
+}
+func DeleteAuxiliaryEffectSlots(n int32, slots *uint32) {
+	syscall.SyscallN(alDeleteAuxiliaryEffectSlots.Addr(),
+		uintptr(n),
+		uintptr(unsafe.Pointer(slots)))
+}
+func AuxiliaryEffectSloti(sid uint32, param int32, value int32) {
+	syscall.SyscallN(alAuxiliaryEffectSloti.Addr(),
+		uintptr(sid),
+		uintptr(param),
+		uintptr(value))
+}
+func AuxiliaryEffectSlotf(sid uint32, param int32, value float32) {
+	syscall.SyscallN(alAuxiliaryEffectSlotf.Addr(),
+		uintptr(sid),
+		uintptr(param),
+		f32(value))
+}
@@ -0,0 +1,165 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+//go:build windows
+
+package wasapi
+
+// capture.go records from the default input device, eg: a microphone.
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Capture records mono 16-bit samples from the default input device.
+type Capture struct {
+	rate    int           // samples per second.
+	mu      sync.Mutex    // guards samples.
+	samples []int16       // recorded samples not yet read.
+	quit    chan struct{} // closed to stop recording.
+	done    chan struct{} // closed once recording has stopped.
+}
+
+// OpenCapture starts recording from the default input device at
+// the given sample rate. Windows converts from the device format.
+func OpenCapture(rate int) (*Capture, error) {
+	if err := libole32.Load(); err != nil {
+		return nil, fmt.Errorf("wasapi: %w", err)
+	}
+	c := &Capture{rate: rate, quit: make(chan struct{}), done: make(chan struct{})}
+	opened := make(chan error)
+	go c.run(opened)
+	if err := <-opened; err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Read appends the samples recorded since the last Read to buf.
+func (c *Capture) Read(buf []int16) []int16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	buf = append(buf, c.samples...)
+	c.samples = c.samples[:0]
+	return buf
+}
+
+// Close stops recording and releases the input device.
+func (c *Capture) Close() {
+	close(c.quit)
+	<-c.done
+}
+
+// run owns the COM objects for the life of the capture.
+// Recording stops if the input device is disconnected.
+func (c *Capture) run(opened chan<- error) {
+	runtime.LockOSThread()
+	defer close(c.done)
+	if err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); err != nil {
+		opened <- fmt.Errorf("wasapi CoInitializeEx %w", err)
+		return
+	}
+	defer windows.CoUninitialize()
+	client, capture, err := c.open()
+	if err != nil {
+		opened <- err
+		return
+	}
+	defer client.release()
+	defer capture.release()
+	defer clientStop(client)
+	opened <- nil
+
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-tick.C:
+		}
+		if err := c.record(capture); err != nil {
+			return // wait for Close.
+		}
+	}
+}
+
+// open starts a shared mode capture stream on the default input device.
+func (c *Capture) open() (client, capture *comObject, err error) {
+	var enum *comObject
+	if hr := coCreate(&clsidMMDeviceEnumerator, &iidIMMDeviceEnumerator, &enum); failed(hr) {
+		return nil, nil, hresult("CoCreateInstance", hr)
+	}
+	defer enum.release()
+	endpoint, hr := defaultEndpoint(enum, eCapture)
+	if failed(hr) {
+		return nil, nil, hresult("GetDefaultAudioEndpoint", hr)
+	}
+	defer endpoint.release()
+	if hr := activate(endpoint, &iidIAudioClient, &client); failed(hr) {
+		return nil, nil, hresult("Activate", hr)
+	}
+	format := waveFormatEx{
+		FormatTag:      waveFormatPCM,
+		Channels:       1,
+		SamplesPerSec:  uint32(c.rate),
+		AvgBytesPerSec: uint32(c.rate * 2),
+		BlockAlign:     2,
+		BitsPerSample:  16,
+	}
+	buffer := int64(captureBuffer / 100) // in 100 nanosecond units.
+	if hr := clientInitialize(client, buffer, &format); failed(hr) {
+		client.release()
+		return nil, nil, hresult("Initialize", hr)
+	}
+	if hr := clientService(client, &iidIAudioCaptureClient, &capture); failed(hr) {
+		client.release()
+		return nil, nil, hresult("GetService", hr)
+	}
+	if hr := clientStart(client); failed(hr) {
+		capture.release()
+		client.release()
+		return nil, nil, hresult("Start", hr)
+	}
+	return client, capture, nil
+}
+
+// captureBuffer is the recording time held by the device.
+// Unread samples are kept for up to a second.
+const captureBuffer = 200 * time.Millisecond
+
+// record copies the recorded packets from the device.
+func (c *Capture) record(capture *comObject) error {
+	for {
+		var frames uint32
+		if hr := captureNextPacketSize(capture, &frames); failed(hr) {
+			return hresult("GetNextPacketSize", hr)
+		}
+		if frames == 0 {
+			return nil
+		}
+		var data *byte
+		var flags uint32
+		if hr := captureGetBuffer(capture, &data, &frames, &flags); failed(hr) {
+			return hresult("GetBuffer", hr)
+		}
+		c.mu.Lock()
+		if flags&bufferflagsSilent != 0 {
+			c.samples = append(c.samples, make([]int16, frames)...)
+		} else {
+			c.samples = append(c.samples, unsafe.Slice((*int16)(unsafe.Pointer(data)), frames)...)
+		}
+		if extra := len(c.samples) - c.rate; extra > 0 {
+			c.samples = append(c.samples[:0], c.samples[extra:]...) // drop the oldest.
+		}
+		c.mu.Unlock()
+		if hr := captureReleaseBuffer(capture, frames); failed(hr) {
+			return hresult("ReleaseBuffer", hr)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+//go:build windows
+
+// Package wasapi streams audio to the default Windows audio output
+// device, and records from the default input device, using the Windows
+// Audio Session API (WASAPI). It needs no libraries beyond those
+// installed with Windows. The samples are mixed by the caller, see Open.
+//
+// Package wasapi is provided as part of the vu (virtual universe) 3D engine.
+package wasapi
+
+// WASAPI: https://learn.microsoft.com/en-us/windows/win32/coreaudio/wasapi
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Stream plays audio samples on the default output device.
+// The stream follows the default device when it changes.
+type Stream struct {
+	fill    func(out []float32, rate int) // provides the samples.
+	quit    chan struct{}                 // closed to stop the stream.
+	done    chan struct{}                 // closed once the stream has stopped.
+	changed atomic.Bool                   // set when the output device changes.
+}
+
+// Open starts streaming to the default output device. The fill function
+// is called from the stream goroutine and must overwrite out with
+// interleaved stereo samples, -1 to 1, at the given sample rate.
+func Open(fill func(out []float32, rate int)) (*Stream, error) {
+	if err := libole32.Load(); err != nil {
+		return nil, fmt.Errorf("wasapi: %w", err)
+	}
+	s := &Stream{fill: fill, quit: make(chan struct{}), done: make(chan struct{})}
+	opened := make(chan error)
+	go s.run(opened)
+	if err := <-opened; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close stops the stream and releases the output device.
+func (s *Stream) Close() {
+	close(s.quit)
+	<-s.done
+}
+
+// Changed returns true once after the stream has been moved to
+// a different output device, eg: headphones were plugged in.
+func (s *Stream) Changed() bool { return s.changed.Swap(false) }
+
+// Stream timing. Samples are written ahead of playback by
+// the latency and the buffer is topped up every poll.
+const (
+	latency      = 30 * time.Millisecond // audio queued for playback.
+	pollInterval = 5 * time.Millisecond  // time between buffer updates.
+	checkDevice  = time.Second           // time between default device checks.
+)
+
+// run owns the COM objects for the life of the stream.
+// COM objects are used from the thread that created them.
+func (s *Stream) run(opened chan<- error) {
+	runtime.LockOSThread()
+	defer close(s.done)
+	if err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); err != nil {
+		opened <- fmt.Errorf("wasapi CoInitializeEx %w", err)
+		return
+	}
+	defer windows.CoUninitialize()
+	dev := &device{}
+	defer dev.release()
+	if err := dev.open(); err != nil {
+		opened <- err
+		return
+	}
+	opened <- nil
+
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	checked := time.Now()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-tick.C:
+		}
+		err := dev.write(s.fill)
+		if time.Since(checked) < checkDevice {
+			continue
+		}
+
+		// reopen devices that have been unplugged or
+		// when the default device has changed.
+		checked = time.Now()
+		if err != nil || dev.defaultChanged() {
+			dev.close()
+			if dev.open() == nil {
+				s.changed.Store(true)
+			}
+		}
+	}
+}
+
+// device is an open output device.
+type device struct {
+	enum   *comObject // IMMDeviceEnumerator
+	client *comObject // IAudioClient
+	render *comObject // IAudioRenderClient
+	id     string     // endpoint ID of the open device.
+	rate   int        // samples per second.
+	frames uint32     // buffer size in frames.
+}
+
+// open starts a shared mode stream on the default output device.
+// Samples are float32 stereo at the device mix rate. Windows
+// converts them to the device format.
+func (d *device) open() (err error) {
+	if d.enum == nil {
+		if hr := coCreate(&clsidMMDeviceEnumerator, &iidIMMDeviceEnumerator, &d.enum); failed(hr) {
+			return hresult("CoCreateInstance", hr)
+		}
+	}
+	endpoint, hr := d.defaultEndpoint()
+	if failed(hr) {
+		return hresult("GetDefaultAudioEndpoint", hr)
+	}
+	defer endpoint.release()
+	d.id = endpointID(endpoint)
+	defer func() {
+		if err != nil {
+			d.close()
+		}
+	}()
+	if hr := activate(endpoint, &iidIAudioClient, &d.client); failed(hr) {
+		return hresult("Activate", hr)
+	}
+	var mix *waveFormatEx
+	if hr := clientMixFormat(d.client, &mix); failed(hr) {
+		return hresult("GetMixFormat", hr)
+	}
+	d.rate = int(mix.SamplesPerSec)
+	windows.CoTaskMemFree(unsafe.Pointer(mix))
+	format := waveFormatEx{
+		FormatTag:      waveFormatIEEEFloat,
+		Channels:       2,
+		SamplesPerSec:  uint32(d.rate),
+		AvgBytesPerSec: uint32(d.rate * 8),
+		BlockAlign:     8,
+		BitsPerSample:  32,
+	}
+	buffer := int64(2 * latency / 100) // in 100 nanosecond units.
+	if hr := clientInitialize(d.client, buffer, &format); failed(hr) {
+		return hresult("Initialize", hr)
+	}
+	if hr := clientBufferSize(d.client, &d.frames); failed(hr) {
+		return hresult("GetBufferSize", hr)
+	}
+	if hr := clientService(d.client, &iidIAudioRenderClient, &d.render); failed(hr) {
+		return hresult("GetService", hr)
+	}
+	if hr := clientStart(d.client); failed(hr) {
+		return hresult("Start", hr)
+	}
+	return nil
+}
+
+// write tops up the device buffer with samples from fill.
+func (d *device) write(fill func(out []float32, rate int)) error {
+	if d.client == nil {
+		return fmt.Errorf("wasapi: no device")
+	}
+	var padding uint32 // frames queued for playback.
+	if hr := clientPadding(d.client, &padding); failed(hr) {
+		return hresult("GetCurrentPadding", hr)
+	}
+	target := min(uint32(d.rate*int(latency/time.Millisecond)/1000), d.frames)
+	if padding >= target {
+		return nil
+	}
+	frames := target - padding
+	var data *byte
+	if hr := renderGetBuffer(d.render, frames, &data); failed(hr) {
+		return hresult("GetBuffer", hr)
+	}
+	fill(unsafe.Slice((*float32)(unsafe.Pointer(data)), frames*2), d.rate)
+	if hr := renderReleaseBuffer(d.render, frames); failed(hr) {
+		return hresult("ReleaseBuffer", hr)
+	}
+	return nil
+}
+
+// defaultChanged returns true if the default output device
+// is no longer the open device.
+func (d *device) defaultChanged() bool {
+	endpoint, hr := d.defaultEndpoint()
+	if failed(hr) {
+		return false // keep the current device.
+	}
+	defer endpoint.release()
+	return endpointID(endpoint) != d.id
+}
+
+// defaultEndpoint returns the default output device. The caller
+// releases the device.
+func (d *device) defaultEndpoint() (endpoint *comObject, hr uintptr) {
+	return defaultEndpoint(d.enum, eRender)
+}
+
+// close stops the stream and releases the device.
+func (d *device) close() {
+	if d.client != nil {
+		clientStop(d.client)
+	}
+	if d.render != nil {
+		d.render.release()
+		d.render = nil
+	}
+	if d.client != nil {
+		d.client.release()
+		d.client = nil
+	}
+}
+
+// release closes the device and releases the device enumerator.
+func (d *device) release() {
+	d.close()
+	if d.enum != nil {
+		d.enum.release()
+		d.enum = nil
+	}
+}
+
+// =============================================================================
+// COM bindings.
+
+var (
+	libole32         = windows.NewLazySystemDLL("ole32.dll")
+	coCreateInstance = libole32.NewProc("CoCreateInstance")
+)
+
+// Class and interface identifiers.
+var (
+	clsidMMDeviceEnumerator = windows.GUID{Data1: 0xBCDE0395, Data2: 0xE52F, Data3: 0x467C,
+		Data4: [8]byte{0x8E, 0x3D, 0xC4, 0x57, 0x92, 0x91, 0x69, 0x2E}}
+	iidIMMDeviceEnumerator = windows.GUID{Data1: 0xA95664D2, Data2: 0x9614, Data3: 0x4F35,
+		Data4: [8]byte{0xA7, 0x46, 0xDE, 0x8D, 0xB6, 0x36, 0x17, 0xE6}}
+	iidIAudioClient = windows.GUID{Data1: 0x1CB9AD4C, Data2: 0xDBFA, Data3: 0x4C32,
+		Data4: [8]byte{0xB1, 0x78, 0xC2, 0xF5, 0x68, 0xA7, 0x03, 0xB2}}
+	iidIAudioRenderClient = windows.GUID{Data1: 0xF294ACFC, Data2: 0x3146, Data3: 0x4483,
+		Data4: [8]byte{0xA7, 0xBF, 0xAD, 0xDC, 0xA7, 0xC2, 0x60, 0xE2}}
+	iidIAudioCaptureClient = windows.GUID{Data1: 0xC8ADBD64, Data2: 0xE71E, Data3: 0x48A0,
+		Data4: [8]byte{0xA4, 0xDE, 0x18, 0x5C, 0x39, 0x5C, 0xD3, 0x17}}
+)
+
+// WASAPI constants.
+const (
+	clsctxAll                 = 0x17
+	eRender                   = 0
+	eCapture                  = 1
+	eConsole                  = 0
+	sharemodeShared           = 0
+	streamflagsAutoConvertPCM = 0x80000000
+	streamflagsSrcQuality     = 0x08000000
+	waveFormatPCM             = 1
+	waveFormatIEEEFloat       = 3
+	bufferflagsSilent         = 0x2
+)
+
+// Interface method indexes, after the IUnknown methods.
+const (
+	unknownRelease              = 2
+	enumGetDefaultAudioEndpoint = 4
+	deviceActivate              = 3
+	deviceGetId                 = 5
+	clientInitializeMethod      = 3
+	clientGetBufferSize         = 4
+	clientGetCurrentPadding     = 6
+	clientGetMixFormat          = 8
+	clientStartMethod           = 10
+	clientStopMethod            = 11
+	clientGetService            = 14
+	renderGetBufferMethod       = 3
+	renderReleaseBufferMethod   = 4
+	captureGetBufferMethod      = 3
+	captureReleaseBufferMethod  = 4
+	captureGetNextPacketSize    = 5
+)
+
+// comObject is the memory layout of a COM interface.
+type comObject struct {
+	vtbl *[16]uintptr // interface methods.
+}
+
+// release decrements the object reference count.
+func (o *comObject) release() {
+	syscall.SyscallN(o.vtbl[unknownRelease], uintptr(unsafe.Pointer(o)))
+}
+
+// waveFormatEx is the WAVEFORMATEX sample format.
+type waveFormatEx struct {
+	FormatTag      uint16
+	Channels       uint16
+	SamplesPerSec  uint32
+	AvgBytesPerSec uint32
+	BlockAlign     uint16
+	BitsPerSample  uint16
+	CbSize         uint16
+}
+
+// failed returns true for HRESULT error codes.
+func failed(hr uintptr) bool { return int32(hr) < 0 }
+
+// hresult wraps a failed HRESULT as an error.
+func hresult(method string, hr uintptr) error {
+	return fmt.Errorf("wasapi %s: %w", method, syscall.Errno(uint32(hr)))
+}
+
+func coCreate(clsid, iid *windows.GUID, obj **comObject) uintptr {
+	hr, _, _ := syscall.SyscallN(coCreateInstance.Addr(),
+		uintptr(unsafe.Pointer(clsid)),
+		0,
+		clsctxAll,
+		uintptr(unsafe.Pointer(iid)),
+		uintptr(unsafe.Pointer(obj)))
+	return hr
+}
+
+// defaultEndpoint returns the default device for the data flow,
+// eRender or eCapture. The caller releases the device.
+func defaultEndpoint(enum *comObject, flow uintptr) (endpoint *comObject, hr uintptr) {
+	hr, _, _ = syscall.SyscallN(enum.vtbl[enumGetDefaultAudioEndpoint],
+		uintptr(unsafe.Pointer(enum)),
+		flow,
+		eConsole,
+		uintptr(unsafe.Pointer(&endpoint)))
+	return endpoint, hr
+}
+
+func activate(endpoint *comObject, iid *windows.GUID, obj **comObject) uintptr {
+	hr, _, _ := syscall.SyscallN(endpoint.vtbl[deviceActivate],
+		uintptr(unsafe.Pointer(endpoint)),
+		uintptr(unsafe.Pointer(iid)),
+		clsctxAll,
+		0,
+		uintptr(unsafe.Pointer(obj)))
+	return hr
+}
+
+// endpointID returns the unique ID for the device, or "" if unknown.
+func endpointID(endpoint *comObject) string {
+	var id *uint16
+	hr, _, _ := syscall.SyscallN(endpoint.vtbl[deviceGetId],
+		uintptr(unsafe.Pointer(endpoint)),
+		uintptr(unsafe.Pointer(&id)))
+	if failed(hr) || id == nil {
+		return ""
+	}
+	defer windows.CoTaskMemFree(unsafe.Pointer(id))
+	return windows.UTF16PtrToString(id)
+}
+
+func clientInitialize(client *comObject, buffer int64, format *waveFormatEx) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientInitializeMethod],
+		uintptr(unsafe.Pointer(client)),
+		sharemodeShared,
+		streamflagsAutoConvertPCM|streamflagsSrcQuality,
+		uintptr(buffer),
+		0,
+		uintptr(unsafe.Pointer(format)),
+		0)
+	return hr
+}
+
+func clientBufferSize(client *comObject, frames *uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientGetBufferSize],
+		uintptr(unsafe.Pointer(client)),
+		uintptr(unsafe.Pointer(frames)))
+	return hr
+}
+
+func clientPadding(client *comObject, frames *uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientGetCurrentPadding],
+		uintptr(unsafe.Pointer(client)),
+		uintptr(unsafe.Pointer(frames)))
+	return hr
+}
+
+func clientMixFormat(client *comObject, format **waveFormatEx) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientGetMixFormat],
+		uintptr(unsafe.Pointer(client)),
+		uintptr(unsafe.Pointer(format)))
+	return hr
+}
+
+func clientStart(client *comObject) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientStartMethod],
+		uintptr(unsafe.Pointer(client)))
+	return hr
+}
+
+func clientStop(client *comObject) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientStopMethod],
+		uintptr(unsafe.Pointer(client)))
+	return hr
+}
+
+func clientService(client *comObject, iid *windows.GUID, obj **comObject) uintptr {
+	hr, _, _ := syscall.SyscallN(client.vtbl[clientGetService],
+		uintptr(unsafe.Pointer(client)),
+		uintptr(unsafe.Pointer(iid)),
+		uintptr(unsafe.Pointer(obj)))
+	return hr
+}
+
+func renderGetBuffer(render *comObject, frames uint32, data **byte) uintptr {
+	hr, _, _ := syscall.SyscallN(render.vtbl[renderGetBufferMethod],
+		uintptr(unsafe.Pointer(render)),
+		uintptr(frames),
+		uintptr(unsafe.Pointer(data)))
+	return hr
+}
+
+func renderReleaseBuffer(render *comObject, frames uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(render.vtbl[renderReleaseBufferMethod],
+		uintptr(unsafe.Pointer(render)),
+		uintptr(frames),
+		0)
+	return hr
+}
+
+func captureGetBuffer(capture *comObject, data **byte, frames, flags *uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(capture.vtbl[captureGetBufferMethod],
+		uintptr(unsafe.Pointer(capture)),
+		uintptr(unsafe.Pointer(data)),
+		uintptr(unsafe.Pointer(frames)),
+		uintptr(unsafe.Pointer(flags)),
+		0,
+		0)
+	return hr
+}
+
+func captureReleaseBuffer(capture *comObject, frames uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(capture.vtbl[captureReleaseBufferMethod],
+		uintptr(unsafe.Pointer(capture)),
+		uintptr(frames))
+	return hr
+}
+
+func captureNextPacketSize(capture *comObject, frames *uint32) uintptr {
+	hr, _, _ := syscall.SyscallN(capture.vtbl[captureGetNextPacketSize],
+		uintptr(unsafe.Pointer(capture)),
+		uintptr(unsafe.Pointer(frames)))
+	return hr
+}
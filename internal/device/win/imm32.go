@@ -0,0 +1,93 @@
+// Copyright 2010 The win Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package win
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Input method editor window messages
+const (
+	WM_IME_STARTCOMPOSITION = 0x010D
+	WM_IME_ENDCOMPOSITION   = 0x010E
+	WM_IME_COMPOSITION      = 0x010F
+)
+
+// ImmGetCompositionString index constants
+const (
+	GCS_COMPSTR   = 0x0008
+	GCS_CURSORPOS = 0x0080
+	GCS_RESULTSTR = 0x0800
+)
+
+type HIMC HANDLE
+
+var (
+	// Library
+	libimm32 *windows.LazyDLL
+
+	// Functions
+	immAssociateContext     *windows.LazyProc
+	immGetCompositionString *windows.LazyProc
+	immGetContext           *windows.LazyProc
+	immReleaseContext       *windows.LazyProc
+)
+
+func init() {
+	// Library
+	libimm32 = windows.NewLazySystemDLL("imm32.dll")
+
+	// Functions
+	immAssociateContext = libimm32.NewProc("ImmAssociateContext")
+	immGetCompositionString = libimm32.NewProc("ImmGetCompositionStringW")
+	immGetContext = libimm32.NewProc("ImmGetContext")
+	immReleaseContext = libimm32.NewProc("ImmReleaseContext")
+}
+
+func ImmAssociateContext(hWnd HWND, hIMC HIMC) HIMC {
+	ret, _, _ := syscall.Syscall(immAssociateContext.Addr(), 2,
+		uintptr(hWnd),
+		uintptr(hIMC),
+		0)
+
+	return HIMC(ret)
+}
+
+// ImmGetCompositionString returns the size of the requested string
+// in bytes, or a negative error code.
+func ImmGetCompositionString(hIMC HIMC, dwIndex uint32, lpBuf unsafe.Pointer, dwBufLen uint32) int32 {
+	ret, _, _ := syscall.Syscall6(immGetCompositionString.Addr(), 4,
+		uintptr(hIMC),
+		uintptr(dwIndex),
+		uintptr(lpBuf),
+		uintptr(dwBufLen),
+		0,
+		0)
+
+	return int32(ret)
+}
+
+func ImmGetContext(hWnd HWND) HIMC {
+	ret, _, _ := syscall.Syscall(immGetContext.Addr(), 1,
+		uintptr(hWnd),
+		0,
+		0)
+
+	return HIMC(ret)
+}
+
+func ImmReleaseContext(hWnd HWND, hIMC HIMC) bool {
+	ret, _, _ := syscall.Syscall(immReleaseContext.Addr(), 2,
+		uintptr(hWnd),
+		uintptr(hIMC),
+		0)
+
+	return ret != 0
+}
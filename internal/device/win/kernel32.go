@@ -93,6 +93,7 @@ var (
 	queryPerformanceCounter   *windows.LazyProc
 	queryPerformanceFrequency *windows.LazyProc
 	sleep                     *windows.LazyProc
+	getProcessHandleCount     *windows.LazyProc
 )
 
 type (
@@ -184,6 +185,7 @@ func init() {
 	queryPerformanceCounter = libkernel32.NewProc("QueryPerformanceCounter")
 	queryPerformanceFrequency = libkernel32.NewProc("QueryPerformanceFrequency")
 	sleep = libkernel32.NewProc("Sleep")
+	getProcessHandleCount = libkernel32.NewProc("GetProcessHandleCount")
 }
 
 func ActivateActCtx(ctx HANDLE) (uintptr, bool) {
@@ -485,3 +487,12 @@ func Sleep(milliseconds uint32) {
 		0,
 		0)
 }
+
+func GetProcessHandleCount(hProcess HANDLE, pdwHandleCount *uint32) bool {
+	ret, _, _ := syscall.Syscall(getProcessHandleCount.Addr(), 2,
+		uintptr(hProcess),
+		uintptr(unsafe.Pointer(pdwHandleCount)),
+		0)
+
+	return ret != 0
+}
@@ -0,0 +1,64 @@
+// Copyright 2010 The win Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package win
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type HDROP HANDLE
+
+var (
+	// Library
+	libshell32 *windows.LazyDLL
+
+	// Functions
+	dragAcceptFiles *windows.LazyProc
+	dragFinish      *windows.LazyProc
+	dragQueryFile   *windows.LazyProc
+)
+
+func init() {
+	// Library
+	libshell32 = windows.NewLazySystemDLL("shell32.dll")
+
+	// Functions
+	dragAcceptFiles = libshell32.NewProc("DragAcceptFiles")
+	dragFinish = libshell32.NewProc("DragFinish")
+	dragQueryFile = libshell32.NewProc("DragQueryFileW")
+}
+
+func DragAcceptFiles(hWnd HWND, fAccept bool) bool {
+	ret, _, _ := syscall.Syscall(dragAcceptFiles.Addr(), 2,
+		uintptr(hWnd),
+		uintptr(BoolToBOOL(fAccept)),
+		0)
+
+	return ret != 0
+}
+
+func DragQueryFile(hDrop HDROP, iFile uint32, lpszFile *uint16, cch uint32) uint32 {
+	ret, _, _ := syscall.Syscall6(dragQueryFile.Addr(), 4,
+		uintptr(hDrop),
+		uintptr(iFile),
+		uintptr(unsafe.Pointer(lpszFile)),
+		uintptr(cch),
+		0,
+		0)
+
+	return uint32(ret)
+}
+
+func DragFinish(hDrop HDROP) {
+	syscall.Syscall(dragFinish.Addr(), 1,
+		uintptr(hDrop),
+		0,
+		0)
+}
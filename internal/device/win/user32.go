@@ -810,6 +810,9 @@ const (
 	WM_NOTIFYFORMAT           = 85
 	WM_NULL                   = 0
 	WM_PAINT                  = 15
+	WM_POINTERUPDATE          = 0x0245
+	WM_POINTERDOWN            = 0x0246
+	WM_POINTERUP              = 0x0247
 	WM_PAINTCLIPBOARD         = 777
 	WM_PAINTICON              = 38
 	WM_PALETTECHANGED         = 785
@@ -1480,6 +1483,27 @@ type (
 	HWND      HANDLE
 )
 
+// GetPointerType values
+const (
+	PT_POINTER  = 1
+	PT_TOUCH    = 2
+	PT_PEN      = 3
+	PT_MOUSE    = 4
+	PT_TOUCHPAD = 5
+)
+
+// DPI_AWARENESS_CONTEXT values are pseudo handles.
+type DPI_AWARENESS_CONTEXT HANDLE
+
+// SetProcessDpiAwarenessContext values
+const (
+	DPI_AWARENESS_CONTEXT_UNAWARE              = ^DPI_AWARENESS_CONTEXT(0) // -1
+	DPI_AWARENESS_CONTEXT_SYSTEM_AWARE         = ^DPI_AWARENESS_CONTEXT(1) // -2
+	DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE    = ^DPI_AWARENESS_CONTEXT(2) // -3
+	DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 = ^DPI_AWARENESS_CONTEXT(3) // -4
+	USER_DEFAULT_SCREEN_DPI                    = 96                        // 100% scaling
+)
+
 type MSG struct {
 	HWnd    HWND
 	Message uint32
@@ -1768,6 +1792,7 @@ var (
 	changeWindowMessageFilterEx *windows.LazyProc
 	checkMenuRadioItem          *windows.LazyProc
 	clientToScreen              *windows.LazyProc
+	clipCursor                  *windows.LazyProc
 	closeClipboard              *windows.LazyProc
 	createDialogParam           *windows.LazyProc
 	createIconIndirect          *windows.LazyProc
@@ -1817,6 +1842,7 @@ var (
 	getMessage                  *windows.LazyProc
 	getMonitorInfo              *windows.LazyProc
 	getParent                   *windows.LazyProc
+	getPointerType              *windows.LazyProc
 	getRawInputData             *windows.LazyProc
 	getScrollInfo               *windows.LazyProc
 	getSubMenu                  *windows.LazyProc
@@ -1880,6 +1906,8 @@ var (
 	setMenuItemBitmaps          *windows.LazyProc
 	setMenuItemInfo             *windows.LazyProc
 	setParent                   *windows.LazyProc
+	setProcessDPIAware          *windows.LazyProc
+	setProcessDpiAwarenessCtx   *windows.LazyProc
 	setRect                     *windows.LazyProc
 	setScrollInfo               *windows.LazyProc
 	setTimer                    *windows.LazyProc
@@ -1919,6 +1947,7 @@ func init() {
 	changeWindowMessageFilterEx = libuser32.NewProc("ChangeWindowMessageFilterEx")
 	checkMenuRadioItem = libuser32.NewProc("CheckMenuRadioItem")
 	clientToScreen = libuser32.NewProc("ClientToScreen")
+	clipCursor = libuser32.NewProc("ClipCursor")
 	closeClipboard = libuser32.NewProc("CloseClipboard")
 	createDialogParam = libuser32.NewProc("CreateDialogParamW")
 	createIconIndirect = libuser32.NewProc("CreateIconIndirect")
@@ -1968,6 +1997,7 @@ func init() {
 	getMessage = libuser32.NewProc("GetMessageW")
 	getMonitorInfo = libuser32.NewProc("GetMonitorInfoW")
 	getParent = libuser32.NewProc("GetParent")
+	getPointerType = libuser32.NewProc("GetPointerType")
 	getRawInputData = libuser32.NewProc("GetRawInputData")
 	getScrollInfo = libuser32.NewProc("GetScrollInfo")
 	getSubMenu = libuser32.NewProc("GetSubMenu")
@@ -2037,6 +2067,8 @@ func init() {
 	setMenuItemInfo = libuser32.NewProc("SetMenuItemInfoW")
 	setRect = libuser32.NewProc("SetRect")
 	setParent = libuser32.NewProc("SetParent")
+	setProcessDPIAware = libuser32.NewProc("SetProcessDPIAware")
+	setProcessDpiAwarenessCtx = libuser32.NewProc("SetProcessDpiAwarenessContext")
 	setScrollInfo = libuser32.NewProc("SetScrollInfo")
 	setTimer = libuser32.NewProc("SetTimer")
 	setWinEventHook = libuser32.NewProc("SetWinEventHook")
@@ -2192,6 +2224,17 @@ func ClientToScreen(hwnd HWND, lpPoint *POINT) bool {
 	return ret != 0
 }
 
+// ClipCursor confines the cursor to the given screen rectangle.
+// A nil rectangle lets the cursor move anywhere.
+func ClipCursor(lpRect *RECT) bool {
+	ret, _, _ := syscall.Syscall(clipCursor.Addr(), 1,
+		uintptr(unsafe.Pointer(lpRect)),
+		0,
+		0)
+
+	return ret != 0
+}
+
 func CloseClipboard() bool {
 	ret, _, _ := syscall.Syscall(closeClipboard.Addr(), 0,
 		0,
@@ -2682,6 +2725,21 @@ func GetParent(hWnd HWND) HWND {
 	return HWND(ret)
 }
 
+// GetPointerType returns false on versions of windows before 8
+// which do not send pointer messages.
+func GetPointerType(pointerId uint32, pointerType *uint32) bool {
+	if getPointerType.Find() != nil {
+		return false
+	}
+
+	ret, _, _ := syscall.Syscall(getPointerType.Addr(), 2,
+		uintptr(pointerId),
+		uintptr(unsafe.Pointer(pointerType)),
+		0)
+
+	return ret != 0
+}
+
 func GetRawInputData(hRawInput HRAWINPUT, uiCommand uint32, pData unsafe.Pointer, pcbSize *uint32, cBSizeHeader uint32) uint32 {
 	ret, _, _ := syscall.Syscall6(getRawInputData.Addr(), 5,
 		uintptr(hRawInput),
@@ -3316,6 +3374,28 @@ func SetParent(hWnd HWND, parentHWnd HWND) HWND {
 	return HWND(ret)
 }
 
+// SetProcessDpiAwarenessContext falls back to SetProcessDPIAware
+// on versions of windows before Windows 10 1703.
+func SetProcessDpiAwarenessContext(value DPI_AWARENESS_CONTEXT) bool {
+	if setProcessDpiAwarenessCtx.Find() != nil {
+		if setProcessDPIAware.Find() != nil {
+			return false
+		}
+		ret, _, _ := syscall.Syscall(setProcessDPIAware.Addr(), 0,
+			0,
+			0,
+			0)
+		return ret != 0
+	}
+
+	ret, _, _ := syscall.Syscall(setProcessDpiAwarenessCtx.Addr(), 1,
+		uintptr(value),
+		0,
+		0)
+
+	return ret != 0
+}
+
 func SetRect(lprc *RECT, xLeft, yTop, xRight, yBottom uint32) BOOL {
 	ret, _, _ := syscall.Syscall6(setRect.Addr(), 5,
 		uintptr(unsafe.Pointer(lprc)),
@@ -420,10 +420,7 @@ func CmdBeginDebugUtilsLabelEXT(commandBuffer CommandBuffer, labelInfo *DebugUti
 		pLabelInfo = labelInfo.Vulkanize()
 	}
 
-	if vkCmdBeginDebugUtilsLabelEXT.fnHandle == nil {
-		vkCmdBeginDebugUtilsLabelEXT.fnHandle = dlHandle.NewProc("vkCmdBeginDebugUtilsLabelEXT")
-	}
-	syscall.SyscallN(vkCmdBeginDebugUtilsLabelEXT.fnHandle.Addr(), uintptr(commandBuffer), uintptr(unsafe.Pointer(pLabelInfo)))
+	syscall.SyscallN(vkCmdBeginDebugUtilsLabelEXT.addr(), uintptr(commandBuffer), uintptr(unsafe.Pointer(pLabelInfo)))
 
 }
 
@@ -1427,10 +1424,7 @@ var vkCmdEndConditionalRenderingEXT = &vkCommand{"vkCmdEndConditionalRenderingEX
 // CmdEndDebugUtilsLabelEXT: See https://www.khronos.org/registry/vulkan/specs/1.3-extensions/man/html/vkCmdEndDebugUtilsLabelEXT.html
 func CmdEndDebugUtilsLabelEXT(commandBuffer CommandBuffer) {
 
-	if vkCmdEndDebugUtilsLabelEXT.fnHandle == nil {
-		vkCmdEndDebugUtilsLabelEXT.fnHandle = dlHandle.NewProc("vkCmdEndDebugUtilsLabelEXT")
-	}
-	syscall.SyscallN(vkCmdEndDebugUtilsLabelEXT.fnHandle.Addr(), uintptr(commandBuffer))
+	syscall.SyscallN(vkCmdEndDebugUtilsLabelEXT.addr(), uintptr(commandBuffer))
 
 }
 
@@ -8810,10 +8804,7 @@ func SetDebugUtilsObjectNameEXT(device Device, nameInfo *DebugUtilsObjectNameInf
 
 	var rsys uintptr
 
-	if vkSetDebugUtilsObjectNameEXT.fnHandle == nil {
-		vkSetDebugUtilsObjectNameEXT.fnHandle = dlHandle.NewProc("vkSetDebugUtilsObjectNameEXT")
-	}
-	rsys, _, _ = syscall.SyscallN(vkSetDebugUtilsObjectNameEXT.fnHandle.Addr(), uintptr(device), uintptr(unsafe.Pointer(pNameInfo)))
+	rsys, _, _ = syscall.SyscallN(vkSetDebugUtilsObjectNameEXT.addr(), uintptr(device), uintptr(unsafe.Pointer(pNameInfo)))
 	r = Result(rsys)
 
 	if r == Result(0) {
@@ -49,6 +49,37 @@ func init() {
 	dlHandle = windows.NewLazyDLL("vulkan-1.dll")
 }
 
+// instanceProcs are the command addresses found by LoadInstanceCommands.
+var instanceProcs = map[string]uintptr{}
+
+// LoadInstanceCommands looks up the named extension commands using
+// vkGetInstanceProcAddr, since loaders do not have to export extension
+// commands. Returns false if any of the commands are not available.
+func LoadInstanceCommands(instance Instance, names ...string) bool {
+	found := true
+	for _, name := range names {
+		fn := GetInstanceProcAddr(instance, name)
+		if fn == nil {
+			found = false
+			continue
+		}
+		instanceProcs[name] = uintptr(fn)
+	}
+	return found
+}
+
+// addr returns the command address, preferring the address
+// from LoadInstanceCommands over the library export.
+func (c *vkCommand) addr() uintptr {
+	if proc, ok := instanceProcs[c.protoName]; ok {
+		return proc
+	}
+	if c.fnHandle == nil {
+		c.fnHandle = dlHandle.NewProc(c.protoName)
+	}
+	return c.fnHandle.Addr()
+}
+
 var overrideLibName string
 
 // OverrideDefaultVulkanLibrary allows you to set a specific Vulkan library name to be used in your program. For
@@ -0,0 +1,126 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package theora
+
+import "errors"
+
+// bits reads Theora packets most significant bit first.
+// Reading past the end of the packet returns zero bits.
+type bits struct {
+	data []byte
+	pos  int    // next byte to load.
+	win  uint64 // loaded bits, most significant first.
+	n    uint   // number of loaded bits.
+}
+
+// read returns the next n bits, where n is at most 32.
+func (b *bits) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	if b.n < n {
+		for b.n <= 56 {
+			if b.pos < len(b.data) {
+				b.win |= uint64(b.data[b.pos]) << (56 - b.n)
+			}
+			b.pos++
+			b.n += 8
+		}
+	}
+	v := uint32(b.win >> (64 - n))
+	b.win <<= n
+	b.n -= n
+	return v
+}
+
+// overrun returns true if more bits were read than the packet holds.
+func (b *bits) overrun() bool { return 8*(b.pos-len(b.data)) > int(b.n) }
+
+// runLengths are the run length codes for the long
+// and short run bit strings, see spec 7.2.
+var longRuns = [7][2]uint32{{1, 0}, {2, 1}, {4, 1}, {6, 2}, {10, 3}, {18, 4}, {34, 12}}
+var shortRuns = [6][2]uint32{{1, 1}, {3, 1}, {5, 1}, {7, 2}, {11, 2}, {15, 4}}
+
+// runs decodes a run length encoded bit string into dst. Long runs
+// are used for the superblock flags and the block quality indexes,
+// short runs for the block coded flags.
+func (b *bits) runs(dst []uint8, long bool) error {
+	if len(dst) == 0 {
+		return nil
+	}
+	codes := shortRuns[:]
+	if long {
+		codes = longRuns[:]
+	}
+	bit := uint8(b.read(1))
+	for n := 0; ; {
+		i := 0
+		for i < len(codes)-1 && b.read(1) == 1 {
+			i++
+		}
+		rlen := int(codes[i][0] + b.read(uint(codes[i][1])))
+		if n+rlen > len(dst) {
+			return errors.New("theora: bit string overrun")
+		}
+		for end := n + rlen; n < end; n++ {
+			dst[n] = bit
+		}
+		if n == len(dst) {
+			return nil
+		}
+		if long && rlen == 4129 {
+			bit = uint8(b.read(1)) // longest runs are not toggled.
+		} else {
+			bit ^= 1
+		}
+	}
+}
+
+// huffman is a DCT token Huffman tree. Nodes hold the next node
+// for each bit, or the token as -1-token.
+type huffman struct {
+	root  int16
+	nodes [][2]int16
+}
+
+// read reads the tree from the setup header, see spec 6.4.4
+func (h *huffman) read(b *bits) error {
+	leaves := 0
+	var branch func(depth int) (int16, error)
+	branch = func(depth int) (int16, error) {
+		if depth > 32 {
+			return 0, errors.New("theora: huffman code too long")
+		}
+		if b.read(1) == 1 {
+			if leaves++; leaves > 32 {
+				return 0, errors.New("theora: too many huffman codes")
+			}
+			return -1 - int16(b.read(5)), nil
+		}
+		if b.overrun() {
+			return 0, errors.New("theora: short huffman table")
+		}
+		i := len(h.nodes)
+		h.nodes = append(h.nodes, [2]int16{})
+		for bit := range h.nodes[i] {
+			next, err := branch(depth + 1)
+			if err != nil {
+				return 0, err
+			}
+			h.nodes[i][bit] = next
+		}
+		return int16(i), nil
+	}
+	var err error
+	h.root, err = branch(0)
+	return err
+}
+
+// decode returns the next token.
+func (h *huffman) decode(b *bits) int {
+	n := h.root
+	for n >= 0 {
+		n = h.nodes[n][b.read(1)]
+	}
+	return int(-1 - n)
+}
@@ -0,0 +1,446 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package theora
+
+import "errors"
+
+// decode decodes a frame packet into a new current frame, see spec 7.
+// The current frame becomes the previous frame once decoded.
+func (d *Decoder) decode(data []byte) error {
+	b := &bits{data: data}
+	if b.read(1) != 0 {
+		return errors.New("theora: not a frame")
+	}
+	intra := b.read(1) == 0
+	d.qis[0], d.nqis = int32(b.read(6)), 1
+	for d.nqis < 3 && b.read(1) == 1 {
+		d.qis[d.nqis] = int32(b.read(6))
+		d.nqis++
+	}
+	if intra {
+		b.read(3) // reserved.
+	} else if d.prev < 0 {
+		return errors.New("theora: no key frame")
+	}
+
+	// decode into a frame that is not referenced.
+	cur := 0
+	for cur == d.golden || cur == d.prev {
+		cur++
+	}
+	if err := d.codedBlocks(b, intra); err != nil {
+		return err
+	}
+	if err := d.modes(b, intra); err != nil {
+		return err
+	}
+	if !intra {
+		d.motionVectors(b)
+	}
+	if err := d.blockQuality(b); err != nil {
+		return err
+	}
+	if err := d.tokens(b); err != nil {
+		return err
+	}
+	if b.overrun() {
+		return errors.New("theora: short frame")
+	}
+	d.predictDC()
+	if !intra {
+		for pli := range d.frames[cur] {
+			copy(d.frames[cur][pli], d.frames[d.prev][pli]) // for uncoded blocks.
+		}
+	}
+	d.reconstruct(cur)
+	d.loopFilter(cur)
+	d.fillBorders(cur)
+	d.prev = cur
+	if intra {
+		d.golden = cur
+	}
+	return nil
+}
+
+// codedBlocks decodes which blocks are coded, see spec 7.3.
+// Updates the blocks in coded order.
+func (d *Decoder) codedBlocks(b *bits, intra bool) error {
+	d.order = d.order[:0]
+	if intra {
+		for _, blk := range d.sbs {
+			d.coded[blk] = true
+			d.order = append(d.order, blk)
+		}
+		return nil
+	}
+
+	// superblocks are partially coded, fully coded, or not coded.
+	nsbs := len(d.sbEnd)
+	partial := make([]uint8, nsbs)
+	if err := b.runs(partial, true); err != nil {
+		return err
+	}
+	nfull, nblks, start := 0, 0, int32(0)
+	for sbi, end := range d.sbEnd {
+		if partial[sbi] == 0 {
+			nfull++
+		} else {
+			nblks += int(end - start)
+		}
+		start = end
+	}
+	full := d.runs[:nfull]
+	if err := b.runs(full, true); err != nil {
+		return err
+	}
+	flags := make([]uint8, nblks)
+	if err := b.runs(flags, false); err != nil {
+		return err
+	}
+	start = 0
+	for sbi, end := range d.sbEnd {
+		for _, blk := range d.sbs[start:end] {
+			var coded bool
+			if partial[sbi] == 1 {
+				coded, flags = flags[0] == 1, flags[1:]
+			} else {
+				coded = full[0] == 1
+			}
+			d.coded[blk] = coded
+			if coded {
+				d.order = append(d.order, blk)
+			}
+		}
+		if partial[sbi] == 0 {
+			full = full[1:]
+		}
+		start = end
+	}
+	return nil
+}
+
+// modeAlphabets are the predefined macroblock mode codes, see spec 7.4.
+var modeAlphabets = [6][8]uint8{
+	{3, 4, 2, 0, 1, 5, 6, 7},
+	{3, 4, 0, 2, 1, 5, 6, 7},
+	{3, 2, 4, 0, 1, 5, 6, 7},
+	{3, 2, 0, 4, 1, 5, 6, 7},
+	{0, 3, 4, 2, 1, 5, 6, 7},
+	{0, 5, 3, 4, 2, 1, 6, 7},
+}
+
+// modes decodes the macroblock coding modes, see spec 7.4.
+func (d *Decoder) modes(b *bits, intra bool) error {
+	if intra {
+		for i := range d.mbs {
+			d.mbs[i].mode = modeIntra
+		}
+		return nil
+	}
+	scheme := b.read(3)
+	var alphabet [8]uint8
+	switch {
+	case scheme == 0:
+		for mode := uint8(0); mode < 8; mode++ {
+			alphabet[b.read(3)] = mode
+		}
+	case scheme < 7:
+		alphabet = modeAlphabets[scheme-1]
+	}
+	for i := range d.mbs {
+		mb := &d.mbs[i]
+		mb.mode = modeInterNoMV
+		if !d.coded[mb.luma[0]] && !d.coded[mb.luma[1]] && !d.coded[mb.luma[2]] && !d.coded[mb.luma[3]] {
+			continue
+		}
+		if scheme == 7 {
+			mb.mode = uint8(b.read(3))
+			continue
+		}
+		code := 0
+		for code < 7 && b.read(1) == 1 {
+			code++
+		}
+		mb.mode = alphabet[code]
+	}
+	return nil
+}
+
+// motionVectors decodes the block motion vectors, see spec 7.5.
+func (d *Decoder) motionVectors(b *bits) {
+	fixed := b.read(1) == 1
+	var last1, last2, mv [2]int32
+	for i := range d.mbs {
+		mb := &d.mbs[i]
+		switch mb.mode {
+		case modeInterFour:
+			var lmvs [4][2]int32
+			for bi, blk := range mb.luma {
+				if d.coded[blk] {
+					lmvs[bi] = readMV(b, fixed)
+					mv = lmvs[bi]
+				}
+				d.mvs[blk] = lmvs[bi]
+			}
+			last2, last1 = last1, mv
+			d.chromaMVs(mb, &lmvs)
+			continue
+		case modeInterMV:
+			mv = readMV(b, fixed)
+			last2, last1 = last1, mv
+		case modeInterLast:
+			mv = last1
+		case modeInterLast2:
+			mv = last2
+			last2, last1 = last1, mv
+		case modeGoldenMV:
+			mv = readMV(b, fixed)
+		default:
+			mv = [2]int32{}
+		}
+		for _, blk := range mb.luma {
+			d.mvs[blk] = mv
+		}
+		for _, blk := range mb.chroma {
+			d.mvs[blk] = mv
+		}
+	}
+}
+
+// chromaMVs sets the chroma block motion vectors
+// from the four luma block motion vectors.
+func (d *Decoder) chromaMVs(mb *macroblock, lmvs *[4][2]int32) {
+	// round to nearest, with halves away from zero.
+	round := func(v int32, shift uint) int32 {
+		neg := int32(0)
+		if v < 0 {
+			neg = -1
+		}
+		return (v + neg + 1<<(shift-1)) >> shift
+	}
+	n := len(mb.chroma) / 2
+	for i, blk := range mb.chroma {
+		var mv [2]int32
+		switch n {
+		case 1: // 4:2:0 averages all four.
+			for c := range mv {
+				mv[c] = round(lmvs[0][c]+lmvs[1][c]+lmvs[2][c]+lmvs[3][c], 2)
+			}
+		case 2: // 4:2:2 averages each row.
+			r := 2 * (i % 2)
+			for c := range mv {
+				mv[c] = round(lmvs[r][c]+lmvs[r+1][c], 1)
+			}
+		default: // 4:4:4 matches the luma blocks.
+			mv = lmvs[i%4]
+		}
+		d.mvs[blk] = mv
+	}
+}
+
+// readMV reads one motion vector.
+func readMV(b *bits, fixed bool) (mv [2]int32) {
+	for c := range mv {
+		if fixed {
+			mv[c] = int32(b.read(5))
+			if b.read(1) == 1 {
+				mv[c] = -mv[c]
+			}
+			continue
+		}
+		switch code := b.read(3); code {
+		case 0:
+			mv[c] = 0
+		case 1:
+			mv[c] = 1
+		case 2:
+			mv[c] = -1
+		default:
+			v := int32(code - 1) // 3:2 and 4:3
+			switch code {
+			case 5:
+				v = 4 + int32(b.read(2))
+			case 6:
+				v = 8 + int32(b.read(3))
+			case 7:
+				v = 16 + int32(b.read(4))
+			}
+			if b.read(1) == 1 {
+				v = -v
+			}
+			mv[c] = v
+		}
+	}
+	return mv
+}
+
+// blockQuality decodes the quality index for each
+// coded block when there is more than one, see spec 7.6.
+func (d *Decoder) blockQuality(b *bits) error {
+	for _, blk := range d.order {
+		d.qii[blk] = 0
+	}
+	for qii := uint8(0); int(qii) < d.nqis-1; qii++ {
+		n := 0
+		for _, blk := range d.order {
+			if d.qii[blk] == qii {
+				n++
+			}
+		}
+		flags := d.runs[:n]
+		if err := b.runs(flags, true); err != nil {
+			return err
+		}
+		for _, blk := range d.order {
+			if d.qii[blk] == qii {
+				d.qii[blk] += flags[0]
+				flags = flags[1:]
+			}
+		}
+	}
+	return nil
+}
+
+// tokenGroups are the Huffman table group for each zig-zag index.
+var tokenGroups = func() (groups [64]int) {
+	for ti := range groups {
+		switch {
+		case ti == 0:
+			groups[ti] = 0
+		case ti < 6:
+			groups[ti] = 1
+		case ti < 15:
+			groups[ti] = 2
+		case ti < 28:
+			groups[ti] = 3
+		default:
+			groups[ti] = 4
+		}
+	}
+	return groups
+}()
+
+// valueTokens are the smallest magnitude and the
+// number of extra magnitude bits for tokens 17 to 22.
+var valueTokens = [6][2]uint32{{7, 1}, {9, 2}, {13, 3}, {21, 4}, {37, 5}, {69, 9}}
+
+// tokens decodes the quantized DCT coefficients of the coded blocks,
+// see spec 7.7. The coefficients are decoded one zig-zag index at a
+// time across all the blocks, where end of block runs span blocks.
+func (d *Decoder) tokens(b *bits) error {
+	for _, blk := range d.order {
+		clear(d.coeffs[64*blk : 64*blk+64])
+		d.tis[blk], d.ncoeffs[blk] = 0, 64
+	}
+	eobs, luma := 0, int32(d.planes[1].off)
+	htil, htic := 0, 0 // luma and chroma table indexes.
+	for ti := 0; ti < 64; ti++ {
+		if ti <= 1 { // tables for the DC and then the AC coefficients.
+			htil, htic = int(b.read(4)), int(b.read(4))
+		}
+		group := 16 * tokenGroups[ti]
+		tables := [2]*huffman{&d.huffs[group+htil], &d.huffs[group+htic]}
+		for _, blk := range d.order {
+			if int(d.tis[blk]) != ti {
+				continue
+			}
+			if eobs > 0 {
+				d.ncoeffs[blk], d.tis[blk] = uint8(ti), 64
+				eobs--
+				continue
+			}
+			table := tables[0]
+			if blk >= luma {
+				table = tables[1]
+			}
+			if err := d.token(b, blk, table.decode(b), &eobs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// token applies one DCT token to a block.
+func (d *Decoder) token(b *bits, blk int32, token int, eobs *int) error {
+	ti := int(d.tis[blk])
+	run, v := 0, int32(0) // zeros before the value, and the value.
+	switch {
+	case token <= 6: // end of block runs.
+		switch token {
+		case 0, 1, 2:
+			*eobs = token + 1
+		case 3:
+			*eobs = int(b.read(2)) + 4
+		case 4:
+			*eobs = int(b.read(3)) + 8
+		case 5:
+			*eobs = int(b.read(4)) + 16
+		case 6:
+			if *eobs = int(b.read(12)); *eobs == 0 {
+				*eobs = len(d.order) // all the remaining blocks.
+			}
+		}
+		d.ncoeffs[blk], d.tis[blk] = uint8(ti), 64
+		*eobs--
+		return nil
+	case token <= 8: // zero runs.
+		bits := uint(3)
+		if token == 8 {
+			bits = 6
+		}
+		run = int(b.read(bits)) + 1
+		if ti+run > 64 {
+			return errors.New("theora: zero run overrun")
+		}
+		d.tis[blk] = uint8(ti + run)
+		return nil
+	case token <= 12:
+		v = [4]int32{1, -1, 2, -2}[token-9]
+	case token <= 16:
+		v = int32(token - 10)
+		if b.read(1) == 1 {
+			v = -v
+		}
+	case token <= 22:
+		sign := b.read(1)
+		vt := valueTokens[token-17]
+		v = int32(vt[0] + b.read(uint(vt[1])))
+		if sign == 1 {
+			v = -v
+		}
+	case token <= 27:
+		run, v = token-22, 1
+		if b.read(1) == 1 {
+			v = -1
+		}
+	case token <= 29:
+		sign := b.read(1)
+		if token == 28 {
+			run = int(b.read(2)) + 6
+		} else {
+			run = int(b.read(3)) + 10
+		}
+		v = 1 - 2*int32(sign)
+	case token == 30:
+		sign := b.read(1)
+		run, v = 1, 2+int32(b.read(1))
+		if sign == 1 {
+			v = -v
+		}
+	default:
+		sign := b.read(1)
+		v = 2 + int32(b.read(1))
+		run = int(b.read(1)) + 2
+		if sign == 1 {
+			v = -v
+		}
+	}
+	ti += run
+	if ti >= 64 {
+		return errors.New("theora: coefficient overrun")
+	}
+	d.coeffs[64*int(blk)+ti] = int16(v)
+	d.tis[blk] = uint8(ti + 1)
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package theora
+
+// predictDC adds the predicted DC coefficient to each coded block, see
+// spec 7.8. The prediction uses the neighbouring coded blocks to the
+// left and below that have the same reference frame.
+func (d *Decoder) predictDC() {
+	for _, p := range d.planes {
+		var last [3]int32 // last DC for each reference frame.
+		for by := 0; by < p.nbh; by++ {
+			for bx := 0; bx < p.nbw; bx++ {
+				blk := p.off + by*p.nbw + bx
+				if !d.coded[blk] {
+					continue
+				}
+				ref := refFrame[d.mbs[d.blkMB[blk]].mode]
+				var flags int
+				var dcs [4]int32 // left, down left, down, down right.
+				neighbour := func(n, bit, i int) {
+					if d.coded[n] && refFrame[d.mbs[d.blkMB[n]].mode] == ref {
+						flags |= bit
+						dcs[i] = int32(d.coeffs[64*n])
+					}
+				}
+				if bx > 0 {
+					neighbour(blk-1, 1, 0)
+				}
+				if by > 0 {
+					if bx > 0 {
+						neighbour(blk-p.nbw-1, 2, 1)
+					}
+					neighbour(blk-p.nbw, 4, 2)
+					if bx < p.nbw-1 {
+						neighbour(blk-p.nbw+1, 8, 3)
+					}
+				}
+				l, dl, dn, dr := dcs[0], dcs[1], dcs[2], dcs[3]
+				var pred int32
+				switch flags {
+				case 0:
+					pred = last[ref]
+				case 1, 3:
+					pred = l
+				case 2:
+					pred = dl
+				case 4, 6, 12:
+					pred = dn
+				case 5:
+					pred = (l + dn) / 2
+				case 8:
+					pred = dr
+				case 9, 11, 13:
+					pred = (75*l + 53*dr) / 128
+				case 10:
+					pred = (dl + dr) / 2
+				case 14:
+					pred = (3*(dl+dr) + 10*dn) / 16
+				default: // 7, 15
+					pred = (29*(l+dn) - 26*dl) / 32
+					switch {
+					case abs(pred-dn) > 128:
+						pred = dn
+					case abs(pred-l) > 128:
+						pred = l
+					case abs(pred-dl) > 128:
+						pred = dl
+					}
+				}
+				dc := int16(int32(d.coeffs[64*blk]) + pred)
+				d.coeffs[64*blk] = dc
+				last[ref] = int32(dc)
+			}
+		}
+	}
+}
+
+// reconstruct dequantizes and inverse transforms the coded blocks,
+// and adds the residual to the motion compensated prediction,
+// see spec 7.9.
+func (d *Decoder) reconstruct(cur int) {
+	var dq, res [64]int16
+	for _, blk := range d.order {
+		pli := 0
+		for pli < 2 && int(blk) >= d.planes[pli+1].off {
+			pli++
+		}
+		p := &d.planes[pli]
+		mode := d.mbs[d.blkMB[blk]].mode
+		qti := 1
+		if mode == modeIntra {
+			qti = 0
+		}
+		coeffs := d.coeffs[64*blk : 64*blk+64]
+		dcq := d.qmat[qti][pli][d.qis[0]][0]
+		if n := int(d.ncoeffs[blk]); n < 2 {
+			v := int16((int32(coeffs[0])*dcq + 15) >> 5) // only DC.
+			for i := range res {
+				res[i] = v
+			}
+		} else {
+			acq := &d.qmat[qti][pli][d.qis[d.qii[blk]]]
+			dq = [64]int16{}
+			dq[0] = int16(int32(coeffs[0]) * dcq)
+			for zzi := 1; zzi < n; zzi++ {
+				dq[zigzag[zzi]] = int16(int32(coeffs[zzi]) * acq[zzi])
+			}
+			idct(&res, &dq)
+		}
+
+		// add the residual to the prediction.
+		i := int(blk) - p.off
+		at := p.origin + 8*(i/p.nbw)*p.stride + 8*(i%p.nbw)
+		dst := d.frames[cur][pli]
+		if mode == modeIntra {
+			for y := 0; y < 8; y++ {
+				row := dst[at+y*p.stride:]
+				for x := 0; x < 8; x++ {
+					row[x] = clamp255(int32(res[y*8+x]) + 128)
+				}
+			}
+			continue
+		}
+		ref := d.frames[d.prev][pli]
+		if refFrame[mode] == 2 {
+			ref = d.frames[d.golden][pli]
+		}
+		o1, o2 := p.offsets(d.mvs[blk])
+		for y := 0; y < 8; y++ {
+			row := dst[at+y*p.stride:]
+			r1, r2 := ref[at+o1+y*p.stride:], ref[at+o2+y*p.stride:]
+			for x := 0; x < 8; x++ {
+				pred := (int32(r1[x]) + int32(r2[x])) >> 1
+				row[x] = clamp255(pred + int32(res[y*8+x]))
+			}
+		}
+	}
+}
+
+// offsets returns the two buffer offsets for a motion vector. Vectors
+// are in half pixels, or quarter pixels where the chroma is decimated.
+// The whole pixel offsets are truncated towards zero and away from zero,
+// and are the same when there is no fraction.
+func (p *plane) offsets(mv [2]int32) (o1, o2 int) {
+	var xy1, xy2 [2]int
+	for c, dec := range [2]int{p.xdec, p.ydec} {
+		div := int32(2 << dec)
+		v := mv[c] / div
+		xy1[c], xy2[c] = int(v), int(v)
+		if mv[c]%div != 0 {
+			if mv[c] < 0 {
+				xy2[c]--
+			} else {
+				xy2[c]++
+			}
+		}
+	}
+	return xy1[0] + xy1[1]*p.stride, xy2[0] + xy2[1]*p.stride
+}
+
+// Inverse DCT constants: cos(n*pi/16) scaled by 65536.
+const (
+	c1 = 64277
+	c2 = 60547
+	c3 = 54491
+	c4 = 46341
+	c5 = 36410
+	c6 = 25080
+	c7 = 12785
+)
+
+// idct transforms the dequantized coefficients to the residual,
+// first the rows and then the columns, see spec 7.9.3
+func idct(res, dq *[64]int16) {
+	var w [64]int16
+	for i := 0; i < 8; i++ {
+		idct8(w[i:], dq[8*i:]) // rows of dq to columns of w.
+	}
+	for i := 0; i < 8; i++ {
+		idct8(res[i:], w[8*i:]) // rows of w to columns of res.
+	}
+	for i := range res {
+		res[i] = int16((int32(res[i]) + 8) >> 4)
+	}
+}
+
+// idct8 is the one dimensional inverse DCT from x into every
+// 8th value of y, with the reference decoder 16 bit truncation.
+func idct8(y, x []int16) {
+	var t [8]int32
+	mul := func(c int32, v int16) int32 { return c * int32(v) >> 16 }
+	t[0] = mul(c4, x[0]+x[4])
+	t[1] = mul(c4, x[0]-x[4])
+	t[2] = mul(c6, x[2]) - mul(c2, x[6])
+	t[3] = mul(c2, x[2]) + mul(c6, x[6])
+	t[4] = mul(c7, x[1]) - mul(c1, x[7])
+	t[5] = mul(c3, x[5]) - mul(c5, x[3])
+	t[6] = mul(c5, x[5]) + mul(c3, x[3])
+	t[7] = mul(c1, x[1]) + mul(c7, x[7])
+
+	r := t[4] + t[5]
+	t[5] = mul(c4, int16(t[4]-t[5]))
+	t[4] = r
+	r = t[7] + t[6]
+	t[6] = mul(c4, int16(t[7]-t[6]))
+	t[7] = r
+
+	t[0], t[3] = t[0]+t[3], t[0]-t[3]
+	t[1], t[2] = t[1]+t[2], t[1]-t[2]
+	t[6], t[5] = t[6]+t[5], t[6]-t[5]
+
+	y[0<<3] = int16(t[0] + t[7])
+	y[1<<3] = int16(t[1] + t[6])
+	y[2<<3] = int16(t[2] + t[5])
+	y[3<<3] = int16(t[3] + t[4])
+	y[4<<3] = int16(t[3] - t[4])
+	y[5<<3] = int16(t[2] - t[5])
+	y[6<<3] = int16(t[1] - t[6])
+	y[7<<3] = int16(t[0] - t[7])
+}
+
+// loopFilter smooths the edges of the coded blocks, see spec 7.10.
+func (d *Decoder) loopFilter(cur int) {
+	limit := d.lflims[d.qis[0]]
+	if limit == 0 {
+		return
+	}
+	for pli, p := range d.planes {
+		pix := d.frames[cur][pli]
+		for by := 0; by < p.nbh; by++ {
+			for bx := 0; bx < p.nbw; bx++ {
+				blk := p.off + by*p.nbw + bx
+				if !d.coded[blk] {
+					continue
+				}
+				at := p.origin + 8*by*p.stride + 8*bx
+				if bx > 0 {
+					filter(pix, at, 1, p.stride, limit)
+				}
+				if by > 0 {
+					filter(pix, at, p.stride, 1, limit)
+				}
+				if bx < p.nbw-1 && !d.coded[blk+1] {
+					filter(pix, at+8, 1, p.stride, limit)
+				}
+				if by < p.nbh-1 && !d.coded[blk+p.nbw] {
+					filter(pix, at+8*p.stride, p.stride, 1, limit)
+				}
+			}
+		}
+	}
+}
+
+// filter smooths the 8 pixel edge before the pixel at, where step
+// crosses the edge and along moves along the edge.
+func filter(pix []uint8, at, step, along int, limit int32) {
+	for i := 0; i < 8; i++ {
+		p := at + i*along
+		r := (int32(pix[p-2*step]) - 3*int32(pix[p-step]) + 3*int32(pix[p]) - int32(pix[p+step]) + 4) >> 3
+		switch {
+		case r <= -2*limit, r >= 2*limit:
+			r = 0
+		case r <= -limit:
+			r = -r - 2*limit
+		case r >= limit:
+			r = -r + 2*limit
+		}
+		pix[p-step] = clamp255(int32(pix[p-step]) + r)
+		pix[p] = clamp255(int32(pix[p]) - r)
+	}
+}
+
+// fillBorders copies the plane edges into the borders.
+func (d *Decoder) fillBorders(cur int) {
+	for pli, p := range d.planes {
+		pix := d.frames[cur][pli]
+		for y := 0; y < p.h; y++ {
+			row := pix[p.origin+y*p.stride-border : p.origin+y*p.stride+p.w+border]
+			for x := 0; x < border; x++ {
+				row[x], row[border+p.w+x] = row[border], row[border+p.w-1]
+			}
+		}
+		first, last := pix[border*p.stride:(border+1)*p.stride], pix[(border+p.h-1)*p.stride:(border+p.h)*p.stride]
+		for y := 0; y < border; y++ {
+			copy(pix[y*p.stride:], first)
+			copy(pix[(border+p.h+y)*p.stride:], last)
+		}
+	}
+}
+
+// clamp255 clamps a pixel value.
+func clamp255(v int32) uint8 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 255:
+		return 255
+	}
+	return uint8(v)
+}
+
+// abs returns the absolute value.
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,400 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+// Package theora decodes Theora video frames. The Theora I specification
+// is from https://www.theora.org/doc/Theora.pdf and the decoded frames
+// match the reference decoder from https://gitlab.xiph.org/xiph/theora.
+//
+// Package theora is provided as part of the vu (virtual universe) 3D engine.
+package theora
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Decoder decodes the frames of one Theora stream in order.
+// Decoders keep the reference frames needed by later frames.
+type Decoder struct {
+	// identification header.
+	fmbw, fmbh int // frame size in 16x16 macroblocks.
+	picw, pich int // picture size in pixels.
+	picx, picy int // picture offset from the bottom left of the frame.
+	pf         int // pixel format: 0:4:2:0, 2:4:2:2, 3:4:4:4
+
+	// setup header.
+	lflims [64]int32           // loop filter limits for each quality index.
+	qmat   [2][3][64][64]int32 // dequantization [intra,inter][plane][qi][zig-zag index]
+	huffs  [80]huffman         // DCT token tables.
+
+	// frame layout.
+	planes       [3]plane      // Y, Cb, Cr.
+	nblks        int           // blocks in all planes.
+	sbs          []int32       // superblock blocks in coded order, see sbEnd.
+	sbEnd        []int32       // end of each superblock in sbs.
+	mbs          []macroblock  // macroblocks in coded order.
+	blkMB        []int32       // macroblock for each block.
+	frames       [3][3][]uint8 // reference and current frames.
+	golden, prev int           // reference frame indexes, -1 before the first key frame.
+
+	// per frame state, mostly indexed by block.
+	coded   []bool     // true for blocks coded in this frame.
+	order   []int32    // coded blocks in coded order.
+	mvs     [][2]int32 // block motion vectors in half or quarter pixels.
+	qii     []uint8    // block quality index index.
+	ncoeffs []uint8    // number of tokens decoded for each block.
+	coeffs  []int16    // quantized coefficients in zig-zag order, 64 for each block.
+	tis     []uint8    // next token index for each coded block.
+	runs    []uint8    // run length decode buffer.
+	qis     [3]int32   // frame quality indexes.
+	nqis    int        // number of quality indexes.
+}
+
+// plane is the layout of one colour plane. Rows are stored bottom up
+// with a border of copied edge pixels for motion vectors that point
+// outside the frame.
+type plane struct {
+	w, h       int // size in pixels.
+	nbw, nbh   int // size in 8x8 blocks.
+	off        int // index of the first block.
+	stride     int // bytes in each buffer row.
+	origin     int // buffer offset of the bottom left pixel.
+	xdec, ydec int // 1 where chroma is half the luma size.
+}
+
+// border is the number of edge pixels copied around each plane.
+const border = 16
+
+// macroblock covers 16x16 luma pixels along with the chroma blocks
+// for the same area.
+type macroblock struct {
+	mode   uint8    // coding mode, see modeIntra.
+	luma   [4]int32 // luma blocks in raster order.
+	chroma []int32  // Cb blocks followed by the Cr blocks, in raster order.
+}
+
+// Macroblock coding modes.
+const (
+	modeInterNoMV = iota
+	modeIntra
+	modeInterMV
+	modeInterLast
+	modeInterLast2
+	modeGoldenNoMV
+	modeGoldenMV
+	modeInterFour
+)
+
+// refFrame is the reference frame for each mode:
+// 0 for none, 1 for the previous frame, 2 for the golden frame.
+var refFrame = [8]int{1, 0, 1, 1, 1, 2, 2, 1}
+
+// NewDecoder reads the three Theora header packets.
+func NewDecoder(headers [][]byte) (d *Decoder, err error) {
+	if len(headers) != 3 {
+		return nil, fmt.Errorf("theora: expected 3 headers, got %d", len(headers))
+	}
+	magic := []string{"\x80theora", "\x81theora", "\x82theora"}
+	for i, h := range headers {
+		if len(h) < 7 || string(h[:7]) != magic[i] {
+			return nil, fmt.Errorf("theora: bad header %d", i)
+		}
+	}
+	d = &Decoder{golden: -1, prev: -1}
+	if err = d.identification(headers[0][7:]); err != nil {
+		return nil, err
+	}
+	if err = d.setup(headers[2][7:]); err != nil {
+		return nil, err
+	}
+	d.layout()
+	return d, nil
+}
+
+// Size returns the picture size in pixels.
+func (d *Decoder) Size() (w, h int) { return d.picw, d.pich }
+
+// identification reads the frame size and pixel format.
+func (d *Decoder) identification(id []byte) error {
+	if len(id) < 35 {
+		return errors.New("theora: short identification header")
+	}
+	if id[0] != 3 || id[1] != 2 {
+		return fmt.Errorf("theora: unsupported version %d.%d.%d", id[0], id[1], id[2])
+	}
+	be := func(b []byte) (v int) {
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	d.fmbw, d.fmbh = be(id[3:5]), be(id[5:7])
+	d.picw, d.pich = be(id[7:10]), be(id[10:13])
+	d.picx, d.picy = int(id[13]), int(id[14])
+	d.pf = int(id[34]>>3) & 3
+	switch {
+	case d.fmbw == 0 || d.fmbh == 0:
+		return errors.New("theora: empty frame")
+	case d.picx+d.picw > d.fmbw*16 || d.picy+d.pich > d.fmbh*16:
+		return errors.New("theora: picture outside frame")
+	case d.pf == 1:
+		return errors.New("theora: reserved pixel format")
+	}
+	return nil
+}
+
+// setup reads the loop filter limits, the quantization parameters,
+// and the DCT token Huffman tables.
+func (d *Decoder) setup(data []byte) error {
+	b := &bits{data: data}
+	nbits := uint(b.read(3))
+	for qi := range d.lflims {
+		d.lflims[qi] = int32(b.read(nbits))
+	}
+
+	// quantization parameters, spec 6.4.2
+	var acscale, dcscale [64]int32
+	nbits = uint(b.read(4)) + 1
+	for qi := range acscale {
+		acscale[qi] = int32(b.read(nbits))
+	}
+	nbits = uint(b.read(4)) + 1
+	for qi := range dcscale {
+		dcscale[qi] = int32(b.read(nbits))
+	}
+	nbms := int(b.read(9)) + 1
+	if nbms > 384 {
+		return errors.New("theora: too many base matrices")
+	}
+	bms := make([][64]int32, nbms)
+	for bmi := range bms {
+		for ci := range bms[bmi] {
+			bms[bmi][ci] = int32(b.read(8))
+		}
+	}
+	var sizes [2][3][]int32 // quant range sizes.
+	var bmis [2][3][]int32  // quant range base matrix indexes.
+	for qti := 0; qti < 2; qti++ {
+		for pli := 0; pli < 3; pli++ {
+			if (qti > 0 || pli > 0) && b.read(1) == 0 {
+				qtj, plj := (3*qti+pli-1)/3, (pli+2)%3
+				if qti > 0 && b.read(1) == 1 {
+					qtj, plj = qti-1, pli
+				}
+				sizes[qti][pli], bmis[qti][pli] = sizes[qtj][plj], bmis[qtj][plj]
+				continue
+			}
+			bmi := int32(b.read(ilog(nbms - 1)))
+			if bmi >= int32(nbms) {
+				return errors.New("theora: bad base matrix index")
+			}
+			bmis[qti][pli] = []int32{bmi}
+			for qi := 0; qi < 63; {
+				size := int32(b.read(ilog(62-qi))) + 1
+				qi += int(size)
+				bmi := int32(b.read(ilog(nbms - 1)))
+				if qi > 63 || bmi >= int32(nbms) {
+					return errors.New("theora: bad quant range")
+				}
+				sizes[qti][pli] = append(sizes[qti][pli], size)
+				bmis[qti][pli] = append(bmis[qti][pli], bmi)
+			}
+		}
+	}
+
+	// interpolate the base matrices for each quality index, spec 6.4.3
+	qmin := [2][2]int32{{16, 8}, {32, 16}} // [qti][dc,ac]
+	for qti := range d.qmat {
+		for pli := range d.qmat[qti] {
+			for qi := range d.qmat[qti][pli] {
+				qri, start := 0, int32(0)
+				for start+sizes[qti][pli][qri] < int32(qi) {
+					start += sizes[qti][pli][qri]
+					qri++
+				}
+				size := sizes[qti][pli][qri]
+				bm0, bm1 := &bms[bmis[qti][pli][qri]], &bms[bmis[qti][pli][qri+1]]
+				end, q := start+size, int32(qi)
+				for zzi := 0; zzi < 64; zzi++ {
+					ci := zigzag[zzi]
+					bm := (2*(end-q)*bm0[ci] + 2*(q-start)*bm1[ci] + size) / (2 * size)
+					scale, lo := acscale[qi], qmin[qti][1]
+					if zzi == 0 {
+						scale, lo = dcscale[qi], qmin[qti][0]
+					}
+					d.qmat[qti][pli][qi][zzi] = max(lo, min(scale*bm/100*4, 4096))
+				}
+			}
+		}
+	}
+
+	// DCT token Huffman tables, spec 6.4.4
+	for i := range d.huffs {
+		if err := d.huffs[i].read(b); err != nil {
+			return err
+		}
+	}
+	if b.overrun() {
+		return errors.New("theora: short setup header")
+	}
+	return nil
+}
+
+// layout sizes the planes and orders the blocks and macroblocks.
+func (d *Decoder) layout() {
+	w, h := d.fmbw*16, d.fmbh*16
+	for pli := range d.planes {
+		p := &d.planes[pli]
+		if pli > 0 {
+			p.xdec = 1 - d.pf&1  // 4:4:4 is not decimated.
+			p.ydec = 1 - d.pf>>1 // 4:2:0 is decimated vertically.
+		}
+		p.w, p.h = w>>p.xdec, h>>p.ydec
+		p.nbw, p.nbh = p.w/8, p.h/8
+		p.off = d.nblks
+		p.stride = p.w + 2*border
+		p.origin = border*p.stride + border
+		d.nblks += p.nbw * p.nbh
+		for f := range d.frames {
+			d.frames[f][pli] = make([]uint8, p.stride*(p.h+2*border))
+		}
+	}
+
+	// superblocks are 4x4 blocks, in raster order, with the
+	// blocks of each superblock in Hilbert curve order.
+	hilbert := [16][2]int{
+		{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 2}, {0, 3}, {1, 3}, {1, 2},
+		{2, 2}, {2, 3}, {3, 3}, {3, 2}, {3, 1}, {2, 1}, {2, 0}, {3, 0},
+	}
+	for _, p := range d.planes {
+		for sby := 0; sby < (p.nbh+3)/4; sby++ {
+			for sbx := 0; sbx < (p.nbw+3)/4; sbx++ {
+				for _, xy := range hilbert {
+					bx, by := sbx*4+xy[0], sby*4+xy[1]
+					if bx < p.nbw && by < p.nbh {
+						d.sbs = append(d.sbs, int32(p.off+by*p.nbw+bx))
+					}
+				}
+				d.sbEnd = append(d.sbEnd, int32(len(d.sbs)))
+			}
+		}
+	}
+
+	// macroblocks are ordered by luma superblock,
+	// with 2x2 macroblocks in each superblock.
+	d.blkMB = make([]int32, d.nblks)
+	quads := [4][2]int{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	for sby := 0; sby < (d.fmbh+1)/2; sby++ {
+		for sbx := 0; sbx < (d.fmbw+1)/2; sbx++ {
+			for _, xy := range quads {
+				mbx, mby := sbx*2+xy[0], sby*2+xy[1]
+				if mbx >= d.fmbw || mby >= d.fmbh {
+					continue
+				}
+				mb := macroblock{}
+				y := &d.planes[0]
+				for i := range mb.luma {
+					mb.luma[i] = int32(y.off + (mby*2+i/2)*y.nbw + mbx*2 + i%2)
+				}
+				for _, p := range d.planes[1:] {
+					bw, bh := 2>>p.xdec, 2>>p.ydec // chroma blocks in the macroblock.
+					for j := 0; j < bh; j++ {
+						for i := 0; i < bw; i++ {
+							mb.chroma = append(mb.chroma, int32(p.off+(mby*bh+j)*p.nbw+mbx*bw+i))
+						}
+					}
+				}
+				for _, blk := range mb.luma {
+					d.blkMB[blk] = int32(len(d.mbs))
+				}
+				for _, blk := range mb.chroma {
+					d.blkMB[blk] = int32(len(d.mbs))
+				}
+				d.mbs = append(d.mbs, mb)
+			}
+		}
+	}
+
+	d.coded = make([]bool, d.nblks)
+	d.order = make([]int32, 0, d.nblks)
+	d.mvs = make([][2]int32, d.nblks)
+	d.qii = make([]uint8, d.nblks)
+	d.ncoeffs = make([]uint8, d.nblks)
+	d.coeffs = make([]int16, 64*d.nblks)
+	d.tis = make([]uint8, d.nblks)
+	d.runs = make([]uint8, max(len(d.sbEnd), d.nblks))
+}
+
+// DecodeFrame decodes the next frame in the stream and draws the picture
+// into img, which is the picture size. Empty frames repeat the previous
+// frame and leave img unchanged.
+func (d *Decoder) DecodeFrame(frame []byte, img *image.NRGBA) error {
+	if len(frame) == 0 {
+		return nil
+	}
+	if err := d.decode(frame); err != nil {
+		return err
+	}
+	d.draw(img)
+	return nil
+}
+
+// draw converts the picture area of the last decoded frame
+// to RGB, flipping the bottom up rows.
+func (d *Decoder) draw(img *image.NRGBA) {
+	f := &d.frames[d.prev]
+	y, cb, cr := &d.planes[0], &d.planes[1], &d.planes[2]
+	w, h := min(d.picw, img.Rect.Dx()), min(d.pich, img.Rect.Dy())
+	for row := 0; row < h; row++ {
+		fy := d.picy + d.pich - 1 - row // frame row, counted from the bottom.
+		yrow := f[0][y.origin+fy*y.stride:]
+		brow := f[1][cb.origin+(fy>>cb.ydec)*cb.stride:]
+		rrow := f[2][cr.origin+(fy>>cr.ydec)*cr.stride:]
+		pix := img.Pix[row*img.Stride:]
+		for col := 0; col < w; col++ {
+			fx := d.picx + col
+			r, g, b := ycbcr(yrow[fx], brow[fx>>cb.xdec], rrow[fx>>cr.xdec])
+			pix[4*col], pix[4*col+1], pix[4*col+2], pix[4*col+3] = r, g, b, 255
+		}
+	}
+}
+
+// ycbcr converts a BT.601 video range pixel to RGB.
+func ycbcr(y, cb, cr uint8) (r, g, b uint8) {
+	yy := (int32(y) - 16) * 76309 // 1.164 * 65536
+	u, v := int32(cb)-128, int32(cr)-128
+	return clampRGB(yy + 104597*v), clampRGB(yy - 25675*u - 53279*v), clampRGB(yy + 132201*u)
+}
+
+// clampRGB scales and clamps a 16.16 fixed point colour.
+func clampRGB(c int32) uint8 {
+	c = (c + 32768) >> 16
+	switch {
+	case c < 0:
+		return 0
+	case c > 255:
+		return 255
+	}
+	return uint8(c)
+}
+
+// zigzag maps the zig-zag coefficient order to the 8x8 raster order.
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// ilog returns the number of bits needed to store v.
+func ilog(v int) uint {
+	n := uint(0)
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+	return n
+}
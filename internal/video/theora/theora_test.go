@@ -0,0 +1,158 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package theora
+
+import (
+	"image"
+	"testing"
+)
+
+// go test -run Decoder
+func TestDecoder(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+
+	// go test -run Decoder/key
+	t.Run("key", func(t *testing.T) {
+		d, err := NewDecoder(testHeaders())
+		if err != nil {
+			t.Fatalf("headers %s", err)
+		}
+		if w, h := d.Size(); w != 16 || h != 16 {
+			t.Fatalf("expected 16x16 got %dx%d", w, h)
+		}
+		if err := d.DecodeFrame(testKey(), img); err != nil {
+			t.Fatalf("key frame %s", err)
+		}
+
+		// the luma DC of 64 is predicted for the other blocks.
+		// Y:160 is grey 168 and the chroma is neutral.
+		for i := 0; i < len(img.Pix); i += 4 {
+			if p := img.Pix[i : i+4]; p[0] != 168 || p[1] != 168 || p[2] != 168 || p[3] != 255 {
+				t.Fatalf("expected grey got %v at %d", p, i/4)
+			}
+		}
+	})
+
+	// go test -run Decoder/inter
+	t.Run("inter", func(t *testing.T) {
+		d, _ := NewDecoder(testHeaders())
+		if err := d.DecodeFrame(testInter(), img); err == nil {
+			t.Errorf("expected inter frame to need a key frame")
+		}
+		d.DecodeFrame(testKey(), img)
+		clear(img.Pix)
+		if err := d.DecodeFrame(testInter(), img); err != nil {
+			t.Fatalf("inter frame %s", err)
+		}
+		if p := img.Pix[len(img.Pix)-4:]; p[0] != 168 || p[3] != 255 {
+			t.Errorf("expected uncoded blocks to repeat got %v", p)
+		}
+		clear(img.Pix)
+		if err := d.DecodeFrame(nil, img); err != nil || img.Pix[0] != 0 {
+			t.Errorf("expected empty frame to leave the image")
+		}
+	})
+
+	// go test -run Decoder/errors
+	t.Run("errors", func(t *testing.T) {
+		if _, err := NewDecoder(testHeaders()[:2]); err == nil {
+			t.Errorf("expected missing setup header to fail")
+		}
+		headers := testHeaders()
+		headers[2] = headers[2][:20]
+		if _, err := NewDecoder(headers); err == nil {
+			t.Errorf("expected short setup header to fail")
+		}
+		headers = testHeaders()
+		headers[0][8] = 1 // version 1.2
+		if _, err := NewDecoder(headers); err == nil {
+			t.Errorf("expected old version to fail")
+		}
+		d, _ := NewDecoder(testHeaders())
+		if err := d.DecodeFrame([]byte{0x80}, img); err == nil {
+			t.Errorf("expected header packet to fail")
+		}
+	})
+}
+
+// bitWriter writes bits most significant first.
+type bitWriter struct {
+	data []byte
+	n    uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>uint(i)&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+// testHeaders returns the headers for a 16x16 4:2:0 stream. All the
+// quantizers are 16 and all the tokens have 5 bit Huffman codes.
+func testHeaders() [][]byte {
+	id := []byte("\x80theora")
+	id = append(id, 3, 2, 1, 0, 1, 0, 1) // version, 1x1 macroblocks.
+	id = append(id, 0, 0, 16, 0, 0, 16)  // picture size.
+	id = append(id, 0, 0, 0, 0, 0, 30, 0, 0, 0, 1)
+	id = append(id, make([]byte, 12)...) // aspect, colour, bitrate, quality, pixel format.
+
+	w := &bitWriter{data: []byte("\x82theora"), n: 56}
+	w.write(0, 3) // no loop filter.
+	for i := 0; i < 2; i++ {
+		w.write(6, 4) // 7 bit AC then DC scales.
+		for qi := 0; qi < 64; qi++ {
+			w.write(100, 7)
+		}
+	}
+	w.write(0, 9) // one base matrix.
+	for ci := 0; ci < 64; ci++ {
+		w.write(4, 8)
+	}
+	w.write(62, 6) // one quant range for all quality indexes.
+	w.write(0, 8)  // copy the range for the other planes.
+	var tree func(depth int, token uint32)
+	tree = func(depth int, token uint32) {
+		if depth == 5 {
+			w.write(1, 1)
+			w.write(token, 5)
+			return
+		}
+		w.write(0, 1)
+		tree(depth+1, token<<1)
+		tree(depth+1, token<<1|1)
+	}
+	for i := 0; i < 80; i++ {
+		tree(0, 0)
+	}
+	return [][]byte{id, []byte("\x81theora"), w.data}
+}
+
+// testKey returns a key frame with a luma DC of 64.
+func testKey() []byte {
+	w := &bitWriter{}
+	w.write(0, 9)  // data packet, intra frame, qi 0, 1 qi
+	w.write(0, 3)  // reserved.
+	w.write(0, 8)  // DC huffman tables.
+	w.write(21, 5) // first luma block DC +64
+	w.write(0, 1)  // ...
+	w.write(27, 5) // ...
+	w.write(6, 5)  // end of block run for the remaining blocks.
+	w.write(0, 12) // ...
+	w.write(0, 8)  // AC huffman tables.
+	return w.data
+}
+
+// testInter returns a frame with no coded blocks.
+func testInter() []byte {
+	w := &bitWriter{}
+	w.write(0x80, 9) // data packet, inter frame, qi 0, 1 qi
+	w.write(0x5, 4)  // 3 superblocks not partially coded.
+	w.write(0x5, 4)  // 3 superblocks not fully coded.
+	w.write(0xe, 4)  // fixed length modes, motion vector mode.
+	w.write(0, 16)   // DC and AC huffman tables.
+	return w.data
+}
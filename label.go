@@ -9,8 +9,13 @@ import (
 	"image"
 	"image/draw"
 	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
 
 	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
 )
 
 // AddLabel creates a static string model for 2D or 3D text display.
@@ -20,10 +25,17 @@ import (
 //
 // A label requires a texture based shader, font mapping data, and a font
 // texture atlas. The mesh is calculated from the string once the font
-// assets have loaded.
+// assets have loaded. Strings are UTF-8 and the wrap width is in pixels
+// where lines are broken between words.
+//
+// Fonts with many characters can have more than one atlas page.
+// Glyphs from other pages are drawn by child models that use the
+// page textures, ie: "tex:color:lucon18_1" for the second page.
 func (e *Entity) AddLabel(s string, wrap int, assets ...string) (me *Entity) {
 	me = e.AddPart() // add a transform node for the label.
 	if mod := me.app.models.createLabel(s, wrap, me); mod != nil {
+		mod.req = strings.Join(assets, ",")
+		mod.label.assets = assets
 		mod.getAssets(me, assets...)
 
 		// labels need a backing mesh once the font loads.
@@ -43,27 +55,155 @@ func (e *Entity) LabelSize() (w, h int) {
 	return 0, 0
 }
 
-// FUTURE: SetText to update a label string and regenerate a new mesh.
+// SetTextOutline draws an outline of the given color around the label text.
+// The width is a fraction (0-1) of the font distance field spread where
+// 0 turns the outline off. Only affects labels that use a signed distance
+// field font and shader, eg:
+//
+//	eng.ImportAssets("sdf.shd", "sdf:48:lucon.ttf")
+//	text := scene.AddLabel("text", 0, "shd:sdf", "fnt:lucon48sdf", "tex:color:lucon48sdf")
+//	text.SetTextOutline(0.3, 0, 0, 0, 1)
+//
+// Depends on Ent.AddLabel.
+func (e *Entity) SetTextOutline(width, r, g, b, a float64) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
+		m.label.outline = float32(lin.Clamp(width, 0, 1))
+		m.label.effect = rgba{float32(r), float32(g), float32(b), float32(a)}
+		m.setTextUniforms()
+		return e
+	}
+	slog.Error("SetTextOutline needs label", "entity", e.eid)
+	return e
+}
+
+// SetTextGlow adds a glow around the label text using the outline color.
+// The width is a fraction (0-1) of the font distance field spread where
+// 0 turns the glow off. Only affects labels that use a signed distance
+// field font and shader. See SetTextOutline.
+//
+// Depends on Ent.AddLabel.
+func (e *Entity) SetTextGlow(width float64) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
+		m.label.glow = float32(lin.Clamp(width, 0, 1))
+		m.setTextUniforms()
+		return e
+	}
+	slog.Error("SetTextGlow needs label", "entity", e.eid)
+	return e
+}
+
+// SetTextSoftness blurs the edges of the label text, outline, and glow.
+// The width is a fraction (0-1) of the font distance field spread where
+// 0 keeps the edges sharp. Only affects labels that use a signed distance
+// field font and shader. See SetTextOutline.
+//
+// Depends on Ent.AddLabel.
+func (e *Entity) SetTextSoftness(width float64) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
+		m.label.softness = float32(lin.Clamp(width, 0, 1))
+		m.setTextUniforms()
+		return e
+	}
+	slog.Error("SetTextSoftness needs label", "entity", e.eid)
+	return e
+}
+
+// SetTextRTL lays out the label text right-to-left when rtl is true.
+// Each line is drawn in reverse character order and right aligned to
+// the wrap width, or to the longest line if there is no wrap width.
+// The label mesh is regenerated if it has already been created.
+//
+// Depends on Ent.AddLabel.
+func (e *Entity) SetTextRTL(rtl bool) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
+		if m.label.rtl != rtl {
+			m.label.rtl = rtl
+			e.app.ld.loadLabelMesh(m.fntAID, e)
+		}
+		return e
+	}
+	slog.Error("SetTextRTL needs label", "entity", e.eid)
+	return e
+}
+
+// SetText changes the label string. The label mesh is regenerated
+// once the font assets have loaded.
+//
+// Depends on Ent.AddLabel.
+func (e *Entity) SetText(s string) *Entity {
+	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
+		if m.label.str != s {
+			m.label.str = s
+			e.app.ld.loadLabelMesh(m.fntAID, e)
+		}
+		return e
+	}
+	slog.Error("SetText needs label", "entity", e.eid)
+	return e
+}
+
 // FUTURE: SetWrap to update a label wrap and regenerate a new mesh.
 
+// setTextUniforms updates the label sdf effect shader uniforms.
+func (m *model) setTextUniforms() {
+	l := m.label
+	m.uniforms[load.ARGS4] = render.V4S32ToBytes(l.outline, l.glow, l.softness, 0, m.uniforms[load.ARGS4])
+	c := l.effect
+	m.uniforms[load.OUTLINE] = render.V4S32ToBytes(c.r, c.g, c.b, c.a, m.uniforms[load.OUTLINE])
+}
+
 // labelData is an internal call to get label information for the given entity.
-func (e *Entity) labelData() (labelStr string, wrap int) {
+func (e *Entity) labelData() (labelStr string, wrap int, rtl bool) {
 	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
-		return m.label.str, m.label.wrap
+		return m.label.str, m.label.wrap, m.label.rtl
 	}
 	slog.Error("labelData needs label", "entity", e.eid)
-	return "", 0
+	return "", 0, false
 }
 
-// setLabelMesh is an internal call to set the underlying mesh
-// for the label.
-func (e *Entity) setLabelMesh(msh *mesh, sx, sy int) {
-	if m := e.app.models.get(e.eid); m != nil && m.mtype == labelModel && m.label != nil {
-		m.label.w, m.label.h = sx, sy
-		m.mesh = msh
+// setLabelMesh is an internal call to set the underlying mesh for
+// the given font atlas page of the label. Page 0 is the label model.
+// Other pages are drawn by child models that share the label material.
+// The child models for pages that are not in use are culled.
+func (e *Entity) setLabelMesh(meshes map[int]*mesh, sx, sy int) {
+	m := e.app.models.get(e.eid)
+	if m == nil || m.mtype != labelModel || m.label == nil {
+		slog.Error("setLabelMesh needs label", "entity", e.eid)
 		return
 	}
-	slog.Error("setLabelMesh needs label", "entity", e.eid)
+	m.label.w, m.label.h = sx, sy
+	if m.mesh != nil {
+		e.app.ld.release(m.mesh) // replaced label text.
+	}
+	m.mesh = meshes[0]
+	for page, child := range m.label.pages {
+		if _, ok := meshes[page]; !ok {
+			child.Cull(true) // page not used by the current text.
+		}
+	}
+	for page, msh := range meshes {
+		if page == 0 {
+			continue
+		}
+		child, ok := m.label.pages[page]
+		if !ok {
+			child = e.AddModel(m.label.pageAssets(page)...)
+			m.label.pages[page] = child
+		}
+		child.Cull(false)
+		if cm := e.app.models.get(child.eid); cm != nil {
+			if cm.mesh != nil {
+				e.app.ld.release(cm.mesh)
+			}
+			cm.mesh = msh
+			if cm.mat != nil && cm.mat != m.mat {
+				e.app.ld.release(cm.mat)
+			}
+			cm.mat = m.mat           // share label color...
+			cm.uniforms = m.uniforms // ...and text effects.
+			cm.layer, cm.queue = m.layer, m.queue
+		}
+	}
 }
 
 // label entity methods
@@ -81,7 +221,43 @@ type label struct {
 	w, h int // 0 for nil strings or unloaded assets.
 
 	// Sets a wrap amount for the string label in pixels.
-	wrap int // Default 0. Negative values ignored.
+	wrap int  // Default 0. Negative values ignored.
+	rtl  bool // Default false for left-to-right text.
+
+	// assets are the label asset requests.
+	// pages are child models for glyphs on other font atlas pages.
+	assets []string
+	pages  map[int]*Entity
+
+	// Signed distance field font effects.
+	outline  float32 // outline width as fraction of sdf spread.
+	glow     float32 // glow width as fraction of sdf spread.
+	softness float32 // edge blur as fraction of sdf spread.
+	effect   rgba    // outline and glow color.
+}
+
+// pageAssets returns the label asset requests for a child model
+// that draws the glyphs on the given font atlas page.
+func (l *label) pageAssets(page int) (assets []string) {
+	for _, a := range l.assets {
+		attr := strings.Split(a, ":")
+		switch {
+		case attr[0] == "fnt":
+			continue // the page model uses the label mesh.
+		case len(attr) == 3 && attr[0] == "tex":
+			a = fmt.Sprintf("%s:%s:%s", attr[0], attr[1], fontPage(attr[2], page))
+		}
+		assets = append(assets, a)
+	}
+	return assets
+}
+
+// fontPage returns the texture name for the given font atlas page.
+func fontPage(name string, page int) string {
+	if page == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s_%d", name, page)
 }
 
 // ============================================================================
@@ -91,17 +267,21 @@ type label struct {
 // for a font. It is combined with a texture (the font bitmapped image)
 // in order to produce displayable strings.
 type font struct {
-	name  string         // Unique id for a glyph set.
-	tag   aid            // Name and type as a number.
-	w, h  int            // Width and height of the entire font bitmap image.
-	chars map[rune]*char // The "character" image information.
-	img   *image.NRGBA   // the font bitmap image.
+	name  string          // Unique id for a glyph set.
+	tag   aid             // Name and type as a number.
+	w, h  int             // Width and height of each font bitmap image.
+	lineh int             // Line height in pixels.
+	chars map[rune]*char  // The "character" image information.
+	kerns map[[2]rune]int // Advance adjustments for pairs of characters.
+	imgs  []*image.NRGBA  // the font bitmap images, one per atlas page.
+	sdf   bool            // true if imgs are signed distance fields.
 }
 
 // newFont allocates space for font mapping data.
 func newFont(name string) *font {
 	f := &font{name: name, tag: assetID(fnt, name)}
 	f.chars = map[rune]*char{}
+	f.kerns = map[[2]rune]int{}
 	return f
 }
 
@@ -112,9 +292,96 @@ func (f *font) label() string { return f.name } // asset name
 // set font mapping data. Expected to be called by loader
 // as fonts are loaded from disk.
 func (f *font) setSize(w, h int) { f.w, f.h = w, h }
-func (f *font) addChar(r rune, x, y, w, h, xo, yo, xa int) {
+func (f *font) addChar(r rune, x, y, w, h, xo, yo, xa, page int) {
 	uvs := f.uvs(x, y, w, h)
-	f.chars[r] = &char{x, y, w, h, xo, yo, xa, uvs}
+	f.chars[r] = &char{x, y, w, h, xo, yo, xa, page, uvs}
+	if f.lineh < h {
+		f.lineh = h // default to the glyph height.
+	}
+}
+func (f *font) addKern(left, right rune, adjust int) { f.kerns[[2]rune{left, right}] = adjust }
+
+// glyph returns the character information for the given rune,
+// replacing unavailable characters with "." Returns nil if
+// neither the rune or "." are available.
+func (f *font) glyph(r rune) *char {
+	if c := f.chars[r]; c != nil {
+		return c
+	}
+	return f.chars['.']
+}
+
+// advance returns the pen movement after drawing the given rune
+// when it is followed by the next rune. Next is 0 for the last rune.
+func (f *font) advance(r, next rune) int {
+	c := f.glyph(r)
+	if c == nil {
+		return 0
+	}
+	return c.xAdvance + f.kerns[[2]rune{r, next}]
+}
+
+// measure returns the width in pixels of the given runes.
+func (f *font) measure(runes []rune) (width int) {
+	for i, r := range runes {
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		width += f.advance(r, next)
+	}
+	return width
+}
+
+// wrapLines splits the string into lines at newlines and, given a
+// positive wrap width in pixels, between words that would otherwise
+// exceed the wrap width. Words wider than the wrap width are not split.
+func (f *font) wrapLines(str string, wrap int) (lines [][]rune) {
+	for _, paragraph := range strings.Split(str, "\n") {
+		line := []rune{}
+		if wrap <= 0 {
+			lines = append(lines, append(line, []rune(paragraph)...))
+			continue
+		}
+		for _, word := range splitWords(paragraph) {
+			isSpace := unicode.IsSpace(word[0])
+			if !isSpace && len(line) > 0 && f.measure(line)+f.measure(word) > wrap {
+				lines = append(lines, trimSpaces(line))
+				line = []rune{}
+			}
+			if isSpace && len(line) == 0 && len(lines) > 0 {
+				continue // drop leading spaces on wrapped lines.
+			}
+			line = append(line, word...)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitWords splits a string into alternating runs of spaces and
+// non-spaces so that a line can be rebuilt with its original spacing.
+func splitWords(str string) (words [][]rune) {
+	word := []rune{}
+	for _, r := range str {
+		if len(word) > 0 && unicode.IsSpace(r) != unicode.IsSpace(word[0]) {
+			words = append(words, word)
+			word = []rune{}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words
+}
+
+// trimSpaces removes trailing spaces from a line.
+func trimSpaces(line []rune) []rune {
+	for len(line) > 0 && unicode.IsSpace(line[len(line)-1]) {
+		line = line[:len(line)-1]
+	}
+	return line
 }
 
 // setStr creates an image for the given string returning
@@ -122,34 +389,50 @@ func (f *font) addChar(r rune, x, y, w, h, xo, yo, xa int) {
 // a buffer slice.
 //
 //	wrap : optional (positive) width in pixels for wrapping text.
+//	rtl  : true to layout lines right-to-left.
 //
 // The pixel size and mesh data for the resulting string image is returned.
-func (f *font) setStr(str string, wrap int) (sx, sy int, md load.MeshData) {
-	vx := []float32{} // vec2 vertex data
-	uv := []float32{} // vec2 texcoords
-	ix := []uint16{}  // triangle indexes
+// There is one mesh for each font atlas page used by the string.
+// Page 0 is always returned, even if it has no characters.
+func (f *font) setStr(str string, wrap int, rtl bool) (sx, sy int, mds map[int]load.MeshData) {
+	type pageMesh struct {
+		vx  []float32 // vec2 vertex data
+		uv  []float32 // vec2 texcoords
+		ix  []uint16  // triangle indexes
+		cnt int       // characters rendered.
+	}
+	pages := map[int]*pageMesh{0: {}}
+
+	// right-to-left lines are aligned to the wrap width or the widest line.
+	lines := f.wrapLines(str, wrap)
+	align := wrap
+	if rtl && align <= 0 {
+		for _, line := range lines {
+			align = max(align, f.measure(line))
+		}
+	}
 
 	// gather and arrange the letters for the phrase.
-	width, height, fh, cnt := 0, 0, 0, 0
-	for _, char := range str {
-		c := f.chars[char]
-		if c == nil {
-			// replace unavailable characters with "."
-			c = f.chars['.']
+	height := 0
+	for _, line := range lines {
+		width := 0
+		if rtl {
+			slices.Reverse(line)
+			width = align - f.measure(line)
 		}
-		switch {
-		case c != nil:
-			fh = c.h // remember font height for wrapping with newlines.
-			uv = append(uv, c.uvcs...)
-			xo, yo := float32(c.xOffset), float32(c.yOffset)
+		for i, r := range line {
+			c := f.glyph(r)
+			if c == nil {
+				continue
+			}
 			if c.w != 0 && c.h != 0 {
-
-				// calculate the x, y positions based on desired locations.
-				vx = append(vx,
-					float32(width)+xo, float32(height)+yo, // 0,0,
-					float32(c.w+width)+xo, float32(height)+yo, // 1,0
-					float32(width)+xo, float32(c.h+height)+yo, // 0,1
-					float32(c.w+width)+xo, float32(c.h+height)+yo) // 1,1
+				pm, ok := pages[c.page]
+				if !ok {
+					pm = &pageMesh{}
+					pages[c.page] = pm
+				}
+				pm.vx, pm.uv, pm.ix = c.quad(width, height, pm.vx, pm.uv, pm.ix)
+				pm.cnt++ // count characters rendered.
 
 				// keep track of the max size in pixels.
 				if sx < c.w+width {
@@ -159,27 +442,28 @@ func (f *font) setStr(str string, wrap int) (sx, sy int, md load.MeshData) {
 					sy = c.h + height
 				}
 			}
-			width += c.xAdvance
-			if wrap > 0 && (width > wrap && char == ' ') {
-				width = 0
-				height += c.h
+			next := rune(0)
+			if i+1 < len(line) {
+				next = line[i+1]
 			}
-
-			// create the triangles indexes referring to the points above.
-			i0 := uint16(cnt * 4)
-			ix = append(ix, i0, i0+2, i0+1, i0+1, i0+2, i0+3)
-			cnt++ // count characters rendered.
-		case char == '\n':
-			// auto wrap at newlines.
-			width = 0
-			height += fh
+			width += f.advance(r, next)
 		}
+		height += f.lineh
 	}
-	md = make(load.MeshData, load.VertexTypes)
-	md[load.Vertexes] = load.F32Buffer(vx, 2)  // vec2
-	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
-	md[load.Indexes] = load.U16Buffer(ix)
-	return sx, sy, md
+	mds = map[int]load.MeshData{}
+	for page, pm := range pages {
+		if pm.cnt == 0 {
+			// a zero sized quad keeps the label valid for empty strings.
+			pm.vx, pm.uv = make([]float32, 8), make([]float32, 8)
+			pm.ix = []uint16{0, 2, 1, 1, 2, 3}
+		}
+		md := make(load.MeshData, load.VertexTypes)
+		md[load.Vertexes] = load.F32Buffer(pm.vx, 2)  // vec2
+		md[load.Texcoords] = load.F32Buffer(pm.uv, 2) // vec2
+		md[load.Indexes] = load.U16Buffer(pm.ix)
+		mds[page] = md
+	}
+	return sx, sy, mds
 }
 
 // uvs calculates the four UV points for one character.
@@ -209,9 +493,29 @@ type char struct {
 	xOffset  int       // Current position offset for texture to screen.
 	yOffset  int       // Current position offset for texture to screen.
 	xAdvance int       // Current position advance after drawing character.
+	page     int       // Font atlas page.
 	uvcs     []float32 // Character bitmap texture coordinates 0:0, 1:0, 0:1, 1:1.
 }
 
+// quad appends the vertexes, texture coordinates, and triangle indexes
+// for drawing this character with its pen position at x, y.
+func (c *char) quad(x, y int, vx, uv []float32, ix []uint16) ([]float32, []float32, []uint16) {
+	uv = append(uv, c.uvcs...)
+	xo, yo := float32(c.xOffset), float32(c.yOffset)
+
+	// create the triangles indexes referring to the points below.
+	i0 := uint16(len(vx) / 2) // 2 floats per vertex.
+	ix = append(ix, i0, i0+2, i0+1, i0+1, i0+2, i0+3)
+
+	// calculate the x, y positions based on desired locations.
+	vx = append(vx,
+		float32(x)+xo, float32(y)+yo, // 0,0,
+		float32(c.w+x)+xo, float32(y)+yo, // 1,0
+		float32(x)+xo, float32(c.h+y)+yo, // 0,1
+		float32(c.w+x)+xo, float32(c.h+y)+yo) // 1,1
+	return vx, uv, ix
+}
+
 // =============================================================================
 // support for writing font strings to images.
 
@@ -245,6 +549,9 @@ func (f *font) writeText(str string, xoff, yoff int, dst *image.NRGBA) error {
 	if dst == nil {
 		return fmt.Errorf("writeText nil image")
 	}
+	if f.sdf {
+		return fmt.Errorf("writeText needs a bitmap font: %s", f.name)
+	}
 	imgw := dst.Bounds().Size().X
 	imgh := dst.Bounds().Size().Y
 	px, py := xoff, yoff // starting pixel locations.
@@ -254,19 +561,17 @@ func (f *font) writeText(str string, xoff, yoff int, dst *image.NRGBA) error {
 
 	// gather and arrange the letters for the phrase.
 	// Don't complain if the string runs off the edge or bottom of the destination image.
-	src := f.img // copy from the font bitmap image
-	srcw := src.Bounds().Size().X
-	srch := src.Bounds().Size().Y
+	runes := []rune(str)
 	width, height := px, py //
-	for _, char := range str {
-		c := f.chars[char]
-		if c == nil {
-			// replace unavailable characters with "."
-			// If the "." char is nil, then ignore the character.
-			c = f.chars['.']
-		}
+	for i, char := range runes {
+		// replace unavailable characters with "."
+		// If the "." char is nil, then ignore the character.
+		c := f.glyph(char)
 		switch {
-		case c != nil:
+		case c != nil && c.page < len(f.imgs):
+			src := f.imgs[c.page] // copy from the font bitmap image
+			srcw := src.Bounds().Size().X
+			srch := src.Bounds().Size().Y
 			xo, yo := c.xOffset, c.yOffset
 			if c.w != 0 && c.h != 0 && len(c.uvcs) == 8 {
 				uvx0, uvy0 := c.uvcs[0], c.uvcs[1] // 0,0
@@ -282,7 +587,11 @@ func (f *font) writeText(str string, xoff, yoff int, dst *image.NRGBA) error {
 				// copy character glyph to destination text block image
 				draw.Draw(dst, dstRect, src, srcRect.Min, draw.Over)
 			}
-			width += c.xAdvance
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			width += f.advance(char, next)
 		}
 	}
 	return nil
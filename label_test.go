@@ -3,7 +3,9 @@
 package vu
 
 import (
+	"encoding/binary"
 	"image"
+	"math"
 	"testing"
 
 	// DEBUG to dump text block image as png.
@@ -22,9 +24,10 @@ func TestLabel(t *testing.T) {
 	f := newFont("lucon18")
 	f.w, f.h = int(atlas.Img.Width), int(atlas.Img.Height)
 	for _, g := range atlas.Glyphs {
-		f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa)
+		f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa, g.Page)
 	}
-	sx, sy, md := f.setStr("X", 0)
+	sx, sy, mds := f.setStr("X", 0, false)
+	md := mds[0]
 	if sx != 13 || sy != 18 {
 		t.Errorf("expected size 13:18 got %d:%d", sx, sy)
 	}
@@ -47,6 +50,61 @@ func TestLabel(t *testing.T) {
 	// md[load.Indexes].PrintU16()
 }
 
+// go test -run Layout
+func TestLabelLayout(t *testing.T) {
+	f := newFont("test")
+	f.setSize(100, 100)
+	for i, r := range "abc .é" {
+		f.addChar(r, i*10, 0, 10, 10, 0, 0, 10, 0)
+	}
+	f.addChar('ж', 0, 0, 10, 10, 0, 0, 10, 1) // second atlas page.
+
+	t.Run("utf8", func(t *testing.T) {
+		sx, sy, mds := f.setStr("éa", 0, false)
+		if sx != 20 || sy != 10 {
+			t.Errorf("expected size 20:10 got %d:%d", sx, sy)
+		}
+		if cnt := mds[0][load.Indexes].Count; cnt != 12 {
+			t.Errorf("expected 2 characters got %d indexes", cnt)
+		}
+	})
+	t.Run("pages", func(t *testing.T) {
+		_, _, mds := f.setStr("aжa", 0, false)
+		if len(mds) != 2 {
+			t.Fatalf("expected 2 pages got %d", len(mds))
+		}
+		if cnt := mds[1][load.Indexes].Count; cnt != 6 {
+			t.Errorf("expected 1 page 1 character got %d indexes", cnt)
+		}
+		if _, _, mds = f.setStr("ж", 0, false); mds[0] == nil {
+			t.Errorf("expected page 0 mesh data")
+		}
+	})
+	t.Run("kerning", func(t *testing.T) {
+		f.addKern('a', 'b', -3)
+		defer delete(f.kerns, [2]rune{'a', 'b'})
+		if sx, _, _ := f.setStr("ab", 0, false); sx != 17 {
+			t.Errorf("expected kerned width 17 got %d", sx)
+		}
+	})
+	t.Run("wrap", func(t *testing.T) {
+		sx, sy, _ := f.setStr("abc abc ab", 70, false)
+		if sx != 70 || sy != 20 {
+			t.Errorf("expected size 70:20 got %d:%d", sx, sy)
+		}
+		if _, sy, _ = f.setStr("a\nb", 0, false); sy != 20 {
+			t.Errorf("expected 2 lines got height %d", sy)
+		}
+	})
+	t.Run("rtl", func(t *testing.T) {
+		_, _, mds := f.setStr("ab", 50, true)
+		vb := mds[0][load.Vertexes]
+		if x := math.Float32frombits(binary.LittleEndian.Uint32(vb.Data[0:4])); x != 30 {
+			t.Errorf("expected right aligned first character at 30 got %f", x)
+		}
+	})
+}
+
 // go test -run Text
 func TestTextBlock(t *testing.T) {
 	atlas, err := load.TTFont("18:lucon.ttf")
@@ -54,10 +112,10 @@ func TestTextBlock(t *testing.T) {
 		t.Fatalf("unexpected font load error: %s", err)
 	}
 	f := newFont("lucon18")
-	f.img = atlas.NRGBA
+	f.imgs = []*image.NRGBA{atlas.NRGBA}
 	f.w, f.h = int(atlas.Img.Width), int(atlas.Img.Height)
 	for _, g := range atlas.Glyphs {
-		f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa)
+		f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa, g.Page)
 	}
 
 	imgSize := 256 // image width and height in pixels
@@ -88,3 +146,20 @@ func TestTextBlock(t *testing.T) {
 		}
 	})
 }
+
+// go test -run LabelEffects
+func TestLabelEffects(t *testing.T) {
+	eng := &Engine{app: newApplication()}
+	defer eng.app.ld.dispose()
+	scene := eng.AddScene(Scene3D)
+	text := scene.AddLabel("text", 0, "shd:sdf", "fnt:lucon48sdf", "tex:color:lucon48sdf")
+	text.SetTextOutline(0.25, 0, 0, 0, 1).SetTextGlow(0.5).SetTextSoftness(2)
+	args := eng.app.models.get(text.eid).uniforms[load.ARGS4]
+	got := [4]float32{}
+	for i := range got {
+		got[i] = math.Float32frombits(binary.LittleEndian.Uint32(args[i*4:]))
+	}
+	if got != [4]float32{0.25, 0.5, 1, 0} {
+		t.Errorf("expected outline, glow, and clamped softness got %v", got)
+	}
+}
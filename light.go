@@ -2,12 +2,28 @@
 
 package vu
 
-// light.go holder for lighting related code.
-// FUTURE: different types of lights and more lights.
+// light.go holder for lighting related code, eg:
+//
+//	lamp := scene.AddLight(SpotLight).SetAt(0, 4, 0).SetSpin(-90, 0, 0)
+//	lamp.SetLight(1, 0.9, 0.7, 40).SetSpotCone(20, 35).SetLightRange(12)
+//	panel := scene.AddLight(AreaLight).SetAt(0, 3, -5).SetAreaSize(4, 1)
+//
+// Point, spot, and area lights fade with distance using an attenuation
+// profile. The default inverse square profile matches real lights and is
+// faded smoothly to zero at the light range so that lights stop at a known
+// distance. Spot lights shine along the light direction, fading between
+// the inner and outer cone angles. Area lights approximate a rectangular
+// light shining from its facing side, lighting from the closest point on
+// the rectangle. Lights face down the light -Z axis, use SetSpin or SetView
+// to aim them.
+//
+// FUTURE: more lights and shadows.
 
 import (
 	"log/slog"
+	"math"
 
+	"github.com/gazed/vu/math/lin"
 	"github.com/gazed/vu/render"
 )
 
@@ -15,6 +31,15 @@ import (
 const (
 	DirectionalLight = iota // no falloff ie: the sun
 	PointLight              // falloff ie: light bulb
+	SpotLight               // point light limited to a cone ie: flashlight
+	AreaLight               // rectangle shining one way ie: window, panel
+)
+
+// Light attenuation profiles, see Entity.SetLightFalloff.
+const (
+	InverseSquareFalloff = iota // physically based, faded out at the range.
+	LinearFalloff               // fades evenly to zero at the range.
+	NoFalloff                   // full brightness up to the range.
 )
 
 // AddLight adds a light to a scene.
@@ -55,6 +80,79 @@ func (e *Entity) SetLight(r, g, b, intensity float32) *Entity {
 	return e
 }
 
+// SetLightRange sets the distance where point, spot, and area lights
+// reach zero. Inverse square lights are faded to zero at the range.
+// The default range 0 means unlimited, in which case only inverse
+// square lights fade with distance.
+//
+// Depends on Entity.AddLight.
+func (e *Entity) SetLightRange(distance float64) *Entity {
+	if l := e.app.lights.get(e.eid); l != nil {
+		l.reach = float32(max(distance, 0))
+		return e
+	}
+	slog.Error("SetLightRange needs AddLight", "eid", e.eid)
+	return e
+}
+
+// SetLightFalloff sets how point, spot, and area lights dim with
+// distance using one of the light attenuation profiles:
+// InverseSquareFalloff, LinearFalloff, or NoFalloff.
+// The default is InverseSquareFalloff.
+//
+// Depends on Entity.AddLight.
+func (e *Entity) SetLightFalloff(profile int) *Entity {
+	l := e.app.lights.get(e.eid)
+	if l == nil {
+		slog.Error("SetLightFalloff needs AddLight", "eid", e.eid)
+		return e
+	}
+	if profile < InverseSquareFalloff || profile > NoFalloff {
+		slog.Error("SetLightFalloff invalid profile", "profile", profile)
+		return e
+	}
+	l.falloff = profile
+	return e
+}
+
+// SetSpotCone sets the spot light cone as the angles in degrees from
+// the light direction. The light is full strength inside the inner angle
+// and fades to nothing at the outer angle. The default is 25 and 35.
+//
+// Depends on Entity.AddLight with SpotLight.
+func (e *Entity) SetSpotCone(inner, outer float64) *Entity {
+	l := e.app.lights.get(e.eid)
+	if l == nil || l.kind != SpotLight {
+		slog.Error("SetSpotCone needs SpotLight", "eid", e.eid)
+		return e
+	}
+	if inner < 0 || outer < inner || outer >= 90 {
+		slog.Error("SetSpotCone invalid cone", "inner", inner, "outer", outer)
+		return e
+	}
+	l.inner, l.outer = inner, outer
+	return e
+}
+
+// SetAreaSize sets the width and height of an area light rectangle.
+// The width is along the light X axis and the height along the Y axis.
+// The default is 1 by 1.
+//
+// Depends on Entity.AddLight with AreaLight.
+func (e *Entity) SetAreaSize(width, height float64) *Entity {
+	l := e.app.lights.get(e.eid)
+	if l == nil || l.kind != AreaLight {
+		slog.Error("SetAreaSize needs AreaLight", "eid", e.eid)
+		return e
+	}
+	if width <= 0 || height <= 0 {
+		slog.Error("SetAreaSize invalid size", "width", width, "height", height)
+		return e
+	}
+	l.width, l.height = width, height
+	return e
+}
+
 // =============================================================================
 // light data.
 
@@ -64,6 +162,12 @@ type light struct {
 	kind      int     // light type
 	r, g, b   float32 // Light color: values are 0 to 1.
 	intensity float32 //
+	reach     float32 // distance where the light stops, 0 for unlimited.
+	falloff   int     // attenuation profile.
+
+	// spot light cone angles and area light size.
+	inner, outer  float64 // degrees from the light direction.
+	width, height float64 // area light rectangle.
 }
 
 // newLight creates a white light.
@@ -74,6 +178,34 @@ func newLight(lightType int) (l *light) {
 		g:         1.0, //   ""
 		b:         1.0, //   ""
 		intensity: 5.0, // default intensity.
+		inner:     25,  // default spot cone.
+		outer:     35,  //   ""
+		width:     1,   // default area size.
+		height:    1,   //   ""
+	}
+}
+
+// fill sets the render light from the light values and the light
+// location and rotation. Directional lights only use the location.
+func (l *light) fill(rl *render.Light, at *lin.V3, rot *lin.Q) {
+	rl.X, rl.Y, rl.Z = float32(at.X), float32(at.Y), float32(at.Z)
+	rl.W = float32(l.kind)
+	rl.R, rl.G, rl.B = l.r, l.g, l.b
+	rl.Intensity = l.intensity
+	dx, dy, dz := lin.MultSQ(0, 0, -1, rot)
+	rl.DX, rl.DY, rl.DZ = float32(dx), float32(dy), float32(dz)
+	rl.Range = l.reach
+	rx, ry, rz := lin.MultSQ(1, 0, 0, rot)
+	rl.RX, rl.RY, rl.RZ = float32(rx), float32(ry), float32(rz)
+	rl.Falloff = float32(l.falloff)
+	rl.Shape = [4]float32{}
+	switch l.kind {
+	case SpotLight:
+		rl.Shape[0] = float32(math.Cos(lin.Rad(l.inner)))
+		rl.Shape[1] = float32(math.Cos(lin.Rad(l.outer)))
+	case AreaLight:
+		rl.Shape[0] = float32(l.width * 0.5)
+		rl.Shape[1] = float32(l.height * 0.5)
 	}
 }
 
@@ -110,19 +242,6 @@ func (ls *lights) create(light, scene *Entity, lightType int) (l *light) {
 	return l
 }
 
-// fillPass populates the render.Pass data with the light information
-// at the given pov.
-func (ls *lights) fillLight(light *render.Light, lid eID, pov *pov) {
-	l := ls.data[lid]
-	px, py, pz := pov.at()
-	light.X = float32(px)
-	light.Y = float32(py)
-	light.Z = float32(pz)
-	light.R = l.r
-	light.G = l.g
-	light.B = l.b
-}
-
 // dispose the light associated for the given entity. Do nothing
 // if no such light exists.
 func (ls *lights) dispose(id eID) {
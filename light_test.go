@@ -0,0 +1,66 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Light
+func TestLight(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	scene.AddLight(DirectionalLight).SetAt(-1, -2, -2)
+	spot := scene.AddLight(SpotLight).SetAt(0, 4, 0).SetSpin(-90, 0, 0)
+	spot.SetSpotCone(30, 60).SetLightRange(10).SetLightFalloff(LinearFalloff)
+	area := scene.AddLight(AreaLight).SetAt(0, 3, -5).SetAreaSize(4, 2)
+	passes := app.scenes.getFrame(app, 0, app.frame)
+	lights := passes[render.Pass3D].Lights
+
+	// go test -run Light/spot
+	t.Run("spot", func(t *testing.T) {
+		l := lights[1]
+		if l.W != SpotLight || l.Y != 4 || !lin.Aeq(float64(l.DY), -1) {
+			t.Errorf("expected spot light pointing down got %+v", l)
+		}
+		if !lin.Aeq(float64(l.Shape[0]), math.Sqrt(3)/2) || !lin.Aeq(float64(l.Shape[1]), 0.5) {
+			t.Errorf("expected cone cosines got %v", l.Shape)
+		}
+		if l.Range != 10 || l.Falloff != LinearFalloff {
+			t.Errorf("expected linear falloff range got %f %f", l.Range, l.Falloff)
+		}
+	})
+
+	// go test -run Light/area
+	t.Run("area", func(t *testing.T) {
+		l := lights[2]
+		if l.W != AreaLight || l.DZ != -1 || l.RX != 1 || l.Shape[0] != 2 || l.Shape[1] != 1 {
+			t.Errorf("expected area light facing -Z got %+v", l)
+		}
+		if l.Falloff != InverseSquareFalloff || l.Range != 0 {
+			t.Errorf("expected unlimited inverse square falloff")
+		}
+		if n := passes[render.Pass3D].Uniforms[load.NLIGHTS][0]; n != 3 {
+			t.Errorf("expected 3 lights got %d", n)
+		}
+	})
+
+	// go test -run Light/invalid
+	t.Run("invalid", func(t *testing.T) {
+		area.SetSpotCone(10, 20).SetLightFalloff(7)
+		spot.SetSpotCone(50, 40).SetAreaSize(1, 1)
+		if l := app.lights.get(spot.eid); l.inner != 30 || l.outer != 60 {
+			t.Errorf("expected spot cone unchanged")
+		}
+		if l := app.lights.get(area.eid); l.falloff != InverseSquareFalloff || l.width != 4 {
+			t.Errorf("expected area light unchanged")
+		}
+	})
+}
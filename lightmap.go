@@ -0,0 +1,404 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// lightmap.go bakes the light reaching static models into lightmap
+// images for cheap global illumination, eg:
+//
+//	img, err := room.BakeLightmap(256, 64, 0.4, 0.5, 0.6)
+//	// save img as room_lm.png, then in later runs:
+//	room := scene.AddModel("shd:lightmap", "msh:room", "tex:color:bricks", "tex:lightmap:room_lm")
+//
+// Lightmapped meshes have a second set of texture coordinates, glTF
+// TEXCOORD_1, that lay out the mesh triangles without overlap. Each
+// lightmap pixel is lit by tracing rays from the matching point on the
+// model surface. Rays towards each scene light check if the light is
+// blocked by scene parts. Rays scattered over the surface hemisphere
+// gather the sky light and the light bounced once off other parts, so
+// that corners darken and bright surfaces tint their neighbours. Baking
+// runs on the CPU and is meant for tools and loading screens, not for
+// each frame.
+//
+// A lightmap pixel holds half of the light that a white surface would
+// reflect so that lightmaps can brighten surfaces up to twice their
+// color. The lightmap shader doubles the lightmap color.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// BakeLightmap returns a size by size lightmap image with the light reaching
+// the surface of this model from the scene lights, the sky, and one bounce
+// off the other scene parts. Samples is the number of hemisphere rays used
+// for each lightmap pixel, where 0 bakes only the direct light. The sky color
+// is the light from rays that leave the scene. Every scene light is baked,
+// not just the lights used by the shaders. Parts must be in place, so bake
+// after the scene has been updated at least once.
+//
+// Depends on Entity.AddModel with a mesh that has lightmap texture
+// coordinates, see load.Texcoords2, and on the MeshTriangles attribute
+// for loaded meshes.
+func (e *Entity) BakeLightmap(size, samples int, skyR, skyG, skyB float64) (img *image.NRGBA, err error) {
+	m, p := e.app.models.get(e.eid), e.app.povs.get(e.eid)
+	if m == nil || p == nil {
+		return nil, fmt.Errorf("BakeLightmap needs AddModel: %d", e.eid)
+	}
+	if m.mesh != nil && m.mesh.bounded && len(m.mesh.faces) == 0 {
+		return nil, fmt.Errorf("BakeLightmap needs MeshTriangles: %d", e.eid)
+	}
+	if m.mesh == nil || len(m.mesh.uv2) == 0 {
+		return nil, fmt.Errorf("BakeLightmap needs lightmap texture coordinates: %d", e.eid)
+	}
+	scene := sceneRoot(e.app.povs, e.eid)
+	if sc := e.app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		return nil, fmt.Errorf("BakeLightmap needs 3D scene: %d", e.eid)
+	}
+	if size < 1 || size > maxLightmapSize || samples < 0 {
+		return nil, fmt.Errorf("BakeLightmap invalid size %d or samples %d", size, samples)
+	}
+	e.app.spatial.update(e.app)
+	b := &baker{app: e.app, scene: scene, samples: samples, size: size}
+	b.sky = lin.V3{X: skyR, Y: skyG, Z: skyB}
+	b.random = rand.New(rand.NewSource(1)) // repeatable bakes.
+	b.gatherLights()
+	b.bake(m.mesh, p.wm)
+	b.dilate()
+	return b.image(), nil
+}
+
+// =============================================================================
+// lightmap baking.
+
+const (
+	maxLightmapSize = 4096  // largest lightmap width and height.
+	lightmapBias    = 0.001 // ray start offset to avoid self hits.
+	lightmapDilate  = 2     // pixels grown past the triangle edges.
+	lightmapAlbedo  = 0.5   // bounce color for parts without a color.
+)
+
+// baker holds the scene lights and the lightmap light while baking.
+type baker struct {
+	app     *application
+	scene   eID
+	lights  []render.Light // all scene lights.
+	sky     lin.V3         // light from rays that miss the scene.
+	samples int            // hemisphere rays for each pixel.
+	random  *rand.Rand
+
+	size   int      // lightmap width and height.
+	light  []lin.V3 // light for each pixel.
+	filled []bool   // true for pixels covered by the mesh.
+}
+
+// gatherLights collects the scene lights in the same way as the
+// scene render pass, without the shader limit on the number of lights.
+func (b *baker) gatherLights() {
+	povs := b.app.povs
+	for _, kid := range povs.getNode(b.scene).kids {
+		ki, ok := povs.index[kid]
+		if !ok || povs.nodes[ki].cull {
+			continue
+		}
+		kp := povs.povs[ki]
+		if l := b.app.lights.get(kp.eid); l != nil {
+			rl := render.Light{}
+			l.fill(&rl, kp.tn.Loc, kp.tn.Rot)
+			b.lights = append(b.lights, rl)
+		}
+	}
+}
+
+// bake lights each lightmap pixel whose center is covered by one
+// of the mesh triangles laid out using the lightmap texture coordinates.
+func (b *baker) bake(msh *mesh, wm *lin.M4) {
+	b.light = make([]lin.V3, b.size*b.size)
+	b.filled = make([]bool, b.size*b.size)
+	size := float64(b.size)
+	for i := 0; i+2 < len(msh.faces); i += 3 {
+		var at, norm [3]lin.V3
+		var uv [3][2]float64
+		for j := range at {
+			v := msh.faces[i+j]
+			at[j] = worldPoint(&msh.verts[v], wm)
+			uv[j] = [2]float64{msh.uv2[v][0] * size, msh.uv2[v][1] * size}
+		}
+		face := faceNormal(&at[0], &at[1], &at[2])
+		for j := range norm {
+			norm[j] = face
+			if len(msh.norms) > 0 {
+				norm[j] = worldDirection(&msh.norms[msh.faces[i+j]], wm)
+			}
+		}
+
+		// visit the pixels inside the triangle bounds.
+		x0 := max(int(math.Floor(min(uv[0][0], uv[1][0], uv[2][0]))), 0)
+		x1 := min(int(math.Ceil(max(uv[0][0], uv[1][0], uv[2][0]))), b.size-1)
+		y0 := max(int(math.Floor(min(uv[0][1], uv[1][1], uv[2][1]))), 0)
+		y1 := min(int(math.Ceil(max(uv[0][1], uv[1][1], uv[2][1]))), b.size-1)
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				pixel := y*b.size + x
+				w, inside := barycentric(uv, float64(x)+0.5, float64(y)+0.5)
+				if !inside || b.filled[pixel] {
+					continue
+				}
+				pos, n := lin.V3{}, lin.V3{}
+				for j := range w {
+					pos.X += at[j].X * w[j]
+					pos.Y += at[j].Y * w[j]
+					pos.Z += at[j].Z * w[j]
+					n.X += norm[j].X * w[j]
+					n.Y += norm[j].Y * w[j]
+					n.Z += norm[j].Z * w[j]
+				}
+				if n.AeqZ() {
+					n = face
+				}
+				b.light[pixel] = b.gather(&pos, n.Unit())
+				b.filled[pixel] = true
+			}
+		}
+	}
+}
+
+// gather returns the light reflected by a white surface at the world
+// location pos with surface normal n: the direct light plus the sky
+// and bounced light arriving from the hemisphere around the normal.
+func (b *baker) gather(pos, n *lin.V3) (light lin.V3) {
+	origin := lin.V3{X: pos.X + n.X*lightmapBias, Y: pos.Y + n.Y*lightmapBias, Z: pos.Z + n.Z*lightmapBias}
+	light = b.direct(&origin, n)
+	light.Scale(&light, 1/math.Pi) // lambert diffuse.
+	if b.samples == 0 {
+		return light
+	}
+
+	// cosine weighted hemisphere rays average to the reflected light.
+	indirect := lin.V3{}
+	tangent, bitangent := hemisphere(n)
+	for i := 0; i < b.samples; i++ {
+		r1, r2 := b.random.Float64(), b.random.Float64()
+		sin, cos := math.Sincos(2 * math.Pi * r1)
+		r, up := math.Sqrt(r2), math.Sqrt(1-r2)
+		dir := lin.V3{
+			X: tangent.X*r*cos + bitangent.X*r*sin + n.X*up,
+			Y: tangent.Y*r*cos + bitangent.Y*r*sin + n.Y*up,
+			Z: tangent.Z*r*cos + bitangent.Z*r*sin + n.Z*up,
+		}
+		indirect.Add(&indirect, b.bounce(&origin, dir.Unit()))
+	}
+	indirect.Scale(&indirect, 1/float64(b.samples))
+	return *light.Add(&light, &indirect)
+}
+
+// bounce returns the light arriving along the ray. Rays that leave
+// the scene see the sky. Rays that hit a part see the direct light
+// reflected off the part using the part color.
+func (b *baker) bounce(origin, dir *lin.V3) *lin.V3 {
+	eid, t, face, hit := b.app.spatial.castRay(b.app, b.scene, *origin, *dir, math.Inf(1))
+	if !hit {
+		return &b.sky
+	}
+	m, p := b.app.models.get(eid), b.app.povs.get(eid)
+	msh := m.mesh
+	v0 := worldPoint(&msh.verts[msh.faces[face]], p.wm)
+	v1 := worldPoint(&msh.verts[msh.faces[face+1]], p.wm)
+	v2 := worldPoint(&msh.verts[msh.faces[face+2]], p.wm)
+	n := faceNormal(&v0, &v1, &v2)
+	if n.Dot(dir) > 0 {
+		n.Neg(&n) // face the ray.
+	}
+	s := t - lightmapBias
+	at := lin.V3{X: origin.X + dir.X*s, Y: origin.Y + dir.Y*s, Z: origin.Z + dir.Z*s}
+	light := b.direct(&at, &n)
+	albedo := lin.V3{X: lightmapAlbedo, Y: lightmapAlbedo, Z: lightmapAlbedo}
+	if m.mat != nil {
+		albedo = lin.V3{X: float64(m.mat.color.r), Y: float64(m.mat.color.g), Z: float64(m.mat.color.b)}
+	}
+	light.Mult(&light, &albedo)
+	return light.Scale(&light, 1/math.Pi)
+}
+
+// direct returns the light reaching the world location pos from
+// the scene lights that are not blocked by scene parts.
+func (b *baker) direct(pos, n *lin.V3) (light lin.V3) {
+	for i := range b.lights {
+		rl := &b.lights[i]
+		l, dist, atten := bakeFalloff(rl, pos)
+		ndotl := n.Dot(&l)
+		if ndotl <= 0 || atten <= 0 {
+			continue
+		}
+		if _, _, _, blocked := b.app.spatial.castRay(b.app, b.scene, *pos, l, dist); blocked {
+			continue
+		}
+		s := float64(rl.Intensity) * atten * ndotl
+		light.X += float64(rl.R) * s
+		light.Y += float64(rl.G) * s
+		light.Z += float64(rl.B) * s
+	}
+	return light
+}
+
+// dilate grows the lit pixels into the empty pixels around them so that
+// texture filtering at the triangle edges does not blend in unlit pixels.
+func (b *baker) dilate() {
+	grown := make([]bool, len(b.filled))
+	for pass := 0; pass < lightmapDilate; pass++ {
+		copy(grown, b.filled)
+		for y := 0; y < b.size; y++ {
+			for x := 0; x < b.size; x++ {
+				if b.filled[y*b.size+x] {
+					continue
+				}
+				sum, count := lin.V3{}, 0.0
+				for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+					nx, ny := x+d[0], y+d[1]
+					if nx >= 0 && nx < b.size && ny >= 0 && ny < b.size && b.filled[ny*b.size+nx] {
+						sum.Add(&sum, &b.light[ny*b.size+nx])
+						count++
+					}
+				}
+				if count > 0 {
+					b.light[y*b.size+x] = *sum.Scale(&sum, 1/count)
+					grown[y*b.size+x] = true
+				}
+			}
+		}
+		copy(b.filled, grown)
+	}
+}
+
+// image returns the baked light as a lightmap, storing half the light.
+func (b *baker) image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, b.size, b.size))
+	channel := func(v float64) uint8 { return uint8(lin.Clamp(v*0.5, 0, 1)*255 + 0.5) }
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			l := b.light[y*b.size+x]
+			img.SetNRGBA(x, y, color.NRGBA{R: channel(l.X), G: channel(l.Y), B: channel(l.Z), A: 255})
+		}
+	}
+	return img
+}
+
+// bakeFalloff returns the unit vector towards the light, the distance
+// to the light, and how much of the light reaches the world location.
+// Matches the lightFalloff function in the lighting shaders.
+func bakeFalloff(rl *render.Light, at *lin.V3) (l lin.V3, dist, atten float64) {
+	pos := lin.V3{X: float64(rl.X), Y: float64(rl.Y), Z: float64(rl.Z)}
+	kind := int(rl.W)
+	if kind == DirectionalLight {
+		l.Neg(&pos).Unit()
+		return l, math.Inf(1), 1
+	}
+	dir := lin.V3{X: float64(rl.DX), Y: float64(rl.DY), Z: float64(rl.DZ)}
+	to := lin.V3{}
+	to.Sub(&pos, at)
+	if kind == AreaLight {
+		right := lin.V3{X: float64(rl.RX), Y: float64(rl.RY), Z: float64(rl.RZ)}
+		up := lin.V3{}
+		up.Cross(&right, &dir)
+		hw, hh := float64(rl.Shape[0]), float64(rl.Shape[1])
+		x := lin.Clamp(-to.Dot(&right), -hw, hw)
+		y := lin.Clamp(-to.Dot(&up), -hh, hh)
+		to.X, to.Y, to.Z = to.X+right.X*x+up.X*y, to.Y+right.Y*x+up.Y*y, to.Z+right.Z*x+up.Z*y
+	}
+	dist = max(to.Len(), 0.0001)
+	l.Scale(&to, 1/dist)
+	cone := 1.0
+	switch kind {
+	case SpotLight:
+		inner, outer := float64(rl.Shape[0]), float64(rl.Shape[1])
+		c := lin.Clamp((-l.Dot(&dir)-outer)/max(inner-outer, 0.0001), 0, 1)
+		cone = c * c * (3 - 2*c) // smoothstep.
+	case AreaLight:
+		cone = max(-l.Dot(&dir), 0)
+	}
+
+	// attenuation profiles.
+	reach := float64(rl.Range)
+	atten = 1.0
+	switch {
+	case int(rl.Falloff) == InverseSquareFalloff:
+		atten = 1 / max(dist*dist, 0.01)
+		if reach > 0 {
+			window := lin.Clamp(1-math.Pow(dist/reach, 4), 0, 1)
+			atten *= window * window
+		}
+	case reach > 0 && int(rl.Falloff) == LinearFalloff:
+		atten = lin.Clamp(1-dist/reach, 0, 1)
+	case reach > 0 && dist > reach:
+		atten = 0
+	}
+	return l, dist, atten * cone
+}
+
+// barycentric returns the barycentric weights of the point x,y
+// in the 2D triangle t, and true if the point is inside the triangle.
+func barycentric(t [3][2]float64, x, y float64) (w [3]float64, inside bool) {
+	area := (t[1][0]-t[0][0])*(t[2][1]-t[0][1]) - (t[2][0]-t[0][0])*(t[1][1]-t[0][1])
+	if math.Abs(area) < lin.Epsilon {
+		return w, false // degenerate triangle.
+	}
+	w[1] = ((x-t[0][0])*(t[2][1]-t[0][1]) - (t[2][0]-t[0][0])*(y-t[0][1])) / area
+	w[2] = ((t[1][0]-t[0][0])*(y-t[0][1]) - (x-t[0][0])*(t[1][1]-t[0][1])) / area
+	w[0] = 1 - w[1] - w[2]
+	const edge = -1e-9 // include points on the edges.
+	return w, w[0] >= edge && w[1] >= edge && w[2] >= edge
+}
+
+// hemisphere returns two unit vectors perpendicular to the normal n
+// and to each other.
+func hemisphere(n *lin.V3) (tangent, bitangent lin.V3) {
+	axis := lin.V3{X: 1}
+	if math.Abs(n.X) > 0.9 {
+		axis = lin.V3{Y: 1}
+	}
+	tangent.Cross(&axis, n).Unit()
+	bitangent.Cross(n, &tangent)
+	return tangent, bitangent
+}
+
+// faceNormal returns the unit normal of the counter-clockwise triangle a,b,c.
+func faceNormal(a, b, c *lin.V3) (n lin.V3) {
+	e1, e2 := lin.V3{}, lin.V3{}
+	e1.Sub(b, a)
+	e2.Sub(c, a)
+	n.Cross(&e1, &e2)
+	if n.AeqZ() {
+		return lin.V3{Y: 1} // degenerate triangle.
+	}
+	n.Unit()
+	return n
+}
+
+// worldPoint returns the local point v moved to world space
+// using the world matrix wm.
+func worldPoint(v *lin.V3, wm *lin.M4) lin.V3 {
+	return lin.V3{
+		X: v.X*wm.Xx + v.Y*wm.Yx + v.Z*wm.Zx + wm.Wx,
+		Y: v.X*wm.Xy + v.Y*wm.Yy + v.Z*wm.Zy + wm.Wy,
+		Z: v.X*wm.Xz + v.Y*wm.Yz + v.Z*wm.Zz + wm.Wz,
+	}
+}
+
+// worldDirection returns the local direction v rotated to world space
+// using the world matrix wm. Expects uniform scaling.
+func worldDirection(v *lin.V3, wm *lin.M4) lin.V3 {
+	d := lin.V3{
+		X: v.X*wm.Xx + v.Y*wm.Yx + v.Z*wm.Zx,
+		Y: v.X*wm.Xy + v.Y*wm.Yy + v.Z*wm.Zy,
+		Z: v.X*wm.Xz + v.Y*wm.Yz + v.Z*wm.Zz,
+	}
+	if d.AeqZ() {
+		return *v
+	}
+	return *d.Unit()
+}
@@ -0,0 +1,97 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Lightmap
+func TestLightmap(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	scene.AddLight(DirectionalLight).SetAt(0, -1, 0).SetLight(1, 1, 1, 2) // shining down.
+	scene.AddModel("msh:cube").SetAt(0, 1, 0)                             // blocks the light.
+
+	// a 10x10 floor facing up with lightmap coordinates covering the lightmap.
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer([]float32{-5, 0, -5, 5, 0, -5, 5, 0, 5, -5, 0, 5}, 3)
+	md[load.Normals] = load.F32Buffer([]float32{0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0}, 3)
+	md[load.Texcoords2] = load.F32Buffer([]float32{0, 0, 1, 0, 1, 1, 0, 1}, 2)
+	md[load.Indexes] = load.U16Buffer([]uint16{0, 3, 2, 0, 2, 1})
+	msh := newMesh("floor")
+	msh.setBounds(md, true)
+	floor := scene.AddModel("msh:cube")
+	app.models.get(floor.eid).mesh = msh
+	app.povs.setWorldMatrix(app.work, 0)
+
+	// go test -run Lightmap/direct
+	t.Run("direct", func(t *testing.T) {
+		img, err := floor.BakeLightmap(16, 0, 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		light := 2 / math.Pi // white surface lit straight on.
+		lit := uint8(light*0.5*255 + 0.5)
+		if c := img.NRGBAAt(1, 1); c.R != lit || c.G != lit || c.A != 255 {
+			t.Errorf("expected lit corner %d got %v", lit, c)
+		}
+		if c := img.NRGBAAt(8, 8); c.R != 0 {
+			t.Errorf("expected shadow under the cube got %v", c)
+		}
+	})
+
+	// go test -run Lightmap/indirect
+	t.Run("indirect", func(t *testing.T) {
+		img, err := floor.BakeLightmap(16, 32, 0.2, 0.2, 0.2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shadow, corner := img.NRGBAAt(8, 8), img.NRGBAAt(1, 1)
+		if shadow.R == 0 || shadow.R >= corner.R {
+			t.Errorf("expected sky light in the shadow got %v %v", shadow, corner)
+		}
+		again, _ := floor.BakeLightmap(16, 32, 0.2, 0.2, 0.2)
+		if again.NRGBAAt(8, 8) != shadow {
+			t.Errorf("expected repeatable bakes")
+		}
+	})
+
+	// go test -run Lightmap/falloff
+	t.Run("falloff", func(t *testing.T) {
+		spot := &render.Light{Y: 4, W: SpotLight, R: 1, Intensity: 1, DY: -1, Range: 8}
+		spot.Shape = [4]float32{float32(math.Cos(lin.Rad(20))), float32(math.Cos(lin.Rad(30)))}
+		at := lin.V3{}
+		l, dist, atten := bakeFalloff(spot, &at)
+		window := 1 - math.Pow(4.0/8, 4)
+		if !lin.Aeq(l.Y, 1) || !lin.Aeq(dist, 4) || !lin.Aeq(atten, window*window/16) {
+			t.Errorf("expected windowed inverse square got %v %f %f", l, dist, atten)
+		}
+		at.X = 4 // 45 degrees is outside the cone.
+		if _, _, atten = bakeFalloff(spot, &at); atten != 0 {
+			t.Errorf("expected no light outside the cone got %f", atten)
+		}
+		spot.Falloff = LinearFalloff
+		if _, _, atten = bakeFalloff(spot, &lin.V3{}); !lin.Aeq(atten, 0.5) {
+			t.Errorf("expected linear falloff got %f", atten)
+		}
+	})
+
+	// go test -run Lightmap/errors
+	t.Run("errors", func(t *testing.T) {
+		cube := scene.AddModel("msh:cube")
+		if _, err := cube.BakeLightmap(16, 0, 0, 0, 0); err == nil {
+			t.Errorf("expected missing lightmap coordinates error")
+		}
+		if _, err := floor.BakeLightmap(0, 0, 0, 0, 0); err == nil {
+			t.Errorf("expected invalid size error")
+		}
+	})
+}
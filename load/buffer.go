@@ -7,6 +7,8 @@ package load
 
 import (
 	"fmt"
+	"log/slog"
+	"strings"
 	"unsafe"
 )
 
@@ -47,28 +49,30 @@ func U16Buffer(indexes []uint16) Buffer {
 	}
 }
 
-// PrintF32 dumps bytes as float32 vertices. Used to debug mesh data.
-// eg: md[load.Vertexes].PrintF32()
+// PrintF32 logs the bytes as float32 vertices. Used to debug mesh data.
+// eg: md[load.Vertexes].PrintF32("Vertexes")
 func (buff Buffer) PrintF32(name string) {
 	vsize := len(buff.Data) / 4 // number of vertexes.
 	vx := unsafe.Slice((*float32)(unsafe.Pointer(&buff.Data[0])), vsize)
-	fmt.Printf("%s:%d\n", name, len(vx))
 	dim := vsize / int(buff.Count)
+	b := &strings.Builder{}
 	for i := 0; i < len(vx); i += dim {
 		switch dim {
 		case 2:
-			fmt.Printf("  %+f,%+f,\n", vx[i], vx[i+1])
+			fmt.Fprintf(b, "\n  %+f,%+f,", vx[i], vx[i+1])
 		case 3:
-			fmt.Printf("  %+f,%+f,%+f,\n", vx[i], vx[i+1], vx[i+2])
+			fmt.Fprintf(b, "\n  %+f,%+f,%+f,", vx[i], vx[i+1], vx[i+2])
 		}
 	}
+	slog.Info("mesh data", "name", name, "size", len(vx), "data", b.String())
 }
 
-// PrintU16 dumps bytes as uint16 triangle indexes. Used to debug mesh data.
+// PrintU16 logs the bytes as uint16 triangle indexes. Used to debug mesh data.
 func (buff Buffer) PrintU16(name string) {
 	ix := unsafe.Slice((*uint16)(unsafe.Pointer(&buff.Data[0])), buff.Count)
-	fmt.Printf("%s:%d\n", name, len(ix))
+	b := &strings.Builder{}
 	for i := 0; i < len(ix); i += 3 {
-		fmt.Printf("  %d,%d,%d,\n", ix[i], ix[i+1], ix[i+2])
+		fmt.Fprintf(b, "\n  %d,%d,%d,", ix[i], ix[i+1], ix[i+2])
 	}
+	slog.Info("mesh data", "name", name, "size", len(ix), "data", b.String())
 }
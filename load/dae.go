@@ -0,0 +1,1261 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// dae.go imports Collada 1.4 and 1.5 ".dae" scenes, eg:
+//
+//	assets := load.Model("robot.dae")
+//
+// The Collada document is translated into a gltf document so that
+// Collada scenes are combined into a model using the same rules as
+// gltf scenes, see glb.go:
+//   - geometry triangles, polylists, and polygons become mesh primitives.
+//   - scene nodes keep their hierarchy and transforms. The scene is
+//     rotated and scaled to Y up meters when the document asset says so.
+//   - one skin controller becomes the model skeleton, and the node
+//     animations that target the joints become the animation clips.
+//     Animation clips are taken from library_animation_clips when
+//     present, otherwise all the animations form a single clip.
+//   - common profile effects become solid or base color texture materials.
+//
+// Morph controllers, cameras, lights, physics, and instanced nodes are ignored.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gazed/vu/internal/load/gltf"
+)
+
+// Dae imports a Collada document from a ".dae" file. It returns the
+// same assets as Glb: the model mesh data, followed by the material
+// textures and material data, followed by the animation data for
+// skinned models.
+func Dae(name string, data []byte) []AssetData {
+	doc := &daeDoc{}
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("collada %s: %w", name, err)}}
+	}
+	d := &dae{doc: doc, gltf: &gltf.Document{}}
+	if err := d.convert(); err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("collada %s: %w", name, err)}}
+	}
+	g := &glb{doc: d.gltf, dir: assetDirs[getFileExtension(name)]}
+	assets, err := g.assets(name)
+	if err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("collada %s: %w", name, err)}}
+	}
+	return assets
+}
+
+// =============================================================================
+// collada xml elements.
+
+// daeDoc is the subset of a COLLADA document used by the engine.
+type daeDoc struct {
+	XMLName xml.Name `xml:"COLLADA"`
+	Asset   struct {
+		Unit struct {
+			Meter float64 `xml:"meter,attr"`
+		} `xml:"unit"`
+		UpAxis string `xml:"up_axis"`
+	} `xml:"asset"`
+	Images       []daeImage       `xml:"library_images>image"`
+	Effects      []daeEffect      `xml:"library_effects>effect"`
+	Materials    []daeMaterial    `xml:"library_materials>material"`
+	Geometries   []daeGeometry    `xml:"library_geometries>geometry"`
+	Controllers  []daeController  `xml:"library_controllers>controller"`
+	Animations   []daeAnimation   `xml:"library_animations>animation"`
+	Clips        []daeClip        `xml:"library_animation_clips>animation_clip"`
+	VisualScenes []daeVisualScene `xml:"library_visual_scenes>visual_scene"`
+	Scene        daeInstance      `xml:"scene>instance_visual_scene"`
+}
+
+// daeImage is an image file. Collada 1.4 puts the file name
+// directly in init_from, Collada 1.5 puts it in init_from>ref.
+type daeImage struct {
+	ID       string `xml:"id,attr"`
+	InitFrom struct {
+		Path string `xml:",chardata"`
+		Ref  string `xml:"ref"`
+	} `xml:"init_from"`
+}
+
+// daeEffect is a material shading description.
+type daeEffect struct {
+	ID      string     `xml:"id,attr"`
+	Params  []daeParam `xml:"profile_COMMON>newparam"`
+	Phong   *daeShader `xml:"profile_COMMON>technique>phong"`
+	Blinn   *daeShader `xml:"profile_COMMON>technique>blinn"`
+	Lambert *daeShader `xml:"profile_COMMON>technique>lambert"`
+}
+
+// daeParam links effect textures to images.
+type daeParam struct {
+	Sid     string `xml:"sid,attr"`
+	Surface string `xml:"surface>init_from"` // image id, Collada 1.4.
+	Source  string `xml:"sampler2D>source"`  // surface param sid, Collada 1.4.
+	Image   struct {
+		URL string `xml:"url,attr"` // image url, Collada 1.5.
+	} `xml:"sampler2D>instance_image"`
+}
+
+// daeShader holds the common profile shading values.
+type daeShader struct {
+	Diffuse struct {
+		Color   string `xml:"color"`
+		Texture struct {
+			Texture string `xml:"texture,attr"` // sampler param sid or image id.
+		} `xml:"texture"`
+	} `xml:"diffuse"`
+	Shininess *float64 `xml:"shininess>float"`
+}
+
+// daeMaterial references an effect.
+type daeMaterial struct {
+	ID     string `xml:"id,attr"`
+	Name   string `xml:"name,attr"`
+	Effect struct {
+		URL string `xml:"url,attr"`
+	} `xml:"instance_effect"`
+}
+
+// daeSource is an array of values with an accessor stride.
+type daeSource struct {
+	ID       string `xml:"id,attr"`
+	Floats   string `xml:"float_array"`
+	Names    string `xml:"Name_array"`
+	IDRefs   string `xml:"IDREF_array"`
+	Accessor struct {
+		Stride int `xml:"stride,attr"`
+	} `xml:"technique_common>accessor"`
+}
+
+// daeInput references a source for one semantic, eg: POSITION.
+type daeInput struct {
+	Semantic string `xml:"semantic,attr"`
+	Source   string `xml:"source,attr"`
+	Offset   int    `xml:"offset,attr"`
+	Set      int    `xml:"set,attr"`
+}
+
+// daeGeometry is a named mesh.
+type daeGeometry struct {
+	ID   string   `xml:"id,attr"`
+	Name string   `xml:"name,attr"`
+	Mesh *daeMesh `xml:"mesh"`
+}
+
+// daeMesh holds the mesh sources and the polygon primitives.
+type daeMesh struct {
+	Sources  []daeSource `xml:"source"`
+	Vertices struct {
+		ID     string     `xml:"id,attr"`
+		Inputs []daeInput `xml:"input"`
+	} `xml:"vertices"`
+	Triangles []daePrimitive `xml:"triangles"`
+	Polylists []daePrimitive `xml:"polylist"`
+	Polygons  []daePrimitive `xml:"polygons"`
+}
+
+// daePrimitive is a list of polygons that share a material.
+type daePrimitive struct {
+	Material string     `xml:"material,attr"`
+	Inputs   []daeInput `xml:"input"`
+	VCount   string     `xml:"vcount"`
+	P        []string   `xml:"p"`
+}
+
+// daeController is a skin applied to a geometry.
+type daeController struct {
+	ID   string   `xml:"id,attr"`
+	Skin *daeSkin `xml:"skin"`
+}
+
+// daeSkin holds the joints and the vertex joint weights.
+type daeSkin struct {
+	Source    string      `xml:"source,attr"`
+	BindShape string      `xml:"bind_shape_matrix"`
+	Sources   []daeSource `xml:"source"`
+	Joints    []daeInput  `xml:"joints>input"`
+	Weights   struct {
+		Inputs []daeInput `xml:"input"`
+		VCount string     `xml:"vcount"`
+		V      string     `xml:"v"`
+	} `xml:"vertex_weights"`
+}
+
+// daeAnimation holds keyframe channels and nested animations.
+type daeAnimation struct {
+	ID         string         `xml:"id,attr"`
+	Sources    []daeSource    `xml:"source"`
+	Samplers   []daeSampler   `xml:"sampler"`
+	Channels   []daeTarget    `xml:"channel"`
+	Animations []daeAnimation `xml:"animation"`
+}
+
+// daeSampler combines keyframe times, values, and interpolation.
+type daeSampler struct {
+	ID     string     `xml:"id,attr"`
+	Inputs []daeInput `xml:"input"`
+}
+
+// daeTarget links a sampler to a node transform, eg: "hip/rotateZ.ANGLE".
+type daeTarget struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// daeClip is a named time range of one or more animations.
+type daeClip struct {
+	Name      string  `xml:"name,attr"`
+	ID        string  `xml:"id,attr"`
+	Start     float64 `xml:"start,attr"`
+	End       float64 `xml:"end,attr"`
+	Instances []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"instance_animation"`
+}
+
+// daeVisualScene is a node hierarchy.
+type daeVisualScene struct {
+	ID    string    `xml:"id,attr"`
+	Nodes []daeNode `xml:"node"`
+}
+
+// daeNode is a scene node. The node transform elements, eg: matrix,
+// translate, rotate, and scale, are applied in document order.
+type daeNode struct {
+	ID          string         `xml:"id,attr"`
+	Sid         string         `xml:"sid,attr"`
+	Name        string         `xml:"name,attr"`
+	Nodes       []daeNode      `xml:"node"`
+	Geometries  []daeInstance  `xml:"instance_geometry"`
+	Controllers []daeInstance  `xml:"instance_controller"`
+	Transforms  []daeTransform `xml:",any"`
+}
+
+// daeTransform is one node transform element.
+type daeTransform struct {
+	XMLName xml.Name
+	Sid     string `xml:"sid,attr"`
+	Values  string `xml:",chardata"`
+}
+
+// daeInstance references a library element. Instanced geometry
+// binds the primitive material symbols to materials.
+type daeInstance struct {
+	URL       string   `xml:"url,attr"`
+	Skeletons []string `xml:"skeleton"`
+	Materials []struct {
+		Symbol string `xml:"symbol,attr"`
+		Target string `xml:"target,attr"`
+	} `xml:"bind_material>technique_common>instance_material"`
+}
+
+// =============================================================================
+// collada to gltf.
+
+// dae translates one collada document into a gltf document.
+type dae struct {
+	doc    *daeDoc
+	gltf   *gltf.Document
+	buff   []byte                 // gltf buffer data.
+	nodes  []*daeNode             // gltf node index to collada node.
+	parent []int                  // parent node index, -1 for root nodes.
+	xforms [][]daeXform           // node transform elements.
+	ids    map[string]uint32      // collada node id to gltf node index.
+	mats   map[string]*uint32     // collada material id to gltf material index.
+	skins  map[string]*daeSkinned // gltf skin by collada controller id.
+}
+
+// daeXform is a parsed node transform element.
+type daeXform struct {
+	kind   string // matrix, translate, rotate, or scale.
+	sid    string
+	values []float64
+}
+
+// convert builds the gltf scene, meshes, skins, and animations.
+func (d *dae) convert() error {
+	d.ids = map[string]uint32{}
+	d.mats = map[string]*uint32{}
+	d.skins = map[string]*daeSkinned{}
+	if len(d.doc.VisualScenes) == 0 {
+		return fmt.Errorf("expecting a visual scene")
+	}
+	vs := &d.doc.VisualScenes[0]
+	if ref := daeRef(d.doc.Scene.URL); ref != "" {
+		i := slices.IndexFunc(d.doc.VisualScenes, func(s daeVisualScene) bool { return s.ID == ref })
+		if i < 0 {
+			return fmt.Errorf("invalid visual scene %s", d.doc.Scene.URL)
+		}
+		vs = &d.doc.VisualScenes[i]
+	}
+
+	// the optional root node converts the scene to Y up meters.
+	scene := &gltf.Scene{}
+	d.gltf.Scenes = []*gltf.Scene{scene}
+	root, parent := d.root(), -1
+	if root != glbIdentity {
+		d.addNode(&daeNode{Name: "collada"}, -1)
+		d.gltf.Nodes[0].Matrix = root
+		scene.Nodes, parent = []uint32{0}, 0
+	}
+	for i := range vs.Nodes {
+		n, err := d.node(&vs.Nodes[i], parent)
+		if err != nil {
+			return err
+		}
+		if parent < 0 {
+			scene.Nodes = append(scene.Nodes, n)
+		}
+	}
+
+	// meshes are added once all the skin joints are known.
+	for i, n := range d.nodes {
+		for _, inst := range n.Geometries {
+			if err := d.geometry(uint32(i), &inst, nil); err != nil {
+				return err
+			}
+		}
+		for _, inst := range n.Controllers {
+			if err := d.controller(uint32(i), &inst); err != nil {
+				return err
+			}
+		}
+	}
+	if err := d.animations(); err != nil {
+		return err
+	}
+	d.gltf.Buffers = []*gltf.Buffer{{ByteLength: uint32(len(d.buff)), Data: d.buff}}
+	return nil
+}
+
+// root returns the transform from the document up axis and units
+// to the engine Y up meters.
+func (d *dae) root() (m glbM4) {
+	m = glbIdentity
+	switch strings.TrimSpace(d.doc.Asset.UpAxis) {
+	case "Z_UP":
+		m = glbM4{1, 0, 0, 0, 0, 0, -1, 0, 0, 1, 0, 0, 0, 0, 0, 1}
+	case "X_UP":
+		m = glbM4{0, 1, 0, 0, -1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+	}
+	if meter := d.doc.Asset.Unit.Meter; meter > 0 && meter != 1 {
+		m = m.mult(glbTRS{r: gltf.DefaultRotation, s: [3]float64{meter, meter, meter}}.matrix())
+	}
+	return m
+}
+
+// addNode adds a gltf node for the collada node.
+func (d *dae) addNode(n *daeNode, parent int) uint32 {
+	index := uint32(len(d.gltf.Nodes))
+	name := n.Name
+	if name == "" {
+		name = n.ID
+	}
+	d.gltf.Nodes = append(d.gltf.Nodes, &gltf.Node{Name: name})
+	d.nodes = append(d.nodes, n)
+	d.parent = append(d.parent, parent)
+	d.xforms = append(d.xforms, nil)
+	if parent >= 0 {
+		d.gltf.Nodes[parent].Children = append(d.gltf.Nodes[parent].Children, index)
+	}
+	if n.ID != "" {
+		d.ids[n.ID] = index
+	}
+	return index
+}
+
+// node adds the collada node and its children to the gltf nodes.
+func (d *dae) node(n *daeNode, parent int) (index uint32, err error) {
+	index = d.addNode(n, parent)
+	for _, t := range n.Transforms {
+		switch t.XMLName.Local {
+		case "matrix", "translate", "rotate", "scale":
+			vals, err := daeFloats(t.Values)
+			if err != nil {
+				return 0, fmt.Errorf("node %s %s: %w", n.ID, t.XMLName.Local, err)
+			}
+			x := daeXform{kind: t.XMLName.Local, sid: t.Sid, values: vals}
+			if len(vals) != x.size() {
+				return 0, fmt.Errorf("node %s: invalid %s", n.ID, x.kind)
+			}
+			d.xforms[index] = append(d.xforms[index], x)
+		}
+	}
+	d.gltf.Nodes[index].Matrix = daeLocal(d.xforms[index])
+	for i := range n.Nodes {
+		if _, err := d.node(&n.Nodes[i], int(index)); err != nil {
+			return 0, err
+		}
+	}
+	return index, nil
+}
+
+// size returns the number of values for the transform element.
+func (x daeXform) size() int {
+	switch x.kind {
+	case "matrix":
+		return 16
+	case "rotate":
+		return 4
+	}
+	return 3
+}
+
+// daeLocal returns the combined transform of the node transform elements.
+func daeLocal(xforms []daeXform) glbM4 {
+	m := glbIdentity
+	for _, x := range xforms {
+		v := x.values
+		switch x.kind {
+		case "matrix":
+			m = m.mult(daeMatrix(v))
+		case "translate":
+			m = m.mult(glbTRS{t: [3]float64{v[0], v[1], v[2]}, r: gltf.DefaultRotation, s: gltf.DefaultScale}.matrix())
+		case "rotate":
+			axis := []float64{v[0], v[1], v[2]}
+			glbNormalize(axis)
+			s, c := math.Sincos(v[3] * math.Pi / 360) // half angle in radians.
+			r := [4]float64{axis[0] * s, axis[1] * s, axis[2] * s, c}
+			m = m.mult(glbTRS{r: r, s: gltf.DefaultScale}.matrix())
+		case "scale":
+			m = m.mult(glbTRS{r: gltf.DefaultRotation, s: [3]float64{v[0], v[1], v[2]}}.matrix())
+		}
+	}
+	return m
+}
+
+// daeMatrix converts a collada row-major matrix to a gltf column-major matrix.
+func daeMatrix(v []float64) (m glbM4) {
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m[col*4+row] = v[row*4+col]
+		}
+	}
+	return m
+}
+
+// =============================================================================
+// meshes and skins.
+
+// geometry adds the instanced geometry as the mesh of the given node.
+// Skinned geometry has joint weights for each geometry position.
+func (d *dae) geometry(node uint32, inst *daeInstance, skin *daeWeights) error {
+	ref := daeRef(inst.URL)
+	i := slices.IndexFunc(d.doc.Geometries, func(g daeGeometry) bool { return g.ID == ref })
+	if i < 0 {
+		return fmt.Errorf("invalid geometry %s", inst.URL)
+	}
+	geom := &d.doc.Geometries[i]
+	if geom.Mesh == nil {
+		slog.Warn("collada geometry is not a mesh", "geometry", geom.ID)
+		return nil
+	}
+	if d.gltf.Nodes[node].Mesh != nil {
+		return fmt.Errorf("expecting one mesh for node %s", d.gltf.Nodes[node].Name)
+	}
+	mesh := &gltf.Mesh{Name: geom.Name}
+	prims := [][]daePrimitive{geom.Mesh.Triangles, geom.Mesh.Polylists, geom.Mesh.Polygons}
+	for kind, list := range prims {
+		for _, p := range list {
+			prim, err := d.primitive(geom.Mesh, &p, kind, skin)
+			if err != nil {
+				return fmt.Errorf("geometry %s: %w", geom.ID, err)
+			}
+			if prim == nil {
+				continue // no polygons.
+			}
+			for _, m := range inst.Materials {
+				if m.Symbol == p.Material {
+					if prim.Material, err = d.material(daeRef(m.Target)); err != nil {
+						return err
+					}
+				}
+			}
+			mesh.Primitives = append(mesh.Primitives, prim)
+		}
+	}
+	if len(mesh.Primitives) == 0 {
+		return nil
+	}
+	d.gltf.Meshes = append(d.gltf.Meshes, mesh)
+	d.gltf.Nodes[node].Mesh = gltf.Index(uint32(len(d.gltf.Meshes) - 1))
+	return nil
+}
+
+// collada primitive kinds.
+const (
+	daeTriangles = iota
+	daePolylist
+	daePolygons
+)
+
+// primitive converts collada polygons to an indexed gltf triangle
+// primitive. Collada indexes each vertex attribute separately so
+// vertexes are created for each unique combination of indexes.
+func (d *dae) primitive(mesh *daeMesh, p *daePrimitive, kind int, skin *daeWeights) (*gltf.Primitive, error) {
+	type input struct {
+		src    *daeSource
+		vals   []float64
+		offset int
+	}
+	var pos, nrm, uv *input
+	stride := 0
+	for _, in := range p.Inputs {
+		stride = max(stride, in.Offset+1)
+		inputs := []daeInput{in}
+		if in.Semantic == "VERTEX" {
+			inputs = mesh.Vertices.Inputs // expand the vertex inputs.
+		}
+		for _, vi := range inputs {
+			target := (**input)(nil)
+			switch {
+			case vi.Semantic == "POSITION" && pos == nil:
+				target = &pos
+			case vi.Semantic == "NORMAL" && nrm == nil:
+				target = &nrm
+			case vi.Semantic == "TEXCOORD" && uv == nil:
+				target = &uv
+			default:
+				continue // unsupported or additional input.
+			}
+			src := daeSourceRef(mesh.Sources, vi.Source)
+			if src == nil {
+				return nil, fmt.Errorf("invalid source %s", vi.Source)
+			}
+			vals, err := src.floats()
+			if err != nil {
+				return nil, err
+			}
+			*target = &input{src: src, vals: vals, offset: in.Offset}
+		}
+	}
+	if pos == nil {
+		return nil, fmt.Errorf("expecting vertex positions")
+	}
+
+	// polygon vertex counts.
+	var indexes []int
+	var counts []int
+	for _, ps := range p.P {
+		vals, err := daeInts(ps)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, vals...)
+		if kind == daePolygons {
+			counts = append(counts, len(vals)/stride)
+		}
+	}
+	switch kind {
+	case daeTriangles:
+		for i := 0; i < len(indexes)/stride/3; i++ {
+			counts = append(counts, 3)
+		}
+	case daePolylist:
+		var err error
+		if counts, err = daeInts(p.VCount); err != nil {
+			return nil, err
+		}
+	}
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if total*stride != len(indexes) {
+		return nil, fmt.Errorf("invalid primitive indexes")
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	// create the unique vertexes.
+	values := func(in *input, index, size int) ([]float64, error) {
+		step := max(in.src.Accessor.Stride, size)
+		if index < 0 || (index+1)*step > len(in.vals) {
+			return nil, fmt.Errorf("invalid index %d for %s", index, in.src.ID)
+		}
+		return in.vals[index*step : index*step+size], nil
+	}
+	var positions, normals, texcoords, joints, weights []float64
+	vertexes := map[[3]int]int{} // vertex index by position, normal, texcoord index.
+	corners := make([]int, total)
+	for c := range corners {
+		key := [3]int{-1, -1, -1}
+		for i, in := range []*input{pos, nrm, uv} {
+			if in != nil {
+				key[i] = indexes[c*stride+in.offset]
+			}
+		}
+		v, ok := vertexes[key]
+		if !ok {
+			v = len(vertexes)
+			vertexes[key] = v
+			vals, err := values(pos, key[0], 3)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, vals...)
+			if nrm != nil {
+				if vals, err = values(nrm, key[1], 3); err != nil {
+					return nil, err
+				}
+				normals = append(normals, vals...)
+			}
+			if uv != nil {
+				if vals, err = values(uv, key[2], 2); err != nil {
+					return nil, err
+				}
+				texcoords = append(texcoords, vals[0], 1-vals[1]) // collada v is up.
+			}
+			if skin != nil {
+				if key[0]*4 >= len(skin.joints) {
+					return nil, fmt.Errorf("missing skin weights for vertex %d", key[0])
+				}
+				joints = append(joints, skin.joints[key[0]*4:key[0]*4+4]...)
+				weights = append(weights, skin.weights[key[0]*4:key[0]*4+4]...)
+			}
+		}
+		corners[c] = v
+	}
+
+	// triangulate the polygons as triangle fans.
+	tris := []float64{}
+	first := 0
+	for _, count := range counts {
+		for i := 2; i < count; i++ {
+			tris = append(tris, float64(corners[first]), float64(corners[first+i-1]), float64(corners[first+i]))
+		}
+		first += count
+	}
+	if skin != nil {
+		skin.bind.points(positions)
+		skin.bind.directions(normals, 3)
+	}
+	prim := &gltf.Primitive{Attributes: gltf.Attribute{}, Mode: gltf.PrimitiveTriangles}
+	prim.Attributes[gltf.POSITION] = d.accessor(positions, gltf.ComponentFloat, gltf.AccessorVec3)
+	if len(normals) > 0 {
+		prim.Attributes[gltf.NORMAL] = d.accessor(normals, gltf.ComponentFloat, gltf.AccessorVec3)
+	}
+	if len(texcoords) > 0 {
+		prim.Attributes[gltf.TEXCOORD_0] = d.accessor(texcoords, gltf.ComponentFloat, gltf.AccessorVec2)
+	}
+	if skin != nil {
+		prim.Attributes[gltf.JOINTS_0] = d.accessor(joints, gltf.ComponentUshort, gltf.AccessorVec4)
+		prim.Attributes[gltf.WEIGHTS_0] = d.accessor(weights, gltf.ComponentFloat, gltf.AccessorVec4)
+	}
+	prim.Indices = gltf.Index(d.accessor(tris, gltf.ComponentUint, gltf.AccessorScalar))
+	return prim, nil
+}
+
+// daeWeights are the skin joints and weights for each geometry position.
+type daeWeights struct {
+	bind    glbM4     // bind shape transform.
+	joints  []float64 // 4 skin joint indexes per position.
+	weights []float64 // 4 normalized joint weights per position.
+}
+
+// daeSkinned is a gltf skin and the skinned geometry.
+type daeSkinned struct {
+	index   uint32      // gltf skin index.
+	source  string      // skinned geometry url.
+	weights *daeWeights // skinned geometry vertex weights.
+}
+
+// controller adds the skinned geometry as the mesh of the given node.
+func (d *dae) controller(node uint32, inst *daeInstance) error {
+	ref := daeRef(inst.URL)
+	skin, ok := d.skins[ref]
+	if !ok {
+		i := slices.IndexFunc(d.doc.Controllers, func(c daeController) bool { return c.ID == ref })
+		if i < 0 {
+			return fmt.Errorf("invalid controller %s", inst.URL)
+		}
+		if d.doc.Controllers[i].Skin == nil {
+			slog.Warn("collada controller is not a skin", "controller", ref)
+			return nil
+		}
+		var err error
+		if skin, err = d.skin(d.doc.Controllers[i].Skin, inst.Skeletons); err != nil {
+			return fmt.Errorf("controller %s: %w", ref, err)
+		}
+		d.skins[ref] = skin
+	}
+	geom := &daeInstance{URL: skin.source, Materials: inst.Materials}
+	if err := d.geometry(node, geom, skin.weights); err != nil {
+		return err
+	}
+	d.gltf.Nodes[node].Skin = gltf.Index(skin.index)
+	return nil
+}
+
+// skin adds the gltf skin for the collada skin. The skin joints are
+// found by sid under the skeleton root nodes, or by node id or name.
+func (d *dae) skin(s *daeSkin, skeletons []string) (*daeSkinned, error) {
+	var names []string
+	var ibm []float64
+	for _, in := range s.Joints {
+		src := daeSourceRef(s.Sources, in.Source)
+		if src == nil {
+			return nil, fmt.Errorf("invalid source %s", in.Source)
+		}
+		switch in.Semantic {
+		case "JOINT":
+			names = src.names()
+		case "INV_BIND_MATRIX":
+			vals, err := src.floats()
+			if err != nil {
+				return nil, err
+			}
+			ibm = vals
+		}
+	}
+	if len(names) == 0 || len(ibm) != len(names)*16 {
+		return nil, fmt.Errorf("expecting an inverse bind matrix for each joint")
+	}
+	skin := &gltf.Skin{}
+	for i, name := range names {
+		n, ok := d.joint(name, skeletons)
+		if !ok {
+			return nil, fmt.Errorf("joint %s is not in the scene", name)
+		}
+		skin.Joints = append(skin.Joints, n)
+		m := daeMatrix(ibm[i*16 : i*16+16])
+		copy(ibm[i*16:i*16+16], m[:])
+	}
+	skin.InverseBindMatrices = gltf.Index(d.accessor(ibm, gltf.ComponentFloat, gltf.AccessorMat4))
+	d.gltf.Skins = append(d.gltf.Skins, skin)
+
+	// vertex weights, keeping the 4 largest weights of each vertex.
+	w := &daeWeights{bind: glbIdentity}
+	if strings.TrimSpace(s.BindShape) != "" {
+		vals, err := daeFloats(s.BindShape)
+		if err != nil || len(vals) != 16 {
+			return nil, fmt.Errorf("invalid bind shape matrix")
+		}
+		w.bind = daeMatrix(vals)
+	}
+	counts, err := daeInts(s.Weights.VCount)
+	if err != nil {
+		return nil, err
+	}
+	v, err := daeInts(s.Weights.V)
+	if err != nil {
+		return nil, err
+	}
+	jointOffset, weightOffset, stride := -1, -1, 0
+	var weights []float64
+	for _, in := range s.Weights.Inputs {
+		stride = max(stride, in.Offset+1)
+		switch in.Semantic {
+		case "JOINT":
+			jointOffset = in.Offset
+		case "WEIGHT":
+			src := daeSourceRef(s.Sources, in.Source)
+			if src == nil {
+				return nil, fmt.Errorf("invalid source %s", in.Source)
+			}
+			if weights, err = src.floats(); err != nil {
+				return nil, err
+			}
+			weightOffset = in.Offset
+		}
+	}
+	if jointOffset < 0 || weightOffset < 0 {
+		return nil, fmt.Errorf("expecting joint and weight inputs")
+	}
+	type influence struct {
+		joint  int
+		weight float64
+	}
+	for _, count := range counts {
+		if len(v) < count*stride {
+			return nil, fmt.Errorf("invalid vertex weights")
+		}
+		influences := []influence{}
+		for i := 0; i < count; i++ {
+			j, wi := v[i*stride+jointOffset], v[i*stride+weightOffset]
+			if j >= len(names) || wi < 0 || wi >= len(weights) {
+				return nil, fmt.Errorf("invalid vertex weight")
+			}
+			if j >= 0 { // -1 is the bind shape.
+				influences = append(influences, influence{j, weights[wi]})
+			}
+		}
+		v = v[count*stride:]
+		sort.SliceStable(influences, func(a, b int) bool { return influences[a].weight > influences[b].weight })
+		influences = influences[:min(len(influences), 4)]
+		total := 0.0
+		for _, in := range influences {
+			total += in.weight
+		}
+		var vj, vw [4]float64
+		for i, in := range influences {
+			vj[i], vw[i] = float64(in.joint), in.weight/total
+		}
+		w.joints = append(w.joints, vj[:]...)
+		w.weights = append(w.weights, vw[:]...)
+	}
+	return &daeSkinned{index: uint32(len(d.gltf.Skins) - 1), source: s.Source, weights: w}, nil
+}
+
+// joint returns the node for the given skin joint name.
+func (d *dae) joint(name string, skeletons []string) (uint32, bool) {
+	under := func(n int) bool {
+		if len(skeletons) == 0 {
+			return true
+		}
+		for ; n >= 0; n = d.parent[n] {
+			if slices.Contains(skeletons, "#"+d.nodes[n].ID) {
+				return true
+			}
+		}
+		return false
+	}
+	for i, n := range d.nodes {
+		if n.Sid == name && under(i) {
+			return uint32(i), true
+		}
+	}
+	if n, ok := d.ids[name]; ok {
+		return n, true
+	}
+	for i, n := range d.nodes {
+		if n.Name == name {
+			return uint32(i), true
+		}
+	}
+	return 0, false
+}
+
+// =============================================================================
+// materials.
+
+// material returns the gltf material for the collada material.
+// Common profile shading is approximated with a non-metallic
+// PBR material whose roughness comes from the shininess.
+func (d *dae) material(id string) (*uint32, error) {
+	if index, ok := d.mats[id]; ok {
+		return index, nil
+	}
+	i := slices.IndexFunc(d.doc.Materials, func(m daeMaterial) bool { return m.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("invalid material %s", id)
+	}
+	mat := d.doc.Materials[i]
+	ref := daeRef(mat.Effect.URL)
+	i = slices.IndexFunc(d.doc.Effects, func(e daeEffect) bool { return e.ID == ref })
+	if i < 0 {
+		return nil, fmt.Errorf("invalid effect %s", mat.Effect.URL)
+	}
+	effect := &d.doc.Effects[i]
+	pbr := &gltf.PBRMetallicRoughness{MetallicFactor: gltf.Float(0), RoughnessFactor: gltf.Float(1)}
+	shader := effect.Phong
+	for _, s := range []*daeShader{effect.Blinn, effect.Lambert} {
+		if shader == nil {
+			shader = s
+		}
+	}
+	if shader != nil {
+		if vals, err := daeFloats(shader.Diffuse.Color); err == nil && len(vals) == 4 {
+			color := [4]float64{}
+			for c := range color {
+				color[c] = min(max(vals[c], 0), 1)
+			}
+			pbr.BaseColorFactor = &color
+		}
+		if s := shader.Shininess; s != nil && *s >= 0 {
+			pbr.RoughnessFactor = gltf.Float(min(math.Sqrt(2/(*s+2)), 1))
+		}
+		if tex := shader.Diffuse.Texture.Texture; tex != "" {
+			if image, ok := d.image(effect, tex); ok {
+				d.gltf.Textures = append(d.gltf.Textures, &gltf.Texture{Source: gltf.Index(image)})
+				pbr.BaseColorTexture = &gltf.TextureInfo{Index: uint32(len(d.gltf.Textures) - 1)}
+			}
+		}
+	}
+	d.gltf.Materials = append(d.gltf.Materials, &gltf.Material{Name: mat.Name, PBRMetallicRoughness: pbr})
+	d.mats[id] = gltf.Index(uint32(len(d.gltf.Materials) - 1))
+	return d.mats[id], nil
+}
+
+// image returns the gltf image for an effect texture. The texture
+// references a sampler param, a surface param, and then the image,
+// or references the image directly. Image files are expected in the
+// model asset directory.
+func (d *dae) image(effect *daeEffect, texture string) (uint32, bool) {
+	param := func(sid string) *daeParam {
+		i := slices.IndexFunc(effect.Params, func(p daeParam) bool { return p.Sid == sid })
+		if i < 0 {
+			return nil
+		}
+		return &effect.Params[i]
+	}
+	ref := texture
+	if sampler := param(texture); sampler != nil {
+		ref = daeRef(sampler.Image.URL)
+		if surface := param(sampler.Source); surface != nil {
+			ref = surface.Surface
+		}
+	}
+	i := slices.IndexFunc(d.doc.Images, func(img daeImage) bool { return img.ID == ref })
+	if i < 0 {
+		slog.Warn("collada texture image not found", "texture", texture)
+		return 0, false
+	}
+	file := strings.TrimSpace(d.doc.Images[i].InitFrom.Path)
+	if ref := strings.TrimSpace(d.doc.Images[i].InitFrom.Ref); ref != "" {
+		file = ref
+	}
+	if unescaped, err := url.PathUnescape(strings.TrimPrefix(file, "file://")); err == nil {
+		file = unescaped
+	}
+	file = filepath.ToSlash(file)
+	if !filepath.IsLocal(file) {
+		file = path.Base(file) // absolute paths from the modelling tool.
+	}
+	d.gltf.Images = append(d.gltf.Images, &gltf.Image{URI: path.Clean(file)})
+	return uint32(len(d.gltf.Images) - 1), true
+}
+
+// =============================================================================
+// animations.
+
+// daeChannel is the keyframe data for one animated node transform element.
+type daeChannel struct {
+	node   int       // gltf node index.
+	sid    string    // transform element sid.
+	index  int       // transform element value, -1 for all values.
+	step   bool      // true for step interpolation.
+	times  []float64 // keyframe times in seconds.
+	values []float64 // keyframe values.
+	size   int       // values per keyframe.
+	anims  []string  // ids of the animation and its parents.
+}
+
+// animations adds the collada animations as gltf animations.
+// Each animated node is sampled at the union of its channel keyframe
+// times. The sampled node transforms become translation, rotation,
+// and scale channels.
+func (d *dae) animations() error {
+	var channels []*daeChannel
+	var collect func(a *daeAnimation, ids []string) error
+	collect = func(a *daeAnimation, ids []string) error {
+		ids = append(slices.Clip(ids), a.ID)
+		for _, ch := range a.Channels {
+			c, err := d.channel(a, ch)
+			if err != nil {
+				return fmt.Errorf("animation %s: %w", a.ID, err)
+			}
+			if c != nil {
+				c.anims = ids
+				channels = append(channels, c)
+			}
+		}
+		for i := range a.Animations {
+			if err := collect(&a.Animations[i], ids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := range d.doc.Animations {
+		if err := collect(&d.doc.Animations[i], nil); err != nil {
+			return err
+		}
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	clips := d.doc.Clips
+	if len(clips) == 0 {
+		clips = []daeClip{{End: math.Inf(1)}} // all animations.
+	}
+	for _, clip := range clips {
+		if clip.End <= clip.Start {
+			clip.End = math.Inf(1) // clip without an end time.
+		}
+		var used []*daeChannel
+		for _, c := range channels {
+			for _, inst := range clip.Instances {
+				if slices.Contains(c.anims, daeRef(inst.URL)) {
+					used = append(used, c)
+					break
+				}
+			}
+			if len(clip.Instances) == 0 {
+				used = append(used, c)
+			}
+		}
+		if anim := d.clip(&clip, used); anim != nil {
+			d.gltf.Animations = append(d.gltf.Animations, anim)
+		}
+	}
+	return nil
+}
+
+// daeSelectors are the supported animation target value selectors.
+var daeSelectors = map[string]int{"X": 0, "Y": 1, "Z": 2, "ANGLE": 3}
+
+// channel returns the keyframes for the animation channel, or nil
+// if the channel does not target a supported node transform.
+func (d *dae) channel(a *daeAnimation, target daeTarget) (*daeChannel, error) {
+	nodeID, element, ok := strings.Cut(target.Target, "/")
+	node, found := d.ids[nodeID]
+	if !ok || !found {
+		slog.Warn("collada unsupported animation target", "target", target.Target)
+		return nil, nil
+	}
+	c := &daeChannel{node: int(node), sid: element, index: -1}
+	if sid, sel, ok := strings.Cut(element, "."); ok {
+		c.sid, c.index = sid, -2 // eg: AXIS is not supported.
+		if i, ok := daeSelectors[strings.ToUpper(sel)]; ok {
+			c.index = i
+		}
+	} else if sid, sel, ok := strings.Cut(element, "("); ok {
+		c.sid, c.index = sid, -2 // matrix elements are not supported.
+		if i, err := strconv.Atoi(strings.TrimSuffix(sel, ")")); err == nil {
+			c.index = i
+		}
+	}
+	x := slices.IndexFunc(d.xforms[node], func(x daeXform) bool { return x.sid == c.sid })
+	if x < 0 || c.index < -1 || c.index >= d.xforms[node][x].size() {
+		slog.Warn("collada unsupported animation target", "target", target.Target)
+		return nil, nil
+	}
+	c.size = 1
+	if c.index < 0 {
+		c.size = d.xforms[node][x].size()
+	}
+
+	// keyframes.
+	ref := daeRef(target.Source)
+	i := slices.IndexFunc(a.Samplers, func(s daeSampler) bool { return s.ID == ref })
+	if i < 0 {
+		return nil, fmt.Errorf("invalid sampler %s", target.Source)
+	}
+	for _, in := range a.Samplers[i].Inputs {
+		src := daeSourceRef(a.Sources, in.Source)
+		if src == nil {
+			return nil, fmt.Errorf("invalid source %s", in.Source)
+		}
+		var err error
+		switch in.Semantic {
+		case "INPUT":
+			c.times, err = src.floats()
+		case "OUTPUT":
+			c.values, err = src.floats()
+		case "INTERPOLATION":
+			names := src.names()
+			c.step = len(names) > 0 && names[0] == "STEP"
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.times) == 0 || len(c.values) != len(c.times)*c.size || !sort.Float64sAreSorted(c.times) {
+		return nil, fmt.Errorf("invalid keyframes for %s", target.Target)
+	}
+	return c, nil
+}
+
+// clip returns the gltf animation for the channels in the clip time range.
+func (d *dae) clip(clip *daeClip, channels []*daeChannel) *gltf.Animation {
+	anim := &gltf.Animation{Name: clip.Name}
+	if anim.Name == "" {
+		anim.Name = clip.ID
+	}
+	nodes := map[int][]*daeChannel{}
+	for _, c := range channels {
+		nodes[c.node] = append(nodes[c.node], c)
+	}
+	for node := range d.nodes {
+		chs := nodes[node]
+		if len(chs) == 0 {
+			continue
+		}
+		times := []float64{}
+		for _, c := range chs {
+			for _, t := range c.times {
+				times = append(times, min(max(t, clip.Start), clip.End))
+			}
+		}
+		if !math.IsInf(clip.End, 1) {
+			times = append(times, clip.Start, clip.End)
+		}
+		slices.Sort(times)
+		times = slices.Compact(times)
+
+		// sample the node transform at each keyframe time.
+		var ts, rs, ss []float64
+		xforms := slices.Clone(d.xforms[node])
+		for i, t := range times {
+			for x := range xforms {
+				xforms[x].values = slices.Clone(d.xforms[node][x].values)
+				for _, c := range chs {
+					if c.sid == xforms[x].sid {
+						c.sample(t, xforms[x].values)
+					}
+				}
+			}
+			p := glbDecompose(daeLocal(xforms))
+			if i > 0 && p.r[0]*rs[len(rs)-4]+p.r[1]*rs[len(rs)-3]+p.r[2]*rs[len(rs)-2]+p.r[3]*rs[len(rs)-1] < 0 {
+				p.r = [4]float64{-p.r[0], -p.r[1], -p.r[2], -p.r[3]} // keep rotations continuous.
+			}
+			ts, rs, ss = append(ts, p.t[:]...), append(rs, p.r[:]...), append(ss, p.s[:]...)
+			times[i] = t - clip.Start
+		}
+		input := d.accessor(times, gltf.ComponentFloat, gltf.AccessorScalar)
+		for _, out := range []struct {
+			path  gltf.TRSProperty
+			vals  []float64
+			atype gltf.AccessorType
+		}{
+			{gltf.TRSTranslation, ts, gltf.AccessorVec3},
+			{gltf.TRSRotation, rs, gltf.AccessorVec4},
+			{gltf.TRSScale, ss, gltf.AccessorVec3},
+		} {
+			sampler := &gltf.AnimationSampler{Input: input, Interpolation: gltf.InterpolationLinear}
+			sampler.Output = d.accessor(out.vals, gltf.ComponentFloat, out.atype)
+			anim.Samplers = append(anim.Samplers, sampler)
+			anim.Channels = append(anim.Channels, &gltf.Channel{
+				Sampler: gltf.Index(uint32(len(anim.Samplers) - 1)),
+				Target:  gltf.ChannelTarget{Node: gltf.Index(uint32(node)), Path: out.path},
+			})
+		}
+	}
+	if len(anim.Channels) == 0 {
+		return nil
+	}
+	return anim
+}
+
+// sample updates the transform element values with the channel
+// value at time t. Times outside the keyframes are clamped.
+func (c *daeChannel) sample(t float64, values []float64) {
+	out := values
+	if c.index >= 0 {
+		out = values[c.index : c.index+1]
+	}
+	value := func(k int) []float64 { return c.values[k*c.size : k*c.size+c.size] }
+	k := sort.SearchFloat64s(c.times, t)
+	switch {
+	case k < len(c.times) && c.times[k] == t:
+		copy(out, value(k))
+	case k == 0:
+		copy(out, value(0))
+	case k >= len(c.times):
+		copy(out, value(len(c.times)-1))
+	case c.step:
+		copy(out, value(k-1))
+	default:
+		v0, v1 := value(k-1), value(k)
+		u := (t - c.times[k-1]) / (c.times[k] - c.times[k-1])
+		for i := range out {
+			out[i] = v0[i] + (v1[i]-v0[i])*u
+		}
+	}
+}
+
+// =============================================================================
+// collada data.
+
+// accessor adds the values to the gltf buffer and returns the accessor index.
+func (d *dae) accessor(vals []float64, ctype gltf.ComponentType, atype gltf.AccessorType) uint32 {
+	offset := len(d.buff)
+	for _, v := range vals {
+		switch ctype {
+		case gltf.ComponentUshort:
+			d.buff = binary.LittleEndian.AppendUint16(d.buff, uint16(v))
+		case gltf.ComponentUint:
+			d.buff = binary.LittleEndian.AppendUint32(d.buff, uint32(v))
+		default:
+			d.buff = binary.LittleEndian.AppendUint32(d.buff, math.Float32bits(float32(v)))
+		}
+	}
+	for len(d.buff)%4 != 0 {
+		d.buff = append(d.buff, 0) // keep the views aligned.
+	}
+	view := &gltf.BufferView{ByteOffset: uint32(offset), ByteLength: uint32(len(d.buff) - offset)}
+	d.gltf.BufferViews = append(d.gltf.BufferViews, view)
+	d.gltf.Accessors = append(d.gltf.Accessors, &gltf.Accessor{
+		BufferView:    gltf.Index(uint32(len(d.gltf.BufferViews) - 1)),
+		ComponentType: ctype,
+		Count:         uint32(len(vals) / int(atype.Components())),
+		Type:          atype,
+	})
+	return uint32(len(d.gltf.Accessors) - 1)
+}
+
+// floats returns the source float values.
+func (s *daeSource) floats() ([]float64, error) {
+	vals, err := daeFloats(s.Floats)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", s.ID, err)
+	}
+	return vals, nil
+}
+
+// names returns the source name or id values.
+func (s *daeSource) names() []string {
+	if names := strings.Fields(s.Names); len(names) > 0 {
+		return names
+	}
+	return strings.Fields(s.IDRefs)
+}
+
+// daeSourceRef returns the source for the given url, or nil if not found.
+func daeSourceRef(sources []daeSource, url string) *daeSource {
+	ref := daeRef(url)
+	for i := range sources {
+		if sources[i].ID == ref {
+			return &sources[i]
+		}
+	}
+	return nil
+}
+
+// daeRef returns the element id from a local url, eg: "#id" is "id".
+func daeRef(url string) string { return strings.TrimPrefix(strings.TrimSpace(url), "#") }
+
+// daeFloats parses whitespace separated floats.
+func daeFloats(s string) ([]float64, error) {
+	fields := strings.Fields(s)
+	vals := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", f)
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// daeInts parses whitespace separated integers.
+func daeInts(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	vals := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", f)
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
@@ -0,0 +1,406 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Flac decodes FLAC lossless audio into the same PCM data as Wav.
+// Samples are returned as 8 bit unsigned or 16 bit signed little endian
+// values with the channels interleaved. Samples with more than 16 bits
+// are reduced to 16 bits. The FLAC format is from:
+//   - https://xiph.org/flac/format.html
+//
+// The Reader r is expected to be opened and closed by the caller.
+func Flac(r io.Reader) (aud *AudioData, err error) {
+	aud = &AudioData{}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return aud, fmt.Errorf("Invalid .flac audio file: %s", err)
+	}
+	if len(data) < 4 || string(data[:4]) != "fLaC" {
+		return aud, fmt.Errorf("Invalid .flac audio file")
+	}
+	br := &bitReader{data: data, off: 4}
+	info, err := flacMetadata(br)
+	if err != nil {
+		return aud, fmt.Errorf("Invalid .flac audio file: %w", err)
+	}
+	if info.channels > 8 || info.bps < 4 || info.bps > 24 {
+		return aud, fmt.Errorf("%w: .flac audio channels:%d bits:%d", errors.ErrUnsupported, info.channels, info.bps)
+	}
+
+	// decode the frames until all the samples are decoded. Trailing
+	// data, eg: ID3 or APEv2 tags, is ignored. The header total is only
+	// trusted as far as the data size, the samples grow as needed.
+	samples := make([][]int32, info.channels)
+	for ch := range samples {
+		samples[ch] = make([]int32, 0, min(info.total, uint64(len(data))))
+	}
+	for br.off < len(data) && (info.total == 0 || uint64(len(samples[0])) < info.total) {
+		if !br.frameSync() {
+			break // not a frame.
+		}
+		if samples, err = flacFrame(br, info, samples); err != nil {
+			return aud, fmt.Errorf("Corrupt .flac audio file: %w", err)
+		}
+	}
+	if info.total > 0 && len(samples[0]) > int(info.total) {
+		for ch := range samples {
+			samples[ch] = samples[ch][:info.total]
+		}
+	}
+
+	// interleave the channels using 8 or 16 bit samples.
+	sampleBits := uint16(16)
+	if info.bps <= 8 {
+		sampleBits = 8
+	}
+	pcm := make([]byte, 0, len(samples[0])*int(info.channels)*int(sampleBits/8))
+	for i := range samples[0] {
+		for ch := range samples {
+			s := samples[ch][i]
+			switch {
+			case sampleBits == 8:
+				pcm = append(pcm, byte(s<<(8-info.bps)+128)) // 8 bit PCM is unsigned.
+			case info.bps <= 16:
+				pcm = binary.LittleEndian.AppendUint16(pcm, uint16(s<<(16-info.bps)))
+			default:
+				pcm = binary.LittleEndian.AppendUint16(pcm, uint16(s>>(info.bps-16)))
+			}
+		}
+	}
+	aud.Data = pcm
+	aud.Attrs = &AudioAttributes{
+		Channels:   uint16(info.channels),
+		Frequency:  info.rate,
+		DataSize:   uint32(len(pcm)),
+		SampleBits: sampleBits,
+	}
+	return aud, nil
+}
+
+// flacInfo is the FLAC STREAMINFO metadata.
+type flacInfo struct {
+	rate     uint32 // samples per second.
+	channels uint32 // 1 to 8.
+	bps      uint32 // bits per sample.
+	total    uint64 // samples per channel, 0 if unknown.
+}
+
+// flacMetadata reads the metadata blocks, keeping the stream info.
+func flacMetadata(br *bitReader) (info flacInfo, err error) {
+	found := false
+	for last := uint64(0); last == 0; {
+		last = br.read(1)
+		kind := br.read(7)
+		size := int(br.read(24))
+		switch {
+		case kind == 0 && size == 34: // STREAMINFO
+			br.read(16) // min block size.
+			br.read(16) // max block size.
+			br.read(24) // min frame size.
+			br.read(24) // max frame size.
+			info.rate = uint32(br.read(20))
+			info.channels = uint32(br.read(3)) + 1
+			info.bps = uint32(br.read(5)) + 1
+			info.total = br.read(36)
+			br.skip(16) // MD5 signature.
+			found = true
+		default:
+			br.skip(size) // ignore other metadata.
+		}
+		if br.err != nil {
+			return info, br.err
+		}
+	}
+	if !found || info.rate == 0 {
+		return info, fmt.Errorf("missing stream info")
+	}
+	return info, nil
+}
+
+// flacFrame decodes one frame, appending the samples for each channel.
+func flacFrame(br *bitReader, info flacInfo, samples [][]int32) ([][]int32, error) {
+	if sync := br.read(14); sync != 0x3FFE {
+		return samples, fmt.Errorf("frame sync")
+	}
+	br.read(1) // reserved.
+	br.read(1) // blocking strategy.
+	sizeCode := br.read(4)
+	rateCode := br.read(4)
+	chanCode := br.read(4)
+	bitsCode := br.read(3)
+	br.read(1) // reserved.
+
+	// frame or sample number is UTF-8 coded.
+	for first := br.read(8); first&0xC0 == 0xC0; first = (first << 1) & 0xFF {
+		br.read(8)
+	}
+	blockSize := 0
+	switch {
+	case sizeCode == 1:
+		blockSize = 192
+	case sizeCode >= 2 && sizeCode <= 5:
+		blockSize = 576 << (sizeCode - 2)
+	case sizeCode == 6:
+		blockSize = int(br.read(8)) + 1
+	case sizeCode == 7:
+		blockSize = int(br.read(16)) + 1
+	case sizeCode >= 8:
+		blockSize = 256 << (sizeCode - 8)
+	default:
+		return samples, fmt.Errorf("block size %d", sizeCode)
+	}
+	switch rateCode {
+	case 12:
+		br.read(8) // kHz
+	case 13, 14:
+		br.read(16) // Hz or tens of Hz
+	case 15:
+		return samples, fmt.Errorf("sample rate %d", rateCode)
+	}
+	bps := info.bps
+	switch bitsCode {
+	case 1:
+		bps = 8
+	case 2:
+		bps = 12
+	case 4:
+		bps = 16
+	case 5:
+		bps = 20
+	case 6:
+		bps = 24
+	case 3, 7:
+		return samples, fmt.Errorf("%w: sample size %d", errors.ErrUnsupported, bitsCode)
+	}
+	channels := uint32(chanCode) + 1
+	if chanCode >= 8 {
+		channels = 2 // stereo with the channels decorrelated.
+	}
+	if chanCode > 10 || channels != info.channels || bps != info.bps {
+		return samples, fmt.Errorf("frame format channels:%d bits:%d", channels, bps)
+	}
+	br.read(8) // CRC-8 of the frame header.
+
+	// decode each channel. The side channel has an extra bit.
+	start := len(samples[0])
+	for ch := range samples {
+		sbps := bps
+		if (chanCode == 8 && ch == 1) || (chanCode == 9 && ch == 0) || (chanCode == 10 && ch == 1) {
+			sbps++
+		}
+		samples[ch] = append(samples[ch], make([]int32, blockSize)...)
+		if err := flacSubframe(br, sbps, samples[ch][start:]); err != nil {
+			return samples, err
+		}
+	}
+	if br.err != nil {
+		return samples, br.err
+	}
+
+	// undo the stereo decorrelation.
+	left, right := samples[0][start:], samples[len(samples)-1][start:]
+	for i := 0; i < blockSize && chanCode >= 8; i++ {
+		switch chanCode {
+		case 8: // left, side
+			right[i] = left[i] - right[i]
+		case 9: // side, right
+			left[i] += right[i]
+		case 10: // mid, side
+			mid := left[i]<<1 | right[i]&1
+			left[i], right[i] = (mid+right[i])>>1, (mid-right[i])>>1
+		}
+	}
+	br.align()
+	br.read(16) // CRC-16 of the frame.
+	return samples, br.err
+}
+
+// flacFixed are the fixed predictor coefficients for orders 0 to 4.
+var flacFixed = [][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}
+
+// flacSubframe decodes the samples for one channel of a frame.
+func flacSubframe(br *bitReader, bps uint32, out []int32) error {
+	br.read(1) // zero padding.
+	kind := br.read(6)
+	wasted := uint32(0)
+	if br.read(1) == 1 {
+		wasted = uint32(br.unary()) + 1
+		bps -= min(wasted, bps)
+	}
+	switch {
+	case kind == 0: // constant
+		v := int32(br.signed(bps))
+		for i := range out {
+			out[i] = v
+		}
+	case kind == 1: // verbatim
+		for i := range out {
+			out[i] = int32(br.signed(bps))
+		}
+	case kind >= 8 && kind <= 12: // fixed predictor
+		order := int(kind - 8)
+		if err := flacPredict(br, bps, out, flacFixed[order], 0); err != nil {
+			return err
+		}
+	case kind >= 32: // linear predictor
+		order := int(kind - 31)
+		if order > len(out) {
+			return fmt.Errorf("lpc order %d", order)
+		}
+		for i := 0; i < order; i++ {
+			out[i] = int32(br.signed(bps)) // warm up samples.
+		}
+		precision := uint32(br.read(4)) + 1
+		shift := br.signed(5)
+		if precision == 16 || shift < 0 {
+			return fmt.Errorf("lpc precision:%d shift:%d", precision, shift)
+		}
+		coefs := make([]int64, order)
+		for i := range coefs {
+			coefs[i] = br.signed(precision)
+		}
+		if err := flacPredict(br, 0, out, coefs, uint(shift)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("subframe type %d", kind)
+	}
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return br.err
+}
+
+// flacPredict reads the warm up samples, unless bps is 0 because they
+// have already been read, and the residual. Each sample is then the
+// residual plus the prediction from the previous samples.
+func flacPredict(br *bitReader, bps uint32, out []int32, coefs []int64, shift uint) error {
+	order := len(coefs)
+	if order > len(out) {
+		return fmt.Errorf("predictor order %d", order)
+	}
+	if bps > 0 {
+		for i := 0; i < order; i++ {
+			out[i] = int32(br.signed(bps))
+		}
+	}
+	if err := flacResidual(br, order, out); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		sum := int64(0)
+		for j, c := range coefs {
+			sum += c * int64(out[i-1-j])
+		}
+		out[i] += int32(sum >> shift)
+	}
+	return nil
+}
+
+// flacResidual reads the rice coded prediction errors into out,
+// after the warm up samples.
+func flacResidual(br *bitReader, order int, out []int32) error {
+	method := br.read(2)
+	if method > 1 {
+		return fmt.Errorf("residual method %d", method)
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partitions := 1 << br.read(4)
+	size := len(out) / partitions
+	if len(out)%partitions != 0 || size < order {
+		return fmt.Errorf("residual partitions %d", partitions)
+	}
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * size
+		k := br.read(paramBits)
+		if k == escape {
+			bits := uint32(br.read(5))
+			for ; i < end; i++ {
+				out[i] = int32(br.signed(bits))
+			}
+			continue
+		}
+		for ; i < end; i++ {
+			v := br.unary()<<k | br.read(uint(k))
+			out[i] = int32(v>>1) ^ -int32(v&1) // zig zag decode.
+		}
+		if br.err != nil {
+			return br.err
+		}
+	}
+	return nil
+}
+
+// bitReader reads big endian bit fields. Reading past the end
+// of the data returns zeros and sets err.
+type bitReader struct {
+	data  []byte
+	off   int    // next byte to load into the cache.
+	cache uint64 // unread bits are the low n bits.
+	n     uint   // number of unread bits in the cache.
+	err   error  // set when reading past the end of the data.
+}
+
+// read returns the next n bits, where n is at most 56.
+func (br *bitReader) read(n uint) uint64 {
+	for br.n < n {
+		if br.off >= len(br.data) {
+			br.err = io.ErrUnexpectedEOF
+			return 0
+		}
+		br.cache = br.cache<<8 | uint64(br.data[br.off])
+		br.off++
+		br.n += 8
+	}
+	br.n -= n
+	return br.cache >> br.n & (1<<n - 1)
+}
+
+// signed returns the next n bits as a two's complement value.
+func (br *bitReader) signed(n uint32) int64 {
+	v := int64(br.read(uint(n)))
+	if n > 0 && v&(1<<(n-1)) != 0 {
+		v -= 1 << n
+	}
+	return v
+}
+
+// unary returns the number of 0 bits before the next 1 bit.
+func (br *bitReader) unary() (count uint64) {
+	for br.read(1) == 0 && br.err == nil {
+		count++
+	}
+	return count
+}
+
+// frameSync returns true if the reader is at a FLAC frame sync code.
+func (br *bitReader) frameSync() bool {
+	return br.n == 0 && br.off+1 < len(br.data) && br.data[br.off] == 0xFF && br.data[br.off+1]&0xFE == 0xF8
+}
+
+// align skips to the next byte boundary.
+func (br *bitReader) align() { br.n -= br.n % 8 }
+
+// skip moves past the next size bytes. Expected to be byte aligned.
+func (br *bitReader) skip(size int) {
+	br.align()
+	cached := int(br.n / 8)
+	br.n = 0
+	if br.off += size - cached; br.off > len(br.data) {
+		br.off = len(br.data)
+		br.err = io.ErrUnexpectedEOF
+	}
+}
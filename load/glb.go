@@ -2,130 +2,591 @@
 
 package load
 
-// glb.go imports a subset of the GLTF specification.
+// glb.go imports glTF 2.0 models exported from tools like Blender.
+// Both binary ".glb" files and json ".gltf" files are supported.
+// The ".gltf" external buffers and images are read from the asset
+// directory. The scene nodes are combined into a single model:
+//   - mesh primitives are merged into one mesh. Non-skinned meshes
+//     are transformed by their node hierarchy transforms.
+//   - the material of the first mesh primitive is used for the model.
+//   - one skin is supported. Its joints and the node animations
+//     that target the joints become the model AnimationData.
+//
+// Animations are resampled at a fixed frame rate. Morph targets,
+// cameras, and extensions are ignored.
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
-	"image/png"
+	"image/draw"
+	_ "image/jpeg" // gltf textures are png or jpeg.
 	"io"
+	"io/fs"
 	"log/slog"
+	"math"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
 
 	"github.com/gazed/vu/internal/load/gltf"
 )
 
-// Glb accepts a subset of a binary gltf.Document that describes
-// a single mesh model. The single models limitiations is enforced as follows:
-//   - one Scene
-//   - one Node
-//   - one Mesh
-//   - one Mesh.Primitive
+// Glb imports a glTF 2.0 document from a ".glb" or ".gltf" file.
+// It returns the model mesh data, followed by the material textures
+// and material data, followed by the animation data for skinned models.
+func Glb(name string, r io.Reader) []AssetData {
+	dir := assetDirs[getFileExtension(name)]
+	doc := &gltf.Document{}
+	if err := gltf.NewDecoderFS(r, glbFS(dir)).Decode(doc); err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("gltf %s: %w", name, err)}}
+	}
+	g := &glb{doc: doc, dir: dir}
+	data, err := g.assets(name)
+	if err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("gltf %s: %w", name, err)}}
+	}
+	return data
+}
+
+// glbFrameRate is the frames per second for resampled animations.
+const glbFrameRate = 30
+
+// glb imports one gltf document.
+type glb struct {
+	doc    *gltf.Document
+	dir    string   // asset directory for external images.
+	parent []int    // parent node index, -1 for root nodes.
+	world  []glbM4  // node rest transforms.
+	order  []uint32 // scene nodes, parents before children.
+}
+
+// assets converts the gltf document into engine asset data.
+func (g *glb) assets(name string) (data []AssetData, err error) {
+	if err = g.scene(); err != nil {
+		return nil, err
+	}
+	mesh, err := g.mesh()
+	if err != nil {
+		return nil, err
+	}
+	var anim *AnimationData
+	if mesh.skin != nil {
+		sk, err := g.skeleton(mesh.skin)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range mesh.verts[Joints] {
+			if int(v) >= len(sk.remap) {
+				return nil, fmt.Errorf("invalid joint index %d", int(v))
+			}
+			mesh.verts[Joints][i] = float32(sk.remap[int(v)])
+		}
+		clips, err := g.clips(sk)
+		if err != nil {
+			return nil, err
+		}
+		anim = &AnimationData{Joints: sk.joints, Clips: clips}
+	}
+	md, err := mesh.meshData()
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, AssetData{Filename: name, Data: md})
+	materials, err := g.material(name, mesh.material)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, materials...)
+	if anim != nil {
+		data = append(data, AssetData{Filename: name, Data: anim})
+	}
+	return data, nil
+}
+
+// scene finds the scene nodes and their rest transforms.
+// Documents without scenes use all the root nodes.
+func (g *glb) scene() error {
+	nodes := g.doc.Nodes
+	g.parent = make([]int, len(nodes))
+	for i := range g.parent {
+		g.parent[i] = -1
+	}
+	for i, n := range nodes {
+		for _, c := range n.Children {
+			if int(c) >= len(nodes) || g.parent[c] >= 0 {
+				return fmt.Errorf("invalid child node %d", c)
+			}
+			g.parent[c] = i
+		}
+	}
+	var roots []uint32
+	switch {
+	case len(g.doc.Scenes) > 0:
+		scene := uint32(0)
+		if g.doc.Scene != nil {
+			scene = *g.doc.Scene
+		}
+		if int(scene) >= len(g.doc.Scenes) {
+			return fmt.Errorf("invalid scene %d", scene)
+		}
+		roots = g.doc.Scenes[scene].Nodes
+	default:
+		for i, p := range g.parent {
+			if p < 0 {
+				roots = append(roots, uint32(i))
+			}
+		}
+	}
+
+	// visit the nodes, parents before children.
+	g.world = make([]glbM4, len(nodes))
+	var visit func(n uint32, parent glbM4) error
+	visit = func(n uint32, parent glbM4) error {
+		if int(n) >= len(nodes) || slices.Contains(g.order, n) {
+			return fmt.Errorf("invalid scene node %d", n)
+		}
+		g.world[n] = parent.mult(glbLocal(nodes[n]))
+		g.order = append(g.order, n)
+		for _, c := range nodes[n].Children {
+			if err := visit(c, g.world[n]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, n := range roots {
+		if err := visit(n, glbIdentity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// mesh
+
+// glbAttributes maps the supported gltf vertex attributes
+// to the engine vertex data types.
+var glbAttributes = map[string]struct {
+	attr  int
+	atype gltf.AccessorType
+}{
+	gltf.POSITION:   {Vertexes, gltf.AccessorVec3},
+	gltf.NORMAL:     {Normals, gltf.AccessorVec3},
+	gltf.TANGENT:    {Tangents, gltf.AccessorVec4},
+	gltf.TEXCOORD_0: {Texcoords, gltf.AccessorVec2},
+	gltf.TEXCOORD_1: {Texcoords2, gltf.AccessorVec2},
+	gltf.JOINTS_0:   {Joints, gltf.AccessorVec4},
+	gltf.WEIGHTS_0:  {Weights, gltf.AccessorVec4},
+}
+
+// glbMesh is the vertex data merged from all the mesh primitives.
+type glbMesh struct {
+	verts    [VertexTypes][]float32 // vertex data for each vertex type.
+	indexes  []uint32               // triangle indexes.
+	count    int                    // number of vertexes.
+	material *uint32                // first primitive material.
+	skin     *gltf.Skin             // skin for skinned meshes.
+}
+
+// mesh merges the primitives of all the scene meshes.
+// Each primitive must have the same vertex attributes.
+func (g *glb) mesh() (m *glbMesh, err error) {
+	m = &glbMesh{}
+	var attrs [VertexTypes]bool // vertex attributes of the first primitive.
+	for _, n := range g.order {
+		node := g.doc.Nodes[n]
+		if node.Mesh == nil {
+			continue
+		}
+		if int(*node.Mesh) >= len(g.doc.Meshes) {
+			return nil, fmt.Errorf("invalid mesh %d", *node.Mesh)
+		}
+		if node.Skin != nil {
+			if int(*node.Skin) >= len(g.doc.Skins) {
+				return nil, fmt.Errorf("invalid skin %d", *node.Skin)
+			}
+			if m.skin != nil && m.skin != g.doc.Skins[*node.Skin] {
+				return nil, fmt.Errorf("expecting one gltf Skin")
+			}
+			m.skin = g.doc.Skins[*node.Skin]
+		}
+
+		// skinned meshes ignore the node transform.
+		world := g.world[n]
+		if node.Skin != nil {
+			world = glbIdentity
+		}
+		for _, p := range g.doc.Meshes[*node.Mesh].Primitives {
+			if p.Mode != gltf.PrimitiveTriangles {
+				return nil, fmt.Errorf("expecting triangle primitives")
+			}
+			var has [VertexTypes]bool
+			count := -1
+			for k, accessor := range p.Attributes {
+				a, ok := glbAttributes[k]
+				if !ok {
+					slog.Warn("unprocessed data", "data", k)
+					continue
+				}
+				vals, err := g.values(accessor, a.atype)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+				dim := int(a.atype.Components())
+				if count >= 0 && len(vals)/dim != count {
+					return nil, fmt.Errorf("invalid vertex attributes")
+				}
+				count = len(vals) / dim
+				switch a.attr {
+				case Vertexes:
+					world.points(vals)
+				case Normals, Tangents:
+					world.directions(vals, dim)
+				}
+				m.verts[a.attr] = append(m.verts[a.attr], glbFloat32(vals)...)
+				has[a.attr] = true
+			}
+			if !has[Vertexes] {
+				return nil, fmt.Errorf("expecting vertex positions")
+			}
+			if m.count == 0 {
+				attrs, m.material = has, p.Material
+			} else if has != attrs {
+				return nil, fmt.Errorf("inconsistent primitive attributes")
+			}
+
+			// triangle indexes, generated when not supplied.
+			first := len(m.indexes)
+			if p.Indices != nil {
+				vals, err := g.values(*p.Indices, gltf.AccessorScalar)
+				if err != nil {
+					return nil, fmt.Errorf("indexes: %w", err)
+				}
+				for _, v := range vals {
+					if int(v) >= count {
+						return nil, fmt.Errorf("invalid vertex index %d", int(v))
+					}
+					m.indexes = append(m.indexes, uint32(m.count)+uint32(v))
+				}
+			} else {
+				for i := 0; i < count; i++ {
+					m.indexes = append(m.indexes, uint32(m.count+i))
+				}
+			}
+			if (len(m.indexes)-first)%3 != 0 {
+				return nil, fmt.Errorf("expecting triangle indexes")
+			}
+			if world.det() < 0 {
+				// mirrored transforms reverse the triangle winding.
+				for i := first; i < len(m.indexes); i += 3 {
+					m.indexes[i+1], m.indexes[i+2] = m.indexes[i+2], m.indexes[i+1]
+				}
+			}
+			m.count += count
+		}
+	}
+	if m.count == 0 {
+		return nil, fmt.Errorf("expecting a gltf Mesh")
+	}
+	return m, nil
+}
+
+// meshData returns the merged vertex data with uint16 indexes.
+func (m *glbMesh) meshData() (md MeshData, err error) {
+	if m.count > math.MaxUint16+1 {
+		return nil, fmt.Errorf("too many vertexes for uint16 indexes %d", m.count)
+	}
+	md = make(MeshData, VertexTypes)
+	for attr, vals := range m.verts {
+		if len(vals) > 0 {
+			md[attr] = F32Buffer(vals, uint32(len(vals)/m.count))
+		}
+	}
+	indexes := make([]uint16, len(m.indexes))
+	for i, index := range m.indexes {
+		indexes[i] = uint16(index)
+	}
+	md[Indexes] = U16Buffer(indexes)
+	return md, nil
+}
+
+// =============================================================================
+// skins and animations.
+
+// glbSkeleton is the skin joints in engine joint order.
+type glbSkeleton struct {
+	joints []JointData
+	nodes  []uint32 // joint nodes, parents before children.
+	prefix []glbM4  // rest transform from the parent joint to the joint node parent.
+	remap  []int    // skin joint index to engine joint index.
+}
+
+// skeleton orders the skin joints so that parents are before children
+// and calculates the joint bind poses. The bind poses come from the
+// skin inverse bind matrices, or the node rest transforms if there
+// are no inverse bind matrices.
+func (g *glb) skeleton(skin *gltf.Skin) (sk *glbSkeleton, err error) {
+	if len(skin.Joints) == 0 {
+		return nil, fmt.Errorf("expecting skin joints")
+	}
+	for _, n := range skin.Joints {
+		if !slices.Contains(g.order, n) {
+			return nil, fmt.Errorf("skin joint %d is not in the scene", n)
+		}
+	}
+	sk = &glbSkeleton{nodes: slices.Clone(skin.Joints)}
+	sort.Slice(sk.nodes, func(i, j int) bool {
+		return slices.Index(g.order, sk.nodes[i]) < slices.Index(g.order, sk.nodes[j])
+	})
+	for _, n := range skin.Joints {
+		sk.remap = append(sk.remap, slices.Index(sk.nodes, n))
+	}
+
+	// joint model space bind transforms.
+	bind := make([]glbM4, len(sk.nodes))
+	for i, n := range sk.nodes {
+		bind[i] = g.world[n]
+	}
+	if skin.InverseBindMatrices != nil {
+		vals, err := g.values(*skin.InverseBindMatrices, gltf.AccessorMat4)
+		if err != nil {
+			return nil, fmt.Errorf("inverse bind matrices: %w", err)
+		}
+		if len(vals) != len(skin.Joints)*16 {
+			return nil, fmt.Errorf("expecting an inverse bind matrix for each joint")
+		}
+		for i, j := range sk.remap {
+			bind[j] = glbM4(vals[i*16 : i*16+16]).inverse()
+		}
+	}
+
+	// joint poses are relative to the nearest parent joint.
+	for i, n := range sk.nodes {
+		parent := -1
+		nodeParent := g.parent[n]
+		for p := nodeParent; p >= 0 && parent < 0; p = g.parent[p] {
+			parent = slices.Index(sk.nodes, uint32(p))
+		}
+		local, prefix := bind[i], glbIdentity
+		if parent >= 0 {
+			local = bind[parent].inverse().mult(bind[i])
+		}
+		switch {
+		case parent >= 0 && nodeParent != int(sk.nodes[parent]):
+			prefix = g.world[sk.nodes[parent]].inverse().mult(g.world[nodeParent])
+		case parent < 0 && nodeParent >= 0:
+			prefix = g.world[nodeParent]
+		}
+		sk.prefix = append(sk.prefix, prefix)
+		sk.joints = append(sk.joints, JointData{
+			Name:   g.doc.Nodes[n].Name,
+			Parent: int32(parent),
+			Pose:   glbDecompose(local).pose(),
+		})
+	}
+	return sk, nil
+}
+
+// glbChannel is the keyframe data for one animated joint property.
+type glbChannel struct {
+	joint  int              // engine joint index.
+	path   gltf.TRSProperty // animated joint property.
+	interp gltf.Interpolation
+	times  []float64 // keyframe times in seconds.
+	values []float64 // keyframe values.
+}
+
+// clips resamples the gltf animations that target the skeleton joints.
+// Joint properties that are not animated keep their rest values.
+// Clips are expected to loop since gltf does not mark looping animations.
+func (g *glb) clips(sk *glbSkeleton) (clips []ClipData, err error) {
+	rest := make([]glbTRS, len(sk.nodes))
+	for i, n := range sk.nodes {
+		rest[i] = glbNodeTRS(g.doc.Nodes[n])
+	}
+	for a, anim := range g.doc.Animations {
+		duration := 0.0
+		channels := []*glbChannel{}
+		for _, ch := range anim.Channels {
+			if ch.Target.Node == nil || ch.Sampler == nil || ch.Target.Path == gltf.TRSWeights {
+				continue // only joint transforms are animated.
+			}
+			joint := slices.Index(sk.nodes, *ch.Target.Node)
+			if joint < 0 {
+				continue
+			}
+			if int(*ch.Sampler) >= len(anim.Samplers) {
+				return nil, fmt.Errorf("invalid animation sampler %d", *ch.Sampler)
+			}
+			s := anim.Samplers[*ch.Sampler]
+			c := &glbChannel{joint: joint, path: ch.Target.Path, interp: s.Interpolation}
+			if c.times, err = g.values(s.Input, gltf.AccessorScalar); err != nil {
+				return nil, fmt.Errorf("animation times: %w", err)
+			}
+			atype := gltf.AccessorVec3
+			if c.path == gltf.TRSRotation {
+				atype = gltf.AccessorVec4
+			}
+			if c.values, err = g.values(s.Output, atype); err != nil {
+				return nil, fmt.Errorf("animation values: %w", err)
+			}
+			keys := len(c.times) * int(atype.Components())
+			if c.interp == gltf.InterpolationCubicSpline {
+				keys *= 3 // in-tangent, value, out-tangent.
+			}
+			if len(c.times) == 0 || len(c.values) != keys {
+				return nil, fmt.Errorf("invalid animation keyframes")
+			}
+			duration = max(duration, c.times[len(c.times)-1])
+			channels = append(channels, c)
+		}
+		if len(channels) == 0 {
+			continue
+		}
+		clip := ClipData{Name: anim.Name, Rate: glbFrameRate, Loop: true}
+		if clip.Name == "" {
+			clip.Name = fmt.Sprintf("clip%d", a)
+		}
+		trs := make([]glbTRS, len(sk.nodes))
+		nframes := int(math.Round(duration*glbFrameRate)) + 1
+		for f := 0; f < nframes; f++ {
+			t := float64(f) / glbFrameRate
+			copy(trs, rest)
+			for _, c := range channels {
+				switch c.path {
+				case gltf.TRSTranslation:
+					c.sample(t, trs[c.joint].t[:])
+				case gltf.TRSRotation:
+					c.sample(t, trs[c.joint].r[:])
+				case gltf.TRSScale:
+					c.sample(t, trs[c.joint].s[:])
+				}
+			}
+			poses := make([]JointPose, len(sk.nodes))
+			for j := range poses {
+				if sk.prefix[j] == glbIdentity {
+					poses[j] = trs[j].pose()
+					continue
+				}
+				poses[j] = glbDecompose(sk.prefix[j].mult(trs[j].matrix())).pose()
+			}
+			clip.Frames = append(clip.Frames, poses)
+		}
+		clips = append(clips, clip)
+	}
+	return clips, nil
+}
+
+// sample sets out to the channel value at time t. Times before
+// the first keyframe, or after the last keyframe, are clamped.
+func (c *glbChannel) sample(t float64, out []float64) {
+	n := len(out)
+	stride, at := n, 0 // values per keyframe and value offset.
+	if c.interp == gltf.InterpolationCubicSpline {
+		stride, at = 3*n, n
+	}
+	value := func(k int) []float64 { return c.values[k*stride+at : k*stride+at+n] }
+	k := sort.SearchFloat64s(c.times, t)
+	switch {
+	case k == 0:
+		copy(out, value(0))
+		return
+	case k >= len(c.times):
+		copy(out, value(len(c.times)-1))
+		return
+	case c.interp == gltf.InterpolationStep:
+		copy(out, value(k-1))
+		return
+	}
+	v0, v1 := value(k-1), value(k)
+	dt := c.times[k] - c.times[k-1]
+	u := (t - c.times[k-1]) / dt
+	switch {
+	case c.interp == gltf.InterpolationCubicSpline:
+		b0 := c.values[(k-1)*stride+2*n:] // out-tangent of the first keyframe.
+		a1 := c.values[k*stride:]         // in-tangent of the second keyframe.
+		u2, u3 := u*u, u*u*u
+		for i := range out {
+			out[i] = (2*u3-3*u2+1)*v0[i] + (u3-2*u2+u)*dt*b0[i] + (-2*u3+3*u2)*v1[i] + (u3-u2)*dt*a1[i]
+		}
+	case c.path == gltf.TRSRotation:
+		glbSlerp(out, v0, v1, u)
+	default:
+		for i := range out {
+			out[i] = v0[i] + (v1[i]-v0[i])*u
+		}
+	}
+	if c.path == gltf.TRSRotation {
+		glbNormalize(out)
+	}
+}
+
+// glbSlerp sets out to the spherical interpolation of quaternions a and b.
+func glbSlerp(out, a, b []float64, u float64) {
+	dot := a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+	sign := 1.0
+	if dot < 0 {
+		dot, sign = -dot, -1 // take the shortest path.
+	}
+	wa, wb := 1-u, u
+	if dot < 0.9995 {
+		theta := math.Acos(dot)
+		wa = math.Sin((1-u)*theta) / math.Sin(theta)
+		wb = math.Sin(u*theta) / math.Sin(theta)
+	}
+	for i := range out {
+		out[i] = wa*a[i] + sign*wb*b[i]
+	}
+}
+
+// glbNormalize scales the given vector to unit length.
+func glbNormalize(v []float64) {
+	l := 0.0
+	for _, x := range v {
+		l += x * x
+	}
+	if l = math.Sqrt(l); l > 0 {
+		for i := range v {
+			v[i] /= l
+		}
+	}
+}
+
+// =============================================================================
+// materials and textures.
+
+// material returns the model material assets using the same
+// conventions as the pbr shaders:
+//   - base color and metallic-roughness textures: two textures.
+//   - base color texture: one texture and one material.
+//   - otherwise a solid color material.
 //
-// These conform to a single model exported from Blender.
-func Glb(name string, r io.Reader) (data []AssetData) {
-	doc := gltf.Document{}
-	gltf.NewDecoder(r).Decode(&doc)
-
-	// reduce complexity by focusing on single mesh models.
-	if len(doc.Scenes) != 1 {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one gltf Scene")}}
-	}
-	if len(doc.Nodes) != 1 {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one gltf Node")}}
-	}
-	if len(doc.Meshes) != 1 {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one gltf Mesh")}}
-	}
-
-	// primitive contains the mesh data.
-	if len(doc.Meshes[0].Primitives) != 1 {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one gltf Mesh.Primitive")}}
-	}
-	primitive := doc.Meshes[0].Primitives[0]
-	if len(primitive.Attributes) < 1 {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one or more vertex attributes")}}
-	}
-
-	// Blender exports non-interleaved vertex data so use that.
-	md := make(MeshData, VertexTypes)
-	for k, v := range primitive.Attributes {
-		accessor := doc.Accessors[v]
-		buffview := doc.BufferViews[*accessor.BufferView]
-		buff := doc.Buffers[buffview.Buffer].Data
-		offset := buffview.ByteOffset
-		byteLen := buffview.ByteLength
-
-		// sanity check: all the vertex data counts must match.
-		if accessor.Count != doc.Accessors[0].Count {
-			return []AssetData{{Filename: name, Err: fmt.Errorf("invalid vertex attributes")}}
-		}
-
-		// make copies of the gltf doc data.
-		switch k {
-		case gltf.POSITION:
-			if accessor.Type != gltf.AccessorVec3 || accessor.ComponentType != gltf.ComponentFloat {
-				return []AssetData{{Filename: name, Err: fmt.Errorf("expecting vec3:float32 vertexes")}}
-			}
-			md[Vertexes].Stride = 12 // bytes for vec3 of float32
-			md[Vertexes].Count = accessor.Count
-			md[Vertexes].Data = make([]byte, byteLen)
-			copy(md[Vertexes].Data, buff[offset:offset+byteLen])
-		case gltf.NORMAL:
-			if accessor.Type != gltf.AccessorVec3 || accessor.ComponentType != gltf.ComponentFloat {
-				return []AssetData{{Filename: name, Err: fmt.Errorf("expecting vec3:float32 normals")}}
-			}
-			md[Normals].Stride = 12 // bytes for vec3 of float32
-			md[Normals].Count = accessor.Count
-			md[Normals].Data = make([]byte, byteLen)
-			copy(md[Normals].Data, buff[offset:offset+byteLen])
-		case gltf.TEXCOORD_0:
-			if accessor.Type != gltf.AccessorVec2 || accessor.ComponentType != gltf.ComponentFloat {
-				return []AssetData{{Filename: name, Err: fmt.Errorf("expecting vec2:float32 texcoords")}}
-			}
-			md[Texcoords].Stride = 8 // 4x2 bytes for vec2 of float32
-			md[Texcoords].Count = accessor.Count
-			md[Texcoords].Data = make([]byte, byteLen)
-			copy(md[Texcoords].Data, buff[offset:offset+byteLen])
-		default:
-			slog.Warn("unprocessed data", "data", k)
-		}
-	}
-
-	// get mesh indices if they exist
-	if primitive.Indices != nil {
-		accessor := doc.Accessors[*primitive.Indices]
-		if accessor.Type != gltf.AccessorScalar || accessor.ComponentType != gltf.ComponentUshort {
-			return []AssetData{{Filename: name, Err: fmt.Errorf("expecting uint16 indexes")}}
-		}
-		md[Indexes].Stride = 2 // bytes for uint16
-		md[Indexes].Count = accessor.Count
-		viewIndex := accessor.BufferView
-		buffview := doc.BufferViews[*viewIndex]
-		offset := buffview.ByteOffset
-		byteLen := buffview.ByteLength
-		buff := doc.Buffers[buffview.Buffer].Data
-		md[Indexes].Data = make([]byte, byteLen)
-		copy(md[Indexes].Data, buff[offset:offset+byteLen])
-	}
-	data = append(data, AssetData{Filename: name, Data: md, Err: nil})
-
-	// load material, expecting 1 PRB material.
-	if len(doc.Materials) != 1 || doc.Materials[0].PBRMetallicRoughness == nil {
-		return []AssetData{{Filename: name, Err: fmt.Errorf("expecting one PBR material")}}
-	}
-	mat := doc.Materials[0].PBRMetallicRoughness
+// The gltf default material is used if there is no material.
+func (g *glb) material(name string, index *uint32) (data []AssetData, err error) {
+	mat := &gltf.PBRMetallicRoughness{}
+	if index != nil {
+		if int(*index) >= len(g.doc.Materials) {
+			return nil, fmt.Errorf("invalid material %d", *index)
+		}
+		if pbr := g.doc.Materials[*index].PBRMetallicRoughness; pbr != nil {
+			mat = pbr
+		}
+	}
 	switch {
 	case mat.BaseColorTexture != nil && mat.MetallicRoughnessTexture != nil:
 		// support PRB materials with base color and metallic-roughness textures
 		// corresponds to the pbr_texture shader - create two textures
-		bc, err := getPNGTexture(&doc, mat.BaseColorTexture.Index)
+		bc, err := g.texture(mat.BaseColorTexture.Index)
 		if err != nil {
-			return []AssetData{{Filename: name, Err: fmt.Errorf("PBR base color:%w", err)}}
+			return nil, fmt.Errorf("PBR base color:%w", err)
 		}
-		mr, err := getPNGTexture(&doc, mat.MetallicRoughnessTexture.Index)
+		mr, err := g.texture(mat.MetallicRoughnessTexture.Index)
 		if err != nil {
-			return []AssetData{{Filename: name, Err: fmt.Errorf("PBR material-roughness:%w", err)}}
+			return nil, fmt.Errorf("PBR material-roughness:%w", err)
 		}
 		data = append(data, AssetData{Filename: name, Data: bc, Err: nil})
 		data = append(data, AssetData{Filename: name, Data: mr, Err: nil})
@@ -133,9 +594,9 @@ func Glb(name string, r io.Reader) (data []AssetData) {
 	case mat.BaseColorTexture != nil:
 		// support PRB materials with just a base color texture.
 		// corresponds to the pbr_base_color shader - create one texture and one material.
-		img, err := getPNGTexture(&doc, mat.BaseColorTexture.Index)
+		img, err := g.texture(mat.BaseColorTexture.Index)
 		if err != nil {
-			return []AssetData{{Filename: name, Err: err}}
+			return nil, err
 		}
 		data = append(data, AssetData{Filename: name, Data: img, Err: nil})
 
@@ -146,7 +607,7 @@ func Glb(name string, r io.Reader) (data []AssetData) {
 		}
 		data = append(data, AssetData{Filename: name, Data: pbr, Err: nil})
 		slog.Debug("load.Glb: color texture PBR")
-	case mat.BaseColorFactor != nil:
+	default:
 		// support solid shaded PRB materials.
 		// corresponds to the pbr_solid shader - create one material.
 		color := mat.BaseColorFactorOrDefault()
@@ -160,53 +621,327 @@ func Glb(name string, r io.Reader) (data []AssetData) {
 		pbr.ColorA = color[3]
 		data = append(data, AssetData{Filename: name, Data: pbr, Err: nil})
 		slog.Debug("load.Glb: solid color PBR")
+	}
+	return data, nil
+}
+
+// texture returns the NRGBA image data for the given texture.
+// The png or jpeg image can be in a buffer, embedded in the
+// image URI, or in an external file in the asset directory.
+func (g *glb) texture(textureIndex uint32) (idata *ImageData, err error) {
+	if int(textureIndex) >= len(g.doc.Textures) {
+		return nil, fmt.Errorf("invalid texture %d", textureIndex)
+	}
+	tex := g.doc.Textures[textureIndex]
+	if tex.Source == nil || int(*tex.Source) >= len(g.doc.Images) {
+		return nil, fmt.Errorf("expecting texture image index")
+	}
+	img := g.doc.Images[*tex.Source]
+	var ibytes []byte
+	switch {
+	case img.BufferView != nil:
+		if ibytes, err = g.view(*img.BufferView); err != nil {
+			return nil, err
+		}
+	case img.IsEmbeddedResource():
+		if ibytes, err = img.MarshalData(); err != nil {
+			return nil, err
+		}
+	case img.URI != "" && filepath.IsLocal(img.URI):
+		if ibytes, err = ReadFile(path.Join(g.dir, img.URI)); err != nil {
+			return nil, err
+		}
 	default:
-		return []AssetData{{Filename: name, Err: fmt.Errorf("missing PBR material parameters")}}
+		return nil, fmt.Errorf("expecting texture image data")
 	}
 
-	// FUTURE load animation (joints, and weights) vertex data
+	// get a NRGBA image from the bytes.
+	decoded, _, err := image.Decode(bytes.NewReader(ibytes))
+	if err != nil {
+		return nil, fmt.Errorf("expecting png or jpeg image data")
+	}
+	nrgba, ok := decoded.(*image.NRGBA)
+	if !ok {
+		bounds := decoded.Bounds()
+		nrgba = image.NewNRGBA(bounds)
+		draw.Draw(nrgba, bounds, decoded, bounds.Min, draw.Src)
+	}
+	idata = &ImageData{Pixels: nrgba.Pix, Opaque: nrgba.Opaque()}
+	idata.Width = uint32(nrgba.Bounds().Size().X)
+	idata.Height = uint32(nrgba.Bounds().Size().Y)
+	return idata, nil
+}
 
-	return data
+// =============================================================================
+// gltf buffer data.
+
+// view returns the bytes for the given buffer view.
+func (g *glb) view(index uint32) ([]byte, error) {
+	if int(index) >= len(g.doc.BufferViews) {
+		return nil, fmt.Errorf("invalid buffer view %d", index)
+	}
+	view := g.doc.BufferViews[index]
+	if int(view.Buffer) >= len(g.doc.Buffers) {
+		return nil, fmt.Errorf("invalid buffer %d", view.Buffer)
+	}
+	buff := g.doc.Buffers[view.Buffer].Data
+	if int(view.ByteOffset)+int(view.ByteLength) > len(buff) {
+		return nil, fmt.Errorf("buffer view %d out of range", index)
+	}
+	return buff[view.ByteOffset : view.ByteOffset+view.ByteLength], nil
 }
 
-// getPNGTexture attemps to return a png NRGBA image for the given texture.
-func getPNGTexture(doc *gltf.Document, textureIndex uint32) (idata *ImageData, err error) {
-	tex := doc.Textures[textureIndex]
-	if tex.Source == nil {
-		return nil, fmt.Errorf("expecting texture image index")
+// values returns the accessor data as float64 values. Normalized
+// integer values are converted to floats between 0 and 1, or -1 and 1.
+// Accessors without a buffer view are all zeros.
+func (g *glb) values(index uint32, atype gltf.AccessorType) (vals []float64, err error) {
+	if int(index) >= len(g.doc.Accessors) {
+		return nil, fmt.Errorf("invalid accessor %d", index)
 	}
-	img := doc.Images[*tex.Source]
-	if img.MimeType != "image/png" {
-		return nil, fmt.Errorf("expecting png texture image")
+	acc := g.doc.Accessors[index]
+	if acc.Type != atype {
+		return nil, fmt.Errorf("expecting %s accessor got %s", atype, acc.Type)
 	}
-	if img.BufferView == nil {
-		return nil, fmt.Errorf("expecting texture buffer view")
+	if acc.Sparse != nil {
+		return nil, fmt.Errorf("sparse accessors are not supported")
+	}
+	comps := int(atype.Components())
+	vals = make([]float64, int(acc.Count)*comps)
+	if acc.BufferView == nil || acc.Count == 0 {
+		return vals, nil
+	}
+	buff, err := g.view(*acc.BufferView)
+	if err != nil {
+		return nil, err
 	}
+	size := int(acc.ComponentType.ByteSize())
+	stride := int(g.doc.BufferViews[*acc.BufferView].ByteStride)
+	if stride == 0 {
+		stride = size * comps // tightly packed.
+	}
+	start := int(acc.ByteOffset)
+	if start+stride*(int(acc.Count)-1)+size*comps > len(buff) {
+		return nil, fmt.Errorf("accessor %d out of range", index)
+	}
+	for i := 0; i < int(acc.Count); i++ {
+		for c := 0; c < comps; c++ {
+			b := buff[start+i*stride+c*size:]
+			v := 0.0
+			switch acc.ComponentType {
+			case gltf.ComponentFloat:
+				v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			case gltf.ComponentUint:
+				v = float64(binary.LittleEndian.Uint32(b))
+			case gltf.ComponentUshort:
+				v = float64(binary.LittleEndian.Uint16(b))
+				if acc.Normalized {
+					v /= math.MaxUint16
+				}
+			case gltf.ComponentShort:
+				v = float64(int16(binary.LittleEndian.Uint16(b)))
+				if acc.Normalized {
+					v = max(v/math.MaxInt16, -1)
+				}
+			case gltf.ComponentUbyte:
+				v = float64(b[0])
+				if acc.Normalized {
+					v /= math.MaxUint8
+				}
+			case gltf.ComponentByte:
+				v = float64(int8(b[0]))
+				if acc.Normalized {
+					v = max(v/math.MaxInt8, -1)
+				}
+			}
+			vals[i*comps+c] = v
+		}
+	}
+	return vals, nil
+}
+
+// glbFloat32 converts float64 values to float32 vertex data.
+func glbFloat32(vals []float64) []float32 {
+	f32 := make([]float32, len(vals))
+	for i, v := range vals {
+		f32[i] = float32(v)
+	}
+	return f32
+}
 
-	// get the buffer view
-	view := doc.BufferViews[*img.BufferView]
-	offset := view.ByteOffset
-	byteLen := view.ByteLength
+// glbFS reads external gltf buffers from the asset directory
+// using ReadFile so that apps can override the file system.
+type glbFS string
 
-	// get the bytes from the buffer data
-	buff := doc.Buffers[view.Buffer].Data
-	ibytes := buff[offset : offset+byteLen]
+// Open is not used since glbFS implements fs.ReadFileFS.
+func (dir glbFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+}
 
-	// get a png image from the bytes
-	pngImg, err := png.Decode(bytes.NewReader(ibytes))
-	if err != nil {
-		return nil, fmt.Errorf("expecting png image data")
+// ReadFile implements fs.ReadFileFS.
+func (dir glbFS) ReadFile(name string) ([]byte, error) {
+	return ReadFile(path.Join(string(dir), name))
+}
+
+// =============================================================================
+// gltf transforms.
+
+// glbM4 is a gltf column-major 4x4 transform matrix.
+type glbM4 [16]float64
+
+// glbIdentity is the identity transform.
+var glbIdentity = glbM4(gltf.DefaultMatrix)
+
+// glbLocal returns the node transform relative to its parent.
+func glbLocal(n *gltf.Node) glbM4 {
+	if m := n.MatrixOrDefault(); m != gltf.DefaultMatrix {
+		return glbM4(m)
+	}
+	return glbNodeTRS(n).matrix()
+}
+
+// mult returns the transform m*b, ie: b is applied first.
+func (m glbM4) mult(b glbM4) (c glbM4) {
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			for k := 0; k < 4; k++ {
+				c[col*4+row] += m[k*4+row] * b[col*4+k]
+			}
+		}
 	}
+	return c
+}
+
+// det returns the determinant of the rotation and scale.
+func (m glbM4) det() float64 {
+	return m[0]*(m[5]*m[10]-m[9]*m[6]) - m[4]*(m[1]*m[10]-m[9]*m[2]) + m[8]*(m[1]*m[6]-m[5]*m[2])
+}
 
-	// return the image data for the png image.
-	idata = &ImageData{}
-	switch t := pngImg.(type) {
-	case *image.NRGBA:
-		idata.Pixels = []byte(t.Pix)
+// inverse returns the inverse of the affine transform m.
+func (m glbM4) inverse() (inv glbM4) {
+	det := m.det()
+	if det == 0 {
+		return glbIdentity
+	}
+	a, b, c := m[0], m[4], m[8]  // row 0
+	d, e, f := m[1], m[5], m[9]  // row 1
+	g, h, i := m[2], m[6], m[10] // row 2
+	// row-major inverse rotation and scale.
+	r := [9]float64{
+		(e*i - f*h) / det, (c*h - b*i) / det, (b*f - c*e) / det,
+		(f*g - d*i) / det, (a*i - c*g) / det, (c*d - a*f) / det,
+		(d*h - e*g) / det, (b*g - a*h) / det, (a*e - b*d) / det,
+	}
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			inv[col*4+row] = r[row*3+col]
+		}
+		inv[12+row] = -(r[row*3]*m[12] + r[row*3+1]*m[13] + r[row*3+2]*m[14])
+	}
+	inv[15] = 1
+	return inv
+}
+
+// points transforms the vec3 points in place.
+func (m glbM4) points(v []float64) {
+	if m == glbIdentity {
+		return
+	}
+	for i := 0; i+2 < len(v); i += 3 {
+		x, y, z := v[i], v[i+1], v[i+2]
+		v[i] = m[0]*x + m[4]*y + m[8]*z + m[12]
+		v[i+1] = m[1]*x + m[5]*y + m[9]*z + m[13]
+		v[i+2] = m[2]*x + m[6]*y + m[10]*z + m[14]
+	}
+}
+
+// directions transforms the first 3 components of each vector
+// in place using the inverse transpose, and normalizes the result.
+func (m glbM4) directions(v []float64, dim int) {
+	if m == glbIdentity {
+		return
+	}
+	n := m.inverse()
+	for i := 0; i+2 < len(v); i += dim {
+		x, y, z := v[i], v[i+1], v[i+2]
+		v[i] = n[0]*x + n[1]*y + n[2]*z
+		v[i+1] = n[4]*x + n[5]*y + n[6]*z
+		v[i+2] = n[8]*x + n[9]*y + n[10]*z
+		glbNormalize(v[i : i+3])
+	}
+}
+
+// glbTRS is a transform as translation, rotation, and scale.
+type glbTRS struct {
+	t [3]float64 // translation x,y,z
+	r [4]float64 // unit quaternion x,y,z,w
+	s [3]float64 // scale x,y,z
+}
+
+// glbNodeTRS returns the node rest transform.
+func glbNodeTRS(n *gltf.Node) glbTRS {
+	if m := n.MatrixOrDefault(); m != gltf.DefaultMatrix {
+		return glbDecompose(glbM4(m))
+	}
+	return glbTRS{t: n.TranslationOrDefault(), r: n.RotationOrDefault(), s: n.ScaleOrDefault()}
+}
+
+// matrix returns the transform that scales, rotates, and then translates.
+func (p glbTRS) matrix() (m glbM4) {
+	x, y, z, w := p.r[0], p.r[1], p.r[2], p.r[3]
+	r := [9]float64{ // row-major rotation.
+		1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w),
+		2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w),
+		2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y),
+	}
+	for col := 0; col < 3; col++ {
+		for row := 0; row < 3; row++ {
+			m[col*4+row] = r[row*3+col] * p.s[col]
+		}
+		m[12+col] = p.t[col]
+	}
+	m[15] = 1
+	return m
+}
+
+// pose returns the transform as a joint pose.
+func (p glbTRS) pose() JointPose {
+	q := [4]float32{float32(p.r[0]), float32(p.r[1]), float32(p.r[2]), float32(p.r[3])}
+	return JointPose{
+		T: [3]float32{float32(p.t[0]), float32(p.t[1]), float32(p.t[2])},
+		R: unitQuaternion(q),
+		S: [3]float32{float32(p.s[0]), float32(p.s[1]), float32(p.s[2])},
+	}
+}
+
+// glbDecompose splits an affine transform without shear
+// into its translation, rotation, and scale.
+func glbDecompose(m glbM4) (p glbTRS) {
+	p.t = [3]float64{m[12], m[13], m[14]}
+	for col := 0; col < 3; col++ {
+		p.s[col] = math.Sqrt(m[col*4]*m[col*4] + m[col*4+1]*m[col*4+1] + m[col*4+2]*m[col*4+2])
+	}
+	if m.det() < 0 {
+		p.s[0] = -p.s[0]
+	}
+	r := func(row, col int) float64 {
+		if p.s[col] == 0 {
+			return 0
+		}
+		return m[col*4+row] / p.s[col]
+	}
+	switch trace := r(0, 0) + r(1, 1) + r(2, 2); {
+	case trace > 0:
+		k := 0.5 / math.Sqrt(trace+1)
+		p.r = [4]float64{(r(2, 1) - r(1, 2)) * k, (r(0, 2) - r(2, 0)) * k, (r(1, 0) - r(0, 1)) * k, 0.25 / k}
+	case r(0, 0) > r(1, 1) && r(0, 0) > r(2, 2):
+		k := 2 * math.Sqrt(1+r(0, 0)-r(1, 1)-r(2, 2))
+		p.r = [4]float64{0.25 * k, (r(0, 1) + r(1, 0)) / k, (r(0, 2) + r(2, 0)) / k, (r(2, 1) - r(1, 2)) / k}
+	case r(1, 1) > r(2, 2):
+		k := 2 * math.Sqrt(1+r(1, 1)-r(0, 0)-r(2, 2))
+		p.r = [4]float64{(r(0, 1) + r(1, 0)) / k, 0.25 * k, (r(1, 2) + r(2, 1)) / k, (r(0, 2) - r(2, 0)) / k}
 	default:
-		return nil, fmt.Errorf("expecting png NRGBA, got %T", t)
+		k := 2 * math.Sqrt(1+r(2, 2)-r(0, 0)-r(1, 1))
+		p.r = [4]float64{(r(0, 2) + r(2, 0)) / k, (r(1, 2) + r(2, 1)) / k, 0.25 * k, (r(1, 0) - r(0, 1)) / k}
 	}
-	idata.Width = uint32(pngImg.Bounds().Size().X)
-	idata.Height = uint32(pngImg.Bounds().Size().Y)
-	return idata, err
+	return p
 }
@@ -0,0 +1,290 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// iqm.go reads Inter-Quake Model files. IQM is a binary format
+// for rigged meshes and their skeletal animations, see:
+//   https://github.com/lsalzman/iqm
+// Only version 2 files are supported.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// Iqm returns the mesh data and the animation data, if any, from
+// the given iqm file data. Multiple iqm meshes are combined into a
+// single mesh. The iqm triangles are clockwise so they are reversed
+// to match the counter-clockwise triangles expected by the engine.
+func Iqm(name string, data []byte) []AssetData {
+	md, anim, err := iqm(data)
+	if err != nil {
+		return []AssetData{{Filename: name, Err: fmt.Errorf("iqm %s: %w", name, err)}}
+	}
+	assets := []AssetData{{Filename: name, Data: md}}
+	if anim != nil {
+		assets = append(assets, AssetData{Filename: name, Data: anim})
+	}
+	return assets
+}
+
+// iqm file layout.
+const iqmMagic = "INTERQUAKEMODEL\x00"
+
+// iqmHeader follows the magic string at the start of the file.
+type iqmHeader struct {
+	Version, Filesize, Flags                        uint32
+	NumText, OfsText                                uint32
+	NumMeshes, OfsMeshes                            uint32
+	NumVertexArrays, NumVertexes, OfsVertexArrays   uint32
+	NumTriangles, OfsTriangles, OfsAdjacency        uint32
+	NumJoints, OfsJoints                            uint32
+	NumPoses, OfsPoses                              uint32
+	NumAnims, OfsAnims                              uint32
+	NumFrames, NumFrameChannels, OfsFrames, OfsBnds uint32
+	NumComment, OfsComment                          uint32
+	NumExtensions, OfsExtensions                    uint32
+}
+
+// iqmVertexArray describes one type of vertex data.
+type iqmVertexArray struct {
+	Type, Flags, Format, Size, Offset uint32
+}
+
+// iqm vertex array types and formats.
+const (
+	iqmPosition     = 0
+	iqmTexcoord     = 1
+	iqmNormal       = 2
+	iqmTangent      = 3
+	iqmBlendIndexes = 4
+	iqmBlendWeights = 5
+	iqmUbyte        = 1
+	iqmFloat        = 7
+	iqmLoop         = 1 // animation flag.
+)
+
+// iqmJoint is a skeleton joint in the bind pose.
+type iqmJoint struct {
+	Name      uint32
+	Parent    int32
+	Translate [3]float32
+	Rotate    [4]float32
+	Scale     [3]float32
+}
+
+// iqmPose describes how to decode the frame channels for one joint.
+// The 10 channels are translate x,y,z, rotate x,y,z,w, scale x,y,z.
+type iqmPose struct {
+	Parent        int32
+	Mask          uint32
+	ChannelOffset [10]float32
+	ChannelScale  [10]float32
+}
+
+// iqmAnim is a named range of frames.
+type iqmAnim struct {
+	Name, FirstFrame, NumFrames uint32
+	Framerate                   float32
+	Flags                       uint32
+}
+
+// iqm parses the iqm file data.
+func iqm(data []byte) (md MeshData, anim *AnimationData, err error) {
+	if len(data) < len(iqmMagic) || string(data[:len(iqmMagic)]) != iqmMagic {
+		return nil, nil, fmt.Errorf("not an iqm file")
+	}
+	hdr := iqmHeader{}
+	if err = iqmRead(data, uint32(len(iqmMagic)), &hdr); err != nil {
+		return nil, nil, err
+	}
+	if hdr.Version != 2 {
+		return nil, nil, fmt.Errorf("unsupported version %d", hdr.Version)
+	}
+	if hdr.NumVertexes == 0 || hdr.NumVertexes > math.MaxUint16+1 {
+		return nil, nil, fmt.Errorf("expecting 1 to 65536 vertexes, got %d", hdr.NumVertexes)
+	}
+	if int(hdr.OfsText)+int(hdr.NumText) > len(data) {
+		return nil, nil, fmt.Errorf("invalid text")
+	}
+	text := data[hdr.OfsText : hdr.OfsText+hdr.NumText]
+
+	// vertex data.
+	md = make(MeshData, VertexTypes)
+	arrays := make([]iqmVertexArray, hdr.NumVertexArrays)
+	if err = iqmRead(data, hdr.OfsVertexArrays, arrays); err != nil {
+		return nil, nil, err
+	}
+	count := hdr.NumVertexes
+	for _, va := range arrays {
+		switch {
+		case va.Type == iqmPosition && va.Format == iqmFloat && va.Size == 3:
+			md[Vertexes], err = iqmFloats(data, va, count)
+		case va.Type == iqmTexcoord && va.Format == iqmFloat && va.Size == 2:
+			md[Texcoords], err = iqmFloats(data, va, count)
+		case va.Type == iqmNormal && va.Format == iqmFloat && va.Size == 3:
+			md[Normals], err = iqmFloats(data, va, count)
+		case va.Type == iqmTangent && va.Format == iqmFloat && va.Size == 4:
+			md[Tangents], err = iqmFloats(data, va, count)
+		case va.Type == iqmBlendIndexes && va.Format == iqmUbyte && va.Size == 4:
+			md[Joints], err = iqmBytes(data, va, count, 1)
+		case va.Type == iqmBlendWeights && va.Format == iqmUbyte && va.Size == 4:
+			md[Weights], err = iqmBytes(data, va, count, 255)
+		case va.Type == iqmBlendWeights && va.Format == iqmFloat && va.Size == 4:
+			md[Weights], err = iqmFloats(data, va, count)
+		default:
+			slog.Warn("unprocessed data", "iqm_type", va.Type, "format", va.Format, "size", va.Size)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if md[Vertexes].Count == 0 {
+		return nil, nil, fmt.Errorf("expecting vec3:float32 vertexes")
+	}
+
+	// triangle indexes, reversing the winding order.
+	tris := make([][3]uint32, hdr.NumTriangles)
+	if err = iqmRead(data, hdr.OfsTriangles, tris); err != nil {
+		return nil, nil, err
+	}
+	indexes := make([]uint16, 0, len(tris)*3)
+	for _, t := range tris {
+		if t[0] >= count || t[1] >= count || t[2] >= count {
+			return nil, nil, fmt.Errorf("invalid triangle %v", t)
+		}
+		indexes = append(indexes, uint16(t[0]), uint16(t[2]), uint16(t[1]))
+	}
+	if len(indexes) == 0 {
+		return nil, nil, fmt.Errorf("expecting triangles")
+	}
+	md[Indexes] = U16Buffer(indexes)
+	if hdr.NumJoints == 0 {
+		return md, nil, nil // static mesh.
+	}
+
+	// skeleton joints.
+	anim = &AnimationData{}
+	joints := make([]iqmJoint, hdr.NumJoints)
+	if err = iqmRead(data, hdr.OfsJoints, joints); err != nil {
+		return nil, nil, err
+	}
+	for i, j := range joints {
+		if j.Parent >= int32(i) {
+			return nil, nil, fmt.Errorf("joint %d parent %d must be earlier", i, j.Parent)
+		}
+		pose := JointPose{T: j.Translate, R: j.Rotate, S: j.Scale}
+		anim.Joints = append(anim.Joints, JointData{Name: iqmString(text, j.Name), Parent: j.Parent, Pose: pose})
+	}
+	if hdr.NumAnims == 0 || hdr.NumFrames == 0 {
+		return md, anim, nil // skeleton without animations.
+	}
+	if hdr.NumPoses != hdr.NumJoints {
+		return nil, nil, fmt.Errorf("expecting one pose per joint, got %d poses", hdr.NumPoses)
+	}
+
+	// decode the frames into joint poses.
+	poses := make([]iqmPose, hdr.NumPoses)
+	if err = iqmRead(data, hdr.OfsPoses, poses); err != nil {
+		return nil, nil, err
+	}
+	channels := make([]uint16, hdr.NumFrames*hdr.NumFrameChannels)
+	if err = iqmRead(data, hdr.OfsFrames, channels); err != nil {
+		return nil, nil, err
+	}
+	frames := make([][]JointPose, hdr.NumFrames)
+	for f := range frames {
+		frames[f] = make([]JointPose, len(poses))
+		for p, pose := range poses {
+			values := [10]float32{}
+			for c := range values {
+				values[c] = pose.ChannelOffset[c]
+				if pose.Mask&(1<<c) != 0 {
+					if len(channels) == 0 {
+						return nil, nil, fmt.Errorf("missing frame channels")
+					}
+					values[c] += float32(channels[0]) * pose.ChannelScale[c]
+					channels = channels[1:]
+				}
+			}
+			jp := &frames[f][p]
+			copy(jp.T[:], values[0:3])
+			copy(jp.R[:], values[3:7])
+			copy(jp.S[:], values[7:10])
+			jp.R = unitQuaternion(jp.R)
+		}
+	}
+
+	// group the frames into animation clips.
+	anims := make([]iqmAnim, hdr.NumAnims)
+	if err = iqmRead(data, hdr.OfsAnims, anims); err != nil {
+		return nil, nil, err
+	}
+	for _, a := range anims {
+		if a.FirstFrame+a.NumFrames > hdr.NumFrames || a.NumFrames == 0 {
+			return nil, nil, fmt.Errorf("invalid animation frames %d:%d", a.FirstFrame, a.NumFrames)
+		}
+		anim.Clips = append(anim.Clips, ClipData{
+			Name:   iqmString(text, a.Name),
+			Rate:   a.Framerate,
+			Loop:   a.Flags&iqmLoop != 0,
+			Frames: frames[a.FirstFrame : a.FirstFrame+a.NumFrames],
+		})
+	}
+	return md, anim, nil
+}
+
+// iqmRead reads little endian data at the given file offset.
+func iqmRead(data []byte, offset uint32, v any) error {
+	if int(offset) > len(data) {
+		return fmt.Errorf("invalid offset %d", offset)
+	}
+	if err := binary.Read(bytes.NewReader(data[offset:]), binary.LittleEndian, v); err != nil {
+		return fmt.Errorf("read %T at %d: %w", v, offset, err)
+	}
+	return nil
+}
+
+// iqmFloats copies float32 vertex data.
+func iqmFloats(data []byte, va iqmVertexArray, count uint32) (b Buffer, err error) {
+	floats := make([]float32, count*va.Size)
+	if err = iqmRead(data, va.Offset, floats); err != nil {
+		return b, err
+	}
+	return F32Buffer(floats, va.Size), nil
+}
+
+// iqmBytes converts ubyte vertex data to float32 vertex data,
+// dividing by the given value to normalize the data if necessary.
+func iqmBytes(data []byte, va iqmVertexArray, count uint32, div float32) (b Buffer, err error) {
+	ubytes := make([]uint8, count*va.Size)
+	if err = iqmRead(data, va.Offset, ubytes); err != nil {
+		return b, err
+	}
+	floats := make([]float32, len(ubytes))
+	for i, v := range ubytes {
+		floats[i] = float32(v) / div
+	}
+	return F32Buffer(floats, va.Size), nil
+}
+
+// iqmString returns the null terminated string at the given text offset.
+func iqmString(text []byte, offset uint32) string {
+	if int(offset) >= len(text) {
+		return ""
+	}
+	str, _, _ := bytes.Cut(text[offset:], []byte{0})
+	return string(str)
+}
+
+// unitQuaternion normalizes the given quaternion.
+func unitQuaternion(q [4]float32) [4]float32 {
+	l := float32(math.Sqrt(float64(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])))
+	if l == 0 {
+		return [4]float32{0, 0, 0, 1}
+	}
+	return [4]float32{q[0] / l, q[1] / l, q[2] / l, q[3] / l}
+}
@@ -6,12 +6,19 @@
 //   - ".png"  image data
 //   - ".shd"  shader configuration description
 //   - ".glb"  vertex data, image data, animation data, material data
+//   - ".gltf" same as ".glb" with external or embedded buffers and images
+//   - ".iqm"  vertex data, animation data
+//   - ".dae"  collada scenes with the same data as ".glb"
 //   - ".wav"  audio data
+//   - ".flac" audio data
+//   - ".mp3"  audio data, other audio files with RegisterAudioDecoder
 //   - ".ttf"  true type font file.
+//   - ".ogv"  movie frames and audio packets, also ".webm" and ".mkv"
 //   - ".yaml" data file
 //
 // This package is primary used internally for getting data from disk
 // that is then upload to the render and audio systems.
+// Asset files can also be packed into zip archives, see Mount.
 //
 // Package load is provided as part of the vu (virtual universe) 3D engine.
 package load
@@ -21,12 +28,17 @@ package load
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
+	"io"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
 // =============================================================================
@@ -39,8 +51,16 @@ var assetDirs = map[string]string{
 	".shd":  "assets/shaders", // yaml shader configuration files.
 	".png":  "assets/images",  // png images, often textures.
 	".glb":  "assets/models",  // glb scenes, meshes, materials, animations, textures,...
+	".gltf": "assets/models",  // gltf json version of glb, including external buffers.
+	".iqm":  "assets/models",  // iqm rigged meshes and animations.
+	".dae":  "assets/models",  // collada scenes, converted like gltf scenes.
 	".ttf":  "assets/fonts",   // true type font files.
 	".wav":  "assets/audio",   // sound data.
+	".flac": "assets/audio",   // lossless compressed sound data.
+	".mp3":  "assets/audio",   // lossy compressed sound data.
+	".ogv":  "assets/video",   // ogg movies.
+	".webm": "assets/video",   // webm movies.
+	".mkv":  "assets/video",   // matroska movies.
 	".yaml": "assets/data",    // data files
 }
 
@@ -64,16 +84,17 @@ func LoadAssetFile(fname string) []AssetData {
 	case ".png":
 		img, err := Image(fname)
 		return []AssetData{{Filename: fname, Data: img, Err: err}}
-	case ".glb":
+	case ".glb", ".gltf", ".iqm", ".dae":
 		return Model(fname) // possible to have multiple assets
-	case ".wav":
-		aud, err := Audio(fname)
-		return []AssetData{{Filename: fname, Data: aud, Err: err}}
 	case ".ttf":
 		atlas, err := TTFont(fname)
 		return []AssetData{{Filename: fname, Data: atlas, Err: err}}
 	}
-	err := fmt.Errorf("unsupported asset file %s", fname)
+	if hasAudioDecoder(getFileExtension(fname)) {
+		aud, err := Audio(fname)
+		return []AssetData{{Filename: fname, Data: aud, Err: err}}
+	}
+	err := fmt.Errorf("%w: asset file %s", errors.ErrUnsupported, fname)
 	return []AssetData{{Filename: fname, Err: err}}
 }
 
@@ -85,25 +106,96 @@ type AssetData struct {
 	Data     interface{} // struct of loaded data.
 }
 
+// ErrAssetNotFound is wrapped by the load errors
+// for asset files that do not exist.
+var ErrAssetNotFound = errors.New("asset not found")
+
 // ReadFile can be overridden by the app to use other
 // options than loading files from the file system.
-// Eg: the app can use a go:embed FS.
+// Eg: the app can use a go:embed FS, see also MountFS.
 var ReadFile func(string) ([]byte, error) = osReadFile
 
-// osReadFile is the default file system reader.
-func osReadFile(filepath string) ([]byte, error) { return os.ReadFile(filepath) }
+// osReadFile is the default file system reader. Loose files
+// override the files in mounted archives.
+func osReadFile(filepath string) ([]byte, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		if mdata, merr := readMounted(filepath); merr == nil {
+			return mdata, nil
+		}
+	}
+	return data, err
+}
 
-// getData returns the raw bytes in the requested file.
-//
-//	filename: name of the file including the file extension.
-func getData(filename string) (data []byte, err error) {
+// OpenFile can be overridden along with ReadFile. It is used to stream
+// large asset files, eg: movies, instead of reading the whole file.
+var OpenFile func(string) (io.ReadCloser, error) = osOpenFile
+
+// osOpenFile is the default file system opener. Loose files override
+// the files in mounted archives. Files that can't be opened are read
+// using ReadFile so that apps that only override ReadFile still work.
+func osOpenFile(filepath string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		if mfile, merr := openMounted(filepath); merr == nil {
+			return mfile, nil
+		}
+		if data, rerr := ReadFile(filepath); rerr == nil {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// StatFile can be overridden along with ReadFile. It is used to
+// check when asset files have changed. Eg: an embedded FS that never
+// changes can return an error to disable asset reloading.
+var StatFile func(string) (fs.FileInfo, error) = osStatFile
+
+// osStatFile is the default file system stat. Loose files
+// override the files in mounted archives.
+func osStatFile(filepath string) (fs.FileInfo, error) {
+	info, err := os.Stat(filepath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		if minfo, merr := statMounted(filepath); merr == nil {
+			return minfo, nil
+		}
+	}
+	return info, err
+}
+
+// AssetPath returns the file path for the given asset filename
+// using the asset directory conventions.
+func AssetPath(filename string) string {
 	assetDir := "" // default to local directory.
 	extension := getFileExtension(filename)
 	if dir, defined := assetDirs[extension]; defined {
 		assetDir = dir
 	}
-	filepath := strings.TrimSpace(path.Join(assetDir, filename))
-	return ReadFile(filepath)
+	return strings.TrimSpace(path.Join(assetDir, filename))
+}
+
+// ModTime returns the last modification time of the given asset file.
+func ModTime(filename string) (time.Time, error) {
+	info, err := StatFile(AssetPath(filename))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// getData returns the raw bytes in the requested file.
+//
+//	filename: name of the file including the file extension.
+func getData(filename string) (data []byte, err error) {
+	data, err = ReadFile(AssetPath(filename))
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		return data, fmt.Errorf("%w: %w", ErrAssetNotFound, err)
+	}
+	return data, err
 }
 
 // getFileExtension returns the given filename extension
@@ -163,6 +255,44 @@ type ImageData struct {
 	Height uint32
 	Pixels []byte
 	Opaque bool
+	Linear bool // pixels are data, eg: palette indexes, not sRGB colors.
+
+	// Cube images are six square cubemap faces stacked from top to bottom
+	// in the order +X, -X, +Y, -Y, +Z, -Z, so Height is six times Width.
+	Cube bool
+}
+
+// imageMarks are the images marked by SetLinearImage and SetCubeImage.
+var imageMarks = struct {
+	lock   sync.RWMutex
+	linear map[string]bool
+	cube   map[string]bool
+}{linear: map[string]bool{}, cube: map[string]bool{}}
+
+// SetLinearImage marks the named .png image, eg: "knight.png", as linear
+// data that is not gamma corrected when sampled. Used for color index and
+// palette textures, see the "palette" shader. Call before importing the image.
+func SetLinearImage(name string, linear bool) {
+	markImage(imageMarks.linear, name, linear)
+}
+
+// SetCubeImage marks the named .png image, eg: "sky.png", as a cubemap.
+// The image is six square faces stacked from top to bottom in the order
+// +X, -X, +Y, -Y, +Z, -Z. Used for environment textures, see the "reflect"
+// shader. Call before importing the image.
+func SetCubeImage(name string, cube bool) {
+	markImage(imageMarks.cube, name, cube)
+}
+
+// markImage adds or removes the image name from the given marks.
+func markImage(marks map[string]bool, name string, mark bool) {
+	imageMarks.lock.Lock()
+	defer imageMarks.lock.Unlock()
+	if mark {
+		marks[name] = true
+		return
+	}
+	delete(marks, name)
 }
 
 // Image loads .png images as the underlying data format for textures.
@@ -175,7 +305,9 @@ func Image(name string) (idata *ImageData, err error) {
 	if err != nil {
 		return idata, fmt.Errorf("image decode %s: %w", name, err)
 	}
-	idata = &ImageData{}
+	imageMarks.lock.RLock()
+	idata = &ImageData{Linear: imageMarks.linear[name], Cube: imageMarks.cube[name]}
+	imageMarks.lock.RUnlock()
 	switch t := img.(type) {
 	case *image.NRGBA:
 		idata.Pixels = []byte(t.Pix)
@@ -185,11 +317,14 @@ func Image(name string) (idata *ImageData, err error) {
 	}
 	idata.Width = uint32(img.Bounds().Size().X)
 	idata.Height = uint32(img.Bounds().Size().Y)
+	if idata.Cube && idata.Height != 6*idata.Width {
+		return idata, fmt.Errorf("image cubemap needs 6 square faces: %s", name)
+	}
 	return idata, nil
 }
 
 // =============================================================================
-// ".glb" gltf binary files
+// ".glb" gltf binary files and ".gltf" gltf json files
 // https://github.com/KhronosGroup/glTF-Tutorials/blob/master/gltfTutorial/README.md
 
 // Vertex MeshData attribute types.
@@ -199,10 +334,11 @@ const (
 	Normals            // 2 optional:V3 float32
 	Tangents           // 3 optional:V4 float32
 	Colors             // 4 optional:V3 uint8
-	Joints             // 5 FUTURE:V4 uint8    animations
-	Weights            // 6 FUTURE:V4 uint8    animations
-	Indexes            // 7 required:uint16             - must be second last.
-	VertexTypes        // 8 number of vertex data types - must be last.
+	Joints             // 5 optional:V4 float32 joint indexes for animations
+	Weights            // 6 optional:V4 float32 joint weights for animations
+	Texcoords2         // 7 optional:V2 float32 second uv set, eg: lightmaps
+	Indexes            // 8 required:uint16             - must be second last.
+	VertexTypes        // 9 number of vertex data types - must be last.
 )
 
 // Instance Data attribute types describe per-instance model data.
@@ -235,9 +371,44 @@ func Model(name string) (data []AssetData) {
 	if err != nil {
 		return []AssetData{{Filename: name, Err: fmt.Errorf("model load %s: %w", name, err)}}
 	}
+	switch getFileExtension(name) {
+	case ".iqm":
+		return Iqm(name, dbytes)
+	case ".dae":
+		return Dae(name, dbytes)
+	}
 	return Glb(name, bytes.NewReader(dbytes))
 }
 
+// AnimationData is the skeleton and the animation clips for a rigged
+// mesh. The mesh Joints and Weights vertex data reference the joints.
+type AnimationData struct {
+	Joints []JointData // skeleton joints, parents before children.
+	Clips  []ClipData  // animation clips, may be empty.
+}
+
+// JointData is one joint, or bone, of a skeleton.
+type JointData struct {
+	Name   string    // joint name.
+	Parent int32     // parent joint index, -1 for a root joint.
+	Pose   JointPose // bind pose relative to the parent joint.
+}
+
+// JointPose is a joint transform relative to its parent joint.
+type JointPose struct {
+	T [3]float32 // translation x,y,z
+	R [4]float32 // unit quaternion rotation x,y,z,w
+	S [3]float32 // scale x,y,z
+}
+
+// ClipData is a named animation. Each frame has one pose for each joint.
+type ClipData struct {
+	Name   string        // clip name, eg: "walk".
+	Rate   float32       // frames per second.
+	Loop   bool          // true if the clip repeats.
+	Frames [][]JointPose // joint poses for each frame.
+}
+
 // =============================================================================
 // ".ttf" - truetype font glyph mappings
 
@@ -249,6 +420,24 @@ type FontAtlas struct {
 	Img    ImageData // Atlas image ready for upload to GPU.
 	Glyphs []Glyph   // Character position mapping data.
 	NRGBA  *image.NRGBA
+	SDF    bool // true if the atlas alpha is a signed distance field.
+
+	// Pages are additional atlas images for glyphs that
+	// did not fit on the first atlas image.
+	Pages []FontPage
+
+	// LineHeight is the distance in pixels between lines of text.
+	LineHeight int
+
+	// Kerns adjust the advance between specific pairs of glyphs.
+	Kerns []Kern
+}
+
+// FontPage is an additional font atlas image.
+// Glyph.Page 1 is FontAtlas.Pages[0].
+type FontPage struct {
+	Img   ImageData // Atlas image ready for upload to GPU.
+	NRGBA *image.NRGBA
 }
 
 // Glyph holds UV texture mapping information for one character.
@@ -257,14 +446,26 @@ type Glyph struct {
 	Char       rune // Character.
 	X, Y, W, H int  // Character bit size.
 	Xo, Yo, Xa int  // Character offset.
+	Page       int  // Atlas page: 0 is the FontAtlas.Img.
+}
+
+// Kern is the advance adjustment in pixels
+// when the Left glyph is followed by the Right glyph.
+type Kern struct {
+	Left, Right rune
+	Adjust      int
 }
 
 // TTFont loads font character mapping data from a true type font file.
 // By convention, name includes the desired font size eg: "##:font.ttf".
+// A signed distance field atlas is generated when the name is prefixed
+// with "sdf:" eg: "sdf:##:font.ttf". The sdf font tag has a "sdf" suffix,
+// eg: "sdf:48:lucon.ttf" has the tag "lucon48sdf".
 func TTFont(name string) (atlas *FontAtlas, err error) {
 	var size int
 	var fontfile string
-	if _, err := fmt.Sscanf(name, "%d:%s", &size, &fontfile); err != nil {
+	sdfName, isSDF := strings.CutPrefix(name, sdfPrefix)
+	if _, err := fmt.Sscanf(sdfName, "%d:%s", &size, &fontfile); err != nil {
 		return atlas, fmt.Errorf("font name %s: %w", name, err)
 	}
 
@@ -275,17 +476,31 @@ func TTFont(name string) (atlas *FontAtlas, err error) {
 	}
 
 	// generate the atlas and font mapping data
-	atlas, err = Ttf(data, size)
+	if isSDF {
+		atlas, err = TtfSDF(data, size, sdfSpread(size))
+	} else {
+		atlas, err = Ttf(data, size)
+	}
 	if err != nil {
 		return atlas, fmt.Errorf("font generation %s: %w", name, err)
 	}
 	fontName := strings.TrimSuffix(fontfile, path.Ext(fontfile))
 	atlas.Tag = fmt.Sprintf("%s%d", fontName, size)
+	if isSDF {
+		atlas.Tag += "sdf"
+	}
 	return atlas, nil
 }
 
+// sdfPrefix marks font requests that generate signed distance field atlases.
+const sdfPrefix = "sdf:"
+
+// sdfSpread scales the distance field spread with the font size
+// so that outlines and glows have room to render.
+func sdfSpread(size int) int { return max(4, size/8) }
+
 // =============================================================================
-// ".wav" - audio data.
+// ".wav", ".flac", ".mp3" - audio data.
 
 // AudioData consists of the actual audio data bytes along with sounds attributes
 // that describe how the sound data is interpreted and played.
@@ -305,11 +520,57 @@ type AudioAttributes struct {
 	SampleBits uint16 // 8 bits = 8, 16 bits = 16, etc.
 }
 
-// Audio loads audio data.
+// AudioDecoder converts an audio file to PCM audio data.
+type AudioDecoder func(r io.Reader) (*AudioData, error)
+
+// audioDecoders convert each type of audio file to PCM audio data.
+// Other audio files, eg: ".ogg", are added by the application
+// using RegisterAudioDecoder.
+var audioDecoders = struct {
+	lock sync.RWMutex
+	list map[string]AudioDecoder
+}{list: map[string]AudioDecoder{
+	".wav":  Wav,
+	".flac": Flac,
+	".mp3":  Mp3,
+}}
+
+// RegisterAudioDecoder adds a decoder for audio files with the given
+// extension, eg: ".ogg", so that sound libraries don't have to be
+// re-encoded. The decoder wraps a third party library and returns
+// the same PCM data as Wav. Use SetAssetDir for the file location.
+// Registering a nil decoder removes the decoder.
+func RegisterAudioDecoder(ext string, decode AudioDecoder) {
+	audioDecoders.lock.Lock()
+	defer audioDecoders.lock.Unlock()
+	ext = strings.ToLower(ext)
+	if decode == nil {
+		delete(audioDecoders.list, ext)
+		return
+	}
+	audioDecoders.list[ext] = decode
+}
+
+// hasAudioDecoder returns true if audio files with
+// the given extension can be decoded.
+func hasAudioDecoder(ext string) bool {
+	audioDecoders.lock.RLock()
+	defer audioDecoders.lock.RUnlock()
+	return audioDecoders.list[ext] != nil
+}
+
+// Audio loads audio data. All audio files are decoded
+// to the same PCM audio data format.
 func Audio(name string) (aud *AudioData, err error) {
+	audioDecoders.lock.RLock()
+	decode, ok := audioDecoders.list[getFileExtension(name)]
+	audioDecoders.lock.RUnlock()
+	if !ok {
+		return aud, fmt.Errorf("audio load %s: %w", name, errors.ErrUnsupported)
+	}
 	data, err := getData(name)
 	if err != nil {
 		return aud, fmt.Errorf("audio load %s: %w", name, err)
 	}
-	return Wav(bytes.NewReader(data))
+	return decode(bytes.NewReader(data))
 }
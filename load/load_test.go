@@ -3,9 +3,25 @@
 package load
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gazed/vu/internal/load/gltf"
 )
 
 // go test -run Ttf
@@ -23,6 +39,62 @@ func TestTtf(t *testing.T) {
 	}
 }
 
+// go test -run TtfSDF
+func TestTtfSDF(t *testing.T) {
+	SetAssetDir(".ttf", "../assets/fonts")
+	atlas, err := TTFont("sdf:24:hack.ttf")
+	if err != nil {
+		t.Fatalf("sdf ttf load failed %s", err)
+	}
+	if atlas.Tag != "hack24sdf" || !atlas.SDF {
+		t.Errorf("expecting sdf hack24sdf got %s %t", atlas.Tag, atlas.SDF)
+	}
+	bitmap, _ := TTFont("24:hack.ttf")
+	spread := sdfSpread(24)
+	for i, g := range atlas.Glyphs {
+		b := bitmap.Glyphs[i]
+		if g.W != b.W+2*spread || g.H != b.H+2*spread || g.Xo != b.Xo-spread || g.Yo != -spread {
+			t.Fatalf("expecting %c glyph padded by %d got %+v for %+v", g.Char, spread, g, b)
+		}
+	}
+
+	// the glyph box corner is outside the glyph, the center of "I" is inside.
+	for _, g := range atlas.Glyphs {
+		if g.Char == 'I' {
+			corner := atlas.NRGBA.NRGBAAt(g.X, g.Y).A
+			center := atlas.NRGBA.NRGBAAt(g.X+g.W/2, g.Y+g.H/2).A
+			if corner >= 0x80 || center < 0x80 {
+				t.Errorf("expecting outside corner %d and inside center %d", corner, center)
+			}
+		}
+	}
+	if _, err := TtfSDF(nil, 24, 0); err == nil {
+		t.Errorf("expected invalid spread error")
+	}
+}
+
+// go test -run FontRunes
+func TestFontRunes(t *testing.T) {
+	SetAssetDir(".ttf", "../assets/fonts")
+	defer SetFontRunes(DefaultFontRunes())
+	SetFontRunes(DefaultFontRunes() + "éàé")
+	if len(ttfRunes) != len(DefaultFontRunes())+2 {
+		t.Errorf("expected duplicate runes to be ignored got %d runes", len(ttfRunes))
+	}
+
+	// large fonts overflow onto additional atlas pages.
+	atlas, err := TTFont("72:hack.ttf")
+	if err != nil {
+		t.Fatalf("ttf load failed %s", err)
+	}
+	if len(atlas.Pages) == 0 || atlas.Glyphs[len(atlas.Glyphs)-1].Page != len(atlas.Pages) {
+		t.Errorf("expected multiple atlas pages got %d", len(atlas.Pages)+1)
+	}
+	if atlas.LineHeight <= 0 {
+		t.Errorf("expected line height got %d", atlas.LineHeight)
+	}
+}
+
 // go test -run Glb
 func TestGlb(t *testing.T) {
 	SetAssetDir(".glb", "../assets/models")
@@ -69,6 +141,337 @@ func TestGlb(t *testing.T) {
 	})
 }
 
+// go test -run Gltf
+func TestGltf(t *testing.T) {
+	t.Run("rigged", func(t *testing.T) {
+		assets := Glb("rigged.gltf", gltfFile(gltfDoc()))
+		if len(assets) != 3 || assets[0].Err != nil {
+			t.Fatalf("expected mesh, material, and animation data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		if md[Vertexes].Count != 3 || md[Joints].Count != 3 || md[Weights].Count != 3 {
+			t.Errorf("expected 3 vertexes with joints and weights")
+		}
+		if j := math.Float32frombits(binary.LittleEndian.Uint32(md[Joints].Data)); j != 1 {
+			t.Errorf("expected remapped joint index got %f", j)
+		}
+		if mat, ok := assets[1].Data.(PBRMaterialData); !ok || mat.ColorR != 1 {
+			t.Errorf("expected default material %+v", assets[1].Data)
+		}
+		anim := assets[2].Data.(*AnimationData)
+		if len(anim.Joints) != 2 || anim.Joints[0].Name != "root" || anim.Joints[1].Parent != 0 {
+			t.Fatalf("expected parent joint first %+v", anim.Joints)
+		}
+		if anim.Joints[0].Pose.T[1] != 1 || anim.Joints[1].Pose.T[0] != 1 {
+			t.Errorf("expected bind pose translations %+v", anim.Joints)
+		}
+		if len(anim.Clips) != 1 || anim.Clips[0].Name != "wave" || len(anim.Clips[0].Frames) != 31 {
+			t.Fatalf("expected one resampled clip %+v", anim.Clips)
+		}
+		frame := anim.Clips[0].Frames[15]
+		if z := frame[1].R[2]; math.Abs(float64(z)-math.Sin(math.Pi/8)) > 1e-6 {
+			t.Errorf("expected 45 degree arm rotation got %f", z)
+		}
+		if frame[0].T[1] != 1 {
+			t.Errorf("expected armature transform in root joint %+v", frame[0])
+		}
+	})
+	t.Run("transform", func(t *testing.T) {
+		doc := gltfDoc()
+		doc.Nodes[3].Skin = nil
+		doc.Nodes[3].Translation = [3]float64{0, 0, 5}
+		assets := Glb("static.gltf", gltfFile(doc))
+		if len(assets) != 2 || assets[0].Err != nil {
+			t.Fatalf("expected mesh and material data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		y := math.Float32frombits(binary.LittleEndian.Uint32(md[Vertexes].Data[4:]))
+		z := math.Float32frombits(binary.LittleEndian.Uint32(md[Vertexes].Data[8:]))
+		if y != 1 || z != 5 {
+			t.Errorf("expected node transforms got %f %f", y, z)
+		}
+	})
+	t.Run("external", func(t *testing.T) {
+		doc := gltfDoc()
+		bin := doc.Buffers[0].Data
+		doc.Buffers[0].URI = "rigged.bin"
+		defer func(read func(string) ([]byte, error)) { ReadFile = read }(ReadFile)
+		ReadFile = func(name string) ([]byte, error) {
+			if name != "models/rigged.bin" {
+				return nil, fmt.Errorf("unexpected file %s", name)
+			}
+			return bin, nil
+		}
+		SetAssetDir(".gltf", "models")
+		defer SetAssetDir(".gltf", "assets/models")
+		if assets := Glb("rigged.gltf", gltfFile(doc)); len(assets) != 3 || assets[0].Err != nil {
+			t.Errorf("expected external buffer %+v", assets)
+		}
+	})
+	t.Run("lightmap", func(t *testing.T) {
+		doc := gltfDoc()
+		uv2 := &bytes.Buffer{}
+		binary.Write(uv2, binary.LittleEndian, []float32{0, 0, 1, 0, 0, 1})
+		offset := uint32(len(doc.Buffers[0].Data))
+		doc.Buffers[0].Data = append(doc.Buffers[0].Data, uv2.Bytes()...)
+		doc.Buffers[0].ByteLength = uint32(len(doc.Buffers[0].Data))
+		doc.BufferViews[0].ByteLength = doc.Buffers[0].ByteLength
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView: gltf.Index(0), ByteOffset: offset, Count: 3, ComponentType: gltf.ComponentFloat, Type: gltf.AccessorVec2,
+		})
+		doc.Meshes[0].Primitives[0].Attributes[gltf.TEXCOORD_1] = uint32(len(doc.Accessors) - 1)
+		assets := Glb("lightmapped.gltf", gltfFile(doc))
+		if len(assets) == 0 || assets[0].Err != nil {
+			t.Fatalf("expected mesh data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		if md[Texcoords2].Count != 3 || md[Texcoords2].Stride != 8 || md[Texcoords].Count != 0 {
+			t.Errorf("expected lightmap texture coordinates got %d %d", md[Texcoords2].Count, md[Texcoords2].Stride)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		doc := gltfDoc()
+		doc.Nodes[3].Mesh = gltf.Index(5)
+		if assets := Glb("bad.gltf", gltfFile(doc)); assets[0].Err == nil {
+			t.Errorf("expected invalid mesh error")
+		}
+		if assets := Glb("bad.gltf", strings.NewReader("{")); assets[0].Err == nil {
+			t.Errorf("expected decode error")
+		}
+	})
+}
+
+// gltfDoc creates a skinned triangle in an armature with two joints and
+// a one second animation that rotates the second joint 90 degrees.
+// The skin joints are listed child first.
+func gltfDoc() *gltf.Document {
+	body := &bytes.Buffer{}
+	add := func(v any) *uint32 {
+		offset := body.Len()
+		binary.Write(body, binary.LittleEndian, v)
+		return gltf.Index(uint32(offset))
+	}
+	positions := add([]float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	joints := add([]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	weights := add([]float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0})
+	indexes := add([]uint32{0, 1, 2})
+	times := add([]float32{0, 1})
+	s := float32(math.Sqrt2 / 2)
+	rotations := add([]float32{0, 0, 0, 1, 0, 0, s, s})
+	doc := gltf.NewDocument()
+	doc.Buffers = []*gltf.Buffer{{ByteLength: uint32(body.Len()), Data: body.Bytes()}}
+	doc.BufferViews = []*gltf.BufferView{{ByteLength: uint32(body.Len())}}
+	accessor := func(offset *uint32, count uint32, c gltf.ComponentType, a gltf.AccessorType) uint32 {
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView: gltf.Index(0), ByteOffset: *offset, Count: count, ComponentType: c, Type: a,
+		})
+		return uint32(len(doc.Accessors) - 1)
+	}
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{{
+		Attributes: gltf.Attribute{
+			gltf.POSITION:  accessor(positions, 3, gltf.ComponentFloat, gltf.AccessorVec3),
+			gltf.JOINTS_0:  accessor(joints, 3, gltf.ComponentUbyte, gltf.AccessorVec4),
+			gltf.WEIGHTS_0: accessor(weights, 3, gltf.ComponentFloat, gltf.AccessorVec4),
+		},
+		Indices: gltf.Index(accessor(indexes, 3, gltf.ComponentUint, gltf.AccessorScalar)),
+	}}}}
+	doc.Nodes = []*gltf.Node{
+		{Name: "armature", Translation: [3]float64{0, 1, 0}, Children: []uint32{1, 3}},
+		{Name: "root", Children: []uint32{2}},
+		{Name: "arm", Translation: [3]float64{1, 0, 0}},
+		{Name: "body", Mesh: gltf.Index(0), Skin: gltf.Index(0)},
+	}
+	doc.Scenes[0].Nodes = []uint32{0}
+	doc.Skins = []*gltf.Skin{{Joints: []uint32{2, 1}}}
+	doc.Animations = []*gltf.Animation{{
+		Name:     "wave",
+		Channels: []*gltf.Channel{{Sampler: gltf.Index(0), Target: gltf.ChannelTarget{Node: gltf.Index(2), Path: gltf.TRSRotation}}},
+		Samplers: []*gltf.AnimationSampler{{
+			Input:  accessor(times, 2, gltf.ComponentFloat, gltf.AccessorScalar),
+			Output: accessor(rotations, 2, gltf.ComponentFloat, gltf.AccessorVec4),
+		}},
+	}}
+	return doc
+}
+
+// gltfFile encodes the document as gltf json. Buffers
+// without a URI are embedded in the json.
+func gltfFile(doc *gltf.Document) *bytes.Reader {
+	for _, b := range doc.Buffers {
+		if b.URI == "" {
+			b.EmbeddedResource()
+		}
+	}
+	data, _ := json.Marshal(doc)
+	return bytes.NewReader(data)
+}
+
+// go test -run Collada
+func TestCollada(t *testing.T) {
+	t.Run("rigged", func(t *testing.T) {
+		assets := Dae("rigged.dae", []byte(colladaDoc))
+		if len(assets) != 3 || assets[0].Err != nil {
+			t.Fatalf("expected mesh, material, and animation data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		if md[Vertexes].Count != 4 || md[Normals].Count != 4 || md[Indexes].Count != 6 {
+			t.Errorf("expected triangulated quad %d %d", md[Vertexes].Count, md[Indexes].Count)
+		}
+		if j := math.Float32frombits(binary.LittleEndian.Uint32(md[Joints].Data)); j != 1 {
+			t.Errorf("expected remapped joint index got %f", j)
+		}
+		if mat, ok := assets[1].Data.(PBRMaterialData); !ok || mat.ColorR != 1 || mat.Metallic != 0 {
+			t.Errorf("expected red phong material %+v", assets[1].Data)
+		}
+		anim := assets[2].Data.(*AnimationData)
+		if len(anim.Joints) != 2 || anim.Joints[0].Name != "root" || anim.Joints[1].Parent != 0 {
+			t.Fatalf("expected parent joint first %+v", anim.Joints)
+		}
+		if len(anim.Clips) != 1 || len(anim.Clips[0].Frames) != 31 {
+			t.Fatalf("expected one resampled clip %+v", anim.Clips)
+		}
+		frame := anim.Clips[0].Frames[15]
+		if z := frame[1].R[2]; math.Abs(float64(z)-math.Sin(math.Pi/8)) > 1e-6 {
+			t.Errorf("expected 45 degree arm rotation got %f", z)
+		}
+		if y := frame[0].T[1]; math.Abs(float64(y)-1) > 1e-6 {
+			t.Errorf("expected z up armature in root joint %+v", frame[0])
+		}
+	})
+	t.Run("static", func(t *testing.T) {
+		doc := strings.Replace(colladaDoc, "<instance_controller url=\"#skin\">", "<instance_geometry url=\"#quad\">", 1)
+		doc = strings.Replace(doc, "</instance_controller>", "</instance_geometry>", 1)
+		assets := Dae("static.dae", []byte(doc))
+		if len(assets) != 2 || assets[0].Err != nil {
+			t.Fatalf("expected mesh and material data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		v := make([]float32, 12)
+		binary.Read(bytes.NewReader(md[Vertexes].Data), binary.LittleEndian, v)
+		if v[6] != 1 || v[7] != 2 || v[8] != 0 { // collada 1,0,1 is 1,1,0 plus the armature.
+			t.Errorf("expected z up node transforms got %v", v)
+		}
+		uv := math.Float32frombits(binary.LittleEndian.Uint32(md[Texcoords].Data[4:]))
+		if uv != 1 {
+			t.Errorf("expected flipped texture coordinates got %f", uv)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		doc := strings.Replace(colladaDoc, "source=\"#quad-positions\"", "source=\"#missing\"", 1)
+		if assets := Dae("bad.dae", []byte(doc)); assets[0].Err == nil {
+			t.Errorf("expected invalid source error")
+		}
+		if assets := Dae("bad.dae", []byte("<COLLADA>")); assets[0].Err == nil {
+			t.Errorf("expected decode error")
+		}
+	})
+}
+
+// colladaDoc is a z up armature with two joints and a skinned quad.
+// The one second animation rotates the second joint 90 degrees.
+// The skin joints are listed child first.
+const colladaDoc = `<?xml version="1.0" encoding="utf-8"?>
+<COLLADA xmlns="http://www.collada.org/2005/11/COLLADASchema" version="1.4.1">
+  <asset><unit name="meter" meter="1"/><up_axis>Z_UP</up_axis></asset>
+  <library_effects>
+    <effect id="red-effect"><profile_COMMON><technique sid="common"><phong>
+      <diffuse><color sid="diffuse">1 0 0 1</color></diffuse>
+      <shininess><float sid="shininess">50</float></shininess>
+    </phong></technique></profile_COMMON></effect>
+  </library_effects>
+  <library_materials>
+    <material id="red" name="red"><instance_effect url="#red-effect"/></material>
+  </library_materials>
+  <library_geometries>
+    <geometry id="quad" name="quad"><mesh>
+      <source id="quad-positions">
+        <float_array id="quad-positions-array" count="12">0 0 0 1 0 0 1 0 1 0 0 1</float_array>
+        <technique_common><accessor source="#quad-positions-array" count="4" stride="3"/></technique_common>
+      </source>
+      <source id="quad-normals">
+        <float_array id="quad-normals-array" count="3">0 -1 0</float_array>
+        <technique_common><accessor source="#quad-normals-array" count="1" stride="3"/></technique_common>
+      </source>
+      <source id="quad-uvs">
+        <float_array id="quad-uvs-array" count="8">0 0 1 0 1 1 0 1</float_array>
+        <technique_common><accessor source="#quad-uvs-array" count="4" stride="2"/></technique_common>
+      </source>
+      <vertices id="quad-vertices"><input semantic="POSITION" source="#quad-positions"/></vertices>
+      <polylist material="red-material" count="1">
+        <input semantic="VERTEX" source="#quad-vertices" offset="0"/>
+        <input semantic="NORMAL" source="#quad-normals" offset="1"/>
+        <input semantic="TEXCOORD" source="#quad-uvs" offset="2" set="0"/>
+        <vcount>4</vcount>
+        <p>0 0 0 1 0 1 2 0 2 3 0 3</p>
+      </polylist>
+    </mesh></geometry>
+  </library_geometries>
+  <library_controllers>
+    <controller id="skin"><skin source="#quad">
+      <bind_shape_matrix>1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1</bind_shape_matrix>
+      <source id="skin-joints"><Name_array id="skin-joints-array" count="2">arm root</Name_array></source>
+      <source id="skin-bind">
+        <float_array id="skin-bind-array" count="32">1 0 0 -1 0 1 0 0 0 0 1 -1 0 0 0 1 1 0 0 0 0 1 0 0 0 0 1 -1 0 0 0 1</float_array>
+        <technique_common><accessor source="#skin-bind-array" count="2" stride="16"/></technique_common>
+      </source>
+      <source id="skin-weights">
+        <float_array id="skin-weights-array" count="2">1 0.5</float_array>
+        <technique_common><accessor source="#skin-weights-array" count="2" stride="1"/></technique_common>
+      </source>
+      <joints>
+        <input semantic="JOINT" source="#skin-joints"/>
+        <input semantic="INV_BIND_MATRIX" source="#skin-bind"/>
+      </joints>
+      <vertex_weights count="4">
+        <input semantic="JOINT" source="#skin-joints" offset="0"/>
+        <input semantic="WEIGHT" source="#skin-weights" offset="1"/>
+        <vcount>1 2 1 1</vcount>
+        <v>0 0 0 1 1 1 1 0 1 0</v>
+      </vertex_weights>
+    </skin></controller>
+  </library_controllers>
+  <library_animations>
+    <animation id="wave">
+      <source id="wave-times">
+        <float_array id="wave-times-array" count="2">0 1</float_array>
+        <technique_common><accessor source="#wave-times-array" count="2" stride="1"/></technique_common>
+      </source>
+      <source id="wave-angles">
+        <float_array id="wave-angles-array" count="2">0 90</float_array>
+        <technique_common><accessor source="#wave-angles-array" count="2" stride="1"/></technique_common>
+      </source>
+      <sampler id="wave-sampler">
+        <input semantic="INPUT" source="#wave-times"/>
+        <input semantic="OUTPUT" source="#wave-angles"/>
+      </sampler>
+      <channel source="#wave-sampler" target="arm/rotateZ.ANGLE"/>
+    </animation>
+  </library_animations>
+  <library_visual_scenes>
+    <visual_scene id="scene">
+      <node id="armature" name="armature">
+        <translate sid="location">0 0 1</translate>
+        <node id="root" sid="root" name="root" type="JOINT">
+          <node id="arm" sid="arm" name="arm" type="JOINT">
+            <translate sid="location">1 0 0</translate>
+            <rotate sid="rotateZ">0 0 1 0</rotate>
+          </node>
+        </node>
+        <node id="body" name="body">
+          <instance_controller url="#skin">
+            <skeleton>#root</skeleton>
+            <bind_material><technique_common>
+              <instance_material symbol="red-material" target="#red"/>
+            </technique_common></bind_material>
+          </instance_controller>
+        </node>
+      </node>
+    </visual_scene>
+  </library_visual_scenes>
+  <scene><instance_visual_scene url="#scene"/></scene>
+</COLLADA>
+`
+
 func TestWav(t *testing.T) {
 	SetAssetDir(".wav", "../assets/audio")
 	snd, err := Audio("bloop.wav")
@@ -77,12 +480,350 @@ func TestWav(t *testing.T) {
 	}
 }
 
+// go test -run Flac
+func TestFlac(t *testing.T) {
+	t.Run("mono", func(t *testing.T) {
+		SetAssetDir(".wav", "../assets/audio")
+		SetAssetDir(".flac", "../assets/audio")
+		wav, _ := Audio("bloop.wav")
+		snd, err := Audio("bloop.flac")
+		if err != nil {
+			t.Fatalf("flac load failed %s", err)
+		}
+		if *snd.Attrs != *wav.Attrs || !bytes.Equal(snd.Data, wav.Data) {
+			t.Errorf("expected flac to match wav got %+v", snd.Attrs)
+		}
+	})
+
+	// stereo 16 bit frames use each subframe type and stereo decorrelation.
+	t.Run("stereo", func(t *testing.T) {
+		data, _ := hex.DecodeString(flacStereo)
+		snd, err := Flac(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("flac decode failed %s", err)
+		}
+		want := AudioAttributes{Channels: 2, Frequency: 44100, DataSize: 176 * 4, SampleBits: 16}
+		if *snd.Attrs != want {
+			t.Fatalf("expected %+v got %+v", want, *snd.Attrs)
+		}
+		for i := 0; i < 176; i++ {
+			left, right := int16(-800), int16(300)
+			if i < 160 {
+				left = int16((i*37)%2000-1000) * 8
+				right = left>>1 + int16(i%7)
+			}
+			l := int16(binary.LittleEndian.Uint16(snd.Data[i*4:]))
+			r := int16(binary.LittleEndian.Uint16(snd.Data[i*4+2:]))
+			if l != left || r != right {
+				t.Fatalf("sample %d expected %d %d got %d %d", i, left, right, l, r)
+			}
+		}
+		if _, err := Flac(bytes.NewReader(data[:len(data)-40])); err == nil {
+			t.Errorf("expected truncated flac to fail")
+		}
+	})
+
+	// applications add decoders for other audio files.
+	t.Run("register", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "bloop.ogg"), []byte{1, 2}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		SetAssetDir(".ogg", dir)
+		if assets := LoadAssetFile("bloop.ogg"); !errors.Is(assets[0].Err, errors.ErrUnsupported) {
+			t.Fatalf("expected unsupported got %v", assets[0].Err)
+		}
+		RegisterAudioDecoder(".OGG", func(r io.Reader) (*AudioData, error) {
+			data, err := io.ReadAll(r)
+			return &AudioData{Data: data, Attrs: &AudioAttributes{Channels: 1, Frequency: 8000, SampleBits: 8}}, err
+		})
+		defer RegisterAudioDecoder(".ogg", nil)
+		assets := LoadAssetFile("bloop.ogg")
+		if aud, ok := assets[0].Data.(*AudioData); assets[0].Err != nil || !ok || len(aud.Data) != 2 {
+			t.Errorf("expected decoded audio got %+v", assets[0])
+		}
+	})
+
+	// tags after the last frame are ignored.
+	t.Run("trailing tags", func(t *testing.T) {
+		data, _ := hex.DecodeString(flacStereo)
+		tagged := append(append([]byte{}, data...), "TAG"...)
+		tagged = append(tagged, make([]byte, 125)...) // ID3v1 tag.
+		snd, err := Flac(bytes.NewReader(tagged))
+		if err != nil || snd.Attrs.DataSize != 176*4 {
+			t.Errorf("expected trailing tag to be ignored %v", err)
+		}
+	})
+}
+
+// go test -run Mp3
+func TestMp3(t *testing.T) {
+	// silent 32kbps 32kHz mono frames of 1152 samples.
+	frame := append([]byte{0xff, 0xfb, 0x18, 0xc0}, make([]byte, 140)...)
+	t.Run("silence", func(t *testing.T) {
+		snd, err := Mp3(bytes.NewReader(bytes.Repeat(frame, 3)))
+		if err != nil {
+			t.Fatalf("mp3 decode failed %s", err)
+		}
+		want := AudioAttributes{Channels: 2, Frequency: 32000, DataSize: 3 * 1152 * 4, SampleBits: 16}
+		if *snd.Attrs != want {
+			t.Fatalf("expected %+v got %+v", want, *snd.Attrs)
+		}
+		if !bytes.Equal(snd.Data, make([]byte, want.DataSize)) {
+			t.Errorf("expected silence")
+		}
+	})
+
+	// go test -run Mp3/asset
+	t.Run("asset", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "quiet.mp3"), frame, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		SetAssetDir(".mp3", dir)
+		defer SetAssetDir(".mp3", "../assets/audio")
+		if snd, err := Audio("quiet.mp3"); err != nil || snd.Attrs.DataSize != 1152*4 {
+			t.Errorf("expected mp3 asset got %v", err)
+		}
+	})
+
+	// go test -run Mp3/invalid
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := Mp3(bytes.NewReader([]byte("RIFF1234WAVE"))); err == nil {
+			t.Errorf("expected invalid mp3 to fail")
+		}
+	})
+}
+
+// flacStereo is 176 samples of 16 bit stereo audio.
+var flacStereo = "" +
+	"664c614380000022001000200000000000000ac442f0000000b044d91cedb9b898e6b585c227a9edeb51fff86018001f" +
+	"44173c18e1ef18820f87f87f87fa17830787aa08fd054b0b0b0b0b1f1b16161616163e3652c2c2c2c2c7c6c585858585" +
+	"8f8d8b0b007d5afff86088011ff81405c006e802e0020020020020020020020020020020020020020020020020020020" +
+	"0200200000067f8000d0080080080080080080080012016e06e5314c5a394c5314c53394c5316853394c5314c53394c5" +
+	"a14c535c9349349300d5972d0a6298a67298a6298b40486ffff86098021f6540fb0f88140e5314c5314c531685314c53" +
+	"14c5314c5a14c5314c5314c531685314c5314c5314c5a14c5314c53017b10fb5b7ba5fbf07c3afc857ccc7d16fd617da" +
+	"bfdf67e40fe8b7ed27f1cff677fb1fffc0046809100d80122816d01b78202024c829702de0328837303bd84080393aff" +
+	"f860a8031f91180cf20dd10eac0f8a0882c23fc40009fff900067ffdc0001d124464e5cd92c006d940c408c00404403c" +
+	"044069882611e868810031136497139052d95dc5c098286539a746ea1cce77d1f1a78fdd196fcdabf66658735d356275" +
+	"4053db4a9d181416fe6be0fe74ef0f51f969a169fff86018041f1002f7c0f8e8fa10fb38fc60fd88feb0ffd801000228" +
+	"0350047805a006c807f009180a400b680c900db80ee0100811301258138014a815d016f8182019481a701b9814fbe2fc" +
+	"77006a03015540602aa80c05550180aa808d8afff86018050f7500fce000012c4bb0"
+
+// go test -run Video
+func TestVideo(t *testing.T) {
+	// mkv returns a matroska element with an 8 byte size.
+	mkv := func(id uint64, body ...[]byte) []byte {
+		el := []byte{}
+		for shift := 24; shift >= 0; shift -= 8 {
+			if b := byte(id >> shift); b != 0 || len(el) > 0 {
+				el = append(el, b)
+			}
+		}
+		data := bytes.Join(body, nil)
+		el = append(el, 0x01) // 8 byte size.
+		el = append(el, binary.BigEndian.AppendUint64(nil, uint64(len(data)))[1:]...)
+		return append(el, data...)
+	}
+	u := func(v uint64) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+	// drain reads the rest of the movie packets.
+	drain := func(vs *VideoStream) (frames, sounds []VideoPacket, err error) {
+		for {
+			p, audio, err := vs.Next()
+			switch {
+			case errors.Is(err, io.EOF):
+				return frames, sounds, nil
+			case err != nil:
+				return frames, sounds, err
+			case audio:
+				sounds = append(sounds, p)
+			default:
+				frames = append(frames, p)
+			}
+		}
+	}
+
+	// go test -run Video/webm
+	t.Run("webm", func(t *testing.T) {
+		rate := binary.BigEndian.AppendUint64(nil, math.Float64bits(48000))
+		data := bytes.Join([][]byte{
+			mkv(mkvEBML, mkv(0x4282, []byte("webm"))),
+			mkv(mkvSegment,
+				mkv(mkvInfo, mkv(mkvTimecodeScale, u(1000000)), mkv(mkvDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(1100)))),
+				mkv(mkvTracks,
+					mkv(mkvTrackEntry, mkv(mkvTrackNumber, u(1)), mkv(mkvTrackType, u(1)), mkv(mkvCodecID, []byte("V_VP9")),
+						mkv(mkvFrameDuration, u(40000000)), mkv(mkvVideo, mkv(mkvPixelWidth, u(64)), mkv(mkvPixelHeight, u(32)))),
+					mkv(mkvTrackEntry, mkv(mkvTrackNumber, u(2)), mkv(mkvTrackType, u(2)), mkv(mkvCodecID, []byte("A_VORBIS")),
+						mkv(mkvCodecPrivate, []byte{2, 1, 2, 'a', 'b', 'b', 'c', 'c', 'c'}),
+						mkv(mkvAudio, mkv(mkvSampleRate, rate), mkv(mkvChannels, u(2))))),
+				mkv(mkvCluster, mkv(mkvTimecode, u(1000)),
+					mkv(mkvSimpleBlock, []byte{0x81, 0, 0, 0x80, 'k'}),
+					mkv(mkvSimpleBlock, []byte{0x82, 0, 0, 0x02, 1, 3, 'a', 'b', 'c', 'd', 'e'}),
+					mkv(mkvBlockGroup, mkv(mkvBlock, []byte{0x81, 0, 40, 0, 'p'}), mkv(mkvReference, []byte{0xD8})))),
+		}, nil)
+		vid, err := Webm(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vid.Codec != "vp9" || vid.Width != 64 || vid.Height != 32 || vid.FPS != 25 || vid.Duration != 1100*time.Millisecond {
+			t.Errorf("unexpected video track %s %dx%d %f %v", vid.Codec, vid.Width, vid.Height, vid.FPS, vid.Duration)
+		}
+		if vid.AudioCodec != "vorbis" || vid.Channels != 2 || vid.Rate != 48000 || len(vid.AudioHeaders) != 3 || string(vid.AudioHeaders[2]) != "ccc" {
+			t.Errorf("unexpected audio track %s %d %d %q", vid.AudioCodec, vid.Channels, vid.Rate, vid.AudioHeaders)
+		}
+		frames, sounds, err := drain(vid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 2 || !frames[0].Key || frames[1].Key || string(frames[1].Data) != "p" {
+			t.Fatalf("unexpected frames %+v", frames)
+		}
+		if frames[0].Time != time.Second || frames[1].Time != 1040*time.Millisecond {
+			t.Errorf("unexpected frame times %v %v", frames[0].Time, frames[1].Time)
+		}
+		if len(sounds) != 2 || string(sounds[0].Data) != "abc" || string(sounds[1].Data) != "de" {
+			t.Errorf("expected laced audio packets got %+v", sounds)
+		}
+		vid, err = Webm(bytes.NewReader(data[:len(data)-3]))
+		if _, _, err = drain(vid); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("expected truncated webm to fail got %v", err)
+		}
+	})
+
+	// go test -run Video/lacing
+	t.Run("lacing", func(t *testing.T) {
+		frames, err := mkvLacing([]byte{0x83, 0xBE, 'a', 'b', 'c', 'd', 'e', 'f', 'g'}, 3, 3)
+		if err != nil || len(frames) != 3 || string(frames[0]) != "abc" || string(frames[1]) != "de" || string(frames[2]) != "fg" {
+			t.Errorf("unexpected ebml lacing %q %v", frames, err)
+		}
+		frames, err = mkvLacing([]byte("aabbcc"), 2, 3)
+		if err != nil || len(frames) != 3 || string(frames[2]) != "cc" {
+			t.Errorf("unexpected fixed lacing %q %v", frames, err)
+		}
+		if _, err = mkvLacing([]byte{9, 'a'}, 1, 2); err == nil {
+			t.Errorf("expected oversized xiph lacing to fail")
+		}
+	})
+
+	// go test -run Video/ogv
+	t.Run("ogv", func(t *testing.T) {
+		seen := map[uint32]bool{}
+		page := func(serial uint32, granule int64, packets ...[]byte) []byte {
+			lacing, body := []byte{}, []byte{}
+			for _, p := range packets {
+				for n := len(p); ; n -= 255 {
+					lacing = append(lacing, byte(min(n, 255)))
+					if n < 255 {
+						break
+					}
+				}
+				body = append(body, p...)
+			}
+			pg := append([]byte("OggS\x00\x00"), make([]byte, 21)...)
+			binary.LittleEndian.PutUint64(pg[6:], uint64(granule))
+			binary.LittleEndian.PutUint32(pg[14:], serial)
+			pg[26] = byte(len(lacing))
+			if !seen[serial] {
+				pg[5], seen[serial] = 0x02, true // first page of the stream.
+			}
+			pg = append(append(pg, lacing...), body...)
+			binary.LittleEndian.PutUint32(pg[22:], oggCRC(pg))
+			return pg
+		}
+		theora := make([]byte, 42)
+		copy(theora, "\x80theora\x03\x02\x01")
+		copy(theora[14:], []byte{0, 0, 64, 0, 0, 32, 0, 0, 0, 0, 0, 30, 0, 0, 0, 1})
+		vorbis := make([]byte, 30)
+		copy(vorbis, "\x01vorbis")
+		vorbis[11] = 1
+		binary.LittleEndian.PutUint32(vorbis[12:], 44100)
+		long := bytes.Repeat([]byte{0x40}, 300) // spans two lacing values.
+		data := bytes.Join([][]byte{
+			page(7, 0, theora),
+			page(9, 0, vorbis),
+			page(7, 0, []byte("\x81comment"), []byte("\x82setup")),
+			page(9, 0, []byte("\x03comment"), []byte("\x05setup")),
+			page(7, 3<<6, []byte{0x00, 1}, long, []byte{}),
+			page(9, 4410, []byte("sound")),
+		}, nil)
+		vid, err := Ogv(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vid.Codec != "theora" || vid.Width != 64 || vid.Height != 32 || vid.FPS != 30 || len(vid.Headers) != 3 {
+			t.Errorf("unexpected video stream %s %dx%d %f", vid.Codec, vid.Width, vid.Height, vid.FPS)
+		}
+		if vid.AudioCodec != "vorbis" || vid.Channels != 1 || vid.Rate != 44100 || len(vid.AudioHeaders) != 3 {
+			t.Errorf("unexpected audio stream %s %d %d", vid.AudioCodec, vid.Channels, vid.Rate)
+		}
+		frames, sounds, err := drain(vid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 3 || !frames[0].Key || frames[1].Key || len(frames[1].Data) != 300 || len(frames[2].Data) != 0 {
+			t.Fatalf("unexpected frames %d", len(frames))
+		}
+		if want := 2 * time.Second / 30; frames[2].Time < want-time.Microsecond || frames[2].Time > want+time.Microsecond {
+			t.Errorf("expected last frame at %v got %v", want, frames[2].Time)
+		}
+		if len(sounds) != 1 || sounds[0].Time != 100*time.Millisecond {
+			t.Errorf("unexpected audio packets %+v", sounds)
+		}
+		data[len(data)-1] ^= 0xFF
+		vid, err = Ogv(bytes.NewReader(data))
+		if _, _, err = drain(vid); err == nil {
+			t.Errorf("expected page checksum to fail")
+		}
+	})
+
+	// go test -run Video/invalid
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := Video("intro.avi"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unsupported movie got %v", err)
+		}
+		if _, err := Video("missing.webm"); !errors.Is(err, ErrAssetNotFound) {
+			t.Errorf("expected missing movie got %v", err)
+		}
+		if _, err := Ogv(bytes.NewReader([]byte("OggS"))); err == nil {
+			t.Errorf("expected short ogg page to fail")
+		}
+	})
+}
+
 func TestImage(t *testing.T) {
 	SetAssetDir(".png", "../assets/images")
 	img, err := Image("keyboard.png")
 	if err != nil && len(img.Pixels) > 0 {
 		t.Errorf("image load failed %s", err)
 	}
+	SetLinearImage("keyboard.png", true)
+	defer SetLinearImage("keyboard.png", false)
+	if img, err = Image("keyboard.png"); err != nil || !img.Linear {
+		t.Errorf("expected a linear image %v", err)
+	}
+	SetCubeImage("keyboard.png", true)
+	defer SetCubeImage("keyboard.png", false)
+	if _, err = Image("keyboard.png"); err == nil {
+		t.Errorf("expected cubemap to need 6 square faces")
+	}
+}
+
+// go test -run Errors
+func TestErrors(t *testing.T) {
+	t.Run("asset not found", func(t *testing.T) {
+		SetAssetDir(".png", "../assets/images")
+		_, err := Image("missing.png")
+		if !errors.Is(err, ErrAssetNotFound) || !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected ErrAssetNotFound got %v", err)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		assets := LoadAssetFile("notes.txt")
+		if len(assets) != 1 || !errors.Is(assets[0].Err, errors.ErrUnsupported) {
+			t.Errorf("expected ErrUnsupported got %v", assets)
+		}
+	})
 }
 
 // go test -run Shader
@@ -113,8 +854,8 @@ func TestShader(t *testing.T) {
 		if shd.Attrs[1].AttrType != Texcoords {
 			t.Fatalf("expected texcoord as second attribute")
 		}
-		if len(shd.Uniforms) != 4 {
-			t.Fatalf("expected 4 uniforms got %d", len(shd.Uniforms))
+		if len(shd.Uniforms) != 6 {
+			t.Fatalf("expected 6 uniforms got %d", len(shd.Uniforms))
 		}
 	})
 
@@ -125,6 +866,109 @@ func TestShader(t *testing.T) {
 		}
 	})
 
+	t.Run("palette", func(t *testing.T) {
+		shd, err := ShaderConfig("palette.shd")
+		if err != nil || shd.Name != "palette" || shd.Pass != "2D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 2 || samplers[1].Name != "palette" {
+			t.Errorf("expected color and palette samplers got %v", samplers)
+		}
+		if u := shd.Uniforms[len(shd.Uniforms)-1]; u.PacketUID != PALETTEROW || u.DataType != DataType_VEC4 {
+			t.Errorf("expected palette row uniform got %v", u)
+		}
+	})
+
+	t.Run("terrain", func(t *testing.T) {
+		shd, err := ShaderConfig("terrain.shd")
+		if err != nil || shd.Name != "terrain" || shd.Pass != "3D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 5 || samplers[0].Name != "splat" {
+			t.Errorf("expected splat and layer samplers got %v", samplers)
+		}
+	})
+
+	t.Run("water", func(t *testing.T) {
+		shd, err := ShaderConfig("water.shd")
+		if err != nil || shd.Name != "water" || shd.Pass != "3D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 4 || samplers[1].Name != "sky" || samplers[3].Name != "refraction" {
+			t.Errorf("expected normals, sky, and reflection samplers got %v", samplers)
+		}
+	})
+
+	t.Run("lightmap", func(t *testing.T) {
+		shd, err := ShaderConfig("lightmap.shd")
+		if err != nil || shd.Name != "lightmap" || shd.Pass != "3D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if len(shd.Attrs) != 3 || shd.Attrs[2].AttrType != Texcoords2 {
+			t.Errorf("expected lightmap texture coordinates as third attribute")
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 2 || samplers[1].Name != "lightmap" {
+			t.Errorf("expected color and lightmap samplers got %v", samplers)
+		}
+	})
+
+	t.Run("gui", func(t *testing.T) {
+		shd, err := ShaderConfig("gui.shd")
+		if err != nil || shd.Name != "gui" || shd.Pass != "2D" || shd.DrawLines {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if len(shd.Attrs) != 2 || shd.Attrs[1].AttrType != Colors || len(shd.GetSamplerUniforms()) != 0 {
+			t.Errorf("expected position and color attributes")
+		}
+	})
+
+	t.Run("skin", func(t *testing.T) {
+		shd, err := ShaderConfig("skin.shd")
+		if err != nil || shd.Name != "skin" || shd.Pass != "2D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 1 || samplers[0].Name != "color" {
+			t.Errorf("expected color sampler got %v", samplers)
+		}
+	})
+
+	t.Run("reflect", func(t *testing.T) {
+		shd, err := ShaderConfig("reflect.shd")
+		if err != nil || shd.Name != "reflect" || shd.Pass != "3D" {
+			t.Fatalf("shader configuration load failed %s", err)
+		}
+		if samplers := shd.GetSamplerUniforms(); len(samplers) != 1 || samplers[0].Name != "env" {
+			t.Errorf("expected env sampler got %v", samplers)
+		}
+		probe := 0
+		for _, u := range shd.Uniforms {
+			if u.Scope == ModelScope && (u.PacketUID == PROBE || u.PacketUID == PROBEBOX) {
+				probe++
+			}
+		}
+		if probe != 2 {
+			t.Errorf("expected probe model uniforms got %d", probe)
+		}
+	})
+
+	t.Run("fog", func(t *testing.T) {
+		for _, name := range []string{"pbr0.shd", "pbr1.shd", "anim3D.shd", "tex3D.shd", "col3D.shd", "lightmap.shd", "reflect.shd", "sky.shd"} {
+			shd, err := ShaderConfig(name)
+			if err != nil {
+				t.Fatalf("shader configuration load failed %s", err)
+			}
+			fog := 0
+			for _, u := range shd.Uniforms {
+				if u.Scope == SceneScope && (u.PassUID == FOG || u.PassUID == FOGCOLOR) {
+					fog++
+				}
+			}
+			if want := map[bool]int{true: 0, false: 2}[name == "sky.shd"]; fog != want {
+				t.Errorf("%s expected %d fog uniforms got %d", name, want, fog)
+			}
+		}
+	})
+
 	t.Run("bbinst", func(t *testing.T) {
 		shd, err := ShaderConfig("bbinst.shd")
 		if err != nil || shd.Name != "bbinst" || shd.Pass != "3D" {
@@ -153,3 +997,260 @@ func dumpMesh() {
 	md[Texcoords].PrintF32("Texcoords")
 	md[Indexes].PrintU16("Indexes")
 }
+
+// go test -run Simplify
+func TestSimplify(t *testing.T) {
+	t.Run("plane", func(t *testing.T) {
+		md := gridMesh(10) // flat 1x1 plane with 200 triangles.
+		lods, err := Simplify(md, 0.5, 0.1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, expect := range []uint32{100, 20} {
+			tris := lods[i][Indexes].Count / 3
+			if tris > expect || tris == 0 {
+				t.Errorf("expected at most %d triangles got %d", expect, tris)
+			}
+			if area := meshArea(lods[i]); math.Abs(area-1) > 1e-4 {
+				t.Errorf("expected the plane area to be kept got %f", area)
+			}
+		}
+	})
+	t.Run("monkey", func(t *testing.T) {
+		SetAssetDir(".glb", "../assets/models")
+		assets := Model("monkey0.glb")
+		md, ok := assets[0].Data.(MeshData)
+		if !ok {
+			t.Fatalf("exepcted mesh data")
+		}
+		lods, err := Simplify(md, 0.25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, max := lods[0][Indexes].Count, md[Indexes].Count/4; got > max+2 || got == 0 {
+			t.Errorf("expected about %d indexes got %d", max, got)
+		}
+		if &lods[0][Vertexes].Data[0] != &md[Vertexes].Data[0] {
+			t.Errorf("expected shared vertex data")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := Simplify(gridMesh(2), 1.5); err == nil {
+			t.Errorf("expected invalid ratio error")
+		}
+		if _, err := Simplify(make(MeshData, VertexTypes), 0.5); err == nil {
+			t.Errorf("expected missing data error")
+		}
+	})
+}
+
+// gridMesh creates a flat 1x1 grid of size*size quads.
+func gridMesh(size int) MeshData {
+	verts, index := []float32{}, []uint16{}
+	for y := 0; y <= size; y++ {
+		for x := 0; x <= size; x++ {
+			verts = append(verts, float32(x)/float32(size), float32(y)/float32(size), 0)
+		}
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := uint16(y*(size+1) + x)
+			w := v + uint16(size+1)
+			index = append(index, v, v+1, w+1, v, w+1, w)
+		}
+	}
+	md := make(MeshData, VertexTypes)
+	md[Vertexes] = F32Buffer(verts, 3)
+	md[Indexes] = U16Buffer(index)
+	return md
+}
+
+// meshArea returns the total area of the triangles in the xy plane.
+func meshArea(md MeshData) (area float64) {
+	at := func(i uint16) (x, y float64) {
+		x = float64(math.Float32frombits(binary.LittleEndian.Uint32(md[Vertexes].Data[int(i)*12:])))
+		y = float64(math.Float32frombits(binary.LittleEndian.Uint32(md[Vertexes].Data[int(i)*12+4:])))
+		return x, y
+	}
+	index := md[Indexes]
+	for i := 0; i < int(index.Count); i += 3 {
+		ax, ay := at(binary.LittleEndian.Uint16(index.Data[i*2:]))
+		bx, by := at(binary.LittleEndian.Uint16(index.Data[i*2+2:]))
+		cx, cy := at(binary.LittleEndian.Uint16(index.Data[i*2+4:]))
+		area += ((bx-ax)*(cy-ay) - (cx-ax)*(by-ay)) / 2
+	}
+	return area
+}
+
+// go test -run Iqm
+func TestIqm(t *testing.T) {
+	t.Run("rigged", func(t *testing.T) {
+		assets := Iqm("test.iqm", iqmFile())
+		if len(assets) != 2 || assets[0].Err != nil {
+			t.Fatalf("expected mesh and animation data %+v", assets)
+		}
+		md := assets[0].Data.(MeshData)
+		if md[Vertexes].Count != 3 || md[Joints].Count != 3 || md[Weights].Count != 3 {
+			t.Errorf("expected 3 vertexes with joints and weights")
+		}
+		if index := md[Indexes].Data; index[2] != 2 || index[4] != 1 {
+			t.Errorf("expected reversed triangle winding %v", index)
+		}
+		if w := math.Float32frombits(binary.LittleEndian.Uint32(md[Weights].Data)); w != 1 {
+			t.Errorf("expected normalized weights got %f", w)
+		}
+		anim := assets[1].Data.(*AnimationData)
+		if len(anim.Joints) != 2 || anim.Joints[1].Name != "arm" || anim.Joints[1].Parent != 0 {
+			t.Fatalf("expected 2 joints %+v", anim.Joints)
+		}
+		if len(anim.Clips) != 1 || anim.Clips[0].Name != "wave" || !anim.Clips[0].Loop {
+			t.Fatalf("expected one looping clip %+v", anim.Clips)
+		}
+		frames := anim.Clips[0].Frames
+		if len(frames) != 2 || frames[1][1].T[1] != 2 || frames[0][1].R[3] != 1 {
+			t.Errorf("expected decoded frame channels %+v", frames)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if assets := Iqm("bad.iqm", []byte("not an iqm file")); assets[0].Err == nil {
+			t.Errorf("expected magic error")
+		}
+		data := iqmFile()
+		binary.LittleEndian.PutUint32(data[len(iqmMagic):], 1)
+		if assets := Iqm("old.iqm", data); assets[0].Err == nil {
+			t.Errorf("expected version error")
+		}
+	})
+}
+
+// iqmFile creates a rigged triangle with two joints and a
+// two frame animation that moves the second joint up.
+func iqmFile() []byte {
+	body := &bytes.Buffer{}
+	base := uint32(len(iqmMagic) + binary.Size(iqmHeader{}))
+	add := func(v any) uint32 {
+		offset := base + uint32(body.Len())
+		binary.Write(body, binary.LittleEndian, v)
+		return offset
+	}
+	hdr := iqmHeader{Version: 2, NumVertexes: 3, NumTriangles: 1, NumJoints: 2, NumPoses: 2, NumAnims: 1}
+	text := []byte("\x00root\x00arm\x00wave\x00")
+	hdr.NumText, hdr.OfsText = uint32(len(text)), add(text)
+	positions := add([]float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	joints := add([]uint8{0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0})
+	weights := add([]uint8{255, 0, 0, 0, 255, 0, 0, 0, 255, 0, 0, 0})
+	hdr.NumVertexArrays = 3
+	hdr.OfsVertexArrays = add([]iqmVertexArray{
+		{Type: iqmPosition, Format: iqmFloat, Size: 3, Offset: positions},
+		{Type: iqmBlendIndexes, Format: iqmUbyte, Size: 4, Offset: joints},
+		{Type: iqmBlendWeights, Format: iqmUbyte, Size: 4, Offset: weights},
+	})
+	hdr.OfsTriangles = add([]uint32{0, 1, 2})
+	hdr.OfsJoints = add([]iqmJoint{
+		{Name: 1, Parent: -1, Rotate: [4]float32{0, 0, 0, 1}, Scale: [3]float32{1, 1, 1}},
+		{Name: 6, Parent: 0, Translate: [3]float32{1, 0, 0}, Rotate: [4]float32{0, 0, 0, 1}, Scale: [3]float32{1, 1, 1}},
+	})
+	static := iqmPose{Parent: -1, ChannelOffset: [10]float32{0, 0, 0, 0, 0, 0, 1, 1, 1, 1}}
+	moving := iqmPose{Parent: 0, Mask: 1 << 1, ChannelOffset: static.ChannelOffset}
+	moving.ChannelScale[1] = 2 // y translation is 0 or 2.
+	hdr.OfsPoses = add([]iqmPose{static, moving})
+	hdr.OfsAnims = add([]iqmAnim{{Name: 10, FirstFrame: 0, NumFrames: 2, Framerate: 10, Flags: iqmLoop}})
+	hdr.NumFrames, hdr.NumFrameChannels = 2, 1
+	hdr.OfsFrames = add([]uint16{0, 1})
+	hdr.Filesize = base + uint32(body.Len())
+	file := &bytes.Buffer{}
+	file.WriteString(iqmMagic)
+	binary.Write(file, binary.LittleEndian, hdr)
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+// go test -run Mount
+func TestMount(t *testing.T) {
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	defer SetAssetDir(".yaml", "assets/data")
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("chdir %s", err)
+	}
+	SetAssetDir(".yaml", "data")
+
+	// create a zip archive with a custom extension.
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, name := range []string{"a", "b"} {
+		w, _ := zw.Create("data/" + name + ".yaml")
+		w.Write([]byte("base " + name))
+	}
+	zw.Close()
+	os.WriteFile("base.pak", buf.Bytes(), 0644)
+	if err := Mount("missing.pak", 0); err == nil {
+		t.Errorf("expected missing archive error")
+	}
+	if err := Mount("base.pak", 0); err != nil {
+		t.Fatalf("mount %s", err)
+	}
+	defer Unmount("base.pak")
+	check := func(name, want string) {
+		t.Helper()
+		if data, err := DataBytes(name); err != nil || string(data) != want {
+			t.Errorf("expected %s got %s %v", want, data, err)
+		}
+	}
+	check("a.yaml", "base a")
+
+	// higher priority archives override lower priority archives.
+	if err := MountFS("patch", fstest.MapFS{"data/a.yaml": {Data: []byte("patch a")}}, 1); err != nil {
+		t.Fatalf("mount %s", err)
+	}
+	defer Unmount("patch")
+	check("a.yaml", "patch a")
+	check("b.yaml", "base b")
+	if names := Mounted(); len(names) != 2 || names[0] != "patch" || names[1] != "base.pak" {
+		t.Errorf("expected mounts in priority order got %v", names)
+	}
+
+	// loose files override archives.
+	os.Mkdir("data", 0755)
+	os.WriteFile("data/b.yaml", []byte("loose b"), 0644)
+	check("b.yaml", "loose b")
+	if _, err := ModTime("a.yaml"); err != nil {
+		t.Errorf("expected archive file mod time %s", err)
+	}
+
+	// unmounted archives are no longer read.
+	Unmount("patch")
+	Unmount("base.pak")
+	if _, err := DataBytes("a.yaml"); err == nil || len(Mounted()) != 0 {
+		t.Errorf("expected unmounted archives")
+	}
+}
+
+// go test -run PackFormat
+func TestPackFormat(t *testing.T) {
+	if data := PackManifestData(); !bytes.HasPrefix(data, []byte("format: 1\n")) {
+		t.Errorf("expected current format manifest got %s", data)
+	}
+	newer := fstest.MapFS{PackManifest: {Data: []byte("format: 2\nengine: v9.0.0\n")}}
+	if err := MountFS("newer", newer, 0); err == nil || !strings.Contains(err.Error(), "v9.0.0") {
+		t.Errorf("expected newer format error got %v", err)
+	}
+	older := fstest.MapFS{
+		PackManifest:         {Data: []byte("format: 0\n")},
+		"old/data/pack.yaml": {Data: []byte("moved")},
+	}
+	if err := MountFS("older", older, 0); err == nil || len(Mounted()) != 0 {
+		t.Errorf("expected missing migration error got %v", err)
+	}
+
+	// format 0 packs kept their assets in an "old" directory.
+	RegisterPackMigration(0, func(fsys fs.FS) (fs.FS, error) { return fs.Sub(fsys, "old") })
+	defer delete(packMigrations.list, 0)
+	if err := MountFS("older", older, 0); err != nil {
+		t.Fatalf("expected migrated pack got %v", err)
+	}
+	defer Unmount("older")
+	if data, err := readMounted("data/pack.yaml"); err != nil || string(data) != "moved" {
+		t.Errorf("expected migrated asset path got %s %v", data, err)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// mount.go reads asset files from mounted archives so that a game can
+// ship its assets packed into a few files instead of a folder full of
+// images and sounds. Archives use the same asset paths as loose files,
+// eg: "assets/images/core.png". Loose files override archive files so
+// that individual assets can be changed without repacking an archive.
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// mount is an archive, or other file system, used as an asset root.
+type mount struct {
+	name     string // unique mount name, the archive path for zip files.
+	fsys     fs.FS  // archive contents.
+	priority int    // higher priority mounts are checked first.
+	closer   func() error
+}
+
+// mounts are checked in order. Mounts are read
+// from the loader goroutines so access is guarded.
+var mounts struct {
+	lock sync.RWMutex
+	list []*mount
+}
+
+// Mount adds the zip archive at the given file path as an asset root.
+// Custom pack files, eg: "game.pak", can be zip archives with a different
+// file extension. Archives with a higher priority override files in
+// archives with a lower priority, ie: a patch archive can replace the
+// files from the base game archive. Archives with the same priority are
+// checked newest mount first. Loose files always override archive files.
+// Archives with a newer pack format than PackFormat are not mounted.
+func Mount(archive string, priority int) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("mount %s: %w", archive, err)
+	}
+	fsys, err := checkPack(archive, zr)
+	if err != nil {
+		zr.Close()
+		return fmt.Errorf("mount %w", err)
+	}
+	mountFS(&mount{name: archive, fsys: fsys, priority: priority, closer: zr.Close})
+	return nil
+}
+
+// MountFS adds the given file system as an asset root using the
+// same rules as Mount. Eg: use a go:embed FS, or a file system
+// that reads a custom pack format. The name is used to Unmount.
+// Returns an error if the pack format is not supported.
+func MountFS(name string, fsys fs.FS, priority int) (err error) {
+	if fsys, err = checkPack(name, fsys); err != nil {
+		return fmt.Errorf("mount %w", err)
+	}
+	mountFS(&mount{name: name, fsys: fsys, priority: priority})
+	return nil
+}
+
+// mountFS adds the mount in priority order, replacing any
+// existing mount with the same name.
+func mountFS(m *mount) {
+	Unmount(m.name)
+	mounts.lock.Lock()
+	defer mounts.lock.Unlock()
+	at := 0
+	for at < len(mounts.list) && mounts.list[at].priority > m.priority {
+		at++
+	}
+	mounts.list = slices.Insert(mounts.list, at, m)
+}
+
+// Unmount removes the named archive or file system, closing
+// zip archives. Returns an error if closing the archive failed.
+// Unmounting an unknown name is ignored.
+func Unmount(name string) (err error) {
+	mounts.lock.Lock()
+	defer mounts.lock.Unlock()
+	for i, m := range mounts.list {
+		if m.name == name {
+			mounts.list = slices.Delete(mounts.list, i, i+1)
+			if m.closer != nil {
+				err = m.closer()
+			}
+			break
+		}
+	}
+	return err
+}
+
+// Mounted returns the names of the mounted archives
+// in the order that they are checked.
+func Mounted() (names []string) {
+	mounts.lock.RLock()
+	defer mounts.lock.RUnlock()
+	for _, m := range mounts.list {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// mountPath converts an asset file path to a file system path.
+// Returns false for paths outside the asset root.
+func mountPath(filepath string) (string, bool) {
+	name := path.Clean(strings.ReplaceAll(filepath, "\\", "/"))
+	return name, fs.ValidPath(name)
+}
+
+// readMounted returns the file data from the highest priority
+// mount that contains the given file.
+func readMounted(filepath string) (data []byte, err error) {
+	name, ok := mountPath(filepath)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	mounts.lock.RLock()
+	defer mounts.lock.RUnlock()
+	for _, m := range mounts.list {
+		if data, err = fs.ReadFile(m.fsys, name); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// openMounted opens the file from the highest priority
+// mount that contains the given file.
+func openMounted(filepath string) (file io.ReadCloser, err error) {
+	name, ok := mountPath(filepath)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	mounts.lock.RLock()
+	defer mounts.lock.RUnlock()
+	for _, m := range mounts.list {
+		if file, err = m.fsys.Open(name); err == nil {
+			return file, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// statMounted returns the file information from the highest
+// priority mount that contains the given file.
+func statMounted(filepath string) (info fs.FileInfo, err error) {
+	name, ok := mountPath(filepath)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	mounts.lock.RLock()
+	defer mounts.lock.RUnlock()
+	for _, m := range mounts.list {
+		if info, err = fs.Stat(m.fsys, name); err == nil {
+			return info, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
@@ -0,0 +1,37 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// Mp3 decodes MPEG audio layer III files into the same PCM data as Wav
+// using the go-mp3 decoder. Samples are returned as 16 bit signed little
+// endian stereo values. Mono files have the same left and right samples.
+// ID3 tags are skipped. The MP3 format is from:
+//   - https://www.iso.org/standard/22412.html
+//
+// The Reader r is expected to be opened and closed by the caller.
+func Mp3(r io.Reader) (aud *AudioData, err error) {
+	aud = &AudioData{}
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return aud, fmt.Errorf("Invalid .mp3 audio file: %s", err)
+	}
+	data, err := io.ReadAll(dec)
+	if err != nil {
+		return aud, fmt.Errorf("Corrupt .mp3 audio file: %w", err)
+	}
+	aud.Attrs = &AudioAttributes{
+		Channels:   2,
+		Frequency:  uint32(dec.SampleRate()),
+		DataSize:   uint32(len(data)),
+		SampleBits: 16,
+	}
+	aud.Data = data
+	return aud, nil
+}
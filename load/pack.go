@@ -0,0 +1,118 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// pack.go checks the format of mounted asset archives. An archive can
+// have a "pack.yaml" manifest in its root that records the pack format
+// and the engine version used to build it, eg:
+//
+//	format: 1
+//	engine: (devel)
+//
+// Archives without a manifest are treated as format 1. Packs built for
+// older formats are upgraded as they are mounted using the registered
+// pack migrations. Packs built for newer formats are rejected.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime/debug"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PackFormat is the asset pack format supported by this engine.
+const PackFormat = 1
+
+// PackManifest is the archive root file describing the asset pack.
+const PackManifest = "pack.yaml"
+
+// packManifest is the pack.yaml file data.
+type packManifest struct {
+	Format int    `yaml:"format"`
+	Engine string `yaml:"engine,omitempty"`
+}
+
+// PackMigration upgrades a pack from one format to the next, returning
+// a file system with the upgraded layout. Eg: a migration could wrap the
+// archive to rename asset directories that moved between formats.
+type PackMigration func(fsys fs.FS) (fs.FS, error)
+
+// packMigrations are the registered pack upgrades indexed by the
+// format they upgrade from.
+var packMigrations struct {
+	lock sync.Mutex
+	list map[int]PackMigration
+}
+
+// RegisterPackMigration adds a migration that upgrades packs from the
+// given format to the next format. Expected to be called on startup
+// before mounting packs.
+func RegisterPackMigration(from int, migrate PackMigration) {
+	packMigrations.lock.Lock()
+	defer packMigrations.lock.Unlock()
+	if packMigrations.list == nil {
+		packMigrations.list = map[int]PackMigration{}
+	}
+	packMigrations.list[from] = migrate
+}
+
+// PackManifestData returns the manifest file data for a pack
+// built by this engine.
+func PackManifestData() []byte {
+	data, _ := yaml.Marshal(&packManifest{Format: PackFormat, Engine: EngineVersion()})
+	return data
+}
+
+// EngineVersion returns the vu module version that was built into the
+// application, or "(devel)" when vu is the main module.
+func EngineVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+	if info.Main.Path == "github.com/gazed/vu" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/gazed/vu" {
+			return dep.Version
+		}
+	}
+	return "(unknown)"
+}
+
+// checkPack reads the pack manifest and applies any migrations
+// needed to upgrade the pack to the current format.
+func checkPack(name string, fsys fs.FS) (fs.FS, error) {
+	pm := packManifest{Format: 1}
+	data, err := fs.ReadFile(fsys, PackManifest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fsys, nil // packs without manifests are format 1.
+	case err != nil:
+		return nil, fmt.Errorf("pack %s manifest: %w", name, err)
+	}
+	if err := yaml.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("pack %s manifest: %w", name, err)
+	}
+	if pm.Format > PackFormat {
+		return nil, fmt.Errorf("pack %s format %d built by engine %s is newer than supported format %d",
+			name, pm.Format, pm.Engine, PackFormat)
+	}
+	packMigrations.lock.Lock()
+	defer packMigrations.lock.Unlock()
+	for format := pm.Format; format < PackFormat; format++ {
+		migrate, ok := packMigrations.list[format]
+		if !ok {
+			return nil, fmt.Errorf("pack %s format %d built by engine %s: no migration to format %d",
+				name, format, pm.Engine, format+1)
+		}
+		if fsys, err = migrate(fsys); err != nil {
+			return nil, fmt.Errorf("pack %s migrating format %d: %w", name, format, err)
+		}
+	}
+	return fsys, nil
+}
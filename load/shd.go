@@ -30,13 +30,14 @@ var shaderStages = map[string]ShaderStage{
 // Expected use is for passing data from the engine to the render system.
 var ShaderAttributes = map[string]int{
 	// names for model vertex data
-	"position": Vertexes,
-	"texcoord": Texcoords,
-	"normal":   Normals,
-	"tangent":  Tangents,
-	"v_color":  Colors,
-	"joint":    Joints,
-	"weight":   Weights,
+	"position":  Vertexes,
+	"texcoord":  Texcoords,
+	"normal":    Normals,
+	"tangent":   Tangents,
+	"v_color":   Colors,
+	"joint":     Joints,
+	"weight":    Weights,
+	"texcoord2": Texcoords2,
 
 	// names for instanced model data
 	"i_position": InstancePosition,
@@ -74,24 +75,32 @@ var ShaderUniformScope = map[string]UniformScope{
 // a single scene (render pass).
 // Expected use is for passing data from the engine to the render system.
 var ShaderPassUniforms = map[string]PassUniform{
-	"proj":    PROJ,    //
-	"view":    VIEW,    //
-	"cam":     CAM,     //
-	"lights":  LIGHTS,  //
-	"nlights": NLIGHTS, //
-	"time":    TIME,    //
+	"proj":     PROJ,     //
+	"view":     VIEW,     //
+	"cam":      CAM,      //
+	"lights":   LIGHTS,   //
+	"nlights":  NLIGHTS,  //
+	"time":     TIME,     //
+	"fog":      FOG,      // distance and height fog.
+	"fogcolor": FOGCOLOR, // fog color and amount.
 }
 
 // ShaderPacketUniforms are shader uniforms that apply to one model.
 // Render data for a model is put into a render.Packet.
 // Expected use is for passing data from the engine to the render system.
 var ShaderPacketUniforms = map[string]PacketUniform{
-	"model":    MODEL,    // 4x4 matrix
-	"scale":    SCALE,    // 3 floats
-	"color":    COLOR,    //
-	"material": MATERIAL, //
-	"args4":    ARGS4,    // 4 floats
-	"args16":   ARGS16,   // 16 floats
+	"model":      MODEL,      // 4x4 matrix
+	"scale":      SCALE,      // 3 floats
+	"color":      COLOR,      //
+	"material":   MATERIAL,   //
+	"args4":      ARGS4,      // 4 floats
+	"args16":     ARGS16,     // 16 floats
+	"outline":    OUTLINE,    // 4 floats
+	"bones":      BONES,      // int first bone index.
+	"keycolor":   KEYCOLOR,   // 4 floats
+	"paletterow": PALETTEROW, // 4 floats
+	"probe":      PROBE,      // 4 floats
+	"probebox":   PROBEBOX,   // 4 floats
 }
 
 // ShaderUniformData are the supported uniform data types.
@@ -163,9 +172,11 @@ func Shd(name string, data []byte) (shader *Shader, err error) {
 		}
 
 		// uniform must be in the render pass or in the render packet.
+		// Material samplers are bound by name and can be named anything.
 		passID, ok3 := ShaderPassUniforms[u.Name]
 		packetID, ok4 := ShaderPacketUniforms[u.Name]
-		if !ok3 && !ok4 {
+		sampler := dtype == DataType_SAMPLER && scope == MaterialScope
+		if !ok3 && !ok4 && !sampler {
 			return shader, fmt.Errorf("Shd:unsupported uniform %s", u.Name)
 		}
 		uniforms = append(uniforms, ShaderUniform{
@@ -191,6 +202,7 @@ func Shd(name string, data []byte) (shader *Shader, err error) {
 	if cfg.Render != "" {
 		shader.CullModeNone = strings.Contains(cfg.Render, "cullOff")
 		shader.DrawLines = strings.Contains(cfg.Render, "drawLines")
+		shader.Occlusion = strings.Contains(cfg.Render, "occlusion")
 	}
 
 	// return the shader
@@ -236,6 +248,7 @@ type Shader struct {
 	// Set from shaderConfig.Render flags.
 	CullModeNone bool // true disables backface culling.
 	DrawLines    bool // true to render lines instead of triangles.
+	Occlusion    bool // true for occlusion queries: no color or depth writes.
 
 	// Attrs must match the shader attributes in name and position, ie:
 	//   Attr[0].Name == position   ... which matches
@@ -302,6 +315,8 @@ const (
 	LIGHTS                          // scene
 	NLIGHTS                         // scene
 	TIME                            // scene
+	FOG                             // scene
+	FOGCOLOR                        // scene
 	PassUniforms                    // must be last
 )
 
@@ -349,6 +364,12 @@ const (
 	MATERIAL                            // model
 	ARGS4                               // model shader specific data passing.
 	ARGS16                              // model shader specific data passing.
+	OUTLINE                             // model text outline and glow color.
+	BONES                               // model first bone in the bone buffer.
+	KEYCOLOR                            // model color key transparency color.
+	PALETTEROW                          // model palette row for indexed colors.
+	PROBE                               // model reflection probe location.
+	PROBEBOX                            // model reflection probe box size.
 	PacketUniforms                      // must be last
 )
 
@@ -370,13 +391,13 @@ const (
 )
 
 var DataTypeSizes = map[ShaderDataType]uint32{
-	DataType_INT:     4,  // int32
-	DataType_FLOAT:   4,  // float32
-	DataType_LIGHT3:  96, // 3 light struct of 2 vec4 float
-	DataType_MAT3:    36, // 9 float32
-	DataType_MAT4:    64, // 16 float32
-	DataType_SAMPLER: 0,  // samplers don't have size.
-	DataType_VEC2:    8,  // float32 vec2
-	DataType_VEC3:    12, // float32 vec3
-	DataType_VEC4:    16, // float32 vec4
+	DataType_INT:     4,   // int32
+	DataType_FLOAT:   4,   // float32
+	DataType_LIGHT3:  240, // 3 light struct of 5 vec4 float
+	DataType_MAT3:    36,  // 9 float32
+	DataType_MAT4:    64,  // 16 float32
+	DataType_SAMPLER: 0,   // samplers don't have size.
+	DataType_VEC2:    8,   // float32 vec2
+	DataType_VEC3:    12,  // float32 vec3
+	DataType_VEC4:    16,  // float32 vec4
 }
@@ -0,0 +1,409 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// simplify.go reduces the number of triangles in mesh data so that
+// lower detail meshes can be generated without offline tooling, eg:
+//
+//	lods, err := load.Simplify(md, 0.5, 0.25)
+//	err = eng.MakeMeshes("tree_lod", lods) // creates "tree_lod0", "tree_lod1"
+//	tree.AddLOD("msh:tree_lod0", 40).AddLOD("msh:tree_lod1", 100)
+//
+// Simplification uses quadric error metrics to collapse mesh edges:
+//   https://www.cs.cmu.edu/~./garland/Papers/quadrics.pdf
+// Edges are only collapsed to existing vertexes so the simplified meshes
+// share the vertex data and only have new, smaller, triangle indexes.
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Simplify returns one lower detail copy of the mesh data for each of
+// the given ratios. A ratio is the fraction of triangles to keep, ie:
+// 0.25 keeps a quarter of the triangles. The returned mesh data shares
+// the vertex data of the given mesh and has new triangle indexes.
+// Fewer triangles may be removed than requested in order to preserve
+// the mesh boundary edges and to avoid flipping triangles.
+func Simplify(md MeshData, ratios ...float64) (lods []MeshData, err error) {
+	if len(md) != VertexTypes {
+		return nil, fmt.Errorf("Simplify: expected %d mesh buffers", VertexTypes)
+	}
+	verts, index := md[Vertexes], md[Indexes]
+	if verts.Stride != 12 || len(verts.Data) < int(verts.Count)*12 {
+		return nil, fmt.Errorf("Simplify: expecting vec3:float32 vertexes")
+	}
+	if index.Stride != 2 || index.Count < 3 || index.Count%3 != 0 || len(index.Data) < int(index.Count)*2 {
+		return nil, fmt.Errorf("Simplify: expecting uint16 triangle indexes")
+	}
+	for _, ratio := range ratios {
+		if ratio <= 0 || ratio > 1 {
+			return nil, fmt.Errorf("Simplify: invalid ratio %f", ratio)
+		}
+	}
+	s, err := newSimplifier(md)
+	if err != nil {
+		return nil, err
+	}
+
+	// simplify from the largest to smallest ratio, taking a
+	// copy of the triangle indexes at each requested ratio.
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(ratios[b], ratios[a])
+	})
+	lods = make([]MeshData, len(ratios))
+	for _, i := range order {
+		s.collapse(max(1, int(math.Round(ratios[i]*float64(len(s.faces))))))
+		lod := make(MeshData, VertexTypes)
+		copy(lod, md) // share the vertex data.
+		lod[Indexes] = U16Buffer(s.indexes())
+		lods[i] = lod
+	}
+	return lods, nil
+}
+
+// boundaryWeight scales the planes that keep boundary edges in place.
+const boundaryWeight = 1000.0
+
+// simplifier tracks the mesh state while collapsing edges.
+// Vertexes with the same position are welded so that texture seams
+// are simplified along with the rest of the mesh.
+type simplifier struct {
+	texcoords []float32     // optional texcoords to pick seam vertexes.
+	weld      []int         // original vertex to welded point.
+	points    []simplePoint // welded vertex positions.
+	faces     []simpleFace  // triangles, some of which have collapsed.
+	live      int           // number of triangles that have not collapsed.
+	edges     edgeHeap      // candidate edge collapses ordered by cost.
+}
+
+// simplePoint is a welded vertex.
+type simplePoint struct {
+	at      [3]float64 // position.
+	q       quadric    // error of moving the point.
+	faces   []int      // adjacent faces, including collapsed faces.
+	verts   []uint16   // original vertexes at this position.
+	version int        // incremented when the point changes.
+	removed bool       // true once the point has collapsed.
+}
+
+// simpleFace is a triangle as welded points and original vertexes.
+type simpleFace struct {
+	p       [3]int    // welded points.
+	v       [3]uint16 // original vertexes.
+	removed bool      // true once the triangle has collapsed.
+}
+
+// newSimplifier welds the vertexes and calculates the point quadrics
+// and the initial edge collapse costs.
+func newSimplifier(md MeshData) (s *simplifier, err error) {
+	verts, index := md[Vertexes], md[Indexes]
+	s = &simplifier{weld: make([]int, verts.Count)}
+	if tc := md[Texcoords]; tc.Stride == 8 && tc.Count == verts.Count {
+		s.texcoords = make([]float32, tc.Count*2)
+		for i := range s.texcoords {
+			s.texcoords[i] = math.Float32frombits(binary.LittleEndian.Uint32(tc.Data[i*4:]))
+		}
+	}
+
+	// weld vertexes with the same position.
+	welded := map[[3]float32]int{}
+	for i := 0; i < int(verts.Count); i++ {
+		at := [3]float32{}
+		for j := range at {
+			at[j] = math.Float32frombits(binary.LittleEndian.Uint32(verts.Data[i*12+j*4:]))
+		}
+		pid, ok := welded[at]
+		if !ok {
+			pid = len(s.points)
+			welded[at] = pid
+			s.points = append(s.points, simplePoint{at: [3]float64{float64(at[0]), float64(at[1]), float64(at[2])}})
+		}
+		s.weld[i] = pid
+		s.points[pid].verts = append(s.points[pid].verts, uint16(i))
+	}
+
+	// create the triangles, ignoring any that are already degenerate.
+	edgeFaces := map[[2]int]int{} // count faces on each edge.
+	for i := 0; i < int(index.Count); i += 3 {
+		f := simpleFace{}
+		for j := range f.v {
+			f.v[j] = binary.LittleEndian.Uint16(index.Data[(i+j)*2:])
+			if int(f.v[j]) >= len(s.weld) {
+				return nil, fmt.Errorf("Simplify: invalid index %d", f.v[j])
+			}
+			f.p[j] = s.weld[f.v[j]]
+		}
+		if f.p[0] == f.p[1] || f.p[1] == f.p[2] || f.p[2] == f.p[0] {
+			continue
+		}
+		fid := len(s.faces)
+		s.faces = append(s.faces, f)
+		for j, pid := range f.p {
+			s.points[pid].faces = append(s.points[pid].faces, fid)
+			edgeFaces[edgeKey(pid, f.p[(j+1)%3])] += 1
+		}
+		n, d, ok := s.plane(f.p)
+		if !ok {
+			continue // zero area triangle.
+		}
+		q := planeQuadric(n, d, 1)
+		for _, pid := range f.p {
+			s.points[pid].q.add(q)
+		}
+	}
+	if len(s.faces) == 0 {
+		return nil, fmt.Errorf("Simplify: no triangles")
+	}
+	s.live = len(s.faces)
+
+	// keep boundary edges in place using planes perpendicular to the
+	// boundary triangle that run along the boundary edge.
+	for _, f := range s.faces {
+		n, _, ok := s.plane(f.p)
+		if !ok {
+			continue
+		}
+		for j := range f.p {
+			a, b := f.p[j], f.p[(j+1)%3]
+			if edgeFaces[edgeKey(a, b)] != 1 {
+				continue
+			}
+			e := sub(s.points[b].at, s.points[a].at)
+			bn, ok := normalize(cross(e, n))
+			if !ok {
+				continue
+			}
+			q := planeQuadric(bn, -dot(bn, s.points[a].at), boundaryWeight*dot(e, e))
+			s.points[a].q.add(q)
+			s.points[b].q.add(q)
+		}
+	}
+
+	// queue the initial edge collapses in triangle order so
+	// that the simplified meshes are repeatable.
+	for _, f := range s.faces {
+		for j := range f.p {
+			if a, b := f.p[j], f.p[(j+1)%3]; a < b || edgeFaces[edgeKey(a, b)] == 1 {
+				s.queue(a, b)
+			}
+		}
+	}
+	return s, nil
+}
+
+// collapse removes the cheapest edges until there are no
+// more than the target number of triangles.
+func (s *simplifier) collapse(target int) {
+	for s.live > target && s.edges.Len() > 0 {
+		e := heap.Pop(&s.edges).(edgeCollapse)
+		from, to := &s.points[e.from], &s.points[e.to]
+		if from.removed || to.removed || from.version != e.fromVersion || to.version != e.toVersion {
+			continue // stale edge.
+		}
+		if s.flips(e.from, e.to) {
+			continue // rejected until the neighbourhood changes.
+		}
+
+		// move the triangles from the collapsed point.
+		from.removed = true
+		to.q.add(from.q)
+		to.version += 1
+		for _, fid := range from.faces {
+			f := &s.faces[fid]
+			if f.removed {
+				continue
+			}
+			for j := range f.p {
+				if f.p[j] == e.from {
+					f.p[j] = e.to
+					f.v[j] = s.vertex(f.v[j], e.to)
+				}
+			}
+			if f.p[0] == f.p[1] || f.p[1] == f.p[2] || f.p[2] == f.p[0] {
+				f.removed = true
+				s.live -= 1
+				continue
+			}
+			to.faces = append(to.faces, fid)
+		}
+
+		// update the costs of the edges around the remaining point.
+		to.faces = slices.DeleteFunc(to.faces, func(fid int) bool { return s.faces[fid].removed })
+		for _, fid := range to.faces {
+			f := s.faces[fid]
+			for j := range f.p {
+				if f.p[j] == e.to {
+					s.queue(e.to, f.p[(j+1)%3])
+					s.queue(e.to, f.p[(j+2)%3])
+				}
+			}
+		}
+	}
+}
+
+// flips returns true if moving a point would flip any of the triangles
+// that remain after the collapse.
+func (s *simplifier) flips(from, to int) bool {
+	for _, fid := range s.points[from].faces {
+		f := s.faces[fid]
+		if f.removed || f.p[0] == to || f.p[1] == to || f.p[2] == to {
+			continue // removed by the collapse.
+		}
+		n0, _, ok := s.plane(f.p)
+		if !ok {
+			continue
+		}
+		for j := range f.p {
+			if f.p[j] == from {
+				f.p[j] = to
+			}
+		}
+		n1, _, ok := s.plane(f.p)
+		if !ok || dot(n0, n1) < 0.2 {
+			return true
+		}
+	}
+	return false
+}
+
+// vertex returns the original vertex at the given point that best
+// matches the given vertex. Texture coordinates are used to pick the
+// matching vertex on texture seams.
+func (s *simplifier) vertex(v uint16, pid int) uint16 {
+	verts := s.points[pid].verts
+	best, bestDist := verts[0], math.MaxFloat64
+	if s.texcoords == nil {
+		return best
+	}
+	for _, c := range verts {
+		du := float64(s.texcoords[c*2] - s.texcoords[v*2])
+		dv := float64(s.texcoords[c*2+1] - s.texcoords[v*2+1])
+		if dist := du*du + dv*dv; dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
+
+// indexes returns the triangle indexes of the remaining triangles.
+func (s *simplifier) indexes() []uint16 {
+	index := make([]uint16, 0, s.live*3)
+	for _, f := range s.faces {
+		if !f.removed {
+			index = append(index, f.v[0], f.v[1], f.v[2])
+		}
+	}
+	return index
+}
+
+// queue adds the cheaper direction of collapsing the given edge.
+func (s *simplifier) queue(a, b int) {
+	pa, pb := &s.points[a], &s.points[b]
+	q := pa.q
+	q.add(pb.q)
+	e := edgeCollapse{from: a, to: b, cost: q.eval(pb.at)}
+	if cost := q.eval(pa.at); cost < e.cost {
+		e = edgeCollapse{from: b, to: a, cost: cost}
+	}
+	e.fromVersion, e.toVersion = s.points[e.from].version, s.points[e.to].version
+	heap.Push(&s.edges, e)
+}
+
+// plane returns the unit normal and plane offset of a triangle.
+// Returns false for a zero area triangle.
+func (s *simplifier) plane(p [3]int) (n [3]float64, d float64, ok bool) {
+	a, b, c := s.points[p[0]].at, s.points[p[1]].at, s.points[p[2]].at
+	if n, ok = normalize(cross(sub(b, a), sub(c, a))); !ok {
+		return n, 0, false
+	}
+	return n, -dot(n, a), true
+}
+
+// edgeKey orders the edge points so both triangles share the same key.
+func edgeKey(a, b int) [2]int { return [2]int{min(a, b), max(a, b)} }
+
+// =============================================================================
+
+// quadric is the upper triangle of the symmetric 4x4 matrix
+// that measures the squared distance to a set of planes.
+type quadric [10]float64
+
+// planeQuadric returns the weighted quadric for the plane nx+d=0.
+func planeQuadric(n [3]float64, d, weight float64) quadric {
+	a, b, c := n[0], n[1], n[2]
+	return quadric{
+		a * a, a * b, a * c, a * d,
+		b * b, b * c, b * d,
+		c * c, c * d,
+		d * d,
+	}.scale(weight)
+}
+
+// add sums the quadric errors.
+func (q *quadric) add(o quadric) {
+	for i := range q {
+		q[i] += o[i]
+	}
+}
+
+// scale returns the quadric multiplied by s.
+func (q quadric) scale(s float64) quadric {
+	for i := range q {
+		q[i] *= s
+	}
+	return q
+}
+
+// eval returns the quadric error at the given point.
+func (q *quadric) eval(p [3]float64) float64 {
+	x, y, z := p[0], p[1], p[2]
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z +
+		q[9]
+}
+
+// edgeCollapse moves the from point to the to point.
+// The versions are used to ignore edges that have changed.
+type edgeCollapse struct {
+	from, to               int
+	fromVersion, toVersion int
+	cost                   float64
+}
+
+// edgeHeap orders edge collapses by lowest cost. Implements heap.Interface.
+type edgeHeap []edgeCollapse
+
+func (h edgeHeap) Len() int           { return len(h) }
+func (h edgeHeap) Less(i, j int) bool { return h[i].cost < h[j].cost }
+func (h edgeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *edgeHeap) Push(x any)        { *h = append(*h, x.(edgeCollapse)) }
+func (h *edgeHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// vector helpers for float64 positions.
+func sub(a, b [3]float64) [3]float64 { return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func dot(a, b [3]float64) float64    { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+func normalize(a [3]float64) (n [3]float64, ok bool) {
+	l := math.Sqrt(dot(a, a))
+	if l < 1e-12 {
+		return a, false
+	}
+	return [3]float64{a[0] / l, a[1] / l, a[2] / l}, true
+}
@@ -13,6 +13,7 @@ import (
 	"image"
 	"image/draw"
 	"log/slog"
+	"math"
 
 	// DEBUG to dump atlas image as png.
 	// "image/png"
@@ -24,12 +25,55 @@ import (
 	"golang.org/x/image/math/fixed"
 )
 
-// basic runes plus some symbols. FUTURE: make this configurable.
-var ttfRunes = []rune(" ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz1234567890`~!@#$%^&*()[]{}/=?+\\|-_.>,<'\";:")
+// basic runes plus some symbols. See SetFontRunes.
+var ttfRunes = []rune(defaultFontRunes)
+
+// defaultFontRunes are the ASCII letters, numbers, and symbols.
+const defaultFontRunes = " ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz1234567890`~!@#$%^&*()[]{}/=?+\\|-_.>,<'\";:"
+
+// SetFontRunes sets the characters that are added to font atlases
+// generated from truetype fonts. This allows non-English text, eg:
+//
+//	load.SetFontRunes(load.DefaultFontRunes() + "àâçéèêëîïôûùüÿñæœ")
+//
+// Duplicate runes are ignored. Runes that do not fit on the first
+// atlas image are placed on additional atlas pages.
+// Expected to be called before any fonts are loaded.
+func SetFontRunes(runes string) {
+	seen := map[rune]bool{}
+	unique := []rune{}
+	for _, r := range runes {
+		if !seen[r] {
+			seen[r] = true
+			unique = append(unique, r)
+		}
+	}
+	ttfRunes = unique
+}
+
+// DefaultFontRunes returns the default font atlas characters.
+func DefaultFontRunes() string { return defaultFontRunes }
 
 // Ttf reads the truetype font and generates the atlas image and atlas
 // character mapping data.
 func Ttf(ttfBytes []byte, size int) (atlas *FontAtlas, err error) {
+	return ttf(ttfBytes, size, 0)
+}
+
+// TtfSDF reads the truetype font and generates a signed distance field
+// atlas image and atlas character mapping data. The spread is the
+// distance in pixels that the field extends beyond each glyph edge.
+// SDF atlases stay crisp when the text is scaled.
+func TtfSDF(ttfBytes []byte, size, spread int) (atlas *FontAtlas, err error) {
+	if spread <= 0 {
+		return nil, fmt.Errorf("invalid sdf spread %d", spread)
+	}
+	return ttf(ttfBytes, size, spread)
+}
+
+// ttf generates a bitmap atlas when spread is zero
+// and a signed distance field atlas otherwise.
+func ttf(ttfBytes []byte, size, spread int) (atlas *FontAtlas, err error) {
 	f, err := opentype.Parse(ttfBytes)
 	if err != nil {
 		return nil, fmt.Errorf("openttype parse:%w", err)
@@ -41,15 +85,16 @@ func Ttf(ttfBytes []byte, size int) (atlas *FontAtlas, err error) {
 	})
 	if err != nil {
 		return nil, fmt.Errorf("openttype face:%w", err)
-		return nil, err
 	}
 
 	// A reasonable amount of runes with a reasonable font size should easily
-	// fit into a 512x512 image. FUTURE: make this configurable.
+	// fit into a 512x512 image. Additional atlas pages are created for
+	// large rune sets. FUTURE: make this configurable.
 	imgSize := 512 // image width and height in pixels
 
 	atlas = &FontAtlas{}
 	img := image.NewNRGBA(image.Rect(0, 0, imgSize, imgSize))
+	pages := []*image.NRGBA{img}
 	penx, peny := 0, 0
 	lineHeight := face.Metrics().Height.Round()
 	XAscent := face.Metrics().Ascent.Round()
@@ -68,13 +113,23 @@ func Ttf(ttfBytes []byte, size int) (atlas *FontAtlas, err error) {
 		descent := int(float32(maxY) + (float32(bounds.Min.Y)/64.0 - float32(minY)))
 		bearingX := int(float32(bounds.Min.X) / 64.0)
 
+		// sdf glyphs are padded on all sides by the spread.
+		boxWidth, boxHeight := glyphWidth+2*spread, lineHeight+2*spread
+		if boxWidth >= imgSize || boxHeight >= imgSize {
+			return nil, fmt.Errorf("atlas image to small %d", imgSize)
+		}
+
 		// advance to the next line if necessary.
-		if penx+glyphWidth >= imgSize {
+		if penx+boxWidth >= imgSize {
 			penx = 0
-			peny += lineHeight
-			if peny >= imgSize {
-				return nil, fmt.Errorf("atlas image to small %d", imgSize)
-			}
+			peny += boxHeight
+		}
+
+		// start a new atlas page when the current page is full.
+		if peny+boxHeight > imgSize {
+			penx, peny = 0, 0
+			img = image.NewNRGBA(image.Rect(0, 0, imgSize, imgSize))
+			pages = append(pages, img)
 		}
 
 		// create glyph image
@@ -91,20 +146,47 @@ func Ttf(ttfBytes []byte, size int) (atlas *FontAtlas, err error) {
 		// copy glyph image to atlas image aliging the glyphs
 		// on the baseline within identical height boxes.
 		base := maxY - descent + (XAscent + minY)
-		draw.Draw(img, image.Rect(penx, peny+base, penx+glyphWidth, peny+base+glyphHeight), dst, image.Point{}, draw.Src)
+		if spread > 0 {
+			box := image.NewNRGBA(image.Rect(0, 0, boxWidth, boxHeight))
+			draw.Draw(box, image.Rect(spread, spread+base, spread+glyphWidth, spread+base+glyphHeight), dst, image.Point{}, draw.Src)
+			draw.Draw(img, image.Rect(penx, peny, penx+boxWidth, peny+boxHeight), sdfGlyph(box, spread), image.Point{}, draw.Src)
+		} else {
+			draw.Draw(img, image.Rect(penx, peny+base, penx+glyphWidth, peny+base+glyphHeight), dst, image.Point{}, draw.Src)
+		}
 
 		// capture the glyph atlas position for rendering text.
-		xoff := bearingX
-		yoff := 0 // descent is built into the font placement.
-		g := Glyph{r, penx, peny, glyphWidth, lineHeight, xoff, yoff, xadvance.Round()}
+		// The sdf padding is undone by offsetting the glyph quad.
+		xoff := bearingX - spread
+		yoff := -spread // descent is built into the font placement.
+		g := Glyph{r, penx, peny, boxWidth, boxHeight, xoff, yoff, xadvance.Round(), len(pages) - 1}
 		atlas.Glyphs = append(atlas.Glyphs, g)
-		penx += glyphWidth
+		penx += boxWidth
+	}
+	for i, page := range pages {
+		pimg := ImageData{
+			Pixels: []byte(page.Pix),
+			Width:  uint32(page.Bounds().Size().X),
+			Height: uint32(page.Bounds().Size().Y),
+			Opaque: true,
+		}
+		if i == 0 {
+			atlas.Img, atlas.NRGBA = pimg, page
+			continue
+		}
+		atlas.Pages = append(atlas.Pages, FontPage{Img: pimg, NRGBA: page})
+	}
+	atlas.LineHeight = lineHeight
+	atlas.SDF = spread > 0
+
+	// kerning adjusts the spacing between specific pairs of glyphs.
+	// Only non-zero adjustments are kept.
+	for _, left := range atlas.Glyphs {
+		for _, right := range atlas.Glyphs {
+			if k := face.Kern(left.Char, right.Char).Round(); k != 0 {
+				atlas.Kerns = append(atlas.Kerns, Kern{left.Char, right.Char, k})
+			}
+		}
 	}
-	atlas.Img.Pixels = []byte(img.Pix)
-	atlas.Img.Width = uint32(img.Bounds().Size().X)
-	atlas.Img.Height = uint32(img.Bounds().Size().Y)
-	atlas.Img.Opaque = true
-	atlas.NRGBA = img
 
 	// DEBUG dump atlas image as png.
 	// atlasPng, _ := os.Create("atlas.png")
@@ -113,3 +195,47 @@ func Ttf(ttfBytes []byte, size int) (atlas *FontAtlas, err error) {
 
 	return atlas, nil
 }
+
+// sdfGlyph converts a glyph coverage image into a signed distance field.
+// The distance to the nearest edge is searched up to spread pixels away and
+// stored in the alpha channel where 0.5 (128) is the glyph edge, values
+// above are inside the glyph and values below are outside.
+//
+// The brute force search is fine for the small glyph images
+// generated once when a font is loaded.
+func sdfGlyph(glyph *image.NRGBA, spread int) *image.NRGBA {
+	w, h := glyph.Bounds().Dx(), glyph.Bounds().Dy()
+	inside := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return false
+		}
+		return glyph.Pix[y*glyph.Stride+x*4+3] >= 0x80
+	}
+	sdf := image.NewNRGBA(image.Rect(0, 0, w, h))
+	maxDist := float64(spread * spread)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			in := inside(x, y)
+
+			// find the closest pixel with the opposite inside state.
+			closest := maxDist
+			for dy := -spread; dy <= spread; dy++ {
+				for dx := -spread; dx <= spread; dx++ {
+					d2 := float64(dx*dx + dy*dy)
+					if d2 < closest && inside(x+dx, y+dy) != in {
+						closest = d2
+					}
+				}
+			}
+			dist := math.Sqrt(closest) / float64(spread) // 0:1
+			if !in {
+				dist = -dist
+			}
+			alpha := 0.5 + dist*0.5 // -1:1 to 0:1
+			i := y*sdf.Stride + x*4
+			sdf.Pix[i], sdf.Pix[i+1], sdf.Pix[i+2] = 0xFF, 0xFF, 0xFF
+			sdf.Pix[i+3] = uint8(math.Round(alpha * 255))
+		}
+	}
+	return sdf
+}
@@ -0,0 +1,767 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package load
+
+// video.go splits movie files into their compressed video frames and
+// audio packets. Movies are streamed, so the packets are demuxed as they
+// are read, and are played by decoding the frames in order, see
+// vu.RegisterVideoCodec. The containers are from:
+//   - https://www.xiph.org/ogg/doc/framing.html
+//   - https://www.matroska.org/technical/elements.html
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"time"
+)
+
+// VideoData describes a movie and the codecs needed to decode it.
+type VideoData struct {
+	Width, Height int           // frame size in pixels.
+	FPS           float64       // frames per second, 0 if unknown.
+	Duration      time.Duration // movie length, 0 if unknown.
+	Codec         string        // video codec, eg: "theora", "vp8", "vp9", "mjpeg".
+	Headers       [][]byte      // video codec setup packets.
+
+	// the first audio track, if any.
+	AudioCodec   string   // audio codec, eg: "vorbis", "opus", "pcm".
+	AudioHeaders [][]byte // audio codec setup packets.
+	Channels     int      // number of audio channels.
+	Rate         int      // audio samples per second.
+	SampleBits   int      // "pcm" sample size, 8 or 16.
+}
+
+// VideoPacket is one compressed video frame or block of audio.
+type VideoPacket struct {
+	Time time.Duration // presentation time from the start of the movie.
+	Key  bool          // true for video frames that do not need earlier frames.
+	Data []byte        // compressed data.
+}
+
+// VideoStream demuxes a movie as it is read so that only the packets
+// waiting to be decoded are held in memory. The movie description is
+// read when the stream is opened.
+type VideoStream struct {
+	VideoData
+	more   func() error // reads and queues more packets, io.EOF at the end.
+	queue  []videoQueued
+	closer io.Closer // file opened by Video, nil for caller readers.
+}
+
+// videoQueued is a demuxed packet waiting to be read.
+type videoQueued struct {
+	packet VideoPacket
+	audio  bool
+}
+
+// Next returns the next video frame or audio packet in decode order,
+// where audio is true for audio packets. The packets are still
+// compressed by their codecs. Returns io.EOF after the last packet.
+func (vs *VideoStream) Next() (p VideoPacket, audio bool, err error) {
+	for len(vs.queue) == 0 {
+		if err = vs.more(); err != nil {
+			return p, false, err
+		}
+	}
+	q := vs.queue[0]
+	vs.queue[0] = videoQueued{}
+	vs.queue = vs.queue[1:]
+	return q.packet, q.audio, nil
+}
+
+// Close closes the movie file opened by Video.
+func (vs *VideoStream) Close() error {
+	if vs.closer == nil {
+		return nil
+	}
+	err := vs.closer.Close()
+	vs.closer = nil
+	return err
+}
+
+// push queues a demuxed packet.
+func (vs *VideoStream) push(p VideoPacket, audio bool) {
+	vs.queue = append(vs.queue, videoQueued{packet: p, audio: audio})
+}
+
+// Video opens and starts demuxing the named ".ogv" or ".webm" movie,
+// see OpenFile. The caller closes the stream when done.
+func Video(name string) (vs *VideoStream, err error) {
+	demux, ok := videoDemuxers[getFileExtension(name)]
+	if !ok {
+		return vs, fmt.Errorf("video load %s: %w", name, errors.ErrUnsupported)
+	}
+	file, err := OpenFile(AssetPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = fmt.Errorf("%w: %w", ErrAssetNotFound, err)
+		}
+		return vs, fmt.Errorf("video load %s: %w", name, err)
+	}
+	if vs, err = demux(file); err != nil {
+		file.Close()
+		return vs, fmt.Errorf("video load %s: %w", name, err)
+	}
+	vs.closer = file
+	return vs, nil
+}
+
+// videoDemuxers split each type of movie file into packets.
+var videoDemuxers = map[string]func(r io.Reader) (*VideoStream, error){
+	".ogv":  Ogv,
+	".webm": Webm,
+	".mkv":  Webm,
+}
+
+// =============================================================================
+// Ogg container with Theora video and Vorbis or Opus audio.
+
+// Ogv starts demuxing an Ogg movie. The first Theora stream is the video
+// and the first Vorbis or Opus stream is the audio. Other streams are
+// ignored. The pages are read as the packets are needed.
+//
+// The Reader r is expected to be opened and closed by the caller.
+func Ogv(r io.Reader) (vs *VideoStream, err error) {
+	vs = &VideoStream{}
+	ogg := &ogg{vs: vs, r: bufio.NewReader(r), streams: map[uint32]*oggStream{}}
+	vs.more = ogg.page
+
+	// the streams begin on the first pages, followed by their headers.
+	for !ogg.ready() {
+		if err = ogg.page(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return vs, fmt.Errorf("Invalid .ogv video file: %w", err)
+		}
+	}
+	if vs.Codec == "" {
+		return vs, fmt.Errorf("%w: .ogv video without theora stream", errors.ErrUnsupported)
+	}
+	if !ogg.ready() {
+		return vs, fmt.Errorf("Invalid .ogv video file: missing headers")
+	}
+	return vs, nil
+}
+
+// ogg tracks the demux state while reading the pages.
+type ogg struct {
+	vs      *VideoStream
+	r       *bufio.Reader
+	off     int64                 // file offset of the next page.
+	streams map[uint32]*oggStream // logical streams by serial number.
+	started bool                  // true once a page that isn't a stream start is read.
+}
+
+// oggStream is one logical stream of an Ogg file.
+type oggStream struct {
+	kind    byte          // 'v' for the video, 'a' for the audio, 0 ignored.
+	headers int           // setup packets still to come.
+	frames  int           // video frames so far.
+	at      time.Duration // time of the last audio packet.
+	partial []byte        // packet continued on the next page.
+}
+
+// ready returns true once the stream headers have been read.
+func (o *ogg) ready() bool {
+	if !o.started {
+		return false
+	}
+	for _, s := range o.streams {
+		if s.kind != 0 && s.headers > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// page reads the next page and queues the packets that end on it.
+func (o *ogg) page() error {
+	header := make([]byte, 27, 27+255)
+	if _, err := io.ReadFull(o.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("short page at %d", o.off)
+		}
+		return err // io.EOF after the last page.
+	}
+	if string(header[:4]) != "OggS" || header[4] != 0 {
+		return fmt.Errorf("bad page at %d", o.off)
+	}
+	nsegs := int(header[26])
+	page := append(header, make([]byte, nsegs)...)
+	if _, err := io.ReadFull(o.r, page[27:]); err != nil {
+		return fmt.Errorf("short page at %d", o.off)
+	}
+	lacing := page[27 : 27+nsegs]
+	size := 0
+	for _, l := range lacing {
+		size += int(l)
+	}
+	page = append(page, make([]byte, size)...)
+	if _, err := io.ReadFull(o.r, page[27+nsegs:]); err != nil {
+		return fmt.Errorf("short page at %d", o.off)
+	}
+	if crc := binary.LittleEndian.Uint32(page[22:]); crc != oggCRC(page) {
+		return fmt.Errorf("page checksum at %d", o.off)
+	}
+	o.off += int64(len(page))
+	granule := int64(binary.LittleEndian.Uint64(page[6:]))
+	serial := binary.LittleEndian.Uint32(page[14:])
+	s, ok := o.streams[serial]
+	if !ok {
+		s = &oggStream{}
+		o.streams[serial] = s
+	}
+	if page[5]&0x02 == 0 {
+		o.started = true // streams only begin on the first pages.
+	}
+
+	// lacing values less than 255 end a packet. The page granule
+	// position belongs to the last packet that ends on the page.
+	body, start, pos := page[27+nsegs:], 0, 0
+	ended := [][]byte{}
+	for _, l := range lacing {
+		pos += int(l)
+		if l < 255 {
+			ended = append(ended, append(s.partial, body[start:pos]...))
+			s.partial, start = nil, pos
+		}
+	}
+	if start < pos {
+		s.partial = append(s.partial, body[start:pos]...)
+	}
+	for i, packet := range ended {
+		if i == len(ended)-1 {
+			o.packet(s, packet, granule, !ok && i == 0)
+			continue
+		}
+		o.packet(s, packet, -1, !ok && i == 0)
+	}
+	return nil
+}
+
+// packet handles a complete packet of a logical stream. The first packet
+// identifies the stream. The headers that follow are the codec setup.
+func (o *ogg) packet(s *oggStream, data []byte, granule int64, first bool) {
+	vid := &o.vs.VideoData
+	switch {
+	case first && !o.started:
+		o.identify(s, data)
+	case s.kind == 'v' && s.headers > 0:
+		vid.Headers = append(vid.Headers, data)
+		s.headers--
+	case s.kind == 'a' && s.headers > 0:
+		vid.AudioHeaders = append(vid.AudioHeaders, data)
+		s.headers--
+	case s.kind == 'v':
+		// empty packets repeat the previous frame. A clear second bit
+		// marks an intra frame that does not depend on earlier frames.
+		at := time.Duration(float64(s.frames) / vid.FPS * float64(time.Second))
+		key := len(data) > 0 && data[0]&0x40 == 0
+		o.vs.push(VideoPacket{Time: at, Key: key, Data: data}, false)
+		s.frames++
+	case s.kind == 'a':
+		// packet times come from the granule position,
+		// which counts audio samples.
+		if granule >= 0 && vid.Rate > 0 {
+			s.at = time.Duration(granule) * time.Second / time.Duration(vid.Rate)
+		}
+		o.vs.push(VideoPacket{Time: s.at, Data: data}, true)
+	}
+}
+
+// identify keeps the first Theora stream and the first Vorbis or Opus
+// stream using the stream identification header.
+func (o *ogg) identify(s *oggStream, id []byte) {
+	vid := &o.vs.VideoData
+	switch {
+	case vid.Codec == "" && bytes.HasPrefix(id, []byte("\x80theora")) && len(id) >= 42:
+		be24 := func(b []byte) int { return int(b[0])<<16 | int(b[1])<<8 | int(b[2]) }
+		num, den := binary.BigEndian.Uint32(id[22:]), binary.BigEndian.Uint32(id[26:])
+		if num == 0 || den == 0 {
+			return // invalid frame rate.
+		}
+		vid.Codec, vid.FPS = "theora", float64(num)/float64(den)
+		vid.Width, vid.Height = be24(id[14:]), be24(id[17:])
+		vid.Headers = [][]byte{id}
+		s.kind, s.headers = 'v', 2
+	case vid.AudioCodec == "" && bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 16:
+		vid.AudioCodec, vid.Channels = "vorbis", int(id[11])
+		vid.Rate = int(binary.LittleEndian.Uint32(id[12:]))
+		vid.AudioHeaders = [][]byte{id}
+		s.kind, s.headers = 'a', 2
+	case vid.AudioCodec == "" && bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 19:
+		vid.AudioCodec, vid.Channels, vid.Rate = "opus", int(id[9]), 48000 // opus always decodes at 48kHz.
+		vid.AudioHeaders = [][]byte{id}
+		s.kind, s.headers = 'a', 1
+	}
+}
+
+// oggTable is the Ogg CRC32 lookup table for the
+// unreflected 0x04c11db7 polynomial.
+var oggTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for b := 0; b < 8; b++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggCRC returns the page checksum, calculated
+// with the page checksum field set to zero.
+func oggCRC(page []byte) (crc uint32) {
+	for i, b := range page {
+		if i >= 22 && i < 26 {
+			b = 0
+		}
+		crc = crc<<8 ^ oggTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// =============================================================================
+// Matroska container, including WebM.
+
+// Webm starts demuxing a WebM or Matroska movie. The first video track
+// and the first audio track are kept. Other tracks are ignored. The
+// clusters are read as the packets are needed.
+//
+// The Reader r is expected to be opened and closed by the caller.
+func Webm(r io.Reader) (vs *VideoStream, err error) {
+	vs = &VideoStream{}
+	mkv := &matroska{vs: vs, r: bufio.NewReader(r), scale: 1000000}
+	vs.more = mkv.more
+	magic, err := mkv.r.Peek(4)
+	if err != nil || binary.BigEndian.Uint32(magic) != mkvEBML {
+		return vs, fmt.Errorf("Invalid .webm video file")
+	}
+
+	// the tracks are described before the first cluster.
+	for !mkv.clusters {
+		if err = mkv.element(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return vs, fmt.Errorf("Invalid .webm video file: %w", err)
+		}
+	}
+	if vs.Codec == "" {
+		return vs, fmt.Errorf("%w: .webm video without video track", errors.ErrUnsupported)
+	}
+	return vs, nil
+}
+
+// Matroska element IDs, including their length markers.
+const (
+	mkvEBML          = 0x1A45DFA3
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvTrackNumber   = 0xD7
+	mkvTrackType     = 0x83
+	mkvCodecID       = 0x86
+	mkvCodecPrivate  = 0x63A2
+	mkvFrameDuration = 0x23E383 // DefaultDuration
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+	mkvAudio         = 0xE1
+	mkvSampleRate    = 0xB5 // SamplingFrequency
+	mkvChannels      = 0x9F
+	mkvBitDepth      = 0x6264
+	mkvCluster       = 0x1F43B675
+	mkvTimecode      = 0xE7
+	mkvSimpleBlock   = 0xA3
+	mkvBlockGroup    = 0xA0
+	mkvBlock         = 0xA1
+	mkvReference     = 0xFB // ReferenceBlock
+)
+
+// mkvCodecs maps the Matroska codec ids to codec names.
+var mkvCodecs = map[string]string{
+	"V_THEORA":      "theora",
+	"V_VP8":         "vp8",
+	"V_VP9":         "vp9",
+	"V_AV1":         "av1",
+	"V_MJPEG":       "mjpeg",
+	"A_VORBIS":      "vorbis",
+	"A_OPUS":        "opus",
+	"A_PCM/INT/LIT": "pcm",
+}
+
+// maxElement is the largest Matroska element that is read into memory.
+const maxElement = 64 << 20
+
+// matroska tracks the demux state while reading the elements.
+type matroska struct {
+	vs       *VideoStream
+	r        *bufio.Reader
+	off      int64      // file offset of the next element.
+	open     []mkvLevel // master elements being read.
+	clusters bool       // true once the first cluster is reached.
+	duration float64    // segment duration in timecode ticks.
+	scale    uint64     // nanoseconds per timecode tick.
+	cluster  int64      // cluster timecode.
+	track    mkvTrack
+	video    uint64 // kept video track number.
+	audio    uint64 // kept audio track number.
+}
+
+// mkvLevel is a master element whose children are being read.
+type mkvLevel struct {
+	id  uint64
+	end int64 // file offset of the element end, -1 if unknown.
+}
+
+// mkvTrack collects a track entry.
+type mkvTrack struct {
+	number, kind   uint64
+	codec          string
+	private        []byte
+	duration       uint64 // nanoseconds per frame.
+	width, height  uint64
+	rate           float64
+	channels, bits uint64
+}
+
+// more reads elements until packets are queued.
+func (mkv *matroska) more() error {
+	for len(mkv.vs.queue) == 0 {
+		if err := mkv.element(); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("Invalid .webm video file: %w", err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// element reads the next element, descending into the master elements
+// that hold the tracks and blocks. Returns io.EOF after the last element.
+func (mkv *matroska) element() (err error) {
+	mkv.finish(false)
+	id, n, err := mkv.vint(false)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			mkv.finish(true)
+		}
+		return err
+	}
+	size, m, err := mkv.vint(true)
+	if err != nil {
+		return noEOF(err)
+	}
+	mkv.off += int64(n + m)
+	end := mkv.off + int64(size)
+	if size == 1<<(7*m)-1 {
+		end = -1 // unknown sizes extend to the parent end.
+	}
+	if top := len(mkv.open) - 1; top >= 0 && mkv.open[top].end >= 0 && (end < 0 || end > mkv.open[top].end) {
+		if end < 0 {
+			end = mkv.open[top].end
+		} else {
+			return fmt.Errorf("element %x size %d", id, size)
+		}
+	}
+	switch id {
+	case mkvSegment, mkvInfo, mkvTracks, mkvVideo, mkvAudio, mkvCluster, mkvTrackEntry:
+		if top := len(mkv.open) - 1; id == mkvCluster && top >= 0 && mkv.open[top].id == mkvCluster {
+			mkv.open = mkv.open[:top] // clusters with unknown sizes end at the next cluster.
+		}
+		if id == mkvTrackEntry {
+			mkv.track = mkvTrack{}
+		}
+		mkv.clusters = mkv.clusters || id == mkvCluster
+		mkv.open = append(mkv.open, mkvLevel{id: id, end: end})
+		return nil
+	}
+	if end < 0 || size > maxElement {
+		return fmt.Errorf("element %x size %d", id, size)
+	}
+	body := make([]byte, size)
+	if _, err = io.ReadFull(mkv.r, body); err != nil {
+		return noEOF(err)
+	}
+	mkv.off = end
+	switch id {
+	case mkvBlockGroup:
+		key := mkvChild(body, mkvReference) == nil
+		if block := mkvChild(body, mkvBlock); block != nil {
+			err = mkv.addBlock(block, key)
+		}
+	case mkvSimpleBlock:
+		if len(body) > 0 {
+			err = mkv.addBlock(body, false)
+		}
+	case mkvTimecodeScale:
+		mkv.scale = ebmlUint(body)
+	case mkvDuration:
+		mkv.duration = ebmlFloat(body)
+	case mkvTimecode:
+		mkv.cluster = int64(ebmlUint(body))
+	case mkvTrackNumber:
+		mkv.track.number = ebmlUint(body)
+	case mkvTrackType:
+		mkv.track.kind = ebmlUint(body)
+	case mkvCodecID:
+		mkv.track.codec = string(bytes.TrimRight(body, "\x00"))
+	case mkvCodecPrivate:
+		mkv.track.private = body
+	case mkvFrameDuration:
+		mkv.track.duration = ebmlUint(body)
+	case mkvPixelWidth:
+		mkv.track.width = ebmlUint(body)
+	case mkvPixelHeight:
+		mkv.track.height = ebmlUint(body)
+	case mkvSampleRate:
+		mkv.track.rate = ebmlFloat(body)
+	case mkvChannels:
+		mkv.track.channels = ebmlUint(body)
+	case mkvBitDepth:
+		mkv.track.bits = ebmlUint(body)
+	}
+	return err
+}
+
+// finish closes the master elements that have been read,
+// or all the open elements at the end of the file.
+func (mkv *matroska) finish(all bool) {
+	for top := len(mkv.open) - 1; top >= 0; top-- {
+		level := mkv.open[top]
+		if !all && (level.end < 0 || mkv.off < level.end) {
+			return
+		}
+		mkv.open = mkv.open[:top]
+		switch level.id {
+		case mkvTrackEntry:
+			mkv.addTrack(&mkv.track)
+		case mkvInfo:
+			mkv.vs.Duration = time.Duration(mkv.duration * float64(mkv.scale))
+		}
+	}
+}
+
+// vint reads an EBML variable length integer, see ebmlVint.
+func (mkv *matroska) vint(strip bool) (v uint64, n int, err error) {
+	first, err := mkv.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if first == 0 {
+		return 0, 0, fmt.Errorf("bad ebml integer at %d", mkv.off)
+	}
+	for n = 1; first&(0x80>>(n-1)) == 0; n++ {
+	}
+	buf := make([]byte, n)
+	buf[0] = first
+	if _, err = io.ReadFull(mkv.r, buf[1:]); err != nil {
+		return 0, 0, noEOF(err)
+	}
+	return ebmlVint(buf, strip)
+}
+
+// noEOF reports an end of file inside an element as unexpected.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// addTrack keeps the first video and audio tracks.
+func (mkv *matroska) addTrack(t *mkvTrack) {
+	vid := &mkv.vs.VideoData
+	codec, ok := mkvCodecs[t.codec]
+	if !ok {
+		codec = t.codec // unknown codecs keep the matroska name.
+	}
+	switch {
+	case t.kind == 1 && mkv.video == 0:
+		mkv.video = t.number
+		vid.Codec, vid.Width, vid.Height = codec, int(t.width), int(t.height)
+		if t.duration > 0 {
+			vid.FPS = float64(time.Second) / float64(t.duration)
+		}
+		vid.Headers = mkvHeaders(codec, t.private)
+	case t.kind == 2 && mkv.audio == 0:
+		mkv.audio = t.number
+		vid.AudioCodec, vid.Channels, vid.Rate = codec, int(max(t.channels, 1)), int(t.rate)
+		vid.SampleBits = int(t.bits)
+		vid.AudioHeaders = mkvHeaders(codec, t.private)
+	}
+}
+
+// mkvHeaders splits the codec private data into setup packets.
+// Theora and Vorbis pack their three headers using Xiph lacing.
+// Badly laced private data is left for the decoder to reject.
+func mkvHeaders(codec string, private []byte) [][]byte {
+	if len(private) == 0 {
+		return nil
+	}
+	if codec != "theora" && codec != "vorbis" {
+		return [][]byte{private}
+	}
+	headers, err := mkvLacing(private[1:], 1, int(private[0])+1)
+	if err != nil {
+		return [][]byte{private}
+	}
+	return headers
+}
+
+// addBlock adds the frames of a block on a kept track.
+// SimpleBlocks carry their own key frame flag.
+func (mkv *matroska) addBlock(block []byte, key bool) error {
+	track, n, err := ebmlVint(block, true)
+	if err != nil || len(block) < n+3 {
+		return fmt.Errorf("bad block")
+	}
+	if track != mkv.video && track != mkv.audio {
+		return nil
+	}
+	rel := int64(int16(binary.BigEndian.Uint16(block[n:])))
+	flags := block[n+2]
+	at := time.Duration(uint64(mkv.cluster+rel) * mkv.scale)
+	frames := [][]byte{block[n+3:]}
+	if lacing := int(flags>>1) & 3; lacing != 0 {
+		if len(block) < n+4 {
+			return fmt.Errorf("bad laced block")
+		}
+		if frames, err = mkvLacing(block[n+4:], lacing, int(block[n+3])+1); err != nil {
+			return err
+		}
+	}
+	key = key || flags&0x80 != 0
+	for _, f := range frames {
+		mkv.vs.push(VideoPacket{Time: at, Key: key, Data: f}, track != mkv.video)
+	}
+	return nil
+}
+
+// mkvLacing splits data into count frames where the sizes of all but
+// the last frame are encoded using Xiph (1), fixed (2), or EBML (3) lacing.
+func mkvLacing(data []byte, lacing, count int) (frames [][]byte, err error) {
+	sizes := make([]int, count-1)
+	off := 0
+	switch lacing {
+	case 1: // xiph: sums of bytes where 255 continues.
+		for i := range sizes {
+			for {
+				if off >= len(data) {
+					return nil, fmt.Errorf("bad xiph lacing")
+				}
+				sizes[i] += int(data[off])
+				off++
+				if data[off-1] != 255 {
+					break
+				}
+			}
+		}
+	case 2: // fixed: equal sized frames.
+		if len(data)%count != 0 {
+			return nil, fmt.Errorf("bad fixed lacing")
+		}
+		for i := range sizes {
+			sizes[i] = len(data) / count
+		}
+	case 3: // ebml: a size followed by signed differences.
+		for i := range sizes {
+			v, n, err := ebmlVint(data[off:], true)
+			if err != nil {
+				return nil, fmt.Errorf("bad ebml lacing")
+			}
+			off += n
+			if i == 0 {
+				sizes[i] = int(v)
+				continue
+			}
+			bias := int64(1)<<(7*n-1) - 1
+			sizes[i] = sizes[i-1] + int(int64(v)-bias)
+		}
+	}
+	data = data[off:]
+	for _, size := range sizes {
+		if size < 0 || size > len(data) {
+			return nil, fmt.Errorf("bad lacing sizes")
+		}
+		frames = append(frames, data[:size])
+		data = data[size:]
+	}
+	return append(frames, data), nil
+}
+
+// mkvChild returns the body of the first child element with the given id.
+func mkvChild(data []byte, want uint64) []byte {
+	for len(data) > 0 {
+		id, n, err := ebmlVint(data, false)
+		if err != nil {
+			return nil
+		}
+		size, m, err := ebmlVint(data[n:], true)
+		if err != nil || size > uint64(len(data)-n-m) {
+			return nil
+		}
+		data = data[n+m:]
+		if id == want {
+			return data[:size]
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// ebmlVint reads an EBML variable length integer returning the
+// value and the number of bytes read. Element IDs keep the length
+// marker bit while sizes and numbers have it removed.
+func ebmlVint(data []byte, strip bool) (v uint64, n int, err error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, fmt.Errorf("bad ebml integer")
+	}
+	for n = 1; data[0]&(0x80>>(n-1)) == 0; n++ {
+	}
+	if len(data) < n {
+		return 0, 0, fmt.Errorf("short ebml integer")
+	}
+	for _, b := range data[:n] {
+		v = v<<8 | uint64(b)
+	}
+	if strip {
+		v &^= 1 << (7 * n) // remove the marker bit.
+	}
+	return v, n, nil
+}
+
+// ebmlUint reads a big endian unsigned integer element.
+func ebmlUint(data []byte) (v uint64) {
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// ebmlFloat reads a 4 or 8 byte float element.
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
@@ -2,9 +2,13 @@
 
 package vu
 
-// loader.go uses a goroutine to load asset data from disk.
-// The asset data is then stored in an asset object and kept
-// for reuse.
+// loader.go uses worker goroutines to load and decode asset data
+// from disk. The decoded asset data is queued for upload to the GPU
+// and audio device on the engine goroutine. Uploads are spread over
+// updates so that large imports stream in without stalling the
+// engine loop. The asset data is then stored in an asset object and
+// kept for reuse until no model uses it, see resources.go. Loaded files can be watched for changes and reloaded
+// into the existing asset objects so that references stay valid.
 
 import (
 	"fmt"
@@ -28,6 +32,9 @@ type assetLoader struct {
 	// loaded tracks asset files.
 	loaded map[string]bool // true: completed, false: still loading
 
+	// triangles keeps a CPU copy of the loaded mesh triangles.
+	triangles bool // see MeshTriangles.
+
 	// requests tracks outstanding entities requests for assets.
 	requests map[aid][]assetRequest
 
@@ -42,8 +49,63 @@ type assetLoader struct {
 	// load asset files using a goroutine.
 	loadAssetReq chan string           // request load asset file eg: "bloop.wav"
 	loadedAssets chan []load.AssetData // file assets finished importing.
+
+	// pending files are waiting for a free worker. Requests are
+	// queued here so that importing many files never blocks.
+	pending []string
+	closed  bool // true once the workers are shut down.
+
+	// uploads are decoded files waiting to be uploaded. The uploads
+	// for one update stop once the budget time has been used.
+	uploads []*assetUpload
+	budget  time.Duration
+
+	// done tracks the completion callbacks for asset files
+	// and errs remembers the files that failed to load.
+	done map[string][]func(filename string, err error)
+	errs map[string]error
+
+	// watch checks the loaded files for changes every watch interval.
+	// Changed files are reloaded in place of the existing assets.
+	watch     time.Duration                    // zero when not watching.
+	watched   time.Time                        // last file check.
+	watching  map[string]*watchedFile          // loaded files being watched.
+	reloading map[string]bool                  // files being reloaded.
+	reloaded  func(filename string, err error) // optional reload callback.
+
+	// files track the models using each loaded file so that the
+	// GPU resources of unused files can be released.
+	files     map[string]*resourceFile // loaded files used by models.
+	owners    map[aid]string           // the file for each loaded asset.
+	unused    []string                 // unused files, least recently used first.
+	resBudget uint64                   // GPU bytes of unused files to keep.
+	drops     []asset                  // generated assets to release.
+	dropInsts []uint32                 // instance data to release.
+	dropTargs []uint32                 // render targets to release.
+}
+
+// watchedFile tracks the modification times of a loaded
+// asset file and the files it depends on.
+type watchedFile struct {
+	files []string    // the asset file and its dependencies.
+	times []time.Time // last known modification times.
 }
 
+// assetUpload is a decoded asset file that is being uploaded.
+type assetUpload struct {
+	filename string
+	data     []load.AssetData // decoded file assets.
+	next     int              // next data to upload.
+	assets   []asset          // uploaded assets.
+	err      error            // first error for the file.
+	reload   bool             // true if replacing loaded assets.
+	size     uint64           // approximate GPU bytes uploaded.
+}
+
+// defaultUploadBudget is the max time per update spent uploading
+// assets. At least one asset is uploaded each update.
+const defaultUploadBudget = 5 * time.Millisecond
+
 // newLoader is called once on startup by the engine.
 func newLoader() *assetLoader {
 	l := &assetLoader{}
@@ -51,6 +113,13 @@ func newLoader() *assetLoader {
 	l.requests = map[aid][]assetRequest{}
 	l.labelRequests = map[aid][]*Entity{}
 	l.assets = map[aid]asset{}
+	l.done = map[string][]func(string, error){}
+	l.errs = map[string]error{}
+	l.watching = map[string]*watchedFile{}
+	l.reloading = map[string]bool{}
+	l.files = map[string]*resourceFile{}
+	l.owners = map[aid]string{}
+	l.budget = defaultUploadBudget
 
 	// allocate enough workers to avoid having to wait for a worker.
 	numWorkers := 5
@@ -70,6 +139,9 @@ func (l *assetLoader) getAsset(aid aid, eid eID, callback assetReady) {
 		callback(eid, a) // asset was already loaded.
 		return
 	}
+	if filename, ok := l.owners[aid]; ok {
+		l.importAssetData(filename) // asset file was evicted.
+	}
 
 	// register the asset request.
 	if reqs, ok := l.requests[aid]; ok {
@@ -86,9 +158,14 @@ func (l *assetLoader) getLoadedAsset(aid aid) asset {
 }
 
 // loadLabelMesh requests a mesh for a static label once
-// the font assets are loaded.
+// the font assets are loaded. Duplicate requests are ignored.
 func (l *assetLoader) loadLabelMesh(aid aid, me *Entity) {
 	if reqs, ok := l.labelRequests[aid]; ok {
+		for _, req := range reqs {
+			if req.eid == me.eid {
+				return // already requested.
+			}
+		}
 		l.labelRequests[aid] = append(reqs, me)
 	} else {
 		l.labelRequests[aid] = []*Entity{me} // lazy create.
@@ -114,8 +191,32 @@ func (l *assetLoader) importAssetData(assetFilenames ...string) {
 		}
 
 		// first load request for this file.
-		l.loaded[filename] = false // mark as loading
-		l.loadAssetReq <- filename // put request on loader goroutine channel.
+		l.loaded[filename] = false              // mark as loading
+		l.pending = append(l.pending, filename) // queue for the loader goroutines.
+	}
+	l.queueFiles()
+}
+
+// notify calls done once the given file has finished loading.
+// Done is called immediately for files that have already loaded.
+func (l *assetLoader) notify(filename string, done func(filename string, err error)) {
+	if l.loaded[filename] {
+		done(filename, l.errs[filename])
+		return
+	}
+	l.done[filename] = append(l.done[filename], done)
+}
+
+// queueFiles passes pending files to the loader goroutines
+// until there are no more pending files or the workers are busy.
+func (l *assetLoader) queueFiles() {
+	for len(l.pending) > 0 && !l.closed {
+		select {
+		case l.loadAssetReq <- l.pending[0]: // put request on loader goroutine channel.
+			l.pending = l.pending[1:]
+		default:
+			return // workers are busy, try again next update.
+		}
 	}
 }
 
@@ -124,165 +225,370 @@ func (l *assetLoader) dispose() {
 	// Close the worker queue since there are no more sends,
 	// however keep the receiving channel open in case there
 	// are workers trying to write to it.
+	l.closed = true
 	close(l.loadAssetReq) // shuts down idle workers.
 }
 
 // loadAssets checks for asset data from the goroutines and turns
 // any data into loaded assets. As it is expected to run on the main thread
-// each update tick, it will limit the amount of time spent processing assets
-// so as to not stall the main loop.
+// each update tick, it will limit the amount of time spent uploading assets
+// so as to not stall the main loop. Assets from one file are made available
+// together once all of the file assets have been uploaded.
 func (l *assetLoader) loadAssets(rc render.Loader, ac audio.Loader) (assetsCreated int) {
-	var timeUsed time.Duration
 	start := time.Now()
+	l.releaseResources(rc)
+	l.checkFiles()
+	l.queueFiles()
 
-	// track how much time is spent uploading assets
-	// and return after a reasonable amount has elapsed.
-	// FUTURE: possibly consume up to the amount of unused update time.
-	timeLimit := 0.005 // max 5 milliseconds per update is reasonable.
-	var err error
-	assetsCreated = 0
-	for timeUsed.Seconds() < timeLimit {
+	// queue the decoded files from the loader goroutines.
+	for received := true; received; {
 		select {
 		case loaded := <-l.loadedAssets:
-
 			// loaded should always contain one or more assets from a single file.
 			if len(loaded) <= 0 {
 				slog.Warn("investigate: no assets returned from worker")
 				break
 			}
-
-			// filename helps uniquely identify the assets.
 			filename := loaded[0].Filename
-			ext := strings.ToLower(path.Ext(filename))
-			name := strings.Replace(filename, ext, "", 1)
-			assets := []asset{}
-			for _, assetData := range loaded {
-				if assetData.Err != nil {
-					slog.Error("failed asset load", "filename", assetData.Filename, "error", assetData.Err)
-					break // developer needs to debug why asset is missing.
-				}
-				switch data := assetData.Data.(type) {
-				case load.MeshData:
-					assetsCreated += 1
-					msh := newMesh(name)
-					msh.mid, err = rc.LoadMesh(data)
-					if err != nil {
-						slog.Error("LoadMesh failed", "error", err)
-						break
-					}
-					assets = append(assets, msh)
-					slog.Debug("loader", "asset", "msh:"+msh.label(), "mid", msh.mid, "filename", filename)
-				case load.PBRMaterialData:
-					assetsCreated += 1
-					mat := newMaterial(name)
-					mat.color = rgba{
-						float32(data.ColorR),
-						float32(data.ColorG),
-						float32(data.ColorB),
-						float32(data.ColorA),
-					}
-					mat.metallic = float32(data.Metallic)
-					mat.roughness = float32(data.Roughness)
-					assets = append(assets, mat)
-					slog.Debug("loader", "asset", "mat:"+mat.label(), "filename", filename)
-				case *load.ImageData:
-					assetsCreated += 1
-					t := newTexture(name)
-					t.opaque = data.Opaque
-					t.tid, err = rc.LoadTexture(data)
-					if err != nil {
-						slog.Error("LoadTexture failed", "error", err)
-						break
-					}
-					assets = append(assets, t)
-					slog.Debug("loader", "asset", "tex:"+t.label(), "tid", t.tid, "opaque", t.opaque, "filename", filename)
-				case *load.FontAtlas:
-					// create 2 assets:
-					// 1.create the texture atlas image - uploaded to GPU.
-					assetsCreated += 1
-					t := newTexture(data.Tag)
-					t.opaque = false // a font atlas always have some alpha values.
-					t.tid, err = rc.LoadTexture(&data.Img)
-					if err != nil {
-						slog.Error("FontAtlas LoadTexture failed", "error", err)
-						break
-					}
-					assets = append(assets, t)
-					slog.Debug("loader", "asset", "tex:"+t.label(), "tid", t.tid, "opaque", t.opaque, "filename", filename)
-
-					// 2. create the font mapping data - stored in memory.
-					assetsCreated += 1
-					f := newFont(data.Tag)
-					f.setSize(int(data.Img.Width), int(data.Img.Height))
-					f.img = data.NRGBA
-					for _, g := range data.Glyphs {
-						f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa)
-					}
-					assets = append(assets, f)
-					slog.Debug("loader", "asset", "fnt:"+f.label(), "filename", filename, "chars", len(f.chars))
-				case *load.AudioData:
-					assetsCreated += 1
-					s := newSound(name)
-					s.data.Channels = data.Attrs.Channels
-					s.data.SampleBits = data.Attrs.SampleBits
-					s.data.Frequency = data.Attrs.Frequency
-					s.data.DataSize = data.Attrs.DataSize
-					s.data.AudioData = append(s.data.AudioData, data.Data...)
-					err = ac.LoadSound(&s.sid, &s.did, s.data) // upload audio data to audio device
-					if err != nil {
-						slog.Error("LoadSound failed", "error", err)
-						break
-					}
-					assets = append(assets, s)
-					slog.Debug("loader", "asset", "snd:"+s.label(), "filename", filename)
-				case *load.Shader:
-					assetsCreated += 1
-					s := newShader(name)
-					s.setConfig(data)
-					s.sid, err = rc.LoadShader(s.config)
-					if err != nil {
-						slog.Error("LoadShader failed", "error", err)
-						break
-					}
-					assets = append(assets, s)
-					slog.Debug("loader", "asset", "shd:"+s.label(), "sid", s.sid, "filename", filename)
-				case load.ShaderData:
-					// ignore since shader bytes are loaded directly from the render package.
-					slog.Warn("load.ShaderBytes called") // unexpected. Testing?
-
-				// case TODO animation asset from glb
-
-				default:
-					dtype := fmt.Sprintf("%T", data)
-					slog.Error("unknown asset data", "datatype", dtype)
-					break // developer needs sync code with the load package.
-				}
-			}
-			l.loaded[filename] = true // mark file as as loaded
+			up := &assetUpload{filename: filename, data: loaded, reload: l.reloading[filename]}
+			l.uploads = append(l.uploads, up)
+		default:
+			received = false
+		}
+	}
 
-			// track loaded assets and notify requested asset listeners.
-			for _, a := range assets {
-				l.assets[a.aid()] = a // track loaded assets.
+	// upload assets until the time budget is used.
+	for len(l.uploads) > 0 {
+		up := l.uploads[0]
+		assetsCreated += l.upload(up, rc, ac)
+		if up.next >= len(up.data) {
+			l.uploads = l.uploads[1:]
+			l.finish(up)
+		}
+		if time.Since(start) >= l.budget {
+			break // continue next update.
+		}
+	}
+	l.loadLabels(rc) // check outstanding label requests
+	return assetsCreated
+}
 
-				// notify any outstanding requests for this asset.
-				if reqs, ok := l.requests[a.aid()]; ok {
-					for _, req := range reqs {
-						req.callback(req.eid, a)
-					}
-				}
-				delete(l.requests, a.aid())
+// upload creates the engine assets for the next asset data of the
+// given file, uploading the data to the render or audio context.
+// Returns the number of assets created.
+func (l *assetLoader) upload(up *assetUpload, rc render.Loader, ac audio.Loader) (assetsCreated int) {
+	assetData := up.data[up.next]
+	up.next++
+	if assetData.Err != nil {
+		slog.Error("failed asset load", "filename", assetData.Filename, "error", assetData.Err)
+		up.err = assetData.Err
+		up.next = len(up.data) // developer needs to debug why asset is missing.
+		return 0
+	}
+
+	// filename helps uniquely identify the assets.
+	filename := up.filename
+	ext := strings.ToLower(path.Ext(filename))
+	name := strings.Replace(filename, ext, "", 1)
+	failed := func(err error) {
+		if up.err == nil {
+			up.err = err
+		}
+	}
+	var err error
+	switch data := assetData.Data.(type) {
+	case load.MeshData:
+		assetsCreated += 1
+		msh := newMesh(name)
+		msh.setBounds(data, l.triangles)
+		msh.mid, err = rc.LoadMesh(data)
+		if err != nil {
+			slog.Error("LoadMesh failed", "error", err)
+			failed(err)
+			break
+		}
+		up.assets = append(up.assets, msh)
+		up.size += meshSize(data)
+		slog.Debug("loader", "asset", "msh:"+msh.label(), "mid", msh.mid, "filename", filename)
+	case load.PBRMaterialData:
+		assetsCreated += 1
+		mat := newMaterial(name)
+		mat.color = rgba{
+			float32(data.ColorR),
+			float32(data.ColorG),
+			float32(data.ColorB),
+			float32(data.ColorA),
+		}
+		mat.metallic = float32(data.Metallic)
+		mat.roughness = float32(data.Roughness)
+		up.assets = append(up.assets, mat)
+		slog.Debug("loader", "asset", "mat:"+mat.label(), "filename", filename)
+	case *load.ImageData:
+		assetsCreated += 1
+		t := newTexture(name)
+		t.opaque = data.Opaque
+		t.w, t.h = int(data.Width), int(data.Height)
+		t.tid, err = rc.LoadTexture(data)
+		if err != nil {
+			slog.Error("LoadTexture failed", "error", err)
+			failed(err)
+			break
+		}
+		up.assets = append(up.assets, t)
+		up.size += uint64(len(data.Pixels))
+		slog.Debug("loader", "asset", "tex:"+t.label(), "tid", t.tid, "opaque", t.opaque, "filename", filename)
+	case *load.FontAtlas:
+		// create 2 assets:
+		// 1.create the texture atlas images - uploaded to GPU.
+		// Additional pages are named with a page suffix, ie: "lucon18_1"
+		images := []*load.ImageData{&data.Img}
+		for i := range data.Pages {
+			images = append(images, &data.Pages[i].Img)
+		}
+		for page, img := range images {
+			assetsCreated += 1
+			t := newTexture(fontPage(data.Tag, page))
+			t.opaque = false // a font atlas always have some alpha values.
+			t.w, t.h = int(img.Width), int(img.Height)
+			t.tid, err = rc.LoadTexture(img)
+			if err != nil {
+				slog.Error("FontAtlas LoadTexture failed", "error", err)
+				failed(err)
+				break
 			}
+			up.assets = append(up.assets, t)
+			up.size += uint64(len(img.Pixels))
+			slog.Debug("loader", "asset", "tex:"+t.label(), "tid", t.tid, "opaque", t.opaque, "filename", filename)
+		}
+		if err != nil {
+			break // don't create a font with missing pages.
+		}
 
-		default:
-			l.loadLabels(rc)     // check outstanding label requests
-			return assetsCreated // return if there are no loaded assets.
+		// 2. create the font mapping data - stored in memory.
+		assetsCreated += 1
+		f := newFont(data.Tag)
+		f.setSize(int(data.Img.Width), int(data.Img.Height))
+		f.imgs = []*image.NRGBA{data.NRGBA}
+		for _, page := range data.Pages {
+			f.imgs = append(f.imgs, page.NRGBA)
+		}
+		f.sdf = data.SDF
+		for _, g := range data.Glyphs {
+			f.addChar(g.Char, g.X, g.Y, g.W, g.H, g.Xo, g.Yo, g.Xa, g.Page)
+		}
+		if data.LineHeight > 0 {
+			f.lineh = data.LineHeight
+		}
+		for _, k := range data.Kerns {
+			f.addKern(k.Left, k.Right, k.Adjust)
 		}
-		l.loadLabels(rc) // check outstanding label requests
-		timeUsed = time.Since(start)
+		up.assets = append(up.assets, f)
+		slog.Debug("loader", "asset", "fnt:"+f.label(), "filename", filename, "chars", len(f.chars), "pages", len(f.imgs))
+	case *load.AudioData:
+		assetsCreated += 1
+		s := newSound(name)
+		s.data.Channels = data.Attrs.Channels
+		s.data.SampleBits = data.Attrs.SampleBits
+		s.data.Frequency = data.Attrs.Frequency
+		s.data.DataSize = data.Attrs.DataSize
+		s.data.AudioData = append(s.data.AudioData, data.Data...)
+		err = ac.LoadSound(&s.sid, &s.did, s.data) // upload audio data to audio device
+		if err != nil {
+			slog.Error("LoadSound failed", "error", err)
+			failed(err)
+			break
+		}
+		up.assets = append(up.assets, s)
+		slog.Debug("loader", "asset", "snd:"+s.label(), "filename", filename)
+	case *load.Shader:
+		assetsCreated += 1
+		s := newShader(name)
+		s.setConfig(data)
+		s.sid, err = rc.LoadShader(s.config)
+		if err != nil {
+			slog.Error("LoadShader failed", "error", err)
+			failed(err)
+			break
+		}
+		up.assets = append(up.assets, s)
+		slog.Debug("loader", "asset", "shd:"+s.label(), "sid", s.sid, "filename", filename)
+	case load.ShaderData:
+		// ignore since shader bytes are loaded directly from the render package.
+		slog.Warn("load.ShaderBytes called") // unexpected. Testing?
+
+	case *load.AnimationData:
+		assetsCreated += 1
+		a := newAnimation(name, data)
+		up.assets = append(up.assets, a)
+		slog.Debug("loader", "asset", "anm:"+a.label(), "joints", len(a.joints), "clips", len(a.clips), "filename", filename)
+
+	default:
+		dtype := fmt.Sprintf("%T", data)
+		slog.Error("unknown asset data", "datatype", dtype)
+		failed(fmt.Errorf("unknown asset data %s", dtype)) // developer needs sync code with the load package.
 	}
 	return assetsCreated
 }
 
+// finish makes the uploaded file assets available and
+// notifies any outstanding requests and file callbacks.
+func (l *assetLoader) finish(up *assetUpload) {
+	if up.reload {
+		l.finishReload(up)
+		return
+	}
+	l.loaded[up.filename] = true // mark file as as loaded
+	if up.err != nil {
+		l.errs[up.filename] = up.err
+	}
+
+	// track loaded assets and notify requested asset listeners.
+	l.trackFile(up)
+	for _, a := range up.assets {
+		l.addAsset(a)
+	}
+	for _, done := range l.done[up.filename] {
+		done(up.filename, up.err)
+	}
+	delete(l.done, up.filename)
+	if l.watch > 0 {
+		l.watchFile(up.filename)
+	}
+}
+
+// addAsset tracks a loaded asset and notifies any
+// outstanding requests for the asset.
+func (l *assetLoader) addAsset(a asset) {
+	l.assets[a.aid()] = a // track loaded assets.
+	if reqs, ok := l.requests[a.aid()]; ok {
+		for _, req := range reqs {
+			req.callback(req.eid, a)
+		}
+	}
+	delete(l.requests, a.aid())
+}
+
+// =============================================================================
+// asset reloading.
+
+// setWatch starts or stops checking loaded files for changes.
+// Files are checked every interval. Zero stops watching.
+func (l *assetLoader) setWatch(interval time.Duration, reloaded func(filename string, err error)) {
+	l.watch = max(interval, 0)
+	l.reloaded = reloaded
+	if l.watch == 0 {
+		clear(l.watching)
+		return
+	}
+	for filename, loaded := range l.loaded {
+		if _, ok := l.watching[filename]; loaded && !ok {
+			l.watchFile(filename)
+		}
+	}
+}
+
+// watchFile records the current modification times of the given
+// loaded file and the files it depends on.
+func (l *assetLoader) watchFile(filename string) {
+	// font files are requested with a size prefix, eg: "22:hack.ttf"
+	files := []string{filename[strings.LastIndex(filename, ":")+1:]}
+
+	// shader configurations depend on the compiled shader stages.
+	if ext := path.Ext(filename); strings.ToLower(ext) == ".shd" {
+		if s, ok := l.assets[assetID(shd, strings.TrimSuffix(filename, ext))].(*shader); ok {
+			if s.config.Stages&load.Stage_VERTEX != 0 {
+				files = append(files, s.config.Name+".vert.spv")
+			}
+			if s.config.Stages&load.Stage_FRAGMENT != 0 {
+				files = append(files, s.config.Name+".frag.spv")
+			}
+		}
+	}
+	wf := &watchedFile{files: files, times: make([]time.Time, len(files))}
+	for i, file := range files {
+		wf.times[i], _ = load.ModTime(file) // missing files are zero.
+	}
+	l.watching[filename] = wf
+}
+
+// checkFiles queues the watched files that have changed since they
+// were loaded. Files are only checked once per watch interval.
+func (l *assetLoader) checkFiles() {
+	if l.watch <= 0 || time.Since(l.watched) < l.watch {
+		return
+	}
+	l.watched = time.Now()
+	for filename, wf := range l.watching {
+		if l.reloading[filename] {
+			continue // wait for the current reload.
+		}
+		changed := false
+		for i, last := range wf.times {
+			mod, err := load.ModTime(wf.files[i])
+			if err != nil || mod.Equal(last) {
+				continue // unchanged or being written.
+			}
+			wf.times[i] = mod
+			changed = true
+		}
+		if changed {
+			slog.Debug("reloading changed asset file", "filename", filename)
+			l.reloading[filename] = true
+			l.pending = append(l.pending, filename)
+		}
+	}
+}
+
+// finishReload replaces the existing assets with the reloaded assets.
+// The existing asset objects are updated so that the models using them
+// draw the new data without having to request the assets again.
+// The previous assets are kept if the reload fails, eg: a shader
+// with compile errors. Previous GPU resources are released once
+// the frames in flight have finished with them.
+func (l *assetLoader) finishReload(up *assetUpload) {
+	delete(l.reloading, up.filename)
+	if up.err != nil {
+		slog.Error("asset reload failed", "filename", up.filename, "error", up.err)
+	} else {
+		delete(l.errs, up.filename)
+		l.trackFile(up)
+		for _, a := range up.assets {
+			if existing, ok := l.assets[a.aid()]; ok {
+				l.drops = append(l.drops, gpuAsset(existing))
+				replaceAsset(existing, a)
+				continue
+			}
+			l.addAsset(a) // new asset, or the file failed to load earlier.
+		}
+	}
+	if l.reloaded != nil {
+		l.reloaded(up.filename, up.err)
+	}
+}
+
+// replaceAsset copies the reloaded asset b into the existing asset a.
+// Assets with the same aid have the same type.
+func replaceAsset(a, b asset) {
+	switch b := b.(type) {
+	case *mesh:
+		*a.(*mesh) = *b
+	case *texture:
+		*a.(*texture) = *b
+	case *shader:
+		*a.(*shader) = *b
+	case *material:
+		*a.(*material) = *b
+	case *font:
+		*a.(*font) = *b
+	case *sound:
+		*a.(*sound) = *b
+	case *animation:
+		*a.(*animation) = *b
+	default:
+		slog.Error("unexpected reload asset", "name", b.label())
+	}
+}
+
 // loadLabels checks for outstanding label asset requests and
 // loads the label mesh if all the assets are available.
 func (l *assetLoader) loadLabels(rc render.Loader) {
@@ -296,21 +602,29 @@ func (l *assetLoader) loadLabels(rc render.Loader) {
 					slog.Error("loadLabels expected font asset", "asset", a.label())
 					break
 				}
-				str, wrap := me.labelData()
-				sx, sy, md := fnt.setStr(str, wrap)
+				if !me.Exists() {
+					continue // label disposed before its font loaded.
+				}
+				str, wrap, rtl := me.labelData()
+				sx, sy, mds := fnt.setStr(str, wrap, rtl)
 
-				// upload the mesh data
-				mid, err := rc.LoadMesh(md)
-				if err != nil {
-					slog.Error("generateLabelMesh:LoadMesh failed", "error", err)
-					break
+				// upload the mesh data, one mesh per font atlas page.
+				meshes := map[int]*mesh{}
+				for page, md := range mds {
+					mid, err := rc.LoadMesh(md)
+					if err != nil {
+						slog.Error("generateLabelMesh:LoadMesh failed", "error", err)
+						break
+					}
+					msh := newMesh(fmt.Sprintf("label%04d", mid))
+					msh.mid = mid
+					msh.generated = true
+					meshes[page] = msh
+					slog.Debug("new label mesh", "asset", "msh:"+msh.label(), "id", msh.mid, "page", page)
 				}
 
-				// set the mesh asset on the label
-				msh := newMesh(fmt.Sprintf("label%04d", mid))
-				msh.mid = mid
-				slog.Debug("new label mesh", "asset", "msh:"+msh.label(), "id", msh.mid)
-				me.setLabelMesh(msh, sx, sy)
+				// set the mesh assets on the label
+				me.setLabelMesh(meshes, sx, sy)
 			}
 			delete(l.labelRequests, aid)
 		}
@@ -338,8 +652,10 @@ func importWorker(wid int, loadAssetRequest <-chan string, loadedAssets chan<- [
 func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	generateDefaultMeshes()
 
-	// create default meshes
+	// create default meshes. These are small,
+	// so their triangles are always kept.
 	m := newMesh("icon")
+	m.setBounds(iconMeshData, true)
 	m.mid, err = rc.LoadMesh(iconMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh icon: %w", err)
@@ -348,6 +664,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	slog.Debug("vu built-in", "asset", "msh:"+m.label(), "id", m.mid)
 
 	m = newMesh("quad")
+	m.setBounds(quadMeshData, true)
 	m.mid, err = rc.LoadMesh(quadMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh quad: %w", err)
@@ -356,6 +673,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	slog.Debug("vu built-in", "asset", "msh:"+m.label(), "id", m.mid)
 
 	m = newMesh("frame")
+	m.setBounds(frameMeshData, true)
 	m.mid, err = rc.LoadMesh(frameMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh frame: %w", err)
@@ -364,6 +682,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	slog.Debug("vu built-in", "asset", "msh:"+m.label(), "id", m.mid)
 
 	m = newMesh("cube")
+	m.setBounds(cubeMeshData, true)
 	m.mid, err = rc.LoadMesh(cubeMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh cube: %w", err)
@@ -372,6 +691,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	slog.Debug("vu built-in", "asset", "msh:"+m.label(), "id", m.mid)
 
 	m = newMesh("circle")
+	m.setBounds(circleMeshData, true)
 	m.mid, err = rc.LoadMesh(circleMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh circle: %w", err)
@@ -380,6 +700,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	slog.Debug("vu built-in", "asset", "msh:"+m.label(), "id", m.mid)
 
 	m = newMesh("circle2D")
+	m.setBounds(circle2DMeshData, true)
 	m.mid, err = rc.LoadMesh(circle2DMeshData)
 	if err != nil {
 		return fmt.Errorf("LoadMesh circle2D: %w", err)
@@ -391,6 +712,7 @@ func (l *assetLoader) loadDefaultAssets(rc render.Loader) (err error) {
 	t := newTexture("test")
 	size, pixels := generateDefaultTexture()
 	img := &load.ImageData{Width: size, Height: size, Pixels: pixels}
+	t.w, t.h = int(size), int(size)
 	t.tid, err = rc.LoadTexture(img)
 	if err != nil {
 		return fmt.Errorf("LoadTexture test: %w", err)
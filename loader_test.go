@@ -3,6 +3,9 @@
 package vu
 
 import (
+	"fmt"
+	"io/fs"
+	"path"
 	"testing"
 	"time"
 
@@ -94,6 +97,208 @@ func TestLoader(t *testing.T) {
 	})
 }
 
+// go test -run LoaderStream
+// verify loader spreads uploads over updates and reports each file.
+func TestLoaderStream(t *testing.T) {
+	ld := newLoader()                // start goroutine.
+	rc := &loaderTestRenderContext{} // mock render context.
+	ac := &loaderTestAudioContext{}  // mock audio context.
+	defer ld.dispose()
+	ld.budget = 0 // one asset each update.
+
+	done := map[string]error{}
+	notify := func(filename string, err error) { done[filename] = err }
+	files := []string{"box0.glb", "bloop.wav", "missing.png"}
+	ld.importAssetData(files...)
+	for _, file := range files {
+		ld.notify(file, notify)
+	}
+	for i := 0; i < 2000 && len(done) < len(files); i++ {
+		if created := ld.loadAssets(rc, ac); created > 1 {
+			t.Fatalf("expected one asset per update got %d", created)
+		}
+		if _, ok := done["box0.glb"]; !ok && ld.getLoadedAsset(assetID(msh, "box0")) != nil {
+			t.Fatalf("expected file assets to be available together")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(done) != 3 || done["box0.glb"] != nil || done["bloop.wav"] != nil || done["missing.png"] == nil {
+		t.Fatalf("expected callbacks for each file %v", done)
+	}
+	if ld.getLoadedAsset(assetID(msh, "box0")) == nil || ld.getLoadedAsset(assetID(aud, "bloop")) == nil {
+		t.Errorf("expected loaded assets")
+	}
+
+	// files that are already loaded are reported immediately.
+	calls := 0
+	ld.notify("missing.png", func(filename string, err error) {
+		if calls++; err == nil {
+			t.Errorf("expected the earlier load error")
+		}
+	})
+	if calls != 1 {
+		t.Errorf("expected immediate callback")
+	}
+}
+
+// go test -run LoaderFontPages
+// verify fonts are not created when an atlas page fails to upload.
+func TestLoaderFontPages(t *testing.T) {
+	ld := newLoader()
+	defer ld.dispose()
+	atlas := &load.FontAtlas{Tag: "lucon18", Pages: []load.FontPage{{}}}
+	up := &assetUpload{filename: "lucon.ttf", data: []load.AssetData{{Filename: "lucon.ttf", Data: atlas}}}
+	ld.upload(up, &loaderTestFailPages{}, &loaderTestAudioContext{})
+	if up.err == nil {
+		t.Fatalf("expected page upload error")
+	}
+	for _, a := range up.assets {
+		if _, ok := a.(*font); ok {
+			t.Errorf("expected font to be skipped")
+		}
+	}
+}
+
+// loaderTestFailPages fails to upload the second font page.
+type loaderTestFailPages struct {
+	loaderTestRenderContext
+	loads int
+}
+
+func (rc *loaderTestFailPages) LoadTexture(img *load.ImageData) (uint32, error) {
+	if rc.loads++; rc.loads > 1 {
+		return 0, fmt.Errorf("out of memory")
+	}
+	return 1, nil
+}
+
+// go test -run LoaderWatch
+// verify changed files are reloaded into the existing assets.
+func TestLoaderWatch(t *testing.T) {
+	ld := newLoader()                // start goroutine.
+	rc := &loaderTestRenderContext{} // mock render context.
+	ac := &loaderTestAudioContext{}  // mock audio context.
+	defer ld.dispose()
+
+	// fake the file modification times.
+	modTimes := map[string]time.Time{}
+	defer func(stat func(string) (fs.FileInfo, error)) { load.StatFile = stat }(load.StatFile)
+	load.StatFile = func(name string) (fs.FileInfo, error) {
+		return loaderTestFileInfo{mod: modTimes[path.Base(name)]}, nil
+	}
+	reloads := map[string]int{}
+	ld.setWatch(time.Nanosecond, func(filename string, err error) {
+		if err != nil {
+			t.Errorf("unexpected reload error %s", err)
+		}
+		reloads[filename]++
+	})
+	update := func(until func() bool) {
+		for i := 0; i < 2000 && !until(); i++ {
+			ld.loadAssets(rc, ac)
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// load the mesh and check that it is unchanged.
+	ld.importAssetData("box0.glb")
+	update(func() bool { return ld.loaded["box0.glb"] })
+	m, ok := ld.getLoadedAsset(assetID(msh, "box0")).(*mesh)
+	if !ok {
+		t.Fatalf("expected box0 mesh")
+	}
+	mid := m.mid
+	ld.loadAssets(rc, ac)
+	if len(reloads) != 0 || len(ld.reloading) != 0 {
+		t.Fatalf("expected no reloads for unchanged files")
+	}
+
+	// change the file and check the existing mesh is updated.
+	modTimes["box0.glb"] = time.Now()
+	update(func() bool { return reloads["box0.glb"] > 0 })
+	if reloads["box0.glb"] != 1 {
+		t.Fatalf("expected one reload got %d", reloads["box0.glb"])
+	}
+	if ld.getLoadedAsset(assetID(msh, "box0")) != m || m.mid == mid {
+		t.Errorf("expected reloaded data in the existing mesh")
+	}
+
+	// stop watching.
+	ld.setWatch(0, nil)
+	modTimes["box0.glb"] = time.Now().Add(time.Second)
+	ld.loadAssets(rc, ac)
+	if len(ld.reloading) != 0 {
+		t.Errorf("expected no reloads once stopped")
+	}
+}
+
+// go test -run Resources
+// verify unused asset files are evicted and imported again when needed.
+func TestResources(t *testing.T) {
+	eng := &Engine{app: newApplication()}
+	ld := eng.app.ld
+	rc := &loaderTestRenderContext{} // mock render context.
+	ac := &loaderTestAudioContext{}  // mock audio context.
+	defer ld.dispose()
+	update := func(until func() bool) {
+		for i := 0; i < 2000 && !until(); i++ {
+			ld.loadAssets(rc, ac)
+			time.Sleep(time.Millisecond)
+		}
+	}
+	users := func() int {
+		if rf := ld.files["box0.glb"]; rf != nil {
+			return rf.users
+		}
+		return -1
+	}
+
+	// two models share the file mesh.
+	scene := eng.AddScene(Scene3D)
+	ld.importAssetData("box0.glb")
+	m1 := scene.AddModel("msh:box0")
+	update(func() bool { return ld.loaded["box0.glb"] })
+	m2 := scene.AddModel("msh:box0")
+	if users() != 2 {
+		t.Fatalf("expected 2 users got %d", users())
+	}
+
+	// unused files are kept within the budget.
+	eng.SetResourceBudget(1 << 30)
+	m1.Dispose(eng)
+	m2.Dispose(eng)
+	ld.loadAssets(rc, ac)
+	if users() != 0 || ld.getLoadedAsset(assetID(msh, "box0")) == nil {
+		t.Fatalf("expected unused file to be cached %d", users())
+	}
+
+	// unused files are evicted when over the budget.
+	drops := loaderTestDrops
+	eng.SetResourceBudget(0)
+	ld.loadAssets(rc, ac)
+	if ld.getLoadedAsset(assetID(msh, "box0")) != nil || ld.loaded["box0.glb"] {
+		t.Fatalf("expected unused file to be evicted")
+	}
+	if loaderTestDrops == drops {
+		t.Errorf("expected GPU resources to be dropped")
+	}
+
+	// evicted files are imported again when requested.
+	m3 := scene.AddModel("msh:box0")
+	update(func() bool { return ld.loaded["box0.glb"] })
+	if mod := eng.app.models.get(m3.eid); mod == nil || mod.mesh == nil || users() != 1 {
+		t.Errorf("expected reloaded mesh")
+	}
+}
+
+// loaderTestFileInfo fakes file modification times.
+type loaderTestFileInfo struct {
+	fs.FileInfo
+	mod time.Time
+}
+
+func (fi loaderTestFileInfo) ModTime() time.Time { return fi.mod }
+
 // track asset load callbacks to entities.
 var loaderTestID1Callbacks = 0
 var loaderTestID2Callbacks = 0
@@ -114,6 +319,7 @@ var loaderTestTextureUpdates = 0
 var loaderTestMeshLoads = 0
 var loaderTestShaderLoads = 0
 var loaderTestSoundLoads = 0
+var loaderTestDrops = 0
 
 // mock the render.Load interface expected by the loader.
 type loaderTestRenderContext struct{}
@@ -128,7 +334,7 @@ func (rc *loaderTestRenderContext) UpdateTexture(tid uint32, img *load.ImageData
 }
 func (rc *loaderTestRenderContext) LoadMesh(load.MeshData) (mid uint32, err error) {
 	loaderTestMeshLoads += 1
-	return 0, nil
+	return uint32(loaderTestMeshLoads), nil
 }
 func (rc *loaderTestRenderContext) LoadMeshes([]load.MeshData) (mids []uint32, err error) {
 	loaderTestMeshLoads += 1
@@ -139,6 +345,14 @@ func (rc *loaderTestRenderContext) LoadShader(config *load.Shader) (tid uint16,
 	loaderTestShaderLoads += 1
 	return 0, nil
 }
+func (rc *loaderTestRenderContext) LoadTarget(w, h uint32) (target, tid uint32, err error) {
+	return 1, 0, nil
+}
+func (rc *loaderTestRenderContext) DropTexture(tid uint32)      { loaderTestDrops += 1 }
+func (rc *loaderTestRenderContext) DropMesh(mid uint32)         { loaderTestDrops += 1 }
+func (rc *loaderTestRenderContext) DropShader(sid uint16)       { loaderTestDrops += 1 }
+func (rc *loaderTestRenderContext) DropInstanceData(iid uint32) { loaderTestDrops += 1 }
+func (rc *loaderTestRenderContext) DropTarget(target uint32)    { loaderTestDrops += 1 }
 
 // mock the audio.Load interface expected by the loader.
 type loaderTestAudioContext struct{}
@@ -0,0 +1,150 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// lod.go swaps 3D model meshes for lower detail meshes as the model
+// moves away from the camera, eg:
+//
+//	tree := scene.AddModel("shd:pbr0", "msh:tree", "mat:tree")
+//	tree.AddLOD("msh:tree1", 40).AddLOD("msh:tree2", 100).SetLODFade(5)
+//
+// The lower detail meshes are expected to be imported by the
+// application, eg: eng.ImportAssets("tree1.glb", "tree2.glb").
+
+import (
+	"cmp"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// AddLOD adds a level of detail mesh, eg: "msh:tree1", that is drawn
+// instead of the model mesh when the model is at least the given distance
+// from the camera. The model mesh is drawn until the level of detail mesh
+// has loaded. Only affects 3D models.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) AddLOD(mesh string, distance float64) *Entity {
+	m := e.app.models.get(e.eid)
+	if m == nil {
+		slog.Error("AddLOD needs AddModel", "eid", e.eid)
+		return e
+	}
+	name, ok := strings.CutPrefix(mesh, "msh:")
+	if !ok || name == "" {
+		slog.Error("AddLOD expects msh:name", "eid", e.eid, "mesh", mesh)
+		return e
+	}
+	m.lods = append(m.lods, &lod{name: name, distance: distance})
+	slices.SortStableFunc(m.lods, func(a, b *lod) int { return cmp.Compare(a.distance, b.distance) })
+	e.app.ld.getAsset(assetID(msh, name), e.eid, e.app.models.lodLoaded)
+	return e
+}
+
+// SetLODFade cross-fades between levels of detail over the given distance
+// before each level of detail distance. Both meshes are drawn while fading
+// so that the change is less noticeable. Cross-fading uses the model
+// color alpha so it only affects shaders with a color uniform. Zero, the
+// default, switches meshes without fading.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetLODFade(distance float64) *Entity {
+	m := e.app.models.get(e.eid)
+	if m == nil {
+		slog.Error("SetLODFade needs AddModel", "eid", e.eid)
+		return e
+	}
+	m.lodFade = max(0, distance)
+	return e
+}
+
+// lod is a level of detail mesh for a model.
+type lod struct {
+	name     string  // mesh asset name.
+	distance float64 // camera distance where the mesh is used.
+	mesh     *mesh   // nil until loaded.
+}
+
+// lodLoaded is called from the loader when a level of
+// detail mesh has finished loading.
+func (ms *models) lodLoaded(eid eID, a asset) {
+	m := ms.get(eid)
+	msh, ok := a.(*mesh)
+	if m == nil || !ok {
+		return // the model may have been disposed before the mesh loaded.
+	}
+	for _, l := range m.lods {
+		if l.name == msh.name {
+			if l.mesh != nil {
+				ms.ld.release(l.mesh)
+			}
+			ms.ld.acquire(msh)
+			l.mesh = msh
+		}
+	}
+}
+
+// lodMeshes returns the mesh to draw based on the model distance from
+// the camera. The next level of detail mesh and its fade, from 0 to 1,
+// are returned when the model is cross-fading to the next level.
+func (m *model) lodMeshes() (msh, next *mesh, fade float64) {
+	msh = m.mesh
+	if len(m.lods) == 0 {
+		return msh, nil, 0
+	}
+	dist := math.Sqrt(m.tocam) // tocam is distance squared.
+	for _, l := range m.lods {
+		if l.mesh == nil {
+			continue // not loaded.
+		}
+		if dist >= l.distance {
+			msh = l.mesh
+			continue
+		}
+		if m.lodFade > 0 && dist > l.distance-m.lodFade {
+			return msh, l.mesh, (dist - (l.distance - m.lodFade)) / m.lodFade
+		}
+		break
+	}
+	return msh, nil, 0
+}
+
+// lodFadePacket adds a packet for the next level of detail mesh when
+// the model packet at the given index is cross-fading between levels.
+// Both packets are drawn as transparent using the fade to scale the
+// model color alpha.
+func (ss *scenes) lodFadePacket(m *model, index int, packets render.Packets) render.Packets {
+	_, next, fade := m.lodMeshes()
+	if next == nil || m.mat == nil || len(packets[index].Uniforms[load.COLOR]) == 0 {
+		return packets // not fading or the shader has no color.
+	}
+	packets, far := packets.GetPacket()
+	near := &packets[index] // after GetPacket may have grown packets.
+
+	// copy the model packet, using the next mesh.
+	far.ShaderID = near.ShaderID
+	far.MeshID = next.mid
+	far.TextureIDs = append(far.TextureIDs, near.TextureIDs...)
+	far.Bones = append(far.Bones, near.Bones...)
+	for uid, data := range near.Uniforms {
+		far.Uniforms[uid] = append(far.Uniforms[uid][:0], data...)
+	}
+	far.Tag = near.Tag
+	far.Scope = near.Scope
+	far.Occlusion = near.Occlusion
+
+	// fade out the current mesh while fading in the next mesh.
+	c := m.mat.color
+	near.Uniforms[load.COLOR] = render.V4S32ToBytes(c.r, c.g, c.b, c.a*float32(1-fade), near.Uniforms[load.COLOR])
+	far.Uniforms[load.COLOR] = render.V4S32ToBytes(c.r, c.g, c.b, c.a*float32(fade), far.Uniforms[load.COLOR])
+	if m.drawType() < drawTransparent {
+		near.Bucket = setBucketType(near.Bucket, drawTransparent)
+	}
+	near.Bucket = setBucketDepth(near.Bucket, m.depth)
+	far.Bucket = near.Bucket
+	return packets
+}
@@ -0,0 +1,64 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run LOD
+func TestLOD(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	col3D := newShader("col3D")  // shader with a color uniform.
+	cfg, err := load.ShaderConfig("col3D.shd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	col3D.setConfig(cfg)
+	app.ld.assets[col3D.aid()] = col3D
+	scene := app.addScene(Scene3D)
+	me := scene.AddModel("shd:col3D", "msh:cube")
+	me.AddLOD("msh:quad", 50).AddLOD("msh:icon", 20) // loaded out of order.
+	m := app.models.get(me.eid)
+	cube, quad, icon := m.mesh, m.lods[1].mesh, m.lods[0].mesh
+	if cube == nil || quad == nil || icon == nil {
+		t.Fatalf("expected default meshes")
+	}
+
+	t.Run("select", func(t *testing.T) {
+		for _, tc := range []struct {
+			dist float64
+			msh  *mesh
+		}{{0, cube}, {19, cube}, {20, icon}, {49, icon}, {50, quad}, {500, quad}} {
+			m.tocam = tc.dist * tc.dist
+			if msh, next, _ := m.lodMeshes(); msh != tc.msh || next != nil {
+				t.Errorf("distance %f expected mesh %s got %s", tc.dist, tc.msh.name, msh.name)
+			}
+		}
+	})
+	t.Run("fade", func(t *testing.T) {
+		me.SetLODFade(10)
+		m.tocam = 45 * 45
+		msh, next, fade := m.lodMeshes()
+		if msh != icon || next != quad || fade != 0.5 {
+			t.Errorf("expected half fade from icon to quad got %s %v %f", msh.name, next, fade)
+		}
+	})
+	t.Run("fade packets", func(t *testing.T) {
+		me.SetColor(1, 1, 1, 1).SetAt(0, 0, -45)
+		packets := app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets
+		if len(packets) != 2 {
+			t.Fatalf("expected 2 packets while fading got %d", len(packets))
+		}
+		for _, p := range packets {
+			if c := p.Uniforms[load.COLOR]; len(c) != 16 || c[15] != 0x3F || c[14] != 0x00 {
+				t.Errorf("expected half faded alpha %v", c)
+			}
+		}
+	})
+}
@@ -0,0 +1,311 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// logger.go routes the engine and application log records to sinks, eg:
+//
+//	file, _ := os.Create("game.log")
+//	vu.NewLogger().
+//		AddSink(vu.LogWriter(file), slog.LevelDebug).
+//		AddSink(eng.Console(), slog.LevelInfo).
+//		SetLevel("render", slog.LevelWarn).
+//		SetRateLimit(10, time.Second).
+//		Install()
+//
+// The engine logs with log/slog. Each record is tagged with the subsystem
+// that logged it, eg: "render", "audio", "physics", found from the package
+// of the logging code. Engine records use "engine" and application records
+// use "app", unless the record has a "sys" attribute, eg:
+//
+//	slog.Info("spawned", "sys", "ai", "eid", eid)
+//
+// Records are filtered by subsystem level, rate limited by message, and
+// then passed to each sink that accepts the record level.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger filters log records and passes them to sinks.
+// Create with NewLogger.
+type Logger struct {
+	lock   sync.Mutex
+	levels map[string]slog.Level // minimum level by subsystem.
+	level  slog.Level            // minimum level for other subsystems.
+	sinks  []logSink             // record destinations.
+
+	// rate limiting, off when limit is 0.
+	limit int                 // records per period for each message.
+	per   time.Duration       // rate limit period.
+	rates map[string]*logRate // rate limits by subsystem and message.
+}
+
+// logSink is a sink and the minimum level that it accepts.
+type logSink struct {
+	sink  LogSink
+	level slog.Level
+}
+
+// logRate counts the records for one message in the current period.
+type logRate struct {
+	start   time.Time // start of the period.
+	count   int       // records in the period.
+	dropped int       // records dropped since the last record passed.
+}
+
+// LogSink receives the log records that pass the Logger filters.
+// Sinks are called on the goroutine that logged the record, one
+// record at a time.
+type LogSink interface {
+	Log(rec *LogRecord)
+}
+
+// LogFunc is a callback log sink.
+type LogFunc func(rec *LogRecord)
+
+// Log implements LogSink.
+func (f LogFunc) Log(rec *LogRecord) { f(rec) }
+
+// LogWriter returns a sink that writes each record as a line of text,
+// starting with the record time, eg: to a log file.
+func LogWriter(w io.Writer) LogSink {
+	return LogFunc(func(rec *LogRecord) {
+		io.WriteString(w, rec.Time.Format("2006-01-02 15:04:05.000 ")+rec.String()+"\n")
+	})
+}
+
+// LogRecord is a log record along with its subsystem.
+type LogRecord struct {
+	Time    time.Time
+	Level   slog.Level
+	Sys     string      // subsystem, eg: "render".
+	Message string      //
+	Attrs   []slog.Attr // record attributes, without "sys".
+	Dropped int         // records with this message dropped by rate limiting.
+}
+
+// String returns the record as text, without the time, eg:
+//
+//	WARN render: shader compile shader=pbr
+func (rec *LogRecord) String() string {
+	b := &strings.Builder{}
+	b.WriteString(rec.Level.String() + " " + rec.Sys + ": " + rec.Message)
+	for _, a := range rec.Attrs {
+		b.WriteString(" " + a.String())
+	}
+	if rec.Dropped > 0 {
+		fmt.Fprintf(b, " (%d dropped)", rec.Dropped)
+	}
+	return b.String()
+}
+
+// NewLogger returns a logger without sinks that passes records
+// at slog.LevelInfo and above.
+func NewLogger() *Logger {
+	return &Logger{levels: map[string]slog.Level{}, rates: map[string]*logRate{}}
+}
+
+// AddSink adds a destination for the records at the given level and above.
+func (l *Logger) AddSink(sink LogSink, level slog.Level) *Logger {
+	l.lock.Lock()
+	l.sinks = append(l.sinks, logSink{sink: sink, level: level})
+	l.lock.Unlock()
+	return l
+}
+
+// SetLevel sets the minimum level for the given subsystem,
+// or for all other subsystems when sys is "".
+func (l *Logger) SetLevel(sys string, level slog.Level) *Logger {
+	l.lock.Lock()
+	if sys == "" {
+		l.level = level
+	} else {
+		l.levels[sys] = level
+	}
+	l.lock.Unlock()
+	return l
+}
+
+// SetRateLimit passes at most n records with the same subsystem and
+// message in each period. The number of dropped records is reported
+// with the next record that passes. Rate limiting is off for n <= 0.
+func (l *Logger) SetRateLimit(n int, per time.Duration) *Logger {
+	l.lock.Lock()
+	l.limit, l.per = n, per
+	clear(l.rates)
+	l.lock.Unlock()
+	return l
+}
+
+// Handler returns the slog handler that passes records to the logger.
+func (l *Logger) Handler() slog.Handler { return &logHandler{log: l} }
+
+// Install makes the logger the slog default so that it receives the
+// engine logs, the application slog logs, and the standard log package.
+func (l *Logger) Install() { slog.SetDefault(slog.New(l.Handler())) }
+
+// enabled returns true if any subsystem or sink uses the level.
+func (l *Logger) enabled(level slog.Level) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	lowest := l.level
+	for _, lv := range l.levels {
+		lowest = min(lowest, lv)
+	}
+	if level < lowest {
+		return false
+	}
+	for _, s := range l.sinks {
+		if level >= s.level {
+			return true
+		}
+	}
+	return false
+}
+
+// log filters the record and passes it to the sinks.
+// The lock is held while logging so that sinks see one record at a time.
+func (l *Logger) log(rec *LogRecord) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	level, ok := l.levels[rec.Sys]
+	if !ok {
+		level = l.level
+	}
+	if rec.Level < level || !l.allow(rec) {
+		return
+	}
+	for _, s := range l.sinks {
+		if rec.Level >= s.level {
+			s.sink.Log(rec)
+		}
+	}
+}
+
+// allow applies the rate limit for the record message.
+func (l *Logger) allow(rec *LogRecord) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	key := rec.Sys + ":" + rec.Message
+	rate, ok := l.rates[key]
+	if !ok {
+		rate = &logRate{start: rec.Time}
+		l.rates[key] = rate
+	}
+	if rec.Time.Sub(rate.start) >= l.per {
+		rate.start, rate.count = rec.Time, 0
+	}
+	if rate.count >= l.limit {
+		rate.dropped++
+		return false
+	}
+	rate.count++
+	rec.Dropped, rate.dropped = rate.dropped, 0
+	return true
+}
+
+// =============================================================================
+
+// logHandler adapts the Logger to slog, keeping the
+// attributes and groups added with WithAttrs and WithGroup.
+type logHandler struct {
+	log    *Logger
+	attrs  []slog.Attr // attributes from WithAttrs.
+	sys    string      // "sys" attribute from WithAttrs.
+	groups []string    // groups from WithGroup.
+}
+
+// Enabled implements slog.Handler.
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.log.enabled(level)
+}
+
+// Handle implements slog.Handler.
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	rec := &LogRecord{Time: r.Time, Level: r.Level, Sys: h.sys, Message: r.Message}
+	rec.Attrs = append(rec.Attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "sys" && len(h.groups) == 0 {
+			rec.Sys = a.Value.String()
+		} else {
+			rec.Attrs = append(rec.Attrs, h.group(a))
+		}
+		return true
+	})
+	if rec.Sys == "" {
+		rec.Sys = logSys(r.PC)
+	}
+	h.log.log(rec)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		if a.Key == "sys" && len(h.groups) == 0 {
+			nh.sys = a.Value.String()
+			continue
+		}
+		nh.attrs = append(nh.attrs, h.group(a))
+	}
+	return &nh
+}
+
+// WithGroup implements slog.Handler.
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.groups = append(append([]string{}, h.groups...), name)
+	return &nh
+}
+
+// group qualifies the attribute key with the current groups.
+func (h *logHandler) group(a slog.Attr) slog.Attr {
+	if len(h.groups) > 0 {
+		a.Key = strings.Join(h.groups, ".") + "." + a.Key
+	}
+	return a
+}
+
+// logSys returns the subsystem for the code at the given program counter.
+func logSys(pc uintptr) string {
+	if pc == 0 {
+		return "app"
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return logFuncSys(frame.Function)
+}
+
+// logFuncSys returns the subsystem for the given function name,
+// eg: "render" for github.com/gazed/vu/render.(*Context).Draw.
+// Engine subsystems are named for their package.
+func logFuncSys(fn string) string {
+	pkg := fn
+	if slash := strings.LastIndex(fn, "/"); slash >= 0 {
+		if dot := strings.Index(fn[slash:], "."); dot >= 0 {
+			pkg = fn[:slash+dot]
+		}
+	}
+	const module = "github.com/gazed/vu"
+	switch {
+	case pkg == module:
+		return "engine"
+	case strings.HasPrefix(pkg, module+"/"):
+		path := strings.TrimPrefix(strings.TrimPrefix(pkg, module+"/"), "internal/")
+		sys, _, _ := strings.Cut(path, "/")
+		return sys
+	}
+	return "app"
+}
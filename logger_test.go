@@ -0,0 +1,112 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run Logger
+func TestLogger(t *testing.T) {
+	recs := []*LogRecord{}
+	keep := LogFunc(func(rec *LogRecord) { recs = append(recs, rec) })
+	last := func() string {
+		if len(recs) == 0 {
+			return ""
+		}
+		return recs[len(recs)-1].String()
+	}
+
+	// go test -run Logger/tags
+	t.Run("tags", func(t *testing.T) {
+		recs = recs[:0]
+		log := slog.New(NewLogger().AddSink(keep, slog.LevelInfo).Handler())
+		log.Info("start", "size", 2)
+		if last() != "INFO engine: start size=2" {
+			t.Errorf("expected engine subsystem got %q", last())
+		}
+		log.With("sys", "ai").WithGroup("npc").Info("spawn", "eid", 7)
+		if last() != "INFO ai: spawn npc.eid=7" {
+			t.Errorf("expected ai subsystem got %q", last())
+		}
+		for pkg, sys := range map[string]string{
+			"github.com/gazed/vu/render.(*Context).Draw":   "render",
+			"github.com/gazed/vu/internal/audio/al.Init":   "audio",
+			"github.com/gazed/vu.(*Engine).Run":            "engine",
+			"github.com/gazed/vu/physics.Simulate.func1":   "physics",
+			"github.com/gazed/vue.Run":                     "app",
+			"main.main":                                    "app",
+			"github.com/someone/game/levels.(*Level).Load": "app",
+		} {
+			if got := logFuncSys(pkg); got != sys {
+				t.Errorf("%s: expected %s got %s", pkg, sys, got)
+			}
+		}
+	})
+
+	// go test -run Logger/levels
+	t.Run("levels", func(t *testing.T) {
+		recs = recs[:0]
+		logger := NewLogger().AddSink(keep, slog.LevelDebug).
+			SetLevel("", slog.LevelWarn).SetLevel("render", slog.LevelDebug)
+		log := slog.New(logger.Handler())
+		log.Info("hidden")
+		log.Debug("shown", "sys", "render")
+		log.Info("hidden", "sys", "audio")
+		log.Warn("shown", "sys", "audio")
+		if len(recs) != 2 || recs[0].Sys != "render" || recs[1].Sys != "audio" {
+			t.Errorf("expected subsystem levels got %d records", len(recs))
+		}
+		if !log.Enabled(context.Background(), slog.LevelDebug) {
+			t.Errorf("expected debug enabled for render")
+		}
+		logger.SetLevel("render", slog.LevelWarn)
+		if log.Enabled(context.Background(), slog.LevelInfo) {
+			t.Errorf("expected info disabled")
+		}
+	})
+
+	// go test -run Logger/sinks
+	t.Run("sinks", func(t *testing.T) {
+		recs = recs[:0]
+		buf := &bytes.Buffer{}
+		log := slog.New(NewLogger().
+			AddSink(keep, slog.LevelInfo).
+			AddSink(LogWriter(buf), slog.LevelError).Handler())
+		log.Info("loaded")
+		log.Error("failed", "err", "missing")
+		if len(recs) != 2 || strings.Count(buf.String(), "\n") != 1 {
+			t.Fatalf("expected sink levels got %d %q", len(recs), buf.String())
+		}
+		if !strings.HasSuffix(buf.String(), " ERROR engine: failed err=missing\n") {
+			t.Errorf("expected a timestamped line got %q", buf.String())
+		}
+	})
+
+	// go test -run Logger/rate
+	t.Run("rate", func(t *testing.T) {
+		recs = recs[:0]
+		h := NewLogger().AddSink(keep, slog.LevelInfo).SetRateLimit(2, time.Second).Handler()
+		start := time.Now()
+		logAt := func(ms int, msg string) {
+			r := slog.NewRecord(start.Add(time.Duration(ms)*time.Millisecond), slog.LevelInfo, msg, 0)
+			h.Handle(context.Background(), r)
+		}
+		for i := 0; i < 5; i++ {
+			logAt(i, "spam")
+		}
+		logAt(5, "other")
+		if len(recs) != 3 {
+			t.Fatalf("expected spam limited got %d records", len(recs))
+		}
+		logAt(1000, "spam")
+		if len(recs) != 4 || recs[3].Dropped != 3 || last() != "INFO app: spam (3 dropped)" {
+			t.Errorf("expected dropped count in the next period got %q", last())
+		}
+	})
+}
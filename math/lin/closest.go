@@ -0,0 +1,124 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+// closest.go finds the closest points on segments, triangles, and boxes,
+// and calculates barycentric coordinates. These are the building blocks
+// for picking, navigation mesh projection, decals, and ground snapping.
+//
+// Based on Real-Time Collision Detection by Christer Ericson, chapter 5.
+
+// ClosestPointOnSegment updates vector v to be the point on the line
+// segment from a to b that is closest to point p. Vector v may be used
+// as one of the input vectors. The updated vector v is returned.
+func (v *V3) ClosestPointOnSegment(p, a, b *V3) *V3 {
+	t := SegmentFraction(p, a, b)
+	return v.SetS(a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t, a.Z+(b.Z-a.Z)*t)
+}
+
+// SegmentFraction returns the fraction, from 0 at a to 1 at b, of the
+// point on the line segment from a to b that is closest to point p.
+// Zero is returned for degenerate segments where a and b are the same.
+func SegmentFraction(p, a, b *V3) float64 {
+	abx, aby, abz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	abab := abx*abx + aby*aby + abz*abz
+	if abab < Epsilon*Epsilon {
+		return 0
+	}
+	t := ((p.X-a.X)*abx + (p.Y-a.Y)*aby + (p.Z-a.Z)*abz) / abab
+	return Clamp(t, 0, 1)
+}
+
+// ClosestPointOnTriangle updates vector v to be the point on, or inside,
+// the triangle a, b, c that is closest to point p. Vector v may be used
+// as one of the input vectors. The updated vector v is returned.
+func (v *V3) ClosestPointOnTriangle(p, a, b, c *V3) *V3 {
+	abx, aby, abz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	acx, acy, acz := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+	dot := func(x0, y0, z0, x1, y1, z1 float64) float64 { return x0*x1 + y0*y1 + z0*z1 }
+
+	// vertex region outside a.
+	apx, apy, apz := p.X-a.X, p.Y-a.Y, p.Z-a.Z
+	d1, d2 := dot(abx, aby, abz, apx, apy, apz), dot(acx, acy, acz, apx, apy, apz)
+	if d1 <= 0 && d2 <= 0 {
+		return v.Set(a)
+	}
+
+	// vertex region outside b.
+	bpx, bpy, bpz := p.X-b.X, p.Y-b.Y, p.Z-b.Z
+	d3, d4 := dot(abx, aby, abz, bpx, bpy, bpz), dot(acx, acy, acz, bpx, bpy, bpz)
+	if d3 >= 0 && d4 <= d3 {
+		return v.Set(b)
+	}
+
+	// edge region ab.
+	if vc := d1*d4 - d3*d2; vc <= 0 && d1 >= 0 && d3 <= 0 {
+		t := d1 / (d1 - d3)
+		return v.SetS(a.X+abx*t, a.Y+aby*t, a.Z+abz*t)
+	}
+
+	// vertex region outside c.
+	cpx, cpy, cpz := p.X-c.X, p.Y-c.Y, p.Z-c.Z
+	d5, d6 := dot(abx, aby, abz, cpx, cpy, cpz), dot(acx, acy, acz, cpx, cpy, cpz)
+	if d6 >= 0 && d5 <= d6 {
+		return v.Set(c)
+	}
+
+	// edge region ac.
+	if vb := d5*d2 - d1*d6; vb <= 0 && d2 >= 0 && d6 <= 0 {
+		t := d2 / (d2 - d6)
+		return v.SetS(a.X+acx*t, a.Y+acy*t, a.Z+acz*t)
+	}
+
+	// edge region bc.
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		t := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return v.SetS(b.X+(c.X-b.X)*t, b.Y+(c.Y-b.Y)*t, b.Z+(c.Z-b.Z)*t)
+	}
+
+	// inside the triangle.
+	vb, vc := d5*d2-d1*d6, d1*d4-d3*d2
+	denom := 1 / (va + vb + vc)
+	s, t := vb*denom, vc*denom
+	return v.SetS(a.X+abx*s+acx*t, a.Y+aby*s+acy*t, a.Z+abz*s+acz*t)
+}
+
+// ClosestPointOnAABB updates vector v to be the point on, or inside,
+// the axis aligned box with corners min and max that is closest to
+// point p. Vector v may be used as one of the input vectors.
+// The updated vector v is returned.
+func (v *V3) ClosestPointOnAABB(p, min, max *V3) *V3 {
+	return v.SetS(Clamp(p.X, min.X, max.X), Clamp(p.Y, min.Y, max.Y), Clamp(p.Z, min.Z, max.Z))
+}
+
+// Barycentric returns the barycentric coordinates u, v, w of point p
+// with respect to the triangle a, b, c, where p = u*a + v*b + w*c
+// and u + v + w = 1. Points that are not on the triangle plane are
+// projected onto the plane. Returns ok false, and zero coordinates,
+// for degenerate triangles that have no area.
+func Barycentric(p, a, b, c *V3) (u, v, w float64, ok bool) {
+	v0x, v0y, v0z := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	v1x, v1y, v1z := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+	v2x, v2y, v2z := p.X-a.X, p.Y-a.Y, p.Z-a.Z
+	d00 := v0x*v0x + v0y*v0y + v0z*v0z
+	d01 := v0x*v1x + v0y*v1y + v0z*v1z
+	d11 := v1x*v1x + v1y*v1y + v1z*v1z
+	d20 := v2x*v0x + v2y*v0y + v2z*v0z
+	d21 := v2x*v1x + v2y*v1y + v2z*v1z
+	denom := d00*d11 - d01*d01
+	if denom <= Epsilon*Epsilon*d00*d11 {
+		return 0, 0, 0, false
+	}
+	v = (d11*d20 - d01*d21) / denom
+	w = (d00*d21 - d01*d20) / denom
+	return 1 - v - w, v, w, true
+}
+
+// PointInTriangle returns true if point p, projected onto the plane of
+// the triangle a, b, c, is inside or on the edge of the triangle.
+// Degenerate triangles contain no points.
+func PointInTriangle(p, a, b, c *V3) bool {
+	u, v, w, ok := Barycentric(p, a, b, c)
+	return ok && u >= -Epsilon && v >= -Epsilon && w >= -Epsilon
+}
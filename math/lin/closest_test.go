@@ -0,0 +1,71 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+import (
+	"testing"
+)
+
+// go test -run Closest
+func TestClosest(t *testing.T) {
+	a, b, c := &V3{0, 0, 0}, &V3{2, 0, 0}, &V3{0, 2, 0}
+	t.Run("segment", func(t *testing.T) {
+		v := &V3{}
+		if !v.ClosestPointOnSegment(&V3{1, 5, 0}, a, b).Aeq(&V3{1, 0, 0}) {
+			t.Errorf("expected middle of segment got %s", v.Dump())
+		}
+		if !v.ClosestPointOnSegment(&V3{-3, 1, 0}, a, b).Aeq(a) {
+			t.Errorf("expected segment start got %s", v.Dump())
+		}
+		if f := SegmentFraction(&V3{9, 0, 0}, a, b); f != 1 {
+			t.Errorf("expected segment end got %f", f)
+		}
+		if f := SegmentFraction(b, a, a); f != 0 {
+			t.Errorf("expected degenerate segment start got %f", f)
+		}
+	})
+	t.Run("triangle", func(t *testing.T) {
+		v := &V3{}
+		tests := []struct{ p, want V3 }{
+			{V3{0.5, 0.5, 3}, V3{0.5, 0.5, 0}}, // inside, above.
+			{V3{-1, -1, 0}, V3{0, 0, 0}},       // vertex a.
+			{V3{3, -1, 0}, V3{2, 0, 0}},        // vertex b.
+			{V3{-1, 3, 1}, V3{0, 2, 0}},        // vertex c.
+			{V3{1, -1, 0}, V3{1, 0, 0}},        // edge ab.
+			{V3{-1, 1, 0}, V3{0, 1, 0}},        // edge ac.
+			{V3{2, 2, -1}, V3{1, 1, 0}},        // edge bc.
+		}
+		for _, tc := range tests {
+			if !v.ClosestPointOnTriangle(&tc.p, a, b, c).Aeq(&tc.want) {
+				t.Errorf("%s expected %s got %s", tc.p.Dump(), tc.want.Dump(), v.Dump())
+			}
+		}
+		p := &V3{2, 2, 0}
+		if !p.ClosestPointOnTriangle(p, a, b, c).Aeq(&V3{1, 1, 0}) {
+			t.Errorf("expected input vector to be reusable got %s", p.Dump())
+		}
+	})
+	t.Run("aabb", func(t *testing.T) {
+		v := &V3{}
+		if !v.ClosestPointOnAABB(&V3{5, 0.5, -5}, &V3{-1, -1, -1}, &V3{1, 1, 1}).Aeq(&V3{1, 0.5, -1}) {
+			t.Errorf("expected clamped point got %s", v.Dump())
+		}
+	})
+	t.Run("barycentric", func(t *testing.T) {
+		u, v, w, ok := Barycentric(&V3{0.5, 1, 4}, a, b, c)
+		if !ok || !Aeq(u, 0.25) || !Aeq(v, 0.25) || !Aeq(w, 0.5) {
+			t.Errorf("expected projected coordinates got %f %f %f", u, v, w)
+		}
+		if _, _, _, ok := Barycentric(a, a, b, &V3{4, 0, 0}); ok {
+			t.Errorf("expected degenerate triangle")
+		}
+	})
+	t.Run("inside", func(t *testing.T) {
+		if !PointInTriangle(&V3{0.5, 0.5, 1}, a, b, c) || !PointInTriangle(&V3{1, 1, 0}, a, b, c) {
+			t.Errorf("expected point inside triangle")
+		}
+		if PointInTriangle(&V3{1.5, 1.5, 0}, a, b, c) || PointInTriangle(a, a, a, a) {
+			t.Errorf("expected point outside triangle")
+		}
+	})
+}
@@ -623,6 +623,67 @@ func (m *M3) Inv(a *M3) *M3 {
 	return m
 }
 
+// Det returns the determinant of matrix m. The matrix has an inverse
+// exactly when the determinant is nonzero.
+func (m *M4) Det() float64 {
+	s0, s1, s2, s3, s4, s5, c0, c1, c2, c3, c4, c5 := m.minors()
+	return s0*c5 - s1*c4 + s2*c3 + s3*c2 - s4*c1 + s5*c0
+}
+
+// Inv updates m to be the inverse of matrix a. Useful for matrices
+// without a known inverse, eg: custom projection matrices.
+// The updated matrix m is returned.
+// Matrix m is not updated if the matrix has no inverse.
+func (m *M4) Inv(a *M4) *M4 {
+	s0, s1, s2, s3, s4, s5, c0, c1, c2, c3, c4, c5 := a.minors()
+	det := s0*c5 - s1*c4 + s2*c3 + s3*c2 - s4*c1 + s5*c0
+	if det == 0 {
+		return m
+	}
+	d := 1 / det
+	xx := (a.Yy*c5 - a.Yz*c4 + a.Yw*c3) * d
+	xy := (-a.Xy*c5 + a.Xz*c4 - a.Xw*c3) * d
+	xz := (a.Wy*s5 - a.Wz*s4 + a.Ww*s3) * d
+	xw := (-a.Zy*s5 + a.Zz*s4 - a.Zw*s3) * d
+	yx := (-a.Yx*c5 + a.Yz*c2 - a.Yw*c1) * d
+	yy := (a.Xx*c5 - a.Xz*c2 + a.Xw*c1) * d
+	yz := (-a.Wx*s5 + a.Wz*s2 - a.Ww*s1) * d
+	yw := (a.Zx*s5 - a.Zz*s2 + a.Zw*s1) * d
+	zx := (a.Yx*c4 - a.Yy*c2 + a.Yw*c0) * d
+	zy := (-a.Xx*c4 + a.Xy*c2 - a.Xw*c0) * d
+	zz := (a.Wx*s4 - a.Wy*s2 + a.Ww*s0) * d
+	zw := (-a.Zx*s4 + a.Zy*s2 - a.Zw*s0) * d
+	wx := (-a.Yx*c3 + a.Yy*c1 - a.Yz*c0) * d
+	wy := (a.Xx*c3 - a.Xy*c1 + a.Xz*c0) * d
+	wz := (-a.Wx*s3 + a.Wy*s1 - a.Wz*s0) * d
+	ww := (a.Zx*s3 - a.Zy*s1 + a.Zz*s0) * d
+	m.Xx, m.Xy, m.Xz, m.Xw = xx, xy, xz, xw
+	m.Yx, m.Yy, m.Yz, m.Yw = yx, yy, yz, yw
+	m.Zx, m.Zy, m.Zz, m.Zw = zx, zy, zz, zw
+	m.Wx, m.Wy, m.Wz, m.Ww = wx, wy, wz, ww
+	return m
+}
+
+// minors returns the 2x2 determinants of the top two rows (s) and
+// the bottom two rows (c) used to find the determinant and inverse.
+// Based on the Laplace expansion described in "The Laplace Expansion
+// Theorem: Computing the Determinants and Inverses of Matrices", Eberly.
+func (m *M4) minors() (s0, s1, s2, s3, s4, s5, c0, c1, c2, c3, c4, c5 float64) {
+	s0 = m.Xx*m.Yy - m.Yx*m.Xy
+	s1 = m.Xx*m.Yz - m.Yx*m.Xz
+	s2 = m.Xx*m.Yw - m.Yx*m.Xw
+	s3 = m.Xy*m.Yz - m.Yy*m.Xz
+	s4 = m.Xy*m.Yw - m.Yy*m.Xw
+	s5 = m.Xz*m.Yw - m.Yz*m.Xw
+	c5 = m.Zz*m.Ww - m.Wz*m.Zw
+	c4 = m.Zy*m.Ww - m.Wy*m.Zw
+	c3 = m.Zy*m.Wz - m.Wy*m.Zz
+	c2 = m.Zx*m.Ww - m.Wx*m.Zw
+	c1 = m.Zx*m.Wz - m.Wx*m.Zz
+	c0 = m.Zx*m.Wy - m.Wx*m.Zy
+	return
+}
+
 // ============================================================================
 // convenience functions for allocating matrices. Nothing else should allocate.
 
@@ -745,3 +806,79 @@ func (m *M4) PerspectiveInverse(fov, aspect, near, far float64) *M4 {
 	m.Ww = far / (near * far)
 	return m
 }
+
+// OrthographicInverse sets matrix m to be the inverse of the matching
+// OrthographicProjection. Used to go from clip space back to view space,
+// eg: when creating a picking ray from a mouse location.
+func (m *M4) OrthographicInverse(left, right, bottom, top, near, far float64) *M4 {
+	m.Xx = (right - left) / 2
+	m.Xy = 0
+	m.Xz = 0
+	m.Xw = 0
+	m.Yx = 0
+	m.Yy = (top - bottom) / 2
+	m.Yz = 0
+	m.Yw = 0
+	m.Zx = 0
+	m.Zy = 0
+	m.Zz = -(far - near)
+	m.Zw = 0
+	m.Wx = (right + left) / 2
+	m.Wy = (top + bottom) / 2
+	m.Wz = -near
+	m.Ww = 1
+	return m
+}
+
+// FrustumProjection for Vulkan sets matrix m with values needed to
+// convert a view vector to clip space using a perspective frustum
+// that need not be centered on the z-axis, eg: for off-center views
+// or tiled displays. The input arguments are:
+//
+//	left, right   The frustum x extents on the near plane.
+//	bottom, top   The frustum y extents on the near plane.
+//	near, far     The depth clipping planes.
+//
+// Matches PerspectiveProjection when the frustum is centered.
+func (m *M4) FrustumProjection(left, right, bottom, top, near, far float64) *M4 {
+	m.Xx = 2 * near / (right - left)
+	m.Xy = 0
+	m.Xz = 0
+	m.Xw = 0
+	m.Yx = 0
+	m.Yy = -2 * near / (top - bottom)
+	m.Yz = 0
+	m.Yw = 0
+	m.Zx = (right + left) / (right - left)
+	m.Zy = -(top + bottom) / (top - bottom)
+	m.Zz = far / (near - far)
+	m.Zw = -1.0
+	m.Wx = 0
+	m.Wy = 0
+	m.Wz = -(far * near) / (far - near)
+	m.Ww = 0
+	return m
+}
+
+// FrustumInverse sets matrix m to be the inverse of the matching
+// FrustumProjection. Used to go from clip space back to view space,
+// eg: when creating a picking ray from a mouse location.
+func (m *M4) FrustumInverse(left, right, bottom, top, near, far float64) *M4 {
+	m.Xx = (right - left) / (2 * near)
+	m.Xy = 0
+	m.Xz = 0
+	m.Xw = 0
+	m.Yx = 0
+	m.Yy = -(top - bottom) / (2 * near)
+	m.Yz = 0
+	m.Yw = 0
+	m.Zx = 0
+	m.Zy = 0
+	m.Zz = 0
+	m.Zw = (near - far) / (near * far)
+	m.Wx = (right + left) / (2 * near)
+	m.Wy = (top + bottom) / (2 * near)
+	m.Wz = -1.0
+	m.Ww = 1 / near
+	return m
+}
@@ -359,6 +359,47 @@ func TestPerspectiveInverse(t *testing.T) {
 	}
 }
 
+// multiplying the orthographic and frustum projections by their
+// inverses should result in the identity matrix.
+func TestProjectionInverses(t *testing.T) {
+	mpro, minv := &M4{}, &M4{}
+	mpro.OrthographicProjection(-3, 5, -1, 2, 0.5, 20)
+	minv.OrthographicInverse(-3, 5, -1, 2, 0.5, 20)
+	if !mpro.Mult(mpro, minv).Aeq(M4I) {
+		t.Errorf("Orthographic wanted identity got\n%s", mpro.Dump())
+	}
+	mpro.FrustumProjection(-1, 1, -1, 1, 1, 2)
+	if want := NewM4().PerspectiveProjection(90, 1, 1, 2); !want.Aeq(mpro) {
+		t.Errorf("Centered frustum got\n%s wanted\n%s", mpro.Dump(), want.Dump())
+	}
+	mpro.FrustumProjection(-0.2, 0.6, -0.1, 0.3, 0.25, 100)
+	minv.FrustumInverse(-0.2, 0.6, -0.1, 0.3, 0.25, 100)
+	if !mpro.Mult(mpro, minv).Aeq(M4I) {
+		t.Errorf("Frustum wanted identity got\n%s", mpro.Dump())
+	}
+}
+
+// multiplying a matrix by its general inverse should result
+// in the identity matrix.
+func TestInverseM4(t *testing.T) {
+	m := &M4{
+		2, 0, 0, 0,
+		1, 3, 0, 0,
+		4, 1, 4, 0,
+		1, 7, 2, 5}
+	if det := m.Det(); det != 120 {
+		t.Errorf("expected triangular determinant 120 got %f", det)
+	}
+	m.Xz, m.Yw = 1, 2 // no longer triangular.
+	if inv := NewM4().Inv(m); !inv.Mult(m, inv).Aeq(M4I) {
+		t.Errorf("Wanted identity got\n%s", inv.Dump())
+	}
+	m.Set(&M4{Xx: 1, Yx: 1}) // singular matrix
+	if inv := NewM4I().Inv(m); !inv.Eq(M4I) {
+		t.Errorf("expected singular matrix to be ignored")
+	}
+}
+
 // unit tests
 // ============================================================================
 // benchmarking.
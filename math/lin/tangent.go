@@ -0,0 +1,155 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+// tangent.go calculates tangent space for normal mapping. Tangents
+// follow the glTF and MikkTSpace conventions where the tangent points
+// along increasing u, and the bitangent is calculated in the shader as:
+//
+//	bitangent = cross(normal, tangent.xyz) * tangent.w
+
+import "math"
+
+// TriangleTangent sets t and b to the unnormalized tangent and bitangent
+// of the triangle with corners p0, p1, p2 and texture coordinates uv0,
+// uv1, uv2. Only the X and Y of the texture coordinates are used.
+// Returns false, leaving t and b unchanged, if the texture coordinates
+// do not cover any area.
+func TriangleTangent(p0, p1, p2, uv0, uv1, uv2 *V3, t, b *V3) bool {
+	e1x, e1y, e1z := p1.X-p0.X, p1.Y-p0.Y, p1.Z-p0.Z
+	e2x, e2y, e2z := p2.X-p0.X, p2.Y-p0.Y, p2.Z-p0.Z
+	du1, dv1 := uv1.X-uv0.X, uv1.Y-uv0.Y
+	du2, dv2 := uv2.X-uv0.X, uv2.Y-uv0.Y
+	det := du1*dv2 - du2*dv1
+	if math.Abs(det) < Epsilon*Epsilon {
+		return false
+	}
+	r := 1 / det
+	t.SetS((e1x*dv2-e2x*dv1)*r, (e1y*dv2-e2y*dv1)*r, (e1z*dv2-e2z*dv1)*r)
+	b.SetS((e2x*du1-e1x*du2)*r, (e2y*du1-e1y*du2)*r, (e2z*du1-e1z*du2)*r)
+	return true
+}
+
+// TangentSign returns the handedness, 1 or -1, of the tangent space
+// given by normal n, tangent t, and bitangent b. A negative handedness
+// means the texture is mirrored. The sign is stored in the tangent w.
+func TangentSign(n, t, b *V3) float64 {
+	x, y, z := n.Y*t.Z-n.Z*t.Y, n.Z*t.X-n.X*t.Z, n.X*t.Y-n.Y*t.X
+	if x*b.X+y*b.Y+z*b.Z < 0 {
+		return -1
+	}
+	return 1
+}
+
+// TangentMode selects how Tangents combines the triangle tangents.
+type TangentMode int
+
+const (
+	// TangentArea adds the triangle tangents of each vertex so that
+	// larger triangles have more influence. Fast, and good for
+	// procedural meshes with normal maps that are not baked.
+	TangentArea TangentMode = iota
+
+	// TangentMikk follows the MikkTSpace ordering used by Blender and
+	// most normal map bakers: triangle tangents are projected onto the
+	// vertex normal plane, normalized, and weighted by the triangle
+	// corner angle, and vertexes with the same position, normal, and
+	// texture coordinate share tangents even if they are not shared by
+	// index. Use it to match normal maps baked with MikkTSpace.
+	TangentMikk
+)
+
+// Tangents returns the per-vertex tangents for an indexed triangle mesh.
+// Positions and normals are vec3, texture coordinates are vec2, and each
+// group of three indexes is a triangle. The tangents are vec4 unit
+// vectors, orthogonal to the vertex normal, with the handedness in w.
+// The tangents can be uploaded as the mesh tangents vertex data.
+// Vertexes that are not part of a textured triangle are given an
+// arbitrary tangent that is orthogonal to the normal.
+func Tangents[I uint16 | uint32](positions, normals, uvs []float32, indexes []I, mode TangentMode) []float32 {
+	count := len(positions) / 3
+
+	// vertexes that share tangents. Normally each vertex is its own group.
+	groups := make([]int, count)
+	for i := range groups {
+		groups[i] = i
+	}
+	if mode == TangentMikk {
+		welded := map[[8]float32]int{}
+		for i := range groups {
+			key := [8]float32{
+				positions[i*3], positions[i*3+1], positions[i*3+2],
+				normals[i*3], normals[i*3+1], normals[i*3+2],
+				uvs[i*2], uvs[i*2+1],
+			}
+			if group, ok := welded[key]; ok {
+				groups[i] = group
+				continue
+			}
+			welded[key] = i
+		}
+	}
+
+	// add the triangle tangents to each triangle corner vertex group.
+	tsum := make([]V3, count) // accumulated tangents.
+	bsum := make([]V3, count) // accumulated bitangents.
+	p, uv, n := [3]V3{}, [3]V3{}, &V3{}
+	t, b, ct, cb, e1, e2 := &V3{}, &V3{}, &V3{}, &V3{}, &V3{}, &V3{}
+	for f := 0; f+2 < len(indexes); f += 3 {
+		corners := [3]int{int(indexes[f]), int(indexes[f+1]), int(indexes[f+2])}
+		for c, i := range corners {
+			p[c].SetS(float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+			uv[c].SetS(float64(uvs[i*2]), float64(uvs[i*2+1]), 0)
+		}
+		if !TriangleTangent(&p[0], &p[1], &p[2], &uv[0], &uv[1], &uv[2], t, b) {
+			continue // no texture mapping.
+		}
+		for c, i := range corners {
+			g := groups[i]
+			if mode != TangentMikk {
+				tsum[g].Add(&tsum[g], t)
+				bsum[g].Add(&bsum[g], b)
+				continue
+			}
+			n.SetS(float64(normals[i*3]), float64(normals[i*3+1]), float64(normals[i*3+2])).Unit()
+			e1.Sub(&p[(c+1)%3], &p[c]).Unit()
+			e2.Sub(&p[(c+2)%3], &p[c]).Unit()
+			angle := math.Acos(Clamp(e1.Dot(e2), -1, 1))
+			tsum[g].Add(&tsum[g], ct.reject(t, n).Unit().Scale(ct, angle))
+			bsum[g].Add(&bsum[g], cb.reject(b, n).Unit().Scale(cb, angle))
+		}
+	}
+
+	// orthogonalize each vertex tangent to the vertex normal.
+	tangents := make([]float32, count*4)
+	for i := 0; i < count; i++ {
+		g := groups[i]
+		n.SetS(float64(normals[i*3]), float64(normals[i*3+1]), float64(normals[i*3+2])).Unit()
+		t.reject(&tsum[g], n)
+		if l := t.Len(); l == 0 || l < Epsilon*tsum[g].Len() {
+			t.perpendicular(n) // untextured or degenerate vertex.
+		}
+		t.Unit()
+		tangents[i*4] = float32(t.X)
+		tangents[i*4+1] = float32(t.Y)
+		tangents[i*4+2] = float32(t.Z)
+		tangents[i*4+3] = float32(TangentSign(n, t, &bsum[g]))
+	}
+	return tangents
+}
+
+// reject sets v to vector a with the part that is parallel to
+// the unit vector n removed. The updated vector v is returned.
+func (v *V3) reject(a, n *V3) *V3 {
+	d := a.Dot(n)
+	return v.SetS(a.X-n.X*d, a.Y-n.Y*d, a.Z-n.Z*d)
+}
+
+// perpendicular sets v to a vector that is perpendicular to
+// the unit vector n. The updated vector v is returned.
+func (v *V3) perpendicular(n *V3) *V3 {
+	if math.Abs(n.X) < 0.9 {
+		return v.Cross(n, &V3{X: 1}) // use the x-axis.
+	}
+	return v.Cross(n, &V3{Y: 1}) // n is close to the x-axis.
+}
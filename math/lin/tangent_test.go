@@ -0,0 +1,76 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+import "testing"
+
+// go test -run Tangent
+func TestTangent(t *testing.T) {
+	// unit quad in the XY plane facing +Z.
+	positions := []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}
+	normals := []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}
+	indexes := []uint16{0, 1, 2, 0, 2, 3}
+
+	t.Run("triangle", func(t *testing.T) {
+		tan, bit := &V3{}, &V3{}
+		p0, p1, p2 := &V3{}, &V3{X: 2}, &V3{Y: 4}
+		uv0, uv1, uv2 := &V3{}, &V3{X: 1}, &V3{Y: 1}
+		if !TriangleTangent(p0, p1, p2, uv0, uv1, uv2, tan, bit) {
+			t.Fatalf("expected a tangent")
+		}
+		if !tan.Aeq(&V3{X: 2}) || !bit.Aeq(&V3{Y: 4}) {
+			t.Errorf("expected scaled tangents got %v %v", tan, bit)
+		}
+		if TriangleTangent(p0, p1, p2, uv0, uv0, uv0, tan, bit) {
+			t.Errorf("expected degenerate texture coordinates")
+		}
+	})
+	t.Run("handedness", func(t *testing.T) {
+		n, tan := &V3{Z: 1}, &V3{X: 1}
+		if TangentSign(n, tan, &V3{Y: 1}) != 1 || TangentSign(n, tan, &V3{Y: -1}) != -1 {
+			t.Errorf("expected right and left handed tangent space")
+		}
+	})
+	for _, mode := range []TangentMode{TangentArea, TangentMikk} {
+		t.Run("quad", func(t *testing.T) {
+			uvs := []float32{0, 0, 1, 0, 1, 1, 0, 1}
+			tangents := Tangents(positions, normals, uvs, indexes, mode)
+			for i := 0; i < 4; i++ {
+				if got := tangents[i*4 : i*4+4]; got[0] != 1 || got[1] != 0 || got[3] != 1 {
+					t.Errorf("mode %d vertex %d expected +x tangent got %v", mode, i, got)
+				}
+			}
+		})
+		t.Run("mirrored", func(t *testing.T) {
+			uvs := []float32{1, 0, 0, 0, 0, 1, 1, 1} // u increases along -x.
+			tangents := Tangents(positions, normals, uvs, []uint32{0, 1, 2, 0, 2, 3}, mode)
+			if got := tangents[:4]; got[0] != -1 || got[3] != -1 {
+				t.Errorf("mode %d expected mirrored tangent got %v", mode, got)
+			}
+		})
+	}
+	t.Run("welded", func(t *testing.T) {
+		// two triangles with unshared but identical first vertexes,
+		// the second texture is rotated so that its tangent differs.
+		positions := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, -1, 0, 0}
+		normals := []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}
+		uvs := []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}
+		indexes := []uint16{0, 1, 2, 3, 4, 5}
+		area := Tangents(positions, normals, uvs, indexes, TangentArea)
+		mikk := Tangents(positions, normals, uvs, indexes, TangentMikk)
+		if mikk[0] != mikk[12] || mikk[1] != mikk[13] {
+			t.Errorf("expected welded vertexes to share tangents %v", mikk)
+		}
+		if area[0] == area[12] && area[1] == area[13] && area[2] == area[14] {
+			t.Errorf("expected unwelded vertexes %v", area)
+		}
+	})
+	t.Run("untextured", func(t *testing.T) {
+		uvs := make([]float32, 8)
+		tangents := Tangents(positions, normals, uvs, indexes, TangentArea)
+		tan := &V3{X: float64(tangents[0]), Y: float64(tangents[1]), Z: float64(tangents[2])}
+		if !Aeq(tan.Len(), 1) || !AeqZ(tan.Dot(&V3{Z: 1})) {
+			t.Errorf("expected a tangent perpendicular to the normal %v", tan)
+		}
+	})
+}
@@ -0,0 +1,307 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+// vectori.go performs 2 or 3 element integer vector math for grid,
+// chunk, and voxel coordinates. Integer vectors are comparable so they
+// can be used directly as map keys, eg: map[lin.V3i]*Chunk, avoiding
+// the precision problems of float keys far from the origin.
+
+import "math"
+
+// V2i is a 2 element integer vector, eg: a 2D grid cell.
+type V2i struct {
+	X int64
+	Y int64
+}
+
+// V3i is a 3 element integer vector, eg: a chunk or voxel coordinate.
+type V3i struct {
+	X int64
+	Y int64
+	Z int64
+}
+
+// Eq (==) returns true if each element in vector v has the same value
+// as the corresponding element in vector a.
+func (v *V2i) Eq(a *V2i) bool { return v.X == a.X && v.Y == a.Y }
+
+// Eq (==) returns true if each element in vector v has the same value
+// as the corresponding element in vector a.
+func (v *V3i) Eq(a *V3i) bool { return v.X == a.X && v.Y == a.Y && v.Z == a.Z }
+
+// GetS returns the individual vector values.
+func (v *V2i) GetS() (x, y int64) { return v.X, v.Y }
+
+// GetS returns the individual vector values.
+func (v *V3i) GetS() (x, y, z int64) { return v.X, v.Y, v.Z }
+
+// SetS (=) explicitly sets vector v using the given element values.
+// The updated vector v is returned.
+func (v *V2i) SetS(x, y int64) *V2i {
+	v.X, v.Y = x, y
+	return v
+}
+
+// SetS (=) explicitly sets vector v using the given element values.
+// The updated vector v is returned.
+func (v *V3i) SetS(x, y, z int64) *V3i {
+	v.X, v.Y, v.Z = x, y, z
+	return v
+}
+
+// Set (=) assigns all the elements values from vector a to the
+// corresponding element values in vector v. The updated vector v is returned.
+func (v *V2i) Set(a *V2i) *V2i {
+	v.X, v.Y = a.X, a.Y
+	return v
+}
+
+// Set (=) assigns all the elements values from vector a to the
+// corresponding element values in vector v. The updated vector v is returned.
+func (v *V3i) Set(a *V3i) *V3i {
+	v.X, v.Y, v.Z = a.X, a.Y, a.Z
+	return v
+}
+
+// Add (+) adds vectors a and b storing the results of the addition in v.
+// Vector v may be used as one or both of the parameters.
+// The updated vector v is returned.
+func (v *V2i) Add(a, b *V2i) *V2i {
+	v.X, v.Y = a.X+b.X, a.Y+b.Y
+	return v
+}
+
+// Add (+) adds vectors a and b storing the results of the addition in v.
+// Vector v may be used as one or both of the parameters.
+// The updated vector v is returned.
+func (v *V3i) Add(a, b *V3i) *V3i {
+	v.X, v.Y, v.Z = a.X+b.X, a.Y+b.Y, a.Z+b.Z
+	return v
+}
+
+// Sub (-) subtracts vector b from a storing the results in v.
+// Vector v may be used as one or both of the parameters.
+// The updated vector v is returned.
+func (v *V2i) Sub(a, b *V2i) *V2i {
+	v.X, v.Y = a.X-b.X, a.Y-b.Y
+	return v
+}
+
+// Sub (-) subtracts vector b from a storing the results in v.
+// Vector v may be used as one or both of the parameters.
+// The updated vector v is returned.
+func (v *V3i) Sub(a, b *V3i) *V3i {
+	v.X, v.Y, v.Z = a.X-b.X, a.Y-b.Y, a.Z-b.Z
+	return v
+}
+
+// Mult (*) multiplies the corresponding elements of vectors a and b
+// storing the results in v. Vector v may be used as one or both of the
+// parameters. The updated vector v is returned.
+func (v *V2i) Mult(a, b *V2i) *V2i {
+	v.X, v.Y = a.X*b.X, a.Y*b.Y
+	return v
+}
+
+// Mult (*) multiplies the corresponding elements of vectors a and b
+// storing the results in v. Vector v may be used as one or both of the
+// parameters. The updated vector v is returned.
+func (v *V3i) Mult(a, b *V3i) *V3i {
+	v.X, v.Y, v.Z = a.X*b.X, a.Y*b.Y, a.Z*b.Z
+	return v
+}
+
+// Scale (*=) updates vector v to be vector a with each element
+// multiplied by s. The updated vector v is returned.
+func (v *V2i) Scale(a *V2i, s int64) *V2i {
+	v.X, v.Y = a.X*s, a.Y*s
+	return v
+}
+
+// Scale (*=) updates vector v to be vector a with each element
+// multiplied by s. The updated vector v is returned.
+func (v *V3i) Scale(a *V3i, s int64) *V3i {
+	v.X, v.Y, v.Z = a.X*s, a.Y*s, a.Z*s
+	return v
+}
+
+// Div (/) updates vector v to be vector a with each element divided
+// by s, rounding towards negative infinity. Floored division maps
+// coordinates to the cell that contains them, eg: voxel -1 is in
+// chunk -1, not chunk 0. The updated vector v is returned.
+func (v *V2i) Div(a *V2i, s int64) *V2i {
+	v.X, v.Y = floorDiv(a.X, s), floorDiv(a.Y, s)
+	return v
+}
+
+// Div (/) updates vector v to be vector a with each element divided
+// by s, rounding towards negative infinity. Floored division maps
+// coordinates to the cell that contains them, eg: voxel -1 is in
+// chunk -1, not chunk 0. The updated vector v is returned.
+func (v *V3i) Div(a *V3i, s int64) *V3i {
+	v.X, v.Y, v.Z = floorDiv(a.X, s), floorDiv(a.Y, s), floorDiv(a.Z, s)
+	return v
+}
+
+// Mod (%) updates vector v to be the floored remainder of each element
+// of vector a divided by s. The results are between 0 and s-1 for a
+// positive s, eg: the location of a voxel inside its chunk.
+// The updated vector v is returned.
+func (v *V2i) Mod(a *V2i, s int64) *V2i {
+	v.X, v.Y = a.X-floorDiv(a.X, s)*s, a.Y-floorDiv(a.Y, s)*s
+	return v
+}
+
+// Mod (%) updates vector v to be the floored remainder of each element
+// of vector a divided by s. The results are between 0 and s-1 for a
+// positive s, eg: the location of a voxel inside its chunk.
+// The updated vector v is returned.
+func (v *V3i) Mod(a *V3i, s int64) *V3i {
+	v.X, v.Y, v.Z = a.X-floorDiv(a.X, s)*s, a.Y-floorDiv(a.Y, s)*s, a.Z-floorDiv(a.Z, s)*s
+	return v
+}
+
+// floorDiv divides a by b rounding towards negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// Neg (-) sets vector v to be the negative values of vector a.
+// Vector v may be used as the input parameter.
+// The updated vector v is returned.
+func (v *V2i) Neg(a *V2i) *V2i {
+	v.X, v.Y = -a.X, -a.Y
+	return v
+}
+
+// Neg (-) sets vector v to be the negative values of vector a.
+// Vector v may be used as the input parameter.
+// The updated vector v is returned.
+func (v *V3i) Neg(a *V3i) *V3i {
+	v.X, v.Y, v.Z = -a.X, -a.Y, -a.Z
+	return v
+}
+
+// Min updates vector v so that each element in v is the minimum
+// of the corresponding elements in vectors a and b.
+// The updated vector v is returned.
+func (v *V2i) Min(a, b *V2i) *V2i {
+	v.X, v.Y = min(a.X, b.X), min(a.Y, b.Y)
+	return v
+}
+
+// Min updates vector v so that each element in v is the minimum
+// of the corresponding elements in vectors a and b.
+// The updated vector v is returned.
+func (v *V3i) Min(a, b *V3i) *V3i {
+	v.X, v.Y, v.Z = min(a.X, b.X), min(a.Y, b.Y), min(a.Z, b.Z)
+	return v
+}
+
+// Max updates vector v so that each element in v is the maximum
+// of the corresponding elements in vectors a and b.
+// The updated vector v is returned.
+func (v *V2i) Max(a, b *V2i) *V2i {
+	v.X, v.Y = max(a.X, b.X), max(a.Y, b.Y)
+	return v
+}
+
+// Max updates vector v so that each element in v is the maximum
+// of the corresponding elements in vectors a and b.
+// The updated vector v is returned.
+func (v *V3i) Max(a, b *V3i) *V3i {
+	v.X, v.Y, v.Z = max(a.X, b.X), max(a.Y, b.Y), max(a.Z, b.Z)
+	return v
+}
+
+// Dot vector v with input vector a. Both vectors v and a are unchanged.
+func (v *V2i) Dot(a *V2i) int64 { return v.X*a.X + v.Y*a.Y }
+
+// Dot vector v with input vector a. Both vectors v and a are unchanged.
+func (v *V3i) Dot(a *V3i) int64 { return v.X*a.X + v.Y*a.Y + v.Z*a.Z }
+
+// LenSqr returns the length of vector v squared.
+func (v *V2i) LenSqr() int64 { return v.Dot(v) }
+
+// LenSqr returns the length of vector v squared.
+func (v *V3i) LenSqr() int64 { return v.Dot(v) }
+
+// Manhattan returns the grid distance, the sum of the absolute element
+// differences, between vectors v and a.
+func (v *V2i) Manhattan(a *V2i) int64 { return absi(v.X-a.X) + absi(v.Y-a.Y) }
+
+// Manhattan returns the grid distance, the sum of the absolute element
+// differences, between vectors v and a.
+func (v *V3i) Manhattan(a *V3i) int64 {
+	return absi(v.X-a.X) + absi(v.Y-a.Y) + absi(v.Z-a.Z)
+}
+
+// absi returns the absolute value of x.
+func absi(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Hash returns a well mixed hash of vector v. Neighbouring vectors
+// have unrelated hashes, eg: for seeding or spatial hash tables.
+// The same vector always has the same hash.
+func (v *V2i) Hash() uint64 { return hashInts(v.X, v.Y) }
+
+// Hash returns a well mixed hash of vector v. Neighbouring vectors
+// have unrelated hashes, eg: for seeding or spatial hash tables.
+// The same vector always has the same hash.
+func (v *V3i) Hash() uint64 { return hashInts(v.X, v.Y, v.Z) }
+
+// hashInts combines the values using the splitmix64 finalizer.
+func hashInts(vals ...int64) (h uint64) {
+	for _, v := range vals {
+		h ^= uint64(v) + 0x9E3779B97F4A7C15 + (h << 6) + (h >> 2)
+		h ^= h >> 30
+		h *= 0xBF58476D1CE4E5B9
+		h ^= h >> 27
+		h *= 0x94D049BB133111EB
+		h ^= h >> 31
+	}
+	return h
+}
+
+// ============================================================================
+// integer and float vector conversions.
+
+// SetV3i updates vector v to be the float values of integer vector a.
+// The updated vector v is returned.
+func (v *V3) SetV3i(a *V3i) *V3 {
+	v.X, v.Y, v.Z = float64(a.X), float64(a.Y), float64(a.Z)
+	return v
+}
+
+// Floor updates vector v to be the given float values rounded down to
+// the containing integer grid cell, eg: -0.5 becomes -1. Use GetS to
+// floor a float vector, eg: v.Floor(a.GetS()).
+// The updated vector v is returned.
+func (v *V2i) Floor(x, y float64) *V2i {
+	v.X, v.Y = int64(math.Floor(x)), int64(math.Floor(y))
+	return v
+}
+
+// Floor updates vector v to be the given float values rounded down to
+// the containing integer grid cell, eg: -0.5 becomes -1. Use GetS to
+// floor a float vector, eg: v.Floor(a.GetS()).
+// The updated vector v is returned.
+func (v *V3i) Floor(x, y, z float64) *V3i {
+	v.X, v.Y, v.Z = int64(math.Floor(x)), int64(math.Floor(y)), int64(math.Floor(z))
+	return v
+}
+
+// GetF returns the vector values as floats.
+func (v *V2i) GetF() (x, y float64) { return float64(v.X), float64(v.Y) }
+
+// GetF returns the vector values as floats.
+func (v *V3i) GetF() (x, y, z float64) { return float64(v.X), float64(v.Y), float64(v.Z) }
@@ -0,0 +1,105 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package lin
+
+import (
+	"testing"
+)
+
+// go test -run Vectori
+func TestVectori(t *testing.T) {
+	t.Run("math", func(t *testing.T) {
+		a, b, v := &V3i{1, -2, 3}, &V3i{4, 5, -6}, &V3i{}
+		if v.Add(a, b); !v.Eq(&V3i{5, 3, -3}) {
+			t.Errorf("add got %+v", *v)
+		}
+		if v.Sub(a, b); !v.Eq(&V3i{-3, -7, 9}) {
+			t.Errorf("sub got %+v", *v)
+		}
+		if v.Mult(a, b).Scale(v, 2); !v.Eq(&V3i{8, -20, -36}) {
+			t.Errorf("mult scale got %+v", *v)
+		}
+		if v.Min(a, b); !v.Eq(&V3i{1, -2, -6}) {
+			t.Errorf("min got %+v", *v)
+		}
+		if v.Max(a, b); !v.Eq(&V3i{4, 5, 3}) {
+			t.Errorf("max got %+v", *v)
+		}
+		if d := a.Dot(b); d != -24 {
+			t.Errorf("dot got %d", d)
+		}
+		if d := a.Manhattan(b); d != 19 {
+			t.Errorf("manhattan got %d", d)
+		}
+
+		// 2 element vectors have the same methods.
+		a2, b2, v2 := &V2i{1, -2}, &V2i{4, 5}, &V2i{}
+		if v2.Add(a2, b2).Sub(v2, b2).Neg(v2); !v2.Eq(&V2i{-1, 2}) {
+			t.Errorf("add sub neg got %+v", *v2)
+		}
+		if v2.Min(a2, b2); !v2.Eq(&V2i{1, -2}) {
+			t.Errorf("min got %+v", *v2)
+		}
+		if v2.Max(a2, b2); !v2.Eq(&V2i{4, 5}) {
+			t.Errorf("max got %+v", *v2)
+		}
+		if d := a2.Dot(b2); d != -6 || a2.LenSqr() != 5 {
+			t.Errorf("dot got %d", d)
+		}
+		if d := a2.Manhattan(b2); d != 10 {
+			t.Errorf("manhattan got %d", d)
+		}
+	})
+	t.Run("floor div mod", func(t *testing.T) {
+		v, a := &V3i{}, &V3i{-1, 16, -17}
+		if v.Div(a, 16); !v.Eq(&V3i{-1, 1, -2}) {
+			t.Errorf("div got %+v", *v)
+		}
+		if v.Mod(a, 16); !v.Eq(&V3i{15, 0, 15}) {
+			t.Errorf("mod got %+v", *v)
+		}
+		v2 := &V2i{}
+		if v2.Div(&V2i{-32, 31}, 16); !v2.Eq(&V2i{-2, 1}) {
+			t.Errorf("div got %+v", *v2)
+		}
+	})
+	t.Run("map keys", func(t *testing.T) {
+		big := int64(1) << 53
+		chunks := map[V3i]int{{big, 0, 0}: 1, {big + 1, 0, 0}: 2}
+		if len(chunks) != 2 || chunks[V3i{big + 1, 0, 0}] != 2 {
+			t.Errorf("expected distinct keys for large coordinates")
+		}
+	})
+	t.Run("hash", func(t *testing.T) {
+		a, b := &V3i{1, 2, 3}, &V3i{1, 2, 3}
+		if a.Hash() != b.Hash() {
+			t.Errorf("expected equal vectors to have equal hashes")
+		}
+		seen := map[uint64]bool{}
+		for x := int64(-8); x < 8; x++ {
+			for y := int64(-8); y < 8; y++ {
+				seen[(&V3i{x, y, 0}).Hash()] = true
+				seen[(&V2i{x, y}).Hash()] = true
+			}
+		}
+		if len(seen) != 2*16*16 {
+			t.Errorf("expected unique hashes got %d", len(seen))
+		}
+	})
+	t.Run("convert", func(t *testing.T) {
+		v := &V3i{}
+		if v.Floor((&V3{-0.5, 1.5, -2}).GetS()); !v.Eq(&V3i{-1, 1, -2}) {
+			t.Errorf("floor got %+v", *v)
+		}
+		if f := (&V3{}).SetV3i(v); !f.Eq(&V3{-1, 1, -2}) {
+			t.Errorf("float got %s", f.Dump())
+		}
+		v2 := (&V2i{}).Floor(-0.1, 2.9)
+		if x, y := v2.GetF(); x != -1 || y != 2 {
+			t.Errorf("float got %f %f", x, y)
+		}
+		if x, y, z := v.GetF(); x != -1 || y != 1 || z != -2 {
+			t.Errorf("float got %f %f %f", x, y, z)
+		}
+	})
+}
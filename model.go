@@ -8,10 +8,10 @@ import (
 	"fmt"
 	"image"
 	"log/slog"
-	"math"
 	"strings"
 
 	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
 	"github.com/gazed/vu/render"
 )
 
@@ -31,6 +31,7 @@ func (e *Entity) AddModel(assets ...string) (me *Entity) {
 func (e *Entity) AddInstancedModel(assets ...string) (me *Entity) {
 	me = e.AddPart() // add a transform node
 	if mod := me.app.models.create(me); mod != nil {
+		mod.req = strings.Join(assets, ",")
 		mod.getAssets(me, assets...)
 		mod.isInstanced = true
 		mod.instanceCount = 0 // until SetInstanceData is called
@@ -41,11 +42,15 @@ func (e *Entity) AddInstancedModel(assets ...string) (me *Entity) {
 // SetInstanceData sets the instance data for an instanced model.
 func (e *Entity) SetInstanceData(eng *Engine, count uint32, data []load.Buffer) (me *Entity) {
 	if mod := e.app.models.get(e.eid); mod != nil && mod.isInstanced {
+		if mod.hasInstances {
+			e.app.ld.dropInstanceData(mod.instanceID) // replaced.
+		}
 		var err error
 		mod.instanceID, err = eng.rc.LoadInstanceData(data)
 		if err != nil {
 			slog.Error("SetInstanceData", "error", err)
 		}
+		mod.hasInstances = err == nil
 		mod.instanceCount = count
 		return e
 	}
@@ -108,6 +113,43 @@ func (e *Entity) SetMetallicRoughness(metallic bool, roughness float64) *Entity
 	return e
 }
 
+// SetColorKey makes the model texture pixels that match the given color
+// transparent, eg: the magenta background of older sprite sheets. Pixels
+// within tolerance, the distance between the colors, are also transparent.
+// Color values are 0-1. Used by the "sprite" and "palette" shaders.
+// A negative tolerance turns off the color key.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetColorKey(r, g, b, tolerance float64) *Entity {
+	if m := e.app.models.get(e.eid); m != nil {
+		m.uniforms[load.KEYCOLOR] = render.V4SToBytes(r, g, b, tolerance, m.uniforms[load.KEYCOLOR])
+		return e
+	}
+	slog.Error("SetColorKey needs AddModel", "eid", e.eid)
+	return e
+}
+
+// SetPaletteRow selects the palette texture row used to color the model
+// with the "palette" shader. The palette shader uses the red channel of
+// the "color" texture as an index into the selected row of the "palette"
+// texture, eg: each row is a team color or skin for the same sprite.
+// Both images are data and are marked using load.SetLinearImage before
+// they are imported so that the indexes are not gamma corrected.
+//
+//	load.SetLinearImage("knight.png", true)
+//	load.SetLinearImage("teams.png", true)
+//	knight := scene.AddModel("shd:palette", "msh:icon", "tex:color:knight", "tex:palette:teams")
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetPaletteRow(row int) *Entity {
+	if m := e.app.models.get(e.eid); m != nil {
+		m.uniforms[load.PALETTEROW] = render.V4SToBytes(float64(max(row, 0)), 0, 0, 0, m.uniforms[load.PALETTEROW])
+		return e
+	}
+	slog.Error("SetPaletteRow needs AddModel", "eid", e.eid)
+	return e
+}
+
 // SetModelUniform sets data for the given uniform. The uniform data is
 // passed to the shader.
 func (e *Entity) SetModelUniform(uniform string, data interface{}) *Entity {
@@ -140,42 +182,9 @@ func (e *Entity) AddUpdatableTexture(eng *Engine, name string, img *image.NRGBA)
 			slog.Error("AddUpdatableTexture already set", "eid", e.eid)
 			return e
 		}
-
-		// create 2 texture assets.
-		opaque := img.Opaque()
-		t1 := newTexture(name + "_a")
-		t1.opaque = opaque
-		t2 := newTexture(name + "_b")
-		t2.opaque = opaque
-		mod.updatable = []*texture{t1, t2}
-
-		// upload the initial texture to the GPU
-		var err error
-		idata := &load.ImageData{
-			Width:  uint32(img.Bounds().Size().X),
-			Height: uint32(img.Bounds().Size().Y),
-			Pixels: []byte(img.Pix),
-			Opaque: opaque,
-		}
-		t1.tid, err = eng.rc.LoadTexture(idata)
-		if err != nil {
-			slog.Error("AddUpdatableTexture upload1", "err", err)
-			return e
-		}
-		slog.Debug("model", "asset", "tex:"+t1.label(), "tid", t1.tid, "opaque", t1.opaque)
-		t2.tid, err = eng.rc.LoadTexture(idata)
-		if err != nil {
-			slog.Error("AddUpdatableTexture upload2", "err", err)
-			return e
+		if err := mod.addUpdatable(e.app, eng.rc, name, img); err != nil {
+			slog.Error("AddUpdatableTexture upload", "err", err)
 		}
-		slog.Debug("model", "asset", "tex:"+t2.label(), "tid", t2.tid, "opaque", t2.opaque)
-
-		// TODO fake this
-		// m.samplerMap[uniform] = name // remember uniform to texture mapping.
-		mod.samplerMap["color"] = t1.label()
-
-		// m.texs only takes one of the 2 textures.
-		mod.texs = []*texture{t1}
 		return e
 	}
 	slog.Error("AddUpdatableTexture needs AddModel", "eid", e.eid)
@@ -190,24 +199,9 @@ func (e *Entity) UpdateTexture(eng *Engine, img *image.NRGBA) *Entity {
 			slog.Error("UpdateTexture not set", "eid", e.eid)
 			return e
 		}
-
-		// update the uploadable texture,
-		t2 := mod.updatable[1] // updatable is always second
-		idata := &load.ImageData{
-			Width:  uint32(img.Bounds().Size().X),
-			Height: uint32(img.Bounds().Size().Y),
-			Pixels: []byte(img.Pix),
-			Opaque: img.Opaque(),
-		}
-		if err := eng.rc.UpdateTexture(t2.tid, idata); err != nil {
+		if err := mod.updateTexture(eng.rc, img); err != nil {
 			slog.Error("UpdateTexture update", "err", err)
-			return e
 		}
-
-		// swap textures and render the recently updated texture.
-		mod.updatable[0], mod.updatable[1] = mod.updatable[1], mod.updatable[0]
-		mod.texs[0] = mod.updatable[0] // always render the first after swap.
-		mod.samplerMap["color"] = mod.texs[0].label()
 		return e
 	}
 	slog.Error("UpdateTexture needs AddModel", "eid", e.eid)
@@ -217,6 +211,7 @@ func (e *Entity) UpdateTexture(eng *Engine, img *image.NRGBA) *Entity {
 // SetLayer helps to order draws - normally 2D UI elements.
 // Layer values are 0 (first) to 15 (last). Normally packets
 // are drawn in the creation order, but this allows specific ordering.
+// Render queues order the models within a layer, see SetRenderQueue.
 //
 // Depends on Entity.AddModel.
 func (e *Entity) SetLayer(layer uint8) *Entity {
@@ -228,7 +223,45 @@ func (e *Entity) SetLayer(layer uint8) *Entity {
 	return e
 }
 
+// RenderQueue orders model draws within a draw layer.
+type RenderQueue uint8
+
+// Render queues are drawn in the order listed. Opaque models are drawn
+// in the order created, grouped by shader. Transparent and overlay models
+// are drawn back to front using their depth in the camera view so that
+// alpha blending shows the models behind them.
+const (
+	QueueAuto        RenderQueue = iota // opaque or transparent from the model material.
+	QueueBackground                     // drawn first, eg: skydomes and backdrops.
+	QueueOpaque                         // solid models.
+	QueueTransparent                    // alpha blended models, back to front.
+	QueueOverlay                        // drawn last, back to front, eg: gizmos.
+)
+
+// SetRenderQueue sets when the model is drawn within its draw layer.
+// The default QueueAuto draws transparent models after opaque models,
+// where a model is transparent if its material color or a texture has
+// alpha values. Use QueueTransparent for shaders that blend without
+// transparent textures, or QueueOpaque for alpha tested cutouts.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetRenderQueue(queue RenderQueue) *Entity {
+	if m := e.app.models.get(e.eid); m != nil {
+		m.queue = queue
+		return e
+	}
+	slog.Error("SetRenderQueue needs AddModel", "eid", e.eid)
+	return e
+}
+
 // FUTURE: AddEffect(...) generate quad for particle effects in geometry stage.
+// FUTURE: optional screen-space particle collision, where particles bounce
+// or die on scene geometry, by reading the 3D pass depth buffer. Needs the
+// particle effects above plus render support that does not exist yet:
+//   - a depth image created with VK_IMAGE_USAGE_SAMPLED_BIT and a
+//     sampler so a later pass can read it.
+//   - a compute or vertex stage particle update that is given the
+//     depth texture and the inverse projection as scene uniforms.
 
 // =============================================================================
 // model data
@@ -274,17 +307,31 @@ type model struct {
 	isInstanced   bool   // default false.
 	instanceCount uint32 // default false.
 	instanceID    uint32 // render instance data ID.
+	hasInstances  bool   // true once instance data is loaded.
+
+	actor *actor // for an actorModel.
 
 	// FUTURE
-	// anim   *actor  // set for an animated model
 	// effect *effect // set for a particle effect
 
 	// generic uniforms set the app and passed to the shader.
 	uniforms map[load.PacketUniform][]byte
 
 	// packet bucket sort values.
-	tocam float64 // distance to camera helps with 3D render order.
-	layer uint8   // draw layer 0-15
+	tocam float64     // distance to camera helps with 3D render order.
+	depth float64     // view depth sorts 3D transparent models.
+	layer uint8       // draw layer 0-15
+	queue RenderQueue // draw order within a layer.
+
+	scope   uint8   // GPU profile scope, zero for the render pass scope.
+	occlude *lin.V3 // occlusion bounding box half extents, nil if not culled.
+
+	// noViewCull is true if the model is drawn when outside the camera view.
+	noViewCull bool
+
+	// level of detail meshes sorted by distance.
+	lods    []*lod  // replace the model mesh at a distance.
+	lodFade float64 // cross-fade distance, zero for no fading.
 }
 
 // newModel initializes the data structures and default uniforms.
@@ -315,7 +362,11 @@ func (m *model) getAssets(me *Entity, assets ...string) {
 				m.fntAID = assetID(fnt, name)
 				me.app.ld.getAsset(m.fntAID, me.eid, me.app.models.assetLoaded)
 			case "anm":
-				// TODO get animation bone data from GLB files.
+				m.mtype = actorModel
+				if m.actor == nil {
+					m.actor = newActor()
+				}
+				me.app.ld.getAsset(assetID(anm, name), me.eid, me.app.models.assetLoaded)
 			default:
 				slog.Error("undefined model asset", "attr", attr[0], "name", name, "eid", me.eid)
 			}
@@ -338,30 +389,86 @@ func (m *model) getAssets(me *Entity, assets ...string) {
 	}
 }
 
-// addAsset adds the asset to the model.
-func (m *model) addAsset(a asset) {
+// addAsset adds the asset to the model. Returns the asset that
+// the model no longer uses, if any, eg: a replaced mesh.
+func (m *model) addAsset(a asset) (unused asset) {
 	switch la := a.(type) {
 	case *mesh:
+		if m.mesh != nil {
+			unused = m.mesh
+		}
 		m.mesh = la
 	case *material:
-		if m.mat == nil {
-			// set materials not already set by app.
-			m.mat = la
+		if m.mat != nil {
+			return la // keep materials already set by app.
 		}
+		m.mat = la
 	case *texture:
 		// textures are added in the order they are loaded.
 		// They will have to need to match the order of the sampler
 		// uniforms from the shader config when they are used for rendering.
 		m.texs = append(m.texs, la)
 	case *font:
-		if m.mtype == labelModel && m.label != nil {
-			m.label.fnt = la
+		if m.mtype != labelModel || m.label == nil {
+			return la
 		}
+		m.label.fnt = la
 	case *shader:
+		if m.shader != nil {
+			unused = m.shader
+		}
 		m.shader = la
+	case *animation:
+		if m.actor == nil {
+			return la
+		}
+		m.actor.anim = la
 	default:
 		slog.Error("unexepected model asset", "name", a.label())
 	}
+	return unused
+}
+
+// release returns the model assets and drops the model GPU
+// resources once the model is disposed.
+func (m *model) release(ld *assetLoader) {
+	for _, a := range m.assets() {
+		ld.release(a)
+	}
+	for _, t := range m.updatable {
+		ld.drops = append(ld.drops, t) // owned by the model.
+	}
+	if m.hasInstances {
+		ld.dropInstanceData(m.instanceID)
+	}
+}
+
+// assets returns the assets used by the model.
+func (m *model) assets() (assets []asset) {
+	if m.mesh != nil {
+		assets = append(assets, m.mesh)
+	}
+	if m.shader != nil {
+		assets = append(assets, m.shader)
+	}
+	if m.mat != nil {
+		assets = append(assets, m.mat)
+	}
+	for _, t := range m.texs {
+		assets = append(assets, t) // updatable textures are ignored.
+	}
+	if m.label != nil && m.label.fnt != nil {
+		assets = append(assets, m.label.fnt)
+	}
+	if m.actor != nil && m.actor.anim != nil {
+		assets = append(assets, m.actor.anim)
+	}
+	for _, l := range m.lods {
+		if l.mesh != nil {
+			assets = append(assets, l.mesh)
+		}
+	}
+	return assets
 }
 
 // fillPacket populates a render.Packet for this model returning
@@ -376,7 +483,8 @@ func (m *model) fillPacket(packet *render.Packet, pov *pov, cam *Camera) error {
 	if m.mesh == nil {
 		return fmt.Errorf("mesh not loaded: %s", m.req)
 	}
-	packet.MeshID = m.mesh.mid // GPU mesh reference.
+	msh, _, _ := m.lodMeshes()
+	packet.MeshID = msh.mid // GPU mesh reference.
 
 	// check specific needs for different model types.
 	switch m.mtype {
@@ -386,7 +494,10 @@ func (m *model) fillPacket(packet *render.Packet, pov *pov, cam *Camera) error {
 			return fmt.Errorf("label not loaded: %s", m.req)
 		}
 	case actorModel:
-		// FUTURE check animation data.
+		if m.actor == nil || len(m.actor.bones) == 0 {
+			return fmt.Errorf("animation not loaded: %s", m.req)
+		}
+		packet.Bones = append(packet.Bones[:0], m.actor.bones...)
 	case effectModel:
 		// FUTURE check particle effect data.
 	}
@@ -453,6 +564,12 @@ func (m *model) fillPacket(packet *render.Packet, pov *pov, cam *Camera) error {
 			switch u.PacketUID {
 			case load.MODEL:
 				packet.Uniforms[load.MODEL] = render.M4ToBytes(pov.mm, packet.Uniforms[load.MODEL])
+			case load.BONES:
+				// the first bone is set by the render system.
+				if m.actor == nil {
+					return fmt.Errorf("bones need an animation: %s", m.req)
+				}
+				packet.Uniforms[load.BONES] = render.Int32ToBytes(0, packet.Uniforms[load.BONES])
 			case load.SCALE:
 				sx, sy, sz := pov.scale()
 				packet.Uniforms[load.SCALE] = render.V4SToBytes(sx, sy, sz, 0, packet.Uniforms[load.SCALE])
@@ -468,6 +585,9 @@ func (m *model) fillPacket(packet *render.Packet, pov *pov, cam *Camera) error {
 			default:
 				// basic uniforms are set using SetModelUniform.
 				data, ok := m.uniforms[u.PacketUID]
+				if !ok {
+					data, ok = uniformDefaults[u.PacketUID]
+				}
 				if !ok {
 					return fmt.Errorf("waiting on uniforms: %s", m.req)
 				}
@@ -477,20 +597,63 @@ func (m *model) fillPacket(packet *render.Packet, pov *pov, cam *Camera) error {
 		}
 	}
 
-	// add the eid to help debug packets.
+	// add the eid to help debug packets and the GPU profile scope.
 	packet.Tag = uint32(pov.eid) // Use eid for debugging draw calls.
+	packet.Scope = m.scope       // GPU profile scope.
 
 	// set the render packet sorting information.
-	packet.Bucket = setBucketType(packet.Bucket, drawOpaque)
-	if m.isTransparent() {
-		packet.Bucket = setBucketType(packet.Bucket, drawTransparent)
-	}
+	drawType := m.drawType()
+	packet.Bucket = setBucketType(packet.Bucket, drawType)
 	packet.Bucket = setBucketShader(packet.Bucket, m.shader.sid)
-	packet.Bucket = setBucketDistance(packet.Bucket, math.MaxFloat64-m.tocam)
 	packet.Bucket = setBucketLayer(packet.Bucket, m.layer)
+	if drawType >= drawTransparent {
+		packet.Bucket = setBucketDepth(packet.Bucket, m.depth)
+	}
 	return nil // model has all information needed to render.
 }
 
+// uniformDefaults are used for optional uniforms that have not been set.
+var uniformDefaults = map[load.PacketUniform][]byte{
+	load.KEYCOLOR:   render.V4S32ToBytes(0, 0, 0, -1, nil), // no color key.
+	load.PALETTEROW: render.V4S32ToBytes(0, 0, 0, 0, nil),  // first palette row.
+	load.PROBE:      render.V4S32ToBytes(0, 0, 0, 0, nil),  // no reflection probe.
+	load.PROBEBOX:   render.V4S32ToBytes(0, 0, 0, 0, nil),  // no probe box.
+}
+
+// drawType returns the bucket draw type for the model render queue.
+func (m *model) drawType() uint64 {
+	switch m.queue {
+	case QueueBackground:
+		return drawBackground
+	case QueueOpaque:
+		return drawOpaque
+	case QueueTransparent:
+		return drawTransparent
+	case QueueOverlay:
+		return drawOverlay
+	}
+	if m.isTransparent() {
+		return drawTransparent
+	}
+	return drawOpaque
+}
+
+// setSampler sets the texture for the given shader sampler,
+// replacing the previous texture for the sampler.
+func (m *model) setSampler(sampler string, t *texture) {
+	if label, ok := m.samplerMap[sampler]; ok {
+		for i := range m.texs {
+			if m.texs[i].label() == label {
+				m.texs[i] = t
+				m.samplerMap[sampler] = t.label()
+				return
+			}
+		}
+	}
+	m.texs = append(m.texs, t)
+	m.samplerMap[sampler] = t.label()
+}
+
 // isTransparent returns true if the model is transparent.
 // This is either a property of its base color texture or
 // its material alpha value.
@@ -510,12 +673,16 @@ func (m *model) isTransparent() bool {
 // models is the component manager for model data.
 type models struct {
 	list map[eID]*model // All model objects.
+	ld   *assetLoader   // tracks the assets used by models.
+
+	// Scratch for per update calculations.
+	actors []*actor // animated models.
 }
 
 // newModels creates the render model component manager.
 // Expected to be called once on startup.
-func newModels() *models {
-	ms := &models{}
+func newModels(ld *assetLoader) *models {
+	ms := &models{ld: ld}
 	ms.list = map[eID]*model{} // any model in any state.
 	return ms
 }
@@ -527,6 +694,7 @@ func (ms *models) create(e *Entity) *model {
 	}
 	m := newModel(basicModel)
 	ms.list[e.eid] = m
+	e.app.spatial.add(e.app, e.eid)
 	return m
 }
 
@@ -537,11 +705,16 @@ func (ms *models) createLabel(s string, wrap int, e *Entity) *model {
 	}
 	m := newModel(labelModel)
 	ms.list[e.eid] = m
-	m.label = &label{str: s, wrap: wrap}
+	e.app.spatial.add(e.app, e.eid)
+	m.label = &label{str: s, wrap: wrap, pages: map[int]*Entity{}}
 
 	// create default white color for the label.
 	m.mat = newMaterial(fmt.Sprintf("mat%d", e.eid)) // fake name
 	m.mat.color = rgba{1, 1, 1, 1}
+
+	// default to no text effects with a black effect color.
+	m.label.effect = rgba{0, 0, 0, 1}
+	m.setTextUniforms()
 	return m
 }
 
@@ -554,7 +727,10 @@ func (ms *models) assetLoaded(eid eID, a asset) {
 		slog.Warn("no model for asset", "eid", eid)
 		return
 	}
-	m.addAsset(a)
+	ms.ld.acquire(a)
+	if unused := m.addAsset(a); unused != nil {
+		ms.ld.release(unused)
+	}
 }
 
 // get the model for the given entity.
@@ -565,8 +741,74 @@ func (ms *models) get(eid eID) *model { return ms.list[eid] }
 // func (ms *models) getReady(eid eID) *model { return ms.ready[eid] }
 
 // dispose of the model, removing it from all of the maps.
-// There is no easy way of knowing when to delete the related assets.
-// Leave that to the application.
+// The model assets are released and are evicted once no other
+// model uses them, see resources.go.
 func (ms *models) dispose(eid eID) {
-	delete(ms.list, eid)
+	if m, ok := ms.list[eid]; ok {
+		m.release(ms.ld)
+		delete(ms.list, eid)
+	}
+}
+
+// addUpdatable creates and uploads the 2 updatable textures,
+// see Entity.AddUpdatableTexture.
+func (m *model) addUpdatable(app *application, rc render.Loader, name string, img *image.NRGBA) (err error) {
+	// create 2 texture assets.
+	opaque := img.Opaque()
+	t1 := newTexture(name + "_a")
+	t1.opaque = opaque
+	t2 := newTexture(name + "_b")
+	t2.opaque = opaque
+	m.updatable = []*texture{t1, t2}
+
+	// upload the initial texture to the GPU
+	idata := &load.ImageData{
+		Width:  uint32(img.Bounds().Size().X),
+		Height: uint32(img.Bounds().Size().Y),
+		Pixels: []byte(img.Pix),
+		Opaque: opaque,
+	}
+	t1.w, t1.h = int(idata.Width), int(idata.Height)
+	t2.w, t2.h = t1.w, t1.h
+	if t1.tid, err = rc.LoadTexture(idata); err != nil {
+		return err
+	}
+	slog.Debug("model", "asset", "tex:"+t1.label(), "tid", t1.tid, "opaque", t1.opaque)
+	if t2.tid, err = rc.LoadTexture(idata); err != nil {
+		return err
+	}
+	slog.Debug("model", "asset", "tex:"+t2.label(), "tid", t2.tid, "opaque", t2.opaque)
+
+	// TODO fake this
+	// m.samplerMap[uniform] = name // remember uniform to texture mapping.
+	m.samplerMap["color"] = t1.label()
+
+	// m.texs only takes one of the 2 textures.
+	for _, t := range m.texs {
+		app.ld.release(t)
+	}
+	m.texs = []*texture{t1}
+	return nil
+}
+
+// updateTexture uploads the image to the updatable texture and then
+// swaps the updatable texture with the render texture.
+func (m *model) updateTexture(rc render.Loader, img *image.NRGBA) error {
+	// update the uploadable texture,
+	t2 := m.updatable[1] // updatable is always second
+	idata := &load.ImageData{
+		Width:  uint32(img.Bounds().Size().X),
+		Height: uint32(img.Bounds().Size().Y),
+		Pixels: []byte(img.Pix),
+		Opaque: img.Opaque(),
+	}
+	if err := rc.UpdateTexture(t2.tid, idata); err != nil {
+		return err
+	}
+
+	// swap textures and render the recently updated texture.
+	m.updatable[0], m.updatable[1] = m.updatable[1], m.updatable[0]
+	m.texs[0] = m.updatable[0] // always render the first after swap.
+	m.samplerMap["color"] = m.texs[0].label()
+	return nil
 }
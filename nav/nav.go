@@ -0,0 +1,207 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+// Package nav finds paths for characters walking through level geometry.
+// Level triangles are voxelized, similar to Recast, into a navigation
+// mesh of convex walkable polygons that are searched using A*. Paths
+// are pulled tight around corners and obstacles, like closed doors or
+// parked vehicles, can be carved out of the mesh as they move.
+//
+// Package nav is provided as part of the vu (virtual universe) 3D engine.
+package nav
+
+// nav.go exposes the navigation mesh API.
+//	 voxel.go : level triangles to walkable cells.
+//	 poly.go  : walkable cells to linked polygons.
+//	 path.go  : A* polygon search and string pulling.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// ErrNoPath is wrapped by path errors when there is no walkable path.
+var ErrNoPath = errors.New("no path")
+
+// Config describes the agents that walk on a navigation mesh and the
+// size of the voxels used to build it. Smaller cells give more accurate
+// meshes that take longer to build and search.
+type Config struct {
+	CellSize    float64 // horizontal voxel size.
+	CellHeight  float64 // vertical voxel size.
+	AgentHeight float64 // clearance needed above walkable ground.
+	AgentRadius float64 // distance kept from walls and ledges.
+	AgentClimb  float64 // highest step that can be walked up.
+	MaxSlope    float64 // steepest walkable slope in radians.
+}
+
+// DefaultConfig returns the configuration for human sized agents
+// in a level measured in meters.
+func DefaultConfig() Config {
+	return Config{
+		CellSize:    0.3,
+		CellHeight:  0.2,
+		AgentHeight: 2.0,
+		AgentRadius: 0.6,
+		AgentClimb:  0.9,
+		MaxSlope:    math.Pi / 4,
+	}
+}
+
+// Mesh is a navigation mesh of walkable polygons. Meshes are not safe
+// for concurrent use since queries rebuild the parts of the mesh that
+// were changed by obstacles.
+type Mesh struct {
+	cfg    Config
+	origin lin.V3 // minimum corner of the voxel grid.
+	w, d   int    // voxel grid columns along x and rows along z.
+	climb  int32  // agent climb in cell heights.
+	height int32  // agent height in cell heights.
+
+	// walkable cells ordered by column, where column is x+z*w.
+	cells    []cell
+	colStart []int32 // first cell of each column, with an extra end entry.
+
+	// polygons are built for each tile of cells.
+	tw, td    int      // tiles along x and z.
+	tilePolys [][]poly // polygons for each tile.
+	dirty     []bool   // tiles whose polygons need rebuilding.
+	polys     []*poly  // all polygons, indexed by cell.poly.
+	relink    bool     // true if the polygon portals need rebuilding.
+
+	obstacles map[int]obstacle
+	nextID    int
+}
+
+// obstacle is a vertical cylinder carved out of the mesh.
+type obstacle struct {
+	at     lin.V3 // center of the obstacle base.
+	radius float64
+	height float64
+}
+
+// tileSize is the number of cells along each side of a tile.
+const tileSize = 32
+
+// Build creates a navigation mesh from level triangles given as
+// counter-clockwise indexes into the world space vertexes. Triangles
+// facing up, that are not steeper than the agent slope, are walkable
+// where there is room for the agent. Returns an error if nothing
+// is walkable.
+func Build(cfg Config, vertexes []lin.V3, indexes []uint32) (*Mesh, error) {
+	if cfg.CellSize <= 0 || cfg.CellHeight <= 0 || cfg.AgentHeight <= 0 || cfg.AgentRadius < 0 || cfg.AgentClimb < 0 {
+		return nil, fmt.Errorf("nav build: invalid config %+v", cfg)
+	}
+	if len(indexes) < 3 || len(indexes)%3 != 0 {
+		return nil, fmt.Errorf("nav build: expected triangles got %d indexes", len(indexes))
+	}
+	for _, index := range indexes {
+		if int(index) >= len(vertexes) {
+			return nil, fmt.Errorf("nav build: invalid index %d for %d vertexes", index, len(vertexes))
+		}
+	}
+	hf := newHeightfield(cfg, vertexes)
+	m := &Mesh{cfg: cfg, origin: hf.origin, w: hf.w, d: hf.d, obstacles: map[int]obstacle{}}
+	m.climb = int32(math.Floor(cfg.AgentClimb / cfg.CellHeight))
+	m.height = int32(math.Ceil(cfg.AgentHeight / cfg.CellHeight))
+	minY := math.Cos(cfg.MaxSlope)
+	for t := 0; t < len(indexes); t += 3 {
+		v0, v1, v2 := vertexes[indexes[t]], vertexes[indexes[t+1]], vertexes[indexes[t+2]]
+		n := lin.NewV3().Cross(lin.NewV3().Sub(&v1, &v0), lin.NewV3().Sub(&v2, &v0))
+		walkable := n.Len() > 0 && n.Y/n.Len() >= minY
+		hf.rasterize(v0, v1, v2, walkable, m.climb)
+	}
+	hf.filterLowObstacles(m.climb)
+	m.buildCells(hf)
+	m.erode(int32(math.Ceil(cfg.AgentRadius / cfg.CellSize)))
+
+	m.tw, m.td = (m.w+tileSize-1)/tileSize, (m.d+tileSize-1)/tileSize
+	m.tilePolys = make([][]poly, m.tw*m.td)
+	m.dirty = make([]bool, m.tw*m.td)
+	for i := range m.dirty {
+		m.dirty[i] = true
+	}
+	m.update()
+	if len(m.polys) == 0 {
+		return nil, fmt.Errorf("nav build: nothing is walkable")
+	}
+	return m, nil
+}
+
+// Polygons appends the corners of each walkable polygon to found and
+// returns the result, eg: to debug draw the navigation mesh.
+func (m *Mesh) Polygons(found [][4]lin.V3) [][4]lin.V3 {
+	m.update()
+	for _, p := range m.polys {
+		found = append(found, m.corners(p))
+	}
+	return found
+}
+
+// Nearest returns the closest walkable point to the given point
+// within a few cells. Returns false if there is no walkable point.
+func (m *Mesh) Nearest(at lin.V3) (lin.V3, bool) {
+	m.update()
+	_, point, ok := m.locate(at)
+	return point, ok
+}
+
+// AddObstacle carves a vertical cylinder, standing on at, out of the
+// navigation mesh. Returns an id used to move or remove the obstacle.
+func (m *Mesh) AddObstacle(at lin.V3, radius, height float64) (id int) {
+	m.nextID++
+	ob := obstacle{at: at, radius: radius, height: height}
+	m.obstacles[m.nextID] = ob
+	m.carve(ob, 1)
+	return m.nextID
+}
+
+// MoveObstacle moves the obstacle to stand on at.
+func (m *Mesh) MoveObstacle(id int, at lin.V3) {
+	if ob, ok := m.obstacles[id]; ok {
+		m.carve(ob, -1)
+		ob.at = at
+		m.obstacles[id] = ob
+		m.carve(ob, 1)
+	}
+}
+
+// RemoveObstacle restores the parts of the mesh covered by the obstacle.
+func (m *Mesh) RemoveObstacle(id int) {
+	if ob, ok := m.obstacles[id]; ok {
+		m.carve(ob, -1)
+		delete(m.obstacles, id)
+	}
+}
+
+// carve adds, or removes, the obstacle from the cells that it covers.
+// The obstacle is widened by the agent radius. The polygons are
+// rebuilt for the changed tiles before the next query.
+func (m *Mesh) carve(ob obstacle, blocked int32) {
+	cs := m.cfg.CellSize
+	r := ob.radius + m.cfg.AgentRadius
+	x0, x1 := m.column(ob.at.X-r, m.origin.X, m.w), m.column(ob.at.X+r, m.origin.X, m.w)
+	z0, z1 := m.column(ob.at.Z-r, m.origin.Z, m.d), m.column(ob.at.Z+r, m.origin.Z, m.d)
+	for z := z0; z <= z1; z++ {
+		for x := x0; x <= x1; x++ {
+			cx, cz := m.origin.X+(float64(x)+0.5)*cs, m.origin.Z+(float64(z)+0.5)*cs
+			if math.Hypot(cx-ob.at.X, cz-ob.at.Z) > r {
+				continue
+			}
+			col := x + z*m.w
+			for i := m.colStart[col]; i < m.colStart[col+1]; i++ {
+				if c := &m.cells[i]; c.y >= ob.at.Y-m.cfg.AgentClimb && c.y <= ob.at.Y+ob.height {
+					c.blocked += blocked
+					m.dirty[x/tileSize+(z/tileSize)*m.tw] = true
+				}
+			}
+		}
+	}
+}
+
+// column returns the clamped grid column, or row, of the world value.
+func (m *Mesh) column(v, origin float64, size int) int {
+	return min(max(int(math.Floor((v-origin)/m.cfg.CellSize)), 0), size-1)
+}
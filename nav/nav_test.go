@@ -0,0 +1,170 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package nav
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// level is a triangle soup used to build test meshes.
+type level struct {
+	verts   []lin.V3
+	indexes []uint32
+}
+
+// box adds an axis aligned box from lo to hi with outward facing triangles.
+func (l *level) box(lo, hi lin.V3) *level {
+	i := uint32(len(l.verts))
+	for c := 0; c < 8; c++ {
+		v := lo
+		if c&1 != 0 {
+			v.X = hi.X
+		}
+		if c&2 != 0 {
+			v.Y = hi.Y
+		}
+		if c&4 != 0 {
+			v.Z = hi.Z
+		}
+		l.verts = append(l.verts, v)
+	}
+	for _, q := range [][4]uint32{{2, 6, 7, 3}, {0, 1, 5, 4}, {0, 2, 3, 1}, {4, 5, 7, 6}, {0, 4, 6, 2}, {1, 3, 7, 5}} {
+		l.indexes = append(l.indexes, i+q[0], i+q[1], i+q[2], i+q[0], i+q[2], i+q[3])
+	}
+	return l
+}
+
+// length returns the length of the path.
+func length(path []lin.V3) (d float64) {
+	for i := 1; i < len(path); i++ {
+		d += path[i].Dist(&path[i-1])
+	}
+	return d
+}
+
+// go test -run Nav
+func TestNav(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AgentRadius = 0.3
+
+	// a 20x20 floor with a wall across the middle that has a gap at x=8.
+	floor := (&level{}).box(lin.V3{X: -10, Y: -1, Z: -10}, lin.V3{X: 10, Y: 0, Z: 10})
+	floor.box(lin.V3{X: -10, Y: 0, Z: -0.5}, lin.V3{X: 7, Y: 2, Z: 0.5})
+	floor.box(lin.V3{X: 9, Y: 0, Z: -0.5}, lin.V3{X: 10, Y: 2, Z: 0.5})
+	m, err := Build(cfg, floor.verts, floor.indexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// go test -run Nav/build
+	t.Run("build", func(t *testing.T) {
+		polys := m.Polygons(nil)
+		if len(polys) == 0 {
+			t.Fatal("expected polygons")
+		}
+		area := 0.0
+		for _, p := range polys {
+			switch p[0].Y {
+			case 0:
+				area += (p[2].X - p[0].X) * (p[2].Z - p[0].Z)
+			case 2: // top of the wall.
+			default:
+				t.Fatalf("expected polygons on the floor or wall got %v", p)
+			}
+		}
+		if area < 300 || area > 380 {
+			t.Errorf("expected floor minus wall and edges got %f", area)
+		}
+		if at, ok := m.Nearest(lin.V3{X: 0, Y: 1, Z: 0}); !ok || at.Y != 0 || math.Abs(at.Z) < 0.5+cfg.AgentRadius {
+			t.Errorf("expected the floor beside the wall got %v", at)
+		}
+		if at, ok := m.Nearest(lin.V3{X: 1, Y: 0.5, Z: 3}); !ok || at.Y != 0 || at.X != 1 || at.Z != 3 {
+			t.Errorf("expected point on the floor got %v", at)
+		}
+	})
+
+	// go test -run Nav/path
+	t.Run("path", func(t *testing.T) {
+		start, end := lin.V3{X: -5, Y: 0, Z: -5}, lin.V3{X: -5, Y: 0, Z: 5}
+		path, err := m.FindPath(start, end, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !path[0].Aeq(&start) || !path[len(path)-1].Aeq(&end) || len(path) < 4 {
+			t.Fatalf("expected path around the wall got %v", path)
+		}
+		for _, corner := range path[1 : len(path)-1] {
+			if corner.X < 7 || corner.X > 9 || math.Abs(corner.Z) > 1.5 {
+				t.Errorf("expected corners at the gap got %v", corner)
+			}
+		}
+		if d := length(path); d < 26 || d > 28 {
+			t.Errorf("expected a tight path got length %f", d)
+		}
+
+		// straight line in the open.
+		path, _ = m.FindPath(lin.V3{X: -8, Y: 0, Z: 2}, lin.V3{X: 8, Y: 0, Z: 8}, path[:0])
+		if len(path) != 2 {
+			t.Errorf("expected a straight path got %v", path)
+		}
+		if _, err := m.FindPath(start, lin.V3{X: 50, Y: 0, Z: 0}, nil); !errors.Is(err, ErrNoPath) {
+			t.Errorf("expected no path off the mesh got %v", err)
+		}
+	})
+
+	// go test -run Nav/obstacle
+	t.Run("obstacle", func(t *testing.T) {
+		start, end := lin.V3{X: -5, Y: 0, Z: -5}, lin.V3{X: -5, Y: 0, Z: 5}
+		id := m.AddObstacle(lin.V3{X: 8, Y: 0, Z: 0}, 1.5, 2)
+		if _, err := m.FindPath(start, end, nil); !errors.Is(err, ErrNoPath) {
+			t.Errorf("expected the gap to be blocked got %v", err)
+		}
+		m.MoveObstacle(id, lin.V3{X: 0, Y: 0, Z: 5})
+		path, err := m.FindPath(start, end, nil)
+		if err != nil || length(path) < 26 {
+			t.Errorf("expected path through the gap got %v %v", path, err)
+		}
+		if at, ok := m.Nearest(lin.V3{X: 0, Y: 0, Z: 5}); ok && math.Hypot(at.X, at.Z-5) < 1.5 {
+			t.Errorf("expected obstacle to cover the floor got %v", at)
+		}
+		m.RemoveObstacle(id)
+		if _, ok := m.Nearest(lin.V3{X: 0, Y: 0, Z: 5}); !ok {
+			t.Error("expected floor after removing obstacle")
+		}
+	})
+
+	// go test -run Nav/levels
+	t.Run("levels", func(t *testing.T) {
+		// a platform above the floor reached by a ramp.
+		l := (&level{}).box(lin.V3{X: -10, Y: -1, Z: -10}, lin.V3{X: 10, Y: 0, Z: 10})
+		l.box(lin.V3{X: -10, Y: 3, Z: -10}, lin.V3{X: 10, Y: 3.5, Z: -4})
+		l.verts = append(l.verts, lin.V3{X: -2, Y: 0, Z: 4}, lin.V3{X: 2, Y: 0, Z: 4}, lin.V3{X: 2, Y: 3.5, Z: -4}, lin.V3{X: -2, Y: 3.5, Z: -4})
+		n := uint32(len(l.verts) - 4)
+		l.indexes = append(l.indexes, n, n+1, n+2, n, n+2, n+3)
+		m, err := Build(cfg, l.verts, l.indexes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		top, ok := m.Nearest(lin.V3{X: 6, Y: 3.5, Z: -7})
+		if !ok || math.Abs(top.Y-3.5) > cfg.CellHeight {
+			t.Fatalf("expected the platform got %v", top)
+		}
+		below, ok := m.Nearest(lin.V3{X: 6, Y: 0, Z: -7})
+		if !ok || below.Y != 0 {
+			t.Fatalf("expected the floor under the platform got %v", below)
+		}
+		path, err := m.FindPath(lin.V3{X: 6, Y: 0, Z: 8}, top, nil)
+		if err != nil || !path[len(path)-1].Aeq(&top) {
+			t.Fatalf("expected path up the ramp got %v %v", path, err)
+		}
+		for _, p := range path[1 : len(path)-1] {
+			if p.X < -2.5 || p.X > 2.5 {
+				t.Errorf("expected path to use the ramp got %v", path)
+			}
+		}
+	})
+}
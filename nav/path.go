@@ -0,0 +1,253 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package nav
+
+// path.go finds paths across the navigation mesh. The polygons are
+// searched using A* with the portal midpoints as the path points.
+// The path through the portals is then pulled tight using the
+// simple stupid funnel algorithm:
+// https://digestingduck.blogspot.com/2010/03/simple-stupid-funnel-algorithm.html
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// FindPath appends the corners of the shortest walkable path from
+// start to end to path and returns the result. The path begins at the
+// walkable point nearest start and finishes at the walkable point
+// nearest end. Returns an error wrapping ErrNoPath if either point is
+// not near the mesh or if there is no path between them.
+func (m *Mesh) FindPath(start, end lin.V3, path []lin.V3) ([]lin.V3, error) {
+	m.update()
+	from, src, ok := m.locate(start)
+	if !ok {
+		return path, fmt.Errorf("path start %v not on navmesh: %w", start, ErrNoPath)
+	}
+	to, dst, ok := m.locate(end)
+	if !ok {
+		return path, fmt.Errorf("path end %v not on navmesh: %w", end, ErrNoPath)
+	}
+	route, ok := m.search(m.cells[from].poly, m.cells[to].poly, src, dst)
+	if !ok {
+		return path, fmt.Errorf("path %v to %v: %w", start, end, ErrNoPath)
+	}
+	return stringPull(m.funnel(route, src, dst), path), nil
+}
+
+// step is a polygon on a path and the portal used to reach it.
+type step struct {
+	poly   int32
+	portal *portal // nil for the first polygon.
+}
+
+// searchNode is the A* search state for a polygon.
+type searchNode struct {
+	g      float64 // path length to the node.
+	at     lin.V3  // where the path enters the polygon.
+	parent int32
+	portal *portal
+	closed bool
+	seen   bool
+}
+
+// search returns the polygons from the start to the end polygon.
+func (m *Mesh) search(from, to int32, start, end lin.V3) (route []step, ok bool) {
+	nodes := make([]searchNode, len(m.polys))
+	open := &searchQueue{}
+	nodes[from] = searchNode{at: start, parent: -1, seen: true}
+	heap.Push(open, searchItem{poly: from, f: start.Dist(&end)})
+	for open.Len() > 0 {
+		item := heap.Pop(open).(searchItem)
+		current := &nodes[item.poly]
+		if current.closed {
+			continue // already reached by a shorter path.
+		}
+		current.closed = true
+		if item.poly == to {
+			for p := to; p >= 0; p = nodes[p].parent {
+				route = append(route, step{poly: p, portal: nodes[p].portal})
+			}
+			for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+				route[i], route[j] = route[j], route[i]
+			}
+			return route, true
+		}
+		for i := range m.polys[item.poly].portals {
+			pt := &m.polys[item.poly].portals[i]
+			next := &nodes[pt.to]
+			if next.closed {
+				continue
+			}
+			at := lin.NewV3().Add(&pt.a, &pt.b)
+			at.Scale(at, 0.5)
+			g := current.g + current.at.Dist(at)
+			if next.seen && g >= next.g {
+				continue
+			}
+			*next = searchNode{g: g, at: *at, parent: item.poly, portal: pt, seen: true}
+			heap.Push(open, searchItem{poly: pt.to, f: g + at.Dist(&end)})
+		}
+	}
+	return nil, false
+}
+
+// searchItem is an open polygon ordered by its estimated path length.
+type searchItem struct {
+	poly int32
+	f    float64
+}
+
+// searchQueue is a min heap of open polygons, see container/heap.
+type searchQueue []searchItem
+
+func (q searchQueue) Len() int           { return len(q) }
+func (q searchQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+func (q searchQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *searchQueue) Push(x any)        { *q = append(*q, x.(searchItem)) }
+func (q *searchQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// funnel returns the left and right ends of the portals along the
+// route, as seen when walking the route, starting and ending with
+// the start and end points.
+func (m *Mesh) funnel(route []step, start, end lin.V3) (portals [][2]lin.V3) {
+	portals = append(portals, [2]lin.V3{start, start})
+	for _, s := range route[1:] {
+		pt := s.portal
+		mid := lin.NewV3().Add(&pt.a, &pt.b)
+		mid.Scale(mid, 0.5)
+		ax, az := pt.a.X-mid.X, pt.a.Z-mid.Z
+		if float64(dx[pt.dir])*az-float64(dz[pt.dir])*ax < 0 {
+			portals = append(portals, [2]lin.V3{pt.b, pt.a}) // a is on the right.
+			continue
+		}
+		portals = append(portals, [2]lin.V3{pt.a, pt.b})
+	}
+	return append(portals, [2]lin.V3{end, end})
+}
+
+// stringPull appends the corners of the shortest path through the
+// left, right portals to path and returns the result.
+func stringPull(portals [][2]lin.V3, path []lin.V3) []lin.V3 {
+	apex, left, right := portals[0][0], portals[0][0], portals[0][1]
+	apexIndex, leftIndex, rightIndex := 0, 0, 0
+	path = append(path, apex)
+	for i := 1; i < len(portals); i++ {
+		l, r := portals[i][0], portals[i][1]
+
+		// tighten the funnel on the right side.
+		if triArea2(&apex, &right, &r) <= 0 {
+			if samePoint(&apex, &right) || triArea2(&apex, &left, &r) > 0 {
+				right, rightIndex = r, i
+			} else {
+				// right crossed left: left is the next corner.
+				path = addCorner(path, left)
+				apex, apexIndex = left, leftIndex
+				left, right, leftIndex, rightIndex = apex, apex, apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+
+		// tighten the funnel on the left side.
+		if triArea2(&apex, &left, &l) >= 0 {
+			if samePoint(&apex, &left) || triArea2(&apex, &right, &l) < 0 {
+				left, leftIndex = l, i
+			} else {
+				// left crossed right: right is the next corner.
+				path = addCorner(path, right)
+				apex, apexIndex = right, rightIndex
+				left, right, leftIndex, rightIndex = apex, apex, apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+	}
+	end := portals[len(portals)-1][0]
+	if last := &path[len(path)-1]; !samePoint(last, &end) || last.Y != end.Y {
+		path = addCorner(path, end)
+	}
+	return path
+}
+
+// addCorner appends the corner to the path, replacing the last corner
+// if it is on the straight line from the previous corner to this one.
+// Straight lines are common along the stepped edges of the cells.
+func addCorner(path []lin.V3, corner lin.V3) []lin.V3 {
+	if n := len(path); n >= 2 && math.Abs(triArea2(&path[n-2], &path[n-1], &corner)) < 1e-9 {
+		path[n-1] = corner
+		return path
+	}
+	return append(path, corner)
+}
+
+// triArea2 returns twice the signed area of the triangle a, b, c
+// seen from above.
+func triArea2(a, b, c *lin.V3) float64 {
+	ax, az := b.X-a.X, b.Z-a.Z
+	bx, bz := c.X-a.X, c.Z-a.Z
+	return bx*az - ax*bz
+}
+
+// samePoint returns true if the points are the same seen from above.
+func samePoint(a, b *lin.V3) bool {
+	return math.Abs(a.X-b.X) < 1e-6 && math.Abs(a.Z-b.Z) < 1e-6
+}
+
+// locate returns the usable cell nearest the point and the walkable
+// point on that cell closest to the point. Cells below the point, up
+// to the agent climb, and above the point, up to the agent height,
+// are searched outwards to a few cells from the point.
+func (m *Mesh) locate(at lin.V3) (cell int32, point lin.V3, ok bool) {
+	cs := m.cfg.CellSize
+	cx, cz := int(math.Floor((at.X-m.origin.X)/cs)), int(math.Floor((at.Z-m.origin.Z)/cs))
+	reach := max(3, int(math.Ceil(m.cfg.AgentRadius/cs))*2+2)
+	best, cell := math.Inf(1), int32(-1)
+	for r := 0; r <= reach; r++ {
+		for z := cz - r; z <= cz+r; z++ {
+			for x := cx - r; x <= cx+r; x++ {
+				if max(abs32(x-cx), abs32(z-cz)) != r || x < 0 || z < 0 || x >= m.w || z >= m.d {
+					continue // only the ring at distance r.
+				}
+				col := x + z*m.w
+				for i := m.colStart[col]; i < m.colStart[col+1]; i++ {
+					c := &m.cells[i]
+					if c.poly < 0 || at.Y < c.y-m.cfg.AgentClimb || at.Y > c.y+m.cfg.AgentHeight {
+						continue
+					}
+					p := m.cellPoint(c, at)
+					if d := p.Dist(&at); d < best {
+						best, cell, point = d, i, p
+					}
+				}
+			}
+		}
+		if cell >= 0 && float64(r)*cs > best {
+			break // further rings are further away.
+		}
+	}
+	return cell, point, cell >= 0
+}
+
+// cellPoint returns the point on the cell floor closest to at.
+func (m *Mesh) cellPoint(c *cell, at lin.V3) lin.V3 {
+	cs := m.cfg.CellSize
+	x0, z0 := m.origin.X+float64(c.x)*cs, m.origin.Z+float64(c.z)*cs
+	return lin.V3{X: lin.Clamp(at.X, x0, x0+cs), Y: c.y, Z: lin.Clamp(at.Z, z0, z0+cs)}
+}
+
+// abs32 returns the absolute value of a.
+func abs32(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,213 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package nav
+
+// poly.go merges the walkable cells of each tile into rectangles of
+// connected cells. The rectangles are the navigation mesh polygons.
+// Polygons are linked by portals where their border cells touch.
+// Tiles covered by obstacles are rebuilt before the next query.
+
+import (
+	"slices"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// poly is a rectangle of connected walkable cells.
+type poly struct {
+	x0, z0, x1, z1 int32    // cell columns and rows, inclusive.
+	cells          []int32  // cells in row order.
+	portals        []portal // links to the neighbouring polygons.
+}
+
+// portal is the edge shared with a neighbouring polygon.
+type portal struct {
+	to   int32  // neighbouring polygon.
+	dir  int    // direction of the neighbour, see dx, dz.
+	a, b lin.V3 // portal edge ends.
+}
+
+// usable returns true if agents can stand on the cell.
+func (m *Mesh) usable(i int32) bool {
+	c := &m.cells[i]
+	return c.open && c.blocked == 0
+}
+
+// update rebuilds the polygons of changed tiles and relinks
+// the polygons if any tiles were rebuilt.
+func (m *Mesh) update() {
+	for t, dirty := range m.dirty {
+		if dirty {
+			m.buildTile(t)
+			m.dirty[t], m.relink = false, true
+		}
+	}
+	if m.relink {
+		m.link()
+		m.relink = false
+	}
+}
+
+// buildTile merges the usable cells in the tile into rectangles.
+// Rows of connected cells are grown along +x and then extended
+// along +z while the next row is connected to the last row.
+func (m *Mesh) buildTile(t int) {
+	tx, tz := int32(t%m.tw)*tileSize, int32(t/m.tw)*tileSize
+	tx1, tz1 := min(tx+tileSize, int32(m.w)), min(tz+tileSize, int32(m.d))
+	taken := map[int32]bool{}
+	inTile := func(n int32) bool {
+		if n < 0 || taken[n] || !m.usable(n) {
+			return false
+		}
+		c := &m.cells[n]
+		return c.x >= tx && c.x < tx1 && c.z >= tz && c.z < tz1
+	}
+	polys := m.tilePolys[t][:0]
+	for z := tz; z < tz1; z++ {
+		for x := tx; x < tx1; x++ {
+			col := int(x) + int(z)*m.w
+			for i := m.colStart[col]; i < m.colStart[col+1]; i++ {
+				if taken[i] || !m.usable(i) {
+					continue
+				}
+				row := []int32{i}
+				for n := m.cells[i].links[2]; inTile(n); n = m.cells[n].links[2] {
+					row = append(row, n)
+				}
+				p := poly{x0: x, z0: z, x1: x + int32(len(row)) - 1, z1: z}
+				p.cells = append(p.cells, row...)
+				for _, c := range row {
+					taken[c] = true
+				}
+				for {
+					next := make([]int32, 0, len(row))
+					for k, c := range row {
+						n := m.cells[c].links[1]
+						if !inTile(n) || (k > 0 && m.cells[next[k-1]].links[2] != n) {
+							break
+						}
+						next = append(next, n)
+					}
+					if len(next) != len(row) {
+						break
+					}
+					for _, c := range next {
+						taken[c] = true
+					}
+					p.cells = append(p.cells, next...)
+					p.z1++
+					row = next
+				}
+				polys = append(polys, p)
+			}
+		}
+	}
+	m.tilePolys[t] = polys
+}
+
+// link numbers the polygons and finds the portals between them.
+// Each run of touching border cells between two polygons is a portal.
+func (m *Mesh) link() {
+	for i := range m.cells {
+		m.cells[i].poly = -1
+	}
+	m.polys = m.polys[:0]
+	for t := range m.tilePolys {
+		for i := range m.tilePolys[t] {
+			p := &m.tilePolys[t][i]
+			for _, c := range p.cells {
+				m.cells[c].poly = int32(len(m.polys))
+			}
+			m.polys = append(m.polys, p)
+		}
+	}
+
+	// touch is a border cell c touching polygon to in direction dir.
+	type touch struct {
+		to     int32
+		dir    int
+		at     int32 // position along the border.
+		c, n   int32 // the touching cells.
+		across int32 // border position across the direction.
+	}
+	touches := []touch{}
+	for pi, p := range m.polys {
+		p.portals = p.portals[:0]
+		touches = touches[:0]
+		for _, c := range p.cells {
+			for dir, n := range m.cells[c].links {
+				if n < 0 || m.cells[n].poly < 0 || m.cells[n].poly == int32(pi) {
+					continue
+				}
+				at, across := m.cells[c].z, m.cells[c].x
+				if dir == 1 || dir == 3 {
+					at, across = m.cells[c].x, m.cells[c].z
+				}
+				touches = append(touches, touch{to: m.cells[n].poly, dir: dir, at: at, c: c, n: n, across: across})
+			}
+		}
+		slices.SortFunc(touches, func(a, b touch) int {
+			switch {
+			case a.to != b.to:
+				return int(a.to - b.to)
+			case a.dir != b.dir:
+				return a.dir - b.dir
+			case a.across != b.across:
+				return int(a.across - b.across)
+			}
+			return int(a.at - b.at)
+		})
+		for start := 0; start < len(touches); {
+			end := start + 1
+			for end < len(touches) {
+				prev, next := &touches[end-1], &touches[end]
+				if next.to != prev.to || next.dir != prev.dir || next.across != prev.across || next.at != prev.at+1 {
+					break
+				}
+				end++
+			}
+			first, last := &touches[start], &touches[end-1]
+			a := m.faceEnd(first.c, first.n, first.dir, false)
+			b := m.faceEnd(last.c, last.n, last.dir, true)
+			p.portals = append(p.portals, portal{to: first.to, dir: first.dir, a: a, b: b})
+			start = end
+		}
+	}
+}
+
+// faceEnd returns one end of the face between cell c and its neighbour
+// n in direction dir. The height is between the two cell heights.
+func (m *Mesh) faceEnd(c, n int32, dir int, far bool) lin.V3 {
+	cell, cs := &m.cells[c], m.cfg.CellSize
+	x, z := float64(cell.x), float64(cell.z)
+	switch dir {
+	case 0, 2:
+		x += float64(max(dx[dir], 0))
+		if far {
+			z++
+		}
+	case 1, 3:
+		z += float64(max(dz[dir], 0))
+		if far {
+			x++
+		}
+	}
+	y := (cell.y + m.cells[n].y) * 0.5
+	return lin.V3{X: m.origin.X + x*cs, Y: y, Z: m.origin.Z + z*cs}
+}
+
+// corners returns the polygon corners counter-clockwise from above
+// using the heights of the corner cells.
+func (m *Mesh) corners(p *poly) [4]lin.V3 {
+	cs, cols := m.cfg.CellSize, p.x1-p.x0+1
+	n := int32(len(p.cells))
+	corner := func(cell int32, x, z int32) lin.V3 {
+		return lin.V3{X: m.origin.X + float64(x)*cs, Y: m.cells[p.cells[cell]].y, Z: m.origin.Z + float64(z)*cs}
+	}
+	return [4]lin.V3{
+		corner(0, p.x0, p.z0),
+		corner(n-cols, p.x0, p.z1+1),
+		corner(n-1, p.x1+1, p.z1+1),
+		corner(cols-1, p.x1+1, p.z0),
+	}
+}
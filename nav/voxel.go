@@ -0,0 +1,272 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package nav
+
+// voxel.go turns level triangles into walkable cells. Triangles are
+// rasterized into columns of solid spans. The top of a walkable span,
+// with room for the agent above it, is a walkable cell. Cells are
+// linked to the cells beside them that the agent can step to, and
+// cells near walls and ledges are removed so that agents keep their
+// radius away from them.
+// Based on Recast: https://github.com/recastnavigation/recastnavigation
+
+import (
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// span is a solid part of a voxel column from smin to smax cell heights.
+type span struct {
+	smin, smax int32
+	walkable   bool // true if the top of the span can be walked on.
+}
+
+// heightfield is a grid of voxel columns.
+type heightfield struct {
+	origin lin.V3 // minimum corner of the grid.
+	cs, ch float64
+	w, d   int
+	cols   [][]span // spans for each column ordered by height.
+}
+
+// newHeightfield creates an empty heightfield covering the vertexes.
+func newHeightfield(cfg Config, vertexes []lin.V3) *heightfield {
+	lo, hi := vertexes[0], vertexes[0]
+	for i := range vertexes {
+		lo.Min(&lo, &vertexes[i])
+		hi.Max(&hi, &vertexes[i])
+	}
+	hf := &heightfield{origin: lo, cs: cfg.CellSize, ch: cfg.CellHeight}
+	hf.w = max(int(math.Ceil((hi.X-lo.X)/cfg.CellSize)), 1)
+	hf.d = max(int(math.Ceil((hi.Z-lo.Z)/cfg.CellSize)), 1)
+	hf.cols = make([][]span, hf.w*hf.d)
+	return hf
+}
+
+// rasterize adds the spans covered by a triangle. The triangle is
+// clipped to each row of cells and then to each cell in the row.
+func (hf *heightfield) rasterize(v0, v1, v2 lin.V3, walkable bool, climb int32) {
+	o, cs := &hf.origin, hf.cs
+	lo := lin.NewV3().Min(&v0, &v1)
+	lo.Min(lo, &v2)
+	hi := lin.NewV3().Max(&v0, &v1)
+	hi.Max(hi, &v2)
+	z0 := min(max(int((lo.Z-o.Z)/cs), 0), hf.d-1)
+	z1 := min(max(int((hi.Z-o.Z)/cs), 0), hf.d-1)
+	in := []lin.V3{v0, v1, v2}
+	for z := z0; z <= z1 && len(in) >= 3; z++ {
+		var row []lin.V3
+		row, in = splitPoly(in, o.Z+float64(z+1)*cs, 2)
+		if len(row) < 3 {
+			continue
+		}
+		minX, maxX := row[0].X, row[0].X
+		for _, v := range row {
+			minX, maxX = min(minX, v.X), max(maxX, v.X)
+		}
+		x0 := min(max(int((minX-o.X)/cs), 0), hf.w-1)
+		x1 := min(max(int((maxX-o.X)/cs), 0), hf.w-1)
+		for x := x0; x <= x1 && len(row) >= 3; x++ {
+			var cell []lin.V3
+			cell, row = splitPoly(row, o.X+float64(x+1)*cs, 0)
+			if len(cell) < 3 {
+				continue
+			}
+			minY, maxY := cell[0].Y, cell[0].Y
+			for _, v := range cell {
+				minY, maxY = min(minY, v.Y), max(maxY, v.Y)
+			}
+			smax := int32(math.Ceil((maxY-o.Y)/hf.ch - voxelEpsilon))
+			smin := min(int32(math.Floor((minY-o.Y)/hf.ch+voxelEpsilon)), smax-1) // keep flat tops on the surface.
+			hf.addSpan(x+z*hf.w, smin, smax, walkable, climb)
+		}
+	}
+}
+
+// voxelEpsilon stops rounding errors from moving
+// heights that are on a cell boundary to the next cell.
+const voxelEpsilon = 1e-6
+
+// splitPoly splits a convex polygon by the plane where the given axis,
+// 0 for x and 2 for z, equals offset. Returns the parts below and above.
+func splitPoly(in []lin.V3, offset float64, axis int) (below, above []lin.V3) {
+	value := func(v *lin.V3) float64 {
+		if axis == 0 {
+			return v.X
+		}
+		return v.Z
+	}
+	d := make([]float64, len(in))
+	for i := range in {
+		d[i] = offset - value(&in[i])
+	}
+	for i, j := 0, len(in)-1; i < len(in); j, i = i, i+1 {
+		ina, inb := d[j] >= 0, d[i] >= 0
+		if ina != inb {
+			s := d[j] / (d[j] - d[i])
+			p := lin.NewV3().Sub(&in[i], &in[j])
+			p.Scale(p, s).Add(p, &in[j])
+			below, above = append(below, *p), append(above, *p)
+			if d[i] > 0 {
+				below = append(below, in[i])
+			} else if d[i] < 0 {
+				above = append(above, in[i])
+			}
+			continue
+		}
+		if d[i] >= 0 {
+			below = append(below, in[i])
+			if d[i] != 0 {
+				continue
+			}
+		}
+		above = append(above, in[i])
+	}
+	return below, above
+}
+
+// addSpan merges the span with the column spans that it overlaps.
+// The merged span is walkable if the walkable tops are within climb.
+func (hf *heightfield) addSpan(col int, smin, smax int32, walkable bool, climb int32) {
+	s := span{smin: smin, smax: smax, walkable: walkable}
+	spans := hf.cols[col]
+	kept := spans[:0]
+	for _, e := range spans {
+		if e.smax < s.smin || e.smin > s.smax {
+			kept = append(kept, e)
+			continue
+		}
+		s.smin, s.smax = min(s.smin, e.smin), max(s.smax, e.smax)
+		if abs(s.smax-e.smax) <= climb {
+			s.walkable = s.walkable || e.walkable
+		}
+	}
+	at := len(kept)
+	for at > 0 && kept[at-1].smin > s.smin {
+		at--
+	}
+	kept = append(kept, span{})
+	copy(kept[at+1:], kept[at:])
+	kept[at] = s
+	hf.cols[col] = kept
+}
+
+// filterLowObstacles makes the tops of solid spans that are within
+// climb of the walkable span below them walkable, eg: stair edges.
+func (hf *heightfield) filterLowObstacles(climb int32) {
+	for _, spans := range hf.cols {
+		for i := 1; i < len(spans); i++ {
+			below := &spans[i-1]
+			if !spans[i].walkable && below.walkable && spans[i].smax-below.smax <= climb {
+				spans[i].walkable = true
+			}
+		}
+	}
+}
+
+// abs returns the absolute value of a.
+func abs(a int32) int32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// cell is a walkable voxel cell.
+type cell struct {
+	x, z        int32
+	y           float64  // world height of the cell floor.
+	floor, ceil int32    // floor and ceiling in cell heights.
+	links       [4]int32 // walkable neighbour in each direction, or -1.
+	open        bool     // false if too close to a wall or ledge.
+	blocked     int32    // number of obstacles covering the cell.
+	poly        int32    // polygon containing the cell, or -1.
+}
+
+// dx, dz are the neighbour offsets for each link direction.
+var (
+	dx = [4]int32{-1, 0, 1, 0}
+	dz = [4]int32{0, 1, 0, -1}
+)
+
+// buildCells creates a cell for the top of each walkable span
+// with room for the agent and links the neighbouring cells.
+func (m *Mesh) buildCells(hf *heightfield) {
+	m.colStart = make([]int32, len(hf.cols)+1)
+	for col, spans := range hf.cols {
+		m.colStart[col] = int32(len(m.cells))
+		for i, s := range spans {
+			ceil := int32(math.MaxInt32)
+			if i+1 < len(spans) {
+				ceil = spans[i+1].smin
+			}
+			if !s.walkable || ceil-s.smax < m.height {
+				continue
+			}
+			m.cells = append(m.cells, cell{
+				x: int32(col % hf.w), z: int32(col / hf.w),
+				y: hf.origin.Y + float64(s.smax)*hf.ch, floor: s.smax, ceil: ceil,
+				links: [4]int32{-1, -1, -1, -1}, open: true, poly: -1,
+			})
+		}
+	}
+	m.colStart[len(hf.cols)] = int32(len(m.cells))
+	for i := range m.cells {
+		c := &m.cells[i]
+		for dir := range c.links {
+			x, z := c.x+dx[dir], c.z+dz[dir]
+			if x < 0 || z < 0 || int(x) >= m.w || int(z) >= m.d {
+				continue
+			}
+			col := int(x) + int(z)*m.w
+			best := int32(math.MaxInt32)
+			for n := m.colStart[col]; n < m.colStart[col+1]; n++ {
+				nc := &m.cells[n]
+				step := abs(nc.floor - c.floor)
+				if step <= m.climb && min(nc.ceil, c.ceil)-max(nc.floor, c.floor) >= m.height && step < best {
+					c.links[dir], best = n, step
+				}
+			}
+		}
+	}
+}
+
+// erode removes the cells that are closer than radius cells to a wall
+// or ledge. Distances are two for each cell across and three for each
+// cell diagonally, see Recast rcErodeWalkableArea.
+func (m *Mesh) erode(radius int32) {
+	if radius <= 0 {
+		return
+	}
+	dist := make([]int32, len(m.cells))
+	for i := range m.cells {
+		dist[i] = math.MaxInt32 / 2
+		for _, n := range m.cells[i].links {
+			if n < 0 {
+				dist[i] = 0 // edge cell.
+			}
+		}
+	}
+	// relax updates the cell distance from its neighbour n in direction
+	// dir, and from the diagonal neighbour in direction diag from n.
+	relax := func(i int, dir, diag int) {
+		if n := m.cells[i].links[dir]; n >= 0 {
+			dist[i] = min(dist[i], dist[n]+2)
+			if nn := m.cells[n].links[diag]; nn >= 0 {
+				dist[i] = min(dist[i], dist[nn]+3)
+			}
+		}
+	}
+	for i := range m.cells {
+		relax(i, 0, 3) // -x, then -z.
+		relax(i, 3, 2) // -z, then +x.
+	}
+	for i := len(m.cells) - 1; i >= 0; i-- {
+		relax(i, 2, 1) // +x, then +z.
+		relax(i, 1, 0) // +z, then -x.
+	}
+	for i := range m.cells {
+		m.cells[i].open = dist[i] >= radius*2
+	}
+}
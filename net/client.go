@@ -0,0 +1,199 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// client.go connects to a server and receives snapshots.
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// Client is a connection to a server. Create clients using Dial.
+type Client struct {
+	ep       *endpoint
+	conn     *Conn
+	addr     string    // server address.
+	next     int       // next transport to try.
+	snaps    history   // received snapshots, the baselines for deltas.
+	newest   uint32    // newest received snapshot tick.
+	started  time.Time // first connect attempt with the current transport.
+	lastSent time.Time // last connect attempt.
+}
+
+// Dial creates a client that connects to the server at the given
+// address, eg: "localhost:7777". The client connects during the
+// following updates, see OnConnect. The configured transports are
+// tried in order, each for the connection timeout. The client is
+// disconnected with ErrTimeout if the server does not respond,
+// or with ErrDenied if the server is full.
+func Dial(addr string, cfg Config) (*Client, error) {
+	cl := &Client{addr: addr, ep: newEndpoint(nil, cfg)} // replaced by dial.
+	cl.ep.onSnapshot = cl.snapshot
+	cl.conn = newConn(cl.ep, nil, nil, connConnecting, time.Time{})
+	if err := cl.dial(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// dial switches the connection to the next transport that can
+// resolve the server address.
+func (cl *Client) dial() (err error) {
+	for cl.next < len(cl.ep.cfg.Transports) {
+		t := cl.ep.cfg.Transports[cl.next]
+		cl.next++
+		pc, raddr, derr := t.Dial(cl.addr)
+		if derr != nil {
+			err = derr
+			continue
+		}
+		ep := newEndpoint([]net.PacketConn{pc}, cl.ep.cfg)
+		ep.h, ep.onSnapshot, ep.drop = cl.ep.h, cl.ep.onSnapshot, cl.ep.drop
+		cl.ep.close()
+		cl.ep = ep
+		cl.conn.ep, cl.conn.pc, cl.conn.addr = cl.ep, pc, raddr
+		cl.started, cl.lastSent = time.Time{}, time.Time{}
+		return nil
+	}
+	if err == nil {
+		err = ErrTimeout // no more transports.
+	}
+	return err
+}
+
+// Transport returns the transport of the server connection.
+func (cl *Client) Transport() Transport { return cl.ep.cfg.Transports[cl.next-1] }
+
+// OnConnect sets the function called when the server accepts the client.
+func (cl *Client) OnConnect(fn func(c *Conn)) *Client {
+	cl.ep.h.connect = fn
+	return cl
+}
+
+// OnDisconnect sets the function called when the connection fails
+// or ends. The error is ErrClosed, ErrDenied, or ErrTimeout.
+func (cl *Client) OnDisconnect(fn func(c *Conn, err error)) *Client {
+	cl.ep.h.disconnect = fn
+	return cl
+}
+
+// OnMessage sets the function called for each server message.
+// The message is only valid for the duration of the call.
+func (cl *Client) OnMessage(fn func(c *Conn, msg []byte)) *Client {
+	cl.ep.h.message = fn
+	return cl
+}
+
+// OnSnapshot sets the function called for each snapshot received from
+// the server. Snapshots that arrive after a newer snapshot are dropped.
+func (cl *Client) OnSnapshot(fn func(s *Snapshot)) *Client {
+	cl.ep.h.snapshot = fn
+	return cl
+}
+
+// Handle sets the function called for remote procedure calls of the
+// given name, see Conn.Call. A nil function removes the handler.
+func (cl *Client) Handle(name string, fn func(c *Conn, args []byte)) *Client {
+	cl.ep.handle(name, fn)
+	return cl
+}
+
+// Conn returns the connection to the server. Messages sent
+// before the client connects are sent once it connects.
+func (cl *Client) Conn() *Conn { return cl.conn }
+
+// Connected returns true if the server has accepted the client
+// and the connection has not ended.
+func (cl *Client) Connected() bool { return cl.conn.state == connConnected }
+
+// Update processes the received packets, calling the handlers, and
+// sends the queued messages. Call Update once each engine update.
+func (cl *Client) Update(now time.Time) {
+	c := cl.conn
+	if c.state == connConnecting {
+		if cl.started.IsZero() {
+			cl.started = now
+		}
+		if now.Sub(cl.lastSent) >= cl.ep.cfg.Resend {
+			cl.lastSent = now
+			cl.ep.send(c.pc, c.addr, cl.ep.header(packetConnect))
+		}
+	}
+	for n := len(cl.ep.in); n > 0; n-- {
+		cl.receive(<-cl.ep.in, now)
+	}
+	switch c.state {
+	case connConnecting:
+		if now.Sub(cl.started) > cl.ep.cfg.Timeout {
+			if cl.dial() != nil {
+				cl.disconnect(ErrTimeout)
+			}
+		}
+	case connConnected:
+		if now.Sub(c.lastRecv) > cl.ep.cfg.Timeout {
+			cl.disconnect(ErrTimeout)
+			return
+		}
+		c.flush(now)
+	}
+}
+
+// receive handles one packet from the server.
+func (cl *Client) receive(p received, now time.Time) {
+	c := cl.conn
+	if p.pc != c.pc || p.addr.String() != c.addr.String() {
+		return
+	}
+	switch p.data[headerSize-1] {
+	case packetAccept:
+		if c.state == connConnecting {
+			c.state, c.lastRecv, c.lastSend = connConnected, now, now
+			if cl.ep.h.connect != nil {
+				cl.ep.h.connect(c)
+			}
+		}
+	case packetDeny:
+		if c.state == connConnecting {
+			cl.disconnect(ErrDenied)
+		}
+	case packetData:
+		if c.state == connConnected {
+			c.receive(p.data[headerSize:], now)
+		}
+	case packetDisconnect:
+		if c.state != connClosed {
+			cl.disconnect(ErrClosed)
+		}
+	}
+}
+
+// disconnect ends the connection and calls the disconnect handler.
+func (cl *Client) disconnect(err error) {
+	cl.conn.state = connClosed
+	if cl.ep.h.disconnect != nil {
+		cl.ep.h.disconnect(cl.conn, err)
+	}
+}
+
+// snapshot decodes a snapshot message, acks it, and passes
+// it to the snapshot handler.
+func (cl *Client) snapshot(c *Conn, body []byte) {
+	s, err := decodeSnapshot(body, &cl.snaps)
+	if err != nil || s.Tick <= cl.newest {
+		return // the server resends from an acked snapshot.
+	}
+	cl.newest = s.Tick
+	cl.snaps.add(s)
+	c.queue(kindSnapAck, binary.AppendUvarint(nil, uint64(s.Tick)), false)
+	if cl.ep.h.snapshot != nil {
+		cl.ep.h.snapshot(s)
+	}
+}
+
+// Close disconnects from the server and closes the client.
+func (cl *Client) Close() error {
+	cl.conn.Close()
+	return cl.ep.close()
+}
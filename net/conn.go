@@ -0,0 +1,318 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// conn.go acknowledges packets and resends reliable messages. Each data
+// packet has a sequence number and acks the newest packet received along
+// with a bit for each of the 32 packets before it. Reliable messages are
+// resent until a packet holding them is acked, and are delivered in the
+// order they were sent. Unreliable messages are sent once.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// sentWindow is the number of sent packets remembered for acks.
+const sentWindow = 256
+
+// maxPending is the most unacked reliable messages for a connection.
+const maxPending = 1024
+
+// connection states.
+const (
+	connConnecting = iota // client waiting for the server to accept.
+	connConnected         // sending and receiving messages.
+	connClosed            // disconnected.
+)
+
+// Conn is a connection between a client and the server.
+// Servers have one connection for each client and clients
+// have one connection to the server.
+type Conn struct {
+	ep    *endpoint
+	pc    net.PacketConn // socket of the connection transport.
+	addr  net.Addr
+	state int
+
+	// packet acks.
+	seq     uint16                 // sequence number of the next sent packet.
+	remote  uint16                 // newest received packet sequence number.
+	ackBits uint32                 // bit n is set if packet remote-1-n was received.
+	gotAny  bool                   // true once a data packet is received.
+	ackOwed bool                   // true if received packets need acking.
+	sent    [sentWindow]sentPacket // sent packets by sequence number.
+	rtt     time.Duration          // smoothed round trip time.
+
+	// reliable messages.
+	nextID  uint16            // id of the next reliable message sent.
+	pending []*pendingMsg     // unacked reliable messages in id order.
+	expect  uint16            // id of the next reliable message delivered.
+	early   map[uint16][]byte // reliable messages received out of order.
+	unrel   [][]byte          // unreliable messages for the next packet.
+
+	lastRecv time.Time // when the last packet was received.
+	lastSend time.Time // when the last packet was sent.
+
+	snapAcked uint32 // newest snapshot acked by the client.
+}
+
+// sentPacket remembers a sent packet until it is acked.
+type sentPacket struct {
+	seq   uint16
+	time  time.Time
+	ids   []uint16 // reliable messages in the packet.
+	valid bool     // true if the packet was sent.
+	acked bool     // true once the packet is acked.
+}
+
+// pendingMsg is a reliable message that is resent until acked.
+type pendingMsg struct {
+	id   uint16
+	data []byte
+	sent time.Time // last sent, zero if not yet sent.
+}
+
+// newConn creates a connection to the given address.
+func newConn(ep *endpoint, pc net.PacketConn, addr net.Addr, state int, now time.Time) *Conn {
+	return &Conn{ep: ep, pc: pc, addr: addr, state: state, early: map[uint16][]byte{}, lastRecv: now, lastSend: now}
+}
+
+// Addr returns the address of the other end of the connection.
+func (c *Conn) Addr() net.Addr { return c.addr }
+
+// RTT returns the smoothed round trip time of the connection.
+func (c *Conn) RTT() time.Duration { return c.rtt }
+
+// Send queues a message for the next Update. Reliable messages are
+// resent until received and are delivered in the order they were sent.
+// Unreliable messages may be lost. The message is copied.
+func (c *Conn) Send(msg []byte, reliable bool) error {
+	return c.queue(kindUser, msg, reliable)
+}
+
+// Call queues a reliable message that runs the named remote procedure,
+// see Server.Handle and Client.Handle. The arguments are copied.
+func (c *Conn) Call(name string, args []byte) error {
+	return c.queue(kindRPC, append(appendString(nil, name), args...), true)
+}
+
+// Close disconnects. The other end is told so it doesn't have to
+// wait for a timeout.
+func (c *Conn) Close() {
+	if c.state != connClosed {
+		c.ep.send(c.pc, c.addr, c.ep.header(packetDisconnect))
+		c.state = connClosed
+	}
+}
+
+// queue adds a message of the given kind to the send queues.
+func (c *Conn) queue(kind byte, msg []byte, reliable bool) error {
+	switch {
+	case c.state == connClosed:
+		return ErrClosed
+	case len(msg) > MaxMessage:
+		return fmt.Errorf("net: message size %d is larger than %d", len(msg), MaxMessage)
+	case reliable && len(c.pending) >= maxPending:
+		return fmt.Errorf("net: too many unacked reliable messages")
+	}
+	data := append([]byte{kind}, msg...)
+	if reliable {
+		c.pending = append(c.pending, &pendingMsg{id: c.nextID, data: data})
+		c.nextID++
+		return nil
+	}
+	c.unrel = append(c.unrel, data)
+	return nil
+}
+
+// flush sends the queued messages and the reliable messages that are
+// due to be resent. An empty packet is sent to ack received packets
+// or to keep the connection alive.
+func (c *Conn) flush(now time.Time) {
+	if c.state != connConnected {
+		return
+	}
+	resend := max(c.ep.cfg.Resend, c.rtt*3/2)
+	pkt, ids, sent := c.startPacket(), []uint16{}, false
+	add := func(data []byte, flags byte, id uint16) {
+		size := 1 + binary.MaxVarintLen16 + len(data)
+		if flags != 0 {
+			size += 2
+		}
+		if len(pkt)+size > maxPacket {
+			c.sendPacket(pkt, ids, now)
+			pkt, ids, sent = c.startPacket(), []uint16{}, true
+		}
+		pkt = append(pkt, flags)
+		if flags != 0 {
+			pkt = binary.LittleEndian.AppendUint16(pkt, id)
+			ids = append(ids, id)
+		}
+		pkt = binary.AppendUvarint(pkt, uint64(len(data)))
+		pkt = append(pkt, data...)
+	}
+	for _, r := range c.pending {
+		if r.sent.IsZero() || now.Sub(r.sent) >= resend {
+			r.sent = now
+			add(r.data, 1, r.id)
+		}
+	}
+	for _, data := range c.unrel {
+		add(data, 0, 0)
+	}
+	clear(c.unrel)
+	c.unrel = c.unrel[:0]
+	if len(pkt) > headerSize+8 || (!sent && (c.ackOwed || now.Sub(c.lastSend) >= c.ep.cfg.Keepalive)) {
+		c.sendPacket(pkt, ids, now)
+	}
+}
+
+// startPacket returns a data packet header with space for
+// the sequence number and acks.
+func (c *Conn) startPacket() []byte {
+	return append(c.ep.header(packetData), make([]byte, 8)...)
+}
+
+// sendPacket fills in the sequence number and acks and sends the packet.
+func (c *Conn) sendPacket(pkt []byte, ids []uint16, now time.Time) {
+	binary.LittleEndian.PutUint16(pkt[headerSize:], c.seq)
+	binary.LittleEndian.PutUint16(pkt[headerSize+2:], c.remote)
+	binary.LittleEndian.PutUint32(pkt[headerSize+4:], c.ackBits)
+	c.sent[c.seq%sentWindow] = sentPacket{seq: c.seq, time: now, ids: ids, valid: true}
+	c.seq++
+	c.ackOwed, c.lastSend = false, now
+	c.ep.send(c.pc, c.addr, pkt)
+}
+
+// receive processes a data packet, without the packet header.
+func (c *Conn) receive(data []byte, now time.Time) {
+	r := &reader{b: data}
+	seq, ack, bits := r.u16(), r.u16(), r.u32()
+	if r.err != nil {
+		return
+	}
+	switch {
+	case !c.gotAny:
+		c.remote, c.ackBits, c.gotAny = seq, 0, true
+	case seqGreater(seq, c.remote):
+		shift := seq - c.remote
+		c.ackBits = c.ackBits<<shift | 1<<(shift-1) // bits past 32 are shifted out.
+		c.remote = seq
+	default:
+		back := c.remote - seq
+		if back == 0 || back > 32 || c.ackBits&(1<<(back-1)) != 0 {
+			return // duplicate or too old.
+		}
+		c.ackBits |= 1 << (back - 1)
+	}
+	c.lastRecv, c.ackOwed = now, true
+
+	// acks.
+	c.acked(ack, now)
+	for i := uint16(0); i < 32; i++ {
+		if bits&(1<<i) != 0 {
+			c.acked(ack-1-i, now)
+		}
+	}
+
+	// messages.
+	for len(r.b) > 0 && c.state == connConnected {
+		flags := r.byte()
+		var id uint16
+		if flags&1 != 0 {
+			id = r.u16()
+		}
+		msg := r.bytes()
+		if r.err != nil || len(msg) == 0 {
+			return
+		}
+		if flags&1 != 0 {
+			c.receiveReliable(id, msg)
+		} else {
+			c.deliver(msg)
+		}
+	}
+}
+
+// acked removes the reliable messages of an acked packet.
+func (c *Conn) acked(seq uint16, now time.Time) {
+	sp := &c.sent[seq%sentWindow]
+	if !sp.valid || sp.seq != seq || sp.acked {
+		return
+	}
+	sp.acked = true
+	if sample := now.Sub(sp.time); c.rtt == 0 {
+		c.rtt = sample
+	} else {
+		c.rtt += (sample - c.rtt) / 8
+	}
+	if len(sp.ids) == 0 {
+		return
+	}
+	keep := c.pending[:0]
+	for _, r := range c.pending {
+		acked := false
+		for _, id := range sp.ids {
+			if r.id == id {
+				acked = true
+				break
+			}
+		}
+		if !acked {
+			keep = append(keep, r)
+		}
+	}
+	clear(c.pending[len(keep):])
+	c.pending = keep
+}
+
+// receiveReliable delivers reliable messages in order,
+// holding on to the messages that arrive early.
+func (c *Conn) receiveReliable(id uint16, msg []byte) {
+	switch {
+	case id == c.expect:
+		c.expect++
+		c.deliver(msg)
+		for c.state == connConnected {
+			next, ok := c.early[c.expect]
+			if !ok {
+				break
+			}
+			delete(c.early, c.expect)
+			c.expect++
+			c.deliver(next)
+		}
+	case seqGreater(id, c.expect) && id-c.expect < maxPending:
+		c.early[id] = msg
+	}
+}
+
+// deliver passes a received message to its handler.
+func (c *Conn) deliver(msg []byte) {
+	h := &c.ep.h
+	body := msg[1:]
+	switch msg[0] {
+	case kindUser:
+		if h.message != nil {
+			h.message(c, body)
+		}
+	case kindRPC:
+		r := &reader{b: body}
+		name := string(r.bytes())
+		if fn := h.rpcs[name]; r.err == nil && fn != nil {
+			fn(c, r.b)
+		}
+	case kindSnapshot:
+		if c.ep.onSnapshot != nil {
+			c.ep.onSnapshot(c, body)
+		}
+	case kindSnapAck:
+		r := &reader{b: body}
+		if tick := uint32(r.uvarint()); r.err == nil && tick > c.snapAcked {
+			c.snapAcked = tick
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// interp.go smooths the received snapshots. Snapshots arrive at the
+// network tick rate with jitter, so clients render entities a small
+// delay in the past, blending between the two snapshots around the
+// render time. Entities are not extrapolated past the newest snapshot.
+
+import (
+	"slices"
+	"time"
+)
+
+// Interpolator buffers snapshots and blends their entity states
+// for rendering, eg:
+//
+//	interp := net.NewInterpolator(100 * time.Millisecond)
+//	cl.OnSnapshot(func(s *net.Snapshot) { interp.Add(s, time.Now()) })
+//	...
+//	for _, st := range interp.Sample(time.Now()) { // each engine update.
+//		e := entities[st.ID]
+//		e.SetAt(float64(st.Values[0]), float64(st.Values[1]), float64(st.Values[2]))
+//	}
+type Interpolator struct {
+	delay  time.Duration // render time behind the server time.
+	snaps  []*Snapshot   // buffered snapshots ordered by tick.
+	origin time.Time     // local time of server time zero.
+}
+
+// NewInterpolator returns an interpolator that renders the given delay
+// behind the server. The delay should cover a couple of snapshot
+// intervals plus the network jitter, eg: 100ms for 20 snapshots
+// a second.
+func NewInterpolator(delay time.Duration) *Interpolator {
+	return &Interpolator{delay: max(delay, 0)}
+}
+
+// Add buffers a snapshot received at the given local time.
+// Snapshots older than the newest buffered snapshot are ignored.
+func (ip *Interpolator) Add(s *Snapshot, received time.Time) {
+	if n := len(ip.snaps); n > 0 && s.Tick <= ip.snaps[n-1].Tick {
+		return
+	}
+	ip.snaps = append(ip.snaps, s)
+
+	// the least delayed snapshot gives the best server clock estimate.
+	if origin := received.Add(-s.Time); ip.origin.IsZero() || origin.Before(ip.origin) {
+		ip.origin = origin
+	}
+}
+
+// Sample returns the entity states at the given local time less the
+// interpolation delay. Values of entities in both surrounding snapshots
+// are linearly blended. Entities appear and disappear at the snapshot
+// where they were added or removed. The returned states are copies.
+func (ip *Interpolator) Sample(now time.Time) []State {
+	if len(ip.snaps) == 0 {
+		return nil
+	}
+	at := now.Sub(ip.origin) - ip.delay
+
+	// drop snapshots older than the one before the render time.
+	i := 0
+	for i+1 < len(ip.snaps) && ip.snaps[i+1].Time <= at {
+		i++
+	}
+	clear(ip.snaps[:i])
+	ip.snaps = ip.snaps[i:]
+	a := ip.snaps[0]
+	if len(ip.snaps) == 1 || at <= a.Time {
+		return copyStates(a.States)
+	}
+	b := ip.snaps[1]
+	t := float32(at-a.Time) / float32(b.Time-a.Time)
+	states := copyStates(a.States)
+	for i := range states {
+		st := &states[i]
+		next := b.Get(st.ID)
+		if next == nil || next.Kind != st.Kind || len(next.Values) != len(st.Values) {
+			continue
+		}
+		for j, v := range st.Values {
+			st.Values[j] = v + (next.Values[j]-v)*t
+		}
+	}
+	return states
+}
+
+// copyStates returns a deep copy of the states.
+func copyStates(states []State) []State {
+	out := make([]State, len(states))
+	for i, st := range states {
+		out[i] = State{ID: st.ID, Kind: st.Kind, Values: slices.Clone(st.Values)}
+	}
+	return out
+}
@@ -0,0 +1,317 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+// Package net connects game clients to a game server for multiplayer
+// prototypes. Connections send messages over UDP with optional
+// reliable, ordered delivery. The server replicates entity state to
+// the clients as delta compressed snapshots that clients smooth out
+// with an Interpolator. Named remote procedure calls are sent as
+// reliable messages, eg:
+//
+//	srv, err := net.Listen(":7777", net.Config{Protocol: 42})
+//	srv.Handle("chat", func(c *net.Conn, args []byte) { srv.Broadcast(args, true) })
+//	...
+//	srv.SendSnapshot(states) // each network tick.
+//	srv.Update(time.Now())   // each engine update.
+//
+//	cl, err := net.Dial("localhost:7777", net.Config{Protocol: 42})
+//	cl.OnSnapshot(func(s *net.Snapshot) { interp.Add(s, time.Now()) })
+//	cl.Conn().Call("chat", []byte("hello"))
+//	...
+//	cl.Update(time.Now())    // each engine update.
+//
+// The packets can also be carried by TCP or WebSocket transports for
+// networks that block UDP. The game code is the same for each transport.
+// A server can listen on several transports, and clients fall back to
+// the next transport if connecting fails, see Config.Transports.
+//
+// Servers and clients are not safe for concurrent use. Received packets
+// are processed, and the handlers are called, by Update so that games
+// can change their state from the handlers without locking.
+//
+// Package net is provided as part of the vu (virtual universe) 3D engine.
+package net
+
+// net.go has the packet layer shared by clients and servers.
+//	 conn.go     : packet acks and reliable messages.
+//	 server.go   : accepting clients.
+//	 client.go   : connecting to a server.
+//	 snapshot.go : delta compressed entity state.
+//	 interp.go   : client side snapshot interpolation.
+//	 transport.go: UDP, TCP, and WebSocket packet transports.
+//	 websocket.go: WebSocket handshake and framing.
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Errors passed to the disconnect handlers.
+var (
+	ErrTimeout = errors.New("connection timed out")
+	ErrDenied  = errors.New("connection denied")
+	ErrClosed  = errors.New("connection closed")
+)
+
+// MaxMessage is the largest message, in bytes, that can be sent. Larger
+// data, like big snapshots, must be split by the application.
+const MaxMessage = 1100
+
+// Config tunes the connections. Zero values use the defaults.
+type Config struct {
+	Protocol   uint32        // application protocol, connections need matching values.
+	Timeout    time.Duration // disconnect after no packets for this long, default 5s.
+	Resend     time.Duration // min wait before resending reliable messages, default 100ms.
+	Keepalive  time.Duration // max time between packets, default 100ms.
+	MaxClients int           // server connection limit, default 16.
+
+	// Transports carry the packets, default UDP. Servers listen on each
+	// transport, so use a fixed port since port 0 picks a different port
+	// for UDP and TCP. TCP and WebSocket transports share one TCP port.
+	// Clients try each transport in order, falling back to the next
+	// one if connecting times out, eg: []Transport{UDP, WebSocket("/vu")}
+	Transports []Transport
+}
+
+// defaults fills in the unset configuration values.
+func (cfg Config) defaults() Config {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Resend <= 0 {
+		cfg.Resend = 100 * time.Millisecond
+	}
+	if cfg.Keepalive <= 0 {
+		cfg.Keepalive = 100 * time.Millisecond
+	}
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = 16
+	}
+	if len(cfg.Transports) == 0 {
+		cfg.Transports = []Transport{UDP}
+	}
+	return cfg
+}
+
+// packet types.
+const (
+	packetConnect    byte = iota + 1 // client asks to connect.
+	packetAccept                     // server accepts a client.
+	packetDeny                       // server is full.
+	packetData                       // acks and messages.
+	packetDisconnect                 // either side is closing.
+)
+
+// packetMagic starts every packet, followed by the application protocol.
+const packetMagic = 0x766e // "vn"
+
+// maxPacket is the largest packet, small enough to avoid IP fragmentation.
+const maxPacket = 1200
+
+// headerSize is the packet magic, protocol, and type.
+const headerSize = 2 + 4 + 1
+
+// message kinds, the first byte of each message.
+const (
+	kindUser     byte = iota // application message.
+	kindRPC                  // remote procedure call.
+	kindSnapshot             // server entity snapshot.
+	kindSnapAck              // client snapshot ack.
+)
+
+// handlers are the application callbacks.
+type handlers struct {
+	connect    func(c *Conn)
+	disconnect func(c *Conn, err error)
+	message    func(c *Conn, msg []byte)
+	snapshot   func(s *Snapshot)
+	rpcs       map[string]func(c *Conn, args []byte)
+}
+
+// received is a packet from a socket reader.
+type received struct {
+	pc   net.PacketConn // socket that received the packet.
+	addr net.Addr
+	data []byte
+}
+
+// endpoint is the sockets shared by the connections of a client or server.
+type endpoint struct {
+	pcs  []net.PacketConn // one socket for each transport.
+	cfg  Config
+	in   chan received // packets from the reader goroutines.
+	done chan struct{} // closed to stop the readers.
+	wg   sync.WaitGroup
+	h    handlers
+
+	// onSnapshot decodes snapshot messages on clients.
+	onSnapshot func(c *Conn, body []byte)
+
+	// drop is a test hook that drops sent packets.
+	drop func(data []byte) bool
+}
+
+// newEndpoint starts reading packets from the sockets.
+func newEndpoint(pcs []net.PacketConn, cfg Config) *endpoint {
+	ep := &endpoint{pcs: pcs, cfg: cfg.defaults(), in: make(chan received, 256), done: make(chan struct{})}
+	ep.h.rpcs = map[string]func(c *Conn, args []byte){}
+	for _, pc := range pcs {
+		ep.wg.Add(1)
+		go ep.read(pc)
+	}
+	return ep
+}
+
+// read passes packets with the expected protocol to the update loop.
+// Packets are dropped if the update loop falls behind.
+func (ep *endpoint) read(pc net.PacketConn) {
+	defer ep.wg.Done()
+	buf := make([]byte, 2*maxPacket)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-ep.done:
+				return
+			default:
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue // eg: ICMP port unreachable on some platforms.
+			}
+		}
+		if n < headerSize || binary.LittleEndian.Uint16(buf) != packetMagic ||
+			binary.LittleEndian.Uint32(buf[2:]) != ep.cfg.Protocol {
+			continue
+		}
+		select {
+		case ep.in <- received{pc: pc, addr: addr, data: append([]byte{}, buf[:n]...)}:
+		default:
+		}
+	}
+}
+
+// header starts a packet of the given type.
+func (ep *endpoint) header(kind byte) []byte {
+	pkt := make([]byte, headerSize, maxPacket)
+	binary.LittleEndian.PutUint16(pkt, packetMagic)
+	binary.LittleEndian.PutUint32(pkt[2:], ep.cfg.Protocol)
+	pkt[6] = kind
+	return pkt
+}
+
+// send writes a packet, ignoring errors since packets can be lost anyway.
+func (ep *endpoint) send(pc net.PacketConn, addr net.Addr, pkt []byte) {
+	if ep.drop != nil && ep.drop(pkt) {
+		return
+	}
+	pc.WriteTo(pkt, addr)
+}
+
+// close stops the readers and closes the sockets.
+// Closing more than once does nothing.
+func (ep *endpoint) close() error {
+	select {
+	case <-ep.done:
+		return nil
+	default:
+	}
+	close(ep.done)
+	var errs []error
+	for _, pc := range ep.pcs {
+		errs = append(errs, pc.Close())
+	}
+	ep.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// handle registers a remote procedure call handler.
+func (ep *endpoint) handle(name string, fn func(c *Conn, args []byte)) {
+	if fn == nil {
+		delete(ep.h.rpcs, name)
+		return
+	}
+	ep.h.rpcs[name] = fn
+}
+
+// =============================================================================
+// wire format helpers.
+
+// seqGreater returns true if sequence a is newer than b, allowing for wrap.
+func seqGreater(a, b uint16) bool { return int16(a-b) > 0 }
+
+// appendString adds a length prefixed string.
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// reader decodes wire data, remembering the first error.
+type reader struct {
+	b   []byte
+	err error
+}
+
+// errShort is returned for truncated data.
+var errShort = errors.New("net: short data")
+
+// byte reads one byte.
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.b) < 1 {
+		r.err = errShort
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+// u16 reads a little endian uint16.
+func (r *reader) u16() uint16 {
+	if r.err != nil || len(r.b) < 2 {
+		r.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+// u32 reads a little endian uint32.
+func (r *reader) u32() uint32 {
+	if r.err != nil || len(r.b) < 4 {
+		r.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+// uvarint reads a variable length unsigned integer.
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errShort
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// bytes reads a length prefixed byte slice.
+func (r *reader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil || uint64(len(r.b)) < n {
+		r.err = errShort
+		return nil
+	}
+	v := r.b[:n:n]
+	r.b = r.b[n:]
+	return v
+}
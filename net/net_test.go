@@ -0,0 +1,247 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// go test -run Net
+func TestNet(t *testing.T) {
+	cfg := Config{Protocol: 42, Timeout: time.Second, Resend: 10 * time.Millisecond}
+
+	// connect starts a server with a connected client.
+	connect := func(t *testing.T) (*Server, *Client) {
+		srv, err := Listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		cl, err := Dial(srv.Addr().String(), cfg)
+		if err != nil {
+			t.Fatalf("dial %s", err)
+		}
+		if !pump(srv, cl, func() bool { return cl.Connected() && len(srv.Conns()) == 1 }) {
+			t.Fatalf("expected client to connect")
+		}
+		return srv, cl
+	}
+
+	// go test -run Net/connect
+	t.Run("connect", func(t *testing.T) {
+		srv, cl := connect(t)
+		var got error
+		srv.OnDisconnect(func(c *Conn, err error) { got = err })
+		cl.Close()
+		if !pump(srv, nil, func() bool { return got != nil }) || got != ErrClosed || len(srv.Conns()) != 0 {
+			t.Errorf("expected server to see the disconnect got %v", got)
+		}
+		srv.Close()
+	})
+
+	// go test -run Net/protocol
+	t.Run("protocol", func(t *testing.T) {
+		srv, err := Listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer srv.Close()
+		other := cfg
+		other.Protocol, other.Timeout = 7, 100*time.Millisecond
+		cl, _ := Dial(srv.Addr().String(), other)
+		defer cl.Close()
+		var got error
+		cl.OnDisconnect(func(c *Conn, err error) { got = err })
+		if pump(srv, cl, func() bool { return got != nil }); got != ErrTimeout || len(srv.Conns()) != 0 {
+			t.Errorf("expected mismatched protocol to time out got %v", got)
+		}
+	})
+
+	// go test -run Net/reliable
+	t.Run("reliable", func(t *testing.T) {
+		srv, cl := connect(t)
+		defer srv.Close()
+		defer cl.Close()
+		drops := 0
+		cl.ep.drop = func(pkt []byte) bool { drops++; return drops%3 != 0 } // lose 2 of 3.
+		got := []string{}
+		srv.OnMessage(func(c *Conn, msg []byte) { got = append(got, string(msg)) })
+		for i := 0; i < 50; i++ {
+			cl.Conn().Send([]byte(fmt.Sprintf("m%d", i)), true)
+			pump(srv, cl, nil)
+		}
+		pump(srv, cl, func() bool { return len(got) == 50 })
+		if len(got) != 50 {
+			t.Fatalf("expected all messages got %d", len(got))
+		}
+		for i, m := range got {
+			if m != fmt.Sprintf("m%d", i) {
+				t.Fatalf("expected ordered messages got %s at %d", m, i)
+			}
+		}
+		if len(cl.Conn().pending) != 0 {
+			pump(srv, cl, func() bool { return len(cl.Conn().pending) == 0 })
+		}
+		if len(cl.Conn().pending) != 0 || cl.Conn().RTT() <= 0 {
+			t.Errorf("expected messages to be acked")
+		}
+	})
+
+	// go test -run Net/rpc
+	t.Run("rpc", func(t *testing.T) {
+		srv, cl := connect(t)
+		defer srv.Close()
+		defer cl.Close()
+		srv.Handle("echo", func(c *Conn, args []byte) { c.Call("reply", args) })
+		reply := ""
+		cl.Handle("reply", func(c *Conn, args []byte) { reply = string(args) })
+		cl.Conn().Call("unknown", nil) // ignored.
+		cl.Conn().Call("echo", []byte("hi"))
+		if !pump(srv, cl, func() bool { return reply != "" }) || reply != "hi" {
+			t.Errorf("expected reply got %q", reply)
+		}
+		if err := cl.Conn().Send(make([]byte, MaxMessage+1), true); err == nil {
+			t.Errorf("expected message size error")
+		}
+	})
+
+	// go test -run Net/snapshot
+	t.Run("snapshot", func(t *testing.T) {
+		srv, cl := connect(t)
+		defer srv.Close()
+		defer cl.Close()
+		var got *Snapshot
+		cl.OnSnapshot(func(s *Snapshot) { got = s })
+		states := []State{{ID: 2, Kind: 1, Values: []float32{1, 2, 3}}, {ID: 1, Kind: 1, Values: []float32{4}}}
+		srv.SendSnapshot(states)
+		if !pump(srv, cl, func() bool { return got != nil }) || len(got.States) != 2 || got.States[0].ID != 1 {
+			t.Fatalf("expected sorted snapshot got %+v", got)
+		}
+		pump(srv, cl, func() bool { return srv.Conns()[0].snapAcked == 1 })
+
+		// the next snapshot is a delta from the acked one.
+		states[0].Values[1] = 5
+		states = append(states[:1], State{ID: 3, Kind: 2, Values: []float32{6}})
+		srv.SendSnapshot(states)
+		if !pump(srv, cl, func() bool { return got.Tick == 2 }) {
+			t.Fatalf("expected second snapshot")
+		}
+		if s := got.Get(2); s == nil || s.Values[1] != 5 || s.Values[2] != 3 {
+			t.Errorf("expected changed value got %+v", s)
+		}
+		if got.Get(1) != nil || got.Get(3) == nil {
+			t.Errorf("expected removed and added entities got %+v", got.States)
+		}
+		if err := srv.SendSnapshot([]State{{ID: 1, Values: make([]float32, MaxValues+1)}}); err == nil {
+			t.Errorf("expected too many values error")
+		}
+	})
+
+	// go test -run Net/delta
+	t.Run("delta", func(t *testing.T) {
+		base, _ := newSnapshot(1, 0, []State{{ID: 1, Values: []float32{1, 2, 3, 4}}, {ID: 2, Values: []float32{1}}})
+		next, _ := newSnapshot(2, 50*time.Millisecond, []State{{ID: 1, Values: []float32{1, 2, 9, 4}}, {ID: 2, Values: []float32{1}}})
+		full, delta := encodeSnapshot(next, nil), encodeSnapshot(next, base)
+		if len(delta) >= len(full) {
+			t.Errorf("expected delta %d to be smaller than %d", len(delta), len(full))
+		}
+		h := &history{}
+		if _, err := decodeSnapshot(delta, h); err == nil {
+			t.Errorf("expected missing base error")
+		}
+		h.add(base)
+		s, err := decodeSnapshot(delta, h)
+		if err != nil || s.Time != next.Time || s.Get(1).Values[2] != 9 || s.Get(2) == nil {
+			t.Errorf("expected decoded delta got %+v %v", s, err)
+		}
+	})
+
+	// go test -run Net/timeout
+	t.Run("timeout", func(t *testing.T) {
+		srv, cl := connect(t)
+		defer srv.Close()
+		defer cl.Close()
+		var got error
+		srv.OnDisconnect(func(c *Conn, err error) { got = err })
+		cl.ep.drop = func(pkt []byte) bool { return true }
+		if !pump(srv, cl, func() bool { return got != nil }) || got != ErrTimeout {
+			t.Errorf("expected timeout got %v", got)
+		}
+	})
+
+	// go test -run Net/full
+	t.Run("full", func(t *testing.T) {
+		one := cfg
+		one.MaxClients = 1
+		srv, err := Listen("127.0.0.1:0", one)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer srv.Close()
+		a, _ := Dial(srv.Addr().String(), one)
+		defer a.Close()
+		pump(srv, a, a.Connected)
+		b, _ := Dial(srv.Addr().String(), one)
+		defer b.Close()
+		var got error
+		b.OnDisconnect(func(c *Conn, err error) { got = err })
+		if pump(srv, b, func() bool { return got != nil }); got != ErrDenied {
+			t.Errorf("expected denied got %v", got)
+		}
+	})
+}
+
+// go test -run Interpolator
+func TestInterpolator(t *testing.T) {
+	ms := time.Millisecond
+	snap := func(tick uint32, x float32) *Snapshot {
+		return &Snapshot{Tick: tick, Time: time.Duration(tick) * 50 * ms, States: []State{{ID: 1, Values: []float32{x}}}}
+	}
+	ip := NewInterpolator(100 * ms)
+	if ip.Sample(time.Now()) != nil {
+		t.Errorf("expected no states")
+	}
+	start := time.Now()
+	ip.Add(snap(1, 0), start.Add(70*ms)) // late packet.
+	ip.Add(snap(2, 10), start.Add(100*ms))
+	ip.Add(snap(3, 20), start.Add(150*ms))
+	ip.Add(snap(2, 99), start.Add(160*ms)) // old, ignored.
+
+	// server time 50ms is local time 50ms.
+	if x := ip.Sample(start.Add(175 * ms))[0].Values[0]; x != 5 {
+		t.Errorf("expected halfway value got %f", x)
+	}
+	if x := ip.Sample(start.Add(200 * ms))[0].Values[0]; x != 10 {
+		t.Errorf("expected snapshot value got %f", x)
+	}
+	if len(ip.snaps) != 2 {
+		t.Errorf("expected old snapshots to be dropped got %d", len(ip.snaps))
+	}
+	if x := ip.Sample(start.Add(time.Second))[0].Values[0]; x != 20 {
+		t.Errorf("expected no extrapolation got %f", x)
+	}
+}
+
+// pump updates the server and client until done returns true or the
+// test times out. A nil done runs one short round of updates.
+func pump(srv *Server, cl *Client, done func() bool) bool {
+	end := time.Now().Add(3 * time.Second)
+	if done == nil {
+		end = time.Now().Add(5 * time.Millisecond)
+	}
+	for time.Now().Before(end) {
+		now := time.Now()
+		if srv != nil {
+			srv.Update(now)
+		}
+		if cl != nil {
+			cl.Update(now)
+		}
+		if done != nil && done() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done == nil
+}
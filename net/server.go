@@ -0,0 +1,189 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// server.go accepts client connections and sends them snapshots.
+
+import (
+	"net"
+	"slices"
+	"time"
+)
+
+// Server accepts client connections. Create servers using Listen.
+type Server struct {
+	ep    *endpoint
+	conns []*Conn          // connections in the order they were accepted.
+	addrs map[string]*Conn // connections by client address, see addrKey.
+	snaps history          // sent snapshots, the baselines for deltas.
+	tick  uint32           // last snapshot tick.
+	start time.Time        // time of the first update.
+	now   time.Time        // time of the last update.
+}
+
+// Listen creates a server listening for clients on the given address,
+// eg: ":7777", using each of the configured transports.
+func Listen(addr string, cfg Config) (*Server, error) {
+	cfg = cfg.defaults()
+	pcs, err := listen(addr, cfg.Transports)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{ep: newEndpoint(pcs, cfg), addrs: map[string]*Conn{}}, nil
+}
+
+// Addr returns the address the server is listening on
+// using the first transport.
+func (s *Server) Addr() net.Addr { return s.ep.pcs[0].LocalAddr() }
+
+// Addrs returns the listening address for each transport.
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.ep.pcs))
+	for i, pc := range s.ep.pcs {
+		addrs[i] = pc.LocalAddr()
+	}
+	return addrs
+}
+
+// OnConnect sets the function called when a client connects.
+func (s *Server) OnConnect(fn func(c *Conn)) *Server {
+	s.ep.h.connect = fn
+	return s
+}
+
+// OnDisconnect sets the function called when a client disconnects
+// or times out. The error is ErrClosed or ErrTimeout.
+func (s *Server) OnDisconnect(fn func(c *Conn, err error)) *Server {
+	s.ep.h.disconnect = fn
+	return s
+}
+
+// OnMessage sets the function called for each client message.
+// The message is only valid for the duration of the call.
+func (s *Server) OnMessage(fn func(c *Conn, msg []byte)) *Server {
+	s.ep.h.message = fn
+	return s
+}
+
+// Handle sets the function called for remote procedure calls of the
+// given name, see Conn.Call. A nil function removes the handler.
+func (s *Server) Handle(name string, fn func(c *Conn, args []byte)) *Server {
+	s.ep.handle(name, fn)
+	return s
+}
+
+// Conns returns the connected clients.
+func (s *Server) Conns() []*Conn { return s.conns }
+
+// Broadcast sends the message to all connected clients.
+func (s *Server) Broadcast(msg []byte, reliable bool) error {
+	for _, c := range s.conns {
+		if err := c.Send(msg, reliable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendSnapshot sends the entity states to the connected clients as
+// the next snapshot. Each client is sent the changes since the last
+// snapshot it received. Snapshots are sent with the next Update.
+func (s *Server) SendSnapshot(states []State) error {
+	snap, err := newSnapshot(s.tick+1, s.now.Sub(s.start), states)
+	if err != nil {
+		return err
+	}
+	s.tick = snap.Tick
+	s.snaps.add(snap)
+	for _, c := range s.conns {
+		if err := c.queue(kindSnapshot, encodeSnapshot(snap, s.snaps.get(c.snapAcked)), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update processes the received packets, calling the handlers,
+// drops the connections that have timed out, and sends the
+// queued messages. Call Update once each engine update.
+func (s *Server) Update(now time.Time) {
+	if s.start.IsZero() {
+		s.start = now
+	}
+	s.now = now
+	for n := len(s.ep.in); n > 0; n-- {
+		s.receive(<-s.ep.in, now)
+	}
+	for _, c := range slices.Clone(s.conns) { // remove changes the list.
+		switch {
+		case c.state == connClosed:
+			s.remove(c, nil)
+		case now.Sub(c.lastRecv) > s.ep.cfg.Timeout:
+			c.state = connClosed
+			s.remove(c, ErrTimeout)
+		default:
+			c.flush(now)
+		}
+	}
+}
+
+// receive handles one packet.
+func (s *Server) receive(p received, now time.Time) {
+	key := addrKey(p.addr)
+	c := s.addrs[key]
+	switch p.data[headerSize-1] {
+	case packetConnect:
+		switch {
+		case c != nil:
+			s.ep.send(p.pc, p.addr, s.ep.header(packetAccept)) // accept was lost.
+		case len(s.conns) >= s.ep.cfg.MaxClients:
+			s.ep.send(p.pc, p.addr, s.ep.header(packetDeny))
+		default:
+			c = newConn(s.ep, p.pc, p.addr, connConnected, now)
+			s.conns = append(s.conns, c)
+			s.addrs[key] = c
+			s.ep.send(p.pc, p.addr, s.ep.header(packetAccept))
+			if s.ep.h.connect != nil {
+				s.ep.h.connect(c)
+			}
+		}
+	case packetData:
+		if c != nil {
+			c.receive(p.data[headerSize:], now)
+		}
+	case packetDisconnect:
+		if c != nil && c.state != connClosed {
+			c.state = connClosed
+			s.remove(c, ErrClosed)
+		}
+	}
+}
+
+// remove removes a closed connection. The disconnect handler is
+// called unless the server closed the connection.
+func (s *Server) remove(c *Conn, err error) {
+	key := addrKey(c.addr)
+	if s.addrs[key] != c {
+		return // already dropped.
+	}
+	delete(s.addrs, key)
+	if i := slices.Index(s.conns, c); i >= 0 {
+		s.conns = slices.Delete(s.conns, i, i+1)
+	}
+	if err != nil && s.ep.h.disconnect != nil {
+		s.ep.h.disconnect(c, err)
+	}
+}
+
+// Close disconnects the clients and stops the server.
+func (s *Server) Close() error {
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns, s.addrs = nil, map[string]*Conn{}
+	return s.ep.close()
+}
+
+// addrKey identifies a client by transport and address since clients
+// using different transports can have the same address.
+func addrKey(addr net.Addr) string { return addr.Network() + " " + addr.String() }
@@ -0,0 +1,212 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// snapshot.go replicates entity state from the server to the clients.
+// Each snapshot is encoded as a delta against the newest snapshot that
+// the client has acked, so only new entities, changed values, and
+// removed entities are sent. Snapshots are sent unreliably since a lost
+// snapshot is replaced by the next one.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// MaxValues is the most values in one entity State.
+const MaxValues = 64
+
+// historySize is the number of snapshots kept as delta baselines.
+const historySize = 64
+
+// State is the replicated state of one entity.
+type State struct {
+	ID     uint32    // entity id, unique within a snapshot.
+	Kind   uint16    // application entity type, eg: to spawn the entity.
+	Values []float32 // replicated values, eg: location and rotation.
+}
+
+// Snapshot is the replicated state of the server entities at one tick.
+type Snapshot struct {
+	Tick   uint32        // snapshot number, starting at 1.
+	Time   time.Duration // server time when the snapshot was taken.
+	States []State       // entity states ordered by ID.
+}
+
+// Get returns the state for the given entity id, nil if the
+// entity is not in the snapshot.
+func (s *Snapshot) Get(id uint32) *State {
+	i, ok := slices.BinarySearchFunc(s.States, id, func(st State, id uint32) int {
+		return int(int64(st.ID) - int64(id))
+	})
+	if !ok {
+		return nil
+	}
+	return &s.States[i]
+}
+
+// newSnapshot copies and sorts the states.
+func newSnapshot(tick uint32, at time.Duration, states []State) (*Snapshot, error) {
+	s := &Snapshot{Tick: tick, Time: at, States: make([]State, len(states))}
+	for i, st := range states {
+		if len(st.Values) > MaxValues {
+			return nil, fmt.Errorf("net: state %d has %d values, max %d", st.ID, len(st.Values), MaxValues)
+		}
+		s.States[i] = State{ID: st.ID, Kind: st.Kind, Values: slices.Clone(st.Values)}
+	}
+	slices.SortFunc(s.States, func(a, b State) int { return int(int64(a.ID) - int64(b.ID)) })
+	for i := 1; i < len(s.States); i++ {
+		if s.States[i].ID == s.States[i-1].ID {
+			return nil, fmt.Errorf("net: duplicate state id %d", s.States[i].ID)
+		}
+	}
+	return s, nil
+}
+
+// history keeps recent snapshots by tick.
+type history [historySize]*Snapshot
+
+// add remembers a snapshot, replacing the oldest.
+func (h *history) add(s *Snapshot) { h[s.Tick%historySize] = s }
+
+// get returns the snapshot for the tick, nil if it is not available.
+func (h *history) get(tick uint32) *Snapshot {
+	if s := h[tick%historySize]; s != nil && s.Tick == tick && tick != 0 {
+		return s
+	}
+	return nil
+}
+
+// state record types.
+const (
+	recordDelta byte = iota // changed values of an existing entity.
+	recordFull              // all values of a new entity.
+)
+
+// encodeSnapshot encodes the snapshot as changes from the base snapshot.
+// A nil base encodes the full snapshot.
+//
+//	tick, base tick (0 for none), time in microseconds
+//	record count, records: id, type, kind and values or value mask and changed values
+//	removed count, removed ids
+func encodeSnapshot(s, base *Snapshot) []byte {
+	b := binary.AppendUvarint(nil, uint64(s.Tick))
+	if base == nil {
+		base = &Snapshot{}
+	}
+	b = binary.AppendUvarint(b, uint64(base.Tick))
+	b = binary.AppendUvarint(b, uint64(s.Time/time.Microsecond))
+
+	// new and changed entities.
+	records, count := []byte{}, 0
+	for i := range s.States {
+		st := &s.States[i]
+		old := base.Get(st.ID)
+		if old == nil || old.Kind != st.Kind || len(old.Values) != len(st.Values) {
+			records = binary.AppendUvarint(records, uint64(st.ID))
+			records = append(records, recordFull)
+			records = binary.AppendUvarint(records, uint64(st.Kind))
+			records = append(records, byte(len(st.Values)))
+			for _, v := range st.Values {
+				records = binary.LittleEndian.AppendUint32(records, math.Float32bits(v))
+			}
+			count++
+			continue
+		}
+		mask := uint64(0)
+		for j, v := range st.Values {
+			if math.Float32bits(v) != math.Float32bits(old.Values[j]) {
+				mask |= 1 << j
+			}
+		}
+		if mask == 0 {
+			continue
+		}
+		records = binary.AppendUvarint(records, uint64(st.ID))
+		records = append(records, recordDelta)
+		records = binary.AppendUvarint(records, mask)
+		for j, v := range st.Values {
+			if mask&(1<<j) != 0 {
+				records = binary.LittleEndian.AppendUint32(records, math.Float32bits(v))
+			}
+		}
+		count++
+	}
+	b = binary.AppendUvarint(b, uint64(count))
+	b = append(b, records...)
+
+	// removed entities.
+	removed := []uint32{}
+	for _, st := range base.States {
+		if s.Get(st.ID) == nil {
+			removed = append(removed, st.ID)
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(removed)))
+	for _, id := range removed {
+		b = binary.AppendUvarint(b, uint64(id))
+	}
+	return b
+}
+
+// decodeSnapshot applies an encoded snapshot to its base snapshot,
+// which is looked up from the client history.
+func decodeSnapshot(data []byte, h *history) (*Snapshot, error) {
+	r := &reader{b: data}
+	tick, baseTick := uint32(r.uvarint()), uint32(r.uvarint())
+	at := time.Duration(r.uvarint()) * time.Microsecond
+	if r.err != nil {
+		return nil, r.err
+	}
+	base := &Snapshot{}
+	if baseTick != 0 {
+		if base = h.get(baseTick); base == nil {
+			return nil, fmt.Errorf("net: snapshot %d missing base %d", tick, baseTick)
+		}
+	}
+	states := map[uint32]State{}
+	for _, st := range base.States {
+		states[st.ID] = st
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		id, typ := uint32(r.uvarint()), r.byte()
+		switch typ {
+		case recordFull:
+			st := State{ID: id, Kind: uint16(r.uvarint()), Values: make([]float32, r.byte())}
+			for i := range st.Values {
+				st.Values[i] = math.Float32frombits(r.u32())
+			}
+			states[id] = st
+		case recordDelta:
+			old, ok := states[id]
+			mask := r.uvarint()
+			if !ok || mask>>len(old.Values) != 0 {
+				return nil, fmt.Errorf("net: snapshot %d bad delta for %d", tick, id)
+			}
+			st := State{ID: id, Kind: old.Kind, Values: slices.Clone(old.Values)}
+			for i := range st.Values {
+				if mask&(1<<i) != 0 {
+					st.Values[i] = math.Float32frombits(r.u32())
+				}
+			}
+			states[id] = st
+		default:
+			return nil, fmt.Errorf("net: snapshot %d bad record type %d", tick, typ)
+		}
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		delete(states, uint32(r.uvarint()))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	s := &Snapshot{Tick: tick, Time: at, States: make([]State, 0, len(states))}
+	for _, st := range states {
+		s.States = append(s.States, st)
+	}
+	slices.SortFunc(s.States, func(a, b State) int { return int(int64(a.ID) - int64(b.ID)) })
+	return s, nil
+}
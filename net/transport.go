@@ -0,0 +1,519 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// transport.go carries packets over UDP, TCP, or WebSockets.
+// The stream transports wrap their connections as packet sockets so
+// that the connection layer works the same over each transport.
+// Packets sent over streams are not lost, so reliable messages are
+// acked on the first try and never resent.
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transport carries packets between clients and servers,
+// see Config.Transports.
+type Transport interface {
+	// Listen returns a socket that receives packets from any client.
+	Listen(addr string) (net.PacketConn, error)
+
+	// Dial returns a socket for packets to and from the server,
+	// along with the server address used to send the packets.
+	Dial(addr string) (pc net.PacketConn, raddr net.Addr, err error)
+}
+
+// UDP sends packets as UDP datagrams. It is the default transport and
+// has the lowest latency since lost packets don't delay later packets.
+var UDP Transport = udpTransport{}
+
+// TCP sends length prefixed packets over TCP connections. Use TCP for
+// networks that block UDP. Packets are not lost, but a lost TCP segment
+// delays the packets that follow it.
+var TCP Transport = streamTransport{network: "tcp"}
+
+// WebSocket returns a transport that sends packets as binary WebSocket
+// messages to the given HTTP path, eg: "/vu". Use WebSockets for
+// networks that only allow web traffic, and for browser clients.
+func WebSocket(path string) Transport {
+	return streamTransport{network: "ws", path: path}
+}
+
+// udpTransport uses UDP sockets.
+type udpTransport struct{}
+
+// Listen returns a UDP socket bound to the given address.
+func (udpTransport) Listen(addr string) (net.PacketConn, error) {
+	return net.ListenPacket("udp", addr)
+}
+
+// Dial returns a UDP socket bound to any local port.
+func (udpTransport) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc, err := net.ListenPacket("udp", ":0")
+	return pc, raddr, err
+}
+
+// streamTransport uses TCP connections, with WebSocket framing if
+// the network is "ws".
+type streamTransport struct {
+	network string // "tcp" or "ws".
+	path    string // WebSocket HTTP path.
+}
+
+// Listen accepts TCP connections, or WebSocket connections
+// on the transport path.
+func (t streamTransport) Listen(addr string) (net.PacketConn, error) {
+	sl, err := listenStream(addr)
+	if err != nil {
+		return nil, err
+	}
+	sc, _ := sl.add(t) // the first transport is never a duplicate.
+	go sl.accept()
+	return sc, nil
+}
+
+// listen returns a socket for each transport. The stream transports
+// share one TCP listener so that servers can use TCP and WebSockets
+// on the same port.
+func listen(addr string, transports []Transport) ([]net.PacketConn, error) {
+	var sl *streamListener
+	pcs := []net.PacketConn{}
+	for _, t := range transports {
+		var pc net.PacketConn
+		var err error
+		if st, ok := t.(streamTransport); ok {
+			if sl == nil {
+				sl, err = listenStream(addr)
+			}
+			if err == nil {
+				pc, err = sl.add(st)
+			}
+		} else {
+			pc, err = t.Listen(addr)
+		}
+		if err != nil {
+			for _, pc := range pcs {
+				pc.Close()
+			}
+			return nil, err
+		}
+		pcs = append(pcs, pc)
+	}
+	if sl != nil {
+		go sl.accept()
+	}
+	return pcs, nil
+}
+
+// Dial connects to the server in the background. Packets sent
+// before the connection is ready are dropped.
+func (t streamTransport) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, nil, err
+	}
+	sc := newStreamConn(t.network, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.stop = cancel
+	go func() {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return // the client times out.
+		}
+		p := &streamPeer{conn: conn, r: bufio.NewReader(conn)}
+		if t.network == "ws" {
+			p.ws, p.client = true, true
+			if p.r, err = wsHandshake(conn, addr, t.path); err != nil {
+				conn.Close()
+				return
+			}
+		}
+		sc.serve(p, addr)
+	}()
+	return sc, streamAddr{network: t.network, addr: addr}, nil
+}
+
+// streamTimeout limits how long stream connections wait for
+// the first bytes from a new peer, and for each write.
+const streamTimeout = 5 * time.Second
+
+// streamQueue is the number of packets waiting to be written
+// to a peer. Peers that fall further behind are disconnected.
+const streamQueue = 256
+
+// streamListener accepts TCP connections for the stream transports,
+// passing each one to the TCP socket or to the WebSocket server.
+type streamListener struct {
+	ln  net.Listener
+	web *connListener // connections for the WebSocket server.
+	srv *http.Server  // upgrades WebSocket connections.
+
+	mu    sync.Mutex
+	tcp   *streamConn            // raw TCP connections, nil if unused.
+	ws    map[string]*streamConn // WebSocket connections by path.
+	users int                    // open sockets, the last one closes the listener.
+}
+
+// listenStream creates a TCP listener for the stream transports.
+func listenStream(addr string) (*streamListener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sl := &streamListener{ln: ln, ws: map[string]*streamConn{}}
+	sl.web = &connListener{addr: ln.Addr(), conns: make(chan net.Conn), done: make(chan struct{})}
+	sl.srv = &http.Server{Handler: http.HandlerFunc(sl.upgrade)}
+	return sl, nil
+}
+
+// add returns a socket for the stream transport.
+func (sl *streamListener) add(t streamTransport) (*streamConn, error) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	switch {
+	case t.network == "tcp" && sl.tcp != nil:
+		return nil, errors.New("net: duplicate TCP transport")
+	case t.network == "ws" && sl.ws[t.path] != nil:
+		return nil, fmt.Errorf("net: duplicate WebSocket path %s", t.path)
+	}
+	sc := newStreamConn(t.network, sl.ln.Addr().String())
+	if t.network == "tcp" {
+		sl.tcp = sc
+	} else {
+		sl.ws[t.path] = sc
+	}
+	sl.users++
+	sc.stop = func() { sl.remove(t) }
+	return sc, nil
+}
+
+// remove stops passing connections to the transport socket.
+func (sl *streamListener) remove(t streamTransport) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if t.network == "tcp" {
+		sl.tcp = nil
+	} else {
+		delete(sl.ws, t.path)
+	}
+	if sl.users--; sl.users == 0 {
+		sl.ln.Close()
+		sl.srv.Close()
+	}
+}
+
+// accept passes new connections to the transports until
+// the listener closes.
+func (sl *streamListener) accept() {
+	go sl.srv.Serve(sl.web)
+	for {
+		conn, err := sl.ln.Accept()
+		if err != nil {
+			return // closed.
+		}
+		go sl.route(conn)
+	}
+}
+
+// route passes a new connection to the TCP socket or the WebSocket
+// server. Listeners with both check the first bytes for an HTTP
+// request. TCP packets start with a length less than MaxMessage,
+// which never matches "GET ".
+func (sl *streamListener) route(conn net.Conn) {
+	sl.mu.Lock()
+	tcp, ws := sl.tcp, len(sl.ws) > 0
+	sl.mu.Unlock()
+	br := bufio.NewReader(conn)
+	web := tcp == nil
+	if tcp != nil && ws {
+		conn.SetReadDeadline(time.Now().Add(streamTimeout))
+		head, err := br.Peek(4)
+		conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			conn.Close()
+			return
+		}
+		web = string(head) == "GET "
+	}
+	if !web {
+		tcp.serve(&streamPeer{conn: conn, r: br}, conn.RemoteAddr().String())
+		return
+	}
+	sl.web.push(peekConn{Conn: conn, r: br})
+}
+
+// upgrade accepts WebSocket connections on the transport paths.
+func (sl *streamListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	sl.mu.Lock()
+	sc := sl.ws[r.URL.Path]
+	sl.mu.Unlock()
+	if sc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	conn, br, err := wsUpgrade(w, r)
+	if err != nil {
+		return // the upgrade wrote the error response.
+	}
+	sc.serve(&streamPeer{conn: conn, r: br, ws: true}, conn.RemoteAddr().String())
+}
+
+// connListener gives routed connections to the WebSocket server.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// push waits for the server to accept the connection.
+func (l *connListener) push(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept returns the next routed connection.
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections.
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the shared listener address.
+func (l *connListener) Addr() net.Addr { return l.addr }
+
+// peekConn is a connection whose first bytes were read to route it.
+type peekConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read returns the routing bytes before the rest of the connection.
+func (c peekConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// streamAddr is the address of a stream connection.
+type streamAddr struct {
+	network string
+	addr    string
+}
+
+// Network returns "tcp" or "ws".
+func (a streamAddr) Network() string { return a.network }
+
+// String returns the host and port.
+func (a streamAddr) String() string { return a.addr }
+
+// streamConn is a packet socket for stream connections.
+// Servers have a connection for each client.
+type streamConn struct {
+	local streamAddr
+	in    chan streamPacket // packets from the peer readers.
+	done  chan struct{}     // closed when the socket closes.
+	once  sync.Once
+	stop  func() // stops accepting or dialing connections.
+
+	mu    sync.Mutex
+	peers map[string]*streamPeer // connections by remote address.
+}
+
+// streamPacket is a packet read from a peer.
+type streamPacket struct {
+	addr streamAddr
+	data []byte
+}
+
+// newStreamConn creates a packet socket for stream connections.
+func newStreamConn(network, local string) *streamConn {
+	return &streamConn{
+		local: streamAddr{network: network, addr: local},
+		in:    make(chan streamPacket, 256),
+		done:  make(chan struct{}),
+		peers: map[string]*streamPeer{},
+	}
+}
+
+// serve reads packets from a connection until it fails or closes.
+func (sc *streamConn) serve(p *streamPeer, key string) {
+	sc.mu.Lock()
+	select {
+	case <-sc.done:
+		sc.mu.Unlock()
+		p.conn.Close()
+		return
+	default:
+	}
+	p.out, p.done = make(chan []byte, streamQueue), make(chan struct{})
+	go p.writeFrames()
+	sc.peers[key] = p
+	sc.mu.Unlock()
+	defer func() {
+		sc.mu.Lock()
+		delete(sc.peers, key)
+		sc.mu.Unlock()
+		close(p.done) // the writer closes the connection.
+	}()
+	addr := streamAddr{network: sc.local.network, addr: key}
+	for {
+		data, err := p.read()
+		if err != nil {
+			return
+		}
+		select {
+		case sc.in <- streamPacket{addr: addr, data: data}:
+		case <-sc.done:
+			return
+		default: // dropped, the reader is behind.
+		}
+	}
+}
+
+// ReadFrom waits for the next packet from any connection.
+func (sc *streamConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-sc.in:
+		return copy(b, p.data), p.addr, nil
+	case <-sc.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo queues a packet for the connection with the given address.
+// It doesn't wait for the packet to be written, and disconnects peers
+// that don't keep up.
+func (sc *streamConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	sc.mu.Lock()
+	p := sc.peers[addr.String()]
+	sc.mu.Unlock()
+	if p == nil {
+		return 0, fmt.Errorf("net: no connection to %s", addr)
+	}
+	if err := p.write(b); err != nil {
+		p.conn.Close() // the reader removes the peer.
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the connections and stops accepting new ones.
+func (sc *streamConn) Close() error {
+	sc.once.Do(func() {
+		close(sc.done)
+		sc.stop()
+		sc.mu.Lock()
+		for _, p := range sc.peers {
+			p.conn.Close()
+		}
+		sc.mu.Unlock()
+	})
+	return nil
+}
+
+// LocalAddr returns the listening address for servers.
+func (sc *streamConn) LocalAddr() net.Addr { return sc.local }
+
+// Deadlines are not supported.
+func (sc *streamConn) SetDeadline(t time.Time) error      { return errors.ErrUnsupported }
+func (sc *streamConn) SetReadDeadline(t time.Time) error  { return errors.ErrUnsupported }
+func (sc *streamConn) SetWriteDeadline(t time.Time) error { return errors.ErrUnsupported }
+
+// streamPeer is one stream connection. Packets are written by
+// a goroutine for each peer so that slow peers don't block the
+// socket writer.
+type streamPeer struct {
+	conn   net.Conn
+	r      io.Reader
+	ws     bool          // true for WebSocket framing.
+	client bool          // true for the client end of a WebSocket.
+	out    chan []byte   // frames waiting to be written.
+	done   chan struct{} // closed when the reader stops.
+}
+
+// read returns the next packet.
+func (p *streamPeer) read() ([]byte, error) {
+	if p.ws {
+		return p.wsRead()
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(p.r, size[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint16(size[:]))
+	_, err := io.ReadFull(p.r, data)
+	return data, err
+}
+
+// write queues a packet.
+func (p *streamPeer) write(pkt []byte) error {
+	if p.ws {
+		return p.wsWrite(wsBinary, pkt)
+	}
+	return p.send(append(binary.LittleEndian.AppendUint16(nil, uint16(len(pkt))), pkt...))
+}
+
+// send queues a frame for the writer.
+func (p *streamPeer) send(frame []byte) error {
+	select {
+	case p.out <- frame:
+		return nil
+	default:
+		return errors.New("net: stream peer is not keeping up")
+	}
+}
+
+// writeFrames writes the queued frames until a write fails or the
+// reader stops. Frames queued before the reader stopped are still
+// written, eg: the reply to a WebSocket close.
+func (p *streamPeer) writeFrames() {
+	defer p.conn.Close()
+	for {
+		select {
+		case frame := <-p.out:
+			if p.writeFrame(frame) != nil {
+				return
+			}
+		case <-p.done:
+			for {
+				select {
+				case frame := <-p.out:
+					if p.writeFrame(frame) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeFrame writes one frame, giving up on stalled peers.
+func (p *streamPeer) writeFrame(frame []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(streamTimeout))
+	_, err := p.conn.Write(frame)
+	return err
+}
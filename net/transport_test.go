@@ -0,0 +1,192 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// go test -run Transport
+func TestTransport(t *testing.T) {
+	cfg := Config{Protocol: 42, Timeout: 300 * time.Millisecond, Resend: 10 * time.Millisecond}
+
+	// roundtrip checks messages, rpcs, and snapshots over a transport.
+	roundtrip := func(t *testing.T, tr Transport) {
+		cfg := cfg
+		cfg.Transports = []Transport{tr}
+		srv, err := Listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer srv.Close()
+		cl, err := Dial(srv.Addr().String(), cfg)
+		if err != nil {
+			t.Fatalf("dial %s", err)
+		}
+		defer cl.Close()
+		srv.Handle("echo", func(c *Conn, args []byte) { c.Call("reply", args) })
+		reply := ""
+		cl.Handle("reply", func(c *Conn, args []byte) { reply = string(args) })
+		var got *Snapshot
+		cl.OnSnapshot(func(s *Snapshot) { got = s })
+		cl.Conn().Call("echo", []byte("hi")) // sent once connected.
+		if !pump(srv, cl, func() bool { return reply == "hi" }) {
+			t.Fatalf("expected reply got %q", reply)
+		}
+		srv.SendSnapshot([]State{{ID: 1, Values: []float32{1, 2}}})
+		if !pump(srv, cl, func() bool { return got != nil }) || got.Get(1).Values[1] != 2 {
+			t.Errorf("expected snapshot got %+v", got)
+		}
+		var closed error
+		srv.OnDisconnect(func(c *Conn, err error) { closed = err })
+		cl.Close()
+		if !pump(srv, nil, func() bool { return closed != nil }) || len(srv.Conns()) != 0 {
+			t.Errorf("expected disconnect")
+		}
+	}
+
+	// go test -run Transport/udp
+	t.Run("udp", func(t *testing.T) { roundtrip(t, UDP) })
+
+	// go test -run Transport/tcp
+	t.Run("tcp", func(t *testing.T) { roundtrip(t, TCP) })
+
+	// go test -run Transport/websocket
+	t.Run("websocket", func(t *testing.T) { roundtrip(t, WebSocket("/vu")) })
+
+	// go test -run Transport/fallback
+	t.Run("fallback", func(t *testing.T) {
+		ws := WebSocket("/vu")
+		cfg := cfg
+		cfg.Transports = []Transport{ws}
+		srv, err := Listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer srv.Close()
+		cfg.Transports = []Transport{UDP, TCP, ws} // no UDP or TCP server.
+		cl, err := Dial(srv.Addr().String(), cfg)
+		if err != nil {
+			t.Fatalf("dial %s", err)
+		}
+		defer cl.Close()
+		if !pump(srv, cl, cl.Connected) || cl.Transport() != ws {
+			t.Errorf("expected websocket fallback got %v", cl.Transport())
+		}
+
+		// the websocket path is checked.
+		resp, err := http.Get("http://" + srv.Addr().String() + "/other")
+		if err != nil || resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected not found got %v", err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		resp, err = http.Get("http://" + srv.Addr().String() + "/vu")
+		if err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected bad request got %v", err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	})
+
+	// go test -run Transport/multiple
+	t.Run("multiple", func(t *testing.T) {
+		cfg := cfg
+		cfg.Transports = []Transport{UDP, TCP, WebSocket("/vu")}
+		srv, err := Listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer srv.Close()
+		addrs := srv.Addrs()
+		if addrs[1].String() != addrs[2].String() {
+			t.Errorf("expected tcp and websocket to share %s got %s", addrs[1], addrs[2])
+		}
+		clients := []*Client{}
+		for i, tr := range cfg.Transports {
+			cfg.Transports = []Transport{tr}
+			cl, err := Dial(addrs[i].String(), cfg)
+			if err != nil {
+				t.Fatalf("dial %s", err)
+			}
+			defer cl.Close()
+			clients = append(clients, cl)
+		}
+		connected := func() bool {
+			for _, cl := range clients {
+				cl.Update(time.Now())
+			}
+			for _, cl := range clients {
+				if !cl.Connected() {
+					return false
+				}
+			}
+			return len(srv.Conns()) == len(clients)
+		}
+		if !pump(srv, nil, connected) {
+			t.Errorf("expected a client on each transport")
+		}
+
+		// transports can't share a port twice.
+		cfg.Transports = []Transport{TCP, TCP}
+		if srv, err := Listen("127.0.0.1:0", cfg); err == nil {
+			srv.Close()
+			t.Errorf("expected duplicate transport error")
+		}
+	})
+
+	// go test -run Transport/stalled
+	t.Run("stalled", func(t *testing.T) {
+		pc, err := TCP.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen %s", err)
+		}
+		defer pc.Close()
+		conn, err := net.Dial("tcp", pc.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("dial %s", err)
+		}
+		defer conn.Close()
+		conn.Write([]byte{1, 0, 42}) // a one byte packet.
+		buf := make([]byte, MaxMessage)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil || n != 1 || buf[0] != 42 {
+			t.Fatalf("expected packet got %d %v", n, err)
+		}
+
+		// the peer never reads, so writes fail once the queue
+		// fills instead of blocking the caller.
+		start, failed := time.Now(), false
+		for i := 0; i < 100000 && !failed; i++ {
+			_, err = pc.WriteTo(buf, addr)
+			failed = err != nil
+		}
+		if !failed || time.Since(start) > streamTimeout {
+			t.Errorf("expected write error without blocking got %v after %s", err, time.Since(start))
+		}
+	})
+
+	// go test -run Transport/unreachable
+	t.Run("unreachable", func(t *testing.T) {
+		cfg := cfg
+		cfg.Transports = []Transport{TCP}
+		cl, err := Dial("127.0.0.1:1", cfg)
+		if err != nil {
+			t.Fatalf("dial %s", err)
+		}
+		defer cl.Close()
+		var got error
+		cl.OnDisconnect(func(c *Conn, err error) { got = err })
+		if pump(nil, cl, func() bool { return got != nil }); got != ErrTimeout {
+			t.Errorf("expected timeout got %v", got)
+		}
+		if _, err := Dial("no port", cfg); err == nil {
+			t.Errorf("expected address error")
+		}
+	})
+}
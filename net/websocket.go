@@ -0,0 +1,210 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package net
+
+// websocket.go implements the parts of RFC 6455 needed to carry
+// binary packets: the opening handshake, binary messages, and ping,
+// pong, and close control frames. Extensions are not supported.
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebSocket opcodes.
+const (
+	wsContinue byte = 0x0
+	wsText     byte = 0x1
+	wsBinary   byte = 0x2
+	wsClose    byte = 0x8
+	wsPing     byte = 0x9
+	wsPong     byte = 0xa
+)
+
+// wsMaxMessage limits the size of received messages.
+const wsMaxMessage = 64 * 1024
+
+// wsGUID is hashed with the client key to accept a connection.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsAcceptKey returns the accept value for the client key.
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsUpgrade completes the server side of the opening handshake.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.Reader, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerHas(r.Header, "Connection", "upgrade") ||
+		!headerHas(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, nil, errors.New("net: not a websocket upgrade")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket upgrade unsupported", http.StatusInternalServerError)
+		return nil, nil, errors.New("net: http connection can't be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw.Reader, nil
+}
+
+// wsHandshake completes the client side of the opening handshake.
+func wsHandshake(conn net.Conn, host, path string) (*bufio.Reader, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	req := "GET " + path + " HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, fmt.Errorf("net: websocket handshake failed: %s", resp.Status)
+	}
+	return br, nil
+}
+
+// headerHas returns true if the comma separated header values
+// contain the token, ignoring case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsRead returns the next data message, answering pings
+// along the way. Closing frames end the connection.
+func (p *streamPeer) wsRead() ([]byte, error) {
+	var msg []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(p.r, h[:]); err != nil {
+			return nil, err
+		}
+		fin, op := h[0]&0x80 != 0, h[0]&0x0f
+		masked, size := h[1]&0x80 != 0, uint64(h[1]&0x7f)
+		switch size {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(p.r, b[:]); err != nil {
+				return nil, err
+			}
+			size = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(p.r, b[:]); err != nil {
+				return nil, err
+			}
+			size = binary.BigEndian.Uint64(b[:])
+		}
+		if size > wsMaxMessage || uint64(len(msg))+size > wsMaxMessage {
+			return nil, fmt.Errorf("net: websocket message larger than %d", wsMaxMessage)
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(p.r, mask[:]); err != nil {
+				return nil, err
+			}
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(p.r, payload); err != nil {
+			return nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+		switch op {
+		case wsClose:
+			p.wsWrite(wsClose, nil)
+			return nil, io.EOF
+		case wsPing:
+			if err := p.wsWrite(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsContinue, wsText, wsBinary:
+			msg = append(msg, payload...)
+			if fin {
+				return msg, nil
+			}
+		default:
+			return nil, fmt.Errorf("net: websocket opcode %d unsupported", op)
+		}
+	}
+}
+
+// wsWrite queues one frame. Client frames are masked.
+func (p *streamPeer) wsWrite(op byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+	maskBit := byte(0)
+	if p.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if p.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	return p.send(frame)
+}
@@ -0,0 +1,185 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// ninepatch.go stretches small skin textures over 2D panels and buttons
+// of any size, eg:
+//
+//	eng.ImportAssets("skin.shd", "panel.png")
+//	panel := ui.AddNinePatch(300, 200, 12, 12, 12, 12, "shd:skin", "tex:color:panel")
+//	panel.SetAt(400, 300, 0).SetColor(1, 1, 1, 0.9)
+//
+// The texture is split into nine pieces by the border insets, given in
+// texture pixels from each edge. The corners are drawn at their texture
+// size, the edges are stretched along their length, and the center is
+// stretched to fill the rest. Borders are shrunk when the patch is smaller
+// than the combined insets. The patch mesh is centered on the model
+// location like the "msh:icon" quad, and is regenerated when the size or
+// insets change. The skin shader tints the texture with the model color.
+// Widgets can be skinned using Entity.SetWidgetSkin.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// AddNinePatch adds a 2D model that stretches the texture to w, h pixels
+// keeping the texture borders unscaled. Insets are the border widths in
+// texture pixels. The assets are a 2D texture shader and texture, eg:
+// "shd:skin", "tex:color:button".
+//
+// Depends on Eng.AddScene for a Scene2D.
+func (e *Entity) AddNinePatch(w, h float64, left, top, right, bottom int, assets ...string) (me *Entity) {
+	me = e.AddModel(assets...).SetColor(1, 1, 1, 1)
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass2D {
+		slog.Error("AddNinePatch needs 2D scene", "eid", e.eid)
+		return me
+	}
+	if left < 0 || top < 0 || right < 0 || bottom < 0 {
+		slog.Error("AddNinePatch invalid insets", "left", left, "top", top, "right", right, "bottom", bottom)
+		return me
+	}
+	np := me.app.patches.create(me.eid)
+	np.w, np.h = max(w, 0), max(h, 0)
+	np.insets = [4]int{left, top, right, bottom}
+	return me
+}
+
+// SetNinePatchSize changes the nine patch width and height in pixels.
+//
+// Depends on Entity.AddNinePatch.
+func (e *Entity) SetNinePatchSize(w, h float64) *Entity {
+	if np := e.app.patches.get(e.eid); np != nil {
+		w, h = max(w, 0), max(h, 0)
+		if np.w != w || np.h != h {
+			np.w, np.h, np.dirty = w, h, true
+		}
+		return e
+	}
+	slog.Error("SetNinePatchSize needs AddNinePatch", "eid", e.eid)
+	return e
+}
+
+// SetNinePatchInsets changes the border widths in texture pixels.
+//
+// Depends on Entity.AddNinePatch.
+func (e *Entity) SetNinePatchInsets(left, top, right, bottom int) *Entity {
+	if np := e.app.patches.get(e.eid); np != nil && left >= 0 && top >= 0 && right >= 0 && bottom >= 0 {
+		if insets := [4]int{left, top, right, bottom}; np.insets != insets {
+			np.insets, np.dirty = insets, true
+		}
+		return e
+	}
+	slog.Error("SetNinePatchInsets needs AddNinePatch", "eid", e.eid)
+	return e
+}
+
+// =============================================================================
+// nine patch data
+
+// ninePatch is the size and borders of one nine patch model.
+type ninePatch struct {
+	w, h   float64 // size in pixels.
+	insets [4]int  // left, top, right, bottom borders in texture pixels.
+	dirty  bool    // true if the mesh needs regenerating.
+}
+
+// slices returns the 4 vertex offsets and texture coordinates along
+// one axis of the given size where the texture is tsize pixels with
+// borders lo and hi. Borders shrink to fit small sizes.
+func (np *ninePatch) slices(size float64, tsize, lo, hi int) (at, uv [4]float32) {
+	a, b := float64(lo), float64(hi)
+	if a+b > size && a+b > 0 {
+		shrink := size / (a + b)
+		a, b = a*shrink, b*shrink
+	}
+	half := size / 2
+	at = [4]float32{float32(-half), float32(-half + a), float32(half - b), float32(half)}
+	t := float32(max(tsize, 1))
+	uv = [4]float32{0, float32(lo) / t, 1 - float32(hi)/t, 1}
+	return at, uv
+}
+
+// meshData generates the 4x4 grid of vertexes for a texture
+// of the given size. The grid rows go from top to bottom.
+func (np *ninePatch) meshData(tw, th int) load.MeshData {
+	xs, us := np.slices(np.w, tw, np.insets[0], np.insets[2])
+	ys, vs := np.slices(np.h, th, np.insets[1], np.insets[3])
+	vx, uv, ix := make([]float32, 0, 32), make([]float32, 0, 32), make([]uint16, 0, 54)
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			vx = append(vx, xs[c], ys[r])
+			uv = append(uv, us[c], vs[r])
+		}
+	}
+	for r := uint16(0); r < 3; r++ {
+		for c := uint16(0); c < 3; c++ {
+			tl := r*4 + c // same winding as the icon quad.
+			ix = append(ix, tl+4, tl+5, tl+1, tl+4, tl+1, tl)
+		}
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(vx, 2)  // vec2
+	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(ix)
+	return md
+}
+
+// =============================================================================
+// patches component manager.
+
+// patches tracks the nine patch models.
+type patches struct {
+	list map[eID]*ninePatch
+}
+
+// newPatches creates the nine patch component manager.
+// There is only expected to be once instance created by the engine.
+func newPatches() *patches {
+	return &patches{list: map[eID]*ninePatch{}}
+}
+
+// create a nine patch for the given model entity.
+func (ps *patches) create(eid eID) *ninePatch {
+	np := &ninePatch{dirty: true}
+	ps.list[eid] = np
+	return np
+}
+
+// get the nine patch for the given entity.
+func (ps *patches) get(eid eID) *ninePatch { return ps.list[eid] }
+
+// dispose removes the nine patch. The patch mesh
+// is released with the nine patch model.
+func (ps *patches) dispose(eid eID) { delete(ps.list, eid) }
+
+// update regenerates the meshes of the nine patches that have changed
+// once their textures have loaded. The previous mesh is released.
+// Called by the engine once each update.
+func (ps *patches) update(app *application, rc render.Loader) {
+	for eid, np := range ps.list {
+		if !np.dirty {
+			continue
+		}
+		m := app.models.get(eid)
+		if m == nil || len(m.texs) == 0 || m.texs[0].w == 0 {
+			continue // waiting for the texture.
+		}
+		mid, err := rc.LoadMesh(np.meshData(m.texs[0].w, m.texs[0].h))
+		if err != nil {
+			slog.Error("nine patch LoadMesh", "error", err)
+			delete(ps.list, eid)
+			continue
+		}
+		if m.mesh != nil {
+			app.ld.release(m.mesh) // generated mesh.
+		}
+		m.mesh = newMesh("ninepatch")
+		m.mesh.mid = mid
+		m.mesh.generated = true
+		np.dirty = false
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+
+	"github.com/gazed/vu/load"
+)
+
+// go test -run NinePatch
+func TestNinePatch(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene2D)
+
+	// go test -run NinePatch/mesh
+	t.Run("mesh", func(t *testing.T) {
+		np := &ninePatch{w: 100, h: 40, insets: [4]int{8, 4, 8, 12}}
+		md := np.meshData(32, 32)
+		if md[load.Vertexes].Count != 16 || md[load.Indexes].Count != 54 {
+			t.Fatalf("expected 4x4 grid got %d %d", md[load.Vertexes].Count, md[load.Indexes].Count)
+		}
+		xs, us := np.slices(np.w, 32, 8, 8)
+		if xs != [4]float32{-50, -42, 42, 50} || us != [4]float32{0, 0.25, 0.75, 1} {
+			t.Errorf("expected unscaled borders got %v %v", xs, us)
+		}
+		ys, vs := np.slices(8, 32, 4, 12) // smaller than the borders.
+		if ys != [4]float32{-4, -2, -2, 4} || vs != [4]float32{0, 0.125, 0.625, 1} {
+			t.Errorf("expected shrunk borders got %v %v", ys, vs)
+		}
+	})
+
+	// go test -run NinePatch/update
+	t.Run("update", func(t *testing.T) {
+		patch := scene.AddNinePatch(100, 40, 8, 8, 8, 8, "shd:icon", "tex:color:test")
+		app.ld.loadAssets(rc, nil)
+		app.patches.update(app, rc)
+		m := app.models.get(patch.eid)
+		if m.mesh == nil || !m.mesh.generated || app.patches.get(patch.eid).dirty {
+			t.Fatalf("expected generated patch mesh")
+		}
+		first := m.mesh
+		patch.SetNinePatchSize(100, 40)
+		app.patches.update(app, rc)
+		if m.mesh != first {
+			t.Errorf("expected same size to keep the mesh")
+		}
+		patch.SetNinePatchSize(200, 40).SetNinePatchInsets(4, 4, 4, 4)
+		app.patches.update(app, rc)
+		if m.mesh == first {
+			t.Errorf("expected resize to regenerate the mesh")
+		}
+		patch.Dispose(nil)
+		if app.patches.get(patch.eid) != nil {
+			t.Errorf("expected nine patch disposed")
+		}
+	})
+
+	// go test -run NinePatch/skin
+	t.Run("skin", func(t *testing.T) {
+		app.ui.font = "lucon18"
+		button := scene.AddButton("ok", 120, 30).SetWidgetSkin(6, 6, 6, 6, "shd:skin", "tex:color:test")
+		wg := app.ui.get(button.eid)
+		np := app.patches.get(wg.bg.eid)
+		if np == nil || np.w != 120 || np.h != 30 {
+			t.Fatalf("expected skinned button background")
+		}
+		button.SetWidgetSize(150, 30)
+		app.ui.draw(app)
+		if np.w != 150 || !np.dirty {
+			t.Errorf("expected skin resized with the widget")
+		}
+		if scene.AddNinePatch(10, 10, -1, 0, 0, 0); len(app.patches.list) != 1 {
+			t.Errorf("expected invalid insets")
+		}
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// occlusion.go skips drawing 3D models that are hidden behind other
+// models. It helps dense indoor scenes where walls hide most of the
+// models, eg:
+//
+//	furniture.SetOcclusionCull(1, 0.5, 1) // bounding box half extents.
+//
+// A bounding box is drawn for each occlusion culled model using an
+// occlusion query. Models whose bounding box is completely hidden are
+// not drawn. Visibility lags the camera by a few frames, so bounding
+// boxes should be a bit larger than their models.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// SetOcclusionCull enables occlusion culling for a 3D model using a
+// bounding box with the given half extents. The bounding box is centered
+// on the model origin and is scaled, rotated, and moved with the model.
+// Zero half extents disable occlusion culling. Instanced models are not
+// occlusion culled.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetOcclusionCull(hx, hy, hz float64) *Entity {
+	m := e.app.models.get(e.eid)
+	if m == nil {
+		slog.Error("SetOcclusionCull needs AddModel", "eid", e.eid)
+		return e
+	}
+	if hx <= 0 || hy <= 0 || hz <= 0 {
+		m.occlude = nil
+		return e
+	}
+	m.occlude = lin.NewV3S(hx, hy, hz)
+	e.app.ld.importAssetData("occlude.shd")
+	return e
+}
+
+// occlusionPacket adds the bounding box packet for an occlusion culled
+// model that has just been added to the packets. The model is drawn
+// normally when the camera is close enough to be inside its bounding
+// box, or until the occlusion shader has loaded.
+func (ss *scenes) occlusionPacket(app *application, sc *scene, p *pov, m *model, packets render.Packets) render.Packets {
+	s, _ := app.ld.getLoadedAsset(assetID(shd, "occlude")).(*shader)
+	cube, _ := app.ld.getLoadedAsset(assetID(msh, "cube")).(*mesh)
+	if s == nil || cube == nil {
+		return packets // occlusion shader not loaded.
+	}
+	hx, hy, hz := m.occlude.X*p.sw.X, m.occlude.Y*p.sw.Y, m.occlude.Z*p.sw.Z
+	near := lin.NewV3S(hx, hy, hz).Len() + sc.cam.near
+	if m.tocam <= near*near {
+		return packets // camera may be inside the bounding box.
+	}
+
+	// link the model packet to the bounding box packet.
+	oid := uint32(p.eid)
+	packets[len(packets)-1].Occlusion = oid
+	packets, box := packets.GetPacket()
+	box.ShaderID = s.sid
+	box.MeshID = cube.mid
+	box.Occlusion = oid
+	box.IsOcclusionQuery = true
+	box.Tag = oid
+	box.Scope = m.scope
+
+	// scale the unit cube to the bounding box before the model transform.
+	ss.box.Set(p.mm).ScaleSM(2*m.occlude.X, 2*m.occlude.Y, 2*m.occlude.Z)
+	box.Uniforms[load.MODEL] = render.M4ToBytes(ss.box, box.Uniforms[load.MODEL])
+
+	// draw bounding boxes after the opaque models in the same layer.
+	box.Bucket = setBucketType(newBucket(render.Pass3D), drawOcclusion)
+	box.Bucket = setBucketShader(box.Bucket, s.sid)
+	box.Bucket = setBucketLayer(box.Bucket, m.layer)
+	return packets
+}
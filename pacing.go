@@ -0,0 +1,84 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// pacing.go controls how often frames are presented and counts the
+// frames that were presented late. Games trade latency for battery by
+// choosing a vsync mode and an optional frame rate cap, eg:
+//
+//	eng.SetVSync(render.VSyncOn) // wait for the display.
+//	eng.SetFrameLimit(0)         // no cap, vsync paces the frames.
+//
+//	eng.SetVSync(render.VSyncOff) // lowest latency.
+//	eng.SetFrameLimit(144)        // cap to save power.
+//
+// A frame is missed when it takes noticeably longer than the frame
+// interval, where the interval is the frame cap or the display refresh
+// when vsync waits for the display.
+
+import (
+	"time"
+
+	"github.com/gazed/vu/render"
+)
+
+// SetVSync sets how rendered frames are presented to the display.
+// The default is render.VSyncMailbox.
+func (eng *Engine) SetVSync(mode render.VSync) {
+	eng.pace.vsync = mode
+	if eng.rc != nil {
+		eng.rc.SetVSync(mode)
+	}
+	if eng.dev != nil {
+		eng.pace.setRefreshRate(eng.dev.RefreshRate())
+	}
+}
+
+// VSync returns the current vsync mode.
+func (eng *Engine) VSync() render.VSync { return eng.pace.vsync }
+
+// MissedFrames returns the number of frames that took more than
+// one and a half frame intervals since the engine started running.
+// Frames are not counted when there is no frame cap and vsync does
+// not wait for the display.
+func (eng *Engine) MissedFrames() int { return eng.pace.missed }
+
+// =============================================================================
+// pacing tracks frame timing.
+
+// missedFrame is the fraction of the frame interval
+// after which a frame is counted as missed.
+const missedFrame = 1.5
+
+// pacing holds the frame pacing settings and missed frame count.
+type pacing struct {
+	vsync   render.VSync  // present mode.
+	refresh time.Duration // display refresh interval, 0 if unknown.
+	missed  int           // number of late frames.
+}
+
+// setRefreshRate records the display refresh rate in hertz.
+func (p *pacing) setRefreshRate(hz int) {
+	p.refresh = 0
+	if hz > 0 {
+		p.refresh = time.Second / time.Duration(hz)
+	}
+}
+
+// interval returns the expected time between frames for the given
+// frame throttle. Returns 0 if the frames are not paced.
+func (p *pacing) interval(throttle time.Duration) time.Duration {
+	if p.vsync == render.VSyncOn || p.vsync == render.VSyncAdaptive {
+		return max(throttle, p.refresh) // can't present faster than the display.
+	}
+	return throttle
+}
+
+// frame counts the frame as missed if the delta time
+// between frames is too long for the frame interval.
+func (p *pacing) frame(delta, throttle time.Duration) {
+	expect := p.interval(throttle)
+	if expect > 0 && delta.Seconds() > expect.Seconds()*missedFrame {
+		p.missed++
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/device"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Pacing
+func TestPacing(t *testing.T) {
+	ms := time.Millisecond
+	t.Run("frame limit", func(t *testing.T) {
+		eng := &Engine{}
+		eng.SetFrameLimit(60)
+		if eng.throttle != time.Second/60 {
+			t.Fatalf("expected 60fps throttle got %s", eng.throttle)
+		}
+		eng.SetFrameLimit(500) // ignored.
+		if eng.throttle != time.Second/60 {
+			t.Fatalf("expected ignored limit got %s", eng.throttle)
+		}
+		eng.SetFrameLimit(0)
+		if eng.throttle != 0 {
+			t.Fatalf("expected no throttle got %s", eng.throttle)
+		}
+	})
+	t.Run("missed with cap", func(t *testing.T) {
+		p := pacing{}
+		p.frame(16*ms, 16*ms)
+		p.frame(20*ms, 16*ms) // late but not missed.
+		p.frame(30*ms, 16*ms)
+		if p.missed != 1 {
+			t.Fatalf("expected 1 missed frame got %d", p.missed)
+		}
+	})
+	t.Run("missed with vsync", func(t *testing.T) {
+		p := pacing{vsync: render.VSyncOn}
+		p.setRefreshRate(100)
+		p.frame(12*ms, 0)
+		p.frame(16*ms, 0)
+		p.frame(100*ms, 0)
+		if p.missed != 2 {
+			t.Fatalf("expected 2 missed frames got %d", p.missed)
+		}
+		if got := p.interval(20 * ms); got != 20*ms {
+			t.Fatalf("expected cap interval got %s", got)
+		}
+	})
+	t.Run("not paced", func(t *testing.T) {
+		p := pacing{vsync: render.VSyncOff}
+		p.setRefreshRate(60)
+		p.frame(time.Second, 0)
+		if p.missed != 0 {
+			t.Fatalf("expected no missed frames got %d", p.missed)
+		}
+	})
+	t.Run("sleep until", func(t *testing.T) {
+		deadline := time.Now().Add(5 * ms)
+		device.SleepUntil(deadline)
+		if now := time.Now(); now.Before(deadline) || now.Sub(deadline) > 20*ms {
+			t.Fatalf("expected wake at deadline got %s", now.Sub(deadline))
+		}
+		device.SleepUntil(time.Now().Add(-ms)) // past deadlines return.
+	})
+}
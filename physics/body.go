@@ -7,6 +7,7 @@ package physics
 
 import (
 	"log/slog"
+	"math"
 
 	"github.com/gazed/vu/math/lin"
 )
@@ -34,6 +35,11 @@ type Body struct {
 	static_friction_coefficient  float64
 	dynamic_friction_coefficient float64
 	restitution_coefficient      float64
+	planar                       bool   // 2D: moves in XY, rotates around Z.
+	proxy                        int32  // broad phase tree leaf + 1, 0 if none.
+	layer                        uint32 // collision layer bits.
+	mask                         uint32 // layers that this body collides with.
+	trigger                      bool   // true for trigger volumes that don't collide.
 
 	// PBD Auxilar
 	previous_world_position   lin.V3
@@ -103,6 +109,7 @@ func body_create_ex(
 	body.static_friction_coefficient = static_friction_coefficient
 	body.dynamic_friction_coefficient = dynamic_friction_coefficient
 	body.restitution_coefficient = restitution_coefficient
+	body.layer, body.mask = 1, math.MaxUint32 // collide with everything.
 	if body.dynamic_friction_coefficient > body.static_friction_coefficient {
 		slog.Warn("dynamic friction coefficient is greater than static friction coefficient")
 	}
@@ -143,6 +150,61 @@ func (body *Body) SetScale(world_scale lin.V3) {
 	body.world_scale = world_scale
 }
 
+// Static returns true if the body is not moved by the simulation.
+func (body *Body) Static() bool { return body.fixed }
+
+// Sphere returns the radius of sphere bodies.
+// Returns false if the body is not a sphere.
+func (body *Body) Sphere() (radius float64, ok bool) {
+	if len(body.colliders) != 1 || body.colliders[0].ctype != collider_TYPE_SPHERE {
+		return 0, false
+	}
+	return float64(body.colliders[0].sphere.radius), true
+}
+
+// Box returns the half-extents of box bodies.
+// Returns false if the body is not a box.
+func (body *Body) Box() (hx, hy, hz float64, ok bool) {
+	if len(body.colliders) != 1 || body.colliders[0].ctype != collider_TYPE_CONVEX_HULL {
+		return 0, 0, 0, false
+	}
+	vertices := body.colliders[0].convex_hull.vertices
+	if len(vertices) != 8 {
+		return 0, 0, 0, false
+	}
+	for _, v := range vertices {
+		hx, hy, hz = max(hx, math.Abs(v.X)), max(hy, math.Abs(v.Y)), max(hz, math.Abs(v.Z))
+	}
+	for _, v := range vertices {
+		if math.Abs(v.X) != hx || math.Abs(v.Y) != hy || math.Abs(v.Z) != hz {
+			return 0, 0, 0, false // 4 sided polygon.
+		}
+	}
+	return hx, hy, hz, true
+}
+
+// SetPlanar constrains the body to 2D physics where the body moves
+// in the XY plane at its current Z and only rotates around the Z axis.
+// Planar bodies collide with bodies that overlap their Z depth.
+func (body *Body) SetPlanar(planar bool) { body.planar = planar }
+
+// Planar returns true if the body is constrained to 2D physics.
+func (body *Body) Planar() bool { return body.planar }
+
+// constrain_planar removes any motion outside the XY plane at the
+// given Z, keeping only the twist of the rotation around the Z axis.
+func (body *Body) constrain_planar(z float64) {
+	body.world_position.Z = z
+	body.linear_velocity.Z = 0.0
+	body.angular_velocity.X, body.angular_velocity.Y = 0.0, 0.0
+	q := &body.world_rotation
+	if twist := math.Sqrt(q.Z*q.Z + q.W*q.W); twist > 0.0 {
+		q.X, q.Y, q.Z, q.W = 0.0, 0.0, q.Z/twist, q.W/twist
+		return
+	}
+	q.X, q.Y, q.Z, q.W = 0.0, 0.0, 0.0, 1.0
+}
+
 // Activate set the body as active in the simulation.
 func (body *Body) Activate() {
 	body.active = true
@@ -3,7 +3,9 @@
 package physics
 
 import (
+	"cmp"
 	"log/slog"
+	"slices"
 
 	"github.com/gazed/vu/math/lin"
 )
@@ -14,23 +16,35 @@ type broad_Collision_Pair struct {
 	b2_id bid
 }
 
-// broad_get_collision_pairs
+// broad_get_collision_pairs returns the pairs of bodies whose bounding
+// spheres are close, ordered by body ID. The pairs are found using the
+// broad phase tree instead of checking every pair of bodies.
 func broad_get_collision_pairs(bodies []Body) []broad_Collision_Pair {
+	broad_tree_update(bodies)
 	collision_pairs := []broad_Collision_Pair{}
 	for i := 0; i < len(bodies); i++ {
 		b1 := &bodies[i]
-		for j := i + 1; j < len(bodies); j++ {
+		if b1.proxy == 0 {
+			continue
+		}
+		start := len(collision_pairs)
+		lo, hi := broad_body_bounds(b1)
+		tree_query(&broad_tree, lo, hi, func(j bid) {
+			if int(j) <= i {
+				return // each pair once.
+			}
 			b2 := &bodies[j]
 			entities_distance := lin.NewV3().Sub(&b1.world_position, &b2.world_position).Len()
 
 			// Increase the distance a little to account for moving objects.
 			// @TODO: We should derivate this value from delta_time, forces, velocities, etc
-			max_distance_for_collision := b1.bounding_sphere_radius + b2.bounding_sphere_radius + 0.1
-			if entities_distance <= max_distance_for_collision {
+			max_distance_for_collision := b1.bounding_sphere_radius + b2.bounding_sphere_radius + 2*broad_MARGIN
+			if entities_distance <= max_distance_for_collision && layers_collide(b1, b2) {
 				// body ID is the bodies array index.
-				collision_pairs = append(collision_pairs, broad_Collision_Pair{bid(i), bid(j)})
+				collision_pairs = append(collision_pairs, broad_Collision_Pair{bid(i), j})
 			}
-		}
+		})
+		slices.SortFunc(collision_pairs[start:], func(a, b broad_Collision_Pair) int { return cmp.Compare(a.b2_id, b.b2_id) })
 	}
 	return collision_pairs
 }
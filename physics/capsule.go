@@ -0,0 +1,226 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// capsule.go adds capsule and cylinder shapes. It is not part of the
+// original raw-physics port. Capsules are a line segment with a radius,
+// so like spheres they are found using a support point. Cylinders are
+// convex hulls with many sides.
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// NewCapsule creates a pill shaped physics body located at the origin.
+// The capsule is a cylinder with half-height hy along the Y axis and
+// radius r, capped by half spheres of radius r, so the total height is
+// 2*(hy+r). The capsule can be static (unmovable) or kinematic (moveable).
+func NewCapsule(r, hy float64, static bool) *Body {
+	colliders := []collider{collider_capsule_create(r, hy)}
+	world_position := lin.NewV3()                  // app to call body.SetPosition
+	world_rotation := lin.NewQ().SetAa(0, 1, 0, 0) // app to call body.SetRotation
+	world_scale := lin.NewV3().SetS(1, 1, 1)       // app to call body.SetScale
+	mass := 1.0
+	static_friction_coefficient := 0.5
+	dynamic_friction_coefficient := 0.5
+	restitution_coefficient := 0.0
+	return body_create_ex(*world_position, *world_rotation, *world_scale, mass, colliders,
+		static_friction_coefficient, dynamic_friction_coefficient, restitution_coefficient, static)
+}
+
+// cylinder_SIDES is the number of sides for cylinder hulls.
+const cylinder_SIDES = 16
+
+// NewCylinder creates a cylinder shaped physics body located at the
+// origin with radius r and half-height hy along the Y axis.
+// The cylinder can be static (unmovable) or kinematic (moveable).
+func NewCylinder(r, hy float64, static bool) *Body {
+	points := make([]lin.V3, 0, cylinder_SIDES*2)
+	for i := 0; i < cylinder_SIDES; i++ {
+		angle := 2 * math.Pi * float64(i) / cylinder_SIDES
+		x, z := r*math.Cos(angle), r*math.Sin(angle)
+		points = append(points, lin.V3{X: x, Y: +hy, Z: z}, lin.V3{X: x, Y: -hy, Z: z})
+	}
+	vertexes, indexes := ConvexHull(points)
+	if vertexes == nil {
+		slog.Error("NewCylinder needs a radius and height", "r", r, "hy", hy)
+		return nil
+	}
+	return hull_body_create(vertexes, indexes, static)
+}
+
+// Capsule returns the radius and half-height of capsule bodies.
+// Returns false if the body is not a capsule.
+func (body *Body) Capsule() (r, hy float64, ok bool) {
+	if len(body.colliders) != 1 || body.colliders[0].ctype != collider_TYPE_CAPSULE {
+		return 0, 0, false
+	}
+	c := &body.colliders[0].capsule
+	return c.radius, c.half_height, true
+}
+
+// collider_Capsule is a line segment along the local Y axis
+// with a radius.
+type collider_Capsule struct {
+	radius      float64
+	half_height float64 // half the segment length.
+	top         lin.V3  // world segment end points.
+	bottom      lin.V3  //   "
+}
+
+// collider_capsule_create
+func collider_capsule_create(radius, half_height float64) collider {
+	var collider collider
+	collider.ctype = collider_TYPE_CAPSULE
+	collider.capsule.radius = radius
+	collider.capsule.half_height = half_height
+	collider.capsule.top = lin.V3{Y: half_height}
+	collider.capsule.bottom = lin.V3{Y: -half_height}
+	return collider
+}
+
+// capsule_update places the capsule segment in world space.
+func capsule_update(capsule *collider_Capsule, translation lin.V3, rotation *lin.Q) {
+	axis := lin.NewV3().MultQ(&lin.V3{Y: capsule.half_height}, rotation)
+	capsule.top.Add(&translation, axis)
+	capsule.bottom.Sub(&translation, axis)
+}
+
+// capsule_support_point returns the furthest capsule point in the direction.
+func capsule_support_point(capsule *collider_Capsule, direction lin.V3) lin.V3 {
+	end := capsule.bottom
+	if capsule.top.Dot(&direction) > capsule.bottom.Dot(&direction) {
+		end = capsule.top
+	}
+	offset := lin.NewV3().Set(&direction).Unit()
+	return *offset.Scale(offset, capsule.radius).Add(offset, &end)
+}
+
+// capsule_inertia_tensor returns the inertia of a solid capsule
+// made from a cylinder and two half spheres.
+func capsule_inertia_tensor(capsule *collider_Capsule, mass float64) lin.M3 {
+	r, h := capsule.radius, capsule.half_height
+	cylinder_volume := math.Pi * r * r * 2 * h
+	sphere_volume := 4.0 / 3.0 * math.Pi * r * r * r
+	mc := mass * cylinder_volume / (cylinder_volume + sphere_volume)
+	ms := mass - mc
+	result := lin.NewM3()
+	result.Yy = mc*r*r/2 + ms*2*r*r/5
+	result.Xx = mc*(h*h/3+r*r/4) + ms*(2*r*r/5+h*h+3*h*r/4)
+	result.Zz = result.Xx
+	return *result
+}
+
+// collider_segment returns the world segment and radius of round colliders.
+// Spheres are capsules with a zero length segment.
+func collider_segment(collider *collider) (a, b lin.V3, radius float64, ok bool) {
+	switch collider.ctype {
+	case collider_TYPE_SPHERE:
+		c := collider.sphere.center
+		return c, c, float64(collider.sphere.radius), true
+	case collider_TYPE_CAPSULE:
+		c := &collider.capsule
+		return c.top, c.bottom, c.radius, true
+	}
+	return a, b, 0, false
+}
+
+// round_get_contacts returns the contact between two colliders that
+// are spheres or capsules, using the closest points of their segments.
+func round_get_contacts(collider1, collider2 *collider, contacts []collider_Contact) []collider_Contact {
+	a1, b1, r1, _ := collider_segment(collider1)
+	a2, b2, r2, _ := collider_segment(collider2)
+	c1, c2 := closest_points_segment_segment(a1, b1, a2, b2)
+	distance_vector := lin.NewV3().Sub(&c2, &c1)
+	distance := distance_vector.Len()
+	if distance >= r1+r2 {
+		return contacts
+	}
+	normal := lin.V3{Y: 1} // any direction for overlapping segments.
+	if distance > 0 {
+		normal = *distance_vector.Scale(distance_vector, 1/distance)
+	}
+	var contact collider_Contact
+	contact.collision_point1.Add(&c1, lin.NewV3().Scale(&normal, r1))
+	contact.collision_point2.Sub(&c2, lin.NewV3().Scale(&normal, r2))
+	contact.normal = normal
+	return append(contacts, contact)
+}
+
+// capsule_contact_manifold returns the capsule contacts for a collision
+// found with EPA. The capsule segment end points that penetrate the
+// other collider are the contacts so that a capsule lying on its side
+// has two contacts. The normal points from collider1 to collider2.
+func capsule_contact_manifold(capsule *collider_Capsule, capsule_first bool,
+	normal lin.V3, penetration float64, contacts []collider_Contact) []collider_Contact {
+	direction := normal // deepest capsule points are towards the other collider.
+	if !capsule_first {
+		direction.Neg(&normal)
+	}
+	deepest := max(capsule.top.Dot(&direction), capsule.bottom.Dot(&direction))
+	ends := []lin.V3{capsule.top}
+	if capsule.half_height > 0 {
+		ends = append(ends, capsule.bottom)
+	}
+	for _, end := range ends {
+		depth := penetration - (deepest - end.Dot(&direction))
+		if depth <= 0 {
+			continue
+		}
+		point := lin.NewV3().Add(&end, lin.NewV3().Scale(&direction, capsule.radius))
+		var contact collider_Contact
+		if capsule_first {
+			contact.collision_point1 = *point
+			contact.collision_point2.Sub(point, lin.NewV3().Scale(&normal, depth))
+		} else {
+			contact.collision_point1.Add(point, lin.NewV3().Scale(&normal, depth))
+			contact.collision_point2 = *point
+		}
+		contact.normal = normal
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
+
+// closest_points_segment_segment returns the closest points between
+// the segments p1:q1 and p2:q2.
+// Based on Real-Time Collision Detection, Christer Ericson, 5.1.9.
+func closest_points_segment_segment(p1, q1, p2, q2 lin.V3) (c1, c2 lin.V3) {
+	const EPSILON = 1e-12
+	d1 := lin.NewV3().Sub(&q1, &p1) // direction of segment 1
+	d2 := lin.NewV3().Sub(&q2, &p2) // direction of segment 2
+	r := lin.NewV3().Sub(&p1, &p2)
+	a, e, f := d1.Dot(d1), d2.Dot(d2), d2.Dot(r)
+	var s, t float64
+	switch {
+	case a <= EPSILON && e <= EPSILON:
+		return p1, p2 // both segments are points.
+	case a <= EPSILON:
+		t = clamp01(f / e) // first segment is a point.
+	default:
+		c := d1.Dot(r)
+		if e <= EPSILON {
+			s = clamp01(-c / a) // second segment is a point.
+		} else {
+			b := d1.Dot(d2)
+			if denom := a*e - b*b; denom > EPSILON {
+				s = clamp01((b*f - c*e) / denom)
+			} // else parallel segments: use s = 0.
+			t = (b*s + f) / e
+			if t < 0 {
+				t, s = 0, clamp01(-c/a)
+			} else if t > 1 {
+				t, s = 1, clamp01((b-c)/a)
+			}
+		}
+	}
+	c1.Add(&p1, d1.Scale(d1, s))
+	c2.Add(&p2, d2.Scale(d2, t))
+	return c1, c2
+}
+
+// clamp01 limits v to the range 0 to 1.
+func clamp01(v float64) float64 { return max(0, min(v, 1)) }
@@ -0,0 +1,178 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// character.go is a kinematic character controller. It is not part of
+// the original raw-physics port. Characters are capsule bodies moved
+// directly by the app instead of by forces. Each move pushes the capsule
+// out of the bodies it overlaps so that characters slide along walls,
+// step over small ledges, stay on the ground when walking down slopes,
+// and slide off slopes that are too steep.
+
+import (
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Character moves a capsule body through the other simulation bodies.
+// Characters are not moved by the simulation, though they push any
+// kinematic bodies that they overlap.
+type Character struct {
+	StepHeight   float64 // highest ledge that can be stepped over.
+	MaxSlope     float64 // steepest walkable slope in radians.
+	SnapDistance float64 // furthest drop that keeps the character on the ground.
+
+	grounded bool   // true if the last move ended on walkable ground.
+	ground   lin.V3 // ground normal when grounded.
+}
+
+// character_ITERATIONS is the most contacts resolved for each
+// part of a character move.
+const character_ITERATIONS = 4
+
+// character_SKIN ignores contacts with less penetration so that
+// characters resting on the ground are not constantly pushed up.
+const character_SKIN = 1e-4
+
+// NewCharacter returns a character controller and its static capsule
+// body, see NewCapsule. The character steps over ledges up to half its
+// radius, walks on slopes up to 45 degrees, and snaps to ground that
+// is up to half its radius below it.
+func NewCharacter(r, hy float64) (*Character, *Body) {
+	c := &Character{StepHeight: r * 0.5, MaxSlope: math.Pi / 4, SnapDistance: r * 0.5}
+	return c, NewCapsule(r, hy, true)
+}
+
+// Grounded returns true if the character is standing on walkable ground.
+func (c *Character) Grounded() bool { return c.grounded }
+
+// Ground returns the normal of the walkable ground under the character.
+// The normal is only valid when the character is grounded.
+func (c *Character) Ground() lin.V3 { return c.ground }
+
+// Move moves the character body by motion, resolving collisions with
+// the other bodies. The body may be one of the bodies, in which case it
+// is not collided with itself. The vertical part of the motion, eg:
+// gravity or jumping, is applied after the horizontal part. Returns
+// true if the character ended the move on walkable ground.
+func (c *Character) Move(body *Body, bodies []Body, motion lin.V3) bool {
+	if len(body.colliders) == 0 {
+		return false
+	}
+	was_grounded := c.grounded
+	c.grounded = false
+	start := body.world_position
+
+	// slide horizontally, then try stepping over anything in the way.
+	horizontal := lin.V3{X: motion.X, Z: motion.Z}
+	c.move(body, bodies, horizontal)
+	if was_grounded && c.StepHeight > 0 {
+		moved := character_distance(&start, &body.world_position)
+		if wanted := horizontal.Len(); moved < wanted-character_SKIN {
+			c.step(body, bodies, start, horizontal, moved)
+		}
+	}
+
+	// vertical motion, then stay on the ground when walking downhill.
+	c.move(body, bodies, lin.V3{Y: motion.Y})
+	if was_grounded && !c.grounded && motion.Y <= 0 && c.SnapDistance > 0 {
+		before := body.world_position
+		c.move(body, bodies, lin.V3{Y: -c.SnapDistance})
+		if !c.grounded {
+			body.world_position = before // nothing to snap to.
+			colliders_update(body.colliders, body.world_position, &body.world_rotation)
+		}
+	}
+	return c.grounded
+}
+
+// step retries the horizontal move from start after lifting the body
+// by the step height. The step is kept if it ends on walkable ground
+// further along than the plain slide.
+func (c *Character) step(body *Body, bodies []Body, start, horizontal lin.V3, moved float64) {
+	slide, grounded, ground := body.world_position, c.grounded, c.ground
+	body.world_position = start
+	c.move(body, bodies, lin.V3{Y: c.StepHeight})
+	c.move(body, bodies, horizontal)
+	c.grounded = false
+	c.move(body, bodies, lin.V3{Y: -c.StepHeight})
+	if c.grounded && character_distance(&start, &body.world_position) > moved+character_SKIN {
+		return // stepped up.
+	}
+	body.world_position, c.grounded, c.ground = slide, grounded, ground
+	colliders_update(body.colliders, body.world_position, &body.world_rotation)
+}
+
+// move moves the body by delta in steps no longer than a tenth of the
+// capsule radius so that thin bodies are not passed through.
+func (c *Character) move(body *Body, bodies []Body, delta lin.V3) {
+	length := delta.Len()
+	radius := body.bounding_sphere_radius
+	if capsule := &body.colliders[0]; capsule.ctype == collider_TYPE_CAPSULE {
+		radius = capsule.capsule.radius
+	}
+	steps := max(1, int(math.Ceil(length/(radius*0.1))))
+	step := lin.NewV3().Scale(&delta, 1/float64(steps))
+	for i := 0; i < steps; i++ {
+		body.world_position.Add(&body.world_position, step)
+		c.resolve(body, bodies, length)
+	}
+}
+
+// resolve pushes the body out of the deepest overlapping body until
+// there are no overlaps. Walkable ground pushes the body up so that
+// characters don't slide down slopes. Walls and steep slopes push the
+// body sideways so that characters can't climb them.
+func (c *Character) resolve(body *Body, bodies []Body, reach float64) {
+	walkable := math.Cos(c.MaxSlope)
+	for i := 0; i < character_ITERATIONS; i++ {
+		colliders_update(body.colliders, body.world_position, &body.world_rotation)
+		push, depth := character_deepest_contact(body, bodies, reach)
+		if depth <= character_SKIN {
+			return
+		}
+		out := lin.NewV3()
+		switch side := math.Hypot(push.X, push.Z); {
+		case push.Y >= walkable:
+			out.Y = depth / push.Y // straight up.
+			c.grounded, c.ground = true, push
+		case push.Y > 0 && side > 0:
+			out.SetS(push.X, 0, push.Z).Scale(out, depth/(side*side)) // sideways.
+		default:
+			out.Scale(&push, depth) // ceilings and walls.
+		}
+		body.world_position.Add(&body.world_position, out)
+	}
+	colliders_update(body.colliders, body.world_position, &body.world_rotation)
+}
+
+// character_deepest_contact returns the direction and distance that
+// pushes the body out of the body it overlaps the most.
+func character_deepest_contact(body *Body, bodies []Body, reach float64) (push lin.V3, depth float64) {
+	for i := range bodies {
+		other := &bodies[i]
+		if len(other.colliders) == 0 || &other.colliders[0] == &body.colliders[0] {
+			continue // the character itself.
+		}
+		if other.trigger || !layers_collide(body, other) {
+			continue // bodies that the character passes through.
+		}
+		distance := lin.NewV3().Sub(&body.world_position, &other.world_position).Len()
+		if distance > body.bounding_sphere_radius+other.bounding_sphere_radius+reach {
+			continue
+		}
+		colliders_update(other.colliders, other.world_position, &other.world_rotation)
+		for _, contact := range colliders_get_contacts(body.colliders, other.colliders) {
+			penetration := lin.NewV3().Sub(&contact.collision_point1, &contact.collision_point2).Dot(&contact.normal)
+			if penetration > depth {
+				depth = penetration
+				push.Neg(&contact.normal) // normals point from the character to the other body.
+			}
+		}
+	}
+	return push, depth
+}
+
+// character_distance returns the horizontal distance between a and b.
+func character_distance(a, b *lin.V3) float64 { return math.Hypot(b.X-a.X, b.Z-a.Z) }
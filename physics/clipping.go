@@ -347,7 +347,7 @@ func convex_convex_contact_manifold(collider1, collider2 *collider,
 // clipping_get_contact_manifold
 func clipping_get_contact_manifold(collider1, collider2 *collider,
 	normal lin.V3, penetration float64, contacts []collider_Contact) []collider_Contact {
-	// TODO: For now, we only consider CONVEX and SPHERE colliders.
+	// TODO: For now, we only consider CONVEX, SPHERE, and CAPSULE colliders.
 	// If new colliders are added, we can think about making this more generic.
 
 	switch {
@@ -368,6 +368,10 @@ func clipping_get_contact_manifold(collider1, collider2 *collider,
 		contact.collision_point2 = sphere_collision_point
 		contact.normal = normal
 		contacts = append(contacts, contact)
+	case collider1.ctype == collider_TYPE_CAPSULE:
+		contacts = capsule_contact_manifold(&collider1.capsule, true, normal, penetration, contacts)
+	case collider2.ctype == collider_TYPE_CAPSULE:
+		contacts = capsule_contact_manifold(&collider2.capsule, false, normal, penetration, contacts)
 	case collider1.ctype == collider_TYPE_CONVEX_HULL && collider2.ctype == collider_TYPE_CONVEX_HULL:
 		contacts = convex_convex_contact_manifold(collider1, collider2, normal, contacts)
 	default:
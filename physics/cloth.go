@@ -0,0 +1,330 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// cloth.go is a position based dynamics cloth solver for flags, capes,
+// and curtains. It is not part of the original raw-physics port.
+// Cloth is a triangle mesh where each vertex is a particle. Triangle
+// edges keep particles at their rest distance and the far vertexes of
+// neighbouring triangles resist bending. Particles are pushed out of
+// the simulation bodies, but the cloth does not push the bodies.
+// Based on Small Steps in Physics Simulation, Macklin et al.
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Cloth is a sheet of particles simulated in world space.
+type Cloth struct {
+	Stretch   float64 // stretch compliance, 0 for cloth that doesn't stretch.
+	Bend      float64 // bend compliance, larger values bend more easily.
+	Damping   float64 // fraction of the particle velocity lost each second.
+	Friction  float64 // fraction of sliding removed when touching bodies: 0 to 1.
+	Thickness float64 // closest distance between the cloth and bodies.
+	Wind      lin.V3  // wind velocity.
+	Drag      float64 // how much the wind pushes the cloth.
+
+	positions    []lin.V3  // particle world positions.
+	previous     []lin.V3  // particle positions before the last substep.
+	velocities   []lin.V3  // particle velocities.
+	inverse_mass []float64 // 0 for pinned particles.
+	normals      []lin.V3  // particle normals from the triangles.
+	indexes      []uint32  // triangles.
+	stretch      []cloth_Constraint
+	bend         []cloth_Constraint
+}
+
+// cloth_Constraint keeps two particles at a rest distance.
+type cloth_Constraint struct {
+	p1, p2 uint32
+	rest   float64
+}
+
+// cloth_SUBSTEPS is the number of solver steps for each Simulate.
+const cloth_SUBSTEPS = 10
+
+// cloth_GRAVITY matches the gravity of the rigid body simulation.
+const cloth_GRAVITY = 10.0
+
+// NewCloth creates a cloth from triangles given as counter-clockwise
+// indexes into the world space vertexes. Each vertex is a particle with
+// the same mass. Returns nil if there are no triangles.
+func NewCloth(vertexes []lin.V3, indexes []uint32) *Cloth {
+	if len(indexes) < 3 || len(indexes)%3 != 0 {
+		slog.Error("NewCloth needs triangles", "indexes", len(indexes))
+		return nil
+	}
+	for _, index := range indexes {
+		if int(index) >= len(vertexes) {
+			slog.Error("NewCloth invalid index", "index", index, "vertexes", len(vertexes))
+			return nil
+		}
+	}
+	n := len(vertexes)
+	c := &Cloth{Bend: 1e-4, Damping: 0.1, Friction: 0.5, Thickness: 0.02, Drag: 1}
+	c.positions = append([]lin.V3{}, vertexes...)
+	c.previous = append([]lin.V3{}, vertexes...)
+	c.velocities = make([]lin.V3, n)
+	c.normals = make([]lin.V3, n)
+	c.inverse_mass = make([]float64, n)
+	for i := range c.inverse_mass {
+		c.inverse_mass[i] = 1
+	}
+	c.indexes = append([]uint32{}, indexes...)
+
+	// triangle edges stretch. The far vertexes of the two
+	// triangles that share an edge bend.
+	type edge struct{ a, b uint32 }
+	far := map[edge]uint32{} // the third vertex of the first triangle with the edge.
+	for t := 0; t < len(indexes); t += 3 {
+		tri := indexes[t : t+3]
+		for k := 0; k < 3; k++ {
+			a, b, opposite := tri[k], tri[(k+1)%3], tri[(k+2)%3]
+			e := edge{min(a, b), max(a, b)}
+			if other, ok := far[e]; ok {
+				c.bend = append(c.bend, c.constraint(opposite, other))
+				continue
+			}
+			far[e] = opposite
+			c.stretch = append(c.stretch, c.constraint(a, b))
+		}
+	}
+	c.update_normals()
+	return c
+}
+
+// constraint returns a constraint at the current particle distance.
+func (c *Cloth) constraint(p1, p2 uint32) cloth_Constraint {
+	return cloth_Constraint{p1: p1, p2: p2, rest: c.positions[p1].Dist(&c.positions[p2])}
+}
+
+// Particles returns the particle world positions. The positions are
+// updated by Simulate and are expected to be treated as read only.
+func (c *Cloth) Particles() []lin.V3 { return c.positions }
+
+// Normals returns the particle normals, updated by Simulate.
+func (c *Cloth) Normals() []lin.V3 { return c.normals }
+
+// Pin stops the simulation from moving particle i. Pinned particles
+// are moved by the application using MoveParticle, eg: to attach a
+// cape to a character.
+func (c *Cloth) Pin(i int, pinned bool) {
+	if i < 0 || i >= len(c.positions) {
+		slog.Error("Cloth.Pin invalid particle", "particle", i)
+		return
+	}
+	c.inverse_mass[i] = 1
+	if pinned {
+		c.inverse_mass[i] = 0
+		c.velocities[i] = lin.V3{}
+	}
+}
+
+// Pinned returns true if particle i is pinned.
+func (c *Cloth) Pinned(i int) bool {
+	return i >= 0 && i < len(c.positions) && c.inverse_mass[i] == 0
+}
+
+// MoveParticle places particle i at the world position without
+// giving it any velocity.
+func (c *Cloth) MoveParticle(i int, at lin.V3) {
+	if i < 0 || i >= len(c.positions) {
+		slog.Error("Cloth.MoveParticle invalid particle", "particle", i)
+		return
+	}
+	c.positions[i], c.previous[i] = at, at
+}
+
+// Simulate moves the cloth particles for the given timestep. The cloth
+// collides with the bodies but does not move them. Expected to be
+// called after the bodies are simulated for the same timestep.
+func (c *Cloth) Simulate(bods []Body, timestep float64) {
+	if timestep <= 0 {
+		return
+	}
+	h := timestep / cloth_SUBSTEPS
+	nearby := c.nearby(bods, timestep)
+	for step := 0; step < cloth_SUBSTEPS; step++ {
+		c.integrate(h)
+		for i := range c.stretch {
+			c.solve(&c.stretch[i], c.Stretch/(h*h))
+		}
+		for i := range c.bend {
+			c.solve(&c.bend[i], c.Bend/(h*h))
+		}
+		for _, id := range nearby {
+			c.collide(&bods[id])
+		}
+		for i := range c.positions {
+			if c.inverse_mass[i] > 0 {
+				c.velocities[i].Sub(&c.positions[i], &c.previous[i]).Scale(&c.velocities[i], 1/h)
+			}
+		}
+	}
+	c.update_normals()
+}
+
+// integrate moves the particles by their velocities after applying
+// gravity, wind, and damping.
+func (c *Cloth) integrate(h float64) {
+	damping := max(0, 1-c.Damping*h)
+	for i := range c.positions {
+		p, v := &c.positions[i], &c.velocities[i]
+		c.previous[i] = *p
+		if c.inverse_mass[i] == 0 {
+			continue
+		}
+		v.Y -= cloth_GRAVITY * h
+		if c.Drag > 0 {
+			n := &c.normals[i] // wind pushes the cloth along its normal.
+			push := n.Dot(lin.NewV3().Sub(&c.Wind, v)) * c.Drag * h
+			v.Add(v, lin.NewV3().Scale(n, push))
+		}
+		v.Scale(v, damping)
+		p.Add(p, lin.NewV3().Scale(v, h))
+	}
+}
+
+// solve moves a constraint's particles towards their rest distance.
+// Compliance is scaled by the inverse of the substep squared.
+func (c *Cloth) solve(con *cloth_Constraint, compliance float64) {
+	w1, w2 := c.inverse_mass[con.p1], c.inverse_mass[con.p2]
+	if w1+w2 == 0 {
+		return
+	}
+	p1, p2 := &c.positions[con.p1], &c.positions[con.p2]
+	delta := lin.NewV3().Sub(p1, p2)
+	length := delta.Len()
+	if length < 1e-12 {
+		return
+	}
+	lambda := -(length - con.rest) / (w1 + w2 + compliance)
+	delta.Scale(delta, lambda/length)
+	p1.Add(p1, lin.NewV3().Scale(delta, w1))
+	p2.Sub(p2, lin.NewV3().Scale(delta, w2))
+}
+
+// nearby returns the bodies that the cloth may touch this timestep.
+func (c *Cloth) nearby(bods []Body, timestep float64) []bid {
+	lo, hi := c.positions[0], c.positions[0]
+	fastest := 0.0
+	for i := range c.positions {
+		lo.Min(&lo, &c.positions[i])
+		hi.Max(&hi, &c.positions[i])
+		fastest = max(fastest, c.velocities[i].Len())
+	}
+	margin := c.Thickness + (fastest+cloth_GRAVITY*timestep)*timestep
+	lo.SetS(lo.X-margin, lo.Y-margin, lo.Z-margin)
+	hi.SetS(hi.X+margin, hi.Y+margin, hi.Z+margin)
+	broad_tree_update(bods)
+	nearby := []bid{}
+	tree_query(&broad_tree, lo, hi, func(id bid) {
+		if b := &bods[id]; !b.trigger {
+			colliders_update(b.colliders, b.world_position, &b.world_rotation)
+			nearby = append(nearby, id)
+		}
+	})
+	return nearby
+}
+
+// collide pushes the particles out of the body.
+func (c *Cloth) collide(b *Body) {
+	reach := b.bounding_sphere_radius + c.Thickness
+	for i := range c.positions {
+		p := &c.positions[i]
+		if c.inverse_mass[i] == 0 || p.DistSqr(&b.world_position) > reach*reach {
+			continue
+		}
+		for k := range b.colliders {
+			if normal, ok := cloth_push(&b.colliders[k], p, &c.previous[i], c.Thickness); ok {
+				// remove some of the sliding along the body surface.
+				moved := lin.NewV3().Sub(p, &c.previous[i])
+				slide := moved.Sub(moved, lin.NewV3().Scale(&normal, normal.Dot(moved)))
+				p.Sub(p, slide.Scale(slide, c.Friction))
+			}
+		}
+	}
+}
+
+// cloth_push moves the point out of the collider by the thickness.
+// Returns the direction the point was pushed.
+func cloth_push(collider *collider, p, previous *lin.V3, thickness float64) (normal lin.V3, ok bool) {
+	switch collider.ctype {
+	case collider_TYPE_SPHERE, collider_TYPE_CAPSULE:
+		a, b, r, _ := collider_segment(collider)
+		closest := lin.NewV3().ClosestPointOnSegment(p, &a, &b)
+		return cloth_push_from(p, previous, closest, lin.V3{Y: 1}, r+thickness)
+	case collider_TYPE_CONVEX_HULL:
+		hull := &collider.convex_hull
+		nearest := -math.MaxFloat64
+		for i := range hull.transformed_faces {
+			face := &hull.transformed_faces[i]
+			d := face.normal.Dot(lin.NewV3().Sub(p, &hull.transformed_vertices[face.elements[0]]))
+			if d >= thickness {
+				return normal, false // outside the hull.
+			}
+			if d > nearest {
+				nearest, normal = d, face.normal
+			}
+		}
+		p.Add(p, lin.NewV3().Scale(&normal, thickness-nearest))
+		return normal, len(hull.transformed_faces) > 0
+	case collider_TYPE_MESH:
+		lo := lin.V3{X: p.X - thickness, Y: p.Y - thickness, Z: p.Z - thickness}
+		hi := lin.V3{X: p.X + thickness, Y: p.Y + thickness, Z: p.Z + thickness}
+		for _, t := range mesh_bvh_query(collider.mesh, lo, hi, nil) {
+			v := collider.mesh.triangles[t].convex_hull.transformed_vertices
+			closest := lin.NewV3().ClosestPointOnTriangle(p, &v[0], &v[1], &v[2])
+			side := lin.NewV3().Cross(lin.NewV3().Sub(&v[1], &v[0]), lin.NewV3().Sub(&v[2], &v[0])).Unit()
+			if side.Dot(lin.NewV3().Sub(previous, closest)) < 0 {
+				side.Neg(side) // push back to the side the particle came from.
+			}
+			if n, pushed := cloth_push_from(p, previous, closest, *side, thickness); pushed {
+				normal, ok = n, true
+			}
+		}
+		return normal, ok
+	}
+	return normal, false
+}
+
+// cloth_push_from moves the point to be at least distance from the
+// closest point. Points that are on the closest point are pushed
+// along the fallback direction.
+func cloth_push_from(p, previous, closest *lin.V3, fallback lin.V3, distance float64) (normal lin.V3, ok bool) {
+	offset := lin.NewV3().Sub(p, closest)
+	length := offset.Len()
+	if length >= distance {
+		return normal, false
+	}
+	normal = fallback
+	if length > 1e-9 {
+		normal = *offset.Scale(offset, 1/length)
+	}
+	p.Add(closest, lin.NewV3().Scale(&normal, distance))
+	return normal, true
+}
+
+// update_normals sets the particle normals from the
+// area weighted normals of their triangles.
+func (c *Cloth) update_normals() {
+	for i := range c.normals {
+		c.normals[i] = lin.V3{}
+	}
+	for t := 0; t < len(c.indexes); t += 3 {
+		i1, i2, i3 := c.indexes[t], c.indexes[t+1], c.indexes[t+2]
+		p1, p2, p3 := &c.positions[i1], &c.positions[i2], &c.positions[i3]
+		n := lin.NewV3().Cross(lin.NewV3().Sub(p2, p1), lin.NewV3().Sub(p3, p1))
+		for _, i := range []uint32{i1, i2, i3} {
+			c.normals[i].Add(&c.normals[i], n)
+		}
+	}
+	for i := range c.normals {
+		if c.normals[i].Len() > 0 {
+			c.normals[i].Unit()
+		}
+	}
+}
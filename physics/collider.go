@@ -46,6 +46,8 @@ type collider_Type uint8
 const (
 	collider_TYPE_SPHERE collider_Type = iota
 	collider_TYPE_CONVEX_HULL
+	collider_TYPE_MESH    // static triangle mesh, see mesh.go.
+	collider_TYPE_CAPSULE // see capsule.go.
 )
 
 // collider;
@@ -53,6 +55,8 @@ type collider struct {
 	ctype       collider_Type
 	convex_hull collider_Convex_Hull // hull...
 	sphere      collider_Sphere      // ...or sphere based on type
+	mesh        *collider_Mesh       // ...or triangle mesh...
+	capsule     collider_Capsule     // ...or capsule.
 }
 
 // @NOTE: for simplicity (and speed), we don't deal with scaling in the colliders.
@@ -436,6 +440,10 @@ func collider_update(collider *collider, translation lin.V3, rotation *lin.Q) {
 		}
 	case collider_TYPE_SPHERE:
 		collider.sphere.center = translation
+	case collider_TYPE_MESH:
+		mesh_update(collider.mesh, translation, rotation)
+	case collider_TYPE_CAPSULE:
+		capsule_update(&collider.capsule, translation, rotation)
 	default:
 		slog.Error("collider_update: unsupported collider type", "collider_type", collider.ctype)
 	}
@@ -463,6 +471,9 @@ func colliders_get_default_inertia_tensor(colliders []collider, mass float64) li
 			result.Zz = I
 			return *result
 		}
+		if collider.ctype == collider_TYPE_CAPSULE {
+			return capsule_inertia_tensor(&collider.capsule, mass)
+		}
 	}
 
 	total_num_vertices := 0
@@ -501,6 +512,10 @@ func collider_get_bounding_sphere_radius(collider *collider) float64 {
 		return get_convex_hull_collider_bounding_sphere_radius(collider)
 	case collider_TYPE_SPHERE:
 		return get_sphere_collider_bounding_sphere_radius(collider)
+	case collider_TYPE_MESH:
+		return collider.mesh.radius
+	case collider_TYPE_CAPSULE:
+		return collider.capsule.half_height + collider.capsule.radius
 	}
 	slog.Error("collider_get_bounding_sphere_radius: no bounding radius")
 	return 0.0
@@ -524,6 +539,11 @@ func collider_get_contacts(collider1, collider2 *collider, contacts []collider_C
 	var simplex gjk_Simplex
 	normal := lin.NewV3()
 
+	// Meshes collide using the triangles near the other collider.
+	if collider1.ctype == collider_TYPE_MESH || collider2.ctype == collider_TYPE_MESH {
+		return mesh_get_contacts(collider1, collider2, contacts)
+	}
+
 	// If both colliders are spheres, calling EPA is not only extremely slow, but also provide bad results.
 	// GJK is also not necessary. In this case, just calculate everything analytically.
 	if collider1.ctype == collider_TYPE_SPHERE && collider2.ctype == collider_TYPE_SPHERE {
@@ -539,6 +559,13 @@ func collider_get_contacts(collider1, collider2 *collider, contacts []collider_C
 		return contacts
 	}
 
+	// Spheres and capsules are also found analytically using their segments.
+	_, _, _, round1 := collider_segment(collider1)
+	_, _, _, round2 := collider_segment(collider2)
+	if round1 && round2 {
+		return round_get_contacts(collider1, collider2, contacts)
+	}
+
 	// Call GJK to check if there is a collision
 	if gjk_collides(collider1, collider2, &simplex) {
 		// There is a collision.  Get the collision normal using EPA
@@ -0,0 +1,137 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// contact.go filters collisions using body layers and reports the
+// bodies that touched during the last simulation step. It is not part
+// of the original raw-physics port. Trigger bodies report the bodies
+// that overlap them without colliding.
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Contact is where two bodies touched during the last simulation step.
+type Contact struct {
+	B1, B2  int    // indexes of the touching bodies where B1 < B2.
+	Point   lin.V3 // world location of the contact.
+	Normal  lin.V3 // contact normal pointing from B1 to B2.
+	Trigger bool   // true if either body is a trigger.
+}
+
+// Contacts appends the contacts from the last call to Simulate to
+// found and returns the result. The contacts are ordered by body
+// and there is at most one contact for each pair of bodies.
+func Contacts(found []Contact) []Contact { return append(found, contacts...) }
+
+// SetLayer sets the collision layer bits of the body and the mask of
+// layers that it collides with. Bodies only collide when each body's
+// layer is in the other body's mask. Bodies are created on layer 1
+// and collide with all layers.
+func (body *Body) SetLayer(layer, mask uint32) { body.layer, body.mask = layer, mask }
+
+// Layer returns the body collision layer and mask.
+func (body *Body) Layer() (layer, mask uint32) { return body.layer, body.mask }
+
+// SetTrigger makes the body a trigger volume that reports the bodies
+// that overlap it, see Contacts, instead of colliding with them.
+func (body *Body) SetTrigger(trigger bool) { body.trigger = trigger }
+
+// Trigger returns true if the body is a trigger volume.
+func (body *Body) Trigger() bool { return body.trigger }
+
+// contacts are the contacts from the last simulation step.
+var contacts []Contact
+
+// contact_step holds the contacts found during the current simulation step.
+var contact_step = map[broad_Collision_Pair]Contact{}
+
+// contact_last holds the contacts from the last simulation step using
+// the body colliders as keys so that sleeping bodies, whose contacts
+// are not checked, keep touching.
+var contact_last = map[contact_Key]Contact{}
+
+// contact_Key identifies a pair of bodies across simulation steps.
+type contact_Key struct {
+	c1, c2 *collider
+}
+
+// layers_collide returns true if each body is in the other body's mask.
+func layers_collide(b1, b2 *Body) bool {
+	return b1.layer&b2.mask != 0 && b2.layer&b1.mask != 0
+}
+
+// contact_split_triggers removes the pairs with trigger bodies from the
+// collision pairs and returns them separately. Triggers don't overlap
+// static bodies.
+func contact_split_triggers(bodies []Body, pairs []broad_Collision_Pair) (collision_pairs, trigger_pairs []broad_Collision_Pair) {
+	collision_pairs = pairs[:0]
+	for _, pair := range pairs {
+		b1, b2 := &bodies[pair.b1_id], &bodies[pair.b2_id]
+		switch {
+		case !b1.trigger && !b2.trigger:
+			collision_pairs = append(collision_pairs, pair)
+		case !b1.fixed || !b2.fixed:
+			trigger_pairs = append(trigger_pairs, pair)
+		}
+	}
+	return collision_pairs, trigger_pairs
+}
+
+// contact_record saves the deepest of the contacts between two bodies.
+func contact_record(bid1, bid2 bid, found []collider_Contact, trigger bool) {
+	deepest, depth := -1, math.Inf(-1)
+	for i := range found {
+		c := &found[i]
+		penetration := lin.NewV3().Sub(&c.collision_point1, &c.collision_point2).Dot(&c.normal)
+		if penetration > depth {
+			deepest, depth = i, penetration
+		}
+	}
+	if deepest < 0 {
+		return
+	}
+	c := &found[deepest]
+	point := lin.NewV3().Add(&c.collision_point1, &c.collision_point2)
+	contact_step[broad_Collision_Pair{bid1, bid2}] = Contact{
+		B1: int(bid1), B2: int(bid2), Point: *point.Scale(point, 0.5), Normal: c.normal, Trigger: trigger,
+	}
+}
+
+// contact_keep keeps the contact from the last simulation step between
+// two bodies whose collisions were not checked because they are asleep.
+func contact_keep(b1, b2 *Body, bid1, bid2 bid) {
+	if c, ok := contact_last[contact_Key{&b1.colliders[0], &b2.colliders[0]}]; ok {
+		c.B1, c.B2 = int(bid1), int(bid2)
+		contact_step[broad_Collision_Pair{bid1, bid2}] = c
+	}
+}
+
+// contacts_collect adds the trigger overlaps to the contacts found
+// during the simulation step and saves them for Contacts.
+func contacts_collect(bodies []Body, trigger_pairs []broad_Collision_Pair) {
+	for _, pair := range trigger_pairs {
+		b1, b2 := &bodies[pair.b1_id], &bodies[pair.b2_id]
+		colliders_update(b1.colliders, b1.world_position, &b1.world_rotation)
+		colliders_update(b2.colliders, b2.world_position, &b2.world_rotation)
+		contact_record(pair.b1_id, pair.b2_id, colliders_get_contacts(b1.colliders, b2.colliders), true)
+	}
+	contacts = contacts[:0]
+	clear(contact_last)
+	for pair, c := range contact_step {
+		contacts = append(contacts, c)
+		b1, b2 := &bodies[pair.b1_id], &bodies[pair.b2_id]
+		contact_last[contact_Key{&b1.colliders[0], &b2.colliders[0]}] = c
+	}
+	clear(contact_step)
+	slices.SortFunc(contacts, func(a, b Contact) int {
+		if a.B1 != b.B1 {
+			return cmp.Compare(a.B1, b.B1)
+		}
+		return cmp.Compare(a.B2, b.B2)
+	})
+}
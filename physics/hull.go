@@ -0,0 +1,204 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// hull.go builds convex hulls from points. It is not part of the
+// original raw-physics port. The hull is built incrementally: start
+// with a tetrahedron and add each point outside the hull by replacing
+// the faces the point can see with faces joining the point to the
+// horizon edges of the visible faces.
+
+import (
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// ConvexHull returns the smallest convex shape enclosing the points
+// as hull vertexes and counter-clockwise triangle indexes. Points
+// inside the hull are dropped. Returns nil if the points are flat,
+// ie: there are less than 4 points that are not on the same plane.
+func ConvexHull(points []lin.V3) (vertexes []lin.V3, indexes []uint32) {
+	tetra, eps := hull_initial_tetrahedron(points)
+	if tetra == nil {
+		return nil, nil
+	}
+
+	// orient the tetrahedron faces away from its center.
+	center := lin.V3{}
+	for _, i := range tetra {
+		center.Add(&center, &points[i])
+	}
+	center.Scale(&center, 0.25)
+	faces := []v3Int{}
+	for _, f := range [][3]int{{0, 1, 2}, {0, 2, 3}, {0, 3, 1}, {1, 3, 2}} {
+		face := v3Int{tetra[f[0]], tetra[f[1]], tetra[f[2]]}
+		if hull_face_distance(points, face, center) > 0 {
+			face.y, face.z = face.z, face.y
+		}
+		faces = append(faces, face)
+	}
+
+	// add the point furthest outside the hull until all points are inside.
+	// Adding the furthest points first keeps points on the final hull
+	// faces from becoming vertexes.
+	outside := make([]uint32, 0, len(points))
+	for i := range points {
+		outside = append(outside, uint32(i))
+	}
+	for {
+		i, furthest := uint32(0), eps
+		remaining := outside[:0]
+		for _, pi := range outside {
+			dist := -math.MaxFloat64
+			for _, face := range faces {
+				dist = max(dist, hull_face_distance(points, face, points[pi]))
+			}
+			if dist > eps {
+				remaining = append(remaining, pi) // inside points stay inside.
+				if dist > furthest {
+					i, furthest = pi, dist
+				}
+			}
+		}
+		outside = remaining
+		if len(outside) == 0 {
+			break
+		}
+		visible := faces[:0:0]
+		kept := []v3Int{}
+		for _, face := range faces {
+			if hull_face_distance(points, face, points[i]) > eps {
+				visible = append(visible, face)
+			} else {
+				kept = append(kept, face)
+			}
+		}
+
+		// horizon edges belong to one visible face. Keeping the visible
+		// face edge direction keeps the new faces counter-clockwise.
+		edges := map[v2Int]bool{}
+		for _, f := range visible {
+			for _, e := range []v2Int{{f.x, f.y}, {f.y, f.z}, {f.z, f.x}} {
+				if edges[v2Int{e.y, e.x}] {
+					delete(edges, v2Int{e.y, e.x}) // shared by two visible faces.
+				} else {
+					edges[e] = true
+				}
+			}
+		}
+		for _, f := range visible {
+			for _, e := range []v2Int{{f.x, f.y}, {f.y, f.z}, {f.z, f.x}} {
+				if edges[e] {
+					kept = append(kept, v3Int{e.x, e.y, i})
+				}
+			}
+		}
+		faces = kept
+	}
+
+	// return only the points used by the hull faces.
+	remap := map[uint32]uint32{}
+	for _, f := range faces {
+		for _, i := range []uint32{f.x, f.y, f.z} {
+			if _, ok := remap[i]; !ok {
+				remap[i] = uint32(len(vertexes))
+				vertexes = append(vertexes, points[i])
+			}
+			indexes = append(indexes, remap[i])
+		}
+	}
+	return vertexes, indexes
+}
+
+// NewConvexHull creates a physics body from the convex hull of the
+// points, see ConvexHull. The points are relative to the body center
+// which is located at the origin. Returns nil if the points are flat.
+// The hull can be static (unmovable) or kinematic (moveable).
+func NewConvexHull(points []lin.V3, static bool) *Body {
+	vertexes, indexes := ConvexHull(points)
+	if vertexes == nil {
+		return nil
+	}
+	return hull_body_create(vertexes, indexes, static)
+}
+
+// hull_initial_tetrahedron returns the indexes of 4 points that are not
+// on the same plane, and a distance tolerance based on the point spread.
+// Returns nil if there is no such tetrahedron.
+func hull_initial_tetrahedron(points []lin.V3) (tetra []uint32, eps float64) {
+	if len(points) < 4 {
+		return nil, 0
+	}
+
+	// start with the points furthest apart along the x, y, or z axis.
+	lo, hi := [3]uint32{}, [3]uint32{}
+	for i := range points {
+		p, ix := &points[i], uint32(i)
+		for axis := 0; axis < 3; axis++ {
+			if hull_axis(p, axis) < hull_axis(&points[lo[axis]], axis) {
+				lo[axis] = ix
+			}
+			if hull_axis(p, axis) > hull_axis(&points[hi[axis]], axis) {
+				hi[axis] = ix
+			}
+		}
+	}
+	a, b, spread := uint32(0), uint32(0), 0.0
+	for axis := 0; axis < 3; axis++ {
+		if d := hull_axis(&points[hi[axis]], axis) - hull_axis(&points[lo[axis]], axis); d > spread {
+			a, b, spread = lo[axis], hi[axis], d
+		}
+	}
+	eps = spread * 1e-7
+	if spread == 0 {
+		return nil, 0
+	}
+
+	// the point furthest from the line ab.
+	ab := lin.NewV3().Sub(&points[b], &points[a])
+	c, best := uint32(0), 0.0
+	for i := range points {
+		ap := lin.NewV3().Sub(&points[i], &points[a])
+		if d := lin.NewV3().Cross(ab, ap).Len(); d > best {
+			c, best = uint32(i), d
+		}
+	}
+	if best <= eps*spread {
+		return nil, 0 // all points on a line.
+	}
+
+	// the point furthest from the plane abc.
+	face := v3Int{a, b, c}
+	d, best := uint32(0), 0.0
+	for i := range points {
+		if dist := math.Abs(hull_face_distance(points, face, points[i])); dist > best {
+			d, best = uint32(i), dist
+		}
+	}
+	if best <= eps {
+		return nil, 0 // all points on a plane.
+	}
+	return []uint32{a, b, c, d}, eps
+}
+
+// hull_face_distance returns the distance of p above the plane
+// of the counter-clockwise face, negative if p is below the face.
+func hull_face_distance(points []lin.V3, face v3Int, p lin.V3) float64 {
+	v1, v2, v3 := &points[face.x], &points[face.y], &points[face.z]
+	v12 := lin.NewV3().Sub(v2, v1)
+	v13 := lin.NewV3().Sub(v3, v1)
+	normal := lin.NewV3().Cross(v12, v13).Unit()
+	return normal.Dot(lin.NewV3().Sub(&p, v1))
+}
+
+// hull_axis returns the x, y, or z value of p for axis 0, 1, or 2.
+func hull_axis(p *lin.V3, axis int) float64 {
+	switch axis {
+	case 0:
+		return p.X
+	case 1:
+		return p.Y
+	}
+	return p.Z
+}
@@ -0,0 +1,333 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// joint.go connects pairs of bodies so that doors, ragdolls, and
+// vehicles can be built from constrained bodies. It is not part of
+// the original raw-physics port, though it follows the XPBD joint
+// approach of the ported hinge and spherical joints in pbd.go.
+//
+// Each joint has an X axis, eg: the hinge or slider axis, and Y and Z
+// axes at right angles to X. Movement along each axis is locked, limited,
+// or free. Rotation is split into twist around the X axis and swing of
+// the X axis. The joint types set up the axes for common joints.
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// JointType identifies the initial axis settings of a joint.
+type JointType uint8
+
+// Joint types.
+const (
+	FixedJoint   JointType = iota // no movement between the bodies.
+	HingeJoint                    // twists around the X axis, eg: a door.
+	BallJoint                     // twists and swings freely, eg: a shoulder.
+	SliderJoint                   // moves along the X axis, eg: a piston.
+	GenericJoint                  // 6-DOF: starts locked, app sets each axis.
+)
+
+// JointMode controls the movement along, or around, a joint axis.
+type JointMode uint8
+
+// Joint modes.
+const (
+	JointLocked  JointMode = iota // no movement.
+	JointLimited                  // movement between the Lower and Upper limits.
+	JointFree                     // any movement.
+)
+
+// JointAxis controls the movement along, or around, one joint axis.
+// Limits and motor speeds are distances and distance per second for
+// linear axes, and radians and radians per second for twist and swing.
+type JointAxis struct {
+	Mode         JointMode
+	Lower, Upper float64 // JointLimited range. Swing only uses Upper.
+	MotorSpeed   float64 // target velocity of body2 relative to body1.
+	MotorForce   float64 // maximum motor force or torque, 0 for no motor.
+}
+
+// Joint connects two of the simulated bodies. Joints refer to bodies
+// using their index in the bodies slice given to Simulate. Use a static
+// body to attach a body to the world. Joined bodies do not collide with
+// each other.
+type Joint struct {
+	B1, B2     int          // joined body indexes.
+	Linear     [3]JointAxis // movement along the X, Y, and Z axes.
+	Twist      JointAxis    // rotation around the X axis.
+	Swing      JointAxis    // rotation of the X axis, Swing.Upper is the cone angle.
+	Compliance float64      // softness: inverse stiffness, 0 for rigid.
+
+	jtype   JointType
+	anchor1 lin.V3  // joint position in body1 local coordinates.
+	anchor2 lin.V3  // joint position in body2 local coordinates.
+	x1, y1  lin.V3  // joint X and Y axes in body1 local coordinates.
+	x2, y2  lin.V3  // joint X and Y axes in body2 local coordinates.
+	angle   float64 // twist angle from the last simulation step.
+}
+
+// NewJoint creates a joint between bodies b1 and b2 at the world position
+// pivot with the joint X axis pointing along the world direction axis.
+// The bodies world positions and rotations must be set before calling
+// NewJoint. Returns nil if the body indexes are not valid.
+func NewJoint(jtype JointType, bodies []Body, b1, b2 int, pivot, axis lin.V3) *Joint {
+	if b1 < 0 || b2 < 0 || b1 >= len(bodies) || b2 >= len(bodies) || b1 == b2 {
+		slog.Error("NewJoint invalid bodies", "b1", b1, "b2", b2, "bodies", len(bodies))
+		return nil
+	}
+	if axis.Len() == 0 {
+		axis = lin.V3{X: 1}
+	}
+	axis.Unit()
+	perp, unused := lin.NewV3(), lin.NewV3()
+	axis.Plane(perp, unused)
+
+	j := &Joint{B1: b1, B2: b2, jtype: jtype}
+	body1, body2 := &bodies[b1], &bodies[b2]
+	j.anchor1, j.x1, j.y1 = joint_local(body1, pivot, axis, *perp)
+	j.anchor2, j.x2, j.y2 = joint_local(body2, pivot, axis, *perp)
+	switch jtype {
+	case HingeJoint:
+		j.Twist.Mode = JointFree
+	case BallJoint:
+		j.Twist.Mode, j.Swing.Mode = JointFree, JointFree
+	case SliderJoint:
+		j.Linear[0].Mode = JointFree
+	}
+	return j
+}
+
+// joint_local returns the world pivot and axes in body local coordinates.
+func joint_local(body *Body, pivot, x, y lin.V3) (anchor, lx, ly lin.V3) {
+	inv := lin.NewQ().Inv(&body.world_rotation)
+	anchor.Sub(&pivot, &body.world_position).MultQ(&anchor, inv)
+	lx.MultQ(&x, inv)
+	ly.MultQ(&y, inv)
+	return anchor, lx, ly
+}
+
+// Type returns the joint type given to NewJoint.
+func (j *Joint) Type() JointType { return j.jtype }
+
+// main returns the axis used by SetLimits and SetMotor: the twist
+// axis for hinges, the X axis for sliders, and the swing for ball joints.
+func (j *Joint) main() *JointAxis {
+	switch j.jtype {
+	case SliderJoint:
+		return &j.Linear[0]
+	case BallJoint:
+		return &j.Swing
+	}
+	return &j.Twist
+}
+
+// SetLimits limits the hinge angle, slider distance, or ball joint
+// swing. Ball joints swing up to the upper angle in any direction.
+func (j *Joint) SetLimits(lower, upper float64) *Joint {
+	axis := j.main()
+	axis.Mode, axis.Lower, axis.Upper = JointLimited, lower, upper
+	return j
+}
+
+// SetMotor drives the hinge, slider, or ball joint swing at the given
+// speed using up to the given force. Ball joint motors swing the X axis
+// around the joint Y axis. A zero force turns the motor off.
+func (j *Joint) SetMotor(speed, force float64) *Joint {
+	axis := j.main()
+	axis.MotorSpeed, axis.MotorForce = speed, force
+	return j
+}
+
+// Angle returns the twist of body2 relative to body1 in radians
+// as of the last simulation step.
+func (j *Joint) Angle() float64 { return j.angle }
+
+// joint_SUBSTEPS is the number of simulation substeps used when
+// there are joints. More substeps make the joints stiffer.
+const joint_SUBSTEPS = 8
+
+// joint_Constraint solves a joint each simulation substep.
+type joint_Constraint struct {
+	joint        *Joint
+	lambda_pos   float64
+	lambda_swing float64
+	lambda_twist float64
+}
+
+// pbd_joint_constraint_init
+func pbd_joint_constraint_init(constraint *constraint, joint *Joint) {
+	constraint.ctype = joint_CONSTRAINT
+	constraint.b1_id = bid(joint.B1)
+	constraint.b2_id = bid(joint.B2)
+	constraint.joint_constraint.joint = joint
+}
+
+// joint_frame returns the world joint axes of a body.
+func joint_frame(b *Body, lx, ly *lin.V3) (x, y, z lin.V3) {
+	x.MultQ(lx, &b.world_rotation)
+	y.MultQ(ly, &b.world_rotation)
+	z.Cross(&x, &y)
+	return x, y, z
+}
+
+// joint_constraint_solve keeps the joined bodies within the joint
+// axis limits, first rotating and then moving the bodies.
+func joint_constraint_solve(constraint *constraint, h float64) {
+	if constraint.ctype != joint_CONSTRAINT {
+		slog.Error("joint_constraint_solve: expecting joint constraint")
+		return
+	}
+	const EPSILON float64 = 1e-50
+	jc := &constraint.joint_constraint
+	j := jc.joint
+	b1 := body_get_by_id(constraint.b1_id)
+	b2 := body_get_by_id(constraint.b2_id)
+
+	// swing: keep the X axes aligned, or within the cone angle.
+	x1, _, _ := joint_frame(b1, &j.x1, &j.y1)
+	x2, _, _ := joint_frame(b2, &j.x2, &j.y2)
+	delta_q, ok := lin.V3{}, false
+	switch j.Swing.Mode {
+	case JointLocked:
+		delta_q.Cross(&x1, &x2)
+		ok = true
+	case JointLimited:
+		n := lin.NewV3().Cross(&x1, &x2)
+		if n_len := n.Len(); n_len > EPSILON {
+			n.Scale(n, 1/n_len)
+			delta_q, ok = limit_angle(*n, x1, x2, 0, j.Swing.Upper)
+		}
+	}
+	if ok {
+		jc.lambda_swing = joint_rotate(b1, b2, h, j.Compliance, jc.lambda_swing, delta_q)
+	}
+
+	// twist: the angle between the Y axes around the X axis.
+	x1, y1, _ := joint_frame(b1, &j.x1, &j.y1)
+	x2, y2, _ := joint_frame(b2, &j.x2, &j.y2)
+	n := lin.NewV3().Add(&x1, &x2)
+	if n_len := n.Len(); n_len > EPSILON {
+		n.Scale(n, 1/n_len)
+		n1 := lin.NewV3().Sub(&y1, lin.NewV3().Scale(n, n.Dot(&y1))).Unit()
+		n2 := lin.NewV3().Sub(&y2, lin.NewV3().Scale(n, n.Dot(&y2))).Unit()
+		j.angle = math.Atan2(n.Dot(lin.NewV3().Cross(n1, n2)), n1.Dot(n2))
+		ok = false
+		switch j.Twist.Mode {
+		case JointLocked:
+			delta_q, ok = limit_angle(*n, *n1, *n2, 0, 0)
+		case JointLimited:
+			delta_q, ok = limit_angle(*n, *n1, *n2, j.Twist.Lower, j.Twist.Upper)
+		}
+		if ok {
+			jc.lambda_twist = joint_rotate(b1, b2, h, j.Compliance, jc.lambda_twist, delta_q)
+		}
+	}
+
+	// position: move body2 anchor back within the limits of each body1 axis.
+	x1, y1, z1 := joint_frame(b1, &j.x1, &j.y1)
+	var pcpd position_Constraint_Preprocessed_Data
+	calculate_positional_constraint_preprocessed_data(b1, b2, j.anchor1, j.anchor2, &pcpd)
+	p1 := lin.NewV3().Add(&b1.world_position, &pcpd.r1_wc)
+	p2 := lin.NewV3().Add(&b2.world_position, &pcpd.r2_wc)
+	d := lin.NewV3().Sub(p2, p1)
+	delta_x := lin.NewV3()
+	for i, axis := range []*lin.V3{&x1, &y1, &z1} {
+		s := d.Dot(axis)
+		target := s
+		switch j.Linear[i].Mode {
+		case JointLocked:
+			target = 0
+		case JointLimited:
+			target = lin.Clamp(s, j.Linear[i].Lower, j.Linear[i].Upper)
+		}
+		delta_x.Add(delta_x, lin.NewV3().Scale(axis, target-s)) // p1 - p2 when locked.
+	}
+	delta_lambda := positional_constraint_get_delta_lambda(&pcpd, h, j.Compliance, jc.lambda_pos, *delta_x)
+	positional_constraint_apply(&pcpd, delta_lambda, *delta_x)
+	jc.lambda_pos += delta_lambda
+}
+
+// joint_rotate applies an angular correction and returns the updated lambda.
+func joint_rotate(b1, b2 *Body, h, compliance, lambda float64, delta_q lin.V3) float64 {
+	var acpd angular_Constraint_Preprocessed_Data
+	calculate_angular_constraint_preprocessed_data(b1, b2, &acpd)
+	delta_lambda := angular_constraint_get_delta_lambda(&acpd, h, compliance, lambda, delta_q)
+	angular_constraint_apply(&acpd, delta_lambda, delta_q)
+	return lambda + delta_lambda
+}
+
+// joint_angular_motor turns body2 relative to body1 around the world
+// axis n at the motor speed, using at most the motor torque.
+func joint_angular_motor(b1, b2 *Body, n *lin.V3, motor *JointAxis, h float64) {
+	var acpd angular_Constraint_Preprocessed_Data
+	calculate_angular_constraint_preprocessed_data(b1, b2, &acpd)
+	w := n.Dot(lin.NewV3().MultMv(&acpd.b1_inverse_inertia_tensor, n)) +
+		n.Dot(lin.NewV3().MultMv(&acpd.b2_inverse_inertia_tensor, n))
+	if w <= 0 {
+		return
+	}
+	relative := lin.NewV3().Sub(&b2.angular_velocity, &b1.angular_velocity).Dot(n)
+	impulse := lin.Clamp((motor.MotorSpeed-relative)/w, -motor.MotorForce*h, motor.MotorForce*h)
+	p := lin.NewV3().Scale(n, impulse)
+	if !b1.fixed {
+		b1.angular_velocity.Sub(&b1.angular_velocity, lin.NewV3().MultMv(&acpd.b1_inverse_inertia_tensor, p))
+	}
+	if !b2.fixed {
+		b2.angular_velocity.Add(&b2.angular_velocity, lin.NewV3().MultMv(&acpd.b2_inverse_inertia_tensor, p))
+	}
+}
+
+// joint_motor_solve changes the body velocities so that body2 moves
+// relative to body1 at the motor speeds, using at most the motor force.
+func joint_motor_solve(constraint *constraint, h float64) {
+	j := constraint.joint_constraint.joint
+	b1 := body_get_by_id(constraint.b1_id)
+	b2 := body_get_by_id(constraint.b2_id)
+	x1, y1, z1 := joint_frame(b1, &j.x1, &j.y1)
+
+	// twist motor turns around the X axis.
+	if j.Twist.MotorForce > 0 && j.Twist.Mode != JointLocked {
+		joint_angular_motor(b1, b2, &x1, &j.Twist, h)
+	}
+
+	// swing motor swings the X axis around the Y axis.
+	if j.Swing.MotorForce > 0 && j.Swing.Mode != JointLocked {
+		joint_angular_motor(b1, b2, &y1, &j.Swing, h)
+	}
+
+	// linear motors move along the joint axes.
+	for i, n := range []*lin.V3{&x1, &y1, &z1} {
+		motor := &j.Linear[i]
+		if motor.MotorForce <= 0 || motor.Mode == JointLocked {
+			continue
+		}
+		var pcpd position_Constraint_Preprocessed_Data
+		calculate_positional_constraint_preprocessed_data(b1, b2, j.anchor1, j.anchor2, &pcpd)
+		w1 := b1.inverse_mass + lin.NewV3().Cross(&pcpd.r1_wc, n).Dot(
+			lin.NewV3().MultMv(&pcpd.b1_inverse_inertia_tensor, lin.NewV3().Cross(&pcpd.r1_wc, n)))
+		w2 := b2.inverse_mass + lin.NewV3().Cross(&pcpd.r2_wc, n).Dot(
+			lin.NewV3().MultMv(&pcpd.b2_inverse_inertia_tensor, lin.NewV3().Cross(&pcpd.r2_wc, n)))
+		if w1+w2 <= 0 {
+			continue
+		}
+		v1 := lin.NewV3().Add(&b1.linear_velocity, lin.NewV3().Cross(&b1.angular_velocity, &pcpd.r1_wc))
+		v2 := lin.NewV3().Add(&b2.linear_velocity, lin.NewV3().Cross(&b2.angular_velocity, &pcpd.r2_wc))
+		relative := lin.NewV3().Sub(v2, v1).Dot(n)
+		impulse := lin.Clamp((motor.MotorSpeed-relative)/(w1+w2), -motor.MotorForce*h, motor.MotorForce*h)
+		p := lin.NewV3().Scale(n, impulse)
+		if !b1.fixed {
+			b1.linear_velocity.Sub(&b1.linear_velocity, lin.NewV3().Scale(p, b1.inverse_mass))
+			b1.angular_velocity.Sub(&b1.angular_velocity,
+				lin.NewV3().MultMv(&pcpd.b1_inverse_inertia_tensor, lin.NewV3().Cross(&pcpd.r1_wc, p)))
+		}
+		if !b2.fixed {
+			b2.linear_velocity.Add(&b2.linear_velocity, lin.NewV3().Scale(p, b2.inverse_mass))
+			b2.angular_velocity.Add(&b2.angular_velocity,
+				lin.NewV3().MultMv(&pcpd.b2_inverse_inertia_tensor, lin.NewV3().Cross(&pcpd.r2_wc, p)))
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// mesh.go provides static triangle mesh colliders for level geometry
+// and props. It is not part of the original raw-physics port.
+// Each triangle is a flat convex hull so that the existing GJK, EPA,
+// and clipping code find the contacts. A bounding volume hierarchy
+// (BVH) limits the triangles tested to those near the other collider.
+
+import (
+	"cmp"
+	"log/slog"
+	"math"
+	"slices"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// NewMesh creates a static (unmovable) physics body from triangles
+// given as counter-clockwise indexes into the vertexes. The vertexes
+// are relative to the body center which is located at the origin.
+// Degenerate triangles are dropped. Returns nil if there are no triangles.
+func NewMesh(vertexes []lin.V3, indexes []uint32) *Body {
+	mesh := collider_mesh_create(vertexes, indexes)
+	if len(mesh.mesh.triangles) == 0 {
+		slog.Error("NewMesh needs 1 or more triangles")
+		return nil
+	}
+	colliders := []collider{mesh}
+
+	world_position := lin.NewV3()                  // app to call body.SetPosition
+	world_rotation := lin.NewQ().SetAa(0, 1, 0, 0) // app to call body.SetRotation
+	world_scale := lin.NewV3().SetS(1, 1, 1)       // app to call body.SetScale
+	mass := 1.0
+	static_friction_coefficient := 0.5
+	dynamic_friction_coefficient := 0.5
+	restitution_coefficient := 0.0
+	return body_create_ex(*world_position, *world_rotation, *world_scale, mass, colliders,
+		static_friction_coefficient, dynamic_friction_coefficient, restitution_coefficient, true)
+}
+
+// NewHeightfield creates a static (unmovable) physics body for terrain
+// from a grid of cols by rows heights given row by row. Grid points are
+// spacing apart with columns along the X axis and rows along the Z axis,
+// starting at the body center. The heightfield is a triangle mesh with
+// two triangles for each grid square. Returns nil if the grid is smaller
+// than 2x2 or does not match the number of heights.
+func NewHeightfield(heights []float64, cols, rows int, spacing float64) *Body {
+	if cols < 2 || rows < 2 || len(heights) != cols*rows || spacing <= 0 {
+		slog.Error("NewHeightfield invalid grid", "cols", cols, "rows", rows, "heights", len(heights))
+		return nil
+	}
+	vertexes := make([]lin.V3, 0, cols*rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			vertexes = append(vertexes, lin.V3{X: float64(c) * spacing, Y: heights[r*cols+c], Z: float64(r) * spacing})
+		}
+	}
+	indexes := make([]uint32, 0, (cols-1)*(rows-1)*6)
+	for r := uint32(0); r < uint32(rows-1); r++ {
+		for c := uint32(0); c < uint32(cols-1); c++ {
+			i, row := r*uint32(cols)+c, uint32(cols) // counter-clockwise facing +Y.
+			indexes = append(indexes, i, i+row, i+1, i+1, i+row, i+row+1)
+		}
+	}
+	return NewMesh(vertexes, indexes)
+}
+
+// collider_Mesh is a triangle mesh collider.
+type collider_Mesh struct {
+	triangles []collider // one convex hull collider for each triangle.
+	nodes     []bvh_Node // BVH with the root node first.
+	order     []uint32   // triangle indexes grouped by BVH leaf.
+	radius    float64    // bounding sphere radius.
+	position  lin.V3     // transform of the triangle world positions.
+	rotation  lin.Q      //   "
+	placed    bool       // true once the triangles have world positions.
+}
+
+// bvh_Node bounds a group of triangles. Children are always
+// after their parent in the node list.
+type bvh_Node struct {
+	min, max lin.V3 // world space bounds.
+	left     int32  // first child node, or -1 for a leaf.
+	right    int32  // second child node, or -1 for a leaf.
+	start    uint32 // leaf triangles in mesh.order.
+	count    uint32 //   "
+}
+
+// bvh_LEAF_SIZE is the most triangles in a BVH leaf.
+const bvh_LEAF_SIZE = 4
+
+// collider_mesh_create creates a mesh collider where each triangle
+// has front and back faces so that it collides from either side.
+func collider_mesh_create(vertexes []lin.V3, indexes []uint32) collider {
+	mesh := &collider_Mesh{}
+	for i := 0; i+2 < len(indexes); i += 3 {
+		v1, v2, v3 := vertexes[indexes[i]], vertexes[indexes[i+1]], vertexes[indexes[i+2]]
+		area := lin.NewV3().Cross(lin.NewV3().Sub(&v2, &v1), lin.NewV3().Sub(&v3, &v1)).Len()
+		if area < 1e-12 {
+			continue // degenerate triangle has no normal.
+		}
+		triangle := collider_convex_hull_create([]lin.V3{v1, v2, v3}, []uint32{0, 1, 2, 0, 2, 1})
+		mesh.triangles = append(mesh.triangles, triangle)
+		mesh.radius = max(mesh.radius, v1.Len(), v2.Len(), v3.Len())
+	}
+	mesh.order = make([]uint32, len(mesh.triangles))
+	for i := range mesh.order {
+		mesh.order[i] = uint32(i)
+	}
+	if len(mesh.triangles) > 0 {
+		mesh_bvh_build(mesh, 0, uint32(len(mesh.order)))
+	}
+	return collider{ctype: collider_TYPE_MESH, mesh: mesh}
+}
+
+// mesh_bvh_build adds the node for the triangles mesh.order[start:end]
+// by splitting them in half along the longest axis of their centers.
+func mesh_bvh_build(mesh *collider_Mesh, start, end uint32) int32 {
+	index := int32(len(mesh.nodes))
+	mesh.nodes = append(mesh.nodes, bvh_Node{left: -1, right: -1, start: start, count: end - start})
+	if end-start <= bvh_LEAF_SIZE {
+		return index
+	}
+	lo := lin.V3{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64}
+	hi := lin.V3{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64}
+	for _, t := range mesh.order[start:end] {
+		c := mesh_triangle_center(&mesh.triangles[t])
+		lo.Min(&lo, &c)
+		hi.Max(&hi, &c)
+	}
+	axis := 0
+	if hi.Y-lo.Y > hi.X-lo.X {
+		axis = 1
+	}
+	if hi.Z-lo.Z > max(hi.X-lo.X, hi.Y-lo.Y) {
+		axis = 2
+	}
+	slices.SortFunc(mesh.order[start:end], func(a, b uint32) int {
+		ca := mesh_triangle_center(&mesh.triangles[a])
+		cb := mesh_triangle_center(&mesh.triangles[b])
+		return cmp.Compare(hull_axis(&ca, axis), hull_axis(&cb, axis))
+	})
+	mid := start + (end-start)/2
+	left := mesh_bvh_build(mesh, start, mid)
+	right := mesh_bvh_build(mesh, mid, end)
+	mesh.nodes[index].left, mesh.nodes[index].right = left, right
+	return index
+}
+
+// mesh_triangle_center returns the average of the triangle vertexes.
+func mesh_triangle_center(triangle *collider) lin.V3 {
+	c := lin.V3{}
+	for i := range triangle.convex_hull.vertices {
+		c.Add(&c, &triangle.convex_hull.vertices[i])
+	}
+	return *c.Scale(&c, 1.0/3.0)
+}
+
+// mesh_update moves the triangles to their world positions and
+// refits the BVH bounds. Static meshes are only updated when
+// they are placed or moved by the app.
+func mesh_update(mesh *collider_Mesh, translation lin.V3, rotation *lin.Q) {
+	if mesh.placed && mesh.position == translation && mesh.rotation == *rotation {
+		return
+	}
+	mesh.placed, mesh.position, mesh.rotation = true, translation, *rotation
+	for i := range mesh.triangles {
+		collider_update(&mesh.triangles[i], translation, rotation)
+	}
+	for i := len(mesh.nodes) - 1; i >= 0; i-- { // children before parents.
+		n := &mesh.nodes[i]
+		if n.left < 0 {
+			n.min, n.max = collider_get_aabb(&mesh.triangles[mesh.order[n.start]])
+			for _, t := range mesh.order[n.start+1 : n.start+n.count] {
+				lo, hi := collider_get_aabb(&mesh.triangles[t])
+				n.min.Min(&n.min, &lo)
+				n.max.Max(&n.max, &hi)
+			}
+			continue
+		}
+		l, r := &mesh.nodes[n.left], &mesh.nodes[n.right]
+		n.min.Min(&l.min, &r.min)
+		n.max.Max(&l.max, &r.max)
+	}
+}
+
+// mesh_bvh_query appends the triangles whose bounds overlap lo:hi.
+func mesh_bvh_query(mesh *collider_Mesh, lo, hi lin.V3, triangles []uint32) []uint32 {
+	stack := []int32{0}
+	for len(stack) > 0 {
+		n := &mesh.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !aabb_overlaps(&n.min, &n.max, &lo, &hi) {
+			continue
+		}
+		if n.left < 0 {
+			for _, t := range mesh.order[n.start : n.start+n.count] {
+				tlo, thi := collider_get_aabb(&mesh.triangles[t])
+				if aabb_overlaps(&tlo, &thi, &lo, &hi) {
+					triangles = append(triangles, t)
+				}
+			}
+			continue
+		}
+		stack = append(stack, n.left, n.right)
+	}
+	return triangles
+}
+
+// aabb_overlaps returns true if the bounds lo1:hi1 and lo2:hi2 overlap.
+func aabb_overlaps(lo1, hi1, lo2, hi2 *lin.V3) bool {
+	return hi1.X >= lo2.X && lo1.X <= hi2.X &&
+		hi1.Y >= lo2.Y && lo1.Y <= hi2.Y &&
+		hi1.Z >= lo2.Z && lo1.Z <= hi2.Z
+}
+
+// collider_get_aabb returns the world space axis aligned bounds
+// of a sphere, capsule, or convex hull collider.
+func collider_get_aabb(collider *collider) (lo, hi lin.V3) {
+	if a, b, r, ok := collider_segment(collider); ok {
+		lo.Min(&a, &b)
+		hi.Max(&a, &b)
+		return lin.V3{X: lo.X - r, Y: lo.Y - r, Z: lo.Z - r}, lin.V3{X: hi.X + r, Y: hi.Y + r, Z: hi.Z + r}
+	}
+	vertices := collider.convex_hull.transformed_vertices
+	lo, hi = vertices[0], vertices[0]
+	for i := range vertices[1:] {
+		lo.Min(&lo, &vertices[i+1])
+		hi.Max(&hi, &vertices[i+1])
+	}
+	return lo, hi
+}
+
+// mesh_get_contacts returns the contacts between a mesh collider and
+// a sphere or convex hull collider using the triangles near the other
+// collider. The contact normals point from collider1 to collider2.
+func mesh_get_contacts(collider1, collider2 *collider, contacts []collider_Contact) []collider_Contact {
+	mesh, other, mesh_first := collider1.mesh, collider2, true
+	if collider2.ctype == collider_TYPE_MESH {
+		mesh, other, mesh_first = collider2.mesh, collider1, false
+	}
+	if other.ctype == collider_TYPE_MESH {
+		return contacts // meshes are static and don't collide.
+	}
+	lo, hi := collider_get_aabb(other)
+	for _, t := range mesh_bvh_query(mesh, lo, hi, nil) {
+		triangle := &mesh.triangles[t]
+		if mesh_first {
+			contacts = collider_get_contacts(triangle, other, contacts)
+		} else {
+			contacts = collider_get_contacts(other, triangle, contacts)
+		}
+	}
+	return contacts
+}
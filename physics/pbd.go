@@ -30,6 +30,7 @@ const (
 	mutual_ORIENTATION_CONSTRAINT
 	hinge_JOINT_CONSTRAINT
 	spherical_JOINT_CONSTRAINT
+	joint_CONSTRAINT // see joint.go
 )
 
 // positional_Constraint;
@@ -106,6 +107,7 @@ type constraint struct {
 	mutual_orientation_constraint mutual_Orientation_Constraint
 	hinge_joint_constraint        hinge_Joint_Constraint
 	spherical_joint_constraint    spherical_Joint_Constraint
+	joint_constraint              joint_Constraint
 }
 
 // #define LINEAR_SLEEPING_THRESHOLD 0.10
@@ -521,6 +523,9 @@ func solve_constraint(constraint *constraint, h float64) {
 	case spherical_JOINT_CONSTRAINT:
 		// spherical_joint_constraint_solve(constraint, h)
 		return
+	case joint_CONSTRAINT:
+		joint_constraint_solve(constraint, h)
+		return
 	default:
 		slog.Error("solve_constraint: unsupported constraint", "constraint_type", constraint.ctype)
 	}
@@ -572,6 +577,10 @@ func copy_constraints(constraints []constraint) []constraint {
 			constraint.spherical_joint_constraint.lambda_pos = 0.0
 			constraint.spherical_joint_constraint.lambda_swing = 0.0
 			constraint.spherical_joint_constraint.lambda_twist = 0.0
+		case joint_CONSTRAINT:
+			constraint.joint_constraint.lambda_pos = 0.0
+			constraint.joint_constraint.lambda_swing = 0.0
+			constraint.joint_constraint.lambda_twist = 0.0
 		}
 	}
 	return copied_constraints
@@ -590,7 +599,13 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 	}
 	h := dt / float64(num_substeps)
 
-	broad_collision_pairs := broad_get_collision_pairs(bodies)
+	broad_collision_pairs, trigger_pairs := contact_split_triggers(bodies, broad_get_collision_pairs(bodies))
+	joined := map[broad_Collision_Pair]bool{} // joined bodies don't collide.
+	for i := range external_constraints {
+		if c := &external_constraints[i]; c.ctype == joint_CONSTRAINT {
+			joined[broad_Collision_Pair{min(c.b1_id, c.b2_id), max(c.b1_id, c.b2_id)}] = true
+		}
+	}
 	simulation_islands := broad_collect_simulation_islands(bodies, broad_collision_pairs, external_constraints)
 
 	// All entities will be contained in the simulation islands.
@@ -664,6 +679,9 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 			b.world_rotation.W = b.world_rotation.W + h*0.5*q.W
 			// should we normalize?
 			b.world_rotation.Unit()
+			if b.planar {
+				b.constrain_planar(b.previous_world_position.Z)
+			}
 		}
 
 		// Create the constraints array
@@ -674,6 +692,9 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 			for j := 0; j < len(broad_collision_pairs); j++ {
 				bid1 := broad_collision_pairs[j].b1_id
 				bid2 := broad_collision_pairs[j].b2_id
+				if joined[broad_collision_pairs[j]] {
+					continue
+				}
 				b1 := body_get_by_id(bid1)
 				b2 := body_get_by_id(bid2)
 
@@ -687,12 +708,14 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 
 				// No need to solve the collision if both entities are either inactive or fixed
 				if (b1.fixed || !b1.active) && (b2.fixed || !b2.active) {
+					contact_keep(b1, b2, bid1, bid2)
 					continue
 				}
 
 				colliders_update(b1.colliders, b1.world_position, &b1.world_rotation)
 				colliders_update(b2.colliders, b2.world_position, &b2.world_rotation)
 				contacts := colliders_get_contacts(b1.colliders, b2.colliders)
+				contact_record(bid1, bid2, contacts, false)
 				for l := 0; l < len(contacts); l++ {
 					contact := &contacts[l]
 					var constraint constraint
@@ -709,6 +732,7 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 				solve_constraint(constraint, h)
 			}
 		}
+		pbd_constrain_planar(bodies)
 
 		// The PBD velocity update
 		for j := 0; j < len(bodies); j++ {
@@ -816,7 +840,22 @@ func pbd_simulate_with_constraints(dt float64, bodies []Body, external_constrain
 				}
 			} else if constraint.ctype == hinge_JOINT_CONSTRAINT {
 				// TODO: Joint damping
+			} else if constraint.ctype == joint_CONSTRAINT {
+				joint_motor_solve(constraint, h)
 			}
 		}
+		pbd_constrain_planar(bodies)
+	}
+	contacts_collect(bodies, trigger_pairs)
+}
+
+// pbd_constrain_planar keeps the 2D bodies in their plane after the
+// solvers. Not part of the original raw-physics code.
+func pbd_constrain_planar(bodies []Body) {
+	for j := 0; j < len(bodies); j++ {
+		b := &bodies[j]
+		if b.planar && !b.fixed && b.active {
+			b.constrain_planar(b.previous_world_position.Z)
+		}
 	}
 }
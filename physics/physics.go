@@ -23,8 +23,21 @@ package physics
 //	 pbd_base_constraints.go : pbd_base_constraints.cpp pbd_base_constraints.h
 //	 physics_util.go         : physics_util.cpp physics_util.h
 //	 support.go              : support.cpp support.h
+//	 hull.go                 : convex hull from points, not ported.
+//	 mesh.go                 : triangle mesh collider, not ported.
+//	 capsule.go              : capsule and cylinder shapes, not ported.
+//	 character.go            : kinematic character controller, not ported.
+//	 joint.go                : hinge, ball, slider, fixed, and 6-DOF joints, not ported.
+//	 tree.go                 : dynamic AABB tree broad phase and queries, not ported.
+//	 contact.go              : collision layers, triggers, and contact reports, not ported.
+//	 snapshot.go             : save and restore simulation state, not ported.
+//	 cloth.go                : position based dynamics cloth, not ported.
+//	 vehicle.go              : raycast vehicle, not ported.
 
 import (
+	"log/slog"
+	"math"
+
 	"github.com/gazed/vu/math/lin"
 )
 
@@ -33,10 +46,16 @@ import (
 // the bodies positions and orientations will be updated based on forces
 // acting upon them and/or collision results. Fixed, or unmoved bodies,
 // or bodies with zero mass are not updated.
-func Simulate(bods []Body, timestep float64) {
+//
+// Joints connect pairs of the bodies, see NewJoint. The simulation takes
+// smaller steps when there are joints so that jointed bodies stay together.
+func Simulate(bods []Body, timestep float64, joints ...*Joint) {
 	bodies = bods
 	for i := range bodies {
-		b := bodies[i]
+		b := &bodies[i]
+		if b.planar {
+			b.constrain_planar(b.world_position.Z)
+		}
 		colliders_update(b.colliders, b.world_position, &b.world_rotation)
 	}
 	const GRAVITY float64 = 10.0
@@ -49,7 +68,21 @@ func Simulate(bods []Body, timestep float64) {
 		force := lin.NewV3().SetS(0.0, -GRAVITY*1.0/bod.inverse_mass, 0.0)
 		bod.AddForce(*position, *force, false)
 	}
-	pbd_simulate(timestep, bodies, 1, 1, true)
+	if len(joints) == 0 {
+		pbd_simulate(timestep, bodies, 1, 1, true)
+	} else {
+		constraints := make([]constraint, 0, len(joints))
+		for _, j := range joints {
+			if j == nil || j.B1 < 0 || j.B2 < 0 || j.B1 >= len(bodies) || j.B2 >= len(bodies) || j.B1 == j.B2 {
+				slog.Error("Simulate: invalid joint bodies", "joint", j)
+				continue
+			}
+			var c constraint
+			pbd_joint_constraint_init(&c, j)
+			constraints = append(constraints, c)
+		}
+		pbd_simulate_with_constraints(timestep, bodies, constraints, joint_SUBSTEPS, 1, true)
+	}
 	for i := range bodies {
 		bod := &bodies[i]
 		bod.clear_forces()
@@ -112,8 +145,72 @@ func NewBox(hx, hy, hz float64, static bool) *Body {
 		4, 0, 1, // front
 	}
 
-	boxCollider := collider_convex_hull_create(vertexes, indexes)
-	colliders := []collider{boxCollider}
+	return hull_body_create(vertexes, indexes, static)
+}
+
+// NewCircle creates a disc shaped 2D physics body located at the origin.
+// The circle size is defined by the radius.
+// The circle can be static (unmovable) or kinematic (moveable).
+func NewCircle(radius float64, static bool) *Body {
+	body := NewSphere(radius, static)
+	body.planar = true
+	return body
+}
+
+// NewRect creates a rectangle shaped 2D physics body located at the origin.
+// The rectangle size is given by the half-extents so that actual size
+// is w=2*hx, h=2*hy. The Z depth matches the smaller side.
+// The rectangle can be static (unmovable) or kinematic (moveable).
+func NewRect(hx, hy float64, static bool) *Body {
+	body := NewBox(hx, hy, min(hx, hy), static)
+	body.planar = true
+	return body
+}
+
+// NewPolygon creates a convex polygon shaped 2D physics body located at
+// the origin. The polygon is given as counter-clockwise x,y point pairs
+// centered on the origin. The Z depth matches the smaller side of the
+// polygon bounds. Returns nil if there are less than 3 points.
+// The polygon can be static (unmovable) or kinematic (moveable).
+func NewPolygon(xy []float64, static bool) *Body {
+	n := len(xy) / 2
+	if n < 3 {
+		slog.Error("NewPolygon needs 3 or more points", "points", n)
+		return nil
+	}
+	hx, hy := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		hx, hy = max(hx, math.Abs(xy[i*2])), max(hy, math.Abs(xy[i*2+1]))
+	}
+	hz := min(hx, hy)
+
+	// extrude the polygon into a prism with front vertexes 0:n
+	// and back vertexes n:2n.
+	vertexes := make([]lin.V3, n*2)
+	for i := 0; i < n; i++ {
+		vertexes[i] = lin.V3{X: xy[i*2], Y: xy[i*2+1], Z: +hz}
+		vertexes[n+i] = lin.V3{X: xy[i*2], Y: xy[i*2+1], Z: -hz}
+	}
+	indexes := []uint32{}
+	for i := uint32(1); i < uint32(n-1); i++ {
+		f, b := uint32(0), uint32(n)
+		indexes = append(indexes, f, f+i, f+i+1) // front
+		indexes = append(indexes, b, b+i+1, b+i) // back
+	}
+	for i := uint32(0); i < uint32(n); i++ {
+		j := (i + 1) % uint32(n)
+		indexes = append(indexes, i, uint32(n)+i, uint32(n)+j) // side
+		indexes = append(indexes, i, uint32(n)+j, j)           // side
+	}
+	body := hull_body_create(vertexes, indexes, static)
+	body.planar = true
+	return body
+}
+
+// hull_body_create creates a physics body from a convex hull.
+func hull_body_create(vertexes []lin.V3, indexes []uint32, static bool) *Body {
+	hullCollider := collider_convex_hull_create(vertexes, indexes)
+	colliders := []collider{hullCollider}
 
 	world_position := lin.NewV3()                  // app to call body.SetPosition
 	world_rotation := lin.NewQ().SetAa(0, 1, 0, 0) // app to call body.SetRotation
@@ -126,9 +223,6 @@ func NewBox(hx, hy, hz float64, static bool) *Body {
 		static_friction_coefficient, dynamic_friction_coefficient, restitution_coefficient, static)
 }
 
-// TODO convex hull for a give set of vertexes and indexes.
-// func NewConvexHull(...) *Body {}
-
 // v2Int is a 2 element integer vector.
 type v2Int struct {
 	x uint32
@@ -4,6 +4,8 @@ package physics
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"testing"
 
 	"github.com/gazed/vu/math/lin"
@@ -19,6 +21,790 @@ func TestConvexHull(t *testing.T) {
 	if c.ctype != collider_TYPE_CONVEX_HULL {
 		t.Fatal("expecting convex hull collider")
 	}
+
+	t.Run("points", func(t *testing.T) {
+		points := []lin.V3{}
+		for _, x := range []float64{-1, 0, 1} { // cube corners, edges, and center.
+			for _, y := range []float64{-1, 0, 1} {
+				for _, z := range []float64{-1, 0, 1} {
+					points = append(points, lin.V3{X: x, Y: y, Z: z})
+				}
+			}
+		}
+		vertexes, indexes := ConvexHull(points)
+		if len(vertexes) != 8 || len(indexes) != 12*3 {
+			t.Fatalf("expected cube hull got %d vertexes %d triangles", len(vertexes), len(indexes)/3)
+		}
+		b := NewConvexHull(points, false)
+		if hx, hy, hz, ok := b.Box(); !ok || hx != 1 || hy != 1 || hz != 1 {
+			t.Errorf("expected box got %f %f %f", hx, hy, hz)
+		}
+		if faces := len(b.colliders[0].convex_hull.faces); faces != 6 {
+			t.Errorf("expected 6 faces got %d", faces)
+		}
+	})
+	t.Run("flat", func(t *testing.T) {
+		square := []lin.V3{{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 1, Y: 1, Z: 0}, {X: 0, Y: 1, Z: 0}}
+		if NewConvexHull(square, false) != nil {
+			t.Error("expected nil for flat points")
+		}
+	})
+}
+
+// go test -run Mesh
+func TestMesh(t *testing.T) {
+	// grid returns a flat size x size grid of 1x1 squares at y=0.
+	grid := func(size int) (vertexes []lin.V3, indexes []uint32) {
+		for z := 0; z <= size; z++ {
+			for x := 0; x <= size; x++ {
+				vertexes = append(vertexes, lin.V3{X: float64(x - size/2), Y: 0, Z: float64(z - size/2)})
+			}
+		}
+		row := uint32(size + 1)
+		for z := uint32(0); z < uint32(size); z++ {
+			for x := uint32(0); x < uint32(size); x++ {
+				i := z*row + x
+				indexes = append(indexes, i, i+row, i+1, i+1, i+row, i+row+1)
+			}
+		}
+		return vertexes, indexes
+	}
+
+	t.Run("bvh", func(t *testing.T) {
+		ground := NewMesh(grid(20))
+		mesh := ground.colliders[0].mesh
+		if len(mesh.triangles) != 800 {
+			t.Fatalf("expected 800 triangles got %d", len(mesh.triangles))
+		}
+		mesh_update(mesh, lin.V3{X: 0, Y: -1, Z: 0}, lin.NewQI())
+		found := mesh_bvh_query(mesh, lin.V3{X: 0.1, Y: -1.5, Z: 0.1}, lin.V3{X: 0.9, Y: -0.5, Z: 0.9}, nil)
+		if len(found) != 2 {
+			t.Errorf("expected the 2 triangles under the box got %d", len(found))
+		}
+		if found = mesh_bvh_query(mesh, lin.V3{X: 0, Y: 1, Z: 0}, lin.V3{X: 1, Y: 2, Z: 1}, nil); len(found) != 0 {
+			t.Errorf("expected no triangles above the ground got %d", len(found))
+		}
+	})
+	t.Run("collide", func(t *testing.T) {
+		ground := NewMesh(grid(20))
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: 0.3, Y: 2, Z: 0.2})
+		box := NewBox(0.5, 0.5, 0.5, false)
+		box.SetPosition(lin.V3{X: 3.5, Y: 2, Z: 3.5})
+		bods := []Body{*ground, *ball, *box}
+		for i := 0; i < 120; i++ {
+			Simulate(bods, 1.0/60.0)
+		}
+		if y := bods[1].world_position.Y; y < 0.4 || y > 0.6 {
+			t.Errorf("expected ball resting on mesh got %f", y)
+		}
+		if y := bods[2].world_position.Y; y < 0.4 || y > 0.6 {
+			t.Errorf("expected box resting on mesh got %f", y)
+		}
+	})
+	t.Run("heightfield", func(t *testing.T) {
+		heights := make([]float64, 11*11)
+		for i := range heights {
+			heights[i] = 1 // flat at y=1.
+		}
+		ground := NewHeightfield(heights, 11, 11, 1)
+		if ground == nil || len(ground.colliders[0].mesh.triangles) != 200 {
+			t.Fatalf("expected heightfield triangles")
+		}
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: 5.3, Y: 3, Z: 4.2})
+		bods := []Body{*ground, *ball}
+		for i := 0; i < 120; i++ {
+			Simulate(bods, 1.0/60.0)
+		}
+		if y := bods[1].world_position.Y; y < 1.4 || y > 1.6 {
+			t.Errorf("expected ball resting on heightfield got %f", y)
+		}
+		if NewHeightfield(heights, 10, 11, 1) != nil {
+			t.Errorf("expected mismatched grid to fail")
+		}
+	})
+}
+
+// go test -run Capsule
+func TestCapsule(t *testing.T) {
+	t.Run("segments", func(t *testing.T) {
+		c1, c2 := closest_points_segment_segment(
+			lin.V3{X: -1, Y: 0, Z: 0}, lin.V3{X: 1, Y: 0, Z: 0},
+			lin.V3{X: 0, Y: 1, Z: -1}, lin.V3{X: 0, Y: 1, Z: 1})
+		if c1 != (lin.V3{}) || c2 != (lin.V3{Y: 1}) {
+			t.Errorf("expected crossing segments got %v %v", c1, c2)
+		}
+	})
+	t.Run("contacts", func(t *testing.T) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		colliders_update(ground.colliders, ground.world_position, &ground.world_rotation)
+		capsule := NewCapsule(0.5, 1, false)
+		for _, tc := range []struct {
+			angle, y float64
+			contacts int
+		}{{0, 1.4, 1}, {90, 0.4, 2}} { // standing and lying 0.1 into the ground.
+			capsule.SetPosition(lin.V3{X: 0, Y: tc.y, Z: 0})
+			capsule.SetRotation(*lin.NewQ().SetAa(0, 0, 1, lin.Rad(tc.angle)))
+			colliders_update(capsule.colliders, capsule.world_position, &capsule.world_rotation)
+			contacts := colliders_get_contacts(ground.colliders, capsule.colliders)
+			if len(contacts) != tc.contacts {
+				t.Fatalf("expected %d contacts got %d", tc.contacts, len(contacts))
+			}
+			for _, c := range contacts {
+				depth := lin.NewV3().Sub(&c.collision_point1, &c.collision_point2).Dot(&c.normal)
+				if !lin.Aeq(depth, 0.1) || !lin.Aeq(c.normal.Y, 1) {
+					t.Errorf("expected 0.1 penetration up got %f %v", depth, c.normal)
+				}
+			}
+		}
+	})
+	t.Run("rest", func(t *testing.T) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		lying := NewCapsule(0.5, 1, false)
+		lying.SetPosition(lin.V3{X: 3, Y: 3, Z: 0})
+		lying.SetRotation(*lin.NewQ().SetAa(0, 0, 1, lin.Rad(90)))
+		cylinder := NewCylinder(0.5, 1, false)
+		cylinder.SetPosition(lin.V3{X: 0, Y: 3, Z: 3})
+		bods := []Body{*ground, *lying, *cylinder}
+		for i := 0; i < 180; i++ {
+			Simulate(bods, 1.0/60.0)
+		}
+		if y := bods[1].world_position.Y; y < 0.4 || y > 0.6 {
+			t.Errorf("expected lying capsule on ground got %f", y)
+		}
+		if y := bods[2].world_position.Y; y < 0.9 || y > 1.1 {
+			t.Errorf("expected cylinder on ground got %f", y)
+		}
+		if r, hy, ok := bods[1].Capsule(); !ok || r != 0.5 || hy != 1 {
+			t.Errorf("expected capsule got %f %f", r, hy)
+		}
+	})
+}
+
+// go test -run Character
+func TestCharacter(t *testing.T) {
+	// world returns a character standing on the ground next to the blocks.
+	world := func(blocks ...*Body) (*Character, []Body) {
+		c, body := NewCharacter(0.5, 0.5)
+		body.SetPosition(lin.V3{X: 0, Y: 1, Z: 0})
+		ground := NewBox(20, 1, 20, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		bods := []Body{*body, *ground}
+		for _, b := range blocks {
+			bods = append(bods, *b)
+		}
+		c.Move(&bods[0], bods, lin.V3{Y: -0.1})
+		return c, bods
+	}
+	walk := func(c *Character, bods []Body, steps int, motion lin.V3) *lin.V3 {
+		for i := 0; i < steps; i++ {
+			c.Move(&bods[0], bods, motion)
+		}
+		return &bods[0].world_position
+	}
+
+	t.Run("ground", func(t *testing.T) {
+		c, bods := world()
+		if !c.Grounded() || bods[0].world_position.Y < 0.99 || bods[0].world_position.Y > 1.01 {
+			t.Errorf("expected grounded character got %v", bods[0].world_position)
+		}
+	})
+	t.Run("slide", func(t *testing.T) {
+		wall := NewBox(0.5, 2, 5, true)
+		wall.SetPosition(lin.V3{X: 2, Y: 2, Z: 0})
+		c, bods := world(wall)
+		p := walk(c, bods, 40, lin.V3{X: 0.1, Y: -0.1, Z: 0.05})
+		if p.X < 0.95 || p.X > 1.01 || p.Z < 1.9 {
+			t.Errorf("expected slide along wall got %v", p)
+		}
+	})
+	t.Run("step", func(t *testing.T) {
+		ledge := NewBox(2, 0.1, 2, true)
+		ledge.SetPosition(lin.V3{X: 3, Y: 0.1, Z: 0})
+		c, bods := world(ledge)
+		p := walk(c, bods, 30, lin.V3{X: 0.1, Y: -0.1})
+		if p.X < 2.9 || p.Y < 1.19 || p.Y > 1.21 || !c.Grounded() {
+			t.Errorf("expected step onto ledge got %v", p)
+		}
+
+		wall := NewBox(2, 1, 2, true) // too high to step over.
+		wall.SetPosition(lin.V3{X: 3, Y: 1, Z: 0})
+		c, bods = world(wall)
+		if p = walk(c, bods, 30, lin.V3{X: 0.1, Y: -0.1}); p.X > 0.51 {
+			t.Errorf("expected wall to block got %v", p)
+		}
+	})
+	t.Run("slopes", func(t *testing.T) {
+		ramp := func(angle float64) *Body {
+			b := NewBox(5, 0.5, 5, true)
+			b.SetRotation(*lin.NewQ().SetAa(0, 0, 1, lin.Rad(angle)))
+			b.SetPosition(lin.V3{X: 5, Y: 0, Z: 0})
+			return b
+		}
+		c, bods := world(ramp(20))
+		if p := walk(c, bods, 60, lin.V3{X: 0.1, Y: -0.1}); p.Y < 1.5 || !c.Grounded() {
+			t.Errorf("expected walk up gentle slope got %v", p)
+		}
+		c, bods = world(ramp(60))
+		if p := walk(c, bods, 60, lin.V3{X: 0.1, Y: -0.1}); p.Y > 1.6 {
+			t.Errorf("expected steep slope to block got %v", p)
+		}
+	})
+	t.Run("snap", func(t *testing.T) {
+		c, bods := world()
+		p := walk(c, bods, 5, lin.V3{X: 0.1})
+		if !c.Grounded() {
+			t.Errorf("expected snap to ground got %v", p)
+		}
+		bods[0].world_position.Y = 3 // too far above the ground to snap.
+		if c.Move(&bods[0], bods, lin.V3{X: 0.1}); c.Grounded() || bods[0].world_position.Y != 3 {
+			t.Errorf("expected falling character got %v", bods[0].world_position)
+		}
+	})
+}
+
+// go test -run Joint
+func TestJoint(t *testing.T) {
+	// world returns a static anchor at the origin and a box at x.
+	world := func(x float64) []Body {
+		anchor := NewBox(0.1, 0.1, 0.1, true)
+		box := NewBox(0.5, 0.5, 0.5, false)
+		box.SetPosition(lin.V3{X: x, Y: 0, Z: 0})
+		return []Body{*anchor, *box}
+	}
+	run := func(bods []Body, steps int, joints ...*Joint) {
+		for i := 0; i < steps; i++ {
+			Simulate(bods, 1.0/60.0, joints...)
+		}
+	}
+
+	t.Run("fixed", func(t *testing.T) {
+		bods := world(1)
+		j := NewJoint(FixedJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1})
+		run(bods, 60, j)
+		if p := bods[1].world_position; !lin.Aeq(p.X, 1) || math.Abs(p.Y) > 0.01 {
+			t.Errorf("expected fixed box got %v", p)
+		}
+	})
+	t.Run("ball", func(t *testing.T) {
+		bods := world(2)
+		j := NewJoint(BallJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1})
+		run(bods, 30, j)
+		p := bods[1].world_position
+		if d := p.Len(); d < 1.98 || d > 2.02 || p.Y > -0.5 {
+			t.Errorf("expected pendulum swing got %v %f", p, d)
+		}
+	})
+	t.Run("hinge", func(t *testing.T) {
+		bods := world(1)
+		j := NewJoint(HingeJoint, bods, 0, 1, lin.V3{}, lin.V3{Y: 1}).SetLimits(-math.Pi/4, math.Pi/4)
+		bods[1].Push(0, 0, 3)
+		least := 0.0
+		for i := 0; i < 60; i++ {
+			run(bods, 1, j)
+			least = min(least, j.Angle())
+		}
+		p := bods[1].world_position
+		if math.Abs(p.Y) > 0.01 || math.Abs(math.Hypot(p.X, p.Z)-1) > 0.01 {
+			t.Errorf("expected door to swing around the hinge got %v", p)
+		}
+		if least < -math.Pi/4-0.02 || least > -math.Pi/4+0.05 {
+			t.Errorf("expected door to stop at its limit got %f", least)
+		}
+	})
+	t.Run("motor", func(t *testing.T) {
+		bods := world(0)
+		j := NewJoint(HingeJoint, bods, 0, 1, lin.V3{}, lin.V3{Z: 1}).SetMotor(2, 100)
+		run(bods, 30, j)
+		if w := bods[1].angular_velocity; !lin.Aeq(w.Z, 2) || math.Abs(bods[1].world_position.Len()) > 0.01 {
+			t.Errorf("expected wheel turning at motor speed got %v", w)
+		}
+	})
+	t.Run("swing motor", func(t *testing.T) {
+		bods := world(0)
+		j := NewJoint(BallJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1}).SetMotor(2, 100)
+		run(bods, 30, j)
+		if w := bods[1].angular_velocity; !lin.Aeq(w.Len(), 2) || math.Abs(w.X) > 0.01 {
+			t.Errorf("expected ball swinging at motor speed got %v", w)
+		}
+	})
+	t.Run("slider", func(t *testing.T) {
+		bods := world(1)
+		j := NewJoint(SliderJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1}).SetLimits(-1, 1)
+		bods[1].Push(5, 0, 0)
+		run(bods, 30, j)
+		if p := bods[1].world_position; math.Abs(p.X-2) > 0.01 || math.Abs(p.Y) > 0.01 {
+			t.Errorf("expected slide to the limit got %v", p)
+		}
+		j.SetMotor(-1, 100)
+		run(bods, 30, j)
+		if p := bods[1].world_position; p.X > 1.6 || p.X < 1.4 {
+			t.Errorf("expected motor to slide back got %v", p)
+		}
+	})
+	t.Run("generic", func(t *testing.T) {
+		bods := world(1)
+		j := NewJoint(GenericJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1})
+		j.Linear[1] = JointAxis{Mode: JointLimited, Lower: -0.5, Upper: 0}
+		run(bods, 60, j)
+		if p := bods[1].world_position; !lin.Aeq(p.X, 1) || p.Y < -0.51 || p.Y > -0.49 {
+			t.Errorf("expected box to drop to its limit got %v", p)
+		}
+	})
+	t.Run("no collide", func(t *testing.T) {
+		bods := world(0.2) // overlapping.
+		j := NewJoint(FixedJoint, bods, 0, 1, lin.V3{}, lin.V3{X: 1})
+		run(bods, 10, j)
+		if p := bods[1].world_position; !lin.Aeq(p.X, 0.2) {
+			t.Errorf("expected joined bodies to overlap got %v", p)
+		}
+	})
+}
+
+// go test -run Broad
+func TestBroadphase(t *testing.T) {
+	// brute returns the pairs found by checking every pair of bodies.
+	brute := func(bods []Body) (pairs []broad_Collision_Pair) {
+		for i := range bods {
+			for j := i + 1; j < len(bods); j++ {
+				d := bods[i].world_position.Dist(&bods[j].world_position)
+				if d <= bods[i].bounding_sphere_radius+bods[j].bounding_sphere_radius+2*broad_MARGIN {
+					pairs = append(pairs, broad_Collision_Pair{bid(i), bid(j)})
+				}
+			}
+		}
+		return pairs
+	}
+
+	// go test -run Broad/pairs
+	t.Run("pairs", func(t *testing.T) {
+		bods := make([]Body, 600)
+		for i := range bods {
+			bods[i] = *NewSphere(0.5+0.5*math.Abs(math.Sin(float64(i))), false)
+			f := float64(i)
+			bods[i].SetPosition(lin.V3{X: 40 * math.Sin(f*1.3), Y: 40 * math.Sin(f*2.7), Z: 40 * math.Sin(f*5.1)})
+		}
+		for step := 0; step < 3; step++ {
+			if got, want := broad_get_collision_pairs(bods), brute(bods); !slices.Equal(got, want) {
+				t.Fatalf("step %d: expected %d pairs got %d", step, len(want), len(got))
+			}
+			for i := range bods[:len(bods)/2] {
+				p := bods[i].world_position
+				bods[i].SetPosition(lin.V3{X: p.X + 0.5, Y: p.Y - 0.3, Z: p.Z}) // move half the bodies.
+			}
+			bods = append(bods[:100], bods[200:]...) // remove some bodies.
+		}
+		if h := broad_tree.nodes[broad_tree.root].height; h > 30 {
+			t.Errorf("expected a balanced tree got height %d", h)
+		}
+	})
+
+	// go test -run Broad/ray
+	t.Run("ray", func(t *testing.T) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		ball := NewSphere(1, false)
+		ball.SetPosition(lin.V3{X: 0, Y: 3, Z: 0})
+		pill := NewCapsule(0.5, 1, false)
+		pill.SetPosition(lin.V3{X: 5, Y: 2, Z: 0})
+		ramp := NewMesh([]lin.V3{{X: -1, Y: 0, Z: -1}, {X: -1, Y: 0, Z: 1}, {X: 1, Y: 2, Z: 0}}, []uint32{0, 1, 2})
+		ramp.SetPosition(lin.V3{X: -5, Y: 0, Z: 0})
+		bods := []Body{*ground, *ball, *pill, *ramp}
+		down := lin.V3{X: 0, Y: -1, Z: 0}
+		cases := []struct {
+			origin lin.V3
+			dir    lin.V3
+			body   int
+			dist   float64
+			normal lin.V3
+		}{
+			{lin.V3{X: 0, Y: 10, Z: 0}, down, 1, 6, lin.V3{X: 0, Y: 1, Z: 0}},     // ball top.
+			{lin.V3{X: 3, Y: 10, Z: 0}, down, 0, 10, lin.V3{X: 0, Y: 1, Z: 0}},    // ground.
+			{lin.V3{X: 5, Y: 10, Z: 0}, down, 2, 6.5, lin.V3{X: 0, Y: 1, Z: 0}},   // capsule end.
+			{lin.V3{X: 0, Y: 2, Z: 0}, lin.V3{X: 1}, 2, 4.5, lin.V3{X: -1}},       // capsule side from inside the ball.
+			{lin.V3{X: -5, Y: 10, Z: 0}, down, 3, 9, lin.V3{X: -0.707, Y: 0.707}}, // mesh ramp.
+		}
+		for i, c := range cases {
+			hit, ok := Raycast(bods, c.origin, c.dir, 100)
+			if !ok || hit.Body != c.body || !lin.Aeq(hit.Distance, c.dist) {
+				t.Errorf("ray %d: expected body %d at %f got %t %d %f", i, c.body, c.dist, ok, hit.Body, hit.Distance)
+				continue
+			}
+			if math.Abs(hit.Normal.X-c.normal.X) > 0.001 || math.Abs(hit.Normal.Y-c.normal.Y) > 0.001 {
+				t.Errorf("ray %d: expected normal %v got %v", i, c.normal, hit.Normal)
+			}
+		}
+		if _, ok := Raycast(bods, lin.V3{X: 0, Y: 10, Z: 0}, lin.V3{X: 0, Y: 1, Z: 0}, 100); ok {
+			t.Error("expected ray pointing away to miss")
+		}
+		if _, ok := Raycast(bods, lin.V3{X: 0, Y: 10, Z: 0}, down, 5); ok {
+			t.Error("expected short ray to miss")
+		}
+	})
+
+	// go test -run Broad/query
+	t.Run("query", func(t *testing.T) {
+		bods := make([]Body, 10)
+		for i := range bods {
+			bods[i] = *NewBox(0.5, 0.5, 0.5, false)
+			bods[i].SetPosition(lin.V3{X: float64(i) * 2, Y: 0, Z: 0})
+		}
+		found := QueryBox(bods, lin.V3{X: 3, Y: -1, Z: -1}, lin.V3{X: 7, Y: 1, Z: 1}, nil)
+		if !slices.Equal(found, []int{2, 3}) {
+			t.Errorf("expected boxes 2 and 3 got %v", found)
+		}
+		found = QuerySphere(bods, lin.V3{X: 9, Y: 0.9, Z: 0.9}, 0.5, found[:0])
+		if len(found) != 0 {
+			t.Errorf("expected sphere near the corner to miss got %v", found)
+		}
+		found = QuerySphere(bods, lin.V3{X: 9, Y: 0, Z: 0}, 0.6, found[:0])
+		if !slices.Equal(found, []int{4, 5}) {
+			t.Errorf("expected boxes 4 and 5 got %v", found)
+		}
+	})
+}
+
+// go test -run Contact
+func TestContact(t *testing.T) {
+	// go test -run Contact/layers
+	t.Run("layers", func(t *testing.T) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		ghost := NewSphere(0.5, false)
+		ghost.SetPosition(lin.V3{X: -2, Y: 1, Z: 0})
+		ghost.SetLayer(2, 2) // only collides with layer 2.
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: 2, Y: 1, Z: 0})
+		bods := []Body{*ground, *ghost, *ball}
+		for i := 0; i < 60; i++ {
+			Simulate(bods, 1.0/60.0)
+		}
+		if y := bods[1].world_position.Y; y > -1 {
+			t.Errorf("expected ghost to fall through the ground got %f", y)
+		}
+		if y := bods[2].world_position.Y; y < 0.4 || y > 0.6 {
+			t.Errorf("expected ball resting on the ground got %f", y)
+		}
+		if layer, mask := bods[2].Layer(); layer != 1 || mask != math.MaxUint32 {
+			t.Errorf("expected default layer got %d %x", layer, mask)
+		}
+	})
+
+	// go test -run Contact/report
+	t.Run("report", func(t *testing.T) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: 0, Y: 0.6, Z: 0})
+		bods := []Body{*ground, *ball}
+		Simulate(bods, 1.0/60.0)
+		if found := Contacts(nil); len(found) != 0 {
+			t.Fatalf("expected no contacts before landing got %v", found)
+		}
+		for i := 0; i < 180; i++ { // long enough for the ball to sleep.
+			Simulate(bods, 1.0/60.0)
+		}
+		if bods[1].active {
+			t.Error("expected ball to be asleep")
+		}
+		found := Contacts(nil)
+		if len(found) != 1 || found[0].B1 != 0 || found[0].B2 != 1 || found[0].Trigger {
+			t.Fatalf("expected ground ball contact got %v", found)
+		}
+		if c := found[0]; math.Abs(c.Point.Y) > 0.01 || c.Normal.Y < 0.99 {
+			t.Errorf("expected contact on the ground pointing at the ball got %v %v", c.Point, c.Normal)
+		}
+	})
+
+	// go test -run Contact/trigger
+	t.Run("trigger", func(t *testing.T) {
+		zone := NewBox(1, 1, 1, true)
+		zone.SetPosition(lin.V3{X: 0, Y: 0, Z: 0})
+		zone.SetTrigger(true)
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: 0, Y: 3, Z: 0})
+		bods := []Body{*zone, *ball}
+		entered, left := -1, -1
+		for i := 0; i < 60; i++ {
+			Simulate(bods, 1.0/60.0)
+			found := Contacts(nil)
+			switch {
+			case len(found) == 1 && found[0].Trigger && entered < 0:
+				entered = i
+			case len(found) == 0 && entered >= 0 && left < 0:
+				left = i
+			}
+		}
+		if entered < 0 || left <= entered {
+			t.Errorf("expected ball to enter and leave the trigger got %d %d", entered, left)
+		}
+		if y := bods[1].world_position.Y; y > -1.5 {
+			t.Errorf("expected ball to fall through the trigger got %f", y)
+		}
+	})
+}
+
+// go test -run Snapshot
+func TestSnapshot(t *testing.T) {
+	// world returns a stack of boxes, a rolling ball, and a pendulum.
+	world := func() ([]Body, []*Joint) {
+		ground := NewBox(10, 1, 10, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		post := NewBox(0.1, 0.1, 0.1, true)
+		post.SetPosition(lin.V3{X: 5, Y: 4, Z: 0})
+		bob := NewSphere(0.3, false)
+		bob.SetPosition(lin.V3{X: 6, Y: 4, Z: 0})
+		ball := NewSphere(0.5, false)
+		ball.SetPosition(lin.V3{X: -4, Y: 0.5, Z: 0})
+		ball.Push(3, 0, 0)
+		bods := []Body{*ground, *post, *bob, *ball}
+		for i := 0; i < 3; i++ {
+			box := NewBox(0.5, 0.5, 0.5, false)
+			box.SetPosition(lin.V3{X: 0, Y: 0.5 + float64(i)*1.01, Z: 0})
+			bods = append(bods, *box)
+		}
+		joint := NewJoint(BallJoint, bods, 1, 2, lin.V3{X: 5, Y: 4, Z: 0}, lin.V3{X: 1})
+		return bods, []*Joint{joint}
+	}
+	// run simulates the world and returns the final body states.
+	run := func(bods []Body, joints []*Joint, steps int) []snapshot_Body {
+		for i := 0; i < steps; i++ {
+			Simulate(bods, 1.0/60.0, joints...)
+		}
+		return (&Snapshot{}).Save(bods, joints, nil).bodies
+	}
+
+	// go test -run Snapshot/rollback
+	t.Run("rollback", func(t *testing.T) {
+		bods, joints := world()
+		run(bods, joints, 30)
+		snap := (&Snapshot{}).Save(bods, joints, nil)
+		want := run(bods, joints, 120)
+		if err := snap.Restore(bods, joints, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := run(bods, joints, 120); !slices.Equal(got, want) {
+			t.Errorf("expected identical simulation after restore")
+		}
+	})
+
+	// go test -run Snapshot/encode
+	t.Run("encode", func(t *testing.T) {
+		bods, joints := world()
+		run(bods, joints, 30)
+		bods[3].AddForce(lin.V3{}, lin.V3{X: 0, Y: 50, Z: 0}, false) // unsimulated force.
+		data, err := (&Snapshot{}).Save(bods, joints, nil).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := run(bods, joints, 60)
+
+		// restore into a new copy of the world.
+		snap := &Snapshot{}
+		if err := snap.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		bods, joints = world()
+		if err := snap.Restore(bods, joints, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := run(bods, joints, 60); !slices.Equal(got, want) {
+			t.Errorf("expected identical simulation after decode")
+		}
+
+		// invalid snapshots.
+		if err := snap.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Error("expected error for short data")
+		}
+		if err := snap.UnmarshalBinary(append([]byte("bad!"), data[4:]...)); err == nil {
+			t.Error("expected error for bad header")
+		}
+		if err := snap.Restore(bods[:2], joints, nil); err == nil {
+			t.Error("expected error for missing bodies")
+		}
+	})
+}
+
+// go test -run Cloth
+func TestCloth(t *testing.T) {
+	// grid returns a horizontal, or hanging, cloth with cols*rows particles.
+	grid := func(size float64, cols, rows int, at lin.V3, hanging bool) *Cloth {
+		verts, indexes := []lin.V3{}, []uint32{}
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				x, y := size*float64(c)/float64(cols-1), size*float64(r)/float64(rows-1)
+				if hanging {
+					verts = append(verts, lin.V3{X: at.X + x, Y: at.Y - y, Z: at.Z})
+					continue
+				}
+				verts = append(verts, lin.V3{X: at.X + x, Y: at.Y, Z: at.Z + y})
+			}
+		}
+		for r := 0; r < rows-1; r++ {
+			for c := 0; c < cols-1; c++ {
+				i := uint32(r*cols + c)
+				indexes = append(indexes, i, i+uint32(cols), i+1, i+1, i+uint32(cols), i+uint32(cols)+1)
+			}
+		}
+		return NewCloth(verts, indexes)
+	}
+
+	// go test -run Cloth/hanging
+	t.Run("hanging", func(t *testing.T) {
+		cloth := grid(1, 8, 8, lin.V3{X: 0, Y: 5, Z: 0}, true)
+		cloth.Pin(0, true)
+		cloth.Pin(7, true)
+		pinned := cloth.Particles()[7]
+		for i := 0; i < 120; i++ {
+			cloth.Simulate(nil, 1.0/60.0)
+		}
+		p := cloth.Particles()
+		if p[7] != pinned {
+			t.Errorf("expected pinned particle at %v got %v", pinned, p[7])
+		}
+		if height := p[0].Y - p[63].Y; height < 0.9 || height > 1.1 {
+			t.Errorf("expected cloth to hang about 1 meter got %f", height)
+		}
+		for i := range cloth.stretch {
+			con := &cloth.stretch[i]
+			if stretch := p[con.p1].Dist(&p[con.p2]) / con.rest; stretch > 1.1 {
+				t.Fatalf("expected little stretching got %f", stretch)
+			}
+		}
+	})
+
+	// go test -run Cloth/drape
+	t.Run("drape", func(t *testing.T) {
+		ball := NewSphere(0.5, true)
+		box := NewBox(0.5, 0.5, 0.5, true)
+		box.SetPosition(lin.V3{X: 3, Y: 0, Z: 0})
+		bods := []Body{*ball, *box}
+		Simulate(bods, 1.0/60.0)
+		for _, center := range []lin.V3{{X: 0, Y: 0, Z: 0}, {X: 3, Y: 0, Z: 0}} {
+			cloth := grid(2, 12, 12, lin.V3{X: center.X - 1, Y: 1, Z: -1}, false)
+			cloth.Thickness = 0.02
+			for i := 0; i < 120; i++ {
+				cloth.Simulate(bods, 1.0/60.0)
+			}
+			p := cloth.Particles()
+			for i := range p {
+				d := lin.NewV3().Sub(&p[i], &center)
+				inside := d.Len() < 0.5 // sphere
+				if center.X > 0 {
+					inside = math.Abs(d.X) < 0.5 && math.Abs(d.Y) < 0.5 && math.Abs(d.Z) < 0.5
+				}
+				if inside {
+					t.Fatalf("particle %d inside body at %v", i, p[i])
+				}
+			}
+			if top := p[5*12+5]; top.Y < 0.5 {
+				t.Errorf("expected cloth to rest on the body got %v", top)
+			}
+		}
+	})
+}
+
+// go test -run Vehicle
+func TestVehicle(t *testing.T) {
+	// world returns a four wheel car resting above a large flat ground.
+	world := func() ([]Body, *Vehicle) {
+		ground := NewBox(100, 1, 100, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 0})
+		chassis := NewBox(1, 0.25, 2, false)
+		chassis.SetPosition(lin.V3{X: 0, Y: 0.8, Z: 0})
+		wheels := []Wheel{}
+		for _, at := range []lin.V3{{X: -1, Z: -1.5}, {X: 1, Z: -1.5}, {X: -1, Z: 1.5}, {X: 1, Z: 1.5}} {
+			front := at.Z < 0
+			wheels = append(wheels, Wheel{Mount: at, Radius: 0.3, Rest: 0.5, Drive: !front, Steer: front, Brake: true})
+		}
+		return []Body{*ground, *chassis}, NewVehicle(chassis, wheels...)
+	}
+	// run updates and simulates the vehicle.
+	run := func(bods []Body, car *Vehicle, steps int) {
+		for i := 0; i < steps; i++ {
+			car.Update(&bods[1], bods, 1.0/60.0)
+			Simulate(bods, 1.0/60.0)
+		}
+	}
+	bods, car := world()
+	chassis := &bods[1]
+
+	// go test -run Vehicle/suspension
+	t.Run("suspension", func(t *testing.T) {
+		run(bods, car, 180)
+		for i := 0; i < car.Wheels(); i++ {
+			if !car.Grounded(i) {
+				t.Errorf("expected wheel %d on the ground", i)
+			}
+		}
+		// chassis rests on springs half compressed: 0.3 radius + 0.25 length.
+		if y := chassis.Position().Y; y < 0.45 || y > 0.65 {
+			t.Errorf("expected chassis resting on suspension got %f", y)
+		}
+		if at, _ := car.WheelPose(chassis, 0); math.Abs(at.Y-0.3) > 0.05 {
+			t.Errorf("expected wheel on the ground got %v", at)
+		}
+	})
+
+	// go test -run Vehicle/drive
+	t.Run("drive", func(t *testing.T) {
+		car.Engine = 10
+		run(bods, car, 120)
+		if car.Speed() < 5 || chassis.Position().Z > -5 || math.Abs(chassis.Position().X) > 0.1 {
+			t.Errorf("expected car to drive forward got %f %v", car.Speed(), chassis.Position())
+		}
+		car.Steer = 0.3
+		run(bods, car, 60)
+		if chassis.Position().X > -1 {
+			t.Errorf("expected car to turn left got %v", chassis.Position())
+		}
+		car.Engine, car.Steer, car.Brake = 0, 0, 20
+		run(bods, car, 180)
+		if math.Abs(car.Speed()) > 0.1 {
+			t.Errorf("expected car to stop got %f", car.Speed())
+		}
+		if y := chassis.Position().Y; y < 0.4 {
+			t.Errorf("expected car upright got %v", chassis.Position())
+		}
+	})
+}
+
+// go test -run Planar
+func TestPlanar(t *testing.T) {
+	t.Run("polygon", func(t *testing.T) {
+		b := NewPolygon([]float64{-1, -1, 1, -1, 0, 1}, false)
+		if len(b.colliders[0].convex_hull.vertices) != 6 || !b.Planar() {
+			t.Fatal("expecting triangle prism")
+		}
+		if _, _, _, ok := b.Box(); ok {
+			t.Error("polygon is not a box")
+		}
+		if NewPolygon([]float64{0, 0, 1, 1}, false) != nil {
+			t.Error("expecting nil for 2 points")
+		}
+	})
+	t.Run("collide", func(t *testing.T) {
+		ground := NewRect(10, 1, true)
+		ground.SetPosition(lin.V3{X: 0, Y: -1, Z: 5})
+		ball := NewCircle(0.5, false)
+		ball.SetPosition(lin.V3{X: 0, Y: 2, Z: 5})
+		ball.SetRotation(*lin.NewQ().SetAa(1, 1, 0, lin.Rad(30)))
+		ball.Push(1, 0, 3)
+		bods := []Body{*ground, *ball}
+		for i := 0; i < 120; i++ {
+			Simulate(bods, 1.0/60.0)
+		}
+		b := &bods[1]
+		if b.world_position.Z != 5 || b.world_rotation.X != 0 || b.world_rotation.Y != 0 {
+			t.Errorf("expected planar body got %v %v", b.world_position, b.world_rotation)
+		}
+		if y := b.world_position.Y; y < 0.4 || y > 0.6 {
+			t.Errorf("expected ball resting on ground got %f", y)
+		}
+	})
 }
 
 // check matrix conventions. The physics package uses row-major.
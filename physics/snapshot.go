@@ -0,0 +1,238 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// snapshot.go saves and restores the simulation state so that apps
+// can replay or roll back simulation steps. It is not part of the
+// original raw-physics port.
+//
+// The simulation is deterministic: the same bodies, joints, and forces
+// simulated in the same order with the same timesteps give the same
+// results on the same platform. Collision pairs, islands, and contacts
+// are always ordered by body index. The constraint solver does not
+// keep any data between steps, so restoring the bodies, joints,
+// characters, and contacts is enough to repeat the following steps.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Snapshot is the simulation state of a group of bodies and their
+// joints and characters. It does not include the body shapes which
+// are expected to exist when the snapshot is restored.
+type Snapshot struct {
+	bodies   []snapshot_Body
+	forces   []snapshot_Force // forces for all bodies, in body order.
+	angles   []float64        // joint twist angles.
+	chars    []snapshot_Character
+	contacts []snapshot_Contact
+}
+
+// Save copies the state of the bodies, joints, and characters into
+// the snapshot, reusing the snapshot memory, and returns the snapshot.
+// The saved contacts are from the last call to Simulate, so the bodies
+// are expected to be the ones last given to Simulate.
+func (s *Snapshot) Save(bods []Body, joints []*Joint, chars []*Character) *Snapshot {
+	s.bodies, s.forces = s.bodies[:0], s.forces[:0]
+	for i := range bods {
+		b := &bods[i]
+		s.bodies = append(s.bodies, snapshot_Body{
+			Position:         b.world_position,
+			Rotation:         b.world_rotation,
+			LinearVelocity:   b.linear_velocity,
+			AngularVelocity:  b.angular_velocity,
+			DeactivationTime: b.deactivation_time,
+			Layer:            b.layer,
+			Mask:             b.mask,
+			Forces:           uint32(len(b.forces)),
+			Active:           b.active,
+			Trigger:          b.trigger,
+		})
+		for _, f := range b.forces {
+			s.forces = append(s.forces, snapshot_Force{Position: f.position, Newtons: f.newtons, Local: f.local_coords})
+		}
+	}
+	s.angles = s.angles[:0]
+	for _, j := range joints {
+		s.angles = append(s.angles, j.angle)
+	}
+	s.chars = s.chars[:0]
+	for _, c := range chars {
+		s.chars = append(s.chars, snapshot_Character{Grounded: c.grounded, Ground: c.ground})
+	}
+	s.contacts = s.contacts[:0]
+	for _, c := range contacts {
+		s.contacts = append(s.contacts, snapshot_Contact{
+			B1: uint32(c.B1), B2: uint32(c.B2), Point: c.Point, Normal: c.Normal, Trigger: c.Trigger,
+		})
+	}
+	return s
+}
+
+// Restore sets the bodies, joints, and characters to the saved state.
+// They must be the same bodies, joints, and characters, in the same
+// order, that were saved. Returns an error and changes nothing if the
+// number of bodies, joints, or characters does not match the snapshot.
+func (s *Snapshot) Restore(bods []Body, joints []*Joint, chars []*Character) error {
+	switch {
+	case len(bods) != len(s.bodies):
+		return fmt.Errorf("restore %d bodies: snapshot has %d", len(bods), len(s.bodies))
+	case len(joints) != len(s.angles):
+		return fmt.Errorf("restore %d joints: snapshot has %d", len(joints), len(s.angles))
+	case len(chars) != len(s.chars):
+		return fmt.Errorf("restore %d characters: snapshot has %d", len(chars), len(s.chars))
+	}
+	forces := s.forces
+	for i := range bods {
+		b, sb := &bods[i], &s.bodies[i]
+		b.world_position, b.world_rotation = sb.Position, sb.Rotation
+		b.previous_world_position, b.previous_world_rotation = sb.Position, sb.Rotation
+		b.linear_velocity, b.angular_velocity = sb.LinearVelocity, sb.AngularVelocity
+		b.previous_linear_velocity, b.previous_angular_velocity = sb.LinearVelocity, sb.AngularVelocity
+		b.deactivation_time = sb.DeactivationTime
+		b.layer, b.mask = sb.Layer, sb.Mask
+		b.active, b.trigger = sb.Active, sb.Trigger
+		b.forces = b.forces[:0]
+		for _, f := range forces[:sb.Forces] {
+			b.forces = append(b.forces, force{position: f.Position, newtons: f.Newtons, local_coords: f.Local})
+		}
+		forces = forces[sb.Forces:]
+		colliders_update(b.colliders, b.world_position, &b.world_rotation)
+	}
+	for i, j := range joints {
+		j.angle = s.angles[i]
+	}
+	for i, c := range chars {
+		c.grounded, c.ground = s.chars[i].Grounded, s.chars[i].Ground
+	}
+	contacts = contacts[:0]
+	clear(contact_last)
+	for _, sc := range s.contacts {
+		c := Contact{B1: int(sc.B1), B2: int(sc.B2), Point: sc.Point, Normal: sc.Normal, Trigger: sc.Trigger}
+		contacts = append(contacts, c)
+		b1, b2 := &bods[c.B1], &bods[c.B2]
+		contact_last[contact_Key{&b1.colliders[0], &b2.colliders[0]}] = c
+	}
+	return nil
+}
+
+// snapshot_MAGIC and snapshot_VERSION identify encoded snapshots.
+var snapshot_MAGIC = [4]byte{'v', 'u', 'p', 's'}
+
+const snapshot_VERSION = 1
+
+// MarshalBinary encodes the snapshot, eg: to save a replay or send
+// the simulation state over a network. Floats are encoded exactly so
+// that restored simulations match the saved simulation.
+func (s *Snapshot) MarshalBinary() ([]byte, error) {
+	hdr := snapshot_Header{
+		Magic:    snapshot_MAGIC,
+		Version:  snapshot_VERSION,
+		Bodies:   uint32(len(s.bodies)),
+		Forces:   uint32(len(s.forces)),
+		Joints:   uint32(len(s.angles)),
+		Chars:    uint32(len(s.chars)),
+		Contacts: uint32(len(s.contacts)),
+	}
+	buf := &bytes.Buffer{}
+	for _, data := range []any{hdr, s.bodies, s.forces, s.angles, s.chars, s.contacts} {
+		if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
+			return nil, fmt.Errorf("snapshot encode: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a snapshot encoded by MarshalBinary.
+func (s *Snapshot) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	hdr := snapshot_Header{}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return fmt.Errorf("snapshot decode: %w", err)
+	}
+	if hdr.Magic != snapshot_MAGIC || hdr.Version != snapshot_VERSION {
+		return fmt.Errorf("snapshot decode: invalid header %q version %d", hdr.Magic[:], hdr.Version)
+	}
+	size := int(hdr.Bodies)*binary.Size(snapshot_Body{}) + int(hdr.Forces)*binary.Size(snapshot_Force{}) +
+		int(hdr.Joints)*8 + int(hdr.Chars)*binary.Size(snapshot_Character{}) + int(hdr.Contacts)*binary.Size(snapshot_Contact{})
+	if size != r.Len() {
+		return fmt.Errorf("snapshot decode: expected %d bytes got %d", size, r.Len())
+	}
+	snap := Snapshot{
+		bodies:   make([]snapshot_Body, hdr.Bodies),
+		forces:   make([]snapshot_Force, hdr.Forces),
+		angles:   make([]float64, hdr.Joints),
+		chars:    make([]snapshot_Character, hdr.Chars),
+		contacts: make([]snapshot_Contact, hdr.Contacts),
+	}
+	for _, data := range []any{snap.bodies, snap.forces, snap.angles, snap.chars, snap.contacts} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return fmt.Errorf("snapshot decode: %w", err)
+		}
+	}
+	forces := 0
+	for _, b := range snap.bodies {
+		forces += int(b.Forces)
+	}
+	if forces != len(snap.forces) {
+		return fmt.Errorf("snapshot decode: expected %d forces got %d", forces, len(snap.forces))
+	}
+	for _, c := range snap.contacts {
+		if c.B1 >= hdr.Bodies || c.B2 >= hdr.Bodies {
+			return fmt.Errorf("snapshot decode: invalid contact bodies %d %d", c.B1, c.B2)
+		}
+	}
+	*s = snap
+	return nil
+}
+
+// snapshot_Header starts an encoded snapshot. It is followed by
+// the snapshot bodies, forces, joint angles, characters, and contacts.
+// Snapshot data fields are exported for encoding/binary.
+type snapshot_Header struct {
+	Magic    [4]byte
+	Version  uint32
+	Bodies   uint32
+	Forces   uint32
+	Joints   uint32
+	Chars    uint32
+	Contacts uint32
+}
+
+// snapshot_Body is the saved state of a body.
+type snapshot_Body struct {
+	Position         lin.V3
+	Rotation         lin.Q
+	LinearVelocity   lin.V3
+	AngularVelocity  lin.V3
+	DeactivationTime float64
+	Layer, Mask      uint32
+	Forces           uint32 // number of body forces.
+	Active           bool
+	Trigger          bool
+}
+
+// snapshot_Force is a saved force that has not been simulated yet.
+type snapshot_Force struct {
+	Position lin.V3
+	Newtons  lin.V3
+	Local    bool
+}
+
+// snapshot_Character is the saved state of a character.
+type snapshot_Character struct {
+	Grounded bool
+	Ground   lin.V3
+}
+
+// snapshot_Contact is a saved contact from the last simulation step.
+type snapshot_Contact struct {
+	B1, B2  uint32
+	Point   lin.V3
+	Normal  lin.V3
+	Trigger bool
+}
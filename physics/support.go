@@ -33,6 +33,8 @@ func support_point(collider *collider, direction lin.V3) lin.V3 {
 	case collider_TYPE_SPHERE:
 		v3.Add(&collider.sphere.center, lin.NewV3().Scale(lin.NewV3().Set(&direction).Unit(), float64(collider.sphere.radius)))
 		return *v3
+	case collider_TYPE_CAPSULE:
+		return capsule_support_point(&collider.capsule, direction)
 	}
 	slog.Error("unsupported collider type", "collider_type", collider.ctype)
 	return *v3
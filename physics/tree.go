@@ -0,0 +1,617 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// tree.go is a dynamic AABB tree broad phase. It is not part of the
+// original raw-physics port which checked every pair of bodies.
+// Each body is a leaf with bounds that are a bit larger than the body
+// so that leaves are only moved when their body leaves the bounds.
+// The tree is rebalanced as leaves are added and removed.
+// The tree also finds the bodies hit by rays or inside volumes.
+
+import (
+	"math"
+	"slices"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// RayHit is where a ray hit a body, see Raycast.
+type RayHit struct {
+	Body     int     // index of the hit body.
+	Point    lin.V3  // world location of the hit.
+	Normal   lin.V3  // body surface normal at the hit.
+	Distance float64 // distance along the ray to the hit.
+}
+
+// Raycast returns the closest body hit by the ray from origin in the
+// direction dir, up to maxDistance away. Rays that start inside a
+// body do not hit that body. Returns false if nothing was hit.
+func Raycast(bods []Body, origin, dir lin.V3, maxDistance float64) (hit RayHit, ok bool) {
+	return raycast(bods, origin, dir, maxDistance, nil)
+}
+
+// raycast is Raycast where bodies are not hit if ignore returns true.
+func raycast(bods []Body, origin, dir lin.V3, maxDistance float64, ignore func(b *Body) bool) (hit RayHit, ok bool) {
+	length := dir.Len()
+	if length <= 0 || maxDistance <= 0 {
+		return hit, false
+	}
+	dir.Scale(&dir, 1/length)
+	broad_tree_update(bods)
+	tree_raycast(&broad_tree, origin, dir, maxDistance, func(id bid, best float64) float64 {
+		b := &bods[id]
+		if ignore != nil && ignore(b) {
+			return best
+		}
+		colliders_update(b.colliders, b.world_position, &b.world_rotation)
+		if t, normal, found := body_raycast(b, origin, dir, best); found {
+			hit.Body, hit.Normal, hit.Distance, ok = int(id), normal, t, true
+			return t
+		}
+		return best
+	})
+	if ok {
+		hit.Point.Add(&origin, lin.NewV3().Scale(&dir, hit.Distance))
+	}
+	return hit, ok
+}
+
+// QueryBox appends the indexes of the bodies whose bounds overlap
+// the world space box lo:hi to found and returns the result.
+func QueryBox(bods []Body, lo, hi lin.V3, found []int) []int {
+	broad_tree_update(bods)
+	start := len(found)
+	tree_query(&broad_tree, lo, hi, func(id bid) {
+		b := &bods[id]
+		colliders_update(b.colliders, b.world_position, &b.world_rotation)
+		if blo, bhi := body_get_aabb(b); aabb_overlaps(&blo, &bhi, &lo, &hi) {
+			found = append(found, int(id))
+		}
+	})
+	slices.Sort(found[start:])
+	return found
+}
+
+// QuerySphere appends the indexes of the bodies that overlap the
+// world space sphere to found and returns the result.
+func QuerySphere(bods []Body, center lin.V3, radius float64, found []int) []int {
+	broad_tree_update(bods)
+	sphere := []collider{collider_sphere_create(float32(radius))}
+	colliders_update(sphere, center, lin.NewQI())
+	lo := lin.V3{X: center.X - radius, Y: center.Y - radius, Z: center.Z - radius}
+	hi := lin.V3{X: center.X + radius, Y: center.Y + radius, Z: center.Z + radius}
+	start := len(found)
+	tree_query(&broad_tree, lo, hi, func(id bid) {
+		b := &bods[id]
+		colliders_update(b.colliders, b.world_position, &b.world_rotation)
+		if len(colliders_get_contacts(b.colliders, sphere)) > 0 {
+			found = append(found, int(id))
+		}
+	})
+	slices.Sort(found[start:])
+	return found
+}
+
+// broad_tree holds a leaf for each body in the last bodies given
+// to broad_tree_update.
+var broad_tree = aabb_Tree{root: -1, free: -1}
+
+// broad_MARGIN is added to the body bounds to account for moving
+// bodies. Bodies are paired when their bounding spheres are closer
+// than twice the margin.
+const broad_MARGIN = 0.05
+
+// broad_FAT is added to the leaf bounds so that leaves are only
+// moved when their body has moved a bit.
+const broad_FAT = 0.2
+
+// broad_body_bounds returns the bounding sphere box of a body.
+func broad_body_bounds(b *Body) (lo, hi lin.V3) {
+	r := b.bounding_sphere_radius + broad_MARGIN
+	p := &b.world_position
+	return lin.V3{X: p.X - r, Y: p.Y - r, Z: p.Z - r}, lin.V3{X: p.X + r, Y: p.Y + r, Z: p.Z + r}
+}
+
+// broad_tree_update adds, moves, and removes tree leaves so that
+// the tree has one leaf for each body. Leaves are matched to bodies
+// using the body colliders which are shared by copies of a body.
+func broad_tree_update(bods []Body) {
+	t := &broad_tree
+	t.stamp++
+	for i := range bods {
+		b := &bods[i]
+		if len(b.colliders) == 0 {
+			b.proxy = 0
+			continue
+		}
+		lo, hi := broad_body_bounds(b)
+		leaf, key := b.proxy-1, &b.colliders[0]
+		if leaf < 0 || int(leaf) >= len(t.nodes) || t.nodes[leaf].height != 0 ||
+			t.nodes[leaf].key != key || t.nodes[leaf].stamp == t.stamp {
+			leaf = tree_insert(t, lo, hi, key) // new body, or a copy of a body.
+			b.proxy = leaf + 1
+		} else if n := &t.nodes[leaf]; !aabb_contains(&n.lo, &n.hi, &lo, &hi) {
+			tree_remove_leaf(t, leaf)
+			n.lo, n.hi = tree_fatten(lo, hi)
+			tree_insert_leaf(t, leaf)
+		}
+		t.nodes[leaf].body, t.nodes[leaf].stamp = bid(i), t.stamp
+	}
+	for i := range t.nodes {
+		if n := &t.nodes[i]; n.height == 0 && n.stamp != t.stamp {
+			tree_remove_leaf(t, int32(i)) // body was removed.
+			tree_free(t, int32(i))
+		}
+	}
+}
+
+// aabb_Tree is a dynamic bounding volume hierarchy where each
+// leaf bounds one body.
+type aabb_Tree struct {
+	nodes []tree_Node
+	root  int32  // root node, -1 if the tree is empty.
+	free  int32  // first unused node, -1 if there are none.
+	stamp uint32 // incremented each broad_tree_update.
+}
+
+// tree_Node bounds its two children or a body.
+type tree_Node struct {
+	lo, hi lin.V3    // world space bounds.
+	parent int32     // parent node, or next free node.
+	left   int32     // first child node, -1 for a leaf.
+	right  int32     // second child node, -1 for a leaf.
+	height int32     // 0 for leaves, -1 for unused nodes.
+	key    *collider // leaf body identity: its first collider.
+	body   bid       // leaf body index.
+	stamp  uint32    // last update that saw the leaf body.
+}
+
+// tree_fatten returns the leaf bounds for the body bounds lo:hi.
+func tree_fatten(lo, hi lin.V3) (flo, fhi lin.V3) {
+	return lin.V3{X: lo.X - broad_FAT, Y: lo.Y - broad_FAT, Z: lo.Z - broad_FAT},
+		lin.V3{X: hi.X + broad_FAT, Y: hi.Y + broad_FAT, Z: hi.Z + broad_FAT}
+}
+
+// tree_alloc returns an unused node, reusing freed nodes.
+func tree_alloc(t *aabb_Tree) int32 {
+	if t.free < 0 {
+		t.nodes = append(t.nodes, tree_Node{})
+		t.free = int32(len(t.nodes) - 1)
+		t.nodes[t.free].parent = -1
+	}
+	index := t.free
+	t.free = t.nodes[index].parent
+	t.nodes[index] = tree_Node{parent: -1, left: -1, right: -1}
+	return index
+}
+
+// tree_free returns a node to the unused nodes.
+func tree_free(t *aabb_Tree, index int32) {
+	t.nodes[index] = tree_Node{parent: t.free, left: -1, right: -1, height: -1}
+	t.free = index
+}
+
+// tree_insert adds a leaf for the body bounds lo:hi.
+func tree_insert(t *aabb_Tree, lo, hi lin.V3, key *collider) int32 {
+	leaf := tree_alloc(t)
+	n := &t.nodes[leaf]
+	n.lo, n.hi = tree_fatten(lo, hi)
+	n.key = key
+	tree_insert_leaf(t, leaf)
+	return leaf
+}
+
+// tree_insert_leaf adds the leaf next to the sibling that increases the
+// tree surface area the least.
+// Based on the dynamic tree from Box2D, Erin Catto.
+func tree_insert_leaf(t *aabb_Tree, leaf int32) {
+	if t.root < 0 {
+		t.root = leaf
+		t.nodes[leaf].parent = -1
+		return
+	}
+
+	// find the best sibling.
+	lo, hi := t.nodes[leaf].lo, t.nodes[leaf].hi
+	index := t.root
+	for t.nodes[index].left >= 0 {
+		n := &t.nodes[index]
+		area := aabb_area(&n.lo, &n.hi)
+		combined := aabb_area(lin.NewV3().Min(&n.lo, &lo), lin.NewV3().Max(&n.hi, &hi))
+		cost := 2 * combined                 // new parent for this node and the leaf.
+		inheritance := 2 * (combined - area) // minimum cost of pushing the leaf further down.
+		left := tree_descend_cost(t, n.left, &lo, &hi) + inheritance
+		right := tree_descend_cost(t, n.right, &lo, &hi) + inheritance
+		if cost < left && cost < right {
+			break
+		}
+		index = n.left
+		if right < left {
+			index = n.right
+		}
+	}
+
+	// create a new parent for the sibling and the leaf.
+	sibling := index
+	parent := tree_alloc(t)
+	old_parent := t.nodes[sibling].parent
+	p, s := &t.nodes[parent], &t.nodes[sibling]
+	p.parent, p.left, p.right, p.height = old_parent, sibling, leaf, s.height+1
+	p.lo.Min(&s.lo, &lo)
+	p.hi.Max(&s.hi, &hi)
+	s.parent, t.nodes[leaf].parent = parent, parent
+	if old_parent < 0 {
+		t.root = parent
+	} else if op := &t.nodes[old_parent]; op.left == sibling {
+		op.left = parent
+	} else {
+		op.right = parent
+	}
+	tree_refit(t, parent)
+}
+
+// tree_descend_cost returns the increase in surface area from adding
+// the bounds lo:hi below the node.
+func tree_descend_cost(t *aabb_Tree, index int32, lo, hi *lin.V3) float64 {
+	n := &t.nodes[index]
+	area := aabb_area(lin.NewV3().Min(&n.lo, lo), lin.NewV3().Max(&n.hi, hi))
+	if n.left < 0 {
+		return area
+	}
+	return area - aabb_area(&n.lo, &n.hi)
+}
+
+// tree_remove_leaf takes the leaf out of the tree. The leaf node
+// is not freed so that it can be inserted again.
+func tree_remove_leaf(t *aabb_Tree, leaf int32) {
+	if leaf == t.root {
+		t.root = -1
+		return
+	}
+	parent := t.nodes[leaf].parent
+	grand_parent := t.nodes[parent].parent
+	sibling := t.nodes[parent].left
+	if sibling == leaf {
+		sibling = t.nodes[parent].right
+	}
+	t.nodes[sibling].parent = grand_parent
+	tree_free(t, parent)
+	if grand_parent < 0 {
+		t.root = sibling
+		return
+	}
+	if gp := &t.nodes[grand_parent]; gp.left == parent {
+		gp.left = sibling
+	} else {
+		gp.right = sibling
+	}
+	tree_refit(t, grand_parent)
+}
+
+// tree_refit rebalances and updates the bounds and heights of
+// the node and its ancestors.
+func tree_refit(t *aabb_Tree, index int32) {
+	for index >= 0 {
+		index = tree_balance(t, index)
+		n := &t.nodes[index]
+		l, r := &t.nodes[n.left], &t.nodes[n.right]
+		n.height = 1 + max(l.height, r.height)
+		n.lo.Min(&l.lo, &r.lo)
+		n.hi.Max(&l.hi, &r.hi)
+		index = n.parent
+	}
+}
+
+// tree_balance rotates the taller grandchild of node a up when its
+// children heights differ by more than one. Returns the node that
+// replaced a.
+func tree_balance(t *aabb_Tree, a int32) int32 {
+	na := &t.nodes[a]
+	if na.left < 0 || na.height < 2 {
+		return a
+	}
+	b, c := na.left, na.right
+	switch balance := t.nodes[c].height - t.nodes[b].height; {
+	case balance > 1:
+		tree_rotate(t, a, c, b, false)
+		return c
+	case balance < -1:
+		tree_rotate(t, a, b, c, true)
+		return b
+	}
+	return a
+}
+
+// tree_rotate moves the tall child of node a up to replace a. The
+// shorter grandchild of the tall child replaces it as a child of a.
+// The short child of a stays where it is.
+func tree_rotate(t *aabb_Tree, a, tall, short int32, tall_is_left bool) {
+	na, nt := &t.nodes[a], &t.nodes[tall]
+	f, g := nt.left, nt.right
+
+	// tall replaces a.
+	nt.left, nt.parent, na.parent = a, na.parent, tall
+	if nt.parent < 0 {
+		t.root = tall
+	} else if np := &t.nodes[nt.parent]; np.left == a {
+		np.left = tall
+	} else {
+		np.right = tall
+	}
+
+	// a keeps the shorter grandchild.
+	keep, move := f, g
+	if t.nodes[g].height > t.nodes[f].height {
+		keep, move = g, f
+	}
+	nt.right = keep
+	if tall_is_left {
+		na.left = move
+	} else {
+		na.right = move
+	}
+	t.nodes[move].parent = a
+	ns, nm, nk := &t.nodes[short], &t.nodes[move], &t.nodes[keep]
+	na.lo.Min(&ns.lo, &nm.lo)
+	na.hi.Max(&ns.hi, &nm.hi)
+	na.height = 1 + max(ns.height, nm.height)
+	nt.lo.Min(&na.lo, &nk.lo)
+	nt.hi.Max(&na.hi, &nk.hi)
+	nt.height = 1 + max(na.height, nk.height)
+}
+
+// tree_query calls fn with the bodies whose leaf bounds overlap lo:hi.
+func tree_query(t *aabb_Tree, lo, hi lin.V3, fn func(id bid)) {
+	if t.root < 0 {
+		return
+	}
+	stack := []int32{t.root}
+	for len(stack) > 0 {
+		n := &t.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !aabb_overlaps(&n.lo, &n.hi, &lo, &hi) {
+			continue
+		}
+		if n.left < 0 {
+			fn(n.body)
+			continue
+		}
+		stack = append(stack, n.left, n.right)
+	}
+}
+
+// tree_raycast calls fn with the bodies whose leaf bounds are hit by
+// the ray closer than the best distance so far. fn returns the new best
+// distance which is used to skip the leaves that are further away.
+func tree_raycast(t *aabb_Tree, origin, dir lin.V3, best float64, fn func(id bid, best float64) float64) {
+	if t.root < 0 {
+		return
+	}
+	stack := []int32{t.root}
+	for len(stack) > 0 {
+		n := &t.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !ray_aabb(&origin, &dir, &n.lo, &n.hi, best) {
+			continue
+		}
+		if n.left < 0 {
+			best = fn(n.body, best)
+			continue
+		}
+		stack = append(stack, n.left, n.right)
+	}
+}
+
+// aabb_area returns the surface area of the bounds lo:hi.
+func aabb_area(lo, hi *lin.V3) float64 {
+	dx, dy, dz := hi.X-lo.X, hi.Y-lo.Y, hi.Z-lo.Z
+	return 2 * (dx*dy + dy*dz + dz*dx)
+}
+
+// aabb_contains returns true if the bounds lo1:hi1 enclose lo2:hi2.
+func aabb_contains(lo1, hi1, lo2, hi2 *lin.V3) bool {
+	return lo1.X <= lo2.X && lo1.Y <= lo2.Y && lo1.Z <= lo2.Z &&
+		hi1.X >= hi2.X && hi1.Y >= hi2.Y && hi1.Z >= hi2.Z
+}
+
+// body_get_aabb returns the world space bounds of the body colliders.
+func body_get_aabb(b *Body) (lo, hi lin.V3) {
+	for i := range b.colliders {
+		c := &b.colliders[i]
+		clo, chi := lin.V3{}, lin.V3{}
+		if c.ctype == collider_TYPE_MESH {
+			clo, chi = c.mesh.nodes[0].min, c.mesh.nodes[0].max
+		} else {
+			clo, chi = collider_get_aabb(c)
+		}
+		if i == 0 {
+			lo, hi = clo, chi
+			continue
+		}
+		lo.Min(&lo, &clo)
+		hi.Max(&hi, &chi)
+	}
+	return lo, hi
+}
+
+// ray_aabb returns true if the ray hits the bounds lo:hi closer
+// than the given distance. Rays starting inside the bounds hit them.
+func ray_aabb(origin, dir, lo, hi *lin.V3, distance float64) bool {
+	near, far := 0.0, distance
+	for axis := 0; axis < 3; axis++ {
+		o, d := hull_axis(origin, axis), hull_axis(dir, axis)
+		l, h := hull_axis(lo, axis), hull_axis(hi, axis)
+		if math.Abs(d) < 1e-12 {
+			if o < l || o > h {
+				return false // parallel and outside.
+			}
+			continue
+		}
+		t1, t2 := (l-o)/d, (h-o)/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		near, far = max(near, t1), min(far, t2)
+		if near > far {
+			return false
+		}
+	}
+	return true
+}
+
+// body_raycast returns the closest hit of the ray on the body colliders
+// that is closer than the given distance. The colliders must be updated.
+func body_raycast(b *Body, origin, dir lin.V3, distance float64) (t float64, normal lin.V3, ok bool) {
+	for i := range b.colliders {
+		c := &b.colliders[i]
+		var ct float64
+		var cn lin.V3
+		var hit bool
+		switch c.ctype {
+		case collider_TYPE_SPHERE, collider_TYPE_CAPSULE:
+			p, q, r, _ := collider_segment(c)
+			ct, cn, hit = ray_round(origin, dir, p, q, r, distance)
+		case collider_TYPE_CONVEX_HULL:
+			ct, cn, hit = ray_hull(origin, dir, &c.convex_hull, distance)
+		case collider_TYPE_MESH:
+			ct, cn, hit = ray_mesh(origin, dir, c.mesh, distance)
+		}
+		if hit {
+			t, normal, ok, distance = ct, cn, true, ct
+		}
+	}
+	return t, normal, ok
+}
+
+// ray_round returns where the ray hits the outside of a capsule with
+// segment a:b and radius r. Spheres have a zero length segment.
+func ray_round(origin, dir, a, b lin.V3, r, distance float64) (t float64, normal lin.V3, ok bool) {
+	if c, _ := closest_points_segment_segment(a, b, origin, origin); c.Dist(&origin) <= r {
+		return 0, normal, false // ray starts inside.
+	}
+	t = distance
+	for _, center := range []lin.V3{a, b} {
+		if ct, hit := ray_sphere(origin, dir, center, r); hit && ct <= t {
+			t, ok = ct, true
+		}
+	}
+	axis := lin.NewV3().Sub(&b, &a)
+	if length := axis.Len(); length > 1e-12 {
+		axis.Scale(axis, 1/length)
+		oa := lin.NewV3().Sub(&origin, &a)
+		op := lin.NewV3().Sub(oa, lin.NewV3().Scale(axis, axis.Dot(oa))) // perpendicular to the axis.
+		dp := lin.NewV3().Sub(&dir, lin.NewV3().Scale(axis, axis.Dot(&dir)))
+		qa, qb, qc := dp.Dot(dp), op.Dot(dp), op.Dot(op)-r*r
+		if disc := qb*qb - qa*qc; qa > 1e-12 && disc >= 0 {
+			ct := (-qb - math.Sqrt(disc)) / qa
+			s := axis.Dot(lin.NewV3().Add(oa, lin.NewV3().Scale(&dir, ct)))
+			if ct >= 0 && ct <= t && s >= 0 && s <= length {
+				t, ok = ct, true
+			}
+		}
+	}
+	if !ok {
+		return 0, normal, false
+	}
+	point := lin.NewV3().Add(&origin, lin.NewV3().Scale(&dir, t))
+	c, _ := closest_points_segment_segment(a, b, *point, *point)
+	normal.Sub(point, &c).Unit()
+	return t, normal, true
+}
+
+// ray_sphere returns where the ray hits the outside of a sphere.
+func ray_sphere(origin, dir, center lin.V3, r float64) (t float64, ok bool) {
+	m := lin.NewV3().Sub(&origin, &center)
+	b, c := m.Dot(&dir), m.Dot(m)-r*r
+	disc := b*b - c
+	if disc < 0 {
+		return 0, false
+	}
+	t = -b - math.Sqrt(disc)
+	return t, t >= 0
+}
+
+// ray_hull returns where the ray enters a convex hull by clipping
+// the ray with the hull face planes. The hull must be updated.
+func ray_hull(origin, dir lin.V3, hull *collider_Convex_Hull, distance float64) (t float64, normal lin.V3, ok bool) {
+	enter, exit := 0.0, distance
+	for i := range hull.transformed_faces {
+		face := &hull.transformed_faces[i]
+		n := &face.normal
+		point := &hull.transformed_vertices[face.elements[0]]
+		inside := n.Dot(lin.NewV3().Sub(point, &origin)) // positive if origin is behind the face.
+		denom := n.Dot(&dir)
+		if math.Abs(denom) < 1e-12 {
+			if inside < 0 {
+				return 0, normal, false // parallel and outside.
+			}
+			continue
+		}
+		ft := inside / denom
+		switch {
+		case denom < 0 && ft > enter:
+			enter, normal, ok = ft, *n, true
+		case denom > 0 && ft < exit:
+			exit = ft
+		}
+		if enter > exit {
+			return 0, normal, false
+		}
+	}
+	return enter, normal, ok // not ok if the ray starts inside.
+}
+
+// ray_mesh returns the closest mesh triangle hit by the ray using
+// the mesh BVH. The mesh must be updated.
+func ray_mesh(origin, dir lin.V3, mesh *collider_Mesh, distance float64) (t float64, normal lin.V3, ok bool) {
+	stack := []int32{0}
+	for len(stack) > 0 {
+		n := &mesh.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !ray_aabb(&origin, &dir, &n.min, &n.max, distance) {
+			continue
+		}
+		if n.left >= 0 {
+			stack = append(stack, n.left, n.right)
+			continue
+		}
+		for _, tri := range mesh.order[n.start : n.start+n.count] {
+			v := mesh.triangles[tri].convex_hull.transformed_vertices
+			if ct, cn, hit := ray_triangle(origin, dir, v[0], v[1], v[2], distance); hit {
+				t, normal, ok, distance = ct, cn, true, ct
+			}
+		}
+	}
+	return t, normal, ok
+}
+
+// ray_triangle returns where the ray hits either side of a triangle.
+// The normal faces back along the ray.
+// Based on Fast, Minimum Storage Ray/Triangle Intersection, Möller and Trumbore.
+func ray_triangle(origin, dir, v1, v2, v3 lin.V3, distance float64) (t float64, normal lin.V3, ok bool) {
+	e1, e2 := lin.NewV3().Sub(&v2, &v1), lin.NewV3().Sub(&v3, &v1)
+	p := lin.NewV3().Cross(&dir, e2)
+	det := e1.Dot(p)
+	if math.Abs(det) < 1e-12 {
+		return 0, normal, false // parallel to the triangle.
+	}
+	s := lin.NewV3().Sub(&origin, &v1)
+	u := s.Dot(p) / det
+	if u < 0 || u > 1 {
+		return 0, normal, false
+	}
+	q := lin.NewV3().Cross(s, e1)
+	v := dir.Dot(q) / det
+	if v < 0 || u+v > 1 {
+		return 0, normal, false
+	}
+	if t = e2.Dot(q) / det; t < 0 || t > distance {
+		return 0, normal, false
+	}
+	normal.Cross(e1, e2).Unit()
+	if normal.Dot(&dir) > 0 {
+		normal.Neg(&normal)
+	}
+	return t, normal, true
+}
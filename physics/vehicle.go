@@ -0,0 +1,206 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package physics
+
+// vehicle.go is a raycast vehicle. It is not part of the original
+// raw-physics port. The vehicle chassis is a simulated body and each
+// wheel is a ray cast down from the chassis. Wheels touching the ground
+// push the chassis up with a suspension spring and push it along the
+// ground with the engine, brakes, and tyre grip. Wheels are not bodies
+// so they don't collide with anything.
+
+import (
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Vehicle moves a chassis body using wheel forces. The app sets the
+// engine, brake, and steering each update before calling Update.
+// The chassis forward direction is -Z and up is +Y.
+type Vehicle struct {
+	Engine float64 // drive force in newtons, shared by the drive wheels. Negative reverses.
+	Brake  float64 // brake force in newtons for each brake wheel.
+	Steer  float64 // steering wheel angle in radians. Positive turns left.
+
+	wheels []Wheel
+	state  []vehicle_Wheel
+	speed  float64 // forward speed after the last update.
+}
+
+// Wheel describes a vehicle wheel and its suspension. Zero values
+// for the stiffness, damping, and grip are replaced with defaults
+// by NewVehicle.
+type Wheel struct {
+	Mount     lin.V3  // top of the suspension relative to the chassis center.
+	Radius    float64 // wheel radius.
+	Rest      float64 // suspension length with no load.
+	Stiffness float64 // suspension spring newtons per meter of compression.
+	Damping   float64 // suspension newtons per meter per second.
+	Grip      float64 // tyre friction coefficient.
+	Drive     bool    // true if the engine turns the wheel.
+	Steer     bool    // true if the wheel steers.
+	Brake     bool    // true if the wheel brakes.
+}
+
+// vehicle_Wheel is the wheel state from the last update.
+type vehicle_Wheel struct {
+	grounded bool    // true if the wheel touched the ground.
+	length   float64 // suspension length.
+	steer    float64 // steering angle.
+	spin     float64 // rotation around the axle.
+	rate     float64 // spin speed in radians per second.
+	point    lin.V3  // ground contact.
+	normal   lin.V3  // ground normal.
+}
+
+// vehicle_ROLL is how much of the tyre force tips the chassis. Tyre
+// forces are applied closer to the chassis center height so that
+// vehicles lean in corners without rolling over.
+const vehicle_ROLL = 0.2
+
+// NewVehicle returns a vehicle for the chassis body with the given
+// wheels. The default suspension holds the chassis with the springs
+// half compressed and is damped so that it does not bounce.
+func NewVehicle(chassis *Body, wheels ...Wheel) *Vehicle {
+	v := &Vehicle{wheels: append([]Wheel{}, wheels...), state: make([]vehicle_Wheel, len(wheels))}
+	if chassis == nil || chassis.inverse_mass == 0 || len(wheels) == 0 {
+		return v
+	}
+	share := 1 / (chassis.inverse_mass * float64(len(wheels))) // chassis mass on each wheel.
+	for i := range v.wheels {
+		w := &v.wheels[i]
+		if w.Stiffness <= 0 && w.Rest > 0 {
+			w.Stiffness = share * vehicle_GRAVITY / (w.Rest * 0.5)
+		}
+		if w.Damping <= 0 {
+			w.Damping = 2 * 0.5 * math.Sqrt(w.Stiffness*share) // half critical damping.
+		}
+		if w.Grip <= 0 {
+			w.Grip = 1
+		}
+		v.state[i].length = w.Rest
+	}
+	return v
+}
+
+// vehicle_GRAVITY matches the gravity of the rigid body simulation.
+const vehicle_GRAVITY = 10.0
+
+// Wheels returns the number of wheels.
+func (v *Vehicle) Wheels() int { return len(v.wheels) }
+
+// Speed returns the chassis forward speed from the last update.
+// Negative speeds are reversing.
+func (v *Vehicle) Speed() float64 { return v.speed }
+
+// Grounded returns true if wheel i touched the ground in the last update.
+func (v *Vehicle) Grounded(i int) bool { return i >= 0 && i < len(v.state) && v.state[i].grounded }
+
+// WheelPose returns the world location of the center of wheel i and
+// its rotation, eg: to draw a wheel model. The wheel axle is along
+// the chassis x axis.
+func (v *Vehicle) WheelPose(chassis *Body, i int) (at lin.V3, rot lin.Q) {
+	if i < 0 || i >= len(v.wheels) {
+		return at, *lin.NewQI()
+	}
+	w, s := &v.wheels[i], &v.state[i]
+	at = w.Mount
+	at.Y -= s.length
+	at.MultQ(&at, &chassis.world_rotation).Add(&at, &chassis.world_position)
+	spin := lin.NewQ().SetAa(1, 0, 0, s.spin)
+	steer := lin.NewQ().SetAa(0, 1, 0, s.steer)
+	rot.Mult(spin, steer).Mult(&rot, &chassis.world_rotation)
+	return at, rot
+}
+
+// Update casts the wheel rays and adds the wheel forces to the chassis.
+// The wheels don't touch the chassis or trigger bodies. The chassis may
+// be one of the bodies. Expected to be called before each Simulate
+// with the Simulate timestep.
+func (v *Vehicle) Update(chassis *Body, bods []Body, timestep float64) {
+	if chassis.fixed || len(chassis.colliders) == 0 || timestep <= 0 {
+		return
+	}
+	if v.Engine != 0 {
+		chassis.Activate()
+	}
+	rot := &chassis.world_rotation
+	up := lin.NewV3().MultQ(&lin.V3{Y: 1}, rot)
+	down := lin.NewV3().Neg(up)
+	forward := lin.NewV3().MultQ(&lin.V3{Z: -1}, rot)
+	v.speed = chassis.linear_velocity.Dot(forward)
+
+	// find the ground under each wheel.
+	ignore := func(b *Body) bool { return b.trigger || &b.colliders[0] == &chassis.colliders[0] }
+	grounded, drives := 0, 0
+	for i := range v.wheels {
+		w, s := &v.wheels[i], &v.state[i]
+		mount := lin.NewV3().MultQ(&w.Mount, rot)
+		mount.Add(mount, &chassis.world_position)
+		hit, ok := raycast(bods, *mount, *down, w.Rest+w.Radius, ignore)
+		s.grounded, s.length = ok, w.Rest
+		if ok {
+			s.length = lin.Clamp(hit.Distance-w.Radius, 0, w.Rest)
+			s.point, s.normal = hit.Point, hit.Normal
+			grounded++
+			if w.Drive {
+				drives++
+			}
+		}
+	}
+
+	// push the chassis with the wheels that are on the ground.
+	share := 0.0 // chassis mass on each grounded wheel.
+	if grounded > 0 {
+		share = 1 / (chassis.inverse_mass * float64(grounded))
+	}
+	for i := range v.wheels {
+		w, s := &v.wheels[i], &v.state[i]
+		s.steer = 0
+		if w.Steer {
+			s.steer = v.Steer
+		}
+		if !s.grounded {
+			s.spin = math.Mod(s.spin+s.rate*timestep, 2*math.Pi)
+			continue
+		}
+
+		// suspension spring pushes up at the wheel mount.
+		contact := lin.NewV3().Sub(&s.point, &chassis.world_position)
+		velocity := lin.NewV3().Cross(&chassis.angular_velocity, contact)
+		velocity.Add(velocity, &chassis.linear_velocity)
+		suspension := w.Stiffness*(w.Rest-s.length) - w.Damping*velocity.Dot(up)
+		suspension = max(suspension, 0)
+		mount := lin.NewV3().MultQ(&w.Mount, rot)
+		chassis.AddForce(*mount, *lin.NewV3().Scale(up, suspension), false)
+
+		// tyre forces along the ground in the wheel direction.
+		heading := lin.NewV3().MultQ(forward, lin.NewQ().SetAa(up.X, up.Y, up.Z, s.steer))
+		heading.Sub(heading, lin.NewV3().Scale(&s.normal, s.normal.Dot(heading)))
+		if heading.Len() < lin.Epsilon {
+			continue // wheel is on a wall.
+		}
+		heading.Unit()
+		side := lin.NewV3().Cross(heading, &s.normal).Unit()
+		along, across := velocity.Dot(heading), velocity.Dot(side)
+		traction := 0.0
+		if w.Drive && drives > 0 {
+			traction += v.Engine / float64(drives)
+		}
+		if w.Brake && v.Brake > 0 {
+			stop := math.Abs(along) * share / timestep // force that stops the wheel this step.
+			traction -= math.Copysign(min(v.Brake, stop), along)
+		}
+		slide := -across * share / timestep // force that stops the sideways slide.
+		if total, limit := math.Hypot(traction, slide), w.Grip*suspension; total > limit {
+			traction, slide = traction*limit/total, slide*limit/total
+		}
+		force := lin.NewV3().Scale(heading, traction)
+		force.Add(force, lin.NewV3().Scale(side, slide))
+		contact.Sub(contact, lin.NewV3().Scale(up, up.Dot(contact)*(1-vehicle_ROLL)))
+		chassis.AddForce(*contact, *force, false)
+		s.rate = -along / w.Radius // forward is -Z so the wheel top turns towards -Z.
+		s.spin = math.Mod(s.spin+s.rate*timestep, 2*math.Pi)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// pick.go finds the 3D model part under the mouse, eg:
+//
+//	if part, x, y, z, ok := eng.Pick(scene, in.Mx, in.My); ok {
+//		selected = part // clicked on part at world location x,y,z.
+//	}
+//
+// The mouse location is turned into a ray from the scene camera. Parts
+// whose bounds are hit by the ray are found using the spatial index,
+// then the ray is checked against the part mesh triangles so that parts
+// are only picked where the mesh is drawn. The triangle checks are done
+// on the CPU, so no extra render pass is needed.
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// Pick returns the 3D scene model part drawn at the window pixel mx,my,
+// where 0,0 is the top left of the window showing the scene, eg: the
+// Input.Mx,My mouse location. The camera of the scene view containing
+// the pixel is used, see AddView. The world location where the part
+// mesh is hit is also returned. Returns false if no part is under the
+// pixel or the scene has not yet been drawn. Parts without bounds,
+// culled parts, and parts whose mesh has no triangles are not picked.
+// Loaded meshes keep their triangles with the MeshTriangles attribute.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (eng *Engine) Pick(scene *Entity, mx, my int32) (part *Entity, x, y, z float64, ok bool) {
+	sc := eng.app.scenes.get(scene.eid)
+	if sc == nil || sc.pid != render.Pass3D {
+		slog.Error("Pick needs 3D scene", "eid", scene.eid)
+		return nil, 0, 0, 0, false
+	}
+	view := sc.viewAt(mx, my)
+	origin, dir, ok := view.cam.pickRay(mx-view.vx, my-view.vy)
+	if !ok {
+		return nil, 0, 0, 0, false
+	}
+	eid, distance, ok := eng.app.spatial.pickMesh(eng.app, scene.eid, origin, dir)
+	if !ok {
+		return nil, 0, 0, 0, false
+	}
+	x, y, z = origin.X+dir.X*distance, origin.Y+dir.Y*distance, origin.Z+dir.Z*distance
+	return &Entity{app: eng.app, eid: eid}, x, y, z, true
+}
+
+// pickRay returns the world space ray through the window pixel mx,my.
+// The ray starts on the near plane and points to the far plane so that
+// perspective, orthographic, and custom projections are all handled.
+// The ray direction is a unit vector. Returns false if the pixel is
+// outside the window or the camera window size is not yet known.
+func (c *Camera) pickRay(mx, my int32) (origin, dir lin.V3, ok bool) {
+	ww, wh := int(c.ww), int(c.wh)
+	nx, ny, nz, err := c.Unproject(int(mx), int(my), ww, wh, 0)
+	if err != nil {
+		return origin, dir, false
+	}
+	fx, fy, fz, err := c.Unproject(int(mx), int(my), ww, wh, 1)
+	if err != nil {
+		return origin, dir, false
+	}
+	dir = lin.V3{X: fx - nx, Y: fy - ny, Z: fz - nz}
+	if lin.AeqZ(dir.Len()) {
+		return origin, dir, false
+	}
+	dir.Unit()
+	return lin.V3{X: nx, Y: ny, Z: nz}, dir, true
+}
+
+// pickMesh returns the closest scene part whose mesh triangles are hit
+// by the ray. The part bounds are checked before the mesh triangles.
+func (sp *spatial) pickMesh(app *application, scene eID, origin, dir lin.V3) (eid eID, distance float64, ok bool) {
+	sp.update(app)
+	eid, distance, _, ok = sp.castRay(app, scene, origin, dir, math.Inf(1))
+	return eid, distance, ok
+}
+
+// castRay returns the closest scene part and mesh triangle hit by the ray
+// closer than limit. The face is the index of the first triangle vertex
+// in the part mesh faces. Expects the spatial index to be up to date.
+func (sp *spatial) castRay(app *application, scene eID, origin, dir lin.V3, limit float64) (eid eID, distance float64, face int, ok bool) {
+	best := limit
+	sp.tree.query(func(lo, hi *lin.V3) bool {
+		t, hit := rayBox(&origin, &dir, lo, hi)
+		return hit && t < best
+	}, func(n *bvhNode) {
+		if n.scene != scene || culled(app.povs, n.eid) {
+			return
+		}
+		if t, hit := rayBox(&origin, &dir, &n.blo, &n.bhi); !hit || t >= best {
+			return
+		}
+		m, p := app.models.get(n.eid), app.povs.get(n.eid)
+		if m == nil || p == nil || m.mesh == nil {
+			return
+		}
+		if t, f, hit := rayMesh(&origin, &dir, m.mesh, p.wm, best); hit {
+			best, eid, face, ok = t, n.eid, f, true
+		}
+	})
+	return eid, best, face, ok
+}
+
+// rayMesh returns the distance along the ray to the closest mesh
+// triangle closer than limit, and the index of the triangle face.
+// The mesh vertexes are moved to world space using the world matrix wm.
+func rayMesh(origin, dir *lin.V3, msh *mesh, wm *lin.M4, limit float64) (t float64, face int, hit bool) {
+	t = limit
+	for i := 0; i+2 < len(msh.faces); i += 3 {
+		a := worldPoint(&msh.verts[msh.faces[i]], wm)
+		b := worldPoint(&msh.verts[msh.faces[i+1]], wm)
+		c := worldPoint(&msh.verts[msh.faces[i+2]], wm)
+		if d, ok := rayTriangle(origin, dir, &a, &b, &c); ok && d < t {
+			t, face, hit = d, i, true
+		}
+	}
+	return t, face, hit
+}
+
+// rayTriangle returns the distance along the ray to where it hits
+// triangle a,b,c. Both sides of the triangle are hit.
+// Based on "Fast, Minimum Storage Ray/Triangle Intersection",
+// Möller and Trumbore, 1997.
+func rayTriangle(origin, dir, a, b, c *lin.V3) (t float64, hit bool) {
+	e1 := lin.NewV3().Sub(b, a)
+	e2 := lin.NewV3().Sub(c, a)
+	p := lin.NewV3().Cross(dir, e2)
+	det := e1.Dot(p)
+	if math.Abs(det) < lin.Epsilon {
+		return 0, false // ray is parallel to the triangle.
+	}
+	inv := 1 / det
+	s := lin.NewV3().Sub(origin, a)
+	u := s.Dot(p) * inv
+	if u < 0 || u > 1 {
+		return 0, false
+	}
+	q := s.Cross(s, e1)
+	v := dir.Dot(q) * inv
+	if v < 0 || u+v > 1 {
+		return 0, false
+	}
+	if t = e2.Dot(q) * inv; t < 0 {
+		return 0, false // triangle is behind the ray.
+	}
+	return t, true
+}
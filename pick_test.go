@@ -0,0 +1,86 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Pick
+func TestPick(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	eng := &Engine{app: app}
+	scene := app.addScene(Scene3D)
+	sc := app.scenes.get(scene.eid)
+	sc.setProjection(800, 600)
+	sc.cam.updateView() // at the origin looking down -Z.
+
+	near := scene.AddModel("msh:cube").SetAt(0, 0, -10)
+	far := scene.AddModel("msh:cube").SetAt(0, 0, -20).SetScale(4, 4, 4)
+
+	// go test -run Pick/center
+	t.Run("center", func(t *testing.T) {
+		part, x, y, z, ok := eng.Pick(scene, 400, 300)
+		if !ok || part.eid != near.eid {
+			t.Fatalf("expected near part got %v %t", part, ok)
+		}
+		if !lin.Aeq(x, 0) || !lin.Aeq(y, 0) || !lin.Aeq(z, -9.5) {
+			t.Errorf("expected hit on cube face got %f %f %f", x, y, z)
+		}
+	})
+
+	// go test -run Pick/behind
+	t.Run("behind", func(t *testing.T) {
+		near.Cull(true)
+		part, _, _, z, ok := eng.Pick(scene, 400, 300)
+		if !ok || part.eid != far.eid || !lin.Aeq(z, -18) {
+			t.Errorf("expected far part got %v %f %t", part, z, ok)
+		}
+		near.Cull(false)
+	})
+
+	// go test -run Pick/miss
+	t.Run("miss", func(t *testing.T) {
+		if _, _, _, _, ok := eng.Pick(scene, 10, 10); ok {
+			t.Errorf("expected miss in corner")
+		}
+		if _, _, _, _, ok := eng.Pick(scene, -1, 300); ok {
+			t.Errorf("expected miss outside window")
+		}
+	})
+
+	// go test -run Pick/triangle
+	t.Run("triangle", func(t *testing.T) {
+		a, b, c := lin.V3{X: -1, Y: -1}, lin.V3{X: 1, Y: -1}, lin.V3{Y: 1}
+		origin, dir := lin.V3{Z: 5}, lin.V3{Z: -1}
+		if d, ok := rayTriangle(&origin, &dir, &a, &b, &c); !ok || !lin.Aeq(d, 5) {
+			t.Errorf("expected hit got %f %t", d, ok)
+		}
+		origin.X = 2
+		if _, ok := rayTriangle(&origin, &dir, &a, &b, &c); ok {
+			t.Errorf("expected miss")
+		}
+	})
+
+	// go test -run Pick/indexes
+	t.Run("indexes", func(t *testing.T) {
+		md := make(load.MeshData, load.VertexTypes)
+		md[load.Vertexes] = load.F32Buffer([]float32{-1, -1, 0, 1, -1, 0, 0, 1, 0}, 3)
+		md[load.Indexes] = load.U32Buffer([]uint32{0, 1, 2}, 1)
+		origin, dir := lin.V3{Z: 5}, lin.V3{Z: -1}
+		msh := &mesh{}
+		msh.setBounds(md, false)
+		if _, _, ok := rayMesh(&origin, &dir, msh, lin.NewM4I(), 100); ok || !msh.bounded {
+			t.Errorf("expected bounds without triangles")
+		}
+		msh.setBounds(md, true)
+		if d, _, ok := rayMesh(&origin, &dir, msh, lin.NewM4I(), 100); !ok || !lin.Aeq(d, 5) {
+			t.Errorf("expected hit on 32-bit index mesh got %f %t", d, ok)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// players.go routes user input to local players for couch co-op games.
+// Each player slot is assigned an input device and the bindings from
+// game actions to keys or gamepad buttons, eg:
+//
+//	binds := vu.Bindings{
+//		Keys:    map[string][]int32{"jump": {vu.KSpace}, "fire": {vu.KML}},
+//		Buttons: map[string]uint16{"jump": vu.PadA, "fire": vu.PadRB},
+//	}
+//	eng.AssignPlayer(0, vu.KeyboardMouse, binds)
+//	eng.AssignPlayer(1, vu.Gamepad1, binds)
+//	...
+//	if p := eng.Player(1); p != nil && p.Pressed("jump") {
+//		// player 2 jumped.
+//	}
+//
+// Players can share the keyboard using different key bindings, eg:
+// WASD for one player and the arrow keys for the other. A gamepad
+// is only assigned to one player. Games can use JoinDevice to let
+// players join by pressing a button on an unassigned device.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/device"
+)
+
+// MaxPlayers is the number of local player slots.
+const MaxPlayers = 8
+
+// InputDevice identifies a source of player input.
+type InputDevice int
+
+// Player input devices.
+const (
+	KeyboardMouse InputDevice = iota // the keyboard and mouse.
+	Gamepad1                         // first gamepad, Input.Pads[0].
+	Gamepad2                         // second gamepad, Input.Pads[1].
+	Gamepad3                         // third gamepad, Input.Pads[2].
+	Gamepad4                         // fourth gamepad, Input.Pads[3].
+)
+
+// AssignPlayer routes input from the given device to the given player
+// slot using the given action bindings, replacing any existing player
+// in the slot. The player gets a copy of the bindings so that players
+// can be rebound separately, see Player.Bindings. Returns nil if the
+// slot is not valid or if the gamepad is already assigned to a
+// different player.
+func (eng *Engine) AssignPlayer(slot int, dev InputDevice, binds Bindings) *Player {
+	if slot < 0 || slot >= MaxPlayers || dev < KeyboardMouse || dev > Gamepad4 {
+		slog.Error("AssignPlayer invalid slot or device", "slot", slot, "device", dev)
+		return nil
+	}
+	for _, p := range eng.app.players {
+		if p != nil && p.dev != KeyboardMouse && p.dev == dev && p.slot != slot {
+			slog.Error("AssignPlayer gamepad already assigned", "slot", slot, "player", p.slot)
+			return nil
+		}
+	}
+	if len(eng.app.players) == 0 {
+		eng.app.players = make([]*Player, MaxPlayers)
+	}
+	p := &Player{slot: slot, dev: dev, binds: binds.Clone(), in: eng.app.input, hw: eng.dev}
+	eng.app.players[slot] = p
+	return p
+}
+
+// Rumble sets the vibration of the gamepad, Input.Pads index, where
+// low and high are the speeds, 0 to 1, of the low and high frequency
+// motors. Vibration continues until it is set to 0.
+func (eng *Engine) Rumble(pad int, low, high float64) {
+	eng.dev.Rumble(pad, low, high)
+}
+
+// SetPadDeadZone sets the fraction, 0 to 0.9, of the gamepad thumb
+// stick range around the center that is reported as 0. Default 0.24.
+func (eng *Engine) SetPadDeadZone(zone float64) {
+	eng.dev.SetPadDeadZone(zone)
+}
+
+// Player returns the player assigned to the given slot,
+// or nil if there is no player in the slot.
+func (eng *Engine) Player(slot int) *Player {
+	if slot < 0 || slot >= len(eng.app.players) {
+		return nil
+	}
+	return eng.app.players[slot]
+}
+
+// RemovePlayer frees the given player slot and its input device.
+func (eng *Engine) RemovePlayer(slot int) {
+	if slot >= 0 && slot < len(eng.app.players) {
+		eng.app.players[slot] = nil
+	}
+}
+
+// JoinDevice returns an input device that is not assigned to a player
+// and had a key or button pressed since the last update. Used to let
+// players join a game, eg: "press A to join".
+func (eng *Engine) JoinDevice() (dev InputDevice, ok bool) {
+	in := eng.app.input
+	assigned := [Gamepad4 + 1]bool{}
+	for _, p := range eng.app.players {
+		if p != nil {
+			assigned[p.dev] = true
+		}
+	}
+	if !assigned[KeyboardMouse] && len(in.Pressed) > 0 {
+		return KeyboardMouse, true
+	}
+	for i := range in.Pads {
+		pad := &in.Pads[i]
+		dev := Gamepad1 + InputDevice(i)
+		if !assigned[dev] && pad.Connected && pad.Buttons&^pad.Last != 0 {
+			return dev, true
+		}
+	}
+	return KeyboardMouse, false
+}
+
+// =============================================================================
+
+// Player is the input state for one local player. Action states are
+// read from the latest user input each time they are checked.
+type Player struct {
+	slot  int            // player slot.
+	dev   InputDevice    // assigned input device.
+	binds Bindings       // action bindings.
+	in    *Input         // engine input, refreshed each update.
+	hw    *device.Device // gamepad vibration, nil when testing.
+}
+
+// Slot returns the player slot.
+func (p *Player) Slot() int { return p.slot }
+
+// Device returns the input device assigned to the player.
+func (p *Player) Device() InputDevice { return p.dev }
+
+// Connected returns false if the player gamepad is unplugged.
+// Keyboard players are always connected.
+func (p *Player) Connected() bool {
+	if pad := p.pad(); pad != nil {
+		return pad.Connected
+	}
+	return true
+}
+
+// Pressed returns true if the action was triggered since the last update.
+func (p *Player) Pressed(action string) bool {
+	if pad := p.pad(); pad != nil {
+		return pad.Pressed(p.binds.Buttons[action])
+	}
+	for _, key := range p.binds.Keys[action] {
+		if p.in.Pressed[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Down returns true while the action is held down.
+func (p *Player) Down(action string) bool {
+	if pad := p.pad(); pad != nil {
+		return pad.Down(p.binds.Buttons[action])
+	}
+	for _, key := range p.binds.Keys[action] {
+		if _, ok := p.in.Down[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Released returns true if the action was released since the last update.
+func (p *Player) Released(action string) bool {
+	if pad := p.pad(); pad != nil {
+		return pad.Released(p.binds.Buttons[action])
+	}
+	for _, key := range p.binds.Keys[action] {
+		if _, ok := p.in.Released[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Axis returns -1 when the negative action is down, 1 when the positive
+// action is down, and 0 when neither or both are down, eg:
+//
+//	x := p.Axis("left", "right")
+func (p *Player) Axis(negative, positive string) float64 {
+	v := 0.0
+	if p.Down(negative) {
+		v -= 1
+	}
+	if p.Down(positive) {
+		v += 1
+	}
+	return v
+}
+
+// Pad returns the state of the player gamepad. Keyboard players
+// return a disconnected gamepad with no buttons down.
+func (p *Player) Pad() device.Pad {
+	if pad := p.pad(); pad != nil {
+		return *pad
+	}
+	return device.Pad{}
+}
+
+// Rumble vibrates the player gamepad, see Engine.Rumble.
+// Ignored for keyboard players.
+func (p *Player) Rumble(low, high float64) {
+	if p.pad() != nil && p.hw != nil {
+		p.hw.Rumble(int(p.dev-Gamepad1), low, high)
+	}
+}
+
+// pad returns the player gamepad, nil for keyboard players.
+func (p *Player) pad() *device.Pad {
+	if p.dev < Gamepad1 || p.dev > Gamepad4 {
+		return nil
+	}
+	return &p.in.Pads[p.dev-Gamepad1]
+}
@@ -0,0 +1,80 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Players
+func TestPlayers(t *testing.T) {
+	binds := Bindings{
+		Keys:    map[string][]int32{"jump": {KSpace}, "left": {KA}, "right": {KD}},
+		Buttons: map[string]uint16{"jump": PadA, "left": PadLeft, "right": PadRight},
+	}
+	arrows := Bindings{Keys: map[string][]int32{"jump": {KRet}, "left": {KALeft}, "right": {KARight}}}
+
+	t.Run("assign", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		defer eng.app.ld.dispose()
+		if eng.AssignPlayer(0, KeyboardMouse, binds) == nil || eng.AssignPlayer(1, KeyboardMouse, arrows) == nil {
+			t.Fatalf("expected players to share the keyboard")
+		}
+		if eng.AssignPlayer(2, Gamepad1, binds) == nil {
+			t.Fatalf("expected gamepad player")
+		}
+		if eng.AssignPlayer(3, Gamepad1, binds) != nil {
+			t.Errorf("expected gamepad to be assigned once")
+		}
+		if eng.AssignPlayer(MaxPlayers, Gamepad2, binds) != nil {
+			t.Errorf("expected invalid slot")
+		}
+		eng.RemovePlayer(2)
+		if eng.Player(2) != nil || eng.AssignPlayer(3, Gamepad1, binds) == nil {
+			t.Errorf("expected removed player to free the gamepad")
+		}
+	})
+
+	t.Run("routing", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		defer eng.app.ld.dispose()
+		wasd := eng.AssignPlayer(0, KeyboardMouse, binds)
+		keys := eng.AssignPlayer(1, KeyboardMouse, arrows)
+		pad := eng.AssignPlayer(2, Gamepad2, binds)
+
+		in := eng.app.input
+		in.Pressed[KSpace] = true
+		in.Down[KSpace] = time.Now()
+		in.Down[KARight] = time.Now()
+		in.Pads[1].Connected = true
+		in.Pads[1].Buttons, in.Pads[1].Last = PadLeft, PadA
+		if !wasd.Pressed("jump") || !wasd.Down("jump") || keys.Pressed("jump") {
+			t.Errorf("expected only the first keyboard player to jump")
+		}
+		if keys.Axis("left", "right") != 1 || wasd.Axis("left", "right") != 0 {
+			t.Errorf("expected only the second keyboard player to move right")
+		}
+		if pad.Pressed("jump") || !pad.Released("jump") || pad.Axis("left", "right") != -1 {
+			t.Errorf("expected gamepad player to release jump and move left")
+		}
+		if !pad.Connected() || !wasd.Connected() {
+			t.Errorf("expected connected players")
+		}
+	})
+
+	t.Run("join", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		defer eng.app.ld.dispose()
+		eng.AssignPlayer(0, KeyboardMouse, binds)
+		in := eng.app.input
+		in.Pressed[KSpace] = true
+		if _, ok := eng.JoinDevice(); ok {
+			t.Errorf("expected no join from an assigned keyboard")
+		}
+		in.Pads[2].Connected, in.Pads[2].Buttons = true, PadStart
+		if dev, ok := eng.JoinDevice(); !ok || dev != Gamepad3 {
+			t.Errorf("expected gamepad 3 to join, got %d %t", dev, ok)
+		}
+	})
+}
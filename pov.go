@@ -7,6 +7,7 @@ package vu
 
 import (
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gazed/vu/math/lin"
@@ -237,12 +238,15 @@ type pov struct {
 
 	// Local transform is relative to a parent.
 	// World transform combine parent transform.
-	tp, tn *lin.T  // Local transform (prev, now).
-	sp, sn *lin.V3 // Per axis scale (prev, now): default value 1,1,1.
-	tw     *lin.T  // World transform. Updated on any change.
-	sw     *lin.V3 // World scale. Updated on any change.
-	mm, wm *lin.M4 // render model matrix, world matrix.
-	stable bool    // avoid updating non-moving objects.
+	tp, tn  *lin.T  // Local transform (prev, now).
+	sp, sn  *lin.V3 // Per axis scale (prev, now): default value 1,1,1.
+	tw      *lin.T  // World transform. Updated on any change.
+	sw      *lin.V3 // World scale. Updated on any change.
+	mm, wm  *lin.M4 // render model matrix, world matrix.
+	stable  bool    // avoid updating non-moving objects.
+	tagged  bool    // report moves to the tag spatial grid.
+	indexed bool    // report moves to the spatial index.
+	lerped  bool    // render model matrix is interpolated this frame.
 }
 
 // newPov allocates and initialzes a point of view transform.
@@ -307,11 +311,21 @@ type povs struct {
 	eids  []eID          // ...and associated entity identifiers.
 	nodes []node         // Scene graph parent-child data.
 
+	// tagMoves are tagged entities that moved since the last tag update.
+	tagMoves []eID
+
+	// indexMoves are model entities that moved since the last
+	// spatial index update.
+	indexMoves []eID
+
+	// roots are the moved povs whose subtrees are updated
+	// in parallel, see updateWorlds. Reused each update.
+	roots  []eID
+	moving map[eID]bool
+
 	// Scratch for per update tick calculations.
 	rot *lin.Q  // scratch rotation/orientation.
 	v4  *lin.V4 // scratch vector location.
-	v3  *lin.V3 // scratch vector location.
-	m3  *lin.M3 // scratch rotation matrix.
 }
 
 // newPovs creates a manager for a group of Pov data.
@@ -322,13 +336,12 @@ func newPovs() *povs {
 	ps.eids = []eID{}
 	ps.index = map[eID]uint32{}
 	ps.nodes = []node{}
+	ps.moving = map[eID]bool{}
 
 	// allocate scratch variables. These are used each update when
 	// updating world positions and rotations.
 	ps.rot = lin.NewQ()
 	ps.v4 = &lin.V4{}
-	ps.v3 = &lin.V3{}
-	ps.m3 = &lin.M3{}
 	return ps
 }
 
@@ -434,14 +447,46 @@ func (ps *povs) setPrev(moved []eID) []eID {
 
 // setWorldMatrix sets the local world render matrix.
 // Called once per render to set the pov.mm model matrix used for rendering.
-func (ps *povs) setWorldMatrix(delta time.Duration) {
-	for index := 0; index < len(ps.povs); index++ {
-		p := &ps.povs[index]
+func (ps *povs) setWorldMatrix(w *workers, delta time.Duration) {
+	w.run(len(ps.povs), 1024, func(start, end int) {
+		for index := start; index < end; index++ {
+			p := &ps.povs[index]
+
+			// Use the latest transform updated by updateWorld.
+			// Physics bodies are interpolated afterwards.
+			p.mm.Set(p.wm) // copied on first render.
+			p.lerped = false
+		}
+	})
+}
 
-		// FUTURE: interpolate here; or use some other smooth render option.
+// setModelMatrix sets the render model matrix for the pov at the given
+// index using the given local location and rotation instead of the pov
+// transform. Used to render interpolated physics bodies.
+func (ps *povs) setModelMatrix(index uint32, loc *lin.V3, rot *lin.Q) {
+	p := &ps.povs[index]
+	ps.rot.Set(rot)
+	p.mm.SetQ(ps.rot.Inv(ps.rot))         // invert model rotation.
+	p.mm.ScaleSM(p.sn.X, p.sn.Y, p.sn.Z)  // scale is applied first.
+	p.mm.TranslateMT(loc.X, loc.Y, loc.Z) // translation applied last.
+	if pindex, ok := ps.index[ps.nodes[index].parent]; ok {
+		p.mm.Mult(p.mm, ps.povs[pindex].mm) // parent render transform.
+	}
+	p.lerped = true
+}
 
-		// Use the latest transform updated by updateWorld.
-		p.mm.Set(p.wm) // copied on first render.
+// setLerpedChildren updates the render model matrix of the children
+// of interpolated povs so that they move with their parents. Parent
+// povs are earlier in the dense array than their children.
+func (ps *povs) setLerpedChildren() {
+	for index := range ps.povs {
+		p := &ps.povs[index]
+		if p.lerped {
+			continue
+		}
+		if pindex, ok := ps.index[ps.nodes[index].parent]; ok && ps.povs[pindex].lerped {
+			ps.setModelMatrix(uint32(index), p.tn.Loc, p.tn.Rot)
+		}
 	}
 }
 
@@ -450,56 +495,125 @@ func (ps *povs) setWorldMatrix(delta time.Duration) {
 // Expected to be called for each object update to immediately refresh the
 // world transform values.
 func (ps *povs) updateWorld(p *pov, eid eID) {
-	rot := ps.rot
-	if index, ok := ps.index[eid]; ok {
-		p.stable = false              // object has changed.
-		sx, sy, sz := p.sn.GetS()     // scale
-		lx, ly, lz := p.tn.Loc.GetS() // position
-		rot.Set(p.tn.Rot)             // orientation.
-
-		// Update the model transform matrix the world space coordinates.
-		p.wm.SetQ(rot.Inv(rot))      // invert model rotation.
-		p.wm.ScaleSM(sx, sy, sz)     // scale is applied first: left of rotation.
-		p.wm.TranslateMT(lx, ly, lz) // translation applied last: right of rotation.
-
-		// Combine with parent transform. The world transform of a child is
-		// relative to its parent. Parent's model matrix has already been set
-		// because parent pov's appear earlier in ps.data than their children.
-		node := &ps.nodes[index]
-		if node.parent != 0 {
-			if pindex, ok := ps.index[node.parent]; ok {
-				parent := &ps.povs[pindex] // use ref, not copy.
-				p.wm.Mult(p.wm, parent.wm) // model + parent transform
-			} else {
-				slog.Error("scene graph missing child", "entity", node.parent) // dev error.
-			}
+	ps.tagMoves, ps.indexMoves = ps.propagate(p, eid, ps.tagMoves, ps.indexMoves)
+}
+
+// updateWorlds sets the world locations for the given povs after their
+// local transforms have changed, eg: after a physics step. Povs whose
+// parent is also being updated are covered by their parent. The remaining
+// povs root independent subtrees that are updated on the workers.
+func (ps *povs) updateWorlds(w *workers, eids []eID) {
+	clear(ps.moving)
+	for _, eid := range eids {
+		ps.moving[eid] = false
+	}
+	ps.roots = ps.roots[:0]
+	for _, eid := range eids {
+		if added, ok := ps.moving[eid]; !ok || added || ps.parentMoving(eid) {
+			continue // duplicate or updated with its parent.
 		}
+		ps.moving[eid] = true
+		ps.roots = append(ps.roots, eid)
+	}
 
-		// Track absolute world transform values.
-		// See https://math.stackexchange.com/questions/237369/ and
-		// note the limitations when using uneven or negative scales.
-		m := p.wm
-		p.tw.Loc.SetS(m.Wx, m.Wy, m.Wz) // world space position.
-		sx = ps.v3.SetS(m.Xx, m.Xy, p.wm.Xz).Len()
-		sy = ps.v3.SetS(m.Yx, m.Yy, p.wm.Yz).Len()
-		sz = ps.v3.SetS(m.Zx, m.Zy, p.wm.Zz).Len()
-		p.sw.SetS(sx, sy, sz) // world scale
-		ps.m3.SetS(
-			m.Xx/sx, m.Xy/sx, p.wm.Xz/sx,
-			m.Yx/sy, m.Yy/sy, p.wm.Yz/sy,
-			m.Zx/sz, m.Zy/sz, p.wm.Zz/sz)
-		p.tw.Rot.SetM3(ps.m3)  // world rotation.
-		p.tw.Rot.Inv(p.tw.Rot) // Undo model matrix invert.
-
-		// Child nodes must also be updated.
-		for _, kid := range node.kids {
-			if index, ok := ps.index[kid]; ok {
-				ps.updateWorld(&ps.povs[index], kid)
-			} else {
-				slog.Error("Scene graph missing child.") // dev error.
+	// each range collects its moves and merges them when done.
+	var merge sync.Mutex
+	w.run(len(ps.roots), 256, func(start, end int) {
+		var tagMoves, indexMoves []eID
+		for _, eid := range ps.roots[start:end] {
+			if index, ok := ps.index[eid]; ok {
+				tagMoves, indexMoves = ps.propagate(&ps.povs[index], eid, tagMoves, indexMoves)
 			}
 		}
+		if len(tagMoves) > 0 || len(indexMoves) > 0 {
+			merge.Lock()
+			ps.tagMoves = append(ps.tagMoves, tagMoves...)
+			ps.indexMoves = append(ps.indexMoves, indexMoves...)
+			merge.Unlock()
+		}
+	})
+}
+
+// parentMoving returns true if any of the given pov parents
+// are being updated by updateWorlds.
+func (ps *povs) parentMoving(eid eID) bool {
+	index, ok := ps.index[eid]
+	for ok {
+		parent := ps.nodes[index].parent
+		if _, moving := ps.moving[parent]; moving {
+			return true
+		}
+		index, ok = ps.index[parent]
+	}
+	return false
+}
+
+// propagate sets the world transform for the given pov and its children,
+// returning the given moves with the moved tagged and spatially indexed
+// entities. Only the pov subtree is changed so that independent subtrees
+// can be updated in parallel.
+func (ps *povs) propagate(p *pov, eid eID, tagMoves, indexMoves []eID) ([]eID, []eID) {
+	index, ok := ps.index[eid]
+	if !ok {
+		return tagMoves, indexMoves
+	}
+	var rot lin.Q
+	p.stable = false              // object has changed.
+	sx, sy, sz := p.sn.GetS()     // scale
+	lx, ly, lz := p.tn.Loc.GetS() // position
+	rot.Set(p.tn.Rot)             // orientation.
+
+	// Update the model transform matrix the world space coordinates.
+	p.wm.SetQ(rot.Inv(&rot))     // invert model rotation.
+	p.wm.ScaleSM(sx, sy, sz)     // scale is applied first: left of rotation.
+	p.wm.TranslateMT(lx, ly, lz) // translation applied last: right of rotation.
+
+	// Combine with parent transform. The world transform of a child is
+	// relative to its parent. Parent's model matrix has already been set
+	// because parent pov's appear earlier in ps.data than their children.
+	node := &ps.nodes[index]
+	if node.parent != 0 {
+		if pindex, ok := ps.index[node.parent]; ok {
+			parent := &ps.povs[pindex] // use ref, not copy.
+			p.wm.Mult(p.wm, parent.wm) // model + parent transform
+		} else {
+			slog.Error("scene graph missing child", "entity", node.parent) // dev error.
+		}
+	}
+
+	// Track absolute world transform values.
+	// See https://math.stackexchange.com/questions/237369/ and
+	// note the limitations when using uneven or negative scales.
+	var v3 lin.V3
+	var m3 lin.M3
+	m := p.wm
+	p.tw.Loc.SetS(m.Wx, m.Wy, m.Wz) // world space position.
+	sx = v3.SetS(m.Xx, m.Xy, p.wm.Xz).Len()
+	sy = v3.SetS(m.Yx, m.Yy, p.wm.Yz).Len()
+	sz = v3.SetS(m.Zx, m.Zy, p.wm.Zz).Len()
+	p.sw.SetS(sx, sy, sz) // world scale
+	m3.SetS(
+		m.Xx/sx, m.Xy/sx, p.wm.Xz/sx,
+		m.Yx/sy, m.Yy/sy, p.wm.Yz/sy,
+		m.Zx/sz, m.Zy/sz, p.wm.Zz/sz)
+	p.tw.Rot.SetM3(&m3)    // world rotation.
+	p.tw.Rot.Inv(p.tw.Rot) // Undo model matrix invert.
+	if p.tagged {
+		tagMoves = append(tagMoves, eid)
+	}
+	if p.indexed {
+		indexMoves = append(indexMoves, eid)
+	}
+
+	// Child nodes must also be updated.
+	for _, kid := range node.kids {
+		if index, ok := ps.index[kid]; ok {
+			tagMoves, indexMoves = ps.propagate(&ps.povs[index], kid, tagMoves, indexMoves)
+		} else {
+			slog.Error("Scene graph missing child.") // dev error.
+		}
 	}
+	return tagMoves, indexMoves
 }
 
 // =============================================================================
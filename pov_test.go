@@ -52,6 +52,54 @@ func TestDeleteMiddle(t *testing.T) {
 	}
 }
 
+// go test -run UpdateWorlds
+func TestUpdateWorlds(t *testing.T) {
+	// build the same scene graph twice: a scene with many
+	// models, each with a child that has a child.
+	build := func() (*povs, []eID) {
+		ents, povs := &entities{}, newPovs()
+		scene := povs.create(ents.create(), 0)
+		moved := []eID{}
+		for i := 0; i < 1000; i++ {
+			p := povs.create(ents.create(), scene.eid)
+			kid := povs.create(ents.create(), p.eid)
+			grandkid := povs.create(ents.create(), kid.eid)
+			p.tn.Loc.SetS(float64(i), 1, 0)
+			p.tn.Rot.SetAa(0, 1, 0, lin.Rad(float64(i)))
+			kid.tn.Loc.SetS(0, 2, 0)
+			grandkid.tn.Loc.SetS(0, 0, 3)
+			povs.get(grandkid.eid).tagged = true
+			moved = append(moved, p.eid)
+			if i%10 == 0 {
+				moved = append(moved, kid.eid, p.eid) // covered by the parent.
+			}
+		}
+		return povs, moved
+	}
+	serial, moved := build()
+	for _, eid := range moved {
+		serial.updateWorld(serial.get(eid), eid)
+	}
+	work := newWorkers()
+	work.resize(4)
+	defer work.dispose()
+	parallel, moved := build()
+	parallel.updateWorlds(work, moved)
+
+	for index := range serial.povs {
+		s, p := serial.povs[index].tw.Loc, parallel.povs[index].tw.Loc
+		if !s.Aeq(p) || !serial.povs[index].tw.Rot.Aeq(parallel.povs[index].tw.Rot) {
+			t.Fatalf("pov %d expected %v got %v", index, s, p)
+		}
+	}
+	if len(parallel.tagMoves) != 1000 {
+		t.Errorf("expected each tagged pov moved once got %d", len(parallel.tagMoves))
+	}
+	if len(parallel.roots) != 1000 {
+		t.Errorf("expected independent subtrees got %d", len(parallel.roots))
+	}
+}
+
 // Dump a matrix. Used to debug the pov transform methods.
 func DumpM4(m *lin.M4) string {
 	format := "[%+2.9f, %+2.9f, %+2.9f, %+2.9f]\n"
@@ -0,0 +1,376 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// probe.go captures the surroundings of reflection probes so that nearby
+// shiny models can reflect them, eg:
+//
+//	probe := scene.AddReflectionProbe(32, 10, 4, 10).SetAt(0, 2, 0)
+//	probe.SetProbeRefresh(2 * time.Second) // for moving parts, otherwise
+//	probe.CaptureProbe()                   // after the scene changes.
+//	scene.AddModel("shd:reflect", "msh:sphere").SetColor(0.9, 0.9, 0.9, 1).SetMetallicRoughness(true, 0.1)
+//
+// A probe captures the scene seen from the probe location in every
+// direction into a cubemap environment texture. The capture traces rays
+// through the scene on the CPU using the scene lights, see lightmap.go,
+// so probes are captured when they are added and then only on request or
+// at the refresh interval. The rays of a capture are spread over several
+// updates and the environment texture is replaced once the capture is
+// complete. Parts are only seen by the probe if their mesh triangles are
+// kept, see MeshTriangles. Each model whose shader has an "env" sampler,
+// like the reflect shader, uses the probe whose box contains the model,
+// or the closest probe if no box contains the model. The probe box is also
+// used to correct the reflections of models inside the box so that nearby
+// walls are reflected where they are instead of at an infinite distance.
+// Models can instead use their own cubemap image, eg: "tex:env:sky",
+// see load.SetCubeImage.
+//
+// FUTURE: capture using the GPU by drawing the cubemap faces into render
+// targets, as the water reflections do, and blur the environment for
+// rough materials.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// AddReflectionProbe adds a reflection probe part to a 3D scene. Size is
+// the width and height in pixels of each cubemap face, up to 128.
+// The probe box is centered on the probe with the given half sizes.
+// The probe capture starts on the next update.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) AddReflectionProbe(size int, hx, hy, hz float64) (me *Entity) {
+	me = e.AddPart()
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		slog.Error("AddReflectionProbe needs 3D scene", "eid", e.eid)
+		return me
+	}
+	if size < 4 || hx <= 0 || hy <= 0 || hz <= 0 {
+		slog.Error("AddReflectionProbe invalid probe", "size", size, "hx", hx, "hy", hy, "hz", hz)
+		return me
+	}
+	me.app.probes.create(me.eid, min(size, maxProbeSize), lin.V3{X: hx, Y: hy, Z: hz})
+	return me
+}
+
+// SetProbeRefresh captures the probe again after each interval so that
+// the reflections follow moving parts. The default 0 only captures the
+// probe when it is added or when CaptureProbe is called. A capture that
+// is still running finishes before the next capture starts.
+//
+// Depends on Entity.AddReflectionProbe.
+func (e *Entity) SetProbeRefresh(interval time.Duration) *Entity {
+	if p := e.app.probes.get(e.eid); p != nil {
+		p.refresh, p.elapsed = max(interval, 0), 0
+		return e
+	}
+	slog.Error("SetProbeRefresh needs AddReflectionProbe", "eid", e.eid)
+	return e
+}
+
+// CaptureProbe captures the probe surroundings on the next update,
+// eg: after the parts around the probe have changed.
+//
+// Depends on Entity.AddReflectionProbe.
+func (e *Entity) CaptureProbe() *Entity {
+	if p := e.app.probes.get(e.eid); p != nil {
+		p.capture = true
+		return e
+	}
+	slog.Error("CaptureProbe needs AddReflectionProbe", "eid", e.eid)
+	return e
+}
+
+// SetProbeSky sets the color seen by the probe where there are no
+// scene parts. The default is a pale blue.
+//
+// Depends on Entity.AddReflectionProbe.
+func (e *Entity) SetProbeSky(r, g, b float64) *Entity {
+	if p := e.app.probes.get(e.eid); p != nil {
+		p.sky = lin.V3{X: r, Y: g, Z: b}
+		return e
+	}
+	slog.Error("SetProbeSky needs AddReflectionProbe", "eid", e.eid)
+	return e
+}
+
+// =============================================================================
+// probe data
+
+const (
+	maxProbeSize = 128   // largest cubemap face size.
+	probeRays    = 4096  // rays traced each update for all probes.
+	probeSampler = "env" // shader sampler for the probe environment.
+)
+
+// probe is a reflection probe environment image and its capture settings.
+type probe struct {
+	size    int           // cubemap face width and height.
+	box     lin.V3        // box half sizes.
+	sky     lin.V3        // color where there are no parts.
+	refresh time.Duration // time between captures, 0 for on demand.
+	elapsed time.Duration // time since the last capture started.
+	capture bool          // true to capture on the next update.
+
+	// a running capture traces the image rows a few at a time
+	// from the location where the capture started.
+	tracer *baker // nil if there is no running capture.
+	at     lin.V3 // capture world location.
+	row    int    // next image row to trace.
+
+	// the first texture is drawn while the second is updated.
+	texs []*texture
+	img  *image.NRGBA // capture scratch, the cubemap faces top to bottom.
+}
+
+// contains returns true if the world location at is inside
+// the box of the probe at the world location center.
+func (p *probe) contains(center, at *lin.V3) bool {
+	return math.Abs(at.X-center.X) < p.box.X &&
+		math.Abs(at.Y-center.Y) < p.box.Y &&
+		math.Abs(at.Z-center.Z) < p.box.Z
+}
+
+// direction returns the unit direction for the environment image pixel
+// x,y where each size rows is the next cubemap face. Matches the Vulkan
+// cubemap face layout sampled by the reflect shader.
+func (p *probe) direction(x, y int) lin.V3 {
+	face, y := y/p.size, y%p.size
+	u := (float64(x)+0.5)/float64(p.size)*2 - 1 // -1 to 1 left to right.
+	v := (float64(y)+0.5)/float64(p.size)*2 - 1 // -1 to 1 top to bottom.
+	dir := lin.V3{}
+	switch face {
+	case 0: // +X
+		dir = lin.V3{X: 1, Y: -v, Z: -u}
+	case 1: // -X
+		dir = lin.V3{X: -1, Y: -v, Z: u}
+	case 2: // +Y
+		dir = lin.V3{X: u, Y: 1, Z: v}
+	case 3: // -Y
+		dir = lin.V3{X: u, Y: -1, Z: -v}
+	case 4: // +Z
+		dir = lin.V3{X: u, Y: -v, Z: 1}
+	default: // -Z
+		dir = lin.V3{X: -u, Y: -v, Z: -1}
+	}
+	return *dir.Unit()
+}
+
+// start begins a capture from the probe world location at.
+func (p *probe) start(app *application, scene eID, at *lin.V3) {
+	if p.img == nil {
+		p.img = image.NewNRGBA(image.Rect(0, 0, p.size, 6*p.size))
+	}
+	p.tracer = &baker{app: app, scene: scene, sky: p.sky}
+	p.tracer.gatherLights()
+	p.at, p.row = *at, 0
+}
+
+// shoot traces whole image rows of the running capture until at least
+// rays rays have been traced. Returns the number of rays traced and
+// true once the capture is complete.
+func (p *probe) shoot(rays int) (traced int, done bool) {
+	channel := func(v float64) uint8 { return uint8(lin.Clamp(v, 0, 1)*255 + 0.5) }
+	for ; traced < rays && p.row < 6*p.size; p.row++ {
+		for x := 0; x < p.size; x++ {
+			dir := p.direction(x, p.row)
+			c := p.tracer.bounce(&p.at, &dir)
+			p.img.SetNRGBA(x, p.row, color.NRGBA{R: channel(c.X), G: channel(c.Y), B: channel(c.Z), A: 255})
+		}
+		traced += p.size
+	}
+	if p.row < 6*p.size {
+		return traced, false
+	}
+	p.tracer = nil
+	return traced, true
+}
+
+// =============================================================================
+// probes component manager.
+
+// probes tracks the reflection probes and the models using them.
+type probes struct {
+	list  map[eID]*probe
+	users map[eID]eID // models and their probe, 0 for no probe.
+}
+
+// newProbes creates the reflection probe component manager.
+// There is only expected to be once instance created by the engine.
+func newProbes() *probes {
+	return &probes{list: map[eID]*probe{}, users: map[eID]eID{}}
+}
+
+// create a reflection probe for the given part.
+func (ps *probes) create(eid eID, size int, box lin.V3) *probe {
+	p := &probe{size: size, box: box, sky: lin.V3{X: 0.6, Y: 0.7, Z: 0.8}, capture: true}
+	ps.list[eid] = p
+	return p
+}
+
+// get the reflection probe for the given entity.
+func (ps *probes) get(eid eID) *probe { return ps.list[eid] }
+
+// dispose removes the probe or the probe user. The probe textures
+// are dropped and the probe users are switched to no probe.
+func (ps *probes) dispose(app *application, eid eID) {
+	delete(ps.users, eid)
+	p, ok := ps.list[eid]
+	if !ok {
+		return
+	}
+	delete(ps.list, eid)
+	for _, t := range p.texs {
+		app.ld.drops = append(app.ld.drops, t) // owned by the probe.
+	}
+	for user, pid := range ps.users {
+		if m := app.models.get(user); m != nil && pid == eid {
+			ps.use(app, user, m, 0, nil)
+		}
+	}
+}
+
+// update starts the probe captures that are due, continues the running
+// captures, and gives each model with an environment sampler the nearest
+// probe. At most probeRays rays are traced each update so that captures
+// are spread over several updates. Called by the engine once each update.
+func (ps *probes) update(app *application, rc render.Loader, delta time.Duration) {
+	updated, rays := false, probeRays
+	for eid, p := range ps.list {
+		p.elapsed += delta
+		due := p.capture || (p.refresh > 0 && p.elapsed >= p.refresh)
+		if p.tracer == nil && !due {
+			continue
+		}
+		pov := app.povs.get(eid)
+		if pov == nil || rays <= 0 {
+			continue
+		}
+		if !updated {
+			app.spatial.update(app)
+			updated = true
+		}
+		if p.tracer == nil {
+			p.capture, p.elapsed = false, 0
+			p.start(app, sceneRoot(app.povs, eid), pov.tw.Loc)
+		}
+		traced, done := p.shoot(rays)
+		rays -= traced
+		if !done {
+			continue
+		}
+		if err := ps.upload(eid, p, p.img, rc); err != nil {
+			slog.Error("probe upload", "error", err)
+		}
+	}
+	for eid, m := range app.models.list {
+		if ps.usesProbe(eid, m) {
+			pid, p := ps.nearest(app, eid)
+			ps.use(app, eid, m, pid, p)
+		}
+	}
+}
+
+// upload the environment image to the probe textures. The probe
+// textures are swapped after the update so that the texture being
+// drawn is not changed.
+func (ps *probes) upload(eid eID, p *probe, img *image.NRGBA, rc render.Loader) (err error) {
+	idata := &load.ImageData{
+		Width:  uint32(img.Bounds().Size().X),
+		Height: uint32(img.Bounds().Size().Y),
+		Pixels: []byte(img.Pix),
+		Opaque: true,
+		Cube:   true,
+	}
+	if len(p.texs) == 0 {
+		for _, suffix := range []string{"_a", "_b"} {
+			t := newTexture(fmt.Sprintf("probe%d%s", eid, suffix))
+			t.opaque, t.w, t.h = true, int(idata.Width), int(idata.Height)
+			if t.tid, err = rc.LoadTexture(idata); err != nil {
+				return fmt.Errorf("LoadTexture %s: %w", t.name, err)
+			}
+			p.texs = append(p.texs, t)
+		}
+		return nil
+	}
+	if err = rc.UpdateTexture(p.texs[1].tid, idata); err != nil {
+		return fmt.Errorf("UpdateTexture %s: %w", p.texs[1].name, err)
+	}
+	p.texs[0], p.texs[1] = p.texs[1], p.texs[0]
+	return nil
+}
+
+// usesProbe returns true for models whose shader has an environment
+// sampler that was not set by the application.
+func (ps *probes) usesProbe(eid eID, m *model) bool {
+	if m.shader == nil || m.shader.config == nil {
+		return false
+	}
+	if _, ok := ps.users[eid]; !ok {
+		if _, ok := m.samplerMap[probeSampler]; ok {
+			return false // application environment image.
+		}
+	}
+	for _, u := range m.shader.config.Uniforms {
+		if u.DataType == load.DataType_SAMPLER && u.Name == probeSampler {
+			return true
+		}
+	}
+	return false
+}
+
+// nearest returns the captured probe whose box contains the model,
+// or the closest captured probe in the same scene. Returns 0 and nil
+// if there are no captured probes.
+func (ps *probes) nearest(app *application, eid eID) (pid eID, p *probe) {
+	mp := app.povs.get(eid)
+	if mp == nil {
+		return 0, nil
+	}
+	scene, best, inside := sceneRoot(app.povs, eid), math.Inf(1), false
+	for id, candidate := range ps.list {
+		pp := app.povs.get(id)
+		if pp == nil || len(candidate.texs) == 0 || sceneRoot(app.povs, id) != scene {
+			continue
+		}
+		in := candidate.contains(pp.tw.Loc, mp.tw.Loc)
+		dist := pp.tw.Loc.DistSqr(mp.tw.Loc)
+		if (in && !inside) || (in == inside && (dist < best || (dist == best && id < pid))) {
+			pid, p, best, inside = id, candidate, dist, in
+		}
+	}
+	return pid, p
+}
+
+// use sets the model environment texture and probe uniforms to the given
+// probe. Models without a probe use the default texture and have their
+// probe reflections turned off.
+func (ps *probes) use(app *application, eid eID, m *model, pid eID, p *probe) {
+	var t *texture
+	at, box := lin.V4{}, lin.V4{} // zero turns off the probe reflections.
+	if p != nil {
+		t, box = p.texs[0], lin.V4{X: p.box.X, Y: p.box.Y, Z: p.box.Z}
+		loc := app.povs.get(pid).tw.Loc
+		at = lin.V4{X: loc.X, Y: loc.Y, Z: loc.Z, W: 1}
+	} else if dt, ok := app.ld.assets[assetID(tex, "test")].(*texture); ok {
+		t = dt // any texture, the shader ignores it.
+	}
+	if t == nil {
+		return
+	}
+	m.uniforms[load.PROBE] = render.V4ToBytes(&at, m.uniforms[load.PROBE])
+	m.uniforms[load.PROBEBOX] = render.V4ToBytes(&box, m.uniforms[load.PROBEBOX])
+
+	m.setSampler(probeSampler, t) // replace the previous environment texture.
+	ps.users[eid] = pid
+}
@@ -0,0 +1,135 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Probe
+func TestProbe(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	scene.AddLight(DirectionalLight).SetAt(0, -1, 0).SetLight(1, 1, 1, 2) // shining down.
+	scene.AddModel("msh:cube").SetAt(0, -2, 0)                            // floor below the probe.
+	probe := scene.AddReflectionProbe(8, 4, 4, 4).SetProbeSky(0, 0, 1)
+	far := scene.AddReflectionProbe(8, 1, 1, 1).SetAt(20, 0, 0)
+	shiny := scene.AddModel("msh:cube").SetAt(3, 0, 0)
+	shd := newShader("reflect")
+	shd.config = &load.Shader{Uniforms: []load.ShaderUniform{{Name: "env", DataType: load.DataType_SAMPLER}}}
+	app.models.get(shiny.eid).shader = shd
+	app.povs.setWorldMatrix(app.work, 0)
+	app.probes.update(app, rc, 0)
+
+	// go test -run Probe/capture
+	t.Run("capture", func(t *testing.T) {
+		p := app.probes.get(probe.eid)
+		if len(p.texs) != 2 || p.capture || p.img == nil {
+			t.Fatalf("expected captured probe")
+		}
+		if b := p.img.Bounds(); b.Dx() != 8 || b.Dy() != 6*8 {
+			t.Errorf("expected 6 cubemap faces got %v", b)
+		}
+		if c := p.img.NRGBAAt(4, 2*8+4); c.B != 255 || c.R != 0 {
+			t.Errorf("expected sky above got %v", c)
+		}
+		if c := p.img.NRGBAAt(4, 3*8+4); c.R == 0 || c.B == 255 {
+			t.Errorf("expected floor below got %v", c)
+		}
+		dir := p.direction(4, 5*8+4) // center of the -Z face looks forward.
+		if want := -1 / math.Sqrt(1+2*0.125*0.125); !lin.Aeq(dir.Z, want) || !lin.Aeq(dir.X, want*0.125) {
+			t.Errorf("expected forward direction got %v", dir)
+		}
+		if dir = p.direction(0, 0); dir.X <= 0 || dir.Y <= 0 || dir.Z <= 0 {
+			t.Errorf("expected top left of +X face towards +Z got %v", dir)
+		}
+		first := p.texs[0]
+		probe.SetProbeRefresh(time.Second)
+		app.probes.update(app, rc, 500*time.Millisecond)
+		if p.texs[0] != first {
+			t.Errorf("expected no capture before the refresh")
+		}
+		app.probes.update(app, rc, 500*time.Millisecond)
+		if p.texs[0] == first || p.texs[1] != first {
+			t.Errorf("expected swapped textures after the refresh")
+		}
+	})
+
+	// go test -run Probe/assign
+	t.Run("assign", func(t *testing.T) {
+		m := app.models.get(shiny.eid)
+		p := app.probes.get(probe.eid)
+		if len(m.texs) != 1 || m.samplerMap["env"] != p.texs[0].label() {
+			t.Fatalf("expected probe environment got %v", m.samplerMap)
+		}
+		want := render.V4SToBytes(0, 0, 0, 1, nil)
+		if string(m.uniforms[load.PROBE]) != string(want) {
+			t.Errorf("expected probe location uniform")
+		}
+		want = render.V4SToBytes(4, 4, 4, 0, nil)
+		if string(m.uniforms[load.PROBEBOX]) != string(want) {
+			t.Errorf("expected probe box uniform")
+		}
+		shiny.SetAt(19, 0, 0) // now inside the far probe.
+		app.povs.setWorldMatrix(app.work, 0)
+		app.probes.update(app, rc, 0)
+		if len(m.texs) != 1 || m.samplerMap["env"] != app.probes.get(far.eid).texs[0].label() {
+			t.Errorf("expected far probe environment got %v", m.samplerMap)
+		}
+	})
+
+	// go test -run Probe/dispose
+	t.Run("dispose", func(t *testing.T) {
+		m := app.models.get(shiny.eid)
+		far.Dispose(nil)
+		probe.Dispose(nil)
+		if app.probes.get(probe.eid) != nil || len(app.ld.drops) != 4 {
+			t.Errorf("expected probe textures dropped")
+		}
+		if len(m.texs) != 1 || m.samplerMap["env"] != "test" {
+			t.Errorf("expected fallback environment got %v", m.samplerMap)
+		}
+		if want := render.V4SToBytes(0, 0, 0, 0, nil); string(m.uniforms[load.PROBE]) != string(want) {
+			t.Errorf("expected probe reflections off")
+		}
+		shiny.Dispose(nil)
+		if len(app.probes.users) != 0 {
+			t.Errorf("expected probe user removed")
+		}
+	})
+
+	// go test -run Probe/spread
+	t.Run("spread", func(t *testing.T) {
+		big := scene.AddReflectionProbe(1024, 4, 4, 4)
+		p := app.probes.get(big.eid)
+		if p == nil || p.size != maxProbeSize {
+			t.Fatalf("expected clamped probe size")
+		}
+		updates := 0
+		for ; len(p.texs) == 0 && updates < 100; updates++ {
+			app.probes.update(app, rc, 0)
+		}
+		if want := 6 * maxProbeSize * maxProbeSize / probeRays; updates != want || p.tracer != nil {
+			t.Errorf("expected capture over %d updates got %d", want, updates)
+		}
+		big.Dispose(nil)
+	})
+
+	// go test -run Probe/errors
+	t.Run("errors", func(t *testing.T) {
+		if app.addScene(Scene2D).AddReflectionProbe(8, 1, 1, 1); len(app.probes.list) != 0 {
+			t.Errorf("expected 3D scene only")
+		}
+		if scene.AddReflectionProbe(1, 1, 1, 1); len(app.probes.list) != 0 {
+			t.Errorf("expected invalid size")
+		}
+	})
+}
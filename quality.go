@@ -0,0 +1,148 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// quality.go adjusts application quality settings to hold a target
+// frame rate on unknown hardware. The application registers quality
+// knobs, like render scale, shadow resolution, particle counts, or post
+// effects, that the engine lowers when frames take too long and raises
+// again when there is time to spare, eg:
+//
+//	eng.AddQualityKnob("particles", 4, func(level int) { maxParticles = 250 << level })
+//	eng.AddQualityKnob("shadows", 3, func(level int) { shadowSize = 512 << level })
+//	eng.SetTargetFrameRate(60)
+//
+// Knobs are lowered one level at a time in the order they were added
+// and raised in the reverse order. Frame time must stay over or under
+// budget for a while before a knob is changed so that quality does not
+// flicker between levels.
+
+import (
+	"log/slog"
+	"time"
+)
+
+// AddQualityKnob registers a quality setting with the given number
+// of levels. Set is called with the new level, from 0 for the lowest
+// quality to levels-1 for the highest quality. Knobs start at their
+// highest level. Set is called from the engine update goroutine.
+func (eng *Engine) AddQualityKnob(name string, levels int, set func(level int)) {
+	if levels < 2 || set == nil {
+		slog.Error("AddQualityKnob needs 2 or more levels and a set function", "knob", name)
+		return
+	}
+	eng.quality.knobs = append(eng.quality.knobs, &qualityKnob{name: name, level: levels - 1, max: levels - 1, set: set})
+}
+
+// QualityLevel returns the current level of the named quality knob.
+// Returns -1 if there is no such knob.
+func (eng *Engine) QualityLevel(name string) int {
+	for _, k := range eng.quality.knobs {
+		if k.name == name {
+			return k.level
+		}
+	}
+	return -1
+}
+
+// SetTargetFrameRate enables automatic quality adjustment to hold the
+// given frames per second. Frame time is the larger of the CPU update
+// and render time and the GPU render time. Zero disables the automatic
+// adjustment, leaving the knobs at their current levels.
+func (eng *Engine) SetTargetFrameRate(fps int) {
+	eng.quality.target = 0
+	if fps > 0 {
+		eng.quality.target = time.Second / time.Duration(fps)
+	}
+	eng.quality.over, eng.quality.under = 0, 0
+}
+
+// =============================================================================
+// quality controller.
+
+// Quality controller tuning. Frames over the lower threshold are over
+// budget, frames under the raise threshold have time to spare. The gap
+// between the thresholds stops knobs from bouncing between levels.
+const (
+	qualityLower    = 0.95                   // fraction of target to lower quality.
+	qualityRaise    = 0.70                   // fraction of target to raise quality.
+	qualityLowerFor = time.Second            // time over budget before lowering.
+	qualityRaiseFor = 3 * time.Second        // time under budget before raising.
+	qualitySettle   = 500 * time.Millisecond // ignore frames after a change.
+	qualitySmooth   = 0.1                    // frame time smoothing factor.
+)
+
+// qualityKnob is one application quality setting.
+type qualityKnob struct {
+	name  string
+	level int // current level.
+	max   int // highest level.
+	set   func(level int)
+}
+
+// quality adjusts the quality knobs based on frame time.
+type quality struct {
+	knobs  []*qualityKnob
+	target time.Duration // frame time budget, 0 when disabled.
+	frame  float64       // smoothed frame time in seconds.
+	over   time.Duration // time spent over budget.
+	under  time.Duration // time spent under budget.
+	settle time.Duration // time left to ignore frames after a change.
+}
+
+// update records the work time for a frame that took delta time
+// and changes a quality knob if needed.
+func (q *quality) update(work, delta time.Duration) {
+	if q.target <= 0 || len(q.knobs) == 0 {
+		return
+	}
+	if q.settle > 0 {
+		q.settle -= delta
+		q.frame = work.Seconds() // restart smoothing at the new quality.
+		return
+	}
+	q.frame += (work.Seconds() - q.frame) * qualitySmooth
+	budget := q.target.Seconds()
+	switch {
+	case q.frame > budget*qualityLower:
+		q.over, q.under = q.over+delta, 0
+	case q.frame < budget*qualityRaise:
+		q.over, q.under = 0, q.under+delta
+	default:
+		q.over, q.under = 0, 0
+	}
+	switch {
+	case q.over >= qualityLowerFor:
+		q.change(-1)
+	case q.under >= qualityRaiseFor:
+		q.change(+1)
+	}
+}
+
+// change lowers or raises one knob by one level.
+func (q *quality) change(step int) {
+	q.over, q.under = 0, 0
+	var knob *qualityKnob
+	if step < 0 {
+		for _, k := range q.knobs {
+			if k.level > 0 {
+				knob = k // first knob that can be lowered.
+				break
+			}
+		}
+	} else {
+		for i := len(q.knobs) - 1; i >= 0; i-- {
+			if k := q.knobs[i]; k.level < k.max {
+				knob = k // last knob that can be raised.
+				break
+			}
+		}
+	}
+	if knob == nil {
+		return // already at the lowest or highest quality.
+	}
+	knob.level += step
+	knob.set(knob.level)
+	q.settle = qualitySettle
+	slog.Debug("quality changed", "knob", knob.name, "level", knob.level, "frame", q.frame)
+}
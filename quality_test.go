@@ -0,0 +1,60 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Quality
+func TestQuality(t *testing.T) {
+	eng := &Engine{}
+	shadows, particles := -1, -1
+	eng.AddQualityKnob("shadows", 3, func(level int) { shadows = level })
+	eng.AddQualityKnob("particles", 2, func(level int) { particles = level })
+	eng.SetTargetFrameRate(50) // 20ms budget.
+	frames := func(work time.Duration, seconds float64) {
+		for i := 0; i < int(seconds*50); i++ {
+			eng.quality.update(work, 20*time.Millisecond)
+		}
+	}
+
+	t.Run("lower", func(t *testing.T) {
+		frames(15*time.Millisecond, 5) // within budget.
+		if shadows != -1 || eng.QualityLevel("shadows") != 2 {
+			t.Fatalf("expected no change got %d", shadows)
+		}
+		frames(30*time.Millisecond, 1.5)
+		if shadows != 1 || particles != -1 {
+			t.Fatalf("expected shadows lowered first got %d %d", shadows, particles)
+		}
+		frames(30*time.Millisecond, 10)
+		if shadows != 0 || particles != 0 || eng.QualityLevel("particles") != 0 {
+			t.Fatalf("expected lowest quality got %d %d", shadows, particles)
+		}
+	})
+	t.Run("hysteresis", func(t *testing.T) {
+		frames(17*time.Millisecond, 10) // between thresholds.
+		if shadows != 0 || particles != 0 {
+			t.Fatalf("expected no change got %d %d", shadows, particles)
+		}
+	})
+	t.Run("raise", func(t *testing.T) {
+		frames(5*time.Millisecond, 4)
+		if particles != 1 || shadows != 0 {
+			t.Fatalf("expected particles raised first got %d %d", shadows, particles)
+		}
+		frames(5*time.Millisecond, 20)
+		if shadows != 2 {
+			t.Fatalf("expected highest quality got %d", shadows)
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		eng.SetTargetFrameRate(0)
+		frames(100*time.Millisecond, 5)
+		if shadows != 2 || eng.QualityLevel("missing") != -1 {
+			t.Fatalf("expected no change got %d", shadows)
+		}
+	})
+}
@@ -0,0 +1,621 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// record.go records gameplay video and sound, eg:
+//
+//	eng.StartRecording("play.mp4", 30) // until StopRecording.
+//
+// ".mp4" and ".webm" recordings are encoded by ffmpeg, which is expected
+// to be on the PATH, by piping it the frames. ".mkv" recordings are
+// encoded by the engine as motion JPEG frames and PCM sound. These play
+// in most video players, and in the engine, see Entity.AddVideo, but
+// make large files. Sound is recorded when the sounds are mixed by the
+// engine, see audio.Context.TapOutput.
+//
+// Frames are read back from the GPU as for StartFrameCapture and repeated
+// or dropped to keep the recording at a constant frame rate. Frames that
+// change size, eg: a resized window, are cropped or padded to the size of
+// the first frame.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordRate is the sample rate of recorded sound.
+// Sound is recorded as interleaved 16-bit stereo.
+const recordRate = 48000
+
+// StartRecording records the main window, and the sound, to the given
+// ".mp4", ".webm", or ".mkv" file at the given frames per second, eg: 30,
+// until StopRecording. Returns an error if the file type is unsupported
+// or if ffmpeg is needed and not found.
+func (eng *Engine) StartRecording(path string, fps int) error {
+	if eng.rec != nil {
+		return fmt.Errorf("StartRecording: already recording %s", eng.rec.path)
+	}
+	if fps <= 0 {
+		return fmt.Errorf("StartRecording: invalid fps %d", fps)
+	}
+	rec := &recording{path: path, fps: fps, start: time.Now(), sound: &soundTap{}}
+	if eng.ac == nil || eng.ac.TapOutput(rec.sound.write) != nil {
+		rec.sound = nil // record without sound.
+	}
+	enc, err := newVideoEncoder(path, fps, rec.sound != nil)
+	if err != nil {
+		if rec.sound != nil {
+			eng.ac.TapOutput(nil)
+		}
+		return fmt.Errorf("StartRecording: %w", err)
+	}
+	rec.begin(enc)
+	eng.rec = rec
+	return nil
+}
+
+// StopRecording stops recording and waits for the recording to
+// be written. Returns any error encountered while encoding.
+func (eng *Engine) StopRecording() error {
+	if eng.rec == nil {
+		return nil
+	}
+	if eng.rec.sound != nil && eng.ac != nil {
+		eng.ac.TapOutput(nil)
+	}
+	err := eng.rec.stop()
+	eng.rec = nil
+	return err
+}
+
+// Recording returns true while recording.
+func (eng *Engine) Recording() bool { return eng.rec != nil }
+
+// recordFrame requests the read back of the next frame when
+// the recording needs a new frame. Called before each frame is drawn.
+func (eng *Engine) recordFrame() {
+	rec := eng.rec
+	if rec == nil {
+		return
+	}
+	at := time.Since(rec.start)
+	if !rec.due(at) {
+		return
+	}
+	if err := eng.rc.Capture(func(img *image.NRGBA) { rec.frame(img, at) }); err != nil {
+		slog.Error("StartRecording", "error", err)
+		eng.StopRecording()
+	}
+}
+
+// =============================================================================
+
+// recording paces the captured frames and passes them, along with the
+// recorded sound, to an encoder running on a separate goroutine.
+type recording struct {
+	path    string
+	fps     int
+	start   time.Time       // wall clock time of the first frame.
+	sound   *soundTap       // nil when recording without sound.
+	size    image.Point     // frame size, set by the first frame.
+	wanted  int             // frames requested.
+	frames  int             // frames passed to the encoder.
+	work    chan recordWork // frames and sound waiting to be encoded.
+	done    chan error      // the encoding result.
+	stopped bool            // frames read back after stopping are ignored.
+}
+
+// recordWork is either a frame or a block of sound.
+type recordWork struct {
+	img *image.NRGBA
+	pcm []int16
+}
+
+// begin starts the encoding goroutine.
+func (r *recording) begin(enc videoEncoder) {
+	r.work = make(chan recordWork, 8)
+	r.done = make(chan error, 1)
+	go r.run(enc)
+}
+
+// due returns true if a frame at the given recording time is needed.
+func (r *recording) due(at time.Duration) bool {
+	want := int(at.Seconds()*float64(r.fps)) + 1
+	if want <= r.wanted {
+		return false
+	}
+	r.wanted = want
+	return true
+}
+
+// frame passes a frame to the encoder, repeating it until the next frame
+// is due. Called on the engine goroutine with the frame drawn at the
+// given recording time.
+func (r *recording) frame(img *image.NRGBA, at time.Duration) {
+	if r.stopped {
+		return
+	}
+	if r.size == (image.Point{}) {
+		r.size = image.Pt(img.Rect.Dx()&^1, img.Rect.Dy()&^1) // even sizes for video codecs.
+	}
+	if r.size.X == 0 || r.size.Y == 0 {
+		return
+	}
+	if img.Rect.Size() != r.size {
+		fit := image.NewNRGBA(image.Rectangle{Max: r.size})
+		draw.Draw(fit, fit.Rect, img, img.Rect.Min, draw.Src)
+		img = fit
+	}
+	for want := int(at.Seconds()*float64(r.fps)) + 1; r.frames < want; r.frames++ {
+		r.work <- recordWork{img: img} // blocks if the encoder falls behind.
+	}
+	if pcm := r.sound.take(); len(pcm) > 0 {
+		r.work <- recordWork{pcm: pcm}
+	}
+}
+
+// stop passes the remaining sound to the encoder and waits
+// for the recording to be written.
+func (r *recording) stop() error {
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	if pcm := r.sound.take(); len(pcm) > 0 {
+		r.work <- recordWork{pcm: pcm}
+	}
+	close(r.work)
+	return <-r.done
+}
+
+// run encodes the frames and sound in order. Work is discarded
+// after an encoding error so that the engine is not blocked.
+func (r *recording) run(enc videoEncoder) {
+	var err error
+	for w := range r.work {
+		switch {
+		case err != nil:
+		case w.img != nil:
+			err = enc.frame(w.img)
+		default:
+			err = enc.sound(w.pcm)
+		}
+	}
+	if cerr := enc.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		err = fmt.Errorf("recording %s: %w", r.path, err)
+	}
+	r.done <- err
+}
+
+// soundTap collects the mixed sound output, resampled to the
+// recording rate. Written from the audio stream goroutine and
+// taken from the engine goroutine.
+type soundTap struct {
+	mu   sync.Mutex
+	pcm  []int16    // interleaved stereo samples since the last take.
+	pos  float64    // resample position in the next output block.
+	last [2]float32 // last sample frame of the previous output block.
+}
+
+// write implements the audio.Context.TapOutput callback,
+// resampling the output using linear interpolation.
+func (st *soundTap) write(stereo []float32, rate int) {
+	frames := len(stereo) / 2
+	if frames == 0 || rate <= 0 {
+		return
+	}
+	at := func(i, ch int) float32 {
+		if i < 0 {
+			return st.last[ch]
+		}
+		return stereo[2*min(i, frames-1)+ch]
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	step := float64(rate) / recordRate
+	p := st.pos
+	for ; p <= float64(frames-1); p += step {
+		i := int(math.Floor(p))
+		f := float32(p - float64(i))
+		for ch := 0; ch < 2; ch++ {
+			s := at(i, ch) + (at(i+1, ch)-at(i, ch))*f
+			st.pcm = append(st.pcm, int16(max(-1, min(s, 1))*math.MaxInt16))
+		}
+	}
+	st.pos = p - float64(frames)
+	st.last = [2]float32{stereo[2*frames-2], stereo[2*frames-1]}
+}
+
+// take returns the samples collected since the last take.
+// Returns nil for a nil tap, ie: recording without sound.
+func (st *soundTap) take() (pcm []int16) {
+	if st == nil {
+		return nil
+	}
+	st.mu.Lock()
+	pcm, st.pcm = st.pcm, nil
+	st.mu.Unlock()
+	return pcm
+}
+
+// =============================================================================
+
+// videoEncoder writes a recording. Frames are the same size
+// and sound is interleaved 16-bit stereo at recordRate.
+type videoEncoder interface {
+	frame(img *image.NRGBA) error // add the next frame.
+	sound(pcm []int16) error      // add the next block of sound.
+	close() error                 // finish the recording.
+}
+
+// newVideoEncoder returns the encoder for the recording file type.
+func newVideoEncoder(path string, fps int, sound bool) (videoEncoder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".mkv" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &mkvEncoder{w: file, fps: fps, hasSound: sound}, nil
+	}
+	if _, ok := ffmpegCodecs[ext]; !ok {
+		return nil, fmt.Errorf("%w: %q recordings", errors.ErrUnsupported, ext)
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q recordings need ffmpeg: %w", errors.ErrUnsupported, ext, err)
+	}
+	return &ffmpegEncoder{ffmpeg: ffmpeg, path: path, fps: fps, hasSound: sound}, nil
+}
+
+// ffmpegCodecs are the ffmpeg video and sound
+// encoder arguments for each file type.
+var ffmpegCodecs = map[string]struct{ video, sound []string }{
+	".mp4": {
+		video: []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20"},
+		sound: []string{"-c:a", "aac", "-b:a", "192k"},
+	},
+	".webm": {
+		video: []string{"-c:v", "libvpx-vp9", "-deadline", "realtime", "-cpu-used", "8", "-row-mt", "1", "-crf", "32", "-b:v", "0"},
+		sound: []string{"-c:a", "libopus", "-b:a", "128k"},
+	},
+}
+
+// ffmpegEncoder pipes raw frames to ffmpeg. Sound is written to a
+// temporary WAV file and combined with the video once it is finished.
+type ffmpegEncoder struct {
+	ffmpeg   string // ffmpeg executable.
+	path     string // recording file.
+	fps      int
+	hasSound bool
+
+	cmd    *exec.Cmd      // started by the first frame.
+	in     io.WriteCloser // ffmpeg raw frame input.
+	stderr bytes.Buffer   // ffmpeg error messages.
+	video  string         // video file, path unless there is sound.
+	wav    *os.File       // temporary sound file.
+	pcm    int            // sound bytes written.
+}
+
+// videoArgs returns the ffmpeg arguments that encode
+// raw frames of the given size to the output file.
+func (fe *ffmpegEncoder) videoArgs(out string, w, h int) []string {
+	ext := strings.ToLower(filepath.Ext(fe.path))
+	args := []string{"-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", w, h),
+		"-framerate", strconv.Itoa(fe.fps), "-i", "-"}
+	args = append(args, ffmpegCodecs[ext].video...)
+	return append(args, "-pix_fmt", "yuv420p", out)
+}
+
+// muxArgs returns the ffmpeg arguments that combine
+// the video and sound files into the recording.
+func (fe *ffmpegEncoder) muxArgs() []string {
+	ext := strings.ToLower(filepath.Ext(fe.path))
+	args := []string{"-y", "-loglevel", "error", "-i", fe.video, "-i", fe.wav.Name(), "-c:v", "copy"}
+	args = append(args, ffmpegCodecs[ext].sound...)
+	return append(args, "-shortest", fe.path)
+}
+
+// frame implements videoEncoder.
+func (fe *ffmpegEncoder) frame(img *image.NRGBA) (err error) {
+	if fe.cmd == nil {
+		if err = fe.startVideo(img.Rect.Dx(), img.Rect.Dy()); err != nil {
+			return err
+		}
+	}
+	if _, err = fe.in.Write(img.Pix); err != nil {
+		return fmt.Errorf("ffmpeg: %w %s", err, fe.stderr.String())
+	}
+	return nil
+}
+
+// startVideo starts ffmpeg once the frame size is known.
+func (fe *ffmpegEncoder) startVideo(w, h int) (err error) {
+	fe.video = fe.path
+	if fe.hasSound {
+		ext := filepath.Ext(fe.path)
+		fe.video = strings.TrimSuffix(fe.path, ext) + ".video" + ext
+	}
+	fe.cmd = exec.Command(fe.ffmpeg, fe.videoArgs(fe.video, w, h)...)
+	fe.cmd.Stderr = &fe.stderr
+	if fe.in, err = fe.cmd.StdinPipe(); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	if err = fe.cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// sound implements videoEncoder.
+func (fe *ffmpegEncoder) sound(pcm []int16) (err error) {
+	if fe.wav == nil {
+		ext := filepath.Ext(fe.path)
+		if fe.wav, err = os.Create(strings.TrimSuffix(fe.path, ext) + ".sound.wav"); err != nil {
+			return err
+		}
+		if _, err = fe.wav.Write(wavHeader(0)); err != nil {
+			return err
+		}
+	}
+	if err = binary.Write(fe.wav, binary.LittleEndian, pcm); err != nil {
+		return err
+	}
+	fe.pcm += 2 * len(pcm)
+	return nil
+}
+
+// close implements videoEncoder by finishing the video
+// and then adding the sound to the recording.
+func (fe *ffmpegEncoder) close() (err error) {
+	if fe.wav != nil {
+		defer os.Remove(fe.wav.Name())
+		_, err = fe.wav.WriteAt(wavHeader(fe.pcm), 0)
+		if cerr := fe.wav.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if fe.cmd == nil {
+		return err // no frames were recorded.
+	}
+	fe.in.Close()
+	if werr := fe.cmd.Wait(); werr != nil {
+		return fmt.Errorf("ffmpeg: %w %s", werr, fe.stderr.String())
+	}
+	if fe.video == fe.path {
+		return err
+	}
+	if fe.wav == nil || err != nil {
+		if rerr := os.Rename(fe.video, fe.path); err == nil {
+			err = rerr // keep the video without sound.
+		}
+		return err
+	}
+	defer os.Remove(fe.video)
+	fe.stderr.Reset()
+	mux := exec.Command(fe.ffmpeg, fe.muxArgs()...)
+	mux.Stderr = &fe.stderr
+	if err = mux.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %w %s", err, fe.stderr.String())
+	}
+	return nil
+}
+
+// wavHeader returns the header for a WAV file holding
+// the given number of bytes of recorded sound.
+func wavHeader(size int) []byte {
+	h := make([]byte, 0, 44)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(36+size))
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)           // format size.
+	h = binary.LittleEndian.AppendUint16(h, 1)            // PCM.
+	h = binary.LittleEndian.AppendUint16(h, 2)            // channels.
+	h = binary.LittleEndian.AppendUint32(h, recordRate)   // sample rate.
+	h = binary.LittleEndian.AppendUint32(h, recordRate*4) // bytes per second.
+	h = binary.LittleEndian.AppendUint16(h, 4)            // bytes per sample frame.
+	h = binary.LittleEndian.AppendUint16(h, 16)           // bits per sample.
+	h = append(h, "data"...)
+	return binary.LittleEndian.AppendUint32(h, uint32(size))
+}
+
+// =============================================================================
+
+// mkvEncoder writes a Matroska file with a motion JPEG video track
+// and an optional PCM sound track. Each frame is written as a cluster
+// along with the sound recorded since the previous frame. The segment
+// size is left unknown so that the file is written in a single pass.
+type mkvEncoder struct {
+	w        io.WriteCloser
+	fps      int
+	hasSound bool
+
+	header  bool         // true once the header is written.
+	frames  int          // video frames written.
+	samples int          // sound sample frames written.
+	pending []int16      // sound waiting for the next frame.
+	jpg     bytes.Buffer // reused frame encoding buffer.
+}
+
+// Matroska element IDs used by the recording.
+const (
+	mkvEBML               = 0x1A45DFA3
+	mkvDocType            = 0x4282
+	mkvDocTypeVersion     = 0x4287
+	mkvDocTypeReadVersion = 0x4285
+	mkvSegment            = 0x18538067
+	mkvInfo               = 0x1549A966
+	mkvTimecodeScale      = 0x2AD7B1
+	mkvMuxingApp          = 0x4D80
+	mkvWritingApp         = 0x5741
+	mkvTracks             = 0x1654AE6B
+	mkvTrackEntry         = 0xAE
+	mkvTrackNumber        = 0xD7
+	mkvTrackUID           = 0x73C5
+	mkvTrackType          = 0x83
+	mkvCodecID            = 0x86
+	mkvDefaultDuration    = 0x23E383
+	mkvVideo              = 0xE0
+	mkvPixelWidth         = 0xB0
+	mkvPixelHeight        = 0xBA
+	mkvAudio              = 0xE1
+	mkvSampleRate         = 0xB5
+	mkvChannels           = 0x9F
+	mkvBitDepth           = 0x6264
+	mkvCluster            = 0x1F43B675
+	mkvTimecode           = 0xE7
+	mkvSimpleBlock        = 0xA3
+)
+
+// frame implements videoEncoder.
+func (me *mkvEncoder) frame(img *image.NRGBA) error {
+	if !me.header {
+		if err := me.writeHeader(img.Rect.Dx(), img.Rect.Dy()); err != nil {
+			return err
+		}
+	}
+	me.jpg.Reset()
+	if err := jpeg.Encode(&me.jpg, img, &jpeg.Options{Quality: 90}); err != nil {
+		return err
+	}
+	at := time.Duration(me.frames) * time.Second / time.Duration(me.fps)
+	me.frames++
+	return me.writeCluster(at, me.jpg.Bytes())
+}
+
+// sound implements videoEncoder.
+func (me *mkvEncoder) sound(pcm []int16) error {
+	me.pending = append(me.pending, pcm...)
+	return nil
+}
+
+// close implements videoEncoder.
+func (me *mkvEncoder) close() (err error) {
+	if me.header && len(me.pending) > 0 {
+		at := time.Duration(me.samples) * time.Second / recordRate
+		err = me.writeCluster(at, nil)
+	}
+	if cerr := me.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeHeader writes the file header and track descriptions.
+func (me *mkvEncoder) writeHeader(w, h int) error {
+	me.header = true
+	ebml := mkvElement(mkvEBML,
+		mkvString(mkvDocType, "matroska"),
+		mkvUint(mkvDocTypeVersion, 4),
+		mkvUint(mkvDocTypeReadVersion, 2))
+	segment := mkvID(mkvSegment)
+	segment = append(segment, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF) // unknown size.
+	info := mkvElement(mkvInfo,
+		mkvUint(mkvTimecodeScale, uint64(time.Millisecond)),
+		mkvString(mkvMuxingApp, "vu"),
+		mkvString(mkvWritingApp, "vu"))
+	tracks := mkvElement(mkvTrackEntry,
+		mkvUint(mkvTrackNumber, 1),
+		mkvUint(mkvTrackUID, 1),
+		mkvUint(mkvTrackType, 1),
+		mkvString(mkvCodecID, "V_MJPEG"),
+		mkvUint(mkvDefaultDuration, uint64(time.Second)/uint64(me.fps)),
+		mkvElement(mkvVideo, mkvUint(mkvPixelWidth, uint64(w)), mkvUint(mkvPixelHeight, uint64(h))))
+	if me.hasSound {
+		tracks = append(tracks, mkvElement(mkvTrackEntry,
+			mkvUint(mkvTrackNumber, 2),
+			mkvUint(mkvTrackUID, 2),
+			mkvUint(mkvTrackType, 2),
+			mkvString(mkvCodecID, "A_PCM/INT/LIT"),
+			mkvElement(mkvAudio,
+				mkvFloat(mkvSampleRate, recordRate),
+				mkvUint(mkvChannels, 2),
+				mkvUint(mkvBitDepth, 16)))...)
+	}
+	_, err := me.w.Write(bytes.Join([][]byte{ebml, segment, info, mkvElement(mkvTracks, tracks)}, nil))
+	return err
+}
+
+// writeCluster writes a cluster at the given time holding
+// the frame, if any, and the pending sound.
+func (me *mkvEncoder) writeCluster(at time.Duration, frame []byte) error {
+	ms := at.Milliseconds()
+	blocks := [][]byte{mkvUint(mkvTimecode, uint64(ms))}
+	if frame != nil {
+		blocks = append(blocks, mkvBlock(1, 0, frame))
+	}
+	if me.hasSound && len(me.pending) > 0 {
+		rel := time.Duration(me.samples)*time.Second/recordRate - time.Duration(ms)*time.Millisecond
+		pcm := make([]byte, 2*len(me.pending))
+		for i, s := range me.pending {
+			binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+		}
+		blocks = append(blocks, mkvBlock(2, rel.Milliseconds(), pcm))
+		me.samples += len(me.pending) / 2
+	}
+	me.pending = me.pending[:0]
+	_, err := me.w.Write(mkvElement(mkvCluster, blocks...))
+	return err
+}
+
+// mkvBlock returns a key frame SimpleBlock for the given track
+// at the given millisecond offset from the cluster time.
+func mkvBlock(track uint64, rel int64, data []byte) []byte {
+	rel = max(math.MinInt16, min(rel, math.MaxInt16))
+	body := []byte{0x80 | byte(track), byte(uint16(rel) >> 8), byte(rel), 0x80}
+	return mkvElement(mkvSimpleBlock, append(body, data...))
+}
+
+// mkvID returns the element ID bytes. IDs include their length marker.
+func mkvID(id uint64) []byte {
+	n := 1
+	for id>>(8*n) != 0 {
+		n++
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(id >> (8 * (n - 1 - i)))
+	}
+	return b
+}
+
+// mkvElement returns the element with the given id and
+// children, using an 8 byte size for simplicity.
+func mkvElement(id uint64, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := mkvID(id)
+	b = binary.BigEndian.AppendUint64(b, uint64(len(body))|1<<56)
+	return append(b, body...)
+}
+
+// mkvUint returns an unsigned integer element.
+func mkvUint(id, v uint64) []byte {
+	return mkvElement(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+// mkvFloat returns an 8 byte float element.
+func mkvFloat(id uint64, v float64) []byte {
+	return mkvElement(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+// mkvString returns a string element.
+func mkvString(id uint64, s string) []byte { return mkvElement(id, []byte(s)) }
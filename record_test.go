@@ -0,0 +1,150 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+)
+
+// go test -run Record
+func TestRecord(t *testing.T) {
+	frame := func(w, h int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for i := range img.Pix {
+			img.Pix[i] = 200
+		}
+		return img
+	}
+
+	// go test -run Record/pace
+	t.Run("pace", func(t *testing.T) {
+		enc := &mockEncoder{}
+		r := &recording{path: "test.mkv", fps: 10, sound: &soundTap{}}
+		r.begin(enc)
+		if !r.due(0) || r.due(50*time.Millisecond) || !r.due(100*time.Millisecond) {
+			t.Errorf("expected one frame every 100ms")
+		}
+		r.frame(frame(17, 9), 0)
+		r.frame(frame(17, 9), 350*time.Millisecond) // repeats for dropped frames.
+		r.frame(frame(33, 5), 360*time.Millisecond) // not due.
+		r.sound.write([]float32{0.5, -0.5}, recordRate)
+		if err := r.stop(); err != nil {
+			t.Fatal(err)
+		}
+		r.frame(frame(16, 8), time.Second) // ignored after stopping.
+		if len(enc.frames) != 4 || len(enc.pcm) != 2 || !enc.closed {
+			t.Fatalf("expected 4 frames and sound got %d %d", len(enc.frames), len(enc.pcm))
+		}
+		if size := enc.frames[3].Rect.Size(); size != image.Pt(16, 8) {
+			t.Errorf("expected even frame size got %v", size)
+		}
+	})
+
+	// go test -run Record/resample
+	t.Run("resample", func(t *testing.T) {
+		st := &soundTap{}
+		st.write([]float32{0, 0, 1, -1}, recordRate/2)
+		st.write([]float32{1, -1}, recordRate/2)
+		pcm := st.take()
+		if len(pcm) != 5*2 || pcm[2] != 16383 || pcm[3] != -16383 || pcm[4] != 32767 {
+			t.Errorf("expected doubled sample rate got %v", pcm)
+		}
+		if st.take() != nil {
+			t.Errorf("expected taken samples to be cleared")
+		}
+	})
+
+	// go test -run Record/mkv
+	t.Run("mkv", func(t *testing.T) {
+		out := &closeBuffer{}
+		enc := &mkvEncoder{w: out, fps: 10, hasSound: true}
+		enc.sound([]int16{1, 2, 3, 4})
+		for i := 0; i < 3; i++ {
+			if err := enc.frame(frame(16, 8)); err != nil {
+				t.Fatal(err)
+			}
+			enc.sound(make([]int16, 2*recordRate/10))
+		}
+		if err := enc.close(); err != nil || !out.closed {
+			t.Fatalf("expected closed recording %v", err)
+		}
+		vid, err := load.Webm(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames, sounds := []load.VideoPacket{}, []load.VideoPacket{}
+		for {
+			p, audio, err := vid.Next()
+			if err != nil {
+				break
+			}
+			if audio {
+				sounds = append(sounds, p)
+			} else {
+				frames = append(frames, p)
+			}
+		}
+		if vid.Codec != "mjpeg" || vid.Width != 16 || vid.Height != 8 || vid.FPS != 10 || len(frames) != 3 {
+			t.Fatalf("expected 3 motion jpeg frames got %s %d", vid.Codec, len(frames))
+		}
+		if frames[2].Time != 200*time.Millisecond {
+			t.Errorf("expected frame times got %v", frames[2].Time)
+		}
+		if vid.AudioCodec != "pcm" || vid.Rate != recordRate || vid.Channels != 2 || vid.SampleBits != 16 || len(sounds) != 4 {
+			t.Fatalf("expected pcm sound got %s %d", vid.AudioCodec, len(sounds))
+		}
+		if sounds[3].Time != 200*time.Millisecond || sounds[0].Data[2] != 2 {
+			t.Errorf("expected sound times got %v", sounds[3].Time)
+		}
+		open := func() (*load.VideoData, videoSource, error) {
+			vs, err := load.Webm(bytes.NewReader(out.Bytes()))
+			return &vs.VideoData, vs, err
+		}
+		if _, _, sound, err := openVideo("test.mkv", open); err != nil || sound == nil {
+			t.Errorf("expected playable recording got %v", err)
+		}
+	})
+
+	// go test -run Record/ffmpeg
+	t.Run("ffmpeg", func(t *testing.T) {
+		fe := &ffmpegEncoder{ffmpeg: "ffmpeg", path: "play.webm", fps: 30}
+		args := strings.Join(fe.videoArgs("play.video.webm", 640, 480), " ")
+		if !strings.Contains(args, "-s 640x480 -framerate 30 -i -") || !strings.Contains(args, "libvpx-vp9") ||
+			!strings.HasSuffix(args, "yuv420p play.video.webm") {
+			t.Errorf("unexpected video arguments %s", args)
+		}
+		if _, err := newVideoEncoder("play.avi", 30, false); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unsupported file type got %v", err)
+		}
+		eng := &Engine{}
+		if eng.StartRecording("play.mkv", 0) == nil || eng.Recording() || eng.StopRecording() != nil {
+			t.Errorf("expected invalid fps error")
+		}
+	})
+}
+
+// mockEncoder collects the recording.
+type mockEncoder struct {
+	frames []*image.NRGBA
+	pcm    []int16
+	closed bool
+}
+
+func (me *mockEncoder) frame(img *image.NRGBA) error { me.frames = append(me.frames, img); return nil }
+func (me *mockEncoder) sound(pcm []int16) error      { me.pcm = append(me.pcm, pcm...); return nil }
+func (me *mockEncoder) close() error                 { me.closed = true; return nil }
+
+// closeBuffer is an in memory recording file.
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (cb *closeBuffer) Close() error { cb.closed = true; return nil }
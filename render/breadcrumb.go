@@ -0,0 +1,93 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// breadcrumb.go keeps a CPU side record of the last render passes
+// submitted to the GPU. When the GPU device is lost, eg: a driver timeout
+// and reset (TDR), or when the GPU stops finishing frames, the breadcrumbs
+// are logged to show which pass, shader, and model were in flight, eg:
+//
+//	level=ERROR msg="gpu breadcrumb" frame=1042 pass=3D draws=87 shader=pbr mesh=12 tag=305
+//
+// The tag is the packet tag, usually the entity ID, of the last packet
+// drawn in the pass. Debug builds also label the passes and shader
+// pipelines so that GPU debuggers and crash dumps show the same names.
+
+import (
+	"log/slog"
+	"time"
+)
+
+// maxBreadcrumbs is the number of submitted passes remembered.
+// Enough for the frames that can be in flight.
+const maxBreadcrumbs = 8
+
+// stallTimeout is how long the GPU can go without finishing
+// a frame before the breadcrumbs are logged.
+const stallTimeout = 2 * time.Second
+
+// breadcrumb records a render pass submitted to the GPU.
+type breadcrumb struct {
+	frame  uint64 // frame number.
+	pass   PassID // render pass.
+	draws  int    // draw calls recorded in the pass.
+	shader string // last shader bound in the pass.
+	mesh   uint32 // last mesh drawn in the pass.
+	tag    uint32 // last packet tag drawn in the pass.
+}
+
+// breadcrumbs is a ring of the most recently submitted passes.
+type breadcrumbs struct {
+	ring    [maxBreadcrumbs]breadcrumb
+	next    int           // next ring slot.
+	count   int           // number of breadcrumbs, up to maxBreadcrumbs.
+	stalled time.Duration // time waiting on the GPU to finish a frame.
+	logged  bool          // true once a stall was logged.
+}
+
+// add records a submitted pass, replacing the oldest breadcrumb.
+func (b *breadcrumbs) add(crumb breadcrumb) {
+	b.ring[b.next] = crumb
+	b.next = (b.next + 1) % maxBreadcrumbs
+	b.count = min(b.count+1, maxBreadcrumbs)
+}
+
+// list returns the breadcrumbs from oldest to newest.
+func (b *breadcrumbs) list() []breadcrumb {
+	crumbs := make([]breadcrumb, 0, b.count)
+	for i := b.count; i > 0; i-- {
+		crumbs = append(crumbs, b.ring[(b.next-i+maxBreadcrumbs)%maxBreadcrumbs])
+	}
+	return crumbs
+}
+
+// wait tracks the time spent waiting for the GPU to finish a frame.
+// The breadcrumbs are logged once if the GPU stalls for too long.
+// Returns true the first time a stall is detected.
+func (b *breadcrumbs) wait(waited time.Duration, finished bool) bool {
+	if finished {
+		b.stalled, b.logged = 0, false
+		return false
+	}
+	b.stalled += waited
+	if b.stalled < stallTimeout || b.logged {
+		return false
+	}
+	b.logged = true
+	b.log("gpu stalled", "waiting", b.stalled)
+	return true
+}
+
+// log writes the breadcrumbs, oldest first, after the given reason.
+func (b *breadcrumbs) log(reason string, args ...any) {
+	slog.Error(reason, args...)
+	names := []string{Pass3D: "3D", Pass2D: "2D"}
+	for _, c := range b.list() {
+		pass := "?"
+		if int(c.pass) < len(names) {
+			pass = names[c.pass]
+		}
+		slog.Error("gpu breadcrumb", "frame", c.frame, "pass", pass, "draws", c.draws,
+			"shader", c.shader, "mesh", c.mesh, "tag", c.tag)
+	}
+}
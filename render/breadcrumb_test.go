@@ -0,0 +1,45 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Breadcrumbs
+func TestBreadcrumbs(t *testing.T) {
+	t.Run("ring", func(t *testing.T) {
+		b := breadcrumbs{}
+		if len(b.list()) != 0 {
+			t.Fatalf("expected no breadcrumbs")
+		}
+		for i := 0; i < maxBreadcrumbs+3; i++ {
+			b.add(breadcrumb{frame: uint64(i), pass: PassID(i % 2)})
+		}
+		crumbs := b.list()
+		if len(crumbs) != maxBreadcrumbs {
+			t.Fatalf("expected %d breadcrumbs got %d", maxBreadcrumbs, len(crumbs))
+		}
+		if crumbs[0].frame != 3 || crumbs[len(crumbs)-1].frame != maxBreadcrumbs+2 {
+			t.Fatalf("expected oldest first got %d..%d", crumbs[0].frame, crumbs[len(crumbs)-1].frame)
+		}
+	})
+	t.Run("stall", func(t *testing.T) {
+		b := breadcrumbs{}
+		step := 16 * time.Millisecond
+		stalls := 0
+		for i := 0; i < int(2*stallTimeout/step); i++ {
+			if b.wait(step, false) {
+				stalls++
+			}
+		}
+		if stalls != 1 {
+			t.Fatalf("expected one stall report got %d", stalls)
+		}
+		b.wait(step, true) // frame finished.
+		if b.stalled != 0 || b.logged {
+			t.Fatalf("expected stall reset")
+		}
+	})
+}
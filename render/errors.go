@@ -0,0 +1,38 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// errors.go defines the render errors that applications can check
+// using errors.Is and errors.As. Missing GPU features are reported
+// by wrapping errors.ErrUnsupported.
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDeviceLost is returned once the GPU device has been lost,
+	// eg: a driver crash or reset. The render context can not be
+	// used afterwards and needs to be recreated.
+	ErrDeviceLost = errors.New("gpu device lost")
+
+	// ErrShaderCompile is wrapped by ShaderError when
+	// a shader could not be turned into a GPU pipeline.
+	ErrShaderCompile = errors.New("shader compile failed")
+)
+
+// ShaderError is returned by LoadShader when the GPU rejects a shader.
+type ShaderError struct {
+	Shader string // shader name.
+	Log    string // failure details, eg: shader stage file and driver result.
+	Err    error  // underlying error, may be nil.
+}
+
+// Error implements error.
+func (e *ShaderError) Error() string {
+	return fmt.Sprintf("shader %s: %s", e.Shader, e.Log)
+}
+
+// Unwrap matches ErrShaderCompile and the underlying error.
+func (e *ShaderError) Unwrap() []error { return []error{ErrShaderCompile, e.Err} }
@@ -0,0 +1,27 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// go test -run Errors
+func TestErrors(t *testing.T) {
+	t.Run("shader", func(t *testing.T) {
+		cause := errors.New("driver result")
+		err := fmt.Errorf("LoadShader: %w", &ShaderError{Shader: "pbr", Log: "pbr.vert.spv: invalid", Err: cause})
+		if !errors.Is(err, ErrShaderCompile) || !errors.Is(err, cause) {
+			t.Errorf("expected ErrShaderCompile and cause got %v", err)
+		}
+		var serr *ShaderError
+		if !errors.As(err, &serr) || serr.Shader != "pbr" {
+			t.Errorf("expected ShaderError got %v", err)
+		}
+		if errors.Is(&ShaderError{Shader: "pbr"}, ErrDeviceLost) {
+			t.Errorf("unexpected ErrDeviceLost")
+		}
+	})
+}
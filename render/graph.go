@@ -0,0 +1,78 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// graph.go describes the render passes drawn in a frame along with the
+// render targets that each pass reads and writes. The frame graph can be
+// exported as JSON or as a graphviz DOT graph, eg:
+//
+//	dot -Tsvg frame.dot -o frame.svg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FrameGraph returns the render passes and render targets
+// used to draw the last frame.
+func (c *Context) FrameGraph() FrameGraph { return c.renderer.frameGraph() }
+
+// FrameGraph describes how a frame was drawn.
+type FrameGraph struct {
+	Passes  []GraphPass   `json:"passes"`  // render passes in draw order.
+	Targets []GraphTarget `json:"targets"` // render targets used by the passes.
+}
+
+// GraphPass is a render pass in a frame graph.
+type GraphPass struct {
+	Name      string        `json:"name"`            // render pass name, eg: "3D".
+	Reads     []string      `json:"reads,omitempty"` // targets loaded from earlier passes.
+	Writes    []string      `json:"writes"`          // targets written by the pass.
+	Packets   int           `json:"packets"`         // packets submitted to the pass.
+	DrawCalls int           `json:"draws"`           // draw commands in the pass.
+	GPU       time.Duration `json:"gpu_ns"`          // GPU time, from an earlier frame.
+}
+
+// GraphTarget is a render target image in a frame graph.
+type GraphTarget struct {
+	Name    string `json:"name"`    // target name, eg: "depth".
+	Format  string `json:"format"`  // render API image format.
+	Width   uint32 `json:"width"`   // image size in pixels.
+	Height  uint32 `json:"height"`  //  ""
+	Present bool   `json:"present"` // true if the target is displayed.
+}
+
+// JSON returns the frame graph as indented JSON.
+func (g FrameGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DOT returns the frame graph as a graphviz DOT graph. Passes are
+// ellipses and targets are boxes. Dashed edges show the pass order.
+func (g FrameGraph) DOT() string {
+	sb := &strings.Builder{}
+	sb.WriteString("digraph frame {\n\trankdir=LR;\n")
+	for _, t := range g.Targets {
+		fmt.Fprintf(sb, "\t%q [shape=box label=\"%s\\n%s %dx%d\"];\n", "target_"+t.Name, t.Name, t.Format, t.Width, t.Height)
+		if t.Present {
+			fmt.Fprintf(sb, "\tpresent [shape=plaintext];\n\t%q -> present;\n", "target_"+t.Name)
+		}
+	}
+	for i, p := range g.Passes {
+		ms := float64(p.GPU) / float64(time.Millisecond)
+		fmt.Fprintf(sb, "\t%q [label=\"%d %s\\n%d draws %.2fms\"];\n", "pass_"+p.Name, i+1, p.Name, p.DrawCalls, ms)
+		for _, t := range p.Reads {
+			fmt.Fprintf(sb, "\t%q -> %q;\n", "target_"+t, "pass_"+p.Name)
+		}
+		for _, t := range p.Writes {
+			fmt.Fprintf(sb, "\t%q -> %q;\n", "pass_"+p.Name, "target_"+t)
+		}
+		if i > 0 {
+			fmt.Fprintf(sb, "\t%q -> %q [style=dashed];\n", "pass_"+g.Passes[i-1].Name, "pass_"+p.Name)
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
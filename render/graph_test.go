@@ -0,0 +1,51 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run FrameGraph
+func TestFrameGraph(t *testing.T) {
+	g := FrameGraph{
+		Passes: []GraphPass{
+			{Name: "3D", Writes: []string{"color", "depth"}, DrawCalls: 12, GPU: 2 * time.Millisecond},
+			{Name: "2D", Reads: []string{"color"}, Writes: []string{"color"}, DrawCalls: 3},
+		},
+		Targets: []GraphTarget{
+			{Name: "color", Format: "B8G8R8A8_SRGB", Width: 800, Height: 600, Present: true},
+			{Name: "depth", Format: "D32_SFLOAT", Width: 800, Height: 600},
+		},
+	}
+	t.Run("json", func(t *testing.T) {
+		data, err := g.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := FrameGraph{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Passes) != 2 || got.Passes[0].GPU != g.Passes[0].GPU || got.Passes[1].Reads[0] != "color" {
+			t.Errorf("json round trip failed %s", data)
+		}
+	})
+	t.Run("dot", func(t *testing.T) {
+		dot := g.DOT()
+		for _, expect := range []string{
+			`"pass_3D" -> "target_depth";`,
+			`"target_color" -> "pass_2D";`,
+			`"pass_3D" -> "pass_2D" [style=dashed];`,
+			`"target_color" -> present;`,
+			`1 3D\n12 draws 2.00ms`,
+		} {
+			if !strings.Contains(dot, expect) {
+				t.Errorf("expected %s in\n%s", expect, dot)
+			}
+		}
+	})
+}
@@ -17,6 +17,9 @@ type Packet struct {
 	// packet (model) uniform data.
 	Uniforms map[load.PacketUniform][]byte
 
+	// bone matrices for animated models, 64 bytes per bone.
+	Bones []byte
+
 	// used to draw instanced meshes.
 	IsInstanced   bool   // true for instanced models.
 	InstanceID    uint32 // GPU instance data reference.
@@ -25,6 +28,12 @@ type Packet struct {
 	// Rendering hints.
 	Tag    uint32 // Application tag (entity ID) for debugging.
 	Bucket uint64 // Used to sort packets. Lower buckets rendered first.
+	Scope  uint8  // GPU profile scope. Zero uses the render pass scope.
+
+	// Occlusion culling. Models with an occlusion ID are not drawn
+	// if their bounding box packet, with the same ID, was hidden.
+	Occlusion        uint32 // Occlusion ID, usually the entity ID. Zero if not culled.
+	IsOcclusionQuery bool   // true for the model bounding box packet.
 }
 
 // Reset clears the old render packet so it can be reused.
@@ -37,6 +46,10 @@ func (p *Packet) Reset() {
 	p.InstanceCount = 0             //
 	p.Tag = 0                       //
 	p.Bucket = 0                    //
+	p.Scope = 0                     //
+	p.Occlusion = 0                 //
+	p.IsOcclusionQuery = false      //
+	p.Bones = p.Bones[:0]           // reset, keeping memory
 
 	// reset the uniform data.
 	for i := load.PacketUniform(0); i < load.PacketUniforms; i++ {
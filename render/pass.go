@@ -17,6 +17,21 @@ const (
 	Pass2D               // 2D renderpass rendered next
 )
 
+// MaxViews is the number of passes of each PassID that can be drawn
+// in one frame, eg: four 3D viewports for four player split screen.
+const MaxViews = 4
+
+// MaxTargets is the number of 3D passes that can draw into render
+// targets in one frame, see Context.LoadTarget.
+const MaxTargets = 4
+
+// Viewport is the part of the window drawn by a render pass as
+// fractions of the window size, where 0,0 is the top left corner.
+// The zero value draws the whole window.
+type Viewport struct {
+	X, Y, W, H float32
+}
+
 // NewPass initializes a render pass.
 // The returned Pass is expected to be reused in render loops.
 func NewPass() Pass {
@@ -27,7 +42,13 @@ func NewPass() Pass {
 }
 
 // Pass contains a group of Packets for rendering in this render pass.
+// Passes with the same ID are drawn in the order given, each into its
+// own viewport using its own uniform data. 3D passes with a Target are
+// drawn into the render target before any of the window passes.
 type Pass struct {
+	ID       PassID   // 3D or 2D render pass.
+	Viewport Viewport // window area drawn by this pass.
+	Target   uint32   // 3D render target, 0 for the window.
 
 	// Packets are a reusable list of packets, one per model.
 	Packets  Packets
@@ -5,7 +5,9 @@ package render
 // render.go provides API wrappers for the render specific APIs.
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"log/slog"
 	"math"
 	"time"
@@ -23,6 +25,8 @@ type RenderAPI int
 // support OpenGL or any DirectX versions before DX12. Unlikely futures include:
 //   - Nintendo    NVN - proprietary...unlikely to ship golang to this platform.
 //   - Playstation GNM - proprietary...unlikely to ship golang to this platform.
+//   - WebGL2 is OpenGL ES and not planned. A js/wasm target would more likely
+//     be WebGPU called through syscall/js, along with a browser device platform.
 const (
 	VULKAN_RENDERER RenderAPI = iota // windows, linux, android
 	DX12_RENDERER                    // FUTURE: xbox
@@ -39,7 +43,7 @@ func New(api RenderAPI, dev *device.Device, appTitle string) (rc *Context, err e
 		}
 		return &Context{renderer: vr}, nil
 	}
-	return nil, fmt.Errorf("unsupported render API: %d", api)
+	return nil, fmt.Errorf("%w: render API %d", errors.ErrUnsupported, api)
 }
 
 // Context holds data for the rendering system and wraps the API
@@ -67,6 +71,9 @@ func (c *Context) Draw(passes []Pass, dt time.Duration) (err error) {
 
 	// an error in beginFrame may not be a problem.
 	if err = c.renderer.beginFrame(dt); err != nil {
+		if errors.Is(err, ErrDeviceLost) {
+			return fmt.Errorf("render.BeginFrame: %w", err)
+		}
 		slog.Debug("beginFrame", "error", err)
 		return nil // ignore this frame and keep going
 	}
@@ -82,6 +89,17 @@ func (c *Context) Draw(passes []Pass, dt time.Duration) (err error) {
 	return nil
 }
 
+// Capture reads back the next frame drawn to the main window. The done
+// callback is given the frame image from a later Draw, once the GPU has
+// finished the frame, so that capturing does not stall rendering. Returns
+// an error wrapping errors.ErrUnsupported if frames can't be read back.
+func (c *Context) Capture(done func(img *image.NRGBA)) error {
+	if c.renderer == nil {
+		return fmt.Errorf("renderer not intiialized")
+	}
+	return c.renderer.capture(done)
+}
+
 // Resize updates the graphics resources to the given size.
 // Expected to be called when the user resizes the app window.
 func (c *Context) Resize(width, height uint32) { c.renderer.resize(width, height) }
@@ -89,10 +107,51 @@ func (c *Context) Resize(width, height uint32) { c.renderer.resize(width, height
 // Size returns the current render surface size.
 func (c *Context) Size() (width, height uint32) { return c.renderer.size() }
 
+// AddWindow creates the render resources needed to draw to a device
+// window opened with device.OpenWindow. Windows share the meshes,
+// textures, and shaders loaded for the main window.
+func (c *Context) AddWindow(win uint32) error { return c.renderer.addWindow(win) }
+
+// DropWindow releases the render resources for a window added with
+// AddWindow. Expected to be called before the device window is closed.
+func (c *Context) DropWindow(win uint32) { c.renderer.dropWindow(win) }
+
+// DrawWindow renders the given render passes to a window added with
+// AddWindow. Window 0 is the main window, see Draw.
+func (c *Context) DrawWindow(win uint32, passes []Pass, dt time.Duration) (err error) {
+	if c.renderer == nil || !c.renderer.useWindow(win) {
+		return fmt.Errorf("DrawWindow: unknown window %d", win)
+	}
+	defer c.renderer.useWindow(0)
+	return c.Draw(passes, dt)
+}
+
+// ResizeWindow updates the graphics resources of the given window.
+// Window 0 is the main window, see Resize.
+func (c *Context) ResizeWindow(win, width, height uint32) {
+	if c.renderer.useWindow(win) {
+		c.renderer.resize(width, height)
+		c.renderer.useWindow(0)
+	}
+}
+
+// WindowSize returns the current render surface size of the
+// given window. Returns zeros for unknown windows.
+func (c *Context) WindowSize(win uint32) (width, height uint32) {
+	if c.renderer.useWindow(win) {
+		width, height = c.renderer.size()
+		c.renderer.useWindow(0)
+	}
+	return width, height
+}
+
 // LoadTexture creates GPU texture resources and uploads
-// texture data to the GPU.
+// texture data to the GPU. Large textures are uploaded in the
+// background and are not drawn until the upload completes.
+// Linear images are uploaded as data instead of sRGB colors.
+// Cube images are uploaded as cubemaps for shader samplerCube uniforms.
 func (c *Context) LoadTexture(img *load.ImageData) (tid uint32, err error) {
-	return c.renderer.loadTexture(img.Width, img.Height, img.Pixels)
+	return c.renderer.loadTexture(img.Width, img.Height, img.Pixels, img.Linear, img.Cube)
 }
 
 // UpdateTexture updates the GPU texture data for the given texture ID.
@@ -103,11 +162,27 @@ func (c *Context) UpdateTexture(tid uint32, img *load.ImageData) (err error) {
 	return c.renderer.updateTexture(tid, img.Width, img.Height, img.Pixels)
 }
 
-// DropTexture removes the GPU texture resources
-// for the given texture ID.
+// DropTexture removes the GPU texture resources for the given
+// texture ID. The resources are released once the frames that may be
+// using the texture have finished. The texture ID may then be reused.
 func (c *Context) DropTexture(tid uint32) { c.renderer.dropTexture(tid) }
 
-// LoadMeshes allocates GPU resources for the mesh data.
+// LoadTarget creates a render target that 3D passes draw into instead
+// of the window, see Pass.Target. The whole target is drawn, ignoring
+// the pass viewport. The target color image is also the texture tid so
+// that window models can sample what was drawn, eg: water reflections.
+// Models drawn into a target must not sample the same target.
+func (c *Context) LoadTarget(width, height uint32) (target, tid uint32, err error) {
+	return c.renderer.loadTarget(width, height)
+}
+
+// DropTarget removes the render target and its texture once the
+// frames that may be using them have finished.
+func (c *Context) DropTarget(target uint32) { c.renderer.dropTarget(target) }
+
+// LoadMeshes allocates GPU resources for the mesh data. Large
+// meshes are uploaded in the background and are not drawn until
+// the upload completes.
 func (c *Context) LoadMeshes(msh []load.MeshData) (mids []uint32, err error) {
 	return c.renderer.loadMeshes(msh)
 }
@@ -121,7 +196,17 @@ func (c *Context) LoadMesh(msh load.MeshData) (mid uint32, err error) {
 	return mids[0], nil
 }
 
-// DropMesh discards the mesh resources.
+// UpdateMesh replaces the GPU mesh data for the given mesh ID.
+// Do not update meshes that are being rendered. Double buffer the meshes
+// to update a mesh and then swap for the rendered mesh. UpdateMesh ignores
+// mesh data with more elements than the original mesh data or with
+// different data strides. Used for meshes that change every frame.
+func (c *Context) UpdateMesh(mid uint32, msh load.MeshData) (err error) {
+	return c.renderer.updateMesh(mid, msh)
+}
+
+// DropMesh discards the mesh resources once the frames that may be
+// using the mesh have finished. The mesh ID may then be reused.
 func (c *Context) DropMesh(mid uint32) { c.renderer.dropMesh(mid) }
 
 // LoadInstanceData allocates GPU resources for the instanced mesh data.
@@ -145,6 +230,64 @@ func (c *Context) LoadShader(config *load.Shader) (sid uint16, err error) {
 	return c.renderer.loadShader(config)
 }
 
+// DropShader discards the shader resources once the frames that may
+// be using the shader have finished.
+func (c *Context) DropShader(sid uint16) { c.renderer.dropShader(sid) }
+
+// GPUTimes returns the GPU time for each profile scope, indexed by
+// scope. The given slice is reused if it has space for MaxGPUScopes.
+// The times are from an earlier frame, see Stats.
+func (c *Context) GPUTimes(times []time.Duration) []time.Duration {
+	if cap(times) < MaxGPUScopes {
+		times = make([]time.Duration, MaxGPUScopes)
+	}
+	times = times[:MaxGPUScopes]
+	copy(times, c.renderer.gpuTimes())
+	return times
+}
+
+// GPU profile scopes time groups of draw calls using GPU timestamps.
+// Packets are assigned a scope using Packet.Scope. Packets without a
+// scope are timed as part of their render pass scope. Applications
+// number their own scopes starting from ScopeApp.
+const (
+	Scope3D      uint8 = 1  // 3D render pass packets without a scope.
+	Scope2D      uint8 = 2  // 2D render pass packets without a scope.
+	ScopeApp     uint8 = 3  // first application scope.
+	MaxGPUScopes       = 32 // scopes are less than MaxGPUScopes.
+)
+
+// Stats returns the render statistics for the last drawn frame.
+// The GPU time is measured using timestamp queries and is from
+// an earlier frame since the GPU renders behind the CPU.
+func (c *Context) Stats() Stats { return c.renderer.stats() }
+
+// Stats are render statistics for a single frame.
+type Stats struct {
+	DrawCalls    int           // number of draw commands.
+	Triangles    int           // triangles drawn, including instances.
+	ShaderBinds  int           // number of shader pipeline changes.
+	TextureBinds int           // number of material texture binds.
+	GPU          time.Duration // GPU render time. Zero if not supported.
+	Memory       uint64        // GPU memory allocated by the renderer.
+}
+
+// VSync controls how rendered frames are presented to the display.
+type VSync int
+
+// VSync modes. Modes that are not supported by the display fall back
+// to VSyncOn which is supported by all displays.
+const (
+	VSyncMailbox  VSync = iota // default: replace waiting frames at vertical blank. Low latency without tearing.
+	VSyncOn                    // queue frames for vertical blank. Lowest power, more latency.
+	VSyncAdaptive              // queue frames for vertical blank, present late frames immediately.
+	VSyncOff                   // present frames immediately. Lowest latency, may tear.
+)
+
+// SetVSync changes how frames are presented. The swapchain
+// is recreated using the new present mode on the next frame.
+func (c *Context) SetVSync(mode VSync) { c.renderer.setVSync(mode) }
+
 // SetClearColor sets the color that is used to clear the display.
 func (c *Context) SetClearColor(r, g, b, a float32) {
 	c.renderer.setClearColor(r, g, b, a)
@@ -158,6 +301,14 @@ type Loader interface {
 	LoadMesh(msh load.MeshData) (mid uint32, err error)
 	LoadMeshes(mdata []load.MeshData) (mids []uint32, err error)
 	LoadShader(config *load.Shader) (mid uint16, err error)
+	LoadTarget(width, height uint32) (target, tid uint32, err error)
+
+	// release GPU resources that are no longer used.
+	DropTexture(tid uint32)
+	DropTarget(target uint32)
+	DropMesh(mid uint32)
+	DropShader(sid uint16)
+	DropInstanceData(iid uint32)
 
 	// FUTURE: LoadAnimation
 
@@ -177,17 +328,33 @@ type renderAPI interface {
 	beginFrame(deltaTime time.Duration) error
 	drawFrame(passes []Pass) error
 	endFrame(deltaTime time.Duration) error
+	stats() Stats              // statistics for the last frame.
+	gpuTimes() []time.Duration // GPU time for each profile scope.
+	frameGraph() FrameGraph    // render passes for the last frame.
+
+	// read back the next main window frame.
+	capture(done func(img *image.NRGBA)) error
 
 	// render resize controls.
 	size() (width, height uint32) // returns current size
 	resize(width, height uint32)  // request size change
 	isResizing() bool             // true when size is updating.
+	setVSync(mode VSync)          // change present mode.
+
+	// windows other than the main window 0.
+	addWindow(win uint32) error // create window resources.
+	dropWindow(win uint32)      // release window resources.
+	useWindow(win uint32) bool  // target window for frame and resize calls.
 
 	// create a GPU texture and upload the mesh data.
-	loadTexture(w, h uint32, pixels []byte) (tid uint32, err error)
+	loadTexture(w, h uint32, pixels []byte, linear, cube bool) (tid uint32, err error)
 	updateTexture(tid, w, h uint32, pixels []byte) (err error)
 	dropTexture(tid uint32) // release texture resources
 
+	// create a color and depth image that 3D passes can draw into.
+	loadTarget(w, h uint32) (target, tid uint32, err error)
+	dropTarget(target uint32) // release target and texture resources
+
 	// create a GPU shader using the given shader configuration
 	loadShader(config *load.Shader) (sid uint16, err error)
 	dropShader(sid uint16) // release shader resources
@@ -195,7 +362,7 @@ type renderAPI interface {
 	// create GPU meshes by uploading the mesh vertex data.
 	// return an identifier for each mesh.
 	loadMeshes(msh []load.MeshData) (mid []uint32, err error)
-	// FUTURE: updateMesh() replace mesh with new mesh data.
+	updateMesh(mid uint32, msh load.MeshData) (err error)
 	dropMesh(mid uint32)
 
 	// load instance data for an instanced mesh.
@@ -243,12 +410,15 @@ func (us uniformSets) hasUniform(name string) bool {
 //
 // Due to buffer alignment when setting data.
 // The MinUniformBufferOffsetAlignment is at worst 256 bytes
-// so create each uniform buffer of 256 bytes and complain if
-// the uniform data exceeds this.
+// so create each uniform buffer of a multiple of 256 bytes and
+// complain if the uniform data exceeds this.
 const (
-	maxSceneUniformBytes    = 256 // scene data fits in 256 bytes
+	maxSceneUniformBytes    = 512 // scene data fits in 512 bytes
 	maxMaterialUniformBytes = 256 // material data fits in 256 bytes
 	maxModelUniformBytes    = 128 // model data fits in 128 bytes
+
+	// scene uniform data is kept for each pass drawn in a frame.
+	sceneSlots = 2*MaxViews + MaxTargets // 3D, 2D, and render target passes.
 )
 
 // genUniforms creates shaderUniforms from shader the configuration.
@@ -384,21 +554,22 @@ func (m *m4) toBytes() []byte {
 
 // =============================================================================
 
-// Light holds the location and color for a directional light.
-// Effectively 2 float32 vec4 for 32 bytes.
+// Light holds the location, color, and shape for a scene light.
+// Effectively 5 float32 vec4 for 80 bytes.
 type Light struct {
-	X, Y, Z, W float32 // location - W is 1 for point light, 0 for directional.
-	R, G, B    float32 // color
-	Intensity  float32 // light intensity
+	X, Y, Z, W float32    // location or direction - W is the vu light type.
+	R, G, B    float32    // color
+	Intensity  float32    // light intensity
+	DX, DY, DZ float32    // spot and area light facing direction.
+	Range      float32    // distance where the light stops, 0 for unlimited.
+	RX, RY, RZ float32    // area light width axis.
+	Falloff    float32    // attenuation profile.
+	Shape      [4]float32 // spot cone cosines or area light half sizes.
 }
 
 // reset the light data before reusing the light struct.
 // Called internally from pass.Reset()
-func (l *Light) reset() {
-	l.X, l.Y, l.Z, l.W = 0.0, 0.0, 0.0, 0.0
-	l.R, l.G, l.B = 0.0, 0.0, 0.0
-	l.Intensity = 0.0
-}
+func (l *Light) reset() { *l = Light{} }
 
 // LightsToBytes converts a slice of lights to bytes.
 // The given byte slice is zeroed and returned filled with the given light data.
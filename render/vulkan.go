@@ -10,10 +10,15 @@ package render
 // knows it, and no amount of file reorg seems to help if one does not.
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
 	"log/slog"
 	"math"
 	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -26,24 +31,22 @@ import (
 // vulkanRenderer contains vulkan specific rendering information.
 // Variables are grouped by the function that initializes them.
 type vulkanRenderer struct {
-	title       string     // application name.
-	clear       [4]float32 // rgba clear color.
-	frameWidth  uint32     // current app size
-	frameHeight uint32     //  ""
+	title string     // application name.
+	clear [4]float32 // rgba clear color.
 
-	// display resizing
-	resizeWidth         uint32 // new size when resizing, 0 afterwards
-	resizeHeight        uint32 //  ""
-	resizesRequested    uint64 // track outstanding resize requests.
-	resizesCompleted    uint64 //  ""
-	recreatingSwapchain bool   // true when updating size.
+	// the window being drawn. The main window is always present,
+	// other windows share the renderer GPU resources.
+	*vulkanView               // active window.
+	main        *vulkanView   // main application window.
+	windows     []*vulkanView // other windows.
+	lastView    *vulkanView   // window of the last submitted frame.
+	lastFence   vk.Fence      // fence of the last submitted frame.
 
 	// createInstance initializes the root of the vulkan hierarchy.
 	instance vk.Instance // vulkan root
 
 	// createSurface links the vulkan instance to an OS display
-	osdev   *device.Device // injected in activate()
-	surface vk.SurfaceKHR  // device specific.
+	osdev *device.Device // injected in activate()
 
 	// selectPhysicalDevice selects a GPU
 	physicalDevice         vk.PhysicalDevice
@@ -60,19 +63,92 @@ type vulkanRenderer struct {
 	presentQ  vk.Queue  //  ""
 
 	// setRenderProperties
-	surfaceFormat      vk.SurfaceFormatKHR            // chosen surface format
-	surfacePresentMode vk.PresentModeKHR              // chosen present mode
-	surfaceTransform   vk.SurfaceTransformFlagBitsKHR // surface transform flags
-	depthFormat        vk.Format                      // 3D requires depth
-	frameCount         uint32                         // two frames
-	imageCount         uint32                         // three swapchain images.
+	surfaceFormat vk.SurfaceFormatKHR // chosen surface format
+	vsync         VSync               // requested vsync mode
+	depthFormat   vk.Format           // 3D requires depth
+	frameCount    uint32              // two frames
+	imageCount    uint32              // three swapchain images.
 
 	// createCommandPools
 	graphicsQCmdPool vk.CommandPool // graphics queue command pool
 
 	// createRenderpasses
-	render3D             vk.RenderPass    // world render pass.
-	render2D             vk.RenderPass    // UI overlay pass.
+	render3D     vk.RenderPass // world render pass.
+	render2D     vk.RenderPass // UI overlay pass.
+	renderTarget vk.RenderPass // world render pass drawn into a texture.
+
+	// render frame statistics.
+	frameStats      Stats                       // counted while drawing each frame.
+	scopeTimes      [MaxGPUScopes]time.Duration // GPU time for each profile scope.
+	timestampPeriod float32                     // nanoseconds per GPU timestamp tick.
+	memory          atomic.Uint64               // allocated GPU memory, changed by the upload goroutine.
+	passPackets     [2]int                      // packets submitted to each render pass.
+	passDraws       [2]int                      // draw calls in each render pass.
+	passTimes       [2]time.Duration            // GPU time for each render pass.
+
+	// occlusion culling hides models whose bounding boxes had no samples.
+	occluded map[uint32]bool // occlusion IDs hidden in an earlier frame.
+
+	// mesh vertex attribute buffers.
+	vertexBuffers []vulkanBuffer // non-interleaved.
+	// instanced model data buffers.
+	instanceBuffers []vulkanBuffer // non-interleaved.
+	// animated model bone matrices.
+	bones vulkanBones // vulkan_bones.go
+
+	// uploads copy new textures and meshes to the GPU in the background.
+	// The queue lock synchronizes queue access with the upload goroutine.
+	uploader      vulkanUploader  // upload goroutine data.
+	pendingMeshes map[uint32]bool // meshes that are still uploading.
+	queueLock     sync.Mutex      // guards graphics and present queues.
+
+	// application GPU resources.
+	meshes    []vulkanMesh     // application GPU mesh data
+	textures  []vulkanTexture  // application GPU texture data
+	shaders   []vulkanShader   // shaders - one pipeline per shader.
+	instances []vulkanInstance // application GPU instance data
+	targets   []vulkanTarget   // application render targets.
+	drawn     []uint32         // render targets drawn in the last frame.
+
+	// dropped resources are released once the frames that may be using
+	// them have finished. Released mesh and texture IDs are reused.
+	drops        []vulkanDrop // resources waiting to be released.
+	submitted    uint64       // number of frames submitted.
+	crumbs       breadcrumbs  // last submitted passes for GPU crashes.
+	labels       bool         // true if debug labels are enabled.
+	freeMeshes   []uint32     // released mesh IDs.
+	freeTextures []uint32     // released texture IDs.
+	freeInsts    []uint32     // released instance data IDs.
+	freeTargets  []uint32     // released render target IDs.
+
+	// frame read backs requested for the next frame, see vulkan_capture.go.
+	captureFns []func(img *image.NRGBA)
+}
+
+// vulkanView holds the surface, swapchain, and frame resources for
+// one window. The renderer fields for the active window are promoted
+// from the embedded view, eg: vr.swapchain.
+type vulkanView struct {
+	win         uint32 // device window, 0 for the main window.
+	frameWidth  uint32 // current window size
+	frameHeight uint32 //  ""
+
+	// display resizing
+	resizeWidth         uint32 // new size when resizing, 0 afterwards
+	resizeHeight        uint32 //  ""
+	resizesRequested    uint64 // track outstanding resize requests.
+	resizesCompleted    uint64 //  ""
+	recreatingSwapchain bool   // true when updating size.
+
+	// createSurface links the vulkan instance to an OS window.
+	surface vk.SurfaceKHR // device specific.
+
+	// setRenderProperties
+	surfacePresentMode vk.PresentModeKHR              // chosen present mode
+	surfaceTransform   vk.SurfaceTransformFlagBitsKHR // surface transform flags
+	captures           bool                           // true if swapchain images can be copied.
+
+	// createFramebuffers
 	render3DFramebuffers []vk.Framebuffer // one framebuffer per swapchain image
 	render2DFramebuffers []vk.Framebuffer // one framebuffer per swapchain image
 
@@ -90,19 +166,56 @@ type vulkanRenderer struct {
 	frameIndex uint32          // index for frames - loop using mod maxFrames
 
 	// render frame dynamic state.
-	viewport vk.Viewport // same as frame size.
-	scissor  vk.Rect2D   // same as frame size.
+	viewport  vk.Viewport // same as frame size, or the pass viewport.
+	scissor   vk.Rect2D   // same as frame size, or the pass viewport.
+	sceneSlot uint32      // scene uniforms for the pass being drawn.
+}
 
-	// mesh vertex attribute buffers.
-	vertexBuffers []vulkanBuffer // non-interleaved.
-	// instanced model data buffers.
-	instanceBuffers []vulkanBuffer // non-interleaved.
+// vulkanDrop is a dropped resource waiting to be released.
+type vulkanDrop struct {
+	kind  dropKind // type of resource.
+	id    uint32   // mesh, texture, or shader ID.
+	after uint64   // frames submitted when the resource was dropped.
+}
 
-	// application GPU resources.
-	meshes    []vulkanMesh     // application GPU mesh data
-	textures  []vulkanTexture  // application GPU texture data
-	shaders   []vulkanShader   // shaders - one pipeline per shader.
-	instances []vulkanInstance // application GPU instance data
+// dropKind identifies the type of dropped resource.
+type dropKind uint8
+
+const (
+	dropMeshKind dropKind = iota
+	dropTextureKind
+	dropShaderKind
+	dropInstanceKind
+	dropTargetKind
+)
+
+// releaseDrops releases the dropped resources that are no longer used
+// by any frame. All drops are released when idle is true, ie: after
+// waiting for the device to be idle.
+func (vr *vulkanRenderer) releaseDrops(idle bool) {
+	frames := uint64(len(vr.frames)) * uint64(1+len(vr.windows)) // frames in flight.
+	keep := vr.drops[:0]
+	for _, d := range vr.drops {
+		if !idle && vr.submitted < d.after+frames {
+			keep = append(keep, d) // may still be in use.
+			continue
+		}
+		switch d.kind {
+		case dropMeshKind:
+			vr.disposeMesh(d.id)
+		case dropTextureKind:
+			vr.disposeTexture(d.id)
+		case dropShaderKind:
+			vr.disposeShader(&vr.shaders[d.id])
+			vr.shaders[d.id].materials = nil
+			vr.shaders[d.id].nextMaterialID = 0
+		case dropInstanceKind:
+			vr.freeInsts = append(vr.freeInsts, d.id)
+		case dropTargetKind:
+			vr.disposeTarget(d.id)
+		}
+	}
+	vr.drops = keep
 }
 
 // vkEnabledLayers can be modified by debug builds
@@ -110,9 +223,15 @@ type vulkanRenderer struct {
 var vkEnabledLayers []string = []string{} // enabled vulkan layers
 var addValidationLayer func([]string) ([]string, error) = func(layers []string) ([]string, error) { return layers, nil }
 
+// addDebugLabels can be overridden by debug builds to enable the
+// debug utils extension used to label passes and pipelines.
+var addDebugLabels func([]string) ([]string, bool) = func(extensions []string) ([]string, bool) { return extensions, false }
+
 // getVulkanRenderer acquires the vulkan resources needed to render scenes.
 func getVulkanRenderer(dev *device.Device, title string) (vr *vulkanRenderer, err error) {
 	vr = &vulkanRenderer{}
+	vr.main = &vulkanView{}
+	vr.vulkanView = vr.main
 	vr.title = title
 	vr.osdev = dev
 	vr.frameWidth, vr.frameHeight = vr.osdev.SurfaceSize() // initial size
@@ -126,6 +245,7 @@ func getVulkanRenderer(dev *device.Device, title string) (vr *vulkanRenderer, er
 		vr.selectPhysicalDevice, // one physical device selected
 		vr.createLogicalDevice,  // one logical device with queues
 		vr.createCommandPools,   // one pool per queue family
+		vr.createUploader,       // upload goroutine with its own pool.
 
 		// render properties and swapchain are set on initialization
 		// and updated and/or recreated on a window resize.
@@ -140,6 +260,7 @@ func getVulkanRenderer(dev *device.Device, title string) (vr *vulkanRenderer, er
 		vr.createFramebuffers,
 		vr.createVertexBuffers,
 		vr.createInstanceBuffers,
+		vr.createBoneBuffer,
 	}
 	for _, create := range createFunctions {
 		if err := create(); err != nil {
@@ -157,15 +278,26 @@ func getVulkanRenderer(dev *device.Device, title string) (vr *vulkanRenderer, er
 // dispose releases vulkan resources in the opposite order
 // they were allocated..
 func (vr *vulkanRenderer) dispose() {
+	vr.disposeUploader()
 	if vr.device != 0 {
 		vk.DeviceWaitIdle(vr.device)
 	}
+	for _, v := range vr.windows {
+		vr.disposeView(v)
+	}
+	vr.windows = nil
+	vr.vulkanView = vr.main
 
 	// remove application allocated resources.
+	vr.disposeBoneBuffer()
 	vr.disposeInstanceBuffers()
 	vr.disposeVertexBuffers()
+	vr.releaseDrops(true)
+	for i := range vr.targets {
+		vr.disposeTarget(uint32(i + 1))
+	}
 	for i := range vr.textures {
-		vr.dropTexture(uint32(i))
+		vr.disposeTexture(uint32(i))
 	}
 	for sid := range vr.shaders {
 		vr.disposeShader(&vr.shaders[sid])
@@ -181,6 +313,10 @@ func (vr *vulkanRenderer) dispose() {
 		vk.DestroyRenderPass(vr.device, vr.render2D, nil)
 		vr.render2D = 0
 	}
+	if vr.renderTarget != 0 {
+		vk.DestroyRenderPass(vr.device, vr.renderTarget, nil)
+		vr.renderTarget = 0
+	}
 
 	// swapchain and related resources: image views, depthbuffer
 	// Also scraps all application mesh data.
@@ -213,6 +349,8 @@ func (vr *vulkanRenderer) createInstance() (err error) {
 	if err != nil {
 		return err
 	}
+	extensions, labels := addDebugLabels(vr.instanceExtensions()) // vulkan_debug.go
+	vr.labels = labels
 
 	// create the vulkan instance.
 	instanceInfo := vk.InstanceCreateInfo{
@@ -224,10 +362,22 @@ func (vr *vulkanRenderer) createInstance() (err error) {
 			ApiVersion:         vk.API_VERSION_1_2,
 		},
 		PpEnabledLayerNames:     vkEnabledLayers,
-		PpEnabledExtensionNames: vr.instanceExtensions(), // vulkan_windows.go
+		PpEnabledExtensionNames: extensions, // vulkan_windows.go
 	}
-	vr.instance, err = vk.CreateInstance(&instanceInfo, nil)
-	return err
+	if vr.instance, err = vk.CreateInstance(&instanceInfo, nil); err != nil {
+		return err
+	}
+
+	// debug label commands are extension commands that
+	// need to be looked up once the instance exists.
+	if vr.labels {
+		vr.labels = vk.LoadInstanceCommands(vr.instance, "vkCmdBeginDebugUtilsLabelEXT",
+			"vkCmdEndDebugUtilsLabelEXT", "vkSetDebugUtilsObjectNameEXT")
+		if !vr.labels {
+			slog.Warn("vulkan debug label commands not available")
+		}
+	}
+	return nil
 }
 
 // selectPhysicalDevice finds a physical device for 3D rendering.
@@ -342,7 +492,7 @@ func (vr *vulkanRenderer) selectPhysicalDevice() error {
 			"transferQ", vr.transferQIndex)
 		return nil // found a physical device.
 	}
-	return fmt.Errorf("no physical device found")
+	return fmt.Errorf("%w: no physical device found", errors.ErrUnsupported)
 }
 
 // createLogicalDevice
@@ -462,14 +612,11 @@ func (vr *vulkanRenderer) setRenderProperties() (err error) {
 		slog.Warn("vulkan using default surface format")
 	}
 
+	// swapchain images that can be copied allow frames to be read back.
+	vr.captures = surface.capabilities.SupportedUsageFlags&vk.ImageUsageFlags(vk.IMAGE_USAGE_TRANSFER_SRC_BIT) != 0
+
 	// find the best present mode.
-	vr.surfacePresentMode = vk.PRESENT_MODE_FIFO_KHR // always exists.
-	for _, mode := range surface.presentModes {
-		if mode == vk.PRESENT_MODE_MAILBOX_KHR {
-			vr.surfacePresentMode = mode
-			break
-		}
-	}
+	vr.surfacePresentMode = choosePresentMode(surface.presentModes, vr.vsync)
 
 	// remember the surface transform
 	vr.surfaceTransform = surface.capabilities.CurrentTransform // default
@@ -570,6 +717,10 @@ func (vr *vulkanRenderer) disposeSwapchainResources() {
 // createSwapchain initializes the underlying render image frames.
 func (vr *vulkanRenderer) createSwapchain() (err error) {
 	extent := vk.Extent2D{Width: vr.frameWidth, Height: vr.frameHeight}
+	usage := vk.IMAGE_USAGE_COLOR_ATTACHMENT_BIT
+	if vr.captures {
+		usage |= vk.IMAGE_USAGE_TRANSFER_SRC_BIT // frame read back.
+	}
 
 	// create the swapchain using a shared queue or
 	// separate queues for graphics and presentation
@@ -581,7 +732,7 @@ func (vr *vulkanRenderer) createSwapchain() (err error) {
 		ImageColorSpace:  vr.surfaceFormat.ColorSpace,
 		ImageExtent:      extent,
 		ImageArrayLayers: 1,
-		ImageUsage:       vk.IMAGE_USAGE_COLOR_ATTACHMENT_BIT,
+		ImageUsage:       usage,
 		ImageSharingMode: vk.SHARING_MODE_EXCLUSIVE,
 		PreTransform:     vr.surfaceTransform,
 		CompositeAlpha:   vk.COMPOSITE_ALPHA_OPAQUE_BIT_KHR,
@@ -612,7 +763,7 @@ func (vr *vulkanRenderer) createSwapchain() (err error) {
 func (vr *vulkanRenderer) createImageViews() (err error) {
 	vr.views = make([]vk.ImageView, len(vr.images))
 	for i := range vr.images {
-		vr.views[i], err = vr.createImageView(vr.images[i], vr.surfaceFormat.Format, vk.IMAGE_ASPECT_COLOR_BIT)
+		vr.views[i], err = vr.createImageView(vr.images[i], 1, vr.surfaceFormat.Format, vk.IMAGE_ASPECT_COLOR_BIT)
 		if err != nil {
 			return fmt.Errorf("createFrameImageView: %w", err)
 		}
@@ -631,7 +782,7 @@ func (vr *vulkanRenderer) createDepthBuffer() (err error) {
 	if err != nil {
 		return err
 	}
-	vr.depthImage.view, err = vr.createImageView(vr.depthImage.handle, vr.depthFormat, vk.IMAGE_ASPECT_DEPTH_BIT)
+	vr.depthImage.view, err = vr.createImageView(vr.depthImage.handle, 1, vr.depthFormat, vk.IMAGE_ASPECT_DEPTH_BIT)
 	if err != nil {
 		return err
 	}
@@ -695,6 +846,30 @@ func (vr *vulkanRenderer) createRenderpasses() (err error) {
 	}
 	vr.render3D, err = vk.CreateRenderPass(vr.device, &renderpassInfo, nil)
 
+	// the render target pass matches the 3D pass so that it can use the
+	// 3D shader pipelines. The color image is left ready for sampling by
+	// the window passes, which must wait for the target to be drawn.
+	renderpassInfo.PAttachments[0].FinalLayout = vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL
+	renderpassInfo.PDependencies = []vk.SubpassDependency{
+		{
+			SrcSubpass:    vk.SUBPASS_EXTERNAL,
+			DstSubpass:    0,
+			SrcStageMask:  vk.PIPELINE_STAGE_FRAGMENT_SHADER_BIT | vk.PIPELINE_STAGE_LATE_FRAGMENT_TESTS_BIT,
+			SrcAccessMask: vk.ACCESS_DEPTH_STENCIL_ATTACHMENT_WRITE_BIT,
+			DstStageMask:  vk.PIPELINE_STAGE_COLOR_ATTACHMENT_OUTPUT_BIT | vk.PIPELINE_STAGE_EARLY_FRAGMENT_TESTS_BIT,
+			DstAccessMask: vk.ACCESS_COLOR_ATTACHMENT_WRITE_BIT | vk.ACCESS_DEPTH_STENCIL_ATTACHMENT_WRITE_BIT,
+		},
+		{
+			SrcSubpass:    0,
+			DstSubpass:    vk.SUBPASS_EXTERNAL,
+			SrcStageMask:  vk.PIPELINE_STAGE_COLOR_ATTACHMENT_OUTPUT_BIT,
+			SrcAccessMask: vk.ACCESS_COLOR_ATTACHMENT_WRITE_BIT,
+			DstStageMask:  vk.PIPELINE_STAGE_FRAGMENT_SHADER_BIT,
+			DstAccessMask: vk.ACCESS_SHADER_READ_BIT,
+		},
+	}
+	vr.renderTarget, err = vk.CreateRenderPass(vr.device, &renderpassInfo, nil)
+
 	// create the 2D UI overlay renderpass
 	renderpassInfo = vk.RenderPassCreateInfo{
 		PAttachments: []vk.AttachmentDescription{
@@ -795,10 +970,14 @@ func (vr *vulkanRenderer) disposeFramebuffers() {
 // mesh vertex data and triangle index data. Currently allocating:
 //   - 50Mb for vertex position
 //   - 33Mb for vertex texcoords
+//   - 33Mb for vertex lightmap texcoords
 //   - 12Mb for vertex colors
 //   - 50Mb for vertex normals
 //   - 16Mb for indexes
-//   - Total 161Mb
+//   - Total 194Mb
+//
+// The 134Mb of joint and weight buffers for animated meshes are
+// allocated later, see createSkinBuffers.
 func (vr *vulkanRenderer) createVertexBuffers() (err error) {
 	vr.vertexBuffers = make([]vulkanBuffer, load.VertexTypes)
 	flags := vk.BUFFER_USAGE_VERTEX_BUFFER_BIT | vk.BUFFER_USAGE_TRANSFER_DST_BIT | vk.BUFFER_USAGE_TRANSFER_SRC_BIT
@@ -821,6 +1000,13 @@ func (vr *vulkanRenderer) createVertexBuffers() (err error) {
 		return fmt.Errorf("createBuffers:texcoord %w", err)
 	}
 
+	// vertex lightmap texcoords
+	buff = &vr.vertexBuffers[load.Texcoords2] // V2 float32
+	size = 2 * 4 * space                      // 2-float32 * 4-bytes * lots of space.
+	if err = vr.createBuffer(buff, size, flags, props); err != nil {
+		return fmt.Errorf("createBuffers:texcoord2 %w", err)
+	}
+
 	// vertex colors
 	buff = &vr.vertexBuffers[load.Colors] // V3 uint8
 	size = 3 * 1 * space                  // 3-uint8 * 1-bytes * lots of space.
@@ -837,8 +1023,6 @@ func (vr *vulkanRenderer) createVertexBuffers() (err error) {
 
 	// FUTURE:
 	// vertex load.Tangents V4 float32
-	// vertex load.Weights  V4 uint8    animations
-	// vertex load.Joints   V4 uint8    animations
 
 	// triangle indexes
 	buff = &vr.vertexBuffers[load.Indexes] // uint16
@@ -850,6 +1034,36 @@ func (vr *vulkanRenderer) createVertexBuffers() (err error) {
 	return nil
 }
 
+// createSkinBuffers creates the vertex buffers for animated meshes the
+// first time they are needed by a mesh or shader, so that applications
+// without animated models don't use the memory. Allocating:
+//   - 67Mb for vertex joints
+//   - 67Mb for vertex joint weights
+//   - Total 134Mb
+func (vr *vulkanRenderer) createSkinBuffers() (err error) {
+	if vr.vertexBuffers[load.Joints].handle != 0 {
+		return nil // already created.
+	}
+	flags := vk.BUFFER_USAGE_VERTEX_BUFFER_BIT | vk.BUFFER_USAGE_TRANSFER_DST_BIT | vk.BUFFER_USAGE_TRANSFER_SRC_BIT
+	props := vk.MEMORY_PROPERTY_DEVICE_LOCAL_BIT
+	var space vk.DeviceSize = 2048 * 2048 // same space as the other vertex buffers.
+
+	// vertex joints for animations.
+	buff := &vr.vertexBuffers[load.Joints] // V4 float32
+	size := 4 * 4 * space                  // 4-float32 * 4-bytes * lots of space.
+	if err = vr.createBuffer(buff, size, flags, props); err != nil {
+		return fmt.Errorf("createBuffers:joint %w", err)
+	}
+
+	// vertex joint weights for animations.
+	buff = &vr.vertexBuffers[load.Weights] // V4 float32
+	if err = vr.createBuffer(buff, size, flags, props); err != nil {
+		vr.disposeBuffer(&vr.vertexBuffers[load.Joints])
+		return fmt.Errorf("createBuffers:weight %w", err)
+	}
+	return nil
+}
+
 // disposeVertexbuffers
 func (vr *vulkanRenderer) disposeVertexBuffers() {
 	for i := range vr.vertexBuffers {
@@ -920,6 +1134,42 @@ func (vr *vulkanRenderer) isResizing() bool {
 	return vr.resizesRequested != vr.resizesCompleted
 }
 
+// setVSync changes the present mode by recreating the window swapchains.
+func (vr *vulkanRenderer) setVSync(mode VSync) {
+	if mode == vr.vsync {
+		return
+	}
+	vr.vsync = mode
+	for _, v := range append([]*vulkanView{vr.main}, vr.windows...) {
+		if v.resizesRequested == v.resizesCompleted {
+			v.resizeWidth, v.resizeHeight = v.frameWidth, v.frameHeight
+			v.resizesRequested++ // same size, new present mode.
+		}
+	}
+}
+
+// choosePresentMode returns the present mode for the vsync mode,
+// falling back to FIFO which is always supported.
+func choosePresentMode(modes []vk.PresentModeKHR, vsync VSync) vk.PresentModeKHR {
+	prefer := []vk.PresentModeKHR{vk.PRESENT_MODE_MAILBOX_KHR}
+	switch vsync {
+	case VSyncOn:
+		prefer = nil
+	case VSyncAdaptive:
+		prefer = []vk.PresentModeKHR{vk.PRESENT_MODE_FIFO_RELAXED_KHR}
+	case VSyncOff:
+		prefer = []vk.PresentModeKHR{vk.PRESENT_MODE_IMMEDIATE_KHR, vk.PRESENT_MODE_MAILBOX_KHR}
+	}
+	for _, want := range prefer {
+		for _, mode := range modes {
+			if mode == want {
+				return mode
+			}
+		}
+	}
+	return vk.PRESENT_MODE_FIFO_KHR
+}
+
 // resizeSwapchain recreates everything that is affected by a size change.
 func (vr *vulkanRenderer) resizeSwapchain() (err error) {
 	if vr.recreatingSwapchain {
@@ -931,13 +1181,14 @@ func (vr *vulkanRenderer) resizeSwapchain() (err error) {
 	}
 	vr.recreatingSwapchain = true // stops rendering while recreating swapchain
 	// ---
-	vk.DeviceWaitIdle(vr.device)   // wait for idle before destroying swapchain
+	vr.waitIdle()                  // wait for idle before destroying swapchain
 	vr.disposeFramebuffers()       // delete the renderpass framebuffers.
 	vr.disposeSwapchainResources() // delete the swapchain.
 	surface := surfaceProperties{} // requery swapchain support
 	if err = vr.getSurfaceProperties(&surface, vr.physicalDevice); err != nil {
 		return err
 	}
+	vr.surfacePresentMode = choosePresentMode(surface.presentModes, vr.vsync)
 	vr.frameWidth = uint32(vr.resizeWidth)               // update to new size
 	vr.frameHeight = uint32(vr.resizeHeight)             //
 	vr.resizeWidth = 0                                   // mark resize as complete
@@ -960,6 +1211,7 @@ func (vr *vulkanRenderer) resizeSwapchain() (err error) {
 type vulkanBuffer struct {
 	handle vk.Buffer
 	memory vk.DeviceMemory
+	size   vk.DeviceSize // allocated memory size.
 }
 
 // createBuffer allocates a buffer, memory, and binds the buffer to the memory
@@ -993,6 +1245,8 @@ func (vr *vulkanRenderer) createBuffer(buff *vulkanBuffer, size vk.DeviceSize,
 	if buff.memory, err = vk.AllocateMemory(vr.device, &allocateInfo, nil); err != nil {
 		return fmt.Errorf("createBuff:vk.AllocateMemory: %w", err)
 	}
+	buff.size = memRequirements.Size
+	vr.memory.Add(uint64(buff.size))
 
 	// bind the buffer to the memory
 	if err = vk.BindBufferMemory(vr.device, buff.handle, buff.memory, 0); err != nil {
@@ -1006,6 +1260,8 @@ func (vr *vulkanRenderer) disposeBuffer(buff *vulkanBuffer) {
 	if buff.memory != 0 {
 		vk.FreeMemory(vr.device, buff.memory, nil)
 		buff.memory = 0
+		vr.memory.Add(-uint64(buff.size))
+		buff.size = 0
 	}
 	if buff.handle != 0 {
 		vk.DestroyBuffer(vr.device, buff.handle, nil)
@@ -1085,78 +1341,151 @@ type vulkanBuffData struct {
 	count  uint32 // number of elements, ie: vertexes, instances
 	stride uint32 // number of bytes per element.
 	offset uint32 // start location of data in the buffer.
+	space  uint32 // number of elements allocated in the buffer.
 }
 
 // loadMeshes stores mesh data in GPU buffers.
 // Buffers match the GLTF import data format.
 // Immutable once uploaded. There is only one vertex buffer for
 // all frames. Updating a mesh means adding a new one and refering to it
-// in future draw calls.
+// in future draw calls. The data is uploaded by the upload goroutine,
+// see vulkan_upload.go.
 //
 // FUTURE: handle buffer (de/re)allocates using linked lists.
 func (vr *vulkanRenderer) loadMeshes(meshes []load.MeshData) (mids []uint32, err error) {
-
-	// Add the vertex data at the end of the current mesh data.
-	// Get the current offsets for each vertex data type
-	startingOffsets := make([]uint32, load.VertexTypes) // tracks first offset.
-	for i := 0; i < load.VertexTypes; i++ {
-		if len(vr.meshes) > 0 {
-			prev := vr.meshes[len(vr.meshes)-1]
-			for i := 0; i < load.VertexTypes; i++ {
-				startingOffsets[i] = prev[i].offset + prev[i].stride*prev[i].count
+	job := &upload{}
+	staged := []byte{}
+	for _, msh := range meshes {
+		if msh[load.Joints].Count > 0 || msh[load.Weights].Count > 0 {
+			if err = vr.createSkinBuffers(); err != nil {
+				return nil, fmt.Errorf("loadMeshes: %w", err)
 			}
 		}
-	}
-
-	// cosolidate the upload data into a buffer for each vertex data type.
-	data := make([][]byte, load.VertexTypes)
-	for _, msh := range meshes {
+		mid, reused := vr.reuseMesh(msh)
+		if !reused {
+			// add the mesh data after the last mesh.
+			meshOffsets := make([]uint32, load.VertexTypes)
+			if len(vr.meshes) > 0 {
+				prev := vr.meshes[len(vr.meshes)-1]
+				for i := 0; i < load.VertexTypes; i++ {
+					meshOffsets[i] = prev[i].offset + prev[i].stride*prev[i].space
+				}
+			}
 
-		// add the mesh data after the last mesh.
-		meshOffsets := make([]uint32, load.VertexTypes)
-		if len(vr.meshes) > 0 {
-			prev := vr.meshes[len(vr.meshes)-1]
+			// track each mesh with a vulkan-mesh
+			vmsh := make(vulkanMesh, load.VertexTypes)
 			for i := 0; i < load.VertexTypes; i++ {
-				meshOffsets[i] = prev[i].offset + prev[i].stride*prev[i].count
+				// the previous offset is pushed forward for the vertex
+				// data types that are not used by this mesh.
+				vmsh[i].offset = meshOffsets[i]
+				if msh[i].Count > 0 {
+					vmsh[i].space = msh[i].Count
+					vmsh[i].stride = msh[i].Stride
+				}
 			}
+			mid = uint32(len(vr.meshes)) // mesh ID for the new mesh.
+			vr.meshes = append(vr.meshes, vmsh)
 		}
 
-		// track each mesh with a vulkan-mesh
-		vmsh := make(vulkanMesh, load.VertexTypes)
+		// stage the mesh data with one copy for each data type.
+		vmsh := vr.meshes[mid]
 		for i := 0; i < load.VertexTypes; i++ {
+			vmsh[i].count = msh[i].Count
 			if msh[i].Count > 0 {
-				vmsh[i].count = msh[i].Count
-				vmsh[i].stride = msh[i].Stride
-				vmsh[i].offset = meshOffsets[i]
-
-				// consolidate the upload data into temp buffers.
-				data[i] = append(data[i], msh[i].Data...)
+				job.copies = append(job.copies, vk.BufferCopy{
+					SrcOffset: vk.DeviceSize(len(staged)),
+					DstOffset: vk.DeviceSize(vmsh[i].offset),
+					Size:      vk.DeviceSize(len(msh[i].Data)),
+				})
+				job.dsts = append(job.dsts, vr.vertexBuffers[i].handle)
+				staged = append(staged, msh[i].Data...)
+			}
+		}
+		mids = append(mids, mid)
+	}
+	job.mids = mids
+	if len(staged) == 0 {
+		return mids, nil // nothing to upload.
+	}
+	if err = vr.stageUpload(job, staged); err != nil {
+		return nil, fmt.Errorf("loadMeshes: %w", err)
+	}
+	vr.startUpload(job)
+	return mids, nil
+}
 
-			} else {
-				// push forward the previous offset for the vertex data
-				// types that were not used by this mesh.
-				vmsh[i].offset = meshOffsets[i]
+// reuseMesh returns the ID of a released mesh that has space for
+// the given mesh data, or false if no released mesh is big enough.
+func (vr *vulkanRenderer) reuseMesh(msh load.MeshData) (mid uint32, ok bool) {
+	for f, free := range vr.freeMeshes {
+		vmsh, fits := vr.meshes[free], true
+		for i := 0; i < load.VertexTypes && fits; i++ {
+			if msh[i].Count > 0 {
+				fits = vmsh[i].stride == msh[i].Stride && vmsh[i].space >= msh[i].Count
 			}
 		}
-		mids = append(mids, uint32(len(vr.meshes))) // mesh ID for the new mesh.
-		vr.meshes = append(vr.meshes, vmsh)
+		if fits {
+			vr.freeMeshes = slices.Delete(vr.freeMeshes, f, f+1)
+			return free, true
+		}
+	}
+	return 0, false
+}
+
+// updateMesh : see docs on render:UpdateMesh
+func (vr *vulkanRenderer) updateMesh(mid uint32, msh load.MeshData) (err error) {
+	if int(mid) >= len(vr.meshes) {
+		return fmt.Errorf("updateMesh invalid ID: %d", mid)
+	}
+	vmsh := vr.meshes[mid]
+	if vr.pendingMeshes[mid] {
+		vr.flushUploads() // finish the initial upload first.
+	}
+
+	// check that the new data fits in the existing mesh space.
+	for i := 0; i < load.VertexTypes; i++ {
+		if msh[i].Count > vmsh[i].space {
+			return fmt.Errorf("updateMesh count exceeds space %d %d", msh[i].Count, vmsh[i].space)
+		}
+		if msh[i].Count > 0 && msh[i].Stride != vmsh[i].stride {
+			return fmt.Errorf("updateMesh stride mismatch %d %d", vmsh[i].stride, msh[i].Stride)
+		}
 	}
 
-	// upload the consolidated data once for each data type.
+	// everything fits, so re-upload data. The mesh offsets and space remain the same.
 	for i := 0; i < load.VertexTypes; i++ {
-		uploadData := data[i]
-		if len(uploadData) > 0 {
+		vmsh[i].count = msh[i].Count
+		if msh[i].Count > 0 {
 			buff := &vr.vertexBuffers[i]
-			offset := uint64(startingOffsets[i])
-			vr.uploadData(vr.graphicsQCmdPool, vr.graphicsQ, buff, offset, uploadData)
+			offset := uint64(vmsh[i].offset)
+			vr.uploadData(vr.graphicsQCmdPool, vr.graphicsQ, buff, offset, msh[i].Data)
 		}
 	}
-	return mids, nil
+	return nil // everything ok.
+}
+
+// dropMesh releases the mesh once the frames using it have finished.
+// The mesh buffer space is kept and reused by new meshes that fit in
+// the same space. The last mesh space is never released so that new
+// meshes can always be added after the last mesh.
+func (vr *vulkanRenderer) dropMesh(mid uint32) {
+	if mid >= uint32(len(vr.meshes)) {
+		slog.Error("dropMesh:invalid mesh ID", "mid", mid)
+		return
+	}
+	vr.drops = append(vr.drops, vulkanDrop{kind: dropMeshKind, id: mid, after: vr.submitted})
 }
 
-// FUTURE: handle deallocates using linked lists.
-// For now never deallocate so that the lastMesh is always valid.
-func (vr *vulkanRenderer) dropMesh(mid uint32) {}
+// disposeMesh makes the mesh space available for new meshes.
+func (vr *vulkanRenderer) disposeMesh(mid uint32) {
+	if vr.pendingMeshes[mid] {
+		vr.flushUploads() // can't reuse while uploading.
+	}
+	for i := range vr.meshes[mid] {
+		vr.meshes[mid][i].count = 0
+	}
+	vr.freeMeshes = append(vr.freeMeshes, mid)
+}
 
 // track instanced data as a number of buffers.
 type vulkanInstance []vulkanBuffData
@@ -1165,36 +1494,61 @@ type vulkanInstance []vulkanBuffData
 // Immutable once uploaded. There is only one instance buffer for
 // all frames. Updating instance data means adding a new data and
 // refering to it in future draw calls.
-//
-// FUTURE: handle instance data (de/re)allocates using linked lists.
+// Released instance data space is reused by new instance data that fits.
 func (vr *vulkanRenderer) loadInstanceData(data []load.Buffer) (iid uint32, err error) {
-	inst := make(vulkanInstance, load.InstanceTypes)
-
-	// add the instance data at the end of the buffer
-	offsets := make([]uint32, load.InstanceTypes)
-	if len(vr.instances) > 0 {
-		prev := vr.instances[len(vr.instances)-1]
+	iid, reused := vr.reuseInstanceData(data)
+	if !reused {
+		inst := make(vulkanInstance, load.InstanceTypes)
+
+		// add the instance data at the end of the buffer
+		offsets := make([]uint32, load.InstanceTypes)
+		if len(vr.instances) > 0 {
+			prev := vr.instances[len(vr.instances)-1]
+			for i := 0; i < load.InstanceTypes; i++ {
+				offsets[i] = prev[i].offset + prev[i].stride*prev[i].space
+			}
+		}
 		for i := 0; i < load.InstanceTypes; i++ {
-			offsets[i] = prev[i].offset + prev[i].stride*prev[i].count
+			inst[i].offset = offsets[i]
+			if data[i].Count > 0 {
+				inst[i].space = data[i].Count
+				inst[i].stride = data[i].Stride
+			}
 		}
+		iid = uint32(len(vr.instances)) // instance ID for the new instance data.
+		vr.instances = append(vr.instances, inst)
 	}
+	inst := vr.instances[iid]
 	for i := 0; i < load.InstanceTypes; i++ {
+		inst[i].count = data[i].Count
 		if data[i].Count > 0 {
-			inst[i].count = data[i].Count
-			inst[i].stride = data[i].Stride
-			inst[i].offset = offsets[i]
-
 			// upload data
 			buff := &vr.instanceBuffers[i]
 			offset := uint64(inst[i].offset)
 			vr.uploadData(vr.graphicsQCmdPool, vr.graphicsQ, buff, offset, data[i].Data)
 		}
 	}
-	iid = uint32(len(vr.instances)) // instance ID for the new instance data.
-	vr.instances = append(vr.instances, inst)
 	return iid, nil
 }
 
+// reuseInstanceData returns the ID of released instance data that has
+// space for the given data, or false if no released space is big enough.
+func (vr *vulkanRenderer) reuseInstanceData(data []load.Buffer) (iid uint32, ok bool) {
+	for f, free := range vr.freeInsts {
+		inst, fits := vr.instances[free], true
+		for i := 0; i < load.InstanceTypes && fits; i++ {
+			if data[i].Count > 0 {
+				fits = inst[i].stride == data[i].Stride && inst[i].space >= data[i].Count
+			}
+		}
+		if fits {
+			vr.freeInsts = slices.Delete(vr.freeInsts, f, f+1)
+			return free, true
+		}
+	}
+	return 0, false
+}
+
 // updateInstanceData : see docs on render:UpdateInstanceData
 func (vr *vulkanRenderer) updateInstanceData(iid uint32, data []load.Buffer) (err error) {
 	if int(iid) >= len(vr.instances) {
@@ -1224,9 +1578,15 @@ func (vr *vulkanRenderer) updateInstanceData(iid uint32, data []load.Buffer) (er
 	return nil // everything ok.
 }
 
-// FUTURE: handle deallocates using linked lists.
-// For now never deallocate so that the lastMesh is always valid.
-func (vr *vulkanRenderer) dropInstanceData(iid uint32) {}
+// dropInstanceData releases the instance data once the frames using it
+// have finished. The buffer space is reused by new instance data.
+func (vr *vulkanRenderer) dropInstanceData(iid uint32) {
+	if iid >= uint32(len(vr.instances)) {
+		slog.Error("dropInstanceData:invalid instance ID", "iid", iid)
+		return
+	}
+	vr.drops = append(vr.drops, vulkanDrop{kind: dropInstanceKind, id: iid, after: vr.submitted})
+}
 
 // drawMesh
 func (vr *vulkanRenderer) drawMesh(frame *vulkanFrame, mid uint32, attrs []load.ShaderAttribute) {
@@ -1320,8 +1680,10 @@ type vulkanImage struct {
 	handle vk.Image
 	view   vk.ImageView
 	memory vk.DeviceMemory
+	size   vk.DeviceSize // allocated memory size.
 	width  uint32
 	height uint32
+	layers uint32 // 6 for cubemaps, otherwise 1.
 }
 
 // create a vkCreateImage
@@ -1331,6 +1693,7 @@ func (vr *vulkanRenderer) createImage(img *vulkanImage,
 	memoryFlags vk.MemoryPropertyFlags) (err error) {
 
 	// create the requested image
+	img.layers = max(img.layers, 1)
 	imgInfo := vk.ImageCreateInfo{
 		ImageType: vk.IMAGE_TYPE_2D,
 		Extent: vk.Extent3D{
@@ -1339,7 +1702,7 @@ func (vr *vulkanRenderer) createImage(img *vulkanImage,
 			Depth:  1,
 		},
 		MipLevels:     4,
-		ArrayLayers:   1,
+		ArrayLayers:   img.layers,
 		Format:        format,
 		Tiling:        vk.IMAGE_TILING_OPTIMAL,
 		InitialLayout: vk.IMAGE_LAYOUT_UNDEFINED,
@@ -1347,6 +1710,9 @@ func (vr *vulkanRenderer) createImage(img *vulkanImage,
 		Samples:       vk.SAMPLE_COUNT_1_BIT,
 		SharingMode:   vk.SHARING_MODE_EXCLUSIVE,
 	}
+	if img.layers == 6 {
+		imgInfo.Flags = vk.ImageCreateFlags(vk.IMAGE_CREATE_CUBE_COMPATIBLE_BIT)
+	}
 	img.handle, err = vk.CreateImage(vr.device, &imgInfo, nil)
 	if err != nil {
 		return fmt.Errorf("vk.CreateImage: %w", err)
@@ -1368,6 +1734,8 @@ func (vr *vulkanRenderer) createImage(img *vulkanImage,
 	if err != nil {
 		return fmt.Errorf("vk.AllocateMemory: %w", err)
 	}
+	img.size = memReqs.Size
+	vr.memory.Add(uint64(img.size))
 	err = vk.BindImageMemory(vr.device, img.handle, img.memory, 0)
 	if err != nil {
 		return fmt.Errorf("vk.BindImageMemory: %w", err)
@@ -1375,31 +1743,42 @@ func (vr *vulkanRenderer) createImage(img *vulkanImage,
 	return nil
 }
 
-// create a vk.CreateImageView for the given image
-func (vr *vulkanRenderer) createImageView(img vk.Image, format vk.Format, aspectFlags vk.ImageAspectFlags) (view vk.ImageView, err error) {
+// create a vk.CreateImageView for the given image.
+// Images with 6 layers are viewed as cubemaps.
+func (vr *vulkanRenderer) createImageView(img vk.Image, layers uint32, format vk.Format, aspectFlags vk.ImageAspectFlags) (view vk.ImageView, err error) {
+	viewType := vk.IMAGE_VIEW_TYPE_2D
+	if layers == 6 {
+		viewType = vk.IMAGE_VIEW_TYPE_CUBE
+	}
 	createInfo := vk.ImageViewCreateInfo{
 		Image:    img,
-		ViewType: vk.IMAGE_VIEW_TYPE_2D,
+		ViewType: viewType,
 		Format:   format,
 		SubresourceRange: vk.ImageSubresourceRange{
 			AspectMask:     aspectFlags,
 			BaseMipLevel:   0,
 			LevelCount:     1,
 			BaseArrayLayer: 0,
-			LayerCount:     1,
+			LayerCount:     max(layers, 1),
 		},
 	}
 	return vk.CreateImageView(vr.device, &createInfo, nil)
 }
 
 // transitionImageLayout switches image layout,
-// see use in loadTexture.
+// see use in updateTexture.
 func (vr *vulkanRenderer) transitionImageLayout(img *vulkanImage, format vk.Format, oldLayout vk.ImageLayout, newLayout vk.ImageLayout) {
 	cmd, err := vr.beginSingleUseCommand(vr.graphicsQCmdPool)
 	if err != nil {
 		slog.Error("beginSingleUseCommand", "error", err)
 		return
 	}
+	vr.cmdImageLayout(cmd, img, oldLayout, newLayout)
+	vr.endSingleUseCommand(cmd, vr.graphicsQCmdPool, vr.graphicsQ)
+}
+
+// cmdImageLayout records an image layout switch.
+func (vr *vulkanRenderer) cmdImageLayout(cmd vk.CommandBuffer, img *vulkanImage, oldLayout vk.ImageLayout, newLayout vk.ImageLayout) {
 	barrier := vk.ImageMemoryBarrier{
 		SrcAccessMask:       0,
 		DstAccessMask:       0,
@@ -1413,7 +1792,7 @@ func (vr *vulkanRenderer) transitionImageLayout(img *vulkanImage, format vk.Form
 			BaseMipLevel:   0,
 			LevelCount:     1,
 			BaseArrayLayer: 0,
-			LayerCount:     1,
+			LayerCount:     max(img.layers, 1),
 		},
 	}
 	var sourceStage vk.PipelineStageFlags
@@ -1429,11 +1808,15 @@ func (vr *vulkanRenderer) transitionImageLayout(img *vulkanImage, format vk.Form
 		barrier.DstAccessMask = vk.ACCESS_SHADER_READ_BIT
 		sourceStage = vk.PIPELINE_STAGE_TRANSFER_BIT
 		destinationStage = vk.PIPELINE_STAGE_FRAGMENT_SHADER_BIT
+	case oldLayout == vk.IMAGE_LAYOUT_UNDEFINED && newLayout == vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL:
+		barrier.SrcAccessMask = 0 // render targets that have not been drawn.
+		barrier.DstAccessMask = vk.ACCESS_SHADER_READ_BIT
+		sourceStage = vk.PIPELINE_STAGE_TOP_OF_PIPE_BIT
+		destinationStage = vk.PIPELINE_STAGE_FRAGMENT_SHADER_BIT
 	default:
 		slog.Error("unsupported layout transition!")
 	}
 	vk.CmdPipelineBarrier(cmd, sourceStage, destinationStage, 0, nil, nil, []vk.ImageMemoryBarrier{barrier})
-	vr.endSingleUseCommand(cmd, vr.graphicsQCmdPool, vr.graphicsQ)
 }
 
 func (vr *vulkanRenderer) copyBufferToImage(buffer *vulkanBuffer, img *vulkanImage) {
@@ -1442,6 +1825,12 @@ func (vr *vulkanRenderer) copyBufferToImage(buffer *vulkanBuffer, img *vulkanIma
 		slog.Error("beginSingleUseCommand", "error", err)
 		return
 	}
+	vr.cmdCopyBufferToImage(cmd, buffer, img)
+	vr.endSingleUseCommand(cmd, vr.graphicsQCmdPool, vr.graphicsQ)
+}
+
+// cmdCopyBufferToImage records a copy of the buffer to the image.
+func (vr *vulkanRenderer) cmdCopyBufferToImage(cmd vk.CommandBuffer, buffer *vulkanBuffer, img *vulkanImage) {
 	region := vk.BufferImageCopy{
 		BufferOffset:      0,
 		BufferRowLength:   0,
@@ -1450,13 +1839,12 @@ func (vr *vulkanRenderer) copyBufferToImage(buffer *vulkanBuffer, img *vulkanIma
 			AspectMask:     vk.IMAGE_ASPECT_COLOR_BIT,
 			MipLevel:       0,
 			BaseArrayLayer: 0,
-			LayerCount:     1,
+			LayerCount:     max(img.layers, 1), // cubemap faces follow each other.
 		},
 		ImageOffset: vk.Offset3D{X: 0, Y: 0, Z: 0},
 		ImageExtent: vk.Extent3D{Width: img.width, Height: img.height, Depth: 1},
 	}
 	vk.CmdCopyBufferToImage(cmd, buffer.handle, img.handle, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL, []vk.BufferImageCopy{region})
-	vr.endSingleUseCommand(cmd, vr.graphicsQCmdPool, vr.graphicsQ)
 }
 
 func (vr *vulkanRenderer) disposeImage(img *vulkanImage) {
@@ -1467,6 +1855,8 @@ func (vr *vulkanRenderer) disposeImage(img *vulkanImage) {
 	if img.memory != 0 {
 		vk.FreeMemory(vr.device, img.memory, nil)
 		img.memory = 0
+		vr.memory.Add(-uint64(img.size))
+		img.size = 0
 	}
 	if img.handle != 0 {
 		vk.DestroyImage(vr.device, img.handle, nil)
@@ -1492,6 +1882,8 @@ func (vr *vulkanRenderer) findMemoryType(filter uint32, memFlags vk.MemoryProper
 type vulkanTexture struct {
 	image   vulkanImage
 	sampler vk.Sampler
+	format  vk.Format // sRGB for colors, UNORM for linear data.
+	pending bool      // true until the image upload completes.
 }
 
 // loadTexture stores image data in a GPU buffer
@@ -1499,58 +1891,66 @@ type vulkanTexture struct {
 //
 // CURRENT: immutable once uploaded. Updating a texture means adding a new
 // texture and refering to it in future draw calls.
+// The image is uploaded by the upload goroutine, see vulkan_upload.go.
+//
+// Linear textures hold data, eg: palette indexes, and are not
+// converted from sRGB when sampled. Cube textures are cubemaps
+// made from six square faces stacked from top to bottom.
 //
 // FUTURE - allow replacing textures.
-func (vr *vulkanRenderer) loadTexture(w, h uint32, pixels []byte) (tid uint32, err error) {
-	vr.textures = append(vr.textures, vulkanTexture{})
-	tid = uint32(len(vr.textures) - 1)
+func (vr *vulkanRenderer) loadTexture(w, h uint32, pixels []byte, linear, cube bool) (tid uint32, err error) {
+	if cube && h != 6*w {
+		return 0, fmt.Errorf("loadTexture cubemap needs 6 square faces got %d:%d", w, h)
+	}
+	tid = vr.nextTextureID()
 	tex := &vr.textures[tid]
 
 	// put image data into staging buffer
-	imageSize := vk.DeviceSize(len(pixels))
-	stagingBuffer := vulkanBuffer{}
-	err = vr.createBuffer(&stagingBuffer, imageSize, vk.BUFFER_USAGE_TRANSFER_SRC_BIT,
-		vk.MEMORY_PROPERTY_HOST_VISIBLE_BIT|vk.MEMORY_PROPERTY_HOST_COHERENT_BIT)
-	if err != nil {
-		vr.disposeBuffer(&stagingBuffer)
-		return 0, err
-	}
-	if err = vr.loadCPUBuffer(&stagingBuffer, 0, pixels); err != nil {
-		vr.disposeBuffer(&stagingBuffer)
+	job := &upload{texture: true, tid: tid}
+	if err = vr.stageUpload(job, pixels); err != nil {
 		return 0, err
 	}
 
-	// upload GPU image
+	// create the GPU image and upload in the background.
 	format := vk.FORMAT_R8G8B8A8_SRGB
-	tex.image.width = w
-	tex.image.height = h
+	if linear {
+		format = vk.FORMAT_R8G8B8A8_UNORM
+	}
+	tex.format = format
+	tex.image.width, tex.image.height, tex.image.layers = w, h, 1
+	if cube {
+		tex.image.height, tex.image.layers = w, 6
+	}
 	err = vr.createImage(&tex.image, format,
 		vk.IMAGE_USAGE_TRANSFER_DST_BIT|vk.IMAGE_USAGE_SAMPLED_BIT,
 		vk.MEMORY_PROPERTY_DEVICE_LOCAL_BIT)
 	if err != nil {
-		vr.disposeBuffer(&stagingBuffer)
+		vr.disposeBuffer(&job.staging)
 		return 0, err
 	}
-	vr.transitionImageLayout(&tex.image, format, vk.IMAGE_LAYOUT_UNDEFINED, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL)
-	vr.copyBufferToImage(&stagingBuffer, &tex.image)
-	vr.transitionImageLayout(&tex.image, format, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL, vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL)
-	vr.disposeBuffer(&stagingBuffer)
+	job.image = tex.image
+	vr.startUpload(job)
 
 	// create the texture view
-	tex.image.view, err = vr.createImageView(tex.image.handle, format, vk.IMAGE_ASPECT_COLOR_BIT)
+	tex.image.view, err = vr.createImageView(tex.image.handle, tex.image.layers, format, vk.IMAGE_ASPECT_COLOR_BIT)
 	if err != nil {
 		return 0, err
 	}
 
 	// create a sampler. These are immutable and can be shared
 	// by different shaders and pipelines.
+	// Cubemaps clamp so that the face edges don't wrap.
 	devProps := vk.GetPhysicalDeviceProperties(vr.physicalDevice)
+	address := vk.SAMPLER_ADDRESS_MODE_REPEAT
+	if cube {
+		address = vk.SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE
+	}
 	samplerInfo := vk.SamplerCreateInfo{
 		MagFilter:               vk.FILTER_LINEAR,
 		MinFilter:               vk.FILTER_LINEAR,
-		AddressModeU:            vk.SAMPLER_ADDRESS_MODE_REPEAT,
-		AddressModeV:            vk.SAMPLER_ADDRESS_MODE_REPEAT,
-		AddressModeW:            vk.SAMPLER_ADDRESS_MODE_REPEAT,
+		AddressModeU:            address,
+		AddressModeV:            address,
+		AddressModeW:            address,
 		AnisotropyEnable:        true,
 		MaxAnisotropy:           devProps.Limits.MaxSamplerAnisotropy,
 		BorderColor:             vk.BORDER_COLOR_INT_OPAQUE_BLACK,
@@ -1565,16 +1965,54 @@ func (vr *vulkanRenderer) loadTexture(w, h uint32, pixels []byte) (tid uint32, e
 	}
 	return tid, nil
 }
+
+// nextTextureID returns a released texture ID or a new texture ID.
+func (vr *vulkanRenderer) nextTextureID() (tid uint32) {
+	if n := len(vr.freeTextures); n > 0 {
+		tid = vr.freeTextures[n-1] // reuse a released texture ID.
+		vr.freeTextures = vr.freeTextures[:n-1]
+		return tid
+	}
+	vr.textures = append(vr.textures, vulkanTexture{})
+	return uint32(len(vr.textures) - 1)
+}
+
+// dropTexture releases the texture once the frames using it have finished.
 func (vr *vulkanRenderer) dropTexture(tid uint32) {
 	if tid >= uint32(len(vr.textures)) {
 		slog.Error("invalid texture ID", "tid", tid)
+		return
 	}
+	vr.drops = append(vr.drops, vulkanDrop{kind: dropTextureKind, id: tid, after: vr.submitted})
+}
+
+// disposeTexture releases the texture resources and forgets
+// the materials that use the texture so that the texture ID
+// can be reused.
+func (vr *vulkanRenderer) disposeTexture(tid uint32) {
 	tex := &vr.textures[tid]
+	if tex.pending {
+		vr.flushUploads() // can't drop while uploading.
+	}
+	if tex.image.handle == 0 && tex.sampler == 0 {
+		return // already released.
+	}
 	vr.disposeImage(&tex.image)
 	if tex.sampler != 0 {
 		vk.DestroySampler(vr.device, tex.sampler, nil)
 		tex.sampler = 0
 	}
+	for s := range vr.shaders {
+		shader := &vr.shaders[s]
+		for m := uint32(0); m < shader.nextMaterialID; m++ {
+			material := &shader.materials[m]
+			if slices.Contains(material.samplerSet, tid) {
+				material.samplerSet = material.samplerSet[:0]
+				clear(material.updated)
+			}
+		}
+	}
+	vr.freeTextures = append(vr.freeTextures, tid)
 }
 
 // updateTexture : see docs on render:UpdateTexture
@@ -1583,9 +2021,12 @@ func (vr *vulkanRenderer) updateTexture(tid, width, height uint32, pixels []byte
 		return fmt.Errorf("updateTexture invalid texture ID %d", tid)
 	}
 	tex := vr.textures[tid]
-	if tex.image.width != width || tex.image.height != height {
+	if tex.image.width != width || tex.image.height*tex.image.layers != height {
 		return fmt.Errorf("updateTexture expected image size %d:%d got %d:%d",
-			tex.image.width, tex.image.height, width, height)
+			tex.image.width, tex.image.height*tex.image.layers, width, height)
+	}
+	if tex.pending {
+		vr.flushUploads() // finish the initial upload first.
 	}
 
 	// put image data into staging buffer
@@ -1603,7 +2044,7 @@ func (vr *vulkanRenderer) updateTexture(tid, width, height uint32, pixels []byte
 	}
 
 	// upload GPU image
-	format := vk.FORMAT_R8G8B8A8_SRGB
+	format := tex.format
 	vr.transitionImageLayout(&tex.image, format, vk.IMAGE_LAYOUT_UNDEFINED, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL)
 	vr.copyBufferToImage(&stagingBuffer, &tex.image)
 	vr.transitionImageLayout(&tex.image, format, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL, vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL)
@@ -1611,6 +2052,136 @@ func (vr *vulkanRenderer) updateTexture(tid, width, height uint32, pixels []byte
 	return nil
 }
 
+// =============================================================================
+// render targets are textures drawn by 3D passes.
+
+// vulkanTarget is a color texture and a depth image that
+// 3D passes draw into instead of the window.
+type vulkanTarget struct {
+	tid    uint32         // color image texture.
+	depth  vulkanImage    // 3D requires depth
+	frame  vk.Framebuffer // renderTarget framebuffer.
+	loaded bool           // false once the target is released.
+}
+
+// loadTarget creates a render target and its texture. The texture
+// starts out ready for sampling, even before the target is drawn.
+// Target IDs start at 1 since 0 is the window.
+func (vr *vulkanRenderer) loadTarget(w, h uint32) (target, tid uint32, err error) {
+	if w == 0 || h == 0 {
+		return 0, 0, fmt.Errorf("loadTarget invalid size %d:%d", w, h)
+	}
+	if n := len(vr.freeTargets); n > 0 {
+		target = vr.freeTargets[n-1] // reuse a released target ID.
+		vr.freeTargets = vr.freeTargets[:n-1]
+	} else {
+		vr.targets = append(vr.targets, vulkanTarget{})
+		target = uint32(len(vr.targets))
+	}
+	t := &vr.targets[target-1]
+	t.tid, t.loaded = vr.nextTextureID(), true
+	if err = vr.createTarget(t, w, h); err != nil {
+		vr.disposeTarget(target)
+		return 0, 0, err
+	}
+	return target, t.tid, nil
+}
+
+// createTarget creates the render target images, texture sampler,
+// and framebuffer.
+func (vr *vulkanRenderer) createTarget(t *vulkanTarget, w, h uint32) (err error) {
+	tex := &vr.textures[t.tid]
+	tex.format = vr.surfaceFormat.Format // must match the 3D pass.
+	tex.image.width, tex.image.height, tex.image.layers = w, h, 1
+	err = vr.createImage(&tex.image, tex.format,
+		vk.IMAGE_USAGE_COLOR_ATTACHMENT_BIT|vk.IMAGE_USAGE_SAMPLED_BIT,
+		vk.MEMORY_PROPERTY_DEVICE_LOCAL_BIT)
+	if err != nil {
+		return err
+	}
+	tex.image.view, err = vr.createImageView(tex.image.handle, 1, tex.format, vk.IMAGE_ASPECT_COLOR_BIT)
+	if err != nil {
+		return err
+	}
+	vr.transitionImageLayout(&tex.image, tex.format, vk.IMAGE_LAYOUT_UNDEFINED, vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL)
+
+	// clamp so that samples near the edges don't wrap.
+	samplerInfo := vk.SamplerCreateInfo{
+		MagFilter:               vk.FILTER_LINEAR,
+		MinFilter:               vk.FILTER_LINEAR,
+		AddressModeU:            vk.SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE,
+		AddressModeV:            vk.SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE,
+		AddressModeW:            vk.SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE,
+		BorderColor:             vk.BORDER_COLOR_INT_OPAQUE_BLACK,
+		UnnormalizedCoordinates: false,
+		CompareEnable:           false,
+		CompareOp:               vk.COMPARE_OP_ALWAYS,
+		MipmapMode:              vk.SAMPLER_MIPMAP_MODE_LINEAR,
+	}
+	if tex.sampler, err = vk.CreateSampler(vr.device, &samplerInfo, nil); err != nil {
+		return fmt.Errorf("vk.CreateSampler: %w", err)
+	}
+
+	// the target has its own depth image.
+	t.depth.width, t.depth.height = w, h
+	err = vr.createImage(&t.depth, vr.depthFormat,
+		vk.IMAGE_USAGE_DEPTH_STENCIL_ATTACHMENT_BIT,
+		vk.MEMORY_PROPERTY_DEVICE_LOCAL_BIT)
+	if err != nil {
+		return err
+	}
+	t.depth.view, err = vr.createImageView(t.depth.handle, 1, vr.depthFormat, vk.IMAGE_ASPECT_DEPTH_BIT)
+	if err != nil {
+		return err
+	}
+	frameInfo := vk.FramebufferCreateInfo{
+		RenderPass:   vr.renderTarget,
+		PAttachments: []vk.ImageView{tex.image.view, t.depth.view},
+		Width:        w,
+		Height:       h,
+		Layers:       1,
+	}
+	if t.frame, err = vk.CreateFramebuffer(vr.device, &frameInfo, nil); err != nil {
+		return fmt.Errorf("vk.CreateFramebuffer: %w", err)
+	}
+	return nil
+}
+
+// target returns the render target for the given target ID.
+// Returns nil if there is no such target.
+func (vr *vulkanRenderer) target(target uint32) *vulkanTarget {
+	if target == 0 || target > uint32(len(vr.targets)) || vr.targets[target-1].frame == 0 {
+		return nil
+	}
+	return &vr.targets[target-1]
+}
+
+// dropTarget releases the target once the frames using it have finished.
+func (vr *vulkanRenderer) dropTarget(target uint32) {
+	if vr.target(target) == nil {
+		slog.Error("invalid render target ID", "target", target)
+		return
+	}
+	vr.drops = append(vr.drops, vulkanDrop{kind: dropTargetKind, id: target, after: vr.submitted})
+}
+
+// disposeTarget releases the target resources, including
+// its texture, so that the target ID can be reused.
+func (vr *vulkanRenderer) disposeTarget(target uint32) {
+	t := &vr.targets[target-1]
+	if !t.loaded {
+		return // already released.
+	}
+	if t.frame != 0 {
+		vk.DestroyFramebuffer(vr.device, t.frame, nil)
+		t.frame = 0
+	}
+	vr.disposeImage(&t.depth)
+	vr.disposeTexture(t.tid)
+	*t = vulkanTarget{}
+	vr.freeTargets = append(vr.freeTargets, target)
+}
+
 // =============================================================================
 // shaders are GPU programs.
 
@@ -1623,12 +2194,14 @@ type vulkanShader struct {
 
 	// shader vertex attributes
 	attrs []load.ShaderAttribute
+	lines bool // true if the shader draws lines instead of triangles.
+	bones bool // true if the shader uses the bone buffer for animations.
 
 	// uniform information to help create and update descriptor sets.
 	usets uniformSets // shader uniform information
 
 	// scene uniform data buffers for all scene uniform descriptors
-	// indexed by vr.imageIndex
+	// indexed by vr.imageIndex and vr.sceneSlot.
 	sceneUniforms    vulkanBuffer // scene scope uniform data.
 	sceneUniformsMap *byte        // unsafe pointer to uniform mapped memory
 
@@ -1646,7 +2219,7 @@ type vulkanShader struct {
 	sceneLayout         vk.DescriptorSetLayout // scene uniforms per renderpass
 	materialLayout      vk.DescriptorSetLayout // material uniforms per object
 	descriptorPool      vk.DescriptorPool      // uniforms and samplers
-	sceneDescriptorSets []vk.DescriptorSet     // one per image.
+	sceneDescriptorSets []vk.DescriptorSet     // one per image and scene slot.
 	sceneUpdated        []bool                 // true if descriptor set updated.
 }
 
@@ -1679,6 +2252,7 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 	shader := vulkanShader{name: config.Name}
 	shader.attrs = append(shader.attrs, config.Attrs...)
 	shader.usets = getUniformSets(config.Uniforms)
+	_, shader.bones = shader.usets.index["bones"]
 	shader.maxMaterials = 256 // FUTURE: get from shader config.
 	vr.createShaderUniformBuffers(&shader)
 
@@ -1690,7 +2264,7 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 		stages = append(stages, vk.PipelineShaderStageCreateInfo{Stage: vk.SHADER_STAGE_FRAGMENT_BIT, PName: "main"})
 	default:
 		vr.disposeShader(&shader)
-		return 0, fmt.Errorf("unsupported shader stages %d", config.Stages)
+		return 0, fmt.Errorf("%w: shader stages %d", errors.ErrUnsupported, config.Stages)
 	}
 
 	// load the modules for the given shader stages
@@ -1705,6 +2279,18 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 		defer vk.DestroyShaderModule(vr.device, stages[i].Module, nil)
 	}
 
+	// animated shaders need the skinning buffers to draw.
+	for _, attr := range config.Attrs {
+		skin := attr.AttrType == load.Joints || attr.AttrType == load.Weights
+		if skin && attr.AttrScope == load.VertexAttribute {
+			if err = vr.createSkinBuffers(); err != nil {
+				vr.disposeShader(&shader)
+				return 0, err
+			}
+			break
+		}
+	}
+
 	// non-interleaved attribute descriptions
 	vertexAttrDescriptions := make([]vk.VertexInputAttributeDescription, len(config.Attrs))
 	vertexBindingDescriptions := make([]vk.VertexInputBindingDescription, len(config.Attrs))
@@ -1741,6 +2327,14 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 			StageFlags:      vk.SHADER_STAGE_VERTEX_BIT | vk.SHADER_STAGE_FRAGMENT_BIT,
 		}
 		bindings = append(bindings, binding)
+		if shader.bones {
+			bindings = append(bindings, vk.DescriptorSetLayoutBinding{
+				Binding:         1,
+				DescriptorType:  vk.DESCRIPTOR_TYPE_STORAGE_BUFFER,
+				DescriptorCount: 1,
+				StageFlags:      vk.SHADER_STAGE_VERTEX_BIT,
+			})
+		}
 		shader.sceneLayout, err = vk.CreateDescriptorSetLayout(
 			vr.device, &vk.DescriptorSetLayoutCreateInfo{PBindings: bindings}, nil)
 	}
@@ -1766,7 +2360,7 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 	// a descriptor set for each render image, normally 3.
 	shader.descriptorPool, err = vk.CreateDescriptorPool(vr.device,
 		&vk.DescriptorPoolCreateInfo{
-			MaxSets: 3 + 3*shader.maxMaterials + 3, // 3 scene sets + 3 per material.
+			MaxSets: 3*sceneSlots + 3*shader.maxMaterials + 3, // 3 scene sets per slot + 3 per material.
 			PPoolSizes: []vk.DescriptorPoolSize{
 				{
 					Typ:             vk.DESCRIPTOR_TYPE_UNIFORM_BUFFER,
@@ -1776,6 +2370,10 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 					Typ:             vk.DESCRIPTOR_TYPE_COMBINED_IMAGE_SAMPLER,
 					DescriptorCount: 4096,
 				},
+				{
+					Typ:             vk.DESCRIPTOR_TYPE_STORAGE_BUFFER,
+					DescriptorCount: vr.imageCount * sceneSlots, // bones buffer for each scene set.
+				},
 			},
 			Flags: vk.DESCRIPTOR_POOL_CREATE_FREE_DESCRIPTOR_SET_BIT, // | vk.DESCRIPTOR_POOL_CREATE_UPDATE_AFTER_BIND_BIT;
 		}, nil)
@@ -1784,10 +2382,10 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 		return 0, err
 	}
 
-	// allocate scene descriptor sets, one per image
+	// allocate scene descriptor sets, one per image and scene slot.
 	if shader.sceneLayout != 0 {
 		allocLayouts := []vk.DescriptorSetLayout{}
-		for i := 0; i < int(vr.imageCount); i++ {
+		for i := 0; i < int(vr.imageCount*sceneSlots); i++ {
 			allocLayouts = append(allocLayouts, shader.sceneLayout)
 		}
 		shader.sceneDescriptorSets, err = vk.AllocateDescriptorSets(vr.device,
@@ -1860,6 +2458,7 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 	}
 	if config.DrawLines {
 		inputAssembly.Topology = vk.PRIMITIVE_TOPOLOGY_LINE_LIST
+		shader.lines = true
 	}
 
 	// viewport and scissor are set as dynamic state later.
@@ -1902,6 +2501,9 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 		MinDepthBounds:        0,
 		MaxDepthBounds:        1.0,
 	}
+	if config.Occlusion {
+		depthStencil.DepthWriteEnable = false // test against the depth buffer only.
+	}
 
 	// describe how colors are written to the render image.
 	colorBlend := vk.PipelineColorBlendStateCreateInfo{
@@ -1927,6 +2529,9 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 			},
 		},
 	}
+	if config.Occlusion {
+		colorBlend.PAttachments[0].ColorWriteMask = 0 // only count samples.
+	}
 
 	// pipeline dynamic state can be changed without re-creating the pipeline
 	// Helps when resizing windows. Viewport and Scissor are expected to be set
@@ -1963,9 +2568,11 @@ func (vr *vulkanRenderer) loadShader(config *load.Shader) (sid uint16, err error
 	pipelines, err := vk.CreateGraphicsPipelines(vr.device, 0, []vk.GraphicsPipelineCreateInfo{pipelineInfo}, nil)
 	if err != nil {
 		vr.disposeShader(&shader)
-		return 0, fmt.Errorf("vk.CreateGraphicsPipeline: %w", err)
+		log := fmt.Sprintf("vk.CreateGraphicsPipeline: %s", err)
+		return 0, &ShaderError{Shader: config.Name, Log: log, Err: err}
 	}
 	shader.pipe = pipelines[0]
+	vr.nameObject(vk.OBJECT_TYPE_PIPELINE, uint64(shader.pipe), shader.name)
 
 	// success... add the shader to the list of loaded shaders.
 	vr.shaders = append(vr.shaders, shader)
@@ -1982,7 +2589,7 @@ func (vr *vulkanRenderer) loadShaderModules(shader *vulkanShader, name string, s
 	for i, stage := range stages {
 		stageName, ok := supportedStages[stage.Stage]
 		if !ok {
-			return fmt.Errorf("unsupported shader stage %d", stage.Stage)
+			return fmt.Errorf("%w: shader stage %d", errors.ErrUnsupported, stage.Stage)
 		}
 
 		// load the shader module bytes
@@ -1999,19 +2606,21 @@ func (vr *vulkanRenderer) loadShaderModules(shader *vulkanShader, name string, s
 				PCode:    (*uint32)(unsafe.Pointer(&shaderCode[0])),
 			}, nil)
 		if err != nil {
-			return fmt.Errorf("vk.CreateShaderModule: %w", err)
+			log := fmt.Sprintf("%s: vk.CreateShaderModule: %s", filename, err)
+			return &ShaderError{Shader: name, Log: log, Err: err}
 		}
 	}
 	return nil
 }
 
-// dropShader drops the shader for the given shader ID.
+// dropShader releases the shader once the frames using it have
+// finished. Shader IDs are not reused.
 func (vr *vulkanRenderer) dropShader(sid uint16) {
-	if sid < 0 || sid >= uint16(len(vr.shaders)) {
+	if sid >= uint16(len(vr.shaders)) {
 		slog.Error("dropShader:invalid shader ID", "sid", sid)
-		// also not allowed to drop the default shader.
+		return
 	}
-	vr.disposeShader(&vr.shaders[sid])
+	vr.drops = append(vr.drops, vulkanDrop{kind: dropShaderKind, id: uint32(sid), after: vr.submitted})
 }
 
 // disposeShader releases shader resources.
@@ -2054,7 +2663,7 @@ func (vr *vulkanRenderer) createShaderUniformBuffers(s *vulkanShader) (err error
 
 	// create enough scene uniform data buffer space for each surface image
 	// map the uniform memory once for the lifetime of the app.
-	bufferSize := vk.DeviceSize(maxSceneUniformBytes * numImages)
+	bufferSize := vk.DeviceSize(maxSceneUniformBytes * numImages * sceneSlots)
 	err = vr.createBuffer(&s.sceneUniforms, bufferSize, vk.BUFFER_USAGE_UNIFORM_BUFFER_BIT, flags)
 	if err != nil {
 		return fmt.Errorf("sceneUniformsMap:vk.createBuffer: %w", err)
@@ -2095,9 +2704,10 @@ func (vr *vulkanRenderer) applySceneUniforms(shader *vulkanShader) {
 		slog.Error("applySceneUniforms: no scene uniforms", "shader", shader.name)
 		return
 	}
-	descriptorSet := shader.sceneDescriptorSets[vr.imageIndex]
-	if !shader.sceneUpdated[vr.imageIndex] {
-		offset := vk.DeviceSize(vr.imageIndex * maxSceneUniformBytes)
+	slot := vr.imageIndex*sceneSlots + vr.sceneSlot
+	descriptorSet := shader.sceneDescriptorSets[slot]
+	if !shader.sceneUpdated[slot] {
+		offset := vk.DeviceSize(slot * maxSceneUniformBytes)
 		descriptorSetWrites := []vk.WriteDescriptorSet{
 			{
 				DstSet:          descriptorSet,
@@ -2113,8 +2723,16 @@ func (vr *vulkanRenderer) applySceneUniforms(shader *vulkanShader) {
 				},
 			},
 		}
+		if shader.bones {
+			descriptorSetWrites = append(descriptorSetWrites, vk.WriteDescriptorSet{
+				DstSet:         descriptorSet,
+				DstBinding:     1,
+				DescriptorType: vk.DESCRIPTOR_TYPE_STORAGE_BUFFER,
+				PBufferInfo:    []vk.DescriptorBufferInfo{vr.boneBufferInfo()},
+			})
+		}
 		vk.UpdateDescriptorSets(vr.device, descriptorSetWrites, nil)
-		shader.sceneUpdated[vr.imageIndex] = true
+		shader.sceneUpdated[slot] = true
 	}
 	setNum := uint32(0) // scene is always set=0
 	dsets := []vk.DescriptorSet{descriptorSet}
@@ -2126,10 +2744,19 @@ func (vr *vulkanRenderer) applySceneUniforms(shader *vulkanShader) {
 // The samplers are in the order expected by the shader config.
 func (vr *vulkanRenderer) setMaterialSamplers(shader *vulkanShader, tids []uint32) (matID uint32, err error) {
 	// compare to the existing material samplers to see if there is a match
+	free := -1
 	for i := uint32(0); i < shader.nextMaterialID; i++ {
 		if slices.Compare(tids, shader.materials[i].samplerSet) == 0 {
 			return i, nil // reuse existing material.
 		}
+		if free < 0 && len(shader.materials[i].samplerSet) == 0 {
+			free = int(i) // material released with its textures.
+		}
+	}
+	if free >= 0 {
+		matID = uint32(free)
+		shader.materials[matID].samplerSet = append(shader.materials[matID].samplerSet, tids...)
+		return matID, nil
 	}
 
 	// create a new material for these textures on this shader.
@@ -2234,6 +2861,8 @@ func (vr *vulkanRenderer) endSingleUseCommand(cmd vk.CommandBuffer, pool vk.Comm
 	vk.EndCommandBuffer(cmd)
 
 	// submit the command buffer
+	vr.queueLock.Lock()
+	defer vr.queueLock.Unlock()
 	submitInfo := vk.SubmitInfo{PCommandBuffers: []vk.CommandBuffer{cmd}}
 	if err := vk.QueueSubmit(queue, []vk.SubmitInfo{submitInfo}, 0); err != nil {
 		return fmt.Errorf("vk.QueueSubmit: %w", err)
@@ -2247,6 +2876,13 @@ func (vr *vulkanRenderer) endSingleUseCommand(cmd vk.CommandBuffer, pool vk.Comm
 	return nil
 }
 
+// waitIdle waits for the GPU to finish all submitted work.
+func (vr *vulkanRenderer) waitIdle() error {
+	vr.queueLock.Lock()
+	defer vr.queueLock.Unlock()
+	return vk.DeviceWaitIdle(vr.device)
+}
+
 // =============================================================================
 // Rendering a frame
 
@@ -2266,11 +2902,27 @@ type vulkanFrame struct {
 	imageAvailable vk.Semaphore // done presenting, ready for rendering.
 	renderComplete vk.Semaphore // GPU completed, ready for presentation
 	inFlightFence  vk.Fence     // render to frames not in use by GPU.
+
+	// GPU frame and profile scope timing.
+	queries  vk.QueryPool // frame timestamps. Zero if unsupported.
+	nqueries uint32       // number of timestamps written.
+	scopes   []uint8      // profile scope starting at each timestamp.
+	passes   [2]uint32    // timestamp starting each render pass.
+
+	// occlusion culling queries.
+	occlusion vk.QueryPool // bounding box sample counts.
+	occluders []uint32     // occlusion ID for each query.
+
+	// frame read back, see vulkan_capture.go.
+	capture            vulkanBuffer             // host visible copy of the frame.
+	captureW, captureH uint32                   // copied frame size.
+	captureFns         []func(img *image.NRGBA) // called when the copy is read.
 }
 
 func (vr *vulkanRenderer) createRenderFrames() (err error) {
 	vr.frameIndex = 0 // start rendering at frame zero
 	vr.frames = make([]vulkanFrame, vr.frameCount)
+	vr.occluded = map[uint32]bool{}
 	for i := range vr.frames {
 		if err = vr.createFrameCommandBuffer(&vr.frames[i]); err != nil {
 			return err
@@ -2278,6 +2930,12 @@ func (vr *vulkanRenderer) createRenderFrames() (err error) {
 		if err = vr.createFrameSyncronization(&vr.frames[i]); err != nil {
 			return err
 		}
+		if err = vr.createFrameQueries(&vr.frames[i]); err != nil {
+			return err
+		}
+		if err = vr.createFrameOcclusion(&vr.frames[i]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -2299,6 +2957,15 @@ func (vr *vulkanRenderer) disposeRenderFrames() {
 			vk.FreeCommandBuffers(vr.device, vr.graphicsQCmdPool, []vk.CommandBuffer{vr.frames[i].cmds})
 			vr.frames[i].cmds = 0
 		}
+		if vr.frames[i].queries != 0 {
+			vk.DestroyQueryPool(vr.device, vr.frames[i].queries, nil)
+			vr.frames[i].queries = 0
+		}
+		if vr.frames[i].occlusion != 0 {
+			vk.DestroyQueryPool(vr.device, vr.frames[i].occlusion, nil)
+			vr.frames[i].occlusion = 0
+		}
+		vr.disposeBuffer(&vr.frames[i].capture)
 	}
 }
 
@@ -2341,6 +3008,158 @@ func (vr *vulkanRenderer) createFrameSyncronization(fr *vulkanFrame) (err error)
 	return nil
 }
 
+// maxGPUQueries is the number of GPU timestamps for each frame.
+// Scope changes past the limit are timed as part of the current scope.
+const maxGPUQueries = 128
+
+// createFrameQueries creates the timestamp queries used to measure
+// the GPU frame time. Devices without timestamp support are ignored.
+func (vr *vulkanRenderer) createFrameQueries(fr *vulkanFrame) (err error) {
+	props := vk.GetPhysicalDeviceProperties(vr.physicalDevice)
+	if !props.Limits.TimestampComputeAndGraphics || props.Limits.TimestampPeriod <= 0 {
+		return nil // GPU time is not reported.
+	}
+	vr.timestampPeriod = props.Limits.TimestampPeriod
+	queryInfo := vk.QueryPoolCreateInfo{QueryType: vk.QUERY_TYPE_TIMESTAMP, QueryCount: maxGPUQueries}
+	if fr.queries, err = vk.CreateQueryPool(vr.device, &queryInfo, nil); err != nil {
+		return fmt.Errorf("vk.CreateQueryPool: %w", err)
+	}
+	return nil
+}
+
+// readFrameQueries gets the GPU times from the last time the given frame
+// was rendered. The frame fence has been waited on so the results are
+// expected to be available.
+func (vr *vulkanRenderer) readFrameQueries(fr *vulkanFrame) {
+	if fr.queries == 0 || fr.nqueries < 2 {
+		return
+	}
+	data := make([]byte, fr.nqueries*8) // uint64 timestamps.
+	err := vk.GetQueryPoolResults(vr.device, fr.queries, 0, fr.nqueries, data, 8, vk.QueryResultFlags(vk.QUERY_RESULT_64_BIT))
+	if err != nil {
+		return // keep the previous GPU times.
+	}
+	ticks := func(start, end uint64) time.Duration {
+		if end < start {
+			return 0
+		}
+		return time.Duration(float64(end-start) * float64(vr.timestampPeriod))
+	}
+	clear(vr.scopeTimes[:])
+	stamp := func(i uint32) uint64 { return binary.LittleEndian.Uint64(data[i*8:]) }
+	for i := uint32(0); i+1 < fr.nqueries; i++ {
+		vr.scopeTimes[fr.scopes[i]] += ticks(stamp(i), stamp(i+1))
+	}
+	vr.frameStats.GPU = ticks(stamp(0), stamp(fr.nqueries-1))
+
+	// render passes are timed until the next pass or the end of the frame.
+	ends := [2]uint32{fr.passes[Pass2D], fr.nqueries - 1}
+	for pass, start := range fr.passes {
+		if start < fr.nqueries && ends[pass] < fr.nqueries {
+			vr.passTimes[pass] = ticks(stamp(start), stamp(ends[pass]))
+		}
+	}
+}
+
+// timestamp writes a GPU timestamp that ends the current profile scope
+// and starts the given scope. Scope zero is not reported, but is
+// included in the frame time. The last timestamp is reserved for
+// the end of the frame. Returns the timestamp query, or maxGPUQueries
+// if no timestamp was written.
+func (vr *vulkanRenderer) timestamp(fr *vulkanFrame, scope uint8, last bool) (query uint32) {
+	if fr.queries == 0 || (fr.nqueries >= maxGPUQueries-1 && !last) {
+		return maxGPUQueries
+	}
+	if int(scope) >= MaxGPUScopes {
+		scope = 0 // ignore invalid scopes.
+	}
+	stage := vk.PIPELINE_STAGE_BOTTOM_OF_PIPE_BIT
+	if fr.nqueries == 0 {
+		stage = vk.PIPELINE_STAGE_TOP_OF_PIPE_BIT
+	}
+	vk.CmdWriteTimestamp(fr.cmds, stage, fr.queries, fr.nqueries)
+	fr.scopes = append(fr.scopes, scope)
+	fr.nqueries++
+	return fr.nqueries - 1
+}
+
+// packetScope returns the packet profile scope, using the
+// render pass scope for packets without a scope.
+func packetScope(packet Packet, passScope uint8) uint8 {
+	if packet.Scope == 0 {
+		return passScope
+	}
+	return packet.Scope
+}
+
+// gpuTimes returns the GPU time for each profile scope.
+func (vr *vulkanRenderer) gpuTimes() []time.Duration { return vr.scopeTimes[:] }
+
+// stats returns the statistics for the last drawn frame.
+func (vr *vulkanRenderer) stats() Stats {
+	stats := vr.frameStats
+	stats.Memory = vr.memory.Load()
+	return stats
+}
+
+// frameGraph describes the render passes and render targets for the last
+// drawn frame. The render target passes are drawn first. The 3D pass clears
+// and draws the display and depth images, sampling the render targets.
+// The 2D pass draws over the display image before it is presented.
+func (vr *vulkanRenderer) frameGraph() FrameGraph {
+	w, h := vr.frameWidth, vr.frameHeight
+	graph := FrameGraph{}
+	targets := []string{}
+	for _, id := range vr.drawn {
+		t := vr.target(id)
+		if t == nil {
+			continue // released since the last frame.
+		}
+		name, img := fmt.Sprintf("target%d", id), &vr.textures[t.tid].image
+		targets = append(targets, name)
+		graph.Passes = append(graph.Passes, GraphPass{Name: "3D " + name, Writes: []string{name, name + " depth"}})
+		graph.Targets = append(graph.Targets,
+			GraphTarget{Name: name, Format: vr.textures[t.tid].format.String(), Width: img.width, Height: img.height},
+			GraphTarget{Name: name + " depth", Format: vr.depthFormat.String(), Width: img.width, Height: img.height},
+		)
+	}
+	graph.Passes = append(graph.Passes,
+		GraphPass{
+			Name:      "3D",
+			Reads:     targets,
+			Writes:    []string{"color", "depth"},
+			Packets:   vr.passPackets[Pass3D],
+			DrawCalls: vr.passDraws[Pass3D],
+			GPU:       vr.passTimes[Pass3D],
+		},
+		GraphPass{
+			Name:      "2D",
+			Reads:     []string{"color"},
+			Writes:    []string{"color"},
+			Packets:   vr.passPackets[Pass2D],
+			DrawCalls: vr.passDraws[Pass2D],
+			GPU:       vr.passTimes[Pass2D],
+		},
+	)
+	graph.Targets = append(graph.Targets,
+		GraphTarget{Name: "color", Format: vr.surfaceFormat.Format.String(), Width: w, Height: h, Present: true},
+		GraphTarget{Name: "depth", Format: vr.depthFormat.String(), Width: w, Height: h},
+	)
+	return graph
+}
+
+// countDraw updates the frame statistics for one draw command.
+// Line shaders draw lines, not triangles.
+func (vr *vulkanRenderer) countDraw(shader *vulkanShader, mid, instances uint32) {
+	if mid >= uint32(len(vr.meshes)) {
+		return // not drawn.
+	}
+	vr.frameStats.DrawCalls++
+	if !shader.lines {
+		vr.frameStats.Triangles += int(vr.meshes[mid][load.Indexes].count/3) * int(instances)
+	}
+}
+
 // =============================================================================
 // beginFrame, render objects, endFrame
 
@@ -2350,7 +3169,7 @@ func (vr *vulkanRenderer) setClearColor(r, g, b, a float32) {
 
 func (vr *vulkanRenderer) beginFrame(dt time.Duration) (err error) {
 	if vr.recreatingSwapchain {
-		if err := vk.DeviceWaitIdle(vr.device); err != nil {
+		if err := vr.waitIdle(); err != nil {
 			return fmt.Errorf("vk.DeviceWaitIdle1: %w", err)
 		}
 		return fmt.Errorf("beginFrame aborted: recreating swapchain")
@@ -2358,7 +3177,7 @@ func (vr *vulkanRenderer) beginFrame(dt time.Duration) (err error) {
 
 	// handle screen resizes.
 	if vr.isResizing() {
-		if err := vk.DeviceWaitIdle(vr.device); err != nil {
+		if err := vr.waitIdle(); err != nil {
 			return fmt.Errorf("vk.DeviceWaitIdle.2: %w", err)
 		}
 		if err := vr.resizeSwapchain(); err != nil {
@@ -2371,9 +3190,19 @@ func (vr *vulkanRenderer) beginFrame(dt time.Duration) (err error) {
 	// and is later signalled when the frame is finished.
 	frame := &vr.frames[vr.frameIndex]
 	err = vk.WaitForFences(vr.device, []vk.Fence{frame.inFlightFence}, true, waitFrame)
+	vr.crumbs.wait(time.Duration(waitFrame), err == nil)
 	if err != nil {
-		return fmt.Errorf("beginFrame aborted: vk.WaitForFences: %w", err)
+		return fmt.Errorf("beginFrame aborted: vk.WaitForFences: %w", vr.deviceLost(err))
+	}
+
+	// windows share the uniform buffers indexed by swapchain image, so wait
+	// for the last frame drawn to a different window to finish.
+	if vr.lastView != nil && vr.lastView != vr.vulkanView {
+		if err = vk.WaitForFences(vr.device, []vk.Fence{vr.lastFence}, true, waitFrame); err != nil {
+			return fmt.Errorf("beginFrame aborted: vk.WaitForFences window: %w", err)
+		}
 	}
+	vr.releaseDrops(false) // release resources from completed frames.
 
 	// acquire the next image from the swapchain.
 	// Pass in the semaphore to be signalled when image is available again.
@@ -2401,6 +3230,29 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 		return fmt.Errorf("vk.BeginCommandBuffer %w", err)
 	}
 
+	// draw the textures and meshes that have finished uploading.
+	vr.finishUploads()
+
+	// reset the frame statistics and time the GPU commands.
+	vr.frameStats = Stats{GPU: vr.frameStats.GPU}
+	vr.readFrameQueries(frame)
+	frame.nqueries, frame.scopes = 0, frame.scopes[:0]
+	frame.passes = [2]uint32{maxGPUQueries, maxGPUQueries}
+	vr.passPackets, vr.passDraws = [2]int{}, [2]int{}
+	vr.bones.count = 0
+	if frame.queries != 0 {
+		vk.CmdResetQueryPool(frame.cmds, frame.queries, 0, maxGPUQueries)
+	}
+	vr.timestamp(frame, 0, false)
+
+	// get the models hidden and the frame copied the last time this frame was drawn.
+	vr.readFrameOcclusion(frame)
+	vr.readFrameCapture(frame)
+	frame.occluders = frame.occluders[:0]
+	if frame.occlusion != 0 {
+		vk.CmdResetQueryPool(frame.cmds, frame.occlusion, 0, maxOcclusionQueries)
+	}
+
 	// set pipeline dynamic state
 	vr.setViewportAndScissor()
 	vk.CmdSetViewport(frame.cmds, 0, []vk.Viewport{vr.viewport})
@@ -2427,7 +3279,12 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 		}
 	}
 
-	// first pass always 3D (can be empty if only 2D).
+	// the render targets are drawn first so that
+	// the window passes can sample the target images.
+	frame.passes[Pass3D] = vr.timestamp(frame, Scope3D, false)
+	scope := vr.drawTargets(frame, passes, []vk.ClearValue{colorClear, depthClear})
+
+	// first window pass always 3D (can be empty if only 2D).
 	// start the 3D world render pass
 	render3DInfo := vk.RenderPassBeginInfo{
 		RenderPass:  vr.render3D,
@@ -2439,49 +3296,19 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 		PClearValues: []vk.ClearValue{colorClear, depthClear},
 	}
 	vk.CmdBeginRenderPass(frame.cmds, &render3DInfo, vk.SUBPASS_CONTENTS_INLINE)
-
-	var shader *vulkanShader
-	shaderID := uint16(math.MaxUint16) - 1
-	if len(passes) > 0 && len(passes[Pass3D].Packets) > 0 {
-		pass := passes[Pass3D]
-
-		// draw 3D packets
-		for _, packet := range pass.Packets {
-			// TODO complain about packets without meshes.
-
-			// change shader when necessary.
-			if shaderID != packet.ShaderID {
-				if packet.ShaderID >= uint16(len(vr.shaders)) {
-					slog.Error("invalid shaderID", "shader_id", packet.ShaderID)
-					continue
-				}
-				shaderID = packet.ShaderID // changing shaders.
-				shader = &vr.shaders[shaderID]
-				vk.CmdBindPipeline(frame.cmds, vk.PIPELINE_BIND_POINT_GRAPHICS, shader.pipe)
-
-				// setting scene uniforms for this shader
-				vr.setSceneUniforms(shader, pass)
-				vr.applySceneUniforms(shader)
-			}
-
-			// update material samplers
-			if len(packet.TextureIDs) > 0 {
-				matID, _ := vr.setMaterialSamplers(shader, packet.TextureIDs)
-				vr.applyMaterialUniforms(shader, matID)
-			}
-
-			// bind model scope uniforms for this shader.
-			vr.setModelUniforms(shader, packet)
-			if packet.IsInstanced {
-				// draw multiple models.
-				vr.drawInstancedMesh(frame, packet.MeshID, packet.InstanceID, packet.InstanceCount, shader.attrs)
-			} else {
-				// draw one model.
-				vr.drawMesh(frame, packet.MeshID, shader.attrs)
-			}
+	vr.beginLabel(frame.cmds, "3D pass")
+	crumb := breadcrumb{frame: vr.submitted + 1, pass: Pass3D}
+	for slot, pass := range passes {
+		if pass.ID != Pass3D || pass.Target != 0 || len(pass.Packets) == 0 || !vr.usePassView(frame, pass, slot) {
+			continue
 		}
+		scope = vr.drawPackets3D(frame, pass, scope, &crumb)
 	}
+	vr.endLabel(frame.cmds)
 	vk.CmdEndRenderPass(frame.cmds)
+	vr.passDraws[Pass3D] = vr.frameStats.DrawCalls
+	crumb.draws = vr.passDraws[Pass3D]
+	vr.crumbs.add(crumb)
 
 	// second pass always 2D if present.
 	// then the 2D UI overlay render pass
@@ -2494,11 +3321,30 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 		},
 	}
 	vk.CmdBeginRenderPass(frame.cmds, &render2DInfo, vk.SUBPASS_CONTENTS_INLINE)
-	if len(passes) > 1 && len(passes[Pass2D].Packets) > 0 {
-		pass := passes[Pass2D]
+	vr.beginLabel(frame.cmds, "2D pass")
+	frame.passes[Pass2D] = vr.timestamp(frame, Scope2D, false)
+	crumb = breadcrumb{frame: vr.submitted + 1, pass: Pass2D}
+	scope = Scope2D
+	var shader *vulkanShader
+	shaderID := uint16(math.MaxUint16) - 1
+	for slot, pass := range passes {
+		if pass.ID != Pass2D || len(pass.Packets) == 0 || !vr.usePassView(frame, pass, slot) {
+			continue
+		}
+		vr.passPackets[Pass2D] += len(pass.Packets)
+		shaderID = uint16(math.MaxUint16) - 1 // bind the pass scene uniforms.
 
 		// draw 2D packets
 		for _, packet := range pass.Packets {
+			if !vr.uploaded(packet) {
+				continue // wait for the upload to complete.
+			}
+
+			// time the packet draws by profile scope.
+			if ps := packetScope(packet, Scope2D); ps != scope {
+				scope = ps
+				vr.timestamp(frame, scope, false)
+			}
 
 			// change shader when necessary.
 			if shaderID != packet.ShaderID {
@@ -2509,6 +3355,7 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 				shaderID = packet.ShaderID // changing shaders.
 				shader = &vr.shaders[shaderID]
 				vk.CmdBindPipeline(frame.cmds, vk.PIPELINE_BIND_POINT_GRAPHICS, shader.pipe)
+				vr.frameStats.ShaderBinds++
 
 				// setting scene uniforms for this shader
 				vr.setSceneUniforms(shader, pass)
@@ -2522,14 +3369,23 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 					lastMatID = matID
 				}
 				vr.applyMaterialUniforms(shader, matID)
+				vr.frameStats.TextureBinds++
 			}
 
 			// bind model scope uniforms and draw the model.
 			vr.setModelUniforms(shader, packet)
+			crumb.shader, crumb.mesh, crumb.tag = shader.name, packet.MeshID, packet.Tag
 			vr.drawMesh(frame, packet.MeshID, shader.attrs)
+			vr.countDraw(shader, packet.MeshID, 1)
 		}
 	}
+	vr.endLabel(frame.cmds)
 	vk.CmdEndRenderPass(frame.cmds)
+	vr.passDraws[Pass2D] = vr.frameStats.DrawCalls - vr.passDraws[Pass3D]
+	crumb.draws = vr.passDraws[Pass2D]
+	vr.crumbs.add(crumb)
+	vr.cmdCaptureFrame(frame)
+	vr.timestamp(frame, 0, true) // end of frame.
 
 	// end command recording
 	if err = vk.EndCommandBuffer(frame.cmds); err != nil {
@@ -2538,6 +3394,120 @@ func (vr *vulkanRenderer) drawFrame(passes []Pass) (err error) {
 	return nil
 }
 
+// drawTargets draws the 3D passes that render into targets, returning
+// the current GPU profile scope. Each target is cleared even if it has
+// no packets.
+func (vr *vulkanRenderer) drawTargets(frame *vulkanFrame, passes []Pass, clears []vk.ClearValue) (scope uint8) {
+	scope = Scope3D
+	vr.drawn = vr.drawn[:0]
+	for slot, pass := range passes {
+		if pass.ID != Pass3D || pass.Target == 0 {
+			continue
+		}
+		t := vr.target(pass.Target)
+		if t == nil {
+			slog.Error("invalid render target ID", "target", pass.Target)
+			continue
+		}
+		if len(vr.drawn) >= MaxTargets || !vr.usePassView(frame, pass, slot) {
+			continue
+		}
+		vr.drawn = append(vr.drawn, pass.Target)
+		tex := &vr.textures[t.tid]
+		targetInfo := vk.RenderPassBeginInfo{
+			RenderPass:  vr.renderTarget,
+			Framebuffer: t.frame,
+			RenderArea: vk.Rect2D{
+				Offset: vk.Offset2D{X: 0, Y: 0},
+				Extent: vk.Extent2D{Width: tex.image.width, Height: tex.image.height},
+			},
+			PClearValues: clears,
+		}
+		vk.CmdBeginRenderPass(frame.cmds, &targetInfo, vk.SUBPASS_CONTENTS_INLINE)
+		vr.beginLabel(frame.cmds, "3D target")
+		crumb := breadcrumb{frame: vr.submitted + 1, pass: Pass3D}
+		scope = vr.drawPackets3D(frame, pass, scope, &crumb)
+		vr.endLabel(frame.cmds)
+		vk.CmdEndRenderPass(frame.cmds)
+		crumb.draws = vr.frameStats.DrawCalls
+		vr.crumbs.add(crumb)
+	}
+	return scope
+}
+
+// drawPackets3D draws the packets of one 3D pass, returning the current
+// GPU profile scope. Occlusion culling is only done for window passes.
+func (vr *vulkanRenderer) drawPackets3D(frame *vulkanFrame, pass Pass, scope uint8, crumb *breadcrumb) uint8 {
+	vr.passPackets[Pass3D] += len(pass.Packets)
+	var shader *vulkanShader
+	shaderID := uint16(math.MaxUint16) - 1 // bind the pass scene uniforms.
+	for _, packet := range pass.Packets {
+		// TODO complain about packets without meshes.
+		if !vr.uploaded(packet) {
+			continue // wait for the upload to complete.
+		}
+		if pass.Target != 0 && packet.IsOcclusionQuery {
+			continue // occlusion is tested from the window camera.
+		}
+		if pass.Target == 0 && vr.occludedPacket(packet) {
+			continue // hidden behind other models.
+		}
+
+		// time the packet draws by profile scope.
+		if ps := packetScope(packet, Scope3D); ps != scope {
+			scope = ps
+			vr.timestamp(frame, scope, false)
+		}
+
+		// change shader when necessary.
+		if shaderID != packet.ShaderID {
+			if packet.ShaderID >= uint16(len(vr.shaders)) {
+				slog.Error("invalid shaderID", "shader_id", packet.ShaderID)
+				continue
+			}
+			shaderID = packet.ShaderID // changing shaders.
+			shader = &vr.shaders[shaderID]
+			vk.CmdBindPipeline(frame.cmds, vk.PIPELINE_BIND_POINT_GRAPHICS, shader.pipe)
+			vr.frameStats.ShaderBinds++
+
+			// setting scene uniforms for this shader
+			vr.setSceneUniforms(shader, pass)
+			vr.applySceneUniforms(shader)
+		}
+
+		// update material samplers
+		if len(packet.TextureIDs) > 0 {
+			matID, _ := vr.setMaterialSamplers(shader, packet.TextureIDs)
+			vr.applyMaterialUniforms(shader, matID)
+			vr.frameStats.TextureBinds++
+		}
+
+		// copy the bones for animated models.
+		if shader.bones && !vr.setBones(packet) {
+			continue // out of bone space.
+		}
+
+		// bind model scope uniforms for this shader.
+		// Bounding boxes are drawn inside an occlusion query.
+		vr.setModelUniforms(shader, packet)
+		query, querying := vr.beginOcclusionQuery(frame, packet)
+		crumb.shader, crumb.mesh, crumb.tag = shader.name, packet.MeshID, packet.Tag
+		if packet.IsInstanced {
+			// draw multiple models.
+			vr.drawInstancedMesh(frame, packet.MeshID, packet.InstanceID, packet.InstanceCount, shader.attrs)
+			vr.countDraw(shader, packet.MeshID, packet.InstanceCount)
+		} else {
+			// draw one model.
+			vr.drawMesh(frame, packet.MeshID, shader.attrs)
+			vr.countDraw(shader, packet.MeshID, 1)
+		}
+		if querying {
+			vk.CmdEndQuery(frame.cmds, frame.occlusion, query)
+		}
+	}
+	return scope
+}
+
 var lastMatID uint32 = 345234545
 
 func (vr *vulkanRenderer) endFrame(dt time.Duration) (err error) {
@@ -2555,9 +3525,13 @@ func (vr *vulkanRenderer) endFrame(dt time.Duration) (err error) {
 		PSignalSemaphores: []vk.Semaphore{frame.renderComplete}, // signal present frame
 		PCommandBuffers:   []vk.CommandBuffer{frame.cmds},
 	}
+	vr.queueLock.Lock()
+	defer vr.queueLock.Unlock()
 	if err = vk.QueueSubmit(vr.graphicsQ, []vk.SubmitInfo{submitInfo}, frame.inFlightFence); err != nil {
-		return fmt.Errorf("vk.QueueSubmit %w", err)
+		return fmt.Errorf("vk.QueueSubmit %w", vr.deviceLost(err))
 	}
+	vr.submitted++
+	vr.lastView, vr.lastFence = vr.vulkanView, frame.inFlightFence
 
 	// present the frame, waits for renderComplete.
 	presentInfo := vk.PresentInfoKHR{
@@ -2571,7 +3545,7 @@ func (vr *vulkanRenderer) endFrame(dt time.Duration) (err error) {
 			vr.resize(vr.frameWidth, vr.frameHeight)
 			return nil // didn't quite work.
 		}
-		return fmt.Errorf("endFrame aborted: vkQueuePresentKHR: %w", err)
+		return fmt.Errorf("endFrame aborted: vkQueuePresentKHR: %w", vr.deviceLost(err))
 	}
 	vr.frameIndex = (vr.frameIndex + 1) % vr.frameCount
 	return nil
@@ -2592,6 +3566,72 @@ func (vr *vulkanRenderer) setViewportAndScissor() {
 	vr.scissor.Extent.Height = vr.frameHeight
 }
 
+// usePassView sets the viewport, scissor, and scene uniforms used to
+// draw the given pass. The slot is the pass index in the frame.
+// Returns false if the pass can't be drawn.
+func (vr *vulkanRenderer) usePassView(frame *vulkanFrame, pass Pass, slot int) bool {
+	if slot >= sceneSlots {
+		slog.Error("too many render passes", "max_views", MaxViews, "slot", slot)
+		return false
+	}
+	vr.sceneSlot = uint32(slot)
+	vr.setViewportAndScissor()
+	if t := vr.target(pass.Target); t != nil {
+		img := &vr.textures[t.tid].image // the whole render target.
+		vr.viewport.Width, vr.viewport.Height = float32(img.width), float32(img.height)
+		vr.scissor.Extent = vk.Extent2D{Width: img.width, Height: img.height}
+	} else if v := pass.Viewport; v.W > 0 && v.H > 0 {
+		w, h := float32(vr.frameWidth), float32(vr.frameHeight)
+		x0, y0 := max(v.X*w, 0), max(v.Y*h, 0)
+		x1, y1 := min((v.X+v.W)*w, w), min((v.Y+v.H)*h, h)
+		if x1-x0 < 1 || y1-y0 < 1 {
+			return false // viewport is outside the window.
+		}
+		vr.viewport.X, vr.viewport.Y = x0, y0
+		vr.viewport.Width, vr.viewport.Height = x1-x0, y1-y0
+		vr.scissor.Offset = vk.Offset2D{X: int32(x0), Y: int32(y0)}
+		vr.scissor.Extent = vk.Extent2D{Width: uint32(x1 - x0), Height: uint32(y1 - y0)}
+	}
+	vk.CmdSetViewport(frame.cmds, 0, []vk.Viewport{vr.viewport})
+	vk.CmdSetScissor(frame.cmds, 0, []vk.Rect2D{vr.scissor})
+	return true
+}
+
+// deviceLost logs the GPU breadcrumbs and marks the error
+// as ErrDeviceLost when the GPU device has been lost.
+func (vr *vulkanRenderer) deviceLost(err error) error {
+	if err != vk.ERROR_DEVICE_LOST {
+		return err
+	}
+	vr.crumbs.log("gpu device lost", "error", err)
+	return fmt.Errorf("%w: %w", ErrDeviceLost, err)
+}
+
+// beginLabel starts a named group of commands
+// that is shown by GPU debuggers and crash tools.
+func (vr *vulkanRenderer) beginLabel(cmds vk.CommandBuffer, name string) {
+	if vr.labels {
+		vk.CmdBeginDebugUtilsLabelEXT(cmds, &vk.DebugUtilsLabelEXT{PLabelName: name})
+	}
+}
+
+// endLabel ends the last group of commands started by beginLabel.
+func (vr *vulkanRenderer) endLabel(cmds vk.CommandBuffer) {
+	if vr.labels {
+		vk.CmdEndDebugUtilsLabelEXT(cmds)
+	}
+}
+
+// nameObject labels a vulkan object for GPU debuggers and crash tools.
+func (vr *vulkanRenderer) nameObject(kind vk.ObjectType, handle uint64, name string) {
+	if vr.labels && name != "" {
+		info := vk.DebugUtilsObjectNameInfoEXT{ObjectType: kind, ObjectHandle: handle, PObjectName: name}
+		if err := vk.SetDebugUtilsObjectNameEXT(vr.device, &info); err != nil {
+			slog.Debug("vk.SetDebugUtilsObjectNameEXT", "name", name, "error", err)
+		}
+	}
+}
+
 // used for waits.
 var waitFrame uint64 = uint64(time.Duration(16 * time.Millisecond))
 var maxTimeout uint64 = math.MaxUint64
@@ -2626,7 +3666,7 @@ func (vr *vulkanRenderer) setModelUniforms(shader *vulkanShader, packet Packet)
 func (vr *vulkanRenderer) setUniform(shader *vulkanShader, u *uniform, instance uint32, data []byte) {
 	switch u.scope {
 	case load.SceneScope:
-		offset := uintptr(vr.imageIndex*maxSceneUniformBytes + u.offset)
+		offset := uintptr((vr.imageIndex*sceneSlots+vr.sceneSlot)*maxSceneUniformBytes + u.offset)
 		dst := (*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(shader.sceneUniformsMap)) + offset))
 		copy(unsafe.Slice(dst, len(data)), data)
 	case load.MaterialScope:
@@ -0,0 +1,84 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// vulkan_bones.go holds the bone matrices for skinned meshes. The bone
+// matrices for all the animated models drawn in a frame are copied
+// to a host visible storage buffer. Each swapchain image has its own
+// region of the buffer. Animated shaders bind the region as a scene
+// storage buffer, set=0 binding=1, and each model packet is told where
+// its first bone is using the BONES model uniform.
+
+import (
+	"fmt"
+	"log/slog"
+	"unsafe"
+
+	"github.com/gazed/vu/internal/render/vk"
+	"github.com/gazed/vu/load"
+)
+
+// maxBones is the number of bone matrices for each frame.
+// Bones past the limit are not drawn.
+const maxBones = 8192
+
+// boneBytes is the size of one 4x4 float32 bone matrix.
+const boneBytes = 64
+
+// vulkanBones is the bone storage buffer.
+type vulkanBones struct {
+	buff  vulkanBuffer // bones for each swapchain image.
+	data  *byte        // unsafe pointer to the mapped memory.
+	count uint32       // bones used in the current frame.
+}
+
+// createBoneBuffer creates and maps the bone storage buffer
+// for the lifetime of the app.
+func (vr *vulkanRenderer) createBoneBuffer() (err error) {
+	deviceLocalBits := vk.MemoryPropertyFlags(0)
+	if vr.deviceLocalHostVisible {
+		deviceLocalBits = vk.MEMORY_PROPERTY_DEVICE_LOCAL_BIT
+	}
+	flags := vk.MEMORY_PROPERTY_HOST_VISIBLE_BIT | vk.MEMORY_PROPERTY_HOST_COHERENT_BIT | deviceLocalBits
+	size := vk.DeviceSize(maxBones * boneBytes * len(vr.images))
+	if err = vr.createBuffer(&vr.bones.buff, size, vk.BUFFER_USAGE_STORAGE_BUFFER_BIT, flags); err != nil {
+		return fmt.Errorf("createBoneBuffer:vk.createBuffer: %w", err)
+	}
+	if vr.bones.data, err = vk.MapMemory(vr.device, vr.bones.buff.memory, 0, size, 0); err != nil {
+		return fmt.Errorf("createBoneBuffer:vk.MapMemory: %w", err)
+	}
+	return nil
+}
+
+// disposeBoneBuffer releases the bone storage buffer.
+func (vr *vulkanRenderer) disposeBoneBuffer() {
+	vr.disposeBuffer(&vr.bones.buff)
+	vr.bones.data = nil
+}
+
+// setBones copies the packet bone matrices to the bone buffer
+// and sets the packet bones uniform to the first packet bone.
+// Returns false if there was no room for the packet bones.
+func (vr *vulkanRenderer) setBones(packet Packet) bool {
+	count := uint32(len(packet.Bones) / boneBytes)
+	if vr.bones.count+count > maxBones {
+		slog.Error("need to increase maxBones", "bones", vr.bones.count+count)
+		return false
+	}
+	first := vr.bones.count
+	offset := uintptr((vr.imageIndex*maxBones + first) * boneBytes)
+	dst := (*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(vr.bones.data)) + offset))
+	copy(unsafe.Slice(dst, len(packet.Bones)), packet.Bones)
+	vr.bones.count += count
+	packet.Uniforms[load.BONES] = Int32ToBytes(int32(first), packet.Uniforms[load.BONES])
+	return true
+}
+
+// boneBufferInfo returns the bone buffer region for the current image.
+func (vr *vulkanRenderer) boneBufferInfo() vk.DescriptorBufferInfo {
+	return vk.DescriptorBufferInfo{
+		Buffer: vr.bones.buff.handle,
+		Offset: vk.DeviceSize(vr.imageIndex * maxBones * boneBytes),
+		Rang:   vk.DeviceSize(maxBones * boneBytes),
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// vulkan_capture.go reads rendered frames back from the GPU for screenshots
+// and frame captures. Like an OpenGL pixel buffer object, the swapchain
+// image is copied into a host visible buffer at the end of the frame and
+// the buffer is read when the frame is reused, after the frame fence has
+// been waited on, so reading back a frame does not stall the GPU.
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"unsafe"
+
+	"github.com/gazed/vu/internal/render/vk"
+)
+
+// capture requests a read back of the next frame drawn to the main window.
+func (vr *vulkanRenderer) capture(done func(img *image.NRGBA)) error {
+	if !vr.main.captures {
+		return fmt.Errorf("%w: swapchain images can't be copied", errors.ErrUnsupported)
+	}
+	vr.captureFns = append(vr.captureFns, done)
+	return nil
+}
+
+// cmdCaptureFrame records a copy of the rendered swapchain image into
+// the frame capture buffer when a capture has been requested. Expected
+// to be called after the last render pass.
+func (vr *vulkanRenderer) cmdCaptureFrame(fr *vulkanFrame) {
+	if len(vr.captureFns) == 0 || vr.vulkanView != vr.main {
+		return
+	}
+
+	// the frame fence has been waited on so the buffer can be replaced.
+	w, h := vr.frameWidth, vr.frameHeight
+	size := vk.DeviceSize(w * h * 4)
+	if fr.capture.handle == 0 || fr.captureW != w || fr.captureH != h {
+		vr.disposeBuffer(&fr.capture)
+		flags := vk.MEMORY_PROPERTY_HOST_VISIBLE_BIT | vk.MEMORY_PROPERTY_HOST_COHERENT_BIT
+		if err := vr.createBuffer(&fr.capture, size, vk.BUFFER_USAGE_TRANSFER_DST_BIT, flags); err != nil {
+			vr.captureFailed(fmt.Errorf("capture buffer: %w", err))
+			return
+		}
+		fr.captureW, fr.captureH = w, h
+	}
+	fr.captureFns = append(fr.captureFns, vr.captureFns...)
+	vr.captureFns = vr.captureFns[:0]
+
+	// move the presentable image to a copy source and back again.
+	layers := vk.ImageSubresourceRange{AspectMask: vk.IMAGE_ASPECT_COLOR_BIT, LevelCount: 1, LayerCount: 1}
+	toCopy := vk.ImageMemoryBarrier{
+		SrcAccessMask:       vk.AccessFlags(vk.ACCESS_COLOR_ATTACHMENT_WRITE_BIT),
+		DstAccessMask:       vk.AccessFlags(vk.ACCESS_TRANSFER_READ_BIT),
+		OldLayout:           vk.IMAGE_LAYOUT_PRESENT_SRC_KHR,
+		NewLayout:           vk.IMAGE_LAYOUT_TRANSFER_SRC_OPTIMAL,
+		SrcQueueFamilyIndex: vk.QUEUE_FAMILY_IGNORED,
+		DstQueueFamilyIndex: vk.QUEUE_FAMILY_IGNORED,
+		Image:               vr.images[vr.imageIndex],
+		SubresourceRange:    layers,
+	}
+	vk.CmdPipelineBarrier(fr.cmds, vk.PipelineStageFlags(vk.PIPELINE_STAGE_COLOR_ATTACHMENT_OUTPUT_BIT),
+		vk.PipelineStageFlags(vk.PIPELINE_STAGE_TRANSFER_BIT), 0, nil, nil, []vk.ImageMemoryBarrier{toCopy})
+	region := vk.BufferImageCopy{
+		ImageSubresource: vk.ImageSubresourceLayers{AspectMask: vk.IMAGE_ASPECT_COLOR_BIT, LayerCount: 1},
+		ImageExtent:      vk.Extent3D{Width: w, Height: h, Depth: 1},
+	}
+	vk.CmdCopyImageToBuffer(fr.cmds, vr.images[vr.imageIndex], vk.IMAGE_LAYOUT_TRANSFER_SRC_OPTIMAL,
+		fr.capture.handle, []vk.BufferImageCopy{region})
+	toPresent := toCopy
+	toPresent.SrcAccessMask, toPresent.DstAccessMask = vk.AccessFlags(vk.ACCESS_TRANSFER_READ_BIT), 0
+	toPresent.OldLayout, toPresent.NewLayout = vk.IMAGE_LAYOUT_TRANSFER_SRC_OPTIMAL, vk.IMAGE_LAYOUT_PRESENT_SRC_KHR
+	toHost := vk.BufferMemoryBarrier{
+		SrcAccessMask:       vk.AccessFlags(vk.ACCESS_TRANSFER_WRITE_BIT),
+		DstAccessMask:       vk.AccessFlags(vk.ACCESS_HOST_READ_BIT),
+		SrcQueueFamilyIndex: vk.QUEUE_FAMILY_IGNORED,
+		DstQueueFamilyIndex: vk.QUEUE_FAMILY_IGNORED,
+		Buffer:              fr.capture.handle,
+		Size:                size,
+	}
+	vk.CmdPipelineBarrier(fr.cmds, vk.PipelineStageFlags(vk.PIPELINE_STAGE_TRANSFER_BIT),
+		vk.PipelineStageFlags(vk.PIPELINE_STAGE_BOTTOM_OF_PIPE_BIT|vk.PIPELINE_STAGE_HOST_BIT), 0, nil,
+		[]vk.BufferMemoryBarrier{toHost}, []vk.ImageMemoryBarrier{toPresent})
+}
+
+// readFrameCapture passes the frame copied the last time the given frame
+// was rendered to the capture callbacks. The frame fence has been waited
+// on so the copy is expected to be complete.
+func (vr *vulkanRenderer) readFrameCapture(fr *vulkanFrame) {
+	if len(fr.captureFns) == 0 {
+		return
+	}
+	fns := fr.captureFns
+	fr.captureFns = fr.captureFns[:0]
+	size := vk.DeviceSize(fr.captureW * fr.captureH * 4)
+	ptr, err := vk.MapMemory(vr.device, fr.capture.memory, 0, size, 0)
+	if err != nil {
+		vr.captureFailed(fmt.Errorf("vk.MapMemory: %w", err))
+		return
+	}
+	pixels := unsafe.Slice(ptr, size)
+	for _, done := range fns {
+		done(captureImage(pixels, int(fr.captureW), int(fr.captureH), vr.surfaceFormat.Format))
+	}
+	vk.UnmapMemory(vr.device, fr.capture.memory)
+}
+
+// captureFailed drops the outstanding capture requests.
+func (vr *vulkanRenderer) captureFailed(err error) {
+	vr.captureFns = vr.captureFns[:0]
+	slog.Error("frame capture", "error", err)
+}
+
+// captureImage copies the swapchain pixels into a new image. Blue first
+// surface formats are swizzled and the alpha is opaque since the
+// swapchain is presented without blending.
+func captureImage(pixels []byte, w, h int, format vk.Format) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	copy(img.Pix, pixels)
+	bgra := format == vk.FORMAT_B8G8R8A8_SRGB || format == vk.FORMAT_B8G8R8A8_UNORM
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		if bgra {
+			img.Pix[i], img.Pix[i+2] = img.Pix[i+2], img.Pix[i]
+		}
+		img.Pix[i+3] = 255
+	}
+	return img
+}
@@ -0,0 +1,30 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/gazed/vu/internal/render/vk"
+)
+
+// go test -run Capture
+func TestCapture(t *testing.T) {
+	pixels := []byte{10, 20, 30, 0, 40, 50, 60, 128}
+	t.Run("bgra", func(t *testing.T) {
+		img := captureImage(pixels, 2, 1, vk.FORMAT_B8G8R8A8_SRGB)
+		if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 30, G: 20, B: 10, A: 255}) {
+			t.Errorf("expected swizzled opaque pixel got %v", c)
+		}
+		if pixels[0] != 10 {
+			t.Errorf("expected frame pixels to be unchanged")
+		}
+	})
+	t.Run("rgba", func(t *testing.T) {
+		img := captureImage(pixels, 2, 1, vk.FORMAT_R8G8B8A8_SRGB)
+		if c := img.NRGBAAt(1, 0); c != (color.NRGBA{R: 40, G: 50, B: 60, A: 255}) {
+			t.Errorf("expected opaque pixel got %v", c)
+		}
+	})
+}
@@ -14,7 +14,8 @@ import (
 	"github.com/gazed/vu/internal/render/vk"
 )
 
-// init is called before main to override the addValidationLayer method.
+// init is called before main to override the addValidationLayer
+// and addDebugLabels methods.
 func init() {
 	addValidationLayer = func(layers []string) ([]string, error) {
 		slog.Debug("vulkan validation added")
@@ -34,4 +35,20 @@ func init() {
 		slog.Error("khronos validation layer not found")
 		return layers, nil
 	}
+
+	addDebugLabels = func(extensions []string) ([]string, bool) {
+		props, err := vk.EnumerateInstanceExtensionProperties("")
+		if err != nil {
+			slog.Error("vk.EnumerateInstanceExtensionProperties", "error", err)
+			return extensions, false
+		}
+		for _, p := range props {
+			if p.ExtensionName == vk.EXT_DEBUG_UTILS_EXTENSION_NAME {
+				slog.Debug("vulkan debug labels added")
+				return append(extensions, p.ExtensionName), true
+			}
+		}
+		slog.Warn("vulkan debug labels not available")
+		return extensions, false
+	}
 }
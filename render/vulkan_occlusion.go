@@ -0,0 +1,74 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// vulkan_occlusion.go skips drawing models that are hidden behind other
+// models. Each occlusion culled model has a bounding box packet that is
+// drawn, after the opaque models, inside an occlusion query using a shader
+// that writes no color or depth. Models whose bounding box had no samples
+// pass the depth test are not drawn. Query results are read when the
+// frame is reused, so visibility is from a frame rendered a few frames
+// ago. Models are drawn until they have a query result.
+//
+// FUTURE: hierarchical Z culling on the CPU to avoid the frame latency.
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gazed/vu/internal/render/vk"
+)
+
+// maxOcclusionQueries is the number of occlusion queries for each frame.
+// Bounding boxes past the limit are not queried and their models are drawn.
+const maxOcclusionQueries = 1024
+
+// createFrameOcclusion creates the occlusion queries for one frame.
+func (vr *vulkanRenderer) createFrameOcclusion(fr *vulkanFrame) (err error) {
+	queryInfo := vk.QueryPoolCreateInfo{QueryType: vk.QUERY_TYPE_OCCLUSION, QueryCount: maxOcclusionQueries}
+	if fr.occlusion, err = vk.CreateQueryPool(vr.device, &queryInfo, nil); err != nil {
+		return fmt.Errorf("vk.CreateQueryPool: %w", err)
+	}
+	return nil
+}
+
+// readFrameOcclusion gets the occlusion query results from the last
+// time the given frame was rendered. The frame fence has been waited on
+// so the results are expected to be available. Models are drawn if the
+// results can't be read.
+func (vr *vulkanRenderer) readFrameOcclusion(fr *vulkanFrame) {
+	clear(vr.occluded)
+	nqueries := uint32(len(fr.occluders))
+	if fr.occlusion == 0 || nqueries == 0 {
+		return
+	}
+	data := make([]byte, nqueries*8) // uint64 sample counts.
+	err := vk.GetQueryPoolResults(vr.device, fr.occlusion, 0, nqueries, data, 8, vk.QueryResultFlags(vk.QUERY_RESULT_64_BIT))
+	if err != nil {
+		return // draw everything.
+	}
+	for i, id := range fr.occluders {
+		if binary.LittleEndian.Uint64(data[i*8:]) == 0 {
+			vr.occluded[id] = true
+		}
+	}
+}
+
+// occludedPacket returns true if the packet model was hidden
+// the last time its bounding box was queried.
+func (vr *vulkanRenderer) occludedPacket(packet Packet) bool {
+	return packet.Occlusion != 0 && !packet.IsOcclusionQuery && vr.occluded[packet.Occlusion]
+}
+
+// beginOcclusionQuery starts an occlusion query for a bounding box packet.
+// Returns false if the packet is not a bounding box or there are no more
+// queries for this frame.
+func (vr *vulkanRenderer) beginOcclusionQuery(fr *vulkanFrame, packet Packet) (query uint32, ok bool) {
+	if !packet.IsOcclusionQuery || fr.occlusion == 0 || len(fr.occluders) >= maxOcclusionQueries {
+		return 0, false
+	}
+	query = uint32(len(fr.occluders))
+	fr.occluders = append(fr.occluders, packet.Occlusion)
+	vk.CmdBeginQuery(fr.cmds, fr.occlusion, query, 0)
+	return query, true
+}
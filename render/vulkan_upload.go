@@ -0,0 +1,228 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// vulkan_upload.go copies new textures and meshes to the GPU from a
+// dedicated upload goroutine so that large asset uploads during gameplay
+// do not block rendering. The caller copies the data to a staging buffer
+// and returns. The upload goroutine records and submits the GPU copy
+// commands and waits on a fence for the copy to finish. Textures and
+// meshes are not drawn until their uploads are complete. Small uploads,
+// like label text, are copied immediately so that they are not skipped
+// for a frame.
+//
+// Vulkan queues must be externally synchronized, so queue access
+// is guarded by the renderer queue lock.
+//
+// FUTURE: use the dedicated transfer queue. This needs queue family
+// ownership transfers when it differs from the graphics queue.
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gazed/vu/internal/render/vk"
+)
+
+// minUploadSize is the smallest upload handled by the upload goroutine.
+const minUploadSize = 64 * 1024 // bytes.
+
+// vulkanUploader tracks the uploads handled by the upload goroutine.
+type vulkanUploader struct {
+	pool    vk.CommandPool // only used by the upload goroutine.
+	jobs    chan *upload   // uploads waiting for the GPU.
+	done    chan *upload   // completed uploads.
+	stopped chan struct{}  // closed when the upload goroutine exits.
+	pending int            // uploads that have not completed.
+}
+
+// upload is the GPU copy for a texture or a group of meshes.
+type upload struct {
+	staging vulkanBuffer    // source data. Released by the upload goroutine.
+	size    int             // staging data size in bytes.
+	copies  []vk.BufferCopy // mesh data copies from staging...
+	dsts    []vk.Buffer     // ...to the vertex buffers.
+	mids    []uint32        // meshes being uploaded.
+	texture bool            // true for a texture upload...
+	image   vulkanImage     // ...to this texture image...
+	tid     uint32          // ...for this texture.
+	err     error           // set if the upload failed.
+}
+
+// createUploader starts the upload goroutine
+// with its own command pool.
+func (vr *vulkanRenderer) createUploader() (err error) {
+	poolInfo := vk.CommandPoolCreateInfo{
+		QueueFamilyIndex: vr.graphicsQIndex,
+		Flags:            vk.COMMAND_POOL_CREATE_TRANSIENT_BIT,
+	}
+	up := &vr.uploader
+	if up.pool, err = vk.CreateCommandPool(vr.device, &poolInfo, nil); err != nil {
+		return fmt.Errorf("vk.CreateCommandPool: %w", err)
+	}
+	up.jobs = make(chan *upload, 64)
+	up.done = make(chan *upload, 64)
+	up.stopped = make(chan struct{})
+	vr.pendingMeshes = map[uint32]bool{}
+	go vr.runUploads()
+	return nil
+}
+
+// disposeUploader stops the upload goroutine after
+// the queued uploads have completed.
+func (vr *vulkanRenderer) disposeUploader() {
+	up := &vr.uploader
+	if up.jobs != nil {
+		vr.flushUploads()
+		close(up.jobs)
+		<-up.stopped
+		up.jobs = nil
+	}
+	if up.pool != 0 {
+		vk.DestroyCommandPool(vr.device, up.pool, nil)
+		up.pool = 0
+	}
+}
+
+// runUploads is the upload goroutine. It handles one upload at a time.
+func (vr *vulkanRenderer) runUploads() {
+	up := &vr.uploader
+	for job := range up.jobs {
+		job.err = vr.copyUpload(up.pool, job)
+		vr.disposeBuffer(&job.staging)
+		up.done <- job
+	}
+	close(up.stopped)
+}
+
+// copyUpload records and submits the GPU copy commands
+// and waits for them to complete.
+func (vr *vulkanRenderer) copyUpload(pool vk.CommandPool, job *upload) (err error) {
+	cmd, err := vr.beginSingleUseCommand(pool)
+	if err != nil {
+		return fmt.Errorf("upload: get command: %w", err)
+	}
+	defer vk.FreeCommandBuffers(vr.device, pool, []vk.CommandBuffer{cmd})
+	for i, region := range job.copies {
+		vk.CmdCopyBuffer(cmd, job.staging.handle, job.dsts[i], []vk.BufferCopy{region})
+	}
+	if job.texture {
+		vr.cmdImageLayout(cmd, &job.image, vk.IMAGE_LAYOUT_UNDEFINED, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL)
+		vr.cmdCopyBufferToImage(cmd, &job.staging, &job.image)
+		vr.cmdImageLayout(cmd, &job.image, vk.IMAGE_LAYOUT_TRANSFER_DST_OPTIMAL, vk.IMAGE_LAYOUT_SHADER_READ_ONLY_OPTIMAL)
+	}
+	if err = vk.EndCommandBuffer(cmd); err != nil {
+		return fmt.Errorf("upload: vk.EndCommandBuffer: %w", err)
+	}
+
+	// submit the copy and wait on the fence without holding the queue.
+	fence, err := vk.CreateFence(vr.device, &vk.FenceCreateInfo{}, nil)
+	if err != nil {
+		return fmt.Errorf("upload: vk.CreateFence: %w", err)
+	}
+	defer vk.DestroyFence(vr.device, fence, nil)
+	submitInfo := vk.SubmitInfo{PCommandBuffers: []vk.CommandBuffer{cmd}}
+	vr.queueLock.Lock()
+	err = vk.QueueSubmit(vr.graphicsQ, []vk.SubmitInfo{submitInfo}, fence)
+	vr.queueLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("upload: vk.QueueSubmit: %w", err)
+	}
+	if err = vk.WaitForFences(vr.device, []vk.Fence{fence}, true, maxTimeout); err != nil {
+		return fmt.Errorf("upload: vk.WaitForFences: %w", err)
+	}
+	return nil
+}
+
+// stageUpload copies the given data to a new staging buffer.
+func (vr *vulkanRenderer) stageUpload(job *upload, data []byte) (err error) {
+	usage := vk.BUFFER_USAGE_TRANSFER_SRC_BIT
+	flags := vk.MEMORY_PROPERTY_HOST_VISIBLE_BIT | vk.MEMORY_PROPERTY_HOST_COHERENT_BIT
+	if err = vr.createBuffer(&job.staging, vk.DeviceSize(len(data)), usage, flags); err != nil {
+		vr.disposeBuffer(&job.staging)
+		return err
+	}
+	if err = vr.loadCPUBuffer(&job.staging, 0, data); err != nil {
+		vr.disposeBuffer(&job.staging)
+		return err
+	}
+	job.size = len(data)
+	return nil
+}
+
+// startUpload copies small uploads immediately. Larger uploads are
+// handed to the upload goroutine and are pending until complete.
+func (vr *vulkanRenderer) startUpload(job *upload) {
+	if job.size < minUploadSize {
+		if err := vr.copyUpload(vr.graphicsQCmdPool, job); err != nil {
+			slog.Error("upload failed", "error", err)
+		}
+		vr.disposeBuffer(&job.staging)
+		return
+	}
+	if job.texture {
+		vr.textures[job.tid].pending = true
+	}
+	for _, mid := range job.mids {
+		vr.pendingMeshes[mid] = true
+	}
+	up := &vr.uploader
+	up.pending++
+	for {
+		select {
+		case up.jobs <- job:
+			return
+		case done := <-up.done:
+			vr.finishUpload(done) // make room while the queue is full.
+		}
+	}
+}
+
+// finishUploads marks the completed uploads as ready to draw.
+// Called each frame before drawing.
+func (vr *vulkanRenderer) finishUploads() {
+	for {
+		select {
+		case done := <-vr.uploader.done:
+			vr.finishUpload(done)
+		default:
+			return
+		}
+	}
+}
+
+// flushUploads waits for all uploads to complete. Used before
+// changing a texture or mesh that may still be uploading.
+func (vr *vulkanRenderer) flushUploads() {
+	for vr.uploader.pending > 0 {
+		vr.finishUpload(<-vr.uploader.done)
+	}
+}
+
+// finishUpload marks the texture or meshes as ready to draw.
+func (vr *vulkanRenderer) finishUpload(job *upload) {
+	vr.uploader.pending--
+	if job.err != nil {
+		slog.Error("upload failed", "error", job.err)
+	}
+	if job.texture {
+		vr.textures[job.tid].pending = false
+	}
+	for _, mid := range job.mids {
+		delete(vr.pendingMeshes, mid)
+	}
+}
+
+// uploaded returns true if the packet texture and mesh
+// uploads have completed.
+func (vr *vulkanRenderer) uploaded(packet Packet) bool {
+	if vr.pendingMeshes[packet.MeshID] {
+		return false
+	}
+	for _, tid := range packet.TextureIDs {
+		if tid < uint32(len(vr.textures)) && vr.textures[tid].pending {
+			return false
+		}
+	}
+	return true
+}
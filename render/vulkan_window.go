@@ -0,0 +1,128 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package render
+
+// vulkan_window.go draws to more than one window. Each window has its
+// own surface, swapchain, and frame resources while sharing the device,
+// render passes, meshes, textures, and shaders of the main window.
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gazed/vu/internal/render/vk"
+)
+
+// addWindow creates the swapchain and frame resources
+// for the given device window.
+func (vr *vulkanRenderer) addWindow(win uint32) (err error) {
+	if win == 0 || vr.findView(win) != nil {
+		return fmt.Errorf("addWindow: window %d already added", win)
+	}
+	v := &vulkanView{win: win}
+	v.frameWidth, v.frameHeight = vr.osdev.WindowSize(win)
+	active := vr.vulkanView
+	vr.vulkanView = v
+	defer func() { vr.vulkanView = active }()
+
+	// the window must use the same surface format as the
+	// shared render passes and shader pipelines.
+	format, images := vr.surfaceFormat, vr.imageCount
+	creates := []func() error{
+		vr.createSurface, // vulkan_windows.go
+		vr.checkPresent,
+		vr.setRenderProperties,
+		func() error {
+			same := vr.surfaceFormat == format
+			vr.surfaceFormat, vr.imageCount = format, images
+			if !same {
+				return fmt.Errorf("window surface format differs from main window")
+			}
+			return nil
+		},
+		vr.createSwapchain,
+		vr.createDepthBuffer,
+		vr.createImageViews,
+		vr.createRenderFrames,
+		vr.createFramebuffers,
+	}
+	for _, create := range creates {
+		if err = create(); err != nil {
+			vr.disposeView(v)
+			return fmt.Errorf("addWindow %d: %w", win, err)
+		}
+	}
+	vr.windows = append(vr.windows, v)
+	slog.Debug("vulkan window added", "window", win, "size", fmt.Sprintf("%d:%d", v.frameWidth, v.frameHeight))
+	return nil
+}
+
+// checkPresent ensures the present queue can present to the active window.
+func (vr *vulkanRenderer) checkPresent() error {
+	canPresent, err := vk.GetPhysicalDeviceSurfaceSupportKHR(vr.physicalDevice, vr.presentQIndex, vr.surface)
+	if err != nil {
+		return fmt.Errorf("vk.GetPhysicalDeviceSurfaceSupportKHR: %w", err)
+	}
+	if !canPresent {
+		return fmt.Errorf("%w: present queue does not support window surface", errors.ErrUnsupported)
+	}
+	return nil
+}
+
+// dropWindow releases the window resources once the GPU is idle.
+func (vr *vulkanRenderer) dropWindow(win uint32) {
+	for i, v := range vr.windows {
+		if v.win == win {
+			vr.waitIdle()
+			vr.windows = append(vr.windows[:i], vr.windows[i+1:]...)
+			vr.disposeView(v)
+			return
+		}
+	}
+}
+
+// useWindow makes the given window the target of the following
+// frame and resize calls. Window 0 is the main window.
+// Returns false if the window has not been added.
+func (vr *vulkanRenderer) useWindow(win uint32) bool {
+	if win == 0 {
+		vr.vulkanView = vr.main
+		return true
+	}
+	if v := vr.findView(win); v != nil {
+		vr.vulkanView = v
+		return true
+	}
+	return false
+}
+
+// findView returns the view for the given window, nil if there is none.
+func (vr *vulkanRenderer) findView(win uint32) *vulkanView {
+	for _, v := range vr.windows {
+		if v.win == win {
+			return v
+		}
+	}
+	return nil
+}
+
+// disposeView releases the window swapchain, frame resources, and surface.
+func (vr *vulkanRenderer) disposeView(v *vulkanView) {
+	active := vr.vulkanView
+	vr.vulkanView = v
+	vr.disposeFramebuffers()
+	vr.disposeRenderFrames()
+	vr.disposeSwapchainResources()
+	if v.surface != 0 {
+		vk.DestroySurfaceKHR(vr.instance, v.surface, nil)
+		v.surface = 0
+	}
+	if vr.lastView == v {
+		vr.lastView, vr.lastFence = nil, 0
+	}
+	vr.vulkanView = active
+	if active == v {
+		vr.vulkanView = vr.main
+	}
+}
@@ -20,11 +20,11 @@ func (vr *vulkanRenderer) instanceExtensions() []string {
 	}
 }
 
-// createSurface associates a vulkan instance with a winOS window.
+// createSurface associates a vulkan instance with the active winOS window.
 // It gets display surface information from the windows specific method
 // in the device layer.
 func (vr *vulkanRenderer) createSurface() (err error) {
-	hinstance, hwnd, err := device.GetRenderSurfaceInfo(vr.osdev)
+	hinstance, hwnd, err := device.GetWindowSurfaceInfo(vr.osdev, vr.win)
 	if hinstance == 0 || hwnd == 0 || err != nil {
 		return fmt.Errorf("device.GetWindowsSurfaceInfo failed %w", err)
 	}
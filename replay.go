@@ -0,0 +1,199 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// replay.go records the user input and frame timing of a session so that
+// the session can be played back through the fixed timestep loop, eg: to
+// reproduce a bug report or to run a gameplay regression test.
+//
+//	eng.RecordReplay(file, seed, state) // until StopReplay.
+//	...
+//	header, err := eng.PlayReplay(file) // restore header.State and header.Seed.
+//
+// Each frame records the user input, the frame delta, and the number of
+// physics steps. Playback replaces these with the recorded values, so the
+// application sees the same session provided that it starts from the same
+// state, changes state only from Update, and draws its random numbers
+// from the replay seed. Application checksums, see ReplayCheck, find the
+// first frame where a playback differs from the recording.
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gazed/vu/device"
+	"github.com/gazed/vu/load"
+)
+
+// ReplayFormat is the replay data format written by RecordReplay.
+const ReplayFormat = 1
+
+// ReplayHeader is the start of a recorded session.
+type ReplayHeader struct {
+	Format int    // ReplayFormat when recorded.
+	Engine string // engine version that recorded the session.
+	Seed   int64  // seed for the session random numbers.
+	State  []byte // application state at the start, eg: a save game.
+}
+
+// RecordReplay records the session to w, starting with a header holding
+// the given random seed and application state, until StopReplay.
+func (eng *Engine) RecordReplay(w io.Writer, seed int64, state []byte) error {
+	if eng.replay != nil {
+		return fmt.Errorf("RecordReplay: replay in progress")
+	}
+	rp := &replay{enc: gob.NewEncoder(w)}
+	header := &ReplayHeader{Format: ReplayFormat, Engine: load.EngineVersion(), Seed: seed, State: state}
+	if err := rp.enc.Encode(header); err != nil {
+		return fmt.Errorf("RecordReplay: %w", err)
+	}
+	eng.replay = rp
+	return nil
+}
+
+// PlayReplay plays back the session recorded in r, replacing the user
+// input and frame timing until the recording ends or StopReplay. The
+// returned header has the seed and state that the application is
+// expected to restore before the next Update.
+func (eng *Engine) PlayReplay(r io.Reader) (header *ReplayHeader, err error) {
+	if eng.replay != nil {
+		return nil, fmt.Errorf("PlayReplay: replay in progress")
+	}
+	rp := &replay{dec: gob.NewDecoder(r)}
+	header = &ReplayHeader{}
+	if err = rp.dec.Decode(header); err != nil {
+		return nil, fmt.Errorf("PlayReplay: %w", err)
+	}
+	if header.Format != ReplayFormat {
+		return nil, fmt.Errorf("PlayReplay: unsupported format %d", header.Format)
+	}
+	eng.replay = rp
+	return header, nil
+}
+
+// Replaying returns true while a replay is being played back.
+func (eng *Engine) Replaying() bool {
+	return eng.replay != nil && eng.replay.dec != nil && !eng.replay.done
+}
+
+// ReplayCheck adds a checksum of the application state, eg: a hash
+// of the player locations, to the current frame. Recordings save the
+// checksum while playback compares it with the recorded checksum.
+// Expected to be called from Update. The first difference is
+// returned by StopReplay.
+func (eng *Engine) ReplayCheck(sum uint64) {
+	if eng.replay != nil {
+		eng.replay.checksum(sum)
+	}
+}
+
+// StopReplay stops recording or playing back a replay. Returns the
+// error that stopped the recording or the first playback checksum that
+// differed from the recording, if any.
+func (eng *Engine) StopReplay() error {
+	if eng.replay == nil {
+		return nil
+	}
+	err := eng.replay.stop()
+	eng.replay = nil
+	return err
+}
+
+// replayFrame records, or replaces, the input and timing of
+// a frame. Called each frame before the physics steps are run.
+func (eng *Engine) replayFrame(now time.Time, delta time.Duration, steps int) (time.Duration, int) {
+	if eng.replay == nil {
+		return delta, steps
+	}
+	return eng.replay.frame(now, delta, steps, eng.app.input)
+}
+
+// =============================================================================
+
+// replayFrame is one recorded frame.
+type replayFrame struct {
+	Time  time.Time     // wall clock time, used to shift key down times.
+	Delta time.Duration // frame time.
+	Steps int           // physics steps run.
+	Input device.Input  // user input.
+	Sums  []uint64      // application checksums, see ReplayCheck.
+}
+
+// replay is either recording or playing back a session.
+type replay struct {
+	enc   *gob.Encoder // non-nil when recording.
+	dec   *gob.Decoder // non-nil when playing back.
+	rec   replayFrame  // current frame.
+	in    *Input       // recorded input copy.
+	count int          // frames recorded or played back.
+	check int          // checksums compared in the current frame.
+	done  bool         // true once the recording has been played back.
+	err   error        // first recording or playback error.
+}
+
+// frame records the current frame, or replaces it with the next
+// recorded frame. Frames are written when the next frame starts
+// so that they include the application checksums.
+func (rp *replay) frame(now time.Time, delta time.Duration, steps int, in *Input) (time.Duration, int) {
+	if rp.enc != nil {
+		rp.write()
+		if rp.in == nil {
+			rp.in = &Input{Pressed: map[int32]bool{}, Down: map[int32]time.Time{}, Released: map[int32]time.Duration{}}
+		}
+		rp.in.Clone((*device.Input)(in))
+		rp.rec = replayFrame{Time: now, Delta: delta, Steps: steps, Input: device.Input(*rp.in)}
+		rp.count++
+		return delta, steps
+	}
+	if rp.done {
+		return delta, steps
+	}
+	rp.rec, rp.check = replayFrame{}, 0
+	if err := rp.dec.Decode(&rp.rec); err != nil {
+		if !errors.Is(err, io.EOF) && rp.err == nil {
+			rp.err = fmt.Errorf("replay frame %d: %w", rp.count, err)
+		}
+		rp.done = true
+		return delta, steps
+	}
+	rp.count++
+	in.Clone(&rp.rec.Input)
+	shift := now.Sub(rp.rec.Time) // keys appear to be held for the recorded time.
+	for k, t := range in.Down {
+		in.Down[k] = t.Add(shift)
+	}
+	return rp.rec.Delta, rp.rec.Steps
+}
+
+// write saves the current frame, keeping the first error.
+func (rp *replay) write() {
+	if rp.count == 0 || rp.err != nil {
+		return
+	}
+	if err := rp.enc.Encode(&rp.rec); err != nil {
+		rp.err = fmt.Errorf("replay frame %d: %w", rp.count, err)
+	}
+}
+
+// checksum saves or compares an application checksum.
+func (rp *replay) checksum(sum uint64) {
+	switch {
+	case rp.enc != nil:
+		rp.rec.Sums = append(rp.rec.Sums, sum)
+	case rp.done || rp.err != nil:
+	case rp.check >= len(rp.rec.Sums) || rp.rec.Sums[rp.check] != sum:
+		rp.err = fmt.Errorf("replay frame %d: checksum %d differs from the recording", rp.count, rp.check)
+	}
+	rp.check++
+}
+
+// stop writes the last recorded frame.
+func (rp *replay) stop() error {
+	if rp.enc != nil {
+		rp.write()
+	}
+	return rp.err
+}
@@ -0,0 +1,105 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run Replay
+func TestReplay(t *testing.T) {
+	start := time.Now()
+	frame := 16 * time.Millisecond
+
+	// session records a few frames of input along with a
+	// checksum of the input, as an application would.
+	session := func(eng *Engine, sum func(frame int) uint64) {
+		in := eng.app.input
+		for i := 0; i < 3; i++ {
+			clear(in.Pressed)
+			in.Mx, in.My = int32(10*i), int32(20*i)
+			if i == 1 {
+				in.Pressed[KA] = true
+				in.Down[KA] = start.Add(time.Duration(i) * frame)
+			}
+			eng.replayFrame(start.Add(time.Duration(i)*frame), frame+time.Duration(i), i)
+			eng.ReplayCheck(sum(i))
+		}
+	}
+
+	// go test -run Replay/playback
+	t.Run("playback", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		buf := &bytes.Buffer{}
+		if err := eng.RecordReplay(buf, 42, []byte("level 1")); err != nil {
+			t.Fatal(err)
+		}
+		session(eng, func(i int) uint64 { return uint64(i) })
+		if err := eng.StopReplay(); err != nil {
+			t.Fatal(err)
+		}
+
+		// play back with different live input and timing.
+		eng = &Engine{app: newApplication()}
+		header, err := eng.PlayReplay(bytes.NewReader(buf.Bytes()))
+		if err != nil || header.Seed != 42 || string(header.State) != "level 1" || header.Format != ReplayFormat {
+			t.Fatalf("expected replay header got %+v %v", header, err)
+		}
+		later := start.Add(time.Hour)
+		in := eng.app.input
+		for i := 0; i < 3; i++ {
+			in.Mx = -1
+			delta, steps := eng.replayFrame(later.Add(time.Duration(i)*frame), time.Second, 3)
+			if delta != frame+time.Duration(i) || steps != i || in.Mx != int32(10*i) || in.My != int32(20*i) {
+				t.Errorf("frame %d: expected recorded frame got %v %d %d", i, delta, steps, in.Mx)
+			}
+			if i == 1 && (!in.Pressed[KA] || !in.Down[KA].Equal(later.Add(frame))) {
+				t.Errorf("expected recorded key press shifted to playback time got %v", in.Down[KA])
+			}
+			eng.ReplayCheck(uint64(i))
+		}
+		if !eng.Replaying() {
+			t.Errorf("expected playback until the recording ends")
+		}
+		if delta, steps := eng.replayFrame(later, time.Second, 3); delta != time.Second || steps != 3 || eng.Replaying() {
+			t.Errorf("expected live frames after the recording ends")
+		}
+		if err := eng.StopReplay(); err != nil {
+			t.Errorf("expected matching checksums got %v", err)
+		}
+	})
+
+	// go test -run Replay/diverge
+	t.Run("diverge", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		buf := &bytes.Buffer{}
+		eng.RecordReplay(buf, 1, nil)
+		session(eng, func(i int) uint64 { return 7 })
+		eng.StopReplay()
+
+		eng = &Engine{app: newApplication()}
+		if _, err := eng.PlayReplay(buf); err != nil {
+			t.Fatal(err)
+		}
+		session(eng, func(i int) uint64 { return 7 + uint64(i/2) }) // differs on the third frame.
+		err := eng.StopReplay()
+		if err == nil || !strings.Contains(err.Error(), "frame 3") {
+			t.Errorf("expected the first differing frame got %v", err)
+		}
+	})
+
+	// go test -run Replay/errors
+	t.Run("errors", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		if _, err := eng.PlayReplay(strings.NewReader("not a replay")); err == nil {
+			t.Errorf("expected invalid replay error")
+		}
+		eng.RecordReplay(&bytes.Buffer{}, 1, nil)
+		if eng.RecordReplay(&bytes.Buffer{}, 1, nil) == nil || eng.Replaying() {
+			t.Errorf("expected one replay at a time")
+		}
+	})
+}
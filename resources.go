@@ -0,0 +1,166 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// resources.go reference counts the asset files used by models and
+// releases the GPU resources of files that are no longer used.
+// Files that were imported, but never used by a model, are kept.
+// Unused files are evicted on the next update, or are cached up to
+// a GPU memory budget and evicted least recently used first. Evicted
+// files are imported again if a model asks for their assets.
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+)
+
+// resourceFile tracks the GPU usage of a loaded asset file.
+type resourceFile struct {
+	aids  []aid  // assets loaded from the file.
+	size  uint64 // approximate GPU bytes used by the file assets.
+	users int    // number of model references to the file assets.
+}
+
+// setResourceBudget sets the GPU bytes of unused files to keep.
+func (l *assetLoader) setResourceBudget(bytes uint64) { l.resBudget = bytes }
+
+// trackFile records the assets and GPU size of a loaded file.
+// Reloaded files keep their users.
+func (l *assetLoader) trackFile(up *assetUpload) {
+	rf, ok := l.files[up.filename]
+	if !ok {
+		rf = &resourceFile{}
+		l.files[up.filename] = rf
+	}
+	rf.aids, rf.size = rf.aids[:0], up.size
+	for _, a := range up.assets {
+		rf.aids = append(rf.aids, a.aid())
+		l.owners[a.aid()] = up.filename
+	}
+}
+
+// acquire records a model reference to the given asset.
+// Assets that were not loaded from a file are ignored.
+func (l *assetLoader) acquire(a asset) {
+	rf := l.files[l.owners[a.aid()]]
+	if rf == nil {
+		return // default or generated asset.
+	}
+	if rf.users++; rf.users == 1 {
+		l.unused = slices.DeleteFunc(l.unused, func(f string) bool { return f == l.owners[a.aid()] })
+	}
+}
+
+// release removes a model reference to the given asset. Files are
+// queued for eviction once none of their assets are referenced.
+// Meshes generated for a single model are dropped.
+func (l *assetLoader) release(a asset) {
+	if m, ok := a.(*mesh); ok && m.generated {
+		l.drops = append(l.drops, m)
+		return
+	}
+	filename := l.owners[a.aid()]
+	rf := l.files[filename]
+	if rf == nil || rf.users <= 0 {
+		return // default asset or not acquired.
+	}
+	if rf.users--; rf.users == 0 {
+		l.unused = append(l.unused, filename) // most recently used last.
+	}
+}
+
+// dropInstanceData queues model instance data to be released.
+func (l *assetLoader) dropInstanceData(iid uint32) {
+	l.dropInsts = append(l.dropInsts, iid)
+}
+
+// dropTarget queues a render target to be released.
+func (l *assetLoader) dropTarget(target uint32) {
+	l.dropTargs = append(l.dropTargs, target)
+}
+
+// releaseResources drops the queued model resources and evicts
+// the least recently used files until the unused files fit within
+// the resource budget. A zero budget evicts all unused files.
+func (l *assetLoader) releaseResources(rc render.Loader) {
+	for _, a := range l.drops {
+		dropAsset(rc, a)
+	}
+	for _, iid := range l.dropInsts {
+		rc.DropInstanceData(iid)
+	}
+	for _, target := range l.dropTargs {
+		rc.DropTarget(target)
+	}
+	l.drops, l.dropInsts, l.dropTargs = l.drops[:0], l.dropInsts[:0], l.dropTargs[:0]
+	if len(l.unused) == 0 {
+		return
+	}
+	cached := uint64(0)
+	for _, filename := range l.unused {
+		cached += l.files[filename].size
+	}
+	kept := l.unused[:0]
+	for _, filename := range l.unused {
+		if (l.resBudget > 0 && cached <= l.resBudget) || l.reloading[filename] {
+			kept = append(kept, filename)
+			continue
+		}
+		cached -= l.files[filename].size
+		l.evict(filename, rc)
+	}
+	l.unused = kept
+}
+
+// evict releases the file assets and forgets the file so that
+// future requests import the file again.
+func (l *assetLoader) evict(filename string, rc render.Loader) {
+	slog.Debug("evict asset file", "filename", filename, "bytes", l.files[filename].size)
+	for _, aid := range l.files[filename].aids {
+		if a, ok := l.assets[aid]; ok {
+			dropAsset(rc, a)
+			delete(l.assets, aid)
+		}
+	}
+	delete(l.files, filename)
+	delete(l.loaded, filename)
+	delete(l.errs, filename)
+	delete(l.watching, filename)
+}
+
+// dropAsset releases the GPU resources for the given asset.
+func dropAsset(rc render.Loader, a asset) {
+	switch la := a.(type) {
+	case *mesh:
+		rc.DropMesh(la.mid)
+	case *texture:
+		rc.DropTexture(la.tid)
+	case *shader:
+		rc.DropShader(la.sid)
+	}
+}
+
+// gpuAsset returns a copy of the GPU references of the given asset
+// so that they can be dropped after the asset is replaced.
+func gpuAsset(a asset) asset {
+	switch la := a.(type) {
+	case *mesh:
+		return &mesh{name: la.name, tag: la.tag, mid: la.mid}
+	case *texture:
+		return &texture{name: la.name, tag: la.tag, tid: la.tid}
+	case *shader:
+		return &shader{name: la.name, tag: la.tag, sid: la.sid}
+	}
+	return a // no GPU resources.
+}
+
+// meshSize returns the GPU bytes for the given mesh data.
+func meshSize(md load.MeshData) (size uint64) {
+	for _, buff := range md {
+		size += uint64(len(buff.Data))
+	}
+	return size
+}
@@ -0,0 +1,404 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// savegame.go saves and restores the application game state in named
+// save slots, eg:
+//
+//	saves := vu.NewSaveGame("mygame", 2)
+//	saves.Register("player", &player).Register("world", &world)
+//	err := saves.Save("quick")
+//	...
+//	err := saves.Load("quick")
+//
+// Each registered state is saved under its name. States are encoded as
+// JSON, the default, or as binary data using encoding/gob, see SetCodec.
+// Save files start with a version header holding the "save" kind, the
+// application save format, and the engine version. JSON saves with an
+// older format are upgraded by the "save" migrations, see
+// RegisterMigration, where the migration data has one entry for each
+// registered state, eg:
+//
+//	vu.RegisterMigration("save", 1, func(doc map[string]any) error {
+//		player := doc["player"].(map[string]any)
+//		player["Health"] = player["HP"]
+//		delete(player, "HP")
+//		return nil
+//	})
+//
+// Binary saves are decoded by field name, so older binary saves load as
+// long as the format change only adds or removes fields.
+//
+// Save files are kept in the per user application data directory, see
+// SaveDir, and are written atomically so that a crash while saving
+// leaves the previous save intact.
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gazed/vu/load"
+)
+
+// SaveCodec is the encoding of the save files.
+type SaveCodec int
+
+// Save file encodings.
+const (
+	SaveJSON   SaveCodec = iota // readable text, ".json" files.
+	SaveBinary                  // compact encoding/gob data, ".save" files.
+)
+
+// saveKind is the data file kind of save games, see RegisterMigration.
+const saveKind = "save"
+
+// SaveDir returns the directory for the save files of the given game:
+//
+//	Windows : %AppData%\game\saves
+//	macOS   : ~/Library/Application Support/game/saves
+//	Linux   : $XDG_DATA_HOME/game/saves or ~/.local/share/game/saves
+func SaveDir(game string) (string, error) {
+	var base string
+	var err error
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		base, err = os.UserConfigDir() // AppData\Roaming, Library/Application Support.
+	default:
+		if base = os.Getenv("XDG_DATA_HOME"); base == "" {
+			var home string
+			home, err = os.UserHomeDir()
+			base = filepath.Join(home, ".local", "share")
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("SaveDir: %w", err)
+	}
+	return filepath.Join(base, game, "saves"), nil
+}
+
+// SaveGame saves and loads the registered application state.
+// Create with NewSaveGame.
+type SaveGame struct {
+	game   string         // application name.
+	format int            // application save format.
+	dir    string         // save directory, SaveDir when empty.
+	codec  SaveCodec      // encoding for new saves.
+	states map[string]any // registered state pointers.
+}
+
+// NewSaveGame creates the save games for the named application using the
+// given save format. The format is increased whenever the registered state
+// changes in a way that needs a migration.
+func NewSaveGame(game string, format int) *SaveGame {
+	return &SaveGame{game: game, format: max(1, format), states: map[string]any{}}
+}
+
+// Register adds a pointer to state that is saved and loaded under the
+// given name. Names are unique and can't be "kind", "format", "engine",
+// or "saved". The state must be encodable by encoding/json and, for
+// binary saves, by encoding/gob.
+func (sg *SaveGame) Register(name string, state any) *SaveGame {
+	switch name {
+	case "", "kind", "format", "engine", "saved":
+		slog.Error("SaveGame.Register invalid name", "name", name)
+		return sg
+	}
+	sg.states[name] = state
+	return sg
+}
+
+// SetCodec sets the encoding used by Save. Load reads either encoding.
+func (sg *SaveGame) SetCodec(codec SaveCodec) *SaveGame {
+	sg.codec = codec
+	return sg
+}
+
+// SetDir sets the save directory, replacing the SaveDir default.
+func (sg *SaveGame) SetDir(dir string) *SaveGame {
+	sg.dir = dir
+	return sg
+}
+
+// SaveSlot describes a save file.
+type SaveSlot struct {
+	Name   string    // slot name given to Save.
+	Saved  time.Time // when the slot was saved.
+	Format int       // application save format.
+	Engine string    // engine version that saved the slot.
+	Binary bool      // true for SaveBinary files.
+}
+
+// Save writes the registered states to the named slot, replacing any
+// existing save in the slot. Slot names are used as file names.
+func (sg *SaveGame) Save(slot string) error {
+	dir, err := sg.slotDir(slot)
+	if err != nil {
+		return fmt.Errorf("Save %s: %w", slot, err)
+	}
+	hdr := saveHeader{Kind: saveKind, Format: sg.format, Engine: load.EngineVersion(), Saved: time.Now()}
+	buf := &bytes.Buffer{}
+	ext, other := ".json", ".save"
+	if sg.codec == SaveBinary {
+		ext, other = other, ext
+		err = sg.encodeBinary(buf, hdr)
+	} else {
+		err = sg.encodeJSON(buf, hdr)
+	}
+	if err != nil {
+		return fmt.Errorf("Save %s: %w", slot, err)
+	}
+	if err := writeAtomic(filepath.Join(dir, slot+ext), buf.Bytes()); err != nil {
+		return fmt.Errorf("Save %s: %w", slot, err)
+	}
+	os.Remove(filepath.Join(dir, slot+other)) // one file for each slot.
+	return nil
+}
+
+// Load reads the named slot into the registered states. States that are
+// not in the save are unchanged. Saves with a newer format are not
+// loaded. States may be partly loaded when a save fails to decode.
+func (sg *SaveGame) Load(slot string) error {
+	dir, err := sg.slotDir(slot)
+	if err != nil {
+		return fmt.Errorf("Load %s: %w", slot, err)
+	}
+	path := filepath.Join(dir, slot+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join(dir, slot+".save"))
+		if err == nil {
+			err = sg.decodeBinary(bytes.NewReader(data))
+		}
+	} else if err == nil {
+		err = sg.decodeJSON(data)
+	}
+	if err != nil {
+		return fmt.Errorf("Load %s: %w", slot, err)
+	}
+	return nil
+}
+
+// Delete removes the named slot. Deleting an empty slot is not an error.
+func (sg *SaveGame) Delete(slot string) error {
+	dir, err := sg.slotDir(slot)
+	if err != nil {
+		return fmt.Errorf("Delete %s: %w", slot, err)
+	}
+	for _, ext := range []string{".json", ".save"} {
+		if err := os.Remove(filepath.Join(dir, slot+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Delete %s: %w", slot, err)
+		}
+	}
+	return nil
+}
+
+// Slots returns the saved slots, most recently saved first.
+// Files that are not save games are ignored.
+func (sg *SaveGame) Slots() ([]SaveSlot, error) {
+	dir, err := sg.saveDir()
+	if err != nil {
+		return nil, fmt.Errorf("Slots: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []SaveSlot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Slots: %w", err)
+	}
+	slots := []SaveSlot{}
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		name := strings.TrimSuffix(file.Name(), ext)
+		if file.IsDir() || (ext != ".json" && ext != ".save") {
+			continue
+		}
+		hdr, err := readSaveHeader(filepath.Join(dir, file.Name()), ext == ".save")
+		if err != nil || hdr.Kind != saveKind {
+			continue
+		}
+		slots = append(slots, SaveSlot{Name: name, Saved: hdr.Saved, Format: hdr.Format, Engine: hdr.Engine, Binary: ext == ".save"})
+	}
+	slices.SortFunc(slots, func(a, b SaveSlot) int { return b.Saved.Compare(a.Saved) })
+	return slots, nil
+}
+
+// saveDir returns the save directory.
+func (sg *SaveGame) saveDir() (string, error) {
+	if sg.dir != "" {
+		return sg.dir, nil
+	}
+	return SaveDir(sg.game)
+}
+
+// slotDir validates the slot name and returns the save directory.
+func (sg *SaveGame) slotDir(slot string) (string, error) {
+	if slot == "" || slot != filepath.Base(slot) || strings.ContainsAny(slot, `/\:*?"<>|`) || strings.HasPrefix(slot, ".") {
+		return "", fmt.Errorf("invalid slot name")
+	}
+	return sg.saveDir()
+}
+
+// =============================================================================
+// save file encoding.
+
+// saveHeader starts each save file.
+type saveHeader struct {
+	Kind   string    `json:"kind"`
+	Format int       `json:"format"`
+	Engine string    `json:"engine,omitempty"`
+	Saved  time.Time `json:"saved"`
+}
+
+// encodeJSON writes the header and states as a single JSON object.
+func (sg *SaveGame) encodeJSON(w io.Writer, hdr saveHeader) error {
+	doc := map[string]any{"kind": hdr.Kind, "format": hdr.Format, "engine": hdr.Engine, "saved": hdr.Saved}
+	for name, state := range sg.states {
+		doc[name] = state
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// decodeJSON checks the header, migrates older formats,
+// and decodes the registered states.
+func (sg *SaveGame) decodeJSON(data []byte) error {
+	hdr := saveHeader{}
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return err
+	}
+	if err := sg.check(hdr); err != nil {
+		return err
+	}
+	if hdr.Format < sg.format {
+		doc := map[string]any{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		if err := migrateDoc(doc, saveKind, hdr.Format, sg.format); err != nil {
+			return err
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	}
+	saved := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	for name, state := range sg.states {
+		if raw, ok := saved[name]; ok {
+			if err := json.Unmarshal(raw, state); err != nil {
+				return fmt.Errorf("state %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeBinary writes the header followed by the gob encoded states.
+// Each state is encoded separately so that states can be added and
+// removed between formats.
+func (sg *SaveGame) encodeBinary(w io.Writer, hdr saveHeader) error {
+	states := map[string][]byte{}
+	for name, state := range sg.states {
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(state); err != nil {
+			return fmt.Errorf("state %s: %w", name, err)
+		}
+		states[name] = buf.Bytes()
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&hdr); err != nil {
+		return err
+	}
+	return enc.Encode(states)
+}
+
+// decodeBinary checks the header and decodes the registered states.
+func (sg *SaveGame) decodeBinary(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	hdr := saveHeader{}
+	if err := dec.Decode(&hdr); err != nil {
+		return err
+	}
+	if err := sg.check(hdr); err != nil {
+		return err
+	}
+	states := map[string][]byte{}
+	if err := dec.Decode(&states); err != nil {
+		return err
+	}
+	for name, state := range sg.states {
+		if data, ok := states[name]; ok {
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(state); err != nil {
+				return fmt.Errorf("state %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// check returns an error if the save can't be loaded.
+func (sg *SaveGame) check(hdr saveHeader) error {
+	switch {
+	case hdr.Kind != saveKind:
+		return fmt.Errorf("not a save file")
+	case hdr.Format > sg.format:
+		return fmt.Errorf("save format %d saved by engine %s is newer than supported format %d", hdr.Format, hdr.Engine, sg.format)
+	}
+	return nil
+}
+
+// readSaveHeader reads the header of a save file.
+func readSaveHeader(path string, binary bool) (hdr saveHeader, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return hdr, err
+	}
+	defer file.Close()
+	if binary {
+		err = gob.NewDecoder(file).Decode(&hdr)
+	} else {
+		err = json.NewDecoder(file).Decode(&hdr)
+	}
+	return hdr, err
+}
+
+// writeAtomic replaces the file with the given data by writing a
+// temporary file in the same directory and renaming it over the file.
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // only exists if something failed.
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil { // on disk before the rename.
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,103 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -run SaveGame
+func TestSaveGame(t *testing.T) {
+	type player struct {
+		Name   string
+		Health int
+		Items  []string
+	}
+	type world struct{ Level int }
+	RegisterMigration("save", 1, func(doc map[string]any) error {
+		p := doc["player"].(map[string]any)
+		p["Health"] = p["HP"]
+		delete(p, "HP")
+		return nil
+	})
+	dir := t.TempDir()
+
+	// go test -run SaveGame/slots
+	t.Run("slots", func(t *testing.T) {
+		for _, codec := range []SaveCodec{SaveJSON, SaveBinary} {
+			p, w := player{Name: "hero", Health: 90, Items: []string{"sword"}}, world{Level: 3}
+			saves := NewSaveGame("test", 2).SetDir(dir).SetCodec(codec)
+			saves.Register("player", &p).Register("world", &w)
+			if err := saves.Save("quick"); err != nil {
+				t.Fatal(err)
+			}
+			p, w = player{}, world{}
+			if err := saves.Load("quick"); err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != "hero" || p.Health != 90 || len(p.Items) != 1 || w.Level != 3 {
+				t.Errorf("codec %d: expected loaded state got %+v %+v", codec, p, w)
+			}
+		}
+		saves := NewSaveGame("test", 2).SetDir(dir)
+		saves.Save("auto")
+		slots, err := saves.Slots()
+		if err != nil || len(slots) != 2 || slots[0].Name != "auto" || !slots[1].Binary || slots[1].Format != 2 {
+			t.Errorf("expected newest slot first got %+v %v", slots, err)
+		}
+		files, _ := os.ReadDir(dir)
+		if len(files) != 2 {
+			t.Errorf("expected one file per slot and no temporary files got %d", len(files))
+		}
+		if err := saves.Delete("quick"); err != nil || saves.Load("quick") == nil {
+			t.Errorf("expected deleted slot got %v", err)
+		}
+	})
+
+	// go test -run SaveGame/migrate
+	t.Run("migrate", func(t *testing.T) {
+		old := `{"kind": "save", "format": 1, "player": {"Name": "hero", "HP": 50}}`
+		os.WriteFile(filepath.Join(dir, "old.json"), []byte(old), 0o644)
+		p := player{}
+		saves := NewSaveGame("test", 2).SetDir(dir).Register("player", &p)
+		if err := saves.Load("old"); err != nil || p.Health != 50 || p.Name != "hero" {
+			t.Errorf("expected migrated health got %+v %v", p, err)
+		}
+	})
+
+	// go test -run SaveGame/errors
+	t.Run("errors", func(t *testing.T) {
+		p := player{}
+		newer := NewSaveGame("test", 3).SetDir(dir).Register("player", &p)
+		newer.Save("newer")
+		saves := NewSaveGame("test", 2).SetDir(dir).Register("player", &p)
+		if err := saves.Load("newer"); err == nil || !strings.Contains(err.Error(), "newer than supported format 2") {
+			t.Errorf("expected newer format error got %v", err)
+		}
+		for _, slot := range []string{"", "../up", "a/b", ".hidden"} {
+			if saves.Save(slot) == nil {
+				t.Errorf("expected invalid slot %q", slot)
+			}
+		}
+		os.WriteFile(filepath.Join(dir, "junk.json"), []byte(`{"level": 1}`), 0o644)
+		if saves.Load("junk") == nil {
+			t.Errorf("expected not a save file error")
+		}
+		saves.Register("kind", &p)
+		if _, ok := saves.states["kind"]; ok {
+			t.Errorf("expected reserved name to be ignored")
+		}
+	})
+
+	// go test -run SaveGame/dir
+	t.Run("dir", func(t *testing.T) {
+		t.Setenv("XDG_DATA_HOME", "/data")
+		dir, err := SaveDir("game")
+		if err != nil || (dir != filepath.Join("/data", "game", "saves") && !strings.HasSuffix(dir, filepath.Join("game", "saves"))) {
+			t.Errorf("expected game save directory got %q %v", dir, err)
+		}
+	})
+}
@@ -5,11 +5,14 @@ package vu
 // scene.go transforms application created models into render draw calls.
 
 import (
+	"cmp"
 	"log/slog"
 	"math"
+	"slices"
 	"sort"
 
 	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
 	"github.com/gazed/vu/render"
 )
 
@@ -25,6 +28,14 @@ const (
 	Scene2D SceneType = SceneType(render.Pass2D)
 )
 
+// FUTURE: live render targets shown in Scene2D models, eg: a minimap,
+// rear-view mirror, or character portrait. The render support exists,
+// see render.Context.LoadTarget and the water reflections in water.go.
+// Needs an application API that gives a scene camera a render target
+// and a texture name for the target so a 2D model can sample it.
+// The target would then live as long as the Scene2D models showing it,
+// and be skipped while those models are culled or hidden.
+
 // Cam returns the camera instace for a scene, returning nil
 // if the entity is not a scene.
 //
@@ -46,10 +57,20 @@ func (e *Entity) Cam() *Camera {
 type scene struct {
 	pid render.PassID // scene render pass
 	eid eID           // Scene and top level scene graph node.
-	fbo uint32        // Render target. Default 0: display buffer.
+	fbo uint32        // Render target. Default 0: the window.
+	win uint32        // Window showing the scene. Default 0: main window.
 
 	// Cam is this scenes camera data. Guaranteed to be non-nil.
 	cam *Camera // Created automatically with a new scene.
+
+	// viewport is the window area drawn by the scene camera.
+	view   render.Viewport // zero for the whole window.
+	vx, vy int32           // viewport top left in window pixels.
+	views  []*scene        // other cameras drawing the scene, see AddView.
+
+	// fog is shared by the scene views, see SetFog.
+	fog      lin.V4 // x:start y:end z:height falloff w:base height
+	fogColor lin.V4 // rgb and the most fog.
 }
 
 // newScene creates a new transform hiearchy branch with its own camera.
@@ -65,15 +86,24 @@ func newScene(eid eID, passID render.PassID) *scene {
 }
 
 // setProjection updates scenes cameras projection matrix to match
-// the latest application window size.
+// the latest application window size and the scene viewport.
 func (s *scene) setProjection(ww, wh uint32) {
+	s.vx, s.vy, ww, wh = s.viewRect(ww, wh)
+	if ww == 0 || wh == 0 {
+		ww, wh = 1, 1 // viewport outside the window is not drawn.
+	}
 	w, h := float64(ww), float64(wh)
 	c := s.cam
+	c.scale, c.ww, c.wh = 0, ww, wh
 	switch {
+	case c.custom != nil:
+		c.setCustom()
+	case c.isPixelPerfect():
+		c.setPixelPerfect(ww, wh)
 	case s.pid == render.Pass2D:
-		c.setOrthographic(0, w, 0, h, c.near, c.far)
+		c.setOrthographic(0, w/c.zoom, 0, h/c.zoom, c.near, c.far)
 	default:
-		c.setPerspective(c.fov, w/h, c.near, c.far)
+		c.setProjection3D(w / h)
 	}
 	c.focus = true
 }
@@ -84,6 +114,12 @@ func (s *scene) setPassUniformData(app *application, pass *render.Pass) {
 	pass.Uniforms[load.VIEW] = render.M4ToBytes(s.cam.vm, pass.Uniforms[load.VIEW])
 	cx, cy, cz := s.cam.At()
 	pass.Uniforms[load.CAM] = render.V4SToBytes(cx, cy, cz, 0, pass.Uniforms[load.CAM])
+	fog := s
+	if sc := app.scenes.get(s.eid); sc != nil {
+		fog = sc // views use the scene fog.
+	}
+	pass.Uniforms[load.FOG] = render.V4ToBytes(&fog.fog, pass.Uniforms[load.FOG])
+	pass.Uniforms[load.FOGCOLOR] = render.V4ToBytes(&fog.fogColor, pass.Uniforms[load.FOGCOLOR])
 
 	// adds any scene lights to the render pass.
 	// lights are children of the scene.
@@ -114,11 +150,7 @@ func (s *scene) setPassUniformData(app *application, pass *render.Pass) {
 			slog.Warn("scene:setPassUniformData to many lights")
 			break
 		}
-		light := &pass.Lights[nlights]
-		px, py, pz := kp.at()
-		light.X, light.Y, light.Z = float32(px), float32(py), float32(pz)
-		light.R, light.G, light.B = l.r, l.g, l.b
-		light.Intensity = l.intensity
+		l.fill(&pass.Lights[nlights], kp.tn.Loc, kp.tn.Rot)
 		nlights += 1
 
 	}
@@ -136,7 +168,10 @@ type scenes struct {
 	released []asset        // Scene assets being disposed.
 
 	// Scratch variables: reused each update.
-	parts []uint32 // Flattened pov hiearchy.
+	shown   []*scene // Scenes and views shown in a window.
+	targets []*scene // Cameras drawing into render targets.
+	parts   []uint32 // Flattened pov hiearchy.
+	box     *lin.M4  // Occlusion bounding box transform.
 }
 
 // newScenes creates the scene component manager and is expected to
@@ -145,6 +180,7 @@ func newScenes() *scenes {
 	ss := &scenes{}
 	ss.all = map[eID]*scene{}
 	ss.parts = []uint32{} // updated each frame
+	ss.box = lin.NewM4()  // updated each frame
 	return ss
 }
 
@@ -158,63 +194,146 @@ func (ss *scenes) create(eid eID, sceneType SceneType) *scene {
 	return scene // don't allow creating over existing scene.
 }
 
-// resize the scene cameras to the new window dimensions.
-func (ss *scenes) resize(ww, wh uint32) {
-	for _, scene := range ss.all {
-		scene.cam.focus = true
-	}
-	ss.setViewMatrixes(ww, wh)
+// resize the window scene cameras to the new window dimensions.
+func (ss *scenes) resize(win, ww, wh uint32) {
+	ss.eachView(func(scene *scene) {
+		if scene.win == win {
+			scene.cam.focus = true
+		}
+	})
+	ss.setViewMatrixes(win, ww, wh)
 }
 
 // setViewMatrixes calculates the current render frame camera locations and
-// orientations. Called before rendering to adjust for app camera changes.
-func (ss *scenes) setViewMatrixes(w, h uint32) {
-	for _, scene := range ss.all {
-		scene.setProjection(w, h)
-		scene.cam.updateView()
-	}
+// orientations for the scenes shown in the given window. Called before
+// rendering to adjust for app camera changes.
+func (ss *scenes) setViewMatrixes(win, w, h uint32) {
+	ss.eachView(func(scene *scene) {
+		if scene.win == win {
+			scene.setProjection(w, h)
+			scene.cam.updateView()
+		}
+	})
 }
 
 // get returns the Scene associated with the given entity.
 func (ss *scenes) get(id eID) *scene { return ss.all[id] }
 
-// getFrame converts the scene transform hierarchy to a frame of render packets.
+// first returns the earliest created scene of the given type.
+// Returns nil if there is no scene of the given type.
+func (ss *scenes) first(app *application, st SceneType) *Entity {
+	found := eID(0)
+	for eid, sc := range ss.all {
+		if SceneType(sc.pid) == st && (found == 0 || eid.id() < found.id()) {
+			found = eid
+		}
+	}
+	if found == 0 {
+		return nil
+	}
+	return &Entity{app: app, eid: found}
+}
+
+// getFrame converts the transform hierarchy of the scenes shown in the
+// given window to a frame of render packets. The first 3D and 2D scenes
+// use the first two passes. Other scenes and scene views shown in the
+// window use the passes after them.
 //
 // The provided frame memory is recycled in that the render packets are lazy
 // allocated and reused each update. The updated frame is returned.
-func (ss *scenes) getFrame(app *application, frame []render.Pass) []render.Pass {
+func (ss *scenes) getFrame(app *application, win uint32, frame []render.Pass) []render.Pass {
 	if len(ss.all) <= 0 {
 		return frame // the app hasn't created scenes yet.
 	}
-	if len(ss.all) > 2 {
-		slog.Error("one or two scenes supported: 3D or 2D or 3D+2D")
-		return frame
-	}
+	ss.shown = ss.shown[:0]
+	ss.eachView(func(sc *scene) {
+		if sc.win == win {
+			ss.shown = append(ss.shown, sc)
+		}
+	})
+	slices.SortStableFunc(ss.shown, func(a, b *scene) int { return cmp.Compare(a.eid.id(), b.eid.id()) })
 
 	// turn the scene models into a frame of render.Packets.
-	for _, sc := range ss.all {
-		pass := &frame[sc.pid]           // a scene is either a 3D or 2D render pass.
-		pass.Reset()                     // reset and reuse previous pass.
-		sc.setPassUniformData(app, pass) // set scene uniform data in the pass.
-		if n := app.povs.getNode(sc.eid); n != nil && !n.cull {
-			index := app.povs.index[sc.eid]
-			ss.parts = ss.listParts(app, sc, index, ss.parts[:0])
-			pass.Packets = ss.renderParts(app, sc, ss.parts, pass.Packets)
-
-			// sort the render pass packets.
-			sort.SliceStable(pass.Packets, func(i, j int) bool {
-				return pass.Packets[i].Bucket < pass.Packets[j].Bucket
+	ss.targets = ss.targets[:0]
+	passes, views := int(render.Pass2D)+1, [2]int{}
+	for _, sc := range ss.shown {
+		if views[sc.pid] >= render.MaxViews {
+			slog.Error("too many scene views", "window", win, "max_views", render.MaxViews)
+			continue
+		}
+		index := int(sc.pid) // a scene is either a 3D or 2D render pass.
+		if views[sc.pid] > 0 {
+			index, passes = passes, passes+1
+		}
+		views[sc.pid]++
+		for len(frame) <= index {
+			frame = append(frame, render.NewPass())
+		}
+		ss.fillPass(app, sc, &frame[index]) // reuse previous pass.
+		if sc.pid == render.Pass3D && ss.all[sc.eid] == sc {
+			ss.targets = app.waters.views(app, sc, ss.parts, ss.targets)
+		}
+	}
+
+	// then the scenes drawn into render targets, eg: water reflections.
+	for i, sc := range ss.targets {
+		if i >= render.MaxTargets {
+			slog.Error("too many render targets", "window", win, "max_targets", render.MaxTargets)
+			break
+		}
+		index := passes
+		passes++
+		for len(frame) <= index {
+			frame = append(frame, render.NewPass())
+		}
+		ss.fillPass(app, sc, &frame[index])
+	}
+
+	// clear the first passes when they are not used.
+	for pid, n := range views {
+		if n == 0 && pid < len(frame) {
+			frame[pid].Reset()
+			frame[pid].ID, frame[pid].Viewport = render.PassID(pid), render.Viewport{}
+		}
+	}
+	return frame[:min(passes, len(frame))]
+}
+
+// fillPass resets the given pass and fills it with the scene uniforms
+// and the render packets for the scene models seen by the scene camera.
+// Water surfaces are not drawn into the water render targets.
+func (ss *scenes) fillPass(app *application, sc *scene, pass *render.Pass) {
+	pass.Reset() // reset and reuse previous pass.
+	pass.ID, pass.Viewport, pass.Target = sc.pid, sc.view, sc.fbo
+	sc.setPassUniformData(app, pass) // set scene uniform data in the pass.
+	ss.parts = ss.parts[:0]
+	if n := app.povs.getNode(sc.eid); n == nil || n.cull {
+		return
+	}
+	if sc.pid == render.Pass3D {
+		ss.parts = app.spatial.inView(app, sc, ss.parts)
+		if sc.fbo != 0 {
+			ss.parts = slices.DeleteFunc(ss.parts, func(index uint32) bool {
+				return app.waters.get(app.povs.povs[index].eid) != nil
 			})
 		}
-		frame[sc.pid] = *pass // save the updated pass.
+		ss.setDistances(app, sc, ss.parts)
+	} else {
+		index := app.povs.index[sc.eid]
+		ss.parts = ss.listParts(app, sc, index, ss.parts)
 	}
-	return frame
+	pass.Packets = ss.renderParts(app, sc, ss.parts, pass.Packets)
+
+	// sort the render pass packets.
+	sort.SliceStable(pass.Packets, func(i, j int) bool {
+		return pass.Packets[i].Bucket < pass.Packets[j].Bucket
+	})
 }
 
 // listParts recursively turns the Pov hierarchy into a flat list using a depth
 // first traversal. Pov's not affecting the rendered scene are excluded.
-//
-// TODO: cull more Pov's - often based on camera distance.
+// Used for 2D scenes. 3D scenes use the spatial index to cull parts
+// outside the camera view, see spatial.go.
 func (ss *scenes) listParts(app *application, sc *scene, index uint32, parts []uint32) []uint32 {
 	p := app.povs.povs[index]
 	n := app.povs.nodes[index]
@@ -224,13 +343,7 @@ func (ss *scenes) listParts(app *application, sc *scene, index uint32, parts []u
 
 	// get the model for this part
 	if m := app.models.get(p.eid); m != nil {
-		w := p.tw.Loc
 		parts = append(parts, index)
-		if sc.pid == render.Pass3D {
-			// save distance to camera for transparency sorting.
-			// closer objects drawn last.
-			m.tocam = sc.cam.distance(w.X, w.Y, w.Z)
-		}
 	}
 
 	// recurse scene graph processing children of viable elements.
@@ -242,6 +355,22 @@ func (ss *scenes) listParts(app *application, sc *scene, index uint32, parts []u
 	return parts
 }
 
+// setDistances saves the distance to camera and the view depth used
+// for transparency sorting where closer objects are drawn last.
+// Distances are calculated in parallel since each part is independent.
+func (ss *scenes) setDistances(app *application, sc *scene, parts []uint32) {
+	vm := sc.cam.vm
+	app.work.run(len(parts), 256, func(start, end int) {
+		for _, index := range parts[start:end] {
+			p := &app.povs.povs[index]
+			w := p.tw.Loc
+			m := app.models.get(p.eid)
+			m.tocam = sc.cam.distance(w.X, w.Y, w.Z)
+			m.depth = -(w.X*vm.Xz + w.Y*vm.Yz + w.Z*vm.Zz + vm.Wz) // camera looks down -Z.
+		}
+	})
+}
+
 // renderParts prepares for rendering by converting a sequenced list
 // of pov's into render packets.
 func (ss *scenes) renderParts(app *application, sc *scene, parts []uint32, packets render.Packets) render.Packets {
@@ -264,6 +393,14 @@ func (ss *scenes) renderParts(app *application, sc *scene, parts []uint32, packe
 
 					// exclude models that are not ready to render.
 					packets = packets.DiscardLastPacket()
+				} else if sc.pid == render.Pass3D {
+					index := len(packets) - 1 // model packet.
+					if m.occlude != nil && !m.isInstanced && sc.fbo == 0 {
+						packets = ss.occlusionPacket(app, sc, p, m, packets)
+					}
+					if m.lodFade > 0 {
+						packets = ss.lodFadePacket(m, index, packets)
+					}
 				}
 			}
 		}
@@ -286,15 +423,19 @@ func (ss *scenes) dispose(eid eID, dead []eID) []eID {
 
 // newBucket produces a number that is used to order draw calls
 // from lowest to highest bucket value, ie: sort a<b.
-//   - Pass.... DrawType ShaderID ........ Distance to Camera.................
+//   - Pass.... LayrType ShaderID ........ View depth.........................
 //     00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000
 //     F   F    F   F    F   F    F   F    F   F    F   F    F   F    F   F
 //   - Pass bits is the render pass.
-//   - DrawType sorts within groups of objects.
-//     8 Skydome     : draw first
-//     4 Opaque      : draw next, generally drawn in order created.
-//     1 Transparent : draw last, sorted back to front using distance to camera.
-//   - ShaderID to reduce pipeline switches.
+//   - Layer bits are the model draw layer, see SetLayer.
+//   - DrawType sorts within a layer, see RenderQueue.
+//     0 Background  : draw first, eg: skydomes.
+//     1 Opaque      : draw next, generally drawn in order created.
+//     4 Occlusion   : bounding boxes after opaque objects.
+//     8 Transparent : draw later, sorted back to front using view depth.
+//     C Overlay     : draw last, sorted back to front using view depth.
+//   - ShaderID to reduce pipeline switches. Cleared for depth sorted
+//     objects so that the view depth decides the draw order.
 //
 // Pass bits have their values reversed so that low
 // values result in higher bucket numbers, ie:
@@ -305,10 +446,17 @@ func newBucket(pass render.PassID) uint64 {
 	return b | drawOpaque                 // opaque is default
 }
 
-// setBucketDistance to camera for sorting transparent objects.
-// closer objects should be drawn last.
-func setBucketDistance(b uint64, toCam float64) uint64 {
-	return b | uint64(math.Float32bits(float32(toCam)))
+// setBucketDepth sets the view depth for sorting transparent objects
+// where closer objects are drawn last. The shader is cleared so that
+// depth is more important than pipeline switches.
+func setBucketDepth(b uint64, depth float64) uint64 {
+	bits := math.Float32bits(float32(depth))
+	if bits&0x80000000 != 0 {
+		bits = ^bits // negative floats sort in reverse.
+	} else {
+		bits |= 0x80000000 // positive floats sort after negative.
+	}
+	return b&clearShaderID&clearDistance | uint64(^bits) // far before near.
 }
 
 // setBucketType marks the object as the given type.
@@ -337,8 +485,11 @@ const (
 
 	// draw types.
 	clearType       uint64 = 0xFFF0FFFFFFFFFFFF
+	drawBackground  uint64 = 0x0000000000000000 // background objects first.
 	drawOpaque      uint64 = 0x0001000000000000 // opaque objects before transparent
-	drawTransparent uint64 = 0x0008000000000000 // transparent objects last.
+	drawOcclusion   uint64 = 0x0004000000000000 // occlusion bounding boxes after opaque.
+	drawTransparent uint64 = 0x0008000000000000 // transparent objects after opaque.
+	drawOverlay     uint64 = 0x000C000000000000 // overlay objects last.
 
 	// layers.
 	clearLayer uint64 = 0xFF0FFFFFFFFFFFFF
@@ -3,7 +3,6 @@
 package vu
 
 import (
-	"math"
 	"sort"
 	"testing"
 
@@ -23,7 +22,7 @@ func TestScene(t *testing.T) {
 		scene.AddLight(PointLight).SetLight(1.0, 0.1, 0.1, 10).SetAt(5, 4, 3)
 
 		// check passes
-		passes := app.scenes.getFrame(app, app.frame)
+		passes := app.scenes.getFrame(app, 0, app.frame)
 		if len(passes) != 2 {
 			t.Errorf("expected 2 render passes, got %d", len(passes))
 		}
@@ -44,7 +43,7 @@ func TestScene(t *testing.T) {
 		scene.AddModel("shd:icon", "msh:quad", "tex:color:test")
 
 		// check passes
-		passes := app.scenes.getFrame(app, app.frame)
+		passes := app.scenes.getFrame(app, 0, app.frame)
 		if len(passes) != 2 {
 			t.Errorf("expected 2 render passes, got %d", len(passes))
 		}
@@ -55,6 +54,77 @@ func TestScene(t *testing.T) {
 			t.Errorf("expected 3-3D render packets, got %d", packetCount)
 		}
 	})
+
+	t.Run("occlusion culled model", func(t *testing.T) {
+		app := newApplication()
+		app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+		occlude := newShader("occlude")
+		app.ld.assets[occlude.aid()] = occlude
+		scene := app.addScene(Scene3D)
+		me := scene.AddModel("shd:icon", "msh:cube", "tex:color:test")
+		me.SetOcclusionCull(1, 1, 1).SetAt(0, 0, -10)
+
+		// expect the model packet and its bounding box packet.
+		app.povs.setWorldMatrix(app.work, 0)
+		packets := app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets
+		if len(packets) != 2 {
+			t.Fatalf("expected model and bounding box packets, got %d", len(packets))
+		}
+		model, box := packets[0], packets[1]
+		if model.IsOcclusionQuery {
+			model, box = box, model
+		}
+		if model.IsOcclusionQuery || !box.IsOcclusionQuery {
+			t.Errorf("expected one bounding box packet")
+		}
+		if model.Occlusion != uint32(me.eid) || box.Occlusion != model.Occlusion {
+			t.Errorf("expected occlusion ID %d got %d %d", me.eid, model.Occlusion, box.Occlusion)
+		}
+
+		// the camera is inside the bounding box.
+		me.SetAt(0, 0, -1)
+		packets = app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets
+		if len(packets) != 1 || packets[0].Occlusion != 0 {
+			t.Errorf("expected only the model packet, got %d", len(packets))
+		}
+	})
+
+	t.Run("scene windows", func(t *testing.T) {
+		app := newApplication()
+		app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+		main := app.addScene(Scene3D)
+		main.AddModel("shd:icon", "msh:cube", "tex:color:test")
+		side := app.addScene(Scene3D).SetWindow(1)
+		side.AddModel("shd:icon", "msh:cube", "tex:color:test")
+		side.AddModel("shd:icon", "msh:quad", "tex:color:test")
+		app.addScene(Scene2D).SetWindow(1)
+
+		// each window only draws its own scenes.
+		app.povs.setWorldMatrix(app.work, 0)
+		if n := len(app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets); n != 1 {
+			t.Errorf("expected 1 main window packet, got %d", n)
+		}
+		frame := []render.Pass{render.NewPass(), render.NewPass()}
+		if n := len(app.scenes.getFrame(app, 1, frame)[render.Pass3D].Packets); n != 2 {
+			t.Errorf("expected 2 side window packets, got %d", n)
+		}
+
+		// the window size only changes the window scene cameras.
+		app.scenes.setViewMatrixes(0, 800, 600)
+		app.scenes.setViewMatrixes(1, 400, 300)
+		if c := main.Cam(); c.ww != 800 || c.wh != 600 {
+			t.Errorf("expected main camera 800x600 got %dx%d", c.ww, c.wh)
+		}
+		if c := side.Cam(); c.ww != 400 || c.wh != 300 {
+			t.Errorf("expected side camera 400x300 got %dx%d", c.ww, c.wh)
+		}
+
+		// moving a scene clears its pass in the old window.
+		main.SetWindow(2)
+		if n := len(app.scenes.getFrame(app, 0, app.frame)[render.Pass3D].Packets); n != 0 {
+			t.Errorf("expected stale main window pass to be cleared, got %d", n)
+		}
+	})
 }
 
 // mock render context.
@@ -65,23 +135,75 @@ func (rc *mrc) UpdateTexture(tid uint32, img *load.ImageData) (err error) { retu
 func (rc *mrc) LoadMesh(load.MeshData) (uint32, error)                    { return 0, nil }
 func (rc *mrc) LoadMeshes([]load.MeshData) ([]uint32, error)              { return []uint32{0}, nil }
 func (rc *mrc) LoadShader(config *load.Shader) (uint16, error)            { return 0, nil }
+func (rc *mrc) LoadTarget(w, h uint32) (uint32, uint32, error)            { return 1, 0, nil }
+func (rc *mrc) DropTexture(tid uint32)                                    {}
+func (rc *mrc) DropMesh(mid uint32)                                       {}
+func (rc *mrc) DropShader(sid uint16)                                     {}
+func (rc *mrc) DropInstanceData(iid uint32)                               {}
+func (rc *mrc) DropTarget(target uint32)                                  {}
 
 // go test -run Bucket
 func TestBucket(t *testing.T) {
 
 	// Check the bits placement
 	t.Run("set bucket", func(t *testing.T) {
-		b := setBucketShader(setBucketDistance(newBucket(render.Pass2D), 2.4), 21)
+		b := setBucketShader(newBucket(render.Pass2D), 21)
 		b = setBucketLayer(b, 7)
 
 		// pull out the values to check placement.
-		dist := math.Float32frombits(uint32(b & 0x00000000FFFFFFFF))
-		draw := uint16((b & 0x000F000000000000) >> 48) // drawOpaque == 4
+		draw := uint16((b & 0x000F000000000000) >> 48) // drawOpaque == 1
 		shid := uint16((b & 0x0000FFFF00000000) >> 40)
 		pass := uint8((b & 0xFF00000000000000) >> 56)
 		layer := uint16((b & 0x00F0000000000000) >> 52)
-		if pass != 254 || layer != 7 || draw != 1 || shid != 21 || dist != 2.4 {
-			t.Errorf("bad bucket %016x %d %d %d %d %f\n", b, pass, draw, layer, shid, dist)
+		if pass != 254 || layer != 7 || draw != 1 || shid != 21 {
+			t.Errorf("bad bucket %016x %d %d %d %d\n", b, pass, draw, layer, shid)
+		}
+		if d := setBucketDepth(b, 2.4); d&0x0000FFFF00000000 != 0 || d&0xFFFF000000000000 != b&0xFFFF000000000000 {
+			t.Errorf("expected depth to replace shader %016x", d)
+		}
+	})
+
+	// check render queues and back to front transparency.
+	t.Run("queue sort", func(t *testing.T) {
+		b := newBucket(render.Pass3D)
+		packets := render.Packets{
+			render.Packet{Tag: 5, Bucket: setBucketDepth(setBucketType(b, drawOverlay), 1)},
+			render.Packet{Tag: 3, Bucket: setBucketDepth(setBucketType(b, drawTransparent), 5)},
+			render.Packet{Tag: 4, Bucket: setBucketDepth(setBucketType(b, drawTransparent), -1)},
+			render.Packet{Tag: 1, Bucket: setBucketShader(setBucketType(b, drawOpaque), 3)},
+			render.Packet{Tag: 2, Bucket: setBucketDepth(setBucketType(b, drawTransparent), 50)},
+			render.Packet{Tag: 0, Bucket: setBucketType(b, drawBackground)},
+			render.Packet{Tag: 6, Bucket: setBucketLayer(setBucketType(b, drawBackground), 1)},
+		}
+		sort.SliceStable(packets, func(i, j int) bool {
+			return packets[i].Bucket < packets[j].Bucket
+		})
+		for i, p := range packets {
+			if p.Tag != uint32(i) {
+				t.Fatalf("expected packet %d got %d", i, p.Tag)
+			}
+		}
+	})
+
+	// check transparent parts are drawn back to front by view depth.
+	t.Run("frame depth sort", func(t *testing.T) {
+		app := newApplication()
+		app.ld.loadDefaultAssets(&mrc{}) // direct loads (no goroutine)
+		scene := app.addScene(Scene3D)
+		near := scene.AddModel("shd:icon", "msh:cube", "tex:color:test").SetAt(0, 0, -5)
+		far := scene.AddModel("shd:icon", "msh:cube", "tex:color:test").SetAt(0, 0, -20)
+		near.SetRenderQueue(QueueTransparent)
+		far.SetRenderQueue(QueueTransparent)
+		solid := scene.AddModel("shd:icon", "msh:cube", "tex:color:test").SetAt(0, 0, -30)
+		app.scenes.setViewMatrixes(0, 800, 600)
+		app.povs.setWorldMatrix(app.work, 0)
+		passes := app.scenes.getFrame(app, 0, app.frame)
+		got := []eID{}
+		for _, p := range passes[render.Pass3D].Packets {
+			got = append(got, eID(p.Tag))
+		}
+		if len(got) != 3 || got[0] != solid.eid || got[1] != far.eid || got[2] != near.eid {
+			t.Errorf("expected opaque then far to near got %v", got)
 		}
 	})
 
@@ -0,0 +1,426 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// scenedata.go saves and loads scene graphs using a declarative
+// YAML format so that levels can be authored and edited as data.
+// The format is JSON compatible. Scene data describes the entities
+// created through the engine API: parts, transforms, models, labels,
+// lights, cameras, and physics bodies. Application state, such as
+// ticks, coroutines, and sounds, is not saved.
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/physics"
+)
+
+// SaveScene writes the scene camera and the scene graph parts to w.
+// For example, a 3D scene with a light and a tagged physics box:
+//
+//	kind: scene
+//	format: 1
+//	engine: (devel)
+//	scene: 3D
+//	camera: {at: [0, 2, 10], pitch: 10, fov: 60}
+//	parts:
+//	  - light: {type: directional, color: [1, 1, 1], intensity: 5}
+//	    at: [-10, 10, 10]
+//	  - model: [msh:cube, shd:pbr0]
+//	    color: [0.5, 0.5, 0.5, 1]
+//	    body: {shape: box, size: [0.5, 0.5, 0.5]}
+//	    tags: [crate]
+//
+// Parts created by the engine, like tilemap chunks and debug
+// drawing, are not saved.
+//
+// Depends on Eng.AddScene.
+func (e *Entity) SaveScene(w io.Writer) error {
+	sc := e.app.scenes.get(e.eid)
+	if sc == nil {
+		return fmt.Errorf("SaveScene needs AddScene %d", e.eid)
+	}
+	sd := sceneData{Scene: "3D", Camera: saveCamera(sc.cam)}
+	if SceneType(sc.pid) == Scene2D {
+		sd.Scene = "2D"
+	}
+	sd.Parts = e.app.saveParts(e.eid, nil)
+	if err := SaveData(w, "scene", SceneFormat, &sd); err != nil {
+		return fmt.Errorf("SaveScene: %w", err)
+	}
+	return nil
+}
+
+// LoadScene creates a new scene from YAML or JSON scene data,
+// see Entity.SaveScene for the format. Older scene formats are
+// upgraded using the "scene" migrations, see RegisterMigration.
+// Model assets are requested as if they were created using the
+// engine API. The scene is returned even if some parts could not
+// be created, along with an error describing the first problem.
+func (eng *Engine) LoadScene(r io.Reader) (scene *Entity, err error) {
+	sd := sceneData{}
+	if err := LoadData(r, "scene", SceneFormat, &sd); err != nil {
+		return nil, fmt.Errorf("LoadScene: %w", err)
+	}
+	var st SceneType
+	switch sd.Scene {
+	case "3D", "":
+		st = Scene3D
+	case "2D":
+		st = Scene2D
+	default:
+		return nil, fmt.Errorf("LoadScene: unknown scene type %q", sd.Scene)
+	}
+	scene = eng.app.addScene(st)
+	loadCamera(scene.Cam(), &sd.Camera)
+	for i := range sd.Parts {
+		if perr := eng.app.loadPart(scene, scene, &sd.Parts[i]); perr != nil && err == nil {
+			err = fmt.Errorf("LoadScene: %w", perr)
+		}
+	}
+	return scene, err
+}
+
+// =============================================================================
+// scene data format.
+
+// sceneData is the top level saved scene.
+type sceneData struct {
+	Scene  string     `yaml:"scene"` // "2D" or "3D".
+	Camera cameraData `yaml:"camera"`
+	Parts  []partData `yaml:"parts,omitempty"`
+}
+
+// cameraData is the saved scene camera.
+type cameraData struct {
+	At    []float64 `yaml:"at,flow,omitempty"`
+	Look  []float64 `yaml:"look,flow,omitempty"` // quaternion x, y, z, w.
+	Pitch float64   `yaml:"pitch,omitempty"`
+	Yaw   float64   `yaml:"yaw,omitempty"`
+	Fov   float64   `yaml:"fov,omitempty"`
+	Near  float64   `yaml:"near,omitempty"`
+	Far   float64   `yaml:"far,omitempty"`
+
+	// pixel perfect cameras.
+	Pixels int   `yaml:"pixels,omitempty"`
+	Fit    []int `yaml:"fit,flow,omitempty"` // width, height.
+	Snap   bool  `yaml:"snap,omitempty"`
+}
+
+// partData is a saved scene graph part and its child parts.
+type partData struct {
+	At    []float64 `yaml:"at,flow,omitempty"`
+	Rot   []float64 `yaml:"rot,flow,omitempty"`   // quaternion x, y, z, w.
+	Scale []float64 `yaml:"scale,flow,omitempty"` // default 1, 1, 1.
+	Tags  []string  `yaml:"tags,flow,omitempty"`
+	Cull  bool      `yaml:"cull,omitempty"`
+
+	// optional components.
+	Model     []string   `yaml:"model,flow,omitempty"` // model assets.
+	Instanced bool       `yaml:"instanced,omitempty"`
+	Label     *labelData `yaml:"label,omitempty"` // uses the model assets.
+	Color     []float64  `yaml:"color,flow,omitempty"`
+	Metallic  bool       `yaml:"metallic,omitempty"`
+	Roughness float64    `yaml:"roughness,omitempty"`
+	Layer     uint8      `yaml:"layer,omitempty"`
+	Light     *lightData `yaml:"light,omitempty"`
+	Body      *bodyData  `yaml:"body,omitempty"`
+
+	Parts []partData `yaml:"parts,omitempty"`
+}
+
+// labelData is a saved label string.
+type labelData struct {
+	Text string `yaml:"text"`
+	Wrap int    `yaml:"wrap,omitempty"`
+}
+
+// lightData is a saved scene light.
+type lightData struct {
+	Type      string    `yaml:"type"` // "directional", "point", "spot", or "area".
+	Color     []float64 `yaml:"color,flow,omitempty"`
+	Intensity float64   `yaml:"intensity,omitempty"`
+	Range     float64   `yaml:"range,omitempty"`
+	Falloff   string    `yaml:"falloff,omitempty"`   // "inverse-square", "linear", or "none".
+	Cone      []float64 `yaml:"cone,flow,omitempty"` // spot inner and outer angles.
+	Size      []float64 `yaml:"size,flow,omitempty"` // area width and height.
+}
+
+// bodyData is a saved physics body.
+type bodyData struct {
+	Shape     string    `yaml:"shape"`     // "box", "sphere", or "capsule".
+	Size      []float64 `yaml:"size,flow"` // box half-extents, sphere radius, or capsule radius and half-height.
+	Static    bool      `yaml:"static,omitempty"`
+	Planar    bool      `yaml:"planar,omitempty"`    // 2D physics.
+	Character bool      `yaml:"character,omitempty"` // capsule character controller.
+	Trigger   bool      `yaml:"trigger,omitempty"`   // reports overlaps without colliding.
+}
+
+// lightTypes map saved light names to light types.
+var lightTypes = map[string]int{
+	"directional": DirectionalLight,
+	"point":       PointLight,
+	"spot":        SpotLight,
+	"area":        AreaLight,
+}
+
+// lightFalloffs map saved attenuation names to light falloff profiles.
+var lightFalloffs = map[string]int{
+	"inverse-square": InverseSquareFalloff,
+	"linear":         LinearFalloff,
+	"none":           NoFalloff,
+}
+
+// =============================================================================
+// saving scenes.
+
+// saveCamera returns the saved camera data.
+func saveCamera(c *Camera) cameraData {
+	cd := cameraData{Pitch: c.pitch, Yaw: c.yaw, Fov: c.fov, Near: c.near, Far: c.far}
+	cd.At = []float64{c.at.Loc.X, c.at.Loc.Y, c.at.Loc.Z}
+	cd.Look = []float64{c.at.Rot.X, c.at.Rot.Y, c.at.Rot.Z, c.at.Rot.W}
+	cd.Pixels, cd.Snap = c.pixels, c.snap
+	if c.fitW > 0 {
+		cd.Fit = []int{c.fitW, c.fitH}
+	}
+	return cd
+}
+
+// saveParts appends the saved child parts of the given entity.
+func (app *application) saveParts(eid eID, parts []partData) []partData {
+	n := app.povs.getNode(eid)
+	if n == nil {
+		return parts
+	}
+	for _, kid := range n.kids {
+		if app.isInternal(kid) {
+			continue
+		}
+		parts = append(parts, app.savePart(kid))
+	}
+	return parts
+}
+
+// savePart returns the saved data for one entity and its children.
+func (app *application) savePart(eid eID) partData {
+	pd := partData{}
+	if p := app.povs.get(eid); p != nil {
+		pd.At = []float64{p.tn.Loc.X, p.tn.Loc.Y, p.tn.Loc.Z}
+		if !p.tn.Rot.Eq(lin.QI) {
+			pd.Rot = []float64{p.tn.Rot.X, p.tn.Rot.Y, p.tn.Rot.Z, p.tn.Rot.W}
+		}
+		if p.sn.X != 1 || p.sn.Y != 1 || p.sn.Z != 1 {
+			pd.Scale = []float64{p.sn.X, p.sn.Y, p.sn.Z}
+		}
+	}
+	if n := app.povs.getNode(eid); n != nil {
+		pd.Cull = n.cull
+	}
+	pd.Tags = append(pd.Tags, app.tags.ents[eid]...)
+	if m := app.models.get(eid); m != nil {
+		if m.label != nil {
+			pd.Model = m.label.assets
+			pd.Label = &labelData{Text: m.label.str, Wrap: m.label.wrap}
+		} else if m.req != "" {
+			pd.Model = strings.Split(m.req, ",")
+		}
+		pd.Instanced = m.isInstanced
+		if m.mat != nil && m.mat.name == fmt.Sprintf("mat%d", eid) {
+			c := m.mat.color // set by the application.
+			pd.Color = []float64{float64(c.r), float64(c.g), float64(c.b), float64(c.a)}
+			pd.Metallic, pd.Roughness = m.mat.metallic > 0, float64(m.mat.roughness)
+		}
+		pd.Layer = m.layer
+	}
+	if l := app.lights.get(eid); l != nil {
+		pd.Light = &lightData{Intensity: float64(l.intensity), Range: float64(l.reach)}
+		for name, kind := range lightTypes {
+			if kind == l.kind {
+				pd.Light.Type = name
+			}
+		}
+		for name, profile := range lightFalloffs {
+			if profile == l.falloff && profile != InverseSquareFalloff {
+				pd.Light.Falloff = name
+			}
+		}
+		pd.Light.Color = []float64{float64(l.r), float64(l.g), float64(l.b)}
+		switch l.kind {
+		case SpotLight:
+			pd.Light.Cone = []float64{l.inner, l.outer}
+		case AreaLight:
+			pd.Light.Size = []float64{l.width, l.height}
+		}
+	}
+	if b := app.sim.get(eid); b != nil {
+		body := (*physics.Body)(b)
+		if r, ok := body.Sphere(); ok {
+			pd.Body = &bodyData{Shape: "sphere", Size: []float64{r}, Static: body.Static()}
+		} else if hx, hy, hz, ok := body.Box(); ok {
+			pd.Body = &bodyData{Shape: "box", Size: []float64{hx, hy, hz}, Static: body.Static()}
+		} else if r, hy, ok := body.Capsule(); ok {
+			pd.Body = &bodyData{Shape: "capsule", Size: []float64{r, hy}, Static: body.Static()}
+			pd.Body.Character = app.sim.chars[eid] != nil
+		}
+		if pd.Body != nil {
+			pd.Body.Planar = body.Planar()
+			pd.Body.Trigger = body.Trigger()
+		}
+	}
+	pd.Parts = app.saveParts(eid, nil)
+	return pd
+}
+
+// isInternal returns true for parts that are created by the engine
+// as part of another component and are recreated with the component.
+func (app *application) isInternal(eid eID) bool {
+	if app.tiles.get(eid) != nil {
+		return true // tilemaps are not saved.
+	}
+	if d := app.debug; d != nil && ((d.lines != nil && d.lines.eid == eid) || (d.text != nil && d.text.eid == eid)) {
+		return true
+	}
+	if n := app.povs.getNode(eid); n != nil {
+		if m := app.models.get(n.parent); m != nil && m.label != nil {
+			for _, page := range m.label.pages {
+				if page.eid == eid {
+					return true // label font page.
+				}
+			}
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// loading scenes.
+
+// loadCamera applies the saved camera data.
+func loadCamera(c *Camera, cd *cameraData) {
+	if len(cd.At) == 3 {
+		c.SetAt(cd.At[0], cd.At[1], cd.At[2])
+	}
+	c.SetPitch(cd.Pitch).SetYaw(cd.Yaw)
+	if len(cd.Look) == 4 {
+		c.SetLook(&lin.Q{X: cd.Look[0], Y: cd.Look[1], Z: cd.Look[2], W: cd.Look[3]})
+	}
+	if cd.Fov > 0 {
+		c.SetFov(cd.Fov)
+	}
+	if cd.Near != 0 || cd.Far != 0 {
+		c.SetClip(cd.Near, cd.Far)
+	}
+	switch {
+	case len(cd.Fit) == 2:
+		c.SetPixelFit(cd.Fit[0], cd.Fit[1], cd.Snap)
+	case cd.Pixels > 0:
+		c.SetPixelPerfect(cd.Pixels, cd.Snap)
+	}
+}
+
+// loadPart creates the saved part as a child of the given parent.
+// Child parts are created even if there was a problem with the part.
+func (app *application) loadPart(scene, parent *Entity, pd *partData) (err error) {
+	var e *Entity
+	switch {
+	case pd.Light != nil:
+		kind, ok := lightTypes[pd.Light.Type]
+		if !ok || parent != scene {
+			err = fmt.Errorf("light %q must be a known type set on the scene", pd.Light.Type)
+			e = parent.AddPart()
+			break
+		}
+		e = parent.AddLight(kind)
+		if c := pd.Light.Color; len(c) == 3 {
+			intensity := float32(pd.Light.Intensity)
+			if intensity == 0 {
+				intensity = newLight(kind).intensity
+			}
+			e.SetLight(float32(c[0]), float32(c[1]), float32(c[2]), intensity)
+		}
+		if pd.Light.Range > 0 {
+			e.SetLightRange(pd.Light.Range)
+		}
+		if profile, ok := lightFalloffs[pd.Light.Falloff]; ok {
+			e.SetLightFalloff(profile)
+		}
+		if len(pd.Light.Cone) == 2 {
+			e.SetSpotCone(pd.Light.Cone[0], pd.Light.Cone[1])
+		}
+		if len(pd.Light.Size) == 2 {
+			e.SetAreaSize(pd.Light.Size[0], pd.Light.Size[1])
+		}
+	case pd.Label != nil:
+		e = parent.AddLabel(pd.Label.Text, pd.Label.Wrap, pd.Model...)
+	case pd.Instanced:
+		e = parent.AddInstancedModel(pd.Model...)
+	case len(pd.Model) > 0:
+		e = parent.AddModel(pd.Model...)
+	default:
+		e = parent.AddPart()
+	}
+	if len(pd.At) == 3 {
+		e.SetAt(pd.At[0], pd.At[1], pd.At[2])
+	}
+	if len(pd.Rot) == 4 {
+		e.SetView(lin.NewQ().SetS(pd.Rot[0], pd.Rot[1], pd.Rot[2], pd.Rot[3]))
+	}
+	if len(pd.Scale) == 3 {
+		e.SetScale(pd.Scale[0], pd.Scale[1], pd.Scale[2])
+	}
+	if len(pd.Tags) > 0 {
+		e.Tag(pd.Tags...)
+	}
+	if app.models.get(e.eid) != nil {
+		if c := pd.Color; len(c) == 4 {
+			e.SetColor(c[0], c[1], c[2], c[3])
+			e.SetMetallicRoughness(pd.Metallic, pd.Roughness)
+		}
+		if pd.Layer > 0 {
+			e.SetLayer(pd.Layer)
+		}
+	}
+	if pd.Body != nil {
+		if berr := app.loadBody(e, pd.Body); berr != nil && err == nil {
+			err = berr
+		}
+	}
+	for i := range pd.Parts {
+		if perr := app.loadPart(scene, e, &pd.Parts[i]); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	e.Cull(pd.Cull)
+	return err
+}
+
+// loadBody adds the saved physics body to the given entity.
+func (app *application) loadBody(e *Entity, bd *bodyData) error {
+	var body Body
+	switch {
+	case bd.Shape == "sphere" && len(bd.Size) == 1:
+		body = Sphere(bd.Size[0], bd.Static)
+	case bd.Shape == "box" && len(bd.Size) == 3:
+		body = Box(bd.Size[0], bd.Size[1], bd.Size[2], bd.Static)
+	case bd.Shape == "capsule" && len(bd.Size) == 2 && bd.Character:
+		e.AddCharacter(bd.Size[0], bd.Size[1])
+		return nil
+	case bd.Shape == "capsule" && len(bd.Size) == 2:
+		body = Capsule(bd.Size[0], bd.Size[1], bd.Static)
+	default:
+		slog.Error("LoadScene invalid body", "eid", e.eid, "shape", bd.Shape)
+		return fmt.Errorf("invalid %q body with %d sizes", bd.Shape, len(bd.Size))
+	}
+	if bd.Planar {
+		Planar(body)
+	}
+	if bd.Trigger {
+		Trigger(body)
+	}
+	e.AddToSimulation(body)
+	return nil
+}
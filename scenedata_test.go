@@ -0,0 +1,75 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gazed/vu/physics"
+)
+
+// go test -run SceneData
+func TestSceneData(t *testing.T) {
+	eng := &Engine{app: newApplication()}
+	defer eng.app.ld.dispose()
+	scene := eng.AddScene(Scene3D)
+	scene.Cam().SetAt(0, 2, 10).SetPitch(10).SetFov(60)
+	scene.AddLight(PointLight).SetAt(-10, 10, 10).SetLight(1, 0.5, 0.5, 3)
+	scene.AddLight(SpotLight).SetAt(0, 4, 0).SetSpotCone(20, 30).SetLightRange(12).SetLightFalloff(LinearFalloff)
+	crate := scene.AddModel("msh:cube", "shd:pbr0").SetColor(0.5, 0.5, 0.5, 1).SetScale(2, 2, 2)
+	crate.Tag("crate").AddToSimulation(Box(1, 2, 3, StaticSim))
+	crate.AddPart().SetAt(0, 1, 0).AddLabel("hello", 100, "shd:label", "fnt:lucon18", "tex:color:lucon18")
+	scene.AddPart().SetAa(0, 1, 0, 1).AddToSimulation(Circle(0.5, KinematicSim)).Cull(true)
+	scene.AddTilemap(16, 4, 4, "shd:icon", "tex:color:tiles") // not saved.
+
+	saved := &bytes.Buffer{}
+	if err := scene.SaveScene(saved); err != nil {
+		t.Fatalf("save failed %s", err)
+	}
+	t.Run("round trip", func(t *testing.T) {
+		loaded, err := eng.LoadScene(bytes.NewReader(saved.Bytes()))
+		if err != nil {
+			t.Fatalf("load failed %s", err)
+		}
+		resaved := &bytes.Buffer{}
+		if err := loaded.SaveScene(resaved); err != nil || resaved.String() != saved.String() {
+			t.Fatalf("expected same scene data got\n%s\nexpected\n%s", resaved, saved)
+		}
+		found := eng.Tagged("crate", nil)
+		if len(found) != 2 {
+			t.Fatalf("expected the saved and loaded crates got %d", len(found))
+		}
+		if hx, hy, hz, ok := (*physics.Body)(found[1].Body()).Box(); !ok || hx != 1 || hy != 2 || hz != 3 {
+			t.Errorf("expected loaded box body %f %f %f", hx, hy, hz)
+		}
+		if strings.Contains(saved.String(), "tex:color:tiles") {
+			t.Errorf("expected tilemap to be skipped")
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		data := `{"scene": "2D", "camera": {"pixels": 2}, "parts": [{"model": ["shd:icon", "tex:color:core"], "at": [10, 20, 0]}]}`
+		loaded, err := eng.LoadScene(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("load failed %s", err)
+		}
+		if loaded.Cam().pixels != 2 || len(eng.app.povs.getNode(loaded.eid).kids) != 1 {
+			t.Errorf("expected 2D scene with one model")
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := eng.LoadScene(strings.NewReader("scene: 4D")); err == nil {
+			t.Errorf("expected scene type error")
+		}
+		data := "parts:\n  - body: {shape: cone, size: [1]}\n    parts: [{at: [1, 2, 3]}]"
+		loaded, err := eng.LoadScene(strings.NewReader(data))
+		if err == nil || loaded == nil {
+			t.Fatalf("expected partial scene and body error")
+		}
+		part := eng.app.povs.getNode(loaded.eid).kids[0]
+		if len(eng.app.povs.getNode(part).kids) != 1 {
+			t.Errorf("expected child parts to load")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// screenshot.go reads rendered frames back from the GPU, eg:
+//
+//	eng.SaveScreenshot("shot.png")     // the next frame.
+//	eng.StartFrameCapture("frames")    // every frame until stopped.
+//	...
+//	eng.StopFrameCapture()
+//
+// Frames are read back a couple of frames after they are drawn, once
+// the GPU has finished with them, so capturing does not stall rendering.
+// Captured frames are numbered PNG files, eg: "frames/frame000001.png",
+// that can be assembled into a video, eg:
+//
+//	ffmpeg -framerate 60 -i frames/frame%06d.png capture.mp4
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Screenshot reads back the next frame drawn to the main window. The done
+// callback is called on the engine goroutine with the frame image a couple
+// of frames later. Returns an error wrapping errors.ErrUnsupported if the
+// GPU can't read back frames.
+func (eng *Engine) Screenshot(done func(img image.Image)) error {
+	return eng.rc.Capture(func(img *image.NRGBA) { done(img) })
+}
+
+// SaveScreenshot writes the next frame drawn to the main window to the
+// given PNG file. The file is encoded and written on a separate goroutine.
+// Write errors are logged.
+func (eng *Engine) SaveScreenshot(path string) error {
+	return eng.rc.Capture(func(img *image.NRGBA) {
+		go func() {
+			if err := savePNG(path, img, png.DefaultCompression); err != nil {
+				slog.Error("SaveScreenshot", "error", err)
+			}
+		}()
+	})
+}
+
+// StartFrameCapture writes every frame drawn to the main window to
+// numbered PNG files in the given directory until StopFrameCapture.
+// The directory is created if needed. Frames are written on a separate
+// goroutine which slows the engine, rather than dropping frames, if
+// the frames can't be written as fast as they are drawn.
+func (eng *Engine) StartFrameCapture(dir string) error {
+	if eng.frames != nil {
+		return fmt.Errorf("StartFrameCapture: already capturing to %s", eng.frames.dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("StartFrameCapture: %w", err)
+	}
+	eng.frames = newFrameWriter(dir)
+	return nil
+}
+
+// StopFrameCapture stops capturing frames and waits for the
+// captured frames to be written. Returns the number of frames written.
+func (eng *Engine) StopFrameCapture() (frames int) {
+	if eng.frames == nil {
+		return 0
+	}
+	frames = eng.frames.stop()
+	eng.frames = nil
+	return frames
+}
+
+// captureFrame requests the read back of the next frame
+// while capturing frames. Called before each frame is drawn.
+func (eng *Engine) captureFrame() {
+	if eng.frames == nil {
+		return
+	}
+	if err := eng.rc.Capture(eng.frames.write); err != nil {
+		slog.Error("StartFrameCapture", "error", err)
+		eng.StopFrameCapture()
+	}
+}
+
+// savePNG encodes the image to the given file.
+func savePNG(path string, img image.Image, level png.CompressionLevel) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := &png.Encoder{CompressionLevel: level}
+	if err = enc.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// =============================================================================
+
+// frameWriter writes captured frames to numbered files.
+type frameWriter struct {
+	dir     string            // output directory.
+	count   int               // frames captured.
+	frames  chan *image.NRGBA // frames waiting to be written.
+	done    chan struct{}     // closed once all frames are written.
+	stopped bool              // frames read back after stopping are ignored.
+}
+
+// newFrameWriter starts the goroutine that writes frames to dir.
+func newFrameWriter(dir string) *frameWriter {
+	fw := &frameWriter{dir: dir, frames: make(chan *image.NRGBA, 8), done: make(chan struct{})}
+	go fw.run()
+	return fw
+}
+
+// write queues a captured frame. Called on the engine goroutine.
+func (fw *frameWriter) write(img *image.NRGBA) {
+	if !fw.stopped {
+		fw.count++
+		fw.frames <- img // blocks if the writer falls behind.
+	}
+}
+
+// stop waits for the queued frames to be written.
+func (fw *frameWriter) stop() int {
+	if !fw.stopped {
+		fw.stopped = true
+		close(fw.frames)
+		<-fw.done
+	}
+	return fw.count
+}
+
+// run writes the frames in order. Encoding speed is favored
+// over file size since frames are normally assembled into a video.
+func (fw *frameWriter) run() {
+	defer close(fw.done)
+	n := 0
+	for img := range fw.frames {
+		n++
+		path := filepath.Join(fw.dir, fmt.Sprintf("frame%06d.png", n))
+		if err := savePNG(path, img, png.BestSpeed); err != nil {
+			slog.Error("frame capture", "error", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test -run Screenshot
+func TestScreenshot(t *testing.T) {
+	frame := func() *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		return img
+	}
+
+	// go test -run Screenshot/frames
+	t.Run("frames", func(t *testing.T) {
+		dir := t.TempDir()
+		fw := newFrameWriter(dir)
+		for i := 0; i < 12; i++ { // more than the queue holds.
+			fw.write(frame())
+		}
+		if n := fw.stop(); n != 12 {
+			t.Errorf("expected 12 frames got %d", n)
+		}
+		fw.write(frame()) // ignored after stopping.
+		if n := fw.stop(); n != 12 {
+			t.Errorf("expected frames after stop to be ignored got %d", n)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "frame*.png"))
+		if len(files) != 12 || filepath.Base(files[11]) != "frame000012.png" {
+			t.Fatalf("expected numbered frame files got %v", files)
+		}
+		file, err := os.Open(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		img, err := png.Decode(file)
+		if err != nil || img.Bounds().Dx() != 4 || img.Bounds().Dy() != 2 {
+			t.Errorf("expected 4x2 frame got %v", err)
+		}
+	})
+
+	// go test -run Screenshot/errors
+	t.Run("errors", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "shot.png")
+		if err := savePNG(path, frame(), png.DefaultCompression); err == nil {
+			t.Errorf("expected error for missing directory")
+		}
+		eng := &Engine{}
+		if n := eng.StopFrameCapture(); n != 0 {
+			t.Errorf("expected no frames when not capturing")
+		}
+	})
+}
@@ -5,8 +5,13 @@ package vu
 // simulation.go integrates physics into the engine.
 
 import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
 	"log/slog"
+	"slices"
 
+	"github.com/gazed/vu/math/lin"
 	"github.com/gazed/vu/physics"
 )
 
@@ -29,6 +34,80 @@ func Sphere(radius float64, static bool) Body { return physics.NewSphere(radius,
 // is w=2*hx, h=2*hy, d=2*hz.
 func Box(hx, hy, hz float64, static bool) Body { return physics.NewBox(hx, hy, hz, static) }
 
+// Circle creates a disc shaped 2D physics body located at the origin.
+// 2D bodies move in the XY plane and only rotate around the Z axis.
+func Circle(radius float64, static bool) Body { return physics.NewCircle(radius, static) }
+
+// Rect creates a rectangle shaped 2D physics body located at the origin.
+// The rectangle size is given by the half-extents so that actual size
+// is w=2*hx, h=2*hy.
+func Rect(hx, hy float64, static bool) Body { return physics.NewRect(hx, hy, static) }
+
+// Polygon creates a convex polygon shaped 2D physics body located at
+// the origin. The polygon is given as counter-clockwise x,y point pairs
+// centered on the origin. Returns nil for less than 3 points.
+func Polygon(xy []float64, static bool) Body { return physics.NewPolygon(xy, static) }
+
+// ConvexHull creates a physics body from the smallest convex shape
+// enclosing the points. The points are centered on the origin.
+// Returns nil if the points are flat.
+func ConvexHull(points []lin.V3, static bool) Body { return physics.NewConvexHull(points, static) }
+
+// Mesh creates a static physics body from triangles given as
+// counter-clockwise indexes into the vertexes, eg: level geometry.
+// The vertexes are centered on the origin. Returns nil if there
+// are no triangles.
+func Mesh(vertexes []lin.V3, indexes []uint32) Body { return physics.NewMesh(vertexes, indexes) }
+
+// Heightfield creates a static physics body for terrain from a grid of
+// cols by rows heights given row by row, with grid points spacing apart
+// along the X and Z axis starting at the origin. Returns nil if the grid
+// does not match the heights.
+func Heightfield(heights []float64, cols, rows int, spacing float64) Body {
+	return physics.NewHeightfield(heights, cols, rows, spacing)
+}
+
+// Capsule creates a pill shaped physics body located at the origin.
+// The capsule is a cylinder with half-height hy along the Y axis and
+// radius r, capped by half spheres, so the total height is 2*(hy+r).
+func Capsule(r, hy float64, static bool) Body { return physics.NewCapsule(r, hy, static) }
+
+// Cylinder creates a cylinder shaped physics body located at the origin.
+// The cylinder has radius r and half-height hy along the Y axis.
+func Cylinder(r, hy float64, static bool) Body { return physics.NewCylinder(r, hy, static) }
+
+// Planar constrains the given body to 2D physics and returns it.
+// eg: vu.Planar(vu.Box(1, 1, 1, vu.KinematicSim))
+func Planar(b Body) Body {
+	if b != nil {
+		(*physics.Body)(b).SetPlanar(true)
+	}
+	return b
+}
+
+// Trigger makes the given body a trigger volume and returns it.
+// Triggers report the bodies that overlap them, see Entity.OnContact,
+// instead of colliding with them.
+// eg: vu.Trigger(vu.Box(1, 1, 1, vu.StaticSim))
+func Trigger(b Body) Body {
+	if b != nil {
+		(*physics.Body)(b).SetTrigger(true)
+	}
+	return b
+}
+
+// Layer sets the collision layer bits of the given body and the mask
+// of layers that it collides with, and returns it. Bodies only collide
+// when each body's layer is in the other body's mask. Bodies are on
+// layer 1 and collide with all layers by default.
+// eg: vu.Layer(vu.Sphere(1, vu.KinematicSim), 2, 2)
+func Layer(b Body, layer, mask uint32) Body {
+	if b != nil {
+		(*physics.Body)(b).SetLayer(layer, mask)
+	}
+	return b
+}
+
 // AddToSimulation
 // Bodies are generally set on top level pov transforms which always
 // have valid world coordindates.
@@ -39,6 +118,10 @@ func (e *Entity) AddToSimulation(b Body) *Entity {
 		slog.Error("AddToSimulation requires existing pov")
 		return e
 	}
+	if b == nil {
+		slog.Error("AddToSimulation needs a body", "eid", e.eid)
+		return e
+	}
 	e.app.sim.create(e.eid, b)
 	return e
 }
@@ -55,14 +138,256 @@ func (e *Entity) Push(x, y, z float64) {
 	slog.Error("Push needs AddToSimulation", "entity_id", e.eid)
 }
 
+// AddCharacter adds a capsule shaped character controller to the
+// simulation, see vu.Capsule. Characters are moved by the application
+// using MoveCharacter and are not moved by the simulation.
+//
+//	r : capsule radius.
+//	hy: capsule half-height, not including the half spheres.
+func (e *Entity) AddCharacter(r, hy float64) *Entity {
+	c, body := physics.NewCharacter(r, hy)
+	if e.AddToSimulation(body); e.app.sim.get(e.eid) != nil {
+		e.app.sim.chars[e.eid] = c
+	}
+	return e
+}
+
+// MoveCharacter moves the character by the given amount, sliding along
+// walls, stepping over small ledges, and staying on the ground when
+// walking downhill. The y motion is expected to include gravity.
+// Returns true if the character is standing on walkable ground.
+//
+// Depends on AddCharacter.
+func (e *Entity) MoveCharacter(x, y, z float64) (grounded bool) {
+	c, ok := e.app.sim.chars[e.eid]
+	p := e.app.povs.get(e.eid)
+	if !ok || p == nil {
+		slog.Error("MoveCharacter needs AddCharacter", "entity_id", e.eid)
+		return false
+	}
+	e.app.sim.place(e.app.povs) // collide with where the app put the bodies.
+	body := (*physics.Body)(e.app.sim.get(e.eid))
+	grounded = c.Move(body, e.app.sim.bodies, lin.V3{X: x, Y: y, Z: z})
+	p.tn.Loc.Set(body.Position())
+	e.app.povs.updateWorld(p, e.eid)
+	return grounded
+}
+
+// Character returns the character controller for this entity, returning
+// nil if there is no character. The controller step height, slope limit,
+// and ground snap distance can be changed.
+func (e *Entity) Character() *physics.Character { return e.app.sim.chars[e.eid] }
+
+// AddVehicle adds a raycast vehicle that moves this entity's physics
+// body, the chassis, using the given wheels. The app sets the vehicle
+// engine, brake, and steering each update. The chassis forward
+// direction is -Z. Wheels are rays, not bodies, so wheel models are
+// placed using Vehicle.WheelPose, eg:
+//
+//	car := chassis.AddVehicle(wheels...)
+//	car.Engine = throttle * 20
+//	at, rot := car.WheelPose(chassis.Body(), 0)
+//
+// Returns nil if the entity does not have a kinematic physics body.
+//
+// Depends on AddToSimulation.
+func (e *Entity) AddVehicle(wheels ...physics.Wheel) *physics.Vehicle {
+	body := e.app.sim.get(e.eid)
+	if body == nil || (*physics.Body)(body).Static() {
+		slog.Error("AddVehicle needs a kinematic AddToSimulation body", "entity_id", e.eid)
+		return nil
+	}
+	v := physics.NewVehicle(body, wheels...)
+	e.app.sim.vehicles[e.eid] = v
+	return v
+}
+
+// Vehicle returns the vehicle for this entity, returning nil
+// if there is no vehicle.
+func (e *Entity) Vehicle() *physics.Vehicle { return e.app.sim.vehicles[e.eid] }
+
+// Joint types for AddJoint.
+const (
+	FixedJoint   = physics.FixedJoint   // no movement between the bodies.
+	HingeJoint   = physics.HingeJoint   // turns around the axis, eg: a door.
+	BallJoint    = physics.BallJoint    // turns and swings freely, eg: a shoulder.
+	SliderJoint  = physics.SliderJoint  // moves along the axis, eg: a piston.
+	GenericJoint = physics.GenericJoint // 6-DOF: starts locked, app frees each axis.
+)
+
+// AddJoint connects the physics bodies of this entity and the other
+// entity. The joint is at the world position pivot and the joint axis
+// points along the world direction axis. The returned joint can be
+// used to set limits and motors. Returns nil if either entity does
+// not have a physics body.
+//
+// Depends on AddToSimulation for both entities.
+func (e *Entity) AddJoint(to *Entity, jtype physics.JointType, pivot, axis lin.V3) *physics.Joint {
+	sim := e.app.sim
+	b1, ok1 := sim.bids[e.eid]
+	b2, ok2 := sim.bids[to.eid]
+	if !ok1 || !ok2 {
+		slog.Error("AddJoint needs AddToSimulation", "entity_id", e.eid, "to", to.eid)
+		return nil
+	}
+	sim.place(e.app.povs) // joint position depends on the body positions.
+	j := physics.NewJoint(jtype, sim.bodies, int(b1), int(b2), pivot, axis)
+	if j != nil {
+		sim.joints = append(sim.joints, simJoint{e1: e.eid, e2: to.eid, joint: j})
+	}
+	return j
+}
+
+// DisposeJoints removes all the joints connected to this entity.
+func (e *Entity) DisposeJoints() { e.app.sim.disposeJoints(e.eid) }
+
 // Body returns the physics body for this entity, returning nil
 // if no physics body exists.
 func (e *Entity) Body() Body { return e.app.sim.get(e.eid) }
 
+// SetInterpolation renders physics bodies between their last two
+// simulation steps so that motion is smooth when the frame rate does
+// not match the fixed simulation rate. Rendered bodies lag the
+// simulation by up to one step. Interpolation is on by default.
+func (eng *Engine) SetInterpolation(on bool) { eng.app.sim.smooth = on }
+
 // DisposeBody removes the physics body from the given entity.
 // Does nothing if there was no physics body.
 func (e *Entity) DisposeBody() { e.app.sim.dispose(e.eid) }
 
+// Contact describes an entity physics body touching another
+// entity physics body, see OnContact.
+type Contact struct {
+	Other   *Entity // the other entity.
+	Point   lin.V3  // world location of the contact.
+	Normal  lin.V3  // contact normal pointing towards the other entity.
+	Trigger bool    // true if either body is a trigger volume.
+}
+
+// OnContact sets the functions that are called after a simulation step
+// when the entity physics body starts and stops touching another body.
+// Stopped contacts have the last location and normal of the contact.
+// Either function may be nil. The functions are removed when the
+// body is disposed. See Engine.ContactEvents for all contacts.
+//
+// Depends on AddToSimulation.
+func (e *Entity) OnContact(begin, end func(c Contact)) *Entity {
+	if e.app.sim.get(e.eid) == nil {
+		slog.Error("OnContact needs AddToSimulation", "eid", e.eid)
+		return e
+	}
+	if begin == nil && end == nil {
+		delete(e.app.sim.handlers, e.eid)
+		return e
+	}
+	e.app.sim.handlers[e.eid] = contactHandler{begin: begin, end: end}
+	return e
+}
+
+// PhysicsState is a saved copy of the physics simulation,
+// see SavePhysics.
+type PhysicsState struct {
+	Step uint64 // simulation step when the state was saved.
+	eids []eID  // saved bodies in simulation order.
+	snap physics.Snapshot
+}
+
+// SavePhysics copies the state of the physics bodies, joints, and
+// characters into state, reusing its memory, and returns the state.
+// A nil state creates a new state. Used with RestorePhysics and
+// StepPhysics to replay or roll back simulation steps.
+func (eng *Engine) SavePhysics(state *PhysicsState) *PhysicsState {
+	return eng.app.sim.save(state)
+}
+
+// RestorePhysics sets the physics bodies, joints, and characters, and
+// the locations of their entities, to the saved state. The saved bodies,
+// joints, and characters must still exist and no others may be added.
+// Returns an error and changes nothing if they don't match.
+func (eng *Engine) RestorePhysics(state *PhysicsState) error {
+	return eng.app.sim.restore(eng.app.povs, eng.app.work, state)
+}
+
+// StepPhysics runs one physics simulation step and calls the contact
+// functions, eg: to simulate the steps since a restored state.
+// The engine runs a step each fixed timestep update.
+func (eng *Engine) StepPhysics() {
+	eng.app.sim.simulate(eng.app.povs, eng.app.work, timestepSecs)
+	eng.app.sim.contact(eng.app)
+}
+
+// PhysicsStep returns the number of physics simulation steps run.
+func (eng *Engine) PhysicsStep() uint64 { return eng.app.sim.steps }
+
+// SetDeterministic runs a physics step for every fixed timestep that
+// has elapsed, eg: for replays or networked games that must simulate
+// the same steps. Normally the engine drops steps when frames are slow.
+// Deterministic mode spreads the steps over the following frames instead.
+// Deterministic mode is off by default.
+func (eng *Engine) SetDeterministic(on bool) { eng.deterministic = on }
+
+// MarshalBinary encodes the physics state, eg: to save a replay
+// or to send the state over a network.
+func (state *PhysicsState) MarshalBinary() ([]byte, error) {
+	snap, err := state.snap.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := binary.LittleEndian.AppendUint64(nil, state.Step)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(state.eids)))
+	for _, eid := range state.eids {
+		data = binary.LittleEndian.AppendUint32(data, uint32(eid))
+	}
+	return append(data, snap...), nil
+}
+
+// UnmarshalBinary decodes a physics state encoded by MarshalBinary.
+func (state *PhysicsState) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("physics state decode: short data")
+	}
+	step, count := binary.LittleEndian.Uint64(data), int(binary.LittleEndian.Uint32(data[8:]))
+	if data = data[12:]; len(data) < count*4 {
+		return fmt.Errorf("physics state decode: expected %d bodies", count)
+	}
+	eids := make([]eID, count)
+	for i := range eids {
+		eids[i] = eID(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	snap := physics.Snapshot{}
+	if err := snap.UnmarshalBinary(data[count*4:]); err != nil {
+		return err
+	}
+	state.Step, state.eids, state.snap = step, eids, snap
+	return nil
+}
+
+// Raycast returns the closest entity with a physics body that is hit
+// by the ray from origin in direction dir, up to maxDistance away.
+// The hit location and surface normal are in world space.
+// Returns false if no body was hit.
+func (eng *Engine) Raycast(origin, dir lin.V3, maxDistance float64) (e *Entity, hit physics.RayHit, ok bool) {
+	app := eng.app
+	if hit, ok = app.sim.raycast(app.povs, origin, dir, maxDistance); !ok {
+		return nil, hit, false
+	}
+	return &Entity{app: app, eid: app.sim.eids[hit.Body]}, hit, true
+}
+
+// QueryBox returns the entities with physics bodies whose bounds
+// overlap the world space box from lo to hi.
+func (eng *Engine) QueryBox(lo, hi lin.V3) []*Entity {
+	app := eng.app
+	return app.sim.entities(app, app.sim.queryBox(app.povs, lo, hi))
+}
+
+// QuerySphere returns the entities with physics bodies that overlap
+// the world space sphere.
+func (eng *Engine) QuerySphere(center lin.V3, radius float64) []*Entity {
+	app := eng.app
+	return app.sim.entities(app, app.sim.querySphere(app.povs, center, radius))
+}
+
 // =============================================================================
 // simulation is the physics component manager
 
@@ -71,6 +396,51 @@ type simulation struct {
 	bids   map[eID]uint32 // Sparse mapping of eid to bid.
 	bodies []physics.Body // Dense array of physics bodies, indexed by bid.
 	eids   []eID          // Dense array of eids indexed by bid.
+
+	// character controllers for bodies moved by the application.
+	chars map[eID]*physics.Character
+
+	// vehicles moving their bodies with wheel forces.
+	vehicles map[eID]*physics.Vehicle
+
+	// joints between pairs of bodies.
+	joints []simJoint
+
+	// contact functions and the bodies touching after the last step.
+	handlers map[eID]contactHandler
+	touching map[simPair]physics.Contact
+	touched  map[simPair]physics.Contact // reused for the next step.
+	found    []physics.Contact           // reused each step.
+
+	steps uint64 // number of simulation steps.
+
+	// body transforms before and after the last simulation step,
+	// indexed by bid, used to interpolate rendered bodies.
+	prev, next []bodyPose
+	smooth     bool // true to interpolate rendered bodies.
+}
+
+// simJoint is a joint between the bodies of two entities.
+type simJoint struct {
+	e1, e2 eID
+	joint  *physics.Joint
+}
+
+// contactHandler holds the entity contact functions.
+type contactHandler struct {
+	begin, end func(c Contact)
+}
+
+// simPair is a pair of touching entities where e1 < e2.
+// The contact normal points from e1 to e2.
+type simPair struct {
+	e1, e2 eID
+}
+
+// bodyPose is a physics body local transform.
+type bodyPose struct {
+	loc lin.V3
+	rot lin.Q
 }
 
 // newSimulation creates a manager for a group of physics data. Expectation
@@ -80,6 +450,12 @@ func newSimulation() *simulation {
 	sim.bodies = []physics.Body{} // Dense array of physics bodies...
 	sim.eids = []eID{}            // ...and associated entity identifiers.
 	sim.bids = map[eID]uint32{}   // map entity ids to body ids.
+	sim.chars = map[eID]*physics.Character{}
+	sim.vehicles = map[eID]*physics.Vehicle{}
+	sim.handlers = map[eID]contactHandler{}
+	sim.touching = map[simPair]physics.Contact{}
+	sim.touched = map[simPair]physics.Contact{}
+	sim.smooth = true
 	return sim
 }
 
@@ -92,7 +468,9 @@ func (sim *simulation) create(id eID, b Body) Body {
 	bid := len(sim.bodies)              // body id is the array index.
 	sim.bodies = append(sim.bodies, *b) // save body - indexed by bid
 	sim.eids = append(sim.eids, id)     //  ""       - indexed by bid
-	sim.bids[id] = uint32(bid)          // map eid to bid.
+	sim.prev = append(sim.prev, bodyPose{})
+	sim.next = append(sim.next, bodyPose{})
+	sim.bids[id] = uint32(bid) // map eid to bid.
 	return b
 }
 
@@ -107,6 +485,10 @@ func (sim *simulation) get(id eID) Body {
 
 // dispose deletes the indicated physics body.
 func (sim *simulation) dispose(eid eID) {
+	delete(sim.chars, eid)
+	delete(sim.vehicles, eid)
+	delete(sim.handlers, eid)
+	sim.disposeJoints(eid)
 	if index, ok := sim.bids[eid]; ok {
 		delete(sim.bids, eid) // delete index from sparse array.
 
@@ -116,8 +498,12 @@ func (sim *simulation) dispose(eid eID) {
 		lastID := sim.eids[lastIndex]             // eid of last index.
 		sim.eids[index] = sim.eids[lastIndex]     // delete by replacing.
 		sim.bodies[index] = sim.bodies[lastIndex] // delete by replacing.
+		sim.prev[index] = sim.prev[lastIndex]     // delete by replacing.
+		sim.next[index] = sim.next[lastIndex]     // delete by replacing.
 		sim.eids = sim.eids[:lastIndex]           // discard moved last element.
 		sim.bodies = sim.bodies[:lastIndex]       // discard moved last element.
+		sim.prev = sim.prev[:lastIndex]           // discard moved last element.
+		sim.next = sim.next[:lastIndex]           // discard moved last element.
 		if eid != lastID {
 			// if the deleted element wasn't the last...
 			sim.bids[lastID] = index // ...update the moved element index.
@@ -125,12 +511,35 @@ func (sim *simulation) dispose(eid eID) {
 	}
 }
 
+// disposeJoints deletes the joints connected to the given entity.
+func (sim *simulation) disposeJoints(eid eID) {
+	sim.joints = slices.DeleteFunc(sim.joints, func(j simJoint) bool { return j.e1 == eid || j.e2 == eid })
+}
+
 // simulate runs physics on all the bodies; adjusting location and orientation.
 // Expected to be called on regular timesteps from the main game loop.
-func (sim *simulation) simulate(ps *povs, timestep float64) {
+func (sim *simulation) simulate(ps *povs, w *workers, timestep float64) {
 
 	// update simulation body transforms with povs that may have
 	// been changed by the app.
+	sim.place(ps)
+	for i := range sim.bodies {
+		sim.prev[i] = bodyPose{loc: *sim.bodies[i].Position(), rot: *sim.bodies[i].Rotation()}
+	}
+
+	// add the vehicle wheel forces in body order.
+	for i, eid := range sim.eids {
+		if v, ok := sim.vehicles[eid]; ok {
+			v.Update(&sim.bodies[i], sim.bodies, timestep)
+		}
+	}
+
+	// run the physics simulation with the joints
+	// using the current body indexes.
+	physics.Simulate(sim.bodies, timestep, sim.physicsJoints()...)
+	sim.steps++
+
+	// apply any physics transform changes to the povs
 	for i := range sim.bodies {
 		bod := &sim.bodies[i]
 		eid := sim.eids[i]
@@ -139,15 +548,93 @@ func (sim *simulation) simulate(ps *povs, timestep float64) {
 			slog.Error("physics body with no pov", "eid", eid)
 			continue
 		}
-		bod.SetPosition(*p.tn.Loc)
-		bod.SetRotation(*p.tn.Rot)
-		bod.SetScale(*p.sw)
+		p.tn.Loc.Set(bod.Position())
+		p.tn.Rot.Set(bod.Rotation())
+		// physics does not change scale.
+		sim.next[i] = bodyPose{loc: *p.tn.Loc, rot: *p.tn.Rot}
 	}
+	ps.updateWorlds(w, sim.eids) // world transforms for the moved bodies.
+}
 
-	// run the physics simulation.
-	physics.Simulate(sim.bodies, timestep)
+// physicsJoints returns the joints with their current body indexes.
+func (sim *simulation) physicsJoints() []*physics.Joint {
+	joints := make([]*physics.Joint, len(sim.joints))
+	for i, sj := range sim.joints {
+		sj.joint.B1, sj.joint.B2 = int(sim.bids[sj.e1]), int(sim.bids[sj.e2])
+		joints[i] = sj.joint
+	}
+	return joints
+}
 
-	// apply any physics transform changes to the povs
+// physicsChars returns the character controllers of the given
+// bodies in body order.
+func (sim *simulation) physicsChars(eids []eID) []*physics.Character {
+	chars := []*physics.Character{}
+	for _, eid := range eids {
+		if c, ok := sim.chars[eid]; ok {
+			chars = append(chars, c)
+		}
+	}
+	return chars
+}
+
+// save copies the simulation state into state.
+func (sim *simulation) save(state *PhysicsState) *PhysicsState {
+	if state == nil {
+		state = &PhysicsState{}
+	}
+	state.Step = sim.steps
+	state.eids = append(state.eids[:0], sim.eids...)
+	state.snap.Save(sim.bodies, sim.physicsJoints(), sim.physicsChars(sim.eids))
+	return state
+}
+
+// restore sets the simulation and the body povs to the saved state.
+func (sim *simulation) restore(ps *povs, w *workers, state *PhysicsState) error {
+	if len(state.eids) != len(sim.eids) {
+		return fmt.Errorf("restore %d bodies: state has %d", len(sim.eids), len(state.eids))
+	}
+	for _, eid := range state.eids {
+		if _, ok := sim.bids[eid]; !ok {
+			return fmt.Errorf("restore: entity %d has no body", eid)
+		}
+	}
+
+	// the body order affects the simulation so put the
+	// bodies back in the order they were saved.
+	n := len(sim.bodies)
+	bodies, prev, next := make([]physics.Body, n), make([]bodyPose, n), make([]bodyPose, n)
+	for i, eid := range state.eids {
+		bid := sim.bids[eid]
+		bodies[i], prev[i], next[i] = sim.bodies[bid], sim.prev[bid], sim.next[bid]
+	}
+	if err := state.snap.Restore(bodies, sim.physicsJoints(), sim.physicsChars(state.eids)); err != nil {
+		return err
+	}
+	sim.bodies, sim.prev, sim.next = bodies, prev, next
+	sim.eids = append(sim.eids[:0], state.eids...)
+	for i, eid := range sim.eids {
+		sim.bids[eid] = uint32(i)
+	}
+	sim.steps = state.Step
+
+	// move the entities to their restored bodies.
+	for i := range sim.bodies {
+		bod, eid := &sim.bodies[i], sim.eids[i]
+		if p := ps.get(eid); p != nil {
+			p.tn.Loc.Set(bod.Position())
+			p.tn.Rot.Set(bod.Rotation())
+			sim.prev[i] = bodyPose{loc: *p.tn.Loc, rot: *p.tn.Rot}
+			sim.next[i] = sim.prev[i]
+		}
+	}
+	ps.updateWorlds(w, sim.eids)
+	sim.touches(sim.touching) // restored contacts are not new contacts.
+	return nil
+}
+
+// place sets the simulation body transforms from their povs.
+func (sim *simulation) place(ps *povs) {
 	for i := range sim.bodies {
 		bod := &sim.bodies[i]
 		eid := sim.eids[i]
@@ -156,9 +643,164 @@ func (sim *simulation) simulate(ps *povs, timestep float64) {
 			slog.Error("physics body with no pov", "eid", eid)
 			continue
 		}
-		p.tn.Loc.Set(bod.Position())
-		p.tn.Rot.Set(bod.Rotation())
-		// physics does not change scale.
-		ps.updateWorld(p, eid)
+		bod.SetPosition(*p.tn.Loc)
+		bod.SetRotation(*p.tn.Rot)
+		bod.SetScale(*p.sw)
+	}
+}
+
+// contact calls the entity contact functions for the bodies that
+// started or stopped touching during the last simulation step.
+// Expected to be called after simulate.
+func (sim *simulation) contact(app *application) {
+	if len(sim.handlers) == 0 && !app.events.contacts.active() {
+		clear(sim.touching)
+		return
+	}
+	now, last := sim.touched, sim.touching
+	sim.touches(now)
+	begins, ends := []simPair{}, []simPair{}
+	for pair := range now {
+		if _, ok := last[pair]; !ok {
+			begins = append(begins, pair)
+		}
+	}
+	for pair := range last {
+		if _, ok := now[pair]; !ok {
+			ends = append(ends, pair)
+		}
+	}
+	slices.SortFunc(begins, comparePairs)
+	slices.SortFunc(ends, comparePairs)
+	sim.touching, sim.touched = now, last
+
+	// call the functions after updating the contacts
+	// in case the functions dispose bodies.
+	for _, pair := range begins {
+		sim.call(app, pair, now[pair], true)
+	}
+	for _, pair := range ends {
+		sim.call(app, pair, last[pair], false)
+	}
+	sim.publish(app, begins, now, true)
+	sim.publish(app, ends, last, false)
+}
+
+// publish queues the contact events for the given entity pairs.
+func (sim *simulation) publish(app *application, pairs []simPair, contacts map[simPair]physics.Contact, begin bool) {
+	for _, pair := range pairs {
+		c := contacts[pair]
+		app.events.contacts.Publish(ContactEvent{
+			A:       &Entity{app: app, eid: pair.e1},
+			B:       &Entity{app: app, eid: pair.e2},
+			Point:   c.Point,
+			Normal:  c.Normal,
+			Trigger: c.Trigger,
+			Begin:   begin,
+		})
+	}
+}
+
+// touches sets touching to the entities that touched during the
+// last simulation step.
+func (sim *simulation) touches(touching map[simPair]physics.Contact) {
+	clear(touching)
+	sim.found = physics.Contacts(sim.found[:0])
+	for _, c := range sim.found {
+		pair := simPair{e1: sim.eids[c.B1], e2: sim.eids[c.B2]}
+		if pair.e1 > pair.e2 {
+			pair.e1, pair.e2 = pair.e2, pair.e1
+			c.Normal.Neg(&c.Normal)
+		}
+		touching[pair] = c
+	}
+}
+
+// comparePairs orders entity pairs.
+func comparePairs(a, b simPair) int {
+	if a.e1 != b.e1 {
+		return cmp.Compare(a.e1, b.e1)
+	}
+	return cmp.Compare(a.e2, b.e2)
+}
+
+// call calls the begin or end contact functions for both entities.
+func (sim *simulation) call(app *application, pair simPair, c physics.Contact, begin bool) {
+	for _, e := range []eID{pair.e1, pair.e2} {
+		h, ok := sim.handlers[e]
+		fn := h.end
+		if begin {
+			fn = h.begin
+		}
+		if !ok || fn == nil {
+			continue
+		}
+		other, normal := pair.e2, c.Normal
+		if e == pair.e2 {
+			other = pair.e1
+			normal.Neg(&normal)
+		}
+		fn(Contact{Other: &Entity{app: app, eid: other}, Point: c.Point, Normal: normal, Trigger: c.Trigger})
+	}
+}
+
+// raycast returns the closest body hit by the ray using the
+// body transforms from the povs.
+func (sim *simulation) raycast(ps *povs, origin, dir lin.V3, maxDistance float64) (physics.RayHit, bool) {
+	sim.place(ps)
+	return physics.Raycast(sim.bodies, origin, dir, maxDistance)
+}
+
+// queryBox returns the bids of the bodies overlapping the box
+// using the body transforms from the povs.
+func (sim *simulation) queryBox(ps *povs, lo, hi lin.V3) []int {
+	sim.place(ps)
+	return physics.QueryBox(sim.bodies, lo, hi, nil)
+}
+
+// querySphere returns the bids of the bodies overlapping the sphere
+// using the body transforms from the povs.
+func (sim *simulation) querySphere(ps *povs, center lin.V3, radius float64) []int {
+	sim.place(ps)
+	return physics.QuerySphere(sim.bodies, center, radius, nil)
+}
+
+// entities returns the entities for the given bids.
+func (sim *simulation) entities(app *application, bids []int) []*Entity {
+	found := make([]*Entity, len(bids))
+	for i, bid := range bids {
+		found[i] = &Entity{app: app, eid: sim.eids[bid]}
+	}
+	return found
+}
+
+// interpolate sets the render transforms of the simulated bodies
+// between their last two simulation steps, where alpha is the
+// fraction of the next step that has elapsed. Bodies moved by the
+// application since the last step are rendered where they are.
+// Called after povs.setWorldMatrix.
+func (sim *simulation) interpolate(ps *povs, alpha float64) {
+	if !sim.smooth || len(sim.bodies) == 0 {
+		return
+	}
+	alpha = min(max(alpha, 0), 1)
+	loc, rot := &lin.V3{}, &lin.Q{}
+	for i, eid := range sim.eids {
+		index, ok := ps.index[eid]
+		if !ok {
+			continue
+		}
+		p, prev, next := &ps.povs[index], &sim.prev[i], &sim.next[i]
+		if !p.tn.Loc.Eq(&next.loc) || !p.tn.Rot.Eq(&next.rot) {
+			continue // moved by the application.
+		}
+		to := next.rot
+		if prev.rot.Dot(&to) < 0 {
+			to.Neg() // same rotation in the same hemisphere.
+		}
+		loc.Lerp(&prev.loc, &next.loc, alpha)
+		rot.Nlerp(&prev.rot, &to, alpha)
+		ps.setModelMatrix(index, loc, rot)
 	}
+	ps.setLerpedChildren()
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/physics"
 )
 
 // go test -run Sim
@@ -21,7 +22,7 @@ func TestSimulation(t *testing.T) {
 		// position before
 		b10 := lin.NewV3().SetS(b1.At())
 		// run one physics simulation step
-		app.sim.simulate(app.povs, timestepSecs)
+		app.sim.simulate(app.povs, app.work, timestepSecs)
 		// position after
 		b11 := lin.NewV3().SetS(b1.At())
 
@@ -43,7 +44,7 @@ func TestSimulation(t *testing.T) {
 		// positions before
 		b10, b20 := lin.NewV3().SetS(b1.At()), lin.NewV3().SetS(b2.At())
 		// run one physics simulation step
-		app.sim.simulate(app.povs, timestepSecs)
+		app.sim.simulate(app.povs, app.work, timestepSecs)
 		// positions after
 		b11, b21 := lin.NewV3().SetS(b1.At()), lin.NewV3().SetS(b2.At())
 
@@ -68,7 +69,7 @@ func TestSimulation(t *testing.T) {
 		// positions before
 		b10, b20 := lin.NewV3().SetS(b1.At()), lin.NewV3().SetS(b2.At())
 		// run one physics simulation step
-		app.sim.simulate(app.povs, timestepSecs)
+		app.sim.simulate(app.povs, app.work, timestepSecs)
 		// positions after
 		b11, b21 := lin.NewV3().SetS(b1.At()), lin.NewV3().SetS(b2.At())
 
@@ -77,6 +78,215 @@ func TestSimulation(t *testing.T) {
 			t.Errorf("expected both balls to move")
 		}
 	})
+
+	// go test -run Sim/interpolate
+	t.Run("interpolate", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		ball := scene.AddPart().SetAt(0, 10, 0).AddToSimulation(Sphere(1, KinematicSim))
+		ball.Push(0, -6, 0)
+		label := ball.AddPart().SetAt(0, 2, 0)
+		app.sim.simulate(app.povs, app.work, timestepSecs)
+		_, y, _ := ball.At()
+
+		// render half way between the last two simulation steps.
+		app.povs.setWorldMatrix(app.work, 0)
+		app.sim.interpolate(app.povs, 0.5)
+		p, kid := app.povs.get(ball.eid), app.povs.get(label.eid)
+		if want := (10 + y) / 2; !lin.Aeq(p.mm.Wy, want) || !lin.Aeq(kid.mm.Wy, want+2) {
+			t.Errorf("expected interpolated %f got %f %f", want, p.mm.Wy, kid.mm.Wy)
+		}
+
+		// bodies moved by the application are not interpolated.
+		ball.SetAt(5, 5, 5)
+		app.povs.setWorldMatrix(app.work, 0)
+		if app.sim.interpolate(app.povs, 0.5); p.mm.Wy != 5 || kid.mm.Wy != 7 {
+			t.Errorf("expected application location got %f %f", p.mm.Wy, kid.mm.Wy)
+		}
+	})
+
+	// go test -run Sim/character
+	t.Run("character", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(20, 1, 20, StaticSim))
+		player := scene.AddPart().SetAt(0, 3, 0).AddCharacter(0.5, 0.5)
+		if player.Character() == nil || player.Body() == nil {
+			t.Fatal("expected character body")
+		}
+		for i := 0; i < 30 && !player.MoveCharacter(0.1, -0.2, 0); i++ {
+		}
+		if x, y, _ := player.At(); x < 0.5 || y < 0.99 || y > 1.01 {
+			t.Errorf("expected character on the ground got %f %f", x, y)
+		}
+		player.DisposeBody()
+		if player.Character() != nil || player.MoveCharacter(0, -1, 0) {
+			t.Error("expected character to be disposed")
+		}
+	})
+
+	// go test -run Sim/joint
+	t.Run("joint", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		post := scene.AddPart().SetAt(0, 5, 0).AddToSimulation(Box(0.1, 0.1, 0.1, StaticSim))
+		ball := scene.AddPart().SetAt(2, 5, 0).AddToSimulation(Sphere(0.5, KinematicSim))
+		if post.AddJoint(ball, BallJoint, lin.V3{X: 0, Y: 5, Z: 0}, lin.V3{X: 1}) == nil {
+			t.Fatal("expected joint")
+		}
+		for i := 0; i < 30; i++ {
+			app.sim.simulate(app.povs, app.work, timestepSecs)
+		}
+		x, y, z := ball.At()
+		if d := lin.NewV3().SetS(x, y-5, z).Len(); d < 1.98 || d > 2.02 || y > 4.5 {
+			t.Errorf("expected pendulum swing got %f %f %f", x, y, z)
+		}
+
+		// joints are removed with their bodies.
+		ball.DisposeBody()
+		if len(app.sim.joints) != 0 {
+			t.Errorf("expected joint to be disposed")
+		}
+	})
+
+	// go test -run Sim/contact
+	t.Run("contact", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		ground := scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(10, 1, 10, StaticSim))
+		zone := scene.AddPart().SetAt(0, 2, 0).AddToSimulation(Trigger(Box(1, 0.5, 1, StaticSim)))
+		ball := scene.AddPart().SetAt(0, 4, 0).AddToSimulation(Sphere(0.5, KinematicSim))
+		ghost := scene.AddPart().SetAt(3, 4, 0).AddToSimulation(Layer(Sphere(0.5, KinematicSim), 2, 2))
+		begins, ends := []Contact{}, []Contact{}
+		ball.OnContact(func(c Contact) { begins = append(begins, c) }, func(c Contact) { ends = append(ends, c) })
+		for i := 0; i < 90; i++ {
+			app.sim.simulate(app.povs, app.work, timestepSecs)
+			app.sim.contact(app)
+		}
+		if len(begins) != 2 || len(ends) != 1 {
+			t.Fatalf("expected trigger and ground contacts got %d begins %d ends", len(begins), len(ends))
+		}
+		if c := begins[0]; c.Other.eid != zone.eid || !c.Trigger || ends[0].Other.eid != zone.eid {
+			t.Errorf("expected to pass through the trigger first got %+v", c)
+		}
+		if c := begins[1]; c.Other.eid != ground.eid || c.Trigger || c.Normal.Y > -0.99 {
+			t.Errorf("expected to land on the ground got %+v", c)
+		}
+		if _, y, _ := ghost.At(); y > -1 {
+			t.Errorf("expected ghost to fall through the ground got %f", y)
+		}
+	})
+
+	// go test -run Sim/snapshot
+	t.Run("snapshot", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		scene := eng.AddScene(Scene3D)
+		scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(10, 1, 10, StaticSim))
+		ball := scene.AddPart().SetAt(-3, 0.5, 0).AddToSimulation(Sphere(0.5, KinematicSim))
+		box := scene.AddPart().SetAt(0, 3, 0).AddToSimulation(Box(0.5, 0.5, 0.5, KinematicSim))
+		player := scene.AddPart().SetAt(3, 1, 0).AddCharacter(0.5, 0.5)
+		ball.Push(4, 0, 0)
+		touches := 0
+		box.OnContact(func(c Contact) { touches++ }, nil)
+		for i := 0; i < 20; i++ {
+			eng.StepPhysics()
+			player.MoveCharacter(0, -0.1, 0)
+		}
+		state := eng.SavePhysics(nil)
+		data, err := state.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := touches
+		for i := 0; i < 40; i++ {
+			eng.StepPhysics()
+		}
+		bx, by, bz := ball.At()
+		ox, oy, oz := box.At()
+		want := touches - saved
+
+		// roll back and replay the same steps using the decoded state.
+		decoded := &PhysicsState{}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := eng.RestorePhysics(decoded); err != nil || eng.PhysicsStep() != 20 {
+			t.Fatalf("expected restore to step 20 got %d %v", eng.PhysicsStep(), err)
+		}
+		if x, _, _ := ball.At(); x == bx {
+			t.Errorf("expected ball to move back")
+		}
+		touches = saved
+		for i := 0; i < 40; i++ {
+			eng.StepPhysics()
+		}
+		if x, y, z := ball.At(); x != bx || y != by || z != bz {
+			t.Errorf("expected ball %f %f %f got %f %f %f", bx, by, bz, x, y, z)
+		}
+		if x, y, z := box.At(); x != ox || y != oy || z != oz {
+			t.Errorf("expected box %f %f %f got %f %f %f", ox, oy, oz, x, y, z)
+		}
+		if touches-saved != want || !player.Character().Grounded() {
+			t.Errorf("expected restored contacts and character got %d %d", touches-saved, want)
+		}
+
+		// bodies must match the saved state.
+		scene.AddPart().AddToSimulation(Sphere(1, KinematicSim))
+		if err := eng.RestorePhysics(state); err == nil {
+			t.Errorf("expected restore to fail with an extra body")
+		}
+	})
+
+	// go test -run Sim/query
+	// go test -run Sim/vehicle
+	t.Run("vehicle", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		ground := scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(50, 1, 50, StaticSim))
+		if ground.AddVehicle() != nil {
+			t.Error("expected static vehicle to fail")
+		}
+		car := scene.AddPart().SetAt(0, 0.8, 0).AddToSimulation(Box(1, 0.25, 2, KinematicSim))
+		wheels := []physics.Wheel{}
+		for _, at := range []lin.V3{{X: -1, Z: -1.5}, {X: 1, Z: -1.5}, {X: -1, Z: 1.5}, {X: 1, Z: 1.5}} {
+			wheels = append(wheels, physics.Wheel{Mount: at, Radius: 0.3, Rest: 0.5, Drive: true})
+		}
+		v := car.AddVehicle(wheels...)
+		v.Engine = 5
+		for i := 0; i < 60; i++ {
+			app.sim.simulate(app.povs, app.work, timestepSecs)
+		}
+		if _, y, z := car.At(); y < 0.4 || z > -1 || car.Vehicle().Speed() < 2 {
+			t.Errorf("expected vehicle to drive forward got %f %f", y, z)
+		}
+		car.DisposeBody()
+		if car.Vehicle() != nil {
+			t.Error("expected vehicle to be disposed")
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		app := newApplication()
+		scene := app.addScene(Scene3D)
+		ground := scene.AddPart().SetAt(0, -1, 0).AddToSimulation(Box(10, 1, 10, StaticSim))
+		ball := scene.AddPart().SetAt(0, 3, 0).AddToSimulation(Sphere(1, KinematicSim))
+		ball.SetAt(4, 3, 0) // moved by the app after being added.
+		hit, ok := app.sim.raycast(app.povs, lin.V3{X: 4, Y: 10, Z: 0}, lin.V3{Y: -1}, 100)
+		if !ok || app.sim.eids[hit.Body] != ball.eid || !lin.Aeq(hit.Point.Y, 4) {
+			t.Errorf("expected ray to hit ball got %t %v", ok, hit)
+		}
+		hit, ok = app.sim.raycast(app.povs, lin.V3{X: 0, Y: 10, Z: 0}, lin.V3{Y: -1}, 100)
+		if !ok || app.sim.eids[hit.Body] != ground.eid || !lin.Aeq(hit.Distance, 10) {
+			t.Errorf("expected ray to hit ground got %t %v", ok, hit)
+		}
+		found := app.sim.entities(app, app.sim.querySphere(app.povs, lin.V3{X: 4, Y: 1, Z: 0}, 1.5))
+		if len(found) != 2 {
+			t.Errorf("expected sphere to overlap ground and ball got %d", len(found))
+		}
+		if found = app.sim.entities(app, app.sim.queryBox(app.povs, lin.V3{X: 3, Y: 2, Z: -1}, lin.V3{X: 5, Y: 3, Z: 1})); len(found) != 1 || found[0].eid != ball.eid {
+			t.Errorf("expected box to overlap the ball")
+		}
+	})
 }
 
 // go test -run Bug
@@ -104,7 +314,7 @@ func TestBug(t *testing.T) {
 
 	// // run one physics simulation step
 	// for i := 0; i < 120; i++ {
-	// 	app.sim.simulate(app.povs, timestepSecs)
+	// 	app.sim.simulate(app.povs, app.work, timestepSecs)
 	// 	pbod := (*physics.Body)(b.Body())
 	// 	pos := pbod.Position()
 	// 	vel := pbod.Velocity()
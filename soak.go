@@ -0,0 +1,267 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// soak.go runs a scene unattended for hours to catch slow resource
+// leaks, eg:
+//
+//	eng.Soak(vu.SoakTest{
+//		Duration: 4 * time.Hour,
+//		Scene:    scene, // scene camera follows the path.
+//		Path:     []vu.SoakPoint{{X: 0, Z: 10}, {At: time.Minute, X: 50, Z: 10, Yaw: 180}},
+//		Limits:   vu.SoakLimits{Memory: 64 << 20, Handles: 100, Frame: 2 * time.Millisecond},
+//		Report:   func(r vu.SoakReport) { fmt.Println(r) },
+//	})
+//	eng.Run(app)
+//
+// Resource usage is sampled at regular intervals and compared to a
+// baseline sample taken after a warmup period. The soak test fails and
+// the engine shuts down as soon as a limit is exceeded. Otherwise the
+// engine shuts down once the soak test duration has passed.
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SoakTest describes an unattended engine run.
+type SoakTest struct {
+	Duration time.Duration // test length, zero runs until shutdown.
+	Interval time.Duration // time between samples. Default 1 minute.
+	Warmup   time.Duration // time before the baseline sample.
+
+	// Optional scripted camera path. The path is repeated
+	// until the end of the test.
+	Scene *Entity     // scene whose camera follows the path.
+	Path  []SoakPoint // camera path points in time order.
+
+	// Limits for resource growth since the baseline.
+	Limits SoakLimits
+
+	// Report is called once when the soak test passes or fails.
+	// The report is logged if there is no Report callback.
+	Report func(r SoakReport)
+}
+
+// SoakPoint is a camera location and direction at a given time.
+// The camera moves smoothly between the points of a path.
+type SoakPoint struct {
+	At         time.Duration // time since the start of the path.
+	X, Y, Z    float64       // camera location.
+	Pitch, Yaw float64       // camera direction in degrees.
+}
+
+// SoakLimits is the allowed growth over the baseline sample.
+// A zero limit is not checked.
+type SoakLimits struct {
+	Memory    uint64        // Go heap bytes.
+	GPUMemory uint64        // renderer GPU memory bytes.
+	Handles   int           // operating system handles.
+	Frame     time.Duration // average frame time.
+}
+
+// SoakSample is the resource usage at one point of a soak test.
+type SoakSample struct {
+	Elapsed    time.Duration // time since the test started.
+	Memory     uint64        // Go heap bytes after garbage collection.
+	GPUMemory  uint64        // renderer GPU memory bytes.
+	Handles    int           // operating system handles.
+	Goroutines int           // number of goroutines.
+	Frame      time.Duration // average frame time since the last sample.
+}
+
+// SoakReport is the soak test result.
+type SoakReport struct {
+	Passed   bool         // true if the test ran without exceeding a limit.
+	Failure  string       // reason the test failed.
+	Baseline SoakSample   // sample that the limits are compared against.
+	Samples  []SoakSample // all samples, including the baseline.
+}
+
+// Soak starts a soak test when the engine runs. The engine shuts
+// down when the test is done. Calling Soak replaces any earlier test.
+func (eng *Engine) Soak(test SoakTest) {
+	if test.Interval <= 0 {
+		test.Interval = time.Minute
+	}
+	if test.Scene != nil && eng.app.scenes.get(test.Scene.eid) == nil {
+		slog.Error("Soak camera path needs AddScene", "eid", test.Scene.eid)
+		test.Scene = nil
+	}
+	eng.soak = &soak{test: test}
+}
+
+// soakFrame advances the soak test each frame.
+func (eng *Engine) soakFrame(delta time.Duration, now time.Time) {
+	if eng.soak == nil {
+		return
+	}
+	if eng.soak.frame(delta, now, eng.measure) {
+		eng.soak.finish()
+		eng.soak = nil
+		eng.Shutdown()
+	}
+}
+
+// stopSoak reports a soak test that was still running when the
+// engine shut down, eg: the user closed the window. Tests without
+// a duration pass, other tests fail since they ended early.
+func (eng *Engine) stopSoak(now time.Time) {
+	if eng.soak == nil {
+		return
+	}
+	elapsed := time.Duration(0)
+	if !eng.soak.start.IsZero() {
+		elapsed = now.Sub(eng.soak.start)
+	}
+	eng.soak.report.Passed = eng.soak.test.Duration == 0
+	if !eng.soak.report.Passed {
+		eng.soak.report.Failure = fmt.Sprintf("stopped after %s", elapsed.Round(time.Second))
+	}
+	eng.soak.finish()
+	eng.soak = nil
+}
+
+// measure samples the engine resource usage.
+func (eng *Engine) measure() SoakSample {
+	runtime.GC() // measure the live heap.
+	mem := runtime.MemStats{}
+	runtime.ReadMemStats(&mem)
+	return SoakSample{
+		Memory:     mem.HeapAlloc,
+		GPUMemory:  eng.rc.Stats().Memory,
+		Handles:    eng.dev.Handles(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// =============================================================================
+// soak test tracking.
+
+// soak tracks a running soak test.
+type soak struct {
+	test   SoakTest
+	start  time.Time     // first frame.
+	last   time.Time     // last sample.
+	frames int           // frames since the last sample.
+	total  time.Duration // frame time since the last sample.
+	base   bool          // true once the baseline is sampled.
+	report SoakReport
+}
+
+// frame moves the camera along the path and samples the resource
+// usage each interval. Returns true when the test is done.
+func (s *soak) frame(delta time.Duration, now time.Time, measure func() SoakSample) (done bool) {
+	if s.start.IsZero() {
+		s.start, s.last = now, now
+	}
+	elapsed := now.Sub(s.start)
+	if s.test.Scene != nil && len(s.test.Path) > 0 {
+		p := s.point(elapsed)
+		s.test.Scene.Cam().SetAt(p.X, p.Y, p.Z).SetPitch(p.Pitch).SetYaw(p.Yaw)
+	}
+	s.frames++
+	s.total += delta
+	finished := s.test.Duration > 0 && elapsed >= s.test.Duration
+	if now.Sub(s.last) < s.test.Interval && !finished {
+		return false
+	}
+
+	// sample the resources.
+	sample := measure()
+	sample.Elapsed = elapsed
+	sample.Frame = s.total / time.Duration(s.frames)
+	s.last, s.frames, s.total = now, 0, 0
+	s.report.Samples = append(s.report.Samples, sample)
+	slog.Debug("soak", "elapsed", elapsed, "memory", sample.Memory, "gpu", sample.GPUMemory,
+		"handles", sample.Handles, "goroutines", sample.Goroutines, "frame", sample.Frame)
+	switch {
+	case !s.base && elapsed >= s.test.Warmup:
+		s.base = true
+		s.report.Baseline = sample
+	case s.base:
+		if s.report.Failure = s.check(sample); s.report.Failure != "" {
+			return true
+		}
+	}
+	s.report.Passed = finished
+	return finished
+}
+
+// check returns the reason the sample failed the limits,
+// or the empty string if the sample is within the limits.
+func (s *soak) check(sample SoakSample) string {
+	base, limits := s.report.Baseline, s.test.Limits
+	switch {
+	case limits.Memory > 0 && sample.Memory > base.Memory+limits.Memory:
+		return fmt.Sprintf("memory grew %d bytes, limit %d", sample.Memory-base.Memory, limits.Memory)
+	case limits.GPUMemory > 0 && sample.GPUMemory > base.GPUMemory+limits.GPUMemory:
+		return fmt.Sprintf("GPU memory grew %d bytes, limit %d", sample.GPUMemory-base.GPUMemory, limits.GPUMemory)
+	case limits.Handles > 0 && sample.Handles > base.Handles+limits.Handles:
+		return fmt.Sprintf("handles grew %d, limit %d", sample.Handles-base.Handles, limits.Handles)
+	case limits.Frame > 0 && sample.Frame > base.Frame+limits.Frame:
+		return fmt.Sprintf("frame time drifted %s, limit %s", sample.Frame-base.Frame, limits.Frame)
+	}
+	return ""
+}
+
+// point returns the camera path location at the given time.
+// The path starts at the first point and repeats after the last point.
+func (s *soak) point(elapsed time.Duration) SoakPoint {
+	path := s.test.Path
+	if end := path[len(path)-1].At; end > 0 {
+		elapsed %= end
+	}
+	if elapsed < path[0].At {
+		return path[0] // hold the first point until the path starts.
+	}
+	for i := 1; i < len(path); i++ {
+		p0, p1 := path[i-1], path[i]
+		if elapsed >= p1.At {
+			continue
+		}
+		if span := p1.At - p0.At; span > 0 {
+			r := float64(elapsed-p0.At) / float64(span)
+			lerp := func(a, b float64) float64 { return a + (b-a)*r }
+			return SoakPoint{At: elapsed,
+				X: lerp(p0.X, p1.X), Y: lerp(p0.Y, p1.Y), Z: lerp(p0.Z, p1.Z),
+				Pitch: lerp(p0.Pitch, p1.Pitch), Yaw: lerp(p0.Yaw, p1.Yaw)}
+		}
+		return p1
+	}
+	return path[len(path)-1]
+}
+
+// finish hands the report to the application.
+func (s *soak) finish() {
+	if s.test.Report != nil {
+		s.test.Report(s.report)
+		return
+	}
+	if s.report.Passed {
+		slog.Info("soak test passed", "report", s.report.String())
+		return
+	}
+	slog.Error("soak test failed", "report", s.report.String())
+}
+
+// String formats the report as a table with one sample per line.
+func (r SoakReport) String() string {
+	sb := &strings.Builder{}
+	if r.Passed {
+		sb.WriteString("soak passed\n")
+	} else {
+		fmt.Fprintf(sb, "soak failed: %s\n", r.Failure)
+	}
+	mb := func(b uint64) float64 { return float64(b) / (1 << 20) }
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	sb.WriteString("elapsed memoryMB gpuMB handles goroutines frameMS")
+	for _, s := range r.Samples {
+		fmt.Fprintf(sb, "\n%s %.1f %.1f %d %d %.2f", s.Elapsed.Round(time.Second),
+			mb(s.Memory), mb(s.GPUMemory), s.Handles, s.Goroutines, ms(s.Frame))
+	}
+	return sb.String()
+}
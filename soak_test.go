@@ -0,0 +1,94 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -run Soak
+func TestSoak(t *testing.T) {
+	now := time.Now()
+	frame := 16 * time.Millisecond
+
+	// measure returns samples with growing memory.
+	memory := uint64(1000)
+	measure := func() SoakSample {
+		memory += 100
+		return SoakSample{Memory: memory, Handles: 10}
+	}
+
+	t.Run("pass", func(t *testing.T) {
+		s := &soak{test: SoakTest{Duration: 3 * time.Minute, Interval: time.Minute}}
+		done := false
+		for i := 0; i <= 180 && !done; i++ {
+			done = s.frame(frame, now.Add(time.Duration(i)*time.Second), measure)
+		}
+		if !done || !s.report.Passed || len(s.report.Samples) != 3 {
+			t.Errorf("expected a passed soak test %+v", s.report)
+		}
+		if s.report.Samples[0].Frame != frame || s.report.Baseline != s.report.Samples[0] {
+			t.Errorf("expected the first sample as the baseline %+v", s.report)
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		s := &soak{test: SoakTest{Duration: time.Hour, Interval: time.Minute, Warmup: 2 * time.Minute}}
+		s.test.Limits.Memory = 150
+		done := false
+		minutes := 0
+		for ; minutes < 10 && !done; minutes++ {
+			done = s.frame(frame, now.Add(time.Duration(minutes)*time.Minute), measure)
+		}
+		if !done || s.report.Passed || !strings.HasPrefix(s.report.Failure, "memory grew 200") {
+			t.Errorf("expected memory failure %+v", s.report)
+		}
+		if s.report.Baseline.Elapsed != 2*time.Minute || minutes != 5 {
+			t.Errorf("expected baseline after warmup %+v", s.report.Baseline)
+		}
+		if str := s.report.String(); !strings.Contains(str, "soak failed: memory grew") {
+			t.Errorf("unexpected report %s", str)
+		}
+	})
+	t.Run("drift", func(t *testing.T) {
+		s := &soak{test: SoakTest{Interval: time.Minute}}
+		s.test.Limits.Frame = time.Millisecond
+		s.frame(frame, now, measure)
+		s.frame(frame, now.Add(time.Minute), measure) // baseline.
+		if s.frame(3*frame, now.Add(2*time.Minute), measure) != true {
+			t.Errorf("expected frame time drift failure")
+		}
+	})
+	t.Run("path", func(t *testing.T) {
+		s := &soak{test: SoakTest{Path: []SoakPoint{
+			{X: 0, Yaw: 0},
+			{At: 10 * time.Second, X: 10, Yaw: 90},
+			{At: 20 * time.Second, X: 0, Yaw: 0},
+		}}}
+		if p := s.point(5 * time.Second); p.X != 5 || p.Yaw != 45 {
+			t.Errorf("expected halfway point %+v", p)
+		}
+		if p := s.point(25 * time.Second); p.X != 5 || p.Yaw != 45 {
+			t.Errorf("expected repeated path %+v", p)
+		}
+		s.test.Path[0].At = 4 * time.Second
+		if p := s.point(2 * time.Second); p.X != 0 || p.Yaw != 0 {
+			t.Errorf("expected the first point before the path starts %+v", p)
+		}
+	})
+	t.Run("camera", func(t *testing.T) {
+		eng := &Engine{app: newApplication()}
+		scene := eng.AddScene(Scene3D)
+		eng.Soak(SoakTest{Scene: scene, Path: []SoakPoint{{X: 1}, {At: time.Second, X: 3}}})
+		eng.soak.frame(frame, now, measure)
+		eng.soak.frame(frame, now.Add(500*time.Millisecond), measure)
+		if x, _, _ := scene.Cam().At(); math.Abs(x-2) > 1e-9 {
+			t.Errorf("expected camera to follow the path got %f", x)
+		}
+		if eng.soak.test.Interval != time.Minute {
+			t.Errorf("expected default interval")
+		}
+	})
+}
@@ -3,9 +3,19 @@
 package vu
 
 // sound.go wraps the audio package and controls all engine sounds.
+// Sounds can be grouped into named buses, eg: "music", "sfx", "voice",
+// that each have their own volume, mute, and effect. Eg:
+//
+//	music := eng.AddSound("theme")
+//	music.SetSoundBus("music")
+//	eng.SetBusVolume("music", 0.4)
+//	eng.SetBusEffect("sfx", vu.LowPass) // underwater.
 
 import (
 	"log/slog"
+	"time"
+
+	"github.com/gazed/vu/audio"
 )
 
 // PlaySound plays the given sound at this entities location.
@@ -15,7 +25,7 @@ import (
 func (e *Entity) PlaySound(eng *Engine, sound *Entity) {
 	if p := e.app.povs.get(e.eid); p != nil {
 		if s := e.app.sounds.get(sound.eid); s != nil {
-			e.app.sounds.play(eng, s, p)
+			e.app.sounds.play(eng, sound.eid, s, p)
 		}
 		return
 	}
@@ -33,6 +43,101 @@ func (e *Entity) SetListener() {
 	slog.Error("SetListener requires location", "entity", e.eid)
 }
 
+// SetSoundBus puts this sound on the named bus. The sound takes on the
+// bus volume and effect. Sounds that are not on a bus are only affected
+// by SetVolume.
+//
+// Depends on Engine.AddSound.
+func (e *Entity) SetSoundBus(bus string) {
+	e.app.sounds.buses[e.eid] = bus // applied when the sound is played.
+}
+
+// SoundEffect is applied to all the sounds on a bus, see SetBusEffect.
+type SoundEffect = audio.Effect
+
+// Expose the audio bus effects, see SetBusEffect.
+const (
+	NoEffect = audio.NoEffect // sounds play unchanged.
+	Reverb   = audio.Reverb   // sounds echo as if in a large room.
+	LowPass  = audio.LowPass  // sounds are muffled, eg: underwater.
+)
+
+// SetBusVolume sets the volume for the sounds on the named bus.
+// Valid values are 0 for silent to 1 for full volume. The bus volume
+// is combined with the overall SetVolume.
+func (eng *Engine) SetBusVolume(bus string, zeroToOne float64) {
+	eng.ac.SetBusGain(bus, zeroToOne)
+}
+
+// BusVolume returns the volume of the named bus.
+func (eng *Engine) BusVolume(bus string) float64 { return eng.ac.BusGain(bus) }
+
+// MuteBus silences, or restores, the sounds on the named bus.
+func (eng *Engine) MuteBus(bus string, mute bool) { eng.ac.MuteBus(bus, mute) }
+
+// BusMuted returns true if the named bus is muted.
+func (eng *Engine) BusMuted(bus string) bool { return eng.ac.BusMuted(bus) }
+
+// SetBusEffect applies the effect to the sounds on the named bus.
+// Returns an error wrapping ErrUnsupportedFeature if the audio
+// device does not support effects.
+func (eng *Engine) SetBusEffect(bus string, effect SoundEffect) error {
+	return eng.ac.SetBusEffect(bus, effect)
+}
+
+// SetVolume sets the volume for all application sounds.
+// Valid values are 0 for silent to 1 for full volume.
+func (eng *Engine) SetVolume(zeroToOne float64) {
+	eng.ac.SetGain(zeroToOne)
+}
+
+// AudioDevices returns the names of the available audio output devices.
+func (eng *Engine) AudioDevices() []string { return eng.ac.Devices() }
+
+// AudioDevice returns the name of the audio output device playing sounds.
+func (eng *Engine) AudioDevice() string { return eng.ac.Device() }
+
+// SetAudioDevice plays sounds on the named audio output device, where
+// the name is one of eng.AudioDevices. The empty string "", the default,
+// follows the system default device, eg: switching to headphones when
+// they are plugged in. Sounds move to the default device if the named
+// device is disconnected.
+func (eng *Engine) SetAudioDevice(name string) error {
+	return eng.ac.SetDevice(name)
+}
+
+// AudioCaptureDevices returns the names of the available
+// audio input devices, eg: microphones.
+func (eng *Engine) AudioCaptureDevices() []string { return eng.ac.CaptureDevices() }
+
+// StartAudioCapture records from the named audio input device, where
+// the name is one of eng.AudioCaptureDevices, or "" for the default
+// input device. Recorded sound is passed to pcm as mono 16-bit samples
+// at the given rate, eg: 16000 for voice chat. The pcm callback is
+// called from a capture goroutine and the samples are reused after
+// the callback returns.
+func (eng *Engine) StartAudioCapture(name string, rate int, pcm func(samples []int16)) error {
+	return eng.ac.StartCapture(name, rate, pcm)
+}
+
+// StopAudioCapture stops recording from the audio input device.
+func (eng *Engine) StopAudioCapture() { eng.ac.StopCapture() }
+
+// audioRefresh is how often the audio output device is checked
+// for disconnects and default device changes.
+const audioRefresh = time.Second
+
+// refreshAudio periodically checks that the audio device is still valid.
+func (eng *Engine) refreshAudio(delta time.Duration) {
+	if eng.audioCheck += delta; eng.audioCheck < audioRefresh {
+		return
+	}
+	eng.audioCheck = 0
+	if eng.ac.Refresh() {
+		slog.Info("audio device changed", "device", eng.ac.Device())
+	}
+}
+
 // =============================================================================
 // sounds: component manager for sound.
 
@@ -40,6 +145,7 @@ func (e *Entity) SetListener() {
 // that has been bound to the audio card in order for the sound to be played.
 type sounds struct {
 	list     map[eID]*sound // loaded sounds assets.
+	buses    map[eID]string // mixer bus for each sound entity.
 	listener eID            // Pov listener location.
 }
 
@@ -50,6 +156,7 @@ func (ss *sounds) get(eid eID) *sound { return ss.list[eid] }
 func newSounds() *sounds {
 	ss := &sounds{}
 	ss.list = map[eID]*sound{} // Sounds ready to be played.
+	ss.buses = map[eID]string{}
 	return ss
 }
 
@@ -66,9 +173,12 @@ func (ss *sounds) assetLoaded(eid eID, a asset) {
 	}
 }
 
-// play the given sound.
-func (ss *sounds) play(eng *Engine, sound *sound, pov *pov) {
+// play the given sound entity, moving it to its mixer bus if needed.
+func (ss *sounds) play(eng *Engine, eid eID, sound *sound, pov *pov) {
 	if sound != nil && pov != nil {
+		if bus, ok := ss.buses[eid]; ok && eng.ac.SoundBus(sound.sid) != bus {
+			eng.ac.SetSoundBus(sound.sid, bus)
+		}
 		x, y, z := pov.at()
 		eng.ac.PlaySound(sound.sid, x, y, z)
 	}
@@ -82,6 +192,7 @@ func (ss *sounds) setListener(pov eID) {
 
 // dispose of sound data associated with the given entity.
 func (ss *sounds) dispose(eng *Engine, eid eID) {
+	delete(ss.buses, eid)
 	if s := ss.list[eid]; s != nil {
 		delete(ss.list, eid)
 
@@ -0,0 +1,609 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// spatial.go keeps the world bounds of 3D model parts in a bounding
+// volume hierarchy so that parts can be found without checking every
+// part in the scene, eg:
+//
+//	visible = eng.PartsInView(scene, visible)
+//	near = eng.PartsNear(scene, x, y, z, 10, near)
+//	part, distance, ok := eng.PickPart(scene, ox, oy, oz, dx, dy, dz)
+//
+// 3D scenes only draw the parts whose bounds overlap the camera view.
+// Part bounds are the model mesh bounds moved, rotated, and scaled with
+// the part. Parts are only moved in the hierarchy when they leave their
+// slightly larger hierarchy bounds. Instanced models, labels, and models
+// whose mesh has not loaded have no bounds and are always drawn.
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// PartsInView returns the model parts of the 3D scene that may be seen
+// by the scene camera. Parts without bounds are always included and
+// culled parts are never included. The found parts are appended to the
+// given slice after it is reset so that the memory can be reused.
+func (eng *Engine) PartsInView(scene *Entity, found []*Entity) []*Entity {
+	found = found[:0]
+	sc := eng.app.scenes.get(scene.eid)
+	if sc == nil || sc.pid != render.Pass3D {
+		slog.Error("PartsInView needs 3D scene", "eid", scene.eid)
+		return found
+	}
+	sp := eng.app.spatial
+	sp.parts = sp.inView(eng.app, sc, sp.parts[:0])
+	for _, index := range sp.parts {
+		found = append(found, &Entity{app: eng.app, eid: eng.app.povs.eids[index]})
+	}
+	return found
+}
+
+// PartsNear returns the model parts of the 3D scene whose bounds are
+// within radius of the given world location. Parts without bounds and
+// culled parts are not included. The found parts are appended to the
+// given slice after it is reset so that the memory can be reused.
+func (eng *Engine) PartsNear(scene *Entity, x, y, z, radius float64, found []*Entity) []*Entity {
+	return eng.app.spatial.near(eng.app, scene.eid, lin.V3{X: x, Y: y, Z: z}, radius, found[:0])
+}
+
+// PickPart returns the 3D scene model part whose bounds are hit first by
+// the ray from the world location ox,oy,oz in the direction dx,dy,dz,
+// eg: the ray from Camera.Ray for mouse picking. The distance is along
+// the ray in units of the ray direction length. Returns false if no part
+// was hit. Parts without bounds and culled parts are not picked.
+// See Engine.Pick to pick parts by their mesh triangles.
+func (eng *Engine) PickPart(scene *Entity, ox, oy, oz, dx, dy, dz float64) (part *Entity, distance float64, ok bool) {
+	origin, dir := lin.V3{X: ox, Y: oy, Z: oz}, lin.V3{X: dx, Y: dy, Z: dz}
+	eid, distance, ok := eng.app.spatial.pick(eng.app, scene.eid, origin, dir)
+	if !ok {
+		return nil, 0, false
+	}
+	return &Entity{app: eng.app, eid: eid}, distance, true
+}
+
+// SetViewCull enables or disables view culling for a 3D model.
+// Models are view culled by default. Disable view culling for models
+// whose shaders move the mesh vertexes outside the mesh bounds.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetViewCull(cull bool) *Entity {
+	m := e.app.models.get(e.eid)
+	if m == nil {
+		slog.Error("SetViewCull needs AddModel", "eid", e.eid)
+		return e
+	}
+	m.noViewCull = !cull
+	e.app.spatial.refresh(e.eid)
+	return e
+}
+
+// =============================================================================
+// spatial index component manager.
+
+// spatial tracks the model parts of 3D scenes. Parts with bounds are
+// leaves in the hierarchy. Parts without bounds are kept in a list.
+type spatial struct {
+	tree   bvh
+	leaves map[eID]int32 // parts in the hierarchy.
+	loose  map[eID]eID   // parts without bounds and their scene.
+
+	// scratch
+	vp     *lin.M4   // camera view projection matrix.
+	planes [6]lin.V4 // camera view planes.
+	parts  []uint32  // pov indexes of found parts.
+}
+
+// spatialFat is added to the part bounds in the hierarchy so
+// that parts are only moved in the hierarchy after moving a bit.
+const spatialFat = 0.5
+
+// newSpatial creates the spatial index component manager.
+// There is only expected to be once instance created by the engine.
+func newSpatial() *spatial {
+	return &spatial{
+		tree:   bvh{root: -1, free: -1},
+		leaves: map[eID]int32{},
+		loose:  map[eID]eID{},
+		vp:     &lin.M4{},
+	}
+}
+
+// add tracks a new model part if it is in a 3D scene.
+// The part is added to the hierarchy once it has bounds.
+func (sp *spatial) add(app *application, eid eID) {
+	scene := sceneRoot(app.povs, eid)
+	if sc := app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		return
+	}
+	if p := app.povs.get(eid); p != nil {
+		p.indexed = true
+		sp.loose[eid] = scene
+	}
+}
+
+// refresh moves a tracked part to the loose list so that
+// its bounds are checked on the next update.
+func (sp *spatial) refresh(eid eID) {
+	if leaf, ok := sp.leaves[eid]; ok {
+		sp.loose[eid] = sp.tree.nodes[leaf].scene
+		sp.tree.removeLeaf(leaf)
+		sp.tree.freeNode(leaf)
+		delete(sp.leaves, eid)
+	}
+}
+
+// dispose stops tracking the given part.
+func (sp *spatial) dispose(eid eID) {
+	if leaf, ok := sp.leaves[eid]; ok {
+		sp.tree.removeLeaf(leaf)
+		sp.tree.freeNode(leaf)
+		delete(sp.leaves, eid)
+	}
+	delete(sp.loose, eid)
+}
+
+// update moves the parts that have moved out of their hierarchy bounds
+// and adds the loose parts that now have bounds. The moved parts are
+// reported by the transform manager. Called before each query and each
+// update so that the moved list does not grow.
+func (sp *spatial) update(app *application) {
+	for _, eid := range app.povs.indexMoves {
+		leaf, ok := sp.leaves[eid]
+		if !ok {
+			continue // loose or disposed after moving.
+		}
+		n := &sp.tree.nodes[leaf]
+		lo, hi, bounded := sp.bounds(app, eid)
+		if !bounded {
+			sp.refresh(eid)
+			continue
+		}
+		n.blo, n.bhi = lo, hi
+		if !boxContains(&n.lo, &n.hi, &lo, &hi) {
+			sp.tree.removeLeaf(leaf)
+			n.lo, n.hi = fatten(lo, hi)
+			sp.tree.insertLeaf(leaf)
+		}
+	}
+	app.povs.indexMoves = app.povs.indexMoves[:0]
+	for eid, scene := range sp.loose {
+		if lo, hi, ok := sp.bounds(app, eid); ok {
+			sp.leaves[eid] = sp.tree.insert(lo, hi, eid, scene)
+			delete(sp.loose, eid)
+		}
+	}
+}
+
+// bounds returns the world bounds of a model part. Returns false
+// if the part has no bounds.
+func (sp *spatial) bounds(app *application, eid eID) (lo, hi lin.V3, ok bool) {
+	m, p := app.models.get(eid), app.povs.get(eid)
+	if m == nil || p == nil || m.isInstanced || m.noViewCull || m.mesh == nil || !m.mesh.bounded {
+		return lo, hi, false
+	}
+
+	// transform the mesh box center and extents by the world matrix.
+	// Based on "Transforming Axis-Aligned Bounding Boxes", Jim Arvo,
+	// Graphics Gems 1990.
+	w := p.wm
+	c := lin.NewV3().Add(&m.mesh.lo, &m.mesh.hi)
+	c.Scale(c, 0.5)
+	e := lin.NewV3().Sub(&m.mesh.hi, &m.mesh.lo)
+	e.Scale(e, 0.5)
+	center := lin.V3{
+		X: c.X*w.Xx + c.Y*w.Yx + c.Z*w.Zx + w.Wx,
+		Y: c.X*w.Xy + c.Y*w.Yy + c.Z*w.Zy + w.Wy,
+		Z: c.X*w.Xz + c.Y*w.Yz + c.Z*w.Zz + w.Wz,
+	}
+	extent := lin.V3{
+		X: e.X*math.Abs(w.Xx) + e.Y*math.Abs(w.Yx) + e.Z*math.Abs(w.Zx),
+		Y: e.X*math.Abs(w.Xy) + e.Y*math.Abs(w.Yy) + e.Z*math.Abs(w.Zy),
+		Z: e.X*math.Abs(w.Xz) + e.Y*math.Abs(w.Yz) + e.Z*math.Abs(w.Zz),
+	}
+	lo.Sub(&center, &extent)
+	hi.Add(&center, &extent)
+	return lo, hi, true
+}
+
+// inView appends the pov indexes of the scene parts that overlap the
+// scene camera view, and of the scene parts without bounds. The indexes
+// are sorted so that parents are before their children.
+func (sp *spatial) inView(app *application, sc *scene, parts []uint32) []uint32 {
+	sp.update(app)
+	sp.setPlanes(sc.cam)
+	sp.tree.query(func(lo, hi *lin.V3) bool { return sp.boxInView(lo, hi) }, func(n *bvhNode) {
+		if n.scene == sc.eid && !culled(app.povs, n.eid) && sp.boxInView(&n.blo, &n.bhi) {
+			parts = append(parts, app.povs.index[n.eid])
+		}
+	})
+	for eid, scene := range sp.loose {
+		if scene == sc.eid && !culled(app.povs, eid) {
+			parts = append(parts, app.povs.index[eid])
+		}
+	}
+	slices.Sort(parts)
+	return parts
+}
+
+// setPlanes sets the camera view planes from the camera view and
+// projection matrixes. Points are in view when they are on the positive
+// side of all the planes. Based on "Fast Extraction of Viewing Frustum
+// Planes from the World-View-Projection Matrix", Gribb and Hartmann.
+func (sp *spatial) setPlanes(cam *Camera) {
+	m := sp.vp.Mult(cam.vm, cam.pm) // row vectors: view then projection.
+	x := lin.V4{X: m.Xx, Y: m.Yx, Z: m.Zx, W: m.Wx}
+	y := lin.V4{X: m.Xy, Y: m.Yy, Z: m.Zy, W: m.Wy}
+	z := lin.V4{X: m.Xz, Y: m.Yz, Z: m.Zz, W: m.Wz}
+	w := lin.V4{X: m.Xw, Y: m.Yw, Z: m.Zw, W: m.Ww}
+	sp.planes[0].Add(&w, &x) // left
+	sp.planes[1].Sub(&w, &x) // right
+	sp.planes[2].Add(&w, &y) // bottom
+	sp.planes[3].Sub(&w, &y) // top
+	sp.planes[4] = z         // near: vulkan depth is 0 to 1.
+	sp.planes[5].Sub(&w, &z) // far
+}
+
+// boxInView returns false if the box is completely outside a camera
+// view plane. Boxes near the view corners may be in view when they
+// are not, which is fine for culling.
+func (sp *spatial) boxInView(lo, hi *lin.V3) bool {
+	for i := range sp.planes {
+		p := &sp.planes[i]
+		x, y, z := lo.X, lo.Y, lo.Z // box corner furthest along the plane normal.
+		if p.X > 0 {
+			x = hi.X
+		}
+		if p.Y > 0 {
+			y = hi.Y
+		}
+		if p.Z > 0 {
+			z = hi.Z
+		}
+		if p.X*x+p.Y*y+p.Z*z+p.W < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// near appends the scene parts whose bounds are within radius of at.
+func (sp *spatial) near(app *application, scene eID, at lin.V3, radius float64, found []*Entity) []*Entity {
+	if radius < 0 {
+		return found
+	}
+	sp.update(app)
+	r2 := radius * radius
+	inRange := func(lo, hi *lin.V3) bool {
+		dx := max(lo.X-at.X, 0, at.X-hi.X)
+		dy := max(lo.Y-at.Y, 0, at.Y-hi.Y)
+		dz := max(lo.Z-at.Z, 0, at.Z-hi.Z)
+		return dx*dx+dy*dy+dz*dz <= r2
+	}
+	sp.tree.query(inRange, func(n *bvhNode) {
+		if n.scene == scene && !culled(app.povs, n.eid) && inRange(&n.blo, &n.bhi) {
+			found = append(found, &Entity{app: app, eid: n.eid})
+		}
+	})
+	return found
+}
+
+// pick returns the closest scene part whose bounds are hit by the ray.
+func (sp *spatial) pick(app *application, scene eID, origin, dir lin.V3) (eid eID, distance float64, ok bool) {
+	sp.update(app)
+	best := math.Inf(1)
+	sp.tree.query(func(lo, hi *lin.V3) bool {
+		t, hit := rayBox(&origin, &dir, lo, hi)
+		return hit && t < best
+	}, func(n *bvhNode) {
+		if n.scene != scene || culled(app.povs, n.eid) {
+			return
+		}
+		if t, hit := rayBox(&origin, &dir, &n.blo, &n.bhi); hit && t < best {
+			best, eid, ok = t, n.eid, true
+		}
+	})
+	return eid, best, ok
+}
+
+// rayBox returns the distance along the ray to where it enters the box.
+// Rays starting inside the box hit at zero distance.
+func rayBox(origin, dir, lo, hi *lin.V3) (t float64, hit bool) {
+	tmin, tmax := 0.0, math.Inf(1)
+	slab := func(o, d, lo, hi float64) bool {
+		if math.Abs(d) < lin.Epsilon {
+			return o >= lo && o <= hi // parallel to the slab.
+		}
+		t0, t1 := (lo-o)/d, (hi-o)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tmin, tmax = max(tmin, t0), min(tmax, t1)
+		return tmin <= tmax
+	}
+	if !slab(origin.X, dir.X, lo.X, hi.X) || !slab(origin.Y, dir.Y, lo.Y, hi.Y) || !slab(origin.Z, dir.Z, lo.Z, hi.Z) {
+		return 0, false
+	}
+	return tmin, true
+}
+
+// sceneRoot returns the scene graph root for the given entity.
+func sceneRoot(povs *povs, eid eID) (root eID) {
+	for id := eid; id != 0; {
+		n := povs.getNode(id)
+		if n == nil {
+			break
+		}
+		root, id = id, n.parent
+	}
+	return root
+}
+
+// culled returns true if the entity or any of its parents are culled.
+func culled(povs *povs, eid eID) bool {
+	for id := eid; id != 0; {
+		n := povs.getNode(id)
+		if n == nil {
+			return false
+		}
+		if n.cull {
+			return true
+		}
+		id = n.parent
+	}
+	return false
+}
+
+// boxContains returns true if box lo:hi contains box ilo:ihi.
+func boxContains(lo, hi, ilo, ihi *lin.V3) bool {
+	return lo.X <= ilo.X && lo.Y <= ilo.Y && lo.Z <= ilo.Z &&
+		hi.X >= ihi.X && hi.Y >= ihi.Y && hi.Z >= ihi.Z
+}
+
+// fatten returns the hierarchy bounds for the part bounds lo:hi.
+func fatten(lo, hi lin.V3) (flo, fhi lin.V3) {
+	return lin.V3{X: lo.X - spatialFat, Y: lo.Y - spatialFat, Z: lo.Z - spatialFat},
+		lin.V3{X: hi.X + spatialFat, Y: hi.Y + spatialFat, Z: hi.Z + spatialFat}
+}
+
+// boxArea returns the surface area of box lo:hi.
+func boxArea(lo, hi *lin.V3) float64 {
+	dx, dy, dz := hi.X-lo.X, hi.Y-lo.Y, hi.Z-lo.Z
+	return 2 * (dx*dy + dy*dz + dz*dx)
+}
+
+// =============================================================================
+// bounding volume hierarchy.
+
+// bvh is a dynamic bounding volume hierarchy where each leaf bounds
+// one part. Based on the dynamic tree from Box2D, Erin Catto.
+type bvh struct {
+	nodes []bvhNode
+	root  int32   // root node, -1 if the hierarchy is empty.
+	free  int32   // first unused node, -1 if there are none.
+	stack []int32 // scratch for queries.
+}
+
+// bvhNode bounds its two children or a part.
+type bvhNode struct {
+	lo, hi   lin.V3 // hierarchy bounds.
+	blo, bhi lin.V3 // leaf part bounds.
+	parent   int32  // parent node, or next free node.
+	left     int32  // first child node, -1 for a leaf.
+	right    int32  // second child node, -1 for a leaf.
+	height   int32  // 0 for leaves, -1 for unused nodes.
+	eid      eID    // leaf part.
+	scene    eID    // leaf part scene.
+}
+
+// alloc returns an unused node, reusing freed nodes.
+func (t *bvh) alloc() int32 {
+	if t.free < 0 {
+		t.nodes = append(t.nodes, bvhNode{parent: -1})
+		t.free = int32(len(t.nodes) - 1)
+	}
+	index := t.free
+	t.free = t.nodes[index].parent
+	t.nodes[index] = bvhNode{parent: -1, left: -1, right: -1}
+	return index
+}
+
+// freeNode returns a node to the unused nodes.
+func (t *bvh) freeNode(index int32) {
+	t.nodes[index] = bvhNode{parent: t.free, left: -1, right: -1, height: -1}
+	t.free = index
+}
+
+// insert adds a leaf for the part bounds lo:hi.
+func (t *bvh) insert(lo, hi lin.V3, eid, scene eID) int32 {
+	leaf := t.alloc()
+	n := &t.nodes[leaf]
+	n.blo, n.bhi = lo, hi
+	n.lo, n.hi = fatten(lo, hi)
+	n.eid, n.scene = eid, scene
+	t.insertLeaf(leaf)
+	return leaf
+}
+
+// insertLeaf adds the leaf next to the sibling that increases
+// the hierarchy surface area the least.
+func (t *bvh) insertLeaf(leaf int32) {
+	if t.root < 0 {
+		t.root = leaf
+		t.nodes[leaf].parent = -1
+		return
+	}
+
+	// find the best sibling.
+	lo, hi := t.nodes[leaf].lo, t.nodes[leaf].hi
+	index := t.root
+	for t.nodes[index].left >= 0 {
+		n := &t.nodes[index]
+		area := boxArea(&n.lo, &n.hi)
+		combined := boxArea(lin.NewV3().Min(&n.lo, &lo), lin.NewV3().Max(&n.hi, &hi))
+		cost := 2 * combined                 // new parent for this node and the leaf.
+		inheritance := 2 * (combined - area) // minimum cost of pushing the leaf further down.
+		left := t.descendCost(n.left, &lo, &hi) + inheritance
+		right := t.descendCost(n.right, &lo, &hi) + inheritance
+		if cost < left && cost < right {
+			break
+		}
+		index = n.left
+		if right < left {
+			index = n.right
+		}
+	}
+
+	// create a new parent for the sibling and the leaf.
+	sibling := index
+	parent := t.alloc()
+	oldParent := t.nodes[sibling].parent
+	p, s := &t.nodes[parent], &t.nodes[sibling]
+	p.parent, p.left, p.right, p.height = oldParent, sibling, leaf, s.height+1
+	p.lo.Min(&s.lo, &lo)
+	p.hi.Max(&s.hi, &hi)
+	s.parent, t.nodes[leaf].parent = parent, parent
+	if oldParent < 0 {
+		t.root = parent
+	} else if op := &t.nodes[oldParent]; op.left == sibling {
+		op.left = parent
+	} else {
+		op.right = parent
+	}
+	t.refit(parent)
+}
+
+// descendCost returns the increase in surface area from adding
+// the bounds lo:hi below the node.
+func (t *bvh) descendCost(index int32, lo, hi *lin.V3) float64 {
+	n := &t.nodes[index]
+	area := boxArea(lin.NewV3().Min(&n.lo, lo), lin.NewV3().Max(&n.hi, hi))
+	if n.left < 0 {
+		return area
+	}
+	return area - boxArea(&n.lo, &n.hi)
+}
+
+// removeLeaf takes the leaf out of the hierarchy. The leaf node
+// is not freed so that it can be inserted again.
+func (t *bvh) removeLeaf(leaf int32) {
+	if leaf == t.root {
+		t.root = -1
+		return
+	}
+	parent := t.nodes[leaf].parent
+	grandParent := t.nodes[parent].parent
+	sibling := t.nodes[parent].left
+	if sibling == leaf {
+		sibling = t.nodes[parent].right
+	}
+	t.nodes[sibling].parent = grandParent
+	t.freeNode(parent)
+	if grandParent < 0 {
+		t.root = sibling
+		return
+	}
+	if gp := &t.nodes[grandParent]; gp.left == parent {
+		gp.left = sibling
+	} else {
+		gp.right = sibling
+	}
+	t.refit(grandParent)
+}
+
+// refit rebalances and updates the bounds and heights
+// of the node and its ancestors.
+func (t *bvh) refit(index int32) {
+	for index >= 0 {
+		index = t.balance(index)
+		n := &t.nodes[index]
+		l, r := &t.nodes[n.left], &t.nodes[n.right]
+		n.height = 1 + max(l.height, r.height)
+		n.lo.Min(&l.lo, &r.lo)
+		n.hi.Max(&l.hi, &r.hi)
+		index = n.parent
+	}
+}
+
+// balance rotates the taller grandchild of node a up when its children
+// heights differ by more than one. Returns the node that replaced a.
+func (t *bvh) balance(a int32) int32 {
+	na := &t.nodes[a]
+	if na.left < 0 || na.height < 2 {
+		return a
+	}
+	b, c := na.left, na.right
+	switch balance := t.nodes[c].height - t.nodes[b].height; {
+	case balance > 1:
+		t.rotate(a, c, b, false)
+		return c
+	case balance < -1:
+		t.rotate(a, b, c, true)
+		return b
+	}
+	return a
+}
+
+// rotate moves the tall child of node a up to replace a. The
+// shorter grandchild of the tall child replaces it as a child of a.
+// The short child of a stays where it is.
+func (t *bvh) rotate(a, tall, short int32, tallIsLeft bool) {
+	na, nt := &t.nodes[a], &t.nodes[tall]
+	f, g := nt.left, nt.right
+
+	// tall replaces a.
+	nt.left, nt.parent, na.parent = a, na.parent, tall
+	if nt.parent < 0 {
+		t.root = tall
+	} else if np := &t.nodes[nt.parent]; np.left == a {
+		np.left = tall
+	} else {
+		np.right = tall
+	}
+
+	// a keeps the shorter grandchild.
+	keep, move := f, g
+	if t.nodes[g].height > t.nodes[f].height {
+		keep, move = g, f
+	}
+	nt.right = keep
+	if tallIsLeft {
+		na.left = move
+	} else {
+		na.right = move
+	}
+	t.nodes[move].parent = a
+	ns, nm, nk := &t.nodes[short], &t.nodes[move], &t.nodes[keep]
+	na.lo.Min(&ns.lo, &nm.lo)
+	na.hi.Max(&ns.hi, &nm.hi)
+	na.height = 1 + max(ns.height, nm.height)
+	nt.lo.Min(&na.lo, &nk.lo)
+	nt.hi.Max(&na.hi, &nk.hi)
+	nt.height = 1 + max(na.height, nk.height)
+}
+
+// query calls leaf with each leaf whose hierarchy bounds pass the
+// overlaps test. Branches whose bounds fail the test are skipped.
+func (t *bvh) query(overlaps func(lo, hi *lin.V3) bool, leaf func(n *bvhNode)) {
+	if t.root < 0 {
+		return
+	}
+	t.stack = append(t.stack[:0], t.root)
+	for len(t.stack) > 0 {
+		n := &t.nodes[t.stack[len(t.stack)-1]]
+		t.stack = t.stack[:len(t.stack)-1]
+		if !overlaps(&n.lo, &n.hi) {
+			continue
+		}
+		if n.left < 0 {
+			leaf(n)
+			continue
+		}
+		t.stack = append(t.stack, n.left, n.right)
+	}
+}
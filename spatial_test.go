@@ -0,0 +1,117 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+)
+
+// go test -run Spatial
+func TestSpatial(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	eng := &Engine{app: app}
+	scene := app.addScene(Scene3D)
+	sc := app.scenes.get(scene.eid)
+	sc.setProjection(800, 600)
+	sc.cam.updateView() // at the origin looking down -Z.
+
+	ahead := scene.AddModel("msh:cube").SetAt(0, 0, -10)
+	behind := scene.AddModel("msh:cube").SetAt(0, 0, 10)
+	aside := scene.AddModel("msh:cube").SetAt(100, 0, -10)
+	beyond := scene.AddModel("msh:cube").SetAt(0, 0, -2000)
+	edge := scene.AddModel("msh:cube").SetAt(14, 0, -10).SetScale(2, 2, 2)      // overlaps the view edge at x=13.33.
+	outside := scene.AddModel("msh:cube").SetAt(16.5, 0, -10).SetScale(2, 2, 2) // outside the view edge at x=14.67.
+	inView := func() map[eID]bool {
+		found := map[eID]bool{}
+		for _, e := range eng.PartsInView(scene, nil) {
+			found[e.eid] = true
+		}
+		return found
+	}
+
+	// go test -run Spatial/view
+	t.Run("view", func(t *testing.T) {
+		found := inView()
+		if !found[ahead.eid] || !found[edge.eid] || found[outside.eid] || found[behind.eid] || found[aside.eid] || found[beyond.eid] {
+			t.Errorf("expected parts ahead got %v", found)
+		}
+		if len(app.spatial.leaves) != 6 || len(app.spatial.loose) != 0 {
+			t.Errorf("expected parts in hierarchy got %d %d", len(app.spatial.leaves), len(app.spatial.loose))
+		}
+
+		// moved and culled parts.
+		aside.SetAt(1, 0, -10)
+		ahead.Cull(true)
+		found = inView()
+		if !found[aside.eid] || found[ahead.eid] {
+			t.Errorf("expected moved part in view got %v", found)
+		}
+		ahead.Cull(false)
+
+		// parts that are not culled are always in view.
+		behind.SetViewCull(false)
+		if found = inView(); !found[behind.eid] {
+			t.Errorf("expected part without view cull got %v", found)
+		}
+		behind.SetViewCull(true)
+	})
+
+	// go test -run Spatial/near
+	t.Run("near", func(t *testing.T) {
+		found := eng.PartsNear(scene, 0, 0, -8, 2, nil)
+		if len(found) != 2 {
+			t.Fatalf("expected 2 parts near got %d", len(found))
+		}
+		for _, e := range found {
+			if e.eid != ahead.eid && e.eid != aside.eid {
+				t.Errorf("unexpected part %d", e.eid)
+			}
+		}
+		if found = eng.PartsNear(scene, 0, 0, 500, 1, found); len(found) != 0 {
+			t.Errorf("expected no parts got %d", len(found))
+		}
+	})
+
+	// go test -run Spatial/pick
+	t.Run("pick", func(t *testing.T) {
+		part, dist, ok := eng.PickPart(scene, 0, 0, 0, 0, 0, -1)
+		if !ok || part.eid != ahead.eid || dist != 9.5 {
+			t.Errorf("expected closest part got %v %f %t", part, dist, ok)
+		}
+		if _, _, ok := eng.PickPart(scene, 0, 5, 0, 0, 0, -1); ok {
+			t.Errorf("expected miss")
+		}
+	})
+
+	// go test -run Spatial/dispose
+	t.Run("dispose", func(t *testing.T) {
+		for _, e := range []*Entity{ahead, behind, aside, beyond, edge, outside} {
+			app.dispose(eng, e.eid)
+		}
+		if len(app.spatial.leaves) != 0 || app.spatial.tree.root != -1 || len(inView()) != 0 {
+			t.Errorf("expected empty hierarchy")
+		}
+	})
+
+	// go test -run Spatial/tree
+	t.Run("tree", func(t *testing.T) {
+		parts := []*Entity{}
+		for i := 0; i < 200; i++ {
+			parts = append(parts, scene.AddModel("msh:cube").SetAt(float64(i%20)*3, 0, -float64(i/20)*3-5))
+		}
+		if found := eng.PartsNear(scene, 0, 0, -5, 0.6, nil); len(found) != 1 || found[0].eid != parts[0].eid {
+			t.Fatalf("expected first part got %v", found)
+		}
+		if h := app.spatial.tree.nodes[app.spatial.tree.root].height; h > 16 {
+			t.Errorf("expected balanced tree got height %d", h)
+		}
+		for _, e := range parts {
+			app.dispose(eng, e.eid)
+		}
+		if app.spatial.tree.root != -1 {
+			t.Errorf("expected empty tree")
+		}
+	})
+}
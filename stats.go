@@ -0,0 +1,187 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// stats.go reports where the frame time goes without the need
+// for an external profiler, eg:
+//
+//	stats := eng.Stats()  // get the last frame statistics, or
+//	eng.ShowStats(true)   // show them in an overlay each frame.
+//
+// GPU time can be broken down further by grouping models into named
+// GPU profile scopes, eg:
+//
+//	terrain.SetGPUScope("terrain")
+//	times = eng.GPUTimes(times) // includes "terrain", "3D", and "2D".
+//
+// The frame graph shows the render passes and the render targets
+// they use, eg:
+//
+//	dot := eng.FrameGraph().DOT() // graphviz description, or
+//	eng.ShowFrameGraph(true)      // show pass order and times each frame.
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gazed/vu/render"
+)
+
+// Stats are the engine statistics for a single frame.
+type Stats struct {
+	Frame  time.Duration // time between frames.
+	Update time.Duration // CPU time for physics, app updates, and asset loading.
+	Render time.Duration // CPU time to generate and submit the render frame.
+	GPU    time.Duration // GPU render time, a few frames late. Zero if not supported.
+
+	// render statistics.
+	DrawCalls    int // number of draw commands.
+	Triangles    int // triangles drawn, including instances.
+	ShaderBinds  int // number of shader changes.
+	TextureBinds int // number of material texture binds.
+
+	// resource usage.
+	GPUMemory uint64 // GPU memory allocated by the renderer.
+}
+
+// Stats returns the statistics for the last rendered frame.
+func (eng *Engine) Stats() Stats { return eng.stats }
+
+// ShowStats shows or hides an overlay with the frame statistics.
+// The overlay is drawn using debug text which requires a font,
+// see Debug.SetFont.
+func (eng *Engine) ShowStats(show bool) { eng.showStats = show }
+
+// FrameGraph returns the render passes and render targets used to draw
+// the last frame. Use FrameGraph.JSON or FrameGraph.DOT to export it.
+func (eng *Engine) FrameGraph() render.FrameGraph { return eng.rc.FrameGraph() }
+
+// ShowFrameGraph shows or hides an overlay with the render pass order,
+// draw calls, and GPU times. The overlay is drawn using debug text,
+// see ShowStats.
+func (eng *Engine) ShowFrameGraph(show bool) { eng.showGraph = show }
+
+// updateStats records the statistics for a rendered frame.
+func (eng *Engine) updateStats(frame, update, render time.Duration) {
+	rs := eng.rc.Stats()
+	eng.stats = Stats{
+		Frame:        frame,
+		Update:       update,
+		Render:       render,
+		GPU:          rs.GPU,
+		DrawCalls:    rs.DrawCalls,
+		Triangles:    rs.Triangles,
+		ShaderBinds:  rs.ShaderBinds,
+		TextureBinds: rs.TextureBinds,
+		GPUMemory:    rs.Memory,
+	}
+}
+
+// SetGPUScope times the model draws as part of the named GPU profile
+// scope. Models without a scope are timed as part of the "3D" or "2D"
+// scope for their scene. An empty name removes the model scope.
+// Up to 29 application scopes can be created.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) SetGPUScope(name string) *Entity {
+	m := e.app.models.get(e.eid)
+	if m == nil {
+		slog.Error("SetGPUScope needs AddModel", "eid", e.eid)
+		return e
+	}
+	m.scope = e.app.gpuScope(name)
+	return e
+}
+
+// GPUTimes returns the GPU time for each GPU profile scope. The scope
+// times are added to the given map after it is cleared so that the
+// memory can be reused. Times are from a frame rendered a few frames
+// ago, since the GPU renders behind the CPU.
+func (eng *Engine) GPUTimes(times map[string]time.Duration) map[string]time.Duration {
+	if times == nil {
+		times = map[string]time.Duration{}
+	}
+	clear(times)
+	eng.gpuTimes = eng.rc.GPUTimes(eng.gpuTimes)
+	for scope, name := range eng.app.gpuScopeNames() {
+		if name != "" {
+			times[name] = eng.gpuTimes[scope]
+		}
+	}
+	return times
+}
+
+// gpuScope returns the GPU profile scope for the given name.
+// New scope names are added as needed.
+func (app *application) gpuScope(name string) uint8 {
+	for scope, scopeName := range app.gpuScopeNames() {
+		if name == scopeName {
+			return uint8(scope)
+		}
+	}
+	if int(render.ScopeApp)+len(app.gpuScopes) >= render.MaxGPUScopes {
+		slog.Error("too many GPU scopes", "scope", name)
+		return 0 // use the render pass scope.
+	}
+	app.gpuScopes = append(app.gpuScopes, name)
+	return render.ScopeApp + uint8(len(app.gpuScopes)-1)
+}
+
+// gpuScopeNames returns the GPU profile scope names indexed by scope.
+// Scope zero is not reported and has no name.
+func (app *application) gpuScopeNames() []string {
+	names := []string{render.Scope3D: "3D", render.Scope2D: "2D"}
+	return append(names, app.gpuScopes...)
+}
+
+// drawStats adds the statistics and frame graph overlays to the
+// debug text. The statistics include the GPU profile scope times.
+func (eng *Engine) drawStats() {
+	sb := &strings.Builder{}
+	if eng.showStats {
+		sb.WriteString(eng.stats.String())
+		eng.gpuTimes = eng.rc.GPUTimes(eng.gpuTimes)
+		for scope, name := range eng.app.gpuScopeNames() {
+			if name != "" && eng.gpuTimes[scope] > 0 {
+				ms := float64(eng.gpuTimes[scope]) / float64(time.Millisecond)
+				fmt.Fprintf(sb, "\n%s %.2fms", name, ms)
+			}
+		}
+	}
+	if eng.showGraph {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(graphString(eng.rc.FrameGraph()))
+	}
+	eng.Debug().Text(10, 10, sb.String())
+}
+
+// graphString formats the frame graph as one line per render pass
+// showing the pass order, the pass targets, and the pass times.
+func graphString(g render.FrameGraph) string {
+	lines := make([]string, len(g.Passes))
+	for i, p := range g.Passes {
+		ms := float64(p.GPU) / float64(time.Millisecond)
+		lines[i] = fmt.Sprintf("%d %s %.2fms draws %d", i+1, p.Name, ms, p.DrawCalls)
+		if len(p.Reads) > 0 {
+			lines[i] += " reads " + strings.Join(p.Reads, ",")
+		}
+		lines[i] += " writes " + strings.Join(p.Writes, ",")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String formats the statistics as a few lines of text.
+func (s Stats) String() string {
+	fps := 0.0
+	if s.Frame > 0 {
+		fps = float64(time.Second) / float64(s.Frame)
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return fmt.Sprintf("fps %.0f frame %.2fms\nupdate %.2fms render %.2fms gpu %.2fms\ndraws %d triangles %d\nshaders %d textures %d",
+		fps, ms(s.Frame), ms(s.Update), ms(s.Render), ms(s.GPU),
+		s.DrawCalls, s.Triangles, s.ShaderBinds, s.TextureBinds)
+}
@@ -0,0 +1,256 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// tag.go labels entities with application defined tags so that groups
+// of entities can be found without scanning all entities, eg:
+//
+//	enemy.Tag("enemy", "flying")
+//	near := eng.TaggedNear("enemy", x, y, z, 10, near)
+//
+// Tagged entities with a transform are kept in a spatial grid that is
+// updated as the entities move so that nearby queries only check the
+// entities in the grid cells that overlap the query radius.
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+)
+
+// Tag adds one or more tags to the entity. Tags are application
+// defined strings like "enemy" or "pickup". Numeric tags can be
+// created using strconv.Itoa. Adding an existing tag is ignored.
+func (e *Entity) Tag(tags ...string) *Entity {
+	if !e.Exists() {
+		slog.Error("Tag needs entity", "eid", e.eid)
+		return e
+	}
+	e.app.tags.add(e.app.povs, e.eid, tags...)
+	return e
+}
+
+// Untag removes one or more tags from the entity.
+// Removing a tag that is not on the entity is ignored.
+func (e *Entity) Untag(tags ...string) *Entity {
+	e.app.tags.remove(e.app.povs, e.eid, tags...)
+	return e
+}
+
+// HasTag returns true if the entity has the given tag.
+func (e *Entity) HasTag(tag string) bool {
+	return slices.Contains(e.app.tags.ents[e.eid], tag)
+}
+
+// Tags returns the tags for the entity in the order they were added.
+// The returned tags must not be changed.
+func (e *Entity) Tags() []string {
+	return e.app.tags.ents[e.eid]
+}
+
+// Tagged returns all the entities that have the given tag. The found
+// entities are appended to the given slice after it is reset
+// so that the memory can be reused between queries.
+func (eng *Engine) Tagged(tag string, found []*Entity) []*Entity {
+	return eng.app.tags.tagged(eng.app, tag, found[:0])
+}
+
+// TaggedNear returns the entities with the given tag whose world
+// location is within the given radius of the given world location.
+// Only tagged entities with transforms are considered.
+// The found entities are appended to the given slice after it is
+// reset so that the memory can be reused between queries.
+func (eng *Engine) TaggedNear(tag string, x, y, z, radius float64, found []*Entity) []*Entity {
+	return eng.app.tags.near(eng.app, tag, x, y, z, radius, found[:0])
+}
+
+// =============================================================================
+// tags component manager.
+
+// tagCellSize is the width of the spatial grid cells in world units.
+// Query costs are based on the number of tagged entities in the
+// overlapped cells, so the size should be close to the common
+// query radius.
+const tagCellSize = 16.0
+
+// gridCell is a spatial grid location.
+type gridCell [3]int32
+
+// tags is the tag component manager. It tracks the tags for each entity
+// and an index from each tag to its entities.
+type tags struct {
+	ents  map[eID][]string   // Tags for each entity.
+	index map[string]*tagged // Entities for each tag.
+	cells map[eID]gridCell   // Grid location for tagged entities with transforms.
+}
+
+// tagged holds the entities for a single tag.
+type tagged struct {
+	eids  []eID              // Tagged entities in the order they were tagged.
+	cells map[gridCell][]eID // Spatial grid for tagged entities with transforms.
+}
+
+// newTags creates the tag component manager.
+// There is only expected to be once instance created by the engine.
+func newTags() *tags {
+	return &tags{
+		ents:  map[eID][]string{},
+		index: map[string]*tagged{},
+		cells: map[eID]gridCell{},
+	}
+}
+
+// cellAt returns the grid cell for the given world location.
+func cellAt(x, y, z float64) gridCell {
+	return gridCell{
+		int32(math.Floor(x / tagCellSize)),
+		int32(math.Floor(y / tagCellSize)),
+		int32(math.Floor(z / tagCellSize)),
+	}
+}
+
+// add the given tags to an entity. Entities with transforms are tracked
+// in the spatial grid and flagged so that moves are reported.
+func (ts *tags) add(povs *povs, eid eID, tags ...string) {
+	for _, tag := range tags {
+		if slices.Contains(ts.ents[eid], tag) {
+			continue // already tagged.
+		}
+		ts.ents[eid] = append(ts.ents[eid], tag)
+		t, ok := ts.index[tag]
+		if !ok {
+			t = &tagged{cells: map[gridCell][]eID{}}
+			ts.index[tag] = t
+		}
+		t.eids = append(t.eids, eid)
+		if p := povs.get(eid); p != nil {
+			p.tagged = true
+			cell := cellAt(p.world())
+			ts.cells[eid] = cell
+			t.cells[cell] = append(t.cells[cell], eid)
+		}
+	}
+}
+
+// remove the given tags from an entity. The entity is no longer
+// tracked once all of its tags are removed.
+func (ts *tags) remove(povs *povs, eid eID, tags ...string) {
+	cell, hasCell := ts.cells[eid]
+	for _, tag := range tags {
+		i := slices.Index(ts.ents[eid], tag)
+		if i < 0 {
+			continue // not tagged.
+		}
+		ts.ents[eid] = slices.Delete(ts.ents[eid], i, i+1)
+		t := ts.index[tag]
+		t.eids = slices.DeleteFunc(t.eids, func(id eID) bool { return id == eid })
+		if hasCell {
+			t.removeCell(cell, eid)
+		}
+		if len(t.eids) == 0 {
+			delete(ts.index, tag)
+		}
+	}
+	if len(ts.ents[eid]) == 0 {
+		delete(ts.ents, eid)
+		delete(ts.cells, eid)
+		if p := povs.get(eid); p != nil {
+			p.tagged = false
+		}
+	}
+}
+
+// dispose removes all tags for a disposed entity.
+func (ts *tags) dispose(povs *povs, eid eID) {
+	if tags, ok := ts.ents[eid]; ok {
+		ts.remove(povs, eid, slices.Clone(tags)...)
+	}
+}
+
+// removeCell removes an entity from a spatial grid cell.
+func (t *tagged) removeCell(cell gridCell, eid eID) {
+	eids := slices.DeleteFunc(t.cells[cell], func(id eID) bool { return id == eid })
+	if len(eids) == 0 {
+		delete(t.cells, cell)
+		return
+	}
+	t.cells[cell] = eids
+}
+
+// update moves the tagged entities that have changed location to
+// their new spatial grid cells. The moved entities are reported by
+// the transform manager. Called before each query and each update
+// so that the moved list does not grow.
+func (ts *tags) update(povs *povs) {
+	for _, eid := range povs.tagMoves {
+		old, ok := ts.cells[eid]
+		p := povs.get(eid)
+		if !ok || p == nil {
+			continue // untagged or disposed after moving.
+		}
+		cell := cellAt(p.world())
+		if cell == old {
+			continue
+		}
+		ts.cells[eid] = cell
+		for _, tag := range ts.ents[eid] {
+			t := ts.index[tag]
+			t.removeCell(old, eid)
+			t.cells[cell] = append(t.cells[cell], eid)
+		}
+	}
+	povs.tagMoves = povs.tagMoves[:0]
+}
+
+// tagged appends the entities with the given tag.
+func (ts *tags) tagged(app *application, tag string, found []*Entity) []*Entity {
+	if t, ok := ts.index[tag]; ok {
+		for _, eid := range t.eids {
+			found = append(found, &Entity{app: app, eid: eid})
+		}
+	}
+	return found
+}
+
+// near appends the entities with the given tag within
+// radius of the given world location.
+func (ts *tags) near(app *application, tag string, x, y, z, radius float64, found []*Entity) []*Entity {
+	t, ok := ts.index[tag]
+	if !ok || radius < 0 {
+		return found
+	}
+	ts.update(app.povs)
+
+	// check if a given entity is in range.
+	r2 := radius * radius
+	inRange := func(eid eID) {
+		if p := app.povs.get(eid); p != nil {
+			wx, wy, wz := p.world()
+			dx, dy, dz := wx-x, wy-y, wz-z
+			if dx*dx+dy*dy+dz*dz <= r2 {
+				found = append(found, &Entity{app: app, eid: eid})
+			}
+		}
+	}
+
+	// check all tagged entities when the query covers more cells than
+	// there are tagged entities. Otherwise only check overlapped cells.
+	span := math.Floor((radius*2)/tagCellSize) + 2 // cells per axis.
+	if span*span*span >= float64(len(t.eids)) {
+		for _, eid := range t.eids {
+			inRange(eid)
+		}
+		return found
+	}
+	lo, hi := cellAt(x-radius, y-radius, z-radius), cellAt(x+radius, y+radius, z+radius)
+	for cx := lo[0]; cx <= hi[0]; cx++ {
+		for cy := lo[1]; cy <= hi[1]; cy++ {
+			for cz := lo[2]; cz <= hi[2]; cz++ {
+				for _, eid := range t.cells[gridCell{cx, cy, cz}] {
+					inRange(eid)
+				}
+			}
+		}
+	}
+	return found
+}
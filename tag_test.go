@@ -0,0 +1,77 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+)
+
+// go test -run Tag
+func TestTag(t *testing.T) {
+	app := &application{eids: &entities{}, povs: newPovs(), tags: newTags()}
+	root := &Entity{app: app, eid: app.eids.create()}
+	app.povs.create(root.eid, 0)
+	a := root.AddPart().SetAt(1, 0, 0).Tag("enemy", "flying")
+	b := root.AddPart().SetAt(50, 0, 0).Tag("enemy")
+	c := root.AddPart().SetAt(2, 0, 0).Tag("pickup", "pickup")
+
+	t.Run("tags", func(t *testing.T) {
+		if !a.HasTag("flying") || b.HasTag("flying") || len(c.Tags()) != 1 {
+			t.Errorf("unexpected tags %v %v %v", a.Tags(), b.Tags(), c.Tags())
+		}
+		if found := app.tags.tagged(app, "enemy", nil); len(found) != 2 || found[0].eid != a.eid {
+			t.Errorf("expected 2 enemies got %d", len(found))
+		}
+	})
+	t.Run("near", func(t *testing.T) {
+		found := app.tags.near(app, "enemy", 0, 0, 0, 10, nil)
+		if len(found) != 1 || found[0].eid != a.eid {
+			t.Errorf("expected 1 near enemy got %d", len(found))
+		}
+		if found = app.tags.near(app, "enemy", 0, 0, 0, 100, nil); len(found) != 2 {
+			t.Errorf("expected 2 enemies in large radius got %d", len(found))
+		}
+	})
+	t.Run("move", func(t *testing.T) {
+		b.SetAt(3, 0, 0)
+		if found := app.tags.near(app, "enemy", 0, 0, 0, 10, nil); len(found) != 2 {
+			t.Errorf("expected moved enemy to be near got %d", len(found))
+		}
+		root.SetAt(100, 0, 0) // moving a parent moves the tagged children.
+		if found := app.tags.near(app, "enemy", 0, 0, 0, 10, nil); len(found) != 0 {
+			t.Errorf("expected no near enemies got %d", len(found))
+		}
+		if found := app.tags.near(app, "enemy", 100, 0, 0, 10, nil); len(found) != 2 {
+			t.Errorf("expected enemies at new location got %d", len(found))
+		}
+		if len(app.povs.tagMoves) != 0 {
+			t.Errorf("expected tag moves to be processed")
+		}
+	})
+	t.Run("grid", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			root.AddPart().SetAt(float64(i*20), 0, -100).Tag("crowd")
+		}
+		found := app.tags.near(app, "crowd", 300, 0, -100, 5, nil) // root is at 100,0,0
+		if len(found) != 1 {
+			t.Fatalf("expected 1 crowd entity got %d", len(found))
+		}
+		if x, _, _ := found[0].World(); x != 300 {
+			t.Errorf("expected crowd entity at 300 got %f", x)
+		}
+	})
+	t.Run("untag", func(t *testing.T) {
+		a.Untag("enemy", "missing")
+		if found := app.tags.tagged(app, "enemy", nil); len(found) != 1 || a.HasTag("enemy") {
+			t.Errorf("expected 1 enemy got %d", len(found))
+		}
+		a.Untag("flying")
+		if _, ok := app.tags.cells[a.eid]; ok || app.povs.get(a.eid).tagged {
+			t.Errorf("expected untagged entity to be removed from grid")
+		}
+		app.tags.dispose(app.povs, c.eid)
+		if len(c.Tags()) != 0 || app.tags.index["pickup"] != nil {
+			t.Errorf("expected disposed entity tags to be removed")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// telemetry.go lets applications route engine events to their own
+// analytics backend, eg:
+//
+//	eng.SetTelemetry(backend) // backend implements vu.Telemetry
+//
+// Telemetry is strictly opt-in. The engine collects nothing beyond its
+// normal frame statistics and sends nothing anywhere. Events are only
+// passed to the application Telemetry, which does nothing by default.
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Telemetry receives engine events. It is implemented by the user app
+// and set using eng.SetTelemetry.
+type Telemetry interface {
+	// Event is called on the engine goroutine. Implementations are
+	// expected to return quickly, eg: by queuing the event to be sent
+	// from another goroutine.
+	Event(ev TelemetryEvent)
+}
+
+// SetTelemetry sets the application telemetry. A nil telemetry
+// restores the default telemetry that ignores all events.
+func (eng *Engine) SetTelemetry(telemetry Telemetry) {
+	if telemetry == nil {
+		telemetry = noTelemetry{}
+	}
+	eng.telemetry = telemetry
+}
+
+// TelemetryKind identifies the telemetry event.
+type TelemetryKind int
+
+const (
+	SessionStart TelemetryKind = iota // Run loop started. Name is the window title.
+	SessionEnd                        // Run loop ended. Duration is the session length.
+	AssetsLoaded                      // ImportAssets files loaded. Name lists the files.
+	FrameTimes                        // Frame time histogram for the last Duration.
+	Crash                             // Run loop panic. Err and Stack are set.
+	AssetsFailed                      // ImportAssets files finished, some failed. Err has the failures.
+)
+
+// String returns the event kind name.
+func (k TelemetryKind) String() string {
+	switch k {
+	case SessionStart:
+		return "session_start"
+	case SessionEnd:
+		return "session_end"
+	case AssetsLoaded:
+		return "assets_loaded"
+	case FrameTimes:
+		return "frame_times"
+	case Crash:
+		return "crash"
+	case AssetsFailed:
+		return "assets_failed"
+	}
+	return fmt.Sprintf("telemetry_%d", int(k))
+}
+
+// TelemetryEvent is a structured engine event. Only the fields
+// for the given kind of event are set.
+type TelemetryEvent struct {
+	Kind     TelemetryKind // Type of event.
+	Time     time.Time     // When the event happened.
+	Name     string        // Window title or comma separated asset files.
+	Duration time.Duration // Session length, load time, or histogram time.
+	Frames   []int         // Frame counts for each FrameBuckets time.
+	Err      error         // Crash panic value or asset load errors.
+	Stack    []byte        // Crash goroutine stack trace.
+}
+
+// FrameBuckets are the upper frame time limits for each FrameTimes
+// histogram bucket. The last bucket counts the frames that were
+// slower than the last limit.
+var FrameBuckets = []time.Duration{
+	8 * time.Millisecond,   // 120+ fps.
+	17 * time.Millisecond,  // 60+ fps.
+	25 * time.Millisecond,  // 40+ fps.
+	34 * time.Millisecond,  // 30+ fps.
+	50 * time.Millisecond,  // 20+ fps.
+	100 * time.Millisecond, // 10+ fps.
+}
+
+// telemetryInterval is how often the frame time histogram is reported.
+const telemetryInterval = time.Minute
+
+// noTelemetry is the default telemetry. It ignores all events.
+type noTelemetry struct{}
+
+// Event implements Telemetry.
+func (noTelemetry) Event(ev TelemetryEvent) {}
+
+// =============================================================================
+// engine telemetry tracking.
+
+// telemetry tracks the data needed for telemetry events.
+type telemetry struct {
+	start   time.Time     // session start.
+	frames  []int         // frame time histogram.
+	since   time.Time     // start of the frame time histogram.
+	imports []assetImport // outstanding ImportAssets calls.
+}
+
+// assetImport is an ImportAssets request.
+type assetImport struct {
+	files []string  // requested asset files.
+	start time.Time // time of request.
+}
+
+// startSession resets the session telemetry
+// and reports the start of the session.
+func (eng *Engine) startSession(now time.Time) {
+	eng.tm.start, eng.tm.since = now, now
+	eng.tm.frames = make([]int, len(FrameBuckets)+1)
+	eng.telemetry.Event(TelemetryEvent{Kind: SessionStart, Time: now, Name: eng.title})
+}
+
+// endSession reports the remaining frame times
+// and the end of the session.
+func (eng *Engine) endSession(now time.Time) {
+	eng.reportFrames(now)
+	eng.telemetry.Event(TelemetryEvent{Kind: SessionEnd, Time: now, Duration: now.Sub(eng.tm.start)})
+}
+
+// crashed reports a run loop panic.
+func (eng *Engine) crashed(r any) {
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+	eng.telemetry.Event(TelemetryEvent{Kind: Crash, Time: time.Now(), Err: err, Stack: debug.Stack()})
+}
+
+// countFrame adds a frame time to the histogram,
+// reporting the histogram each telemetry interval.
+func (eng *Engine) countFrame(frame time.Duration, now time.Time) {
+	bucket := len(FrameBuckets)
+	for i, limit := range FrameBuckets {
+		if frame <= limit {
+			bucket = i
+			break
+		}
+	}
+	eng.tm.frames[bucket]++
+	if now.Sub(eng.tm.since) >= telemetryInterval {
+		eng.reportFrames(now)
+	}
+}
+
+// reportFrames reports and resets the frame time histogram.
+func (eng *Engine) reportFrames(now time.Time) {
+	total := 0
+	for _, count := range eng.tm.frames {
+		total += count
+	}
+	if total > 0 {
+		frames := append([]int{}, eng.tm.frames...)
+		eng.telemetry.Event(TelemetryEvent{Kind: FrameTimes, Time: now, Duration: now.Sub(eng.tm.since), Frames: frames})
+		clear(eng.tm.frames)
+	}
+	eng.tm.since = now
+}
+
+// checkImports reports the ImportAssets calls where all the asset
+// files have finished loading, or failed to load.
+func (eng *Engine) checkImports(now time.Time) {
+	pending := eng.tm.imports[:0]
+	for _, imp := range eng.tm.imports {
+		loaded := true
+		for _, file := range imp.files {
+			loaded = loaded && eng.app.ld.loaded[file]
+		}
+		if !loaded {
+			pending = append(pending, imp)
+			continue
+		}
+		errs := []error{}
+		for _, file := range imp.files {
+			if err := eng.app.ld.errs[file]; err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			}
+		}
+		ev := TelemetryEvent{Kind: AssetsLoaded, Time: now, Name: strings.Join(imp.files, ","), Duration: now.Sub(imp.start)}
+		if len(errs) > 0 {
+			ev.Kind, ev.Err = AssetsFailed, errors.Join(errs...)
+		}
+		eng.telemetry.Event(ev)
+	}
+	eng.tm.imports = pending
+}
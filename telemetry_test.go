@@ -0,0 +1,98 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// go test -run Telemetry
+func TestTelemetry(t *testing.T) {
+	rec := &recordTelemetry{}
+	eng := &Engine{app: newApplication(), title: "test"}
+	eng.SetTelemetry(rec)
+	now := time.Now()
+	eng.startSession(now)
+
+	t.Run("frames", func(t *testing.T) {
+		eng.countFrame(5*time.Millisecond, now)
+		eng.countFrame(16*time.Millisecond, now)
+		eng.countFrame(time.Second, now)
+		eng.countFrame(16*time.Millisecond, now.Add(telemetryInterval)) // report.
+		ev := rec.last(FrameTimes)
+		if ev == nil || ev.Duration != telemetryInterval {
+			t.Fatalf("expected a frame times event")
+		}
+		if ev.Frames[0] != 1 || ev.Frames[1] != 2 || ev.Frames[len(FrameBuckets)] != 1 {
+			t.Errorf("unexpected frame histogram %v", ev.Frames)
+		}
+	})
+	t.Run("imports", func(t *testing.T) {
+		eng.tm.imports = []assetImport{{files: []string{"a.png", "b.wav"}, start: now}}
+		eng.app.ld.loaded["a.png"] = true
+		eng.checkImports(now)
+		if rec.last(AssetsLoaded) != nil {
+			t.Errorf("expected to wait for b.wav")
+		}
+		eng.app.ld.loaded["b.wav"] = true
+		eng.checkImports(now.Add(time.Second))
+		if ev := rec.last(AssetsLoaded); ev == nil || ev.Name != "a.png,b.wav" || ev.Duration != time.Second {
+			t.Errorf("expected assets loaded event %+v", ev)
+		}
+		if len(eng.tm.imports) != 0 {
+			t.Errorf("expected no pending imports")
+		}
+	})
+	t.Run("failed imports", func(t *testing.T) {
+		eng.tm.imports = []assetImport{{files: []string{"c.png", "d.png"}, start: now}}
+		eng.app.ld.loaded["c.png"], eng.app.ld.loaded["d.png"] = true, true
+		eng.app.ld.errs["d.png"] = errors.New("missing")
+		eng.checkImports(now)
+		if ev := rec.last(AssetsFailed); ev == nil || ev.Name != "c.png,d.png" || ev.Err == nil {
+			t.Errorf("expected assets failed event %+v", ev)
+		}
+		if ev := rec.last(AssetsLoaded); ev == nil || ev.Name != "a.png,b.wav" {
+			t.Errorf("expected no assets loaded event for the failed import")
+		}
+	})
+	t.Run("crash", func(t *testing.T) {
+		eng.crashed(errors.New("boom"))
+		if ev := rec.last(Crash); ev == nil || ev.Err.Error() != "boom" || len(ev.Stack) == 0 {
+			t.Errorf("expected crash event %+v", ev)
+		}
+	})
+	t.Run("session", func(t *testing.T) {
+		eng.endSession(now.Add(time.Hour))
+		if ev := rec.last(SessionStart); ev == nil || ev.Name != "test" {
+			t.Errorf("expected session start event")
+		}
+		if ev := rec.last(SessionEnd); ev == nil || ev.Duration != time.Hour {
+			t.Errorf("expected session end event %+v", ev)
+		}
+	})
+	t.Run("default", func(t *testing.T) {
+		eng.SetTelemetry(nil)
+		eng.crashed("ignored") // no-op telemetry.
+		if _, ok := eng.telemetry.(noTelemetry); !ok {
+			t.Errorf("expected the default telemetry")
+		}
+	})
+}
+
+// recordTelemetry remembers telemetry events for testing.
+type recordTelemetry struct{ events []TelemetryEvent }
+
+// Event implements Telemetry.
+func (r *recordTelemetry) Event(ev TelemetryEvent) { r.events = append(r.events, ev) }
+
+// last returns the last event of the given kind or nil if there was none.
+func (r *recordTelemetry) last(kind TelemetryKind) *TelemetryEvent {
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].Kind == kind {
+			return &r.events[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// terrain.go draws large outdoor landscapes from a heightmap, eg:
+//
+//	hm, err := vu.LoadHeightmap(file) // 16-bit grayscale png.
+//	land := scene.AddTerrain(hm, 2, 120, 32, "shd:terrain", "tex:splat:splat",
+//		"tex:layer0:grass", "tex:layer1:rock", "tex:layer2:dirt", "tex:layer3:snow")
+//	land.SetTerrainRange(200, 1500)
+//	if y, ok := land.TerrainHeight(x, z); ok {
+//		player.SetAt(x, y, z) // stand on the ground.
+//	}
+//
+// The heightmap is split into square chunks and each chunk is drawn by its
+// own model. Chunks further from the camera are drawn with fewer triangles
+// by skipping heightmap points, halving the detail each time the distance
+// doubles. Chunk edges have skirts that hang below the surface to hide the
+// cracks where neighbouring chunks have different detail. Chunk meshes are
+// generated as the camera approaches and released once the camera has moved
+// away, a few each frame, so that large terrains only keep the nearby chunks
+// loaded. Each loaded chunk also has a static physics heightfield.
+//
+// The terrain shader blends four layer textures using the weights in
+// the red, green, blue, and alpha channels of a splat texture that is
+// stretched over the whole terrain. The layer textures repeat.
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// Heightmap is a grid of terrain heights, normally from 0 to 1.
+type Heightmap struct {
+	Cols, Rows int       // grid size.
+	Heights    []float32 // row ordered heights.
+}
+
+// NewHeightmap returns a flat heightmap with cols by rows heights.
+func NewHeightmap(cols, rows int) *Heightmap {
+	cols, rows = max(cols, 0), max(rows, 0)
+	return &Heightmap{Cols: cols, Rows: rows, Heights: make([]float32, cols*rows)}
+}
+
+// LoadHeightmap reads a png image as a heightmap. Pixels are converted
+// to grayscale where black is height 0 and white is height 1. Use 16-bit
+// grayscale images to avoid stepped terrain.
+func LoadHeightmap(r io.Reader) (*Heightmap, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("LoadHeightmap: %w", err)
+	}
+	return heightmapFromImage(img), nil
+}
+
+// heightmapFromImage converts image pixels to heights.
+func heightmapFromImage(img image.Image) *Heightmap {
+	b := img.Bounds()
+	hm := NewHeightmap(b.Dx(), b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.Gray16Model.Convert(img.At(x, y)).(color.Gray16)
+			hm.Heights[(y-b.Min.Y)*hm.Cols+x-b.Min.X] = float32(g.Y) / math.MaxUint16
+		}
+	}
+	return hm
+}
+
+// At returns the height at the given column and row. Locations
+// outside the heightmap return the height of the closest edge.
+func (hm *Heightmap) At(col, row int) float64 {
+	if len(hm.Heights) == 0 {
+		return 0
+	}
+	col, row = min(max(col, 0), hm.Cols-1), min(max(row, 0), hm.Rows-1)
+	return float64(hm.Heights[row*hm.Cols+col])
+}
+
+// Set the height at the given column and row. Locations outside
+// the heightmap are ignored. Loaded terrain chunks are not updated.
+func (hm *Heightmap) Set(col, row int, height float64) {
+	if col >= 0 && row >= 0 && col < hm.Cols && row < hm.Rows {
+		hm.Heights[row*hm.Cols+col] = float32(height)
+	}
+}
+
+// AddTerrain adds a terrain to a 3D scene. Heightmap points are spacing
+// units apart, with columns along the X axis and rows along the Z axis,
+// starting at the terrain location. Heights are scaled by height. Each
+// chunk is chunk by chunk grid squares where chunk is a power of 2 from
+// 1 to 128 that evenly divides the heightmap Cols-1 and Rows-1. The assets
+// are the shader and textures used to draw each chunk, see the terrain
+// shader. The terrain is expected to be a direct child of the scene and
+// is not rotated or scaled.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) AddTerrain(hm *Heightmap, spacing, height float64, chunk int, assets ...string) (me *Entity) {
+	me = e.AddPart() // add a transform node for the terrain.
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		slog.Error("AddTerrain needs 3D scene", "eid", e.eid)
+		return me
+	}
+	switch {
+	case hm == nil || hm.Cols < 2 || hm.Rows < 2 || len(hm.Heights) != hm.Cols*hm.Rows:
+		slog.Error("AddTerrain invalid heightmap")
+		return me
+	case spacing <= 0 || chunk < 1 || chunk > maxTerrainChunk || chunk&(chunk-1) != 0:
+		slog.Error("AddTerrain invalid chunk", "spacing", spacing, "chunk", chunk)
+		return me
+	case (hm.Cols-1)%chunk != 0 || (hm.Rows-1)%chunk != 0:
+		slog.Error("AddTerrain heightmap does not divide into chunks", "cols", hm.Cols, "rows", hm.Rows, "chunk", chunk)
+		return me
+	}
+	me.app.terrains.create(me, scene, hm, spacing, height, chunk, assets)
+	return me
+}
+
+// SetTerrainRange sets the distances from the camera used to draw the
+// terrain chunks. Chunks within lodDistance are drawn in full detail and
+// the detail halves each time the distance doubles. Chunks further than
+// viewDistance are unloaded. The defaults are 1 and 8 chunk widths.
+//
+// Depends on Entity.AddTerrain.
+func (e *Entity) SetTerrainRange(lodDistance, viewDistance float64) *Entity {
+	if t := e.app.terrains.get(e.eid); t != nil {
+		if lodDistance > 0 && viewDistance > 0 {
+			t.lodDist, t.viewDist = lodDistance, viewDistance
+		}
+		return e
+	}
+	slog.Error("SetTerrainRange needs AddTerrain", "eid", e.eid)
+	return e
+}
+
+// SetTerrainTiling sets how many times the layer textures repeat
+// across the whole terrain. The default repeats the layer textures
+// once for each chunk.
+//
+// Depends on Entity.AddTerrain.
+func (e *Entity) SetTerrainTiling(repeats float64) *Entity {
+	if t := e.app.terrains.get(e.eid); t != nil {
+		t.tiling = repeats
+		for _, c := range t.chunks {
+			c.model.SetModelUniform("args4", []float32{float32(repeats), 0, 0, 0})
+		}
+		return e
+	}
+	slog.Error("SetTerrainTiling needs AddTerrain", "eid", e.eid)
+	return e
+}
+
+// TerrainHeight returns the world height of the terrain surface at
+// the world location x,z. Returns false if x,z is off the terrain.
+//
+// Depends on Entity.AddTerrain.
+func (e *Entity) TerrainHeight(x, z float64) (y float64, ok bool) {
+	t := e.app.terrains.get(e.eid)
+	if t == nil {
+		slog.Error("TerrainHeight needs AddTerrain", "eid", e.eid)
+		return 0, false
+	}
+	p := e.app.povs.get(e.eid)
+	if p == nil {
+		return 0, false
+	}
+	tx, ty, tz := p.world()
+	h, ok := t.heightAt((x-tx)/t.spacing, (z-tz)/t.spacing)
+	return ty + h, ok
+}
+
+// =============================================================================
+// terrain data
+
+const (
+	maxTerrainChunk = 128 // chunk meshes use uint16 indexes.
+	terrainBuilds   = 4   // chunk meshes generated each frame.
+	terrainKeep     = 1.2 // unload chunks past this times the view distance.
+)
+
+// terrain holds the heightmap and the chunks that draw it.
+type terrain struct {
+	eid, scene eID // terrain part and its scene.
+	hm         *Heightmap
+	spacing    float64 // distance between heightmap points.
+	height     float64 // heightmap scale.
+	chunk      int     // grid squares per chunk side.
+	levels     int     // detail levels, the last uses one square per chunk.
+	assets     []string
+	lodDist    float64 // full detail distance.
+	viewDist   float64 // loaded distance.
+	tiling     float64 // layer texture repeats.
+
+	// chunks are created as the camera approaches.
+	chunks map[lin.V2i]*terrainChunk
+}
+
+// terrainChunk draws a square of the heightmap with one model.
+type terrainChunk struct {
+	model  *Entity // chunk model with generated meshes.
+	body   *Entity // physics heightfield, nil if not loaded.
+	level  int     // detail level being drawn, -1 if unloaded.
+	meshes []*mesh // generated meshes for each detail level.
+}
+
+// size returns the width of a chunk.
+func (t *terrain) size() float64 { return float64(t.chunk) * t.spacing }
+
+// heightAt returns the scaled terrain height at the fractional grid
+// location c,r using bilinear interpolation between heightmap points.
+func (t *terrain) heightAt(c, r float64) (h float64, ok bool) {
+	if c < 0 || r < 0 || c > float64(t.hm.Cols-1) || r > float64(t.hm.Rows-1) {
+		return 0, false
+	}
+	c0, r0 := int(c), int(r)
+	fc, fr := c-float64(c0), r-float64(r0)
+	h0 := lin.Lerp(t.hm.At(c0, r0), t.hm.At(c0+1, r0), fc)
+	h1 := lin.Lerp(t.hm.At(c0, r0+1), t.hm.At(c0+1, r0+1), fc)
+	return lin.Lerp(h0, h1, fr) * t.height, true
+}
+
+// normal returns the surface normal at a heightmap point
+// using the central difference of the neighbouring heights.
+func (t *terrain) normal(c, r int) lin.V3 {
+	dx := (t.hm.At(c+1, r) - t.hm.At(c-1, r)) * t.height / (2 * t.spacing)
+	dz := (t.hm.At(c, r+1) - t.hm.At(c, r-1)) * t.height / (2 * t.spacing)
+	n := lin.V3{X: -dx, Y: 1, Z: -dz}
+	return *n.Unit()
+}
+
+// level returns the detail level for a chunk at the given distance.
+func (t *terrain) level(distance float64) int {
+	level := 0
+	for d := t.lodDist; distance > d && level < t.levels-1; d *= 2 {
+		level++
+	}
+	return level
+}
+
+// distance returns the horizontal distance from the terrain local
+// location x,z to the closest point of the given chunk.
+func (t *terrain) distance(key lin.V2i, x, z float64) float64 {
+	size := t.size()
+	x0, z0 := float64(key.X)*size, float64(key.Y)*size
+	dx := max(x0-x, 0, x-(x0+size))
+	dz := max(z0-z, 0, z-(z0+size))
+	return math.Hypot(dx, dz)
+}
+
+// chunkMesh generates the mesh data for one chunk drawn using every
+// step heightmap point. Vertexes are relative to the chunk corner.
+func (t *terrain) chunkMesh(key lin.V2i, step int) load.MeshData {
+	c0, r0 := int(key.X)*t.chunk, int(key.Y)*t.chunk
+	side := t.chunk/step + 1 // vertexes per side.
+	vx, nm, uv, ix := []float32{}, []float32{}, []float32{}, []uint16{}
+	du, dv := 1/float64(t.hm.Cols-1), 1/float64(t.hm.Rows-1)
+	low := math.Inf(1)
+	vertex := func(c, r int, y float64) {
+		n := t.normal(c, r)
+		vx = append(vx, float32(float64(c-c0)*t.spacing), float32(y), float32(float64(r-r0)*t.spacing))
+		nm = append(nm, float32(n.X), float32(n.Y), float32(n.Z))
+		uv = append(uv, float32(float64(c)*du), float32(float64(r)*dv))
+	}
+	for j := 0; j < side; j++ {
+		for i := 0; i < side; i++ {
+			y := t.hm.At(c0+i*step, r0+j*step) * t.height
+			low = min(low, y)
+			vertex(c0+i*step, r0+j*step, y)
+		}
+	}
+	for j := uint16(0); j < uint16(side-1); j++ {
+		for i := uint16(0); i < uint16(side-1); i++ {
+			v, row := j*uint16(side)+i, uint16(side) // counter-clockwise facing +Y.
+			ix = append(ix, v, v+row, v+1, v+1, v+row, v+row+1)
+		}
+	}
+
+	// skirts hang below each edge to hide cracks between chunks.
+	skirt := low - float64(step)*t.spacing
+	edges := [4][2]int{{0, 1}, {side - 1, side}, {0, side}, {side * (side - 1), 1}}
+	for _, e := range edges {
+		first := uint16(len(vx) / 3)
+		for k := 0; k < side; k++ {
+			v := e[0] + k*e[1]
+			vertex(c0+v%side*step, r0+v/side*step, skirt)
+			if k > 0 {
+				a, b := uint16(v-e[1]), uint16(v) // surface edge.
+				c, d := first+uint16(k-1), first+uint16(k)
+				ix = append(ix, a, c, b, b, c, d, a, b, c, b, d, c) // both sides.
+			}
+		}
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(vx, 3)  // vec3
+	md[load.Normals] = load.F32Buffer(nm, 3)   // vec3
+	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(ix)
+	return md
+}
+
+// colliderHeights returns the full detail heights of a chunk.
+func (t *terrain) colliderHeights(key lin.V2i) []float64 {
+	c0, r0 := int(key.X)*t.chunk, int(key.Y)*t.chunk
+	heights := make([]float64, 0, (t.chunk+1)*(t.chunk+1))
+	for r := r0; r <= r0+t.chunk; r++ {
+		for c := c0; c <= c0+t.chunk; c++ {
+			heights = append(heights, t.hm.At(c, r)*t.height)
+		}
+	}
+	return heights
+}
+
+// =============================================================================
+// terrains component manager.
+
+// terrains tracks the terrain components.
+type terrains struct {
+	list map[eID]*terrain
+}
+
+// newTerrains creates the terrain component manager.
+// There is only expected to be once instance created by the engine.
+func newTerrains() *terrains {
+	return &terrains{list: map[eID]*terrain{}}
+}
+
+// create a terrain for the given entity.
+func (ts *terrains) create(e *Entity, scene eID, hm *Heightmap, spacing, height float64, chunk int, assets []string) *terrain {
+	t := &terrain{eid: e.eid, scene: scene, hm: hm, spacing: spacing, height: height, chunk: chunk}
+	for size := chunk; size > 0; size /= 2 {
+		t.levels++
+	}
+	t.assets = append([]string{}, assets...)
+	t.lodDist, t.viewDist = t.size(), 8*t.size()
+	t.tiling = float64(hm.Cols-1) / float64(chunk)
+	t.chunks = map[lin.V2i]*terrainChunk{}
+	ts.list[e.eid] = t
+	return t
+}
+
+// get the terrain for the given entity.
+func (ts *terrains) get(eid eID) *terrain { return ts.list[eid] }
+
+// dispose removes the terrain, its chunk meshes, and its physics
+// heightfields. The chunk models are children of the terrain and are
+// disposed with the terrain. The heightfield parts are added to dead.
+func (ts *terrains) dispose(app *application, eid eID, dead []eID) []eID {
+	t := ts.list[eid]
+	if t == nil {
+		return dead
+	}
+	for _, c := range t.chunks {
+		if c.body != nil && app.povs.get(c.body.eid) != nil {
+			dead = append(dead, c.body.eid) // not already disposed with the scene.
+		}
+		ts.unload(app, c)
+	}
+	delete(ts.list, eid)
+	return dead
+}
+
+// update draws the terrain chunks near the camera at a detail level
+// based on their distance and unloads the chunks that are too far away.
+// Called by the engine once each update.
+func (ts *terrains) update(app *application, rc render.Loader) {
+	for _, t := range ts.list {
+		sc, p := app.scenes.get(t.scene), app.povs.get(t.eid)
+		if sc == nil || p == nil {
+			continue
+		}
+		cx, _, cz := sc.cam.At()
+		tx, _, tz := p.world()
+		x, z := cx-tx, cz-tz // camera in terrain space.
+
+		// unload the chunks that are now far away.
+		for key, c := range t.chunks {
+			if c.level >= 0 && t.distance(key, x, z) > t.viewDist*terrainKeep {
+				if c.body != nil {
+					app.dispose(nil, c.body.eid)
+					c.body = nil
+				}
+				ts.unload(app, c)
+				c.model.Cull(true)
+			}
+		}
+
+		// find the chunks that need a different detail level.
+		type change struct {
+			key      lin.V2i
+			distance float64
+		}
+		changes := []change{}
+		cols, rows := (t.hm.Cols-1)/t.chunk, (t.hm.Rows-1)/t.chunk
+		size := t.size()
+		c0, c1 := max(int((x-t.viewDist)/size), 0), min(int((x+t.viewDist)/size), cols-1)
+		r0, r1 := max(int((z-t.viewDist)/size), 0), min(int((z+t.viewDist)/size), rows-1)
+		for r := r0; r <= r1; r++ {
+			for c := c0; c <= c1; c++ {
+				key := lin.V2i{X: int64(c), Y: int64(r)}
+				d := t.distance(key, x, z)
+				if d > t.viewDist {
+					continue
+				}
+				if tc := t.chunks[key]; tc == nil || tc.level != t.level(d) {
+					changes = append(changes, change{key: key, distance: d})
+				}
+			}
+		}
+
+		// nearest chunks first, with a limit on the new meshes.
+		slices.SortFunc(changes, func(a, b change) int {
+			return cmp.Compare(a.distance, b.distance)
+		})
+		builds := 0
+		for _, ch := range changes {
+			if ts.show(app, rc, t, ch.key, t.level(ch.distance)) {
+				if builds++; builds >= terrainBuilds {
+					break
+				}
+			}
+		}
+	}
+}
+
+// show draws the chunk at the given detail level, loading the chunk
+// and its level mesh as needed. Returns true if a mesh was generated.
+func (ts *terrains) show(app *application, rc render.Loader, t *terrain, key lin.V2i, level int) (built bool) {
+	c := t.chunks[key]
+	if c == nil {
+		size := t.size()
+		terrain := &Entity{app: app, eid: t.eid}
+		model := terrain.AddModel(t.assets...).SetAt(float64(key.X)*size, 0, float64(key.Y)*size)
+		model.SetModelUniform("args4", []float32{float32(t.tiling), 0, 0, 0})
+		c = &terrainChunk{model: model, level: -1, meshes: make([]*mesh, t.levels)}
+		t.chunks[key] = c
+	}
+	m := app.models.get(c.model.eid)
+	if m == nil {
+		return false
+	}
+	if c.meshes[level] == nil {
+		md := t.chunkMesh(key, 1<<level)
+		mid, err := rc.LoadMesh(md)
+		if err != nil {
+			slog.Error("terrain chunk LoadMesh", "error", err)
+			return false
+		}
+		msh := newMesh("terrain")
+		msh.mid = mid
+		msh.generated = true
+		msh.setBounds(md, app.ld.triangles)
+		c.meshes[level], built = msh, true
+	}
+	if c.body == nil {
+		// heightfields are scene level parts as physics bodies are not nested.
+		p := app.povs.get(t.eid)
+		tx, ty, tz := p.world()
+		size := t.size()
+		scene := &Entity{app: app, eid: t.scene}
+		body := Heightfield(t.colliderHeights(key), t.chunk+1, t.chunk+1, t.spacing)
+		c.body = scene.AddPart().SetAt(tx+float64(key.X)*size, ty, tz+float64(key.Y)*size).AddToSimulation(body)
+	}
+	m.mesh, c.level = c.meshes[level], level
+	c.model.Cull(false)
+	app.spatial.refresh(c.model.eid) // bounds change with the mesh.
+	return built
+}
+
+// unload releases the chunk meshes.
+func (ts *terrains) unload(app *application, c *terrainChunk) {
+	if m := app.models.get(c.model.eid); m != nil {
+		m.mesh = nil // released below.
+	}
+	for i, msh := range c.meshes {
+		if msh != nil {
+			app.ld.release(msh) // generated mesh.
+			c.meshes[i] = nil
+		}
+	}
+	c.level = -1
+}
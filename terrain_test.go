@@ -0,0 +1,119 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Terrain
+func TestTerrain(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	app.ld.triangles = true      // pickable chunk meshes.
+	scene := app.addScene(Scene3D)
+	hm := NewHeightmap(33, 33)
+	for r := 0; r < hm.Rows; r++ {
+		for c := 0; c < hm.Cols; c++ {
+			hm.Set(c, r, float64(c)/32) // ramp up along x.
+		}
+	}
+	land := scene.AddTerrain(hm, 1, 10, 8, "shd:terrain").SetTerrainRange(8, 20)
+	app.povs.setWorldMatrix(app.work, 0)
+	ter := app.terrains.get(land.eid)
+	for i := 0; i < 10; i++ {
+		app.terrains.update(app, rc)
+	}
+
+	// go test -run Terrain/height
+	t.Run("height", func(t *testing.T) {
+		if y, ok := land.TerrainHeight(16, 3); !ok || !lin.Aeq(y, 5) {
+			t.Errorf("expected height 5 got %f %t", y, ok)
+		}
+		if y, ok := land.TerrainHeight(4.5, 3); !ok || !lin.Aeq(y, 10*4.5/32) {
+			t.Errorf("expected interpolated height got %f %t", y, ok)
+		}
+		if _, ok := land.TerrainHeight(-1, 3); ok {
+			t.Errorf("expected off terrain")
+		}
+		n := ter.normal(5, 5)
+		if !lin.Aeq(n.Y/n.X, -3.2) || n.Z != 0 {
+			t.Errorf("expected normal tilted away from the ramp got %v", n)
+		}
+	})
+
+	// go test -run Terrain/lod
+	t.Run("lod", func(t *testing.T) {
+		if len(ter.chunks) != 8 {
+			t.Fatalf("expected chunks within view distance got %d", len(ter.chunks))
+		}
+		for key, level := range map[lin.V2i]int{{X: 0, Y: 0}: 0, {X: 1, Y: 0}: 0, {X: 2, Y: 0}: 1, {X: 1, Y: 1}: 1} {
+			if c := ter.chunks[key]; c == nil || c.level != level {
+				t.Errorf("expected chunk %v level %d", key, level)
+			}
+		}
+		md := ter.chunkMesh(lin.V2i{X: 2, Y: 0}, 2)
+		if md[load.Vertexes].Count != 25+4*5 || md[load.Indexes].Count != 4*4*6+4*4*12 {
+			t.Errorf("expected half detail mesh got %d %d", md[load.Vertexes].Count, md[load.Indexes].Count)
+		}
+		c := ter.chunks[lin.V2i{X: 0, Y: 0}]
+		if m := app.models.get(c.model.eid); m.mesh == nil || !m.mesh.bounded || len(m.mesh.faces) == 0 {
+			t.Errorf("expected pickable chunk mesh")
+		}
+		if c.body == nil || app.sim.get(c.body.eid) == nil {
+			t.Errorf("expected chunk heightfield")
+		}
+	})
+
+	// go test -run Terrain/stream
+	t.Run("stream", func(t *testing.T) {
+		c := ter.chunks[lin.V2i{X: 0, Y: 0}]
+		body := c.body
+		app.scenes.get(scene.eid).cam.SetAt(32, 0, 32)
+		for i := 0; i < 10; i++ {
+			app.terrains.update(app, rc)
+		}
+		if c.level != -1 || c.body != nil || body.Exists() || !c.model.Culled() {
+			t.Errorf("expected far chunk unloaded")
+		}
+		if c := ter.chunks[lin.V2i{X: 3, Y: 3}]; c == nil || c.level != 0 {
+			t.Errorf("expected near chunk loaded")
+		}
+	})
+
+	// go test -run Terrain/load
+	t.Run("load", func(t *testing.T) {
+		img := image.NewGray16(image.Rect(0, 0, 3, 2))
+		img.SetGray16(2, 1, color.Gray16{Y: 0xFFFF})
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		hm, err := LoadHeightmap(buf)
+		if err != nil || hm.Cols != 3 || hm.Rows != 2 || hm.At(2, 1) != 1 || hm.At(0, 0) != 0 || hm.At(5, 5) != 1 {
+			t.Errorf("expected heightmap got %+v %v", hm, err)
+		}
+	})
+
+	// go test -run Terrain/dispose
+	t.Run("dispose", func(t *testing.T) {
+		var body *Entity
+		for _, c := range ter.chunks {
+			if c.body != nil {
+				body = c.body
+			}
+		}
+		land.Dispose(nil)
+		if app.terrains.get(land.eid) != nil || body == nil || body.Exists() {
+			t.Errorf("expected terrain and heightfields disposed")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// tick.go calls entity logic like AI, animation, or particles once each
+// engine update. Ticks for entities far from the scene camera can be
+// called less often so that large worlds only pay the full update cost
+// for the entities near the camera, eg:
+//
+//	agent.OnTick(8, func(delta time.Duration) { think(agent, delta) })
+//
+// Skipped ticks are compensated by passing the total time since the
+// tick was last called. Entities using the same interval are spread
+// across updates so that they do not all tick on the same update.
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// OnTick adds a function that is called each engine update with the game
+// time since it was last called, see Engine.SetTimeScale. The maximum
+// interval is the number of updates between calls when the entity is far
+// from the camera: 1 to tick every update, or 2, 4, or 8. The tick is
+// removed when the entity is disposed.
+func (e *Entity) OnTick(maxInterval int, tick func(delta time.Duration)) *Entity {
+	if !e.Exists() {
+		slog.Error("OnTick needs entity", "eid", e.eid)
+		return e
+	}
+	if !slices.Contains([]int{1, 2, 4, 8}, maxInterval) {
+		slog.Error("OnTick interval must be 1, 2, 4, or 8", "eid", e.eid, "interval", maxInterval)
+		maxInterval = 1
+	}
+	e.app.ticks.add(e.app.povs, e.eid, uint32(maxInterval), tick)
+	return e
+}
+
+// StopTicks removes the entity tick functions.
+func (e *Entity) StopTicks() *Entity {
+	e.app.ticks.dispose(e.eid)
+	return e
+}
+
+// SetTickDistance sets the camera distance where entity ticks start to
+// be called less often. Entities closer than the distance tick every
+// update. Entities tick every 2nd update within twice the distance,
+// every 4th update within 4 times the distance, and every 8th update
+// beyond that, limited by the maximum interval given to Entity.OnTick.
+// Zero ticks all entities every update. The default distance is 50.
+func (eng *Engine) SetTickDistance(distance float64) {
+	eng.app.ticks.distance = max(0, distance)
+}
+
+// =============================================================================
+// ticks component manager.
+
+// tick is a single entity update function.
+type tick struct {
+	eid      eID                       // owning entity.
+	scene    eID                       // scene root for the camera distance.
+	interval uint32                    // maximum updates between calls.
+	elapsed  time.Duration             // time since last called.
+	call     func(delta time.Duration) // application tick function.
+	removed  bool                      // true once the tick is disposed.
+}
+
+// ticks calls the entity tick functions each update.
+type ticks struct {
+	list     []*tick // ticks in the order they were added.
+	distance float64 // camera distance for reducing tick rates.
+	count    uint32  // number of updates.
+}
+
+// newTicks creates the tick component manager.
+// There is only expected to be once instance created by the engine.
+func newTicks() *ticks {
+	return &ticks{list: []*tick{}, distance: 50}
+}
+
+// add a tick function for the given entity. The scene root
+// is remembered for finding the camera distance.
+func (ts *ticks) add(povs *povs, eid eID, interval uint32, call func(delta time.Duration)) {
+	scene := eID(0)
+	for id := eid; id != 0; {
+		if n := povs.getNode(id); n != nil {
+			scene, id = id, n.parent
+			continue
+		}
+		break
+	}
+	ts.list = append(ts.list, &tick{eid: eid, scene: scene, interval: interval, call: call})
+}
+
+// dispose removes the ticks for the given entity.
+func (ts *ticks) dispose(eid eID) {
+	for _, t := range ts.list {
+		if t.eid == eid {
+			t.removed = true
+		}
+	}
+}
+
+// has returns true if the given entity has a tick.
+func (ts *ticks) has(eid eID) bool {
+	for _, t := range ts.list {
+		if t.eid == eid && !t.removed {
+			return true
+		}
+	}
+	return false
+}
+
+// update calls the ticks that are due this update.
+// Called by the engine once each update.
+func (ts *ticks) update(app *application, delta time.Duration) {
+	ts.count++
+	cnt := len(ts.list) // ticks added during update start next update.
+	for i := 0; i < cnt; i++ {
+		t := ts.list[i]
+		if t.removed {
+			continue
+		}
+		t.elapsed += delta
+		interval := ts.interval(app, t)
+		if (ts.count+t.eid.id())%interval == 0 {
+			elapsed := t.elapsed
+			t.elapsed = 0
+			t.call(elapsed)
+		}
+	}
+	ts.list = slices.DeleteFunc(ts.list, func(t *tick) bool { return t.removed })
+}
+
+// interval returns the number of updates between calls
+// based on the entity distance from the scene camera.
+func (ts *ticks) interval(app *application, t *tick) uint32 {
+	if t.interval <= 1 || ts.distance <= 0 {
+		return 1
+	}
+	p, sc := app.povs.get(t.eid), app.scenes.get(t.scene)
+	if p == nil || sc == nil {
+		return 1
+	}
+	toCam := sc.cam.distance(p.world()) // distance squared.
+	interval, near := uint32(1), ts.distance*ts.distance
+	for interval < t.interval && toCam > near {
+		interval *= 2
+		near *= 4 // double the distance.
+	}
+	return interval
+}
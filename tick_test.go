@@ -0,0 +1,61 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Tick
+func TestTick(t *testing.T) {
+	app := &application{eids: &entities{}, povs: newPovs(), scenes: newScenes(), ticks: newTicks()}
+	scene := app.addScene(Scene3D)
+	near := scene.AddPart().SetAt(10, 0, 0)
+	mid := scene.AddPart().SetAt(0, 0, 150)      // between 2 and 4 times the distance.
+	far := scene.AddPart().SetAt(0, 1000, 0)     // beyond 4 times the distance.
+	capped := scene.AddPart().SetAt(-1000, 0, 0) // far, but limited to every 2nd update.
+
+	// count the calls and the time passed to each tick.
+	calls, times := map[eID]int{}, map[eID]time.Duration{}
+	for _, e := range []*Entity{near, mid, far} {
+		eid := e.eid
+		e.OnTick(8, func(delta time.Duration) { calls[eid]++; times[eid] += delta })
+	}
+	capped.OnTick(2, func(delta time.Duration) { calls[capped.eid]++; times[capped.eid] += delta })
+
+	t.Run("intervals", func(t *testing.T) {
+		for i := 0; i < 64; i++ {
+			app.ticks.update(app, time.Millisecond)
+		}
+		expect := map[eID]int{near.eid: 64, mid.eid: 16, far.eid: 8, capped.eid: 32}
+		for eid, cnt := range expect {
+			if calls[eid] != cnt {
+				t.Errorf("entity %d expected %d calls got %d", eid, cnt, calls[eid])
+			}
+		}
+	})
+	t.Run("compensation", func(t *testing.T) {
+		for eid, cnt := range calls {
+			if times[eid] > 64*time.Millisecond || times[eid] < time.Duration(64-8)*time.Millisecond {
+				t.Errorf("entity %d with %d calls got %s", eid, cnt, times[eid])
+			}
+		}
+	})
+	t.Run("distance", func(t *testing.T) {
+		app.ticks.distance = 0 // tick everything every update.
+		clear(calls)
+		app.ticks.update(app, time.Millisecond)
+		if len(calls) != 4 {
+			t.Errorf("expected all ticks to be called got %d", len(calls))
+		}
+	})
+	t.Run("dispose", func(t *testing.T) {
+		far.StopTicks()
+		app.ticks.dispose(near.eid)
+		app.ticks.update(app, time.Millisecond)
+		if len(app.ticks.list) != 2 {
+			t.Errorf("expected 2 ticks got %d", len(app.ticks.list))
+		}
+	})
+}
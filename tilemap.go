@@ -0,0 +1,378 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// tilemap.go draws 2D tile based levels. Each tile layer is split into
+// chunks of tiles and each chunk is drawn using a single static mesh.
+// Chunk meshes are only regenerated when their tiles change, so large
+// levels are drawn with a few draw calls instead of a model per tile.
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// AddTilemap adds a tilemap to a 2D scene. Tiles are square with sides
+// of tileSize pixels. Tile values are indexes into a texture atlas with
+// atlasCols columns and atlasRows rows of tiles, numbered left to right
+// and top to bottom starting at 0. The assets are the shader and atlas
+// texture used to draw the tiles, eg:
+//
+//	level := scene.AddTilemap(16, 8, 8, "shd:icon", "tex:color:tiles")
+//	level.AddTileLayer(200, 50, 0).SetTiles(0, tiles)
+//
+// Tile 0,0 is drawn at the tilemap location with tile columns along
+// the x axis and tile rows along the y axis.
+func (e *Entity) AddTilemap(tileSize, atlasCols, atlasRows int, assets ...string) (me *Entity) {
+	me = e.AddPart() // add a transform node for the tilemap.
+	if tileSize <= 0 || atlasCols <= 0 || atlasRows <= 0 {
+		slog.Error("AddTilemap invalid size", "tile", tileSize, "cols", atlasCols, "rows", atlasRows)
+		return me
+	}
+	me.app.tiles.create(me, tileSize, atlasCols, atlasRows, assets)
+	return me
+}
+
+// AddTileLayer adds a layer of width by height tiles to the tilemap.
+// Layers are numbered from 0 in the order they are added. The layer
+// tiles are drawn using the given 2D draw layer, see Entity.SetLayer.
+// All tiles are initially empty.
+//
+// Depends on Entity.AddTilemap.
+func (e *Entity) AddTileLayer(width, height int, drawLayer uint8) *Entity {
+	if tm := e.app.tiles.get(e.eid); tm != nil {
+		tm.layers = append(tm.layers, newTileLayer(e.AddPart(), width, height, drawLayer))
+		return e
+	}
+	slog.Error("AddTileLayer needs AddTilemap", "eid", e.eid)
+	return e
+}
+
+// SetTile sets the tile at column x, row y of the given layer.
+// Negative tiles are empty. Tiles outside the layer are ignored.
+//
+// Depends on Entity.AddTileLayer.
+func (e *Entity) SetTile(layer, x, y, tile int) *Entity {
+	if tl := e.app.tiles.layer(e.eid, layer); tl != nil {
+		tl.set(x, y, tile)
+		return e
+	}
+	slog.Error("SetTile needs AddTileLayer", "eid", e.eid, "layer", layer)
+	return e
+}
+
+// SetTiles sets all the tiles of the given layer from a row
+// ordered list of width by height tiles. Negative tiles are empty.
+//
+// Depends on Entity.AddTileLayer.
+func (e *Entity) SetTiles(layer int, tiles []int) *Entity {
+	if tl := e.app.tiles.layer(e.eid, layer); tl != nil {
+		if len(tiles) != len(tl.tiles) {
+			slog.Error("SetTiles expects width*height tiles", "eid", e.eid, "tiles", len(tiles))
+			return e
+		}
+		for i, tile := range tiles {
+			tl.set(i%tl.w, i/tl.w, tile)
+		}
+		return e
+	}
+	slog.Error("SetTiles needs AddTileLayer", "eid", e.eid, "layer", layer)
+	return e
+}
+
+// Tile returns the tile at column x, row y of the given layer.
+// Returns -1 for empty tiles and tiles outside the layer.
+//
+// Depends on Entity.AddTileLayer.
+func (e *Entity) Tile(layer, x, y int) int {
+	if tl := e.app.tiles.layer(e.eid, layer); tl != nil && tl.inside(x, y) {
+		return tl.tiles[y*tl.w+x]
+	}
+	return -1
+}
+
+// SetTileParallax scrolls the given layer at a fraction of the camera
+// movement. The default of 1,1 moves the layer with the scene. Smaller
+// values are used for distant backgrounds, eg: 0.5 scrolls at half the
+// camera speed and 0 keeps the layer fixed to the camera.
+//
+// Depends on Entity.AddTileLayer.
+func (e *Entity) SetTileParallax(layer int, px, py float64) *Entity {
+	if tl := e.app.tiles.layer(e.eid, layer); tl != nil {
+		tl.px, tl.py = px, py
+		return e
+	}
+	slog.Error("SetTileParallax needs AddTileLayer", "eid", e.eid, "layer", layer)
+	return e
+}
+
+// SetTileAnimation animates the given tile by drawing each of the frame
+// tiles for frameTime before looping back to the first frame. Animations
+// apply to every layer of the tilemap. No frames removes the animation.
+//
+// Depends on Entity.AddTilemap.
+func (e *Entity) SetTileAnimation(tile int, frames []int, frameTime time.Duration) *Entity {
+	if tm := e.app.tiles.get(e.eid); tm != nil {
+		if len(frames) == 0 || frameTime <= 0 {
+			delete(tm.anims, tile)
+		} else {
+			tm.anims[tile] = &tileAnim{frames: append([]int{}, frames...), frameTime: frameTime}
+		}
+		tm.redraw(true)
+		return e
+	}
+	slog.Error("SetTileAnimation needs AddTilemap", "eid", e.eid)
+	return e
+}
+
+// =============================================================================
+// tilemap data
+
+// tileChunkSize is the number of tiles along each side of a chunk.
+// One chunk has at most 1024 vertexes so it fits uint16 indexes.
+const tileChunkSize = 16
+
+// tilemap is a 2D grid of tiles drawn in layers.
+type tilemap struct {
+	eid     eID               // tilemap entity.
+	size    int               // tile size in pixels.
+	cols    int               // atlas tile columns.
+	rows    int               // atlas tile rows.
+	assets  []string          // chunk model shader and texture.
+	layers  []*tileLayer      // drawn in order.
+	anims   map[int]*tileAnim // animated tiles.
+	elapsed time.Duration     // time for tile animations.
+}
+
+// tileLayer is one layer of tiles. The layer tiles are
+// drawn by chunk models that are children of the layer part.
+type tileLayer struct {
+	part   *Entity // moved to give parallax.
+	w, h   int     // layer size in tiles.
+	tiles  []int   // row ordered tiles, -1 for empty.
+	draw   uint8   // 2D draw layer.
+	px, py float64 // parallax, 1 moves with the scene.
+
+	// chunks are created as tiles are set.
+	chunks map[lin.V2i]*tileChunk
+}
+
+// tileChunk draws a square of layer tiles with one model.
+type tileChunk struct {
+	model    *Entity // chunk model with a generated mesh.
+	dirty    bool    // true if the mesh needs regenerating.
+	animated bool    // true if the chunk has animated tiles.
+}
+
+// tileAnim cycles a tile through a list of frame tiles.
+type tileAnim struct {
+	frames    []int         // tiles drawn for each frame.
+	frameTime time.Duration // time per frame.
+	frame     int           // current frame.
+}
+
+// newTileLayer creates an empty layer.
+func newTileLayer(part *Entity, w, h int, draw uint8) *tileLayer {
+	tl := &tileLayer{part: part, w: max(w, 0), h: max(h, 0), draw: draw, px: 1, py: 1}
+	tl.tiles = make([]int, tl.w*tl.h)
+	for i := range tl.tiles {
+		tl.tiles[i] = -1
+	}
+	tl.chunks = map[lin.V2i]*tileChunk{}
+	return tl
+}
+
+// inside returns true if x, y is a tile in the layer.
+func (tl *tileLayer) inside(x, y int) bool { return x >= 0 && y >= 0 && x < tl.w && y < tl.h }
+
+// set the tile and mark its chunk for regenerating.
+func (tl *tileLayer) set(x, y, tile int) {
+	if !tl.inside(x, y) {
+		return
+	}
+	tile = max(tile, -1)
+	if tl.tiles[y*tl.w+x] == tile {
+		return // unchanged.
+	}
+	tl.tiles[y*tl.w+x] = tile
+	key := lin.V2i{X: int64(x / tileChunkSize), Y: int64(y / tileChunkSize)}
+	if c, ok := tl.chunks[key]; ok {
+		c.dirty = true
+		return
+	}
+	tl.chunks[key] = &tileChunk{dirty: true}
+}
+
+// redraw marks the chunks with animated tiles for regenerating,
+// or all the chunks if all is true.
+func (tm *tilemap) redraw(all bool) {
+	for _, tl := range tm.layers {
+		for _, c := range tl.chunks {
+			c.dirty = c.dirty || c.animated || all
+		}
+	}
+}
+
+// frameTile returns the tile to draw for the given tile.
+func (tm *tilemap) frameTile(tile int) (frame int, animated bool) {
+	if a, ok := tm.anims[tile]; ok {
+		return a.frames[a.frame], true
+	}
+	return tile, false
+}
+
+// chunkMesh generates the mesh data for one chunk of layer tiles.
+// Returns nil if the chunk has no tiles.
+func (tm *tilemap) chunkMesh(tl *tileLayer, key lin.V2i) (md load.MeshData, animated bool) {
+	vx, uv, ix := []float32{}, []float32{}, []uint16{}
+	size := float32(tm.size)
+	du, dv := 1/float32(tm.cols), 1/float32(tm.rows)
+	x0, y0 := int(key.X)*tileChunkSize, int(key.Y)*tileChunkSize
+	for y := y0; y < min(y0+tileChunkSize, tl.h); y++ {
+		for x := x0; x < min(x0+tileChunkSize, tl.w); x++ {
+			tile, anim := tm.frameTile(tl.tiles[y*tl.w+x])
+			animated = animated || anim
+			if tile < 0 || tile >= tm.cols*tm.rows {
+				continue // empty.
+			}
+
+			// quad corners 0,0 1,0 0,1 1,1 match the label glyph quads.
+			i0 := uint16(len(vx) / 2)
+			ix = append(ix, i0, i0+2, i0+1, i0+1, i0+2, i0+3)
+			px, py := float32(x)*size, float32(y)*size
+			vx = append(vx, px, py, px+size, py, px, py+size, px+size, py+size)
+			u, v := float32(tile%tm.cols)*du, float32(tile/tm.cols)*dv
+			uv = append(uv, u, v, u+du, v, u, v+dv, u+du, v+dv)
+		}
+	}
+	if len(ix) == 0 {
+		return nil, animated
+	}
+	md = make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(vx, 2)  // vec2
+	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(ix)
+	return md, animated
+}
+
+// =============================================================================
+// tilemaps component manager.
+
+// tilemaps tracks the tilemap components.
+type tilemaps struct {
+	list map[eID]*tilemap
+}
+
+// newTilemaps creates the tilemap component manager.
+// There is only expected to be once instance created by the engine.
+func newTilemaps() *tilemaps {
+	return &tilemaps{list: map[eID]*tilemap{}}
+}
+
+// create a tilemap for the given entity.
+func (ts *tilemaps) create(e *Entity, size, cols, rows int, assets []string) *tilemap {
+	tm := &tilemap{eid: e.eid, size: size, cols: cols, rows: rows}
+	tm.assets = append([]string{}, assets...)
+	tm.anims = map[int]*tileAnim{}
+	ts.list[e.eid] = tm
+	return tm
+}
+
+// get the tilemap for the given entity.
+func (ts *tilemaps) get(eid eID) *tilemap { return ts.list[eid] }
+
+// layer returns the given tilemap layer or nil if it does not exist.
+func (ts *tilemaps) layer(eid eID, layer int) *tileLayer {
+	if tm := ts.list[eid]; tm != nil && layer >= 0 && layer < len(tm.layers) {
+		return tm.layers[layer]
+	}
+	return nil
+}
+
+// dispose removes the tilemap. The layer and chunk models are
+// children of the tilemap and are disposed with the tilemap.
+func (ts *tilemaps) dispose(eid eID) { delete(ts.list, eid) }
+
+// update advances the tile animations, moves the parallax layers,
+// and regenerates the meshes of the chunks that have changed.
+// Called by the engine once each update.
+func (ts *tilemaps) update(app *application, rc render.Loader, delta time.Duration) {
+	for _, tm := range ts.list {
+		tm.elapsed += delta
+		changed := false
+		for _, a := range tm.anims {
+			frame := int(tm.elapsed/a.frameTime) % len(a.frames)
+			changed = changed || frame != a.frame
+			a.frame = frame
+		}
+		if changed {
+			tm.redraw(false)
+		}
+		cam := ts.camera(app, tm.eid)
+		for _, tl := range tm.layers {
+			if cam != nil {
+				cx, cy, _ := cam.At()
+				tl.part.SetAt(cx*(1-tl.px), cy*(1-tl.py), 0)
+			}
+			for key, c := range tl.chunks {
+				if c.dirty {
+					ts.drawChunk(app, rc, tm, tl, key, c)
+				}
+			}
+		}
+	}
+}
+
+// camera returns the camera for the scene containing the tilemap.
+func (ts *tilemaps) camera(app *application, eid eID) *Camera {
+	scene := eID(0)
+	for id := eid; id != 0; {
+		if n := app.povs.getNode(id); n != nil {
+			scene, id = id, n.parent
+			continue
+		}
+		break
+	}
+	if sc := app.scenes.get(scene); sc != nil {
+		return sc.cam
+	}
+	return nil
+}
+
+// drawChunk regenerates the chunk mesh. The previous mesh
+// is released once it is no longer drawn.
+func (ts *tilemaps) drawChunk(app *application, rc render.Loader, tm *tilemap, tl *tileLayer, key lin.V2i, c *tileChunk) {
+	c.dirty = false
+	md, animated := tm.chunkMesh(tl, key)
+	c.animated = animated
+	if c.model == nil {
+		if md == nil {
+			return // nothing to draw.
+		}
+		c.model = tl.part.AddModel(tm.assets...).SetLayer(tl.draw)
+	}
+	m := app.models.get(c.model.eid)
+	if m == nil {
+		return
+	}
+	if m.mesh != nil {
+		app.ld.release(m.mesh) // generated mesh.
+		m.mesh = nil
+	}
+	c.model.Cull(md == nil)
+	if md == nil {
+		return // chunk is empty.
+	}
+	mid, err := rc.LoadMesh(md)
+	if err != nil {
+		slog.Error("tilemap chunk LoadMesh", "error", err)
+		return
+	}
+	m.mesh = newMesh("tiles")
+	m.mesh.mid = mid
+	m.mesh.generated = true
+}
@@ -0,0 +1,96 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Tilemap
+func TestTilemap(t *testing.T) {
+	app := newApplication()
+	defer app.ld.dispose()
+	rc := &mrc{} // mock render context.
+	scene := app.addScene(Scene2D)
+	level := scene.AddTilemap(16, 4, 2, "shd:icon", "tex:color:tiles").AddTileLayer(20, 20, 0)
+	tm, tl := app.tiles.get(level.eid), app.tiles.layer(level.eid, 0)
+
+	t.Run("tiles", func(t *testing.T) {
+		level.SetTile(0, 1, 2, 5).SetTile(0, 19, 19, 0).SetTile(0, 99, 0, 1)
+		if level.Tile(0, 1, 2) != 5 || level.Tile(0, 0, 0) != -1 || level.Tile(0, 99, 0) != -1 {
+			t.Errorf("unexpected tiles")
+		}
+		if len(tl.chunks) != 2 {
+			t.Errorf("expected 2 chunks got %d", len(tl.chunks))
+		}
+		if level.SetTiles(0, []int{1, 2}); level.Tile(0, 0, 0) != -1 {
+			t.Errorf("expected tiles to match the layer size")
+		}
+	})
+	t.Run("chunk mesh", func(t *testing.T) {
+		md, animated := tm.chunkMesh(tl, lin.V2i{X: 0, Y: 0})
+		if animated || md[load.Vertexes].Count != 4 || md[load.Indexes].Count != 6 {
+			t.Fatalf("expected one tile quad")
+		}
+		uv := md[load.Texcoords].Data // tile 5 is column 1, row 1 of the atlas.
+		u := math.Float32frombits(binary.LittleEndian.Uint32(uv[0:]))
+		v := math.Float32frombits(binary.LittleEndian.Uint32(uv[4:]))
+		x := math.Float32frombits(binary.LittleEndian.Uint32(md[load.Vertexes].Data[0:]))
+		if u != 0.25 || v != 0.5 || x != 16 {
+			t.Errorf("unexpected tile quad %f %f %f", u, v, x)
+		}
+		app.tiles.update(app, rc, time.Millisecond)
+		models := 0
+		for _, c := range tl.chunks {
+			if c.dirty || c.model == nil {
+				t.Fatalf("expected chunk models")
+			}
+			if m := app.models.get(c.model.eid); m != nil && m.mesh != nil && m.mesh.generated {
+				models++
+			}
+		}
+		if models != 2 {
+			t.Errorf("expected 2 chunk meshes got %d", models)
+		}
+	})
+	t.Run("animation", func(t *testing.T) {
+		level.SetTileAnimation(5, []int{5, 6}, 100*time.Millisecond)
+		app.tiles.update(app, rc, 50*time.Millisecond)
+		if tile, _ := tm.frameTile(5); tile != 5 {
+			t.Errorf("expected first frame got %d", tile)
+		}
+		app.tiles.update(app, rc, 100*time.Millisecond)
+		if tile, _ := tm.frameTile(5); tile != 6 {
+			t.Errorf("expected second frame got %d", tile)
+		}
+		animated := 0
+		for _, c := range tl.chunks {
+			if c.animated {
+				animated++
+			}
+		}
+		if animated != 1 {
+			t.Errorf("expected one animated chunk got %d", animated)
+		}
+	})
+	t.Run("parallax", func(t *testing.T) {
+		level.SetTileParallax(0, 0.5, 0)
+		scene.Cam().SetAt(100, 40, 0)
+		app.tiles.update(app, rc, time.Millisecond)
+		if x, y, _ := tl.part.At(); x != 50 || y != 40 {
+			t.Errorf("expected parallax offset got %f %f", x, y)
+		}
+	})
+	t.Run("dispose", func(t *testing.T) {
+		level.Dispose(nil)
+		if app.tiles.get(level.eid) != nil {
+			t.Errorf("expected tilemap to be disposed")
+		}
+	})
+}
@@ -0,0 +1,247 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// timer.go schedules delayed calls, repeating timers, and sequenced
+// actions on the game clock, eg:
+//
+//	eng.After(2*time.Second, func() { door.SetAt(0, 2, 0) })
+//	spawner.Every(5*time.Second, func() { spawn(spawner) })
+//	eng.Sequence().
+//		Do(func() { banner.SetText("Ready") }).Wait(time.Second).
+//		Do(func() { banner.SetText("Go!") }).Wait(time.Second).
+//		Do(func() { banner.Dispose(eng) })
+//
+// The game clock advances by the update delta times scaled by the time
+// scale, see SetTimeScale, so scheduled calls pause with the game. Calls
+// are made from the engine update loop, after the application Update,
+// so they can safely change entities. Use timers instead of time.Timer
+// or time.AfterFunc, whose goroutines race with the update loop.
+
+import (
+	"log/slog"
+	"time"
+)
+
+// SetTimeScale sets the rate of the game clock, where 1 is real time,
+// 0.5 is slow motion, and 0 pauses the game. The game clock times the
+// physics simulation, entity ticks, coroutine waits, and timers. The
+// delta time passed to Update and to tweens is real time so that menus
+// keep running while the game is paused. Negative scales are ignored.
+func (eng *Engine) SetTimeScale(scale float64) {
+	if scale < 0 {
+		slog.Error("SetTimeScale needs a scale of 0 or more", "scale", scale)
+		return
+	}
+	eng.app.timers.scale = scale
+}
+
+// TimeScale returns the rate of the game clock, see SetTimeScale.
+func (eng *Engine) TimeScale() float64 { return eng.app.timers.scale }
+
+// GameTime returns the time on the game clock, ie: the scaled
+// update time since the engine started.
+func (eng *Engine) GameTime() time.Duration { return eng.app.timers.now }
+
+// After calls fn once the given game time has passed.
+func (eng *Engine) After(delay time.Duration, fn func()) *Timer {
+	return eng.Sequence().Wait(delay).Do(fn)
+}
+
+// Every calls fn each time the given game time period passes,
+// until the timer is stopped.
+func (eng *Engine) Every(period time.Duration, fn func()) *Timer {
+	return every(eng.app, 0, period, fn)
+}
+
+// Sequence returns an empty timer for scheduling a sequence of
+// actions, see Timer.Do, Timer.Wait, and Timer.WaitUntil.
+// The sequence starts with the next engine update.
+func (eng *Engine) Sequence() *Timer { return eng.app.timers.add(0) }
+
+// After calls fn once the given game time has passed.
+// The timer is stopped when the entity is disposed.
+func (e *Entity) After(delay time.Duration, fn func()) *Timer {
+	return e.Sequence().Wait(delay).Do(fn)
+}
+
+// Every calls fn each time the given game time period passes. The
+// timer is stopped when the entity is disposed.
+func (e *Entity) Every(period time.Duration, fn func()) *Timer {
+	return every(e.app, e.eid, period, fn)
+}
+
+// Sequence returns an empty timer for scheduling a sequence of
+// actions. The timer is stopped when the entity is disposed.
+func (e *Entity) Sequence() *Timer {
+	if !e.Exists() {
+		slog.Error("Sequence needs entity", "eid", e.eid)
+	}
+	return e.app.timers.add(e.eid)
+}
+
+// StopTimers stops the entity timers.
+func (e *Entity) StopTimers() *Entity {
+	e.app.timers.dispose(e.eid)
+	return e
+}
+
+// every creates a repeating timer.
+func every(app *application, eid eID, period time.Duration, fn func()) *Timer {
+	t := app.timers.add(eid)
+	if period <= 0 {
+		slog.Error("Every needs a period greater than 0", "period", period)
+		t.Stop()
+		return t
+	}
+	return t.Wait(period).Do(fn).Loop()
+}
+
+// =============================================================================
+
+// Timer runs a sequence of actions and waits on the game clock.
+// Timers are created with After, Every, and Sequence.
+type Timer struct {
+	eid    eID           // owning entity, 0 for engine timers.
+	steps  []timerStep   // actions and waits in order.
+	step   int           // current step.
+	time   time.Duration // game time available for waits.
+	period time.Duration // total wait time of the steps.
+	loop   bool          // true to repeat the steps.
+	done   bool          // true once finished or stopped.
+}
+
+// timerStep is one action or wait, only one of which is set.
+type timerStep struct {
+	do    func()        // call the function.
+	wait  time.Duration // wait for game time.
+	until func() bool   // wait for the condition.
+}
+
+// Do adds a function call to the sequence.
+func (t *Timer) Do(fn func()) *Timer {
+	if fn != nil {
+		t.steps = append(t.steps, timerStep{do: fn})
+	}
+	return t
+}
+
+// Wait adds a wait for the given game time to the sequence.
+func (t *Timer) Wait(delay time.Duration) *Timer {
+	if delay > 0 {
+		t.steps = append(t.steps, timerStep{wait: delay})
+		t.period += delay
+	}
+	return t
+}
+
+// WaitUntil adds a wait for the condition to the sequence.
+// The condition is checked once each engine update.
+func (t *Timer) WaitUntil(condition func() bool) *Timer {
+	if condition != nil {
+		t.steps = append(t.steps, timerStep{until: condition})
+	}
+	return t
+}
+
+// Loop repeats the sequence until the timer is stopped.
+// A sequence without waits repeats once each engine update.
+func (t *Timer) Loop() *Timer {
+	t.loop = true
+	return t
+}
+
+// Stop cancels the remaining steps of the timer.
+func (t *Timer) Stop() { t.done = true }
+
+// Done returns true once the timer has finished or been stopped.
+func (t *Timer) Done() bool { return t.done }
+
+// advance runs the steps that are ready after the given game time.
+// Time left over from a wait carries over to the following waits so
+// that repeating timers do not drift.
+func (t *Timer) advance(delta time.Duration) {
+	t.time += delta
+	for !t.done {
+		if t.step >= len(t.steps) {
+			if !t.loop || len(t.steps) == 0 {
+				t.done = true
+				return
+			}
+			t.step = 0
+			if t.period == 0 {
+				return // repeat the next update.
+			}
+		}
+		s := t.steps[t.step]
+		switch {
+		case s.wait > 0:
+			if t.time < s.wait {
+				return
+			}
+			t.time -= s.wait
+		case s.until != nil:
+			if !s.until() {
+				t.time = 0 // waits start once the condition is met.
+				return
+			}
+		case s.do != nil:
+			s.do()
+		}
+		t.step++
+	}
+}
+
+// =============================================================================
+// timers component manager.
+
+// timers runs the timers and keeps the game clock.
+type timers struct {
+	list  []*Timer      // timers in the order they were added.
+	scale float64       // game clock rate, 0 when paused.
+	now   time.Duration // game clock time.
+}
+
+// newTimers creates the timer manager with a real time game clock.
+func newTimers() *timers { return &timers{scale: 1} }
+
+// scaled converts a real time delta to game time.
+func (ts *timers) scaled(delta time.Duration) time.Duration {
+	return time.Duration(float64(delta) * ts.scale)
+}
+
+// add creates a timer that starts with the next update.
+func (ts *timers) add(eid eID) *Timer {
+	t := &Timer{eid: eid}
+	ts.list = append(ts.list, t)
+	return t
+}
+
+// dispose stops the timers owned by the entity.
+func (ts *timers) dispose(eid eID) {
+	for _, t := range ts.list {
+		if t.eid == eid {
+			t.Stop()
+		}
+	}
+}
+
+// update advances the game clock and the timers by the given game
+// time and removes the finished timers.
+func (ts *timers) update(delta time.Duration) {
+	ts.now += delta
+	cnt := len(ts.list) // timers added during update start next update.
+	for i := 0; i < cnt; i++ {
+		if t := ts.list[i]; !t.done {
+			t.advance(delta)
+		}
+	}
+	keep := ts.list[:0]
+	for _, t := range ts.list {
+		if !t.done {
+			keep = append(keep, t)
+		}
+	}
+	clear(ts.list[len(keep):])
+	ts.list = keep
+}
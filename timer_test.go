@@ -0,0 +1,133 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run Timer
+func TestTimer(t *testing.T) {
+	ms := time.Millisecond
+	eng := &Engine{app: newApplication()}
+	ts := eng.app.timers
+	run := func(updates int, delta time.Duration) {
+		for i := 0; i < updates; i++ {
+			ts.update(ts.scaled(delta))
+		}
+	}
+
+	// go test -run Timer/after
+	t.Run("after", func(t *testing.T) {
+		calls := 0
+		eng.After(50*ms, func() { calls++ })
+		run(4, 10*ms)
+		if calls != 0 {
+			t.Fatalf("expected no call before the delay")
+		}
+		run(1, 10*ms)
+		run(5, 10*ms)
+		if calls != 1 || len(ts.list) != 0 {
+			t.Errorf("expected one call got %d", calls)
+		}
+	})
+
+	// go test -run Timer/every
+	t.Run("every", func(t *testing.T) {
+		calls := 0
+		timer := eng.Every(30*ms, func() { calls++ })
+		run(10, 10*ms)
+		if calls != 3 {
+			t.Errorf("expected 3 calls got %d", calls)
+		}
+		run(1, 100*ms) // long update catches up.
+		if calls != 6 {
+			t.Errorf("expected calls for each period got %d", calls)
+		}
+		timer.Stop()
+		run(10, 10*ms)
+		if calls != 6 || len(ts.list) != 0 {
+			t.Errorf("expected stopped timer got %d", calls)
+		}
+		eng.Every(0, func() { calls++ }) // ignored.
+		run(1, 10*ms)
+		if calls != 6 || len(ts.list) != 0 {
+			t.Errorf("expected invalid period to be ignored")
+		}
+	})
+
+	// go test -run Timer/sequence
+	t.Run("sequence", func(t *testing.T) {
+		steps, ready := []string{}, false
+		eng.Sequence().
+			Do(func() { steps = append(steps, "a") }).Wait(20 * ms).
+			Do(func() { steps = append(steps, "b") }).WaitUntil(func() bool { return ready }).Wait(20 * ms).
+			Do(func() { steps = append(steps, "c") })
+		run(1, 10*ms)
+		if len(steps) != 1 {
+			t.Fatalf("expected first step got %v", steps)
+		}
+		run(3, 10*ms)
+		if len(steps) != 2 {
+			t.Fatalf("expected second step got %v", steps)
+		}
+		ready = true
+		run(1, 10*ms)
+		if len(steps) != 2 {
+			t.Errorf("expected wait after the condition got %v", steps)
+		}
+		run(1, 10*ms)
+		if len(steps) != 3 || steps[2] != "c" || len(ts.list) != 0 {
+			t.Errorf("expected sequence done got %v", steps)
+		}
+
+		// loops without waits repeat once each update.
+		calls := 0
+		loop := eng.Sequence().Do(func() { calls++ }).Loop()
+		run(3, 10*ms)
+		loop.Stop()
+		if calls != 3 {
+			t.Errorf("expected one call each update got %d", calls)
+		}
+	})
+
+	// go test -run Timer/pause
+	t.Run("pause", func(t *testing.T) {
+		calls := 0
+		timer := eng.Every(10*ms, func() { calls++ })
+		eng.SetTimeScale(0)
+		start := eng.GameTime()
+		run(10, 10*ms)
+		if calls != 0 || eng.GameTime() != start {
+			t.Errorf("expected paused game clock")
+		}
+		eng.SetTimeScale(0.5)
+		run(10, 10*ms)
+		if calls != 5 || eng.GameTime() != start+50*ms {
+			t.Errorf("expected slow motion got %d calls", calls)
+		}
+		eng.SetTimeScale(-1) // ignored.
+		if eng.TimeScale() != 0.5 {
+			t.Errorf("expected negative scale to be ignored")
+		}
+		eng.SetTimeScale(1)
+		timer.Stop()
+		run(1, 0) // remove the timer.
+	})
+
+	// go test -run Timer/dispose
+	t.Run("dispose", func(t *testing.T) {
+		scene := eng.AddScene(Scene3D)
+		e := scene.AddPart()
+		calls := 0
+		e.Every(10*ms, func() { calls++ })
+		e.After(100*ms, func() { calls += 100 })
+		run(2, 10*ms)
+		e.Dispose(eng)
+		run(20, 10*ms)
+		if calls != 2 || len(ts.list) != 0 {
+			t.Errorf("expected dispose to stop the timers got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,399 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// tween.go animates values over time for UI and camera polish, eg:
+//
+//	panel.TweenAt(0, 200, 0, time.Second/2, vu.EaseOutBack).
+//		Delay(time.Second).
+//		Then(panel.TweenColor(1, 1, 1, 0, time.Second, vu.EaseInQuad)).
+//		OnDone(func() { panel.Dispose(eng) })
+//
+// Tweens run on the engine clock, advancing by the update delta each
+// engine update after the application Update. Tweens use real time,
+// not the game clock, so that menus animate while the game is paused.
+// Entity tweens start from the entity value when the tween starts and
+// are cancelled when the entity is disposed. Engine tweens animate
+// application values through a set function.
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Ease maps the tween time ratio, from 0 to 1, to the tween progress.
+// Progress starts at 0 and ends at 1, but may go outside this range
+// in between, eg: EaseOutBack.
+type Ease func(t float64) float64
+
+// EaseLinear changes at a constant rate.
+// The easing functions follow https://easings.net.
+func EaseLinear(t float64) float64 { return t }
+
+// EaseInQuad starts slow and accelerates.
+func EaseInQuad(t float64) float64 { return t * t }
+
+// EaseOutQuad starts fast and decelerates.
+func EaseOutQuad(t float64) float64 { return t * (2 - t) }
+
+// EaseInCubic starts slower and accelerates faster than EaseInQuad.
+func EaseInCubic(t float64) float64 { return t * t * t }
+
+// EaseOutCubic starts faster and decelerates longer than EaseOutQuad.
+func EaseOutCubic(t float64) float64 { return 1 - (1-t)*(1-t)*(1-t) }
+
+// EaseInOutSine gently accelerates and decelerates.
+func EaseInOutSine(t float64) float64 { return -(math.Cos(math.Pi*t) - 1) / 2 }
+
+// EaseInOutQuad accelerates to the middle and then decelerates.
+func EaseInOutQuad(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return 1 - 2*(1-t)*(1-t)
+}
+
+// EaseInOutCubic accelerates to the middle and then decelerates.
+func EaseInOutCubic(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	return 1 - 4*(1-t)*(1-t)*(1-t)
+}
+
+// EaseOutBack overshoots the end and then settles back.
+func EaseOutBack(t float64) float64 {
+	const c1 = 1.70158
+	const c3 = c1 + 1
+	t--
+	return 1 + c3*t*t*t + c1*t*t
+}
+
+// EaseOutElastic overshoots the end and then oscillates to a stop.
+func EaseOutElastic(t float64) float64 {
+	if t <= 0 || t >= 1 {
+		return t
+	}
+	return math.Pow(2, -10*t)*math.Sin((t*10-0.75)*(2*math.Pi/3)) + 1
+}
+
+// EaseOutBounce bounces to a stop at the end.
+func EaseOutBounce(t float64) float64 {
+	const n1, d1 = 7.5625, 2.75
+	switch {
+	case t < 1/d1:
+		return n1 * t * t
+	case t < 2/d1:
+		t -= 1.5 / d1
+		return n1*t*t + 0.75
+	case t < 2.5/d1:
+		t -= 2.25 / d1
+		return n1*t*t + 0.9375
+	}
+	t -= 2.625 / d1
+	return n1*t*t + 0.984375
+}
+
+// =============================================================================
+// engine tweens.
+
+// Tween calls apply each engine update with the eased progress, from
+// 0 to 1, until the duration has passed. A nil ease is EaseLinear.
+// The tween starts with the next engine update.
+func (eng *Engine) Tween(duration time.Duration, ease Ease, apply func(progress float64)) *Tween {
+	return eng.app.tweens.add(0, duration, ease, nil, apply)
+}
+
+// TweenFloat animates a value between from and to, calling set
+// with the value each engine update.
+func (eng *Engine) TweenFloat(from, to float64, duration time.Duration, ease Ease, set func(v float64)) *Tween {
+	return eng.Tween(duration, ease, func(p float64) { set(lin.Lerp(from, to, p)) })
+}
+
+// TweenV3 animates a vector between from and to, calling set
+// with the vector each engine update.
+func (eng *Engine) TweenV3(from, to lin.V3, duration time.Duration, ease Ease, set func(v *lin.V3)) *Tween {
+	v := &lin.V3{}
+	return eng.Tween(duration, ease, func(p float64) { set(v.Lerp(&from, &to, p)) })
+}
+
+// TweenQ animates a rotation between from and to along the shortest
+// arc, calling set with the rotation each engine update.
+func (eng *Engine) TweenQ(from, to lin.Q, duration time.Duration, ease Ease, set func(q *lin.Q)) *Tween {
+	tq := tweenQ{from: from, to: to}
+	return eng.app.tweens.add(0, duration, ease, tq.begin, func(p float64) { set(tq.at(p)) })
+}
+
+// TweenColor animates an RGBA color, where X, Y, Z, W is red, green,
+// blue, alpha, calling set with the color each engine update.
+func (eng *Engine) TweenColor(from, to lin.V4, duration time.Duration, ease Ease, set func(c *lin.V4)) *Tween {
+	c := &lin.V4{}
+	return eng.Tween(duration, ease, func(p float64) { set(c.Lerp(&from, &to, p)) })
+}
+
+// =============================================================================
+// entity tweens.
+
+// TweenAt moves the entity from its location to the given
+// location, see SetAt.
+//
+// Depends on transform.
+func (e *Entity) TweenAt(x, y, z float64, duration time.Duration, ease Ease) *Tween {
+	if e.app.povs.get(e.eid) == nil {
+		slog.Error("TweenAt needs transform", "eid", e.eid)
+		return e.app.tweens.add(e.eid, duration, ease, nil, nil)
+	}
+	from, to, v := lin.V3{}, lin.V3{X: x, Y: y, Z: z}, &lin.V3{}
+	begin := func() { from.X, from.Y, from.Z = e.At() }
+	return e.app.tweens.add(e.eid, duration, ease, begin, func(p float64) {
+		v.Lerp(&from, &to, p)
+		e.SetAt(v.X, v.Y, v.Z)
+	})
+}
+
+// TweenView rotates the entity from its orientation to the given
+// orientation along the shortest arc, see SetView.
+//
+// Depends on transform.
+func (e *Entity) TweenView(q *lin.Q, duration time.Duration, ease Ease) *Tween {
+	if e.app.povs.get(e.eid) == nil {
+		slog.Error("TweenView needs transform", "eid", e.eid)
+		return e.app.tweens.add(e.eid, duration, ease, nil, nil)
+	}
+	tq := &tweenQ{to: *q}
+	begin := func() {
+		tq.from = *e.View()
+		tq.begin()
+	}
+	return e.app.tweens.add(e.eid, duration, ease, begin, func(p float64) { e.SetView(tq.at(p)) })
+}
+
+// TweenScale scales the entity from its scale to the given
+// scale, see SetScale.
+//
+// Depends on transform.
+func (e *Entity) TweenScale(x, y, z float64, duration time.Duration, ease Ease) *Tween {
+	if e.app.povs.get(e.eid) == nil {
+		slog.Error("TweenScale needs transform", "eid", e.eid)
+		return e.app.tweens.add(e.eid, duration, ease, nil, nil)
+	}
+	from, to, v := lin.V3{}, lin.V3{X: x, Y: y, Z: z}, &lin.V3{}
+	begin := func() { from.X, from.Y, from.Z = e.Scale() }
+	return e.app.tweens.add(e.eid, duration, ease, begin, func(p float64) {
+		v.Lerp(&from, &to, p)
+		e.SetScale(v.X, v.Y, v.Z)
+	})
+}
+
+// TweenColor fades the model from its color to the given
+// color, see SetColor.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) TweenColor(r, g, b, a float64, duration time.Duration, ease Ease) *Tween {
+	if e.app.models.get(e.eid) == nil {
+		slog.Error("TweenColor needs AddModel", "eid", e.eid)
+		return e.app.tweens.add(e.eid, duration, ease, nil, nil)
+	}
+	from, to, c := lin.V4{X: 1, Y: 1, Z: 1, W: 1}, lin.V4{X: r, Y: g, Z: b, W: a}, &lin.V4{}
+	begin := func() {
+		if m := e.app.models.get(e.eid); m != nil && m.mat != nil {
+			col := m.mat.color
+			from = lin.V4{X: float64(col.r), Y: float64(col.g), Z: float64(col.b), W: float64(col.a)}
+		}
+	}
+	return e.app.tweens.add(e.eid, duration, ease, begin, func(p float64) {
+		c.Lerp(&from, &to, p)
+		e.SetColor(c.X, c.Y, c.Z, c.W)
+	})
+}
+
+// StopTweens cancels the entity tweens.
+func (e *Entity) StopTweens() *Entity {
+	e.app.tweens.dispose(e.eid)
+	return e
+}
+
+// tweenQ interpolates between two rotations.
+type tweenQ struct {
+	from, to lin.Q
+	q        lin.Q
+}
+
+// begin flips the end rotation, if needed, to follow the shortest arc.
+func (tq *tweenQ) begin() {
+	if tq.from.Dot(&tq.to) < 0 {
+		tq.to = lin.Q{X: -tq.to.X, Y: -tq.to.Y, Z: -tq.to.Z, W: -tq.to.W}
+	}
+}
+
+// at returns the rotation at the given progress.
+func (tq *tweenQ) at(p float64) *lin.Q { return tq.q.Nlerp(&tq.from, &tq.to, p) }
+
+// =============================================================================
+
+// Tween animates a value over time. Tweens are created by the
+// engine and entity Tween methods.
+type Tween struct {
+	ts       *tweens
+	eid      eID                    // owning entity, 0 for engine tweens.
+	duration time.Duration          // time from start to end.
+	delay    time.Duration          // wait before starting.
+	ease     Ease                   // maps time to progress.
+	begin    func()                 // called when the tween starts.
+	apply    func(progress float64) // called each update once started.
+	done     func()                 // called when the tween finishes.
+	next     []*Tween               // started when the tween finishes.
+
+	time      time.Duration // time since the tween was released.
+	last      uint32        // last update count, to update once per update.
+	waiting   bool          // true while waiting for a previous tween.
+	started   bool          // true once begin has been called.
+	finished  bool          // true once the tween has ended.
+	cancelled bool          // true if the tween was cancelled.
+}
+
+// Delay waits the given time before starting the tween. For sequences,
+// the delay starts when the previous tween finishes.
+func (tw *Tween) Delay(delay time.Duration) *Tween {
+	tw.delay = max(0, delay)
+	return tw
+}
+
+// OnDone sets the function called when the tween finishes.
+// It is not called for cancelled tweens.
+func (tw *Tween) OnDone(done func()) *Tween {
+	tw.done = done
+	return tw
+}
+
+// Then starts the next tween when this tween finishes, and returns the
+// next tween so that sequences can be chained, eg: a.Then(b).Then(c).
+// Calling Then more than once starts the next tweens together.
+func (tw *Tween) Then(next *Tween) *Tween {
+	switch {
+	case next == nil || next == tw || next.started:
+		slog.Error("Tween.Then needs a tween that has not started")
+		return next
+	case tw.cancelled:
+		next.Cancel()
+	case tw.finished:
+		next.waiting = false
+	default:
+		next.waiting = true
+		tw.next = append(tw.next, next)
+	}
+	return next
+}
+
+// Cancel stops the tween, leaving the value where it is,
+// and cancels the tweens that follow it in a sequence.
+func (tw *Tween) Cancel() {
+	if tw.cancelled || tw.finished {
+		return
+	}
+	tw.cancelled = true
+	for _, next := range tw.next {
+		next.Cancel()
+	}
+	tw.next = nil
+}
+
+// Done returns true once the tween has finished or was cancelled.
+func (tw *Tween) Done() bool { return tw.finished || tw.cancelled }
+
+// step applies the tween at its current time and starts the
+// next tweens once the tween is finished.
+func (tw *Tween) step() {
+	if tw.time < tw.delay {
+		return
+	}
+	if !tw.started {
+		tw.started = true
+		if tw.begin != nil {
+			tw.begin()
+		}
+	}
+	ratio := 1.0
+	if tw.duration > 0 {
+		ratio = min(1, float64(tw.time-tw.delay)/float64(tw.duration))
+	}
+	if tw.apply != nil {
+		tw.apply(tw.ease(ratio))
+	}
+	if ratio < 1 || tw.cancelled {
+		return // still running or cancelled by apply.
+	}
+	tw.finished = true
+	if tw.done != nil {
+		tw.done()
+	}
+
+	// start the next tweens with the time left over from this update.
+	over := tw.time - tw.delay - tw.duration
+	next := tw.next
+	tw.next = nil
+	for _, n := range next {
+		if !n.cancelled {
+			n.waiting, n.time, n.last = false, over, tw.ts.count
+			n.step()
+		}
+	}
+}
+
+// =============================================================================
+// tweens component manager.
+
+// tweens advances the running tweens each update.
+type tweens struct {
+	list  []*Tween // tweens in the order they were added.
+	count uint32   // number of updates.
+}
+
+// newTweens creates the tween manager.
+func newTweens() *tweens { return &tweens{} }
+
+// add creates a tween that starts with the next update.
+func (ts *tweens) add(eid eID, duration time.Duration, ease Ease, begin func(), apply func(float64)) *Tween {
+	if ease == nil {
+		ease = EaseLinear
+	}
+	tw := &Tween{ts: ts, eid: eid, duration: max(0, duration), ease: ease, begin: begin, apply: apply}
+	ts.list = append(ts.list, tw)
+	return tw
+}
+
+// dispose cancels the tweens owned by the entity.
+func (ts *tweens) dispose(eid eID) {
+	for _, tw := range ts.list {
+		if tw.eid == eid {
+			tw.Cancel()
+		}
+	}
+}
+
+// update advances the tweens by the update time and
+// removes the finished and cancelled tweens.
+func (ts *tweens) update(delta time.Duration) {
+	ts.count++
+	cnt := len(ts.list) // tweens added during update start next update.
+	for i := 0; i < cnt; i++ {
+		tw := ts.list[i]
+		if tw.waiting || tw.Done() || tw.last == ts.count {
+			continue
+		}
+		tw.last = ts.count
+		tw.time += delta
+		tw.step()
+	}
+	keep := ts.list[:0]
+	for _, tw := range ts.list {
+		if !tw.Done() {
+			keep = append(keep, tw)
+		}
+	}
+	clear(ts.list[len(keep):])
+	ts.list = keep
+}
@@ -0,0 +1,122 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run Tween
+func TestTween(t *testing.T) {
+	ms := time.Millisecond
+	eng := &Engine{app: newApplication()}
+	ts := eng.app.tweens
+	run := func(updates int, delta time.Duration) {
+		for i := 0; i < updates; i++ {
+			ts.update(delta)
+		}
+	}
+
+	// go test -run Tween/ease
+	t.Run("ease", func(t *testing.T) {
+		eases := []Ease{EaseLinear, EaseInQuad, EaseOutQuad, EaseInOutQuad, EaseInCubic, EaseOutCubic,
+			EaseInOutCubic, EaseInOutSine, EaseOutBack, EaseOutElastic, EaseOutBounce}
+		for i, ease := range eases {
+			if !lin.AeqZ(ease(0)) || !lin.Aeq(ease(1), 1) {
+				t.Errorf("ease %d expected 0 to 1 got %f %f", i, ease(0), ease(1))
+			}
+		}
+		if EaseInQuad(0.5) >= 0.5 || EaseOutQuad(0.5) <= 0.5 || !lin.Aeq(EaseInOutCubic(0.5), 0.5) {
+			t.Errorf("expected ease shapes")
+		}
+		if EaseOutBack(0.8) <= 1 {
+			t.Errorf("expected overshoot")
+		}
+	})
+
+	// go test -run Tween/values
+	t.Run("values", func(t *testing.T) {
+		v, at, col, q := 0.0, lin.V3{}, lin.V4{}, lin.Q{}
+		done := 0
+		eng.TweenFloat(10, 20, 100*ms, nil, func(f float64) { v = f }).OnDone(func() { done++ })
+		eng.TweenV3(lin.V3{}, lin.V3{X: 2, Y: 4}, 100*ms, EaseLinear, func(p *lin.V3) { at = *p })
+		eng.TweenColor(lin.V4{W: 1}, lin.V4{X: 1, W: 0}, 100*ms, EaseLinear, func(c *lin.V4) { col = *c })
+		s := math.Sqrt2 / 2
+		to := lin.Q{Z: -s, W: -s} // same rotation as 0,0,s,s.
+		eng.TweenQ(*lin.QI, to, 100*ms, EaseLinear, func(r *lin.Q) { q = *r })
+		run(5, 10*ms)
+		if !lin.Aeq(v, 15) || !lin.Aeq(at.Y, 2) || !lin.Aeq(col.X, 0.5) || !lin.Aeq(col.W, 0.5) || done != 0 {
+			t.Errorf("expected values half way got %f %v %v", v, at, col)
+		}
+		if !lin.Aeq(q.Z, math.Sin(math.Pi/8)) || !lin.Aeq(q.W, math.Cos(math.Pi/8)) {
+			t.Errorf("expected shortest arc got %+v", q)
+		}
+		run(6, 10*ms)
+		if v != 20 || at.X != 2 || done != 1 || len(ts.list) != 0 {
+			t.Errorf("expected tweens finished got %f %v %d %d", v, at, done, len(ts.list))
+		}
+	})
+
+	// go test -run Tween/sequence
+	t.Run("sequence", func(t *testing.T) {
+		scene := eng.AddScene(Scene3D)
+		e := scene.AddPart()
+		steps := []string{}
+		e.TweenAt(10, 0, 0, 100*ms, nil).
+			OnDone(func() { steps = append(steps, "moved") }).
+			Then(e.TweenScale(2, 2, 2, 100*ms, nil)).
+			Delay(50 * ms).
+			OnDone(func() { steps = append(steps, "scaled") })
+		run(10, 10*ms)
+		if x, _, _ := e.At(); x != 10 || len(steps) != 1 {
+			t.Fatalf("expected first tween done got %f %v", x, steps)
+		}
+		run(5, 10*ms)
+		if sx, _, _ := e.Scale(); sx != 1 {
+			t.Errorf("expected delay before the second tween got %f", sx)
+		}
+		run(5, 10*ms)
+		if sx, _, _ := e.Scale(); !lin.Aeq(sx, 1.5) {
+			t.Errorf("expected second tween to start from the current scale got %f", sx)
+		}
+		run(5, 10*ms)
+		if sx, _, _ := e.Scale(); sx != 2 || len(steps) != 2 {
+			t.Errorf("expected sequence done got %f %v", sx, steps)
+		}
+
+		// left over time carries into the next tween.
+		first := eng.Tween(15*ms, nil, nil)
+		v := 0.0
+		first.Then(eng.TweenFloat(0, 100, 100*ms, nil, func(f float64) { v = f }))
+		run(2, 10*ms)
+		if !lin.Aeq(v, 5) {
+			t.Errorf("expected carried time got %f", v)
+		}
+		run(10, 10*ms)
+	})
+
+	// go test -run Tween/cancel
+	t.Run("cancel", func(t *testing.T) {
+		scene := eng.AddScene(Scene3D)
+		e := scene.AddPart()
+		done := false
+		first := e.TweenAt(1, 1, 1, 100*ms, nil)
+		next := first.Then(eng.Tween(100*ms, nil, nil).OnDone(func() { done = true }))
+		run(2, 10*ms)
+		e.Dispose(eng)
+		run(20, 10*ms)
+		if !first.Done() || !next.Done() || done || len(ts.list) != 0 {
+			t.Errorf("expected dispose to cancel the sequence")
+		}
+		missing := scene.AddPart().TweenColor(1, 0, 0, 1, 10*ms, nil) // no model.
+		missing.OnDone(func() { done = true })
+		run(2, 10*ms)
+		if !done {
+			t.Errorf("expected tween to finish without a model")
+		}
+	})
+}
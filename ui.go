@@ -0,0 +1,696 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// ui.go builds menus and HUDs from widgets drawn in a 2D scene, eg:
+//
+//	eng.ImportAssets("col2D.shd", "label.shd", "18:lucon.ttf")
+//	eng.SetUIFont("lucon18")
+//	ui := eng.AddScene(vu.Scene2D)
+//	menu := ui.AddPanel(300, 220).SetAnchor(vu.AnchorCenter, 0, 0)
+//	menu.AddButton("Play", 200, 40).SetAnchor(vu.AnchorTop, 0, 20).OnClick(func(b *vu.Entity) { play() })
+//	menu.AddSlider(200, 20, 0.8).SetAnchor(vu.AnchorTop, 0, 80).OnChange(func(s *vu.Entity) {
+//		eng.SetVolume(s.WidgetValue())
+//	})
+//	menu.AddCheckbox("Fullscreen", 200, 24, false).SetAnchor(vu.AnchorTop, 0, 120)
+//	menu.AddTextField("Player", 200, 30).SetAnchor(vu.AnchorTop, 0, 160)
+//
+// Widgets are parts of the 2D scene or of other widgets. Each widget is
+// placed within its parent, or within the window for widgets added to
+// the scene, using an anchor and a pixel offset so that layouts follow
+// window resizes. Widgets are drawn with col2D quads and label text, or
+// with nine patch skins, see Entity.SetWidgetSkin.
+// Nested widgets are drawn over their parents using the draw layers,
+// so widgets use layers 0 to 14 depending on how deeply they are nested.
+//
+// The UI sees user input before the application Update so that widget
+// callbacks happen in the same update. The mouse clicks buttons and
+// checkboxes, drags sliders, and gives text fields the keyboard focus.
+// Tab moves the keyboard focus between widgets, Return or Space presses
+// the focused button, and the arrow keys move the focused slider.
+// Culled widgets are hidden and ignore input. Use Engine.UIWantsInput
+// to ignore game controls while the UI is using the input.
+//
+// FUTURE: scrolling lists, drop downs, and gamepad navigation.
+
+import (
+	"log/slog"
+	"unicode/utf8"
+
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// Anchor positions a widget within its parent, see Entity.SetAnchor.
+type Anchor uint8
+
+// Widget anchors. The anchor point of the widget is placed
+// on the same anchor point of its parent.
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorCenter
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// SetUIFont sets the font used by widgets created after this call,
+// eg: "lucon18". The font and its texture must be imported by the
+// application along with the label and col2D shaders.
+func (eng *Engine) SetUIFont(name string) { eng.app.ui.font = name }
+
+// UIWantsInput returns true for mouse if the mouse is over a widget or
+// dragging a slider, and true for keys if a text field has the focus.
+// The application is expected to ignore the input that the UI is using.
+func (eng *Engine) UIWantsInput() (mouse, keys bool) {
+	return eng.app.ui.wantsMouse(), eng.app.ui.wantsKeys()
+}
+
+// UIFocus returns the widget with the keyboard focus.
+// Returns nil if no widget has the focus.
+func (eng *Engine) UIFocus() *Entity {
+	if eng.app.ui.focus == 0 {
+		return nil
+	}
+	return &Entity{app: eng.app, eid: eng.app.ui.focus}
+}
+
+// AddPanel adds a rectangle that groups other widgets.
+// Panels block the mouse from the widgets and game behind them.
+//
+// Depends on Eng.AddScene for a Scene2D, or a parent widget.
+func (e *Entity) AddPanel(w, h float64) (me *Entity) {
+	return e.addWidget(panelWidget, "AddPanel", "", w, h)
+}
+
+// AddButton adds a button showing the given text.
+// Buttons call OnClick when they are pressed.
+//
+// Depends on Eng.AddScene for a Scene2D, or a parent widget.
+func (e *Entity) AddButton(text string, w, h float64) (me *Entity) {
+	return e.addWidget(buttonWidget, "AddButton", text, w, h)
+}
+
+// AddSlider adds a horizontal slider with a value from 0 to 1.
+// Sliders call OnChange when the value changes.
+//
+// Depends on Eng.AddScene for a Scene2D, or a parent widget.
+func (e *Entity) AddSlider(w, h, value float64) (me *Entity) {
+	me = e.addWidget(sliderWidget, "AddSlider", "", w, h)
+	if wg := me.app.ui.get(me.eid); wg != nil {
+		wg.value = lin.Clamp(value, 0, 1)
+	}
+	return me
+}
+
+// AddCheckbox adds a check box followed by the given text. The box is
+// as wide as the widget height. Checkboxes call OnChange when toggled.
+//
+// Depends on Eng.AddScene for a Scene2D, or a parent widget.
+func (e *Entity) AddCheckbox(text string, w, h float64, checked bool) (me *Entity) {
+	me = e.addWidget(checkboxWidget, "AddCheckbox", text, w, h)
+	if wg := me.app.ui.get(me.eid); wg != nil && checked {
+		wg.value = 1
+	}
+	return me
+}
+
+// AddTextField adds a single line of editable text. Text fields
+// call OnChange as the text is edited and OnClick when Return is
+// pressed. Typed text is turned on while the text field has focus.
+//
+// Depends on Eng.AddScene for a Scene2D, or a parent widget.
+func (e *Entity) AddTextField(text string, w, h float64) (me *Entity) {
+	return e.addWidget(textWidget, "AddTextField", text, w, h)
+}
+
+// SetAnchor places the widget within its parent. The offset, in pixels,
+// moves the widget right and down from its anchored location.
+// The default is AnchorTopLeft with no offset.
+//
+// Depends on a widget, eg: Entity.AddPanel.
+func (e *Entity) SetAnchor(anchor Anchor, x, y float64) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil && anchor <= AnchorBottomRight {
+		wg.anchor, wg.ox, wg.oy = anchor, x, y
+		return e
+	}
+	slog.Error("SetAnchor needs widget", "eid", e.eid, "anchor", anchor)
+	return e
+}
+
+// SetWidgetSize changes the widget width and height in pixels.
+//
+// Depends on a widget, eg: Entity.AddPanel.
+func (e *Entity) SetWidgetSize(w, h float64) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.w, wg.h = max(w, 0), max(h, 0)
+		return e
+	}
+	slog.Error("SetWidgetSize needs widget", "eid", e.eid)
+	return e
+}
+
+// SetWidgetColor sets the widget background color. The widget is
+// drawn lighter while the mouse is over it or it has the focus.
+//
+// Depends on a widget, eg: Entity.AddPanel.
+func (e *Entity) SetWidgetColor(r, g, b, a float64) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.color = lin.V4{X: r, Y: g, Z: b, W: a}
+		return e
+	}
+	slog.Error("SetWidgetColor needs widget", "eid", e.eid)
+	return e
+}
+
+// SetWidgetSkin draws the widget background by stretching a nine patch
+// texture, see Entity.AddNinePatch. The skin is tinted by the widget
+// color. The assets are a 2D texture shader and the skin texture, eg:
+// "shd:skin", "tex:color:button".
+//
+// Depends on a widget, eg: Entity.AddButton.
+func (e *Entity) SetWidgetSkin(left, top, right, bottom int, assets ...string) *Entity {
+	wg := e.app.ui.get(e.eid)
+	if wg == nil {
+		slog.Error("SetWidgetSkin needs widget", "eid", e.eid)
+		return e
+	}
+	layer := uint8(0)
+	if m := e.app.models.get(wg.bg.eid); m != nil {
+		layer = m.layer
+	}
+	wg.bg.Dispose(nil)
+	wg.bg = e.AddNinePatch(wg.w, wg.h, left, top, right, bottom, assets...).SetLayer(layer)
+	return e
+}
+
+// OnClick sets the function called when a button is pressed, a
+// checkbox is toggled, or Return is pressed in a text field.
+//
+// Depends on a widget, eg: Entity.AddButton.
+func (e *Entity) OnClick(fn func(widget *Entity)) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.onClick = fn
+		return e
+	}
+	slog.Error("OnClick needs widget", "eid", e.eid)
+	return e
+}
+
+// OnChange sets the function called when a slider value changes,
+// a checkbox is toggled, or the text field text is edited.
+//
+// Depends on a widget, eg: Entity.AddSlider.
+func (e *Entity) OnChange(fn func(widget *Entity)) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.onChange = fn
+		return e
+	}
+	slog.Error("OnChange needs widget", "eid", e.eid)
+	return e
+}
+
+// WidgetValue returns the slider value from 0 to 1,
+// or 1 for a checked checkbox and 0 otherwise.
+//
+// Depends on a widget, eg: Entity.AddSlider.
+func (e *Entity) WidgetValue() float64 {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		return wg.value
+	}
+	slog.Error("WidgetValue needs widget", "eid", e.eid)
+	return 0
+}
+
+// SetWidgetValue sets the slider value from 0 to 1, or checks
+// a checkbox for values over 0.5. OnChange is not called.
+//
+// Depends on a widget, eg: Entity.AddSlider.
+func (e *Entity) SetWidgetValue(value float64) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.value = lin.Clamp(value, 0, 1)
+		if wg.kind == checkboxWidget {
+			wg.value = map[bool]float64{true: 1, false: 0}[value > 0.5]
+		}
+		return e
+	}
+	slog.Error("SetWidgetValue needs widget", "eid", e.eid)
+	return e
+}
+
+// WidgetText returns the widget text.
+//
+// Depends on a widget, eg: Entity.AddTextField.
+func (e *Entity) WidgetText() string {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		return wg.text
+	}
+	slog.Error("WidgetText needs widget", "eid", e.eid)
+	return ""
+}
+
+// SetWidgetText changes the button, checkbox, or text field text.
+// OnChange is not called.
+//
+// Depends on a widget, eg: Entity.AddTextField.
+func (e *Entity) SetWidgetText(text string) *Entity {
+	if wg := e.app.ui.get(e.eid); wg != nil {
+		wg.setText(text)
+		return e
+	}
+	slog.Error("SetWidgetText needs widget", "eid", e.eid)
+	return e
+}
+
+// addWidget adds a widget part and the models that draw it.
+func (e *Entity) addWidget(kind widgetKind, caller, text string, w, h float64) (me *Entity) {
+	me = e.AddPart()
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass2D {
+		slog.Error(caller+" needs 2D scene", "eid", e.eid)
+		return me
+	}
+	ui := me.app.ui
+	depth := 0
+	if parent := ui.get(e.eid); parent != nil {
+		depth = parent.depth + 1
+	}
+	wg := ui.create(me.eid, kind, e.eid, max(w, 0), max(h, 0), depth)
+	layer := uint8(min(2*depth, maxUILayer-1))
+	wg.bg = me.AddModel("shd:col2D", "msh:icon").SetLayer(layer)
+	switch kind {
+	case sliderWidget, checkboxWidget:
+		wg.mark = me.AddModel("shd:col2D", "msh:icon").SetLayer(layer + 1)
+	case textWidget:
+		wg.mark = me.AddModel("shd:col2D", "msh:icon").SetLayer(layer + 1) // caret
+	}
+	if kind != panelWidget && kind != sliderWidget {
+		if ui.font == "" {
+			slog.Error(caller+" needs SetUIFont", "eid", e.eid)
+			return me
+		}
+		wg.font = ui.font
+		wg.label = me.AddLabel(text, 0, "shd:label", "fnt:"+ui.font, "tex:color:"+ui.font)
+		wg.label.SetLayer(layer + 1)
+		wg.text = text
+	}
+	return me
+}
+
+// =============================================================================
+// widget data
+
+const (
+	maxUILayer  = 15   // widgets draw under the debug layer.
+	uiPadding   = 6    // pixels between a widget edge and its text.
+	sliderStep  = 0.05 // slider change for each arrow key press.
+	uiHighlight = 0.15 // color added for hover and focus.
+	uiTrack     = 0.35 // slider track height as a fraction of the widget.
+	uiCaret     = 2.0  // text field caret width in pixels.
+	uiMark      = 0.6  // checkbox mark size as a fraction of the box.
+	uiKnob      = 0.5  // slider knob width as a fraction of the height.
+)
+
+// widgetKind distinguishes the widgets.
+type widgetKind uint8
+
+// Widget kinds.
+const (
+	panelWidget widgetKind = iota
+	buttonWidget
+	sliderWidget
+	checkboxWidget
+	textWidget
+)
+
+// widget is the state and models for one widget.
+type widget struct {
+	kind   widgetKind
+	parent eID     // parent widget or part.
+	depth  int     // number of parent widgets.
+	w, h   float64 // size in pixels.
+	anchor Anchor  // location within the parent.
+	ox, oy float64 // offset from the anchored location.
+	x, y   float64 // top left corner in window pixels, set by layout.
+	color  lin.V4  // background color.
+	value  float64 // slider value or checkbox state.
+	text   string  // button, checkbox, or text field text.
+	font   string  // label font asset name.
+
+	onClick  func(widget *Entity) // button pressed.
+	onChange func(widget *Entity) // value or text changed.
+
+	bg    *Entity // background quad.
+	mark  *Entity // slider knob, checkbox mark, or text caret.
+	label *Entity // text, nil for panels and sliders.
+}
+
+// setText updates the widget text and its label.
+func (wg *widget) setText(text string) {
+	if wg.label != nil && wg.text != text {
+		wg.label.SetText(text)
+	}
+	wg.text = text
+}
+
+// focusable widgets get the keyboard focus.
+func (wg *widget) focusable() bool { return wg.kind != panelWidget }
+
+// contains returns true if the window pixel x,y is over the widget.
+func (wg *widget) contains(x, y float64) bool {
+	return x >= wg.x && x < wg.x+wg.w && y >= wg.y && y < wg.y+wg.h
+}
+
+// slide sets the slider value from the window pixel x.
+// Returns true if the value changed.
+func (wg *widget) slide(x float64) bool {
+	knob := wg.h * uiKnob
+	value := 0.0
+	if wg.w > knob {
+		value = lin.Clamp((x-wg.x-knob/2)/(wg.w-knob), 0, 1)
+	}
+	changed := value != wg.value
+	wg.value = value
+	return changed
+}
+
+// layout places the widget within the given parent rectangle.
+func (wg *widget) layout(px, py, pw, ph float64) {
+	fx := float64(wg.anchor%3) * 0.5 // 0 left, 0.5 center, 1 right.
+	fy := float64(wg.anchor/3) * 0.5 // 0 top, 0.5 middle, 1 bottom.
+	wg.x = px + fx*(pw-wg.w) + wg.ox
+	wg.y = py + fy*(ph-wg.h) + wg.oy
+}
+
+// =============================================================================
+// ui component manager.
+
+// ui tracks the widgets and routes user input to them.
+type ui struct {
+	list  map[eID]*widget
+	order []eID  // widgets in creation order, parents before children.
+	font  string // font for new widgets.
+
+	hover  eID // widget under the mouse.
+	active eID // widget pressed by the mouse.
+	focus  eID // widget with the keyboard focus.
+	typing bool
+}
+
+// newUI creates the widget component manager.
+// There is only expected to be once instance created by the engine.
+func newUI() *ui {
+	return &ui{list: map[eID]*widget{}}
+}
+
+// create a widget for the given part.
+func (u *ui) create(eid eID, kind widgetKind, parent eID, w, h float64, depth int) *widget {
+	wg := &widget{kind: kind, parent: parent, w: w, h: h, depth: depth}
+	wg.color = map[widgetKind]lin.V4{
+		panelWidget:    {X: 0.1, Y: 0.1, Z: 0.12, W: 0.85},
+		buttonWidget:   {X: 0.2, Y: 0.3, Z: 0.5, W: 1},
+		sliderWidget:   {X: 0.25, Y: 0.25, Z: 0.3, W: 1},
+		checkboxWidget: {X: 0.25, Y: 0.25, Z: 0.3, W: 1},
+		textWidget:     {X: 0.05, Y: 0.05, Z: 0.05, W: 1},
+	}[kind]
+	u.list[eid] = wg
+	u.order = append(u.order, eid)
+	return wg
+}
+
+// get the widget for the given entity.
+func (u *ui) get(eid eID) *widget { return u.list[eid] }
+
+// dispose removes the widget. The widget models
+// are disposed with the widget part.
+func (u *ui) dispose(eid eID) {
+	if _, ok := u.list[eid]; !ok {
+		return
+	}
+	delete(u.list, eid)
+	for i, id := range u.order {
+		if id == eid {
+			u.order = append(u.order[:i], u.order[i+1:]...)
+			break
+		}
+	}
+	if u.hover == eid {
+		u.hover = 0
+	}
+	if u.active == eid {
+		u.active = 0
+	}
+	if u.focus == eid {
+		u.focus = 0
+	}
+}
+
+// wantsMouse returns true if the mouse is being used by a widget.
+func (u *ui) wantsMouse() bool { return u.hover != 0 || u.active != 0 }
+
+// wantsKeys returns true if a text field has the keyboard focus.
+func (u *ui) wantsKeys() bool {
+	wg := u.list[u.focus]
+	return wg != nil && wg.kind == textWidget
+}
+
+// visible returns true if the widget and its parent widgets are not culled.
+func (u *ui) visible(app *application, eid eID) bool {
+	for wg := u.list[eid]; wg != nil; wg = u.list[eid] {
+		if n := app.povs.getNode(eid); n == nil || n.cull {
+			return false
+		}
+		eid = wg.parent
+	}
+	return true
+}
+
+// update places the widgets in the window, routes the user input to the
+// widgets, and calls the widget callbacks. Returns true if typed text is
+// needed and true if that changed. Called by the engine each update
+// before the application update.
+func (u *ui) update(app *application, in *Input, ww, wh uint32) (typing, changed bool) {
+	if len(u.list) == 0 {
+		changed, u.typing = u.typing, false
+		return false, changed
+	}
+
+	// layout the widgets. Parents are placed before their children.
+	for _, eid := range u.order {
+		wg := u.list[eid]
+		px, py, pw, ph := 0.0, 0.0, float64(ww), float64(wh)
+		if parent := u.list[wg.parent]; parent != nil {
+			px, py, pw, ph = parent.x, parent.y, parent.w, parent.h
+		}
+		wg.layout(px, py, pw, ph)
+		if p := app.povs.get(eid); p != nil {
+			x, y, _ := p.at()
+			if x != wg.x-px || y != wg.y-py {
+				(&Entity{app: app, eid: eid}).SetAt(wg.x-px, wg.y-py, 0)
+			}
+		}
+	}
+
+	// route the user input, saving the callbacks until the
+	// input has been processed.
+	calls := []func(*Entity){}
+	ids := []eID{}
+	call := func(eid eID, fn func(*Entity)) {
+		if fn != nil {
+			calls, ids = append(calls, fn), append(ids, eid)
+		}
+	}
+	u.route(app, in, call)
+	u.draw(app)
+	for i, fn := range calls {
+		if app.eids.valid(ids[i]) {
+			fn(&Entity{app: app, eid: ids[i]})
+		}
+	}
+	typing = u.wantsKeys()
+	changed, u.typing = typing != u.typing, typing
+	return typing, changed
+}
+
+// route the mouse and keyboard input to the widgets.
+func (u *ui) route(app *application, in *Input, call func(eid eID, fn func(*Entity))) {
+	mx, my := float64(in.Mx), float64(in.My)
+	u.hover = 0
+	for i := len(u.order) - 1; i >= 0; i-- {
+		eid := u.order[i] // later widgets are drawn over earlier widgets.
+		if wg := u.list[eid]; wg.contains(mx, my) && u.visible(app, eid) {
+			u.hover = eid
+			break
+		}
+	}
+
+	// the mouse presses buttons and checkboxes, drags sliders,
+	// and sets the keyboard focus.
+	if _, ok := in.Pressed[KML]; ok {
+		u.active, u.focus = 0, 0
+		if wg := u.list[u.hover]; wg != nil && wg.focusable() {
+			u.active, u.focus = u.hover, u.hover
+			if wg.kind == sliderWidget && wg.slide(mx) {
+				call(u.active, wg.onChange)
+			}
+		}
+	}
+	if wg := u.list[u.active]; wg != nil {
+		if _, ok := in.Down[KML]; ok && wg.kind == sliderWidget && wg.slide(mx) {
+			call(u.active, wg.onChange)
+		}
+		if _, ok := in.Released[KML]; ok {
+			if u.hover == u.active {
+				u.press(u.active, wg, call)
+			}
+			u.active = 0
+		}
+	}
+
+	// the keyboard goes to the focused widget.
+	wg := u.list[u.focus]
+	if wg == nil {
+		u.focus = 0
+		return
+	}
+	if !u.visible(app, u.focus) {
+		u.focus = 0 // hidden widgets lose the focus.
+		return
+	}
+	if _, ok := in.Pressed[KTab]; ok {
+		_, back := in.Down[KShift]
+		u.focus = u.next(app, u.focus, back)
+		return
+	}
+	if _, ok := in.Pressed[KEsc]; ok {
+		u.focus = 0
+		return
+	}
+	_, ret := in.Pressed[KRet]
+	_, space := in.Pressed[KSpace]
+	switch wg.kind {
+	case buttonWidget, checkboxWidget:
+		if ret || space {
+			u.press(u.focus, wg, call)
+		}
+	case sliderWidget:
+		step := 0.0
+		if _, ok := in.Pressed[KALeft]; ok {
+			step -= sliderStep
+		}
+		if _, ok := in.Pressed[KARight]; ok {
+			step += sliderStep
+		}
+		if value := lin.Clamp(wg.value+step, 0, 1); value != wg.value {
+			wg.value = value
+			call(u.focus, wg.onChange)
+		}
+	case textWidget:
+		text := wg.text + in.Text
+		if _, ok := in.Pressed[KDel]; ok && len(text) > 0 {
+			_, size := utf8.DecodeLastRuneInString(text)
+			text = text[:len(text)-size]
+		}
+		if text != wg.text {
+			wg.setText(text)
+			call(u.focus, wg.onChange)
+		}
+		if ret {
+			call(u.focus, wg.onClick)
+		}
+	}
+}
+
+// press clicks a button or toggles a checkbox.
+func (u *ui) press(eid eID, wg *widget, call func(eid eID, fn func(*Entity))) {
+	switch wg.kind {
+	case buttonWidget:
+		call(eid, wg.onClick)
+	case checkboxWidget:
+		wg.value = 1 - wg.value
+		call(eid, wg.onChange)
+		call(eid, wg.onClick)
+	}
+}
+
+// next returns the next, or previous, visible widget that can have
+// the keyboard focus. Widgets are visited in creation order.
+func (u *ui) next(app *application, eid eID, back bool) eID {
+	at := 0
+	for i, id := range u.order {
+		if id == eid {
+			at = i
+		}
+	}
+	step, count := map[bool]int{true: -1, false: 1}[back], len(u.order)
+	for n := 1; n <= count; n++ {
+		id := u.order[((at+step*n)%count+count)%count]
+		if wg := u.list[id]; wg.focusable() && u.visible(app, id) {
+			return id
+		}
+	}
+	return 0
+}
+
+// draw updates the widget models to match the widget state.
+func (u *ui) draw(app *application) {
+	for _, eid := range u.order {
+		wg := u.list[eid]
+		c := wg.color
+		if wg.focusable() && (eid == u.hover || eid == u.focus || eid == u.active) {
+			lift := uiHighlight
+			if eid == u.active {
+				lift *= 2
+			}
+			c.X, c.Y, c.Z = min(c.X+lift, 1), min(c.Y+lift, 1), min(c.Z+lift, 1)
+		}
+		bx, by, bw, bh := wg.w/2, wg.h/2, wg.w, wg.h
+		switch wg.kind {
+		case sliderWidget:
+			bh = wg.h * uiTrack // thin track under the knob.
+		case checkboxWidget:
+			bx, bw = wg.h/2, wg.h // box on the left.
+		}
+		wg.bg.SetAt(bx, by, 0).SetColor(c.X, c.Y, c.Z, c.W)
+		if app.patches.get(wg.bg.eid) != nil {
+			wg.bg.SetNinePatchSize(bw, bh) // skinned background.
+		} else {
+			wg.bg.SetScale(bw, bh, 1)
+		}
+
+		// text is centered in buttons and left aligned otherwise.
+		f, _ := app.ld.getLoadedAsset(assetID(fnt, wg.font)).(*font)
+		tw, th := 0.0, 0.0
+		if f != nil {
+			tw, th = float64(f.measure([]rune(wg.text))), float64(f.lineh)
+		}
+		if wg.label != nil {
+			tx := float64(uiPadding)
+			switch wg.kind {
+			case buttonWidget:
+				tx = (wg.w - tw) / 2
+			case checkboxWidget:
+				tx = wg.h + uiPadding
+			}
+			wg.label.SetAt(tx, (wg.h-th)/2, 0).SetColor(1, 1, 1, 1)
+		}
+		switch wg.kind {
+		case sliderWidget:
+			knob := wg.h * uiKnob
+			wg.mark.SetAt(knob/2+wg.value*(wg.w-knob), wg.h/2, 0).SetScale(knob, wg.h, 1)
+			wg.mark.SetColor(0.8, 0.8, 0.85, 1)
+		case checkboxWidget:
+			size := wg.h * uiMark
+			wg.mark.SetAt(wg.h/2, wg.h/2, 0).SetScale(size, size, 1).SetColor(0.9, 0.9, 0.9, 1)
+			wg.mark.Cull(wg.value < 0.5)
+		case textWidget:
+			wg.mark.SetAt(uiPadding+tw+uiCaret, wg.h/2, 0).SetScale(uiCaret, max(th, wg.h/2), 1)
+			wg.mark.SetColor(1, 1, 1, 1)
+			wg.mark.Cull(eid != u.focus)
+		}
+	}
+}
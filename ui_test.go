@@ -0,0 +1,161 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// go test -run UI
+func TestUI(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	app.ui.font = "lucon18"
+	scene := app.addScene(Scene2D)
+	menu := scene.AddPanel(300, 200).SetAnchor(AnchorCenter, 0, 0)
+	clicks, changes := 0, 0
+	play := menu.AddButton("Play", 100, 40).SetAnchor(AnchorTop, 0, 10).OnClick(func(*Entity) { clicks++ })
+	volume := menu.AddSlider(200, 20, 0.5).SetAnchor(AnchorTop, 0, 60).OnChange(func(*Entity) { changes++ })
+	full := menu.AddCheckbox("Fullscreen", 200, 20, false).SetAnchor(AnchorTop, 0, 100)
+	name := menu.AddTextField("ab", 200, 30).SetAnchor(AnchorBottomRight, -10, -10)
+
+	// input sends one frame of user input to the UI.
+	in := &Input{Pressed: map[int32]bool{}, Down: map[int32]time.Time{}, Released: map[int32]time.Duration{}}
+	input := func(x, y int32, pressed, down, released []int32, text string) (typing, changed bool) {
+		in.Mx, in.My, in.Text = x, y, text
+		clear(in.Pressed)
+		clear(in.Down)
+		clear(in.Released)
+		for _, k := range pressed {
+			in.Pressed[k] = true
+		}
+		for _, k := range down {
+			in.Down[k] = time.Time{}
+		}
+		for _, k := range released {
+			in.Released[k] = 0
+		}
+		return app.ui.update(app, in, 800, 600)
+	}
+	click := func(x, y int32) {
+		input(x, y, []int32{KML}, []int32{KML}, nil, "")
+		input(x, y, nil, nil, []int32{KML}, "")
+	}
+	input(0, 0, nil, nil, nil, "")
+
+	// go test -run UI/layout
+	t.Run("layout", func(t *testing.T) {
+		if wg := app.ui.get(menu.eid); wg.x != 250 || wg.y != 200 {
+			t.Errorf("expected centered panel got %f %f", wg.x, wg.y)
+		}
+		if wg := app.ui.get(play.eid); wg.x != 350 || wg.y != 210 {
+			t.Errorf("expected button at top of panel got %f %f", wg.x, wg.y)
+		}
+		if x, y, _ := app.povs.get(play.eid).at(); x != 100 || y != 10 {
+			t.Errorf("expected button placed in panel got %f %f", x, y)
+		}
+		if wg := app.ui.get(name.eid); wg.x != 340 || wg.y != 360 {
+			t.Errorf("expected text field in bottom right got %f %f", wg.x, wg.y)
+		}
+		if app.ui.get(play.eid).depth != 1 || app.models.get(app.ui.get(play.eid).bg.eid).layer != 2 {
+			t.Errorf("expected nested widget drawn over its parent")
+		}
+	})
+
+	// go test -run UI/button
+	t.Run("button", func(t *testing.T) {
+		input(400, 230, nil, nil, nil, "")
+		if !app.ui.wantsMouse() || app.ui.hover != play.eid {
+			t.Errorf("expected hover over button")
+		}
+		click(400, 230)
+		if clicks != 1 || app.ui.focus != play.eid {
+			t.Errorf("expected click and focus got %d", clicks)
+		}
+		input(400, 230, []int32{KML}, []int32{KML}, nil, "")
+		input(10, 10, nil, nil, []int32{KML}, "") // released off the button.
+		if clicks != 1 {
+			t.Errorf("expected no click when released elsewhere")
+		}
+		click(400, 230)
+		input(0, 0, []int32{KRet}, nil, nil, "")
+		if clicks != 3 {
+			t.Errorf("expected return to press focused button got %d", clicks)
+		}
+	})
+
+	// go test -run UI/slider
+	t.Run("slider", func(t *testing.T) {
+		input(300, 270, []int32{KML}, []int32{KML}, nil, "") // left end of the slider.
+		if v := volume.WidgetValue(); v != 0 || changes != 1 {
+			t.Errorf("expected slider at 0 got %f %d", v, changes)
+		}
+		input(700, 270, nil, []int32{KML}, nil, "") // dragged past the right end.
+		input(700, 270, nil, nil, []int32{KML}, "")
+		if v := volume.WidgetValue(); v != 1 || changes != 2 {
+			t.Errorf("expected dragged slider at 1 got %f %d", v, changes)
+		}
+		input(0, 0, []int32{KALeft}, nil, nil, "")
+		if v := volume.WidgetValue(); !lin.Aeq(v, 1-sliderStep) {
+			t.Errorf("expected arrow key to move slider got %f", v)
+		}
+	})
+
+	// go test -run UI/checkbox
+	t.Run("checkbox", func(t *testing.T) {
+		click(305, 310)
+		if full.WidgetValue() != 1 || app.povs.getNode(app.ui.get(full.eid).mark.eid).cull {
+			t.Errorf("expected checked box")
+		}
+		input(0, 0, []int32{KSpace}, nil, nil, "")
+		if full.WidgetValue() != 0 {
+			t.Errorf("expected space to toggle checkbox")
+		}
+	})
+
+	// go test -run UI/text
+	t.Run("text", func(t *testing.T) {
+		if typing, changed := input(0, 0, []int32{KTab}, nil, nil, ""); !typing || !changed || app.ui.focus != name.eid {
+			t.Errorf("expected tab to focus text field")
+		}
+		submitted := ""
+		name.OnClick(func(e *Entity) { submitted = e.WidgetText() })
+		input(0, 0, nil, nil, nil, "cé")
+		input(0, 0, []int32{KDel}, nil, nil, "")
+		input(0, 0, []int32{KRet}, nil, nil, "")
+		if name.WidgetText() != "abc" || submitted != "abc" {
+			t.Errorf("expected edited text got %q %q", name.WidgetText(), submitted)
+		}
+		if str, _, _ := app.ui.get(name.eid).label.labelData(); str != "abc" {
+			t.Errorf("expected label text updated got %q", str)
+		}
+		if !app.ui.wantsKeys() {
+			t.Errorf("expected keys used by text field")
+		}
+		if typing, changed := input(0, 0, []int32{KEsc}, nil, nil, ""); typing || !changed {
+			t.Errorf("expected escape to end typing")
+		}
+	})
+
+	// go test -run UI/hidden
+	t.Run("hidden", func(t *testing.T) {
+		menu.Cull(true)
+		click(400, 230)
+		if clicks != 3 || app.ui.hover != 0 {
+			t.Errorf("expected hidden widgets to ignore input")
+		}
+		menu.Cull(false)
+		input(400, 230, nil, nil, nil, "")
+		menu.Dispose(nil)
+		if len(app.ui.list) != 0 || len(app.ui.order) != 0 || app.ui.hover != 0 {
+			t.Errorf("expected widgets disposed")
+		}
+		if app.addScene(Scene3D).AddPanel(10, 10); len(app.ui.list) != 0 {
+			t.Errorf("expected 2D scene only")
+		}
+	})
+}
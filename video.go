@@ -0,0 +1,667 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// video.go streams movie frames into a model texture so that intro
+// cutscenes and in-world screens can play movies, eg:
+//
+//	eng.ImportAssets("icon.shd")
+//	screen := ui.AddModel("shd:icon", "msh:icon").SetAt(400, 300, 0).SetScale(640, 360, 1)
+//	screen.AddVideo("intro.webm").PlayVideo()
+//
+// Movies are ".ogv", ".webm", or ".mkv" files from the "assets/video"
+// directory. A movie is opened and its codecs are set up on a loader
+// goroutine. Once loaded, the movie file is streamed: a reader goroutine
+// demuxes a few frames ahead of playback, see load.VideoStream, decoding
+// the sound track as it is read. The frames are decoded in order as the
+// movie plays, with each shown frame uploaded to the model's updatable
+// texture. The sound track is queued to an audio stream, see
+// audio.Context.LoadStream, and starts at the listener location with
+// the first frame, and stops when the movie is stopped.
+//
+// The engine decodes Theora video with Vorbis audio, the usual ".ogv"
+// movie, as well as Motion JPEG video, from ".mkv" files, and 8 or 16 bit
+// PCM audio. Movies using other video codecs, eg: VP8, VP9, AV1, fail to
+// load with errors.ErrUnsupported unless the application registers a
+// decoder using RegisterVideoCodec. Movies using other audio codecs,
+// eg: Opus, play without sound unless the application registers
+// a decoder using RegisterAudioCodec.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gazed/vu/internal/video/theora"
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/render"
+	"github.com/jfreymuth/vorbis"
+)
+
+// VideoDecoder decodes the compressed frames of a movie. DecodeFrame
+// is called with each frame in order and draws the decoded frame into
+// img, which is the movie size. Decoders keep any reference frames
+// needed by later frames. Empty frames repeat the previous frame.
+type VideoDecoder interface {
+	DecodeFrame(frame []byte, img *image.NRGBA) error
+}
+
+// AudioDecoder decodes the compressed audio packets of a movie. DecodeAudio
+// is called with each packet in order and appends the decoded samples to
+// pcm as 16 bit samples with the channels interleaved.
+type AudioDecoder interface {
+	DecodeAudio(packet []byte, pcm []int16) ([]int16, error)
+}
+
+// RegisterVideoCodec provides the decoder for the named video codec,
+// eg: "vp8", "vp9". The open function is given the movie description,
+// including the codec setup headers, and is called from the loader
+// goroutine. Registering "theora" or "mjpeg" replaces the engine decoder.
+func RegisterVideoCodec(codec string, open func(vid *load.VideoData) (VideoDecoder, error)) {
+	codecs.lock.Lock()
+	codecs.video[codec] = open
+	codecs.lock.Unlock()
+}
+
+// RegisterAudioCodec provides the decoder for the named audio codec,
+// eg: "opus". Movies with an unregistered audio codec are played
+// without sound. The open function is called each time the movie
+// starts, from the frame reader goroutine. Registering "vorbis"
+// or "pcm" replaces the engine decoder.
+func RegisterAudioCodec(codec string, open func(vid *load.VideoData) (AudioDecoder, error)) {
+	codecs.lock.Lock()
+	codecs.audio[codec] = open
+	codecs.lock.Unlock()
+}
+
+// codecs are the registered video and audio decoders.
+var codecs = struct {
+	lock  sync.Mutex
+	video map[string]func(vid *load.VideoData) (VideoDecoder, error)
+	audio map[string]func(vid *load.VideoData) (AudioDecoder, error)
+}{
+	video: map[string]func(vid *load.VideoData) (VideoDecoder, error){
+		"theora": func(vid *load.VideoData) (VideoDecoder, error) { return theora.NewDecoder(vid.Headers) },
+		"mjpeg":  func(vid *load.VideoData) (VideoDecoder, error) { return mjpegDecoder{}, nil },
+	},
+	audio: map[string]func(vid *load.VideoData) (AudioDecoder, error){
+		"vorbis": newVorbisDecoder,
+		"pcm":    func(vid *load.VideoData) (AudioDecoder, error) { return pcmDecoder{bits: vid.SampleBits}, nil },
+	},
+}
+
+// AddVideo loads the named movie, eg: "intro.webm", and shows its frames
+// using the model texture. The model shader needs a "color" sampler.
+// The movie is ready to play once it has loaded.
+//
+// Depends on Entity.AddModel.
+func (e *Entity) AddVideo(name string) *Entity {
+	if m := e.app.models.get(e.eid); m == nil {
+		slog.Error("AddVideo needs AddModel", "eid", e.eid)
+		return e
+	}
+	if v := e.app.videos.get(e.eid); v != nil {
+		slog.Error("AddVideo already set", "eid", e.eid)
+		return e
+	}
+	e.app.videos.create(e.eid, name)
+	return e
+}
+
+// PlayVideo starts the movie, or restarts a movie that has finished.
+// Playing begins once the movie has loaded.
+//
+// Depends on Entity.AddVideo.
+func (e *Entity) PlayVideo() *Entity {
+	if v := e.app.videos.get(e.eid); v != nil {
+		if !v.playing && v.done {
+			v.rewind()
+		}
+		v.playing = true
+		return e
+	}
+	slog.Error("PlayVideo needs AddVideo", "eid", e.eid)
+	return e
+}
+
+// StopVideo stops the movie and its sound track, and rewinds
+// the movie to the start. The last shown frame remains visible.
+//
+// Depends on Entity.AddVideo.
+func (e *Entity) StopVideo() *Entity {
+	if v := e.app.videos.get(e.eid); v != nil {
+		v.playing = false
+		v.rewind()
+		return e
+	}
+	slog.Error("StopVideo needs AddVideo", "eid", e.eid)
+	return e
+}
+
+// SetVideoLoop restarts the movie, and its sound track,
+// each time the movie finishes when loop is true.
+//
+// Depends on Entity.AddVideo.
+func (e *Entity) SetVideoLoop(loop bool) *Entity {
+	if v := e.app.videos.get(e.eid); v != nil {
+		v.loop = loop
+		return e
+	}
+	slog.Error("SetVideoLoop needs AddVideo", "eid", e.eid)
+	return e
+}
+
+// VideoPlaying returns true while the movie is playing.
+// A movie that is not looped stops playing when it finishes.
+//
+// Depends on Entity.AddVideo.
+func (e *Entity) VideoPlaying() bool {
+	if v := e.app.videos.get(e.eid); v != nil {
+		return v.playing
+	}
+	slog.Error("VideoPlaying needs AddVideo", "eid", e.eid)
+	return false
+}
+
+// VideoTime returns the playback time from the start of the movie.
+//
+// Depends on Entity.AddVideo.
+func (e *Entity) VideoTime() time.Duration {
+	if v := e.app.videos.get(e.eid); v != nil {
+		return v.clock
+	}
+	slog.Error("VideoTime needs AddVideo", "eid", e.eid)
+	return 0
+}
+
+// =============================================================================
+// video data
+
+// videoBuffer is the number of frames read ahead of playback, both
+// by the frame reader and by playback, which reads ahead to queue
+// the sound track.
+const videoBuffer = 16
+
+// videoSource reads the demuxed movie packets, see load.VideoStream.
+type videoSource interface {
+	Next() (p load.VideoPacket, audio bool, err error)
+	Close() error
+}
+
+// videoOpener opens a movie from its start.
+type videoOpener func() (*load.VideoData, videoSource, error)
+
+// soundOpener creates a sound track decoder for a movie start.
+type soundOpener func() (AudioDecoder, error)
+
+// videoFrame is a frame read ahead of playback, the decoded
+// sound track samples that were read with the frames, or the
+// read error.
+type videoFrame struct {
+	packet load.VideoPacket
+	pcm    []int16 // sound track samples, when audio is true.
+	audio  bool
+	err    error
+}
+
+// video is one movie along with its playback state.
+type video struct {
+	name    string             // movie file name.
+	open    videoOpener        // streams the movie file.
+	data    *load.VideoData    // movie description, nil until loaded.
+	dec     VideoDecoder       // video frame decoder.
+	sound   soundOpener        // sound track decoder, nil for no sound.
+	img     *image.NRGBA       // decoded frame.
+	ready   chan videoLoad     // loader results.
+	frames  chan videoFrame    // frames read ahead, closed at the movie end.
+	stop    chan struct{}      // closed to stop the frame reader.
+	queue   []load.VideoPacket // frames read from the reader, not yet decoded.
+	pcm     []int16            // sound track samples not yet queued.
+	last    time.Duration      // time of the last decoded frame.
+	next    int                // next frame to decode.
+	clock   time.Duration      // playback time.
+	playing bool               // true while playing.
+	loop    bool               // true to restart when finished.
+	ended   bool               // true once the frame reader has finished.
+	done    bool               // true once the last frame has been shown.
+
+	// sound track state.
+	sid      uint64 // audio stream reference.
+	uploaded bool   // true once the sound track stream is on the audio device.
+	started  bool   // true while the sound track is playing.
+	cued     bool   // true once the sound track has started from the movie start.
+}
+
+// videoLoad is the result of loading a movie.
+type videoLoad struct {
+	data  *load.VideoData
+	dec   VideoDecoder
+	sound soundOpener
+	err   error
+}
+
+// length returns the movie play time, which
+// includes showing the last frame.
+func (v *video) length() time.Duration {
+	if v.data.FPS > 0 {
+		return v.last + time.Duration(float64(time.Second)/v.data.FPS)
+	}
+	return v.last
+}
+
+// rewind moves playback to the start of the movie. The frame reader
+// restarts from the first frame, which is a key frame, so decoding
+// can restart.
+func (v *video) rewind() {
+	v.next, v.clock, v.done, v.cued = 0, 0, false, false
+	v.last, v.queue, v.pcm, v.ended = 0, nil, nil, false
+	if v.data != nil {
+		v.stopReader()
+		v.startReader()
+	}
+}
+
+// startReader starts a goroutine that reads the movie frames ahead of
+// playback. The reader is stopped with stopReader.
+func (v *video) startReader() {
+	v.frames, v.stop = make(chan videoFrame, videoBuffer), make(chan struct{})
+	go readFrames(v.open, v.sound, v.frames, v.stop)
+}
+
+// stopReader stops the frame reader, if any.
+func (v *video) stopReader() {
+	if v.stop != nil {
+		close(v.stop)
+		v.frames, v.stop = nil, nil
+	}
+}
+
+// readFrames opens the movie and sends its video frames, along with the
+// decoded sound track, until the end of the movie, a read error, or until
+// stopped. The frames channel is closed when the reader is done. The
+// sound track is dropped if it can't be decoded, leaving a silent movie.
+func readFrames(open videoOpener, sound soundOpener, frames chan<- videoFrame, stop <-chan struct{}) {
+	defer close(frames)
+	_, src, err := open()
+	var adec AudioDecoder
+	if err == nil && sound != nil {
+		if adec, err = sound(); err != nil {
+			slog.Warn("video sound track", "error", err)
+			adec, err = nil, nil
+		}
+	}
+	for err == nil {
+		f := videoFrame{}
+		if f.packet, f.audio, err = src.Next(); err != nil {
+			continue
+		}
+		if f.audio {
+			if adec == nil {
+				continue
+			}
+			if f.pcm, err = adec.DecodeAudio(f.packet.Data, nil); err != nil {
+				slog.Warn("video sound track", "error", err)
+				adec, err = nil, nil
+				continue
+			}
+			f.packet = load.VideoPacket{}
+		}
+		select {
+		case frames <- f:
+		case <-stop:
+			src.Close()
+			return
+		}
+	}
+	if src != nil {
+		src.Close()
+	}
+	if !errors.Is(err, io.EOF) {
+		select {
+		case frames <- videoFrame{err: err}:
+		case <-stop:
+		}
+	}
+}
+
+// read takes what the frame reader has sent, keeping the sound track
+// samples and queuing up to videoBuffer frames. Waits for the frame
+// reader when no frames are queued.
+func (v *video) read() error {
+	for !v.ended && len(v.queue) < videoBuffer {
+		var f videoFrame
+		var ok bool
+		if len(v.queue) == 0 {
+			f, ok = <-v.frames
+		} else {
+			select {
+			case f, ok = <-v.frames:
+			default:
+				return nil // nothing ready.
+			}
+		}
+		switch {
+		case !ok:
+			v.ended = true
+		case f.err != nil:
+			return fmt.Errorf("frame %d: %w", v.next+len(v.queue), f.err)
+		case f.audio:
+			v.pcm = append(v.pcm, f.pcm...)
+		default:
+			v.queue = append(v.queue, f.packet)
+		}
+	}
+	return nil
+}
+
+// advance moves the playback clock forward, decoding the frames
+// that are due. Returns true if the frame image changed. Frames
+// that are skipped are still decoded since later frames need them.
+// Frames are read ahead so that the sound track samples that play
+// with them are available. Waits for the frame reader if it has
+// fallen behind.
+func (v *video) advance(delta time.Duration) (changed bool, err error) {
+	v.clock += delta
+	for {
+		if err = v.read(); err != nil {
+			return changed, err
+		}
+		if len(v.queue) == 0 || v.queue[0].Time > v.clock {
+			break
+		}
+		if err = v.dec.DecodeFrame(v.queue[0].Data, v.img); err != nil {
+			return changed, fmt.Errorf("frame %d: %w", v.next, err)
+		}
+		v.last = v.queue[0].Time
+		v.queue[0] = load.VideoPacket{}
+		v.queue = v.queue[1:]
+		v.next++
+		changed = true
+	}
+	if v.ended && len(v.queue) == 0 && v.clock >= v.length() {
+		v.done = true
+	}
+	return changed, nil
+}
+
+// openStream opens the named movie file as a stream.
+func openStream(name string) videoOpener {
+	return func() (*load.VideoData, videoSource, error) {
+		vs, err := load.Video(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &vs.VideoData, vs, nil
+	}
+}
+
+// loadVideo opens the movie and its codecs.
+// Expected to be run on a loader goroutine.
+func loadVideo(name string, open videoOpener, ready chan<- videoLoad) {
+	vl := videoLoad{}
+	vl.data, vl.dec, vl.sound, vl.err = openVideo(name, open)
+	ready <- vl
+}
+
+// openVideo reads the movie description and creates the frame decoder.
+// The sound track is decoded by the frame reader as the movie plays.
+func openVideo(name string, open videoOpener) (vid *load.VideoData, dec VideoDecoder, sound soundOpener, err error) {
+	vid, src, err := open()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	src.Close()
+	dec, sound, err = openCodecs(name, vid)
+	return vid, dec, sound, err
+}
+
+// openCodecs creates the frame decoder and checks that the sound track
+// can be decoded. The returned sound opener is nil for silent movies.
+func openCodecs(name string, vid *load.VideoData) (dec VideoDecoder, sound soundOpener, err error) {
+	if vid.Width <= 0 || vid.Height <= 0 {
+		return nil, nil, fmt.Errorf("video %s: no frames", name)
+	}
+	codecs.lock.Lock()
+	openFrames, vok := codecs.video[vid.Codec]
+	openAudio, aok := codecs.audio[vid.AudioCodec]
+	codecs.lock.Unlock()
+	if !vok {
+		return nil, nil, fmt.Errorf("video %s codec %s: %w", name, vid.Codec, errors.ErrUnsupported)
+	}
+	if dec, err = openFrames(vid); err != nil {
+		return nil, nil, fmt.Errorf("video %s: %w", name, err)
+	}
+	switch {
+	case vid.AudioCodec == "":
+		return dec, nil, nil
+	case !aok:
+		slog.Warn("video audio codec not registered", "video", name, "codec", vid.AudioCodec)
+		return dec, nil, nil
+	case vid.Rate <= 0 || (vid.Channels != 1 && vid.Channels != 2):
+		slog.Warn("video sound track ignored", "video", name, "channels", vid.Channels, "rate", vid.Rate)
+		return dec, nil, nil
+	}
+	if _, err := openAudio(vid); err != nil {
+		return nil, nil, fmt.Errorf("video %s audio: %w", name, err)
+	}
+	sound = func() (AudioDecoder, error) { return openAudio(vid) }
+	return dec, sound, nil
+}
+
+// mjpegDecoder decodes motion JPEG frames where
+// each frame is a complete JPEG image.
+type mjpegDecoder struct{}
+
+// DecodeFrame implements VideoDecoder.
+func (mjpegDecoder) DecodeFrame(frame []byte, img *image.NRGBA) error {
+	if len(frame) == 0 {
+		return nil // repeat the previous frame.
+	}
+	src, err := jpeg.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	return nil
+}
+
+// vorbisDecoder decodes Vorbis packets using the
+// github.com/jfreymuth/vorbis decoder.
+type vorbisDecoder struct {
+	dec vorbis.Decoder
+	buf []float32 // space for the decoded samples of one packet.
+}
+
+// newVorbisDecoder reads the three Vorbis header packets.
+func newVorbisDecoder(vid *load.VideoData) (AudioDecoder, error) {
+	d := &vorbisDecoder{}
+	for _, header := range vid.AudioHeaders {
+		if err := d.dec.ReadHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	if !d.dec.HeadersRead() {
+		return nil, errors.New("vorbis: missing headers")
+	}
+	d.buf = make([]float32, d.dec.BufferSize())
+	return d, nil
+}
+
+// DecodeAudio implements AudioDecoder.
+func (d *vorbisDecoder) DecodeAudio(packet []byte, pcm []int16) ([]int16, error) {
+	samples, err := d.dec.DecodeInto(packet, d.buf)
+	if err != nil {
+		return pcm, err
+	}
+	for _, s := range samples {
+		pcm = append(pcm, int16(max(-1, min(s, 1))*32767))
+	}
+	return pcm, nil
+}
+
+// pcmDecoder converts little endian 8 or 16 bit samples.
+type pcmDecoder struct{ bits int }
+
+// DecodeAudio implements AudioDecoder.
+func (d pcmDecoder) DecodeAudio(packet []byte, pcm []int16) ([]int16, error) {
+	switch d.bits {
+	case 8:
+		for _, b := range packet {
+			pcm = append(pcm, (int16(b)-128)<<8) // 8 bit samples are unsigned.
+		}
+	case 16:
+		for i := 0; i+1 < len(packet); i += 2 {
+			pcm = append(pcm, int16(binary.LittleEndian.Uint16(packet[i:])))
+		}
+	default:
+		return pcm, fmt.Errorf("%w: pcm sample bits %d", errors.ErrUnsupported, d.bits)
+	}
+	return pcm, nil
+}
+
+// =============================================================================
+// videos component manager.
+
+// videoAudio plays the movie sound tracks, see audio.Context.
+type videoAudio interface {
+	LoadStream(sound *uint64, channels, rate int) error
+	QueueStream(sound uint64, pcm []int16)
+	PlaySound(sound uint64, x, y, z float64)
+	StopSound(sound uint64)
+}
+
+// videos tracks the movies.
+type videos struct {
+	list map[eID]*video
+}
+
+// newVideos creates the video component manager.
+// There is only expected to be once instance created by the engine.
+func newVideos() *videos {
+	return &videos{list: map[eID]*video{}}
+}
+
+// create a video for the given model entity and start loading the movie.
+func (vs *videos) create(eid eID, name string) *video {
+	v := &video{name: name, open: openStream(name), ready: make(chan videoLoad, 1)}
+	vs.list[eid] = v
+	go loadVideo(name, v.open, v.ready)
+	return v
+}
+
+// get the video for the given entity.
+func (vs *videos) get(eid eID) *video { return vs.list[eid] }
+
+// dispose removes the video and its sound track. The frame
+// textures are released with the video model.
+func (vs *videos) dispose(eng *Engine, eid eID) {
+	if v := vs.list[eid]; v != nil {
+		delete(vs.list, eid)
+		v.stopReader()
+		if v.uploaded {
+			eng.ac.DropSound(v.sid, 0)
+		}
+	}
+}
+
+// update finishes loading movies and plays the frames and
+// sound tracks of the playing movies. The audio is nil when
+// there is no audio device. Called by the engine once each update.
+func (vs *videos) update(app *application, rc render.Loader, ac videoAudio, delta time.Duration) {
+	for eid, v := range vs.list {
+		m := app.models.get(eid)
+		if m == nil {
+			continue
+		}
+		if v.data == nil {
+			select {
+			case vl := <-v.ready:
+				if err := vs.loaded(eid, v, vl, m, app, rc, ac); err != nil {
+					slog.Error("video load", "video", v.name, "error", err)
+					delete(vs.list, eid)
+				}
+			default:
+			}
+			continue // play from the next update.
+		}
+
+		// stop the sound track for stopped and finished movies.
+		if !v.playing {
+			if v.started {
+				ac.StopSound(v.sid)
+				v.started = false
+			}
+			continue
+		}
+		changed, err := v.advance(delta)
+		if err != nil {
+			slog.Error("video decode", "video", v.name, "error", err)
+			v.playing = false
+			continue
+		}
+		if v.uploaded {
+			ac.QueueStream(v.sid, v.pcm) // sound track read with the frames.
+			v.pcm = v.pcm[:0]
+			if !v.cued { // restarts a playing sound track.
+				x, y, z := 0.0, 0.0, 0.0
+				if p := app.povs.get(app.sounds.listener); p != nil {
+					x, y, z = p.at()
+				}
+				ac.PlaySound(v.sid, x, y, z)
+				v.started, v.cued = true, true
+			}
+		}
+		if changed {
+			if err := m.updateTexture(rc, v.img); err != nil {
+				slog.Error("video upload", "video", v.name, "error", err)
+			}
+		}
+		if v.done {
+			if v.started {
+				ac.StopSound(v.sid)
+				v.started = false
+			}
+			if v.loop {
+				v.rewind() // sound track restarts with the next update.
+				continue
+			}
+			v.playing = false
+		}
+	}
+}
+
+// loaded creates the frame textures and the sound track
+// stream once the movie has been loaded.
+func (vs *videos) loaded(eid eID, v *video, vl videoLoad, m *model, app *application, rc render.Loader, ac videoAudio) error {
+	if vl.err != nil {
+		return vl.err
+	}
+	v.data, v.dec = vl.data, vl.dec
+	v.img = image.NewNRGBA(image.Rect(0, 0, v.data.Width, v.data.Height))
+	for i := 3; i < len(v.img.Pix); i += 4 {
+		v.img.Pix[i] = 255 // opaque black until the first frame.
+	}
+	if len(m.updatable) > 0 {
+		return fmt.Errorf("model already has an updatable texture")
+	}
+	if err := m.addUpdatable(app, rc, fmt.Sprintf("video%d", eid), v.img); err != nil {
+		return err
+	}
+	if vl.sound != nil && ac != nil {
+		if err := ac.LoadStream(&v.sid, v.data.Channels, v.data.Rate); err != nil {
+			slog.Error("video sound track", "video", v.name, "error", err)
+		} else {
+			v.sound, v.uploaded = vl.sound, true
+		}
+	}
+	v.startReader() // without a sound track if there is no stream.
+	return nil
+}
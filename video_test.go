@@ -0,0 +1,244 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+)
+
+// go test -run Video
+func TestVideo(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	ui := app.addScene(Scene2D)
+
+	// movie returns a 4 frame, 10 fps, motion jpeg movie of solid colors
+	// with a 16 bit mono sound track.
+	colors := []color.NRGBA{{R: 255, A: 255}, {G: 255, A: 255}, {B: 255, A: 255}, {R: 255, G: 255, B: 255, A: 255}}
+	movie := func(frames int) *testMovie {
+		tm := &testMovie{vid: load.VideoData{Width: 16, Height: 8, FPS: 10, Codec: "mjpeg", AudioCodec: "pcm", Channels: 1, Rate: 100, SampleBits: 16}}
+		for i := 0; i < frames; i++ {
+			c := colors[i%len(colors)]
+			img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+			for p := 0; p < len(img.Pix); p += 4 {
+				img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = c.R, c.G, c.B, c.A
+			}
+			buf := &bytes.Buffer{}
+			jpeg.Encode(buf, img, &jpeg.Options{Quality: 100})
+			at := time.Duration(i) * 100 * time.Millisecond
+			tm.frames = append(tm.frames, load.VideoPacket{Time: at, Key: i == 0, Data: buf.Bytes()})
+			tm.sounds = append(tm.sounds, load.VideoPacket{Time: at, Data: []byte{0, 0x40, 0, 0xC0}})
+		}
+		return tm
+	}
+
+	// loaded adds a video entity that has already loaded the movie.
+	loaded := func(tm *testMovie) (*Entity, *video) {
+		screen := ui.AddModel("shd:icon", "msh:icon", "tex:color:test")
+		v := &video{name: "test.webm", open: tm.open, ready: make(chan videoLoad, 1)}
+		app.videos.list[screen.eid] = v
+		loadVideo(v.name, v.open, v.ready)
+		return screen, v
+	}
+
+	// go test -run Video/open
+	t.Run("open", func(t *testing.T) {
+		tm := movie(4)
+		_, _, sound, err := openVideo("test.webm", tm.open)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sound == nil || tm.read.Load() != 0 {
+			t.Fatalf("expected sound track to be decoded as the movie plays")
+		}
+		adec, _ := sound()
+		if pcm, err := adec.DecodeAudio(tm.sounds[0].Data, nil); err != nil || len(pcm) != 2 || pcm[0] != 0x4000 || pcm[1] != -0x4000 {
+			t.Errorf("expected pcm samples got %v %v", pcm, err)
+		}
+		vp9 := movie(4)
+		vp9.vid.Codec = "vp9"
+		if _, _, _, err := openVideo("test.webm", vp9.open); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected unregistered codec error got %v", err)
+		}
+		silent := movie(4)
+		silent.vid.AudioCodec = "opus"
+		if _, _, sound, err := openVideo("test.webm", silent.open); err != nil || sound != nil {
+			t.Errorf("expected movie without sound got %v %v", sound, err)
+		}
+		if _, _, _, err := openVideo("missing.webm", openStream("missing.webm")); !errors.Is(err, load.ErrAssetNotFound) {
+			t.Errorf("expected missing movie got %v", err)
+		}
+	})
+
+	// the engine decodes theora and vorbis, checking their headers on load.
+	// go test -run Video/codecs
+	t.Run("codecs", func(t *testing.T) {
+		ogv := movie(4)
+		ogv.vid.Codec, ogv.vid.Headers = "theora", [][]byte{[]byte("\x80theora")}
+		if _, _, _, err := openVideo("test.ogv", ogv.open); err == nil || errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected theora header error got %v", err)
+		}
+		ogv = movie(4)
+		ogv.vid.AudioCodec, ogv.vid.Rate, ogv.vid.AudioHeaders = "vorbis", 44100, [][]byte{[]byte("\x01vorbis")}
+		if _, _, _, err := openVideo("test.ogv", ogv.open); err == nil || errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected vorbis header error got %v", err)
+		}
+		surround := movie(4)
+		surround.vid.Channels = 6
+		if _, _, sound, err := openVideo("test.ogv", surround.open); err != nil || sound != nil {
+			t.Errorf("expected movie without 5.1 sound got %v", err)
+		}
+	})
+
+	// go test -run Video/play
+	t.Run("play", func(t *testing.T) {
+		screen, v := loaded(movie(4))
+		screen.PlayVideo()
+		app.videos.update(app, rc, nil, 0) // loads.
+		m := app.models.get(screen.eid)
+		if v.data == nil || len(m.updatable) != 2 || m.texs[0] != m.updatable[0] || m.texs[0].w != 16 {
+			t.Fatalf("expected updatable frame textures")
+		}
+		shown := m.texs[0]
+		app.videos.update(app, rc, nil, 0) // first frame.
+		if c := v.img.NRGBAAt(8, 4); c.R < 250 || c.G > 5 || m.texs[0] == shown {
+			t.Errorf("expected red first frame got %v", c)
+		}
+		app.videos.update(app, rc, nil, 250*time.Millisecond) // skips a frame.
+		if c := v.img.NRGBAAt(8, 4); c.B < 250 || v.next != 3 {
+			t.Errorf("expected blue third frame got %v %d", c, v.next)
+		}
+		app.videos.update(app, rc, nil, 200*time.Millisecond)
+		if screen.VideoPlaying() || screen.VideoTime() != 450*time.Millisecond {
+			t.Errorf("expected finished movie at %v", screen.VideoTime())
+		}
+		screen.PlayVideo() // restarts.
+		if !screen.VideoPlaying() || screen.VideoTime() != 0 || v.next != 0 {
+			t.Errorf("expected restarted movie")
+		}
+	})
+
+	// go test -run Video/sound
+	t.Run("sound", func(t *testing.T) {
+		ac := &mac{}
+		screen, v := loaded(movie(4))
+		screen.SetVideoLoop(true).PlayVideo()
+		app.videos.update(app, rc, ac, 0) // loads.
+		if !v.uploaded || ac.loads != 1 {
+			t.Fatalf("expected sound track stream")
+		}
+		app.videos.update(app, rc, ac, 0)
+		if ac.plays != 1 || !v.started {
+			t.Errorf("expected sound track to start with the first frame")
+		}
+		if len(ac.pcm) == 0 || ac.pcm[0] != 0x4000 || len(ac.pcm) > 2*(videoBuffer+1) {
+			t.Errorf("expected sound track read with the frames got %d samples", len(ac.pcm))
+		}
+		app.videos.update(app, rc, ac, 400*time.Millisecond) // loops.
+		if !screen.VideoPlaying() || screen.VideoTime() != 0 || ac.stops != 1 {
+			t.Errorf("expected looped movie %v %d", screen.VideoTime(), ac.stops)
+		}
+		app.videos.update(app, rc, ac, 0)
+		if ac.plays != 2 {
+			t.Errorf("expected sound track to restart")
+		}
+		screen.StopVideo()
+		app.videos.update(app, rc, ac, 0)
+		if ac.stops != 2 || v.started {
+			t.Errorf("expected stopped sound track")
+		}
+	})
+
+	// go test -run Video/stream
+	t.Run("stream", func(t *testing.T) {
+		long := movie(100)
+		screen, v := loaded(long)
+		long.read.Store(0) // ignore the movie description read.
+		screen.PlayVideo()
+		app.videos.update(app, rc, nil, 0) // loads.
+		app.videos.update(app, rc, nil, 0) // first frame.
+		time.Sleep(10 * time.Millisecond)  // let the reader fill its buffer.
+
+		// frames and sounds are buffered by the reader and by playback.
+		if read := long.read.Load(); read > 2*(2*videoBuffer+4) {
+			t.Errorf("expected frames to be read ahead of playback got %d", read)
+		}
+		screen.Dispose(&Engine{app: app})
+		if v.stop != nil {
+			t.Errorf("expected stopped frame reader")
+		}
+	})
+
+	// go test -run Video/errors
+	t.Run("errors", func(t *testing.T) {
+		bad := movie(4)
+		bad.frames[1].Data = []byte("not a jpeg")
+		screen, _ := loaded(bad)
+		screen.PlayVideo()
+		app.videos.update(app, rc, nil, 0)
+		app.videos.update(app, rc, nil, 100*time.Millisecond)
+		if screen.VideoPlaying() {
+			t.Errorf("expected decode error to stop the movie")
+		}
+		screen.Dispose(&Engine{app: app})
+		if app.videos.get(screen.eid) != nil {
+			t.Errorf("expected disposed video")
+		}
+	})
+}
+
+// mock audio context.
+type mac struct {
+	loads, plays, stops int
+	pcm                 []int16 // queued samples.
+}
+
+func (ac *mac) LoadStream(sound *uint64, channels, rate int) error { ac.loads++; return nil }
+func (ac *mac) QueueStream(sound uint64, pcm []int16)              { ac.pcm = append(ac.pcm, pcm...) }
+func (ac *mac) PlaySound(sound uint64, x, y, z float64)            { ac.plays++ }
+func (ac *mac) StopSound(sound uint64)                             { ac.stops++ }
+
+// testMovie is an in memory movie that counts the packets read.
+type testMovie struct {
+	vid    load.VideoData
+	frames []load.VideoPacket
+	sounds []load.VideoPacket
+	read   atomic.Int32
+}
+
+// open implements videoOpener.
+func (tm *testMovie) open() (*load.VideoData, videoSource, error) {
+	vid := tm.vid
+	return &vid, &testPackets{tm: tm}, nil
+}
+
+// testPackets reads the movie frames each followed by its sound.
+type testPackets struct {
+	tm   *testMovie
+	next int
+}
+
+func (tp *testPackets) Close() error { return nil }
+func (tp *testPackets) Next() (p load.VideoPacket, audio bool, err error) {
+	if tp.next >= 2*len(tp.tm.frames) {
+		return p, false, io.EOF
+	}
+	tp.tm.read.Add(1)
+	i, audio := tp.next/2, tp.next%2 == 1
+	tp.next++
+	if audio {
+		return tp.tm.sounds[i], true, nil
+	}
+	return tp.tm.frames[i], false, nil
+}
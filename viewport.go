@@ -0,0 +1,100 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// viewport.go draws scenes into parts of a window, eg: split screen
+// multiplayer or editor quad views, eg:
+//
+//	world := eng.AddScene(vu.Scene3D).SetViewport(0, 0, 0.5, 1)
+//	right := world.AddView(0.5, 0, 0.5, 1) // second player camera.
+//	right.Chase(player2, 8, 3, 0.3)
+//
+// Viewports are fractions of the window size where 0,0 is the top left
+// corner. Each view has its own camera whose projection matches the
+// viewport size. Views draw the same scene models and are culled by
+// their own camera. A window draws up to render.MaxViews 3D views and
+// render.MaxViews 2D views, including the scenes themselves.
+
+import (
+	"log/slog"
+
+	"github.com/gazed/vu/render"
+)
+
+// SetViewport draws the scene into the given part of its window, where
+// x,y is the top left corner and w,h is the size, all as fractions of
+// the window size. A zero width or height draws the whole window.
+//
+// Depends on Eng.AddScene. Returns the scene entity.
+func (e *Entity) SetViewport(x, y, w, h float64) *Entity {
+	if s := e.app.scenes.get(e.eid); s != nil {
+		s.setViewport(x, y, w, h)
+		return e
+	}
+	slog.Error("SetViewport needs AddScene", "eid", e.eid)
+	return e
+}
+
+// AddView draws the scene again into the given part of its window using
+// a new camera. The viewport is as in SetViewport. Returns the camera for
+// the new view, or nil if the entity is not a scene.
+//
+// Depends on Eng.AddScene.
+func (e *Entity) AddView(x, y, w, h float64) *Camera {
+	s := e.app.scenes.get(e.eid)
+	if s == nil {
+		slog.Error("AddView needs AddScene", "eid", e.eid)
+		return nil
+	}
+	v := newScene(s.eid, s.pid)
+	v.win = s.win
+	v.setViewport(x, y, w, h)
+	s.views = append(s.views, v)
+	return v.cam
+}
+
+// setViewport sets the window area drawn by the scene camera.
+func (s *scene) setViewport(x, y, w, h float64) {
+	s.view = render.Viewport{}
+	if w > 0 && h > 0 {
+		s.view = render.Viewport{X: float32(x), Y: float32(y), W: float32(w), H: float32(h)}
+	}
+	s.cam.focus = true // update the projection for the viewport size.
+}
+
+// viewRect returns the viewport location and size in pixels for the
+// given window size. Matches how the renderer clips the viewport.
+func (s *scene) viewRect(ww, wh uint32) (x, y int32, w, h uint32) {
+	v := s.view
+	if v.W <= 0 || v.H <= 0 {
+		return 0, 0, ww, wh
+	}
+	fw, fh := float32(ww), float32(wh)
+	x0, y0 := max(v.X*fw, 0), max(v.Y*fh, 0)
+	x1, y1 := min((v.X+v.W)*fw, fw), min((v.Y+v.H)*fh, fh)
+	if x1-x0 < 1 || y1-y0 < 1 {
+		return int32(x0), int32(y0), 0, 0 // viewport is outside the window.
+	}
+	return int32(x0), int32(y0), uint32(x1 - x0), uint32(y1 - y0)
+}
+
+// viewAt returns the scene view whose viewport contains the window
+// pixel mx,my. Returns the scene if no view contains the pixel.
+func (s *scene) viewAt(mx, my int32) *scene {
+	for _, v := range s.views {
+		if mx >= v.vx && my >= v.vy && mx < v.vx+int32(v.cam.ww) && my < v.vy+int32(v.cam.wh) {
+			return v
+		}
+	}
+	return s
+}
+
+// eachView calls fn for each scene and each additional scene view.
+func (ss *scenes) eachView(fn func(s *scene)) {
+	for _, s := range ss.all {
+		fn(s)
+		for _, v := range s.views {
+			fn(v)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"testing"
+
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Viewport
+func TestViewport(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	eng := &Engine{app: app}
+	scene := app.addScene(Scene3D).SetViewport(0, 0, 0.5, 1)
+	left := scene.AddModel("shd:icon", "msh:cube", "tex:color:test").SetAt(0, 0, -10)
+	right := scene.AddModel("shd:icon", "msh:cube", "tex:color:test").SetAt(20, 0, -10)
+	cam := scene.AddView(0.5, 0, 0.5, 1).SetAt(20, 0, 0)
+	app.scenes.setViewMatrixes(0, 800, 600)
+	app.povs.setWorldMatrix(app.work, 0)
+
+	// go test -run Viewport/frame
+	t.Run("frame", func(t *testing.T) {
+		passes := app.scenes.getFrame(app, 0, app.frame)
+		if len(passes) != 3 || passes[2].ID != render.Pass3D || len(passes[render.Pass2D].Packets) != 0 {
+			t.Fatalf("expected scene view pass got %d passes", len(passes))
+		}
+		if v := passes[2].Viewport; v.X != 0.5 || v.W != 0.5 || v.H != 1 {
+			t.Errorf("expected right viewport got %+v", v)
+		}
+		if len(passes[0].Packets) != 1 || len(passes[2].Packets) != 1 {
+			t.Errorf("expected each view to cull by its camera got %d %d", len(passes[0].Packets), len(passes[2].Packets))
+		}
+		if c := scene.Cam(); c.ww != 400 || c.wh != 600 || cam.ww != 400 {
+			t.Errorf("expected viewport cameras got %dx%d %d", c.ww, c.wh, cam.ww)
+		}
+	})
+
+	// go test -run Viewport/pick
+	t.Run("pick", func(t *testing.T) {
+		if part, _, _, _, ok := eng.Pick(scene, 200, 300); !ok || part.eid != left.eid {
+			t.Errorf("expected left part got %v %t", part, ok)
+		}
+		if part, _, _, _, ok := eng.Pick(scene, 600, 300); !ok || part.eid != right.eid {
+			t.Errorf("expected right part got %v %t", part, ok)
+		}
+	})
+
+	// go test -run Viewport/limit
+	t.Run("limit", func(t *testing.T) {
+		for i := 0; i < render.MaxViews; i++ {
+			scene.AddView(0, 0, 0.1, 0.1)
+		}
+		if passes := app.scenes.getFrame(app, 0, app.frame); len(passes) != render.MaxViews+1 {
+			t.Errorf("expected views limited got %d passes", len(passes))
+		}
+	})
+}
@@ -13,7 +13,7 @@
 //
 // Vu dependencies are:
 //   - Vulkan for graphics card access.      See package vu/render.
-//   - OpenAL for sound card access.         See package vu/audio.
+//   - OpenAL or WASAPI for sound card access. See package vu/audio.
 //   - WinAPI for Windows display and input. See package vu/device.
 package vu
 
@@ -21,6 +21,7 @@ package vu
 // user game communicates with the engine.
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -39,7 +40,7 @@ import (
 // The app uses eng to create the initial scenes prior to running
 // the engine.
 func NewEngine(config ...Attr) (eng *Engine, err error) {
-	eng = &Engine{}
+	eng = &Engine{telemetry: noTelemetry{}}
 	eng.SetFrameLimit(60) // default FPS throttle
 
 	// apply configuration overrides to the defaults.
@@ -47,17 +48,23 @@ func NewEngine(config ...Attr) (eng *Engine, err error) {
 	for _, attr := range config {
 		attr(&cfg)
 	}
+	eng.title = cfg.title
 
 	// create engine systems to handle application data.
 	eng.app = newApplication()
 
 	// initialize the device layer needed by the renderer
 	eng.dev = device.New(cfg.windowed, cfg.title, cfg.x, cfg.y, cfg.w, cfg.h)
+	if cfg.highDPI {
+		eng.dev.EnableHighDPI()
+	}
 	if err = eng.dev.CreateDisplay(); err != nil {
 		eng.dispose() // can't continue without a display.
 		return nil, fmt.Errorf("device.CreateDisplay failed %w", err)
 	}
+	eng.pace.setRefreshRate(eng.dev.RefreshRate())
 	eng.dev.SetResizeHandler(eng.handleResize)
+	eng.dev.SetDisplayHandler(eng.handleDisplay)
 
 	// initialize the graphic renderer and the display surface.
 	eng.rc, err = render.New(render.VULKAN_RENDERER, eng.dev, cfg.title)
@@ -66,6 +73,7 @@ func NewEngine(config ...Attr) (eng *Engine, err error) {
 		return nil, fmt.Errorf("render.New failed %w", err)
 	}
 	eng.rc.SetClearColor(cfg.r, cfg.g, cfg.b, cfg.a)
+	eng.app.ld.triangles = cfg.triangles
 
 	// initialize audio.
 	eng.ac = audio.New()
@@ -90,7 +98,7 @@ func (eng *Engine) AddScene(st SceneType) *Entity {
 }
 
 // AddSound creates an entity from the named sound asset.
-// The name is the sound asset filename without the .wav extension.
+// The name is the sound asset filename without the .wav or .flac extension.
 //
 // Passing the sound identifier to an entity PlaySound() method will
 // assigned using SetListener(). Sounds are louder the closer the
@@ -107,21 +115,88 @@ func (eng *Engine) AddSound(name string) (sound *Entity) {
 // Expected to be called at least once initialization to
 // create the assets referenced by models in a scene.
 func (eng *Engine) ImportAssets(assetFilenames ...string) {
+	eng.ImportAssetsFunc(nil, assetFilenames...)
+}
+
+// ImportAssetsFunc is ImportAssets with a callback for each asset file.
+// Files are loaded and decoded on worker goroutines and then uploaded
+// a few assets each update, so that large levels stream in while the
+// engine keeps running. The done callback is called on the engine
+// goroutine once all the assets from a file are ready to use, or with
+// the error if the file failed to load. Done is called immediately for
+// files that have already been imported. Done may be nil.
+func (eng *Engine) ImportAssetsFunc(done func(filename string, err error), assetFilenames ...string) {
 	// public wrapper for the underlying loader file importer.
 	eng.app.ld.importAssetData(assetFilenames...)
+	if done != nil {
+		for _, filename := range assetFilenames {
+			eng.app.ld.notify(filename, done)
+		}
+	}
+	if len(assetFilenames) > 0 {
+		files := append([]string{}, assetFilenames...)
+		eng.tm.imports = append(eng.tm.imports, assetImport{files: files, start: time.Now()})
+	}
+}
+
+// SetUploadBudget sets the max time spent each update uploading
+// imported assets to the GPU and audio device. At least one asset
+// is uploaded each update. Larger budgets load faster, eg: behind
+// a loading screen, while smaller budgets keep the frame rate
+// smooth while streaming. The default is 5 milliseconds.
+func (eng *Engine) SetUploadBudget(budget time.Duration) {
+	eng.app.ld.budget = max(budget, 0)
+}
+
+// SetResourceBudget sets the GPU memory, in bytes, used to cache asset
+// files that are no longer used by any model. Unused files are evicted,
+// least recently used first, once the budget is exceeded. Evicted files
+// are imported again when a model asks for their assets. The default
+// budget of zero evicts files as soon as their last model is disposed.
+// Files that have been imported, but never used by a model, are kept.
+func (eng *Engine) SetResourceBudget(bytes uint64) {
+	eng.app.ld.setResourceBudget(bytes)
+}
+
+// WatchAssets checks imported asset files for changes every interval
+// and reloads the files that have changed. Reloaded textures, meshes,
+// shaders, materials, sounds, and animations replace the existing assets
+// so models pick up the changes without being recreated. Shaders are also
+// reloaded when their compiled .spv files change. Failed reloads, eg:
+// a half saved file, keep the existing assets. The optional reloaded
+// callback is called on the engine goroutine after each reload.
+// An interval of zero stops watching. Intended for development to
+// shorten the art iteration loop.
+func (eng *Engine) WatchAssets(interval time.Duration, reloaded func(filename string, err error)) {
+	eng.app.ld.setWatch(interval, reloaded)
 }
 
 // SetFrameLimit throttles the engine to the given frames-per-second
 // This reduces GPU usage when the actual FPS is higher than the given limit.
 // It will not make the engine faster if the actual FPS is lower than
-// the given limit. Throttle limits less than 30FPS and greater than 240FPS
-// are ignored.
+// the given limit. A limit of 0 removes the throttle, leaving the frame
+// rate to the vsync mode, see SetVSync. Other throttle limits less than
+// 30FPS and greater than 240FPS are ignored.
 func (eng *Engine) SetFrameLimit(limit int) {
-	if limit >= 30 && limit <= 240 {
+	switch {
+	case limit == 0:
+		eng.throttle = 0
+	case limit >= 30 && limit <= 240:
 		eng.throttle = time.Duration(float64(time.Second) / float64(limit))
 	}
 }
 
+// MatchRefreshRate sets the frame limit to the refresh rate of the
+// monitor showing the window. The frame limit is updated whenever the
+// display changes, eg: when the window is moved to a different monitor.
+// Setting false keeps the current frame limit.
+func (eng *Engine) MatchRefreshRate(match bool) {
+	eng.matchRefresh = match
+	if rate := eng.dev.RefreshRate(); match && rate > 0 {
+		eng.SetFrameLimit(rate)
+	}
+}
+
 // =============================================================================
 
 // Engine controls the engine subsystems and the run loop.
@@ -137,6 +212,45 @@ type Engine struct {
 	suspended bool          // true if updating the game state is on hold.
 	running   bool          // true if engine is alive.
 	throttle  time.Duration // FPS throttle.
+
+	// true if the throttle follows the monitor refresh rate.
+	matchRefresh bool
+
+	// true to run every physics step instead of dropping steps.
+	deterministic bool
+
+	// time since the audio device was last checked.
+	audioCheck time.Duration
+
+	// optional application telemetry.
+	title     string    // window title.
+	telemetry Telemetry // application telemetry, ignores events by default.
+	tm        telemetry // telemetry event data.
+
+	// optional soak test.
+	soak *soak // nil unless running a soak test.
+
+	// optional frame capture.
+	frames *frameWriter // nil unless capturing frames.
+	rec    *recording   // nil unless recording.
+
+	// optional replay recording or playback.
+	replay *replay // nil unless recording or playing a replay.
+
+	// frame statistics.
+	stats     Stats           // last frame statistics.
+	showStats bool            // true to draw the statistics overlay.
+	showGraph bool            // true to draw the frame graph overlay.
+	gpuTimes  []time.Duration // GPU profile scope times.
+
+	// optional automatic quality adjustment.
+	quality quality
+
+	// vsync mode and missed frames.
+	pace pacing
+
+	// error that stopped the engine, see Err.
+	err error
 }
 
 // Updator is responsible for updating application state each render frame.
@@ -167,6 +281,15 @@ var (
 func (eng *Engine) Run(updator Updator) {
 	eng.app.updator = updator // application update callback
 
+	// report run loop panics before crashing.
+	defer func() {
+		if r := recover(); r != nil {
+			eng.crashed(r)
+			panic(r)
+		}
+	}()
+	eng.startSession(time.Now())
+
 	// use a fixed timestep to run game updates 60 times a second
 	var elapsedTime time.Duration    // accumulate time to trigger timesteps
 	previousFrameStart := time.Now() // used to calculate delta time
@@ -177,6 +300,7 @@ func (eng *Engine) Run(updator Updator) {
 
 		// process user input.
 		eng.app.input.Clone(eng.dev.GetInput())
+		eng.app.events.checkFocus(eng.app.input)
 		if !eng.dev.IsRunning() {
 			slog.Debug("engine shutdown!") // likely user closed window.
 			eng.Shutdown()                 //
@@ -189,30 +313,61 @@ func (eng *Engine) Run(updator Updator) {
 			frameStart := time.Now()
 
 			// delta measures the time it takes between frames.
+			// The physics runs on the game clock, see SetTimeScale.
 			delta := frameStart.Sub(previousFrameStart)
-			elapsedTime += delta
+			elapsedTime += eng.app.timers.scaled(delta)
 
 			// handle persistent slowness by dropping updates.
 			// fix this by making the updates and render faster.
-			if elapsedTime > 3*timestep {
+			// Deterministic mode runs the updates over the next frames.
+			if elapsedTime > 3*timestep && !eng.deterministic {
 				elapsedTime = timestep // run 1 update and drop the rest
 			}
 
 			// run updates at a fixed interval independent of frame rendering.
 			// run multiple updates to catch up in cases of periodic slowness.
-			for elapsedTime >= timestep {
+			steps := 0
+			for ; elapsedTime >= timestep && steps < 3; steps++ {
 				elapsedTime -= timestep
+			}
+
+			// replays record, or replace, the frame input and timing.
+			delta, steps = eng.replayFrame(frameStart, delta, steps)
+			gameDelta := eng.app.timers.scaled(delta)
+			for step := 0; step < steps; step++ {
 
 				// Simulate physics using a fixed timestep so that
 				// each update advances by the same amount.
-				eng.app.sim.simulate(eng.app.povs, timestepSecs)
+				eng.app.sim.simulate(eng.app.povs, eng.app.work, timestepSecs)
+				eng.app.sim.contact(eng.app)
+				eng.app.cloths.simulate(eng.app, timestepSecs)
 
 				// FUTURE move particle effects using fixed timestep.
 				// eng.app.models.moveParticles(timestepSecs)
 			}
 
-			// update the client app before each render frame
+			// deliver the events published since the last update.
+			eng.app.events.flush()
+
+			// the open developer console takes the keyboard input.
+			if eng.app.console != nil {
+				eng.app.console.update(eng, eng.app.input)
+			}
+
+			// route input to the UI widgets before the client app sees it.
+			sw, sh := eng.rc.Size()
+			if typing, changed := eng.app.ui.update(eng.app, eng.app.input, sw, sh); changed {
+				eng.dev.SetTextInput(typing)
+			}
+
+			// update the client app before each render frame, call the
+			// entity ticks, resume coroutines that have finished waiting,
+			// run the timers, and advance the tweens.
 			eng.app.updator.Update(eng, eng.app.input, delta)
+			eng.app.ticks.update(eng.app, gameDelta)
+			eng.app.coroutines.update(gameDelta)
+			eng.app.timers.update(gameDelta)
+			eng.app.tweens.update(delta)
 			if !eng.running {
 				slog.Debug("app shutdown!") // app called eng.Shutdown()
 				break                       // exit loop to eng.dispose()
@@ -220,30 +375,73 @@ func (eng *Engine) Run(updator Updator) {
 
 			// check for any newly created assets.
 			eng.app.ld.loadAssets(eng.rc, eng.ac)
+			eng.checkImports(time.Now())
+			eng.refreshAudio(delta)
+
+			// move tagged entities and 3D parts to their new spatial locations.
+			eng.app.tags.update(eng.app.povs)
+			eng.app.spatial.update(eng.app)
+			updated := time.Now()
+
+			// advance model animations by elapsed time, not at fixed rate like physics.
+			// Animation clips are sampled at their own frame rate.
+			eng.app.models.animate(eng.app.work, delta)
+			eng.app.scenes.follow(eng.app, delta)
+			eng.app.scenes.rigs(eng.app, delta)
+			eng.app.tiles.update(eng.app, eng.rc, delta)
+			eng.app.patches.update(eng.app, eng.rc)
+			eng.app.terrains.update(eng.app, eng.rc)
+			eng.app.waters.update(eng.app, eng.rc, delta)
+			eng.app.cloths.draw(eng.app, eng.rc)
+			eng.app.decals.update(eng.app, eng.rc, delta)
+			eng.app.probes.update(eng.app, eng.rc, delta)
+			eng.app.videos.update(eng.app, eng.rc, eng.ac, delta)
+
+			// upload any debug draws for this frame.
+			if eng.showStats || eng.showGraph {
+				eng.drawStats()
+			}
+			if eng.app.debug != nil {
+				eng.app.debug.draw(eng.rc)
+			}
+			if eng.app.gui != nil {
+				if eng.app.console != nil {
+					w, h := eng.rc.Size()
+					eng.app.console.draw(eng.app.gui, int(w), int(h)) // over the GUI windows.
+				}
+				eng.app.gui.draw(eng.rc)
+			}
 
-			// FUTURE: advance model animations by elapsed time, not at fixed rate like physics.
-			// Animation data expects to be played back at a particular frame rate.
-			// eng.app.models.animate(delta)
-
-			// render frames outside the fixed timestep.
-			// FUTURE: interpolate the render as a fraction between this frame and last.
-			eng.app.scenes.setViewMatrixes(eng.rc.Size())
-			eng.app.povs.setWorldMatrix(delta)
-			eng.app.frame = eng.app.scenes.getFrame(eng.app, eng.app.frame)
-			eng.rc.Draw(eng.app.frame, delta)
+			// render frames outside the fixed timestep, rendering physics
+			// bodies part way between the last two simulation steps.
+			w, h := eng.rc.Size()
+			eng.app.scenes.setViewMatrixes(0, w, h)
+			eng.app.povs.setWorldMatrix(eng.app.work, delta)
+			eng.app.sim.interpolate(eng.app.povs, elapsedTime.Seconds()/timestepSecs)
+			eng.drawWindows(delta)
+			eng.app.frame = eng.app.scenes.getFrame(eng.app, 0, eng.app.frame)
+			eng.captureFrame()
+			eng.recordFrame()
+			if err := eng.rc.Draw(eng.app.frame, delta); err != nil {
+				eng.renderFailed(err)
+			}
+			eng.updateStats(delta, updated.Sub(frameStart), time.Since(updated))
+			eng.quality.update(max(eng.stats.Update+eng.stats.Render, eng.stats.GPU), delta)
+			eng.pace.frame(delta, eng.throttle)
+			eng.countFrame(delta, time.Now())
+			eng.soakFrame(delta, time.Now())
 
 			// frame complete, remember the start of this frame.
 			previousFrameStart = frameStart
 
 			// throttle to rest the CPU/GPU.
-			// Requires go1.23+ to get 1ms pecision on windows. See go issue #44343.
-			extra := eng.throttle - time.Since(frameStart) // FPS throttle
-			extra = extra - extra%10_000                   // round down for wiggle room.
-			if extra > 0 {
-				time.Sleep(extra)
+			if eng.throttle > 0 {
+				device.SleepUntil(frameStart.Add(eng.throttle))
 			}
 		}
 	}
+	eng.stopSoak(time.Now())
+	eng.endSession(time.Now())
 	eng.dispose()
 }
 
@@ -266,14 +464,15 @@ func (eng *Engine) handleResize() {
 
 	// update display surface if size has changed.
 	if w != pw || h != ph {
-		eng.rc.Resize(w, h)         // request render resize.
-		eng.app.scenes.resize(w, h) // update scene cameras.
+		eng.rc.Resize(w, h)            // request render resize.
+		eng.app.scenes.resize(0, w, h) // update scene cameras.
 	}
 
 	// update apps that have registered for resize callbacks.
 	if eng.app.resizer != nil {
 		eng.app.resizer.Resize(x, y, w, h)
 	}
+	eng.app.events.resize.Publish(ResizeEvent{X: x, Y: y, W: w, H: h})
 }
 
 // Resizer is responsible for updating an application when the window
@@ -289,13 +488,115 @@ func (eng *Engine) SetResizeListener(resizer Resizer) {
 	eng.app.resizer = resizer
 }
 
+// handleDisplay is called by the device when the display changes.
+// The display changes when the refresh rate or resolution changes, when
+// monitors are plugged in or unplugged, and when the window is moved to
+// a different monitor.
+func (eng *Engine) handleDisplay() {
+	refreshRate, monitors := eng.dev.RefreshRate(), eng.dev.Monitors()
+	slog.Debug("display changed", "refresh", refreshRate, "monitors", monitors)
+	eng.pace.setRefreshRate(refreshRate)
+	if eng.matchRefresh && refreshRate > 0 {
+		eng.SetFrameLimit(refreshRate) // re-pace the frame loop.
+	}
+	eng.handleResize() // a resolution change can resize fullscreen windows.
+
+	// update apps that have registered for display callbacks.
+	if eng.app.displayer != nil {
+		eng.app.displayer.DisplayChanged(refreshRate, monitors)
+	}
+}
+
+// Displayer is responsible for updating an application when the display
+// changes. It is implemented by the user app and set on startup.
+type Displayer interface {
+	// DisplayChanged is called when the refresh rate or resolution
+	// changes, when monitors are plugged in or unplugged, and when
+	// the window is moved to a different monitor. Applications can
+	// use eng.SetWindow to move the window when monitors change.
+	DisplayChanged(refreshRate, monitors int)
+}
+
+// SetDisplayListener sets the application callback
+// for when the display changes.
+func (eng *Engine) SetDisplayListener(displayer Displayer) {
+	eng.app.displayer = displayer
+}
+
+// RefreshRate returns the refresh rate, in hertz, of the monitor showing
+// the window. Returns 0 if the refresh rate is not known.
+func (eng *Engine) RefreshRate() int { return eng.dev.RefreshRate() }
+
+// Monitors returns the number of monitors attached to the desktop.
+func (eng *Engine) Monitors() int { return eng.dev.Monitors() }
+
+// DisplayScale returns the surface pixels per logical window unit for
+// the monitor showing the window, eg: 2 for 200% scaling. Always 1
+// unless the engine was created with the HighDPI attribute.
+// The display listener is called when the scale changes.
+func (eng *Engine) DisplayScale() float32 { return eng.dev.DisplayScale() }
+
+// LogicalSize returns the window surface size in logical window units.
+// Same as the Resizer window size unless using HighDPI.
+func (eng *Engine) LogicalSize() (w, h uint32) { return eng.dev.LogicalSize() }
+
+// SetWindow moves and resizes the bordered window where x,y is the upper
+// left corner and w,h is the window surface size in pixels. A fullscreen
+// window uses the new location and size when it returns to a bordered window.
+func (eng *Engine) SetWindow(x, y int32, w, h uint32) {
+	eng.dev.SetWindow(x, y, int32(w), int32(h))
+}
+
 // ToggleFullscreen switches between a borderless fullscreen window and
 // a bordered window.
 func (eng *Engine) ToggleFullscreen() {
 	eng.dev.ToggleFullscreen()
 }
 
-// MakeMeshes loads application generated mesh data.
+// SetCursorMode shows, hides, or captures the mouse cursor.
+// Use CursorCaptured and the Input.Dx, Input.Dy raw mouse motion for
+// mouse look controls that keep working at the window edges.
+func (eng *Engine) SetCursorMode(mode CursorMode) {
+	eng.dev.SetCursorMode(mode)
+}
+
+// WarpCursor moves the mouse cursor to the given location
+// in pixels relative to the top left of the window.
+func (eng *Engine) WarpCursor(x, y int32) {
+	eng.dev.WarpCursor(x, y)
+}
+
+// ConfineCursor keeps the visible mouse cursor inside
+// the window while the window has focus.
+func (eng *Engine) ConfineCursor(confine bool) {
+	eng.dev.ConfineCursor(confine)
+}
+
+// SetTextInput turns typed text, Input.Text, on or off. Expected to be
+// on only while a text field, like a chat box, has focus since it also
+// turns on the input method editor used to type some languages.
+func (eng *Engine) SetTextInput(on bool) {
+	eng.dev.SetTextInput(on)
+}
+
+// Clipboard returns the text on the system clipboard,
+// or "" if the clipboard does not contain text.
+func (eng *Engine) Clipboard() string { return eng.dev.Clipboard() }
+
+// SetClipboard puts the given text on the system clipboard.
+func (eng *Engine) SetClipboard(text string) error {
+	return eng.dev.SetClipboard(text)
+}
+
+// AcceptDrops allows files to be dropped on the window, eg: for editors
+// that import assets. Dropped files are reported in Input.Dropped.
+func (eng *Engine) AcceptDrops(accept bool) {
+	eng.dev.AcceptDrops(accept)
+}
+
+// MakeMeshes loads application generated mesh data. The meshes
+// are pickable and can be baked into lightmaps like loaded meshes,
+// see MeshTriangles.
 func (eng *Engine) MakeMeshes(name string, meshes []load.MeshData) (err error) {
 	mids, err := eng.rc.LoadMeshes(meshes) // upload all mesh data.
 	if err != nil || len(mids) != len(meshes) {
@@ -303,6 +604,7 @@ func (eng *Engine) MakeMeshes(name string, meshes []load.MeshData) (err error) {
 	}
 	for i, mid := range mids {
 		m := newMesh(fmt.Sprintf("%s%d", name, i))
+		m.setBounds(meshes[i], eng.app.ld.triangles)
 		m.mid = mid
 		eng.app.ld.assets[m.aid()] = m
 	}
@@ -317,7 +619,36 @@ func (eng *Engine) MakeMeshes(name string, meshes []load.MeshData) (err error) {
 func (eng *Engine) Shutdown() {
 	eng.running = false
 }
+
+// Err returns the error that stopped the engine, eg: ErrDeviceLost,
+// or nil if the engine was shut down normally. Expected to be checked
+// after Run returns. A new engine is needed to continue.
+func (eng *Engine) Err() error { return eng.err }
+
+// renderFailed shuts down the engine when the renderer can not continue.
+// Other render errors affect only the current frame.
+func (eng *Engine) renderFailed(err error) {
+	if errors.Is(err, ErrDeviceLost) {
+		slog.Error("render failed", "error", err)
+		eng.err = err
+		eng.Shutdown()
+		return
+	}
+	slog.Debug("render frame", "error", err)
+}
 func (eng *Engine) dispose() {
+	eng.StopFrameCapture() // write any captured frames.
+	if err := eng.StopRecording(); err != nil {
+		slog.Error("StopRecording", "error", err)
+	}
+	if err := eng.StopReplay(); err != nil {
+		slog.Error("StopReplay", "error", err)
+	}
+	if eng.app != nil {
+		eng.app.coroutines.stop() // run coroutine deferred functions.
+		eng.app.work.dispose()    // stop the worker goroutines.
+	}
+
 	// cleanup up engine subsystem resources.
 	if eng.ac != nil {
 		eng.ac.Dispose()
@@ -0,0 +1,529 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// water.go draws lakes and oceans as an animated water surface, eg:
+//
+//	sea := scene.AddWater(200, 128, "shd:water", "tex:normals:ripples", "tex:sky:sky")
+//	sea.SetWave(0, 30, 0.4, 12).SetWave(1, 80, 0.1, 3).SetWaterColor(0.05, 0.2, 0.3, 0.8)
+//	sea.SetWaterReflections(512) // reflect the scene instead of the sky.
+//	x, _, z := boat.At()
+//	if y, ok := sea.WaterHeight(x, z); ok {
+//		boat.SetAt(x, y, z) // float on the waves.
+//	}
+//
+// The water is a flat grid that the water shader lifts into up to three
+// sine waves travelling in different directions. Wave speed follows from
+// the wave length as for deep ocean waves. The waves give the surface its
+// shape while two normal map samples, scrolling in different directions,
+// add the small ripples. The surface color blends between the transparent
+// water color and the sky reflected in the surface using the Fresnel term,
+// so that the water is clear looking down and reflective at low angles.
+// The sky texture is an equirectangular panorama. WaterHeight matches the
+// shader waves so that floating objects ride the drawn surface.
+//
+// Water reflections draw the scene into two render targets before the
+// window is drawn. The reflection camera is the scene camera mirrored in
+// the water plane and the refraction camera is the scene camera. Each
+// camera projection has its near plane moved onto the water plane so that
+// the reflection only draws the scene above the water and the refraction
+// only draws the scene below. The water shader samples the images at the
+// screen location of the surface, offset by the ripples, using them
+// instead of the sky texture and the water transparency. Water surfaces
+// are not drawn into the reflection images.
+//
+// FUTURE: reflection images for the scene views, see AddView. Scene views
+// currently show the reflections drawn from the scene camera.
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// AddWater adds a square water surface size units wide to a 3D scene.
+// The surface is centered on the water location facing up, and is split
+// into cells by cells squares that are moved by the waves. More cells
+// give smoother waves. The assets are the water shader and its normal
+// map and sky textures, see the water shader. Water is drawn with the
+// transparent models. The water is not expected to be rotated or scaled.
+//
+// Depends on Eng.AddScene for a Scene3D.
+func (e *Entity) AddWater(size float64, cells int, assets ...string) (me *Entity) {
+	me = e.AddModel(assets...).SetRenderQueue(QueueTransparent)
+	if size <= 0 || cells < 1 || cells > maxWaterCells {
+		slog.Error("AddWater invalid surface", "size", size, "cells", cells)
+		return me
+	}
+	scene := sceneRoot(me.app.povs, me.eid)
+	if sc := me.app.scenes.get(scene); sc == nil || sc.pid != render.Pass3D {
+		slog.Error("AddWater needs 3D scene", "eid", e.eid)
+		return me
+	}
+	me.app.waters.create(me.eid, size, cells)
+	return me
+}
+
+// SetWave sets one of the three water waves. Direction is the angle in
+// degrees, from the X axis towards the Z axis, that the wave travels.
+// Amplitude is the wave height above and below the water level and length
+// is the distance between wave crests. A zero amplitude removes the wave.
+//
+// Depends on Entity.AddWater.
+func (e *Entity) SetWave(index int, direction, amplitude, length float64) *Entity {
+	w := e.app.waters.get(e.eid)
+	if w == nil {
+		slog.Error("SetWave needs AddWater", "eid", e.eid)
+		return e
+	}
+	if index < 0 || index >= maxWaves || length <= 0 {
+		slog.Error("SetWave invalid wave", "index", index, "length", length)
+		return e
+	}
+	w.waves[index] = wave{direction: direction, amplitude: amplitude, length: length}
+	w.resize = true // bounds change with the wave heights.
+	return e
+}
+
+// SetWaterColor sets the color seen looking down into the water.
+// Alpha is the water opacity when looking straight down.
+// The default is a dark blue green with alpha 0.8.
+//
+// Depends on Entity.AddWater.
+func (e *Entity) SetWaterColor(r, g, b, a float64) *Entity {
+	if w := e.app.waters.get(e.eid); w != nil {
+		w.color = lin.V4{X: r, Y: g, Z: b, W: a}
+		return e
+	}
+	slog.Error("SetWaterColor needs AddWater", "eid", e.eid)
+	return e
+}
+
+// SetWaterRipples sets how many times the normal map repeats across the
+// water surface and how fast, in normal map widths per second, the ripples
+// drift. The defaults are 8 repeats and 0.02.
+//
+// Depends on Entity.AddWater.
+func (e *Entity) SetWaterRipples(repeats, speed float64) *Entity {
+	if w := e.app.waters.get(e.eid); w != nil {
+		w.tiling, w.flow = repeats, speed
+		return e
+	}
+	slog.Error("SetWaterRipples needs AddWater", "eid", e.eid)
+	return e
+}
+
+// SetWaterReflections draws the scene reflected in the water, and the
+// scene below the water, into size by size pixel images each frame.
+// The water shader then reflects the scene instead of the sky texture
+// and shows the scene below through the water color. Size is from 16 to
+// 2048 pixels. Reflections draw the 3D scene two more times each frame.
+// A zero size turns the reflections off.
+//
+// Depends on Entity.AddWater.
+func (e *Entity) SetWaterReflections(size int) *Entity {
+	w := e.app.waters.get(e.eid)
+	if w == nil {
+		slog.Error("SetWaterReflections needs AddWater", "eid", e.eid)
+		return e
+	}
+	if size != 0 && (size < minWaterView || size > maxWaterView) {
+		slog.Error("SetWaterReflections invalid size", "size", size)
+		return e
+	}
+	w.viewSize = size
+	return e
+}
+
+// WaterHeight returns the world height of the water surface at the
+// world location x,z, including the waves. Returns false if x,z is
+// not over the water.
+//
+// Depends on Entity.AddWater.
+func (e *Entity) WaterHeight(x, z float64) (y float64, ok bool) {
+	w := e.app.waters.get(e.eid)
+	if w == nil {
+		slog.Error("WaterHeight needs AddWater", "eid", e.eid)
+		return 0, false
+	}
+	p := e.app.povs.get(e.eid)
+	if p == nil {
+		return 0, false
+	}
+	wx, wy, wz := p.world()
+	lx, lz := x-wx, z-wz // location in water space.
+	if half := w.size * 0.5; math.Abs(lx) > half || math.Abs(lz) > half {
+		return 0, false
+	}
+	return wy + w.height(lx, lz), true
+}
+
+// =============================================================================
+// water data
+
+const (
+	maxWaterCells = 254  // surface mesh uses uint16 indexes.
+	maxWaves      = 3    // waves packed into the shader uniforms.
+	waterGravity  = 9.8  // wave speed for deep water waves.
+	minWaterView  = 16   // smallest reflection image size.
+	maxWaterView  = 2048 // largest reflection image size.
+)
+
+// waterSamplers are the water shader samplers for the
+// reflection and refraction images, in waterView order.
+var waterSamplers = []string{"reflection", "refraction"}
+
+// wave is one sine wave moving across the water.
+type wave struct {
+	direction float64 // travel direction in degrees.
+	amplitude float64 // height above and below the water level.
+	length    float64 // distance between crests.
+}
+
+// offset returns the wave height at the water location x,z
+// after the given number of seconds.
+func (w *wave) offset(x, z, seconds float64) float64 {
+	if w.amplitude == 0 {
+		return 0
+	}
+	k := 2 * math.Pi / w.length          // wave number.
+	speed := math.Sqrt(waterGravity * k) // deep water dispersion.
+	dz, dx := math.Sincos(lin.Rad(w.direction))
+	return w.amplitude * math.Sin(k*(dx*x+dz*z)-speed*seconds)
+}
+
+// water holds the wave and surface settings for one water model.
+type water struct {
+	size    float64 // surface width.
+	cells   int     // grid squares per side.
+	waves   [maxWaves]wave
+	color   lin.V4        // water color and opacity.
+	tiling  float64       // normal map repeats.
+	flow    float64       // normal map scroll speed.
+	elapsed time.Duration // wave time.
+	resize  bool          // true if the bounds need updating.
+	args    []float64     // shader uniform data.
+
+	// reflection and refraction images, see SetWaterReflections.
+	viewSize int          // requested image size, 0 for none.
+	views    []*waterView // reflection then refraction, nil if not loaded.
+}
+
+// height returns the sum of the waves at the water location x,z.
+func (w *water) height(x, z float64) (y float64) {
+	seconds := w.elapsed.Seconds()
+	for i := range w.waves {
+		y += w.waves[i].offset(x, z, seconds)
+	}
+	return y
+}
+
+// crest returns the highest the waves can lift the surface.
+func (w *water) crest() (y float64) {
+	for _, wv := range w.waves {
+		y += math.Abs(wv.amplitude)
+	}
+	return y
+}
+
+// uniforms packs the water settings into the shader args16 uniform,
+// one vec4 for each of the wave directions, amplitudes, lengths, and
+// the water color. The 4th value of the first three is the wave time,
+// the normal map repeats, and the ripple speed. The repeats are negated
+// to tell the shader to use the reflection and refraction images.
+func (w *water) uniforms() []float64 {
+	a := w.args[:0]
+	for _, wv := range w.waves {
+		a = append(a, wv.direction)
+	}
+	a = append(a, w.elapsed.Seconds())
+	for _, wv := range w.waves {
+		a = append(a, wv.amplitude)
+	}
+	if len(w.views) > 0 {
+		a = append(a, -w.tiling)
+	} else {
+		a = append(a, w.tiling)
+	}
+	for _, wv := range w.waves {
+		a = append(a, wv.length)
+	}
+	a = append(a, w.flow)
+	a = append(a, w.color.X, w.color.Y, w.color.Z, w.color.W)
+	w.args = a
+	return a
+}
+
+// meshData generates the flat surface grid centered on the origin.
+// The texture coordinates cover the surface once.
+func (w *water) meshData() load.MeshData {
+	side := w.cells + 1 // vertexes per side.
+	vx, uv, ix := []float32{}, []float32{}, []uint16{}
+	half, step := w.size*0.5, w.size/float64(w.cells)
+	for r := 0; r < side; r++ {
+		for c := 0; c < side; c++ {
+			vx = append(vx, float32(float64(c)*step-half), 0, float32(float64(r)*step-half))
+			uv = append(uv, float32(c)/float32(w.cells), float32(r)/float32(w.cells))
+		}
+	}
+	for r := uint16(0); r < uint16(w.cells); r++ {
+		for c := uint16(0); c < uint16(w.cells); c++ {
+			v, row := r*uint16(side)+c, uint16(side) // counter-clockwise facing +Y.
+			ix = append(ix, v, v+row, v+1, v+1, v+row, v+row+1)
+		}
+	}
+	md := make(load.MeshData, load.VertexTypes)
+	md[load.Vertexes] = load.F32Buffer(vx, 3)  // vec3
+	md[load.Texcoords] = load.F32Buffer(uv, 2) // vec2
+	md[load.Indexes] = load.U16Buffer(ix)
+	return md
+}
+
+// viewsChanged returns true if the reflection images need to be
+// loaded, resized, or released.
+func (w *water) viewsChanged() bool {
+	if len(w.views) == 0 {
+		return w.viewSize > 0
+	}
+	return w.views[0].tex.w != w.viewSize
+}
+
+// waterView is a camera that draws the scene reflected in the water,
+// or the scene below the water, into a render target.
+type waterView struct {
+	scene *scene   // camera and render target.
+	tex   *texture // render target image sampled by the water shader.
+	inv   lin.M4   // scratch inverse matrix.
+}
+
+// newWaterView creates a water camera for the given render target.
+func newWaterView(scene eID, target, tid uint32, name string, size int) *waterView {
+	v := &waterView{scene: newScene(scene, render.Pass3D), tex: newTexture(name)}
+	v.scene.fbo = target
+	v.tex.tid, v.tex.opaque, v.tex.w, v.tex.h = tid, true, size, size
+	return v
+}
+
+// reflect sets the view camera to the scene camera mirrored in the water
+// plane at height h. The mirrored view is also turned upside down so that
+// triangles keep their winding, and the water shader turns the image back.
+// Only the scene above the lowest waves is drawn.
+func (v *waterView) reflect(sc *scene, h, crest float64) {
+	c, vc := sc.cam, v.scene.cam
+	x, y, z := c.At()
+	vc.at.Loc.SetS(x, 2*h-y, z)
+	mirror := lin.M4{Xx: 1, Yy: -1, Zz: 1, Wy: 2 * h, Ww: 1} // world y to 2h-y
+	flip := lin.M4{Xx: 1, Yy: -1, Zz: 1, Ww: 1}              // view y to -y
+	vc.vm.Mult(&mirror, c.vm).Mult(vc.vm, &flip)
+	vc.pm.Set(c.pm)
+	v.scene.win = sc.win
+	v.clip(0, 1, 0, -(h - crest))
+}
+
+// refract sets the view camera to the scene camera.
+// Only the scene below the highest waves is drawn.
+func (v *waterView) refract(sc *scene, h, crest float64) {
+	c, vc := sc.cam, v.scene.cam
+	vc.at.Loc.Set(c.at.Loc)
+	vc.vm.Set(c.vm)
+	vc.pm.Set(c.pm)
+	v.scene.win = sc.win
+	v.clip(0, -1, 0, h+crest)
+}
+
+// clip moves the camera near plane onto the world plane ax+by+cz+d=0 so
+// that only the scene on the positive side of the plane is drawn. The
+// projection is not changed when the camera is on the positive side.
+// Based on "Oblique View Frustum Depth Projection and Clipping", Lengyel,
+// using the vulkan 0 to 1 depth range.
+func (v *waterView) clip(a, b, c, d float64) {
+	cam := v.scene.cam
+	vi := v.inv.Inv(cam.vm) // plane from world to view space.
+	p := lin.V4{
+		X: vi.Xx*a + vi.Xy*b + vi.Xz*c + vi.Xw*d,
+		Y: vi.Yx*a + vi.Yy*b + vi.Yz*c + vi.Yw*d,
+		Z: vi.Zx*a + vi.Zy*b + vi.Zz*c + vi.Zw*d,
+		W: vi.Wx*a + vi.Wy*b + vi.Wz*c + vi.Ww*d,
+	}
+	if p.W >= 0 {
+		return // camera is on the drawn side of the plane.
+	}
+
+	// find the far clip space corner opposite the plane in view space.
+	sign := func(f float64) float64 {
+		switch {
+		case f > 0:
+			return 1
+		case f < 0:
+			return -1
+		}
+		return 0
+	}
+	pi := v.inv.Inv(cam.pm) // plane from view to clip space.
+	qx := sign(pi.Xx*p.X + pi.Xy*p.Y + pi.Xz*p.Z + pi.Xw*p.W)
+	qy := sign(pi.Yx*p.X + pi.Yy*p.Y + pi.Yz*p.Z + pi.Yw*p.W)
+	q := lin.V4{
+		X: qx*pi.Xx + qy*pi.Yx + pi.Zx + pi.Wx,
+		Y: qx*pi.Xy + qy*pi.Yy + pi.Zy + pi.Wy,
+		Z: qx*pi.Xz + qy*pi.Yz + pi.Zz + pi.Wz,
+		W: qx*pi.Xw + qy*pi.Yw + pi.Zw + pi.Ww,
+	}
+
+	// replace the projection depth with the plane distance, scaled
+	// so that the far corner stays at the far depth.
+	s := 1 / (p.X*q.X + p.Y*q.Y + p.Z*q.Z + p.W*q.W)
+	cam.pm.Xz, cam.pm.Yz, cam.pm.Zz, cam.pm.Wz = p.X*s, p.Y*s, p.Z*s, p.W*s
+}
+
+// =============================================================================
+// waters component manager.
+
+// waters tracks the water components.
+type waters struct {
+	list map[eID]*water
+}
+
+// newWaters creates the water component manager.
+// There is only expected to be once instance created by the engine.
+func newWaters() *waters {
+	return &waters{list: map[eID]*water{}}
+}
+
+// create a water surface for the given model entity.
+func (ws *waters) create(eid eID, size float64, cells int) *water {
+	w := &water{size: size, cells: cells, tiling: 8, flow: 0.02, resize: true}
+	w.waves[0] = wave{amplitude: 0.1, length: 8}
+	w.color = lin.V4{X: 0.05, Y: 0.2, Z: 0.3, W: 0.8}
+	ws.list[eid] = w
+	return w
+}
+
+// get the water for the given entity.
+func (ws *waters) get(eid eID) *water { return ws.list[eid] }
+
+// dispose removes the water and releases the reflection images.
+// The surface mesh is released with the water model.
+func (ws *waters) dispose(app *application, eid eID) {
+	if w, ok := ws.list[eid]; ok {
+		ws.dropViews(app, w)
+		delete(ws.list, eid)
+	}
+}
+
+// dropViews releases the water reflection images.
+func (ws *waters) dropViews(app *application, w *water) {
+	for _, v := range w.views {
+		app.ld.dropTarget(v.scene.fbo)
+	}
+	w.views = nil
+}
+
+// loadViews creates the water reflection images at the requested
+// size, replacing any existing images.
+func (ws *waters) loadViews(app *application, rc render.Loader, eid eID, w *water) (err error) {
+	ws.dropViews(app, w)
+	if w.viewSize <= 0 {
+		return nil
+	}
+	size, scene := uint32(w.viewSize), sceneRoot(app.povs, eid)
+	for _, sampler := range waterSamplers {
+		target, tid, err := rc.LoadTarget(size, size)
+		if err != nil {
+			ws.dropViews(app, w)
+			return fmt.Errorf("LoadTarget %s: %w", sampler, err)
+		}
+		name := fmt.Sprintf("water%d_%s", eid, sampler)
+		w.views = append(w.views, newWaterView(scene, target, tid, name, w.viewSize))
+	}
+	return nil
+}
+
+// bind gives the water shader reflection and refraction samplers the
+// water reflection images, or the default texture if the water has no
+// reflection images. Shaders without the samplers are left alone.
+func (ws *waters) bind(app *application, m *model, w *water) {
+	if m.shader == nil || m.shader.config == nil {
+		return
+	}
+	for i, sampler := range waterSamplers {
+		if !slices.ContainsFunc(m.shader.config.Uniforms, func(u load.ShaderUniform) bool {
+			return u.DataType == load.DataType_SAMPLER && u.Name == sampler
+		}) {
+			continue
+		}
+		t, _ := app.ld.assets[assetID(tex, "test")].(*texture) // any texture, the shader ignores it.
+		if len(w.views) == len(waterSamplers) {
+			t = w.views[i].tex
+		}
+		if t != nil {
+			m.setSampler(sampler, t)
+		}
+	}
+}
+
+// views adds the reflection and refraction cameras for the waters seen by
+// the given scene camera, updating the cameras to match the scene camera.
+// The parts are the sorted scene parts seen by the scene camera.
+func (ws *waters) views(app *application, sc *scene, parts []uint32, views []*scene) []*scene {
+	for eid, w := range ws.list {
+		index, ok := app.povs.index[eid]
+		if len(w.views) != len(waterSamplers) || !ok {
+			continue
+		}
+		if _, seen := slices.BinarySearch(parts, index); !seen {
+			continue // water is not in view.
+		}
+		h, crest := app.povs.povs[index].tw.Loc.Y, w.crest()
+		w.views[0].reflect(sc, h, crest)
+		w.views[1].refract(sc, h, crest)
+		views = append(views, w.views[0].scene, w.views[1].scene)
+	}
+	return views
+}
+
+// update advances the waves and passes the water settings to the water
+// shader. The surface mesh is generated the first time the water is
+// updated. Called by the engine once each update.
+func (ws *waters) update(app *application, rc render.Loader, delta time.Duration) {
+	for eid, w := range ws.list {
+		m := app.models.get(eid)
+		if m == nil {
+			continue
+		}
+		w.elapsed += delta
+		if w.viewsChanged() {
+			if err := ws.loadViews(app, rc, eid, w); err != nil {
+				slog.Error("water reflections", "error", err)
+				w.viewSize = 0 // don't retry.
+			}
+		}
+		ws.bind(app, m, w)
+		if m.mesh == nil {
+			mid, err := rc.LoadMesh(w.meshData())
+			if err != nil {
+				slog.Error("water LoadMesh", "error", err)
+				delete(ws.list, eid)
+				continue
+			}
+			m.mesh = newMesh("water")
+			m.mesh.mid = mid
+			m.mesh.generated = true
+		}
+		if w.resize {
+			// bounds without triangles so that the water
+			// is culled but not picked or covered by decals.
+			half, crest := w.size*0.5, w.crest()
+			m.mesh.lo = lin.V3{X: -half, Y: -crest, Z: -half}
+			m.mesh.hi = lin.V3{X: half, Y: crest, Z: half}
+			m.mesh.bounded, w.resize = true, false
+			app.spatial.refresh(eid)
+		}
+		(&Entity{app: app, eid: eid}).SetModelUniform("args16", w.uniforms())
+	}
+}
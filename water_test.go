@@ -0,0 +1,132 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gazed/vu/load"
+	"github.com/gazed/vu/math/lin"
+	"github.com/gazed/vu/render"
+)
+
+// go test -run Water
+func TestWater(t *testing.T) {
+	rc := &mrc{} // mock render context.
+	app := newApplication()
+	app.ld.loadDefaultAssets(rc) // direct loads (no goroutine)
+	scene := app.addScene(Scene3D)
+	sea := scene.AddWater(20, 10, "shd:water").SetAt(0, 2, 0)
+	app.povs.setWorldMatrix(app.work, 0)
+	w := app.waters.get(sea.eid)
+
+	// go test -run Water/mesh
+	t.Run("mesh", func(t *testing.T) {
+		md := w.meshData()
+		if md[load.Vertexes].Count != 11*11 || md[load.Indexes].Count != 10*10*6 {
+			t.Errorf("expected surface grid got %d %d", md[load.Vertexes].Count, md[load.Indexes].Count)
+		}
+		app.waters.update(app, rc, time.Second)
+		m := app.models.get(sea.eid)
+		if m.mesh == nil || !m.mesh.bounded || len(m.mesh.faces) != 0 || !lin.Aeq(m.mesh.hi.Y, 0.1) {
+			t.Errorf("expected bounded surface without triangles")
+		}
+		if m.queue != QueueTransparent || len(m.uniforms[load.ARGS16]) != 64 {
+			t.Errorf("expected transparent water with wave uniforms")
+		}
+	})
+
+	// go test -run Water/waves
+	t.Run("waves", func(t *testing.T) {
+		sea.SetWave(0, 90, 0.5, 4).SetWave(1, 0, 0, 1)
+		w.elapsed = 0
+		if y, ok := sea.WaterHeight(3, 1); !ok || !lin.Aeq(y, 2+0.5) {
+			t.Errorf("expected wave crest got %f %t", y, ok) // quarter wave along z.
+		}
+		if y, _ := sea.WaterHeight(3, 3); !lin.Aeq(y, 2-0.5) {
+			t.Errorf("expected wave trough got %f", y)
+		}
+		if _, ok := sea.WaterHeight(11, 0); ok {
+			t.Errorf("expected off the water")
+		}
+		period := 2 * math.Pi / math.Sqrt(waterGravity*2*math.Pi/4)
+		w.elapsed = time.Duration(period * float64(time.Second))
+		if y, _ := sea.WaterHeight(3, 1); !lin.Aeq(y, 2.5) {
+			t.Errorf("expected wave to repeat got %f", y)
+		}
+		if args := w.uniforms(); args[0] != 90 || args[4] != 0.5 || args[8] != 4 || args[15] != 0.8 {
+			t.Errorf("expected packed wave uniforms got %v", args)
+		}
+	})
+	// go test -run Water/reflections
+	t.Run("reflections", func(t *testing.T) {
+		m := app.models.get(sea.eid)
+		m.shader = newShader("water")
+		m.shader.config = &load.Shader{Uniforms: []load.ShaderUniform{
+			{Name: "reflection", DataType: load.DataType_SAMPLER},
+			{Name: "refraction", DataType: load.DataType_SAMPLER},
+		}}
+		app.waters.update(app, rc, 0)
+		if len(m.texs) != 2 || m.samplerMap["reflection"] != "test" || w.uniforms()[7] < 0 {
+			t.Errorf("expected default textures without reflections got %v", m.samplerMap)
+		}
+		sea.SetWaterReflections(8).SetWaterReflections(4096) // logs errors.
+		if w.viewSize != 0 {
+			t.Errorf("expected invalid sizes to be ignored got %d", w.viewSize)
+		}
+		sea.SetWaterReflections(64)
+		app.waters.update(app, rc, 0)
+		if len(w.views) != 2 || w.views[0].tex.w != 64 || w.views[1].scene.fbo == 0 {
+			t.Fatalf("expected reflection and refraction targets")
+		}
+		if len(m.texs) != 2 || m.samplerMap["refraction"] != w.views[1].tex.label() || w.uniforms()[7] >= 0 {
+			t.Errorf("expected reflection textures got %v", m.samplerMap)
+		}
+
+		// the reflection camera is mirrored in the water and only
+		// draws the scene above the water.
+		sc := app.scenes.get(scene.eid)
+		sc.setProjection(800, 600)
+		sc.cam.SetAt(0, 5, 10).updateView()
+		views := app.waters.views(app, sc, []uint32{app.povs.index[sea.eid]}, nil)
+		if len(views) != 2 {
+			t.Fatalf("expected two water views got %d", len(views))
+		}
+		if _, y, _ := views[0].cam.At(); !lin.Aeq(y, 2*2-5) {
+			t.Errorf("expected mirrored camera got %f", y)
+		}
+		depth := func(cam *Camera, x, y, z float64) float64 {
+			p := &lin.V4{X: x, Y: y, Z: z, W: 1}
+			p.MultvM(p, cam.vm).MultvM(p, cam.pm)
+			return p.Z / p.W
+		}
+		crest := w.crest()
+		if d := depth(views[0].cam, 1, 2-crest, -5); !lin.Aeq(d, 0) {
+			t.Errorf("expected reflection near plane on the water got %f", d)
+		}
+		if d := depth(views[0].cam, 1, 2-crest-0.5, -5); d >= 0 {
+			t.Errorf("expected reflection to clip below the water got %f", d)
+		}
+		if d := depth(views[1].cam, 1, 2+crest, -5); !lin.Aeq(d, 0) {
+			t.Errorf("expected refraction near plane on the water got %f", d)
+		}
+		if d := depth(views[1].cam, 1, 2+crest+0.5, -5); d >= 0 {
+			t.Errorf("expected refraction to clip above the water got %f", d)
+		}
+
+		// the water views are drawn into render targets after the scene.
+		passes := app.scenes.getFrame(app, 0, app.frame)
+		if len(passes) != 4 || passes[2].Target == 0 || passes[3].Target == 0 || passes[render.Pass3D].Target != 0 {
+			t.Errorf("expected two render target passes got %d", len(passes))
+		}
+
+		// turning reflections off releases the render targets.
+		sea.SetWaterReflections(0)
+		app.waters.update(app, rc, 0)
+		if len(w.views) != 0 || len(app.ld.dropTargs) != 2 || m.samplerMap["reflection"] != "test" {
+			t.Errorf("expected reflections to be released")
+		}
+	})
+}
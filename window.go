@@ -0,0 +1,138 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// window.go shows scenes in additional windows. Tools like level editors
+// can show a main viewport along with other views of the same world, eg:
+//
+//	top, err := eng.OpenWindow("top view", 1300, 100, 400, 300)
+//	view := eng.AddScene(vu.Scene3D).SetWindow(top)
+//	view.Cam().SetAt(0, 50, 0).SetPitch(-90)
+//
+// Each window shows 3D and 2D scenes, with several scenes drawn into
+// parts of the window using viewports, see viewport.go. Windows share
+// the loaded assets and the engine input. A window closed by the user
+// is closed by the engine and its scenes are no longer drawn.
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gazed/vu/render"
+)
+
+// OpenWindow creates an additional bordered window where x,y is the
+// upper left corner and w,h is the window size in pixels. Returns the
+// window ID used to assign scenes to the window, see Entity.SetWindow.
+func (eng *Engine) OpenWindow(title string, x, y, w, h int32) (win uint32, err error) {
+	if win, err = eng.dev.OpenWindow(title, x, y, w, h); err != nil {
+		return 0, fmt.Errorf("OpenWindow: %w", err)
+	}
+	if err = eng.rc.AddWindow(win); err != nil {
+		eng.dev.CloseWindow(win)
+		return 0, fmt.Errorf("OpenWindow: %w", err)
+	}
+	aw := &appWindow{id: win, frame: []render.Pass{render.NewPass(), render.NewPass()}}
+	aw.w, aw.h = eng.rc.WindowSize(win)
+	eng.app.windows = append(eng.app.windows, aw)
+	return win, nil
+}
+
+// CloseWindow closes a window opened with OpenWindow.
+// The window scenes are no longer drawn.
+func (eng *Engine) CloseWindow(win uint32) {
+	for i, aw := range eng.app.windows {
+		if aw.id == win {
+			eng.app.windows = append(eng.app.windows[:i], eng.app.windows[i+1:]...)
+			eng.rc.DropWindow(win)
+			eng.dev.CloseWindow(win)
+			return
+		}
+	}
+}
+
+// WindowOpen returns true if the given window is open.
+// Window 0 is the main window.
+func (eng *Engine) WindowOpen(win uint32) bool {
+	if win == 0 {
+		return eng.running
+	}
+	return eng.app.window(win) != nil
+}
+
+// WindowCursor returns the mouse location relative to the upper left
+// corner of the given window. Input mouse locations are relative
+// to the main window.
+func (eng *Engine) WindowCursor(win uint32) (mx, my int32) {
+	return eng.dev.WindowCursor(win)
+}
+
+// SetWindow shows the scene and its views in the given window. Window 0
+// is the main window. Scenes sharing a window use SetViewport to draw
+// into different parts of the window.
+//
+// Depends on Eng.AddScene. Returns the scene entity.
+func (e *Entity) SetWindow(win uint32) *Entity {
+	if s := e.app.scenes.get(e.eid); s != nil {
+		s.win = win
+		s.cam.focus = true // update the projection for the window size.
+		for _, v := range s.views {
+			v.win, v.cam.focus = win, true
+		}
+		return e
+	}
+	slog.Error("SetWindow needs AddScene", "eid", e.eid)
+	return e
+}
+
+// =============================================================================
+// application windows
+
+// appWindow tracks an additional window and its render frame.
+type appWindow struct {
+	id    uint32        // device and render window ID.
+	w, h  uint32        // render surface size.
+	frame []render.Pass // window render passes, reused each frame.
+}
+
+// window returns the open window with the given ID, nil if there is none.
+func (app *application) window(win uint32) *appWindow {
+	for _, aw := range app.windows {
+		if aw.id == win {
+			return aw
+		}
+	}
+	return nil
+}
+
+// drawWindows renders the scenes shown in the additional windows.
+// Windows closed by the user are closed and minimized windows are skipped.
+func (eng *Engine) drawWindows(delta time.Duration) {
+	for i := len(eng.app.windows) - 1; i >= 0; i-- {
+		aw := eng.app.windows[i]
+		if !eng.dev.WindowOpen(aw.id) {
+			eng.CloseWindow(aw.id) // closed by the user.
+			continue
+		}
+		w, h := eng.dev.WindowSize(aw.id)
+		if w == 0 || h == 0 {
+			continue // minimized.
+		}
+		if w != aw.w || h != aw.h {
+			aw.w, aw.h = w, h
+			eng.rc.ResizeWindow(aw.id, w, h)
+			eng.app.scenes.resize(aw.id, w, h)
+		}
+		eng.app.scenes.setViewMatrixes(aw.id, w, h)
+		aw.frame = eng.app.scenes.getFrame(eng.app, aw.id, aw.frame)
+		if err := eng.rc.DrawWindow(aw.id, aw.frame, delta); err != nil {
+			if errors.Is(err, ErrDeviceLost) {
+				eng.renderFailed(err)
+				return
+			}
+			slog.Error("window render failed", "window", aw.id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// workers.go spreads the per-frame engine update across worker goroutines.
+// Transform copies, animation sampling, and camera distance culling are
+// split into ranges that are run in parallel. Transforms moved by physics
+// are propagated to their children in parallel, one independent subtree
+// per job. Transforms changed by the application are propagated
+// immediately on the calling goroutine. Applications can use the
+// same workers for their own updates, eg:
+//
+//	eng.Parallel(len(boids), func(start, end int) {
+//		for i := start; i < end; i++ {
+//			boids[i].flock(neighbours)
+//		}
+//	})
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// SetWorkers sets the number of goroutines used for parallel updates,
+// including the calling goroutine. 1 runs all updates on the calling
+// goroutine. The default is the number of usable CPUs.
+func (eng *Engine) SetWorkers(n int) {
+	eng.app.work.resize(n)
+}
+
+// Parallel calls job with ranges of [0:count) on the worker goroutines
+// and returns once all ranges are done. Jobs must only change data for
+// their own range. Jobs must not create or dispose entities, change
+// transforms, or call Parallel.
+func (eng *Engine) Parallel(count int, job func(start, end int)) {
+	eng.app.work.run(count, 1, job)
+}
+
+// =============================================================================
+// workers runs jobs on a pool of goroutines.
+
+// workJob is a range of a parallel job.
+type workJob struct {
+	call       func(start, end int)
+	start, end int
+	done       *sync.WaitGroup
+}
+
+// workers runs parallel jobs. The goroutines are started
+// when first needed and stopped by dispose.
+type workers struct {
+	n    int          // number of goroutines including the caller.
+	jobs chan workJob // job ranges for the worker goroutines.
+	done sync.WaitGroup
+}
+
+// newWorkers creates a worker pool that uses all available CPUs.
+func newWorkers() *workers {
+	return &workers{n: runtime.GOMAXPROCS(0)}
+}
+
+// resize sets the number of goroutines, restarting
+// the worker goroutines when they are next needed.
+func (w *workers) resize(n int) {
+	if n < 1 {
+		slog.Error("SetWorkers needs at least 1 worker", "workers", n)
+		n = 1
+	}
+	w.dispose()
+	w.n = n
+}
+
+// run calls fn with ranges covering count items, where each range has
+// at least minBatch items. The last range is run by the caller.
+func (w *workers) run(count, minBatch int, fn func(start, end int)) {
+	batches := min(w.n, count/max(minBatch, 1))
+	if batches <= 1 {
+		if count > 0 {
+			fn(0, count)
+		}
+		return
+	}
+	if w.jobs == nil {
+		w.jobs = make(chan workJob, w.n)
+		for i := 1; i < w.n; i++ {
+			go worker(w.jobs)
+		}
+	}
+	size := (count + batches - 1) / batches
+	start := 0
+	for ; start+size < count; start += size {
+		w.done.Add(1)
+		w.jobs <- workJob{call: fn, start: start, end: start + size, done: &w.done}
+	}
+	fn(start, count)
+	w.done.Wait()
+}
+
+// worker runs job ranges until the jobs channel is closed.
+func worker(jobs chan workJob) {
+	for job := range jobs {
+		job.call(job.start, job.end)
+		job.done.Done()
+	}
+}
+
+// dispose stops the worker goroutines.
+func (w *workers) dispose() {
+	if w.jobs != nil {
+		close(w.jobs)
+		w.jobs = nil
+	}
+}
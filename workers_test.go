@@ -0,0 +1,45 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// go test -run Workers
+func TestWorkers(t *testing.T) {
+	eng := &Engine{app: &application{work: newWorkers()}}
+	defer eng.app.work.dispose()
+	t.Run("ranges", func(t *testing.T) {
+		for _, workers := range []int{1, 3, 8} {
+			eng.SetWorkers(workers)
+			for _, count := range []int{0, 1, 7, 100, 1001} {
+				seen := make([]int32, count)
+				calls := int32(0)
+				eng.Parallel(count, func(start, end int) {
+					atomic.AddInt32(&calls, 1)
+					for i := start; i < end; i++ {
+						seen[i]++
+					}
+				})
+				for i, cnt := range seen {
+					if cnt != 1 {
+						t.Fatalf("workers %d count %d: item %d seen %d times", workers, count, i, cnt)
+					}
+				}
+				if int(calls) > workers {
+					t.Errorf("expected at most %d ranges got %d", workers, calls)
+				}
+			}
+		}
+	})
+	t.Run("min batch", func(t *testing.T) {
+		eng.SetWorkers(4)
+		calls := int32(0)
+		eng.app.work.run(1000, 1024, func(start, end int) { atomic.AddInt32(&calls, 1) })
+		if calls != 1 {
+			t.Errorf("expected small jobs on the caller got %d ranges", calls)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+// worldgen.go provides a structure for procedural world generation.
+// Worlds are generated in chunks by running an ordered list of stages
+// on each chunk. Chunks are seeded from the world seed and the chunk
+// coordinates so that the same world is generated every time.
+//
+// FUTURE: provide stages for noise, grid, terrain, and voxel generation
+//         once those engine packages exist.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	"github.com/gazed/vu/math/lin"
+)
+
+// Chunk holds the data for one generated piece of the world.
+// Stages read the results of earlier stages and add their own.
+type Chunk struct {
+	X, Y, Z int64 // Chunk grid coordinates.
+	Seed    int64 // Deterministic seed from the world seed and coordinates.
+
+	// Rand is reseeded before each stage from the chunk seed and the
+	// stage name. Stages get the same random numbers regardless of
+	// what other stages are in the pipeline.
+	Rand *rand.Rand
+
+	// Data holds stage results keyed by a stage chosen name.
+	Data map[string]any
+
+	// Err is set if a stage failed while generating the chunk.
+	Err error
+}
+
+// GenStage generates part of a world chunk. It is called with a chunk
+// that has been processed by all earlier stages.
+//
+// Stages are run from worker goroutines and must not access engine
+// entities. Use the completed chunks on the main thread to create
+// models and physics bodies.
+type GenStage func(c *Chunk) error
+
+// WorldGen runs an ordered list of generation stages on world chunks.
+// Chunks can be generated immediately or queued for generation on
+// worker goroutines while the game streams in new areas.
+//
+//	wg := vu.NewWorldGen(seed)
+//	wg.AddStage("height", heightStage).AddStage("trees", treeStage)
+//	wg.Start(2)             // start async workers.
+//	wg.Request(0, 0, 0)     // queue chunk generation...
+//	chunks := wg.Completed() // ...and collect chunks each update.
+type WorldGen struct {
+	seed   int64      // world seed.
+	stages []genStage // ordered generation stages.
+
+	// async generation.
+	requests  chan lin.V3i // chunk coordinates to generate.
+	completed chan *Chunk  // generated chunks.
+	queued    []lin.V3i    // requests waiting for space in the channel.
+	pending   map[lin.V3i]bool
+	workers   sync.WaitGroup
+}
+
+// genStage pairs a stage with its name based seed.
+type genStage struct {
+	name  string
+	seed  int64
+	stage GenStage
+}
+
+// NewWorldGen creates a world generation pipeline where
+// all generated chunks are derived from the given seed.
+func NewWorldGen(seed int64) *WorldGen {
+	return &WorldGen{seed: seed, pending: map[lin.V3i]bool{}}
+}
+
+// AddStage appends a generation stage to the pipeline.
+// Stages are run in the order they are added. Stage names
+// must be unique since they are used to seed each stage.
+func (wg *WorldGen) AddStage(name string, stage GenStage) *WorldGen {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	wg.stages = append(wg.stages, genStage{name: name, seed: int64(h.Sum64()), stage: stage})
+	return wg
+}
+
+// Generate runs all the stages for the given chunk coordinates on the
+// calling goroutine. The generated chunk is returned along with the
+// first stage error.
+func (wg *WorldGen) Generate(x, y, z int64) (*Chunk, error) {
+	c := &Chunk{X: x, Y: y, Z: z, Data: map[string]any{}}
+	c.Seed = chunkSeed(wg.seed, x, y, z)
+	c.Rand = rand.New(rand.NewSource(c.Seed))
+	for _, s := range wg.stages {
+		c.Rand.Seed(c.Seed ^ s.seed)
+		if err := s.stage(c); err != nil {
+			c.Err = fmt.Errorf("worldgen stage %s chunk %d:%d:%d: %w", s.name, x, y, z, err)
+			return c, c.Err
+		}
+	}
+	return c, nil
+}
+
+// Start launches the given number of worker goroutines that generate
+// requested chunks. Call Dispose to stop the workers.
+func (wg *WorldGen) Start(workers int) {
+	if wg.requests != nil {
+		return // already started.
+	}
+	wg.requests = make(chan lin.V3i, 100)
+	wg.completed = make(chan *Chunk, 100)
+	for i := 0; i < max(1, workers); i++ {
+		wg.workers.Add(1)
+		go func() {
+			defer wg.workers.Done()
+			for at := range wg.requests {
+				c, _ := wg.Generate(at.X, at.Y, at.Z)
+				wg.completed <- c
+			}
+		}()
+	}
+}
+
+// Request queues the chunk at the given coordinates for generation
+// on the worker goroutines. Requests for chunks that are already
+// being generated are ignored. Request does not block: requests that
+// don't fit in the worker queue are passed on by later calls to
+// Completed. Expected to be called from the main thread after Start.
+func (wg *WorldGen) Request(x, y, z int64) {
+	if wg.requests == nil {
+		return // not started.
+	}
+	at := lin.V3i{X: x, Y: y, Z: z}
+	if wg.pending[at] {
+		return
+	}
+	wg.pending[at] = true
+	wg.queued = append(wg.queued, at)
+	wg.feed()
+}
+
+// feed passes queued requests to the workers until the worker
+// queue is full.
+func (wg *WorldGen) feed() {
+	for len(wg.queued) > 0 {
+		select {
+		case wg.requests <- wg.queued[0]:
+			wg.queued = wg.queued[1:]
+		default:
+			return
+		}
+	}
+}
+
+// Completed returns the chunks that have finished generating since the
+// last call without blocking. Chunks with a non-nil Err failed a stage.
+// Expected to be called from the main thread, ie: each Update.
+func (wg *WorldGen) Completed() (chunks []*Chunk) {
+	for wg.completed != nil {
+		select {
+		case c := <-wg.completed:
+			delete(wg.pending, lin.V3i{X: c.X, Y: c.Y, Z: c.Z})
+			chunks = append(chunks, c)
+		default:
+			wg.feed()
+			return chunks
+		}
+	}
+	return chunks
+}
+
+// Pending returns the number of requested chunks that have
+// not yet been returned by Completed.
+func (wg *WorldGen) Pending() int { return len(wg.pending) }
+
+// Dispose stops the worker goroutines once the requests passed to the
+// workers have been generated. Queued requests are dropped.
+func (wg *WorldGen) Dispose() {
+	if wg.requests != nil {
+		close(wg.requests)
+		go func(completed chan *Chunk) {
+			// drain so workers don't block on a full channel.
+			for range completed {
+			}
+		}(wg.completed)
+		wg.workers.Wait()
+		close(wg.completed)
+		wg.requests, wg.completed, wg.queued = nil, nil, nil
+		clear(wg.pending)
+	}
+}
+
+// chunkSeed mixes the world seed with the chunk coordinates using
+// the splitmix64 finalizer so that neighbouring chunks get
+// unrelated seeds.
+func chunkSeed(seed, x, y, z int64) int64 {
+	h := uint64(seed)
+	for _, v := range []int64{x, y, z} {
+		h ^= uint64(v) + 0x9E3779B97F4A7C15 + (h << 6) + (h >> 2)
+		h ^= h >> 30
+		h *= 0xBF58476D1CE4E5B9
+		h ^= h >> 27
+		h *= 0x94D049BB133111EB
+		h ^= h >> 31
+	}
+	return int64(h)
+}
@@ -0,0 +1,84 @@
+// Copyright © 2024 Galvanized Logic Inc.
+
+package vu
+
+import (
+	"fmt"
+	"testing"
+)
+
+// go test -run WorldGen
+func TestWorldGen(t *testing.T) {
+	height := func(c *Chunk) error {
+		c.Data["height"] = c.Rand.Intn(100)
+		return nil
+	}
+	trees := func(c *Chunk) error {
+		c.Data["trees"] = c.Data["height"].(int) / 10
+		return nil
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		wg1 := NewWorldGen(42).AddStage("height", height).AddStage("trees", trees)
+		wg2 := NewWorldGen(42).AddStage("height", height)
+		c1, _ := wg1.Generate(1, 0, -3)
+		c2, _ := wg2.Generate(1, 0, -3)
+		if c1.Seed != c2.Seed || c1.Data["height"] != c2.Data["height"] {
+			t.Errorf("expected same chunk got %v %v", c1.Data, c2.Data)
+		}
+		if c1.Data["trees"] != c1.Data["height"].(int)/10 {
+			t.Errorf("expected stages run in order")
+		}
+		c3, _ := wg1.Generate(1, 0, -2)
+		if c1.Seed == c3.Seed {
+			t.Errorf("expected different seeds for different chunks")
+		}
+	})
+
+	t.Run("stage error", func(t *testing.T) {
+		fail := func(c *Chunk) error { return fmt.Errorf("fail") }
+		wg := NewWorldGen(1).AddStage("fail", fail).AddStage("height", height)
+		c, err := wg.Generate(0, 0, 0)
+		if err == nil || c.Err == nil || c.Data["height"] != nil {
+			t.Errorf("expected stage error to stop generation")
+		}
+	})
+
+	t.Run("async", func(t *testing.T) {
+		wg := NewWorldGen(7).AddStage("height", height)
+		wg.Start(2)
+		for x := int64(0); x < 10; x++ {
+			wg.Request(x, 0, 0)
+			wg.Request(x, 0, 0) // duplicates ignored.
+		}
+		chunks := []*Chunk{}
+		for wg.Pending() > 0 {
+			chunks = append(chunks, wg.Completed()...)
+		}
+		wg.Dispose()
+		if len(chunks) != 10 {
+			t.Fatalf("expected 10 chunks got %d", len(chunks))
+		}
+		for _, c := range chunks {
+			if sc, _ := wg.Generate(c.X, c.Y, c.Z); sc.Data["height"] != c.Data["height"] {
+				t.Errorf("expected async chunks to match sync chunks")
+			}
+		}
+	})
+
+	t.Run("many requests", func(t *testing.T) {
+		wg := NewWorldGen(7).AddStage("height", height)
+		wg.Start(2)
+		for x := int64(0); x < 500; x++ {
+			wg.Request(x, 0, 0) // more than the workers can queue.
+		}
+		count := 0
+		for wg.Pending() > 0 {
+			count += len(wg.Completed())
+		}
+		wg.Dispose()
+		if count != 500 {
+			t.Errorf("expected 500 chunks got %d", count)
+		}
+	})
+}